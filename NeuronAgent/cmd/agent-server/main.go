@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -17,94 +18,310 @@ import (
 	"github.com/neurondb/NeuronAgent/internal/db"
 	"github.com/neurondb/NeuronAgent/internal/jobs"
 	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/internal/secrets"
 	"github.com/neurondb/NeuronAgent/internal/session"
 	"github.com/neurondb/NeuronAgent/internal/tools"
+	"github.com/neurondb/NeuronAgent/internal/webhooks"
 	"github.com/neurondb/NeuronAgent/pkg/neurondb"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.DefaultConfig()
-	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
-		var err error
-		cfg, err = config.LoadConfig(configPath)
-		if err != nil {
-			fmt.Printf("Failed to load config: %v, using defaults\n", err)
-		}
-	} else {
-		// Load from environment variables if no config file
-		config.LoadFromEnv(cfg)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
 	}
 
+	cfg := loadConfig()
+
 	// Initialize logging
 	metrics.InitLogging(cfg.Logging.Level, cfg.Logging.Format)
 
-	// Connect to database
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.Database)
-
-	connMaxIdleTime := 10 * time.Minute
-	if cfg.Database.ConnMaxIdleTime > 0 {
-		connMaxIdleTime = cfg.Database.ConnMaxIdleTime
-	}
-	
-	database, err := db.NewDB(connStr, db.PoolConfig{
-		MaxOpenConns:    cfg.Database.MaxOpenConns,
-		MaxIdleConns:    cfg.Database.MaxIdleConns,
-		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
-		ConnMaxIdleTime: connMaxIdleTime,
-	})
+	database, err := connectDatabase(cfg)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to connect to database: %v", err))
 	}
 	defer database.Close()
 
-	// Run migrations
-	migrationRunner, err := db.NewMigrationRunner(database.DB, "./migrations")
-	if err == nil {
-		if err := migrationRunner.Run(context.Background()); err != nil {
-			fmt.Printf("Warning: Migration failed: %v\n", err)
-		}
+	// Run migrations compiled into the binary; an advisory lock keeps
+	// concurrently starting servers from applying them twice
+	migrationRunner, err := db.NewEmbeddedMigrationRunner(database.DB)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load migrations: %v", err))
+	}
+	applied, err := migrationRunner.Run(context.Background())
+	if err != nil {
+		panic(fmt.Sprintf("Migration failed: %v", err))
+	}
+	for _, m := range applied {
+		fmt.Printf("Applied migration %03d_%s\n", m.Version, m.Name)
 	}
 
+	// Export pool statistics and, when enabled, resize the pool to its load
+	poolMonitor, err := db.NewPoolMonitor(database)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid database pool configuration: %v", err))
+	}
+	poolMonitor.Start()
+	defer poolMonitor.Stop()
+
 	// Initialize components
 	queries := db.NewQueries(database.DB)
 	queries.SetConnInfoFunc(database.GetConnInfoString)
+	queries.SetPoolMonitor(poolMonitor)
 	embedClient := neurondb.NewEmbeddingClient(database.DB)
 	toolRegistry := tools.NewRegistry(queries, database)
 	runtime := agent.NewRuntime(database, queries, toolRegistry, embedClient)
+	runtime.SetRunLimits(agent.RunLimits{
+		SessionQueueTimeout: durationOrDefault(cfg.Runs.SessionQueueTimeout, 30*time.Second),
+		MaxRunsPerAgent:     cfg.Runs.MaxConcurrentPerAgent,
+		RetryAfter:          durationOrDefault(cfg.Runs.RetryAfter, 2*time.Second),
+	})
+	runtime.SetRunRecovery(agent.RunRecovery{
+		HeartbeatInterval: durationOrDefault(cfg.Runs.HeartbeatInterval, 15*time.Second),
+		StaleAfter:        durationOrDefault(cfg.Runs.StaleAfter, 2*time.Minute),
+		RecoveryInterval:  durationOrDefault(cfg.Runs.RecoveryInterval, 30*time.Second),
+		MaxResumeAttempts: cfg.Runs.MaxResumeAttempts,
+	})
+
+	// Probe for NeuronDB's ML functions. Missing ones are not called, and
+	// the fallback providers serve in their place.
+	capabilities, err := neurondb.DetectCapabilities(context.Background(), database.DB)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to detect NeuronDB capabilities: %v", err))
+	}
+	runtime.SetCapabilities(capabilities)
+	embeddingProvider, err := fallbackEmbeddingProvider(cfg.Fallback.Embedding)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid fallback embedding provider: %v", err))
+	}
+	if embeddingProvider != nil {
+		embedClient.SetProvider(embeddingProvider)
+	}
+	if llm := cfg.Fallback.LLM; llm.Type != "" {
+		provider, err := agent.NewFallbackLLMProvider(llm.Type, llm.Model, llm.Endpoint, llm.APIKeyEnv, llm.Timeout)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid fallback LLM provider: %v", err))
+		}
+		runtime.SetFallbackLLM(provider)
+	}
+	if missing := capabilities.Missing(); len(missing) > 0 {
+		report := runtime.Capabilities()
+		metrics.Logger().Warn().
+			Strs("missing_functions", missing).
+			Str("embedding", report.Embedding).
+			Str("llm", report.LLM).
+			Bool("memory_enabled", report.MemoryEnabled).
+			Msg("NeuronDB functions are missing; running degraded")
+	}
+
+	// Short-term agent memory in Redis, for agents whose config selects it
+	if cfg.Memory.RedisURL != "" {
+		redisMemory, err := agent.NewRedisMemoryStore(cfg.Memory.RedisURL)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to configure Redis memory: %v", err))
+		}
+		defer redisMemory.Close()
+		pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := redisMemory.Ping(pingCtx); err != nil {
+			// Agents using Redis memory fail until it is reachable
+			metrics.Logger().Warn().Err(err).Msg("Redis memory is not reachable")
+		}
+		cancel()
+		runtime.RegisterMemoryStore(agent.MemoryBackendRedis, redisMemory)
+	}
+
+	// Sampled LLM calls of agents logging to the file sink
+	if cfg.LLMLogs.File != "" {
+		llmLogFile, err := agent.NewLLMLogFile(cfg.LLMLogs.File)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to configure LLM call log: %v", err))
+		}
+		defer llmLogFile.Close()
+		runtime.SetLLMLogFile(llmLogFile)
+	}
 
 	// Initialize session management
-	sessionCache := session.NewCache(5 * time.Minute)
+	sessionCache := session.NewCache(durationOrDefault(cfg.Session.CacheTTL, 5*time.Minute))
 	_ = session.NewManager(queries, sessionCache) // Session manager for future use
-	sessionCleanup := session.NewCleanupService(queries, 1*time.Hour, 24*time.Hour)
+
+	// Archive and purge idle sessions
+	sessionRetainer := session.NewRetainer(queries,
+		session.NewRetentionPolicy(cfg.Session.ArchiveAfter, cfg.Session.PurgeAfter), cfg.Session.ArchiveDir)
+	sessionCleanup := session.NewCleanupService(queries, sessionRetainer, durationOrDefault(cfg.Session.CleanupInterval, 1*time.Hour))
 	sessionCleanup.Start()
 	defer sessionCleanup.Stop()
 
+	// Enforce per-agent memory retention policies
+	memoryEviction := agent.NewMemoryEvictionService(queries, 1*time.Hour)
+	memoryEviction.Start()
+	defer memoryEviction.Stop()
+
+	// Send queued webhook events
+	webhookDelivery := webhooks.NewDeliveryService(queries,
+		durationOrDefault(cfg.Webhooks.DeliveryInterval, 5*time.Second), cfg.Webhooks.MaxAttempts)
+	webhookDelivery.Start()
+	defer webhookDelivery.Stop()
+
+	// Resume runs interrupted by a server stopping
+	runRecovery := agent.NewRunRecoveryService(runtime, queries)
+	runRecovery.Start()
+	defer runRecovery.Stop()
+
 	// Initialize API
-	handlers := api.NewHandlers(queries, runtime)
+	memoryBackfiller := agent.NewMemoryBackfiller(database, queries, embedClient)
+	memoryReembedder := agent.NewMemoryReembedder(queries, embedClient)
+	uploadDir := cfg.Server.UploadDir
+	if uploadDir == "" {
+		uploadDir = filepath.Join(os.TempDir(), "neuronagent-uploads")
+	}
+	documentIngester, err := agent.NewDocumentIngester(queries, embedClient, uploadDir)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to configure document uploads: %v", err))
+	}
+	handlers := api.NewHandlers(queries, runtime, memoryBackfiller, memoryReembedder, documentIngester, toolRegistry, sessionRetainer)
+
+	// Background job worker, started once the server is set up
+	queue := jobs.NewQueue(queries)
+	processor := jobs.NewProcessor(database)
+	processor.Register(agent.MemoryBackfillJobType, memoryBackfiller.Run)
+	processor.Register(agent.MemoryReembedJobType, memoryReembedder.Run)
+	processor.Register(agent.DocumentIngestJobType, documentIngester.Run)
+	processor.Register(agent.ToolApprovalJobType, runtime.ResumeApproval)
+	worker := jobs.NewWorker(queue, processor, 5)
+
+	// Draining takes the server out of service ahead of a deploy
+	drainer := api.NewDrainer(runtime, worker, durationOrDefault(cfg.Server.DrainTimeout, 30*time.Second))
+	handlers.SetDrainer(drainer)
+	keyWrapper, err := secretsKeyWrapper(cfg.Secrets)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to configure secrets: %v", err))
+	}
+	if keyWrapper != nil {
+		secretStore := secrets.NewStore(queries, keyWrapper)
+		toolRegistry.SetSecrets(secretStore)
+		handlers.SetSecrets(secretStore)
+	}
 	keyManager := auth.NewAPIKeyManager(queries)
-	rateLimiter := auth.NewRateLimiter()
+	rateLimiter, err := auth.NewLimiter(cfg.Auth.RateLimit.Backend, queries)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to configure rate limiting: %v", err))
+	}
+	quotas := auth.NewQuotas(queries, organizationQuotas(cfg.Auth.RateLimit.OrganizationQuotas))
+	var oidcAuthenticator *auth.OIDCAuthenticator
+	if oidcConfig := oidcConfig(cfg.Auth.OIDC); oidcConfig.Enabled() {
+		oidcAuthenticator, err = auth.NewOIDCAuthenticator(queries, oidcConfig)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to configure OIDC authentication: %v", err))
+		}
+	}
 
 	// Setup router
 	router := mux.NewRouter()
 	router.Use(api.RequestIDMiddleware)
 	router.Use(api.CORSMiddleware)
 	router.Use(api.LoggingMiddleware)
-	router.Use(api.AuthMiddleware(keyManager, rateLimiter))
+	messageBodyBytes := bytesOrDefault(cfg.Server.MaxMessageBodyBytes, 64<<20)
+	router.Use(api.BodyLimitMiddleware(bytesOrDefault(cfg.Server.MaxBodyBytes, 1<<20), map[string]int64{
+		"/api/v1/sessions/{session_id}/messages": messageBodyBytes,
+		"/api/v1/sessions/import":                messageBodyBytes,
+		"/api/v1/agents/{id}/documents":          bytesOrDefault(cfg.Server.MaxUploadBytes, 256<<20),
+	}))
+	router.Use(api.AuthMiddleware(keyManager, oidcAuthenticator, rateLimiter, quotas))
 
 	// API routes
 	apiRouter := router.PathPrefix("/api/v1").Subrouter()
+	apiRouter.Use(api.OrganizationMiddleware(queries))
 	apiRouter.HandleFunc("/agents", handlers.CreateAgent).Methods("POST")
 	apiRouter.HandleFunc("/agents", handlers.ListAgents).Methods("GET")
+	apiRouter.HandleFunc("/agents/templates", handlers.ListAgentTemplates).Methods("GET")
+	apiRouter.HandleFunc("/agents/from-template", handlers.CreateAgentFromTemplate).Methods("POST")
 	apiRouter.HandleFunc("/agents/{id}", handlers.GetAgent).Methods("GET")
 	apiRouter.HandleFunc("/agents/{id}", handlers.UpdateAgent).Methods("PUT")
 	apiRouter.HandleFunc("/agents/{id}", handlers.DeleteAgent).Methods("DELETE")
+	apiRouter.HandleFunc("/agents/{id}/clone", handlers.CloneAgent).Methods("POST")
+	apiRouter.HandleFunc("/agents/{id}/memory", handlers.GetMemoryUsage).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/memory/evict", handlers.EvictMemory).Methods("POST")
+	apiRouter.HandleFunc("/agents/{id}/memory/search", handlers.SearchMemory).Methods("POST")
+	apiRouter.HandleFunc("/agents/{id}/messages/search", handlers.SearchMessages).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/memory/chunks", handlers.ListMemoryChunks).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/memory/chunks/{chunk_id}", handlers.GetMemoryChunk).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/memory/chunks/{chunk_id}", handlers.UpdateMemoryChunk).Methods("PATCH")
+	apiRouter.HandleFunc("/agents/{id}/memory/chunks/{chunk_id}", handlers.DeleteMemoryChunk).Methods("DELETE")
+	apiRouter.HandleFunc("/agents/{id}/memory/backfill", handlers.StartMemoryBackfill).Methods("POST")
+	apiRouter.HandleFunc("/agents/{id}/memory/backfill/{job_id}", handlers.GetMemoryBackfill).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/memory/reembed", handlers.StartMemoryReembed).Methods("POST")
+	apiRouter.HandleFunc("/agents/{id}/memory/reembed/{job_id}", handlers.GetMemoryReembed).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/documents", handlers.UploadDocument).Methods("POST")
+	apiRouter.HandleFunc("/agents/{id}/documents/{job_id}", handlers.GetDocumentUpload).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/usage", handlers.GetAgentUsage).Methods("GET")
+	apiRouter.HandleFunc("/agents/{id}/llm-logs", handlers.ListLLMLogs).Methods("GET")
+	apiRouter.HandleFunc("/usage", handlers.GetUsage).Methods("GET")
 	apiRouter.HandleFunc("/sessions", handlers.CreateSession).Methods("POST")
+	apiRouter.HandleFunc("/sessions/import", handlers.ImportSession).Methods("POST")
 	apiRouter.HandleFunc("/sessions/{id}", handlers.GetSession).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}", handlers.DeleteSession).Methods("DELETE")
+	apiRouter.HandleFunc("/sessions/{id}/export", handlers.ExportSession).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/summary", handlers.GetSessionSummary).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/trace", handlers.GetSessionTrace).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/archive", handlers.ArchiveSession).Methods("POST")
 	apiRouter.HandleFunc("/agents/{agent_id}/sessions", handlers.ListSessions).Methods("GET")
+	apiRouter.HandleFunc("/agents/{agent_id}/sessions/retention", handlers.ApplySessionRetention).Methods("POST")
 	apiRouter.HandleFunc("/sessions/{session_id}/messages", handlers.SendMessage).Methods("POST")
 	apiRouter.HandleFunc("/sessions/{session_id}/messages", handlers.GetMessages).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{session_id}/attachments", handlers.ListAttachments).Methods("GET")
+	apiRouter.HandleFunc("/messages/{id}/feedback", handlers.SubmitMessageFeedback).Methods("POST")
+	apiRouter.HandleFunc("/messages/{id}/feedback", handlers.GetMessageFeedback).Methods("GET")
+	apiRouter.HandleFunc("/messages/{id}/feedback", handlers.DeleteMessageFeedback).Methods("DELETE")
+	apiRouter.HandleFunc("/feedback", handlers.ListFeedback).Methods("GET")
+	apiRouter.HandleFunc("/feedback/export", handlers.ExportFeedback).Methods("GET")
+	apiRouter.HandleFunc("/feedback/prompt-versions", handlers.GetPromptVersionMetrics).Methods("GET")
+	apiRouter.HandleFunc("/prompt-templates", handlers.CreatePromptTemplate).Methods("POST")
+	apiRouter.HandleFunc("/prompt-templates", handlers.ListPromptTemplates).Methods("GET")
+	apiRouter.HandleFunc("/prompt-templates/{id}", handlers.GetPromptTemplate).Methods("GET")
+	apiRouter.HandleFunc("/prompt-templates/{id}", handlers.DeletePromptTemplate).Methods("DELETE")
+	apiRouter.HandleFunc("/prompt-templates/{id}/versions", handlers.CreatePromptTemplateVersion).Methods("POST")
+	apiRouter.HandleFunc("/prompt-templates/{id}/rollout", handlers.SetPromptTemplateRollout).Methods("PUT")
+	apiRouter.HandleFunc("/approvals", handlers.ListToolApprovals).Methods("GET")
+	apiRouter.HandleFunc("/approvals/{id}", handlers.GetToolApproval).Methods("GET")
+	apiRouter.HandleFunc("/approvals/{id}/approve", handlers.ApproveToolApproval).Methods("POST")
+	apiRouter.HandleFunc("/approvals/{id}/reject", handlers.RejectToolApproval).Methods("POST")
+
+	// Runs
+	apiRouter.HandleFunc("/runs/{id}", handlers.GetRun).Methods("GET")
+	apiRouter.HandleFunc("/webhooks", handlers.CreateWebhook).Methods("POST")
+	apiRouter.HandleFunc("/webhooks", handlers.ListWebhooks).Methods("GET")
+	apiRouter.HandleFunc("/webhooks/deliveries", handlers.ListWebhookDeliveries).Methods("GET")
+	apiRouter.HandleFunc("/webhooks/deliveries/{id}/redeliver", handlers.RedeliverWebhookDelivery).Methods("POST")
+	apiRouter.HandleFunc("/webhooks/{id}", handlers.GetWebhook).Methods("GET")
+	apiRouter.HandleFunc("/webhooks/{id}", handlers.UpdateWebhook).Methods("PUT")
+	apiRouter.HandleFunc("/webhooks/{id}", handlers.DeleteWebhook).Methods("DELETE")
+	apiRouter.HandleFunc("/keys", handlers.CreateAPIKey).Methods("POST")
+	apiRouter.HandleFunc("/keys", handlers.ListAPIKeys).Methods("GET")
+	apiRouter.HandleFunc("/keys/{id}", handlers.RevokeAPIKey).Methods("DELETE")
+	apiRouter.HandleFunc("/organizations", handlers.CreateOrganization).Methods("POST")
+	apiRouter.HandleFunc("/organizations", handlers.ListOrganizations).Methods("GET")
+	apiRouter.HandleFunc("/organizations/{id}", handlers.GetOrganization).Methods("GET")
+	apiRouter.HandleFunc("/organizations/{id}", handlers.UpdateOrganization).Methods("PUT")
+	apiRouter.HandleFunc("/organizations/{id}", handlers.DeleteOrganization).Methods("DELETE")
+	apiRouter.HandleFunc("/organizations/{id}/users", handlers.InviteUser).Methods("POST")
+	apiRouter.HandleFunc("/organizations/{id}/users", handlers.ListUsers).Methods("GET")
+	apiRouter.HandleFunc("/users/{id}", handlers.GetUser).Methods("GET")
+	apiRouter.HandleFunc("/users/{id}", handlers.UpdateUser).Methods("PATCH")
+	apiRouter.HandleFunc("/users/{id}", handlers.DeleteUser).Methods("DELETE")
+	apiRouter.HandleFunc("/jobs/{id}", handlers.GetJob).Methods("GET")
+	apiRouter.HandleFunc("/jobs/{id}/retry", handlers.RetryJob).Methods("POST")
+	apiRouter.HandleFunc("/tools", handlers.CreateTool).Methods("POST")
+	apiRouter.HandleFunc("/tools", handlers.ListTools).Methods("GET")
+	apiRouter.HandleFunc("/tools/{name}", handlers.GetTool).Methods("GET")
+	apiRouter.HandleFunc("/tools/{name}", handlers.UpdateTool).Methods("PUT")
+	apiRouter.HandleFunc("/tools/{name}", handlers.DeleteTool).Methods("DELETE")
+	apiRouter.HandleFunc("/secrets", handlers.CreateSecret).Methods("POST")
+	apiRouter.HandleFunc("/secrets", handlers.ListSecrets).Methods("GET")
+	apiRouter.HandleFunc("/secrets/rotate-key", handlers.RotateSecretsKey).Methods("POST")
+	apiRouter.HandleFunc("/secrets/{name}", handlers.GetSecret).Methods("GET")
+	apiRouter.HandleFunc("/secrets/{name}", handlers.UpdateSecret).Methods("PUT")
+	apiRouter.HandleFunc("/secrets/{name}", handlers.DeleteSecret).Methods("DELETE")
+	apiRouter.HandleFunc("/admin/drain", handlers.StartDrain).Methods("POST")
+	apiRouter.HandleFunc("/admin/drain", handlers.GetDrainStatus).Methods("GET")
 	apiRouter.HandleFunc("/ws", api.HandleWebSocket(runtime)).Methods("GET")
 
 	// Health check
@@ -116,13 +333,15 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 	}).Methods("GET")
 
+	// Readiness for load balancers, failing once the server drains
+	router.HandleFunc("/health/ready", api.HandleReadiness(drainer, database.HealthCheck)).Methods("GET")
+
+	router.HandleFunc("/health/capabilities", api.HandleCapabilities(runtime)).Methods("GET")
+
 	// Metrics endpoint (no auth required)
 	router.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	// Start background workers
-	queue := jobs.NewQueue(queries)
-	processor := jobs.NewProcessor(database)
-	worker := jobs.NewWorker(queue, processor, 5)
 	worker.Start()
 	defer worker.Stop()
 
@@ -153,6 +372,12 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Finish or hand back the work in progress before stopping, unless a
+	// drain through the API already started
+	fmt.Println("Draining server...")
+	drainer.Start(0)
+	<-drainer.Done()
+
 	fmt.Println("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -165,3 +390,133 @@ func main() {
 	fmt.Println("Server exited")
 }
 
+// loadConfig loads the configuration file named by CONFIG_PATH, or the
+// environment when it is unset
+func loadConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
+		var err error
+		cfg, err = config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Printf("Failed to load config: %v, using defaults\n", err)
+			cfg = config.DefaultConfig()
+		}
+	} else {
+		// Load from environment variables if no config file
+		config.LoadFromEnv(cfg)
+	}
+	return cfg
+}
+
+// oidcConfig converts the OIDC section of the configuration file
+func oidcConfig(c config.OIDCConfig) auth.OIDCConfig {
+	return auth.OIDCConfig{
+		Issuer:            c.Issuer,
+		Audience:          c.Audience,
+		JWKSURL:           c.JWKSURL,
+		JWKSCacheTTL:      c.JWKSCacheTTL,
+		ClockSkew:         c.ClockSkew,
+		OrganizationClaim: c.OrganizationClaim,
+		UserClaim:         c.UserClaim,
+		RolesClaim:        c.RolesClaim,
+		RoleMapping:       c.RoleMapping,
+		DefaultRoles:      c.DefaultRoles,
+		RateLimitPerMin:   c.RateLimitPerMin,
+	}
+}
+
+// organizationQuotas converts the organization quotas of the configuration
+// file
+func organizationQuotas(c map[string]config.QuotaConfig) map[string]auth.QuotaLimits {
+	quotas := make(map[string]auth.QuotaLimits, len(c))
+	for organizationID, quota := range c {
+		quotas[organizationID] = auth.QuotaLimits{RequestsPerDay: quota.RequestsPerDay, TokensPerDay: quota.TokensPerDay}
+	}
+	return quotas
+}
+
+// fallbackEmbeddingProvider creates the fallback embedding provider, or
+// returns nil when none is configured
+func fallbackEmbeddingProvider(c config.ProviderConfig) (neurondb.EmbeddingProvider, error) {
+	if c.Type == "" {
+		return nil, nil
+	}
+	keyEnv := c.APIKeyEnv
+	if keyEnv == "" && c.Type == neurondb.EmbeddingProviderOpenAI {
+		keyEnv = "OPENAI_API_KEY"
+	}
+	apiKey := ""
+	if keyEnv != "" {
+		apiKey = os.Getenv(keyEnv)
+	}
+	provider, err := neurondb.NewHTTPEmbeddingProvider(c.Type, c.Model, c.Endpoint, apiKey, c.Dimensions, c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// secretsKeyWrapper creates the wrapper of the secrets master key, or
+// returns nil when none is configured
+func secretsKeyWrapper(c config.SecretsConfig) (secrets.KeyWrapper, error) {
+	if c.Vault.Address != "" {
+		tokenEnv := c.Vault.TokenEnv
+		if tokenEnv == "" {
+			tokenEnv = "VAULT_TOKEN"
+		}
+		return secrets.NewVaultTransitKeyWrapper(c.Vault.Address, c.Vault.Mount, c.Vault.Key, os.Getenv(tokenEnv), c.Vault.Timeout)
+	}
+	keyEnv := c.MasterKeyEnv
+	if keyEnv == "" {
+		keyEnv = "NEURONDB_AGENT_MASTER_KEY"
+	}
+	wrapper, err := secrets.LocalKeyWrapperFromEnv(keyEnv, c.PreviousMasterKeysEnv)
+	if err != nil || wrapper == nil {
+		return nil, err
+	}
+	return wrapper, nil
+}
+
+// durationOrDefault returns d, or def when d is not set; configuration files
+// are not merged with the defaults
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+func bytesOrDefault(n, def int64) int64 {
+	if n > 0 {
+		return n
+	}
+	return def
+}
+
+// connectDatabase opens the connection pool described by cfg
+func connectDatabase(cfg *config.Config) (*db.DB, error) {
+	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.Database)
+
+	connMaxIdleTime := 10 * time.Minute
+	if cfg.Database.ConnMaxIdleTime > 0 {
+		connMaxIdleTime = cfg.Database.ConnMaxIdleTime
+	}
+
+	adaptive := cfg.Database.AdaptivePool
+	return db.NewDB(connStr, db.PoolConfig{
+		MaxOpenConns:         cfg.Database.MaxOpenConns,
+		MaxIdleConns:         cfg.Database.MaxIdleConns,
+		ConnMaxLifetime:      cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime:      connMaxIdleTime,
+		SlowAcquireThreshold: cfg.Database.SlowAcquireThreshold,
+		Adaptive: db.AdaptivePoolConfig{
+			Enabled:        adaptive.Enabled,
+			MinOpenConns:   adaptive.MinOpenConns,
+			MaxOpenConns:   adaptive.MaxOpenConns,
+			TargetWait:     adaptive.TargetWait,
+			MaxCPU:         adaptive.MaxCPU,
+			SampleInterval: adaptive.SampleInterval,
+		},
+	})
+}
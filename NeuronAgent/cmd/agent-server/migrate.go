@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+const migrateUsage = `Usage: agent-server migrate <command>
+
+Commands:
+  up                 apply all pending migrations
+  down [n]           roll back the last n migrations (default 1)
+  status             list migrations and whether they are applied
+  baseline <version> record migrations up to version as applied without
+                     running them (for schemas created before migrations
+                     were tracked)
+`
+
+// runMigrate implements the migrate subcommand and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Print(migrateUsage)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	cfg := loadConfig()
+	database, err := connectDatabase(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	runner, err := db.NewEmbeddedMigrationRunner(database.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		applied, err := runner.Run(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %03d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				fmt.Fprintf(os.Stderr, "Invalid step count '%s'\n", args[1])
+				return 2
+			}
+		}
+		reverted, err := runner.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("Rolled back %03d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rollback failed: %v\n", err)
+			return 1
+		}
+
+	case "status":
+		statuses, err := runner.List(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT\tREVERSIBLE")
+		for _, s := range statuses {
+			state, appliedAt := "pending", "-"
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\t%s\t%v\n", s.Version, s.Name, state, appliedAt, s.Reversible)
+		}
+		w.Flush()
+
+	case "baseline":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid version '%s'\n", args[1])
+			return 2
+		}
+		recorded, err := runner.Baseline(ctx, version)
+		for _, m := range recorded {
+			fmt.Printf("Recorded %03d_%s as applied\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Baseline failed: %v\n", err)
+			return 1
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate command '%s'\n\n%s", args[0], migrateUsage)
+		return 2
+	}
+	return 0
+}
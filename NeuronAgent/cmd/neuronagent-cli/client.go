@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the NeuronAgent REST API
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewClient(profile *Profile, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(profile.URL, "/") + "/api/v1",
+		apiKey:     profile.APIKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// APIError is an error response from the server
+type APIError struct {
+	StatusCode int
+	Err        string `json:"error"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s (HTTP %d): %s", e.Err, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s (HTTP %d)", e.Err, e.StatusCode)
+}
+
+// Do sends a request with body encoded as JSON, and decodes the response
+// into out when it is not nil
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Err == "" {
+			apiErr.Err = http.StatusText(resp.StatusCode)
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/api"
+)
+
+type cli struct {
+	config     *CLIConfig
+	configPath string
+	client     *Client
+	json       bool
+	out        io.Writer
+}
+
+func (c *cli) dispatch(args []string) error {
+	if len(args) < 2 {
+		return usageErrorf("'%s' needs a subcommand", args[0])
+	}
+	command, rest := args[0]+" "+args[1], args[2:]
+	switch command {
+	case "profile list":
+		return c.profileList(rest)
+	case "profile set":
+		return c.profileSet(rest)
+	case "profile use":
+		return c.profileUse(rest)
+	case "agents list":
+		return c.agentsList(rest)
+	case "agents create":
+		return c.agentsCreate(rest)
+	case "agents delete":
+		return c.agentsDelete(rest)
+	case "keys list":
+		return c.keysList(rest)
+	case "keys create":
+		return c.keysCreate(rest)
+	case "keys revoke":
+		return c.keysRevoke(rest)
+	case "sessions inspect":
+		return c.sessionsInspect(rest)
+	case "jobs retry":
+		return c.jobsRetry(rest)
+	case "memory search":
+		return c.memorySearch(rest)
+	default:
+		return usageErrorf("unknown command '%s'", command)
+	}
+}
+
+// parseArgs parses flags given before, between or after the positional
+// arguments, and checks that there are exactly want positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageErrorf("%s: %v", fs.Name(), err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != want {
+		return nil, usageErrorf("%s takes %d argument(s), got %d", fs.Name(), want, len(positional))
+	}
+	return positional, nil
+}
+
+// print writes v as indented JSON in json output mode, or calls table
+// otherwise
+func (c *cli) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// Profiles
+
+func (c *cli) profileList(args []string) error {
+	if _, err := parseArgs(flag.NewFlagSet("profile list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	type profileRow struct {
+		Name      string `json:"name"`
+		URL       string `json:"url"`
+		APIKeyEnv string `json:"api_key_env,omitempty"`
+		HasAPIKey bool   `json:"has_api_key"`
+		Current   bool   `json:"current"`
+	}
+	rows := []profileRow{}
+	for _, name := range c.config.profileNames() {
+		p := c.config.Profiles[name]
+		rows = append(rows, profileRow{
+			Name:      name,
+			URL:       p.URL,
+			APIKeyEnv: p.APIKeyEnv,
+			HasAPIKey: p.APIKey != "",
+			Current:   name == c.config.CurrentProfile,
+		})
+	}
+	return c.print(rows, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "CURRENT\tNAME\tURL\tAPI KEY")
+		for _, r := range rows {
+			current, key := "", "-"
+			if r.Current {
+				current = "*"
+			}
+			if r.HasAPIKey {
+				key = "(stored)"
+			} else if r.APIKeyEnv != "" {
+				key = "$" + r.APIKeyEnv
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, r.Name, r.URL, key)
+		}
+	})
+}
+
+func (c *cli) profileSet(args []string) error {
+	fs := flag.NewFlagSet("profile set", flag.ContinueOnError)
+	serverURL := fs.String("url", "", "")
+	apiKey := fs.String("api-key", "", "")
+	apiKeyEnv := fs.String("api-key-env", "", "")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	name := positional[0]
+	profile, ok := c.config.Profiles[name]
+	if !ok {
+		profile = &Profile{URL: defaultServerURL}
+		c.config.Profiles[name] = profile
+	}
+	if *serverURL != "" {
+		profile.URL = *serverURL
+	}
+	if *apiKey != "" {
+		profile.APIKey, profile.APIKeyEnv = *apiKey, ""
+	}
+	if *apiKeyEnv != "" {
+		profile.APIKey, profile.APIKeyEnv = "", *apiKeyEnv
+	}
+	if c.config.CurrentProfile == "" {
+		c.config.CurrentProfile = name
+	}
+	if err := c.config.save(c.configPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved profile '%s' to %s\n", name, c.configPath)
+	return nil
+}
+
+func (c *cli) profileUse(args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("profile use", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	name := positional[0]
+	if _, ok := c.config.Profiles[name]; !ok {
+		return fmt.Errorf("profile '%s' is not defined", name)
+	}
+	c.config.CurrentProfile = name
+	if err := c.config.save(c.configPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Using profile '%s'\n", name)
+	return nil
+}
+
+// Agents
+
+func (c *cli) agentsList(args []string) error {
+	if _, err := parseArgs(flag.NewFlagSet("agents list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var agents []api.AgentResponse
+	if err := c.client.Do(ctx, "GET", "/agents", nil, nil, &agents); err != nil {
+		return err
+	}
+	return c.print(agents, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tMODEL\tTOOLS\tUPDATED AT")
+		for _, a := range agents {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.ID, a.Name, a.ModelName,
+				strings.Join(a.EnabledTools, ","), a.UpdatedAt.Format(time.RFC3339))
+		}
+	})
+}
+
+func (c *cli) agentsCreate(args []string) error {
+	fs := flag.NewFlagSet("agents create", flag.ContinueOnError)
+	file := fs.String("file", "", "")
+	name := fs.String("name", "", "")
+	model := fs.String("model", "", "")
+	systemPrompt := fs.String("system-prompt", "", "")
+	description := fs.String("description", "", "")
+	tools := fs.String("tools", "", "")
+	config := fs.String("config", "", "")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	// Flags override the fields of --file
+	var req api.CreateAgentRequest
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", *file, err)
+		}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", *file, err)
+		}
+	}
+	if *name != "" {
+		req.Name = *name
+	}
+	if *model != "" {
+		req.ModelName = *model
+	}
+	if *systemPrompt != "" {
+		req.SystemPrompt = *systemPrompt
+	}
+	if *description != "" {
+		req.Description = description
+	}
+	if *tools != "" {
+		req.EnabledTools = splitList(*tools)
+	}
+	if *config != "" {
+		if err := json.Unmarshal([]byte(*config), &req.Config); err != nil {
+			return usageErrorf("--config must be a JSON object: %v", err)
+		}
+	}
+	if req.Name == "" || req.ModelName == "" {
+		return usageErrorf("agents create needs --name and --model, or a --file that sets them")
+	}
+
+	ctx := context.Background()
+	var agent api.AgentResponse
+	if err := c.client.Do(ctx, "POST", "/agents", nil, req, &agent); err != nil {
+		return err
+	}
+	return c.print(agent, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Created agent %s (%s)\n", agent.Name, agent.ID)
+	})
+}
+
+func (c *cli) agentsDelete(args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("agents delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := c.client.Do(ctx, "DELETE", "/agents/"+url.PathEscape(positional[0]), nil, nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Deleted agent %s\n", positional[0])
+	return nil
+}
+
+// API keys
+
+func (c *cli) keysList(args []string) error {
+	fs := flag.NewFlagSet("keys list", flag.ContinueOnError)
+	org := fs.String("org", "", "")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	query := url.Values{}
+	if *org != "" {
+		query.Set("organization_id", *org)
+	}
+
+	ctx := context.Background()
+	var keys []api.APIKeyResponse
+	if err := c.client.Do(ctx, "GET", "/keys", query, nil, &keys); err != nil {
+		return err
+	}
+	return c.print(keys, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tPREFIX\tORGANIZATION\tUSER\tROLES\tRATE/MIN\tLAST USED")
+		for _, k := range keys {
+			lastUsed := "-"
+			if k.LastUsedAt != nil {
+				lastUsed = k.LastUsedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", k.ID, k.KeyPrefix, orDash(k.OrganizationID),
+				orDash(k.UserID), strings.Join(k.Roles, ","), k.RateLimitPerMin, lastUsed)
+		}
+	})
+}
+
+func (c *cli) keysCreate(args []string) error {
+	fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	org := fs.String("org", "", "")
+	user := fs.String("user", "", "")
+	rate := fs.Int("rate", 0, "")
+	roles := fs.String("roles", "", "")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	var req api.APIKeyRequest
+	if *org != "" {
+		req.OrganizationID = org
+	}
+	if *user != "" {
+		req.UserID = user
+	}
+	if *rate != 0 {
+		req.RateLimitPerMin = rate
+	}
+	if *roles != "" {
+		req.Roles = splitList(*roles)
+	}
+
+	ctx := context.Background()
+	var key api.APIKeyResponse
+	if err := c.client.Do(ctx, "POST", "/keys", nil, req, &key); err != nil {
+		return err
+	}
+	return c.print(key, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Key:\t%s\n", key.Key)
+		fmt.Fprintf(w, "Key ID:\t%s\n", key.ID)
+		fmt.Fprintf(w, "Prefix:\t%s\n", key.KeyPrefix)
+		fmt.Fprintf(w, "Roles:\t%s\n", strings.Join(key.Roles, ","))
+		fmt.Fprintln(w, "\nSave this key securely - it cannot be retrieved again.")
+	})
+}
+
+func (c *cli) keysRevoke(args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("keys revoke", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := c.client.Do(ctx, "DELETE", "/keys/"+url.PathEscape(positional[0]), nil, nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Revoked API key %s\n", positional[0])
+	return nil
+}
+
+// Sessions
+
+func (c *cli) sessionsInspect(args []string) error {
+	fs := flag.NewFlagSet("sessions inspect", flag.ContinueOnError)
+	limit := fs.Int("messages", 20, "")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id := url.PathEscape(positional[0])
+
+	ctx := context.Background()
+	var session api.SessionResponse
+	if err := c.client.Do(ctx, "GET", "/sessions/"+id, nil, nil, &session); err != nil {
+		return err
+	}
+	messages := []api.MessageResponse{}
+	if *limit > 0 {
+		query := url.Values{"limit": {strconv.Itoa(*limit)}}
+		if err := c.client.Do(ctx, "GET", "/sessions/"+id+"/messages", query, nil, &messages); err != nil {
+			return err
+		}
+	}
+
+	report := struct {
+		Session  api.SessionResponse   `json:"session"`
+		Messages []api.MessageResponse `json:"messages"`
+	}{session, messages}
+	return c.print(report, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Session:\t%s\n", session.ID)
+		fmt.Fprintf(w, "Agent:\t%s\n", session.AgentID)
+		fmt.Fprintf(w, "External user:\t%s\n", orDash(session.ExternalUserID))
+		fmt.Fprintf(w, "Created at:\t%s\n", session.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "Last activity:\t%s\n", session.LastActivityAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "Archived:\t%v\n", session.Archived)
+		if len(messages) == 0 {
+			return
+		}
+		fmt.Fprintln(w, "\nID\tROLE\tCREATED AT\tCONTENT")
+		for _, m := range messages {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.ID, m.Role, m.CreatedAt.Format(time.RFC3339), truncate(m.Content, 80))
+		}
+	})
+}
+
+// Jobs
+
+func (c *cli) jobsRetry(args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("jobs retry", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.ParseInt(positional[0], 10, 64); err != nil {
+		return usageErrorf("job id '%s' must be a number", positional[0])
+	}
+
+	ctx := context.Background()
+	var job api.JobResponse
+	if err := c.client.Do(ctx, "POST", "/jobs/"+positional[0]+"/retry", nil, nil, &job); err != nil {
+		return err
+	}
+	return c.print(job, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Job %d (%s) is %s\n", job.ID, job.Type, job.Status)
+	})
+}
+
+// Memory
+
+func (c *cli) memorySearch(args []string) error {
+	fs := flag.NewFlagSet("memory search", flag.ContinueOnError)
+	topK := fs.Int("top-k", 5, "")
+	positional, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var results []api.MemorySearchResult
+	req := api.MemorySearchRequest{Query: positional[1], TopK: *topK}
+	if err := c.client.Do(ctx, "POST", "/agents/"+url.PathEscape(positional[0])+"/memory/search", nil, req, &results); err != nil {
+		return err
+	}
+	return c.print(results, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tSIMILARITY\tIMPORTANCE\tCONTENT")
+		for _, r := range results {
+			fmt.Fprintf(w, "%d\t%.4f\t%.2f\t%s\n", r.ID, r.Similarity, r.ImportanceScore, truncate(r.Content, 80))
+		}
+	})
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func orDash(s *string) string {
+	if s == nil || *s == "" {
+		return "-"
+	}
+	return *s
+}
+
+// truncate shortens s to one line of at most n characters
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
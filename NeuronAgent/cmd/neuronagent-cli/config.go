@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultServerURL = "http://localhost:8080"
+
+// Profile is a named server and the credentials to reach it
+type Profile struct {
+	URL    string `yaml:"url"`
+	APIKey string `yaml:"api_key,omitempty"`
+	// APIKeyEnv names an environment variable holding the API key, so the
+	// key need not be written to the config file
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+}
+
+// CLIConfig is the CLI's config file
+type CLIConfig struct {
+	CurrentProfile string              `yaml:"current_profile"`
+	Profiles       map[string]*Profile `yaml:"profiles"`
+}
+
+// configPath returns the config file named by NEURONAGENT_CLI_CONFIG, or
+// neuronagent/cli.yaml in the user's config directory
+func configPath() (string, error) {
+	if path := os.Getenv("NEURONAGENT_CLI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "neuronagent", "cli.yaml"), nil
+}
+
+// loadCLIConfig reads the config file at path. A missing file is an empty
+// config.
+func loadCLIConfig(path string) (*CLIConfig, error) {
+	cfg := &CLIConfig{Profiles: make(map[string]*Profile)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*Profile)
+	}
+	return cfg, nil
+}
+
+// save writes the config to path. The file may hold API keys, so it is only
+// readable by its owner.
+func (c *CLIConfig) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config %s: %w", path, err)
+	}
+	return nil
+}
+
+// profileNames returns the profile names in order
+func (c *CLIConfig) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveProfile picks the server URL and API key to use. Flags take
+// precedence over NEURONAGENT_URL and NEURONAGENT_API_KEY, which take
+// precedence over the profile. The profile is the one named by name, then
+// NEURONAGENT_PROFILE, then the config's current profile.
+func (c *CLIConfig) resolveProfile(name, url, apiKey string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv("NEURONAGENT_PROFILE")
+	}
+	explicit := name != ""
+	if name == "" {
+		name = c.CurrentProfile
+	}
+
+	resolved := &Profile{URL: defaultServerURL}
+	if profile, ok := c.Profiles[name]; ok {
+		if profile.URL != "" {
+			resolved.URL = profile.URL
+		}
+		resolved.APIKey = profile.APIKey
+		if profile.APIKeyEnv != "" {
+			resolved.APIKey = os.Getenv(profile.APIKeyEnv)
+		}
+	} else if explicit {
+		return nil, fmt.Errorf("profile '%s' is not defined", name)
+	}
+
+	if v := os.Getenv("NEURONAGENT_URL"); v != "" {
+		resolved.URL = v
+	}
+	if v := os.Getenv("NEURONAGENT_API_KEY"); v != "" {
+		resolved.APIKey = v
+	}
+	if url != "" {
+		resolved.URL = url
+	}
+	if apiKey != "" {
+		resolved.APIKey = apiKey
+	}
+	return resolved, nil
+}
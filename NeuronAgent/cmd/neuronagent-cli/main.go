@@ -0,0 +1,137 @@
+// Command neuronagent-cli administers a NeuronAgent server through its REST
+// API, for operators and scripts.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const usage = `Usage: neuronagent-cli [global flags] <command> <subcommand> [flags] [args]
+
+Commands:
+  profile list                       list configured profiles
+  profile set <name>                 create or update a profile
+          [--url URL] [--api-key KEY] [--api-key-env VAR]
+  profile use <name>                 make a profile the default
+
+  agents list                        list agents
+  agents create --name NAME --model MODEL
+          [--system-prompt TEXT] [--description TEXT] [--tools a,b]
+          [--config JSON] [--file agent.json]
+  agents delete <agent_id>           delete an agent
+
+  keys list [--org ORG]              list API keys
+  keys create [--org ORG] [--user USER] [--rate N] [--roles user,admin]
+                                     issue an API key; the key is printed once
+  keys revoke <key_id>               revoke an API key
+
+  sessions inspect <session_id> [--messages N]
+                                     show a session and its messages
+
+  jobs retry <job_id>                queue a failed job again
+
+  memory search <agent_id> <query> [--top-k N]
+                                     search an agent's memory
+
+Global flags:
+  --profile NAME   profile to use (env NEURONAGENT_PROFILE)
+  --url URL        server URL (env NEURONAGENT_URL)
+  --api-key KEY    API key (env NEURONAGENT_API_KEY)
+  -o, --output     output format: table or json (default table)
+  --timeout        request timeout (default 30s)
+
+Profiles are kept in the file named by NEURONAGENT_CLI_CONFIG, by default
+neuronagent/cli.yaml in the user config directory.
+`
+
+// usageError is a mistake in the command line; it exits with status 2
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("neuronagent-cli", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	profileName := global.String("profile", "", "")
+	serverURL := global.String("url", "", "")
+	apiKey := global.String("api-key", "", "")
+	output := global.String("output", "table", "")
+	global.StringVar(output, "o", "table", "")
+	timeout := global.Duration("timeout", 30*time.Second, "")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(stdout, usage)
+			return 0
+		}
+		fmt.Fprintf(stderr, "%v\n\n%s", err, usage)
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "Unknown output format '%s'\n", *output)
+		return 2
+	}
+
+	rest := global.Args()
+	if len(rest) == 0 || rest[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		if len(rest) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	path, err := configPath()
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	cfg, err := loadCLIConfig(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	c := &cli{
+		config:     cfg,
+		configPath: path,
+		json:       *output == "json",
+		out:        stdout,
+	}
+	if rest[0] != "profile" {
+		profile, err := cfg.resolveProfile(*profileName, *serverURL, *apiKey)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 2
+		}
+		c.client = NewClient(profile, *timeout)
+	}
+
+	if err := c.dispatch(rest); err != nil {
+		var uerr *usageError
+		if errors.As(err, &uerr) {
+			fmt.Fprintf(stderr, "%v\n\n%s", err, usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
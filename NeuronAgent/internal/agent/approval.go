@@ -0,0 +1,476 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/internal/webhooks"
+)
+
+// ToolApprovalJobType is the job type processed by Runtime.ResumeApproval
+const ToolApprovalJobType = "tool_approval_resume"
+
+// ErrApprovalPending is returned when a message is sent to a session whose
+// run is waiting for a tool approval decision or for its resumption
+var ErrApprovalPending = errors.New("session has a run awaiting tool approval")
+
+const defaultApprovalExpiry = 24 * time.Hour
+
+// ApprovalPolicy selects the tool calls an operator must approve before
+// they run. It is read from the "tool_approval" object of the agent config:
+//
+//	"tool_approval": {
+//	  "tools": ["shell", "delete_*"],       // tool names; path.Match patterns
+//	  "handler_types": ["shell", "http"],   // every tool with these handlers
+//	  "expires_after_hours": 24,            // undecided approvals expire; default 24
+//	  "webhook_url": "https://ops.example.com/approvals",  // also sent approval.requested events
+//	  "webhook_secret_env": "APPROVAL_WEBHOOK_SECRET"
+//	}
+//
+// When the LLM calls a tool that needs approval, none of the calls of that
+// turn run until an operator approves or rejects them.
+type ApprovalPolicy struct {
+	Tools            []string
+	HandlerTypes     []string
+	ExpiresAfter     time.Duration
+	WebhookURL       string
+	WebhookSecretEnv string
+}
+
+// Enabled reports whether any tool call needs approval
+func (p *ApprovalPolicy) Enabled() bool {
+	return len(p.Tools) > 0 || len(p.HandlerTypes) > 0
+}
+
+// Requires reports whether a call of tool needs approval
+func (p *ApprovalPolicy) Requires(tool *db.Tool) bool {
+	for _, pattern := range p.Tools {
+		if matched, _ := path.Match(pattern, tool.Name); matched {
+			return true
+		}
+	}
+	for _, handlerType := range p.HandlerTypes {
+		if handlerType == tool.HandlerType {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseApprovalPolicy extracts the tool approval policy from an agent
+// config. A missing "tool_approval" key yields a policy requiring nothing.
+func ParseApprovalPolicy(config map[string]interface{}) (*ApprovalPolicy, error) {
+	policy := &ApprovalPolicy{ExpiresAfter: defaultApprovalExpiry}
+	raw, ok := config["tool_approval"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tool_approval must be an object, got %T", raw)
+	}
+
+	for key, dest := range map[string]*[]string{"tools": &policy.Tools, "handler_types": &policy.HandlerTypes} {
+		v, ok := settings[key]
+		if !ok || v == nil {
+			continue
+		}
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("tool_approval.%s must be an array of strings", key)
+		}
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("tool_approval.%s[%d] must be a non-empty string", key, i)
+			}
+			if _, err := path.Match(s, ""); key == "tools" && err != nil {
+				return nil, fmt.Errorf("tool_approval.%s[%d] pattern '%s' is invalid: %w", key, i, s, err)
+			}
+			*dest = append(*dest, s)
+		}
+	}
+
+	if v, ok := settings["expires_after_hours"]; ok {
+		hours, ok := v.(float64)
+		if !ok || hours <= 0 {
+			return nil, fmt.Errorf("tool_approval.expires_after_hours must be a positive number")
+		}
+		policy.ExpiresAfter = time.Duration(hours * float64(time.Hour))
+	}
+
+	if v, ok := settings["webhook_url"]; ok {
+		url, ok := v.(string)
+		if !ok || !(strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")) {
+			return nil, fmt.Errorf("tool_approval.webhook_url must be an http(s) URL")
+		}
+		policy.WebhookURL = url
+	}
+	if v, ok := settings["webhook_secret_env"]; ok {
+		env, ok := v.(string)
+		if !ok || env == "" {
+			return nil, fmt.Errorf("tool_approval.webhook_secret_env must be a non-empty string")
+		}
+		policy.WebhookSecretEnv = env
+	}
+	return policy, nil
+}
+
+// ApprovalToolCall is a tool call of a paused run, as stored with its
+// approval
+type ApprovalToolCall struct {
+	ID               string                 `json:"id"`
+	Name             string                 `json:"name"`
+	Arguments        map[string]interface{} `json:"arguments"`
+	HandlerType      string                 `json:"handler_type,omitempty"`
+	RequiresApproval bool                   `json:"requires_approval"`
+}
+
+// ParseApprovalToolCalls decodes the tool calls stored with an approval
+func ParseApprovalToolCalls(raw []byte) ([]ApprovalToolCall, error) {
+	calls := []ApprovalToolCall{}
+	if len(raw) == 0 {
+		return calls, nil
+	}
+	if err := json.Unmarshal(raw, &calls); err != nil {
+		return nil, fmt.Errorf("invalid tool calls: %w", err)
+	}
+	return calls, nil
+}
+
+// approvalRunState is what a paused run needs to resume: the turn up to the
+// LLM's tool calls and the usage it has incurred so far
+type approvalRunState struct {
+	UserMessage         string               `json:"user_message"`
+	Response            string               `json:"response"`
+	ResponseUsage       TokenUsage           `json:"response_usage"`
+	Provider            string               `json:"provider,omitempty"`
+	Model               string               `json:"model,omitempty"`
+	Usage               TokenUsage           `json:"usage"`
+	CostUSD             float64              `json:"cost_usd"`
+	LLMCalls            []LLMCallUsage       `json:"llm_calls"`
+	GuardrailViolations []GuardrailViolation `json:"guardrail_violations,omitempty"`
+	Attachments         []db.MessageAttachment `json:"attachments,omitempty"`
+	APIKeyID            *uuid.UUID           `json:"api_key_id,omitempty"`
+	RunID               *uuid.UUID           `json:"run_id,omitempty"`
+}
+
+// approvalToolCalls returns the calls with whether each needs approval, and
+// whether any does. Unknown tools and tools the agent has not enabled need
+// none; they fail when executed.
+func (r *Runtime) approvalToolCalls(agent *db.Agent, policy *ApprovalPolicy, calls []ToolCall) ([]ApprovalToolCall, bool) {
+	out := make([]ApprovalToolCall, len(calls))
+	required := false
+	for i, call := range calls {
+		out[i] = ApprovalToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments}
+		if !policy.Enabled() || !contains(agent.EnabledTools, call.Name) {
+			continue
+		}
+		tool, err := r.tools.Get(call.Name)
+		if err != nil {
+			continue
+		}
+		out[i].HandlerType = tool.HandlerType
+		if policy.Requires(tool) {
+			out[i].RequiresApproval = true
+			required = true
+		}
+	}
+	return out, required
+}
+
+// pauseForApproval persists the run for an operator's decision and sets
+// state.PendingApproval. Nothing is stored in the session until the run
+// resumes.
+func (r *Runtime) pauseForApproval(ctx context.Context, agent *db.Agent, policy *ApprovalPolicy, state *ExecutionState, calls []ApprovalToolCall, apiKey *db.APIKey) error {
+	runState := approvalRunState{
+		UserMessage:         state.UserMessage,
+		Response:            state.LLMResponse.Content,
+		ResponseUsage:       state.LLMResponse.Usage,
+		Provider:            state.LLMResponse.Provider,
+		Model:               state.LLMResponse.Model,
+		Usage:               state.Usage,
+		CostUSD:             state.CostUSD,
+		LLMCalls:            state.LLMCalls,
+		GuardrailViolations: state.GuardrailViolations,
+		Attachments:         state.Attachments,
+		RunID:               state.RunID,
+	}
+	if apiKey != nil {
+		runState.APIKeyID = &apiKey.ID
+	}
+	runStateMap, err := toJSONMap(runState)
+	if err != nil {
+		return fmt.Errorf("failed to encode run state: %w", err)
+	}
+	callsJSON, err := json.Marshal(calls)
+	if err != nil {
+		return fmt.Errorf("failed to encode tool calls: %w", err)
+	}
+
+	approval := &db.ToolApproval{
+		SessionID: state.SessionID,
+		AgentID:   agent.ID,
+		ToolCalls: callsJSON,
+		RunState:  runStateMap,
+		ExpiresAt: time.Now().Add(policy.ExpiresAfter),
+	}
+	if err := r.queries.CreateToolApproval(ctx, approval); err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			return fmt.Errorf("%w: %v", ErrApprovalPending, err)
+		}
+		return err
+	}
+	state.PendingApproval = approval
+
+	data := map[string]interface{}{
+		"id":         approval.ID,
+		"session_id": approval.SessionID,
+		"agent_id":   approval.AgentID,
+		"status":     approval.Status,
+		"tool_calls": calls,
+		"created_at": approval.CreatedAt,
+		"expires_at": approval.ExpiresAt,
+	}
+	r.events.Emit(ctx, webhooks.EventApprovalRequested, data)
+	if policy.WebhookURL != "" {
+		r.events.EmitTo(ctx, policy.WebhookURL, policy.WebhookSecretEnv, webhooks.EventApprovalRequested, data)
+	}
+	return nil
+}
+
+// ResumeApproval resumes a run whose tool approval has been decided. On
+// approval the run's tool calls execute; on rejection the calls needing
+// approval return the rejection to the LLM instead. The run then finishes
+// like any other, and both the approval and the run record its outcome. A
+// job for a run that already completed returns the stored result.
+func (r *Runtime) ResumeApproval(ctx context.Context, job *db.Job) (map[string]interface{}, error) {
+	idStr, _ := job.Payload["approval_id"].(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed: job_id=%d, invalid approval_id '%v'", job.ID, job.Payload["approval_id"])
+	}
+	approval, err := r.queries.GetToolApproval(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed: job_id=%d, error=%w", job.ID, err)
+	}
+
+	switch approval.Status {
+	case db.ToolApprovalCompleted:
+		return approval.Result.ToMap(), nil
+	case db.ToolApprovalApproved, db.ToolApprovalRejected:
+	default:
+		return nil, fmt.Errorf("tool approval resume failed: approval_id='%s', status='%s' is not awaiting resumption",
+			approval.ID.String(), approval.Status)
+	}
+
+	state, err := r.resume(ctx, approval)
+	if err != nil {
+		// Failing the approval unblocks the session; the tools may have run,
+		// so the job is not retried
+		message := err.Error()
+		if finishErr := r.queries.FinishToolApproval(ctx, approval.ID, db.ToolApprovalFailed, nil, &message); finishErr != nil {
+			metrics.Logger().Error().Err(finishErr).
+				Str("approval_id", approval.ID.String()).
+				Msg("Failed to mark tool approval failed")
+		}
+		if finishErr := r.queries.FinishApprovalRun(ctx, approval.ID, db.RunFailed, nil, &message); finishErr != nil {
+			metrics.Logger().Error().Err(finishErr).
+				Str("approval_id", approval.ID.String()).
+				Msg("Failed to mark run failed")
+		}
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"approval_id":  approval.ID.String(),
+		"session_id":   state.SessionID.String(),
+		"response":     state.FinalAnswer,
+		"tokens_used":  state.TokensUsed,
+		"usage":        state.Usage,
+		"cost_usd":     state.CostUSD,
+		"tool_results": approvalToolResults(state.ToolResults),
+	}
+	result, err = toJSONMap(result)
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed: approval_id='%s', could not encode result: %w", approval.ID.String(), err)
+	}
+	if err := r.queries.FinishToolApproval(ctx, approval.ID, db.ToolApprovalCompleted, result, nil); err != nil {
+		return nil, err
+	}
+	if runResult, err := runResult(state); err != nil {
+		metrics.Logger().Error().Err(err).
+			Str("approval_id", approval.ID.String()).
+			Msg("Failed to encode run result")
+	} else if err := r.queries.FinishApprovalRun(ctx, approval.ID, db.RunCompleted, runResult, nil); err != nil {
+		metrics.Logger().Error().Err(err).
+			Str("approval_id", approval.ID.String()).
+			Msg("Failed to mark run completed")
+	}
+	return result, nil
+}
+
+// resume finishes the run paused by approval
+func (r *Runtime) resume(ctx context.Context, approval *db.ToolApproval) (*ExecutionState, error) {
+	agent, err := r.queries.GetAgentByID(ctx, approval.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load agent): approval_id='%s', agent_id='%s', error=%w",
+			approval.ID.String(), approval.AgentID.String(), err)
+	}
+	session, err := r.queries.GetSession(ctx, approval.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load session): approval_id='%s', session_id='%s', error=%w",
+			approval.ID.String(), approval.SessionID.String(), err)
+	}
+	agent, promptVersionID, err := r.applyPromptTemplate(ctx, agent, session)
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load prompt template): approval_id='%s', agent_id='%s', error=%w",
+			approval.ID.String(), approval.AgentID.String(), err)
+	}
+	guardrails, err := ParseGuardrailPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load guardrails): approval_id='%s', agent_id='%s', error=%w",
+			approval.ID.String(), agent.ID.String(), err)
+	}
+	usagePolicy, err := ParseUsagePolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load usage policy): approval_id='%s', agent_id='%s', error=%w",
+			approval.ID.String(), agent.ID.String(), err)
+	}
+	summarization, err := ParseSummarizationPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load summarization policy): approval_id='%s', agent_id='%s', error=%w",
+			approval.ID.String(), agent.ID.String(), err)
+	}
+	toolCache, err := ParseToolCachePolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load tool cache policy): approval_id='%s', agent_id='%s', error=%w",
+			approval.ID.String(), agent.ID.String(), err)
+	}
+	toolConstraints, err := ParseToolConstraintPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (load tool constraints): approval_id='%s', agent_id='%s', error=%w",
+			approval.ID.String(), agent.ID.String(), err)
+	}
+
+	var runState approvalRunState
+	if err := fromJSONMap(approval.RunState.ToMap(), &runState); err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (decode run state): approval_id='%s', error=%w", approval.ID.String(), err)
+	}
+	calls, err := ParseApprovalToolCalls(approval.ToolCalls)
+	if err != nil {
+		return nil, fmt.Errorf("tool approval resume failed (decode tool calls): approval_id='%s', error=%w", approval.ID.String(), err)
+	}
+
+	toolCalls := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		toolCalls[i] = ToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments}
+	}
+	state := &ExecutionState{
+		SessionID:   approval.SessionID,
+		AgentID:     agent.ID,
+		UserMessage: runState.UserMessage,
+		LLMResponse: &LLMResponse{
+			Content:   runState.Response,
+			ToolCalls: toolCalls,
+			Usage:     runState.ResponseUsage,
+			Provider:  runState.Provider,
+			Model:     runState.Model,
+		},
+		ToolCalls:           toolCalls,
+		Usage:               runState.Usage,
+		CostUSD:             runState.CostUSD,
+		LLMCalls:            runState.LLMCalls,
+		GuardrailViolations: runState.GuardrailViolations,
+		Attachments:         runState.Attachments,
+		PromptVersionID:     promptVersionID,
+		RunID:               runState.RunID,
+	}
+
+	var apiKey *db.APIKey
+	if runState.APIKeyID != nil {
+		if apiKey, err = r.queries.GetAPIKeyByID(ctx, *runState.APIKeyID); err != nil {
+			// The key may have been revoked meanwhile; usage is still recorded
+			// against the agent
+			apiKey = nil
+		}
+	}
+	if apiKey != nil {
+		ctx = auth.WithAPIKey(ctx, apiKey)
+	}
+
+	toolResults := make([]ToolResult, 0, len(calls))
+	for i, call := range calls {
+		if approval.Status == db.ToolApprovalRejected && call.RequiresApproval {
+			reason := ""
+			if approval.Reason != nil {
+				reason = *approval.Reason
+			}
+			toolResults = append(toolResults, ToolResult{
+				ToolCallID: call.ID,
+				Error: fmt.Errorf("tool call rejected by operator: tool_call_id='%s', tool_name='%s', reason='%s'",
+					call.ID, call.Name, reason),
+			})
+			continue
+		}
+		results, err := r.executeTools(ctx, agent, state.SessionID, toolCache, toolConstraints, toolCalls[i:i+1])
+		if err != nil {
+			return nil, fmt.Errorf("agent execution failed at step 6 (tool execution): session_id='%s', agent_id='%s', approval_id='%s', tool_name='%s', error=%w",
+				state.SessionID.String(), agent.ID.String(), approval.ID.String(), call.Name, err)
+		}
+		toolResults = append(toolResults, results...)
+	}
+	r.applyToolResults(state, guardrails, toolResults)
+
+	contextLoader := NewContextLoader(r.queries, r.memory, r.knowledge, r.llm)
+	agentContext, err := contextLoader.Load(ctx, state.SessionID, agent, state.UserMessage, historyMessages, 5)
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 2 (load context): session_id='%s', agent_id='%s', approval_id='%s', max_messages=%d, max_memory_chunks=5, error=%w",
+			state.SessionID.String(), agent.ID.String(), approval.ID.String(), historyMessages, err)
+	}
+	agentContext.Attachments = state.Attachments
+	state.Context = agentContext
+
+	if err := r.answerWithToolResults(ctx, agent, agentContext, usagePolicy, summarization, state); err != nil {
+		return nil, err
+	}
+	if err := r.complete(ctx, agent, guardrails, state, apiKey); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// approvalToolResults converts tool results to their stored form
+func approvalToolResults(results []ToolResult) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(results))
+	for i, result := range results {
+		out[i] = map[string]interface{}{
+			"tool_call_id": result.ToolCallID,
+			"content":      result.Content,
+		}
+		if result.Error != nil {
+			out[i]["error"] = result.Error.Error()
+		}
+	}
+	return out
+}
+
+// openApproval returns the session's run awaiting a tool approval decision
+// or its resumption, or nil if there is none
+func (r *Runtime) openApproval(ctx context.Context, sessionID uuid.UUID) (*db.ToolApproval, error) {
+	approval, err := r.queries.GetOpenToolApproval(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return approval, err
+}
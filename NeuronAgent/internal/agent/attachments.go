@@ -0,0 +1,321 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// Attachment limits and ingestion settings
+const (
+	MaxAttachments      = 10
+	MaxAttachmentBytes  = 10 << 20
+	attachmentFetchTime = 30 * time.Second
+
+	attachmentChunkSize    = 1000 // characters per memory chunk
+	attachmentChunkOverlap = 100  // characters repeated at the start of the next chunk
+	maxAttachmentChunks    = 200
+	attachmentSummaryLen   = 600 // characters of an attachment shown in the prompt
+	attachmentImportance   = 0.8
+)
+
+// ErrInvalidAttachment is returned when an attachment cannot be read, such
+// as a URL that does not resolve or a file over MaxAttachmentBytes
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+// Attachment is a file sent with a user message, either inline in Data or
+// as a URL the file is fetched from, such as a presigned object store URL
+type Attachment struct {
+	Filename string
+	MIMEType string
+	Data     []byte
+	URL      string
+}
+
+var attachmentClient = &http.Client{Timeout: attachmentFetchTime}
+
+// ingestAttachments reads the attachments of a message, stores the text of
+// those it can extract as memory chunks of the session, and records each in
+// message_attachments. An attachment whose text cannot be extracted is still
+// recorded, with its status saying why.
+func (r *Runtime) ingestAttachments(ctx context.Context, agent *db.Agent, state *ExecutionState, attachments []Attachment) error {
+	for i, att := range attachments {
+		data, err := loadAttachment(ctx, att)
+		if err != nil {
+			return fmt.Errorf("attachment %d ('%s'): %w", i, att.Filename, err)
+		}
+
+		sum := sha256.Sum256(data)
+		record := &db.MessageAttachment{
+			SessionID: state.SessionID,
+			Filename:  att.Filename,
+			MIMEType:  normalizeMIMEType(att.MIMEType),
+			SizeBytes: int64(len(data)),
+			SHA256:    hex.EncodeToString(sum[:]),
+			Source:    "inline",
+		}
+		if att.URL != "" {
+			// Presigned URLs carry credentials in their query string
+			sourceURL := att.URL
+			if u, err := url.Parse(att.URL); err == nil {
+				u.RawQuery, u.Fragment = "", ""
+				sourceURL = u.String()
+			}
+			record.Source, record.SourceURL = "url", &sourceURL
+		}
+
+		text, supported, err := extractAttachmentText(record.MIMEType, data)
+		switch {
+		case !supported:
+			record.Status = db.AttachmentUnsupported
+		case err != nil:
+			record.Status = db.AttachmentFailed
+			msg := err.Error()
+			record.Error = &msg
+		default:
+			record.Status = db.AttachmentProcessed
+			record.TextLength = utf8.RuneCountInString(text)
+			summary := summarizeAttachmentText(text)
+			record.Summary = &summary
+		}
+
+		// The attachment is recorded before its chunks are stored so the
+		// chunks can name it
+		if err := r.queries.CreateMessageAttachment(ctx, record); err != nil {
+			return fmt.Errorf("failed to store attachment: session_id='%s', filename='%s', error=%w",
+				state.SessionID.String(), att.Filename, err)
+		}
+		if record.Status == db.AttachmentProcessed {
+			count, err := r.storeAttachmentChunks(ctx, agent, record, text)
+			if err != nil {
+				metrics.Logger().Warn().Err(err).
+					Str("session_id", state.SessionID.String()).
+					Str("attachment_id", record.ID.String()).
+					Msg("Failed to store attachment text in memory")
+			}
+			record.ChunkCount = count
+		}
+		state.Attachments = append(state.Attachments, *record)
+	}
+	return nil
+}
+
+// storeAttachmentChunks embeds the text of an attachment in chunks and stores
+// them in the agent's memory under the session, returning how many were
+// stored
+func (r *Runtime) storeAttachmentChunks(ctx context.Context, agent *db.Agent, record *db.MessageAttachment, text string) (int, error) {
+	chunks := chunkAttachmentText(text)
+	if len(chunks) == 0 || !r.memory.Enabled() {
+		return 0, nil
+	}
+
+	store, policy, err := r.memory.storeFor(agent)
+	if err != nil {
+		return 0, err
+	}
+	embeddings, err := r.embed.EmbedBatch(ctx, chunks, policy.EmbeddingModel)
+	if err != nil {
+		return 0, fmt.Errorf("attachment embedding failed: attachment_id='%s', chunk_count=%d, error=%w",
+			record.ID.String(), len(chunks), err)
+	}
+	if len(embeddings) != len(chunks) {
+		return 0, fmt.Errorf("attachment embedding failed: attachment_id='%s', chunk_count=%d, embedding_count=%d",
+			record.ID.String(), len(chunks), len(embeddings))
+	}
+
+	sessionID := record.SessionID
+	memoryChunks := make([]db.MemoryChunk, len(chunks))
+	for i, chunk := range chunks {
+		memoryChunks[i] = db.MemoryChunk{
+			AgentID:         agent.ID,
+			SessionID:       &sessionID,
+			Content:         chunk,
+			Embedding:       embeddings[i],
+			ImportanceScore: attachmentImportance,
+			Metadata: db.JSONBMap{
+				"source":        "attachment",
+				"attachment_id": record.ID,
+				"filename":      record.Filename,
+				"chunk_index":   i,
+			},
+		}
+	}
+
+	stored := 0
+	if batch, ok := store.(BatchMemoryStore); ok {
+		if err := batch.StoreBatch(ctx, memoryChunks, policy); err != nil {
+			return 0, err
+		}
+		stored = len(memoryChunks)
+	} else {
+		for i := range memoryChunks {
+			if err := store.Store(ctx, &memoryChunks[i], policy); err != nil {
+				return stored, err
+			}
+			stored++
+		}
+	}
+	for i := 0; i < stored; i++ {
+		metrics.RecordMemoryChunkStored(agent.ID.String())
+	}
+
+	if err := r.queries.SetMessageAttachmentChunkCount(ctx, record.ID, stored); err != nil {
+		return stored, err
+	}
+	return stored, nil
+}
+
+// loadAttachment returns the content of an attachment, fetching it when it
+// is given by URL
+func loadAttachment(ctx context.Context, att Attachment) ([]byte, error) {
+	if att.URL == "" {
+		if len(att.Data) > MaxAttachmentBytes {
+			return nil, fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrInvalidAttachment, len(att.Data), MaxAttachmentBytes)
+		}
+		return att.Data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, att.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAttachment, err)
+	}
+	resp, err := attachmentClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: fetch failed: %v", ErrInvalidAttachment, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: fetch returned HTTP %d", ErrInvalidAttachment, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxAttachmentBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: fetch failed: %v", ErrInvalidAttachment, err)
+	}
+	if len(data) > MaxAttachmentBytes {
+		return nil, fmt.Errorf("%w: file exceeds the %d byte limit", ErrInvalidAttachment, MaxAttachmentBytes)
+	}
+	return data, nil
+}
+
+// normalizeMIMEType lowercases a MIME type and drops its parameters
+func normalizeMIMEType(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// extractAttachmentText returns the text of an attachment. supported is
+// false for types whose text is not extracted.
+func extractAttachmentText(mimeType string, data []byte) (text string, supported bool, err error) {
+	switch {
+	case mimeType == "application/pdf":
+		text, err = extractPDFText(data)
+		return text, true, err
+	case isTextMIMEType(mimeType):
+		if !utf8.Valid(data) {
+			return "", true, errors.New("file is not valid UTF-8 text")
+		}
+		text = strings.TrimSpace(string(data))
+		if text == "" {
+			return "", true, errors.New("file is empty")
+		}
+		return text, true, nil
+	}
+	return "", false, nil
+}
+
+// isTextMIMEType reports whether a MIME type is read as plain text
+func isTextMIMEType(mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/x-yaml", "application/yaml",
+		"application/csv", "application/x-ndjson", "application/markdown":
+		return true
+	}
+	return strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml")
+}
+
+// chunkAttachmentText splits text into overlapping chunks of about
+// attachmentChunkSize characters, breaking at whitespace where it can
+func chunkAttachmentText(text string) []string {
+	return chunkText(text, maxAttachmentChunks)
+}
+
+// chunkText splits text like chunkAttachmentText into at most maxChunks
+// chunks
+func chunkText(text string, maxChunks int) []string {
+	runes := []rune(text)
+	var chunks []string
+	for start := 0; start < len(runes) && len(chunks) < maxChunks; {
+		end := start + attachmentChunkSize
+		if end >= len(runes) {
+			end = len(runes)
+		} else if cut := lastSpace(runes[start+attachmentChunkSize/2 : end]); cut >= 0 {
+			end = start + attachmentChunkSize/2 + cut
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+		start = end - attachmentChunkOverlap
+	}
+	return chunks
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' || runes[i] == '\n' || runes[i] == '\t' {
+			return i
+		}
+	}
+	return -1
+}
+
+// summarizeAttachmentText returns the opening of an attachment's text, cut
+// at a word boundary, to show the model what the file is
+func summarizeAttachmentText(text string) string {
+	summary := strings.Join(strings.Fields(text), " ")
+	runes := []rune(summary)
+	if len(runes) <= attachmentSummaryLen {
+		return summary
+	}
+	cut := attachmentSummaryLen
+	if i := lastSpace(runes[attachmentSummaryLen/2 : attachmentSummaryLen]); i >= 0 {
+		cut = attachmentSummaryLen/2 + i
+	}
+	return string(runes[:cut]) + "..."
+}
+
+// attachmentMetadata returns user message metadata listing its attachments
+func attachmentMetadata(attachments []db.MessageAttachment) map[string]interface{} {
+	if len(attachments) == 0 {
+		return nil
+	}
+	refs := make([]map[string]interface{}, len(attachments))
+	for i, att := range attachments {
+		refs[i] = map[string]interface{}{
+			"id":        att.ID,
+			"filename":  att.Filename,
+			"mime_type": att.MIMEType,
+			"status":    att.Status,
+		}
+	}
+	return map[string]interface{}{"attachments": refs}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+// CapabilityReport describes what the server can do with the database it
+// runs on. Without NeuronDB's embedding functions texts are embedded by
+// the fallback provider, and without either agent memory is disabled.
+// Without its LLM functions agents using NeuronDB models are served by the
+// fallback LLM provider.
+type CapabilityReport struct {
+	Functions  map[string]bool `json:"functions"`             // NeuronDB functions found at startup
+	DetectedAt *time.Time      `json:"detected_at,omitempty"` // unset when detection was not run
+	// Embedding and LLM name what serves them: "neurondb", the fallback
+	// provider as type:model, or "none"
+	Embedding     string `json:"embedding"`
+	LLM           string `json:"llm"`
+	MemoryEnabled bool   `json:"memory_enabled"`
+	// Degraded is set when a NeuronDB function is missing
+	Degraded bool `json:"degraded"`
+}
+
+// SetCapabilities makes the runtime and its clients skip the NeuronDB
+// functions the database lacks. It must be called before the runtime is
+// used.
+func (r *Runtime) SetCapabilities(caps *neurondb.Capabilities) {
+	r.caps = caps
+	r.embed.SetCapabilities(caps)
+	r.llm.llmClient.SetCapabilities(caps)
+}
+
+// SetFallbackLLM sets the provider serving agents whose provider chain only
+// has NeuronDB providers when the database cannot generate completions
+func (r *Runtime) SetFallbackLLM(provider LLMProviderConfig) {
+	r.llm.fallback = &provider
+}
+
+// MemoryEnabled reports whether agent memory is available, which needs
+// texts to be embedded
+func (r *Runtime) MemoryEnabled() bool {
+	return r.memory.Enabled()
+}
+
+// Capabilities reports what the server can do with its database
+func (r *Runtime) Capabilities() *CapabilityReport {
+	report := &CapabilityReport{
+		Functions:     map[string]bool{},
+		Embedding:     r.embed.Source(),
+		LLM:           r.llm.Source(),
+		MemoryEnabled: r.MemoryEnabled(),
+	}
+	if r.caps != nil {
+		detectedAt := r.caps.DetectedAt
+		report.DetectedAt = &detectedAt
+		for name, found := range r.caps.Functions {
+			report.Functions[name] = found
+		}
+		report.Degraded = len(r.caps.Missing()) > 0
+	}
+	return report
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// circuitBreaker stops calls to a failing dependency. After threshold
+// consecutive failures it opens and rejects calls until cooldown has passed,
+// then lets a single trial call through: success closes it, failure opens it
+// again.
+type circuitBreaker struct {
+	mu            sync.Mutex
+	state         string
+	failures      int
+	openedAt      time.Time
+	trialInFlight bool
+	now           func() time.Time
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{state: CircuitClosed, now: time.Now}
+}
+
+// Allow reports whether a call may proceed. A true result in the half-open
+// state reserves the trial call, which must be reported with Success or
+// Failure.
+func (b *circuitBreaker) Allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.trialInFlight = true
+		return true
+	case CircuitHalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+		b.trialInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful call and closes the breaker
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trialInFlight = false
+}
+
+// Failure records a failed call. It returns true when the call opened the
+// breaker.
+func (b *circuitBreaker) Failure(threshold int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trialInFlight = false
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= threshold) {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
+
+// State returns the current state
+func (b *circuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
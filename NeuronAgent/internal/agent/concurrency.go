@@ -0,0 +1,254 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// ErrSessionBusy is matched by a RunLimitError for a session still
+// processing an earlier message
+var ErrSessionBusy = errors.New("session is processing another message")
+
+// ErrAgentBusy is matched by a RunLimitError for an agent at its limit of
+// concurrent runs
+var ErrAgentBusy = errors.New("agent is at its concurrent run limit")
+
+const (
+	defaultSessionQueueTimeout = 30 * time.Second
+	defaultRunRetryAfter       = 2 * time.Second
+)
+
+// RunLimits bound the messages the runtime processes at once. Messages to
+// one session always run one at a time: a message waits up to
+// SessionQueueTimeout for the session's earlier messages to finish.
+// MaxRunsPerAgent caps the messages an agent processes at once across its
+// sessions; 0 leaves it unlimited unless the agent's config sets a limit.
+// RetryAfter is the retry hint given with a refused message. The limits
+// hold within one server process.
+type RunLimits struct {
+	SessionQueueTimeout time.Duration
+	MaxRunsPerAgent     int
+	RetryAfter          time.Duration
+}
+
+// RunLimitError reports a message refused by a concurrency limit or by a
+// draining server
+type RunLimitError struct {
+	Err        error // ErrSessionBusy, ErrAgentBusy or ErrDraining
+	ID         string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *RunLimitError) Error() string {
+	if errors.Is(e.Err, ErrAgentBusy) {
+		return fmt.Sprintf("%v: agent_id='%s', max_concurrent_runs=%d", e.Err, e.ID, e.Limit)
+	}
+	return fmt.Sprintf("%v: session_id='%s'", e.Err, e.ID)
+}
+
+func (e *RunLimitError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfterSeconds is the retry hint of a RunLimitError in err, rounded up
+// to whole seconds, or 0 when err is not one
+func RetryAfterSeconds(err error) int {
+	var limitErr *RunLimitError
+	if !errors.As(err, &limitErr) || limitErr.RetryAfter <= 0 {
+		return 0
+	}
+	return int(math.Ceil(limitErr.RetryAfter.Seconds()))
+}
+
+// ConcurrencyPolicy overrides the server's run limits for an agent. It is
+// read from the "concurrency" object of the agent config:
+//
+//	"concurrency": {
+//	  "max_concurrent_runs": 4   // messages the agent processes at once; 0 removes the server limit
+//	}
+type ConcurrencyPolicy struct {
+	// MaxConcurrentRuns is nil when the agent uses the server's limit
+	MaxConcurrentRuns *int
+}
+
+// ParseConcurrencyPolicy extracts the concurrency policy from an agent
+// config
+func ParseConcurrencyPolicy(config map[string]interface{}) (*ConcurrencyPolicy, error) {
+	policy := &ConcurrencyPolicy{}
+	raw, ok := config["concurrency"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("concurrency must be an object, got %T", raw)
+	}
+	if v, ok := settings["max_concurrent_runs"]; ok && v != nil {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("concurrency.max_concurrent_runs must be a non-negative integer")
+		}
+		limit := int(n)
+		policy.MaxConcurrentRuns = &limit
+	}
+	return policy, nil
+}
+
+// RunLimiter serializes the messages of each session and counts the runs
+// of each agent
+type RunLimiter struct {
+	mu       sync.Mutex
+	limits   RunLimits
+	sessions map[uuid.UUID]*sessionQueue
+	agents   map[uuid.UUID]int
+}
+
+// sessionQueue holds the run slot of a session. users counts the holder
+// and the waiters, so the queue is dropped once nobody needs it.
+type sessionQueue struct {
+	slot  chan struct{}
+	users int
+}
+
+// NewRunLimiter creates a run limiter
+func NewRunLimiter(limits RunLimits) *RunLimiter {
+	l := &RunLimiter{
+		sessions: make(map[uuid.UUID]*sessionQueue),
+		agents:   make(map[uuid.UUID]int),
+	}
+	l.SetLimits(limits)
+	return l
+}
+
+// SetLimits replaces the limits. Runs already admitted are unaffected.
+func (l *RunLimiter) SetLimits(limits RunLimits) {
+	if limits.SessionQueueTimeout < 0 {
+		limits.SessionQueueTimeout = 0
+	}
+	if limits.MaxRunsPerAgent < 0 {
+		limits.MaxRunsPerAgent = 0
+	}
+	if limits.RetryAfter <= 0 {
+		limits.RetryAfter = defaultRunRetryAfter
+	}
+	l.mu.Lock()
+	l.limits = limits
+	l.mu.Unlock()
+}
+
+// AcquireSession waits for the session's earlier messages to finish and
+// returns the function releasing its slot. It fails with ErrSessionBusy
+// when the wait exceeds SessionQueueTimeout.
+func (l *RunLimiter) AcquireSession(ctx context.Context, sessionID uuid.UUID) (func(), error) {
+	l.mu.Lock()
+	q, ok := l.sessions[sessionID]
+	if !ok {
+		q = &sessionQueue{slot: make(chan struct{}, 1)}
+		l.sessions[sessionID] = q
+	}
+	q.users++
+	limits := l.limits
+	l.mu.Unlock()
+
+	leave := func() {
+		l.mu.Lock()
+		q.users--
+		if q.users == 0 {
+			delete(l.sessions, sessionID)
+		}
+		l.mu.Unlock()
+	}
+
+	select {
+	case q.slot <- struct{}{}:
+	default:
+		if err := waitForSlot(ctx, q.slot, limits.SessionQueueTimeout); err != nil {
+			leave()
+			if errors.Is(err, ErrSessionBusy) {
+				return nil, &RunLimitError{Err: ErrSessionBusy, ID: sessionID.String(), RetryAfter: limits.RetryAfter}
+			}
+			return nil, err
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-q.slot
+			leave()
+		})
+	}, nil
+}
+
+// sessionBusy returns the error refusing a message to a session whose run
+// is in progress elsewhere, such as on another server
+func (l *RunLimiter) sessionBusy(sessionID uuid.UUID) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &RunLimitError{Err: ErrSessionBusy, ID: sessionID.String(), RetryAfter: l.limits.RetryAfter}
+}
+
+// drainingError returns the error refusing a message to the session while
+// the server drains
+func (l *RunLimiter) drainingError(sessionID uuid.UUID) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &RunLimitError{Err: ErrDraining, ID: sessionID.String(), RetryAfter: l.limits.RetryAfter}
+}
+
+// waitForSlot takes slot once free, giving up after timeout
+func waitForSlot(ctx context.Context, slot chan struct{}, timeout time.Duration) error {
+	if timeout <= 0 {
+		return ErrSessionBusy
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case slot <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrSessionBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AcquireAgent admits a run of the agent and returns the function ending
+// it. It fails with ErrAgentBusy when the agent already processes its
+// limit of messages: the policy's max_concurrent_runs, or the server's
+// MaxRunsPerAgent when the policy sets none.
+func (l *RunLimiter) AcquireAgent(agentID uuid.UUID, policy *ConcurrencyPolicy) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limits.MaxRunsPerAgent
+	if policy != nil && policy.MaxConcurrentRuns != nil {
+		limit = *policy.MaxConcurrentRuns
+	}
+	if limit > 0 && l.agents[agentID] >= limit {
+		return nil, &RunLimitError{Err: ErrAgentBusy, ID: agentID.String(), Limit: limit, RetryAfter: l.limits.RetryAfter}
+	}
+	l.agents[agentID]++
+	metrics.RecordRunsActive(agentID.String(), l.agents[agentID])
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.agents[agentID]--
+			metrics.RecordRunsActive(agentID.String(), l.agents[agentID])
+			if l.agents[agentID] <= 0 {
+				delete(l.agents, agentID)
+			}
+		})
+	}, nil
+}
@@ -8,36 +8,64 @@ import (
 	"github.com/neurondb/NeuronAgent/internal/db"
 )
 
+// historyMessages is how many recent messages a prompt's history holds
+const historyMessages = 20
+
 type Context struct {
+	// Summary is the rolling summary of the session's messages before
+	// Messages, or nil when the session has none
+	Summary      *db.SessionSummary
 	Messages     []db.Message
 	MemoryChunks []MemoryChunk
+	// Attachments are the files sent with the current message
+	Attachments []db.MessageAttachment
+	// Passages are read from the agent's knowledge bases for the current
+	// message
+	Passages []KnowledgePassage
 }
 
 type ContextLoader struct {
-	queries *db.Queries
-	memory  *MemoryManager
-	llm     *LLMClient
+	queries   *db.Queries
+	memory    *MemoryManager
+	knowledge *KnowledgeRetriever
+	llm       *LLMClient
 }
 
-func NewContextLoader(queries *db.Queries, memory *MemoryManager, llm *LLMClient) *ContextLoader {
+func NewContextLoader(queries *db.Queries, memory *MemoryManager, knowledge *KnowledgeRetriever, llm *LLMClient) *ContextLoader {
 	return &ContextLoader{
-		queries: queries,
-		memory:  memory,
-		llm:     llm,
+		queries:   queries,
+		memory:    memory,
+		knowledge: knowledge,
+		llm:       llm,
 	}
 }
 
-func (l *ContextLoader) Load(ctx context.Context, sessionID uuid.UUID, agentID uuid.UUID, userMessage string, maxMessages int, maxMemoryChunks int) (*Context, error) {
-	// Load recent messages
-	messages, err := l.queries.GetRecentMessages(ctx, sessionID, maxMessages)
+func (l *ContextLoader) Load(ctx context.Context, sessionID uuid.UUID, agent *db.Agent, userMessage string, maxMessages int, maxMemoryChunks int) (*Context, error) {
+	agentID := agent.ID
+	// Load recent messages; a summarized session gets its summary and the
+	// messages after it
+	summary, err := l.queries.GetSessionSummary(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("context loading failed (load summary): session_id='%s', agent_id='%s', error=%w",
+			sessionID.String(), agentID.String(), err)
+	}
+	var messages []db.Message
+	if summary != nil {
+		messages, err = l.queries.GetRecentMessagesAfter(ctx, sessionID, summary.ThroughMessageID, maxMessages)
+	} else {
+		messages, err = l.queries.GetRecentMessages(ctx, sessionID, maxMessages)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("context loading failed (load messages): session_id='%s', agent_id='%s', user_message_length=%d, max_messages=%d, error=%w",
 			sessionID.String(), agentID.String(), len(userMessage), maxMessages, err)
 	}
 
 	// Generate embedding for user message to search memory
-	embeddingModel := "all-MiniLM-L6-v2"
-	embedding, err := l.llm.Embed(ctx, embeddingModel, userMessage)
+	embeddingModel := MemoryEmbeddingModel(agent)
+	var embedding []float32
+	if l.memory.Enabled() {
+		embedding, err = l.llm.Embed(ctx, embeddingModel, userMessage)
+	}
 	if err != nil {
 		// If embedding fails, continue without memory chunks but log the error
 		embedding = nil
@@ -47,7 +75,7 @@ func (l *ContextLoader) Load(ctx context.Context, sessionID uuid.UUID, agentID u
 	// Retrieve relevant memory chunks
 	var memoryChunks []MemoryChunk
 	if embedding != nil {
-		chunks, err := l.memory.Retrieve(ctx, agentID, embedding, maxMemoryChunks)
+		chunks, err := l.memory.Retrieve(ctx, agent, embedding, maxMemoryChunks)
 		if err != nil {
 			return nil, fmt.Errorf("context loading failed (retrieve memory): session_id='%s', agent_id='%s', user_message_length=%d, embedding_model='%s', embedding_dimension=%d, max_memory_chunks=%d, message_count=%d, error=%w",
 				sessionID.String(), agentID.String(), len(userMessage), embeddingModel, len(embedding), maxMemoryChunks, len(messages), err)
@@ -55,9 +83,32 @@ func (l *ContextLoader) Load(ctx context.Context, sessionID uuid.UUID, agentID u
 		memoryChunks = chunks
 	}
 
+	// Retrieve passages from the agent's knowledge bases. Unlike memory they
+	// are declared sources the answer should rest on, so failing to read them
+	// fails the message.
+	bases, err := ParseKnowledgeBases(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("context loading failed (load knowledge bases): session_id='%s', agent_id='%s', error=%w",
+			sessionID.String(), agentID.String(), err)
+	}
+	var passages []KnowledgePassage
+	if len(bases) > 0 {
+		embeddings := map[string][]float32{}
+		if embedding != nil {
+			embeddings[embeddingModel] = embedding
+		}
+		passages, err = l.knowledge.Retrieve(ctx, bases, userMessage, embeddings)
+		if err != nil {
+			return nil, fmt.Errorf("context loading failed (retrieve knowledge): session_id='%s', agent_id='%s', user_message_length=%d, knowledge_base_count=%d, error=%w",
+				sessionID.String(), agentID.String(), len(userMessage), len(bases), err)
+		}
+	}
+
 	return &Context{
+		Summary:      summary,
 		Messages:     messages,
 		MemoryChunks: memoryChunks,
+		Passages:     passages,
 	}, nil
 }
 
@@ -76,6 +127,7 @@ func CompressContext(ctx *Context, maxTokens int) *Context {
 	
 	// Strategy: Keep system messages, recent messages, and important memory chunks
 	compressed := &Context{
+		Summary:      ctx.Summary,
 		Messages:     []db.Message{},
 		MemoryChunks: []MemoryChunk{},
 	}
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// Conversation summary styles
+const (
+	SummaryStyleProse   = "prose"
+	SummaryStyleBullets = "bullets"
+)
+
+// Conversation summary lengths
+const (
+	SummaryLengthShort  = "short"
+	SummaryLengthMedium = "medium"
+	SummaryLengthLong   = "long"
+)
+
+// summaryLengthWords is the word limit given to the model for each length
+var summaryLengthWords = map[string]int{
+	SummaryLengthShort:  60,
+	SummaryLengthMedium: 150,
+	SummaryLengthLong:   400,
+}
+
+// conversationSummaryChunk bounds the messages loaded at once while a
+// conversation summary is extended
+const conversationSummaryChunk = 200
+
+// SummaryOptions selects the conversation summary of a session
+type SummaryOptions struct {
+	Style  string
+	Length string
+	// Refresh rebuilds the summary from the first message instead of
+	// extending the cached one
+	Refresh bool
+}
+
+// ParseSummaryOptions checks the style and length of a summary request. An
+// empty style or length defaults to medium-length prose.
+func ParseSummaryOptions(style, length string, refresh bool) (SummaryOptions, error) {
+	opts := SummaryOptions{Style: style, Length: length, Refresh: refresh}
+	if opts.Style == "" {
+		opts.Style = SummaryStyleProse
+	}
+	if opts.Length == "" {
+		opts.Length = SummaryLengthMedium
+	}
+	if opts.Style != SummaryStyleProse && opts.Style != SummaryStyleBullets {
+		return opts, fmt.Errorf("style must be prose or bullets, got '%s'", style)
+	}
+	if _, ok := summaryLengthWords[opts.Length]; !ok {
+		return opts, fmt.Errorf("length must be short, medium or long, got '%s'", length)
+	}
+	return opts, nil
+}
+
+// SummarizeSession returns the summary of a whole session in the style and
+// length of opts. It reports whether the summary was generated or extended
+// by this call rather than served from the cache.
+func (r *Runtime) SummarizeSession(ctx context.Context, sessionID uuid.UUID, opts SummaryOptions) (*db.ConversationSummary, bool, error) {
+	session, err := r.queries.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, false, fmt.Errorf("session summary failed (load session): session_id='%s', error=%w", sessionID.String(), err)
+	}
+	agent, err := r.queries.GetAgentByID(ctx, session.AgentID)
+	if err != nil {
+		return nil, false, fmt.Errorf("session summary failed (load agent): session_id='%s', agent_id='%s', error=%w",
+			sessionID.String(), session.AgentID.String(), err)
+	}
+	return r.summaries.Summarize(ctx, agent, sessionID, opts)
+}
+
+// Summarize returns the conversation summary of a session. The cached
+// summary is extended with the messages added since it was made, so each
+// message is summarized once however often the summary is read. A session
+// without messages has an empty summary, which is not cached.
+func (s *HistorySummarizer) Summarize(ctx context.Context, agent *db.Agent, sessionID uuid.UUID, opts SummaryOptions) (*db.ConversationSummary, bool, error) {
+	var cached *db.ConversationSummary
+	if !opts.Refresh {
+		var err error
+		cached, err = s.queries.GetConversationSummary(ctx, sessionID, opts.Style, opts.Length)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	folded := &db.SessionSummary{SessionID: sessionID}
+	if cached != nil {
+		folded.Summary = cached.Summary
+		folded.ThroughMessageID = cached.ThroughMessageID
+		folded.MessageCount = cached.MessageCount
+	}
+	prompt := conversationSummaryPrompt(opts)
+	extended := false
+	for {
+		messages, err := s.queries.GetOldestMessagesAfter(ctx, sessionID, folded.ThroughMessageID, conversationSummaryChunk)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+		if folded, _, err = s.fold(ctx, agent, sessionID, folded, messages, prompt); err != nil {
+			return nil, false, err
+		}
+		extended = true
+		if len(messages) < conversationSummaryChunk {
+			break
+		}
+	}
+
+	if !extended {
+		if cached != nil {
+			return cached, false, nil
+		}
+		return &db.ConversationSummary{SessionID: sessionID, Style: opts.Style, Length: opts.Length}, false, nil
+	}
+	summary := &db.ConversationSummary{
+		SessionID:        sessionID,
+		Style:            opts.Style,
+		Length:           opts.Length,
+		Summary:          folded.Summary,
+		ThroughMessageID: folded.ThroughMessageID,
+		MessageCount:     folded.MessageCount,
+	}
+	stored, err := s.queries.UpsertConversationSummary(ctx, summary)
+	if err != nil {
+		return nil, false, fmt.Errorf("session summary failed (store summary): session_id='%s', through_message_id=%d, error=%w",
+			sessionID.String(), summary.ThroughMessageID, err)
+	}
+	if !stored {
+		// Another request cached a summary covering more messages meanwhile
+		newer, err := s.queries.GetConversationSummary(ctx, sessionID, opts.Style, opts.Length)
+		if err == nil && newer != nil {
+			return newer, true, nil
+		}
+	}
+	return summary, true, nil
+}
+
+// conversationSummaryPrompt returns the prompt extending a conversation
+// summary in the style and length of opts
+func conversationSummaryPrompt(opts SummaryOptions) func(summary string, messages []db.Message) string {
+	form := fmt.Sprintf("Write at most %d words of plain prose", summaryLengthWords[opts.Length])
+	if opts.Style == SummaryStyleBullets {
+		form = fmt.Sprintf("Write at most %d words as a list of short points, one per line starting with \"- \"", summaryLengthWords[opts.Length])
+	}
+	instructions := "You summarize a conversation between a user and an assistant for someone who has not read it. " +
+		"Update the summary with the new messages below. Say what the user wanted, what was found or decided " +
+		"and what is still open; leave out pleasantries. " + form + " and reply with the summary only."
+	return func(summary string, messages []db.Message) string {
+		return extendSummaryPrompt(instructions, summary, messages)
+	}
+}
@@ -0,0 +1,267 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+// DocumentIngestJobType is the job type processed by DocumentIngester.Run
+const DocumentIngestJobType = "document_ingest"
+
+const (
+	documentBatchSize = 32
+	// maxDocumentChunks bounds the memory chunks stored for one document;
+	// text past them is left out and the progress says so
+	maxDocumentChunks  = 10000
+	documentImportance = 0.7
+)
+
+// DocumentIngestRequest is an uploaded document waiting to be stored in an
+// agent's memory. It is stored as the job payload.
+type DocumentIngestRequest struct {
+	Path      string     `json:"path"` // file in the upload directory
+	Filename  string     `json:"filename"`
+	MIMEType  string     `json:"mime_type"`
+	SizeBytes int64      `json:"size_bytes"`
+	SHA256    string     `json:"sha256"`
+	SessionID *uuid.UUID `json:"session_id,omitempty"` // stores the chunks as session memory
+}
+
+// ParseDocumentIngestRequest reads a request from a job payload
+func ParseDocumentIngestRequest(payload map[string]interface{}) (*DocumentIngestRequest, error) {
+	var req DocumentIngestRequest
+	if err := fromJSONMap(payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid document ingest payload: %w", err)
+	}
+	return &req, nil
+}
+
+// ToPayload converts the request into a job payload
+func (r *DocumentIngestRequest) ToPayload() (map[string]interface{}, error) {
+	return toJSONMap(r)
+}
+
+// DocumentIngestProgress is stored as the job result after every batch
+type DocumentIngestProgress struct {
+	TextLength      int     `json:"text_length"`
+	TotalChunks     int     `json:"total_chunks"`
+	ChunksStored    int     `json:"chunks_stored"`
+	Truncated       bool    `json:"truncated"` // the text had more than the stored chunks hold
+	PercentComplete float64 `json:"percent_complete"`
+	Completed       bool    `json:"completed"`
+}
+
+// ParseDocumentIngestProgress reads progress from a job result. An empty
+// result yields zero progress.
+func ParseDocumentIngestProgress(result map[string]interface{}) (*DocumentIngestProgress, error) {
+	progress := &DocumentIngestProgress{}
+	if len(result) == 0 {
+		return progress, nil
+	}
+	if err := fromJSONMap(result, progress); err != nil {
+		return nil, fmt.Errorf("invalid document ingest progress: %w", err)
+	}
+	return progress, nil
+}
+
+func (p *DocumentIngestProgress) update() {
+	if p.TotalChunks > 0 {
+		p.PercentComplete = float64(p.ChunksStored) / float64(p.TotalChunks) * 100
+	}
+}
+
+// IsDocumentMIMEType reports whether uploaded documents of a MIME type can
+// be stored in memory
+func IsDocumentMIMEType(mimeType string) bool {
+	return mimeType == "application/pdf" || isTextMIMEType(mimeType)
+}
+
+// DocumentIngester stores the text of uploaded documents in agent memory.
+// Uploads are written to files in its upload directory, and the job
+// processing one deletes the file once the document is stored or the job
+// has failed for the last time.
+type DocumentIngester struct {
+	queries   *db.Queries
+	embed     *neurondb.EmbeddingClient
+	uploadDir string
+}
+
+// NewDocumentIngester creates an ingester for uploads in uploadDir, which
+// is created if it does not exist
+func NewDocumentIngester(queries *db.Queries, embedClient *neurondb.EmbeddingClient, uploadDir string) (*DocumentIngester, error) {
+	uploadDir, err := filepath.Abs(uploadDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload directory: %w", err)
+	}
+	if err := os.MkdirAll(uploadDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory '%s': %w", uploadDir, err)
+	}
+	return &DocumentIngester{
+		queries:   queries,
+		embed:     embedClient,
+		uploadDir: uploadDir,
+	}, nil
+}
+
+// CreateUploadFile creates a new file in the upload directory for an
+// upload to be written to
+func (d *DocumentIngester) CreateUploadFile() (*os.File, error) {
+	return os.CreateTemp(d.uploadDir, "upload-*")
+}
+
+// Check verifies that the agent keeps its memory in Postgres, where
+// documents are stored, so uploads are rejected before they are read
+func (d *DocumentIngester) Check(agent *db.Agent) error {
+	policy, err := ParseMemoryBackendPolicy(agent.Config)
+	if err != nil {
+		return err
+	}
+	if policy.Backend != MemoryBackendPostgres {
+		return fmt.Errorf("document uploads need the %s memory backend, the agent uses %s", MemoryBackendPostgres, policy.Backend)
+	}
+	return nil
+}
+
+// Run processes a document ingest job. The document's text is chunked like
+// attachment text and each batch of chunks is stored together with the job
+// progress, so a retried job continues after the last stored batch.
+func (d *DocumentIngester) Run(ctx context.Context, job *db.Job) (result map[string]interface{}, err error) {
+	if job.AgentID == nil {
+		return nil, fmt.Errorf("document ingest job %d has no agent_id", job.ID)
+	}
+	req, err := ParseDocumentIngestRequest(job.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("job_id=%d: %w", job.ID, err)
+	}
+	path, err := d.uploadPath(req.Path)
+	if err != nil {
+		return nil, fmt.Errorf("job_id=%d: %w", job.ID, err)
+	}
+	// The upload is kept for retries and deleted after the last attempt
+	defer func() {
+		if err == nil || job.RetryCount+1 >= job.MaxRetries {
+			if removeErr := os.Remove(path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+				metrics.Logger().Warn().Err(removeErr).Int64("job_id", job.ID).Msg("Failed to delete uploaded document")
+			}
+		}
+	}()
+
+	progress, err := ParseDocumentIngestProgress(job.Result)
+	if err != nil {
+		return nil, err
+	}
+	if progress.Completed {
+		return toJSONMap(progress)
+	}
+
+	agent, err := d.queries.GetAgentByID(ctx, *job.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("uploaded document '%s' is gone: with more than one replica the upload directory must be shared", req.Filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded document '%s': %w", req.Filename, err)
+	}
+	text, supported, err := extractAttachmentText(req.MIMEType, data)
+	if !supported {
+		return nil, fmt.Errorf("documents of type '%s' are not supported", req.MIMEType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the text of '%s': %w", req.Filename, err)
+	}
+
+	chunks := chunkText(text, maxDocumentChunks+1)
+	progress.Truncated = len(chunks) > maxDocumentChunks
+	if progress.Truncated {
+		chunks = chunks[:maxDocumentChunks]
+	}
+	progress.TextLength = utf8.RuneCountInString(text)
+	progress.TotalChunks = len(chunks)
+	progress.update()
+
+	model := MemoryEmbeddingModel(agent)
+	for progress.ChunksStored < len(chunks) {
+		end := progress.ChunksStored + documentBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		batch := chunks[progress.ChunksStored:end]
+		embeddings, err := d.embed.EmbedBatch(ctx, batch, model)
+		if err != nil {
+			return d.fail(progress, fmt.Errorf("document embedding failed: model_name='%s', chunk_count=%d, error=%w", model, len(batch), err))
+		}
+		if len(embeddings) != len(batch) {
+			return d.fail(progress, fmt.Errorf("document embedding failed: model_name='%s', chunk_count=%d, embedding_count=%d",
+				model, len(batch), len(embeddings)))
+		}
+
+		memoryChunks := make([]db.MemoryChunk, len(batch))
+		for i, content := range batch {
+			memoryChunks[i] = db.MemoryChunk{
+				AgentID:         agent.ID,
+				SessionID:       req.SessionID,
+				Content:         content,
+				Embedding:       embeddings[i],
+				ImportanceScore: documentImportance,
+				Metadata: db.JSONBMap{
+					"source":          "upload",
+					"document_job_id": job.ID,
+					"filename":        req.Filename,
+					"sha256":          req.SHA256,
+					"chunk_index":     progress.ChunksStored + i,
+				},
+			}
+		}
+		next := *progress
+		next.ChunksStored = end
+		next.update()
+		result, err := toJSONMap(&next)
+		if err != nil {
+			return d.fail(progress, err)
+		}
+		if err := d.queries.StoreBackfillBatch(ctx, job.ID, memoryChunks, result); err != nil {
+			return d.fail(progress, err)
+		}
+		*progress = next
+		for range batch {
+			metrics.RecordMemoryChunkStored(agent.ID.String())
+		}
+	}
+
+	progress.Completed = true
+	progress.PercentComplete = 100
+	return toJSONMap(progress)
+}
+
+// uploadPath checks that a payload path names a file in the upload
+// directory
+func (d *DocumentIngester) uploadPath(path string) (string, error) {
+	rel, err := filepath.Rel(d.uploadDir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") || strings.ContainsRune(rel, filepath.Separator) {
+		return "", fmt.Errorf("document path '%s' is not in the upload directory", path)
+	}
+	return path, nil
+}
+
+// fail returns the progress so far with err; the worker stores it as the job
+// result, which keeps the resume position for the retry
+func (d *DocumentIngester) fail(progress *DocumentIngestProgress, err error) (map[string]interface{}, error) {
+	result, convErr := toJSONMap(progress)
+	if convErr != nil {
+		return nil, err
+	}
+	return result, err
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// ErrDraining is matched by a RunLimitError for a message sent to a server
+// draining ahead of a shutdown; the message should be retried on another
+// server
+var ErrDraining = errors.New("server is draining")
+
+// runTracker counts the messages and recovered runs the runtime is
+// processing, so a drain can wait for them and hand back the runs still in
+// progress at its deadline
+type runTracker struct {
+	mu         sync.Mutex
+	draining   bool
+	handedBack bool
+	executions map[*execution]struct{}
+	// idle is closed once draining and nothing is left in progress
+	idle       chan struct{}
+	idleClosed bool
+}
+
+// execution is a message or recovered run in progress. Its run is set once
+// recorded.
+type execution struct {
+	tracker *runTracker
+	cancel  context.CancelFunc
+	run     *activeRun
+}
+
+func newRunTracker() *runTracker {
+	return &runTracker{
+		executions: make(map[*execution]struct{}),
+		idle:       make(chan struct{}),
+	}
+}
+
+// admit starts tracking an execution, returning the context it runs with.
+// It returns false once the runtime is draining.
+func (t *runTracker) admit(ctx context.Context) (context.Context, *execution, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return nil, nil, false
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	e := &execution{tracker: t, cancel: cancel}
+	t.executions[e] = struct{}{}
+	return ctx, e, true
+}
+
+// attach records the run of an execution. A run recorded after the drain
+// handed the runs back is handed back at once.
+func (e *execution) attach(run *activeRun) {
+	t := e.tracker
+	t.mu.Lock()
+	e.run = run
+	handBack := t.handedBack
+	if handBack {
+		run.handedBack = true
+	}
+	t.mu.Unlock()
+	if handBack {
+		run.handBack()
+	}
+}
+
+// done stops tracking an execution
+func (e *execution) done() {
+	t := e.tracker
+	t.mu.Lock()
+	delete(t.executions, e)
+	t.checkIdleLocked()
+	t.mu.Unlock()
+	e.cancel()
+}
+
+func (t *runTracker) checkIdleLocked() {
+	if t.draining && len(t.executions) == 0 && !t.idleClosed {
+		close(t.idle)
+		t.idleClosed = true
+	}
+}
+
+// Draining reports whether the runtime refuses new messages
+func (r *Runtime) Draining() bool {
+	r.runs.mu.Lock()
+	defer r.runs.mu.Unlock()
+	return r.runs.draining
+}
+
+// InFlightRuns returns the number of messages and recovered runs in
+// progress
+func (r *Runtime) InFlightRuns() int {
+	r.runs.mu.Lock()
+	defer r.runs.mu.Unlock()
+	return len(r.runs.executions)
+}
+
+// Drain stops the runtime admitting messages and recovering runs, and waits
+// for those in progress to finish. Runs still in progress when ctx is done
+// are handed back: their processing is canceled and their heartbeat marked
+// stale, so recovery on another server resumes them from their last
+// checkpoint at once. It returns the number of runs handed back.
+func (r *Runtime) Drain(ctx context.Context) int {
+	t := r.runs
+	t.mu.Lock()
+	t.draining = true
+	t.checkIdleLocked()
+	t.mu.Unlock()
+
+	select {
+	case <-t.idle:
+		return 0
+	case <-ctx.Done():
+	}
+
+	// Runs are marked before their processing is canceled, so the errors
+	// the cancellation causes do not fail them
+	t.mu.Lock()
+	t.handedBack = true
+	var runs []*activeRun
+	var cancels []context.CancelFunc
+	for e := range t.executions {
+		if e.run != nil {
+			e.run.handedBack = true
+			runs = append(runs, e.run)
+		}
+		cancels = append(cancels, e.cancel)
+	}
+	t.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	for _, run := range runs {
+		run.handBack()
+	}
+	return len(runs)
+}
+
+// handBack stops the heartbeat and marks the run's heartbeat stale, leaving
+// the run for recovery by another server
+func (a *activeRun) handBack() {
+	a.release()
+	releaseRun(a.queries, a.run)
+}
+
+// isHandedBack reports whether a drain handed the run back
+func (a *activeRun) isHandedBack() bool {
+	a.tracker.mu.Lock()
+	defer a.tracker.mu.Unlock()
+	return a.handedBack
+}
+
+// interruptedError returns err, the error a message's run failed with, or
+// an error matching ErrDraining when a drain handed the run back
+func (r *Runtime) interruptedError(run *activeRun, err error) error {
+	if !run.isHandedBack() {
+		return err
+	}
+	return fmt.Errorf("agent execution interrupted: session_id='%s', run_id='%s', error=%w",
+		run.run.SessionID.String(), run.run.ID.String(), r.limiter.drainingError(run.run.SessionID))
+}
+
+// releaseRun marks the heartbeat of a running run stale. A run that
+// finished or was taken over meanwhile is left unchanged.
+func releaseRun(queries *db.Queries, run *db.Run) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := queries.ReleaseRun(ctx, run.ID, run.Attempts)
+	if err != nil && !errors.Is(err, db.ErrVersionConflict) {
+		metrics.Logger().Warn().Err(err).
+			Str("run_id", run.ID.String()).
+			Msg("Failed to hand back run")
+	}
+}
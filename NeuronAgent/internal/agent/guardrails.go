@@ -0,0 +1,391 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Guardrail stages
+const (
+	GuardrailStageInput      = "input"
+	GuardrailStageToolResult = "tool_result"
+	GuardrailStageOutput     = "output"
+)
+
+// Guardrail actions taken on a violation
+const (
+	GuardrailActionBlocked   = "blocked"
+	GuardrailActionRedacted  = "redacted"
+	GuardrailActionFlagged   = "flagged"
+	GuardrailActionTruncated = "truncated"
+)
+
+// Modes for the "pii" and "prompt_injection" settings
+const (
+	guardrailModeOff    = "off"
+	guardrailModeDetect = "detect"
+	guardrailModeRedact = "redact"
+	guardrailModeBlock  = "block"
+	guardrailModeFlag   = "flag"
+)
+
+const (
+	defaultRefusalMessage = "I can't help with that request."
+	withheldToolResult    = "[tool result withheld by guardrails: possible prompt injection]"
+)
+
+// GuardrailPolicy filters the content flowing through an agent. It is read
+// from the "guardrails" object of the agent config:
+//
+//	"guardrails": {
+//	  "blocklist": ["(?i)internal use only"],  // regular expressions
+//	  "keywords": ["password"],                // case-insensitive whole words
+//	  "pii": "redact",                         // off, detect, redact or block
+//	  "pii_types": ["email", "ssn"],           // default: all types
+//	  "prompt_injection": "block",             // off, flag or block (tool results)
+//	  "max_output_chars": 4000,
+//	  "refusal_message": "Sorry, I can't help with that."
+//	}
+//
+// Blocklist and keyword matches block the message. Input is checked before
+// the first LLM call, tool results before they are fed back to the LLM and
+// the final answer before it is returned.
+type GuardrailPolicy struct {
+	Blocklist       []*regexp.Regexp
+	Keywords        []string
+	PII             string
+	PIITypes        []string
+	PromptInjection string
+	MaxOutputChars  int
+	RefusalMessage  string
+}
+
+// GuardrailViolation records a single guardrail hit
+type GuardrailViolation struct {
+	Stage      string `json:"stage"`
+	Rule       string `json:"rule"`
+	Action     string `json:"action"`
+	Detail     string `json:"detail,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// Enabled reports whether the policy checks anything
+func (p *GuardrailPolicy) Enabled() bool {
+	return len(p.Blocklist) > 0 || len(p.Keywords) > 0 || p.PII != guardrailModeOff ||
+		p.PromptInjection != guardrailModeOff || p.MaxOutputChars > 0
+}
+
+// ParseGuardrailPolicy extracts the guardrail policy from an agent config.
+// A missing "guardrails" key yields a disabled policy.
+func ParseGuardrailPolicy(config map[string]interface{}) (*GuardrailPolicy, error) {
+	policy := &GuardrailPolicy{
+		PII:             guardrailModeOff,
+		PromptInjection: guardrailModeOff,
+		RefusalMessage:  defaultRefusalMessage,
+	}
+	raw, ok := config["guardrails"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("guardrails must be an object, got %T", raw)
+	}
+
+	patterns, err := stringList(settings, "blocklist")
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guardrails.blocklist pattern '%s' is invalid: %w", pattern, err)
+		}
+		policy.Blocklist = append(policy.Blocklist, re)
+	}
+
+	if policy.Keywords, err = stringList(settings, "keywords"); err != nil {
+		return nil, err
+	}
+
+	if v, ok := settings["pii"]; ok {
+		mode, ok := v.(string)
+		if !ok || !oneOf(mode, guardrailModeOff, guardrailModeDetect, guardrailModeRedact, guardrailModeBlock) {
+			return nil, fmt.Errorf("guardrails.pii must be one of off, detect, redact or block")
+		}
+		policy.PII = mode
+	}
+	if policy.PIITypes, err = stringList(settings, "pii_types"); err != nil {
+		return nil, err
+	}
+	for _, t := range policy.PIITypes {
+		if _, ok := piiPatterns[t]; !ok {
+			return nil, fmt.Errorf("guardrails.pii_types contains unknown type '%s' (supported: %s)", t, strings.Join(piiTypeOrder, ", "))
+		}
+	}
+
+	if v, ok := settings["prompt_injection"]; ok {
+		mode, ok := v.(string)
+		if !ok || !oneOf(mode, guardrailModeOff, guardrailModeFlag, guardrailModeBlock) {
+			return nil, fmt.Errorf("guardrails.prompt_injection must be one of off, flag or block")
+		}
+		policy.PromptInjection = mode
+	}
+
+	if v, ok := settings["max_output_chars"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != float64(int(n)) {
+			return nil, fmt.Errorf("guardrails.max_output_chars must be a non-negative integer")
+		}
+		policy.MaxOutputChars = int(n)
+	}
+
+	if v, ok := settings["refusal_message"]; ok {
+		msg, ok := v.(string)
+		if !ok || strings.TrimSpace(msg) == "" {
+			return nil, fmt.Errorf("guardrails.refusal_message must be a non-empty string")
+		}
+		policy.RefusalMessage = msg
+	}
+
+	return policy, nil
+}
+
+// CheckInput screens a user message before it reaches the LLM. When blocked
+// is true the message must not be sent to the LLM.
+func (p *GuardrailPolicy) CheckInput(text string) (string, []GuardrailViolation, bool) {
+	return p.checkText(GuardrailStageInput, "", text)
+}
+
+// CheckToolResult screens a tool result before it is fed back to the LLM.
+// Blocked results are replaced by a placeholder rather than dropped so the
+// LLM still sees that the call happened.
+func (p *GuardrailPolicy) CheckToolResult(toolCallID, content string) (string, []GuardrailViolation) {
+	content, violations, blocked := p.checkText(GuardrailStageToolResult, toolCallID, content)
+	if blocked {
+		return withheldToolResult, violations
+	}
+
+	if p.PromptInjection != guardrailModeOff {
+		if hit := detectPromptInjection(content); hit != "" {
+			action := GuardrailActionFlagged
+			if p.PromptInjection == guardrailModeBlock {
+				action = GuardrailActionBlocked
+				content = withheldToolResult
+			}
+			violations = append(violations, GuardrailViolation{
+				Stage:      GuardrailStageToolResult,
+				Rule:       "prompt_injection",
+				Action:     action,
+				Detail:     hit,
+				ToolCallID: toolCallID,
+			})
+		}
+	}
+	return content, violations
+}
+
+// CheckOutput screens the final answer. A blocked answer is replaced with the
+// refusal message; an overlong one is truncated.
+func (p *GuardrailPolicy) CheckOutput(text string) (string, []GuardrailViolation) {
+	text, violations, blocked := p.checkText(GuardrailStageOutput, "", text)
+	if blocked {
+		return p.RefusalMessage, violations
+	}
+
+	if p.MaxOutputChars > 0 {
+		if runes := []rune(text); len(runes) > p.MaxOutputChars {
+			text = string(runes[:p.MaxOutputChars])
+			violations = append(violations, GuardrailViolation{
+				Stage:  GuardrailStageOutput,
+				Rule:   "max_output_length",
+				Action: GuardrailActionTruncated,
+				Detail: fmt.Sprintf("%d characters truncated to %d", len(runes), p.MaxOutputChars),
+			})
+		}
+	}
+	return text, violations
+}
+
+// checkText applies the blocklist, keyword and PII rules shared by all stages
+func (p *GuardrailPolicy) checkText(stage, toolCallID, text string) (string, []GuardrailViolation, bool) {
+	var violations []GuardrailViolation
+	violation := func(rule, action, detail string) {
+		violations = append(violations, GuardrailViolation{
+			Stage:      stage,
+			Rule:       rule,
+			Action:     action,
+			Detail:     detail,
+			ToolCallID: toolCallID,
+		})
+	}
+
+	for _, re := range p.Blocklist {
+		if re.MatchString(text) {
+			violation("blocklist", GuardrailActionBlocked, re.String())
+			return text, violations, true
+		}
+	}
+	for _, keyword := range p.Keywords {
+		if containsWord(text, keyword) {
+			violation("keyword", GuardrailActionBlocked, keyword)
+			return text, violations, true
+		}
+	}
+
+	if p.PII == guardrailModeOff {
+		return text, violations, false
+	}
+	types := p.PIITypes
+	if len(types) == 0 {
+		types = piiTypeOrder
+	}
+	blocked := false
+	for _, t := range types {
+		re := piiPatterns[t]
+		matches := 0
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			if t == "credit_card" && !luhnValid(match) {
+				return match
+			}
+			matches++
+			if p.PII == guardrailModeRedact {
+				return "[REDACTED_" + strings.ToUpper(t) + "]"
+			}
+			return match
+		})
+		if matches == 0 {
+			continue
+		}
+		detail := fmt.Sprintf("%s x%d", t, matches)
+		switch p.PII {
+		case guardrailModeRedact:
+			violation("pii", GuardrailActionRedacted, detail)
+		case guardrailModeBlock:
+			violation("pii", GuardrailActionBlocked, detail)
+			blocked = true
+		default:
+			violation("pii", GuardrailActionFlagged, detail)
+		}
+	}
+	return text, violations, blocked
+}
+
+// piiTypeOrder lists the PII types in the order they are redacted. Credit
+// cards go before phone numbers so long digit runs are not half-matched.
+var piiTypeOrder = []string{"email", "ssn", "credit_card", "phone", "ip_address"}
+
+var piiPatterns = map[string]*regexp.Regexp{
+	"email":       regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	"ssn":         regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	"credit_card": regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+	"phone":       regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{3}\)|\b\d{3})[ .\-]?\d{3}[ .\-]?\d{4}\b`),
+	"ip_address":  regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+}
+
+// promptInjectionPatterns are phrases commonly used to hijack an LLM through
+// content it reads, such as web pages or documents returned by tools
+var promptInjectionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(the\s+)?(previous|prior|above|earlier|preceding|system)\s+(instructions?|prompts?|messages?|rules|context)`),
+	regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(a|an|in)\b`),
+	regexp.MustCompile(`(?i)\b(new|updated|revised)\s+(system\s+)?instructions?\s*:`),
+	regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output)\s+(your|the)\s+(system\s+prompt|instructions|hidden\s+prompt)`),
+	regexp.MustCompile(`(?i)(^|\n)\s*(#{2,}\s*)?(system|assistant)\s*:`),
+	regexp.MustCompile(`(?i)<\|?(im_start|im_end|system|endoftext)\|?>`),
+	regexp.MustCompile(`(?i)\[/?(INST|SYS)\]`),
+}
+
+// detectPromptInjection returns the first suspicious phrase in text, if any
+func detectPromptInjection(text string) string {
+	for _, re := range promptInjectionPatterns {
+		if match := re.FindString(text); match != "" {
+			return strings.TrimSpace(match)
+		}
+	}
+	return ""
+}
+
+// containsWord reports whether keyword occurs in text, ignoring case, with
+// no letters or digits directly around it
+func containsWord(text, keyword string) bool {
+	if keyword == "" {
+		return false
+	}
+	lower := strings.ToLower(text)
+	keyword = strings.ToLower(keyword)
+	for offset := 0; ; {
+		i := strings.Index(lower[offset:], keyword)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(keyword)
+		before := start == 0 || !isWordRune(lastRune(lower[:start]))
+		after := end == len(lower) || !isWordRune([]rune(lower[end:])[0])
+		if before && after {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func lastRune(s string) rune {
+	runes := []rune(s)
+	return runes[len(runes)-1]
+}
+
+// luhnValid checks the credit card checksum of the digits in s
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+func stringList(settings map[string]interface{}, key string) ([]string, error) {
+	raw, ok := settings[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("guardrails.%s must be an array of strings", key)
+	}
+	out := make([]string, 0, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("guardrails.%s[%d] must be a non-empty string", key, i)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func oneOf(s string, values ...string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
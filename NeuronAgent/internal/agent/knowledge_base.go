@@ -0,0 +1,311 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// Knowledge base defaults and limits
+const (
+	defaultKnowledgeTopK  = 3
+	maxKnowledgeTopK      = 20
+	maxKnowledgeBases     = 10
+	defaultKnowledgeIDCol = "id"
+)
+
+// KnowledgeBase is a table of passages an agent reads from on every message.
+// Knowledge bases are listed in the "knowledge_bases" array of the agent
+// config:
+//
+//	"knowledge_bases": [
+//	  {
+//	    "name": "product_docs",            // label shown in citations; defaults to the table
+//	    "table": "docs.passages",          // table or schema.table
+//	    "vector_column": "embedding",      // vector column searched by cosine distance
+//	    "content_column": "body",          // text put in the prompt
+//	    "id_column": "id",                 // reported as the source id; default "id"
+//	    "metadata_columns": ["title", "url"],
+//	    "top_k": 3,                        // passages per message (1-20)
+//	    "min_similarity": 0.3,             // cosine similarity a passage needs (0-1); default none
+//	    "embedding_model": "all-MiniLM-L6-v2"  // model the vectors were made with
+//	  }
+//	]
+//
+// Unlike memory, knowledge bases are read-only: the agent never writes to them.
+type KnowledgeBase struct {
+	Name            string   `json:"name"`
+	Table           string   `json:"table"`
+	VectorColumn    string   `json:"vector_column"`
+	ContentColumn   string   `json:"content_column"`
+	IDColumn        string   `json:"id_column"`
+	MetadataColumns []string `json:"metadata_columns,omitempty"`
+	TopK            int      `json:"top_k"`
+	MinSimilarity   *float64 `json:"min_similarity,omitempty"`
+	EmbeddingModel  string   `json:"embedding_model"`
+}
+
+// KnowledgePassage is a passage retrieved for the current message. Passages
+// are numbered from 1 across all knowledge bases, and the answer cites them
+// by that number.
+type KnowledgePassage struct {
+	Citation      int                    `json:"citation"`
+	KnowledgeBase string                 `json:"knowledge_base"`
+	SourceID      string                 `json:"source_id"`
+	Content       string                 `json:"-"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Similarity    float64                `json:"similarity"`
+}
+
+// ParseKnowledgeBases extracts the knowledge bases from an agent config. A
+// missing "knowledge_bases" key yields none.
+func ParseKnowledgeBases(config map[string]interface{}) ([]KnowledgeBase, error) {
+	raw, ok := config["knowledge_bases"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	entries, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("knowledge_bases must be an array, got %T", raw)
+	}
+	if len(entries) > maxKnowledgeBases {
+		return nil, fmt.Errorf("knowledge_bases may list at most %d knowledge bases", maxKnowledgeBases)
+	}
+
+	bases := make([]KnowledgeBase, 0, len(entries))
+	names := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		settings, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("knowledge_bases[%d] must be an object, got %T", i, entry)
+		}
+		var base KnowledgeBase
+		if err := fromJSONMap(settings, &base); err != nil {
+			return nil, fmt.Errorf("knowledge_bases[%d]: %w", i, err)
+		}
+		if err := base.normalize(); err != nil {
+			return nil, fmt.Errorf("knowledge_bases[%d]: %w", i, err)
+		}
+		if names[base.Name] {
+			return nil, fmt.Errorf("knowledge_bases[%d].name '%s' is used twice", i, base.Name)
+		}
+		names[base.Name] = true
+		bases = append(bases, base)
+	}
+	return bases, nil
+}
+
+// normalize validates the knowledge base and fills in defaults
+func (b *KnowledgeBase) normalize() error {
+	if b.Table == "" {
+		return fmt.Errorf("table is required")
+	}
+	if _, err := quoteQualifiedIdentifier(b.Table); err != nil {
+		return fmt.Errorf("table: %w", err)
+	}
+	if b.VectorColumn == "" {
+		return fmt.Errorf("vector_column is required")
+	}
+	if b.ContentColumn == "" {
+		return fmt.Errorf("content_column is required")
+	}
+	if b.IDColumn == "" {
+		b.IDColumn = defaultKnowledgeIDCol
+	}
+	columns := append([]string{b.VectorColumn, b.ContentColumn, b.IDColumn}, b.MetadataColumns...)
+	for _, column := range columns {
+		if !identifierPattern.MatchString(column) {
+			return fmt.Errorf("invalid column name '%s'", column)
+		}
+	}
+	if b.Name == "" {
+		b.Name = b.Table
+	}
+	if b.TopK == 0 {
+		b.TopK = defaultKnowledgeTopK
+	}
+	if b.TopK < 1 || b.TopK > maxKnowledgeTopK {
+		return fmt.Errorf("top_k must be between 1 and %d", maxKnowledgeTopK)
+	}
+	if b.MinSimilarity != nil && (*b.MinSimilarity < 0 || *b.MinSimilarity > 1) {
+		return fmt.Errorf("min_similarity must be between 0 and 1")
+	}
+	if b.EmbeddingModel == "" {
+		b.EmbeddingModel = memoryEmbeddingModel
+	}
+	return nil
+}
+
+// buildKnowledgeQuery builds the query returning the top_k passages closest
+// to the embedding passed as $1
+func buildKnowledgeQuery(base *KnowledgeBase) (string, error) {
+	table, err := quoteQualifiedIdentifier(base.Table)
+	if err != nil {
+		return "", err
+	}
+	vectorCol := pq.QuoteIdentifier(base.VectorColumn)
+
+	metadataExpr := "'{}'"
+	if len(base.MetadataColumns) > 0 {
+		pairs := make([]string, 0, len(base.MetadataColumns))
+		for _, column := range base.MetadataColumns {
+			pairs = append(pairs, pq.QuoteLiteral(column)+", "+pq.QuoteIdentifier(column))
+		}
+		metadataExpr = "jsonb_build_object(" + strings.Join(pairs, ", ") + ")::text"
+	}
+
+	where := vectorCol + " IS NOT NULL"
+	if base.MinSimilarity != nil {
+		where += fmt.Sprintf(" AND %s <=> $1::vector <= %g", vectorCol, 1-*base.MinSimilarity)
+	}
+	return fmt.Sprintf("SELECT %s::text, %s::text, %s, 1 - (%s <=> $1::vector) FROM %s WHERE %s ORDER BY %s <=> $1::vector LIMIT %d",
+		pq.QuoteIdentifier(base.IDColumn), pq.QuoteIdentifier(base.ContentColumn), metadataExpr,
+		vectorCol, table, where, vectorCol, base.TopK), nil
+}
+
+// KnowledgeRetriever reads passages from the knowledge bases of agents
+type KnowledgeRetriever struct {
+	db  *db.DB
+	llm *LLMClient
+}
+
+func NewKnowledgeRetriever(database *db.DB, llm *LLMClient) *KnowledgeRetriever {
+	return &KnowledgeRetriever{db: database, llm: llm}
+}
+
+// Retrieve returns the passages of each knowledge base closest to the
+// message, numbered in knowledge base order. embeddings caches the message
+// embedding per model and may already hold the one made for memory search.
+func (k *KnowledgeRetriever) Retrieve(ctx context.Context, bases []KnowledgeBase, message string, embeddings map[string][]float32) ([]KnowledgePassage, error) {
+	var passages []KnowledgePassage
+	for i := range bases {
+		base := &bases[i]
+		embedding, ok := embeddings[base.EmbeddingModel]
+		if !ok {
+			var err error
+			embedding, err = k.llm.Embed(ctx, base.EmbeddingModel, message)
+			if err != nil {
+				return nil, fmt.Errorf("knowledge base retrieval failed (embed message): knowledge_base='%s', embedding_model='%s', error=%w",
+					base.Name, base.EmbeddingModel, err)
+			}
+			embeddings[base.EmbeddingModel] = embedding
+		}
+
+		found, err := k.search(ctx, base, embedding)
+		if err != nil {
+			return nil, err
+		}
+		for _, passage := range found {
+			passage.Citation = len(passages) + 1
+			passages = append(passages, passage)
+		}
+	}
+	return passages, nil
+}
+
+func (k *KnowledgeRetriever) search(ctx context.Context, base *KnowledgeBase, embedding []float32) ([]KnowledgePassage, error) {
+	query, err := buildKnowledgeQuery(base)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := k.db.QueryContext(ctx, query, db.FormatVector(embedding))
+	if err != nil {
+		return nil, fmt.Errorf("knowledge base retrieval failed: knowledge_base='%s', table='%s', vector_column='%s', embedding_dimension=%d, error=%w",
+			base.Name, base.Table, base.VectorColumn, len(embedding), err)
+	}
+	defer rows.Close()
+
+	var passages []KnowledgePassage
+	for rows.Next() {
+		var sourceID, content, metadataJSON *string
+		var similarity float64
+		if err := rows.Scan(&sourceID, &content, &metadataJSON, &similarity); err != nil {
+			return nil, fmt.Errorf("knowledge base retrieval failed (scan passage): knowledge_base='%s', error=%w", base.Name, err)
+		}
+		if content == nil || strings.TrimSpace(*content) == "" {
+			continue
+		}
+		passage := KnowledgePassage{
+			KnowledgeBase: base.Name,
+			Content:       *content,
+			Similarity:    similarity,
+		}
+		if sourceID != nil {
+			passage.SourceID = *sourceID
+		}
+		if metadataJSON != nil && len(base.MetadataColumns) > 0 {
+			if err := json.Unmarshal([]byte(*metadataJSON), &passage.Metadata); err != nil {
+				return nil, fmt.Errorf("knowledge base retrieval failed (decode metadata): knowledge_base='%s', error=%w", base.Name, err)
+			}
+		}
+		passages = append(passages, passage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("knowledge base retrieval failed: knowledge_base='%s', error=%w", base.Name, err)
+	}
+	return passages, nil
+}
+
+// knowledgePromptParts lists the retrieved passages with their citation
+// numbers and asks the model to cite them
+func knowledgePromptParts(passages []KnowledgePassage) []string {
+	if len(passages) == 0 {
+		return nil
+	}
+	parts := []string{"\n\n## Knowledge Base:\nUse these sources where they answer the request and cite them as [n]."}
+	for _, passage := range passages {
+		label := passage.KnowledgeBase
+		if title, ok := passage.Metadata["title"].(string); ok && title != "" {
+			label += ": " + title
+		}
+		parts = append(parts, fmt.Sprintf("\n[%d] (%s) %s", passage.Citation, label, passage.Content))
+	}
+	return parts
+}
+
+// KnowledgeSource is a passage the answer was given, reported with the
+// answer. Cited is set when the answer refers to its citation number.
+type KnowledgeSource struct {
+	KnowledgePassage
+	Cited bool `json:"cited"`
+}
+
+// citationPattern matches citations such as [2] or [1, 3] in an answer
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// knowledgeSources lists the passages of the context, flagging those the
+// answer cites
+func knowledgeSources(agentContext *Context, answer string) []KnowledgeSource {
+	if agentContext == nil || len(agentContext.Passages) == 0 {
+		return nil
+	}
+	cited := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, n := range strings.Split(match[1], ",") {
+			if citation, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+				cited[citation] = true
+			}
+		}
+	}
+
+	sources := make([]KnowledgeSource, len(agentContext.Passages))
+	for i, passage := range agentContext.Passages {
+		sources[i] = KnowledgeSource{KnowledgePassage: passage, Cited: cited[passage.Citation]}
+	}
+	return sources
+}
+
+// knowledgeMetadata returns assistant message metadata listing the sources
+// of an answer, or nil when no passages were retrieved
+func knowledgeMetadata(sources []KnowledgeSource) map[string]interface{} {
+	if len(sources) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"sources": sources}
+}
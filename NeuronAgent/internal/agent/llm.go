@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/neurondb/NeuronAgent/internal/db"
 	"github.com/neurondb/NeuronAgent/internal/metrics"
@@ -13,114 +17,251 @@ import (
 type LLMClient struct {
 	llmClient   *neurondb.LLMClient
 	embedClient *neurondb.EmbeddingClient
+	httpClient  *http.Client
+	// fallback serves provider chains of only NeuronDB providers when the
+	// database cannot generate completions
+	fallback *LLMProviderConfig
+
+	breakersMu sync.Mutex
+	breakers   map[string]*circuitBreaker // by LLMProviderConfig.key()
 }
 
-func NewLLMClient(db *db.DB) *LLMClient {
+func NewLLMClient(db *db.DB, embedClient *neurondb.EmbeddingClient) *LLMClient {
 	return &LLMClient{
 		llmClient:   neurondb.NewLLMClient(db.DB),
-		embedClient: neurondb.NewEmbeddingClient(db.DB),
+		embedClient: embedClient,
+		httpClient:  &http.Client{},
+		breakers:    make(map[string]*circuitBreaker),
 	}
 }
 
-func (c *LLMClient) Generate(ctx context.Context, modelName string, prompt string, config map[string]interface{}) (*LLMResponse, error) {
-	llmConfig := neurondb.LLMConfig{
-		Model: modelName,
+// Source names what serves agents without their own providers:
+// "neurondb", the fallback provider as type:model, or "none"
+func (c *LLMClient) Source() string {
+	switch {
+	case c.llmClient.Available():
+		return ProviderNeuronDB
+	case c.fallback != nil:
+		return c.fallback.Label()
+	default:
+		return "none"
 	}
+}
 
-	// Extract config values
-	if temp, ok := config["temperature"].(float64); ok {
-		llmConfig.Temperature = &temp
+// availableProviders drops the NeuronDB providers from a chain when the
+// database cannot generate completions, falling back to the fallback
+// provider when none are left
+func (c *LLMClient) availableProviders(providers []LLMProviderConfig) ([]LLMProviderConfig, error) {
+	if c.llmClient.Available() {
+		return providers, nil
 	}
-	if maxTokens, ok := config["max_tokens"].(float64); ok {
-		maxTokensInt := int(maxTokens)
-		llmConfig.MaxTokens = &maxTokensInt
+	available := make([]LLMProviderConfig, 0, len(providers))
+	for _, provider := range providers {
+		if provider.Type != ProviderNeuronDB {
+			available = append(available, provider)
+		}
 	}
-	if topP, ok := config["top_p"].(float64); ok {
-		llmConfig.TopP = &topP
+	if len(available) == 0 {
+		if c.fallback == nil {
+			return nil, fmt.Errorf("%w, and no fallback LLM provider is configured", neurondb.ErrLLMUnavailable)
+		}
+		available = append(available, *c.fallback)
 	}
+	return available, nil
+}
 
-	result, err := c.llmClient.Generate(ctx, prompt, llmConfig)
-	
-	// Record metrics
-	status := "success"
+// Generate runs the prompt on the agent's provider chain (config "llm"),
+// trying each provider in order. Providers whose circuit breaker is open are
+// skipped; the first success is returned.
+func (c *LLMClient) Generate(ctx context.Context, modelName string, prompt string, config map[string]interface{}) (*LLMResponse, error) {
+	policy, err := ParseLLMProviderPolicy(config, modelName)
 	if err != nil {
-		status = "error"
+		return nil, fmt.Errorf("LLM generation failed: model_name='%s', invalid provider configuration, error=%w", modelName, err)
 	}
-	metrics.RecordLLMCall(modelName, status, result.TokensUsed, 0) // Completion tokens not available
-	
+	policy.Providers, err = c.availableProviders(policy.Providers)
 	if err != nil {
-		promptTokens := EstimateTokens(prompt)
-		temperature := "default"
-		if llmConfig.Temperature != nil {
-			temperature = fmt.Sprintf("%.2f", *llmConfig.Temperature)
+		return nil, fmt.Errorf("LLM generation failed: model_name='%s', error=%w", modelName, err)
+	}
+	opts := generationOptionsFromConfig(config)
+	promptTokens := EstimateTokens(prompt)
+	started := time.Now()
+
+	var attempts []string
+	overflow := false
+	for i, provider := range policy.Providers {
+		breaker := c.breaker(provider)
+		if !breaker.Allow(policy.Cooldown) {
+			attempts = append(attempts, fmt.Sprintf("%s: circuit open", provider.Label()))
+			continue
 		}
-		maxTokens := "default"
-		if llmConfig.MaxTokens != nil {
-			maxTokens = fmt.Sprintf("%d", *llmConfig.MaxTokens)
+
+		result, err := c.callProvider(ctx, provider, prompt, opts)
+		if err != nil {
+			metrics.RecordLLMCall(provider.Model, "error", 0, 0)
+			// A prompt too long for one model may fit the next one; the
+			// provider itself is healthy, so its breaker is left alone
+			if isContextLengthError(err) {
+				overflow = true
+			} else {
+				c.recordFailure(breaker, provider, policy)
+			}
+			attempts = append(attempts, fmt.Sprintf("%s: %v", provider.Label(), err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
 		}
-		topP := "default"
-		if llmConfig.TopP != nil {
-			topP = fmt.Sprintf("%.2f", *llmConfig.TopP)
+		breaker.Success()
+		metrics.RecordLLMCircuitState(provider.Label(), CircuitClosed)
+		if i > 0 {
+			metrics.RecordLLMFailover(provider.Label())
 		}
-		return nil, fmt.Errorf("LLM generation failed: model_name='%s', prompt_length=%d, prompt_tokens=%d, temperature=%s, max_tokens=%s, top_p=%s, streaming=false, error=%w",
-			modelName, len(prompt), promptTokens, temperature, maxTokens, topP, err)
+
+		if result.PromptTokens == 0 {
+			result.PromptTokens = promptTokens
+		}
+		if result.CompletionTokens == 0 {
+			result.CompletionTokens = EstimateTokens(result.Output)
+		}
+		metrics.RecordLLMCall(provider.Model, "success", result.PromptTokens, result.CompletionTokens)
+
+		return &LLMResponse{
+			Content:   result.Output,
+			ToolCalls: []ToolCall{}, // Will be parsed separately
+			Usage: TokenUsage{
+				PromptTokens:     result.PromptTokens,
+				CompletionTokens: result.CompletionTokens,
+				TotalTokens:      result.PromptTokens + result.CompletionTokens,
+			},
+			Provider:    provider.Label(),
+			Model:       provider.Model,
+			PromptChars: len(prompt),
+			Latency:     time.Since(started),
+			Retries:     attempts,
+		}, nil
 	}
 
-	// Estimate completion tokens if not provided
-	completionTokens := EstimateTokens(result.Output)
-	promptTokens := EstimateTokens(prompt)
-	if result.TokensUsed == 0 {
-		result.TokensUsed = promptTokens + completionTokens
-	}
-
-	return &LLMResponse{
-		Content:   result.Output,
-		ToolCalls: []ToolCall{}, // Will be parsed separately
-		Usage: TokenUsage{
-			PromptTokens:     promptTokens,
-			CompletionTokens: completionTokens,
-			TotalTokens:      result.TokensUsed,
-		},
-	}, nil
+	err = fmt.Errorf("LLM generation failed on all providers: model_name='%s', prompt_length=%d, prompt_tokens=%d, provider_count=%d, streaming=false, attempts=[%s]",
+		modelName, len(prompt), promptTokens, len(policy.Providers), strings.Join(attempts, "; "))
+	if overflow {
+		return nil, fmt.Errorf("%w: %w", ErrContextLengthExceeded, err)
+	}
+	return nil, err
 }
 
+// GenerateStream streams the completion from the first available provider.
+// NeuronDB streams natively; other providers write the whole completion at
+// once. A provider that fails before writing anything falls through to the
+// next one; once output has been written the error is returned.
 func (c *LLMClient) GenerateStream(ctx context.Context, modelName string, prompt string, config map[string]interface{}, writer io.Writer) error {
-	llmConfig := neurondb.LLMConfig{
-		Model:  modelName,
-		Stream: true,
+	policy, err := ParseLLMProviderPolicy(config, modelName)
+	if err != nil {
+		return fmt.Errorf("LLM streaming generation failed: model_name='%s', invalid provider configuration, error=%w", modelName, err)
 	}
+	policy.Providers, err = c.availableProviders(policy.Providers)
+	if err != nil {
+		return fmt.Errorf("LLM streaming generation failed: model_name='%s', error=%w", modelName, err)
+	}
+	opts := generationOptionsFromConfig(config)
 
-	// Extract config values
-	if temp, ok := config["temperature"].(float64); ok {
-		llmConfig.Temperature = &temp
+	var attempts []string
+	overflow := false
+	for i, provider := range policy.Providers {
+		breaker := c.breaker(provider)
+		if !breaker.Allow(policy.Cooldown) {
+			attempts = append(attempts, fmt.Sprintf("%s: circuit open", provider.Label()))
+			continue
+		}
+
+		counter := &countingWriter{w: writer}
+		err := c.streamProvider(ctx, provider, prompt, opts, counter)
+		if err != nil {
+			metrics.RecordLLMCall(provider.Model, "error", 0, 0)
+			if isContextLengthError(err) {
+				overflow = true
+			} else {
+				c.recordFailure(breaker, provider, policy)
+			}
+			if counter.n > 0 {
+				return fmt.Errorf("LLM streaming generation failed after partial output: provider='%s', bytes_written=%d, error=%w",
+					provider.Label(), counter.n, err)
+			}
+			attempts = append(attempts, fmt.Sprintf("%s: %v", provider.Label(), err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		breaker.Success()
+		metrics.RecordLLMCircuitState(provider.Label(), CircuitClosed)
+		if i > 0 {
+			metrics.RecordLLMFailover(provider.Label())
+		}
+		metrics.RecordLLMCall(provider.Model, "success", EstimateTokens(prompt), 0)
+		return nil
 	}
-	if maxTokens, ok := config["max_tokens"].(float64); ok {
-		maxTokensInt := int(maxTokens)
-		llmConfig.MaxTokens = &maxTokensInt
+
+	err = fmt.Errorf("LLM streaming generation failed on all providers: model_name='%s', prompt_length=%d, prompt_tokens=%d, provider_count=%d, streaming=true, attempts=[%s]",
+		modelName, len(prompt), EstimateTokens(prompt), len(policy.Providers), strings.Join(attempts, "; "))
+	if overflow {
+		return fmt.Errorf("%w: %w", ErrContextLengthExceeded, err)
 	}
-	if topP, ok := config["top_p"].(float64); ok {
-		llmConfig.TopP = &topP
+	return err
+}
+
+func (c *LLMClient) callProvider(ctx context.Context, provider LLMProviderConfig, prompt string, opts generationOptions) (*providerResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, provider.Timeout)
+	defer cancel()
+	if provider.Type == ProviderNeuronDB {
+		return c.callNeuronDB(ctx, provider, prompt, opts)
 	}
+	return c.callHTTPProvider(ctx, provider, prompt, opts)
+}
 
-	err := c.llmClient.GenerateStream(ctx, prompt, llmConfig, writer)
+func (c *LLMClient) streamProvider(ctx context.Context, provider LLMProviderConfig, prompt string, opts generationOptions, writer io.Writer) error {
+	if provider.Type == ProviderNeuronDB {
+		return c.llmClient.GenerateStream(ctx, prompt, neurondb.LLMConfig{
+			Model:       provider.Model,
+			Temperature: opts.Temperature,
+			MaxTokens:   opts.MaxTokens,
+			TopP:        opts.TopP,
+			Stream:      true,
+		}, writer)
+	}
+	result, err := c.callProvider(ctx, provider, prompt, opts)
 	if err != nil {
-		promptTokens := EstimateTokens(prompt)
-		temperature := "default"
-		if llmConfig.Temperature != nil {
-			temperature = fmt.Sprintf("%.2f", *llmConfig.Temperature)
-		}
-		maxTokens := "default"
-		if llmConfig.MaxTokens != nil {
-			maxTokens = fmt.Sprintf("%d", *llmConfig.MaxTokens)
-		}
-		topP := "default"
-		if llmConfig.TopP != nil {
-			topP = fmt.Sprintf("%.2f", *llmConfig.TopP)
-		}
-		return fmt.Errorf("LLM streaming generation failed: model_name='%s', prompt_length=%d, prompt_tokens=%d, temperature=%s, max_tokens=%s, top_p=%s, streaming=true, error=%w",
-			modelName, len(prompt), promptTokens, temperature, maxTokens, topP, err)
+		return err
 	}
-	return nil
+	_, err = io.WriteString(writer, result.Output)
+	return err
+}
+
+func (c *LLMClient) breaker(provider LLMProviderConfig) *circuitBreaker {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+	b, ok := c.breakers[provider.key()]
+	if !ok {
+		b = newCircuitBreaker()
+		c.breakers[provider.key()] = b
+	}
+	return b
+}
+
+func (c *LLMClient) recordFailure(breaker *circuitBreaker, provider LLMProviderConfig, policy *LLMProviderPolicy) {
+	breaker.Failure(policy.FailureThreshold)
+	metrics.RecordLLMCircuitState(provider.Label(), breaker.State())
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += n
+	return n, err
 }
 
 func (c *LLMClient) Embed(ctx context.Context, model string, text string) ([]float32, error) {
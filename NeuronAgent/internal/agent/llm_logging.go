@@ -0,0 +1,321 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// LLM call log sinks
+const (
+	LLMLogSinkTable = "table"
+	LLMLogSinkFile  = "file"
+)
+
+// LLM call log defaults
+const (
+	defaultLLMLogSamplePercent = 10
+	defaultLLMLogMaxChars      = 20000
+	maxLLMLogMaxChars          = 1000000
+	llmLogWriteTimeout         = 10 * time.Second
+)
+
+// LLMLogPolicy logs a sample of an agent's LLM prompts and responses, for
+// debugging model behavior without logging every call. It is read from the
+// "llm_logging" object of the agent config:
+//
+//	"llm_logging": {
+//	  "enabled": true,
+//	  "sample_percent": 10,  // share of LLM calls logged (0-100)
+//	  "redact": true,        // replace secrets and PII with placeholders
+//	  "sink": "table",       // "table" (neurondb_agent.llm_logs) or "file"
+//	  "max_chars": 20000     // prompts and responses are cut to this many characters
+//	}
+//
+// The file sink is the JSON lines file the server names in LLM_LOG_FILE;
+// without one, entries go to the table.
+type LLMLogPolicy struct {
+	Enabled       bool    `json:"enabled"`
+	SamplePercent float64 `json:"sample_percent"`
+	Redact        bool    `json:"redact"`
+	Sink          string  `json:"sink"`
+	MaxChars      int     `json:"max_chars"`
+}
+
+// ParseLLMLogPolicy extracts the LLM call log policy from an agent config.
+// A missing "llm_logging" key yields a disabled policy.
+func ParseLLMLogPolicy(config map[string]interface{}) (*LLMLogPolicy, error) {
+	policy := &LLMLogPolicy{
+		SamplePercent: defaultLLMLogSamplePercent,
+		Redact:        true,
+		Sink:          LLMLogSinkTable,
+		MaxChars:      defaultLLMLogMaxChars,
+	}
+	raw, ok := config["llm_logging"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("llm_logging must be an object, got %T", raw)
+	}
+
+	if v, ok := settings["enabled"]; ok {
+		enabled, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("llm_logging.enabled must be a boolean")
+		}
+		policy.Enabled = enabled
+	}
+	if v, ok := settings["sample_percent"]; ok {
+		percent, ok := v.(float64)
+		if !ok || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("llm_logging.sample_percent must be a number from 0 to 100")
+		}
+		policy.SamplePercent = percent
+	}
+	if v, ok := settings["redact"]; ok {
+		redact, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("llm_logging.redact must be a boolean")
+		}
+		policy.Redact = redact
+	}
+	if v, ok := settings["sink"]; ok {
+		sink, ok := v.(string)
+		if !ok || !oneOf(sink, LLMLogSinkTable, LLMLogSinkFile) {
+			return nil, fmt.Errorf("llm_logging.sink must be one of %s, %s", LLMLogSinkTable, LLMLogSinkFile)
+		}
+		policy.Sink = sink
+	}
+	if v, ok := settings["max_chars"]; ok {
+		n, ok := v.(float64)
+		if !ok || n != float64(int(n)) || n < 1 || n > maxLLMLogMaxChars {
+			return nil, fmt.Errorf("llm_logging.max_chars must be an integer from 1 to %d", maxLLMLogMaxChars)
+		}
+		policy.MaxChars = int(n)
+	}
+
+	return policy, nil
+}
+
+// sampled decides whether one LLM call is logged
+func (p *LLMLogPolicy) sampled() bool {
+	if !p.Enabled || p.SamplePercent <= 0 {
+		return false
+	}
+	return p.SamplePercent >= 100 || rand.Float64()*100 < p.SamplePercent
+}
+
+// prepare cuts text to the policy's length and redacts it, counting the
+// redacted values by type in redactions. It reports whether text was cut.
+func (p *LLMLogPolicy) prepare(text string, redactions map[string]interface{}) (string, bool) {
+	if p.Redact {
+		text = redactLLMLogText(text, redactions)
+	}
+	runes := []rune(text)
+	if len(runes) <= p.MaxChars {
+		return text, false
+	}
+	return string(runes[:p.MaxChars]), true
+}
+
+// secretTypeOrder lists the secret types in the order they are redacted,
+// before PII. Whole tokens go before the key=value form that may contain
+// them.
+var secretTypeOrder = []string{"private_key", "bearer_token", "jwt", "api_key", "credential"}
+
+var secretPatterns = map[string]*regexp.Regexp{
+	"private_key":  regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	"bearer_token": regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]{8,}=*`),
+	"jwt":          regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{5,}\.[A-Za-z0-9_\-]{5,}\.[A-Za-z0-9_\-]{5,}`),
+	"api_key":      regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_\-]{16,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{30,}|xox[abprs]-[A-Za-z0-9\-]{10,}|AIza[0-9A-Za-z_\-]{35})`),
+	// credential keeps the name and replaces only the value
+	"credential": regexp.MustCompile(`(?i)\b((?:password|passwd|pwd|secret|api[_\-]?key|access[_\-]?token|auth[_\-]?token|client[_\-]?secret)["']?\s*[:=]\s*["']?)([^\s"',;]+)`),
+}
+
+// redactLLMLogText replaces secrets and PII in text with placeholders such
+// as [REDACTED_API_KEY], adding the number replaced of each type to counts
+func redactLLMLogText(text string, counts map[string]interface{}) string {
+	count := func(t string, n int) {
+		if n == 0 {
+			return
+		}
+		prev, _ := counts[t].(int)
+		counts[t] = prev + n
+	}
+
+	for _, t := range secretTypeOrder {
+		re := secretPatterns[t]
+		placeholder := "[REDACTED_" + strings.ToUpper(t) + "]"
+		n := 0
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			n++
+			if t == "credential" {
+				return re.ReplaceAllString(match, "${1}"+placeholder)
+			}
+			return placeholder
+		})
+		count(t, n)
+	}
+	for _, t := range piiTypeOrder {
+		placeholder := "[REDACTED_" + strings.ToUpper(t) + "]"
+		n := 0
+		text = piiPatterns[t].ReplaceAllStringFunc(text, func(match string) string {
+			if t == "credit_card" && !luhnValid(match) {
+				return match
+			}
+			n++
+			return placeholder
+		})
+		count(t, n)
+	}
+	return text
+}
+
+// LLMLogFile is a JSON lines file the LLM calls of agents with the "file"
+// sink are appended to
+type LLMLogFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewLLMLogFile opens path for appending, creating it if needed
+func NewLLMLogFile(path string) (*LLMLogFile, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LLM log file: path='%s', error=%w", path, err)
+	}
+	return &LLMLogFile{file: file}, nil
+}
+
+// llmLogLine is an entry of the LLM log file
+type llmLogLine struct {
+	Time             time.Time              `json:"time"`
+	AgentID          uuid.UUID              `json:"agent_id"`
+	SessionID        *uuid.UUID             `json:"session_id,omitempty"`
+	RunID            *uuid.UUID             `json:"run_id,omitempty"`
+	Purpose          string                 `json:"purpose"`
+	Model            string                 `json:"model"`
+	Provider         *string                `json:"provider,omitempty"`
+	Prompt           string                 `json:"prompt"`
+	Response         *string                `json:"response,omitempty"`
+	Error            *string                `json:"error,omitempty"`
+	PromptTokens     int                    `json:"prompt_tokens"`
+	CompletionTokens int                    `json:"completion_tokens"`
+	LatencyMs        float64                `json:"latency_ms"`
+	Redactions       map[string]interface{} `json:"redactions,omitempty"`
+	Truncated        bool                   `json:"truncated,omitempty"`
+}
+
+// Write appends entry to the file as one line
+func (f *LLMLogFile) Write(entry *db.LLMLog) error {
+	line, err := json.Marshal(llmLogLine{
+		Time:             time.Now().UTC(),
+		AgentID:          entry.AgentID,
+		SessionID:        entry.SessionID,
+		RunID:            entry.RunID,
+		Purpose:          entry.Purpose,
+		Model:            entry.Model,
+		Provider:         entry.Provider,
+		Prompt:           entry.Prompt,
+		Response:         entry.Response,
+		Error:            entry.ErrorMessage,
+		PromptTokens:     entry.PromptTokens,
+		CompletionTokens: entry.CompletionTokens,
+		LatencyMs:        entry.LatencyMs,
+		Redactions:       entry.Redactions,
+		Truncated:        entry.Truncated,
+	})
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file
+func (f *LLMLogFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// logLLMCall writes a sampled LLM call of an agent with llm_logging enabled
+// to its sink. The write runs in the background and only logs failures, so
+// logging never slows down or fails a run.
+func (r *Runtime) logLLMCall(agent *db.Agent, state *ExecutionState, purpose, prompt string, resp *LLMResponse, callErr error, latency time.Duration) {
+	// Invalid policies are rejected when the agent is saved
+	policy, err := ParseLLMLogPolicy(agent.Config.ToMap())
+	if err != nil || !policy.sampled() {
+		return
+	}
+
+	redactions := map[string]interface{}{}
+	sessionID := state.SessionID
+	entry := &db.LLMLog{
+		AgentID:   agent.ID,
+		SessionID: &sessionID,
+		RunID:     state.RunID,
+		Purpose:   purpose,
+		Model:     agent.ModelName,
+		LatencyMs: durationMs(latency),
+	}
+	entry.Prompt, entry.Truncated = policy.prepare(prompt, redactions)
+	if resp != nil {
+		if resp.Model != "" {
+			entry.Model = resp.Model
+		}
+		if resp.Provider != "" {
+			provider := resp.Provider
+			entry.Provider = &provider
+		}
+		response, truncated := policy.prepare(resp.Content, redactions)
+		entry.Response = &response
+		entry.Truncated = entry.Truncated || truncated
+		entry.PromptTokens = resp.Usage.PromptTokens
+		entry.CompletionTokens = resp.Usage.CompletionTokens
+	}
+	if callErr != nil {
+		message, _ := policy.prepare(callErr.Error(), redactions)
+		entry.ErrorMessage = &message
+	}
+	entry.Redactions = redactions
+
+	sink := policy.Sink
+	file := r.llmLogFile
+	if sink == LLMLogSinkFile && file == nil {
+		sink = LLMLogSinkTable
+	}
+	go func() {
+		var err error
+		if sink == LLMLogSinkFile {
+			err = file.Write(entry)
+		} else {
+			bgCtx, cancel := context.WithTimeout(context.Background(), llmLogWriteTimeout)
+			defer cancel()
+			err = r.queries.CreateLLMLog(bgCtx, entry)
+		}
+		if err != nil {
+			metrics.RecordLLMLog(sink, "error")
+			metrics.Logger().Warn().Err(err).
+				Str("agent_id", agent.ID.String()).
+				Str("sink", sink).
+				Msg("Failed to log LLM call")
+			return
+		}
+		metrics.RecordLLMLog(sink, "logged")
+	}()
+}
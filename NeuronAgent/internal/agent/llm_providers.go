@@ -0,0 +1,434 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+// LLM provider types
+const (
+	ProviderNeuronDB  = "neurondb"
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+const (
+	defaultProviderTimeout         = 60 * time.Second
+	defaultCircuitFailureThreshold = 3
+	defaultCircuitCooldown         = 30 * time.Second
+	defaultAnthropicMaxTokens      = 1024
+	maxProviderResponseBytes       = 10 * 1024 * 1024
+)
+
+var defaultProviderEndpoints = map[string]string{
+	ProviderOpenAI:    "https://api.openai.com/v1",
+	ProviderAnthropic: "https://api.anthropic.com",
+	ProviderOllama:    "http://localhost:11434",
+}
+
+var defaultProviderKeyEnv = map[string]string{
+	ProviderOpenAI:    "OPENAI_API_KEY",
+	ProviderAnthropic: "ANTHROPIC_API_KEY",
+}
+
+// LLMProviderConfig configures one provider of an agent's fallback chain
+type LLMProviderConfig struct {
+	Type      string        `json:"type"`
+	Model     string        `json:"model"`
+	Endpoint  string        `json:"endpoint,omitempty"`
+	APIKeyEnv string        `json:"api_key_env,omitempty"` // environment variable holding the API key
+	Timeout   time.Duration `json:"-"`
+}
+
+// key identifies the provider endpoint and model for circuit breaking, so
+// agents sharing a provider share its breaker
+func (p LLMProviderConfig) key() string {
+	return p.Type + "|" + p.Endpoint + "|" + p.Model
+}
+
+// Label names the provider in errors, metrics and responses
+func (p LLMProviderConfig) Label() string {
+	return p.Type + ":" + p.Model
+}
+
+// LLMProviderPolicy is an agent's ordered provider chain and circuit breaker
+// settings
+type LLMProviderPolicy struct {
+	Providers        []LLMProviderConfig `json:"providers"`
+	FailureThreshold int                 `json:"failure_threshold"`
+	Cooldown         time.Duration       `json:"-"`
+}
+
+// ParseLLMProviderPolicy extracts the provider chain from an agent config. A
+// missing "llm" key yields a single NeuronDB provider running modelName.
+func ParseLLMProviderPolicy(config map[string]interface{}, modelName string) (*LLMProviderPolicy, error) {
+	policy := &LLMProviderPolicy{
+		FailureThreshold: defaultCircuitFailureThreshold,
+		Cooldown:         defaultCircuitCooldown,
+	}
+
+	raw, ok := config["llm"]
+	if !ok || raw == nil {
+		policy.Providers = []LLMProviderConfig{{Type: ProviderNeuronDB, Model: modelName, Timeout: defaultProviderTimeout}}
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("llm must be an object, got %T", raw)
+	}
+
+	rawProviders, ok := settings["providers"].([]interface{})
+	if !ok || len(rawProviders) == 0 {
+		return nil, fmt.Errorf("llm.providers must be a non-empty array")
+	}
+	for i, rp := range rawProviders {
+		p, ok := rp.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("llm.providers[%d] must be an object", i)
+		}
+		provider, err := parseLLMProvider(p, modelName)
+		if err != nil {
+			return nil, fmt.Errorf("llm.providers[%d]: %w", i, err)
+		}
+		policy.Providers = append(policy.Providers, provider)
+	}
+
+	if rawBreaker, ok := settings["circuit_breaker"]; ok && rawBreaker != nil {
+		breaker, ok := rawBreaker.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("llm.circuit_breaker must be an object")
+		}
+		if v, ok := breaker["failure_threshold"]; ok {
+			n, ok := v.(float64)
+			if !ok || n < 1 || n != float64(int(n)) {
+				return nil, fmt.Errorf("llm.circuit_breaker.failure_threshold must be a positive integer")
+			}
+			policy.FailureThreshold = int(n)
+		}
+		if v, ok := breaker["cooldown_seconds"]; ok {
+			seconds, ok := v.(float64)
+			if !ok || seconds <= 0 {
+				return nil, fmt.Errorf("llm.circuit_breaker.cooldown_seconds must be a positive number")
+			}
+			policy.Cooldown = time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	return policy, nil
+}
+
+func parseLLMProvider(p map[string]interface{}, modelName string) (LLMProviderConfig, error) {
+	provider := LLMProviderConfig{Timeout: defaultProviderTimeout}
+
+	provider.Type, _ = p["type"].(string)
+	if !oneOf(provider.Type, ProviderNeuronDB, ProviderOpenAI, ProviderAnthropic, ProviderOllama) {
+		return provider, fmt.Errorf("type must be one of neurondb, openai, anthropic or ollama")
+	}
+	if _, ok := p["api_key"]; ok {
+		return provider, fmt.Errorf("api_key must not be stored in the agent config; set api_key_env to the environment variable holding the key")
+	}
+
+	provider.Model = modelName
+	if v, ok := p["model"]; ok {
+		model, ok := v.(string)
+		if !ok || model == "" {
+			return provider, fmt.Errorf("model must be a non-empty string")
+		}
+		provider.Model = model
+	}
+
+	if v, ok := p["endpoint"]; ok {
+		endpoint, ok := v.(string)
+		if !ok || !(strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")) {
+			return provider, fmt.Errorf("endpoint must be an http(s) URL")
+		}
+		if provider.Type == ProviderNeuronDB {
+			return provider, fmt.Errorf("endpoint is not supported for the neurondb provider")
+		}
+		provider.Endpoint = strings.TrimRight(endpoint, "/")
+	} else {
+		provider.Endpoint = defaultProviderEndpoints[provider.Type]
+	}
+
+	provider.APIKeyEnv = defaultProviderKeyEnv[provider.Type]
+	if v, ok := p["api_key_env"]; ok {
+		env, ok := v.(string)
+		if !ok || env == "" {
+			return provider, fmt.Errorf("api_key_env must be a non-empty string")
+		}
+		provider.APIKeyEnv = env
+	}
+
+	if v, ok := p["timeout_seconds"]; ok {
+		seconds, ok := v.(float64)
+		if !ok || seconds <= 0 {
+			return provider, fmt.Errorf("timeout_seconds must be a positive number")
+		}
+		provider.Timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	return provider, nil
+}
+
+// NewFallbackLLMProvider validates the server's fallback LLM provider,
+// which serves agents without their own providers when the database cannot
+// generate completions. Empty endpoints and key variables take the
+// provider's defaults.
+func NewFallbackLLMProvider(providerType, model, endpoint, apiKeyEnv string, timeout time.Duration) (LLMProviderConfig, error) {
+	if providerType == ProviderNeuronDB {
+		return LLMProviderConfig{}, fmt.Errorf("the fallback LLM provider cannot be neurondb")
+	}
+	if model == "" {
+		return LLMProviderConfig{}, fmt.Errorf("the fallback LLM provider needs a model")
+	}
+	p := map[string]interface{}{"type": providerType, "model": model}
+	if endpoint != "" {
+		p["endpoint"] = endpoint
+	}
+	if apiKeyEnv != "" {
+		p["api_key_env"] = apiKeyEnv
+	}
+	if timeout > 0 {
+		p["timeout_seconds"] = timeout.Seconds()
+	}
+	return parseLLMProvider(p, model)
+}
+
+// generationOptions are the sampling settings taken from the agent config
+type generationOptions struct {
+	Temperature *float64
+	MaxTokens   *int
+	TopP        *float64
+}
+
+func generationOptionsFromConfig(config map[string]interface{}) generationOptions {
+	var opts generationOptions
+	if temp, ok := config["temperature"].(float64); ok {
+		opts.Temperature = &temp
+	}
+	if maxTokens, ok := config["max_tokens"].(float64); ok {
+		maxTokensInt := int(maxTokens)
+		opts.MaxTokens = &maxTokensInt
+	}
+	if topP, ok := config["top_p"].(float64); ok {
+		opts.TopP = &topP
+	}
+	return opts
+}
+
+// providerResult is the output of a single provider call. Token counts are
+// zero when the provider does not report them.
+type providerResult struct {
+	Output           string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// callHTTPProvider generates a completion with an OpenAI, Anthropic or Ollama
+// endpoint
+func (c *LLMClient) callHTTPProvider(ctx context.Context, provider LLMProviderConfig, prompt string, opts generationOptions) (*providerResult, error) {
+	var (
+		url     string
+		body    map[string]interface{}
+		headers = map[string]string{"Content-Type": "application/json"}
+	)
+
+	apiKey := ""
+	if provider.APIKeyEnv != "" {
+		apiKey = os.Getenv(provider.APIKeyEnv)
+	}
+
+	switch provider.Type {
+	case ProviderOpenAI:
+		if apiKey == "" {
+			return nil, fmt.Errorf("API key environment variable %s is not set", provider.APIKeyEnv)
+		}
+		url = provider.Endpoint + "/chat/completions"
+		headers["Authorization"] = "Bearer " + apiKey
+		body = map[string]interface{}{
+			"model":    provider.Model,
+			"messages": []map[string]string{{"role": "user", "content": prompt}},
+		}
+		if opts.Temperature != nil {
+			body["temperature"] = *opts.Temperature
+		}
+		if opts.MaxTokens != nil {
+			body["max_tokens"] = *opts.MaxTokens
+		}
+		if opts.TopP != nil {
+			body["top_p"] = *opts.TopP
+		}
+	case ProviderAnthropic:
+		if apiKey == "" {
+			return nil, fmt.Errorf("API key environment variable %s is not set", provider.APIKeyEnv)
+		}
+		url = provider.Endpoint + "/v1/messages"
+		headers["x-api-key"] = apiKey
+		headers["anthropic-version"] = "2023-06-01"
+		maxTokens := defaultAnthropicMaxTokens
+		if opts.MaxTokens != nil {
+			maxTokens = *opts.MaxTokens
+		}
+		body = map[string]interface{}{
+			"model":      provider.Model,
+			"max_tokens": maxTokens,
+			"messages":   []map[string]string{{"role": "user", "content": prompt}},
+		}
+		if opts.Temperature != nil {
+			body["temperature"] = *opts.Temperature
+		}
+		if opts.TopP != nil {
+			body["top_p"] = *opts.TopP
+		}
+	case ProviderOllama:
+		url = provider.Endpoint + "/api/generate"
+		if apiKey != "" {
+			headers["Authorization"] = "Bearer " + apiKey
+		}
+		options := map[string]interface{}{}
+		if opts.Temperature != nil {
+			options["temperature"] = *opts.Temperature
+		}
+		if opts.MaxTokens != nil {
+			options["num_predict"] = *opts.MaxTokens
+		}
+		if opts.TopP != nil {
+			options["top_p"] = *opts.TopP
+		}
+		body = map[string]interface{}{
+			"model":   provider.Model,
+			"prompt":  prompt,
+			"stream":  false,
+			"options": options,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported provider type '%s'", provider.Type)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxProviderResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		preview := string(respBody)
+		if len(preview) > 200 {
+			preview = preview[:200] + "..."
+		}
+		return nil, fmt.Errorf("%s returned HTTP %d: %s", url, resp.StatusCode, preview)
+	}
+
+	return parseProviderResponse(provider.Type, respBody)
+}
+
+// parseProviderResponse extracts the completion and token usage from a
+// provider response body
+func parseProviderResponse(providerType string, body []byte) (*providerResult, error) {
+	switch providerType {
+	case ProviderOpenAI:
+		var resp struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+			Usage struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("invalid openai response: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("openai response has no choices")
+		}
+		return &providerResult{
+			Output:           resp.Choices[0].Message.Content,
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		}, nil
+	case ProviderAnthropic:
+		var resp struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+			Usage struct {
+				InputTokens  int `json:"input_tokens"`
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("invalid anthropic response: %w", err)
+		}
+		var text strings.Builder
+		for _, block := range resp.Content {
+			if block.Type == "text" {
+				text.WriteString(block.Text)
+			}
+		}
+		return &providerResult{
+			Output:           text.String(),
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+		}, nil
+	case ProviderOllama:
+		var resp struct {
+			Response        string `json:"response"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("invalid ollama response: %w", err)
+		}
+		return &providerResult{
+			Output:           resp.Response,
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider type '%s'", providerType)
+	}
+}
+
+// callNeuronDB generates a completion through NeuronDB's LLM functions
+func (c *LLMClient) callNeuronDB(ctx context.Context, provider LLMProviderConfig, prompt string, opts generationOptions) (*providerResult, error) {
+	result, err := c.llmClient.Generate(ctx, prompt, neurondb.LLMConfig{
+		Model:       provider.Model,
+		Temperature: opts.Temperature,
+		MaxTokens:   opts.MaxTokens,
+		TopP:        opts.TopP,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &providerResult{Output: result.Output}, nil
+}
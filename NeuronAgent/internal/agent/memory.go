@@ -11,10 +11,26 @@ import (
 	"github.com/neurondb/NeuronAgent/pkg/neurondb"
 )
 
+// memoryEmbeddingModel embeds memory chunks and the queries they are
+// searched with, unless the agent config names another model
+const memoryEmbeddingModel = "all-MiniLM-L6-v2"
+
+// MemoryEmbeddingModel returns the model the agent's memory is embedded with
+func MemoryEmbeddingModel(agent *db.Agent) string {
+	// Invalid policies are rejected when the agent is saved
+	policy, err := ParseMemoryBackendPolicy(agent.Config)
+	if err != nil {
+		return memoryEmbeddingModel
+	}
+	return policy.EmbeddingModel
+}
+
 type MemoryManager struct {
 	db      *db.DB
 	queries *db.Queries
 	embed   *neurondb.EmbeddingClient
+	// stores holds the memory stores by backend name
+	stores map[string]MemoryStore
 }
 
 type MemoryChunk struct {
@@ -30,47 +46,72 @@ func NewMemoryManager(db *db.DB, queries *db.Queries, embedClient *neurondb.Embe
 		db:      db,
 		queries: queries,
 		embed:   embedClient,
+		stores: map[string]MemoryStore{
+			MemoryBackendPostgres: NewPostgresMemoryStore(queries),
+			MemoryBackendInMemory: NewInMemoryStore(),
+		},
 	}
 }
 
-func (m *MemoryManager) Retrieve(ctx context.Context, agentID uuid.UUID, queryEmbedding []float32, topK int) ([]MemoryChunk, error) {
-	// Record metrics
-	defer func() {
-		metrics.RecordMemoryRetrieval(agentID.String())
-	}()
+// RegisterStore makes a memory backend available to agents, replacing any
+// store registered for it
+func (m *MemoryManager) RegisterStore(backend string, store MemoryStore) {
+	m.stores[backend] = store
+}
 
-	chunks, err := m.queries.SearchMemory(ctx, agentID, queryEmbedding, topK)
+// Enabled reports whether memory can be used: chunks are stored and found
+// by their embeddings, so memory is disabled when texts cannot be embedded
+func (m *MemoryManager) Enabled() bool {
+	return m.embed.Available()
+}
+
+// storeFor returns the memory store the agent's config selects
+func (m *MemoryManager) storeFor(agent *db.Agent) (MemoryStore, *MemoryBackendPolicy, error) {
+	policy, err := ParseMemoryBackendPolicy(agent.Config)
 	if err != nil {
-		return nil, fmt.Errorf("memory retrieval failed: agent_id='%s', query_embedding_dimension=%d, top_k=%d, error=%w",
-			agentID.String(), len(queryEmbedding), topK, err)
+		return nil, nil, err
+	}
+	store, ok := m.stores[policy.Backend]
+	if !ok {
+		return nil, nil, fmt.Errorf("memory backend '%s' is not configured on this server", policy.Backend)
 	}
+	return store, policy, nil
+}
+
+func (m *MemoryManager) Retrieve(ctx context.Context, agent *db.Agent, queryEmbedding []float32, topK int) ([]MemoryChunk, error) {
+	// Record metrics
+	defer func() {
+		metrics.RecordMemoryRetrieval(agent.ID.String())
+	}()
 
-	result := make([]MemoryChunk, len(chunks))
-	for i, chunk := range chunks {
-		result[i] = MemoryChunk{
-			ID:              chunk.ID,
-			Content:         chunk.Content,
-			ImportanceScore: chunk.ImportanceScore,
-			Similarity:      chunk.Similarity,
-			Metadata:        chunk.Metadata,
+	store, policy, err := m.storeFor(agent)
+	if err == nil {
+		var chunks []MemoryChunk
+		if chunks, err = store.Search(ctx, agent.ID, queryEmbedding, topK, policy); err == nil {
+			return chunks, nil
 		}
 	}
-
-	return result, nil
+	return nil, fmt.Errorf("memory retrieval failed: agent_id='%s', query_embedding_dimension=%d, top_k=%d, error=%w",
+		agent.ID.String(), len(queryEmbedding), topK, err)
 }
 
-func (m *MemoryManager) StoreChunks(ctx context.Context, agentID, sessionID uuid.UUID, content string, toolResults []ToolResult) {
+func (m *MemoryManager) StoreChunks(ctx context.Context, agent *db.Agent, sessionID uuid.UUID, content string, toolResults []ToolResult) {
 	// Compute importance score (heuristic: length, user flags, etc.)
 	importance := m.computeImportance(content, toolResults)
 
 	// Only store if importance > threshold
-	if importance < 0.3 {
+	if importance < 0.3 || !m.Enabled() {
+		return
+	}
+
+	store, policy, err := m.storeFor(agent)
+	if err != nil {
+		metrics.Logger().Error().Err(err).Str("agent_id", agent.ID.String()).Msg("Failed to select memory store")
 		return
 	}
 
 	// Compute embedding
-	embeddingModel := "all-MiniLM-L6-v2"
-	embedding, err := m.embed.Embed(ctx, content, embeddingModel)
+	embedding, err := m.embed.Embed(ctx, content, policy.EmbeddingModel)
 	if err != nil {
 		// Log error but don't fail (async operation)
 		// Error is already detailed in embedding client
@@ -78,21 +119,21 @@ func (m *MemoryManager) StoreChunks(ctx context.Context, agentID, sessionID uuid
 	}
 
 	// Store chunk
-	_, err = m.queries.CreateMemoryChunk(ctx, &db.MemoryChunk{
-		AgentID:         agentID,
+	err = store.Store(ctx, &db.MemoryChunk{
+		AgentID:         agent.ID,
 		SessionID:       &sessionID,
 		Content:         content,
 		Embedding:       embedding,
 		ImportanceScore: importance,
-	})
+	}, policy)
 	if err != nil {
 		// Log error but don't fail (async operation)
-		// Error is already detailed in queries.CreateMemoryChunk
+		// Error is already detailed by the store
 		return
 	}
 
 	// Record metrics
-	metrics.RecordMemoryChunkStored(agentID.String())
+	metrics.RecordMemoryChunkStored(agent.ID.String())
 }
 
 func (m *MemoryManager) computeImportance(content string, toolResults []ToolResult) float64 {
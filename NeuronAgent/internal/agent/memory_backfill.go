@@ -0,0 +1,473 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+// MemoryBackfillJobType is the job type processed by MemoryBackfiller.Run
+const MemoryBackfillJobType = "memory_backfill"
+
+const (
+	defaultBackfillBatchSize  = 64
+	maxBackfillBatchSize      = 1000
+	defaultBackfillImportance = 0.5
+	defaultBackfillModel      = "all-MiniLM-L6-v2"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// MemoryBackfillRequest describes a table whose rows are embedded into an
+// agent's memory, one chunk per row. It is stored as the job payload.
+type MemoryBackfillRequest struct {
+	SourceTable     string                 `json:"source_table"`               // table or schema.table
+	TextColumn      string                 `json:"text_column"`                // column holding the chunk content
+	IDColumn        string                 `json:"id_column,omitempty"`        // unique, orderable column; default "id"
+	MetadataColumns []string               `json:"metadata_columns,omitempty"` // copied into chunk metadata
+	Filter          map[string]interface{} `json:"filter,omitempty"`           // column -> value equality; null matches IS NULL
+	BatchSize       int                    `json:"batch_size,omitempty"`
+	Model           string                 `json:"model,omitempty"`
+	Importance      *float64               `json:"importance,omitempty"`
+	Limit           int                    `json:"limit,omitempty"` // maximum rows to process; 0 means all
+}
+
+// Normalize validates the request and fills in defaults
+func (r *MemoryBackfillRequest) Normalize() error {
+	if r.SourceTable == "" {
+		return fmt.Errorf("source_table is required")
+	}
+	if _, err := quoteQualifiedIdentifier(r.SourceTable); err != nil {
+		return fmt.Errorf("source_table: %w", err)
+	}
+	if r.TextColumn == "" {
+		return fmt.Errorf("text_column is required")
+	}
+	if r.IDColumn == "" {
+		r.IDColumn = "id"
+	}
+	columns := append([]string{r.TextColumn, r.IDColumn}, r.MetadataColumns...)
+	for column := range r.Filter {
+		columns = append(columns, column)
+	}
+	for _, column := range columns {
+		if !identifierPattern.MatchString(column) {
+			return fmt.Errorf("invalid column name '%s'", column)
+		}
+	}
+	for column, value := range r.Filter {
+		switch value.(type) {
+		case nil, string, float64, bool:
+		default:
+			return fmt.Errorf("filter value for '%s' must be a string, number, boolean or null", column)
+		}
+	}
+
+	if r.BatchSize == 0 {
+		r.BatchSize = defaultBackfillBatchSize
+	}
+	if r.BatchSize < 1 || r.BatchSize > maxBackfillBatchSize {
+		return fmt.Errorf("batch_size must be between 1 and %d", maxBackfillBatchSize)
+	}
+	if r.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	if r.Model == "" {
+		r.Model = defaultBackfillModel
+	}
+	if r.Importance == nil {
+		importance := defaultBackfillImportance
+		r.Importance = &importance
+	}
+	if *r.Importance < 0 || *r.Importance > 1 {
+		return fmt.Errorf("importance must be between 0 and 1")
+	}
+	return nil
+}
+
+// ParseMemoryBackfillRequest reads a request from a job payload
+func ParseMemoryBackfillRequest(payload map[string]interface{}) (*MemoryBackfillRequest, error) {
+	var req MemoryBackfillRequest
+	if err := fromJSONMap(payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid memory backfill payload: %w", err)
+	}
+	return &req, nil
+}
+
+// ToPayload converts the request into a job payload
+func (r *MemoryBackfillRequest) ToPayload() (map[string]interface{}, error) {
+	return toJSONMap(r)
+}
+
+// MemoryBackfillProgress is stored as the job result after every batch
+type MemoryBackfillProgress struct {
+	TotalRows       int64   `json:"total_rows"`
+	RowsProcessed   int64   `json:"rows_processed"`
+	ChunksCreated   int64   `json:"chunks_created"`
+	RowsSkipped     int64   `json:"rows_skipped"` // rows with empty text
+	Batches         int64   `json:"batches"`
+	LastID          *string `json:"last_id"`
+	PercentComplete float64 `json:"percent_complete"`
+	Completed       bool    `json:"completed"`
+}
+
+// ParseMemoryBackfillProgress reads progress from a job result. An empty
+// result yields zero progress.
+func ParseMemoryBackfillProgress(result map[string]interface{}) (*MemoryBackfillProgress, error) {
+	progress := &MemoryBackfillProgress{}
+	if len(result) == 0 {
+		return progress, nil
+	}
+	if err := fromJSONMap(result, progress); err != nil {
+		return nil, fmt.Errorf("invalid memory backfill progress: %w", err)
+	}
+	return progress, nil
+}
+
+func (p *MemoryBackfillProgress) update() {
+	if p.TotalRows > 0 {
+		p.PercentComplete = float64(p.RowsProcessed) / float64(p.TotalRows) * 100
+		if p.PercentComplete > 100 {
+			p.PercentComplete = 100
+		}
+	}
+}
+
+// MemoryBackfiller embeds rows of existing tables into agent memory
+type MemoryBackfiller struct {
+	db      *db.DB
+	queries *db.Queries
+	embed   *neurondb.EmbeddingClient
+}
+
+func NewMemoryBackfiller(database *db.DB, queries *db.Queries, embedClient *neurondb.EmbeddingClient) *MemoryBackfiller {
+	return &MemoryBackfiller{
+		db:      database,
+		queries: queries,
+		embed:   embedClient,
+	}
+}
+
+// Check verifies that the source table and columns exist and can be read,
+// so bad requests are rejected before a job is queued
+func (b *MemoryBackfiller) Check(ctx context.Context, req *MemoryBackfillRequest) error {
+	query, args, err := buildBackfillQuery(req, nil, 0)
+	if err != nil {
+		return err
+	}
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("memory backfill source check failed: source_table='%s', text_column='%s', id_column='%s', error=%w",
+			req.SourceTable, req.TextColumn, req.IDColumn, err)
+	}
+	return rows.Close()
+}
+
+// Run processes a memory backfill job. Rows are read in id_column order and
+// each batch is stored together with the job progress, so a retried job
+// continues after the last stored batch.
+func (b *MemoryBackfiller) Run(ctx context.Context, job *db.Job) (map[string]interface{}, error) {
+	if job.AgentID == nil {
+		return nil, fmt.Errorf("memory backfill job %d has no agent_id", job.ID)
+	}
+	agentID := *job.AgentID
+
+	req, err := ParseMemoryBackfillRequest(job.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("job_id=%d: %w", job.ID, err)
+	}
+	if err := req.Normalize(); err != nil {
+		return nil, fmt.Errorf("invalid memory backfill payload: job_id=%d, error=%w", job.ID, err)
+	}
+
+	progress, err := ParseMemoryBackfillProgress(job.Result)
+	if err != nil {
+		return nil, err
+	}
+	if progress.Completed {
+		return toJSONMap(progress)
+	}
+
+	if progress.TotalRows, err = b.countRows(ctx, req); err != nil {
+		return b.fail(progress, err)
+	}
+	if req.Limit > 0 && int64(req.Limit) < progress.TotalRows {
+		progress.TotalRows = int64(req.Limit)
+	}
+	progress.update()
+	if err := b.saveProgress(ctx, job.ID, progress); err != nil {
+		return b.fail(progress, err)
+	}
+
+	for {
+		pageSize := req.BatchSize
+		if req.Limit > 0 {
+			remaining := int64(req.Limit) - progress.RowsProcessed
+			if remaining <= 0 {
+				break
+			}
+			if remaining < int64(pageSize) {
+				pageSize = int(remaining)
+			}
+		}
+
+		rows, err := b.fetchRows(ctx, req, progress.LastID, pageSize)
+		if err != nil {
+			return b.fail(progress, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		chunks, skipped, err := b.embedRows(ctx, agentID, job.ID, req, rows)
+		if err != nil {
+			return b.fail(progress, err)
+		}
+
+		next := *progress
+		lastID := rows[len(rows)-1].id
+		next.LastID = &lastID
+		next.RowsProcessed += int64(len(rows))
+		next.ChunksCreated += int64(len(chunks))
+		next.RowsSkipped += int64(skipped)
+		next.Batches++
+		next.update()
+
+		result, err := toJSONMap(&next)
+		if err != nil {
+			return b.fail(progress, err)
+		}
+		if err := b.queries.StoreBackfillBatch(ctx, job.ID, chunks, result); err != nil {
+			return b.fail(progress, err)
+		}
+		*progress = next
+		metrics.RecordMemoryBackfill(agentID.String(), len(chunks), skipped)
+
+		if len(rows) < pageSize {
+			break
+		}
+	}
+
+	progress.Completed = true
+	progress.PercentComplete = 100
+	return toJSONMap(progress)
+}
+
+// fail returns the progress so far with err; the worker stores it as the job
+// result, which keeps the resume position for the retry
+func (b *MemoryBackfiller) fail(progress *MemoryBackfillProgress, err error) (map[string]interface{}, error) {
+	result, convErr := toJSONMap(progress)
+	if convErr != nil {
+		return nil, err
+	}
+	return result, err
+}
+
+func (b *MemoryBackfiller) saveProgress(ctx context.Context, jobID int64, progress *MemoryBackfillProgress) error {
+	result, err := toJSONMap(progress)
+	if err != nil {
+		return err
+	}
+	return b.queries.UpdateJobResult(ctx, jobID, result)
+}
+
+func (b *MemoryBackfiller) countRows(ctx context.Context, req *MemoryBackfillRequest) (int64, error) {
+	table, err := quoteQualifiedIdentifier(req.SourceTable)
+	if err != nil {
+		return 0, err
+	}
+	where, args := buildBackfillFilter(req)
+	query := "SELECT COUNT(*) FROM " + table
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	var count int64
+	if err := b.db.GetContext(ctx, &count, query, args...); err != nil {
+		return 0, fmt.Errorf("memory backfill row count failed: query='%s', source_table='%s', error=%w", query, req.SourceTable, err)
+	}
+	return count, nil
+}
+
+type backfillRow struct {
+	id       string
+	text     sql.NullString
+	metadata string
+}
+
+func (b *MemoryBackfiller) fetchRows(ctx context.Context, req *MemoryBackfillRequest, lastID *string, limit int) ([]backfillRow, error) {
+	query, args, err := buildBackfillQuery(req, lastID, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("memory backfill read failed: query='%s', source_table='%s', limit=%d, error=%w", query, req.SourceTable, limit, err)
+	}
+	defer rows.Close()
+
+	var result []backfillRow
+	for rows.Next() {
+		var row backfillRow
+		if err := rows.Scan(&row.id, &row.text, &row.metadata); err != nil {
+			return nil, fmt.Errorf("memory backfill row scan failed: source_table='%s', error=%w", req.SourceTable, err)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("memory backfill read failed: source_table='%s', error=%w", req.SourceTable, err)
+	}
+	return result, nil
+}
+
+// embedRows embeds the non-empty rows of a batch and returns the chunks to
+// store and the number of rows skipped
+func (b *MemoryBackfiller) embedRows(ctx context.Context, agentID uuid.UUID, jobID int64, req *MemoryBackfillRequest, rows []backfillRow) ([]db.MemoryChunk, int, error) {
+	var texts []string
+	var kept []backfillRow
+	for _, row := range rows {
+		if !row.text.Valid || strings.TrimSpace(row.text.String) == "" {
+			continue
+		}
+		texts = append(texts, row.text.String)
+		kept = append(kept, row)
+	}
+	skipped := len(rows) - len(kept)
+	if len(texts) == 0 {
+		return nil, skipped, nil
+	}
+
+	embeddings, err := b.embed.EmbedBatch(ctx, texts, req.Model)
+	if err != nil {
+		return nil, 0, fmt.Errorf("memory backfill embedding failed: model_name='%s', text_count=%d, error=%w", req.Model, len(texts), err)
+	}
+	if len(embeddings) != len(texts) {
+		return nil, 0, fmt.Errorf("memory backfill embedding failed: model_name='%s', text_count=%d, embedding_count=%d",
+			req.Model, len(texts), len(embeddings))
+	}
+
+	chunks := make([]db.MemoryChunk, len(kept))
+	for i, row := range kept {
+		metadata := db.JSONBMap{
+			"source":          "backfill",
+			"source_table":    req.SourceTable,
+			"source_id":       row.id,
+			"backfill_job_id": jobID,
+		}
+		if len(req.MetadataColumns) > 0 {
+			var columns map[string]interface{}
+			if err := json.Unmarshal([]byte(row.metadata), &columns); err == nil {
+				metadata["columns"] = columns
+			}
+		}
+		chunks[i] = db.MemoryChunk{
+			AgentID:         agentID,
+			Content:         row.text.String,
+			Embedding:       embeddings[i],
+			ImportanceScore: *req.Importance,
+			Metadata:        metadata,
+		}
+	}
+	return chunks, skipped, nil
+}
+
+// buildBackfillQuery builds the keyset query reading the next page after
+// lastID. A limit of 0 builds a query returning no rows, used to check the
+// source.
+func buildBackfillQuery(req *MemoryBackfillRequest, lastID *string, limit int) (string, []interface{}, error) {
+	table, err := quoteQualifiedIdentifier(req.SourceTable)
+	if err != nil {
+		return "", nil, err
+	}
+	idCol := pq.QuoteIdentifier(req.IDColumn)
+
+	metadataExpr := "'{}'"
+	if len(req.MetadataColumns) > 0 {
+		pairs := make([]string, 0, len(req.MetadataColumns))
+		for _, column := range req.MetadataColumns {
+			pairs = append(pairs, pq.QuoteLiteral(column)+", "+pq.QuoteIdentifier(column))
+		}
+		metadataExpr = "jsonb_build_object(" + strings.Join(pairs, ", ") + ")::text"
+	}
+
+	where, args := buildBackfillFilter(req)
+	if lastID != nil {
+		args = append(args, *lastID)
+		where = append(where, fmt.Sprintf("%s > $%d", idCol, len(args)))
+	}
+
+	query := fmt.Sprintf("SELECT %s::text, %s::text, %s FROM %s",
+		idCol, pq.QuoteIdentifier(req.TextColumn), metadataExpr, table)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", idCol, limit)
+	return query, args, nil
+}
+
+// buildBackfillFilter turns the equality filter into WHERE conditions, in
+// column order so queries are stable
+func buildBackfillFilter(req *MemoryBackfillRequest) ([]string, []interface{}) {
+	columns := make([]string, 0, len(req.Filter))
+	for column := range req.Filter {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var where []string
+	var args []interface{}
+	for _, column := range columns {
+		value := req.Filter[column]
+		if value == nil {
+			where = append(where, pq.QuoteIdentifier(column)+" IS NULL")
+			continue
+		}
+		args = append(args, value)
+		where = append(where, fmt.Sprintf("%s = $%d", pq.QuoteIdentifier(column), len(args)))
+	}
+	return where, args
+}
+
+// quoteQualifiedIdentifier quotes "table" or "schema.table"
+func quoteQualifiedIdentifier(name string) (string, error) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return "", fmt.Errorf("invalid table name '%s'", name)
+	}
+	for i, part := range parts {
+		if !identifierPattern.MatchString(part) {
+			return "", fmt.Errorf("invalid table name '%s'", name)
+		}
+		parts[i] = pq.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func fromJSONMap(m map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,360 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+const (
+	redisMemoryKeyPrefix = "neurondb_agent:memory:"
+	redisMemorySeqKey    = "neurondb_agent:memory_seq"
+	redisPoolSize        = 8
+	redisDialTimeout     = 5 * time.Second
+	redisCommandTimeout  = 5 * time.Second
+)
+
+// RedisMemoryStore keeps short-term memory in Redis: one list per agent,
+// newest chunk first, trimmed to max_chunks and expiring ttl_minutes after
+// the last write. Search reads the list and ranks it in the process, which
+// is fast for the few hundred chunks short-term memory holds.
+type RedisMemoryStore struct {
+	client *redisClient
+}
+
+// redisMemoryChunk is a memory chunk as stored in Redis
+type redisMemoryChunk struct {
+	ID              int64                  `json:"id"`
+	SessionID       *uuid.UUID             `json:"session_id,omitempty"`
+	Content         string                 `json:"content"`
+	Embedding       []float32              `json:"embedding"`
+	ImportanceScore float64                `json:"importance_score"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+// NewRedisMemoryStore creates a Redis memory store for a URL of the form
+// redis://[:password@]host:port[/db], or rediss:// for TLS
+func NewRedisMemoryStore(rawURL string) (*RedisMemoryStore, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisMemoryStore{client: client}, nil
+}
+
+// Ping checks that Redis is reachable
+func (s *RedisMemoryStore) Ping(ctx context.Context) error {
+	_, err := s.client.do(ctx, "PING")
+	return err
+}
+
+// Close closes the pooled connections
+func (s *RedisMemoryStore) Close() {
+	s.client.close()
+}
+
+func redisMemoryKey(agentID uuid.UUID) string {
+	return redisMemoryKeyPrefix + agentID.String()
+}
+
+func (s *RedisMemoryStore) Store(ctx context.Context, chunk *db.MemoryChunk, policy *MemoryBackendPolicy) error {
+	id, err := s.client.do(ctx, "INCR", redisMemorySeqKey)
+	if err != nil {
+		return fmt.Errorf("redis memory store failed: agent_id='%s', error=%w", chunk.AgentID.String(), err)
+	}
+	chunk.ID, _ = id.(int64)
+	if chunk.CreatedAt.IsZero() {
+		chunk.CreatedAt = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(redisMemoryChunk{
+		ID:              chunk.ID,
+		SessionID:       chunk.SessionID,
+		Content:         chunk.Content,
+		Embedding:       chunk.Embedding,
+		ImportanceScore: chunk.ImportanceScore,
+		Metadata:        chunk.Metadata,
+		CreatedAt:       chunk.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("redis memory store failed to encode chunk: agent_id='%s', error=%w", chunk.AgentID.String(), err)
+	}
+
+	key := redisMemoryKey(chunk.AgentID)
+	commands := [][]string{{"LPUSH", key, string(payload)}}
+	if policy.MaxChunks > 0 {
+		commands = append(commands, []string{"LTRIM", key, "0", strconv.Itoa(policy.MaxChunks - 1)})
+	}
+	if policy.TTL > 0 {
+		commands = append(commands, []string{"PEXPIRE", key, strconv.FormatInt(policy.TTL.Milliseconds(), 10)})
+	}
+	if _, err := s.client.pipeline(ctx, commands); err != nil {
+		return fmt.Errorf("redis memory store failed: agent_id='%s', key='%s', error=%w", chunk.AgentID.String(), key, err)
+	}
+	return nil
+}
+
+func (s *RedisMemoryStore) Search(ctx context.Context, agentID uuid.UUID, queryEmbedding []float32, topK int, policy *MemoryBackendPolicy) ([]MemoryChunk, error) {
+	key := redisMemoryKey(agentID)
+	reply, err := s.client.do(ctx, "LRANGE", key, "0", "-1")
+	if err != nil {
+		return nil, fmt.Errorf("redis memory search failed: agent_id='%s', key='%s', error=%w", agentID.String(), key, err)
+	}
+	items, _ := reply.([]interface{})
+	chunks := make([]db.MemoryChunk, 0, len(items))
+	for _, item := range items {
+		raw, _ := item.(string)
+		var stored redisMemoryChunk
+		if err := json.Unmarshal([]byte(raw), &stored); err != nil {
+			// Skip entries this version cannot read instead of failing the run
+			continue
+		}
+		chunks = append(chunks, db.MemoryChunk{
+			ID:              stored.ID,
+			AgentID:         agentID,
+			SessionID:       stored.SessionID,
+			Content:         stored.Content,
+			Embedding:       stored.Embedding,
+			ImportanceScore: stored.ImportanceScore,
+			Metadata:        stored.Metadata,
+			CreatedAt:       stored.CreatedAt,
+		})
+	}
+	return rankMemoryChunks(chunks, queryEmbedding, topK, policy, time.Now()), nil
+}
+
+// redisClient is a minimal client for the Redis protocol (RESP2) with a
+// small connection pool. It only supports the commands the memory store
+// sends.
+type redisClient struct {
+	addr     string
+	password string
+	database int
+	tls      *tls.Config
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	client := &redisClient{pool: make(chan *redisConn, redisPoolSize)}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		client.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("invalid redis URL: scheme must be redis or rediss, got '%s'", u.Scheme)
+	}
+	client.addr = u.Host
+	if u.Port() == "" {
+		client.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		client.password, _ = u.User.Password()
+	}
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		n, err := strconv.Atoi(path)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid redis URL: database must be a number, got '%s'", path)
+		}
+		client.database = n
+	}
+	return client, nil
+}
+
+// do sends one command and returns its reply
+func (c *redisClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	replies, err := c.pipeline(ctx, [][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// pipeline sends commands in one round trip and returns their replies. The
+// first error reply is returned as the error.
+func (c *redisClient) pipeline(ctx context.Context, commands [][]string) ([]interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(redisCommandTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.conn.SetDeadline(deadline)
+
+	replies, err := conn.roundTrip(commands)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection state is unknown after an I/O error
+		conn.conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return replies, err
+}
+
+func (c *redisClient) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var netConn net.Conn
+	var err error
+	if c.tls != nil {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis connection failed: addr='%s', error=%w", c.addr, err)
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+
+	var setup [][]string
+	if c.password != "" {
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.database != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.database)})
+	}
+	if len(setup) > 0 {
+		_ = netConn.SetDeadline(time.Now().Add(redisCommandTimeout))
+		if _, err := conn.roundTrip(setup); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("redis connection setup failed: addr='%s', error=%w", c.addr, err)
+		}
+	}
+	return conn, nil
+}
+
+func (c *redisClient) put(conn *redisConn) {
+	select {
+	case c.pool <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (c *redisClient) close() {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// roundTrip writes the commands and reads one reply for each
+func (c *redisConn) roundTrip(commands [][]string) ([]interface{}, error) {
+	var buf strings.Builder
+	for _, args := range commands {
+		fmt.Fprintf(&buf, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(c.conn, buf.String()); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	var firstErr error
+	for i := range commands {
+		reply, err := readRedisReply(c.reader)
+		var replyErr redisError
+		if err != nil && !errors.As(err, &replyErr) {
+			return nil, err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// readRedisReply reads one RESP2 reply: a status or bulk string as string,
+// an integer as int64, an array as []interface{} and a nil bulk string or
+// array as nil. An error reply is returned as a redisError.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readRedisReply(r)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
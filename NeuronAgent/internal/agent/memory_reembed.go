@@ -0,0 +1,295 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+// MemoryReembedJobType is the job type processed by MemoryReembedder.Run
+const MemoryReembedJobType = "memory_reembed"
+
+const (
+	defaultReembedBatchSize = 64
+	maxReembedBatchSize     = 1000
+	// maxReembedSwitchAttempts bounds how often a job embeds the chunks
+	// stored while it ran and tries the switch again
+	maxReembedSwitchAttempts = 5
+)
+
+// MemoryReembedRequest moves an agent's memory to a new embedding model. It
+// is stored as the job payload.
+type MemoryReembedRequest struct {
+	Model     string `json:"model"`
+	BatchSize int    `json:"batch_size,omitempty"`
+}
+
+// Normalize validates the request and fills in defaults
+func (r *MemoryReembedRequest) Normalize() error {
+	r.Model = strings.TrimSpace(r.Model)
+	if r.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if r.BatchSize == 0 {
+		r.BatchSize = defaultReembedBatchSize
+	}
+	if r.BatchSize < 1 || r.BatchSize > maxReembedBatchSize {
+		return fmt.Errorf("batch_size must be between 1 and %d", maxReembedBatchSize)
+	}
+	return nil
+}
+
+// ParseMemoryReembedRequest reads a request from a job payload
+func ParseMemoryReembedRequest(payload map[string]interface{}) (*MemoryReembedRequest, error) {
+	var req MemoryReembedRequest
+	if err := fromJSONMap(payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid memory re-embed payload: %w", err)
+	}
+	return &req, nil
+}
+
+// ToPayload converts the request into a job payload
+func (r *MemoryReembedRequest) ToPayload() (map[string]interface{}, error) {
+	return toJSONMap(r)
+}
+
+// MemoryReembedProgress is stored as the job result after every batch
+type MemoryReembedProgress struct {
+	FromModel       string  `json:"from_model"`
+	TotalChunks     int64   `json:"total_chunks"`
+	ChunksEmbedded  int64   `json:"chunks_embedded"`
+	ChunksSwitched  int64   `json:"chunks_switched"`
+	Batches         int64   `json:"batches"`
+	LastChunkID     int64   `json:"last_chunk_id"`
+	SwitchAttempts  int     `json:"switch_attempts"`
+	PercentComplete float64 `json:"percent_complete"`
+	Switched        bool    `json:"switched"`
+	Completed       bool    `json:"completed"`
+}
+
+// ParseMemoryReembedProgress reads progress from a job result. An empty
+// result yields zero progress.
+func ParseMemoryReembedProgress(result map[string]interface{}) (*MemoryReembedProgress, error) {
+	progress := &MemoryReembedProgress{}
+	if len(result) == 0 {
+		return progress, nil
+	}
+	if err := fromJSONMap(result, progress); err != nil {
+		return nil, fmt.Errorf("invalid memory re-embed progress: %w", err)
+	}
+	return progress, nil
+}
+
+func (p *MemoryReembedProgress) update() {
+	if p.TotalChunks > 0 {
+		p.PercentComplete = float64(p.ChunksEmbedded) / float64(p.TotalChunks) * 100
+		if p.PercentComplete > 100 {
+			p.PercentComplete = 100
+		}
+	}
+}
+
+// MemoryReembedder moves agents' Postgres memory to a new embedding model.
+// The new embeddings are staged next to the old ones, which runs keep
+// searching, and replace them in one transaction that also sets the agent's
+// memory.embedding_model.
+type MemoryReembedder struct {
+	queries *db.Queries
+	embed   *neurondb.EmbeddingClient
+}
+
+func NewMemoryReembedder(queries *db.Queries, embedClient *neurondb.EmbeddingClient) *MemoryReembedder {
+	return &MemoryReembedder{
+		queries: queries,
+		embed:   embedClient,
+	}
+}
+
+// Check verifies that the agent keeps its memory in Postgres, does not use
+// the model yet and that the model embeds text, so bad requests are rejected
+// before a job is queued
+func (e *MemoryReembedder) Check(ctx context.Context, agent *db.Agent, req *MemoryReembedRequest) error {
+	policy, err := ParseMemoryBackendPolicy(agent.Config)
+	if err != nil {
+		return err
+	}
+	if policy.Backend != MemoryBackendPostgres {
+		return fmt.Errorf("memory re-embedding needs the %s memory backend, the agent uses %s", MemoryBackendPostgres, policy.Backend)
+	}
+	if source := e.embed.Source(); source != "neurondb" {
+		return fmt.Errorf("memory re-embedding needs NeuronDB's embedding functions, texts are embedded by '%s'", source)
+	}
+	if policy.EmbeddingModel == req.Model {
+		return fmt.Errorf("the agent's memory is already embedded with '%s'", req.Model)
+	}
+	if _, err := e.embed.Embed(ctx, "embedding model check", req.Model); err != nil {
+		return fmt.Errorf("embedding model '%s' failed: %w", req.Model, err)
+	}
+	return nil
+}
+
+// Run processes a memory re-embed job. Chunks are embedded in id order and
+// each batch is staged together with the job progress, so a retried job
+// continues after the last staged batch. Chunks stored while the job runs
+// are embedded before the switch; if new ones keep arriving the switch is
+// tried at most maxReembedSwitchAttempts times.
+func (e *MemoryReembedder) Run(ctx context.Context, job *db.Job) (map[string]interface{}, error) {
+	if job.AgentID == nil {
+		return nil, fmt.Errorf("memory re-embed job %d has no agent_id", job.ID)
+	}
+	agentID := *job.AgentID
+
+	req, err := ParseMemoryReembedRequest(job.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("job_id=%d: %w", job.ID, err)
+	}
+	if err := req.Normalize(); err != nil {
+		return nil, fmt.Errorf("invalid memory re-embed payload: job_id=%d, error=%w", job.ID, err)
+	}
+
+	progress, err := ParseMemoryReembedProgress(job.Result)
+	if err != nil {
+		return nil, err
+	}
+	if progress.Completed {
+		return toJSONMap(progress)
+	}
+
+	agent, err := e.queries.GetAgentByID(ctx, agentID)
+	if err != nil {
+		return e.fail(progress, err)
+	}
+	if progress.FromModel == "" {
+		progress.FromModel = MemoryEmbeddingModel(agent)
+	}
+	if progress.TotalChunks, err = e.queries.CountMemoryChunks(ctx, agentID); err != nil {
+		return e.fail(progress, err)
+	}
+	progress.update()
+	if err := e.saveProgress(ctx, job.ID, progress); err != nil {
+		return e.fail(progress, err)
+	}
+
+	afterID := progress.LastChunkID
+	for attempt := 1; ; attempt++ {
+		if err := e.stageChunks(ctx, agentID, job.ID, req, progress, afterID); err != nil {
+			return e.fail(progress, err)
+		}
+
+		final := *progress
+		final.SwitchAttempts++
+		final.Switched = true
+		final.Completed = true
+		final.PercentComplete = 100
+		result, err := toJSONMap(&final)
+		if err != nil {
+			return e.fail(progress, err)
+		}
+		switched, unstaged, err := e.queries.SwitchMemoryEmbeddings(ctx, agentID, job.ID, req.Model, result)
+		if err != nil {
+			return e.fail(progress, err)
+		}
+		if unstaged == 0 {
+			final.ChunksSwitched = switched
+			metrics.RecordMemoryReembed(agentID.String(), "switched", switched)
+			metrics.Logger().Info().
+				Str("agent_id", agentID.String()).
+				Str("from_model", final.FromModel).
+				Str("to_model", req.Model).
+				Int64("chunks", switched).
+				Msg("Switched agent memory to a new embedding model")
+			return toJSONMap(&final)
+		}
+
+		// Chunks were stored while the job ran; embed them and try again
+		progress.SwitchAttempts++
+		progress.TotalChunks += unstaged
+		progress.update()
+		if attempt >= maxReembedSwitchAttempts {
+			return e.fail(progress, fmt.Errorf("memory re-embed could not switch agent '%s' to '%s': chunks were stored without a new embedding during each of %d attempts, %d at the last one",
+				agentID.String(), req.Model, attempt, unstaged))
+		}
+		if err := e.saveProgress(ctx, job.ID, progress); err != nil {
+			return e.fail(progress, err)
+		}
+		afterID = 0
+	}
+}
+
+// stageChunks embeds the agent's chunks above afterID that have no staged
+// embedding yet, one batch at a time
+func (e *MemoryReembedder) stageChunks(ctx context.Context, agentID uuid.UUID, jobID int64, req *MemoryReembedRequest, progress *MemoryReembedProgress, afterID int64) error {
+	for {
+		chunks, err := e.queries.ListUnstagedMemoryChunks(ctx, agentID, jobID, afterID, req.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(chunks))
+		texts := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			ids[i] = chunk.ID
+			texts[i] = chunk.Content
+		}
+		embeddings, err := e.embed.EmbedBatch(ctx, texts, req.Model)
+		if err != nil {
+			return fmt.Errorf("memory re-embed embedding failed: model_name='%s', text_count=%d, first_chunk_id=%d, error=%w", req.Model, len(texts), ids[0], err)
+		}
+		if len(embeddings) != len(texts) {
+			return fmt.Errorf("memory re-embed embedding failed: model_name='%s', text_count=%d, embedding_count=%d", req.Model, len(texts), len(embeddings))
+		}
+
+		next := *progress
+		afterID = ids[len(ids)-1]
+		if afterID > next.LastChunkID {
+			next.LastChunkID = afterID
+		}
+		next.ChunksEmbedded += int64(len(chunks))
+		next.Batches++
+		next.update()
+
+		result, err := toJSONMap(&next)
+		if err != nil {
+			return err
+		}
+		vectors := make([][]float32, len(embeddings))
+		for i, embedding := range embeddings {
+			vectors[i] = embedding
+		}
+		if err := e.queries.StoreMemoryReembedBatch(ctx, jobID, ids, vectors, result); err != nil {
+			return err
+		}
+		*progress = next
+		metrics.RecordMemoryReembed(agentID.String(), "embedded", int64(len(chunks)))
+
+		if len(chunks) < req.BatchSize {
+			return nil
+		}
+	}
+}
+
+// fail returns the progress so far with err; the worker stores it as the job
+// result, which keeps the resume position for the retry
+func (e *MemoryReembedder) fail(progress *MemoryReembedProgress, err error) (map[string]interface{}, error) {
+	result, convErr := toJSONMap(progress)
+	if convErr != nil {
+		return nil, err
+	}
+	return result, err
+}
+
+func (e *MemoryReembedder) saveProgress(ctx context.Context, jobID int64, progress *MemoryReembedProgress) error {
+	result, err := toJSONMap(progress)
+	if err != nil {
+		return err
+	}
+	return e.queries.UpdateJobResult(ctx, jobID, result)
+}
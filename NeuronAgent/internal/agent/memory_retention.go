@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// MemoryRetentionPolicy bounds how much memory an agent keeps. It is read
+// from the "memory_retention" object of the agent config:
+//
+//	"memory_retention": {
+//	  "max_chunks": 10000,     // keep at most this many chunks (most important first)
+//	  "max_age_hours": 720,    // chunks older than this are expired
+//	  "min_importance": 0.8,   // expired chunks at or above this score are kept
+//	  "archive": true          // move evicted chunks to memory_chunks_archive
+//	}
+//
+// Zero values disable the corresponding limit.
+type MemoryRetentionPolicy struct {
+	MaxChunks     int           `json:"max_chunks,omitempty"`
+	MaxAge        time.Duration `json:"-"`
+	MaxAgeHours   float64       `json:"max_age_hours,omitempty"`
+	MinImportance *float64      `json:"min_importance,omitempty"`
+	Archive       bool          `json:"archive"`
+}
+
+// Enabled reports whether the policy limits anything
+func (p *MemoryRetentionPolicy) Enabled() bool {
+	return p.MaxChunks > 0 || p.MaxAge > 0
+}
+
+// ParseRetentionPolicy extracts the retention policy from an agent config.
+// A missing "memory_retention" key yields a disabled policy.
+func ParseRetentionPolicy(config map[string]interface{}) (*MemoryRetentionPolicy, error) {
+	policy := &MemoryRetentionPolicy{}
+	raw, ok := config["memory_retention"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("memory_retention must be an object, got %T", raw)
+	}
+
+	if v, ok := settings["max_chunks"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != float64(int(n)) {
+			return nil, fmt.Errorf("memory_retention.max_chunks must be a non-negative integer")
+		}
+		policy.MaxChunks = int(n)
+	}
+	if v, ok := settings["max_age_hours"]; ok {
+		hours, ok := v.(float64)
+		if !ok || hours < 0 {
+			return nil, fmt.Errorf("memory_retention.max_age_hours must be a non-negative number")
+		}
+		policy.MaxAgeHours = hours
+		policy.MaxAge = time.Duration(hours * float64(time.Hour))
+	}
+	if v, ok := settings["min_importance"]; ok && v != nil {
+		score, ok := v.(float64)
+		if !ok || score < 0 || score > 1 {
+			return nil, fmt.Errorf("memory_retention.min_importance must be a number between 0 and 1")
+		}
+		policy.MinImportance = &score
+	}
+	if v, ok := settings["archive"]; ok {
+		archive, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("memory_retention.archive must be a boolean")
+		}
+		policy.Archive = archive
+	}
+
+	return policy, nil
+}
+
+// EvictionResult reports what a single eviction pass did for an agent
+type EvictionResult struct {
+	AgentID         uuid.UUID              `json:"agent_id"`
+	Policy          *MemoryRetentionPolicy `json:"policy"`
+	ExpiredEvicted  int64                  `json:"expired_evicted"`
+	CapacityEvicted int64                  `json:"capacity_evicted"`
+	Archived        bool                   `json:"archived"`
+	RemainingChunks int64                  `json:"remaining_chunks"`
+}
+
+// MemoryEvictor enforces memory retention policies
+type MemoryEvictor struct {
+	queries *db.Queries
+}
+
+func NewMemoryEvictor(queries *db.Queries) *MemoryEvictor {
+	return &MemoryEvictor{queries: queries}
+}
+
+// EvictAgent applies the agent's retention policy: expired chunks are evicted
+// first, then the least important chunks beyond max_chunks. Usage metrics are
+// refreshed afterwards even when the policy is disabled.
+func (e *MemoryEvictor) EvictAgent(ctx context.Context, agent *db.Agent) (*EvictionResult, error) {
+	policy, err := ParseRetentionPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("memory eviction failed: agent_id='%s', agent_name='%s', error=%w",
+			agent.ID.String(), agent.Name, err)
+	}
+
+	result := &EvictionResult{
+		AgentID:  agent.ID,
+		Policy:   policy,
+		Archived: policy.Archive,
+	}
+	action := "deleted"
+	if policy.Archive {
+		action = "archived"
+	}
+
+	if policy.MaxAge > 0 {
+		cutoff := time.Now().Add(-policy.MaxAge)
+		n, err := e.queries.EvictExpiredMemoryChunks(ctx, agent.ID, cutoff, policy.MinImportance, policy.Archive)
+		if err != nil {
+			return nil, fmt.Errorf("memory eviction failed: agent_id='%s', stage='max_age', max_age='%s', error=%w",
+				agent.ID.String(), policy.MaxAge, err)
+		}
+		result.ExpiredEvicted = n
+		if n > 0 {
+			metrics.RecordMemoryEviction(agent.ID.String(), "max_age", action, n)
+		}
+	}
+
+	if policy.MaxChunks > 0 {
+		n, err := e.queries.EvictExcessMemoryChunks(ctx, agent.ID, policy.MaxChunks, policy.Archive)
+		if err != nil {
+			return nil, fmt.Errorf("memory eviction failed: agent_id='%s', stage='max_chunks', max_chunks=%d, error=%w",
+				agent.ID.String(), policy.MaxChunks, err)
+		}
+		result.CapacityEvicted = n
+		if n > 0 {
+			metrics.RecordMemoryEviction(agent.ID.String(), "max_chunks", action, n)
+		}
+	}
+
+	usage, err := e.queries.GetMemoryUsage(ctx, agent.ID)
+	if err != nil {
+		return nil, fmt.Errorf("memory eviction failed: agent_id='%s', stage='usage', error=%w", agent.ID.String(), err)
+	}
+	metrics.RecordMemoryUsage(agent.ID.String(), usage.ChunkCount, usage.ContentBytes)
+	result.RemainingChunks = usage.ChunkCount
+
+	return result, nil
+}
+
+// MemoryEvictionService periodically enforces retention policies for all agents
+type MemoryEvictionService struct {
+	evictor  *MemoryEvictor
+	queries  *db.Queries
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewMemoryEvictionService(queries *db.Queries, interval time.Duration) *MemoryEvictionService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MemoryEvictionService{
+		evictor:  NewMemoryEvictor(queries),
+		queries:  queries,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts the eviction service
+func (s *MemoryEvictionService) Start() {
+	go s.run()
+}
+
+// Stop stops the eviction service and waits for a running pass to finish
+func (s *MemoryEvictionService) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *MemoryEvictionService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.evictAll()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.evictAll()
+		}
+	}
+}
+
+func (s *MemoryEvictionService) evictAll() {
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Minute)
+	defer cancel()
+
+	agents, err := s.queries.ListAgents(ctx)
+	if err != nil {
+		return
+	}
+
+	for i := range agents {
+		if ctx.Err() != nil {
+			return
+		}
+		// Errors are per agent; a bad policy on one agent must not stop the rest
+		if _, err := s.evictor.EvictAgent(ctx, &agents[i]); err != nil {
+			metrics.Logger().Error().Err(err).
+				Str("agent_id", agents[i].ID.String()).
+				Msg("Memory eviction failed")
+		}
+	}
+}
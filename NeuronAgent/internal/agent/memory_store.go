@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+// Memory backends an agent can keep its memory in
+const (
+	MemoryBackendPostgres = "postgres"
+	MemoryBackendRedis    = "redis"
+	MemoryBackendInMemory = "memory"
+)
+
+const (
+	defaultRedisMemoryTTL       = time.Hour
+	defaultRedisMemoryMaxChunks = 200
+	defaultInMemoryMaxChunks    = 1000
+)
+
+// MemoryStore keeps an agent's memory chunks and finds the chunks nearest to
+// an embedding
+type MemoryStore interface {
+	Store(ctx context.Context, chunk *db.MemoryChunk, policy *MemoryBackendPolicy) error
+	Search(ctx context.Context, agentID uuid.UUID, queryEmbedding []float32, topK int, policy *MemoryBackendPolicy) ([]MemoryChunk, error)
+}
+
+// BatchMemoryStore is a MemoryStore that stores several chunks in one
+// write, all of them or none
+type BatchMemoryStore interface {
+	StoreBatch(ctx context.Context, chunks []db.MemoryChunk, policy *MemoryBackendPolicy) error
+}
+
+// MemoryBackendPolicy selects where an agent keeps its memory. It is read
+// from the "memory" object of the agent config:
+//
+//	"memory": {
+//	  "backend": "redis",                    // postgres (default), redis or memory
+//	  "ttl_minutes": 60,                     // chunks older than this are forgotten
+//	  "max_chunks": 200,                     // keep at most this many chunks, newest first
+//	  "embedding_model": "all-MiniLM-L6-v2"  // model memory is embedded and searched with
+//	}
+//
+// ttl_minutes and max_chunks apply to the redis and memory backends. Postgres
+// memory is bounded by memory_retention instead. Changing embedding_model
+// leaves the stored chunks embedded with the old model; a memory re-embed
+// job switches Postgres memory over instead.
+type MemoryBackendPolicy struct {
+	Backend        string
+	TTL            time.Duration
+	MaxChunks      int
+	EmbeddingModel string
+}
+
+// ParseMemoryBackendPolicy extracts the memory backend from an agent config.
+// A missing "memory" key selects Postgres.
+func ParseMemoryBackendPolicy(config map[string]interface{}) (*MemoryBackendPolicy, error) {
+	policy := &MemoryBackendPolicy{Backend: MemoryBackendPostgres, EmbeddingModel: memoryEmbeddingModel}
+	raw, ok := config["memory"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("memory must be an object, got %T", raw)
+	}
+
+	if v, ok := settings["backend"]; ok {
+		backend, _ := v.(string)
+		switch backend {
+		case MemoryBackendPostgres, MemoryBackendRedis, MemoryBackendInMemory:
+			policy.Backend = backend
+		default:
+			return nil, fmt.Errorf("memory.backend must be one of postgres, redis or memory, got %v", v)
+		}
+	}
+	if v, ok := settings["ttl_minutes"]; ok {
+		minutes, ok := v.(float64)
+		if !ok || minutes < 0 {
+			return nil, fmt.Errorf("memory.ttl_minutes must be a non-negative number")
+		}
+		policy.TTL = time.Duration(minutes * float64(time.Minute))
+	}
+	if v, ok := settings["max_chunks"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != float64(int(n)) {
+			return nil, fmt.Errorf("memory.max_chunks must be a non-negative integer")
+		}
+		policy.MaxChunks = int(n)
+	}
+	if v, ok := settings["embedding_model"]; ok {
+		model, ok := v.(string)
+		if !ok || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("memory.embedding_model must be a non-empty string")
+		}
+		policy.EmbeddingModel = model
+	}
+
+	switch policy.Backend {
+	case MemoryBackendRedis:
+		if policy.TTL == 0 {
+			policy.TTL = defaultRedisMemoryTTL
+		}
+		if policy.MaxChunks == 0 {
+			policy.MaxChunks = defaultRedisMemoryMaxChunks
+		}
+	case MemoryBackendInMemory:
+		if policy.MaxChunks == 0 {
+			policy.MaxChunks = defaultInMemoryMaxChunks
+		}
+	}
+	return policy, nil
+}
+
+// expired reports whether a chunk created at createdAt has outlived the TTL
+func (p *MemoryBackendPolicy) expired(createdAt, now time.Time) bool {
+	return p.TTL > 0 && now.Sub(createdAt) > p.TTL
+}
+
+// PostgresMemoryStore keeps memory in the memory_chunks table and searches it
+// with the NeuronDB vector index
+type PostgresMemoryStore struct {
+	queries *db.Queries
+}
+
+// NewPostgresMemoryStore creates the Postgres memory store
+func NewPostgresMemoryStore(queries *db.Queries) *PostgresMemoryStore {
+	return &PostgresMemoryStore{queries: queries}
+}
+
+func (s *PostgresMemoryStore) Store(ctx context.Context, chunk *db.MemoryChunk, policy *MemoryBackendPolicy) error {
+	_, err := s.queries.CreateMemoryChunk(ctx, chunk)
+	return err
+}
+
+// StoreBatch stores chunks in one transaction
+func (s *PostgresMemoryStore) StoreBatch(ctx context.Context, chunks []db.MemoryChunk, policy *MemoryBackendPolicy) error {
+	return s.queries.CreateMemoryChunks(ctx, chunks)
+}
+
+func (s *PostgresMemoryStore) Search(ctx context.Context, agentID uuid.UUID, queryEmbedding []float32, topK int, policy *MemoryBackendPolicy) ([]MemoryChunk, error) {
+	chunks, err := s.queries.SearchMemory(ctx, agentID, queryEmbedding, topK)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MemoryChunk, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = MemoryChunk{
+			ID:              chunk.ID,
+			Content:         chunk.Content,
+			ImportanceScore: chunk.ImportanceScore,
+			Similarity:      chunk.Similarity,
+			Metadata:        chunk.Metadata,
+		}
+	}
+	return result, nil
+}
+
+// InMemoryStore keeps memory in the process. It is lost on restart, so it
+// suits tests and scratch memory on a single server.
+type InMemoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	chunks map[uuid.UUID][]db.MemoryChunk
+}
+
+// NewInMemoryStore creates an empty in-memory store
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{chunks: make(map[uuid.UUID][]db.MemoryChunk)}
+}
+
+func (s *InMemoryStore) Store(ctx context.Context, chunk *db.MemoryChunk, policy *MemoryBackendPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	stored := *chunk
+	stored.ID = s.nextID
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = time.Now()
+	}
+	chunk.ID, chunk.CreatedAt = stored.ID, stored.CreatedAt
+
+	// Newest first, so trimming drops the oldest chunks
+	chunks := append([]db.MemoryChunk{stored}, s.chunks[chunk.AgentID]...)
+	now := time.Now()
+	kept := chunks[:0]
+	for _, c := range chunks {
+		if !policy.expired(c.CreatedAt, now) {
+			kept = append(kept, c)
+		}
+	}
+	if policy.MaxChunks > 0 && len(kept) > policy.MaxChunks {
+		kept = kept[:policy.MaxChunks]
+	}
+	s.chunks[chunk.AgentID] = kept
+	return nil
+}
+
+func (s *InMemoryStore) Search(ctx context.Context, agentID uuid.UUID, queryEmbedding []float32, topK int, policy *MemoryBackendPolicy) ([]MemoryChunk, error) {
+	s.mu.Lock()
+	chunks := append([]db.MemoryChunk(nil), s.chunks[agentID]...)
+	s.mu.Unlock()
+	return rankMemoryChunks(chunks, queryEmbedding, topK, policy, time.Now()), nil
+}
+
+// rankMemoryChunks returns the topK unexpired chunks most similar to the
+// query embedding, for stores that search in the process. Chunks with a
+// different dimension than the query are skipped.
+func rankMemoryChunks(chunks []db.MemoryChunk, queryEmbedding []float32, topK int, policy *MemoryBackendPolicy, now time.Time) []MemoryChunk {
+	ranked := make([]MemoryChunk, 0, len(chunks))
+	for _, c := range chunks {
+		if policy.expired(c.CreatedAt, now) || len(c.Embedding) != len(queryEmbedding) {
+			continue
+		}
+		ranked = append(ranked, MemoryChunk{
+			ID:              c.ID,
+			Content:         c.Content,
+			ImportanceScore: c.ImportanceScore,
+			Similarity:      1 - neurondb.CosineDistance(queryEmbedding, c.Embedding),
+			Metadata:        c.Metadata,
+		})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Similarity > ranked[j].Similarity
+	})
+	if topK >= 0 && len(ranked) > topK {
+		ranked = ranked[:topK]
+	}
+	return ranked
+}
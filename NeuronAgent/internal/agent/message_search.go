@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// Message search modes
+const (
+	MessageSearchKeyword  = "keyword"
+	MessageSearchSemantic = "semantic"
+)
+
+const (
+	// maxMessagesEmbeddedPerSearch bounds the messages a semantic search
+	// embeds before searching, so a first search over a long history stays
+	// quick; later searches embed the rest
+	maxMessagesEmbeddedPerSearch = 256
+	messageEmbedBatchSize        = 64
+)
+
+// MessageSearch is a search of an agent's messages across its sessions
+type MessageSearch struct {
+	Query  string
+	Mode   string
+	Filter db.MessageSearchFilter
+	Limit  int
+}
+
+// MessageSearchResult holds the messages found by a search. Unembedded
+// counts the messages a semantic search could not cover yet because they
+// have no embedding.
+type MessageSearchResult struct {
+	Hits       []db.MessageSearchHit
+	Model      string
+	Embedded   int
+	Unembedded int64
+}
+
+// SearchMessages searches the agent's messages. A keyword search uses
+// PostgreSQL full-text search. A semantic search first embeds messages in
+// its scope that the agent's memory embedding model has not embedded yet,
+// newest first, and then ranks messages by cosine similarity to the query.
+func (r *Runtime) SearchMessages(ctx context.Context, agent *db.Agent, search MessageSearch) (*MessageSearchResult, error) {
+	if search.Mode != MessageSearchSemantic {
+		hits, err := r.queries.SearchMessagesKeyword(ctx, agent.ID, search.Filter, search.Query, search.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return &MessageSearchResult{Hits: hits}, nil
+	}
+
+	model := MemoryEmbeddingModel(agent)
+	result := &MessageSearchResult{Model: model}
+	embedded, err := r.embedMessages(ctx, agent, search.Filter, model)
+	if err != nil {
+		return nil, err
+	}
+	result.Embedded = embedded
+	if result.Unembedded, err = r.queries.CountUnembeddedMessages(ctx, agent.ID, search.Filter, model); err != nil {
+		return nil, err
+	}
+
+	embedding, err := r.llm.Embed(ctx, model, search.Query)
+	if err != nil {
+		return nil, fmt.Errorf("message search failed: agent_id='%s', query_length=%d, error=%w",
+			agent.ID.String(), len(search.Query), err)
+	}
+	if result.Hits, err = r.queries.SearchMessagesSemantic(ctx, agent.ID, search.Filter, embedding, model, search.Query, search.Limit); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// embedMessages embeds up to maxMessagesEmbeddedPerSearch messages matching
+// filter that have no embedding of model and returns how many it embedded
+func (r *Runtime) embedMessages(ctx context.Context, agent *db.Agent, filter db.MessageSearchFilter, model string) (int, error) {
+	messages, err := r.queries.ListUnembeddedMessages(ctx, agent.ID, filter, model, maxMessagesEmbeddedPerSearch)
+	if err != nil {
+		return 0, err
+	}
+	embedded := 0
+	for start := 0; start < len(messages); start += messageEmbedBatchSize {
+		batch := messages[start:min(start+messageEmbedBatchSize, len(messages))]
+		ids := make([]int64, len(batch))
+		texts := make([]string, len(batch))
+		for i, m := range batch {
+			ids[i], texts[i] = m.ID, m.Content
+		}
+		embeddings, err := r.embed.EmbedBatch(ctx, texts, model)
+		if err != nil {
+			return embedded, fmt.Errorf("message embedding failed: agent_id='%s', model='%s', message_count=%d, error=%w",
+				agent.ID.String(), model, len(batch), err)
+		}
+		vectors := make([][]float32, len(embeddings))
+		for i, e := range embeddings {
+			vectors[i] = e
+		}
+		if err := r.queries.CreateMessageEmbeddings(ctx, model, ids, vectors); err != nil {
+			return embedded, err
+		}
+		embedded += len(batch)
+	}
+	return embedded, nil
+}
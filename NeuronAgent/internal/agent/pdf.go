@@ -0,0 +1,286 @@
+package agent
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxPDFStreamBytes bounds an inflated PDF content stream, so a small
+// compressed stream cannot expand without limit
+const maxPDFStreamBytes = 32 << 20
+
+// extractPDFText returns the text drawn by the content streams of a PDF. It
+// reads the text showing operators of uncompressed and FlateDecode streams
+// and decodes strings as single-byte text. Text in fonts with their own
+// encodings, as many CID fonts use, and scanned pages yield no text.
+func extractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+
+	var out strings.Builder
+	rest := data
+	for {
+		i := bytes.Index(rest, []byte("stream"))
+		if i < 0 {
+			break
+		}
+		if i >= 3 && string(rest[i-3:i]) == "end" {
+			rest = rest[i+len("stream"):]
+			continue
+		}
+		header := rest[:i]
+		if j := bytes.LastIndex(header, []byte("obj")); j >= 0 {
+			header = header[j:]
+		}
+		start := i + len("stream")
+		if start < len(rest) && rest[start] == '\r' {
+			start++
+		}
+		if start < len(rest) && rest[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(rest[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		body := rest[start : start+end]
+		rest = rest[start+end+len("endstream"):]
+
+		if bytes.Contains(header, []byte("/Image")) || bytes.Contains(header, []byte("/XRef")) {
+			continue
+		}
+		if bytes.Contains(header, []byte("/Filter")) {
+			if !bytes.Contains(header, []byte("/FlateDecode")) || hasOtherPDFFilter(header) {
+				continue
+			}
+			inflated, err := inflatePDFStream(body)
+			if err != nil {
+				continue
+			}
+			body = inflated
+		}
+		appendPDFText(&out, body)
+	}
+
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		return "", errors.New("PDF has no extractable text")
+	}
+	return text, nil
+}
+
+// hasOtherPDFFilter reports whether a stream dictionary chains FlateDecode
+// with a filter this reader does not decode
+func hasOtherPDFFilter(header []byte) bool {
+	for _, filter := range []string{"/ASCIIHexDecode", "/ASCII85Decode", "/LZWDecode", "/RunLengthDecode", "/DCTDecode", "/JPXDecode", "/CCITTFaxDecode", "/JBIG2Decode", "/Crypt"} {
+		if bytes.Contains(header, []byte(filter)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inflatePDFStream decodes a FlateDecode stream
+func inflatePDFStream(body []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	inflated, err := io.ReadAll(io.LimitReader(zr, maxPDFStreamBytes+1))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if len(inflated) > maxPDFStreamBytes {
+		return nil, fmt.Errorf("stream inflates past %d bytes", maxPDFStreamBytes)
+	}
+	return inflated, nil
+}
+
+// appendPDFText writes the text shown by a content stream to out, starting
+// a new line where the stream moves to a new line or ends a text object
+func appendPDFText(out *strings.Builder, stream []byte) {
+	newline := func() {
+		if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+			out.WriteByte('\n')
+		}
+	}
+
+	var operand string
+	var array []interface{}
+	inArray := false
+	for i := 0; i < len(stream); {
+		c := stream[i]
+		switch {
+		case c == '%':
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, next := readPDFLiteralString(stream, i)
+			i = next
+			if inArray {
+				array = append(array, s)
+			} else {
+				operand = s
+			}
+		case c == '<' && i+1 < len(stream) && stream[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(stream) && stream[i+1] == '>':
+			i += 2
+		case c == '<':
+			s, next := readPDFHexString(stream, i)
+			i = next
+			if inArray {
+				array = append(array, s)
+			} else {
+				operand = s
+			}
+		case c == '[':
+			inArray, array = true, nil
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case isPDFSpace(c) || c == '{' || c == '}' || c == ')' || c == '>':
+			i++
+		default:
+			start := i
+			for i < len(stream) && !isPDFSpace(stream[i]) && !isPDFDelimiter(stream[i]) {
+				i++
+			}
+			if i == start {
+				i++
+				continue
+			}
+			token := string(stream[start:i])
+			if inArray {
+				if n, err := strconv.ParseFloat(token, 64); err == nil {
+					array = append(array, n)
+				}
+				continue
+			}
+			switch token {
+			case "Tj":
+				out.WriteString(operand)
+				operand = ""
+			case "'", "\"":
+				newline()
+				out.WriteString(operand)
+				operand = ""
+			case "TJ":
+				for _, item := range array {
+					switch v := item.(type) {
+					case string:
+						out.WriteString(v)
+					case float64:
+						// A large negative adjustment is a word gap
+						if v < -200 {
+							out.WriteByte(' ')
+						}
+					}
+				}
+				array = nil
+			case "Td", "TD", "T*", "ET":
+				newline()
+			}
+		}
+	}
+	newline()
+}
+
+// readPDFLiteralString reads the literal string starting at stream[i], a
+// '(', and returns it with the index after its closing parenthesis
+func readPDFLiteralString(stream []byte, i int) (string, int) {
+	var b strings.Builder
+	depth := 0
+	for i++; i < len(stream); i++ {
+		c := stream[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			depth--
+		case '\\':
+			i++
+			if i >= len(stream) {
+				return b.String(), i
+			}
+			switch e := stream[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r':
+				if i+1 < len(stream) && stream[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for k := 0; k < 3 && i < len(stream) && stream[i] >= '0' && stream[i] <= '7'; k++ {
+						n = n*8 + int(stream[i]-'0')
+						i++
+					}
+					i--
+					b.WriteRune(rune(n & 0xff))
+				} else {
+					b.WriteRune(rune(e))
+				}
+			}
+			continue
+		}
+		b.WriteRune(rune(c))
+	}
+	return b.String(), i
+}
+
+// readPDFHexString reads the hex string starting at stream[i], a '<', and
+// returns it with the index after its closing '>'. Strings that do not
+// decode to printable single-byte text, such as two-byte glyph codes, are
+// returned empty.
+func readPDFHexString(stream []byte, i int) (string, int) {
+	end := bytes.IndexByte(stream[i:], '>')
+	if end < 0 {
+		return "", len(stream)
+	}
+	hex := strings.Map(func(r rune) rune {
+		if isPDFSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, string(stream[i+1:i+end]))
+	if len(hex)%2 == 1 {
+		hex += "0"
+	}
+	var b strings.Builder
+	for k := 0; k+1 < len(hex); k += 2 {
+		v, err := strconv.ParseUint(hex[k:k+2], 16, 8)
+		if err != nil || (v < 0x20 && v != '\t' && v != '\n') {
+			return "", i + end + 1
+		}
+		b.WriteRune(rune(v))
+	}
+	return b.String(), i + end + 1
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
@@ -1,17 +1,111 @@
 package agent
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
 	"github.com/neurondb/NeuronAgent/internal/db"
 )
 
-// Profile represents a predefined agent profile
+// Profile represents a predefined agent profile. Profiles are the built-in
+// templates agents can be created from; the system prompt and the string
+// values of the config may hold {{parameter}} placeholders that are filled
+// in when an agent is created.
 type Profile struct {
-	Name         string
-	Description  string
-	SystemPrompt string
-	ModelName    string
-	Config       map[string]interface{}
-	EnabledTools []string
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	SystemPrompt string                 `json:"system_prompt"`
+	ModelName    string                 `json:"model_name"`
+	Config       map[string]interface{} `json:"config"`
+	EnabledTools []string               `json:"enabled_tools"`
+	Parameters   []ProfileParameter     `json:"parameters"`
+}
+
+// ProfileParameter is a placeholder of a profile. A parameter without a
+// default must be given when an agent is created from the profile.
+type ProfileParameter struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// Required reports whether the parameter has no default
+func (p ProfileParameter) Required() bool {
+	return p.Default == nil
+}
+
+// profilePlaceholder matches a {{parameter}} placeholder
+var profilePlaceholder = regexp.MustCompile(`\{\{([a-z_][a-z0-9_]*)\}\}`)
+
+// Instantiate returns a copy of the profile with its placeholders filled in
+// from params and the parameter defaults. A config string that is exactly
+// one placeholder takes the parameter's value with its JSON type, so
+// numbers stay numbers; placeholders inside longer text are formatted.
+// Parameters the profile does not declare and required parameters that are
+// missing are errors.
+func (p Profile) Instantiate(params map[string]interface{}) (*Profile, error) {
+	values := make(map[string]interface{}, len(p.Parameters))
+	for _, param := range p.Parameters {
+		values[param.Name] = param.Default
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf("template '%s' has no parameter '%s'", p.Name, name)
+		}
+		switch params[name].(type) {
+		case string, float64, bool:
+		default:
+			return nil, fmt.Errorf("parameter '%s' must be a string, number or boolean", name)
+		}
+		values[name] = params[name]
+	}
+	for _, param := range p.Parameters {
+		if values[param.Name] == nil {
+			return nil, fmt.Errorf("template '%s' requires parameter '%s': %s", p.Name, param.Name, param.Description)
+		}
+	}
+
+	instance := p
+	instance.SystemPrompt = fillPlaceholders(p.SystemPrompt, values).(string)
+	instance.Config = fillPlaceholders(p.Config, values).(map[string]interface{})
+	instance.EnabledTools = append([]string{}, p.EnabledTools...)
+	instance.Parameters = nil
+	return &instance, nil
+}
+
+// fillPlaceholders returns a deep copy of v with the placeholders of its
+// strings replaced by values
+func fillPlaceholders(v interface{}, values map[string]interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		if m := profilePlaceholder.FindStringSubmatch(t); m != nil && m[0] == t {
+			return values[m[1]]
+		}
+		return profilePlaceholder.ReplaceAllStringFunc(t, func(placeholder string) string {
+			name := strings.TrimSuffix(strings.TrimPrefix(placeholder, "{{"), "}}")
+			return fmt.Sprint(values[name])
+		})
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			copied[key] = fillPlaceholders(value, values)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(t))
+		for i, value := range t {
+			copied[i] = fillPlaceholders(value, values)
+		}
+		return copied
+	}
+	return v
 }
 
 // GetDefaultProfiles returns default agent profiles
@@ -65,6 +159,73 @@ func GetDefaultProfiles() []Profile {
 			},
 			EnabledTools: []string{"http", "sql"},
 		},
+		{
+			Name:        "rag-assistant",
+			Description: "Answers questions from a table of documents and cites the passages it used",
+			SystemPrompt: "You are a helpful assistant answering questions about {{topic}}. Base your answers on the " +
+				"knowledge base passages provided with each question and cite them as [n]. If the passages do not " +
+				"contain the answer, say that you do not know instead of guessing.",
+			ModelName: "gpt-4",
+			Config: map[string]interface{}{
+				"temperature": 0.2,
+				"max_tokens":  1000,
+				"knowledge_bases": []interface{}{
+					map[string]interface{}{
+						"name":           "documents",
+						"table":          "{{table}}",
+						"content_column": "{{content_column}}",
+						"vector_column":  "{{vector_column}}",
+						"top_k":          "{{top_k}}",
+					},
+				},
+			},
+			EnabledTools: []string{},
+			Parameters: []ProfileParameter{
+				{Name: "table", Description: "table or schema.table holding the documents and their embeddings"},
+				{Name: "content_column", Description: "column with the document text", Default: "content"},
+				{Name: "vector_column", Description: "column with the document embeddings", Default: "embedding"},
+				{Name: "top_k", Description: "passages retrieved per question (1-20)", Default: 5.0},
+				{Name: "topic", Description: "what the documents are about, used in the system prompt", Default: "the documentation"},
+			},
+		},
+		{
+			Name:        "sql-analyst",
+			Description: "Answers questions about data by writing and running read-only SQL",
+			SystemPrompt: "You are a data analyst working with a PostgreSQL database. Answer questions by querying the " +
+				"{{schema}} schema with the sql tool. Only read data: never insert, update, delete or change the schema. " +
+				"Show the query you ran, then summarize what the results mean.",
+			ModelName: "gpt-4",
+			Config: map[string]interface{}{
+				"temperature": 0.1,
+				"max_tokens":  1500,
+			},
+			EnabledTools: []string{"sql"},
+			Parameters: []ProfileParameter{
+				{Name: "schema", Description: "schema the analyst queries", Default: "public"},
+			},
+		},
+		{
+			Name:        "support-bot",
+			Description: "Customer support agent that redacts personal data and resists prompt injection",
+			SystemPrompt: "You are a friendly customer support agent for {{company}}. Help customers with questions about " +
+				"{{product}}. Be concise and polite, ask for details when a request is unclear, and never share " +
+				"information about other customers. When you cannot resolve an issue, tell the customer it will be " +
+				"passed on to a human agent.",
+			ModelName: "gpt-4",
+			Config: map[string]interface{}{
+				"temperature": 0.4,
+				"max_tokens":  800,
+				"guardrails": map[string]interface{}{
+					"pii":              "redact",
+					"prompt_injection": "block",
+				},
+			},
+			EnabledTools: []string{},
+			Parameters: []ProfileParameter{
+				{Name: "company", Description: "name of the company the bot supports"},
+				{Name: "product", Description: "products or services the bot helps with", Default: "our products and services"},
+			},
+		},
 	}
 }
 
@@ -35,6 +35,12 @@ func (p *PromptBuilder) Build(agent *db.Agent, context *Context, userMessage str
 		}
 	}
 
+	// Knowledge base passages, cited by number
+	parts = append(parts, knowledgePromptParts(context.Passages)...)
+
+	// Summary of the history before the recent messages
+	parts = append(parts, summaryPromptParts(context.Summary)...)
+
 	// Conversation history
 	if len(context.Messages) > 0 {
 		parts = append(parts, "\n\n## Conversation History:")
@@ -44,6 +50,9 @@ func (p *PromptBuilder) Build(agent *db.Agent, context *Context, userMessage str
 		}
 	}
 
+	// Attachments of the current message
+	parts = append(parts, attachmentPromptParts(context.Attachments)...)
+
 	// Current user message
 	parts = append(parts, fmt.Sprintf("\n\n## Current Request:\nUser: %s", userMessage))
 	parts = append(parts, "\n\nAssistant:")
@@ -65,6 +74,12 @@ func (p *PromptBuilder) BuildWithToolResults(agent *db.Agent, context *Context,
 		}
 	}
 
+	// Knowledge base passages, cited by number
+	parts = append(parts, knowledgePromptParts(context.Passages)...)
+
+	// Summary of the history before the recent messages
+	parts = append(parts, summaryPromptParts(context.Summary)...)
+
 	// Conversation history
 	if len(context.Messages) > 0 {
 		parts = append(parts, "\n\n## Conversation History:")
@@ -74,6 +89,9 @@ func (p *PromptBuilder) BuildWithToolResults(agent *db.Agent, context *Context,
 		}
 	}
 
+	// Attachments of the current message
+	parts = append(parts, attachmentPromptParts(context.Attachments)...)
+
 	// Current user message
 	parts = append(parts, fmt.Sprintf("\n\n## Current Request:\nUser: %s", userMessage))
 
@@ -98,3 +116,33 @@ func (p *PromptBuilder) BuildWithToolResults(agent *db.Agent, context *Context,
 
 	return strings.Join(parts, ""), nil
 }
+
+// summaryPromptParts gives the rolling summary of the session's older
+// messages
+func summaryPromptParts(summary *db.SessionSummary) []string {
+	if summary == nil || summary.Summary == "" {
+		return nil
+	}
+	return []string{"\n\n## Summary of Earlier Conversation:\n", summary.Summary}
+}
+
+// attachmentPromptParts lists the files sent with the current message and
+// the opening of their text. The rest of their text reaches the prompt as
+// memory chunks.
+func attachmentPromptParts(attachments []db.MessageAttachment) []string {
+	if len(attachments) == 0 {
+		return nil
+	}
+	parts := []string{"\n\n## Attachments:"}
+	for _, att := range attachments {
+		switch {
+		case att.Summary != nil:
+			parts = append(parts, fmt.Sprintf("\n- %s (%s): %s", att.Filename, att.MIMEType, *att.Summary))
+		case att.Status == db.AttachmentUnsupported:
+			parts = append(parts, fmt.Sprintf("\n- %s (%s): content not readable", att.Filename, att.MIMEType))
+		default:
+			parts = append(parts, fmt.Sprintf("\n- %s (%s): text could not be extracted", att.Filename, att.MIMEType))
+		}
+	}
+	return parts
+}
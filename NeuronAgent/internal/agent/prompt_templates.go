@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// promptVariablesKey is the session metadata key holding the values of the
+// prompt template variables of the session
+const promptVariablesKey = "prompt_variables"
+
+// promptVariablePattern matches a {{name}} placeholder of a prompt template
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// PromptTemplateVariables returns the names of the variables content refers
+// to, sorted and without duplicates
+func PromptTemplateVariables(content string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, match := range promptVariablePattern.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenderPromptTemplate replaces the placeholders of content with values,
+// falling back to the defaults of the version's variables. It fails if a
+// variable has neither a value nor a default.
+func RenderPromptTemplate(content string, defaults, values map[string]interface{}) (string, error) {
+	var missing []string
+	rendered := promptVariablePattern.ReplaceAllStringFunc(content, func(placeholder string) string {
+		name := promptVariablePattern.FindStringSubmatch(placeholder)[1]
+		value, ok := values[name]
+		if !ok || value == nil {
+			value = defaults[name]
+		}
+		if value == nil {
+			missing = append(missing, name)
+			return placeholder
+		}
+		return fmt.Sprint(value)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("prompt template variables have no value: variables=%v", missing)
+	}
+	return rendered, nil
+}
+
+// RenderPromptVersion returns the system prompt a prompt template version
+// serves a session, with the variables of the session's metadata
+func RenderPromptVersion(version *db.PromptTemplateVersion, session *db.Session) (string, error) {
+	values, _ := session.Metadata[promptVariablesKey].(map[string]interface{})
+	rendered, err := RenderPromptTemplate(version.Content, version.Variables.ToMap(), values)
+	if err != nil {
+		return "", fmt.Errorf("prompt template rendering failed: template_id='%s', version=%d, session_id='%s': %w",
+			version.TemplateID.String(), version.Version, session.ID.String(), err)
+	}
+	return rendered, nil
+}
+
+// choosePromptVersion picks the version a session is served by rollout
+// weight. The choice is a hash of the session ID, so it is the same on
+// every call for the session. It returns nil if no version has a weight.
+func choosePromptVersion(versions []db.PromptTemplateVersion, sessionID uuid.UUID) *db.PromptTemplateVersion {
+	total := 0
+	for _, v := range versions {
+		total += v.RolloutWeight
+	}
+	if total == 0 {
+		return nil
+	}
+	h := fnv.New64a()
+	h.Write(sessionID[:])
+	point := int(h.Sum64() % uint64(total))
+	for i := range versions {
+		if point < versions[i].RolloutWeight {
+			return &versions[i]
+		}
+		point -= versions[i].RolloutWeight
+	}
+	return nil
+}
+
+// applyPromptTemplate returns the agent as it serves the session: for an
+// agent using a prompt template, a copy whose system prompt is the session's
+// template version rendered with the session's variables, along with the ID
+// of that version. A session is assigned its version on its first message
+// and keeps it. Agents without a template are returned unchanged.
+func (r *Runtime) applyPromptTemplate(ctx context.Context, agent *db.Agent, session *db.Session) (*db.Agent, *uuid.UUID, error) {
+	if agent.PromptTemplateID == nil {
+		return agent, nil, nil
+	}
+
+	// A session assigned a version of a template the agent no longer uses
+	// is assigned a version of the new one
+	var version *db.PromptTemplateVersion
+	if session.PromptVersionID != nil {
+		v, err := r.queries.GetPromptTemplateVersion(ctx, *session.PromptVersionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v.TemplateID == *agent.PromptTemplateID {
+			version = v
+		}
+	}
+	if version == nil {
+		versions, err := r.queries.ListPromptTemplateVersions(ctx, *agent.PromptTemplateID)
+		if err != nil {
+			return nil, nil, err
+		}
+		version = choosePromptVersion(versions, session.ID)
+		if version == nil {
+			return nil, nil, fmt.Errorf("prompt template has no version rolled out: template_id='%s', version_count=%d",
+				agent.PromptTemplateID.String(), len(versions))
+		}
+		assigned, err := r.queries.AssignSessionPromptVersion(ctx, session.ID, version)
+		if err != nil {
+			return nil, nil, err
+		}
+		if assigned != version.ID {
+			if version, err = r.queries.GetPromptTemplateVersion(ctx, assigned); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	systemPrompt, err := RenderPromptVersion(version, session)
+	if err != nil {
+		return nil, nil, err
+	}
+	served := *agent
+	served.SystemPrompt = systemPrompt
+	return &served, &version.ID, nil
+}
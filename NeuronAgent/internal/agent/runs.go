@@ -0,0 +1,635 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// Run steps. Each is checkpointed once done, and a resumed run continues
+// after its last checkpointed step.
+const (
+	runStepStarted        = "started"         // the message was admitted
+	runStepGenerated      = "generated"       // the LLM called tools that have not run yet
+	runStepToolsCompleted = "tools_completed" // the tool calls ran
+	runStepAnswered       = "answered"        // the final answer is ready to be stored
+)
+
+const (
+	defaultRunHeartbeatInterval = 15 * time.Second
+	defaultRunStaleAfter        = 2 * time.Minute
+	defaultRunRecoveryInterval  = 30 * time.Second
+	defaultMaxResumeAttempts    = 3
+)
+
+// RunRecovery configures the checkpointing of runs. A run refreshes its
+// heartbeat every HeartbeatInterval while in progress. A run whose
+// heartbeat is older than StaleAfter was interrupted; recovery looks for
+// such runs every RecoveryInterval and resumes them from their last
+// checkpoint, failing runs already resumed MaxResumeAttempts times.
+type RunRecovery struct {
+	HeartbeatInterval time.Duration
+	StaleAfter        time.Duration
+	RecoveryInterval  time.Duration
+	MaxResumeAttempts int
+}
+
+// SetRunRecovery replaces the run checkpointing settings. Zero values keep
+// their defaults.
+func (r *Runtime) SetRunRecovery(recovery RunRecovery) {
+	if recovery.HeartbeatInterval <= 0 {
+		recovery.HeartbeatInterval = defaultRunHeartbeatInterval
+	}
+	if recovery.StaleAfter <= 0 {
+		recovery.StaleAfter = defaultRunStaleAfter
+	}
+	if recovery.RecoveryInterval <= 0 {
+		recovery.RecoveryInterval = defaultRunRecoveryInterval
+	}
+	if recovery.MaxResumeAttempts < 0 {
+		recovery.MaxResumeAttempts = 0
+	}
+	r.recovery = recovery
+}
+
+// RunRecoveryPolicy decides what happens to the agent's runs interrupted by
+// a server stopping. It is read from the "run_recovery" object of the agent
+// config:
+//
+//	"run_recovery": {
+//	  "resume": true,       // resume interrupted runs from their last checkpoint; false fails them
+//	  "rerun_tools": false  // rerun tool calls that may have been running when the run stopped
+//	}
+//
+// Tool calls that completed are never rerun. Calls that were running may
+// already have had their effects, so by default such a run is failed.
+type RunRecoveryPolicy struct {
+	Resume     bool
+	RerunTools bool
+}
+
+// ParseRunRecoveryPolicy extracts the run recovery policy from an agent
+// config. A missing "run_recovery" key yields the default policy.
+func ParseRunRecoveryPolicy(config map[string]interface{}) (*RunRecoveryPolicy, error) {
+	policy := &RunRecoveryPolicy{Resume: true}
+	raw, ok := config["run_recovery"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("run_recovery must be an object, got %T", raw)
+	}
+	for key, dest := range map[string]*bool{"resume": &policy.Resume, "rerun_tools": &policy.RerunTools} {
+		v, ok := settings[key]
+		if !ok {
+			continue
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("run_recovery.%s must be a boolean", key)
+		}
+		*dest = b
+	}
+	return policy, nil
+}
+
+// runPolicies are the agent config policies a run follows
+type runPolicies struct {
+	guardrails    *GuardrailPolicy
+	usage         *UsagePolicy
+	approvals     *ApprovalPolicy
+	summarization *SummarizationPolicy
+	toolCache     *ToolCachePolicy
+	constraints   *ToolConstraintPolicy
+}
+
+// parseRunPolicies extracts the policies a run follows from an agent config
+func parseRunPolicies(config map[string]interface{}) (*runPolicies, error) {
+	var policies runPolicies
+	var err error
+	if policies.guardrails, err = ParseGuardrailPolicy(config); err != nil {
+		return nil, fmt.Errorf("load guardrails: %w", err)
+	}
+	if policies.usage, err = ParseUsagePolicy(config); err != nil {
+		return nil, fmt.Errorf("load usage policy: %w", err)
+	}
+	if policies.approvals, err = ParseApprovalPolicy(config); err != nil {
+		return nil, fmt.Errorf("load tool approval policy: %w", err)
+	}
+	if policies.summarization, err = ParseSummarizationPolicy(config); err != nil {
+		return nil, fmt.Errorf("load summarization policy: %w", err)
+	}
+	if policies.toolCache, err = ParseToolCachePolicy(config); err != nil {
+		return nil, fmt.Errorf("load tool cache policy: %w", err)
+	}
+	if policies.constraints, err = ParseToolConstraintPolicy(config); err != nil {
+		return nil, fmt.Errorf("load tool constraints: %w", err)
+	}
+	return &policies, nil
+}
+
+// runCheckpoint is what a run needs to continue after its last step. The
+// context is not part of it; a resumed run loads it again.
+type runCheckpoint struct {
+	UserMessage         string                 `json:"user_message"`
+	Attachments         []db.MessageAttachment `json:"attachments,omitempty"`
+	APIKeyID            *uuid.UUID             `json:"api_key_id,omitempty"`
+	Response            *runResponse           `json:"response,omitempty"`
+	ToolResults         []runToolResult        `json:"tool_results,omitempty"`
+	FinalAnswer         string                 `json:"final_answer,omitempty"`
+	TokensUsed          int                    `json:"tokens_used"`
+	Usage               TokenUsage             `json:"usage"`
+	CostUSD             float64                `json:"cost_usd"`
+	LLMCalls            []LLMCallUsage         `json:"llm_calls,omitempty"`
+	GuardrailViolations []GuardrailViolation   `json:"guardrail_violations,omitempty"`
+}
+
+// runResponse is the first LLM response of a run with the tool calls it
+// made
+type runResponse struct {
+	Content   string        `json:"content"`
+	ToolCalls []RunToolCall `json:"tool_calls,omitempty"`
+	Usage     TokenUsage    `json:"usage"`
+	Provider  string        `json:"provider,omitempty"`
+	Model     string        `json:"model,omitempty"`
+}
+
+// RunToolCall is a tool call of a run, as checkpointed
+type RunToolCall struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+type runToolResult struct {
+	ToolCallID string        `json:"tool_call_id"`
+	Content    string        `json:"content"`
+	Error      string        `json:"error,omitempty"`
+	CacheHit   *ToolCacheHit `json:"cache_hit,omitempty"`
+	DurationMs float64       `json:"duration_ms,omitempty"`
+
+	ConstraintViolations []ToolConstraintViolation `json:"constraint_violations,omitempty"`
+}
+
+// newRunCheckpoint captures the state of a run
+func newRunCheckpoint(state *ExecutionState, apiKeyID *uuid.UUID) *runCheckpoint {
+	checkpoint := &runCheckpoint{
+		UserMessage:         state.UserMessage,
+		Attachments:         state.Attachments,
+		APIKeyID:            apiKeyID,
+		FinalAnswer:         state.FinalAnswer,
+		TokensUsed:          state.TokensUsed,
+		Usage:               state.Usage,
+		CostUSD:             state.CostUSD,
+		LLMCalls:            state.LLMCalls,
+		GuardrailViolations: state.GuardrailViolations,
+	}
+	if resp := state.LLMResponse; resp != nil {
+		checkpoint.Response = &runResponse{
+			Content:  resp.Content,
+			Usage:    resp.Usage,
+			Provider: resp.Provider,
+			Model:    resp.Model,
+		}
+		for _, call := range resp.ToolCalls {
+			checkpoint.Response.ToolCalls = append(checkpoint.Response.ToolCalls,
+				RunToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+		}
+	}
+	for _, result := range state.ToolResults {
+		stored := runToolResult{
+			ToolCallID: result.ToolCallID,
+			Content:    result.Content,
+			CacheHit:   result.CacheHit,
+			DurationMs: durationMs(result.Duration),
+
+			ConstraintViolations: result.ConstraintViolations,
+		}
+		if result.Error != nil {
+			stored.Error = result.Error.Error()
+		}
+		checkpoint.ToolResults = append(checkpoint.ToolResults, stored)
+	}
+	return checkpoint
+}
+
+// restore sets the checkpointed state of a run on state
+func (c *runCheckpoint) restore(state *ExecutionState) {
+	state.UserMessage = c.UserMessage
+	state.Attachments = c.Attachments
+	state.FinalAnswer = c.FinalAnswer
+	state.TokensUsed = c.TokensUsed
+	state.Usage = c.Usage
+	state.CostUSD = c.CostUSD
+	state.LLMCalls = c.LLMCalls
+	state.GuardrailViolations = c.GuardrailViolations
+	if c.Response != nil {
+		state.LLMResponse = &LLMResponse{
+			Content:  c.Response.Content,
+			Usage:    c.Response.Usage,
+			Provider: c.Response.Provider,
+			Model:    c.Response.Model,
+		}
+		for _, call := range c.Response.ToolCalls {
+			state.LLMResponse.ToolCalls = append(state.LLMResponse.ToolCalls,
+				ToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+		}
+		state.ToolCalls = state.LLMResponse.ToolCalls
+	}
+	for _, stored := range c.ToolResults {
+		result := ToolResult{
+			ToolCallID: stored.ToolCallID,
+			Content:    stored.Content,
+			CacheHit:   stored.CacheHit,
+			Duration:   time.Duration(stored.DurationMs * float64(time.Millisecond)),
+
+			ConstraintViolations: stored.ConstraintViolations,
+		}
+		if stored.Error != "" {
+			result.Error = errors.New(stored.Error)
+		}
+		state.ToolResults = append(state.ToolResults, result)
+	}
+}
+
+// RunToolCalls returns the tool calls a run's LLM made, as last
+// checkpointed
+func RunToolCalls(run *db.Run) ([]RunToolCall, error) {
+	var checkpoint runCheckpoint
+	if err := fromJSONMap(run.Checkpoint.ToMap(), &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid run checkpoint: run_id='%s', error=%w", run.ID.String(), err)
+	}
+	if checkpoint.Response == nil {
+		return nil, nil
+	}
+	return checkpoint.Response.ToolCalls, nil
+}
+
+// runResult is the stored result of a completed run
+func runResult(state *ExecutionState) (map[string]interface{}, error) {
+	return toJSONMap(map[string]interface{}{
+		"response":     state.FinalAnswer,
+		"tokens_used":  state.TokensUsed,
+		"usage":        state.Usage,
+		"cost_usd":     state.CostUSD,
+		"tool_results": approvalToolResults(state.ToolResults),
+		"trace":        newRunTrace(state),
+	})
+}
+
+// activeRun is a run in progress on this server. Its heartbeat is
+// refreshed until the run finishes, pauses or is released.
+type activeRun struct {
+	queries  *db.Queries
+	run      *db.Run
+	apiKeyID *uuid.UUID
+	cancel   context.CancelFunc
+	done     chan struct{}
+	tracker  *runTracker
+	// handedBack is set, guarded by the tracker, when a drain left the run
+	// for recovery elsewhere
+	handedBack bool
+}
+
+// startRun records the run of the message in state
+func (r *Runtime) startRun(ctx context.Context, state *ExecutionState, apiKey *db.APIKey) (*activeRun, error) {
+	var apiKeyID *uuid.UUID
+	if apiKey != nil {
+		apiKeyID = &apiKey.ID
+	}
+	checkpoint, err := toJSONMap(newRunCheckpoint(state, apiKeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode run checkpoint: %w", err)
+	}
+	run := &db.Run{
+		SessionID:  state.SessionID,
+		AgentID:    state.AgentID,
+		Step:       runStepStarted,
+		Checkpoint: db.FromMap(checkpoint),
+	}
+	if err := r.queries.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+	state.RunID = &run.ID
+	return r.trackRun(run, apiKeyID), nil
+}
+
+// trackRun starts the heartbeat of a run taken on by this server
+func (r *Runtime) trackRun(run *db.Run, apiKeyID *uuid.UUID) *activeRun {
+	ctx, cancel := context.WithCancel(context.Background())
+	active := &activeRun{
+		queries:  r.queries,
+		run:      run,
+		apiKeyID: apiKeyID,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		tracker:  r.runs,
+	}
+	go active.heartbeat(ctx, r.recovery.HeartbeatInterval)
+	return active
+}
+
+func (a *activeRun) heartbeat(ctx context.Context, interval time.Duration) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := a.queries.HeartbeatRun(ctx, a.run.ID, a.run.Attempts)
+			if errors.Is(err, db.ErrVersionConflict) {
+				// The run finished or recovery took it over
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				metrics.Logger().Warn().Err(err).
+					Str("run_id", a.run.ID.String()).
+					Msg("Failed to refresh run heartbeat")
+			}
+		}
+	}
+}
+
+// release stops the heartbeat. A run released while running is left for
+// recovery.
+func (a *activeRun) release() {
+	a.cancel()
+	<-a.done
+}
+
+// checkpoint records that the run completed step
+func (a *activeRun) checkpoint(ctx context.Context, step string, state *ExecutionState) error {
+	checkpoint, err := toJSONMap(newRunCheckpoint(state, a.apiKeyID))
+	if err != nil {
+		return fmt.Errorf("failed to encode run checkpoint: run_id='%s', step='%s', error=%w", a.run.ID.String(), step, err)
+	}
+	if err := a.queries.CheckpointRun(ctx, a.run.ID, a.run.Attempts, step, checkpoint); err != nil {
+		return err
+	}
+	a.run.Step = step
+	return nil
+}
+
+// pause records that the run awaits the tool approval approvalID
+func (a *activeRun) pause(ctx context.Context, approvalID uuid.UUID) error {
+	a.release()
+	return a.queries.PauseRun(ctx, a.run.ID, a.run.Attempts, approvalID)
+}
+
+// finish records the run as completed. The answer is already stored, so a
+// failure is logged rather than failing the request.
+func (a *activeRun) finish(ctx context.Context, state *ExecutionState) {
+	a.release()
+	result, err := runResult(state)
+	if err == nil {
+		err = a.queries.FinishRun(ctx, a.run.ID, a.run.Attempts, db.RunCompleted, result, nil)
+	}
+	if err != nil {
+		metrics.Logger().Error().Err(err).
+			Str("run_id", a.run.ID.String()).
+			Msg("Failed to record run completion")
+	}
+}
+
+// fail records the run as failed with cause. It is recorded even when the
+// request that ran it was canceled, but not when a drain handed it back.
+func (a *activeRun) fail(cause error) {
+	a.release()
+	if a.isHandedBack() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	message := cause.Error()
+	err := a.queries.FinishRun(ctx, a.run.ID, a.run.Attempts, db.RunFailed, nil, &message)
+	if err != nil && !errors.Is(err, db.ErrVersionConflict) {
+		metrics.Logger().Error().Err(err).
+			Str("run_id", a.run.ID.String()).
+			Msg("Failed to record run failure")
+	}
+}
+
+// activeSessionRun returns the session's run in progress elsewhere, or nil
+// if there is none
+func (r *Runtime) activeSessionRun(ctx context.Context, sessionID uuid.UUID) (*db.Run, error) {
+	run, err := r.queries.GetActiveRun(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return run, err
+}
+
+// RecoverRun takes over a run interrupted on a server that stopped. The
+// run resumes from its last checkpoint, unless the agent's run_recovery
+// policy or the run's attempts say to fail it. Recovered runs were already
+// admitted, so the agent's concurrency limit does not apply to them. A run
+// whose recovery is canceled, such as by this server stopping, is left for
+// recovery elsewhere.
+func (r *Runtime) RecoverRun(ctx context.Context, claimed *db.Run) error {
+	// A draining server hands the run straight back
+	ctx, execution, ok := r.runs.admit(ctx)
+	if !ok {
+		releaseRun(r.queries, claimed)
+		return fmt.Errorf("run recovery deferred: run_id='%s', error=%w", claimed.ID.String(), ErrDraining)
+	}
+	defer execution.done()
+
+	var checkpoint runCheckpoint
+	if err := fromJSONMap(claimed.Checkpoint.ToMap(), &checkpoint); err != nil {
+		run := r.trackRun(claimed, nil)
+		execution.attach(run)
+		return r.failRecoveredRun(run, fmt.Errorf("run recovery failed (decode checkpoint): run_id='%s', error=%w",
+			claimed.ID.String(), err))
+	}
+	run := r.trackRun(claimed, checkpoint.APIKeyID)
+	execution.attach(run)
+
+	// The first attempt started the run; every later one resumed it
+	if resumed := claimed.Attempts - 2; resumed >= r.recovery.MaxResumeAttempts {
+		return r.failRecoveredRun(run, fmt.Errorf("run interrupted: run_id='%s', step='%s', resumed %d times already",
+			claimed.ID.String(), claimed.Step, resumed))
+	}
+
+	agent, err := r.queries.GetAgentByID(ctx, claimed.AgentID)
+	if err != nil {
+		return r.failRecoveredRun(run, fmt.Errorf("run recovery failed (load agent): run_id='%s', agent_id='%s', error=%w",
+			claimed.ID.String(), claimed.AgentID.String(), err))
+	}
+	session, err := r.queries.GetSession(ctx, claimed.SessionID)
+	if err != nil {
+		return r.failRecoveredRun(run, fmt.Errorf("run recovery failed (load session): run_id='%s', session_id='%s', error=%w",
+			claimed.ID.String(), claimed.SessionID.String(), err))
+	}
+	recovery, err := ParseRunRecoveryPolicy(agent.Config.ToMap())
+	if err != nil {
+		return r.failRecoveredRun(run, fmt.Errorf("run recovery failed (load run recovery policy): run_id='%s', agent_id='%s', error=%w",
+			claimed.ID.String(), agent.ID.String(), err))
+	}
+	if !recovery.Resume {
+		return r.failRecoveredRun(run, fmt.Errorf("run interrupted: run_id='%s', step='%s', agent does not resume interrupted runs",
+			claimed.ID.String(), claimed.Step))
+	}
+
+	releaseSession, err := r.limiter.AcquireSession(ctx, session.ID)
+	if err != nil {
+		run.release()
+		return fmt.Errorf("run recovery deferred (wait for session): run_id='%s', session_id='%s', error=%w",
+			claimed.ID.String(), session.ID.String(), err)
+	}
+	defer releaseSession()
+
+	agent, promptVersionID, err := r.applyPromptTemplate(ctx, agent, session)
+	if err != nil {
+		return r.failRecoveredRun(run, fmt.Errorf("run recovery failed (load prompt template): run_id='%s', agent_id='%s', error=%w",
+			claimed.ID.String(), agent.ID.String(), err))
+	}
+	policies, err := parseRunPolicies(agent.Config.ToMap())
+	if err != nil {
+		return r.failRecoveredRun(run, fmt.Errorf("run recovery failed (%w): run_id='%s', agent_id='%s'",
+			err, claimed.ID.String(), agent.ID.String()))
+	}
+
+	state := &ExecutionState{
+		SessionID:       session.ID,
+		AgentID:         agent.ID,
+		RunID:           &claimed.ID,
+		PromptVersionID: promptVersionID,
+	}
+	checkpoint.restore(state)
+
+	// Calls needing approval have not run; the run pauses for them again
+	if claimed.Step == runStepGenerated && !recovery.RerunTools {
+		if _, required := r.approvalToolCalls(agent, policies.approvals, state.ToolCalls); !required {
+			return r.failRecoveredRun(run, fmt.Errorf("run interrupted: run_id='%s', step='%s', tool calls may have been running and the agent does not rerun them",
+				claimed.ID.String(), claimed.Step))
+		}
+	}
+
+	var apiKey *db.APIKey
+	if checkpoint.APIKeyID != nil {
+		if apiKey, err = r.queries.GetAPIKeyByID(ctx, *checkpoint.APIKeyID); err != nil {
+			// The key may have been revoked meanwhile; usage is still recorded
+			// against the agent
+			apiKey = nil
+		}
+	}
+	if apiKey != nil {
+		ctx = auth.WithAPIKey(ctx, apiKey)
+	}
+
+	if err := r.advance(ctx, run, agent, policies, state, apiKey); err != nil {
+		if ctx.Err() != nil {
+			run.release()
+			return err
+		}
+		return r.failRecoveredRun(run, err)
+	}
+	outcome := "completed"
+	if state.PendingApproval != nil {
+		outcome = "paused"
+	}
+	metrics.RecordRunRecovered(agent.ID.String(), outcome)
+	return nil
+}
+
+// failRecoveredRun fails a run taken over by recovery with cause
+func (r *Runtime) failRecoveredRun(run *activeRun, cause error) error {
+	run.fail(cause)
+	metrics.RecordRunRecovered(run.run.AgentID.String(), "failed")
+	return cause
+}
+
+// RunRecoveryService takes over runs interrupted by a server stopping
+type RunRecoveryService struct {
+	runtime *Runtime
+	queries *db.Queries
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewRunRecoveryService(runtime *Runtime, queries *db.Queries) *RunRecoveryService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RunRecoveryService{
+		runtime: runtime,
+		queries: queries,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start starts the recovery service
+func (s *RunRecoveryService) Start() {
+	go s.run()
+}
+
+// Stop stops the recovery service and waits for a recovering run to be
+// released
+func (s *RunRecoveryService) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *RunRecoveryService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.runtime.recovery.RecoveryInterval)
+	defer ticker.Stop()
+
+	s.recoverAll()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.recoverAll()
+		}
+	}
+}
+
+// recoverAll takes over interrupted runs one at a time until none is left.
+// A draining server leaves them to other servers.
+func (s *RunRecoveryService) recoverAll() {
+	for s.ctx.Err() == nil && !s.runtime.Draining() {
+		run, err := s.queries.ClaimInterruptedRun(s.ctx, time.Now().Add(-s.runtime.recovery.StaleAfter))
+		if err != nil {
+			if s.ctx.Err() == nil {
+				metrics.Logger().Error().Err(err).Msg("Failed to claim interrupted run")
+			}
+			return
+		}
+		if run == nil {
+			return
+		}
+		if err := s.runtime.RecoverRun(s.ctx, run); err != nil {
+			metrics.Logger().Warn().Err(err).
+				Str("run_id", run.ID.String()).
+				Str("session_id", run.SessionID.String()).
+				Int("attempts", run.Attempts).
+				Msg("Interrupted run was not resumed")
+		}
+	}
+}
+
+// runMetadata links a stored message to the run that wrote it, so the run
+// can be traced back from the session's messages
+func runMetadata(runID *uuid.UUID) map[string]interface{} {
+	if runID == nil {
+		return nil
+	}
+	return map[string]interface{}{"run_id": runID.String()}
+}
@@ -3,12 +3,16 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/neurondb/NeuronAgent/internal/auth"
 	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/internal/webhooks"
 	"github.com/neurondb/NeuronAgent/pkg/neurondb"
 )
 
@@ -16,11 +20,23 @@ type Runtime struct {
 	db        *db.DB
 	queries   *db.Queries
 	memory    *MemoryManager
+	knowledge *KnowledgeRetriever
 	planner   *Planner
 	prompt    *PromptBuilder
 	llm       *LLMClient
 	tools     ToolRegistry
 	embed     *neurondb.EmbeddingClient
+	caps      *neurondb.Capabilities // nil when detection was not run
+	usage     *UsageTracker
+	events    *webhooks.Emitter
+	summaries *HistorySummarizer
+	limiter   *RunLimiter
+	runs      *runTracker
+	toolCache *ToolResultCache
+	recovery  RunRecovery
+
+	// llmLogFile receives the LLM calls of agents logging to the file sink
+	llmLogFile *LLMLogFile
 }
 
 type ExecutionState struct {
@@ -33,13 +49,61 @@ type ExecutionState struct {
 	ToolResults []ToolResult
 	FinalAnswer string
 	TokensUsed  int
+	Usage       TokenUsage
+	CostUSD     float64
+	LLMCalls    []LLMCallUsage
 	Error       error
+	// GuardrailViolations lists every guardrail hit during the execution
+	GuardrailViolations []GuardrailViolation
+	// PendingApproval is set when the run paused for a tool approval; the
+	// run has no answer yet and resumes once the approval is decided
+	PendingApproval *db.ToolApproval
+	// CacheHit is set when the answer came from the semantic cache without
+	// calling the LLM
+	CacheHit *SemanticCacheHit
+	// Attachments lists the files sent with the user message
+	Attachments []db.MessageAttachment
+	// PromptVersionID is the prompt template version that served the
+	// answer, for agents using a prompt template
+	PromptVersionID *uuid.UUID
+	// Sources lists the knowledge base passages the answer was given
+	Sources []KnowledgeSource
+	// RunID is the run recording the execution; messages refused before
+	// they run, such as blocked ones, have none
+	RunID *uuid.UUID
 }
 
 type LLMResponse struct {
-	Content   string
-	ToolCalls []ToolCall
-	Usage     TokenUsage
+	Content     string
+	ToolCalls   []ToolCall
+	Usage       TokenUsage
+	Provider    string        // provider that produced the response, as type:model
+	Model       string        // model that produced the response
+	PromptChars int           // length of the prompt sent
+	Latency     time.Duration // time to the response, failed attempts included
+	// Retries describes the attempts that failed before the response:
+	// providers failed over and prompts too long for the context length
+	Retries []string
+}
+
+// LLM call purposes, recorded with the usage of each call
+const (
+	LLMCallAnswer        = "answer"        // answers the user message or calls tools
+	LLMCallToolResults   = "tool_results"  // answers with the results of the tool calls
+	LLMCallSummarization = "summarization" // folds history into the session summary
+)
+
+// LLMCallUsage is the usage and estimated cost of one LLM call, with what
+// it took to make it
+type LLMCallUsage struct {
+	Model       string     `json:"model"`
+	Provider    string     `json:"provider,omitempty"`
+	Usage       TokenUsage `json:"usage"`
+	CostUSD     float64    `json:"cost_usd"`
+	Purpose     string     `json:"purpose,omitempty"`
+	PromptChars int        `json:"prompt_chars,omitempty"`
+	LatencyMs   float64    `json:"latency_ms,omitempty"`
+	Retries     []string   `json:"retries,omitempty"`
 }
 
 type ToolCall struct {
@@ -52,12 +116,20 @@ type ToolResult struct {
 	ToolCallID string
 	Content    string
 	Error      error
+	// CacheHit is set when the result came from the session's tool cache
+	CacheHit *ToolCacheHit
+	// Duration is the time the tool took to run; zero for cache hits and
+	// calls that did not run
+	Duration time.Duration
+	// ConstraintViolations are set when the call was refused for breaking
+	// the agent's tool constraints
+	ConstraintViolations []ToolConstraintViolation
 }
 
 type TokenUsage struct {
-	PromptTokens     int
-	CompletionTokens int
-	TotalTokens      int
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // ToolRegistry interface for tool management
@@ -67,24 +139,80 @@ type ToolRegistry interface {
 }
 
 func NewRuntime(db *db.DB, queries *db.Queries, tools ToolRegistry, embedClient *neurondb.EmbeddingClient) *Runtime {
+	llm := NewLLMClient(db, embedClient)
 	return &Runtime{
-		db:      db,
-		queries: queries,
-		memory:  NewMemoryManager(db, queries, embedClient),
-		planner: NewPlanner(),
-		prompt:  NewPromptBuilder(),
-		llm:     NewLLMClient(db),
-		tools:   tools,
-		embed:   embedClient,
+		db:        db,
+		queries:   queries,
+		memory:    NewMemoryManager(db, queries, embedClient),
+		knowledge: NewKnowledgeRetriever(db, llm),
+		planner:   NewPlanner(),
+		prompt:    NewPromptBuilder(),
+		llm:       llm,
+		tools:     tools,
+		embed:     embedClient,
+		usage:     NewUsageTracker(queries),
+		events:    webhooks.NewEmitter(queries),
+		summaries: NewHistorySummarizer(queries, llm),
+		limiter:   NewRunLimiter(RunLimits{SessionQueueTimeout: defaultSessionQueueTimeout}),
+		runs:      newRunTracker(),
+		toolCache: NewToolResultCache(),
+		recovery: RunRecovery{
+			HeartbeatInterval: defaultRunHeartbeatInterval,
+			StaleAfter:        defaultRunStaleAfter,
+			RecoveryInterval:  defaultRunRecoveryInterval,
+			MaxResumeAttempts: defaultMaxResumeAttempts,
+		},
 	}
 }
 
+// SetRunLimits replaces the limits on the messages processed at once
+func (r *Runtime) SetRunLimits(limits RunLimits) {
+	r.limiter.SetLimits(limits)
+}
+
+// SetLLMLogFile sets the file LLM calls of agents with the "file" log sink
+// are written to
+func (r *Runtime) SetLLMLogFile(file *LLMLogFile) {
+	r.llmLogFile = file
+}
+
+// RegisterMemoryStore makes a memory backend available to agents
+func (r *Runtime) RegisterMemoryStore(backend string, store MemoryStore) {
+	r.memory.RegisterStore(backend, store)
+}
+
+// SearchMemory returns the topK memory chunks of the agent closest to query,
+// ranked as they are when a run loads its context
+func (r *Runtime) SearchMemory(ctx context.Context, agent *db.Agent, query string, topK int) ([]MemoryChunk, error) {
+	embedding, err := r.llm.Embed(ctx, MemoryEmbeddingModel(agent), query)
+	if err != nil {
+		return nil, fmt.Errorf("memory search failed: agent_id='%s', query_length=%d, error=%w",
+			agent.ID.String(), len(query), err)
+	}
+	return r.memory.Retrieve(ctx, agent, embedding, topK)
+}
+
 func (r *Runtime) Execute(ctx context.Context, sessionID uuid.UUID, userMessage string) (*ExecutionState, error) {
+	return r.ExecuteWithAttachments(ctx, sessionID, userMessage, nil)
+}
+
+// ExecuteWithAttachments runs the agent on a user message sent with files.
+// The text of the files is stored in the session's memory and their
+// summaries are given to the model with the message.
+func (r *Runtime) ExecuteWithAttachments(ctx context.Context, sessionID uuid.UUID, userMessage string, attachments []Attachment) (*ExecutionState, error) {
 	state := &ExecutionState{
 		SessionID:   sessionID,
 		UserMessage: userMessage,
 	}
 
+	// A draining server takes no new messages
+	ctx, execution, ok := r.runs.admit(ctx)
+	if !ok {
+		return nil, fmt.Errorf("agent execution refused at step 1 (admit message): session_id='%s', error=%w",
+			sessionID.String(), r.limiter.drainingError(sessionID))
+	}
+	defer execution.done()
+
 	// Step 1: Load agent and session
 	session, err := r.queries.GetSession(ctx, sessionID)
 	if err != nil {
@@ -93,118 +221,480 @@ func (r *Runtime) Execute(ctx context.Context, sessionID uuid.UUID, userMessage
 	}
 	state.AgentID = session.AgentID
 
+	// Messages to a session run one at a time, so each sees the history the
+	// one before it stored
+	releaseSession, err := r.limiter.AcquireSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionBusy) {
+			metrics.RecordRunRejected(session.AgentID.String(), "session_busy")
+		}
+		return nil, fmt.Errorf("agent execution refused at step 1 (wait for session): session_id='%s', error=%w",
+			sessionID.String(), err)
+	}
+	defer releaseSession()
+
+	// A session takes no new messages while a run is paused for approval
+	if open, err := r.openApproval(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (check tool approvals): session_id='%s', error=%w",
+			sessionID.String(), err)
+	} else if open != nil {
+		return nil, fmt.Errorf("agent execution refused at step 1: session_id='%s', approval_id='%s', approval_status='%s': %w",
+			sessionID.String(), open.ID.String(), open.Status, ErrApprovalPending)
+	}
+
+	// Nor while a run of another server, or one interrupted and not yet
+	// recovered, is still in progress
+	if active, err := r.activeSessionRun(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (check runs): session_id='%s', error=%w",
+			sessionID.String(), err)
+	} else if active != nil {
+		metrics.RecordRunRejected(session.AgentID.String(), "session_busy")
+		return nil, fmt.Errorf("agent execution refused at step 1: session_id='%s', run_id='%s', error=%w",
+			sessionID.String(), active.ID.String(), r.limiter.sessionBusy(sessionID))
+	}
+
 	agent, err := r.queries.GetAgentByID(ctx, session.AgentID)
 	if err != nil {
 		return nil, fmt.Errorf("agent execution failed at step 1 (load agent): session_id='%s', agent_id='%s', user_message_length=%d, error=%w",
 			sessionID.String(), session.AgentID.String(), len(userMessage), err)
 	}
 
-	// Step 2: Load context (recent messages + memory)
-	contextLoader := NewContextLoader(r.queries, r.memory, r.llm)
-	agentContext, err := contextLoader.Load(ctx, sessionID, agent.ID, userMessage, 20, 5)
+	// Agents using a prompt template serve the session the system prompt of
+	// its template version
+	agent, promptVersionID, err := r.applyPromptTemplate(ctx, agent, session)
 	if err != nil {
-		return nil, fmt.Errorf("agent execution failed at step 2 (load context): session_id='%s', agent_id='%s', agent_name='%s', user_message_length=%d, max_messages=20, max_memory_chunks=5, error=%w",
-			sessionID.String(), agent.ID.String(), agent.Name, len(userMessage), err)
+		return nil, fmt.Errorf("agent execution failed at step 1 (load prompt template): session_id='%s', agent_id='%s', error=%w",
+			sessionID.String(), session.AgentID.String(), err)
 	}
-	state.Context = agentContext
 
-	// Step 3: Build prompt
-	prompt, err := r.prompt.Build(agent, agentContext, userMessage)
+	guardrails, err := ParseGuardrailPolicy(agent.Config.ToMap())
 	if err != nil {
-		messageCount := len(agentContext.Messages)
-		memoryChunkCount := len(agentContext.MemoryChunks)
-		return nil, fmt.Errorf("agent execution failed at step 3 (build prompt): session_id='%s', agent_id='%s', agent_name='%s', user_message_length=%d, context_message_count=%d, context_memory_chunk_count=%d, error=%w",
-			sessionID.String(), agent.ID.String(), agent.Name, len(userMessage), messageCount, memoryChunkCount, err)
+		return nil, fmt.Errorf("agent execution failed at step 1 (load guardrails): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
 	}
 
-	// Step 4: Call LLM via NeuronDB
-	llmResponse, err := r.llm.Generate(ctx, agent.ModelName, prompt, agent.Config)
+	usagePolicy, err := ParseUsagePolicy(agent.Config.ToMap())
 	if err != nil {
-		promptTokens := EstimateTokens(prompt)
-		return nil, fmt.Errorf("agent execution failed at step 4 (LLM generation): session_id='%s', agent_id='%s', agent_name='%s', model_name='%s', prompt_length=%d, prompt_tokens=%d, user_message_length=%d, error=%w",
-			sessionID.String(), agent.ID.String(), agent.Name, agent.ModelName, len(prompt), promptTokens, len(userMessage), err)
+		return nil, fmt.Errorf("agent execution failed at step 1 (load usage policy): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
 	}
-	
-	// Update token count in response
-	if llmResponse.Usage.TotalTokens == 0 {
-		// Estimate if not provided
-		llmResponse.Usage.PromptTokens = EstimateTokens(prompt)
-		llmResponse.Usage.CompletionTokens = EstimateTokens(llmResponse.Content)
-		llmResponse.Usage.TotalTokens = llmResponse.Usage.PromptTokens + llmResponse.Usage.CompletionTokens
+
+	concurrency, err := ParseConcurrencyPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (load concurrency policy): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
+	releaseAgent, err := r.limiter.AcquireAgent(agent.ID, concurrency)
+	if err != nil {
+		metrics.RecordRunRejected(agent.ID.String(), "agent_limit")
+		return nil, fmt.Errorf("agent execution refused at step 1 (admit run): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
 	}
+	defer releaseAgent()
 
-	// Step 5: Parse tool calls from response
-	toolCalls, err := ParseToolCalls(llmResponse.Content)
-	if err == nil && len(toolCalls) > 0 {
-		llmResponse.ToolCalls = toolCalls
+	approvals, err := ParseApprovalPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (load tool approval policy): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
 	}
-	state.LLMResponse = llmResponse
 
-	// Step 6: Execute tools if any
-	if len(llmResponse.ToolCalls) > 0 {
-		state.ToolCalls = llmResponse.ToolCalls
+	cachePolicy, err := ParseSemanticCachePolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (load semantic cache policy): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
 
-		// Execute tools
-		toolResults, err := r.executeTools(ctx, agent, llmResponse.ToolCalls)
-		if err != nil {
-			toolNames := make([]string, len(llmResponse.ToolCalls))
-			for i, call := range llmResponse.ToolCalls {
-				toolNames[i] = call.Name
-			}
-			return nil, fmt.Errorf("agent execution failed at step 6 (tool execution): session_id='%s', agent_id='%s', agent_name='%s', tool_call_count=%d, tool_names=[%s], error=%w",
-				sessionID.String(), agent.ID.String(), agent.Name, len(llmResponse.ToolCalls), fmt.Sprintf("%v", toolNames), err)
+	knowledgeBases, err := ParseKnowledgeBases(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (load knowledge bases): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
+
+	summarization, err := ParseSummarizationPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (load summarization policy): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
+
+	toolCache, err := ParseToolCachePolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (load tool cache policy): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
+
+	toolConstraints, err := ParseToolConstraintPolicy(agent.Config.ToMap())
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (load tool constraints): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
+
+	// Input guardrails run before anything sees the message; a blocked
+	// message is answered with the refusal message without calling the LLM
+	userMessage, violations, blocked := guardrails.CheckInput(userMessage)
+	state.UserMessage = userMessage
+	r.recordViolations(state, violations)
+	if blocked {
+		state.FinalAnswer = guardrails.RefusalMessage
+		if _, err := r.storeMessages(ctx, state); err != nil {
+			return nil, fmt.Errorf("agent execution failed at step 1 (store blocked messages): session_id='%s', agent_id='%s', agent_name='%s', violation_count=%d, error=%w",
+				sessionID.String(), agent.ID.String(), agent.Name, len(state.GuardrailViolations), err)
 		}
-		state.ToolResults = toolResults
+		return state, nil
+	}
+	// Refusals are not the version's answers, so only answers past the input
+	// guardrails are attributed to it
+	state.PromptVersionID = promptVersionID
+
+	// Refuse the message before any model call once a monthly budget is used up
+	apiKey := auth.APIKeyFromContext(ctx)
+	if err := r.usage.CheckBudget(ctx, agent.ID, usagePolicy, apiKey); err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (check budget): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
 
-		// Step 7: Call LLM again with tool results
-		finalPrompt, err := r.prompt.BuildWithToolResults(agent, agentContext, userMessage, llmResponse, toolResults)
-		if err != nil {
-			return nil, fmt.Errorf("agent execution failed at step 7 (build final prompt): session_id='%s', agent_id='%s', agent_name='%s', tool_result_count=%d, error=%w",
-				sessionID.String(), agent.ID.String(), agent.Name, len(toolResults), err)
+	// Attachments are read and stored in memory before the context is
+	// loaded, so their chunks are among the memory it retrieves
+	if len(attachments) > 0 {
+		if err := r.ingestAttachments(ctx, agent, state, attachments); err != nil {
+			return nil, fmt.Errorf("agent execution failed at step 1 (ingest attachments): session_id='%s', agent_id='%s', agent_name='%s', attachment_count=%d, error=%w",
+				sessionID.String(), agent.ID.String(), agent.Name, len(attachments), err)
 		}
+	}
 
-		finalResponse, err := r.llm.Generate(ctx, agent.ModelName, finalPrompt, agent.Config)
-		if err != nil {
-			finalPromptTokens := EstimateTokens(finalPrompt)
-			return nil, fmt.Errorf("agent execution failed at step 7 (final LLM generation): session_id='%s', agent_id='%s', agent_name='%s', model_name='%s', final_prompt_length=%d, final_prompt_tokens=%d, tool_result_count=%d, error=%w",
-				sessionID.String(), agent.ID.String(), agent.Name, agent.ModelName, len(finalPrompt), finalPromptTokens, len(toolResults), err)
+	// From here on the run is checkpointed after each step, so it can be
+	// resumed if this server stops
+	run, err := r.startRun(ctx, state, apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("agent execution failed at step 1 (start run): session_id='%s', agent_id='%s', agent_name='%s', error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, err)
+	}
+	execution.attach(run)
+
+	// A message close enough to one the agent already answered gets the
+	// stored answer without calling the LLM. Answers to messages with
+	// attachments depend on the files, so they are neither served nor stored.
+	// Neither are answers of prompt template versions, whose feedback would
+	// otherwise be mixed up with that of the version that stored the answer,
+	// nor answers citing knowledge base passages retrieved for their message.
+	var cacheEmbedding []float32
+	if cachePolicy.Enabled && len(attachments) == 0 && state.PromptVersionID == nil && len(knowledgeBases) == 0 {
+		var match *db.SemanticCacheMatch
+		cacheEmbedding, match = r.lookupSemanticCache(ctx, agent, cachePolicy, userMessage)
+		if match != nil {
+			state.FinalAnswer = match.Response
+			state.CacheHit = &SemanticCacheHit{
+				EntryID:      match.ID,
+				Similarity:   match.Similarity,
+				TokensSaved:  match.TokensUsed,
+				CostSavedUSD: match.CostUSD,
+				CachedAt:     match.CreatedAt,
+			}
+			if err := r.complete(ctx, agent, guardrails, state, apiKey); err != nil {
+				run.fail(err)
+				return nil, r.interruptedError(run, err)
+			}
+			run.finish(ctx, state)
+			return state, nil
 		}
-		
-		// Update token counts
-		if finalResponse.Usage.TotalTokens == 0 {
-			finalResponse.Usage.PromptTokens = EstimateTokens(finalPrompt)
-			finalResponse.Usage.CompletionTokens = EstimateTokens(finalResponse.Content)
-			finalResponse.Usage.TotalTokens = finalResponse.Usage.PromptTokens + finalResponse.Usage.CompletionTokens
+	}
+
+	policies := &runPolicies{
+		guardrails:    guardrails,
+		usage:         usagePolicy,
+		approvals:     approvals,
+		summarization: summarization,
+		toolCache:     toolCache,
+		constraints:   toolConstraints,
+	}
+	if err := r.advance(ctx, run, agent, policies, state, apiKey); err != nil {
+		run.fail(err)
+		return nil, r.interruptedError(run, err)
+	}
+	if cacheEmbedding != nil && cachePolicy.cacheable(state) {
+		r.storeSemanticCache(agent, cachePolicy, state, cacheEmbedding)
+	}
+	return state, nil
+}
+
+// advance carries a run on from its last checkpointed step until its answer
+// is stored or it pauses for a tool approval, checkpointing every step
+func (r *Runtime) advance(ctx context.Context, run *activeRun, agent *db.Agent, policies *runPolicies, state *ExecutionState, apiKey *db.APIKey) error {
+	sessionID, userMessage := state.SessionID, state.UserMessage
+
+	// Step 2: Load context (recent messages + memory)
+	if state.Context == nil {
+		contextLoader := NewContextLoader(r.queries, r.memory, r.knowledge, r.llm)
+		agentContext, err := contextLoader.Load(ctx, sessionID, agent, userMessage, historyMessages, 5)
+		if err != nil {
+			return fmt.Errorf("agent execution failed at step 2 (load context): session_id='%s', agent_id='%s', agent_name='%s', user_message_length=%d, max_messages=%d, max_memory_chunks=5, error=%w",
+				sessionID.String(), agent.ID.String(), agent.Name, len(userMessage), historyMessages, err)
 		}
-		
-		state.FinalAnswer = finalResponse.Content
-		state.TokensUsed = llmResponse.Usage.TotalTokens + finalResponse.Usage.TotalTokens
-	} else {
-		state.FinalAnswer = llmResponse.Content
-		state.TokensUsed = llmResponse.Usage.TotalTokens
-		if state.TokensUsed == 0 {
-			// Estimate if not provided
-			state.TokensUsed = EstimateTokens(prompt) + EstimateTokens(state.FinalAnswer)
+		agentContext.Attachments = state.Attachments
+		state.Context = agentContext
+	}
+	agentContext := state.Context
+
+	for {
+		switch run.run.Step {
+		case runStepStarted:
+			// Step 3: Build prompt
+			prompt, err := r.prompt.Build(agent, agentContext, userMessage)
+			if err != nil {
+				messageCount := len(agentContext.Messages)
+				memoryChunkCount := len(agentContext.MemoryChunks)
+				return fmt.Errorf("agent execution failed at step 3 (build prompt): session_id='%s', agent_id='%s', agent_name='%s', user_message_length=%d, context_message_count=%d, context_memory_chunk_count=%d, error=%w",
+					sessionID.String(), agent.ID.String(), agent.Name, len(userMessage), messageCount, memoryChunkCount, err)
+			}
+
+			// Step 4: Call LLM via NeuronDB, summarizing the history if the
+			// prompt is too long for the model
+			llmResponse, prompt, err := r.generate(ctx, agent, policies.summarization, policies.usage, state, LLMCallAnswer, prompt, func() (string, error) {
+				return r.prompt.Build(agent, agentContext, userMessage)
+			})
+			if err != nil {
+				promptTokens := EstimateTokens(prompt)
+				return fmt.Errorf("agent execution failed at step 4 (LLM generation): session_id='%s', agent_id='%s', agent_name='%s', model_name='%s', prompt_length=%d, prompt_tokens=%d, user_message_length=%d, error=%w",
+					sessionID.String(), agent.ID.String(), agent.Name, agent.ModelName, len(prompt), promptTokens, len(userMessage), err)
+			}
+
+			// Update token count in response
+			if llmResponse.Usage.TotalTokens == 0 {
+				// Estimate if not provided
+				llmResponse.Usage.PromptTokens = EstimateTokens(prompt)
+				llmResponse.Usage.CompletionTokens = EstimateTokens(llmResponse.Content)
+				llmResponse.Usage.TotalTokens = llmResponse.Usage.PromptTokens + llmResponse.Usage.CompletionTokens
+			}
+			r.recordLLMCall(state, policies.usage, agent.ModelName, LLMCallAnswer, llmResponse)
+
+			// Step 5: Parse tool calls from response
+			toolCalls, err := ParseToolCalls(llmResponse.Content)
+			if err == nil && len(toolCalls) > 0 {
+				llmResponse.ToolCalls = toolCalls
+			}
+			state.LLMResponse = llmResponse
+
+			next := runStepAnswered
+			if len(llmResponse.ToolCalls) > 0 {
+				state.ToolCalls = llmResponse.ToolCalls
+				next = runStepGenerated
+			} else {
+				state.FinalAnswer = llmResponse.Content
+				state.TokensUsed = llmResponse.Usage.TotalTokens
+				if state.TokensUsed == 0 {
+					// Estimate if not provided
+					state.TokensUsed = EstimateTokens(prompt) + EstimateTokens(state.FinalAnswer)
+				}
+			}
+			if err := run.checkpoint(ctx, next, state); err != nil {
+				return fmt.Errorf("agent execution failed at step 5 (checkpoint run): session_id='%s', agent_id='%s', run_id='%s', error=%w",
+					sessionID.String(), agent.ID.String(), run.run.ID.String(), err)
+			}
+
+		case runStepGenerated:
+			// Step 6: Execute tools. A call needing approval pauses the run
+			// before any tool executes.
+			if calls, required := r.approvalToolCalls(agent, policies.approvals, state.ToolCalls); required {
+				if err := r.pauseForApproval(ctx, agent, policies.approvals, state, calls, apiKey); err != nil {
+					return fmt.Errorf("agent execution failed at step 6 (request tool approval): session_id='%s', agent_id='%s', agent_name='%s', tool_call_count=%d, error=%w",
+						sessionID.String(), agent.ID.String(), agent.Name, len(calls), err)
+				}
+				if err := run.pause(ctx, state.PendingApproval.ID); err != nil {
+					return fmt.Errorf("agent execution failed at step 6 (pause run): session_id='%s', agent_id='%s', run_id='%s', approval_id='%s', error=%w",
+						sessionID.String(), agent.ID.String(), run.run.ID.String(), state.PendingApproval.ID.String(), err)
+				}
+				return nil
+			}
+
+			toolResults, err := r.executeTools(ctx, agent, sessionID, policies.toolCache, policies.constraints, state.ToolCalls)
+			if err != nil {
+				toolNames := make([]string, len(state.ToolCalls))
+				for i, call := range state.ToolCalls {
+					toolNames[i] = call.Name
+				}
+				return fmt.Errorf("agent execution failed at step 6 (tool execution): session_id='%s', agent_id='%s', agent_name='%s', tool_call_count=%d, tool_names=[%s], error=%w",
+					sessionID.String(), agent.ID.String(), agent.Name, len(state.ToolCalls), fmt.Sprintf("%v", toolNames), err)
+			}
+			r.applyToolResults(state, policies.guardrails, toolResults)
+			if err := run.checkpoint(ctx, runStepToolsCompleted, state); err != nil {
+				return fmt.Errorf("agent execution failed at step 6 (checkpoint run): session_id='%s', agent_id='%s', run_id='%s', error=%w",
+					sessionID.String(), agent.ID.String(), run.run.ID.String(), err)
+			}
+
+		case runStepToolsCompleted:
+			// Step 7: Call LLM again with tool results
+			if err := r.answerWithToolResults(ctx, agent, agentContext, policies.usage, policies.summarization, state); err != nil {
+				return err
+			}
+			if err := run.checkpoint(ctx, runStepAnswered, state); err != nil {
+				return fmt.Errorf("agent execution failed at step 7 (checkpoint run): session_id='%s', agent_id='%s', run_id='%s', error=%w",
+					sessionID.String(), agent.ID.String(), run.run.ID.String(), err)
+			}
+
+		case runStepAnswered:
+			// Steps 8 and 9
+			if err := r.complete(ctx, agent, policies.guardrails, state, apiKey); err != nil {
+				return err
+			}
+			run.finish(ctx, state)
+			return nil
+
+		default:
+			return fmt.Errorf("agent execution failed: session_id='%s', run_id='%s', unknown run step '%s'",
+				sessionID.String(), run.run.ID.String(), run.run.Step)
 		}
 	}
+}
+
+// applyToolResults runs the tool result guardrails and sets the results on
+// the execution state
+func (r *Runtime) applyToolResults(state *ExecutionState, guardrails *GuardrailPolicy, toolResults []ToolResult) {
+	for i := range toolResults {
+		content, violations := guardrails.CheckToolResult(toolResults[i].ToolCallID, toolResults[i].Content)
+		toolResults[i].Content = content
+		r.recordViolations(state, violations)
+	}
+	state.ToolResults = toolResults
+}
+
+// answerWithToolResults calls the LLM again with the tool results of the
+// execution and sets its final answer
+func (r *Runtime) answerWithToolResults(ctx context.Context, agent *db.Agent, agentContext *Context, usagePolicy *UsagePolicy, summarization *SummarizationPolicy, state *ExecutionState) error {
+	sessionID, llmResponse, toolResults := state.SessionID, state.LLMResponse, state.ToolResults
+
+	finalPrompt, err := r.prompt.BuildWithToolResults(agent, agentContext, state.UserMessage, llmResponse, toolResults)
+	if err != nil {
+		return fmt.Errorf("agent execution failed at step 7 (build final prompt): session_id='%s', agent_id='%s', agent_name='%s', tool_result_count=%d, error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, len(toolResults), err)
+	}
+
+	finalResponse, finalPrompt, err := r.generate(ctx, agent, summarization, usagePolicy, state, LLMCallToolResults, finalPrompt, func() (string, error) {
+		return r.prompt.BuildWithToolResults(agent, agentContext, state.UserMessage, llmResponse, toolResults)
+	})
+	if err != nil {
+		finalPromptTokens := EstimateTokens(finalPrompt)
+		return fmt.Errorf("agent execution failed at step 7 (final LLM generation): session_id='%s', agent_id='%s', agent_name='%s', model_name='%s', final_prompt_length=%d, final_prompt_tokens=%d, tool_result_count=%d, error=%w",
+			sessionID.String(), agent.ID.String(), agent.Name, agent.ModelName, len(finalPrompt), finalPromptTokens, len(toolResults), err)
+	}
+	
+	// Update token counts
+	if finalResponse.Usage.TotalTokens == 0 {
+		finalResponse.Usage.PromptTokens = EstimateTokens(finalPrompt)
+		finalResponse.Usage.CompletionTokens = EstimateTokens(finalResponse.Content)
+		finalResponse.Usage.TotalTokens = finalResponse.Usage.PromptTokens + finalResponse.Usage.CompletionTokens
+	}
+	r.recordLLMCall(state, usagePolicy, agent.ModelName, LLMCallToolResults, finalResponse)
+	
+	state.FinalAnswer = finalResponse.Content
+	state.TokensUsed = llmResponse.Usage.TotalTokens + finalResponse.Usage.TotalTokens
+	return nil
+}
+
+// complete checks the final answer against the output guardrails, stores
+// the turn with its usage and hands the answer to memory
+func (r *Runtime) complete(ctx context.Context, agent *db.Agent, guardrails *GuardrailPolicy, state *ExecutionState, apiKey *db.APIKey) error {
+	sessionID, userMessage := state.SessionID, state.UserMessage
+
+	finalAnswer, violations := guardrails.CheckOutput(state.FinalAnswer)
+	state.FinalAnswer = finalAnswer
+	r.recordViolations(state, violations)
+	state.Sources = knowledgeSources(state.Context, state.FinalAnswer)
 
 	// Step 8: Store messages with token counts
-	if err := r.storeMessages(ctx, sessionID, userMessage, state.FinalAnswer, state.ToolCalls, state.ToolResults, state.TokensUsed); err != nil {
-		return nil, fmt.Errorf("agent execution failed at step 8 (store messages): session_id='%s', agent_id='%s', agent_name='%s', user_message_length=%d, final_answer_length=%d, tool_call_count=%d, tool_result_count=%d, total_tokens=%d, error=%w",
+	assistantMessageID, err := r.storeMessages(ctx, state)
+	if err != nil {
+		return fmt.Errorf("agent execution failed at step 8 (store messages): session_id='%s', agent_id='%s', agent_name='%s', user_message_length=%d, final_answer_length=%d, tool_call_count=%d, tool_result_count=%d, total_tokens=%d, error=%w",
 			sessionID.String(), agent.ID.String(), agent.Name, len(userMessage), len(state.FinalAnswer), len(state.ToolCalls), len(state.ToolResults), state.TokensUsed, err)
 	}
 
+	// The answer is already stored, so a ledger failure is logged rather than
+	// failing the request
+	if err := r.usage.Record(ctx, state, assistantMessageID, apiKey); err != nil {
+		metrics.Logger().Error().Err(err).
+			Str("session_id", sessionID.String()).
+			Str("agent_id", agent.ID.String()).
+			Int("total_tokens", state.Usage.TotalTokens).
+			Msg("Failed to record usage")
+	}
+
+	r.events.Emit(ctx, webhooks.EventMessageCompleted, map[string]interface{}{
+		"session_id":  sessionID,
+		"agent_id":    agent.ID,
+		"message_id":  assistantMessageID,
+		"response":    state.FinalAnswer,
+		"tokens_used": state.TokensUsed,
+		"usage":       state.Usage,
+		"cost_usd":    state.CostUSD,
+	})
+
 	// Step 9: Store memory chunks (async, non-blocking)
 	go func() {
 		bgCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
-		r.memory.StoreChunks(bgCtx, agent.ID, sessionID, state.FinalAnswer, state.ToolResults)
+		r.memory.StoreChunks(bgCtx, agent, sessionID, state.FinalAnswer, state.ToolResults)
 	}()
 
-	return state, nil
+	// Extend the session summary with messages that left the history window
+	if summarization, err := ParseSummarizationPolicy(agent.Config.ToMap()); err == nil && summarization.Enabled {
+		go func() {
+			bgCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+			if err := r.summaries.Extend(bgCtx, agent, sessionID, historyMessages, summarization.BatchMessages); err != nil {
+				metrics.Logger().Warn().Err(err).
+					Str("session_id", sessionID.String()).
+					Str("agent_id", agent.ID.String()).
+					Msg("Failed to extend session summary")
+			}
+		}()
+	}
+
+	return nil
+}
+
+// generate runs prompt on the agent's model. When no provider accepts it
+// because it exceeds the context length, the older half of the history is
+// folded into the session summary, build makes the prompt again and the call
+// is retried, at most policy.MaxRetries times. The prompt last sent is
+// returned with the response. Each call is logged for purpose when the
+// agent's llm_logging samples it.
+func (r *Runtime) generate(ctx context.Context, agent *db.Agent, policy *SummarizationPolicy, usagePolicy *UsagePolicy, state *ExecutionState, purpose, prompt string, build func() (string, error)) (*LLMResponse, string, error) {
+	var overflows []string
+	for attempt := 0; ; attempt++ {
+		started := time.Now()
+		resp, err := r.llm.Generate(ctx, agent.ModelName, prompt, agent.Config)
+		r.logLLMCall(agent, state, purpose, prompt, resp, err, time.Since(started))
+		if err == nil && len(overflows) > 0 {
+			resp.Retries = append(overflows, resp.Retries...)
+		}
+		if err == nil || !errors.Is(err, ErrContextLengthExceeded) || !policy.Enabled || attempt >= policy.MaxRetries {
+			return resp, prompt, err
+		}
+
+		calls, compacted, compactErr := r.summaries.Compact(ctx, agent, state.SessionID, state.Context)
+		for _, call := range calls {
+			r.recordLLMCall(state, usagePolicy, agent.ModelName, LLMCallSummarization, call)
+		}
+		if compactErr != nil {
+			return nil, prompt, fmt.Errorf("%w; %w", err, compactErr)
+		}
+		if !compacted {
+			return nil, prompt, err
+		}
+		overflows = append(overflows, fmt.Sprintf("prompt of %d tokens exceeded the context length; history summarized", EstimateTokens(prompt)))
+		metrics.Logger().Info().
+			Str("session_id", state.SessionID.String()).
+			Str("agent_id", agent.ID.String()).
+			Int("prompt_tokens", EstimateTokens(prompt)).
+			Int("summarized_messages", state.Context.Summary.MessageCount).
+			Int("attempt", attempt+1).
+			Msg("Prompt exceeded the context length; summarized session history")
+
+		if prompt, err = build(); err != nil {
+			return nil, prompt, err
+		}
+	}
 }
 
-func (r *Runtime) executeTools(ctx context.Context, agent *db.Agent, toolCalls []ToolCall) ([]ToolResult, error) {
+func (r *Runtime) executeTools(ctx context.Context, agent *db.Agent, sessionID uuid.UUID, cache *ToolCachePolicy, constraints *ToolConstraintPolicy, toolCalls []ToolCall) ([]ToolResult, error) {
 	results := make([]ToolResult, 0, len(toolCalls))
 
 	for _, call := range toolCalls {
@@ -233,8 +723,35 @@ func (r *Runtime) executeTools(ctx context.Context, agent *db.Agent, toolCalls [
 			continue
 		}
 
+		// Calls whose arguments break the agent's tool constraints do not
+		// run; the violations go back to the LLM so it can correct them
+		if violations := constraints.Check(tool, call.Arguments); len(violations) > 0 {
+			for _, v := range violations {
+				metrics.RecordToolConstraintViolation(agent.ID.String(), call.Name, v.Rule)
+			}
+			results = append(results, ToolResult{
+				ToolCallID:           call.ID,
+				Error:                &ToolConstraintError{ToolCallID: call.ID, Violations: violations},
+				ConstraintViolations: violations,
+			})
+			continue
+		}
+
+		// A repeated call within the session reuses its cached result
+		cacheKey, cached, hit, ok := r.lookupToolCache(agent, sessionID, cache, tool, call.Arguments)
+		if ok {
+			results = append(results, ToolResult{
+				ToolCallID: call.ID,
+				Content:    cached,
+				CacheHit:   hit,
+			})
+			continue
+		}
+
 		// Execute tool
+		started := time.Now()
 		result, err := r.tools.Execute(ctx, tool, call.Arguments)
+		duration := time.Since(started)
 		if err != nil {
 			argKeys := make([]string, 0, len(call.Arguments))
 			for k := range call.Arguments {
@@ -245,12 +762,17 @@ func (r *Runtime) executeTools(ctx context.Context, agent *db.Agent, toolCalls [
 				Content:    result,
 				Error:      fmt.Errorf("tool execution failed: tool_call_id='%s', tool_name='%s', handler_type='%s', agent_id='%s', agent_name='%s', args_count=%d, arg_keys=[%v], error=%w",
 					call.ID, call.Name, tool.HandlerType, agent.ID.String(), agent.Name, len(call.Arguments), argKeys, err),
+				Duration: duration,
 			})
 		} else {
+			if cacheKey != "" {
+				r.toolCache.Put(sessionID, cacheKey, result, cache)
+			}
 			results = append(results, ToolResult{
 				ToolCallID: call.ID,
 				Content:    result,
 				Error:      nil,
+				Duration:   duration,
 			})
 		}
 	}
@@ -258,65 +780,148 @@ func (r *Runtime) executeTools(ctx context.Context, agent *db.Agent, toolCalls [
 	return results, nil
 }
 
-func (r *Runtime) storeMessages(ctx context.Context, sessionID uuid.UUID, userMsg, assistantMsg string, toolCalls []ToolCall, toolResults []ToolResult, totalTokens int) error {
-	// Store user message
+// recordLLMCall adds the usage and estimated cost of an LLM call made for
+// purpose to the execution state
+func (r *Runtime) recordLLMCall(state *ExecutionState, policy *UsagePolicy, modelName, purpose string, resp *LLMResponse) {
+	model := resp.Model
+	if model == "" {
+		model = modelName
+	}
+	call := LLMCallUsage{
+		Model:       model,
+		Provider:    resp.Provider,
+		Usage:       resp.Usage,
+		CostUSD:     policy.Cost(model, resp.Usage),
+		Purpose:     purpose,
+		PromptChars: resp.PromptChars,
+		LatencyMs:   durationMs(resp.Latency),
+		Retries:     resp.Retries,
+	}
+	state.LLMCalls = append(state.LLMCalls, call)
+	state.Usage.PromptTokens += call.Usage.PromptTokens
+	state.Usage.CompletionTokens += call.Usage.CompletionTokens
+	state.Usage.TotalTokens += call.Usage.TotalTokens
+	state.CostUSD += call.CostUSD
+}
+
+// recordViolations adds guardrail violations to the execution state and metrics
+func (r *Runtime) recordViolations(state *ExecutionState, violations []GuardrailViolation) {
+	for _, v := range violations {
+		metrics.RecordGuardrailViolation(state.AgentID.String(), v.Stage, v.Rule, v.Action)
+	}
+	state.GuardrailViolations = append(state.GuardrailViolations, violations...)
+}
+
+// guardrailMetadata returns message metadata holding the violations matching
+// keep, or nil when there are none
+func guardrailMetadata(violations []GuardrailViolation, keep func(GuardrailViolation) bool) map[string]interface{} {
+	var matched []GuardrailViolation
+	for _, v := range violations {
+		if keep(v) {
+			matched = append(matched, v)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"guardrail_violations": matched}
+}
+
+// storeMessages stores the turn of an execution: the user message, its tool
+// calls and results and the final answer. The turn is written in one
+// transaction, so it is stored whole or not at all. It returns the ID of the
+// answer.
+func (r *Runtime) storeMessages(ctx context.Context, state *ExecutionState) (int64, error) {
+	sessionID, userMsg, assistantMsg := state.SessionID, state.UserMessage, state.FinalAnswer
+	toolCalls, toolResults, violations := state.ToolCalls, state.ToolResults, state.GuardrailViolations
+	messages := make([]db.Message, 0, len(toolCalls)+len(toolResults)+2)
+
+	// User message
 	userTokens := EstimateTokens(userMsg)
-	if _, err := r.queries.CreateMessage(ctx, &db.Message{
+	userMetadata := guardrailMetadata(violations, func(v GuardrailViolation) bool {
+		return v.Stage == GuardrailStageInput
+	})
+	userMetadata = mergeMetadata(userMetadata, attachmentMetadata(state.Attachments))
+	userMetadata = mergeMetadata(userMetadata, runMetadata(state.RunID))
+	messages = append(messages, db.Message{
 		SessionID:  sessionID,
 		Role:       "user",
 		Content:    userMsg,
 		TokenCount: &userTokens,
-	}); err != nil {
-		return fmt.Errorf("failed to store user message: session_id='%s', message_length=%d, token_count=%d, error=%w",
-			sessionID.String(), len(userMsg), userTokens, err)
-	}
+		Metadata:   userMetadata,
+	})
 
-	// Store tool calls as messages
+	// Tool calls as messages
 	for _, call := range toolCalls {
 		callJSON, _ := json.Marshal(call.Arguments)
 		toolCallID := call.ID
-		if _, err := r.queries.CreateMessage(ctx, &db.Message{
+		messages = append(messages, db.Message{
 			SessionID:  sessionID,
 			Role:       "assistant",
 			Content:    fmt.Sprintf("Tool call: %s with args: %s", call.Name, string(callJSON)),
 			ToolCallID: &toolCallID,
 			Metadata:   map[string]interface{}{"tool_call": call},
-		}); err != nil {
-			return fmt.Errorf("failed to store tool call message: session_id='%s', tool_call_id='%s', tool_name='%s', args_count=%d, error=%w",
-				sessionID.String(), call.ID, call.Name, len(call.Arguments), err)
-		}
+		})
 	}
 
-	// Store tool results
+	// Tool results
 	for _, result := range toolResults {
 		toolName := result.ToolCallID
 		toolCallID := result.ToolCallID
-		if _, err := r.queries.CreateMessage(ctx, &db.Message{
+		messages = append(messages, db.Message{
 			SessionID:  sessionID,
 			Role:       "tool",
 			Content:    result.Content,
 			ToolName:   &toolName,
 			ToolCallID: &toolCallID,
-		}); err != nil {
-			hasError := result.Error != nil
-			return fmt.Errorf("failed to store tool result message: session_id='%s', tool_call_id='%s', content_length=%d, has_error=%v, error=%w",
-				sessionID.String(), result.ToolCallID, len(result.Content), hasError, err)
-		}
+			Metadata: mergeMetadata(guardrailMetadata(violations, func(v GuardrailViolation) bool {
+				return v.Stage == GuardrailStageToolResult && v.ToolCallID == toolCallID
+			}), mergeMetadata(toolCacheMetadata(result.CacheHit), toolConstraintMetadata(result.ConstraintViolations))),
+		})
 	}
 
-	// Store assistant message
+	// Assistant message
 	assistantTokens := EstimateTokens(assistantMsg)
-	if _, err := r.queries.CreateMessage(ctx, &db.Message{
-		SessionID:  sessionID,
-		Role:       "assistant",
-		Content:    assistantMsg,
-		TokenCount: &assistantTokens,
-	}); err != nil {
-		return fmt.Errorf("failed to store assistant message: session_id='%s', message_length=%d, token_count=%d, error=%w",
-			sessionID.String(), len(assistantMsg), assistantTokens, err)
+	metadata := guardrailMetadata(violations, func(v GuardrailViolation) bool {
+		return v.Stage == GuardrailStageOutput
+	})
+	metadata = mergeMetadata(metadata, semanticCacheMetadata(state.CacheHit))
+	metadata = mergeMetadata(metadata, knowledgeMetadata(state.Sources))
+	metadata = mergeMetadata(metadata, runMetadata(state.RunID))
+	messages = append(messages, db.Message{
+		SessionID:       sessionID,
+		Role:            "assistant",
+		Content:         assistantMsg,
+		TokenCount:      &assistantTokens,
+		Metadata:        metadata,
+		PromptVersionID: state.PromptVersionID,
+	})
+
+	attachmentIDs := make([]uuid.UUID, len(state.Attachments))
+	for i := range state.Attachments {
+		attachmentIDs[i] = state.Attachments[i].ID
+	}
+	if err := r.queries.CreateTurnMessages(ctx, messages, attachmentIDs); err != nil {
+		return 0, fmt.Errorf("failed to store turn messages: session_id='%s', user_message_length=%d, tool_call_count=%d, tool_result_count=%d, attachment_count=%d, answer_length=%d, error=%w",
+			sessionID.String(), len(userMsg), len(toolCalls), len(toolResults), len(attachmentIDs), len(assistantMsg), err)
+	}
+	userID := messages[0].ID
+	for i := range state.Attachments {
+		state.Attachments[i].MessageID = &userID
 	}
 
-	return nil
+	return messages[len(messages)-1].ID, nil
+}
+
+// mergeMetadata adds the keys of extra to metadata, either of which may be nil
+func mergeMetadata(metadata, extra map[string]interface{}) map[string]interface{} {
+	for k, v := range extra {
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		metadata[k] = v
+	}
+	return metadata
 }
 
 // Helper function to check if a string is in an array
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// Semantic cache defaults
+const (
+	defaultSemanticCacheThreshold = 0.95
+	defaultSemanticCacheTTLHours  = 24
+	semanticCacheStoreTimeout     = 30 * time.Second
+)
+
+// SemanticCachePolicy serves stored answers to prompts close to ones the
+// agent already answered, skipping the LLM call. It is read from the
+// "semantic_cache" object of the agent config:
+//
+//	"semantic_cache": {
+//	  "enabled": true,
+//	  "similarity_threshold": 0.95,  // cosine similarity a prior prompt needs to be served (0-1)
+//	  "ttl_hours": 24,               // entries expire after this long; 0 keeps them until the agent changes
+//	  "include_tool_answers": false  // also cache answers that used tools
+//	}
+//
+// Entries are kept per agent version and model, so updating the agent
+// invalidates its cache. Answers that tripped a guardrail are never cached.
+type SemanticCachePolicy struct {
+	Enabled             bool          `json:"enabled"`
+	SimilarityThreshold float64       `json:"similarity_threshold"`
+	TTL                 time.Duration `json:"-"`
+	TTLHours            float64       `json:"ttl_hours"`
+	IncludeToolAnswers  bool          `json:"include_tool_answers"`
+}
+
+// SemanticCacheHit describes the cache entry that answered a message
+type SemanticCacheHit struct {
+	EntryID      int64     `json:"entry_id"`
+	Similarity   float64   `json:"similarity"`
+	TokensSaved  int       `json:"tokens_saved"`
+	CostSavedUSD float64   `json:"cost_saved_usd"`
+	CachedAt     time.Time `json:"cached_at"`
+}
+
+// ParseSemanticCachePolicy extracts the semantic cache policy from an agent
+// config. A missing "semantic_cache" key yields a disabled policy.
+func ParseSemanticCachePolicy(config map[string]interface{}) (*SemanticCachePolicy, error) {
+	policy := &SemanticCachePolicy{
+		SimilarityThreshold: defaultSemanticCacheThreshold,
+		TTLHours:            defaultSemanticCacheTTLHours,
+		TTL:                 defaultSemanticCacheTTLHours * time.Hour,
+	}
+	raw, ok := config["semantic_cache"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("semantic_cache must be an object, got %T", raw)
+	}
+
+	if v, ok := settings["enabled"]; ok {
+		enabled, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("semantic_cache.enabled must be a boolean")
+		}
+		policy.Enabled = enabled
+	}
+	if v, ok := settings["similarity_threshold"]; ok {
+		threshold, ok := v.(float64)
+		if !ok || threshold <= 0 || threshold > 1 {
+			return nil, fmt.Errorf("semantic_cache.similarity_threshold must be a number greater than 0 and at most 1")
+		}
+		policy.SimilarityThreshold = threshold
+	}
+	if v, ok := settings["ttl_hours"]; ok {
+		hours, ok := v.(float64)
+		if !ok || hours < 0 {
+			return nil, fmt.Errorf("semantic_cache.ttl_hours must be a non-negative number")
+		}
+		policy.TTLHours = hours
+		policy.TTL = time.Duration(hours * float64(time.Hour))
+	}
+	if v, ok := settings["include_tool_answers"]; ok {
+		include, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("semantic_cache.include_tool_answers must be a boolean")
+		}
+		policy.IncludeToolAnswers = include
+	}
+
+	return policy, nil
+}
+
+// lookupSemanticCache embeds the user message and returns it with the cache
+// entry answering it, if one is close enough. The cache only saves work, so
+// failures are logged and treated as misses; the embedding is still returned
+// when it was computed.
+func (r *Runtime) lookupSemanticCache(ctx context.Context, agent *db.Agent, policy *SemanticCachePolicy, userMessage string) ([]float32, *db.SemanticCacheMatch) {
+	agentID := agent.ID.String()
+	if !r.memory.Enabled() {
+		// Without embeddings there is nothing to match prompts by
+		return nil, nil
+	}
+	embedding, err := r.llm.Embed(ctx, memoryEmbeddingModel, userMessage)
+	if err != nil {
+		metrics.RecordSemanticCacheLookup(agentID, "error")
+		metrics.Logger().Warn().Err(err).
+			Str("agent_id", agentID).
+			Msg("Semantic cache lookup skipped: failed to embed message")
+		return nil, nil
+	}
+
+	match, err := r.queries.LookupSemanticCache(ctx, agent.ID, agent.Version, agent.ModelName, embedding)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			metrics.RecordSemanticCacheLookup(agentID, "miss")
+			return embedding, nil
+		}
+		metrics.RecordSemanticCacheLookup(agentID, "error")
+		metrics.Logger().Warn().Err(err).
+			Str("agent_id", agentID).
+			Msg("Semantic cache lookup failed")
+		return embedding, nil
+	}
+	if match.Similarity < policy.SimilarityThreshold {
+		metrics.RecordSemanticCacheLookup(agentID, "miss")
+		return embedding, nil
+	}
+
+	metrics.RecordSemanticCacheLookup(agentID, "hit")
+	if err := r.queries.RecordSemanticCacheHit(ctx, match.ID); err != nil {
+		metrics.Logger().Warn().Err(err).
+			Str("agent_id", agentID).
+			Int64("entry_id", match.ID).
+			Msg("Failed to record semantic cache hit")
+	}
+	return embedding, match
+}
+
+// cacheable reports whether the answer of a completed execution may be
+// stored in the semantic cache
+func (p *SemanticCachePolicy) cacheable(state *ExecutionState) bool {
+	if !p.Enabled || state.CacheHit != nil || state.FinalAnswer == "" {
+		return false
+	}
+	if len(state.GuardrailViolations) > 0 {
+		return false
+	}
+	return len(state.ToolCalls) == 0 || p.IncludeToolAnswers
+}
+
+// storeSemanticCache stores the answer of an execution under the embedding
+// of its user message, and purges the agent's stale entries. It runs in the
+// background after the answer is returned, so failures are only logged.
+func (r *Runtime) storeSemanticCache(agent *db.Agent, policy *SemanticCachePolicy, state *ExecutionState, embedding []float32) {
+	entry := &db.SemanticCacheEntry{
+		AgentID:         agent.ID,
+		AgentVersion:    agent.Version,
+		ModelName:       agent.ModelName,
+		Prompt:          state.UserMessage,
+		PromptEmbedding: embedding,
+		Response:        state.FinalAnswer,
+		TokensUsed:      state.TokensUsed,
+		CostUSD:         state.CostUSD,
+	}
+	if policy.TTL > 0 {
+		expiresAt := time.Now().Add(policy.TTL)
+		entry.ExpiresAt = &expiresAt
+	}
+
+	go func() {
+		bgCtx, cancel := context.WithTimeout(context.Background(), semanticCacheStoreTimeout)
+		defer cancel()
+		if err := r.queries.CreateSemanticCacheEntry(bgCtx, entry); err != nil {
+			metrics.Logger().Error().Err(err).
+				Str("agent_id", agent.ID.String()).
+				Msg("Failed to store semantic cache entry")
+			return
+		}
+		if _, err := r.queries.PurgeSemanticCache(bgCtx, agent.ID, agent.Version); err != nil {
+			metrics.Logger().Warn().Err(err).
+				Str("agent_id", agent.ID.String()).
+				Msg("Failed to purge stale semantic cache entries")
+		}
+	}()
+}
+
+// semanticCacheMetadata returns assistant message metadata flagging an
+// answer served from the semantic cache, or nil for other answers
+func semanticCacheMetadata(hit *SemanticCacheHit) map[string]interface{} {
+	if hit == nil {
+		return nil
+	}
+	return map[string]interface{}{"semantic_cache": hit}
+}
@@ -0,0 +1,306 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// ErrContextLengthExceeded is matched by generation errors of prompts longer
+// than the context length of every provider tried
+var ErrContextLengthExceeded = errors.New("prompt exceeds the model context length")
+
+// contextLengthErrorMarkers are fragments of the errors providers return for
+// prompts longer than their context length
+var contextLengthErrorMarkers = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context length exceeded",
+	"exceeds the context window",
+	"context window exceeded",
+	"prompt is too long",
+	"input is too long",
+	"too many tokens",
+	"reduce the length of the messages",
+}
+
+// isContextLengthError reports whether a provider error says the prompt
+// was too long for the model
+func isContextLengthError(err error) bool {
+	if errors.Is(err, ErrContextLengthExceeded) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, marker := range contextLengthErrorMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarization defaults
+const (
+	defaultSummarizationRetries = 2
+	defaultSummarizationBatch   = 10
+	// summaryBatchTokens bounds the messages summarized by one LLM call
+	summaryBatchTokens = 3000
+	// summaryMessageChars bounds the text of one message given to the
+	// summarizer; the rest is cut
+	summaryMessageChars = 4000
+)
+
+// SummarizationPolicy controls the rolling summary of session history. It
+// is read from the "summarization" object of the agent config:
+//
+//	"summarization": {
+//	  "enabled": true,     // summarize history when a prompt exceeds the context length
+//	  "max_retries": 2,    // times one LLM call is retried after summarizing
+//	  "batch_messages": 10 // messages past the history window that extend an existing summary
+//	}
+//
+// When no provider accepts a prompt because it is too long, the older half
+// of the history in the prompt is folded into the session's summary, which
+// stands in for those messages from then on, and the call is retried. Once a
+// session has a summary, messages leaving the history window are folded into
+// it in batches after each run, so the summary keeps covering everything
+// before the window.
+type SummarizationPolicy struct {
+	Enabled       bool `json:"enabled"`
+	MaxRetries    int  `json:"max_retries"`
+	BatchMessages int  `json:"batch_messages"`
+}
+
+// ParseSummarizationPolicy extracts the summarization policy from an agent
+// config. A missing "summarization" key yields the enabled defaults.
+func ParseSummarizationPolicy(config map[string]interface{}) (*SummarizationPolicy, error) {
+	policy := &SummarizationPolicy{
+		Enabled:       true,
+		MaxRetries:    defaultSummarizationRetries,
+		BatchMessages: defaultSummarizationBatch,
+	}
+	raw, ok := config["summarization"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("summarization must be an object, got %T", raw)
+	}
+
+	if v, ok := settings["enabled"]; ok {
+		enabled, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("summarization.enabled must be a boolean")
+		}
+		policy.Enabled = enabled
+	}
+	if v, ok := settings["max_retries"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n > 5 || n != float64(int(n)) {
+			return nil, fmt.Errorf("summarization.max_retries must be an integer between 0 and 5")
+		}
+		policy.MaxRetries = int(n)
+	}
+	if v, ok := settings["batch_messages"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return nil, fmt.Errorf("summarization.batch_messages must be a positive integer")
+		}
+		policy.BatchMessages = int(n)
+	}
+	return policy, nil
+}
+
+// HistorySummarizer maintains the rolling summaries of sessions
+type HistorySummarizer struct {
+	queries *db.Queries
+	llm     *LLMClient
+}
+
+func NewHistorySummarizer(queries *db.Queries, llm *LLMClient) *HistorySummarizer {
+	return &HistorySummarizer{queries: queries, llm: llm}
+}
+
+// Compact folds the older half of the history in agentContext into the
+// session summary and removes it from the context. It reports false when
+// the context has no history left to fold. The LLM calls made are returned
+// so their usage can be recorded.
+func (s *HistorySummarizer) Compact(ctx context.Context, agent *db.Agent, sessionID uuid.UUID, agentContext *Context) ([]*LLMResponse, bool, error) {
+	if len(agentContext.Messages) == 0 {
+		return nil, false, nil
+	}
+	history := append([]db.Message(nil), agentContext.Messages...)
+	sort.Slice(history, func(i, j int) bool { return history[i].ID < history[j].ID })
+	folded := history[:(len(history)+1)/2]
+	throughID := folded[len(folded)-1].ID
+
+	// A first summary starts at the history window; messages before it were
+	// already out of the prompt. An existing summary is extended from where it
+	// ends, so it covers the session without gaps.
+	afterID := folded[0].ID - 1
+	if agentContext.Summary != nil {
+		afterID = agentContext.Summary.ThroughMessageID
+	}
+	messages, err := s.queries.GetMessagesRange(ctx, sessionID, afterID, throughID)
+	if err != nil {
+		return nil, false, fmt.Errorf("history summarization failed (load messages): session_id='%s', after_message_id=%d, through_message_id=%d, error=%w",
+			sessionID.String(), afterID, throughID, err)
+	}
+	if len(messages) == 0 {
+		messages = folded
+	}
+
+	summary, calls, err := s.fold(ctx, agent, sessionID, agentContext.Summary, messages, summaryPrompt)
+	if err != nil {
+		return calls, false, err
+	}
+	if summary, err = s.store(ctx, summary); err != nil {
+		return calls, false, err
+	}
+
+	kept := agentContext.Messages[:0:0]
+	for _, msg := range agentContext.Messages {
+		if msg.ID > summary.ThroughMessageID {
+			kept = append(kept, msg)
+		}
+	}
+	agentContext.Messages = kept
+	agentContext.Summary = summary
+	return calls, true, nil
+}
+
+// Extend folds the messages of a summarized session that have left the
+// history window of window messages into its summary, once there are at
+// least batch of them. Sessions without a summary are left alone.
+func (s *HistorySummarizer) Extend(ctx context.Context, agent *db.Agent, sessionID uuid.UUID, window, batch int) error {
+	current, err := s.queries.GetSessionSummary(ctx, sessionID)
+	if err != nil || current == nil {
+		return err
+	}
+	unsummarized, err := s.queries.CountMessagesAfter(ctx, sessionID, current.ThroughMessageID)
+	if err != nil {
+		return err
+	}
+	outside := unsummarized - window
+	if outside < batch {
+		return nil
+	}
+	messages, err := s.queries.GetOldestMessagesAfter(ctx, sessionID, current.ThroughMessageID, outside)
+	if err != nil {
+		return err
+	}
+	summary, _, err := s.fold(ctx, agent, sessionID, current, messages, summaryPrompt)
+	if err != nil {
+		return err
+	}
+	_, err = s.store(ctx, summary)
+	return err
+}
+
+// fold returns previous, or a new summary, extended with messages. Messages
+// are summarized in batches small enough for one LLM call each, and prompt
+// builds the call extending the summary with a batch.
+func (s *HistorySummarizer) fold(ctx context.Context, agent *db.Agent, sessionID uuid.UUID, previous *db.SessionSummary, messages []db.Message,
+	prompt func(summary string, messages []db.Message) string) (*db.SessionSummary, []*LLMResponse, error) {
+	summary := &db.SessionSummary{SessionID: sessionID}
+	if previous != nil {
+		summary.Summary = previous.Summary
+		summary.ThroughMessageID = previous.ThroughMessageID
+		summary.MessageCount = previous.MessageCount
+	}
+
+	var calls []*LLMResponse
+	for start := 0; start < len(messages); {
+		end, tokens := start, 0
+		for end < len(messages) {
+			msgTokens := EstimateTokens(summarizedContent(messages[end]))
+			if end > start && tokens+msgTokens > summaryBatchTokens {
+				break
+			}
+			tokens += msgTokens
+			end++
+		}
+		batch := messages[start:end]
+
+		resp, err := s.llm.Generate(ctx, agent.ModelName, prompt(summary.Summary, batch), agent.Config)
+		if err != nil {
+			return nil, calls, fmt.Errorf("history summarization failed (LLM generation): session_id='%s', message_count=%d, error=%w",
+				sessionID.String(), len(batch), err)
+		}
+		calls = append(calls, resp)
+		text := strings.TrimSpace(resp.Content)
+		if text == "" {
+			return nil, calls, fmt.Errorf("history summarization failed: session_id='%s', message_count=%d, the model returned an empty summary",
+				sessionID.String(), len(batch))
+		}
+
+		summary.Summary = text
+		summary.ThroughMessageID = batch[len(batch)-1].ID
+		summary.MessageCount += len(batch)
+		start = end
+	}
+	return summary, calls, nil
+}
+
+// store saves a summary and returns the summary the session now has, which
+// is a newer one when another run extended the summary meanwhile
+func (s *HistorySummarizer) store(ctx context.Context, summary *db.SessionSummary) (*db.SessionSummary, error) {
+	stored, err := s.queries.UpsertSessionSummary(ctx, summary)
+	if err != nil {
+		return nil, fmt.Errorf("history summarization failed (store summary): session_id='%s', through_message_id=%d, error=%w",
+			summary.SessionID.String(), summary.ThroughMessageID, err)
+	}
+	if stored {
+		return summary, nil
+	}
+	current, err := s.queries.GetSessionSummary(ctx, summary.SessionID)
+	if err != nil || current == nil {
+		return summary, err
+	}
+	return current, nil
+}
+
+// summaryPrompt asks the model to extend a conversation summary with
+// messages
+func summaryPrompt(summary string, messages []db.Message) string {
+	return extendSummaryPrompt("You maintain a running summary of a conversation between a user and an assistant. "+
+		"Update the summary with the new messages below. Keep facts, names, figures, decisions, "+
+		"open questions and the user's goals and preferences; leave out pleasantries. "+
+		"Write at most 300 words of plain prose and reply with the summary only.", summary, messages)
+}
+
+// extendSummaryPrompt gives the model instructions followed by the current
+// summary and the messages to add to it
+func extendSummaryPrompt(instructions, summary string, messages []db.Message) string {
+	var b strings.Builder
+	b.WriteString(instructions)
+	if summary != "" {
+		b.WriteString("\n\n## Current Summary:\n")
+		b.WriteString(summary)
+	}
+	b.WriteString("\n\n## New Messages:")
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "\n%s: %s", strings.Title(msg.Role), summarizedContent(msg))
+	}
+	b.WriteString("\n\nUpdated summary:")
+	return b.String()
+}
+
+// summarizedContent returns the text of a message given to the summarizer
+func summarizedContent(msg db.Message) string {
+	if len(msg.Content) <= summaryMessageChars {
+		return msg.Content
+	}
+	cut := summaryMessageChars
+	for cut > 0 && !utf8.RuneStart(msg.Content[cut]) {
+		cut--
+	}
+	return msg.Content[:cut] + " [...]"
+}
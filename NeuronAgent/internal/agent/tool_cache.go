@@ -0,0 +1,265 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// Tool result cache defaults
+const (
+	defaultToolCacheTTLSeconds    = 300
+	defaultToolCacheMaxEntries    = 64
+	defaultToolCacheMaxEntryBytes = 256 * 1024
+	// maxToolCacheSessions bounds the sessions cached at once; the least
+	// recently used session is dropped to make room for another
+	maxToolCacheSessions = 1000
+)
+
+// ToolCachePolicy reuses the results of tool calls repeated with the same
+// arguments within a session. It is read from the "tool_cache" object of
+// the agent config:
+//
+//	"tool_cache": {
+//	  "enabled": true,
+//	  "ttl_seconds": 300,          // results expire after this long
+//	  "max_entries": 64,           // results kept per session; the least recently used is dropped
+//	  "max_entry_bytes": 262144,   // larger results are not cached
+//	  "tools": {"get_time": false} // per-tool switch; false never caches the tool
+//	}
+//
+// Only successful results are cached. Updating a tool invalidates its
+// cached results.
+type ToolCachePolicy struct {
+	Enabled       bool
+	TTL           time.Duration
+	MaxEntries    int
+	MaxEntryBytes int
+	Tools         map[string]bool
+}
+
+// ToolCacheHit describes the cached result that answered a tool call
+type ToolCacheHit struct {
+	CachedAt   time.Time `json:"cached_at"`
+	AgeSeconds float64   `json:"age_seconds"`
+}
+
+// ParseToolCachePolicy extracts the tool cache policy from an agent
+// config. A missing "tool_cache" key yields a disabled policy.
+func ParseToolCachePolicy(config map[string]interface{}) (*ToolCachePolicy, error) {
+	policy := &ToolCachePolicy{
+		TTL:           defaultToolCacheTTLSeconds * time.Second,
+		MaxEntries:    defaultToolCacheMaxEntries,
+		MaxEntryBytes: defaultToolCacheMaxEntryBytes,
+		Tools:         map[string]bool{},
+	}
+	raw, ok := config["tool_cache"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tool_cache must be an object, got %T", raw)
+	}
+
+	if v, ok := settings["enabled"]; ok {
+		enabled, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("tool_cache.enabled must be a boolean")
+		}
+		policy.Enabled = enabled
+	}
+	if v, ok := settings["ttl_seconds"]; ok {
+		seconds, ok := v.(float64)
+		if !ok || seconds <= 0 {
+			return nil, fmt.Errorf("tool_cache.ttl_seconds must be a positive number")
+		}
+		policy.TTL = time.Duration(seconds * float64(time.Second))
+	}
+	if v, ok := settings["max_entries"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n > 10000 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("tool_cache.max_entries must be an integer between 1 and 10000")
+		}
+		policy.MaxEntries = int(n)
+	}
+	if v, ok := settings["max_entry_bytes"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("tool_cache.max_entry_bytes must be a positive integer")
+		}
+		policy.MaxEntryBytes = int(n)
+	}
+	if v, ok := settings["tools"]; ok && v != nil {
+		tools, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("tool_cache.tools must be an object of tool names to booleans")
+		}
+		for name, v := range tools {
+			cacheable, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("tool_cache.tools.%s must be a boolean", name)
+			}
+			policy.Tools[name] = cacheable
+		}
+	}
+	return policy, nil
+}
+
+// caches reports whether results of the tool are cached
+func (p *ToolCachePolicy) caches(tool string) bool {
+	if p == nil || !p.Enabled {
+		return false
+	}
+	cacheable, ok := p.Tools[tool]
+	return !ok || cacheable
+}
+
+// ToolResultCache holds recent tool results of each session in memory
+type ToolResultCache struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*sessionToolCache
+}
+
+// sessionToolCache is the cached results of one session
+type sessionToolCache struct {
+	entries  map[string]*toolCacheEntry
+	lastUsed time.Time
+}
+
+type toolCacheEntry struct {
+	content  string
+	cachedAt time.Time
+	lastUsed time.Time
+}
+
+// NewToolResultCache creates an empty tool result cache
+func NewToolResultCache() *ToolResultCache {
+	return &ToolResultCache{
+		sessions: make(map[uuid.UUID]*sessionToolCache),
+	}
+}
+
+// toolCacheKey identifies a call of a tool version with its arguments.
+// Arguments are marshalled with sorted keys, so their order does not matter.
+func toolCacheKey(tool *db.Tool, args map[string]interface{}) (string, error) {
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", tool.Name, tool.Version, encoded)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get returns the cached result of a call within the policy's TTL
+func (c *ToolResultCache) Get(sessionID uuid.UUID, key string, policy *ToolCachePolicy) (string, *ToolCacheHit, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session, ok := c.sessions[sessionID]
+	if !ok {
+		return "", nil, false
+	}
+	entry, ok := session.entries[key]
+	if !ok {
+		return "", nil, false
+	}
+	now := time.Now()
+	age := now.Sub(entry.cachedAt)
+	if age > policy.TTL {
+		delete(session.entries, key)
+		if len(session.entries) == 0 {
+			delete(c.sessions, sessionID)
+		}
+		return "", nil, false
+	}
+	entry.lastUsed = now
+	session.lastUsed = now
+	return entry.content, &ToolCacheHit{CachedAt: entry.cachedAt, AgeSeconds: math.Round(age.Seconds()*1000) / 1000}, true
+}
+
+// Put caches the result of a call, dropping expired and least recently
+// used entries of the session to keep within MaxEntries
+func (c *ToolResultCache) Put(sessionID uuid.UUID, key, content string, policy *ToolCachePolicy) {
+	if len(content) > policy.MaxEntryBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	session, ok := c.sessions[sessionID]
+	if !ok {
+		if len(c.sessions) >= maxToolCacheSessions {
+			c.evictSession()
+		}
+		session = &sessionToolCache{entries: make(map[string]*toolCacheEntry)}
+		c.sessions[sessionID] = session
+	}
+	session.lastUsed = now
+	session.entries[key] = &toolCacheEntry{content: content, cachedAt: now, lastUsed: now}
+
+	for k, entry := range session.entries {
+		if now.Sub(entry.cachedAt) > policy.TTL {
+			delete(session.entries, k)
+		}
+	}
+	for len(session.entries) > policy.MaxEntries {
+		oldest := ""
+		for k, entry := range session.entries {
+			if oldest == "" || entry.lastUsed.Before(session.entries[oldest].lastUsed) {
+				oldest = k
+			}
+		}
+		delete(session.entries, oldest)
+	}
+}
+
+// evictSession drops the least recently used session
+func (c *ToolResultCache) evictSession() {
+	var oldest uuid.UUID
+	var oldestUsed time.Time
+	for id, session := range c.sessions {
+		if oldestUsed.IsZero() || session.lastUsed.Before(oldestUsed) {
+			oldest, oldestUsed = id, session.lastUsed
+		}
+	}
+	delete(c.sessions, oldest)
+}
+
+// lookupToolCache returns the cached result of a call when the policy
+// caches the tool
+func (r *Runtime) lookupToolCache(agent *db.Agent, sessionID uuid.UUID, policy *ToolCachePolicy, tool *db.Tool, args map[string]interface{}) (string, string, *ToolCacheHit, bool) {
+	if !policy.caches(tool.Name) {
+		return "", "", nil, false
+	}
+	key, err := toolCacheKey(tool, args)
+	if err != nil {
+		// Arguments that cannot be encoded are never cached
+		return "", "", nil, false
+	}
+	content, hit, ok := r.toolCache.Get(sessionID, key, policy)
+	outcome := "miss"
+	if ok {
+		outcome = "hit"
+	}
+	metrics.RecordToolCacheLookup(agent.ID.String(), tool.Name, outcome)
+	return key, content, hit, ok
+}
+
+// toolCacheMetadata returns tool message metadata flagging a result served
+// from the tool cache, or nil for other results
+func toolCacheMetadata(hit *ToolCacheHit) map[string]interface{} {
+	if hit == nil {
+		return nil
+	}
+	return map[string]interface{}{"tool_cache": hit}
+}
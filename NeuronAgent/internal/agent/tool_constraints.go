@@ -0,0 +1,548 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// Tool argument constraint rules
+const (
+	constraintMin            = "min"
+	constraintMax            = "max"
+	constraintMaxLength      = "max_length"
+	constraintEnum           = "enum"
+	constraintPattern        = "pattern"
+	constraintAllowedDomains = "allowed_domains"
+	constraintAllowedSchemas = "allowed_schemas"
+)
+
+// ToolConstraintPolicy limits the arguments the LLM may pass to tools. It
+// is read from the "tool_constraints" array of the agent config:
+//
+//	"tool_constraints": [
+//	  {
+//	    "tools": ["sql", "report_*"],    // tool names; path.Match patterns
+//	    "handler_types": ["sql"],        // every tool with these handlers
+//	    "arguments": {
+//	      "query": {"allowed_schemas": ["public", "reporting"]},
+//	      "limit": {"min": 1, "max": 100}
+//	    }
+//	  },
+//	  {
+//	    "handler_types": ["http"],
+//	    "arguments": {"url": {"allowed_domains": ["api.example.com", "*.example.org"]}}
+//	  }
+//	]
+//
+// Every rule matching a tool applies. A call whose arguments break a
+// constraint does not run; its result is an error listing the violations,
+// so the LLM can correct the arguments.
+type ToolConstraintPolicy struct {
+	Rules []ToolConstraintRule
+}
+
+// ToolConstraintRule constrains the arguments of the tools it matches
+type ToolConstraintRule struct {
+	Tools        []string
+	HandlerTypes []string
+	Arguments    map[string]ArgumentConstraint
+}
+
+// ArgumentConstraint constrains one top-level argument. Constraints apply
+// only to arguments the call passes.
+type ArgumentConstraint struct {
+	Min       *float64
+	Max       *float64
+	MaxLength int
+	Enum      []interface{}
+	Pattern   *regexp.Regexp
+	// AllowedDomains are hosts an http(s) URL may point to; "*.example.org"
+	// matches the subdomains of example.org
+	AllowedDomains []string
+	// AllowedSchemas are the schemas an SQL query may read from.
+	// Unqualified tables are taken to be in public.
+	AllowedSchemas []string
+}
+
+// ToolConstraintViolation is an argument of a tool call breaking a
+// constraint
+type ToolConstraintViolation struct {
+	Tool     string      `json:"tool"`
+	Argument string      `json:"argument"`
+	Rule     string      `json:"rule"`
+	Message  string      `json:"message"`
+	Allowed  interface{} `json:"allowed,omitempty"`
+}
+
+// ToolConstraintError is the error of a tool call refused for its
+// arguments. Its message carries the violations as JSON for the LLM.
+type ToolConstraintError struct {
+	ToolCallID string
+	Violations []ToolConstraintViolation
+}
+
+func (e *ToolConstraintError) Error() string {
+	violations, _ := json.Marshal(e.Violations)
+	return fmt.Sprintf("tool call refused: tool_call_id='%s', arguments violate the agent's tool constraints; fix them and call the tool again: violations=%s",
+		e.ToolCallID, violations)
+}
+
+// toolConstraintMetadata returns tool result message metadata holding the
+// violations of a refused call, or nil when there are none
+func toolConstraintMetadata(violations []ToolConstraintViolation) map[string]interface{} {
+	if len(violations) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"tool_constraint_violations": violations}
+}
+
+// Enabled reports whether any tool is constrained
+func (p *ToolConstraintPolicy) Enabled() bool {
+	return len(p.Rules) > 0
+}
+
+// ParseToolConstraintPolicy extracts the tool constraint policy from an
+// agent config. A missing "tool_constraints" key yields a policy
+// constraining nothing.
+func ParseToolConstraintPolicy(config map[string]interface{}) (*ToolConstraintPolicy, error) {
+	policy := &ToolConstraintPolicy{}
+	raw, ok := config["tool_constraints"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tool_constraints must be an array, got %T", raw)
+	}
+
+	for i, item := range items {
+		settings, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("tool_constraints[%d] must be an object", i)
+		}
+		rule := ToolConstraintRule{Arguments: map[string]ArgumentConstraint{}}
+		for key, dest := range map[string]*[]string{"tools": &rule.Tools, "handler_types": &rule.HandlerTypes} {
+			values, err := constraintStrings(settings[key], fmt.Sprintf("tool_constraints[%d].%s", i, key))
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range values {
+				if _, err := path.Match(v, ""); key == "tools" && err != nil {
+					return nil, fmt.Errorf("tool_constraints[%d].tools pattern '%s' is invalid: %w", i, v, err)
+				}
+			}
+			*dest = values
+		}
+		if len(rule.Tools) == 0 && len(rule.HandlerTypes) == 0 {
+			return nil, fmt.Errorf("tool_constraints[%d] must list tools or handler_types", i)
+		}
+
+		arguments, ok := settings["arguments"].(map[string]interface{})
+		if !ok || len(arguments) == 0 {
+			return nil, fmt.Errorf("tool_constraints[%d].arguments must be a non-empty object", i)
+		}
+		for name, v := range arguments {
+			field := fmt.Sprintf("tool_constraints[%d].arguments.%s", i, name)
+			constraint, err := parseArgumentConstraint(v, field)
+			if err != nil {
+				return nil, err
+			}
+			rule.Arguments[name] = constraint
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	return policy, nil
+}
+
+func parseArgumentConstraint(raw interface{}, field string) (ArgumentConstraint, error) {
+	var c ArgumentConstraint
+	settings, ok := raw.(map[string]interface{})
+	if !ok || len(settings) == 0 {
+		return c, fmt.Errorf("%s must be a non-empty object", field)
+	}
+	for key, v := range settings {
+		var err error
+		switch key {
+		case constraintMin, constraintMax:
+			n, ok := v.(float64)
+			if !ok {
+				return c, fmt.Errorf("%s.%s must be a number", field, key)
+			}
+			if key == constraintMin {
+				c.Min = &n
+			} else {
+				c.Max = &n
+			}
+		case constraintMaxLength:
+			n, ok := v.(float64)
+			if !ok || n < 1 || n != float64(int(n)) {
+				return c, fmt.Errorf("%s.max_length must be a positive integer", field)
+			}
+			c.MaxLength = int(n)
+		case constraintEnum:
+			values, ok := v.([]interface{})
+			if !ok || len(values) == 0 {
+				return c, fmt.Errorf("%s.enum must be a non-empty array", field)
+			}
+			for i, value := range values {
+				switch value.(type) {
+				case string, float64, bool:
+				default:
+					return c, fmt.Errorf("%s.enum[%d] must be a string, number or boolean", field, i)
+				}
+			}
+			c.Enum = values
+		case constraintPattern:
+			pattern, ok := v.(string)
+			if !ok || pattern == "" {
+				return c, fmt.Errorf("%s.pattern must be a non-empty string", field)
+			}
+			if c.Pattern, err = regexp.Compile(pattern); err != nil {
+				return c, fmt.Errorf("%s.pattern '%s' is invalid: %w", field, pattern, err)
+			}
+		case constraintAllowedDomains:
+			if c.AllowedDomains, err = constraintStrings(v, field+"."+key); err != nil {
+				return c, err
+			}
+			for i, domain := range c.AllowedDomains {
+				c.AllowedDomains[i] = strings.ToLower(domain)
+			}
+		case constraintAllowedSchemas:
+			if c.AllowedSchemas, err = constraintStrings(v, field+"."+key); err != nil {
+				return c, err
+			}
+		default:
+			return c, fmt.Errorf("%s has unknown constraint '%s' (supported: min, max, max_length, enum, pattern, allowed_domains, allowed_schemas)", field, key)
+		}
+	}
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return c, fmt.Errorf("%s.min must not be greater than max", field)
+	}
+	return c, nil
+}
+
+func constraintStrings(raw interface{}, field string) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", field)
+	}
+	out := make([]string, 0, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("%s[%d] must be a non-empty string", field, i)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Check returns the violations of a call of tool with args
+func (p *ToolConstraintPolicy) Check(tool *db.Tool, args map[string]interface{}) []ToolConstraintViolation {
+	if p == nil {
+		return nil
+	}
+	var violations []ToolConstraintViolation
+	for _, rule := range p.Rules {
+		if !rule.matches(tool) {
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(rule.Arguments)) {
+			value, ok := args[name]
+			if !ok || value == nil {
+				continue
+			}
+			constraint := rule.Arguments[name]
+			for _, v := range constraint.check(value) {
+				v.Tool, v.Argument = tool.Name, name
+				violations = append(violations, v)
+			}
+		}
+	}
+	return violations
+}
+
+func (r *ToolConstraintRule) matches(tool *db.Tool) bool {
+	for _, pattern := range r.Tools {
+		if matched, _ := path.Match(pattern, tool.Name); matched {
+			return true
+		}
+	}
+	for _, handlerType := range r.HandlerTypes {
+		if handlerType == tool.HandlerType {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns the constraints value breaks; Tool and Argument are left
+// for the caller to fill in
+func (c *ArgumentConstraint) check(value interface{}) []ToolConstraintViolation {
+	var violations []ToolConstraintViolation
+	violate := func(rule, message string, allowed interface{}) {
+		violations = append(violations, ToolConstraintViolation{Rule: rule, Message: message, Allowed: allowed})
+	}
+
+	if c.Min != nil || c.Max != nil {
+		n, ok := value.(float64)
+		switch {
+		case !ok:
+			violate("type", fmt.Sprintf("must be a number, got %T", value), nil)
+		case c.Min != nil && n < *c.Min:
+			violate(constraintMin, fmt.Sprintf("must be at least %v, got %v", *c.Min, n), *c.Min)
+		case c.Max != nil && n > *c.Max:
+			violate(constraintMax, fmt.Sprintf("must be at most %v, got %v", *c.Max, n), *c.Max)
+		}
+	}
+	if len(c.Enum) > 0 {
+		found := false
+		for _, allowed := range c.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violate(constraintEnum, fmt.Sprintf("must be one of the allowed values, got %v", value), c.Enum)
+		}
+	}
+
+	s, isString := value.(string)
+	if (c.MaxLength > 0 || c.Pattern != nil || len(c.AllowedDomains) > 0 || len(c.AllowedSchemas) > 0) && !isString {
+		violate("type", fmt.Sprintf("must be a string, got %T", value), nil)
+		return violations
+	}
+	if c.MaxLength > 0 && len([]rune(s)) > c.MaxLength {
+		violate(constraintMaxLength, fmt.Sprintf("must be at most %d characters, got %d", c.MaxLength, len([]rune(s))), c.MaxLength)
+	}
+	if c.Pattern != nil && !c.Pattern.MatchString(s) {
+		violate(constraintPattern, fmt.Sprintf("must match the pattern %s", c.Pattern.String()), c.Pattern.String())
+	}
+	if len(c.AllowedDomains) > 0 {
+		if message := checkURLDomain(s, c.AllowedDomains); message != "" {
+			violate(constraintAllowedDomains, message, c.AllowedDomains)
+		}
+	}
+	if len(c.AllowedSchemas) > 0 {
+		for _, schema := range sqlRelationSchemas(s) {
+			if !contains(c.AllowedSchemas, schema) {
+				violate(constraintAllowedSchemas, fmt.Sprintf("reads from schema '%s', which is not allowed", schema), c.AllowedSchemas)
+			}
+		}
+	}
+	return violations
+}
+
+// checkURLDomain returns why raw is not an http(s) URL on an allowed host,
+// or "" when it is
+func checkURLDomain(raw string, allowed []string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Sprintf("must be an absolute http(s) URL, got '%s'", raw)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	for _, domain := range allowed {
+		if suffix, ok := strings.CutPrefix(domain, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return ""
+			}
+		} else if host == domain {
+			return ""
+		}
+	}
+	return fmt.Sprintf("host '%s' is not an allowed domain", host)
+}
+
+// sqlFromEndKeywords end the relation list of a FROM clause; a word after a
+// relation that is not one of them is its alias
+var sqlFromEndKeywords = map[string]bool{
+	"where": true, "join": true, "inner": true, "left": true, "right": true, "full": true,
+	"cross": true, "natural": true, "on": true, "using": true, "group": true, "order": true,
+	"limit": true, "offset": true, "having": true, "union": true, "except": true,
+	"intersect": true, "window": true, "fetch": true, "for": true, "tablesample": true,
+}
+
+// sqlRelationSchemas returns the schemas of the relations a query reads
+// after FROM and JOIN, in order of appearance and without duplicates.
+// Unqualified relations count as public; table functions are skipped.
+// Relations the query defines with WITH are not relations of a schema, so
+// they are skipped too.
+func sqlRelationSchemas(query string) []string {
+	tokens := sqlTokens(query)
+	ctes := map[string]bool{}
+	for i := 0; i+2 < len(tokens); i++ {
+		// WITH name AS ( / , name AS (
+		if (tokens[i].lower == "with" || tokens[i].text == ",") && tokens[i+1].ident && tokens[i+2].lower == "as" {
+			ctes[tokens[i+1].name()] = true
+		}
+		if tokens[i].lower == "recursive" && tokens[i+1].ident {
+			ctes[tokens[i+1].name()] = true
+		}
+	}
+
+	var schemas []string
+	add := func(schema string) {
+		if !contains(schemas, schema) {
+			schemas = append(schemas, schema)
+		}
+	}
+	// inQuery tracks whether each open parenthesis holds a subquery; FROM
+	// inside a function call, as in EXTRACT(year FROM ts), names no relation
+	var inQuery []bool
+	for i := 0; i < len(tokens); i++ {
+		keyword := tokens[i].lower
+		switch keyword {
+		case "(":
+			next := ""
+			if i+1 < len(tokens) {
+				next = tokens[i+1].lower
+			}
+			inQuery = append(inQuery, next == "select" || next == "with" || next == "values")
+			continue
+		case ")":
+			if len(inQuery) > 0 {
+				inQuery = inQuery[:len(inQuery)-1]
+			}
+			continue
+		case "from", "join":
+		default:
+			continue
+		}
+		if len(inQuery) > 0 && !inQuery[len(inQuery)-1] {
+			continue
+		}
+		if i > 0 && tokens[i-1].lower == "distinct" {
+			// IS DISTINCT FROM
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) {
+			for j < len(tokens) && (tokens[j].lower == "only" || tokens[j].lower == "lateral") {
+				j++
+			}
+			if j >= len(tokens) || !tokens[j].ident {
+				break
+			}
+			schema, name := "public", tokens[j].name()
+			j++
+			if j+1 < len(tokens) && tokens[j].text == "." && tokens[j+1].ident {
+				schema, name = name, tokens[j+1].name()
+				j += 2
+			}
+			isFunction := j < len(tokens) && tokens[j].text == "("
+			if !isFunction && !(schema == "public" && ctes[name]) {
+				add(schema)
+			}
+			// Optional alias
+			if j < len(tokens) && tokens[j].lower == "as" {
+				j++
+			}
+			if j < len(tokens) && tokens[j].ident && !sqlFromEndKeywords[tokens[j].lower] {
+				j++
+			}
+			if keyword != "from" || j >= len(tokens) || tokens[j].text != "," {
+				break
+			}
+			j++
+		}
+	}
+	return schemas
+}
+
+type sqlToken struct {
+	text   string
+	lower  string
+	ident  bool
+	quoted bool
+}
+
+// name returns the identifier a token names, folded to lower case unless
+// it was quoted
+func (t sqlToken) name() string {
+	if t.quoted {
+		return t.text
+	}
+	return t.lower
+}
+
+// sqlTokens splits a query into identifiers and punctuation, dropping
+// string literals, numbers and comments
+func sqlTokens(query string) []sqlToken {
+	var tokens []sqlToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i += 2
+		case r == '\'':
+			i++
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case r == '"':
+			i++
+			var b strings.Builder
+			for i < len(runes) {
+				if runes[i] == '"' {
+					if i+1 < len(runes) && runes[i+1] == '"' {
+						b.WriteRune('"')
+						i += 2
+						continue
+					}
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			i++
+			tokens = append(tokens, sqlToken{text: b.String(), lower: strings.ToLower(b.String()), ident: true, quoted: true})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+			word := string(runes[start:i])
+			tokens = append(tokens, sqlToken{text: word, lower: strings.ToLower(word), ident: true})
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+		default:
+			tokens = append(tokens, sqlToken{text: string(r), lower: string(r)})
+			i++
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// Tool call statuses in a run trace
+const (
+	ToolCallCompleted = "completed"
+	ToolCallFailed    = "failed"
+	ToolCallPending   = "pending" // not run yet, such as while awaiting approval
+)
+
+// RunTrace is how a run went: each LLM call with its prompt size, latency
+// and retries, and each tool call with the size of its arguments and result
+// and how long it took
+type RunTrace struct {
+	LLMCalls  []LLMCallUsage  `json:"llm_calls"`
+	ToolCalls []ToolCallTrace `json:"tool_calls"`
+	// SemanticCacheHit is set when a cached answer was served without
+	// calling the LLM
+	SemanticCacheHit bool `json:"semantic_cache_hit,omitempty"`
+}
+
+// ToolCallTrace is a tool call of a run with its outcome
+type ToolCallTrace struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	Arguments      map[string]interface{} `json:"arguments"`
+	ArgumentsBytes int                    `json:"arguments_bytes"`
+	Status         string                 `json:"status"`
+	ResultBytes    int                    `json:"result_bytes"`
+	DurationMs     float64                `json:"duration_ms"`
+	CacheHit       *ToolCacheHit          `json:"cache_hit,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+}
+
+// newRunTrace builds the trace of the run in state
+func newRunTrace(state *ExecutionState) *RunTrace {
+	trace := &RunTrace{
+		LLMCalls:         state.LLMCalls,
+		ToolCalls:        []ToolCallTrace{},
+		SemanticCacheHit: state.CacheHit != nil,
+	}
+	if trace.LLMCalls == nil {
+		trace.LLMCalls = []LLMCallUsage{}
+	}
+	results := make(map[string]ToolResult, len(state.ToolResults))
+	for _, result := range state.ToolResults {
+		results[result.ToolCallID] = result
+	}
+	for _, call := range state.ToolCalls {
+		entry := ToolCallTrace{
+			ID:        call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+			Status:    ToolCallPending,
+		}
+		if args, err := json.Marshal(call.Arguments); err == nil {
+			entry.ArgumentsBytes = len(args)
+		}
+		if result, ok := results[call.ID]; ok {
+			entry.Status = ToolCallCompleted
+			entry.ResultBytes = len(result.Content)
+			entry.DurationMs = durationMs(result.Duration)
+			entry.CacheHit = result.CacheHit
+			if result.Error != nil {
+				entry.Status = ToolCallFailed
+				entry.Error = result.Error.Error()
+			}
+		}
+		trace.ToolCalls = append(trace.ToolCalls, entry)
+	}
+	return trace
+}
+
+// RunTraceOf returns the trace of a run. Completed runs have it in their
+// result; runs still going, paused or failed, and runs completed before
+// traces were recorded, get it from their last checkpoint.
+func RunTraceOf(run *db.Run) (*RunTrace, error) {
+	if stored, ok := run.Result["trace"].(map[string]interface{}); ok {
+		var trace RunTrace
+		if err := fromJSONMap(stored, &trace); err != nil {
+			return nil, fmt.Errorf("invalid run trace: run_id='%s', error=%w", run.ID.String(), err)
+		}
+		return &trace, nil
+	}
+	var checkpoint runCheckpoint
+	if err := fromJSONMap(run.Checkpoint.ToMap(), &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid run checkpoint: run_id='%s', error=%w", run.ID.String(), err)
+	}
+	state := &ExecutionState{SessionID: run.SessionID, AgentID: run.AgentID}
+	checkpoint.restore(state)
+	return newRunTrace(state), nil
+}
+
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,251 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// ErrBudgetExceeded is matched by every BudgetExceededError
+var ErrBudgetExceeded = errors.New("monthly budget exceeded")
+
+// Budget scopes
+const (
+	BudgetScopeAgent  = "agent"
+	BudgetScopeAPIKey = "api_key"
+)
+
+// ModelPrice is the price of a model in USD per 1,000 tokens
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// UsageBudget caps usage per calendar month (UTC). Zero values disable the
+// corresponding limit.
+type UsageBudget struct {
+	MonthlyUSD    float64 `json:"monthly_budget_usd,omitempty"`
+	MonthlyTokens int64   `json:"monthly_token_budget,omitempty"`
+}
+
+// Enabled reports whether the budget limits anything
+func (b UsageBudget) Enabled() bool {
+	return b.MonthlyUSD > 0 || b.MonthlyTokens > 0
+}
+
+// UsagePolicy prices LLM calls and sets the agent's monthly budget. It is
+// read from the "usage" object of the agent config:
+//
+//	"usage": {
+//	  "pricing": {                      // USD per 1K tokens by model; "*" prices any other model
+//	    "gpt-4o": {"prompt": 0.0025, "completion": 0.01}
+//	  },
+//	  "monthly_budget_usd": 50,         // refuse messages once the month's cost reaches this
+//	  "monthly_token_budget": 5000000   // refuse messages once the month's tokens reach this
+//	}
+//
+// Calls to unpriced models cost 0.
+type UsagePolicy struct {
+	Pricing map[string]ModelPrice `json:"pricing,omitempty"`
+	UsageBudget
+}
+
+// Cost estimates the cost in USD of usage on model
+func (p *UsagePolicy) Cost(model string, usage TokenUsage) float64 {
+	price, ok := p.Pricing[model]
+	if !ok {
+		price, ok = p.Pricing["*"]
+	}
+	if !ok {
+		return 0
+	}
+	cost := (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1000
+	// The ledger stores six decimal places
+	return math.Round(cost*1e6) / 1e6
+}
+
+// ParseUsagePolicy extracts the usage policy from an agent config. A missing
+// "usage" key yields a policy with no pricing and no budget.
+func ParseUsagePolicy(config map[string]interface{}) (*UsagePolicy, error) {
+	policy := &UsagePolicy{Pricing: map[string]ModelPrice{}}
+	raw, ok := config["usage"]
+	if !ok || raw == nil {
+		return policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("usage must be an object, got %T", raw)
+	}
+
+	if v, ok := settings["pricing"]; ok && v != nil {
+		models, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("usage.pricing must be an object keyed by model name")
+		}
+		for model, rawPrice := range models {
+			fields, ok := rawPrice.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("usage.pricing.%s must be an object with prompt and completion prices", model)
+			}
+			var price ModelPrice
+			for key, dest := range map[string]*float64{"prompt": &price.Prompt, "completion": &price.Completion} {
+				if v, ok := fields[key]; ok {
+					n, ok := v.(float64)
+					if !ok || n < 0 {
+						return nil, fmt.Errorf("usage.pricing.%s.%s must be a non-negative number", model, key)
+					}
+					*dest = n
+				}
+			}
+			policy.Pricing[model] = price
+		}
+	}
+
+	budget, err := parseUsageBudget(settings, "usage.")
+	if err != nil {
+		return nil, err
+	}
+	policy.UsageBudget = *budget
+	return policy, nil
+}
+
+// ParseAPIKeyBudget reads the monthly budget of an API key from its
+// metadata ("monthly_budget_usd" and "monthly_token_budget")
+func ParseAPIKeyBudget(metadata map[string]interface{}) (*UsageBudget, error) {
+	return parseUsageBudget(metadata, "api_key.metadata.")
+}
+
+func parseUsageBudget(settings map[string]interface{}, prefix string) (*UsageBudget, error) {
+	budget := &UsageBudget{}
+	if v, ok := settings["monthly_budget_usd"]; ok && v != nil {
+		n, ok := v.(float64)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("%smonthly_budget_usd must be a non-negative number", prefix)
+		}
+		budget.MonthlyUSD = n
+	}
+	if v, ok := settings["monthly_token_budget"]; ok && v != nil {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("%smonthly_token_budget must be a non-negative integer", prefix)
+		}
+		budget.MonthlyTokens = int64(n)
+	}
+	return budget, nil
+}
+
+// BudgetExceededError reports a monthly budget that has been used up
+type BudgetExceededError struct {
+	Scope string  // BudgetScopeAgent or BudgetScopeAPIKey
+	ID    string  // agent or API key ID
+	Unit  string  // "usd" or "tokens"
+	Limit float64 // the monthly budget
+	Used  float64 // usage so far this month
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("monthly %s budget exceeded: %s_id='%s', used=%g, limit=%g", e.Unit, e.Scope, e.ID, e.Used, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrBudgetExceeded) match
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// Exceeded reports whether a month's usage totals have reached the budget
+func (b UsageBudget) Exceeded(totals *db.UsageTotals) bool {
+	return b.check(totals, "", "") != nil
+}
+
+// check returns a BudgetExceededError when totals have reached the budget
+func (b UsageBudget) check(totals *db.UsageTotals, scope, id string) error {
+	if b.MonthlyUSD > 0 && totals.CostUSD >= b.MonthlyUSD {
+		return &BudgetExceededError{Scope: scope, ID: id, Unit: "usd", Limit: b.MonthlyUSD, Used: totals.CostUSD}
+	}
+	if b.MonthlyTokens > 0 && totals.TotalTokens >= b.MonthlyTokens {
+		return &BudgetExceededError{Scope: scope, ID: id, Unit: "tokens", Limit: float64(b.MonthlyTokens), Used: float64(totals.TotalTokens)}
+	}
+	return nil
+}
+
+// UsageTracker records LLM usage in the usage ledger and enforces monthly
+// budgets
+type UsageTracker struct {
+	queries *db.Queries
+}
+
+// NewUsageTracker creates a usage tracker
+func NewUsageTracker(queries *db.Queries) *UsageTracker {
+	return &UsageTracker{queries: queries}
+}
+
+// CheckBudget returns a BudgetExceededError when the agent's or the API
+// key's budget for this month is used up. apiKey may be nil.
+func (t *UsageTracker) CheckBudget(ctx context.Context, agentID uuid.UUID, policy *UsagePolicy, apiKey *db.APIKey) error {
+	if policy.UsageBudget.Enabled() {
+		totals, err := t.queries.GetMonthlyUsage(ctx, &agentID, nil)
+		if err != nil {
+			return fmt.Errorf("budget check failed: agent_id='%s', error=%w", agentID.String(), err)
+		}
+		if err := policy.UsageBudget.check(totals, BudgetScopeAgent, agentID.String()); err != nil {
+			return err
+		}
+	}
+
+	if apiKey == nil {
+		return nil
+	}
+	budget, err := ParseAPIKeyBudget(apiKey.Metadata)
+	if err != nil {
+		return fmt.Errorf("budget check failed: api_key_id='%s', error=%w", apiKey.ID.String(), err)
+	}
+	if !budget.Enabled() {
+		return nil
+	}
+	totals, err := t.queries.GetMonthlyUsage(ctx, nil, &apiKey.ID)
+	if err != nil {
+		return fmt.Errorf("budget check failed: api_key_id='%s', error=%w", apiKey.ID.String(), err)
+	}
+	return budget.check(totals, BudgetScopeAPIKey, apiKey.ID.String())
+}
+
+// Record adds one ledger entry per LLM call of an execution, attributed to
+// the assistant message and, when set, the API key
+func (t *UsageTracker) Record(ctx context.Context, state *ExecutionState, messageID int64, apiKey *db.APIKey) error {
+	if len(state.LLMCalls) == 0 {
+		return nil
+	}
+	records := make([]db.UsageRecord, 0, len(state.LLMCalls))
+	for _, call := range state.LLMCalls {
+		record := db.UsageRecord{
+			AgentID:          state.AgentID,
+			SessionID:        &state.SessionID,
+			MessageID:        &messageID,
+			Model:            call.Model,
+			PromptTokens:     call.Usage.PromptTokens,
+			CompletionTokens: call.Usage.CompletionTokens,
+			TotalTokens:      call.Usage.TotalTokens,
+			CostUSD:          call.CostUSD,
+		}
+		if call.Provider != "" {
+			provider := call.Provider
+			record.Provider = &provider
+		}
+		if apiKey != nil {
+			record.APIKeyID = &apiKey.ID
+		}
+		records = append(records, record)
+	}
+	if err := t.queries.CreateUsageRecords(ctx, records); err != nil {
+		return err
+	}
+	for _, call := range state.LLMCalls {
+		metrics.RecordLLMCost(state.AgentID.String(), call.Model, call.CostUSD)
+	}
+	return nil
+}
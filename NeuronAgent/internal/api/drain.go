@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// JobDrainer is the job worker, which stops claiming jobs when the server
+// drains and returns the number of jobs it handed back to the queue
+type JobDrainer interface {
+	Drain(ctx context.Context) int
+}
+
+// DrainStatus reports a drain of the server
+type DrainStatus struct {
+	Draining       bool       `json:"draining"`
+	Completed      bool       `json:"completed"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	Deadline       *time.Time `json:"deadline,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	InFlightRuns   int        `json:"in_flight_runs"`
+	HandedBackRuns int        `json:"handed_back_runs"`
+	HandedBackJobs int        `json:"handed_back_jobs"`
+}
+
+// Drainer takes the server out of service ahead of a deploy. Once draining,
+// the server reports not ready, refuses new messages and stops claiming
+// jobs and interrupted runs. Runs and jobs in progress may finish until the
+// deadline; those still in progress then are handed back for other servers
+// to resume. A drain is not undone: the server is expected to stop.
+type Drainer struct {
+	runtime *agent.Runtime
+	jobs    JobDrainer
+	timeout time.Duration
+
+	mu     sync.Mutex
+	status DrainStatus
+	done   chan struct{}
+}
+
+// NewDrainer creates a drainer of the runtime and job worker, giving work in
+// progress timeout to finish unless a drain sets its own
+func NewDrainer(runtime *agent.Runtime, jobs JobDrainer, timeout time.Duration) *Drainer {
+	return &Drainer{
+		runtime: runtime,
+		jobs:    jobs,
+		timeout: timeout,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start starts draining, giving work in progress timeout to finish, or the
+// drainer's timeout when it is not positive. It returns false when the
+// server is already draining.
+func (d *Drainer) Start(timeout time.Duration) bool {
+	if timeout <= 0 {
+		timeout = d.timeout
+	}
+	d.mu.Lock()
+	if d.status.Draining {
+		d.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	deadline := now.Add(timeout)
+	d.status.Draining = true
+	d.status.StartedAt = &now
+	d.status.Deadline = &deadline
+	d.mu.Unlock()
+
+	metrics.Logger().Info().Time("deadline", deadline).Msg("Draining server")
+	go d.run(deadline)
+	return true
+}
+
+func (d *Drainer) run(deadline time.Time) {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var runs, jobs int
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		runs = d.runtime.Drain(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		if d.jobs != nil {
+			jobs = d.jobs.Drain(ctx)
+		}
+	}()
+	wg.Wait()
+
+	now := time.Now()
+	d.mu.Lock()
+	d.status.Completed = true
+	d.status.CompletedAt = &now
+	d.status.HandedBackRuns = runs
+	d.status.HandedBackJobs = jobs
+	d.mu.Unlock()
+
+	metrics.Logger().Info().
+		Int("handed_back_runs", runs).
+		Int("handed_back_jobs", jobs).
+		Msg("Server drained")
+	close(d.done)
+}
+
+// Done is closed once a drain completed
+func (d *Drainer) Done() <-chan struct{} {
+	return d.done
+}
+
+// Draining reports whether the server is draining or drained
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status.Draining
+}
+
+// Status reports the drain of the server
+func (d *Drainer) Status() DrainStatus {
+	d.mu.Lock()
+	status := d.status
+	d.mu.Unlock()
+	status.InFlightRuns = d.runtime.InFlightRuns()
+	return status
+}
+
+// HandleReadiness reports whether the server takes traffic: 503 once it is
+// draining or when check, the database health check, fails. Load balancers
+// should route by it, while liveness probes keep using /health.
+func HandleReadiness(drainer *Drainer, check func(ctx context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if drainer != nil && drainer.Draining() {
+			respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+			return
+		}
+		if err := check(r.Context()); err != nil {
+			respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
+}
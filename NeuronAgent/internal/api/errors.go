@@ -15,6 +15,8 @@ type APIError struct {
 	ResourceType string
 	ResourceID   string
 	Details      map[string]interface{}
+	// RetryAfter is sent as the Retry-After header, in whole seconds
+	RetryAfter int
 }
 
 func (e *APIError) Error() string {
@@ -92,5 +94,13 @@ func WrapError(err *APIError, requestID string) *APIError {
 	if err == nil {
 		return nil
 	}
-	return NewErrorWithRequestID(err.Code, err.Message, err.Err, requestID)
+	wrapped := NewErrorWithRequestID(err.Code, err.Message, err.Err, requestID)
+	wrapped.RetryAfter = err.RetryAfter
+	return wrapped
+}
+
+// WithRetryAfter sets the retry hint of the error
+func (e *APIError) WithRetryAfter(seconds int) *APIError {
+	e.RetryAfter = seconds
+	return e
 }
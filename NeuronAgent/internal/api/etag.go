@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag formats a row version as a strong entity tag
+func versionETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// setVersionETag sets the ETag header of a response to a row version
+func setVersionETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", versionETag(version))
+}
+
+// parseIfMatch returns the versions listed in the If-Match header. It
+// returns nil when the header is absent or "*", meaning any version matches.
+// Weak tags (W/"3") are accepted.
+func parseIfMatch(r *http.Request) ([]int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil {
+			return nil, fmt.Errorf("If-Match must list quoted entity tags, got %s", tag)
+		}
+		version, err := strconv.ParseInt(unquoted, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("If-Match entity tag %s is not a version returned by this API", tag)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// versionMatches reports whether version satisfies the versions parsed from
+// If-Match
+func versionMatches(version int64, ifMatch []int64) bool {
+	if ifMatch == nil {
+		return true
+	}
+	for _, v := range ifMatch {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
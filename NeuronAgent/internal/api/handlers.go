@@ -2,31 +2,77 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/auth"
 	"github.com/neurondb/NeuronAgent/internal/db"
 	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/internal/secrets"
+	"github.com/neurondb/NeuronAgent/internal/session"
+	"github.com/neurondb/NeuronAgent/internal/tools"
+	"github.com/neurondb/NeuronAgent/internal/webhooks"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
 )
 
 type Handlers struct {
-	queries *db.Queries
-	runtime *agent.Runtime
+	queries    *db.Queries
+	runtime    *agent.Runtime
+	backfiller *agent.MemoryBackfiller
+	reembedder *agent.MemoryReembedder
+	documents  *agent.DocumentIngester
+	tools      *tools.Registry
+	retainer   *session.Retainer
+	events     *webhooks.Emitter
+	keys       *auth.APIKeyManager
+	secrets    *secrets.Store
+	drainer    *Drainer
 }
 
-func NewHandlers(queries *db.Queries, runtime *agent.Runtime) *Handlers {
+func NewHandlers(queries *db.Queries, runtime *agent.Runtime, backfiller *agent.MemoryBackfiller, reembedder *agent.MemoryReembedder, documents *agent.DocumentIngester, toolRegistry *tools.Registry, retainer *session.Retainer) *Handlers {
 	return &Handlers{
-		queries: queries,
-		runtime: runtime,
+		queries:    queries,
+		runtime:    runtime,
+		backfiller: backfiller,
+		reembedder: reembedder,
+		documents:  documents,
+		tools:      toolRegistry,
+		retainer:   retainer,
+		events:     webhooks.NewEmitter(queries),
+		keys:       auth.NewAPIKeyManager(queries),
 	}
 }
 
+// SetSecrets enables the secrets API. Without a store, its endpoints respond
+// 503.
+func (h *Handlers) SetSecrets(store *secrets.Store) {
+	h.secrets = store
+}
+
+// SetDrainer enables the drain API. Without a drainer, its endpoints
+// respond 503.
+func (h *Handlers) SetDrainer(drainer *Drainer) {
+	h.drainer = drainer
+}
+
 // Agents
 
 func (h *Handlers) CreateAgent(w http.ResponseWriter, r *http.Request) {
@@ -35,7 +81,11 @@ func (h *Handlers) CreateAgent(w http.ResponseWriter, r *http.Request) {
 	method := r.Method
 	
 	var req CreateAgentRequest
-	bodyBytes, _ := io.ReadAll(r.Body)
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, NewErrorWithContext(http.StatusBadRequest, "agent creation failed: request body read error", err, requestID, endpoint, method, "agent", "", nil))
+		return
+	}
 	bodySize := len(bodyBytes)
 	r.Body = io.NopCloser(io.Reader(bytes.NewReader(bodyBytes)))
 	
@@ -51,6 +101,37 @@ func (h *Handlers) CreateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !resolveAgentOrganization(w, r, &req) {
+		return
+	}
+	h.insertAgent(w, r, &req)
+}
+
+// resolveAgentOrganization sets the organization of a new agent. Agents
+// belong to the organization of the key creating them; an admin key, which
+// is not scoped to one, may name another.
+func resolveAgentOrganization(w http.ResponseWriter, r *http.Request, req *CreateAgentRequest) bool {
+	if req.OrganizationID != nil {
+		return requireAdmin(w, r, "create agents in another organization")
+	}
+	if apiKey := auth.APIKeyFromContext(r.Context()); apiKey != nil {
+		req.OrganizationID = apiKey.OrganizationID
+	}
+	return true
+}
+
+// insertAgent creates the agent described by a validated request whose
+// organization is resolved, and responds with it
+func (h *Handlers) insertAgent(w http.ResponseWriter, r *http.Request, req *CreateAgentRequest) {
+	requestID := GetRequestID(r.Context())
+	endpoint := r.URL.Path
+	method := r.Method
+	ctx := r.Context()
+	if err := h.checkPromptTemplate(ctx, req.PromptTemplateID, req.OrganizationID); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return
+	}
+
 	agent := &db.Agent{
 		Name:         req.Name,
 		Description:  req.Description,
@@ -59,9 +140,14 @@ func (h *Handlers) CreateAgent(w http.ResponseWriter, r *http.Request) {
 		MemoryTable:  req.MemoryTable,
 		EnabledTools: req.EnabledTools,
 		Config:       db.FromMap(req.Config),
+		OrganizationID: req.OrganizationID,
+		PromptTemplateID: req.PromptTemplateID,
+	}
+	if apiKey := auth.APIKeyFromContext(ctx); apiKey != nil {
+		agent.OwnerUserID = apiKey.UserID
 	}
 
-	if err := h.queries.CreateAgent(r.Context(), agent); err != nil {
+	if err := h.queries.CreateAgent(ctx, agent); err != nil {
 		respondError(w, NewErrorWithContext(http.StatusInternalServerError, "agent creation failed", err, requestID, endpoint, method, "agent", "", map[string]interface{}{
 			"agent_name":      req.Name,
 			"model_name":      req.ModelName,
@@ -71,7 +157,148 @@ func (h *Handlers) CreateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, toAgentResponse(agent))
+	setVersionETag(w, agent.Version)
+	response := toAgentResponse(agent)
+	h.events.Emit(r.Context(), webhooks.EventAgentCreated, response)
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// ListAgentTemplates lists the built-in templates agents can be created from
+func (h *Handlers) ListAgentTemplates(w http.ResponseWriter, r *http.Request) {
+	profiles := agent.GetDefaultProfiles()
+	for i := range profiles {
+		if profiles[i].Parameters == nil {
+			profiles[i].Parameters = []agent.ProfileParameter{}
+		}
+	}
+	respondJSON(w, http.StatusOK, profiles)
+}
+
+// CreateAgentFromTemplate creates an agent from a built-in template, filling
+// in its parameters and applying the overrides of the request
+func (h *Handlers) CreateAgentFromTemplate(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	var req CreateAgentFromTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if req.Template == "" {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("template is required")), requestID))
+		return
+	}
+	profile := agent.FindProfile(req.Template)
+	if profile == nil {
+		respondError(w, WrapError(NewError(http.StatusNotFound, "agent template not found", fmt.Errorf("no template named '%s'", req.Template)), requestID))
+		return
+	}
+	instance, err := profile.Instantiate(req.Parameters)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return
+	}
+
+	description := instance.Description
+	create := CreateAgentRequest{
+		Name:         req.Name,
+		Description:  &description,
+		SystemPrompt: instance.SystemPrompt,
+		ModelName:    instance.ModelName,
+		EnabledTools: instance.EnabledTools,
+		Config:       instance.Config,
+	}
+	applyAgentOverrides(&create, &req.AgentOverrides)
+	if !ValidateAndRespond(w, func() error { return ValidateCreateAgentRequest(&create) }) {
+		return
+	}
+	if !resolveAgentOrganization(w, r, &create) {
+		return
+	}
+	h.insertAgent(w, r, &create)
+}
+
+// CloneAgent creates a copy of an agent under a new name. The copy has the
+// agent's prompt, model, tools and config, with the overrides of the
+// request applied, but none of its sessions or memory. It is created in the
+// agent's organization unless an admin key names another.
+func (h *Handlers) CloneAgent(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	var req CloneAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	source, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	create := CreateAgentRequest{
+		Name:             req.Name,
+		Description:      source.Description,
+		SystemPrompt:     source.SystemPrompt,
+		ModelName:        source.ModelName,
+		MemoryTable:      source.MemoryTable,
+		EnabledTools:     append([]string{}, source.EnabledTools...),
+		Config:           source.Config.ToMap(),
+		PromptTemplateID: source.PromptTemplateID,
+	}
+	applyAgentOverrides(&create, &req.AgentOverrides)
+	if !ValidateAndRespond(w, func() error { return ValidateCreateAgentRequest(&create) }) {
+		return
+	}
+	if create.OrganizationID != nil {
+		if !requireAdmin(w, r, "create agents in another organization") {
+			return
+		}
+	} else {
+		create.OrganizationID = source.OrganizationID
+	}
+	h.insertAgent(w, r, &create)
+}
+
+// applyAgentOverrides applies the overrides of a template or clone request
+// to the agent it creates
+func applyAgentOverrides(req *CreateAgentRequest, overrides *AgentOverrides) {
+	if overrides.Description != nil {
+		req.Description = overrides.Description
+	}
+	if overrides.SystemPrompt != nil {
+		req.SystemPrompt = *overrides.SystemPrompt
+	}
+	if overrides.ModelName != nil {
+		req.ModelName = *overrides.ModelName
+	}
+	if overrides.MemoryTable != nil {
+		req.MemoryTable = overrides.MemoryTable
+	}
+	if overrides.EnabledTools != nil {
+		req.EnabledTools = overrides.EnabledTools
+	}
+	if overrides.PromptTemplateID != nil {
+		req.PromptTemplateID = overrides.PromptTemplateID
+	}
+	req.OrganizationID = overrides.OrganizationID
+	if len(overrides.Config) > 0 {
+		config := make(map[string]interface{}, len(req.Config)+len(overrides.Config))
+		for key, value := range req.Config {
+			config[key] = value
+		}
+		for key, value := range overrides.Config {
+			if value == nil {
+				delete(config, key)
+			} else {
+				config[key] = value
+			}
+		}
+		req.Config = config
+	}
 }
 
 func (h *Handlers) GetAgent(w http.ResponseWriter, r *http.Request) {
@@ -90,6 +317,7 @@ func (h *Handlers) GetAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setVersionETag(w, agent.Version)
 	respondJSON(w, http.StatusOK, toAgentResponse(agent))
 }
 
@@ -121,7 +349,7 @@ func (h *Handlers) UpdateAgent(w http.ResponseWriter, r *http.Request) {
 	var req CreateAgentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		requestID := GetRequestID(r.Context())
-		respondError(w, WrapError(ErrBadRequest, requestID))
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
 		return
 	}
 
@@ -130,12 +358,34 @@ func (h *Handlers) UpdateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid If-Match header", err), requestID))
+		return
+	}
+
 	agent, err := h.queries.GetAgentByID(r.Context(), id)
 	if err != nil {
 		requestID := GetRequestID(r.Context())
 		respondError(w, WrapError(ErrNotFound, requestID))
 		return
 	}
+	if !versionMatches(agent.Version, ifMatch) {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(versionConflictError("agent", agent.Version), requestID))
+		return
+	}
+	if req.OrganizationID != nil && (agent.OrganizationID == nil || *req.OrganizationID != *agent.OrganizationID) {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("organization_id of an agent cannot be changed")), requestID))
+		return
+	}
+	if err := h.checkPromptTemplate(r.Context(), req.PromptTemplateID, agent.OrganizationID); err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return
+	}
 
 	// Update fields
 	agent.Name = req.Name
@@ -145,13 +395,24 @@ func (h *Handlers) UpdateAgent(w http.ResponseWriter, r *http.Request) {
 	agent.MemoryTable = req.MemoryTable
 	agent.EnabledTools = req.EnabledTools
 	agent.Config = db.FromMap(req.Config)
+	agent.PromptTemplateID = req.PromptTemplateID
 
+	// The update only applies if the agent is unchanged since it was read
+	// above, so a concurrent update in between is reported as a conflict
 	if err := h.queries.UpdateAgent(r.Context(), agent); err != nil {
 		requestID := GetRequestID(r.Context())
-		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update agent", err), requestID))
+		switch {
+		case errors.Is(err, db.ErrVersionConflict):
+			respondError(w, WrapError(NewError(http.StatusConflict, "agent was modified by another request", err), requestID))
+		case errors.Is(err, sql.ErrNoRows):
+			respondError(w, WrapError(ErrNotFound, requestID))
+		default:
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update agent", err), requestID))
+		}
 		return
 	}
 
+	setVersionETag(w, agent.Version)
 	respondJSON(w, http.StatusOK, toAgentResponse(agent))
 }
 
@@ -173,41 +434,52 @@ func (h *Handlers) DeleteAgent(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Sessions
+// Memory
 
-func (h *Handlers) CreateSession(w http.ResponseWriter, r *http.Request) {
-	var req CreateSessionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+func (h *Handlers) GetMemoryUsage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
 		requestID := GetRequestID(r.Context())
 		respondError(w, WrapError(ErrBadRequest, requestID))
 		return
 	}
 
-	// Validate request
-	if !ValidateAndRespond(w, func() error { return ValidateCreateSessionRequest(&req) }) {
+	agentRecord, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrNotFound, requestID))
 		return
 	}
 
-	metadata := db.FromMap(req.Metadata)
-	if req.Metadata == nil {
-		metadata = make(db.JSONBMap)
-	}
-	session := &db.Session{
-		AgentID:       req.AgentID,
-		ExternalUserID: req.ExternalUserID,
-		Metadata:      metadata,
+	policy, err := agent.ParseRetentionPolicy(agentRecord.Config.ToMap())
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "invalid memory retention policy", err), requestID))
+		return
 	}
 
-	if err := h.queries.CreateSession(r.Context(), session); err != nil {
+	usage, err := h.queries.GetMemoryUsage(r.Context(), id)
+	if err != nil {
 		requestID := GetRequestID(r.Context())
-		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create session", err), requestID))
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get memory usage", err), requestID))
 		return
 	}
+	metrics.RecordMemoryUsage(id.String(), usage.ChunkCount, usage.ContentBytes)
 
-	respondJSON(w, http.StatusCreated, toSessionResponse(session))
+	respondJSON(w, http.StatusOK, MemoryUsageResponse{
+		AgentID:            usage.AgentID,
+		ChunkCount:         usage.ChunkCount,
+		ArchivedChunkCount: usage.ArchivedChunkCount,
+		ContentBytes:       usage.ContentBytes,
+		AvgImportance:      usage.AvgImportance,
+		OldestChunkAt:      usage.OldestChunkAt,
+		NewestChunkAt:      usage.NewestChunkAt,
+		Retention:          policy,
+	})
 }
 
-func (h *Handlers) GetSession(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) EvictMemory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
@@ -216,136 +488,3499 @@ func (h *Handlers) GetSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := h.queries.GetSession(r.Context(), id)
+	agentRecord, err := h.queries.GetAgentByID(r.Context(), id)
 	if err != nil {
 		requestID := GetRequestID(r.Context())
 		respondError(w, WrapError(ErrNotFound, requestID))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, toSessionResponse(session))
+	result, err := agent.NewMemoryEvictor(h.queries).EvictAgent(r.Context(), agentRecord)
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to evict memory", err), requestID))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
 }
 
-func (h *Handlers) ListSessions(w http.ResponseWriter, r *http.Request) {
+// StartMemoryBackfill queues a job that embeds the rows of an existing table
+// into the agent's memory
+func (h *Handlers) StartMemoryBackfill(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireMemory(w, r) {
+		return
+	}
 	vars := mux.Vars(r)
-	agentID, err := uuid.Parse(vars["agent_id"])
+	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		requestID := GetRequestID(r.Context())
 		respondError(w, WrapError(ErrBadRequest, requestID))
 		return
 	}
 
-	limit := 50
-	offset := 0
-	// Parse query parameters for pagination
-	if l := r.URL.Query().Get("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
+	a, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
 	}
-	if o := r.URL.Query().Get("offset"); o != "" {
-		fmt.Sscanf(o, "%d", &offset)
+
+	var req agent.MemoryBackfillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if req.Model == "" {
+		req.Model = agent.MemoryEmbeddingModel(a)
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateMemoryBackfillRequest(&req) }) {
+		return
+	}
+	if err := h.backfiller.Check(r.Context(), &req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid backfill source", err), requestID))
+		return
 	}
 
-	sessions, err := h.queries.ListSessions(r.Context(), agentID, limit, offset)
+	payload, err := req.ToPayload()
 	if err != nil {
-		requestID := GetRequestID(r.Context())
-		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list sessions", err), requestID))
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to encode backfill job", err), requestID))
 		return
 	}
-
-	responses := make([]SessionResponse, len(sessions))
-	for i, s := range sessions {
-		responses[i] = toSessionResponse(&s)
+	job, err := h.queries.CreateJob(r.Context(), &db.Job{
+		AgentID:    &id,
+		Type:       agent.MemoryBackfillJobType,
+		Status:     "queued",
+		Payload:    payload,
+		MaxRetries: 3,
+	})
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to queue memory backfill", err), requestID))
+		return
 	}
+	metrics.RecordJobQueued()
 
-	respondJSON(w, http.StatusOK, responses)
+	response, err := toMemoryBackfillResponse(job)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read backfill job", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusAccepted, response)
 }
 
-// Messages
-
-func (h *Handlers) SendMessage(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+// GetMemoryBackfill reports the status and progress of a memory backfill job
+func (h *Handlers) GetMemoryBackfill(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
 	vars := mux.Vars(r)
-	sessionID, err := uuid.Parse(vars["session_id"])
+	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		requestID := GetRequestID(r.Context())
 		respondError(w, WrapError(ErrBadRequest, requestID))
 		return
 	}
-
-	var req SendMessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		requestID := GetRequestID(r.Context())
+	jobID, err := strconv.ParseInt(vars["job_id"], 10, 64)
+	if err != nil {
 		respondError(w, WrapError(ErrBadRequest, requestID))
 		return
 	}
 
-	// Validate request
-	if !ValidateAndRespond(w, func() error { return ValidateSendMessageRequest(&req) }) {
+	// Jobs are not scoped to an organization, so the agent is checked first
+	if _, err := h.queries.GetAgentByID(r.Context(), id); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	job, err := h.queries.GetJob(r.Context(), jobID)
+	if err != nil || job.Type != agent.MemoryBackfillJobType || job.AgentID == nil || *job.AgentID != id {
+		respondError(w, WrapError(ErrNotFound, requestID))
 		return
 	}
 
-	// Check if streaming is requested
-	if req.Stream {
-		StreamResponse(w, r, h.runtime, sessionID.String(), req.Content)
+	response, err := toMemoryBackfillResponse(job)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read backfill job", err), requestID))
 		return
 	}
+	respondJSON(w, http.StatusOK, response)
+}
 
-	state, err := h.runtime.Execute(r.Context(), sessionID, req.Content)
+// StartMemoryReembed queues a job that re-embeds the agent's memory with a
+// new embedding model and switches the agent over to it
+func (h *Handlers) StartMemoryReembed(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "re-embed agent memory") {
+		return
+	}
+	if !h.requireMemory(w, r) {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		metrics.RecordAgentExecution(state.AgentID.String(), "error", time.Since(start))
-		requestID := GetRequestID(r.Context())
-		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to process message", err), requestID))
+		respondError(w, WrapError(ErrBadRequest, requestID))
 		return
 	}
 
-	// Record metrics
-	duration := time.Since(start)
-	metrics.RecordAgentExecution(state.AgentID.String(), "success", duration)
+	a, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
 
-	response := map[string]interface{}{
-		"session_id":   state.SessionID,
-		"agent_id":     state.AgentID,
-		"response":     state.FinalAnswer,
-		"tokens_used":  state.TokensUsed,
-		"tool_calls":   state.ToolCalls,
-		"tool_results": state.ToolResults,
+	var req agent.MemoryReembedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateMemoryReembedRequest(&req) }) {
+		return
+	}
+	active, err := h.queries.HasActiveJob(r.Context(), id, agent.MemoryReembedJobType)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to check memory re-embed jobs", err), requestID))
+		return
+	}
+	if active {
+		respondError(w, WrapError(NewError(http.StatusConflict, "agent memory is already being re-embedded",
+			fmt.Errorf("agent '%s' has a queued or running memory re-embed job", id)), requestID))
+		return
+	}
+	if err := h.reembedder.Check(r.Context(), a, &req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid memory re-embed request", err), requestID))
+		return
 	}
 
-	respondJSON(w, http.StatusOK, response)
+	payload, err := req.ToPayload()
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to encode re-embed job", err), requestID))
+		return
+	}
+	job, err := h.queries.CreateJob(r.Context(), &db.Job{
+		AgentID:    &id,
+		Type:       agent.MemoryReembedJobType,
+		Status:     "queued",
+		Payload:    payload,
+		MaxRetries: 3,
+	})
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to queue memory re-embed", err), requestID))
+		return
+	}
+	metrics.RecordJobQueued()
+
+	response, err := toMemoryReembedResponse(job)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read re-embed job", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusAccepted, response)
 }
 
-func (h *Handlers) GetMessages(w http.ResponseWriter, r *http.Request) {
+// GetMemoryReembed reports the status and progress of a memory re-embed job
+func (h *Handlers) GetMemoryReembed(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
 	vars := mux.Vars(r)
-	sessionID, err := uuid.Parse(vars["session_id"])
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	jobID, err := strconv.ParseInt(vars["job_id"], 10, 64)
 	if err != nil {
-		requestID := GetRequestID(r.Context())
 		respondError(w, WrapError(ErrBadRequest, requestID))
 		return
 	}
 
-	limit := 100
-	offset := 0
-	// Parse query parameters
-	if l := r.URL.Query().Get("limit"); l != "" {
-		_, _ = fmt.Sscanf(l, "%d", &limit)
+	// Jobs are not scoped to an organization, so the agent is checked first
+	if _, err := h.queries.GetAgentByID(r.Context(), id); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
 	}
-	if o := r.URL.Query().Get("offset"); o != "" {
-		_, _ = fmt.Sscanf(o, "%d", &offset)
+	job, err := h.queries.GetJob(r.Context(), jobID)
+	if err != nil || job.Type != agent.MemoryReembedJobType || job.AgentID == nil || *job.AgentID != id {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
 	}
 
-	messages, err := h.queries.GetMessages(r.Context(), sessionID, limit, offset)
+	response, err := toMemoryReembedResponse(job)
 	if err != nil {
-		requestID := GetRequestID(r.Context())
-		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get messages", err), requestID))
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read re-embed job", err), requestID))
 		return
 	}
+	respondJSON(w, http.StatusOK, response)
+}
 
-	responses := make([]MessageResponse, len(messages))
-	for i, m := range messages {
-		responses[i] = toMessageResponse(&m)
-	}
+// documentUploadTimeout is how long reading and answering a document upload
+// may take, in place of the server's read and write timeouts
+const documentUploadTimeout = 30 * time.Minute
 
-	respondJSON(w, http.StatusOK, responses)
+// UploadDocument streams a multipart/form-data document upload to the upload
+// directory and queues a job storing its text in the agent's memory. The
+// document is the "file" part; an optional "session_id" field stores it as
+// memory of that session.
+func (h *Handlers) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireMemory(w, r) {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	a, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	if err := h.documents.Check(a); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid document upload", err), requestID))
+		return
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "document upload must be multipart/form-data", err), requestID))
+		return
+	}
+
+	// Large uploads outlast the server's timeouts; writers that cannot
+	// extend them keep the server's
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Now().Add(documentUploadTimeout))
+	_ = controller.SetWriteDeadline(time.Now().Add(documentUploadTimeout))
+
+	req, apiErr := h.receiveDocument(reader)
+	if apiErr != nil {
+		respondError(w, WrapError(apiErr, requestID))
+		return
+	}
+	queued := false
+	defer func() {
+		if !queued {
+			os.Remove(req.Path)
+		}
+	}()
+	if req.SessionID != nil {
+		sess, err := h.queries.GetSession(r.Context(), *req.SessionID)
+		if err != nil || sess.AgentID != id {
+			respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid document upload",
+				fmt.Errorf("session '%s' is not a session of agent '%s'", req.SessionID, id)), requestID))
+			return
+		}
+	}
+
+	payload, err := req.ToPayload()
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to encode document ingest job", err), requestID))
+		return
+	}
+	job, err := h.queries.CreateJob(r.Context(), &db.Job{
+		AgentID:    &id,
+		SessionID:  req.SessionID,
+		Type:       agent.DocumentIngestJobType,
+		Status:     "queued",
+		Payload:    payload,
+		MaxRetries: 3,
+	})
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to queue document ingest", err), requestID))
+		return
+	}
+	queued = true
+	metrics.RecordJobQueued()
+
+	response, err := toDocumentUploadResponse(job)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read document ingest job", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusAccepted, response)
+}
+
+// receiveDocument reads the parts of a document upload, streaming the file
+// to the upload directory while hashing it. The file is removed again when
+// the upload fails.
+func (h *Handlers) receiveDocument(reader *multipart.Reader) (req *agent.DocumentIngestRequest, apiErr *APIError) {
+	req = &agent.DocumentIngestRequest{}
+	defer func() {
+		if apiErr != nil && req.Path != "" {
+			os.Remove(req.Path)
+		}
+	}()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return req, NewError(http.StatusBadRequest, "invalid multipart body", err)
+		}
+		switch part.FormName() {
+		case "session_id":
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				return req, NewError(http.StatusBadRequest, "invalid multipart body", err)
+			}
+			sessionID, err := uuid.Parse(strings.TrimSpace(string(value)))
+			if err != nil {
+				return req, NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("session_id: %w", err))
+			}
+			req.SessionID = &sessionID
+		case "file":
+			if req.Path != "" {
+				return req, NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("only one file may be uploaded at a time"))
+			}
+			if apiErr := h.receiveDocumentFile(part, req); apiErr != nil {
+				return req, apiErr
+			}
+		}
+		part.Close()
+	}
+	if req.Path == "" {
+		return req, NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("the upload has no file part"))
+	}
+	return req, nil
+}
+
+// receiveDocumentFile writes the file part of a document upload to the
+// upload directory
+func (h *Handlers) receiveDocumentFile(part *multipart.Part, req *agent.DocumentIngestRequest) *APIError {
+	req.Filename = part.FileName()
+	if req.Filename == "" {
+		req.Filename = "document"
+	}
+	mediaType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(req.Filename)))
+	}
+	req.MIMEType = strings.ToLower(mediaType)
+	if !agent.IsDocumentMIMEType(req.MIMEType) {
+		return NewError(http.StatusUnsupportedMediaType, "unsupported document type",
+			fmt.Errorf("'%s' has type '%s'; documents must be PDF or text", req.Filename, req.MIMEType))
+	}
+
+	file, err := h.documents.CreateUploadFile()
+	if err != nil {
+		return NewError(http.StatusInternalServerError, "failed to store document upload", err)
+	}
+	req.Path = file.Name()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), part)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		return NewError(http.StatusInternalServerError, "failed to store document upload", closeErr)
+	}
+	if err != nil {
+		return NewError(http.StatusBadRequest, "failed to read document upload", err)
+	}
+	if size == 0 {
+		return NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("'%s' is empty", req.Filename))
+	}
+	req.SizeBytes = size
+	req.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// GetDocumentUpload reports the status and progress of a document ingest job
+func (h *Handlers) GetDocumentUpload(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	jobID, err := strconv.ParseInt(vars["job_id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	// Jobs are not scoped to an organization, so the agent is checked first
+	if _, err := h.queries.GetAgentByID(r.Context(), id); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	job, err := h.queries.GetJob(r.Context(), jobID)
+	if err != nil || job.Type != agent.DocumentIngestJobType || job.AgentID == nil || *job.AgentID != id {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	response, err := toDocumentUploadResponse(job)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read document ingest job", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// SearchMemory returns the agent's memory chunks closest to a query
+func (h *Handlers) SearchMemory(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireMemory(w, r) {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	agentRecord, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	var req MemorySearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateMemorySearchRequest(&req) }) {
+		return
+	}
+
+	chunks, err := h.runtime.SearchMemory(r.Context(), agentRecord, req.Query, req.TopK)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to search memory", err), requestID))
+		return
+	}
+	results := make([]MemorySearchResult, len(chunks))
+	for i, c := range chunks {
+		results[i] = MemorySearchResult{
+			ID:              c.ID,
+			Content:         c.Content,
+			ImportanceScore: c.ImportanceScore,
+			Similarity:      c.Similarity,
+			Metadata:        c.Metadata,
+		}
+	}
+	respondJSON(w, http.StatusOK, results)
+}
+
+// ListMemoryChunks lists an agent's memory chunks, newest first, for
+// inspecting what the agent remembers
+func (h *Handlers) ListMemoryChunks(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	a, ok := h.curatedMemoryAgent(w, r)
+	if !ok {
+		return
+	}
+	filter, err := parseMemoryChunkFilter(r)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return
+	}
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		_, _ = fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		_, _ = fmt.Sscanf(o, "%d", &offset)
+	}
+	if limit < 1 || limit > 200 || offset < 0 {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("limit must be between 1 and 200 and offset must not be negative")), requestID))
+		return
+	}
+
+	chunks, err := h.queries.ListMemoryChunks(r.Context(), a.ID, filter, limit, offset)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list memory chunks", err), requestID))
+		return
+	}
+	total, err := h.queries.CountMatchingMemoryChunks(r.Context(), a.ID, filter)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to count memory chunks", err), requestID))
+		return
+	}
+	response := MemoryChunkListResponse{Chunks: make([]MemoryChunkResponse, len(chunks)), Total: total}
+	for i := range chunks {
+		response.Chunks[i] = toMemoryChunkResponse(&chunks[i])
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetMemoryChunk returns one of an agent's memory chunks
+func (h *Handlers) GetMemoryChunk(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	a, ok := h.curatedMemoryAgent(w, r)
+	if !ok {
+		return
+	}
+	chunkID, err := strconv.ParseInt(mux.Vars(r)["chunk_id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	chunk, err := h.queries.GetMemoryChunk(r.Context(), a.ID, chunkID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get memory chunk", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toMemoryChunkResponse(chunk))
+}
+
+// UpdateMemoryChunk changes the importance score of a memory chunk or pins
+// it, so operators can demote memories that pollute retrieval and keep the
+// ones retention must not evict
+func (h *Handlers) UpdateMemoryChunk(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "curate agent memory") {
+		return
+	}
+	a, ok := h.curatedMemoryAgent(w, r)
+	if !ok {
+		return
+	}
+	chunkID, err := strconv.ParseInt(mux.Vars(r)["chunk_id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	var req MemoryChunkUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateMemoryChunkUpdateRequest(&req) }) {
+		return
+	}
+
+	chunk, err := h.queries.UpdateMemoryChunk(r.Context(), a.ID, chunkID, req.ImportanceScore, req.Pinned)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update memory chunk", err), requestID))
+		return
+	}
+	metrics.RecordMemoryCuration(a.ID.String(), "update")
+	respondJSON(w, http.StatusOK, toMemoryChunkResponse(chunk))
+}
+
+// DeleteMemoryChunk deletes one of an agent's memory chunks. Deleted chunks
+// are not archived.
+func (h *Handlers) DeleteMemoryChunk(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "curate agent memory") {
+		return
+	}
+	a, ok := h.curatedMemoryAgent(w, r)
+	if !ok {
+		return
+	}
+	chunkID, err := strconv.ParseInt(mux.Vars(r)["chunk_id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	if err := h.queries.DeleteMemoryChunk(r.Context(), a.ID, chunkID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete memory chunk", err), requestID))
+		return
+	}
+	metrics.RecordMemoryCuration(a.ID.String(), "delete")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// curatedMemoryAgent returns the agent of a memory curation request. Only
+// Postgres memory is kept in rows that can be listed and edited.
+func (h *Handlers) curatedMemoryAgent(w http.ResponseWriter, r *http.Request) (*db.Agent, bool) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return nil, false
+	}
+	a, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return nil, false
+	}
+	policy, err := agent.ParseMemoryBackendPolicy(a.Config.ToMap())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "invalid memory backend policy", err), requestID))
+		return nil, false
+	}
+	if policy.Backend != agent.MemoryBackendPostgres {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "memory curation is not supported by the agent's memory backend",
+			fmt.Errorf("memory curation needs the %s memory backend, the agent uses %s", agent.MemoryBackendPostgres, policy.Backend)), requestID))
+		return nil, false
+	}
+	return a, true
+}
+
+// parseMemoryChunkFilter reads the q, session_id, pinned, min_importance and
+// max_importance query parameters
+func parseMemoryChunkFilter(r *http.Request) (db.MemoryChunkFilter, error) {
+	var filter db.MemoryChunkFilter
+	query := r.URL.Query()
+
+	if v := query.Get("q"); v != "" {
+		filter.Contains = &v
+	}
+	if v := query.Get("session_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, fmt.Errorf("session_id must be a UUID: %w", err)
+		}
+		filter.SessionID = &id
+	}
+	if v := query.Get("pinned"); v != "" {
+		pinned, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("pinned must be true or false")
+		}
+		filter.Pinned = &pinned
+	}
+	for name, dest := range map[string]**float64{"min_importance": &filter.MinImportance, "max_importance": &filter.MaxImportance} {
+		if v := query.Get(name); v != "" {
+			score, err := strconv.ParseFloat(v, 64)
+			if err != nil || score < 0 || score > 1 {
+				return filter, fmt.Errorf("%s must be a number between 0 and 1", name)
+			}
+			*dest = &score
+		}
+	}
+	if filter.MinImportance != nil && filter.MaxImportance != nil && *filter.MinImportance > *filter.MaxImportance {
+		return filter, fmt.Errorf("min_importance must not be greater than max_importance")
+	}
+	return filter, nil
+}
+
+// Usage
+
+// GetUsage reports LLM usage per day, agent, API key and model. Admin keys
+// see every key's usage; other keys see only their own.
+func (h *Handlers) GetUsage(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	filter, err := parseUsageFilter(r)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid usage query", err), requestID))
+		return
+	}
+
+	apiKey := auth.APIKeyFromContext(r.Context())
+	if apiKey == nil {
+		respondError(w, WrapError(ErrUnauthorized, requestID))
+		return
+	}
+	if !auth.HasRole(apiKey, auth.RoleAdmin) {
+		if filter.APIKeyID != nil && *filter.APIKeyID != apiKey.ID {
+			respondError(w, WrapError(NewError(http.StatusForbidden, "insufficient permissions", fmt.Errorf("role %s required to read another API key's usage", auth.RoleAdmin)), requestID))
+			return
+		}
+		filter.APIKeyID = &apiKey.ID
+	}
+
+	report, err := h.usageReport(r, filter)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get usage", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// GetAgentUsage reports an agent's LLM usage and its usage this month
+// against its budget
+func (h *Handlers) GetAgentUsage(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	filter, err := parseUsageFilter(r)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid usage query", err), requestID))
+		return
+	}
+	filter.AgentID = &id
+
+	agentRecord, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	policy, err := agent.ParseUsagePolicy(agentRecord.Config.ToMap())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "invalid usage policy", err), requestID))
+		return
+	}
+
+	report, err := h.usageReport(r, filter)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get usage", err), requestID))
+		return
+	}
+	monthToDate, err := h.queries.GetMonthlyUsage(r.Context(), &id, nil)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get usage", err), requestID))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, AgentUsageResponse{
+		AgentID:             id,
+		UsageReportResponse: *report,
+		Budget: BudgetStatus{
+			MonthlyBudgetUSD:   policy.MonthlyUSD,
+			MonthlyTokenBudget: policy.MonthlyTokens,
+			MonthToDate:        *monthToDate,
+			Exceeded:           policy.UsageBudget.Exceeded(monthToDate),
+		},
+	})
+}
+
+// ListLLMLogs lists the sampled LLM calls of an agent, newest first,
+// optionally of one session. Prompts hold other keys' conversations, so
+// only admin keys can read them.
+func (h *Handlers) ListLLMLogs(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "read LLM call logs") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	var sessionID *uuid.UUID
+	if s := r.URL.Query().Get("session_id"); s != "" {
+		parsed, err := uuid.Parse(s)
+		if err != nil {
+			respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("session_id must be a UUID")), requestID))
+			return
+		}
+		sessionID = &parsed
+	}
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		_, _ = fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		_, _ = fmt.Sscanf(o, "%d", &offset)
+	}
+	if limit < 1 || limit > 200 || offset < 0 {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("limit must be between 1 and 200 and offset must not be negative")), requestID))
+		return
+	}
+
+	if _, err := h.queries.GetAgentByID(r.Context(), id); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	logs, err := h.queries.ListLLMLogs(r.Context(), id, sessionID, limit, offset)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list LLM call logs", err), requestID))
+		return
+	}
+	response := make([]LLMLogResponse, len(logs))
+	for i, log := range logs {
+		response[i] = LLMLogResponse{
+			ID:               log.ID,
+			AgentID:          log.AgentID,
+			SessionID:        log.SessionID,
+			RunID:            log.RunID,
+			Purpose:          log.Purpose,
+			Model:            log.Model,
+			Provider:         log.Provider,
+			Prompt:           log.Prompt,
+			Response:         log.Response,
+			Error:            log.ErrorMessage,
+			PromptTokens:     log.PromptTokens,
+			CompletionTokens: log.CompletionTokens,
+			LatencyMs:        log.LatencyMs,
+			Redactions:       log.Redactions.ToMap(),
+			Truncated:        log.Truncated,
+			CreatedAt:        log.CreatedAt,
+		}
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// usageReport runs a usage report and totals its rows
+func (h *Handlers) usageReport(r *http.Request, filter db.UsageFilter) (*UsageReportResponse, error) {
+	rows, err := h.queries.GetUsageReport(r.Context(), filter)
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReportResponse{From: filter.From, To: filter.To, Rows: rows}
+	for _, row := range rows {
+		report.Totals.Requests += row.Requests
+		report.Totals.PromptTokens += row.PromptTokens
+		report.Totals.CompletionTokens += row.CompletionTokens
+		report.Totals.TotalTokens += row.TotalTokens
+		report.Totals.CostUSD += row.CostUSD
+	}
+	return report, nil
+}
+
+// parseUsageFilter reads the agent_id, api_key_id, from and to query
+// parameters. Dates are RFC 3339 timestamps or YYYY-MM-DD (UTC); a date "to"
+// includes that whole day. from defaults to the start of the current month.
+func parseUsageFilter(r *http.Request) (db.UsageFilter, error) {
+	var filter db.UsageFilter
+	query := r.URL.Query()
+
+	for name, dest := range map[string]**uuid.UUID{"agent_id": &filter.AgentID, "api_key_id": &filter.APIKeyID} {
+		if v := query.Get(name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a UUID: %w", name, err)
+			}
+			*dest = &id
+		}
+	}
+
+	if v := query.Get("from"); v != "" {
+		from, _, err := parseUsageTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("from: %w", err)
+		}
+		filter.From = &from
+	} else {
+		now := time.Now().UTC()
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		filter.From = &from
+	}
+	if v := query.Get("to"); v != "" {
+		to, isDate, err := parseUsageTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("to: %w", err)
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, fmt.Errorf("to must be after from")
+	}
+	return filter, nil
+}
+
+// parseUsageTime parses an RFC 3339 timestamp or a YYYY-MM-DD date
+func parseUsageTime(v string) (t time.Time, isDate bool, err error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, false, fmt.Errorf("expected YYYY-MM-DD or an RFC 3339 timestamp, got '%s'", v)
+	}
+	return t, false, nil
+}
+
+// Sessions
+
+func (h *Handlers) CreateSession(w http.ResponseWriter, r *http.Request) {
+	var req CreateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+
+	// Validate request
+	if !ValidateAndRespond(w, func() error { return ValidateCreateSessionRequest(&req) }) {
+		return
+	}
+
+	// The agent must be in the key's organization
+	if _, err := h.queries.GetAgentByID(r.Context(), req.AgentID); err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusNotFound, "agent not found", err), requestID))
+		return
+	}
+
+	metadata := db.FromMap(req.Metadata)
+	if req.Metadata == nil {
+		metadata = make(db.JSONBMap)
+	}
+	session := &db.Session{
+		AgentID:       req.AgentID,
+		ExternalUserID: req.ExternalUserID,
+		Metadata:      metadata,
+	}
+
+	if err := h.queries.CreateSession(r.Context(), session); err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create session", err), requestID))
+		return
+	}
+
+	response := toSessionResponse(session)
+	h.events.Emit(r.Context(), webhooks.EventSessionCreated, response)
+	respondJSON(w, http.StatusCreated, response)
+}
+
+func (h *Handlers) GetSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	session, err := h.queries.GetSession(r.Context(), id)
+	if err != nil {
+		session, err = h.queries.GetArchivedSession(r.Context(), id)
+	}
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, toSessionResponse(session))
+}
+
+func (h *Handlers) ListSessions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	agentID, err := uuid.Parse(vars["agent_id"])
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	limit := 50
+	offset := 0
+	// Parse query parameters for pagination
+	if l := r.URL.Query().Get("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		fmt.Sscanf(o, "%d", &offset)
+	}
+
+	// Archived sessions are listed only on request
+	var sessions []db.Session
+	switch archived := r.URL.Query().Get("archived"); archived {
+	case "", "exclude":
+		sessions, err = h.queries.ListSessions(r.Context(), agentID, limit, offset)
+	case "include":
+		sessions, err = h.queries.ListAllSessions(r.Context(), agentID, limit, offset)
+	case "only":
+		sessions, err = h.queries.ListArchivedSessions(r.Context(), agentID, limit, offset)
+	default:
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid archived parameter",
+			fmt.Errorf("archived must be exclude, include or only, got '%s'", archived)), requestID))
+		return
+	}
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list sessions", err), requestID))
+		return
+	}
+
+	responses := make([]SessionResponse, len(sessions))
+	for i, s := range sessions {
+		responses[i] = toSessionResponse(&s)
+	}
+
+	respondJSON(w, http.StatusOK, responses)
+}
+
+func (h *Handlers) ExportSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	var archive interface{}
+	if _, err := h.queries.GetSession(r.Context(), id); err == nil {
+		archive, err = session.NewArchiver(h.queries).Export(r.Context(), id)
+		if err != nil {
+			requestID := GetRequestID(r.Context())
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to export session", err), requestID))
+			return
+		}
+	} else {
+		// An archived session is exported as it was archived
+		stored, err := h.queries.GetSessionArchive(r.Context(), id)
+		if err != nil {
+			requestID := GetRequestID(r.Context())
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		archive = json.RawMessage(stored)
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"session-%s.json\"", id.String()))
+	respondJSON(w, http.StatusOK, archive)
+}
+
+// GetSessionSummary returns an LLM summary of a session in the requested
+// style and length. Summaries are cached and extended with new messages
+// when next requested.
+func (h *Handlers) GetSessionSummary(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	query := r.URL.Query()
+	opts, err := agent.ParseSummaryOptions(query.Get("style"), query.Get("length"), query.Get("refresh") == "true")
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid summary parameters", err), requestID))
+		return
+	}
+
+	if _, err := h.queries.GetSession(r.Context(), id); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	summary, generated, err := h.runtime.SummarizeSession(r.Context(), id, opts)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to summarize session", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toSessionSummaryResponse(summary, generated))
+}
+
+// GetSessionTrace returns how the session's assistant turns ran: for each
+// run, the messages it stored, its LLM calls with their prompt sizes,
+// latencies and retries, and its tool calls with their sizes and durations
+func (h *Handlers) GetSessionTrace(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		_, _ = fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		_, _ = fmt.Sscanf(o, "%d", &offset)
+	}
+	if limit < 1 || limit > 200 || offset < 0 {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("limit must be between 1 and 200 and offset must not be negative")), requestID))
+		return
+	}
+
+	if _, err := h.queries.GetSession(r.Context(), id); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	runs, err := h.queries.ListSessionRuns(r.Context(), id, limit, offset)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list session runs", err), requestID))
+		return
+	}
+	runIDs := make([]uuid.UUID, len(runs))
+	for i := range runs {
+		runIDs[i] = runs[i].ID
+	}
+	messages, err := h.queries.GetRunMessages(r.Context(), id, runIDs)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get run messages", err), requestID))
+		return
+	}
+
+	response := SessionTraceResponse{SessionID: id, Turns: make([]TurnTraceResponse, len(runs))}
+	for i := range runs {
+		trace, err := agent.RunTraceOf(&runs[i])
+		if err != nil {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read run trace", err), requestID))
+			return
+		}
+		turn := toTurnTraceResponse(&runs[i], trace, messages)
+		response.Turns[i] = turn
+
+		totals := &response.Totals
+		totals.Turns++
+		totals.LLMCalls += len(turn.LLMCalls)
+		totals.ToolCalls += len(turn.ToolCalls)
+		totals.Usage.PromptTokens += turn.Usage.PromptTokens
+		totals.Usage.CompletionTokens += turn.Usage.CompletionTokens
+		totals.Usage.TotalTokens += turn.Usage.TotalTokens
+		totals.CostUSD += turn.CostUSD
+		if turn.DurationMs != nil {
+			totals.DurationMs += *turn.DurationMs
+		}
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ArchiveSession moves a session to the archive now, whatever its agent's
+// retention policy
+func (h *Handlers) ArchiveSession(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	if _, err := h.queries.GetSession(r.Context(), id); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	if err := h.retainer.ArchiveSession(r.Context(), id); err != nil {
+		if errors.Is(err, db.ErrVersionConflict) {
+			respondError(w, WrapError(NewError(http.StatusConflict, "session changed while it was archived; retry", err), requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to archive session", err), requestID))
+		return
+	}
+
+	archived, err := h.queries.GetArchivedSession(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read archived session", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toSessionResponse(archived))
+}
+
+// DeleteSession hard-deletes a live or archived session. Memory chunks
+// derived from it are kept.
+func (h *Handlers) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "delete sessions") {
+		return
+	}
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	if _, err := h.queries.GetSession(r.Context(), id); err == nil {
+		err = h.queries.DeleteSession(r.Context(), id)
+		if err != nil {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete session", err), requestID))
+			return
+		}
+	} else if err := h.queries.DeleteArchivedSession(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete session", err), requestID))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplySessionRetention runs the agent's session retention policy now
+func (h *Handlers) ApplySessionRetention(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["agent_id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	agentRecord, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	result, err := h.retainer.ApplyAgent(r.Context(), agentRecord)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to apply session retention", err), requestID))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+func (h *Handlers) ImportSession(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	endpoint := r.URL.Path
+	method := r.Method
+
+	var req ImportSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, NewErrorWithContext(http.StatusBadRequest, "session import failed: request body parsing error", err, requestID, endpoint, method, "session", "", nil))
+		return
+	}
+
+	// Validate request
+	if !ValidateAndRespond(w, func() error { return ValidateImportSessionRequest(&req) }) {
+		return
+	}
+
+	opts := session.ImportOptions{
+		AgentID:     req.AgentID,
+		CreateAgent: req.CreateAgent,
+	}
+	if len(req.AgentMapping) > 0 {
+		opts.AgentMapping = make(map[uuid.UUID]uuid.UUID, len(req.AgentMapping))
+		for from, to := range req.AgentMapping {
+			fromID, _ := uuid.Parse(from)
+			toID, _ := uuid.Parse(to)
+			opts.AgentMapping[fromID] = toID
+		}
+	}
+
+	result, err := session.NewArchiver(h.queries).Import(r.Context(), req.Archive, opts)
+	if err != nil {
+		respondError(w, NewErrorWithContext(http.StatusUnprocessableEntity, "session import failed", err, requestID, endpoint, method, "session", req.Archive.Session.ID.String(), map[string]interface{}{
+			"archived_agent_id":  req.Archive.Agent.ID.String(),
+			"message_count":      len(req.Archive.Messages),
+			"memory_chunk_count": len(req.Archive.MemoryChunks),
+		}))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, result)
+}
+
+// Messages
+
+func (h *Handlers) SendMessage(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	vars := mux.Vars(r)
+	sessionID, err := uuid.Parse(vars["session_id"])
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+
+	// Validate request
+	if !ValidateAndRespond(w, func() error { return ValidateSendMessageRequest(&req) }) {
+		return
+	}
+
+	// Check if streaming is requested
+	if req.Stream {
+		StreamResponse(w, r, h.runtime, sessionID.String(), req.Content, toAgentAttachments(req.Attachments))
+		return
+	}
+
+	state, err := h.runtime.ExecuteWithAttachments(r.Context(), sessionID, req.Content, toAgentAttachments(req.Attachments))
+	if err != nil {
+		// Execute returns no state on error, so the session's agent is unknown
+		metrics.RecordAgentExecution("unknown", "error", time.Since(start))
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(executionError(err), requestID))
+		return
+	}
+
+	// Record metrics
+	duration := time.Since(start)
+	metrics.RecordAgentExecution(state.AgentID.String(), "success", duration)
+
+	// The run paused for a tool approval; its answer comes once it resumes
+	if state.PendingApproval != nil {
+		approval, err := toToolApprovalResponse(state.PendingApproval)
+		if err != nil {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read tool approval", err), GetRequestID(r.Context())))
+			return
+		}
+		respondJSON(w, http.StatusAccepted, map[string]interface{}{
+			"session_id": state.SessionID,
+			"agent_id":   state.AgentID,
+			"run_id":     state.RunID,
+			"status":     "pending_approval",
+			"approval":   approval,
+			"usage":      state.Usage,
+			"cost_usd":   state.CostUSD,
+		})
+		return
+	}
+
+	response := map[string]interface{}{
+		"session_id":   state.SessionID,
+		"agent_id":     state.AgentID,
+		"response":     state.FinalAnswer,
+		"tokens_used":  state.TokensUsed,
+		"usage":        state.Usage,
+		"cost_usd":     state.CostUSD,
+		"tool_calls":   state.ToolCalls,
+		"tool_results": state.ToolResults,
+	}
+	if state.RunID != nil {
+		response["run_id"] = state.RunID
+	}
+	if len(state.GuardrailViolations) > 0 {
+		response["guardrail_violations"] = state.GuardrailViolations
+	}
+	if state.CacheHit != nil {
+		response["semantic_cache"] = state.CacheHit
+	}
+	if len(state.Attachments) > 0 {
+		response["attachments"] = state.Attachments
+	}
+	if len(state.Sources) > 0 {
+		response["sources"] = state.Sources
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// toAgentAttachments converts the attachments of a request for the runtime
+func toAgentAttachments(reqs []AttachmentRequest) []agent.Attachment {
+	if len(reqs) == 0 {
+		return nil
+	}
+	attachments := make([]agent.Attachment, len(reqs))
+	for i, req := range reqs {
+		filename := req.Filename
+		if filename == "" {
+			filename = fmt.Sprintf("attachment-%d", i+1)
+		}
+		attachments[i] = agent.Attachment{
+			Filename: filename,
+			MIMEType: req.MIMEType,
+			Data:     req.Data,
+			URL:      req.URL,
+		}
+	}
+	return attachments
+}
+
+// versionConflictError reports an If-Match precondition that does not hold
+func versionConflictError(resource string, current int64) *APIError {
+	return NewError(http.StatusConflict, resource+" was modified by another request",
+		fmt.Errorf("If-Match does not match the current version %s", versionETag(current)))
+}
+
+// executionError maps an agent execution error to an API error
+func executionError(err error) *APIError {
+	if errors.Is(err, agent.ErrBudgetExceeded) {
+		return NewError(http.StatusTooManyRequests, "monthly budget exceeded", err)
+	}
+	if errors.Is(err, agent.ErrSessionBusy) {
+		return NewError(http.StatusConflict, "session is processing another message", err).WithRetryAfter(agent.RetryAfterSeconds(err))
+	}
+	if errors.Is(err, agent.ErrAgentBusy) {
+		return NewError(http.StatusTooManyRequests, "agent is at its concurrent run limit", err).WithRetryAfter(agent.RetryAfterSeconds(err))
+	}
+	if errors.Is(err, agent.ErrDraining) {
+		return NewError(http.StatusServiceUnavailable, "server is draining", err).WithRetryAfter(agent.RetryAfterSeconds(err))
+	}
+	if errors.Is(err, agent.ErrApprovalPending) {
+		return NewError(http.StatusConflict, "session has a run awaiting tool approval", err)
+	}
+	if errors.Is(err, agent.ErrInvalidAttachment) {
+		return NewError(http.StatusBadRequest, "attachment could not be read", err)
+	}
+	if errors.Is(err, agent.ErrContextLengthExceeded) {
+		return NewError(http.StatusRequestEntityTooLarge, "message exceeds the model context length", err)
+	}
+	return NewError(http.StatusInternalServerError, "failed to process message", err)
+}
+
+func (h *Handlers) GetMessages(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID, err := uuid.Parse(vars["session_id"])
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	limit := 100
+	offset := 0
+	// Parse query parameters
+	if l := r.URL.Query().Get("limit"); l != "" {
+		_, _ = fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		_, _ = fmt.Sscanf(o, "%d", &offset)
+	}
+
+	messages, err := h.queries.GetMessages(r.Context(), sessionID, limit, offset)
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get messages", err), requestID))
+		return
+	}
+
+	responses := make([]MessageResponse, len(messages))
+	for i, m := range messages {
+		responses[i] = toMessageResponse(&m)
+	}
+
+	respondJSON(w, http.StatusOK, responses)
+}
+
+// SearchMessages searches the messages of all sessions of an agent by
+// keyword or, with mode=semantic, by embedding similarity
+func (h *Handlers) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	search, err := parseMessageSearch(r)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return
+	}
+	if search.Mode == agent.MessageSearchSemantic && !h.requireMemory(w, r) {
+		return
+	}
+	agentRecord, err := h.queries.GetAgentByID(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	result, err := h.runtime.SearchMessages(r.Context(), agentRecord, search)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to search messages", err), requestID))
+		return
+	}
+	response := MessageSearchResponse{
+		Mode:    search.Mode,
+		Model:   result.Model,
+		Results: make([]MessageSearchHit, len(result.Hits)),
+	}
+	for i, hit := range result.Hits {
+		response.Results[i] = MessageSearchHit{
+			ID:        hit.ID,
+			SessionID: hit.SessionID,
+			Role:      hit.Role,
+			Content:   hit.Content,
+			ToolName:  hit.ToolName,
+			CreatedAt: hit.CreatedAt,
+			Score:     hit.Score,
+			Highlight: hit.Highlight,
+		}
+	}
+	if search.Mode == agent.MessageSearchSemantic {
+		response.Embedded = &result.Embedded
+		response.Unembedded = &result.Unembedded
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// parseMessageSearch reads the q, mode, session_id, role, from, to and
+// limit query parameters of a message search. role may be repeated or
+// comma-separated. Dates are parsed as for usage queries.
+func parseMessageSearch(r *http.Request) (agent.MessageSearch, error) {
+	query := r.URL.Query()
+	search := agent.MessageSearch{
+		Query: strings.TrimSpace(query.Get("q")),
+		Mode:  query.Get("mode"),
+		Limit: 20,
+	}
+	if search.Query == "" {
+		return search, fmt.Errorf("q is required")
+	}
+	if len(search.Query) > 1000 {
+		return search, fmt.Errorf("q must be at most 1000 bytes")
+	}
+	switch search.Mode {
+	case "":
+		search.Mode = agent.MessageSearchKeyword
+	case agent.MessageSearchKeyword, agent.MessageSearchSemantic:
+	default:
+		return search, fmt.Errorf("mode must be keyword or semantic, got '%s'", search.Mode)
+	}
+
+	if v := query.Get("session_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return search, fmt.Errorf("session_id must be a UUID: %w", err)
+		}
+		search.Filter.SessionID = &id
+	}
+	for _, v := range query["role"] {
+		for _, role := range strings.Split(v, ",") {
+			role = strings.TrimSpace(role)
+			switch role {
+			case "":
+				continue
+			case "user", "assistant", "system", "tool":
+			default:
+				return search, fmt.Errorf("role must be user, assistant, system or tool, got '%s'", role)
+			}
+			if !slices.Contains(search.Filter.Roles, role) {
+				search.Filter.Roles = append(search.Filter.Roles, role)
+			}
+		}
+	}
+	if v := query.Get("from"); v != "" {
+		from, _, err := parseUsageTime(v)
+		if err != nil {
+			return search, fmt.Errorf("from: %w", err)
+		}
+		search.Filter.From = &from
+	}
+	if v := query.Get("to"); v != "" {
+		to, isDate, err := parseUsageTime(v)
+		if err != nil {
+			return search, fmt.Errorf("to: %w", err)
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+		search.Filter.To = &to
+	}
+	if search.Filter.From != nil && search.Filter.To != nil && !search.Filter.To.After(*search.Filter.From) {
+		return search, fmt.Errorf("to must be after from")
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			return search, fmt.Errorf("limit must be between 1 and 100")
+		}
+		search.Limit = limit
+	}
+	return search, nil
+}
+
+// ListAttachments returns the files sent with the messages of a session
+func (h *Handlers) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID, err := uuid.Parse(vars["session_id"])
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	attachments, err := h.queries.ListMessageAttachments(r.Context(), sessionID)
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list attachments", err), requestID))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, attachments)
+}
+
+// Feedback
+
+// feedbackExportPageSize is how many feedback entries an export reads at a time
+const feedbackExportPageSize = 500
+
+// SubmitMessageFeedback rates an assistant message. Feedback is kept per
+// API key; submitting again replaces the key's earlier feedback.
+func (h *Handlers) SubmitMessageFeedback(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	messageID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	var req MessageFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateMessageFeedbackRequest(&req) }) {
+		return
+	}
+
+	message, err := h.queries.GetMessage(r.Context(), messageID)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	if message.Role != "assistant" {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("only assistant messages can be rated, message %d has role '%s'", messageID, message.Role)), requestID))
+		return
+	}
+	sess, err := h.queries.GetSession(r.Context(), message.SessionID)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	feedback := &db.MessageFeedback{
+		MessageID: message.ID,
+		SessionID: sess.ID,
+		AgentID:   sess.AgentID,
+		Rating:    req.Rating,
+		Score:     req.Score,
+		Comment:   req.Comment,
+		Metadata:  db.FromMap(req.Metadata),
+	}
+	if apiKey := auth.APIKeyFromContext(r.Context()); apiKey != nil {
+		feedback.APIKeyID = &apiKey.ID
+	}
+	if err := h.queries.UpsertMessageFeedback(r.Context(), feedback); err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to save feedback", err), requestID))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, toFeedbackResponse(feedback))
+}
+
+// GetMessageFeedback lists the feedback on a message. Admin keys see every
+// key's feedback; other keys see only their own.
+func (h *Handlers) GetMessageFeedback(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	messageID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	feedback, err := h.queries.ListMessageFeedback(r.Context(), messageID)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get feedback", err), requestID))
+		return
+	}
+
+	apiKey := auth.APIKeyFromContext(r.Context())
+	responses := []FeedbackResponse{}
+	for i := range feedback {
+		if apiKey != nil && !auth.HasRole(apiKey, auth.RoleAdmin) &&
+			(feedback[i].APIKeyID == nil || *feedback[i].APIKeyID != apiKey.ID) {
+			continue
+		}
+		responses = append(responses, toFeedbackResponse(&feedback[i]))
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+// DeleteMessageFeedback removes the calling API key's feedback on a message
+func (h *Handlers) DeleteMessageFeedback(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	messageID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	var apiKeyID *uuid.UUID
+	if apiKey := auth.APIKeyFromContext(r.Context()); apiKey != nil {
+		apiKeyID = &apiKey.ID
+	}
+	if err := h.queries.DeleteMessageFeedback(r.Context(), messageID, apiKeyID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete feedback", err), requestID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFeedback lists feedback matching the query filters with a summary of
+// it. Admin keys see every key's feedback; other keys see only their own.
+func (h *Handlers) ListFeedback(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	filter, ok := h.feedbackFilter(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		_, _ = fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		_, _ = fmt.Sscanf(o, "%d", &offset)
+	}
+
+	feedback, err := h.queries.ListFeedback(r.Context(), filter, limit, offset)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list feedback", err), requestID))
+		return
+	}
+	summary, err := h.queries.GetFeedbackSummary(r.Context(), filter)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to summarize feedback", err), requestID))
+		return
+	}
+
+	response := FeedbackListResponse{Feedback: make([]FeedbackResponse, len(feedback)), Summary: summary}
+	for i := range feedback {
+		response.Feedback[i] = toFeedbackResponse(&feedback[i])
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ExportFeedback streams the rated conversations matching the query filters
+// as JSON Lines, one TrainingExample per feedback entry, oldest first. Each
+// conversation starts with the agent's system prompt.
+func (h *Handlers) ExportFeedback(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	filter, ok := h.feedbackFilter(w, r)
+	if !ok {
+		return
+	}
+
+	// Read the first page before writing so a failure can still be reported
+	feedback, err := h.queries.ListFeedback(r.Context(), filter, feedbackExportPageSize, 0)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to export feedback", err), requestID))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"feedback.jsonl\"")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	systemPrompts := make(map[uuid.UUID]string)
+	for offset := 0; len(feedback) > 0; {
+		for i := range feedback {
+			example, err := h.trainingExample(r, &feedback[i], systemPrompts)
+			if err != nil {
+				// Headers are sent; stop and let the client see a truncated file
+				metrics.Logger().Error().Err(err).
+					Str("request_id", requestID).
+					Int64("message_id", feedback[i].MessageID).
+					Msg("Feedback export stopped")
+				return
+			}
+			if err := encoder.Encode(example); err != nil {
+				return
+			}
+		}
+		if len(feedback) < feedbackExportPageSize {
+			return
+		}
+		offset += len(feedback)
+		if feedback, err = h.queries.ListFeedback(r.Context(), filter, feedbackExportPageSize, offset); err != nil {
+			metrics.Logger().Error().Err(err).
+				Str("request_id", requestID).
+				Int("offset", offset).
+				Msg("Feedback export stopped")
+			return
+		}
+	}
+}
+
+// trainingExample builds the export line for one feedback entry.
+// systemPrompts caches agents' system prompts by agent ID.
+func (h *Handlers) trainingExample(r *http.Request, feedback *db.MessageFeedback, systemPrompts map[uuid.UUID]string) (*TrainingExample, error) {
+	systemPrompt, ok := systemPrompts[feedback.AgentID]
+	if !ok {
+		agentRecord, err := h.queries.GetAgentByID(r.Context(), feedback.AgentID)
+		if err != nil {
+			return nil, err
+		}
+		systemPrompt = agentRecord.SystemPrompt
+		systemPrompts[feedback.AgentID] = systemPrompt
+	}
+	// A message served by a prompt template version was answered with the
+	// version's prompt rather than the agent's
+	if feedback.PromptVersionID != nil {
+		if rendered, err := h.renderedPromptVersion(r.Context(), *feedback.PromptVersionID, feedback.SessionID); err == nil {
+			systemPrompt = rendered
+		}
+	}
+	messages, err := h.queries.GetMessagesUpTo(r.Context(), feedback.SessionID, feedback.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	example := &TrainingExample{
+		Messages:  make([]TrainingMessage, 0, len(messages)+1),
+		Rating:    feedback.Rating,
+		Score:     feedback.Score,
+		Comment:   feedback.Comment,
+		Metadata:  feedback.Metadata,
+		AgentID:   feedback.AgentID,
+		SessionID: feedback.SessionID,
+		MessageID: feedback.MessageID,
+		CreatedAt: feedback.CreatedAt,
+	}
+	if systemPrompt != "" {
+		example.Messages = append(example.Messages, TrainingMessage{Role: "system", Content: systemPrompt})
+	}
+	for _, m := range messages {
+		example.Messages = append(example.Messages, TrainingMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolName:   m.ToolName,
+			ToolCallID: m.ToolCallID,
+		})
+	}
+	return example, nil
+}
+
+// feedbackFilter parses the feedback query filters and limits non-admin keys
+// to their own feedback. It responds with an error and returns false when
+// the request cannot proceed.
+func (h *Handlers) feedbackFilter(w http.ResponseWriter, r *http.Request) (db.FeedbackFilter, bool) {
+	requestID := GetRequestID(r.Context())
+	filter, err := parseFeedbackFilter(r)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid feedback query", err), requestID))
+		return filter, false
+	}
+
+	apiKey := auth.APIKeyFromContext(r.Context())
+	if apiKey == nil {
+		respondError(w, WrapError(ErrUnauthorized, requestID))
+		return filter, false
+	}
+	if !auth.HasRole(apiKey, auth.RoleAdmin) {
+		if filter.APIKeyID != nil && *filter.APIKeyID != apiKey.ID {
+			respondError(w, WrapError(NewError(http.StatusForbidden, "insufficient permissions", fmt.Errorf("role %s required to read another API key's feedback", auth.RoleAdmin)), requestID))
+			return filter, false
+		}
+		filter.APIKeyID = &apiKey.ID
+	}
+	return filter, true
+}
+
+// parseFeedbackFilter reads the agent_id, api_key_id, session_id,
+// prompt_version_id, prompt_template_id, rating, min_score, max_score, from
+// and to query parameters. from and to take the same forms as in usage
+// reports but have no default.
+func parseFeedbackFilter(r *http.Request) (db.FeedbackFilter, error) {
+	var filter db.FeedbackFilter
+	query := r.URL.Query()
+
+	for name, dest := range map[string]**uuid.UUID{"agent_id": &filter.AgentID, "api_key_id": &filter.APIKeyID, "session_id": &filter.SessionID,
+		"prompt_version_id": &filter.PromptVersionID, "prompt_template_id": &filter.PromptTemplateID} {
+		if v := query.Get(name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a UUID: %w", name, err)
+			}
+			*dest = &id
+		}
+	}
+
+	if v := query.Get("rating"); v != "" {
+		if v != "up" && v != "down" {
+			return filter, fmt.Errorf("rating must be 'up' or 'down'")
+		}
+		filter.Rating = &v
+	}
+	for name, dest := range map[string]**int{"min_score": &filter.MinScore, "max_score": &filter.MaxScore} {
+		if v := query.Get(name); v != "" {
+			score, err := strconv.Atoi(v)
+			if err != nil || score < 1 || score > 5 {
+				return filter, fmt.Errorf("%s must be between 1 and 5", name)
+			}
+			*dest = &score
+		}
+	}
+
+	if v := query.Get("from"); v != "" {
+		from, _, err := parseUsageTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("from: %w", err)
+		}
+		filter.From = &from
+	}
+	if v := query.Get("to"); v != "" {
+		to, isDate, err := parseUsageTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("to: %w", err)
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, fmt.Errorf("to must be after from")
+	}
+	return filter, nil
+}
+
+// Prompt templates
+
+// CreatePromptTemplate creates a prompt template in the API key's
+// organization. Versions are added to it separately.
+func (h *Handlers) CreatePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	var req PromptTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidatePromptTemplateRequest(&req) }) {
+		return
+	}
+
+	template := &db.PromptTemplate{Name: req.Name, Description: req.Description}
+	if apiKey := auth.APIKeyFromContext(r.Context()); apiKey != nil {
+		template.OrganizationID = apiKey.OrganizationID
+	}
+	if err := h.queries.CreatePromptTemplate(r.Context(), template); err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			respondError(w, WrapError(NewError(http.StatusConflict, "prompt template already exists", err), requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create prompt template", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusCreated, PromptTemplateResponse{PromptTemplate: *template, Versions: []db.PromptTemplateVersion{}})
+}
+
+// ListPromptTemplates lists the prompt templates of the API key's
+// organization with their versions
+func (h *Handlers) ListPromptTemplates(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	templates, err := h.queries.ListPromptTemplates(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list prompt templates", err), requestID))
+		return
+	}
+	responses := make([]PromptTemplateResponse, len(templates))
+	for i := range templates {
+		versions, err := h.queries.ListPromptTemplateVersions(r.Context(), templates[i].ID)
+		if err != nil {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list prompt template versions", err), requestID))
+			return
+		}
+		responses[i] = PromptTemplateResponse{PromptTemplate: templates[i], Versions: versions}
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+// GetPromptTemplate returns a prompt template with its versions
+func (h *Handlers) GetPromptTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.promptTemplate(w, r)
+	if !ok {
+		return
+	}
+	versions, err := h.queries.ListPromptTemplateVersions(r.Context(), template.ID)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list prompt template versions", err), GetRequestID(r.Context())))
+		return
+	}
+	respondJSON(w, http.StatusOK, PromptTemplateResponse{PromptTemplate: *template, Versions: versions})
+}
+
+// DeletePromptTemplate removes a prompt template and its versions. Agents
+// using it go back to their system prompt, and the messages and feedback of
+// its versions are no longer attributed to a version.
+func (h *Handlers) DeletePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	if err := h.queries.DeletePromptTemplate(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete prompt template", err), requestID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreatePromptTemplateVersion adds the next version of a prompt template.
+// Sessions already assigned a version keep it.
+func (h *Handlers) CreatePromptTemplateVersion(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	template, ok := h.promptTemplate(w, r)
+	if !ok {
+		return
+	}
+	var req PromptTemplateVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidatePromptTemplateVersionRequest(&req) }) {
+		return
+	}
+
+	version := &db.PromptTemplateVersion{
+		TemplateID:    template.ID,
+		Content:       req.Content,
+		Variables:     db.FromMap(req.Variables),
+		RolloutWeight: req.RolloutWeight,
+	}
+	if err := h.queries.CreatePromptTemplateVersion(r.Context(), version); err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create prompt template version", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusCreated, version)
+}
+
+// SetPromptTemplateRollout sets the share of new sessions each version of a
+// prompt template is served
+func (h *Handlers) SetPromptTemplateRollout(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	template, ok := h.promptTemplate(w, r)
+	if !ok {
+		return
+	}
+	var req PromptTemplateRolloutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidatePromptTemplateRolloutRequest(&req) }) {
+		return
+	}
+
+	versions, err := h.queries.SetPromptTemplateRollout(r.Context(), template.ID, req.Weights)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to set prompt template rollout", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, PromptTemplateResponse{PromptTemplate: *template, Versions: versions})
+}
+
+// GetPromptVersionMetrics compares the versions of the prompt template named
+// by the prompt_template_id query parameter on the feedback matching the
+// other feedback filters. Like other feedback reports, non-admin keys see
+// only their own feedback.
+func (h *Handlers) GetPromptVersionMetrics(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	filter, ok := h.feedbackFilter(w, r)
+	if !ok {
+		return
+	}
+	if filter.PromptTemplateID == nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid feedback query", fmt.Errorf("prompt_template_id is required")), requestID))
+		return
+	}
+	if _, err := h.queries.GetPromptTemplate(r.Context(), *filter.PromptTemplateID); err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+
+	versions, err := h.queries.GetPromptVersionMetrics(r.Context(), filter)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get prompt version metrics", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, PromptVersionMetricsResponse{PromptTemplateID: *filter.PromptTemplateID, Versions: versions})
+}
+
+// promptTemplate loads the prompt template named by the route. It responds
+// with an error and returns false when the template is not found.
+func (h *Handlers) promptTemplate(w http.ResponseWriter, r *http.Request) (*db.PromptTemplate, bool) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return nil, false
+	}
+	template, err := h.queries.GetPromptTemplate(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return nil, false
+	}
+	return template, true
+}
+
+// checkPromptTemplate checks that an agent of organizationID can use the
+// prompt template id: the template is visible to the request, belongs to
+// the same organization and has a version rolled out. A nil id is valid.
+func (h *Handlers) checkPromptTemplate(ctx context.Context, id *uuid.UUID, organizationID *string) error {
+	if id == nil {
+		return nil
+	}
+	template, err := h.queries.GetPromptTemplate(ctx, *id)
+	if err != nil {
+		return fmt.Errorf("prompt_template_id '%s' not found", id.String())
+	}
+	if !sameOrganization(template.OrganizationID, organizationID) {
+		return fmt.Errorf("prompt_template_id '%s' belongs to another organization", id.String())
+	}
+	versions, err := h.queries.ListPromptTemplateVersions(ctx, *id)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.RolloutWeight > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("prompt template '%s' has no version with a rollout weight", template.Name)
+}
+
+// renderedPromptVersion returns the system prompt a prompt template version
+// served a session
+func (h *Handlers) renderedPromptVersion(ctx context.Context, versionID, sessionID uuid.UUID) (string, error) {
+	version, err := h.queries.GetPromptTemplateVersion(ctx, versionID)
+	if err != nil {
+		return "", err
+	}
+	sess, err := h.queries.GetSession(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	return agent.RenderPromptVersion(version, sess)
+}
+
+// sameOrganization reports whether two organization IDs are equal, nil
+// being the organization of rows with none
+func sameOrganization(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Tool approvals
+
+// ListToolApprovals lists tool approvals, newest first, filtered by the
+// status, agent_id and session_id query parameters
+func (h *Handlers) ListToolApprovals(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage tool approvals") {
+		return
+	}
+
+	var filter db.ToolApprovalFilter
+	query := r.URL.Query()
+	if v := query.Get("status"); v != "" {
+		filter.Status = &v
+	}
+	for key, dest := range map[string]**uuid.UUID{"agent_id": &filter.AgentID, "session_id": &filter.SessionID} {
+		if v := query.Get(key); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("%s must be a UUID", key)), requestID))
+				return
+			}
+			*dest = &id
+		}
+	}
+
+	limit := 100
+	offset := 0
+	if l := query.Get("limit"); l != "" {
+		_, _ = fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := query.Get("offset"); o != "" {
+		_, _ = fmt.Sscanf(o, "%d", &offset)
+	}
+
+	approvals, err := h.queries.ListToolApprovals(r.Context(), filter, limit, offset)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list tool approvals", err), requestID))
+		return
+	}
+	responses := make([]ToolApprovalResponse, len(approvals))
+	for i := range approvals {
+		response, err := toToolApprovalResponse(&approvals[i])
+		if err != nil {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read tool approval", err), requestID))
+			return
+		}
+		responses[i] = *response
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+// GetToolApproval returns a tool approval, including the resumed run's
+// answer once it has completed
+func (h *Handlers) GetToolApproval(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage tool approvals") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	approval, err := h.queries.GetToolApproval(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get tool approval", err), requestID))
+		return
+	}
+	response, err := toToolApprovalResponse(approval)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read tool approval", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetRun returns the status of a run. A run paused for a tool approval
+// names it; completed runs carry their answer and failed runs their error.
+func (h *Handlers) GetRun(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	run, err := h.queries.GetRun(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get run", err), requestID))
+		return
+	}
+	response, err := toRunResponse(run)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read run", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ApproveToolApproval approves a paused run's tool calls and queues the run
+// to resume
+func (h *Handlers) ApproveToolApproval(w http.ResponseWriter, r *http.Request) {
+	h.decideToolApproval(w, r, db.ToolApprovalApproved)
+}
+
+// RejectToolApproval rejects a paused run's tool calls and queues the run to
+// resume without them
+func (h *Handlers) RejectToolApproval(w http.ResponseWriter, r *http.Request) {
+	h.decideToolApproval(w, r, db.ToolApprovalRejected)
+}
+
+func (h *Handlers) decideToolApproval(w http.ResponseWriter, r *http.Request, status string) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage tool approvals") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+
+	var req ToolApprovalDecisionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+			return
+		}
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateToolApprovalDecisionRequest(&req) }) {
+		return
+	}
+	decidedBy := req.DecidedBy
+	if decidedBy == nil {
+		apiKey := auth.APIKeyFromContext(r.Context())
+		if apiKey.UserID != nil {
+			decidedBy = apiKey.UserID
+		} else {
+			decidedBy = &apiKey.KeyPrefix
+		}
+	}
+
+	approval := &db.ToolApproval{ID: id, Status: status, DecidedBy: decidedBy, Reason: req.Reason}
+	job := &db.Job{
+		Type:    agent.ToolApprovalJobType,
+		Status:  "queued",
+		Payload: map[string]interface{}{"approval_id": id.String()},
+		// Tools may have run before a failure, so the run is not retried
+		MaxRetries: 1,
+	}
+	if err := h.queries.DecideToolApproval(r.Context(), approval, job); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			respondError(w, WrapError(ErrNotFound, requestID))
+		case errors.Is(err, db.ErrVersionConflict):
+			respondError(w, WrapError(NewError(http.StatusConflict, "tool approval is no longer pending", err), requestID))
+		default:
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to decide tool approval", err), requestID))
+		}
+		return
+	}
+	metrics.RecordJobQueued()
+
+	response, err := toToolApprovalResponse(approval)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to read tool approval", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusAccepted, response)
+}
+
+// Webhooks
+
+// CreateWebhook registers an endpoint for lifecycle events
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage webhooks") {
+		return
+	}
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateWebhookRequest(&req) }) {
+		return
+	}
+
+	webhook := webhookFromRequest(&req)
+	if err := h.queries.CreateWebhook(r.Context(), webhook); err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create webhook", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusCreated, toWebhookResponse(webhook))
+}
+
+func (h *Handlers) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage webhooks") {
+		return
+	}
+	webhooks, err := h.queries.ListWebhooks(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list webhooks", err), GetRequestID(r.Context())))
+		return
+	}
+	responses := make([]WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		responses[i] = toWebhookResponse(&webhooks[i])
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+func (h *Handlers) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage webhooks") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	webhook, err := h.queries.GetWebhook(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toWebhookResponse(webhook))
+}
+
+// UpdateWebhook replaces a webhook's settings. Queued deliveries keep the
+// URL and secret they were queued with.
+func (h *Handlers) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage webhooks") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateWebhookRequest(&req) }) {
+		return
+	}
+
+	webhook := webhookFromRequest(&req)
+	webhook.ID = id
+	if err := h.queries.UpdateWebhook(r.Context(), webhook); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update webhook", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toWebhookResponse(webhook))
+}
+
+// DeleteWebhook removes a webhook with its queued and dead-lettered
+// deliveries
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage webhooks") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	if err := h.queries.DeleteWebhook(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete webhook", err), requestID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists deliveries, newest first, filtered by the
+// webhook_id, status and event query parameters. status=dead lists the
+// dead-lettered deliveries.
+func (h *Handlers) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage webhooks") {
+		return
+	}
+
+	var filter db.WebhookDeliveryFilter
+	query := r.URL.Query()
+	if v := query.Get("webhook_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("webhook_id must be a UUID")), requestID))
+			return
+		}
+		filter.WebhookID = &id
+	}
+	if v := query.Get("status"); v != "" {
+		filter.Status = &v
+	}
+	if v := query.Get("event"); v != "" {
+		filter.Event = &v
+	}
+
+	limit := 100
+	offset := 0
+	if l := query.Get("limit"); l != "" {
+		_, _ = fmt.Sscanf(l, "%d", &limit)
+	}
+	if o := query.Get("offset"); o != "" {
+		_, _ = fmt.Sscanf(o, "%d", &offset)
+	}
+
+	deliveries, err := h.queries.ListWebhookDeliveries(r.Context(), filter, limit, offset)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list webhook deliveries", err), requestID))
+		return
+	}
+	responses := make([]WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		responses[i] = toWebhookDeliveryResponse(&deliveries[i])
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+// RedeliverWebhookDelivery queues a dead-lettered delivery again
+func (h *Handlers) RedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage webhooks") {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	delivery, err := h.queries.RedeliverWebhookDelivery(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			respondError(w, WrapError(ErrNotFound, requestID))
+		case errors.Is(err, db.ErrVersionConflict):
+			respondError(w, WrapError(NewError(http.StatusConflict, "only dead-lettered deliveries can be redelivered", err), requestID))
+		default:
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to redeliver webhook delivery", err), requestID))
+		}
+		return
+	}
+	respondJSON(w, http.StatusAccepted, toWebhookDeliveryResponse(delivery))
+}
+
+func webhookFromRequest(req *WebhookRequest) *db.Webhook {
+	webhook := &db.Webhook{
+		URL:         req.URL,
+		Events:      req.Events,
+		SecretEnv:   req.SecretEnv,
+		Description: req.Description,
+		Enabled:     true,
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	return webhook
+}
+
+// API keys
+
+// CreateAPIKey issues a new API key. The key itself is only returned in
+// this response.
+func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage API keys") {
+		return
+	}
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateAPIKeyRequest(&req) }) {
+		return
+	}
+	user, ok := h.resolveAPIKeyOwner(w, r, &req)
+	if !ok {
+		return
+	}
+
+	key, apiKey, err := h.keys.GenerateAPIKey(r.Context(), req.OrganizationID, req.UserID, *req.RateLimitPerMin, req.Roles)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create API key", err), requestID))
+		return
+	}
+	if user != nil && user.Status == db.UserInvited {
+		if err := h.queries.ActivateUser(r.Context(), user.ID); err != nil {
+			// The key works; the user shows as invited until its next key
+			metrics.Logger().Warn().Err(err).
+				Str("request_id", requestID).
+				Str("user_id", user.ID.String()).
+				Msg("Failed to activate user")
+		}
+	}
+	response := toAPIKeyResponse(apiKey)
+	response.Key = key
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// resolveAPIKeyOwner checks a new key against the registered user or
+// organization it names. A key of a registered user takes the user's
+// organization and at most the user's roles, and a key of a registered
+// organization may only name one of its users. Keys naming neither, such as
+// those of organizations managed outside the server, are created as given.
+// It returns the user, if any, and false once it has responded with an
+// error.
+func (h *Handlers) resolveAPIKeyOwner(w http.ResponseWriter, r *http.Request, req *APIKeyRequest) (*db.User, bool) {
+	requestID := GetRequestID(r.Context())
+	var user *db.User
+	if req.UserID != nil {
+		if id, err := uuid.Parse(*req.UserID); err == nil {
+			user, err = h.queries.GetUser(r.Context(), id)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get user", err), requestID))
+				return nil, false
+			}
+		}
+	}
+
+	if user != nil {
+		var invalid error
+		switch {
+		case req.OrganizationID != nil && *req.OrganizationID != user.OrganizationID:
+			invalid = fmt.Errorf("user '%s' belongs to organization '%s', not '%s'", user.ID, user.OrganizationID, *req.OrganizationID)
+		case user.Status == db.UserDisabled:
+			invalid = fmt.Errorf("user '%s' is disabled", user.ID)
+		default:
+			for i, role := range req.Roles {
+				if !slices.Contains(user.Roles, role) {
+					invalid = fmt.Errorf("roles[%d] '%s' is not a role of user '%s'", i, role, user.ID)
+					break
+				}
+			}
+		}
+		if invalid != nil {
+			respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", invalid), requestID))
+			return nil, false
+		}
+		req.OrganizationID = &user.OrganizationID
+		return user, true
+	}
+
+	if req.OrganizationID != nil && req.UserID != nil {
+		_, err := h.queries.GetOrganization(r.Context(), *req.OrganizationID)
+		if err == nil {
+			respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed",
+				fmt.Errorf("user_id must name a user of organization '%s'", *req.OrganizationID)), requestID))
+			return nil, false
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get organization", err), requestID))
+			return nil, false
+		}
+	}
+	return nil, true
+}
+
+// ListAPIKeys lists API keys, newest first, filtered by the organization_id
+// query parameter
+func (h *Handlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage API keys") {
+		return
+	}
+	var organizationID *string
+	if v := r.URL.Query().Get("organization_id"); v != "" {
+		organizationID = &v
+	}
+	keys, err := h.queries.ListAPIKeys(r.Context(), organizationID)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list API keys", err), GetRequestID(r.Context())))
+		return
+	}
+	responses := make([]APIKeyResponse, len(keys))
+	for i := range keys {
+		responses[i] = toAPIKeyResponse(&keys[i])
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+// RevokeAPIKey deletes an API key; requests made with it fail from then on
+func (h *Handlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage API keys") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	if err := h.keys.DeleteAPIKey(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to revoke API key", err), requestID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Organizations and users
+
+func (h *Handlers) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	var req OrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateOrganizationRequest(&req) }) {
+		return
+	}
+
+	org := &db.Organization{Name: req.Name, Metadata: db.FromMap(req.Metadata)}
+	if req.ID != nil {
+		org.ID = *req.ID
+	} else {
+		org.ID = uuid.New().String()
+	}
+	if err := h.queries.CreateOrganization(r.Context(), org); err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			respondError(w, WrapError(NewError(http.StatusConflict, "organization already exists", err), requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create organization", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusCreated, toOrganizationResponse(org))
+}
+
+func (h *Handlers) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	orgs, err := h.queries.ListOrganizations(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list organizations", err), GetRequestID(r.Context())))
+		return
+	}
+	responses := make([]OrganizationResponse, len(orgs))
+	for i := range orgs {
+		responses[i] = toOrganizationResponse(&orgs[i])
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+func (h *Handlers) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	org, err := h.queries.GetOrganization(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get organization", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toOrganizationResponse(org))
+}
+
+// UpdateOrganization replaces an organization's name and metadata; its ID
+// cannot change
+func (h *Handlers) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	var req OrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if req.ID != nil && *req.ID != id {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("an organization's id cannot be changed")), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateOrganizationRequest(&req) }) {
+		return
+	}
+
+	org := &db.Organization{ID: id, Name: req.Name, Metadata: db.FromMap(req.Metadata)}
+	if err := h.queries.UpdateOrganization(r.Context(), org); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update organization", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toOrganizationResponse(org))
+}
+
+// DeleteOrganization removes an organization with its users once no agent
+// or API key belongs to it
+func (h *Handlers) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	if err := h.queries.DeleteOrganization(r.Context(), mux.Vars(r)["id"]); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			respondError(w, WrapError(ErrNotFound, requestID))
+		case errors.Is(err, db.ErrInUse):
+			respondError(w, WrapError(NewError(http.StatusConflict, "organization still has agents or API keys", err), requestID))
+		default:
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete organization", err), requestID))
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InviteUser adds a user to an organization. The user is invited until its
+// first API key is created.
+func (h *Handlers) InviteUser(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateUserRequest(&req) }) {
+		return
+	}
+	org, err := h.queries.GetOrganization(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get organization", err), requestID))
+		return
+	}
+
+	user := &db.User{OrganizationID: org.ID, Email: req.Email, Name: req.Name, Roles: req.Roles}
+	if err := h.queries.CreateUser(r.Context(), user); err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			respondError(w, WrapError(NewError(http.StatusConflict, "the organization already has a user with this email", err), requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to invite user", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusCreated, toUserResponse(user))
+}
+
+func (h *Handlers) ListUsers(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	org, err := h.queries.GetOrganization(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get organization", err), requestID))
+		return
+	}
+	users, err := h.queries.ListUsers(r.Context(), org.ID)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list users", err), requestID))
+		return
+	}
+	responses := make([]UserResponse, len(users))
+	for i := range users {
+		responses[i] = toUserResponse(&users[i])
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	user, err := h.queries.GetUser(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get user", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toUserResponse(user))
+}
+
+// UpdateUser renames a user, assigns its roles or disables it. Its API keys
+// lose the roles it no longer has, and are revoked when it is disabled.
+func (h *Handlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	var req UserUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateUserUpdateRequest(&req) }) {
+		return
+	}
+
+	user, err := h.queries.GetUser(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get user", err), requestID))
+		return
+	}
+	if req.Name != nil {
+		user.Name = req.Name
+	}
+	if req.Roles != nil {
+		user.Roles = req.Roles
+	}
+	if req.Status != nil && *req.Status != user.Status {
+		// A user enabled again before its first key is still invited
+		if *req.Status == db.UserDisabled || user.Status == db.UserDisabled {
+			user.Status = *req.Status
+		}
+	}
+	if err := h.queries.UpdateUser(r.Context(), user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update user", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toUserResponse(user))
+}
+
+// DeleteUser removes a user and revokes its API keys. Agents it created
+// keep its ID as their owner.
+func (h *Handlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage organizations") {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	if err := h.queries.DeleteUser(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete user", err), requestID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Jobs
+
+func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage jobs") {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	job, err := h.queries.GetJob(r.Context(), id)
+	if err != nil {
+		respondError(w, WrapError(ErrNotFound, requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toJobResponse(job))
+}
+
+// RetryJob queues a failed job again with a fresh set of retries
+func (h *Handlers) RetryJob(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !requireAdmin(w, r, "manage jobs") {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, WrapError(ErrBadRequest, requestID))
+		return
+	}
+	job, err := h.queries.RetryJob(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			respondError(w, WrapError(ErrNotFound, requestID))
+		case errors.Is(err, db.ErrVersionConflict):
+			respondError(w, WrapError(NewError(http.StatusConflict, "only failed jobs can be retried", err), requestID))
+		default:
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to retry job", err), requestID))
+		}
+		return
+	}
+	metrics.RecordJobQueued()
+	respondJSON(w, http.StatusAccepted, toJobResponse(job))
+}
+
+// Tools
+
+// requireMemory refuses requests that embed texts with 503 when agent
+// memory is disabled
+func (h *Handlers) requireMemory(w http.ResponseWriter, r *http.Request) bool {
+	if h.runtime.MemoryEnabled() {
+		return true
+	}
+	respondError(w, WrapError(NewError(http.StatusServiceUnavailable, "agent memory is disabled", neurondb.ErrEmbeddingUnavailable), GetRequestID(r.Context())))
+	return false
+}
+
+// requireAdmin responds 403 and returns false unless the request's API key
+// has the admin role
+func requireAdmin(w http.ResponseWriter, r *http.Request, action string) bool {
+	apiKey := auth.APIKeyFromContext(r.Context())
+	if apiKey == nil {
+		respondError(w, WrapError(ErrUnauthorized, GetRequestID(r.Context())))
+		return false
+	}
+	if !auth.HasRole(apiKey, auth.RoleAdmin) {
+		respondError(w, WrapError(NewError(http.StatusForbidden, "insufficient permissions", fmt.Errorf("role %s required to %s", auth.RoleAdmin, action)), GetRequestID(r.Context())))
+		return false
+	}
+	return true
+}
+
+// ListTools lists every tool, including disabled ones
+func (h *Handlers) ListTools(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage tools") {
+		return
+	}
+	list, err := h.tools.ListAllTools(r.Context())
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list tools", err), requestID))
+		return
+	}
+
+	responses := make([]ToolResponse, len(list))
+	for i := range list {
+		responses[i] = toToolResponse(&list[i])
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+func (h *Handlers) GetTool(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage tools") {
+		return
+	}
+	tool, err := h.queries.GetTool(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		requestID := GetRequestID(r.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+		} else {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get tool", err), requestID))
+		}
+		return
+	}
+
+	setVersionETag(w, tool.Version)
+	respondJSON(w, http.StatusOK, toToolResponse(tool))
+}
+
+// CreateTool validates and stores a tool, running it first if the request
+// has test_args
+func (h *Handlers) CreateTool(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage tools") {
+		return
+	}
+	requestID := GetRequestID(r.Context())
+
+	var req ToolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "tool creation failed: request body parsing error", err), requestID))
+		return
+	}
+	tool, testResult, ok := h.prepareTool(w, r, &req)
+	if !ok {
+		return
+	}
+
+	if err := h.queries.CreateTool(r.Context(), tool); err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			respondError(w, WrapError(NewError(http.StatusConflict, "tool already exists", err), requestID))
+		} else {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "tool creation failed", err), requestID))
+		}
+		return
+	}
+	h.tools.Invalidate(tool.Name)
+
+	resp := toToolResponse(tool)
+	resp.TestResult = testResult
+	setVersionETag(w, tool.Version)
+	respondJSON(w, http.StatusCreated, resp)
+}
+
+// UpdateTool replaces a tool's definition. Like agent updates it honours
+// If-Match and rejects concurrent changes with 409.
+func (h *Handlers) UpdateTool(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage tools") {
+		return
+	}
+	requestID := GetRequestID(r.Context())
+	name := mux.Vars(r)["name"]
+
+	var req ToolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if req.Name == "" {
+		req.Name = name
+	} else if req.Name != name {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", fmt.Errorf("name '%s' does not match the tool '%s' being updated; tools cannot be renamed", req.Name, name)), requestID))
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid If-Match header", err), requestID))
+		return
+	}
+
+	current, err := h.queries.GetTool(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+		} else {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get tool", err), requestID))
+		}
+		return
+	}
+	if !versionMatches(current.Version, ifMatch) {
+		respondError(w, WrapError(versionConflictError("tool", current.Version), requestID))
+		return
+	}
+
+	tool, testResult, ok := h.prepareTool(w, r, &req)
+	if !ok {
+		return
+	}
+	tool.Version = current.Version
+	tool.CreatedAt = current.CreatedAt
+
+	if err := h.queries.UpdateTool(r.Context(), tool); err != nil {
+		switch {
+		case errors.Is(err, db.ErrVersionConflict):
+			respondError(w, WrapError(NewError(http.StatusConflict, "tool was modified by another request", err), requestID))
+		case errors.Is(err, sql.ErrNoRows):
+			respondError(w, WrapError(ErrNotFound, requestID))
+		default:
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update tool", err), requestID))
+		}
+		return
+	}
+	h.tools.Invalidate(tool.Name)
+
+	resp := toToolResponse(tool)
+	resp.TestResult = testResult
+	setVersionETag(w, tool.Version)
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handlers) DeleteTool(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "manage tools") {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if err := h.queries.DeleteTool(r.Context(), name); err != nil {
+		requestID := GetRequestID(r.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+		} else {
+			respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete tool", err), requestID))
+		}
+		return
+	}
+	h.tools.Invalidate(name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// prepareTool validates req and builds the tool it defines. If req has
+// test_args the tool is run with them, and a failed run is reported as 422.
+func (h *Handlers) prepareTool(w http.ResponseWriter, r *http.Request, req *ToolRequest) (*db.Tool, *ToolTestResult, bool) {
+	requestID := GetRequestID(r.Context())
+	if err := ValidateToolRequest(req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return nil, nil, false
+	}
+
+	tool := &db.Tool{
+		Name:          req.Name,
+		Description:   req.Description,
+		ArgSchema:     db.FromMap(req.ArgSchema),
+		HandlerType:   req.HandlerType,
+		HandlerConfig: db.FromMap(req.HandlerConfig),
+		Enabled:       req.Enabled == nil || *req.Enabled,
+	}
+	if tool.HandlerConfig == nil {
+		tool.HandlerConfig = db.JSONBMap{}
+	}
+	if err := h.tools.ValidateTool(tool); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return nil, nil, false
+	}
+	if err := h.checkSecretReferences(r.Context(), tool); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "validation failed", err), requestID))
+		return nil, nil, false
+	}
+	if req.TestArgs == nil {
+		return tool, nil, true
+	}
+
+	start := time.Now()
+	output, err := h.tools.TestTool(r.Context(), tool, req.TestArgs)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusUnprocessableEntity, "tool test invocation failed", err), requestID))
+		return nil, nil, false
+	}
+	return tool, &ToolTestResult{
+		Output:     output,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}, true
+}
+
+// checkSecretReferences checks that the secrets a tool references exist
+func (h *Handlers) checkSecretReferences(ctx context.Context, tool *db.Tool) error {
+	names := secrets.References(tool.HandlerConfig.ToMap())
+	if len(names) == 0 {
+		return nil
+	}
+	if h.secrets == nil {
+		return fmt.Errorf("handler_config references secrets but no secrets master key is configured")
+	}
+	for _, name := range names {
+		if _, err := h.queries.GetSecret(ctx, name); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("handler_config references secret '%s', which does not exist", name)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Secrets
+
+// requireSecrets refuses secrets requests with 503 when no master key is
+// configured
+func (h *Handlers) requireSecrets(w http.ResponseWriter, r *http.Request) bool {
+	if !requireAdmin(w, r, "manage secrets") {
+		return false
+	}
+	if h.secrets != nil {
+		return true
+	}
+	respondError(w, WrapError(NewError(http.StatusServiceUnavailable, "secrets are disabled",
+		fmt.Errorf("no secrets master key is configured")), GetRequestID(r.Context())))
+	return false
+}
+
+// secretUsers maps secret names to the sorted names of the tools
+// referencing them
+func (h *Handlers) secretUsers(ctx context.Context) (map[string][]string, error) {
+	list, err := h.tools.ListAllTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string][]string{}
+	for i := range list {
+		for _, name := range secrets.References(list[i].HandlerConfig.ToMap()) {
+			users[name] = append(users[name], list[i].Name)
+		}
+	}
+	for name := range users {
+		slices.Sort(users[name])
+	}
+	return users, nil
+}
+
+// CreateSecret encrypts and stores a secret
+func (h *Handlers) CreateSecret(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireSecrets(w, r) {
+		return
+	}
+	var req SecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateSecretRequest(&req) }) {
+		return
+	}
+
+	secret, err := h.secrets.Create(r.Context(), req.Name, req.Description, req.Value)
+	if err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			respondError(w, WrapError(NewError(http.StatusConflict, "secret already exists", err), requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to create secret", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusCreated, toSecretResponse(secret, nil))
+}
+
+func (h *Handlers) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireSecrets(w, r) {
+		return
+	}
+	list, err := h.queries.ListSecrets(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list secrets", err), requestID))
+		return
+	}
+	users, err := h.secretUsers(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list tools", err), requestID))
+		return
+	}
+	responses := make([]SecretResponse, len(list))
+	for i := range list {
+		responses[i] = toSecretResponse(&list[i], users[list[i].Name])
+	}
+	respondJSON(w, http.StatusOK, responses)
+}
+
+func (h *Handlers) GetSecret(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireSecrets(w, r) {
+		return
+	}
+	secret, err := h.queries.GetSecret(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to get secret", err), requestID))
+		return
+	}
+	users, err := h.secretUsers(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list tools", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toSecretResponse(secret, users[secret.Name]))
+}
+
+// UpdateSecret replaces a secret's value, re-encrypting it under the
+// current master key, or its description. Tools use a new value from their
+// next call.
+func (h *Handlers) UpdateSecret(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireSecrets(w, r) {
+		return
+	}
+	var req SecretUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateSecretUpdateRequest(&req) }) {
+		return
+	}
+
+	secret, err := h.secrets.Update(r.Context(), mux.Vars(r)["name"], req.Description, req.Value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to update secret", err), requestID))
+		return
+	}
+	users, err := h.secretUsers(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list tools", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, toSecretResponse(secret, users[secret.Name]))
+}
+
+// DeleteSecret removes a secret once no tool references it
+func (h *Handlers) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireSecrets(w, r) {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	users, err := h.secretUsers(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to list tools", err), requestID))
+		return
+	}
+	if len(users[name]) > 0 {
+		respondError(w, WrapError(NewError(http.StatusConflict, "secret is referenced by tools",
+			fmt.Errorf("secret '%s' is referenced by tools %v", name, users[name])), requestID))
+		return
+	}
+	if err := h.queries.DeleteSecret(r.Context(), name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondError(w, WrapError(ErrNotFound, requestID))
+			return
+		}
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to delete secret", err), requestID))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateSecretsKey rewraps every secret's data key with the current master
+// key, after the master key was replaced
+func (h *Handlers) RotateSecretsKey(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireSecrets(w, r) {
+		return
+	}
+	result, err := h.secrets.RotateKeys(r.Context())
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "failed to rotate secrets key", err), requestID))
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// StartDrain takes the server out of service ahead of a deploy. The drain
+// continues in the background; its status is returned as it starts.
+func (h *Handlers) StartDrain(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+	if !h.requireDrainer(w, r) {
+		return
+	}
+	var req DrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondError(w, WrapError(NewError(http.StatusBadRequest, "invalid request body", err), requestID))
+		return
+	}
+	if !ValidateAndRespond(w, func() error { return ValidateDrainRequest(&req) }) {
+		return
+	}
+
+	var timeout time.Duration
+	if req.TimeoutSeconds != nil {
+		timeout = time.Duration(*req.TimeoutSeconds) * time.Second
+	}
+	if !h.drainer.Start(timeout) {
+		respondJSON(w, http.StatusOK, h.drainer.Status())
+		return
+	}
+	respondJSON(w, http.StatusAccepted, h.drainer.Status())
+}
+
+// GetDrainStatus reports the drain of the server
+func (h *Handlers) GetDrainStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDrainer(w, r) {
+		return
+	}
+	respondJSON(w, http.StatusOK, h.drainer.Status())
+}
+
+// requireDrainer refuses drain requests with 503 when no drainer is set
+func (h *Handlers) requireDrainer(w http.ResponseWriter, r *http.Request) bool {
+	if !requireAdmin(w, r, "drain the server") {
+		return false
+	}
+	if h.drainer != nil {
+		return true
+	}
+	respondError(w, WrapError(NewError(http.StatusServiceUnavailable, "draining is disabled",
+		fmt.Errorf("the server has no drainer")), GetRequestID(r.Context())))
+	return false
 }
 
 // Helper functions
@@ -360,11 +3995,274 @@ func toAgentResponse(a *db.Agent) AgentResponse {
 		MemoryTable:  a.MemoryTable,
 		EnabledTools: a.EnabledTools,
 		Config:       a.Config.ToMap(),
+		OrganizationID: a.OrganizationID,
+		OwnerUserID:  a.OwnerUserID,
+		PromptTemplateID: a.PromptTemplateID,
+		Version:      a.Version,
 		CreatedAt:    a.CreatedAt,
 		UpdatedAt:    a.UpdatedAt,
 	}
 }
 
+func toToolResponse(t *db.Tool) ToolResponse {
+	return ToolResponse{
+		Name:          t.Name,
+		Description:   t.Description,
+		ArgSchema:     t.ArgSchema.ToMap(),
+		HandlerType:   t.HandlerType,
+		HandlerConfig: t.HandlerConfig.ToMap(),
+		Enabled:       t.Enabled,
+		Version:       t.Version,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
+
+func toAPIKeyResponse(k *db.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:              k.ID,
+		KeyPrefix:       k.KeyPrefix,
+		OrganizationID:  k.OrganizationID,
+		UserID:          k.UserID,
+		RateLimitPerMin: k.RateLimitPerMin,
+		Roles:           k.Roles,
+		CreatedAt:       k.CreatedAt,
+		LastUsedAt:      k.LastUsedAt,
+		ExpiresAt:       k.ExpiresAt,
+	}
+}
+
+func toOrganizationResponse(o *db.Organization) OrganizationResponse {
+	return OrganizationResponse{
+		ID:        o.ID,
+		Name:      o.Name,
+		Metadata:  o.Metadata.ToMap(),
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+func toSecretResponse(s *db.Secret, usedBy []string) SecretResponse {
+	if usedBy == nil {
+		usedBy = []string{}
+	}
+	return SecretResponse{
+		Name:        s.Name,
+		Description: s.Description,
+		KeyID:       s.KeyID,
+		Version:     s.Version,
+		UsedBy:      usedBy,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
+
+func toUserResponse(u *db.User) UserResponse {
+	return UserResponse{
+		ID:             u.ID,
+		OrganizationID: u.OrganizationID,
+		Email:          u.Email,
+		Name:           u.Name,
+		Roles:          u.Roles,
+		Status:         u.Status,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+	}
+}
+
+func toJobResponse(j *db.Job) JobResponse {
+	return JobResponse{
+		ID:          j.ID,
+		AgentID:     j.AgentID,
+		SessionID:   j.SessionID,
+		Type:        j.Type,
+		Status:      j.Status,
+		Priority:    j.Priority,
+		Payload:     j.Payload.ToMap(),
+		Result:      j.Result.ToMap(),
+		Error:       j.ErrorMessage,
+		RetryCount:  j.RetryCount,
+		MaxRetries:  j.MaxRetries,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+		StartedAt:   j.StartedAt,
+		CompletedAt: j.CompletedAt,
+	}
+}
+
+func toMemoryBackfillResponse(job *db.Job) (*MemoryBackfillResponse, error) {
+	req, err := agent.ParseMemoryBackfillRequest(job.Payload)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := agent.ParseMemoryBackfillProgress(job.Result)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryBackfillResponse{
+		JobID:       job.ID,
+		AgentID:     *job.AgentID,
+		Status:      job.Status,
+		Request:     req,
+		Progress:    progress,
+		Error:       job.ErrorMessage,
+		RetryCount:  job.RetryCount,
+		CreatedAt:   job.CreatedAt,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+	}, nil
+}
+
+func toMemoryChunkResponse(chunk *db.MemoryChunk) MemoryChunkResponse {
+	return MemoryChunkResponse{
+		ID:              chunk.ID,
+		AgentID:         chunk.AgentID,
+		SessionID:       chunk.SessionID,
+		MessageID:       chunk.MessageID,
+		Content:         chunk.Content,
+		ImportanceScore: chunk.ImportanceScore,
+		Pinned:          chunk.Pinned,
+		Metadata:        chunk.Metadata.ToMap(),
+		CreatedAt:       chunk.CreatedAt,
+	}
+}
+
+func toDocumentUploadResponse(job *db.Job) (*DocumentUploadResponse, error) {
+	req, err := agent.ParseDocumentIngestRequest(job.Payload)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := agent.ParseDocumentIngestProgress(job.Result)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentUploadResponse{
+		JobID:       job.ID,
+		AgentID:     *job.AgentID,
+		SessionID:   req.SessionID,
+		Status:      job.Status,
+		Filename:    req.Filename,
+		MIMEType:    req.MIMEType,
+		SizeBytes:   req.SizeBytes,
+		SHA256:      req.SHA256,
+		Progress:    progress,
+		Error:       job.ErrorMessage,
+		RetryCount:  job.RetryCount,
+		CreatedAt:   job.CreatedAt,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+	}, nil
+}
+
+func toMemoryReembedResponse(job *db.Job) (*MemoryReembedResponse, error) {
+	req, err := agent.ParseMemoryReembedRequest(job.Payload)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := agent.ParseMemoryReembedProgress(job.Result)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryReembedResponse{
+		JobID:       job.ID,
+		AgentID:     *job.AgentID,
+		Status:      job.Status,
+		Request:     req,
+		Progress:    progress,
+		Error:       job.ErrorMessage,
+		RetryCount:  job.RetryCount,
+		CreatedAt:   job.CreatedAt,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+	}, nil
+}
+
+func toToolApprovalResponse(a *db.ToolApproval) (*ToolApprovalResponse, error) {
+	calls, err := agent.ParseApprovalToolCalls(a.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	response := &ToolApprovalResponse{
+		ID:          a.ID,
+		SessionID:   a.SessionID,
+		AgentID:     a.AgentID,
+		Status:      a.Status,
+		ToolCalls:   calls,
+		DecidedBy:   a.DecidedBy,
+		Reason:      a.Reason,
+		Error:       a.ErrorMessage,
+		CreatedAt:   a.CreatedAt,
+		ExpiresAt:   a.ExpiresAt,
+		DecidedAt:   a.DecidedAt,
+		CompletedAt: a.CompletedAt,
+	}
+	if len(a.Result) > 0 {
+		response.Result = a.Result.ToMap()
+	}
+	return response, nil
+}
+
+// toRunResponse converts a run. The tool calls of an unfinished run are
+// included, so callers can see what it is waiting on.
+func toRunResponse(run *db.Run) (*RunResponse, error) {
+	response := &RunResponse{
+		ID:          run.ID,
+		SessionID:   run.SessionID,
+		AgentID:     run.AgentID,
+		Status:      run.Status,
+		Step:        run.Step,
+		Attempts:    run.Attempts,
+		ApprovalID:  run.ApprovalID,
+		Error:       run.ErrorMessage,
+		HeartbeatAt: run.HeartbeatAt,
+		CreatedAt:   run.CreatedAt,
+		UpdatedAt:   run.UpdatedAt,
+		CompletedAt: run.CompletedAt,
+	}
+	if len(run.Result) > 0 {
+		response.Result = run.Result.ToMap()
+	}
+	if run.Status == db.RunRunning || run.Status == db.RunAwaitingApproval {
+		calls, err := agent.RunToolCalls(run)
+		if err != nil {
+			return nil, err
+		}
+		response.ToolCalls = calls
+	}
+	return response, nil
+}
+
+func toWebhookResponse(w *db.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:          w.ID,
+		URL:         w.URL,
+		Events:      w.Events,
+		SecretEnv:   w.SecretEnv,
+		Description: w.Description,
+		Enabled:     w.Enabled,
+		CreatedAt:   w.CreatedAt,
+		UpdatedAt:   w.UpdatedAt,
+	}
+}
+
+func toWebhookDeliveryResponse(d *db.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		EventID:        d.EventID,
+		Event:          d.Event,
+		URL:            d.URL,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		Payload:        d.Payload,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
+
 func toSessionResponse(s *db.Session) SessionResponse {
 	return SessionResponse{
 		ID:             s.ID,
@@ -373,7 +4271,71 @@ func toSessionResponse(s *db.Session) SessionResponse {
 		Metadata:       s.Metadata.ToMap(),
 		CreatedAt:      s.CreatedAt,
 		LastActivityAt: s.LastActivityAt,
+		OrganizationID: s.OrganizationID,
+		PromptVersionID: s.PromptVersionID,
+		Archived:       s.ArchivedAt != nil,
+		ArchivedAt:     s.ArchivedAt,
+	}
+}
+
+func toSessionSummaryResponse(s *db.ConversationSummary, generated bool) SessionSummaryResponse {
+	resp := SessionSummaryResponse{
+		SessionID:        s.SessionID,
+		Summary:          s.Summary,
+		Style:            s.Style,
+		Length:           s.Length,
+		MessageCount:     s.MessageCount,
+		ThroughMessageID: s.ThroughMessageID,
+		Cached:           !generated,
+	}
+	if !s.UpdatedAt.IsZero() {
+		resp.CreatedAt = &s.CreatedAt
+		resp.UpdatedAt = &s.UpdatedAt
+	}
+	return resp
+}
+
+// toTurnTraceResponse builds the trace of a run's turn, finding the
+// messages it stored among messages
+func toTurnTraceResponse(run *db.Run, trace *agent.RunTrace, messages []db.Message) TurnTraceResponse {
+	turn := TurnTraceResponse{
+		RunID:            run.ID,
+		Status:           run.Status,
+		Step:             run.Step,
+		Attempts:         run.Attempts,
+		ApprovalID:       run.ApprovalID,
+		Error:            run.ErrorMessage,
+		StartedAt:        run.CreatedAt,
+		CompletedAt:      run.CompletedAt,
+		SemanticCacheHit: trace.SemanticCacheHit,
+		LLMCalls:         trace.LLMCalls,
+		ToolCalls:        trace.ToolCalls,
 	}
+	if run.CompletedAt != nil {
+		duration := float64(run.CompletedAt.Sub(run.CreatedAt).Microseconds()) / 1000
+		turn.DurationMs = &duration
+	}
+	for _, call := range trace.LLMCalls {
+		turn.Usage.PromptTokens += call.Usage.PromptTokens
+		turn.Usage.CompletionTokens += call.Usage.CompletionTokens
+		turn.Usage.TotalTokens += call.Usage.TotalTokens
+		turn.CostUSD += call.CostUSD
+	}
+	runID := run.ID.String()
+	for i := range messages {
+		m := &messages[i]
+		if m.Metadata["run_id"] != runID {
+			continue
+		}
+		message := &TraceMessageResponse{ID: m.ID, Chars: len(m.Content), TokenCount: m.TokenCount, CreatedAt: m.CreatedAt}
+		switch m.Role {
+		case "user":
+			turn.UserMessage = message
+		case "assistant":
+			turn.AssistantMessage = message
+		}
+	}
+	return turn
 }
 
 func toMessageResponse(m *db.Message) MessageResponse {
@@ -390,10 +4352,28 @@ func toMessageResponse(m *db.Message) MessageResponse {
 		ToolCallID: m.ToolCallID,
 		TokenCount: m.TokenCount,
 		Metadata:   metadata,
+		PromptVersionID: m.PromptVersionID,
 		CreatedAt:  m.CreatedAt,
 	}
 }
 
+func toFeedbackResponse(f *db.MessageFeedback) FeedbackResponse {
+	return FeedbackResponse{
+		ID:        f.ID,
+		MessageID: f.MessageID,
+		SessionID: f.SessionID,
+		AgentID:   f.AgentID,
+		APIKeyID:  f.APIKeyID,
+		Rating:    f.Rating,
+		Score:     f.Score,
+		Comment:   f.Comment,
+		Metadata:  f.Metadata.ToMap(),
+		PromptVersionID: f.PromptVersionID,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -401,6 +4381,16 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 }
 
 func respondError(w http.ResponseWriter, err *APIError) {
+	// A body cut off by BodyLimitMiddleware fails to parse; say why
+	var tooLarge *http.MaxBytesError
+	if err.Code == http.StatusBadRequest && errors.As(err.Err, &tooLarge) {
+		err = &APIError{
+			Code:      http.StatusRequestEntityTooLarge,
+			Message:   "request body too large",
+			Err:       fmt.Errorf("request body exceeds the %d byte limit", tooLarge.Limit),
+			RequestID: err.RequestID,
+		}
+	}
 	response := ErrorResponse{
 		Error: err.Message,
 		Code:  err.Code,
@@ -411,5 +4401,9 @@ func respondError(w http.ResponseWriter, err *APIError) {
 	if err.RequestID != "" {
 		w.Header().Set("X-Request-ID", err.RequestID)
 	}
+	if err.RetryAfter > 0 {
+		response.RetryAfter = err.RetryAfter
+		w.Header().Set("Retry-After", strconv.Itoa(err.RetryAfter))
+	}
 	respondJSON(w, err.Code, response)
 }
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/neurondb/NeuronAgent/internal/agent"
+)
+
+// HandleCapabilities reports the NeuronDB functions found at startup and
+// the fallbacks in use where they are missing
+func HandleCapabilities(runtime *agent.Runtime) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, runtime.Capabilities())
+	}
+}
@@ -1,26 +1,31 @@
 package api
 
 import (
-	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
 	"github.com/neurondb/NeuronAgent/internal/metrics"
 )
 
 type contextKey string
 
-const apiKeyContextKey contextKey = "api_key"
-
-// AuthMiddleware authenticates requests using API keys
-func AuthMiddleware(keyManager *auth.APIKeyManager, rateLimiter *auth.RateLimiter) func(http.Handler) http.Handler {
+// AuthMiddleware authenticates requests using API keys, or JWT bearer
+// tokens when an OIDC authenticator is configured. Both resolve to an API
+// key record, so the rest of the server treats them alike. Authenticated
+// requests are then held to the key's rate limit and, when quotas is set,
+// to the daily quotas of the key and its organization.
+func AuthMiddleware(keyManager *auth.APIKeyManager, oidc *auth.OIDCAuthenticator, rateLimiter auth.Limiter, quotas *auth.Quotas) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip auth for health and metrics endpoints
-			if r.URL.Path == "/health" || r.URL.Path == "/metrics" {
+			if r.URL.Path == "/health" || r.URL.Path == "/health/ready" || r.URL.Path == "/health/capabilities" || r.URL.Path == "/metrics" {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -42,6 +47,20 @@ func AuthMiddleware(keyManager *auth.APIKeyManager, rateLimiter *auth.RateLimite
 			}
 
 			key := parts[1]
+			if oidc != nil && strings.EqualFold(parts[0], "Bearer") && auth.IsJWT(key) {
+				apiKey, err := oidc.Authenticate(r.Context(), key)
+				if err != nil {
+					metrics.Logger().Warn().Err(err).Str("request_id", GetRequestID(r.Context())).Msg("Token authentication failed")
+					respondError(w, WrapError(ErrUnauthorized, GetRequestID(r.Context())))
+					return
+				}
+				if !admitRequest(w, r, rateLimiter, quotas, apiKey) {
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), apiKey)))
+				return
+			}
+
 			keyPrefix := key
 			if len(keyPrefix) > 8 {
 				keyPrefix = keyPrefix[:8]
@@ -63,25 +82,191 @@ func AuthMiddleware(keyManager *auth.APIKeyManager, rateLimiter *auth.RateLimite
 			}
 			fmt.Printf("[MIDDLEWARE] Authentication succeeded: prefix=%s\n", apiKey.KeyPrefix)
 
-			// Check rate limit
-			if !rateLimiter.CheckLimit(apiKey.ID.String(), apiKey.RateLimitPerMin) {
-				requestID := GetRequestID(r.Context())
-				respondError(w, WrapError(NewError(http.StatusTooManyRequests, "rate limit exceeded", nil), requestID))
+			// Check rate limit and quotas
+			if !admitRequest(w, r, rateLimiter, quotas, apiKey) {
 				return
 			}
 
 			// Add API key to context
-			ctx := context.WithValue(r.Context(), apiKeyContextKey, apiKey)
+			next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), apiKey)))
+		})
+	}
+}
+
+// admitRequest counts a request of apiKey against its rate limit and daily
+// quotas and reports their state in the response headers. A request over
+// either is refused with 429 and a Retry-After header.
+func admitRequest(w http.ResponseWriter, r *http.Request, limiter auth.Limiter, quotas *auth.Quotas, apiKey *db.APIKey) bool {
+	requestID := GetRequestID(r.Context())
+	status, err := limiter.Take(r.Context(), apiKey.ID.String(), apiKey.RateLimitPerMin)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "rate limit check failed", err), requestID))
+		return false
+	}
+	header := w.Header()
+	header.Set("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(status.Reset)))
+	if !status.Allowed {
+		metrics.RecordRateLimited("rate_limit")
+		respondError(w, WrapError(NewError(http.StatusTooManyRequests, "rate limit exceeded", nil), requestID).
+			WithRetryAfter(max(ceilSeconds(status.RetryAfter), 1)))
+		return false
+	}
+
+	if quotas == nil {
+		return true
+	}
+	quota, err := quotas.Check(r.Context(), apiKey)
+	if err != nil {
+		respondError(w, WrapError(NewError(http.StatusInternalServerError, "quota check failed", err), requestID))
+		return false
+	}
+	if quota == nil {
+		return true
+	}
+	if quota.RequestsLimit > 0 {
+		header.Set("X-Quota-Requests-Limit", strconv.FormatInt(quota.RequestsLimit, 10))
+		header.Set("X-Quota-Requests-Remaining", strconv.FormatInt(quota.RequestsRemaining, 10))
+	}
+	if quota.TokensLimit > 0 {
+		header.Set("X-Quota-Tokens-Limit", strconv.FormatInt(quota.TokensLimit, 10))
+		header.Set("X-Quota-Tokens-Remaining", strconv.FormatInt(quota.TokensRemaining, 10))
+	}
+	header.Set("X-Quota-Reset", strconv.Itoa(ceilSeconds(quota.Reset)))
+	if !quota.Allowed {
+		metrics.RecordRateLimited("quota_" + quota.ExceededScope)
+		err := fmt.Errorf("daily %s quota of the %s is used up until midnight UTC", quota.ExceededUnit, strings.ReplaceAll(quota.ExceededScope, "_", " "))
+		respondError(w, WrapError(NewError(http.StatusTooManyRequests, "daily quota exceeded", err), requestID).
+			WithRetryAfter(ceilSeconds(quota.Reset)))
+		return false
+	}
+	return true
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// OrganizationMiddleware scopes the agent and session queries of a request
+// to the organization of its API key, so a key only reaches the agents,
+// sessions and memory of its own organization. Admin keys are not scoped
+// and reach every organization. Routes naming an agent, session, message or
+// run are checked here too, so a resource of another organization is
+// reported as not found before its handler runs.
+func OrganizationMiddleware(queries *db.Queries) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := auth.APIKeyFromContext(r.Context())
+			if apiKey == nil || auth.HasRole(apiKey, auth.RoleAdmin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := db.WithOrganization(r.Context(), apiKey.OrganizationID)
+			if !routeInOrganization(r.WithContext(ctx), queries) {
+				respondError(w, WrapError(ErrNotFound, GetRequestID(ctx)))
+				return
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// routeInOrganization reports whether the agent, session, message or run
+// named by the route of r is visible in the organization r is scoped to. IDs
+// that do not parse are left for the handler to reject.
+func routeInOrganization(r *http.Request, queries *db.Queries) bool {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	if id, err := uuid.Parse(vars["agent_id"]); err == nil {
+		if _, err := queries.GetAgentByID(ctx, id); err != nil {
+			return false
+		}
+	}
+	if id, err := uuid.Parse(vars["session_id"]); err == nil {
+		if !sessionInOrganization(r, queries, id) {
+			return false
+		}
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ := route.GetPathTemplate()
+		if strings.HasPrefix(template, "/api/v1/agents/{id}") {
+			if id, err := uuid.Parse(vars["id"]); err == nil {
+				if _, err := queries.GetAgentByID(ctx, id); err != nil {
+					return false
+				}
+			}
+		}
+		if strings.HasPrefix(template, "/api/v1/messages/{id}") {
+			if id, err := strconv.ParseInt(vars["id"], 10, 64); err == nil {
+				message, err := queries.GetMessage(ctx, id)
+				if err != nil {
+					// Handlers report messages that do not exist
+					return true
+				}
+				return sessionInOrganization(r, queries, message.SessionID)
+			}
+		}
+		if strings.HasPrefix(template, "/api/v1/runs/{id}") {
+			if id, err := uuid.Parse(vars["id"]); err == nil {
+				run, err := queries.GetRun(ctx, id)
+				if err != nil {
+					// Handlers report runs that do not exist
+					return true
+				}
+				return sessionInOrganization(r, queries, run.SessionID)
+			}
+		}
+	}
+	return true
+}
+
+// sessionInOrganization reports whether a live or archived session is in
+// the organization r is scoped to
+func sessionInOrganization(r *http.Request, queries *db.Queries, id uuid.UUID) bool {
+	if _, err := queries.GetSession(r.Context(), id); err == nil {
+		return true
+	}
+	_, err := queries.GetArchivedSession(r.Context(), id)
+	return err == nil
+}
+
+// BodyLimitMiddleware refuses request bodies over limit bytes with 413.
+// Routes in routeLimits, keyed by path template, have their own limit. A
+// body announcing a larger Content-Length is refused before it is read;
+// others are cut off when they pass the limit, and the handler reading them
+// responds with 413.
+func BodyLimitMiddleware(limit int64, routeLimits map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			max := limit
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					if routeLimit, ok := routeLimits[template]; ok {
+						max = routeLimit
+					}
+				}
+			}
+			if max > 0 && r.Body != nil && r.Body != http.NoBody {
+				if r.ContentLength > max {
+					respondError(w, WrapError(NewError(http.StatusRequestEntityTooLarge, "request body too large",
+						fmt.Errorf("request body of %d bytes exceeds the %d byte limit", r.ContentLength, max)), GetRequestID(r.Context())))
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, max)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // CORSMiddleware adds CORS headers
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -121,3 +306,8 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/session"
 )
 
 // Request DTOs
@@ -16,6 +20,40 @@ type CreateAgentRequest struct {
 	MemoryTable  *string                `json:"memory_table"`
 	EnabledTools []string               `json:"enabled_tools"`
 	Config       map[string]interface{} `json:"config"`
+	// OrganizationID creates the agent in another organization than the
+	// API key's. Only admin keys may set it, and only on creation.
+	OrganizationID *string              `json:"organization_id,omitempty"`
+	// PromptTemplateID serves sessions the versions of a prompt template
+	// instead of SystemPrompt
+	PromptTemplateID *uuid.UUID         `json:"prompt_template_id"`
+}
+
+// AgentOverrides replace fields of the agent created from a template or a
+// clone. Config keys are merged over the source config, and a null value
+// removes a key.
+type AgentOverrides struct {
+	Description      *string                `json:"description"`
+	SystemPrompt     *string                `json:"system_prompt"`
+	ModelName        *string                `json:"model_name"`
+	MemoryTable      *string                `json:"memory_table"`
+	EnabledTools     []string               `json:"enabled_tools"`
+	Config           map[string]interface{} `json:"config"`
+	OrganizationID   *string                `json:"organization_id,omitempty"`
+	PromptTemplateID *uuid.UUID             `json:"prompt_template_id"`
+}
+
+// CreateAgentFromTemplateRequest creates an agent from a built-in template
+type CreateAgentFromTemplateRequest struct {
+	Template   string                 `json:"template"`
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters"`
+	AgentOverrides
+}
+
+// CloneAgentRequest creates a copy of an agent under a new name
+type CloneAgentRequest struct {
+	Name string `json:"name"`
+	AgentOverrides
 }
 
 type CreateSessionRequest struct {
@@ -29,6 +67,148 @@ type SendMessageRequest struct {
 	Content  string                 `json:"content"`
 	Stream   bool                   `json:"stream"`
 	Metadata map[string]interface{} `json:"metadata"`
+	// Attachments are files sent with the message
+	Attachments []AttachmentRequest `json:"attachments"`
+}
+
+// AttachmentRequest is a file sent with a message, either inline as
+// base64 in data or by a URL to fetch it from, such as a presigned URL
+type AttachmentRequest struct {
+	Filename string `json:"filename"`
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"` // base64 in JSON
+	URL      string `json:"url"`
+}
+
+type ImportSessionRequest struct {
+	Archive      *session.Archive  `json:"archive"`
+	AgentID      *uuid.UUID        `json:"agent_id"`
+	AgentMapping map[string]string `json:"agent_mapping"` // archived agent ID -> local agent ID
+	CreateAgent  bool              `json:"create_agent"`
+}
+
+// MessageFeedbackRequest rates an assistant message. At least one of
+// rating, score and comment is required.
+type MessageFeedbackRequest struct {
+	Rating   *string                `json:"rating"` // "up" or "down"
+	Score    *int                   `json:"score"`  // 1 to 5
+	Comment  *string                `json:"comment"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// PromptTemplateRequest creates a prompt template
+type PromptTemplateRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+// PromptTemplateVersionRequest adds a version to a prompt template.
+// Variables maps each {{name}} placeholder of content to its default, or to
+// null when sessions must give it. RolloutWeight defaults to 0, so a new
+// version serves no sessions until it is rolled out.
+type PromptTemplateVersionRequest struct {
+	Content       string                 `json:"content"`
+	Variables     map[string]interface{} `json:"variables"`
+	RolloutWeight int                    `json:"rollout_weight"`
+}
+
+// PromptTemplateRolloutRequest sets the rollout weights of a prompt
+// template's versions, keyed by version number. Versions not listed get a
+// weight of 0.
+type PromptTemplateRolloutRequest struct {
+	Weights map[int]int `json:"weights"`
+}
+
+// ToolApprovalDecisionRequest approves or rejects a paused run's tool
+// calls. DecidedBy defaults to the deciding API key's user.
+type ToolApprovalDecisionRequest struct {
+	Reason    *string `json:"reason"`
+	DecidedBy *string `json:"decided_by"`
+}
+
+// WebhookRequest registers a webhook or replaces its settings. Enabled
+// defaults to true.
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	SecretEnv   *string  `json:"secret_env"`
+	Description *string  `json:"description"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// ToolRequest defines a tool. test_args, when set, runs the tool with those
+// arguments before it is saved; a failed run rejects the request.
+type ToolRequest struct {
+	Name          string                 `json:"name"`
+	Description   string                 `json:"description"`
+	ArgSchema     map[string]interface{} `json:"arg_schema"`
+	HandlerType   string                 `json:"handler_type"`
+	HandlerConfig map[string]interface{} `json:"handler_config"`
+	Enabled       *bool                  `json:"enabled"` // defaults to true
+	TestArgs      map[string]interface{} `json:"test_args"`
+}
+
+// APIKeyRequest creates an API key. Roles defaults to ["user"].
+type APIKeyRequest struct {
+	OrganizationID  *string  `json:"organization_id"`
+	UserID          *string  `json:"user_id"`
+	RateLimitPerMin *int     `json:"rate_limit_per_minute"` // defaults to 60
+	Roles           []string `json:"roles"`
+}
+
+// OrganizationRequest registers or updates an organization. ID is only read
+// on creation, and defaults to a new UUID.
+type OrganizationRequest struct {
+	ID       *string                `json:"id"`
+	Name     string                 `json:"name"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// SecretRequest creates a secret tools can reference as secret://name
+type SecretRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	Value       string  `json:"value"`
+}
+
+// SecretUpdateRequest changes a secret. Omitted fields are left unchanged.
+type SecretUpdateRequest struct {
+	Description *string `json:"description"`
+	Value       *string `json:"value"`
+}
+
+// DrainRequest starts a drain of the server. TimeoutSeconds defaults to
+// the server's drain_timeout.
+type DrainRequest struct {
+	TimeoutSeconds *int `json:"timeout_seconds"`
+}
+
+// UserRequest invites a user into an organization. Roles defaults to
+// ["user"].
+type UserRequest struct {
+	Email string   `json:"email"`
+	Name  *string  `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// UserUpdateRequest changes a user. Omitted fields are left unchanged.
+type UserUpdateRequest struct {
+	Name   *string  `json:"name"`
+	Roles  []string `json:"roles"`
+	Status *string  `json:"status"` // active or disabled
+}
+
+// MemorySearchRequest searches an agent's memory. TopK defaults to 5.
+type MemorySearchRequest struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k"`
+}
+
+// MemoryChunkUpdateRequest curates a memory chunk. Omitted fields are left
+// unchanged.
+type MemoryChunkUpdateRequest struct {
+	ImportanceScore *float64 `json:"importance_score"`
+	Pinned          *bool    `json:"pinned"`
 }
 
 // Response DTOs
@@ -42,6 +222,10 @@ type AgentResponse struct {
 	MemoryTable  *string                `json:"memory_table"`
 	EnabledTools []string               `json:"enabled_tools"`
 	Config       map[string]interface{} `json:"config"`
+	OrganizationID *string              `json:"organization_id"`
+	OwnerUserID  *string                `json:"owner_user_id"`
+	PromptTemplateID *uuid.UUID         `json:"prompt_template_id"`
+	Version      int64                  `json:"version"`
 	CreatedAt    time.Time              `json:"created_at"`
 	UpdatedAt    time.Time              `json:"updated_at"`
 }
@@ -53,6 +237,90 @@ type SessionResponse struct {
 	Metadata       map[string]interface{} `json:"metadata"`
 	CreatedAt      time.Time             `json:"created_at"`
 	LastActivityAt time.Time              `json:"last_activity_at"`
+	OrganizationID *string                `json:"organization_id"`
+	PromptVersionID *uuid.UUID            `json:"prompt_version_id"`
+	Archived       bool                   `json:"archived"`
+	ArchivedAt     *time.Time             `json:"archived_at,omitempty"`
+}
+
+// SessionSummaryResponse is a summary of a session. Cached is false when
+// the summary was generated or extended for this request.
+type SessionSummaryResponse struct {
+	SessionID        uuid.UUID  `json:"session_id"`
+	Summary          string     `json:"summary"`
+	Style            string     `json:"style"`
+	Length           string     `json:"length"`
+	MessageCount     int        `json:"message_count"`
+	ThroughMessageID int64      `json:"through_message_id"`
+	Cached           bool       `json:"cached"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"`
+}
+
+// SessionTraceResponse is how the turns of a session ran, one run each,
+// oldest first
+type SessionTraceResponse struct {
+	SessionID uuid.UUID           `json:"session_id"`
+	Turns     []TurnTraceResponse `json:"turns"`
+	Totals    TraceTotalsResponse `json:"totals"`
+}
+
+// TurnTraceResponse is the trace of one assistant turn: its run, the
+// messages it stored, and each LLM and tool call it made
+type TurnTraceResponse struct {
+	RunID            uuid.UUID             `json:"run_id"`
+	Status           string                `json:"status"`
+	Step             string                `json:"step"`
+	Attempts         int                   `json:"attempts"`
+	ApprovalID       *uuid.UUID            `json:"approval_id,omitempty"`
+	Error            *string               `json:"error,omitempty"`
+	StartedAt        time.Time             `json:"started_at"`
+	CompletedAt      *time.Time            `json:"completed_at"`
+	DurationMs       *float64              `json:"duration_ms"`
+	UserMessage      *TraceMessageResponse `json:"user_message"`
+	AssistantMessage *TraceMessageResponse `json:"assistant_message"`
+	Usage            agent.TokenUsage      `json:"usage"`
+	CostUSD          float64               `json:"cost_usd"`
+	SemanticCacheHit bool                  `json:"semantic_cache_hit"`
+	LLMCalls         []agent.LLMCallUsage  `json:"llm_calls"`
+	ToolCalls        []agent.ToolCallTrace `json:"tool_calls"`
+}
+
+// TraceMessageResponse is a message stored by a turn, by size
+type TraceMessageResponse struct {
+	ID         int64     `json:"id"`
+	Chars      int       `json:"chars"`
+	TokenCount *int      `json:"token_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TraceTotalsResponse adds up the turns of a session trace
+type TraceTotalsResponse struct {
+	Turns      int              `json:"turns"`
+	LLMCalls   int              `json:"llm_calls"`
+	ToolCalls  int              `json:"tool_calls"`
+	Usage      agent.TokenUsage `json:"usage"`
+	CostUSD    float64          `json:"cost_usd"`
+	DurationMs float64          `json:"duration_ms"`
+}
+// LLMLogResponse is a sampled LLM call of an agent
+type LLMLogResponse struct {
+	ID               int64                  `json:"id"`
+	AgentID          uuid.UUID              `json:"agent_id"`
+	SessionID        *uuid.UUID             `json:"session_id,omitempty"`
+	RunID            *uuid.UUID             `json:"run_id,omitempty"`
+	Purpose          string                 `json:"purpose"`
+	Model            string                 `json:"model"`
+	Provider         *string                `json:"provider,omitempty"`
+	Prompt           string                 `json:"prompt"`
+	Response         *string                `json:"response"`
+	Error            *string                `json:"error,omitempty"`
+	PromptTokens     int                    `json:"prompt_tokens"`
+	CompletionTokens int                    `json:"completion_tokens"`
+	LatencyMs        float64                `json:"latency_ms"`
+	Redactions       map[string]interface{} `json:"redactions"`
+	Truncated        bool                   `json:"truncated"`
+	CreatedAt        time.Time              `json:"created_at"`
 }
 
 type MessageResponse struct {
@@ -64,12 +332,342 @@ type MessageResponse struct {
 	ToolCallID *string                `json:"tool_call_id"`
 	TokenCount *int                   `json:"token_count"`
 	Metadata   map[string]interface{} `json:"metadata"`
+	PromptVersionID *uuid.UUID        `json:"prompt_version_id"`
 	CreatedAt  time.Time              `json:"created_at"`
 }
 
+type MemoryUsageResponse struct {
+	AgentID            uuid.UUID                    `json:"agent_id"`
+	ChunkCount         int64                        `json:"chunk_count"`
+	ArchivedChunkCount int64                        `json:"archived_chunk_count"`
+	ContentBytes       int64                        `json:"content_bytes"`
+	AvgImportance      float64                      `json:"avg_importance"`
+	OldestChunkAt      *time.Time                   `json:"oldest_chunk_at"`
+	NewestChunkAt      *time.Time                   `json:"newest_chunk_at"`
+	Retention          *agent.MemoryRetentionPolicy `json:"retention"`
+}
+
+type UsageReportResponse struct {
+	From   *time.Time          `json:"from,omitempty"`
+	To     *time.Time          `json:"to,omitempty"`
+	Rows   []db.UsageReportRow `json:"rows"`
+	Totals db.UsageTotals      `json:"totals"`
+}
+
+// BudgetStatus is an agent's usage this month against its budget
+type BudgetStatus struct {
+	MonthlyBudgetUSD   float64        `json:"monthly_budget_usd,omitempty"`
+	MonthlyTokenBudget int64          `json:"monthly_token_budget,omitempty"`
+	MonthToDate        db.UsageTotals `json:"month_to_date"`
+	Exceeded           bool           `json:"exceeded"`
+}
+
+type AgentUsageResponse struct {
+	AgentID uuid.UUID `json:"agent_id"`
+	UsageReportResponse
+	Budget BudgetStatus `json:"budget"`
+}
+
+type MemoryBackfillResponse struct {
+	JobID       int64                         `json:"job_id"`
+	AgentID     uuid.UUID                     `json:"agent_id"`
+	Status      string                        `json:"status"`
+	Request     *agent.MemoryBackfillRequest  `json:"request"`
+	Progress    *agent.MemoryBackfillProgress `json:"progress"`
+	Error       *string                       `json:"error,omitempty"`
+	RetryCount  int                           `json:"retry_count"`
+	CreatedAt   time.Time                     `json:"created_at"`
+	StartedAt   *time.Time                    `json:"started_at"`
+	CompletedAt *time.Time                    `json:"completed_at"`
+}
+
+type DocumentUploadResponse struct {
+	JobID       int64                         `json:"job_id"`
+	AgentID     uuid.UUID                     `json:"agent_id"`
+	SessionID   *uuid.UUID                    `json:"session_id,omitempty"`
+	Status      string                        `json:"status"`
+	Filename    string                        `json:"filename"`
+	MIMEType    string                        `json:"mime_type"`
+	SizeBytes   int64                         `json:"size_bytes"`
+	SHA256      string                        `json:"sha256"`
+	Progress    *agent.DocumentIngestProgress `json:"progress"`
+	Error       *string                       `json:"error,omitempty"`
+	RetryCount  int                           `json:"retry_count"`
+	CreatedAt   time.Time                     `json:"created_at"`
+	StartedAt   *time.Time                    `json:"started_at"`
+	CompletedAt *time.Time                    `json:"completed_at"`
+}
+
+type MemoryChunkResponse struct {
+	ID              int64                  `json:"id"`
+	AgentID         uuid.UUID              `json:"agent_id"`
+	SessionID       *uuid.UUID             `json:"session_id"`
+	MessageID       *int64                 `json:"message_id"`
+	Content         string                 `json:"content"`
+	ImportanceScore float64                `json:"importance_score"`
+	Pinned          bool                   `json:"pinned"`
+	Metadata        map[string]interface{} `json:"metadata"`
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+// MemoryChunkListResponse is a page of an agent's memory chunks. Total
+// counts every chunk matching the filters.
+type MemoryChunkListResponse struct {
+	Chunks []MemoryChunkResponse `json:"chunks"`
+	Total  int64                 `json:"total"`
+}
+
+type MemoryReembedResponse struct {
+	JobID       int64                        `json:"job_id"`
+	AgentID     uuid.UUID                    `json:"agent_id"`
+	Status      string                       `json:"status"`
+	Request     *agent.MemoryReembedRequest  `json:"request"`
+	Progress    *agent.MemoryReembedProgress `json:"progress"`
+	Error       *string                      `json:"error,omitempty"`
+	RetryCount  int                          `json:"retry_count"`
+	CreatedAt   time.Time                    `json:"created_at"`
+	StartedAt   *time.Time                   `json:"started_at"`
+	CompletedAt *time.Time                   `json:"completed_at"`
+}
+
+type FeedbackResponse struct {
+	ID        int64                  `json:"id"`
+	MessageID int64                  `json:"message_id"`
+	SessionID uuid.UUID              `json:"session_id"`
+	AgentID   uuid.UUID              `json:"agent_id"`
+	APIKeyID  *uuid.UUID             `json:"api_key_id"`
+	Rating    *string                `json:"rating"`
+	Score     *int                   `json:"score"`
+	Comment   *string                `json:"comment"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	PromptVersionID *uuid.UUID       `json:"prompt_version_id"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+type ToolResponse struct {
+	Name          string                 `json:"name"`
+	Description   string                 `json:"description"`
+	ArgSchema     map[string]interface{} `json:"arg_schema"`
+	HandlerType   string                 `json:"handler_type"`
+	HandlerConfig map[string]interface{} `json:"handler_config"`
+	Enabled       bool                   `json:"enabled"`
+	Version       int64                  `json:"version"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+	TestResult    *ToolTestResult        `json:"test_result,omitempty"`
+}
+
+// ToolTestResult is the outcome of running a tool with test_args
+type ToolTestResult struct {
+	Output     string  `json:"output"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type FeedbackListResponse struct {
+	Feedback []FeedbackResponse  `json:"feedback"`
+	Summary  *db.FeedbackSummary `json:"summary"`
+}
+
+// PromptTemplateResponse is a prompt template with its versions
+type PromptTemplateResponse struct {
+	db.PromptTemplate
+	Versions []db.PromptTemplateVersion `json:"versions"`
+}
+
+// PromptVersionMetricsResponse compares the versions of a prompt template
+// on the feedback of the messages they served
+type PromptVersionMetricsResponse struct {
+	PromptTemplateID uuid.UUID                 `json:"prompt_template_id"`
+	Versions         []db.PromptVersionMetrics `json:"versions"`
+}
+
+// TrainingMessage is one turn of an exported conversation
+type TrainingMessage struct {
+	Role       string  `json:"role"`
+	Content    string  `json:"content"`
+	ToolName   *string `json:"tool_name,omitempty"`
+	ToolCallID *string `json:"tool_call_id,omitempty"`
+}
+
+// TrainingExample is one line of a feedback export: the conversation up to
+// and including the rated assistant message, and its feedback
+type TrainingExample struct {
+	Messages  []TrainingMessage      `json:"messages"`
+	Rating    *string                `json:"rating"`
+	Score     *int                   `json:"score"`
+	Comment   *string                `json:"comment"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	AgentID   uuid.UUID              `json:"agent_id"`
+	SessionID uuid.UUID              `json:"session_id"`
+	MessageID int64                  `json:"message_id"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"code"`
+	Error      string `json:"error"`
+	Message    string `json:"message,omitempty"`
+	Code       int    `json:"code"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+
+// ToolApprovalResponse is a run paused for an operator's decision on its
+// tool calls. Result holds the resumed run's answer once completed.
+type ToolApprovalResponse struct {
+	ID          uuid.UUID                `json:"id"`
+	SessionID   uuid.UUID                `json:"session_id"`
+	AgentID     uuid.UUID                `json:"agent_id"`
+	Status      string                   `json:"status"`
+	ToolCalls   []agent.ApprovalToolCall `json:"tool_calls"`
+	DecidedBy   *string                  `json:"decided_by"`
+	Reason      *string                  `json:"reason"`
+	Result      map[string]interface{}   `json:"result,omitempty"`
+	Error       *string                  `json:"error,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	ExpiresAt   time.Time                `json:"expires_at"`
+	DecidedAt   *time.Time               `json:"decided_at"`
+	CompletedAt *time.Time               `json:"completed_at"`
+}
+
+type RunResponse struct {
+	ID          uuid.UUID              `json:"id"`
+	SessionID   uuid.UUID              `json:"session_id"`
+	AgentID     uuid.UUID              `json:"agent_id"`
+	Status      string                 `json:"status"`
+	Step        string                 `json:"step"`
+	Attempts    int                    `json:"attempts"`
+	ApprovalID  *uuid.UUID             `json:"approval_id"`
+	ToolCalls   []agent.RunToolCall    `json:"tool_calls,omitempty"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+	Error       *string                `json:"error,omitempty"`
+	HeartbeatAt time.Time              `json:"heartbeat_at"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	CompletedAt *time.Time             `json:"completed_at"`
+}
+
+type WebhookResponse struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	SecretEnv   *string   `json:"secret_env"`
+	Description *string   `json:"description"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryResponse is one event queued for one endpoint. Payload is
+// the exact body posted to it.
+type WebhookDeliveryResponse struct {
+	ID             int64           `json:"id"`
+	WebhookID      *uuid.UUID      `json:"webhook_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	Event          string          `json:"event"`
+	URL            string          `json:"url"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// APIKeyResponse describes an API key. Key, the secret itself, is only
+// returned when the key is created.
+type APIKeyResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	Key             string     `json:"key,omitempty"`
+	KeyPrefix       string     `json:"key_prefix"`
+	OrganizationID  *string    `json:"organization_id"`
+	UserID          *string    `json:"user_id"`
+	RateLimitPerMin int        `json:"rate_limit_per_minute"`
+	Roles           []string   `json:"roles"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+}
+
+type OrganizationResponse struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// SecretResponse describes a secret. Its value is never returned. UsedBy
+// lists the tools whose handler_config references it.
+type SecretResponse struct {
+	Name        string    `json:"name"`
+	Description *string   `json:"description"`
+	KeyID       string    `json:"key_id"`
+	Version     int64     `json:"version"`
+	UsedBy      []string  `json:"used_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type UserResponse struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID string    `json:"organization_id"`
+	Email          string    `json:"email"`
+	Name           *string   `json:"name"`
+	Roles          []string  `json:"roles"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+type JobResponse struct {
+	ID          int64                  `json:"id"`
+	AgentID     *uuid.UUID             `json:"agent_id"`
+	SessionID   *uuid.UUID             `json:"session_id"`
+	Type        string                 `json:"type"`
+	Status      string                 `json:"status"`
+	Priority    int                    `json:"priority"`
+	Payload     map[string]interface{} `json:"payload"`
+	Result      map[string]interface{} `json:"result"`
+	Error       *string                `json:"error,omitempty"`
+	RetryCount  int                    `json:"retry_count"`
+	MaxRetries  int                    `json:"max_retries"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	StartedAt   *time.Time             `json:"started_at"`
+	CompletedAt *time.Time             `json:"completed_at"`
+}
+
+// MemorySearchResult is one memory chunk matching a memory search
+// MessageSearchHit is a message found by a message search
+type MessageSearchHit struct {
+	ID        int64     `json:"id"`
+	SessionID uuid.UUID `json:"session_id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	ToolName  *string   `json:"tool_name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Score     float64   `json:"score"`
+	Highlight string    `json:"highlight"`
+}
+
+// MessageSearchResponse lists the messages found, best first. Semantic
+// searches report how many messages they embedded and how many in their
+// scope are still unembedded, and so not searched.
+type MessageSearchResponse struct {
+	Mode       string             `json:"mode"`
+	Model      string             `json:"model,omitempty"`
+	Results    []MessageSearchHit `json:"results"`
+	Embedded   *int               `json:"embedded_messages,omitempty"`
+	Unembedded *int64             `json:"unembedded_messages,omitempty"`
+}
+
+type MemorySearchResult struct {
+	ID              int64                  `json:"id"`
+	Content         string                 `json:"content"`
+	ImportanceScore float64                `json:"importance_score"`
+	Similarity      float64                `json:"similarity"`
+	Metadata        map[string]interface{} `json:"metadata"`
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // StreamResponse streams agent responses chunk by chunk
-func StreamResponse(w http.ResponseWriter, r *http.Request, runtime *agent.Runtime, sessionIDStr string, userMessage string) {
+func StreamResponse(w http.ResponseWriter, r *http.Request, runtime *agent.Runtime, sessionIDStr string, userMessage string, attachments []agent.Attachment) {
 	// Set headers for streaming
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -34,10 +34,26 @@ func StreamResponse(w http.ResponseWriter, r *http.Request, runtime *agent.Runti
 
 	// Execute agent with streaming
 	// Note: This is a simplified version - full implementation would stream LLM output
-	state, err := runtime.Execute(r.Context(), sessionID, userMessage)
+	state, err := runtime.ExecuteWithAttachments(r.Context(), sessionID, userMessage, attachments)
 	if err != nil {
-		sendSSE(w, flusher, "error", map[string]interface{}{
+		payload := map[string]interface{}{
 			"error": err.Error(),
+		}
+		if retryAfter := agent.RetryAfterSeconds(err); retryAfter > 0 {
+			payload["retry_after"] = retryAfter
+		}
+		sendSSE(w, flusher, "error", payload)
+		return
+	}
+
+	// A run paused for a tool approval has no answer to stream yet
+	if state.PendingApproval != nil {
+		sendSSE(w, flusher, "approval_required", map[string]interface{}{
+			"approval_id": state.PendingApproval.ID,
+			"run_id":      state.RunID,
+			"expires_at":  state.PendingApproval.ExpiresAt,
+			"usage":       state.Usage,
+			"cost_usd":    state.CostUSD,
 		})
 		return
 	}
@@ -64,11 +80,26 @@ func StreamResponse(w http.ResponseWriter, r *http.Request, runtime *agent.Runti
 	}
 
 	// Send completion
-	sendSSE(w, flusher, "done", map[string]interface{}{
+	done := map[string]interface{}{
 		"tokens_used":  state.TokensUsed,
+		"usage":        state.Usage,
+		"cost_usd":     state.CostUSD,
 		"tool_calls":   state.ToolCalls,
 		"tool_results": state.ToolResults,
-	})
+	}
+	if state.RunID != nil {
+		done["run_id"] = state.RunID
+	}
+	if state.CacheHit != nil {
+		done["semantic_cache"] = state.CacheHit
+	}
+	if len(state.Attachments) > 0 {
+		done["attachments"] = state.Attachments
+	}
+	if len(state.Sources) > 0 {
+		done["sources"] = state.Sources
+	}
+	sendSSE(w, flusher, "done", done)
 }
 
 func sendSSE(w http.ResponseWriter, flusher http.Flusher, event string, data interface{}) {
@@ -3,8 +3,18 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
 
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/secrets"
+	"github.com/neurondb/NeuronAgent/internal/session"
 	"github.com/neurondb/NeuronAgent/internal/utils"
+	"github.com/neurondb/NeuronAgent/internal/webhooks"
 )
 
 // ValidateCreateAgentRequest validates CreateAgentRequest
@@ -24,6 +34,48 @@ func ValidateCreateAgentRequest(req *CreateAgentRequest) error {
 	if !utils.ValidateMinLength(req.SystemPrompt, 10) {
 		return fmt.Errorf("system_prompt must be at least 10 characters")
 	}
+	if _, err := agent.ParseRetentionPolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := session.ParseRetentionPolicy(req.Config, session.RetentionPolicy{}); err != nil {
+		return err
+	}
+	if _, err := agent.ParseGuardrailPolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseLLMProviderPolicy(req.Config, req.ModelName); err != nil {
+		return err
+	}
+	if _, err := agent.ParseUsagePolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseConcurrencyPolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseApprovalPolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseMemoryBackendPolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseToolCachePolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseToolConstraintPolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseSemanticCachePolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseKnowledgeBases(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseRunRecoveryPolicy(req.Config); err != nil {
+		return err
+	}
+	if _, err := agent.ParseLLMLogPolicy(req.Config); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -44,6 +96,303 @@ func ValidateSendMessageRequest(req *SendMessageRequest) error {
 	if !utils.ValidateMinLength(req.Content, 1) {
 		return fmt.Errorf("content must not be empty")
 	}
+	if len(req.Attachments) > agent.MaxAttachments {
+		return fmt.Errorf("at most %d attachments may be sent with a message", agent.MaxAttachments)
+	}
+	for i, att := range req.Attachments {
+		if strings.TrimSpace(att.MIMEType) == "" {
+			return fmt.Errorf("attachments[%d].mime_type is required", i)
+		}
+		if (len(att.Data) == 0) == (att.URL == "") {
+			return fmt.Errorf("attachments[%d] must have exactly one of data or url", i)
+		}
+		if len(att.Data) > agent.MaxAttachmentBytes {
+			return fmt.Errorf("attachments[%d].data must not exceed %d bytes", i, agent.MaxAttachmentBytes)
+		}
+		if att.URL != "" {
+			u, err := url.Parse(att.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("attachments[%d].url must be an http or https URL", i)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateImportSessionRequest validates ImportSessionRequest
+func ValidateImportSessionRequest(req *ImportSessionRequest) error {
+	if req.Archive == nil {
+		return fmt.Errorf("archive is required")
+	}
+	if err := req.Archive.Validate(); err != nil {
+		return err
+	}
+	for from, to := range req.AgentMapping {
+		if _, err := uuid.Parse(from); err != nil {
+			return fmt.Errorf("agent_mapping key '%s' is not a valid UUID", from)
+		}
+		if _, err := uuid.Parse(to); err != nil {
+			return fmt.Errorf("agent_mapping value '%s' is not a valid UUID", to)
+		}
+	}
+	return nil
+}
+
+// ValidateMessageFeedbackRequest validates MessageFeedbackRequest
+func ValidateMessageFeedbackRequest(req *MessageFeedbackRequest) error {
+	if req.Rating == nil && req.Score == nil && (req.Comment == nil || *req.Comment == "") {
+		return fmt.Errorf("one of rating, score or comment is required")
+	}
+	if req.Rating != nil && !utils.ValidateIn(*req.Rating, "up", "down") {
+		return fmt.Errorf("rating must be 'up' or 'down'")
+	}
+	if req.Score != nil && !utils.ValidateIntRange(*req.Score, 1, 5) {
+		return fmt.Errorf("score must be between 1 and 5")
+	}
+	if req.Comment != nil && !utils.ValidateLength(*req.Comment, 0, 10000) {
+		return fmt.Errorf("comment must be at most 10000 characters")
+	}
+	return nil
+}
+
+// ValidatePromptTemplateRequest validates PromptTemplateRequest
+func ValidatePromptTemplateRequest(req *PromptTemplateRequest) error {
+	if err := utils.ValidateRequiredWithError(req.Name, "name"); err != nil {
+		return err
+	}
+	if !utils.ValidateLength(req.Name, 1, 100) {
+		return fmt.Errorf("name must be between 1 and 100 characters")
+	}
+	return nil
+}
+
+// ValidatePromptTemplateVersionRequest validates
+// PromptTemplateVersionRequest. Every placeholder of content must be
+// declared in variables.
+func ValidatePromptTemplateVersionRequest(req *PromptTemplateVersionRequest) error {
+	if !utils.ValidateMinLength(req.Content, 10) {
+		return fmt.Errorf("content must be at least 10 characters")
+	}
+	for _, name := range agent.PromptTemplateVariables(req.Content) {
+		if _, ok := req.Variables[name]; !ok {
+			return fmt.Errorf("variable '%s' of content must be declared in variables", name)
+		}
+	}
+	if req.RolloutWeight < 0 || req.RolloutWeight > 10000 {
+		return fmt.Errorf("rollout_weight must be between 0 and 10000")
+	}
+	return nil
+}
+
+// ValidatePromptTemplateRolloutRequest validates PromptTemplateRolloutRequest
+func ValidatePromptTemplateRolloutRequest(req *PromptTemplateRolloutRequest) error {
+	total := 0
+	for version, weight := range req.Weights {
+		if weight < 0 || weight > 10000 {
+			return fmt.Errorf("weights[%d] must be between 0 and 10000", version)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("weights must give at least one version a positive weight")
+	}
+	return nil
+}
+
+// ValidateMemoryBackfillRequest validates a memory backfill request and fills
+// in its defaults
+func ValidateMemoryBackfillRequest(req *agent.MemoryBackfillRequest) error {
+	return req.Normalize()
+}
+
+// ValidateMemoryReembedRequest validates a memory re-embed request and fills
+// in its defaults
+func ValidateMemoryReembedRequest(req *agent.MemoryReembedRequest) error {
+	return req.Normalize()
+}
+
+// ValidateMemoryChunkUpdateRequest validates MemoryChunkUpdateRequest
+func ValidateMemoryChunkUpdateRequest(req *MemoryChunkUpdateRequest) error {
+	if req.ImportanceScore == nil && req.Pinned == nil {
+		return fmt.Errorf("importance_score or pinned is required")
+	}
+	if req.ImportanceScore != nil && (*req.ImportanceScore < 0 || *req.ImportanceScore > 1) {
+		return fmt.Errorf("importance_score must be between 0 and 1")
+	}
+	return nil
+}
+
+// ValidateToolApprovalDecisionRequest validates ToolApprovalDecisionRequest
+func ValidateToolApprovalDecisionRequest(req *ToolApprovalDecisionRequest) error {
+	if req.Reason != nil && !utils.ValidateLength(*req.Reason, 0, 10000) {
+		return fmt.Errorf("reason must be at most 10000 characters")
+	}
+	if req.DecidedBy != nil && !utils.ValidateLength(*req.DecidedBy, 1, 200) {
+		return fmt.Errorf("decided_by must be between 1 and 200 characters")
+	}
+	return nil
+}
+
+// ValidateWebhookRequest validates WebhookRequest
+func ValidateWebhookRequest(req *WebhookRequest) error {
+	if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	if len(req.Events) == 0 {
+		return fmt.Errorf("events must list at least one event type")
+	}
+	for i, event := range req.Events {
+		if !webhooks.IsEventType(event) {
+			return fmt.Errorf("events[%d] '%s' must be one of %s or '%s'", i, event, strings.Join(webhooks.EventTypes, ", "), webhooks.AllEvents)
+		}
+	}
+	if req.SecretEnv != nil && *req.SecretEnv == "" {
+		return fmt.Errorf("secret_env must be a non-empty environment variable name")
+	}
+	return nil
+}
+
+// ValidateToolRequest validates the fields of ToolRequest that do not depend
+// on the handler; the tool registry checks the rest
+func ValidateToolRequest(req *ToolRequest) error {
+	if err := utils.ValidateRequiredWithError(req.Name, "name"); err != nil {
+		return err
+	}
+	if err := utils.ValidateRequiredWithError(req.Description, "description"); err != nil {
+		return err
+	}
+	if err := utils.ValidateRequiredWithError(req.HandlerType, "handler_type"); err != nil {
+		return err
+	}
+	if req.ArgSchema == nil {
+		return fmt.Errorf("arg_schema is required")
+	}
+	return nil
+}
+
+// ValidateAPIKeyRequest validates APIKeyRequest and fills in its defaults
+func ValidateAPIKeyRequest(req *APIKeyRequest) error {
+	if req.RateLimitPerMin == nil {
+		rateLimit := 60
+		req.RateLimitPerMin = &rateLimit
+	}
+	if *req.RateLimitPerMin < 1 || *req.RateLimitPerMin > 100000 {
+		return fmt.Errorf("rate_limit_per_minute must be between 1 and 100000")
+	}
+	if len(req.Roles) == 0 {
+		req.Roles = []string{auth.RoleUser}
+	}
+	return validateRoles(req.Roles)
+}
+
+// validateRoles checks that every role is a known one
+func validateRoles(roles []string) error {
+	for i, role := range roles {
+		if role != auth.RoleAdmin && role != auth.RoleUser && role != auth.RoleReadOnly {
+			return fmt.Errorf("roles[%d] '%s' must be one of %s, %s, %s", i, role, auth.RoleAdmin, auth.RoleUser, auth.RoleReadOnly)
+		}
+	}
+	return nil
+}
+
+// organizationIDPattern limits organization IDs to characters that are safe
+// in URLs, logs and quota configuration
+var organizationIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateOrganizationRequest validates OrganizationRequest
+func ValidateOrganizationRequest(req *OrganizationRequest) error {
+	if req.ID != nil && !organizationIDPattern.MatchString(*req.ID) {
+		return fmt.Errorf("id must be 1 to 64 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	}
+	if err := utils.ValidateRequiredWithError(req.Name, "name"); err != nil {
+		return err
+	}
+	if req.Metadata == nil {
+		req.Metadata = map[string]interface{}{}
+	}
+	return nil
+}
+
+// ValidateUserRequest validates UserRequest and fills in its defaults
+func ValidateUserRequest(req *UserRequest) error {
+	req.Email = strings.TrimSpace(req.Email)
+	if at := strings.Index(req.Email, "@"); at < 1 || at == len(req.Email)-1 || strings.ContainsAny(req.Email, " \t") {
+		return fmt.Errorf("email must be an email address")
+	}
+	if len(req.Roles) == 0 {
+		req.Roles = []string{auth.RoleUser}
+	}
+	return validateRoles(req.Roles)
+}
+
+// ValidateUserUpdateRequest validates UserUpdateRequest
+func ValidateUserUpdateRequest(req *UserUpdateRequest) error {
+	if req.Roles != nil {
+		if len(req.Roles) == 0 {
+			return fmt.Errorf("roles must list at least one role")
+		}
+		if err := validateRoles(req.Roles); err != nil {
+			return err
+		}
+	}
+	if req.Status != nil && *req.Status != db.UserActive && *req.Status != db.UserDisabled {
+		return fmt.Errorf("status must be %s or %s", db.UserActive, db.UserDisabled)
+	}
+	return nil
+}
+
+// ValidateSecretRequest validates SecretRequest
+func ValidateSecretRequest(req *SecretRequest) error {
+	if !secrets.NamePattern.MatchString(req.Name) {
+		return fmt.Errorf("name must start with a letter and contain at most 100 letters, digits, '_' or '-'")
+	}
+	return validateSecretValue(req.Value)
+}
+
+// ValidateSecretUpdateRequest validates SecretUpdateRequest
+func ValidateSecretUpdateRequest(req *SecretUpdateRequest) error {
+	if req.Description == nil && req.Value == nil {
+		return fmt.Errorf("description or value is required")
+	}
+	if req.Value != nil {
+		return validateSecretValue(*req.Value)
+	}
+	return nil
+}
+
+func validateSecretValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("value is required")
+	}
+	if len(value) > secrets.MaxValueBytes {
+		return fmt.Errorf("value must be at most %d bytes", secrets.MaxValueBytes)
+	}
+	return nil
+}
+
+// maxDrainTimeoutSeconds bounds the time a drain gives work in progress
+const maxDrainTimeoutSeconds = 3600
+
+// ValidateDrainRequest validates DrainRequest
+func ValidateDrainRequest(req *DrainRequest) error {
+	if req.TimeoutSeconds != nil && (*req.TimeoutSeconds < 1 || *req.TimeoutSeconds > maxDrainTimeoutSeconds) {
+		return fmt.Errorf("timeout_seconds must be between 1 and %d", maxDrainTimeoutSeconds)
+	}
+	return nil
+}
+
+// ValidateMemorySearchRequest validates MemorySearchRequest and fills in its
+// defaults
+func ValidateMemorySearchRequest(req *MemorySearchRequest) error {
+	if err := utils.ValidateRequiredWithError(req.Query, "query"); err != nil {
+		return err
+	}
+	if req.TopK == 0 {
+		req.TopK = 5
+	}
+	if req.TopK < 1 || req.TopK > 100 {
+		return fmt.Errorf("top_k must be between 1 and 100")
+	}
 	return nil
 }
 
@@ -47,7 +47,23 @@ func HandleWebSocket(runtime *agent.Runtime) http.HandlerFunc {
 			// Execute agent
 			state, err := runtime.Execute(r.Context(), sessionID, content)
 			if err != nil {
-				conn.WriteJSON(map[string]string{"error": err.Error()})
+				payload := map[string]interface{}{"error": err.Error()}
+				if retryAfter := agent.RetryAfterSeconds(err); retryAfter > 0 {
+					payload["retry_after"] = retryAfter
+				}
+				conn.WriteJSON(payload)
+				continue
+			}
+
+			if state.PendingApproval != nil {
+				if err := conn.WriteJSON(map[string]interface{}{
+					"type":        "approval_required",
+					"approval_id": state.PendingApproval.ID,
+					"run_id":      state.RunID,
+					"expires_at":  state.PendingApproval.ExpiresAt,
+				}); err != nil {
+					break
+				}
 				continue
 			}
 
@@ -57,6 +73,15 @@ func HandleWebSocket(runtime *agent.Runtime) http.HandlerFunc {
 				"content":  state.FinalAnswer,
 				"complete": true,
 			}
+			if state.RunID != nil {
+				response["run_id"] = state.RunID
+			}
+			if state.CacheHit != nil {
+				response["semantic_cache"] = state.CacheHit
+			}
+			if len(state.Sources) > 0 {
+				response["sources"] = state.Sources
+			}
 
 			if err := conn.WriteJSON(response); err != nil {
 				break
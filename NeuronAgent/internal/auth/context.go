@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+type contextKey string
+
+const apiKeyContextKey contextKey = "api_key"
+
+// WithAPIKey returns a context carrying the authenticated API key
+func WithAPIKey(ctx context.Context, apiKey *db.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, apiKey)
+}
+
+// APIKeyFromContext returns the authenticated API key, or nil when the
+// request was not authenticated
+func APIKeyFromContext(ctx context.Context) *db.APIKey {
+	apiKey, _ := ctx.Value(apiKeyContextKey).(*db.APIKey)
+	return apiKey
+}
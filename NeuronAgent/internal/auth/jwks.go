@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksMinRefresh is how often a token signed with an unknown key may make
+// the key set be fetched again, so forged key IDs cannot flood the issuer
+const jwksMinRefresh = time.Minute
+
+// maxJWKSResponseBytes bounds discovery and key set responses
+const maxJWKSResponseBytes = 1 << 20
+
+// jsonWebKey is one key of a JSON Web Key Set (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verificationKey is a parsed signing key of the issuer
+type verificationKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet fetches and caches the signing keys of an OIDC issuer. The JWKS
+// URL is read from the issuer's discovery document unless it is configured.
+type keySet struct {
+	issuer  string
+	jwksURL string
+	ttl     time.Duration
+	client  *http.Client
+
+	// refreshMu serializes fetches, so callers that need the keys fetched
+	// wait for one fetch instead of each making their own. mu guards the
+	// cached keys and is never held across a fetch, so callers the cache
+	// serves are not held up by a slow issuer.
+	refreshMu sync.Mutex
+	mu        sync.Mutex
+	keys      []verificationKey
+	fetchedAt time.Time
+}
+
+func newKeySet(issuer, jwksURL string, ttl time.Duration) *keySet {
+	return &keySet{
+		issuer:  issuer,
+		jwksURL: jwksURL,
+		ttl:     ttl,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// lookup returns the keys that may have signed a token with the given key
+// ID and algorithm. The cached key set is fetched again when it is older
+// than the TTL, or when no key matches and it was not fetched in the last
+// minute, which picks up rotated keys. When fetching fails the cached keys
+// are used until they match nothing.
+func (s *keySet) lookup(ctx context.Context, kid, alg string) ([]verificationKey, error) {
+	cached, fetchedAt := s.cached()
+
+	var fetchErr error
+	if cached == nil || time.Since(fetchedAt) > s.ttl {
+		fetchErr = s.refresh(ctx, fetchedAt)
+		cached, fetchedAt = s.cached()
+	}
+	keys := matchingKeys(cached, kid, alg)
+	if len(keys) == 0 && fetchErr == nil && time.Since(fetchedAt) > jwksMinRefresh {
+		fetchErr = s.refresh(ctx, fetchedAt)
+		cached, _ = s.cached()
+		keys = matchingKeys(cached, kid, alg)
+	}
+	if len(keys) == 0 {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return nil, fmt.Errorf("no signing key matches kid='%s', alg='%s'", kid, alg)
+	}
+	return keys, nil
+}
+
+// cached returns the cached keys and when they were fetched
+func (s *keySet) cached() ([]verificationKey, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys, s.fetchedAt
+}
+
+// refresh fetches the key set, unless another caller fetched it after the
+// keys fetched at seen while this one waited
+func (s *keySet) refresh(ctx context.Context, seen time.Time) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	if _, fetchedAt := s.cached(); fetchedAt.After(seen) {
+		return nil
+	}
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// fetch reads and parses the issuer's key set
+func (s *keySet) fetch(ctx context.Context) ([]verificationKey, error) {
+	jwksURL := s.jwksURL
+	if jwksURL == "" {
+		var err error
+		if jwksURL, err = s.discoverJWKSURL(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := s.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("JWKS fetch failed: url='%s', error=%w", jwksURL, err)
+	}
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			// Keys of types this server cannot use are skipped
+			continue
+		}
+		keys = append(keys, verificationKey{kid: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+	return keys, nil
+}
+
+// discoverJWKSURL reads jwks_uri from the issuer's OpenID configuration
+func (s *keySet) discoverJWKSURL(ctx context.Context) (string, error) {
+	discoveryURL := strings.TrimSuffix(s.issuer, "/") + "/.well-known/openid-configuration"
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := s.getJSON(ctx, discoveryURL, &discovery); err != nil {
+		return "", fmt.Errorf("OIDC discovery failed: url='%s', error=%w", discoveryURL, err)
+	}
+	if discovery.Issuer != s.issuer {
+		return "", fmt.Errorf("OIDC discovery failed: url='%s', issuer='%s' does not match the configured issuer '%s'",
+			discoveryURL, discovery.Issuer, s.issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery failed: url='%s', jwks_uri is missing", discoveryURL)
+	}
+	return discovery.JWKSURI, nil
+}
+
+func (s *keySet) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxJWKSResponseBytes)).Decode(v)
+}
+
+// matchingKeys returns the keys with the given key ID, or every key when
+// the token names none, that can verify alg
+func matchingKeys(keys []verificationKey, kid, alg string) []verificationKey {
+	var matches []verificationKey
+	for _, k := range keys {
+		if kid != "" && k.kid != kid {
+			continue
+		}
+		if k.alg != "" && k.alg != alg {
+			continue
+		}
+		if !keyFitsAlgorithm(k.key, alg) {
+			continue
+		}
+		matches = append(matches, k)
+	}
+	return matches
+}
+
+// parseJSONWebKey returns the public key of an RSA, EC or OKP (Ed25519) JWK
+func parseJSONWebKey(jwk jsonWebKey) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 || e.Int64() < 3 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve '%s'", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", jwk.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
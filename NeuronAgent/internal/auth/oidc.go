@@ -0,0 +1,434 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// OIDCConfig configures bearer-token authentication against an OpenID
+// Connect issuer. Tokens must be signed by one of the issuer's keys, be
+// issued by Issuer for Audience, and be within their validity period.
+type OIDCConfig struct {
+	Issuer   string
+	Audience string
+	// JWKSURL overrides the jwks_uri of the issuer's discovery document
+	JWKSURL      string
+	JWKSCacheTTL time.Duration
+	ClockSkew    time.Duration
+	// Claims mapped to the organization, user and roles of the caller.
+	// Dotted names reach into nested claims, as in realm_access.roles.
+	OrganizationClaim string
+	UserClaim         string
+	RolesClaim        string
+	// RoleMapping maps IdP roles to admin, user or read-only. Only mapped
+	// roles are granted, even ones named like the agent roles; other roles
+	// are ignored. DefaultRoles apply when no role of the token maps.
+	RoleMapping     map[string]string
+	DefaultRoles    []string
+	RateLimitPerMin int
+}
+
+// Enabled reports whether OIDC authentication is configured
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// OIDC defaults
+const (
+	defaultJWKSCacheTTL      = time.Hour
+	defaultClockSkew         = time.Minute
+	defaultOrganizationClaim = "org_id"
+	defaultUserClaim         = "sub"
+	defaultRolesClaim        = "roles"
+	defaultOIDCRateLimit     = 60
+)
+
+// identityKeyPrefix marks the api_keys rows that stand for OIDC identities
+const identityKeyPrefix = "jwt:"
+
+// validRoles are the roles API keys and mapped tokens can carry
+var validRoles = map[string]bool{
+	RoleAdmin:    true,
+	RoleUser:     true,
+	RoleReadOnly: true,
+}
+
+// OIDCAuthenticator authenticates requests bearing JWTs issued by an OIDC
+// provider. Each identity, the issuer and subject of a token, is kept as
+// a row of the API key table, so rate limits, budgets, usage and
+// organization scoping treat it like an API key. The row's organization,
+// user and roles follow the claims of the latest token.
+type OIDCAuthenticator struct {
+	queries *db.Queries
+	config  OIDCConfig
+	keys    *keySet
+}
+
+// NewOIDCAuthenticator creates an authenticator for config, filling in
+// defaults for the settings it leaves unset
+func NewOIDCAuthenticator(queries *db.Queries, config OIDCConfig) (*OIDCAuthenticator, error) {
+	if config.Issuer == "" {
+		return nil, fmt.Errorf("OIDC issuer is required")
+	}
+	if config.Audience == "" {
+		return nil, fmt.Errorf("OIDC audience is required")
+	}
+	if config.JWKSCacheTTL <= 0 {
+		config.JWKSCacheTTL = defaultJWKSCacheTTL
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = defaultClockSkew
+	}
+	if config.OrganizationClaim == "" {
+		config.OrganizationClaim = defaultOrganizationClaim
+	}
+	if config.UserClaim == "" {
+		config.UserClaim = defaultUserClaim
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = defaultRolesClaim
+	}
+	if config.DefaultRoles == nil {
+		config.DefaultRoles = []string{RoleUser}
+	}
+	if config.RateLimitPerMin <= 0 {
+		config.RateLimitPerMin = defaultOIDCRateLimit
+	}
+	for idpRole, role := range config.RoleMapping {
+		if !validRoles[role] {
+			return nil, fmt.Errorf("OIDC role mapping of '%s': unknown role '%s'", idpRole, role)
+		}
+	}
+	for _, role := range config.DefaultRoles {
+		if !validRoles[role] {
+			return nil, fmt.Errorf("OIDC default roles: unknown role '%s'", role)
+		}
+	}
+	return &OIDCAuthenticator{
+		queries: queries,
+		config:  config,
+		keys:    newKeySet(config.Issuer, config.JWKSURL, config.JWKSCacheTTL),
+	}, nil
+}
+
+// IsJWT reports whether a bearer credential has the shape of a JWT. API
+// keys are base64url without dots, so the two never overlap.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Authenticate validates token and returns the API key record of its
+// identity, creating it on first use
+func (a *OIDCAuthenticator) Authenticate(ctx context.Context, token string) (*db.APIKey, error) {
+	claims, err := a.verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return a.identity(ctx, claims)
+}
+
+// jwtHeader is the JOSE header of a signed token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// verify checks the signature and the registered claims of token and
+// returns its claims
+func (a *OIDCAuthenticator) verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid token: expected 3 parts, got %d", len(parts))
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	hash, ok := algorithmHash(header.Alg)
+	if !ok {
+		return nil, fmt.Errorf("invalid token: unsupported algorithm '%s'", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	keys, err := a.keys.lookup(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if verifySignature(k.key, header.Alg, hash, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("invalid token: signature verification failed")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token payload: %w", err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	var claims map[string]interface{}
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("invalid token payload: %w", err)
+	}
+	if err := a.checkRegisteredClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkRegisteredClaims checks the issuer, audience and validity period
+// of a token, allowing for the configured clock skew
+func (a *OIDCAuthenticator) checkRegisteredClaims(claims map[string]interface{}, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != a.config.Issuer {
+		return fmt.Errorf("invalid token: issuer '%s' is not '%s'", iss, a.config.Issuer)
+	}
+	if !audienceContains(claims["aud"], a.config.Audience) {
+		return fmt.Errorf("invalid token: audience does not include '%s'", a.config.Audience)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return fmt.Errorf("invalid token: sub claim is missing")
+	}
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("invalid token: exp claim is missing")
+	}
+	if now.After(exp.Add(a.config.ClockSkew)) {
+		return fmt.Errorf("invalid token: expired at %s", exp.Format(time.RFC3339))
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(a.config.ClockSkew).Before(nbf) {
+		return fmt.Errorf("invalid token: not valid before %s", nbf.Format(time.RFC3339))
+	}
+	if iat, ok := numericDate(claims["iat"]); ok && now.Add(a.config.ClockSkew).Before(iat) {
+		return fmt.Errorf("invalid token: issued in the future at %s", iat.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// identity maps the claims of a verified token to its API key record
+func (a *OIDCAuthenticator) identity(ctx context.Context, claims map[string]interface{}) (*db.APIKey, error) {
+	subject := claims["sub"].(string)
+	var organizationID, userID *string
+	if org, ok := claimString(claims, a.config.OrganizationClaim); ok {
+		organizationID = &org
+	}
+	if user, ok := claimString(claims, a.config.UserClaim); ok {
+		userID = &user
+	}
+	roles := a.mapRoles(claimStrings(claims, a.config.RolesClaim))
+
+	sum := sha256.Sum256([]byte(a.config.Issuer + "\n" + subject))
+	digest := hex.EncodeToString(sum[:])
+	apiKey := &db.APIKey{
+		// Not a bcrypt hash, so no presented API key ever verifies
+		// against it
+		KeyHash:         identityKeyPrefix + digest,
+		KeyPrefix:       identityKeyPrefix + digest[:8],
+		OrganizationID:  organizationID,
+		UserID:          userID,
+		RateLimitPerMin: a.config.RateLimitPerMin,
+		Roles:           roles,
+		Metadata: db.JSONBMap{
+			"auth":    "oidc",
+			"issuer":  a.config.Issuer,
+			"subject": subject,
+		},
+	}
+	if err := a.queries.UpsertIdentityAPIKey(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// mapRoles returns the agent roles of the IdP roles of a token, or the
+// default roles when none maps
+func (a *OIDCAuthenticator) mapRoles(idpRoles []string) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, idpRole := range idpRoles {
+		role, ok := a.config.RoleMapping[idpRole]
+		if ok && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return append([]string(nil), a.config.DefaultRoles...)
+	}
+	return roles
+}
+
+// algorithmHash returns the hash of a supported signing algorithm.
+// Symmetric algorithms and "none" are not supported.
+func algorithmHash(alg string) (crypto.Hash, bool) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, true
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, true
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, true
+	case "EdDSA":
+		return 0, true
+	}
+	return 0, false
+}
+
+// keyFitsAlgorithm reports whether key can verify signatures of alg
+func keyFitsAlgorithm(key interface{}, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		switch alg {
+		case "ES256":
+			return k.Curve.Params().BitSize == 256
+		case "ES384":
+			return k.Curve.Params().BitSize == 384
+		case "ES512":
+			return k.Curve.Params().BitSize == 521
+		}
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+func verifySignature(key interface{}, alg string, hash crypto.Hash, signed, signature []byte) bool {
+	if k, ok := key.(ed25519.PublicKey); ok {
+		return ed25519.Verify(k, signed, signature)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(k, hash, digest, signature, nil) == nil
+		}
+		return rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS encodes the signature as R || S, each as long as the curve
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+// audienceContains reports whether the aud claim, a string or an array of
+// strings, includes audience
+func audienceContains(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// numericDate reads a NumericDate claim, seconds since the epoch
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// claimValue returns the claim at a dotted path, such as
+// realm_access.roles. A claim whose name contains dots is found too.
+func claimValue(claims map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := claims[path]; ok {
+		return v, true
+	}
+	var current interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[name]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// claimString returns a non-empty string or numeric claim as a string
+func claimString(claims map[string]interface{}, path string) (string, bool) {
+	v, ok := claimValue(claims, path)
+	if !ok {
+		return "", false
+	}
+	switch s := v.(type) {
+	case string:
+		return s, s != ""
+	case json.Number:
+		return s.String(), true
+	}
+	return "", false
+}
+
+// claimStrings returns a claim holding an array of strings, or a single
+// string of space-separated values as in the scope claim
+func claimStrings(claims map[string]interface{}, path string) []string {
+	v, ok := claimValue(claims, path)
+	if !ok {
+		return nil
+	}
+	switch s := v.(type) {
+	case string:
+		return strings.Fields(s)
+	case []interface{}:
+		values := make([]string, 0, len(s))
+		for _, item := range s {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
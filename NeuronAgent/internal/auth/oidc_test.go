@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "neuronagent"
+)
+
+// testIssuerKeys serves a JWKS of RSA keys that tests can rotate
+type testIssuerKeys struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches atomic.Int32
+	// block, when set, holds key set requests until it is closed
+	block chan struct{}
+}
+
+func newTestIssuer(t *testing.T) (*testIssuerKeys, *httptest.Server) {
+	t.Helper()
+	issuer := &testIssuerKeys{keys: map[string]*rsa.PrivateKey{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.fetches.Add(1)
+		issuer.mu.Lock()
+		block := issuer.block
+		issuer.mu.Unlock()
+		if block != nil {
+			<-block
+		}
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		var keys []map[string]string
+		for kid, key := range issuer.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(server.Close)
+	return issuer, server
+}
+
+// addKey generates a signing key under kid
+func (i *testIssuerKeys) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	i.mu.Lock()
+	i.keys[kid] = key
+	i.mu.Unlock()
+	return key
+}
+
+// signToken returns a compact JWS of claims with the given header
+func signToken(t *testing.T, key *rsa.PrivateKey, header map[string]string, claims map[string]interface{}) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims are registered claims that pass verification
+func validClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"sub": "user-1",
+		"exp": now.Add(time.Hour).Unix(),
+		"iat": now.Unix(),
+	}
+}
+
+func newTestAuthenticator(t *testing.T, jwksURL string, mapping map[string]string) *OIDCAuthenticator {
+	t.Helper()
+	a, err := NewOIDCAuthenticator(nil, OIDCConfig{
+		Issuer:      testIssuer,
+		Audience:    testAudience,
+		JWKSURL:     jwksURL,
+		RoleMapping: mapping,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCAuthenticator: %v", err)
+	}
+	return a
+}
+
+func TestVerifyRejectsUnsupportedAlgorithms(t *testing.T) {
+	issuer, server := newTestIssuer(t)
+	key := issuer.addKey(t, "k1")
+	a := newTestAuthenticator(t, server.URL, nil)
+
+	if _, err := a.verify(context.Background(), signToken(t, key, map[string]string{"alg": "RS256", "kid": "k1"}, validClaims())); err != nil {
+		t.Fatalf("RS256 token: %v", err)
+	}
+
+	payload, _ := json.Marshal(validClaims())
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	for _, alg := range []string{"none", "HS256", "HS384", "HS512", ""} {
+		header, _ := json.Marshal(map[string]string{"alg": alg, "kid": "k1"})
+		token := base64.RawURLEncoding.EncodeToString(header) + "." + body + "."
+		if _, err := a.verify(context.Background(), token); err == nil || !strings.Contains(err.Error(), "unsupported algorithm") {
+			t.Errorf("alg %q: got %v, want an unsupported algorithm error", alg, err)
+		}
+	}
+
+	// A token re-labelled RS256 with another signature does not verify
+	token := signToken(t, key, map[string]string{"alg": "RS256", "kid": "k1"}, validClaims())
+	forged := token[:strings.LastIndex(token, ".")+1] + base64.RawURLEncoding.EncodeToString([]byte("forged"))
+	if _, err := a.verify(context.Background(), forged); err == nil {
+		t.Error("forged signature: expected an error")
+	}
+}
+
+func TestCheckRegisteredClaims(t *testing.T) {
+	a := newTestAuthenticator(t, "http://127.0.0.1:0/jwks", nil)
+	now := time.Now()
+	number := func(t time.Time) json.Number { return json.Number(big.NewInt(t.Unix()).String()) }
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": testIssuer,
+			"aud": []interface{}{"other", testAudience},
+			"sub": "user-1",
+			"exp": number(now.Add(time.Hour)),
+		}
+	}
+
+	if err := a.checkRegisteredClaims(base(), now); err != nil {
+		t.Fatalf("valid claims: %v", err)
+	}
+	// Within the clock skew
+	claims := base()
+	claims["exp"] = number(now.Add(-30 * time.Second))
+	claims["nbf"] = number(now.Add(30 * time.Second))
+	if err := a.checkRegisteredClaims(claims, now); err != nil {
+		t.Errorf("claims within the clock skew: %v", err)
+	}
+
+	for name, change := range map[string]func(map[string]interface{}){
+		"wrong issuer":     func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"missing issuer":   func(c map[string]interface{}) { delete(c, "iss") },
+		"wrong audience":   func(c map[string]interface{}) { c["aud"] = "other" },
+		"missing audience": func(c map[string]interface{}) { delete(c, "aud") },
+		"missing subject":  func(c map[string]interface{}) { delete(c, "sub") },
+		"expired":          func(c map[string]interface{}) { c["exp"] = number(now.Add(-2 * time.Minute)) },
+		"missing exp":      func(c map[string]interface{}) { delete(c, "exp") },
+		"not yet valid":    func(c map[string]interface{}) { c["nbf"] = number(now.Add(2 * time.Minute)) },
+		"issued in future": func(c map[string]interface{}) { c["iat"] = number(now.Add(2 * time.Minute)) },
+		"exp not a number": func(c map[string]interface{}) { c["exp"] = "tomorrow" },
+	} {
+		claims := base()
+		change(claims)
+		if err := a.checkRegisteredClaims(claims, now); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLookupRefreshesForUnknownKeyID(t *testing.T) {
+	issuer, server := newTestIssuer(t)
+	first := issuer.addKey(t, "k1")
+	a := newTestAuthenticator(t, server.URL, nil)
+	ctx := context.Background()
+
+	if _, err := a.verify(ctx, signToken(t, first, map[string]string{"alg": "RS256", "kid": "k1"}, validClaims())); err != nil {
+		t.Fatalf("k1 token: %v", err)
+	}
+	if n := issuer.fetches.Load(); n != 1 {
+		t.Fatalf("key set fetched %d times, want 1", n)
+	}
+
+	// A key rotated in within the last minute is not fetched yet, so
+	// unknown key IDs cannot flood the issuer
+	rotated := issuer.addKey(t, "k2")
+	token := signToken(t, rotated, map[string]string{"alg": "RS256", "kid": "k2"}, validClaims())
+	if _, err := a.verify(ctx, token); err == nil {
+		t.Fatal("k2 token within a minute of the last fetch: expected an error")
+	}
+	if n := issuer.fetches.Load(); n != 1 {
+		t.Fatalf("key set fetched %d times within a minute, want 1", n)
+	}
+
+	a.keys.mu.Lock()
+	a.keys.fetchedAt = a.keys.fetchedAt.Add(-2 * jwksMinRefresh)
+	a.keys.mu.Unlock()
+	if _, err := a.verify(ctx, token); err != nil {
+		t.Fatalf("k2 token after the minimum refresh interval: %v", err)
+	}
+	if n := issuer.fetches.Load(); n != 2 {
+		t.Errorf("key set fetched %d times, want 2", n)
+	}
+}
+
+func TestLookupDoesNotWaitForRefresh(t *testing.T) {
+	issuer, server := newTestIssuer(t)
+	issuer.addKey(t, "k1")
+	keys := newKeySet(testIssuer, server.URL, time.Hour)
+	ctx := context.Background()
+	if _, err := keys.lookup(ctx, "k1", "RS256"); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+
+	// Hold the next fetch, started by a token with an unknown key ID
+	block := make(chan struct{})
+	issuer.mu.Lock()
+	issuer.block = block
+	issuer.mu.Unlock()
+	keys.mu.Lock()
+	keys.fetchedAt = keys.fetchedAt.Add(-2 * jwksMinRefresh)
+	keys.mu.Unlock()
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		_, _ = keys.lookup(ctx, "unknown", "RS256")
+	}()
+	for issuer.fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Keys in the cache are still served while the fetch is in flight
+	done := make(chan error, 1)
+	go func() {
+		_, err := keys.lookup(ctx, "k1", "RS256")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("cached lookup: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("cached lookup waited for the key set fetch")
+	}
+	close(block)
+	<-refreshed
+}
+
+func TestMapRoles(t *testing.T) {
+	a := newTestAuthenticator(t, "http://127.0.0.1:0/jwks", map[string]string{
+		"neuronagent-admins": RoleAdmin,
+		"analysts":           RoleReadOnly,
+		"staff":              RoleReadOnly,
+	})
+
+	tests := []struct {
+		name     string
+		idpRoles []string
+		want     []string
+	}{
+		{"mapped", []string{"neuronagent-admins"}, []string{RoleAdmin}},
+		{"deduplicated", []string{"analysts", "staff"}, []string{RoleReadOnly}},
+		{"unmapped are ignored", []string{"analysts", "billing"}, []string{RoleReadOnly}},
+		{"agent role names are not granted unmapped", []string{"admin"}, []string{RoleUser}},
+		{"defaults when none maps", []string{"billing"}, []string{RoleUser}},
+		{"defaults without roles", nil, []string{RoleUser}},
+	}
+	for _, tc := range tests {
+		if got := a.mapRoles(tc.idpRoles); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: mapRoles(%v) = %v, want %v", tc.name, tc.idpRoles, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// QuotaLimits is a daily quota (UTC days). Zero values disable the
+// corresponding limit.
+type QuotaLimits struct {
+	RequestsPerDay int64
+	TokensPerDay   int64
+}
+
+// Enabled reports whether the quota limits anything
+func (l QuotaLimits) Enabled() bool {
+	return l.RequestsPerDay > 0 || l.TokensPerDay > 0
+}
+
+// ParseAPIKeyQuota reads the daily quota of an API key from its metadata
+// ("daily_request_quota" and "daily_token_quota")
+func ParseAPIKeyQuota(metadata map[string]interface{}) (*QuotaLimits, error) {
+	limits := &QuotaLimits{}
+	for key, dest := range map[string]*int64{"daily_request_quota": &limits.RequestsPerDay, "daily_token_quota": &limits.TokensPerDay} {
+		v, ok := metadata[key]
+		if !ok || v == nil {
+			continue
+		}
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("api_key.metadata.%s must be a non-negative integer", key)
+		}
+		*dest = int64(n)
+	}
+	return limits, nil
+}
+
+// QuotaStatus is the state of the daily quotas of a request. Each limit is
+// the one closest to being used up among the key's and its organization's
+// quotas; a zero limit means neither has one.
+type QuotaStatus struct {
+	Allowed           bool
+	RequestsLimit     int64
+	RequestsRemaining int64
+	TokensLimit       int64
+	TokensRemaining   int64
+	// Reset is how long until the quotas start again, at midnight UTC
+	Reset time.Duration
+	// ExceededScope and ExceededUnit name the quota that refused the
+	// request: db.QuotaScopeAPIKey or db.QuotaScopeOrganization, and
+	// "requests" or "tokens"
+	ExceededScope string
+	ExceededUnit  string
+}
+
+// Quotas enforces the daily request and token quotas of API keys and
+// organizations. Usage is counted in Postgres, so every replica enforces
+// the same quotas. Requests are counted as they are admitted; tokens are
+// counted as LLM calls are recorded in the usage ledger, so the request
+// that crosses a token quota still completes.
+type Quotas struct {
+	queries       *db.Queries
+	organizations map[string]QuotaLimits
+}
+
+// NewQuotas creates a quota enforcer with the daily quotas of
+// organizations, keyed by organization ID
+func NewQuotas(queries *db.Queries, organizations map[string]QuotaLimits) *Quotas {
+	return &Quotas{queries: queries, organizations: organizations}
+}
+
+type quotaCheck struct {
+	subject db.QuotaSubject
+	limits  QuotaLimits
+}
+
+// Check counts a request of apiKey against its daily quotas and those of
+// its organization. It returns nil when neither has a quota.
+func (q *Quotas) Check(ctx context.Context, apiKey *db.APIKey) (*QuotaStatus, error) {
+	var checks []quotaCheck
+	// Organizations are locked before keys, the order every server uses
+	if apiKey.OrganizationID != nil {
+		if limits, ok := q.organizations[*apiKey.OrganizationID]; ok && limits.Enabled() {
+			checks = append(checks, quotaCheck{db.QuotaSubject{Scope: db.QuotaScopeOrganization, Subject: *apiKey.OrganizationID}, limits})
+		}
+	}
+	keyLimits, err := ParseAPIKeyQuota(apiKey.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("quota check failed: api_key_id='%s', error=%w", apiKey.ID.String(), err)
+	}
+	if keyLimits.Enabled() {
+		checks = append(checks, quotaCheck{db.QuotaSubject{Scope: db.QuotaScopeAPIKey, Subject: apiKey.ID.String()}, *keyLimits})
+	}
+	if len(checks) == 0 {
+		return nil, nil
+	}
+
+	subjects := make([]db.QuotaSubject, len(checks))
+	for i, check := range checks {
+		subjects[i] = check.subject
+	}
+	status := &QuotaStatus{}
+	usage, admitted, err := q.queries.CountQuotaRequest(ctx, subjects, func(usage []db.QuotaUsage) bool {
+		for i, check := range checks {
+			if check.limits.RequestsPerDay > 0 && usage[i].Requests >= check.limits.RequestsPerDay {
+				status.ExceededScope, status.ExceededUnit = check.subject.Scope, "requests"
+				return false
+			}
+			if check.limits.TokensPerDay > 0 && usage[i].Tokens >= check.limits.TokensPerDay {
+				status.ExceededScope, status.ExceededUnit = check.subject.Scope, "tokens"
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("quota check failed: api_key_id='%s', error=%w", apiKey.ID.String(), err)
+	}
+
+	status.Allowed = admitted
+	for i, check := range checks {
+		if limit := check.limits.RequestsPerDay; limit > 0 {
+			remaining := max(limit-usage[i].Requests, 0)
+			if status.RequestsLimit == 0 || remaining < status.RequestsRemaining {
+				status.RequestsLimit, status.RequestsRemaining = limit, remaining
+			}
+		}
+		if limit := check.limits.TokensPerDay; limit > 0 {
+			remaining := max(limit-usage[i].Tokens, 0)
+			if status.TokensLimit == 0 || remaining < status.TokensRemaining {
+				status.TokensLimit, status.TokensRemaining = limit, remaining
+			}
+		}
+	}
+	now := time.Now().UTC()
+	status.Reset = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+	return status, nil
+}
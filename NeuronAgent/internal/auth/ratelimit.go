@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// Rate limit backends
+const (
+	RateLimitBackendMemory   = "memory"
+	RateLimitBackendPostgres = "postgres"
+)
+
+// RateLimitStatus is the state of a key's per-minute rate limit after a
+// request
+type RateLimitStatus struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the full limit is available again
+	Reset time.Duration
+	// RetryAfter is how long a refused request should wait before retrying
+	RetryAfter time.Duration
+}
+
+// Limiter limits the requests each key makes per minute
+type Limiter interface {
+	// Take counts a request of keyID and reports whether it is within
+	// limitPerMin
+	Take(ctx context.Context, keyID string, limitPerMin int) (*RateLimitStatus, error)
+}
+
+// NewLimiter returns the limiter of a backend: RateLimitBackendMemory (or
+// "") for a RateLimiter, RateLimitBackendPostgres for a
+// PostgresRateLimiter
+func NewLimiter(backend string, queries *db.Queries) (Limiter, error) {
+	switch backend {
+	case "", RateLimitBackendMemory:
+		return NewRateLimiter(), nil
+	case RateLimitBackendPostgres:
+		return NewPostgresRateLimiter(queries), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend '%s': must be '%s' or '%s'", backend, RateLimitBackendMemory, RateLimitBackendPostgres)
+	}
+}
+
+// PostgresRateLimiter keeps a token bucket per key in Postgres, so every
+// replica draws from the same limit. A key's bucket holds up to its
+// per-minute limit and refills continuously at that rate, which limits
+// requests over any sliding minute rather than per fixed window.
+type PostgresRateLimiter struct {
+	queries *db.Queries
+}
+
+// NewPostgresRateLimiter creates a Postgres-backed rate limiter
+func NewPostgresRateLimiter(queries *db.Queries) *PostgresRateLimiter {
+	return &PostgresRateLimiter{queries: queries}
+}
+
+// Take takes a request from the key's bucket
+func (l *PostgresRateLimiter) Take(ctx context.Context, keyID string, limitPerMin int) (*RateLimitStatus, error) {
+	allowed, remaining, err := l.queries.TakeRateLimitToken(ctx, "api_key:"+keyID, limitPerMin)
+	if err != nil {
+		return nil, fmt.Errorf("rate limit check failed: api_key_id='%s', error=%w", keyID, err)
+	}
+	status := &RateLimitStatus{
+		Allowed:   allowed,
+		Limit:     limitPerMin,
+		Remaining: int(math.Floor(remaining)),
+	}
+	if limitPerMin > 0 {
+		perToken := time.Minute / time.Duration(limitPerMin)
+		status.Reset = time.Duration((float64(limitPerMin) - remaining) * float64(perToken))
+		if !allowed {
+			status.RetryAfter = time.Duration((1 - remaining) * float64(perToken))
+		}
+	}
+	return status, nil
+}
@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
+// RateLimiter counts requests per key in memory, so each server process
+// applies the limits separately
 type RateLimiter struct {
 	limits map[string]*rateLimit
 	mu     sync.RWMutex
@@ -22,6 +25,15 @@ func NewRateLimiter() *RateLimiter {
 }
 
 func (r *RateLimiter) CheckLimit(keyID string, limitPerMin int) bool {
+	return r.take(keyID, limitPerMin).Allowed
+}
+
+// Take counts a request of keyID against a fixed one-minute window
+func (r *RateLimiter) Take(ctx context.Context, keyID string, limitPerMin int) (*RateLimitStatus, error) {
+	return r.take(keyID, limitPerMin), nil
+}
+
+func (r *RateLimiter) take(keyID string, limitPerMin int) *RateLimitStatus {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -30,20 +42,33 @@ func (r *RateLimiter) CheckLimit(keyID string, limitPerMin int) bool {
 
 	if !exists || now.After(rl.resetTime) {
 		// Reset or create
-		r.limits[keyID] = &rateLimit{
+		rl = &rateLimit{
 			count:     1,
 			resetTime: now.Add(1 * time.Minute),
 		}
-		return true
+		r.limits[keyID] = rl
+		return r.status(rl, limitPerMin, now, true)
 	}
 
 	if rl.count >= limitPerMin {
-		return false
+		return r.status(rl, limitPerMin, now, false)
 	}
 
 	rl.count++
-	return true
+	return r.status(rl, limitPerMin, now, true)
 }
 
-// HasRole and RequireRole are now in roles.go
+func (r *RateLimiter) status(rl *rateLimit, limitPerMin int, now time.Time, allowed bool) *RateLimitStatus {
+	status := &RateLimitStatus{
+		Allowed:   allowed,
+		Limit:     limitPerMin,
+		Remaining: max(limitPerMin-rl.count, 0),
+		Reset:     rl.resetTime.Sub(now),
+	}
+	if !allowed {
+		status.RetryAfter = status.Reset
+	}
+	return status
+}
 
+// HasRole and RequireRole are now in roles.go
@@ -13,13 +13,35 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	Auth     AuthConfig     `yaml:"auth"`
 	Logging  LoggingConfig  `yaml:"logging"`
+	Session  SessionConfig  `yaml:"session"`
+	Webhooks WebhookConfig  `yaml:"webhooks"`
+	Memory   MemoryConfig   `yaml:"memory"`
+	Runs     RunsConfig     `yaml:"runs"`
+	LLMLogs  LLMLogConfig   `yaml:"llm_logs"`
+	Fallback FallbackConfig `yaml:"fallback"`
+	Secrets  SecretsConfig  `yaml:"secrets"`
 }
 
+// ServerConfig configures the HTTP server. Request bodies over
+// MaxBodyBytes are refused with 413. Messages and session imports, which
+// can carry inline attachments, may be up to MaxMessageBodyBytes, and
+// document uploads up to MaxUploadBytes. Uploads are streamed to files in
+// UploadDir, by default a directory in the system temp dir; with more than
+// one replica it must be storage all replicas share, since any replica may
+// process the upload.
 type ServerConfig struct {
-	Host         string        `yaml:"host"`
-	Port         int           `yaml:"port"`
-	ReadTimeout  time.Duration `yaml:"read_timeout"`
-	WriteTimeout time.Duration `yaml:"write_timeout"`
+	Host                string        `yaml:"host"`
+	Port                int           `yaml:"port"`
+	ReadTimeout         time.Duration `yaml:"read_timeout"`
+	WriteTimeout        time.Duration `yaml:"write_timeout"`
+	MaxBodyBytes        int64         `yaml:"max_body_bytes"`
+	MaxMessageBodyBytes int64         `yaml:"max_message_body_bytes"`
+	MaxUploadBytes      int64         `yaml:"max_upload_bytes"`
+	UploadDir           string        `yaml:"upload_dir"`
+	// DrainTimeout is how long a drain, on SIGTERM or POST
+	// /api/v1/admin/drain, lets runs and jobs in progress finish before
+	// handing them back for other servers
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
 }
 
 type DatabaseConfig struct {
@@ -32,10 +54,165 @@ type DatabaseConfig struct {
 	MaxIdleConns    int           `yaml:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
 	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// SlowAcquireThreshold logs queries that wait longer than this for a
+	// connection
+	SlowAcquireThreshold time.Duration      `yaml:"slow_acquire_threshold"`
+	AdaptivePool         AdaptivePoolConfig `yaml:"adaptive_pool"`
+}
+
+// AdaptivePoolConfig resizes the connection pool between MinOpenConns and
+// MaxOpenConns by the time queries wait for a connection, holding it while
+// the CPU is busier than MaxCPU (a fraction, e.g. 0.85)
+type AdaptivePoolConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	MinOpenConns   int           `yaml:"min_open_conns"`
+	MaxOpenConns   int           `yaml:"max_open_conns"`
+	TargetWait     time.Duration `yaml:"target_wait"`
+	MaxCPU         float64       `yaml:"max_cpu"`
+	SampleInterval time.Duration `yaml:"sample_interval"`
 }
 
 type AuthConfig struct {
-	APIKeyHeader string `yaml:"api_key_header"`
+	APIKeyHeader string          `yaml:"api_key_header"`
+	OIDC         OIDCConfig      `yaml:"oidc"`
+	RateLimit    RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig chooses where per-key request rates are counted: in
+// memory, separately on every replica ("memory"), or in Postgres, shared by
+// all replicas ("postgres"). OrganizationQuotas cap the requests and LLM
+// tokens each organization uses per day (UTC); keys set their own daily
+// quotas in their metadata. Quotas are always counted in Postgres.
+type RateLimitConfig struct {
+	Backend            string                 `yaml:"backend"`
+	OrganizationQuotas map[string]QuotaConfig `yaml:"organization_quotas"`
+}
+
+// QuotaConfig is a daily quota. Zero values disable the corresponding
+// limit.
+type QuotaConfig struct {
+	RequestsPerDay int64 `yaml:"requests_per_day"`
+	TokensPerDay   int64 `yaml:"tokens_per_day"`
+}
+
+// OIDCConfig enables JWT bearer tokens issued by an OpenID Connect
+// provider alongside API keys. It is off while Issuer is empty. Claims of
+// the token name the caller's organization, user and roles; RoleMapping
+// maps IdP roles to admin, user or read-only.
+type OIDCConfig struct {
+	Issuer            string            `yaml:"issuer"`
+	Audience          string            `yaml:"audience"`
+	JWKSURL           string            `yaml:"jwks_url"`
+	JWKSCacheTTL      time.Duration     `yaml:"jwks_cache_ttl"`
+	ClockSkew         time.Duration     `yaml:"clock_skew"`
+	OrganizationClaim string            `yaml:"organization_claim"`
+	UserClaim         string            `yaml:"user_claim"`
+	RolesClaim        string            `yaml:"roles_claim"`
+	RoleMapping       map[string]string `yaml:"role_mapping"`
+	DefaultRoles      []string          `yaml:"default_roles"`
+	RateLimitPerMin   int               `yaml:"rate_limit_per_minute"`
+}
+
+// SessionConfig controls the session cache and the server-wide session
+// retention defaults. Agents can override the retention durations in their
+// config; zero durations disable archiving or purging.
+type SessionConfig struct {
+	CacheTTL        time.Duration `yaml:"cache_ttl"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+	ArchiveAfter    time.Duration `yaml:"archive_after"`
+	PurgeAfter      time.Duration `yaml:"purge_after"`
+	ArchiveDir      string        `yaml:"archive_dir"`
+}
+
+// WebhookConfig controls how queued webhook events are sent. A delivery
+// that fails MaxAttempts times is dead-lettered.
+type WebhookConfig struct {
+	DeliveryInterval time.Duration `yaml:"delivery_interval"`
+	MaxAttempts      int           `yaml:"max_attempts"`
+}
+
+// MemoryConfig configures the optional memory backends. Agents can use the
+// redis backend only when RedisURL is set.
+type MemoryConfig struct {
+	RedisURL string `yaml:"redis_url"`
+}
+
+// RunsConfig limits the messages processed at once. Messages to a session
+// always run one at a time; a message waits up to SessionQueueTimeout for
+// the session's earlier messages before it is refused with 409.
+// MaxConcurrentPerAgent caps the messages one agent processes at once
+// (0 = unlimited); agents can override it in their config. Refused
+// messages carry RetryAfter as their retry hint.
+//
+// A run in progress refreshes its heartbeat every HeartbeatInterval. Runs
+// whose heartbeat is older than StaleAfter were interrupted, for example by
+// a restart; every RecoveryInterval a server claims them and resumes them
+// from their last checkpoint, failing those resumed MaxResumeAttempts
+// times already.
+type RunsConfig struct {
+	SessionQueueTimeout   time.Duration `yaml:"session_queue_timeout"`
+	MaxConcurrentPerAgent int           `yaml:"max_concurrent_per_agent"`
+	RetryAfter            time.Duration `yaml:"retry_after"`
+	HeartbeatInterval     time.Duration `yaml:"heartbeat_interval"`
+	StaleAfter            time.Duration `yaml:"stale_after"`
+	RecoveryInterval      time.Duration `yaml:"recovery_interval"`
+	MaxResumeAttempts     int           `yaml:"max_resume_attempts"`
+}
+
+// LLMLogConfig names the JSON lines file that agents whose llm_logging
+// uses the "file" sink write their sampled LLM calls to. Without it those
+// calls go to the llm_logs table.
+type LLMLogConfig struct {
+	File string `yaml:"file"`
+}
+
+// FallbackConfig names the external providers used where the database
+// lacks NeuronDB's ML functions, as on plain Postgres or builds of the
+// extension without them. Embedding embeds texts when there is no
+// neurondb_embed; without either, agent memory is disabled. LLM serves
+// agents whose provider chain only has NeuronDB providers when there is no
+// neurondb_llm_generate or neurondb_llm_complete. Each is off while its
+// Type is empty.
+type FallbackConfig struct {
+	Embedding ProviderConfig `yaml:"embedding"`
+	LLM       ProviderConfig `yaml:"llm"`
+}
+
+// ProviderConfig configures an external model provider: openai or ollama
+// for embeddings, openai, anthropic or ollama for LLMs. An empty Endpoint
+// selects the provider's public one and an empty APIKeyEnv its usual
+// variable (OPENAI_API_KEY, ANTHROPIC_API_KEY). Dimensions asks OpenAI for
+// embeddings of that size, which must match the memory vector columns.
+type ProviderConfig struct {
+	Type       string        `yaml:"type"`
+	Model      string        `yaml:"model"`
+	Endpoint   string        `yaml:"endpoint"`
+	APIKeyEnv  string        `yaml:"api_key_env"`
+	Dimensions int           `yaml:"dimensions"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// SecretsConfig configures the master key that encrypts the secrets tools
+// reference as secret://name. With Vault.Address set, data keys are wrapped
+// by the Vault transit key Vault.Key, authenticated with the token in
+// Vault.TokenEnv. Otherwise the master key is the base64 32-byte key in
+// MasterKeyEnv, and PreviousMasterKeysEnv may list the comma-separated keys
+// it replaced until secrets are rewrapped. Without either, the secrets API
+// is disabled and tools referencing secrets fail.
+type SecretsConfig struct {
+	MasterKeyEnv          string      `yaml:"master_key_env"`
+	PreviousMasterKeysEnv string      `yaml:"previous_master_keys_env"`
+	Vault                 VaultConfig `yaml:"vault"`
+}
+
+// VaultConfig names a key of a Vault transit secrets engine. Mount defaults
+// to "transit".
+type VaultConfig struct {
+	Address  string        `yaml:"address"`
+	Mount    string        `yaml:"mount"`
+	Key      string        `yaml:"key"`
+	TokenEnv string        `yaml:"token_env"`
+	Timeout  time.Duration `yaml:"timeout"`
 }
 
 type LoggingConfig struct {
@@ -12,6 +12,12 @@ func DefaultConfig() *Config {
 			Port:         8080,
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 30 * time.Second,
+
+			MaxBodyBytes:        1 << 20,
+			MaxMessageBodyBytes: 64 << 20,
+			MaxUploadBytes:      256 << 20,
+
+			DrainTimeout: 30 * time.Second,
 		},
 		Database: DatabaseConfig{
 			Host:            "localhost",
@@ -23,14 +29,42 @@ func DefaultConfig() *Config {
 			MaxIdleConns:    5,
 			ConnMaxLifetime: 5 * time.Minute,
 			ConnMaxIdleTime: 10 * time.Minute,
+
+			SlowAcquireThreshold: 200 * time.Millisecond,
 		},
 		Auth: AuthConfig{
 			APIKeyHeader: "Authorization",
+			RateLimit:    RateLimitConfig{Backend: "memory"},
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
 			Format: "json",
 		},
+		Session: SessionConfig{
+			CacheTTL:        5 * time.Minute,
+			CleanupInterval: 1 * time.Hour,
+		},
+		Webhooks: WebhookConfig{
+			DeliveryInterval: 5 * time.Second,
+			MaxAttempts:      8,
+		},
+		Runs: RunsConfig{
+			SessionQueueTimeout: 30 * time.Second,
+			RetryAfter:          2 * time.Second,
+			HeartbeatInterval:   15 * time.Second,
+			StaleAfter:          2 * time.Minute,
+			RecoveryInterval:    30 * time.Second,
+			MaxResumeAttempts:   3,
+		},
+		Secrets: SecretsConfig{
+			MasterKeyEnv:          "NEURONDB_AGENT_MASTER_KEY",
+			PreviousMasterKeysEnv: "NEURONDB_AGENT_PREVIOUS_MASTER_KEYS",
+			Vault: VaultConfig{
+				Mount:    "transit",
+				TokenEnv: "VAULT_TOKEN",
+				Timeout:  10 * time.Second,
+			},
+		},
 	}
 }
 
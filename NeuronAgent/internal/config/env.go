@@ -28,6 +28,29 @@ func LoadFromEnv(cfg *Config) error {
 			cfg.Server.WriteTimeout = d
 		}
 	}
+	if size := os.Getenv("SERVER_MAX_BODY_BYTES"); size != "" {
+		if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+			cfg.Server.MaxBodyBytes = n
+		}
+	}
+	if size := os.Getenv("SERVER_MAX_MESSAGE_BODY_BYTES"); size != "" {
+		if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+			cfg.Server.MaxMessageBodyBytes = n
+		}
+	}
+	if size := os.Getenv("SERVER_MAX_UPLOAD_BYTES"); size != "" {
+		if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+			cfg.Server.MaxUploadBytes = n
+		}
+	}
+	if dir := os.Getenv("SERVER_UPLOAD_DIR"); dir != "" {
+		cfg.Server.UploadDir = dir
+	}
+	if timeout := os.Getenv("SERVER_DRAIN_TIMEOUT"); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			cfg.Server.DrainTimeout = d
+		}
+	}
 
 	// Database config
 	if host := os.Getenv("DB_HOST"); host != "" {
@@ -62,11 +85,43 @@ func LoadFromEnv(cfg *Config) error {
 			cfg.Database.ConnMaxLifetime = d
 		}
 	}
+	if threshold := os.Getenv("DB_SLOW_ACQUIRE_THRESHOLD"); threshold != "" {
+		if d, err := time.ParseDuration(threshold); err == nil {
+			cfg.Database.SlowAcquireThreshold = d
+		}
+	}
+	if adaptive := os.Getenv("DB_ADAPTIVE_POOL"); adaptive != "" {
+		if b, err := strconv.ParseBool(adaptive); err == nil {
+			cfg.Database.AdaptivePool.Enabled = b
+		}
+	}
+	if minConns := os.Getenv("DB_POOL_MIN_CONNS"); minConns != "" {
+		if n, err := strconv.Atoi(minConns); err == nil {
+			cfg.Database.AdaptivePool.MinOpenConns = n
+		}
+	}
+	if maxConns := os.Getenv("DB_POOL_MAX_CONNS"); maxConns != "" {
+		if n, err := strconv.Atoi(maxConns); err == nil {
+			cfg.Database.AdaptivePool.MaxOpenConns = n
+		}
+	}
 
 	// Auth config
 	if header := os.Getenv("AUTH_API_KEY_HEADER"); header != "" {
 		cfg.Auth.APIKeyHeader = header
 	}
+	if issuer := os.Getenv("AUTH_OIDC_ISSUER"); issuer != "" {
+		cfg.Auth.OIDC.Issuer = issuer
+	}
+	if audience := os.Getenv("AUTH_OIDC_AUDIENCE"); audience != "" {
+		cfg.Auth.OIDC.Audience = audience
+	}
+	if jwksURL := os.Getenv("AUTH_OIDC_JWKS_URL"); jwksURL != "" {
+		cfg.Auth.OIDC.JWKSURL = jwksURL
+	}
+	if backend := os.Getenv("AUTH_RATE_LIMIT_BACKEND"); backend != "" {
+		cfg.Auth.RateLimit.Backend = backend
+	}
 
 	// Logging config
 	if level := os.Getenv("LOG_LEVEL"); level != "" {
@@ -76,6 +131,133 @@ func LoadFromEnv(cfg *Config) error {
 		cfg.Logging.Format = format
 	}
 
+	// LLM call log config
+	if file := os.Getenv("LLM_LOG_FILE"); file != "" {
+		cfg.LLMLogs.File = file
+	}
+
+	// Session config
+	if ttl := os.Getenv("SESSION_CACHE_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			cfg.Session.CacheTTL = d
+		}
+	}
+	if interval := os.Getenv("SESSION_CLEANUP_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			cfg.Session.CleanupInterval = d
+		}
+	}
+	if after := os.Getenv("SESSION_ARCHIVE_AFTER"); after != "" {
+		if d, err := time.ParseDuration(after); err == nil {
+			cfg.Session.ArchiveAfter = d
+		}
+	}
+	if after := os.Getenv("SESSION_PURGE_AFTER"); after != "" {
+		if d, err := time.ParseDuration(after); err == nil {
+			cfg.Session.PurgeAfter = d
+		}
+	}
+	if dir := os.Getenv("SESSION_ARCHIVE_DIR"); dir != "" {
+		cfg.Session.ArchiveDir = dir
+	}
+
+	// Memory config
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		cfg.Memory.RedisURL = redisURL
+	}
+
+	// Fallback providers
+	for _, f := range []struct {
+		prefix   string
+		provider *ProviderConfig
+	}{
+		{"FALLBACK_EMBEDDING_", &cfg.Fallback.Embedding},
+		{"FALLBACK_LLM_", &cfg.Fallback.LLM},
+	} {
+		if v := os.Getenv(f.prefix + "TYPE"); v != "" {
+			f.provider.Type = v
+		}
+		if v := os.Getenv(f.prefix + "MODEL"); v != "" {
+			f.provider.Model = v
+		}
+		if v := os.Getenv(f.prefix + "ENDPOINT"); v != "" {
+			f.provider.Endpoint = v
+		}
+		if v := os.Getenv(f.prefix + "API_KEY_ENV"); v != "" {
+			f.provider.APIKeyEnv = v
+		}
+		if v := os.Getenv(f.prefix + "TIMEOUT"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil {
+				f.provider.Timeout = d
+			}
+		}
+	}
+	if dims := os.Getenv("FALLBACK_EMBEDDING_DIMENSIONS"); dims != "" {
+		if n, err := strconv.Atoi(dims); err == nil {
+			cfg.Fallback.Embedding.Dimensions = n
+		}
+	}
+
+	// Run limits
+	if timeout := os.Getenv("RUN_SESSION_QUEUE_TIMEOUT"); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			cfg.Runs.SessionQueueTimeout = d
+		}
+	}
+	if maxRuns := os.Getenv("RUN_MAX_CONCURRENT_PER_AGENT"); maxRuns != "" {
+		if n, err := strconv.Atoi(maxRuns); err == nil {
+			cfg.Runs.MaxConcurrentPerAgent = n
+		}
+	}
+	if retryAfter := os.Getenv("RUN_RETRY_AFTER"); retryAfter != "" {
+		if d, err := time.ParseDuration(retryAfter); err == nil {
+			cfg.Runs.RetryAfter = d
+		}
+	}
+	if interval := os.Getenv("RUN_HEARTBEAT_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			cfg.Runs.HeartbeatInterval = d
+		}
+	}
+	if staleAfter := os.Getenv("RUN_STALE_AFTER"); staleAfter != "" {
+		if d, err := time.ParseDuration(staleAfter); err == nil {
+			cfg.Runs.StaleAfter = d
+		}
+	}
+	if interval := os.Getenv("RUN_RECOVERY_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			cfg.Runs.RecoveryInterval = d
+		}
+	}
+	if attempts := os.Getenv("RUN_MAX_RESUME_ATTEMPTS"); attempts != "" {
+		if n, err := strconv.Atoi(attempts); err == nil {
+			cfg.Runs.MaxResumeAttempts = n
+		}
+	}
+
+	// Webhook config
+	if interval := os.Getenv("WEBHOOK_DELIVERY_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			cfg.Webhooks.DeliveryInterval = d
+		}
+	}
+	if attempts := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); attempts != "" {
+		if n, err := strconv.Atoi(attempts); err == nil {
+			cfg.Webhooks.MaxAttempts = n
+		}
+	}
+
+	// Secrets config
+	if address := os.Getenv("SECRETS_VAULT_ADDR"); address != "" {
+		cfg.Secrets.Vault.Address = address
+	}
+	if mount := os.Getenv("SECRETS_VAULT_MOUNT"); mount != "" {
+		cfg.Secrets.Vault.Mount = mount
+	}
+	if key := os.Getenv("SECRETS_VAULT_KEY"); key != "" {
+		cfg.Secrets.Vault.Key = key
+	}
+
 	return nil
 }
 
@@ -31,6 +31,11 @@ type PoolConfig struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+	// SlowAcquireThreshold logs queries that waited longer than this for a
+	// connection; zero disables the log
+	SlowAcquireThreshold time.Duration
+	// Adaptive resizes MaxOpenConns within bounds while the server runs
+	Adaptive AdaptivePoolConfig
 }
 
 // NewDB creates a new database instance
@@ -6,17 +6,18 @@ import (
 	"path/filepath"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/neurondb/NeuronAgent/migrations"
 )
 
 type MigrationRunner struct {
-	db         *sqlx.DB
-	schemaMgr  *SchemaManager
+	db            *sqlx.DB
+	schemaMgr     *SchemaManager
 	migrationsDir string
 }
 
 func NewMigrationRunner(db *sqlx.DB, migrationsDir string) (*MigrationRunner, error) {
 	schemaMgr := NewSchemaManager(db)
-	
+
 	// Get absolute path
 	absPath, err := filepath.Abs(migrationsDir)
 	if err != nil {
@@ -37,12 +38,25 @@ func NewMigrationRunner(db *sqlx.DB, migrationsDir string) (*MigrationRunner, er
 	return runner, nil
 }
 
+// NewEmbeddedMigrationRunner creates a runner for the migrations compiled
+// into the binary
+func NewEmbeddedMigrationRunner(db *sqlx.DB) (*MigrationRunner, error) {
+	schemaMgr := NewSchemaManager(db)
+	if err := schemaMgr.LoadMigrationsFS(migrations.FS); err != nil {
+		return nil, fmt.Errorf("failed to load embedded migrations: %w", err)
+	}
+	return &MigrationRunner{
+		db:        db,
+		schemaMgr: schemaMgr,
+	}, nil
+}
+
 // Run runs all pending migrations
-func (mr *MigrationRunner) Run(ctx context.Context) error {
+func (mr *MigrationRunner) Run(ctx context.Context) ([]Migration, error) {
 	return mr.schemaMgr.Migrate(ctx)
 }
 
-// Status returns migration status
+// Status returns the current version and the number of known migrations
 func (mr *MigrationRunner) Status(ctx context.Context) (int, int, error) {
 	current, err := mr.schemaMgr.GetCurrentVersion(ctx)
 	if err != nil {
@@ -52,8 +66,22 @@ func (mr *MigrationRunner) Status(ctx context.Context) (int, int, error) {
 	return current, total, nil
 }
 
+// List returns the status of every known migration
+func (mr *MigrationRunner) List(ctx context.Context) ([]MigrationStatus, error) {
+	return mr.schemaMgr.Status(ctx)
+}
+
 // Rollback rolls back the last migration
 func (mr *MigrationRunner) Rollback(ctx context.Context) error {
 	return mr.schemaMgr.Rollback(ctx)
 }
 
+// Down rolls back the last steps migrations
+func (mr *MigrationRunner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	return mr.schemaMgr.Down(ctx, steps)
+}
+
+// Baseline marks migrations up to version as applied without running them
+func (mr *MigrationRunner) Baseline(ctx context.Context, version int) ([]Migration, error) {
+	return mr.schemaMgr.Baseline(ctx, version)
+}
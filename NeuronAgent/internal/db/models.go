@@ -16,6 +16,10 @@ type Agent struct {
 	MemoryTable  *string                `db:"memory_table"`
 	EnabledTools pq.StringArray         `db:"enabled_tools"`
 	Config       JSONBMap               `db:"config"`
+	OrganizationID *string              `db:"organization_id"` // nil for agents of keys with no organization
+	OwnerUserID  *string                `db:"owner_user_id"` // user of the API key that created the agent
+	PromptTemplateID *uuid.UUID         `db:"prompt_template_id"` // template whose versions replace SystemPrompt
+	Version      int64                  `db:"version"` // incremented on every update
 	CreatedAt    time.Time              `db:"created_at"`
 	UpdatedAt    time.Time              `db:"updated_at"`
 }
@@ -27,6 +31,9 @@ type Session struct {
 	Metadata       JSONBMap               `db:"metadata"`
 	CreatedAt      time.Time              `db:"created_at"`
 	LastActivityAt time.Time              `db:"last_activity_at"`
+	OrganizationID *string                `db:"organization_id"` // the agent's organization
+	PromptVersionID *uuid.UUID            `db:"prompt_version_id"` // prompt template version the session is served
+	ArchivedAt     *time.Time             `db:"archived_at"` // set for sessions read from sessions_archive
 }
 
 type Message struct {
@@ -37,10 +44,66 @@ type Message struct {
 	ToolName   *string                `db:"tool_name"`
 	ToolCallID *string                `db:"tool_call_id"`
 	TokenCount *int                   `db:"token_count"`
-	Metadata   map[string]interface{} `db:"metadata"`
+	Metadata   JSONBMap               `db:"metadata"`
+	PromptVersionID *uuid.UUID        `db:"prompt_version_id"` // set on assistant answers of agents using a prompt template
 	CreatedAt  time.Time              `db:"created_at"`
 }
 
+// Message attachment statuses
+const (
+	AttachmentProcessed   = "processed"   // text extracted and stored in memory
+	AttachmentUnsupported = "unsupported" // type with no text extraction; only its metadata is kept
+	AttachmentFailed      = "failed"      // text extraction failed
+)
+
+// MessageSearchFilter restricts a message search across an agent's
+// sessions. Nil fields and empty Roles match every message.
+type MessageSearchFilter struct {
+	SessionID *uuid.UUID
+	Roles     []string
+	From      *time.Time // inclusive
+	To        *time.Time // exclusive
+}
+
+// MessageSearchHit is a message found by a search. Score is the keyword
+// rank or the cosine similarity, and Highlight an excerpt with the query's
+// words marked by <mark> tags.
+type MessageSearchHit struct {
+	ID        int64     `db:"id"`
+	SessionID uuid.UUID `db:"session_id"`
+	Role      string    `db:"role"`
+	Content   string    `db:"content"`
+	ToolName  *string   `db:"tool_name"`
+	CreatedAt time.Time `db:"created_at"`
+	Score     float64   `db:"score"`
+	Highlight string    `db:"highlight"`
+}
+
+// UnembeddedMessage is a message not yet embedded for semantic search
+type UnembeddedMessage struct {
+	ID      int64  `db:"id"`
+	Content string `db:"content"`
+}
+
+// MessageAttachment is a file sent with a user message
+type MessageAttachment struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	SessionID  uuid.UUID `db:"session_id" json:"session_id"`
+	MessageID  *int64    `db:"message_id" json:"message_id,omitempty"`
+	Filename   string    `db:"filename" json:"filename"`
+	MIMEType   string    `db:"mime_type" json:"mime_type"`
+	SizeBytes  int64     `db:"size_bytes" json:"size_bytes"`
+	SHA256     string    `db:"sha256" json:"sha256"`
+	Source     string    `db:"source" json:"source"` // "inline" or "url"
+	SourceURL  *string   `db:"source_url" json:"source_url,omitempty"`
+	Status     string    `db:"status" json:"status"`
+	Error      *string   `db:"error" json:"error,omitempty"`
+	TextLength int       `db:"text_length" json:"text_length"`
+	ChunkCount int       `db:"chunk_count" json:"chunk_count"`
+	Summary    *string   `db:"summary" json:"summary,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
 type MemoryChunk struct {
 	ID              int64                  `db:"id"`
 	AgentID         uuid.UUID              `db:"agent_id"`
@@ -49,16 +112,38 @@ type MemoryChunk struct {
 	Content         string                 `db:"content"`
 	Embedding       []float32              `db:"embedding"` // Will be converted to/from neurondb_vector
 	ImportanceScore float64                `db:"importance_score"`
+	Pinned          bool                   `db:"pinned"` // kept by memory retention
 	Metadata        JSONBMap               `db:"metadata"`
 	CreatedAt       time.Time              `db:"created_at"`
 }
 
+// MemoryChunkFilter selects an agent's memory chunks. Nil fields match
+// every chunk.
+type MemoryChunkFilter struct {
+	Contains      *string // content substring, matched case-insensitively
+	SessionID     *uuid.UUID
+	Pinned        *bool
+	MinImportance *float64
+	MaxImportance *float64
+}
+
 // MemoryChunkWithSimilarity includes similarity score from vector search
 type MemoryChunkWithSimilarity struct {
 	MemoryChunk
 	Similarity float64 `db:"similarity"`
 }
 
+// MemoryUsage summarizes the memory chunks held by an agent
+type MemoryUsage struct {
+	AgentID            uuid.UUID  `db:"agent_id"`
+	ChunkCount         int64      `db:"chunk_count"`
+	ArchivedChunkCount int64      `db:"archived_chunk_count"`
+	ContentBytes       int64      `db:"content_bytes"`
+	AvgImportance      float64    `db:"avg_importance"`
+	OldestChunkAt      *time.Time `db:"oldest_chunk_at"`
+	NewestChunkAt      *time.Time `db:"newest_chunk_at"`
+}
+
 type Tool struct {
 	Name          string                 `db:"name"`
 	Description   string                 `db:"description"`
@@ -66,6 +151,7 @@ type Tool struct {
 	HandlerType   string                 `db:"handler_type"`
 	HandlerConfig JSONBMap               `db:"handler_config"`
 	Enabled       bool                   `db:"enabled"`
+	Version       int64                  `db:"version"` // incremented on every update
 	CreatedAt     time.Time              `db:"created_at"`
 	UpdatedAt     time.Time              `db:"updated_at"`
 }
@@ -101,3 +187,370 @@ type APIKey struct {
 	LastUsedAt      *time.Time             `db:"last_used_at"`
 	ExpiresAt       *time.Time             `db:"expires_at"`
 }
+
+// Organization is a registered tenant. Its ID is the organization_id of
+// its API keys, agents and sessions.
+type Organization struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	Metadata  JSONBMap  `db:"metadata"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// Secret is an encrypted credential tools refer to as secret://name. Its
+// value is encrypted with a data key, which is stored wrapped by the master
+// key KeyID; Version increments whenever the value or description changes.
+type Secret struct {
+	Name        string    `db:"name"`
+	Description *string   `db:"description"`
+	Ciphertext  []byte    `db:"ciphertext"`
+	WrappedKey  []byte    `db:"wrapped_key"`
+	KeyID       string    `db:"key_id"`
+	Version     int64     `db:"version"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+// User statuses. A user is invited until its first API key is created, and
+// a disabled user has no keys.
+const (
+	UserInvited  = "invited"
+	UserActive   = "active"
+	UserDisabled = "disabled"
+)
+
+// User is a member of an organization. Roles bound the roles of the user's
+// API keys.
+type User struct {
+	ID             uuid.UUID      `db:"id"`
+	OrganizationID string         `db:"organization_id"`
+	Email          string         `db:"email"`
+	Name           *string        `db:"name"`
+	Roles          pq.StringArray `db:"roles"`
+	Status         string         `db:"status"`
+	CreatedAt      time.Time      `db:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at"`
+}
+
+// UsageRecord is one LLM call in the usage ledger
+type UsageRecord struct {
+	ID               int64      `db:"id"`
+	AgentID          uuid.UUID  `db:"agent_id"`
+	SessionID        *uuid.UUID `db:"session_id"`
+	MessageID        *int64     `db:"message_id"`
+	APIKeyID         *uuid.UUID `db:"api_key_id"`
+	Model            string     `db:"model"`
+	Provider         *string    `db:"provider"`
+	PromptTokens     int        `db:"prompt_tokens"`
+	CompletionTokens int        `db:"completion_tokens"`
+	TotalTokens      int        `db:"total_tokens"`
+	CostUSD          float64    `db:"cost_usd"`
+	CreatedAt        time.Time  `db:"created_at"`
+}
+
+// UsageTotals sums usage ledger entries
+type UsageTotals struct {
+	Requests         int64   `db:"requests" json:"requests"`
+	PromptTokens     int64   `db:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int64   `db:"completion_tokens" json:"completion_tokens"`
+	TotalTokens      int64   `db:"total_tokens" json:"total_tokens"`
+	CostUSD          float64 `db:"cost_usd" json:"cost_usd"`
+}
+
+// UsageReportRow is the usage of one agent, API key and model on one day
+type UsageReportRow struct {
+	Day      time.Time  `db:"day" json:"day"`
+	AgentID  uuid.UUID  `db:"agent_id" json:"agent_id"`
+	APIKeyID *uuid.UUID `db:"api_key_id" json:"api_key_id,omitempty"`
+	Model    string     `db:"model" json:"model"`
+	UsageTotals
+}
+
+// UsageFilter selects usage ledger entries. Nil fields match everything;
+// From is inclusive and To exclusive.
+type UsageFilter struct {
+	AgentID  *uuid.UUID
+	APIKeyID *uuid.UUID
+	From     *time.Time
+	To       *time.Time
+}
+
+// Quota scopes
+const (
+	QuotaScopeAPIKey       = "api_key"
+	QuotaScopeOrganization = "organization"
+)
+
+// QuotaSubject is the API key or organization a daily quota is counted for
+type QuotaSubject struct {
+	Scope   string
+	Subject string // API key ID or organization ID
+}
+
+// QuotaUsage is what a quota subject used on Day (UTC)
+type QuotaUsage struct {
+	Scope    string    `db:"scope"`
+	Subject  string    `db:"subject"`
+	Day      time.Time `db:"day"`
+	Requests int64     `db:"requests"`
+	Tokens   int64     `db:"tokens"`
+}
+
+// MessageFeedback is a rating of an assistant message
+type MessageFeedback struct {
+	ID        int64      `db:"id"`
+	MessageID int64      `db:"message_id"`
+	SessionID uuid.UUID  `db:"session_id"`
+	AgentID   uuid.UUID  `db:"agent_id"`
+	APIKeyID  *uuid.UUID `db:"api_key_id"`
+	Rating    *string    `db:"rating"` // "up" or "down"
+	Score     *int       `db:"score"`  // 1 to 5
+	Comment   *string    `db:"comment"`
+	Metadata  JSONBMap   `db:"metadata"`
+	PromptVersionID *uuid.UUID `db:"prompt_version_id"` // prompt template version of the rated message
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+}
+
+// FeedbackFilter selects message feedback. Nil fields match everything;
+// From is inclusive and To exclusive.
+type FeedbackFilter struct {
+	AgentID   *uuid.UUID
+	APIKeyID  *uuid.UUID
+	SessionID *uuid.UUID
+	Rating    *string
+	MinScore  *int
+	MaxScore  *int
+	From      *time.Time
+	To        *time.Time
+	PromptVersionID  *uuid.UUID
+	PromptTemplateID *uuid.UUID // feedback on messages served any version of the template
+}
+
+// FeedbackSummary aggregates message feedback
+type FeedbackSummary struct {
+	Count       int64    `db:"count" json:"count"`
+	Up          int64    `db:"up" json:"up"`
+	Down        int64    `db:"down" json:"down"`
+	Scored      int64    `db:"scored" json:"scored"`
+	AvgScore    *float64 `db:"avg_score" json:"avg_score"`
+	WithComment int64    `db:"with_comment" json:"with_comment"`
+}
+
+// PromptTemplate is a named system prompt with versions. Agents using it
+// serve each session one version, chosen by the versions' rollout weights.
+type PromptTemplate struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	Name           string    `db:"name" json:"name"`
+	Description    *string   `db:"description" json:"description,omitempty"`
+	OrganizationID *string   `db:"organization_id" json:"organization_id,omitempty"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// PromptTemplateVersion is one version of a prompt template. Content holds
+// {{name}} placeholders for Variables, which map each variable to its
+// default; a variable with a nil default must be given by the session.
+type PromptTemplateVersion struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	TemplateID    uuid.UUID `db:"template_id" json:"template_id"`
+	Version       int       `db:"version" json:"version"`
+	Content       string    `db:"content" json:"content"`
+	Variables     JSONBMap  `db:"variables" json:"variables"`
+	RolloutWeight int       `db:"rollout_weight" json:"rollout_weight"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// PromptVersionMetrics is the feedback on the messages one prompt template
+// version served
+type PromptVersionMetrics struct {
+	PromptVersionID uuid.UUID `db:"prompt_version_id" json:"prompt_version_id"`
+	Version         int       `db:"version" json:"version"`
+	RolloutWeight   int       `db:"rollout_weight" json:"rollout_weight"`
+	Messages        int64     `db:"messages" json:"messages"` // assistant messages served
+	FeedbackSummary
+	// UpRate is the share of ratings that are up, nil with no ratings
+	UpRate *float64 `db:"up_rate" json:"up_rate"`
+}
+
+// Tool approval statuses. A pending approval is decided as approved or
+// rejected, after which the paused run resumes and ends completed or failed.
+// Pending approvals past their expiry are marked expired.
+const (
+	ToolApprovalPending   = "pending"
+	ToolApprovalApproved  = "approved"
+	ToolApprovalRejected  = "rejected"
+	ToolApprovalExpired   = "expired"
+	ToolApprovalCompleted = "completed"
+	ToolApprovalFailed    = "failed"
+)
+
+// ToolApproval is an agent run paused until an operator decides on its tool
+// calls
+type ToolApproval struct {
+	ID           uuid.UUID  `db:"id"`
+	SessionID    uuid.UUID  `db:"session_id"`
+	AgentID      uuid.UUID  `db:"agent_id"`
+	Status       string     `db:"status"`
+	ToolCalls    []byte     `db:"tool_calls"` // JSON array of the run's tool calls
+	RunState     JSONBMap   `db:"run_state"`  // what the run needs to resume
+	DecidedBy    *string    `db:"decided_by"`
+	Reason       *string    `db:"reason"`
+	Result       JSONBMap   `db:"result"` // the resumed run's answer
+	ErrorMessage *string    `db:"error_message"`
+	CreatedAt    time.Time  `db:"created_at"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	DecidedAt    *time.Time `db:"decided_at"`
+	CompletedAt  *time.Time `db:"completed_at"`
+}
+
+// ToolApprovalFilter selects tool approvals. Nil fields match everything.
+type ToolApprovalFilter struct {
+	Status    *string
+	AgentID   *uuid.UUID
+	SessionID *uuid.UUID
+}
+
+// Agent run statuses. A run is running until it completes or fails, or
+// awaits an approval while paused for one. A running run whose heartbeat
+// stopped was interrupted and is resumed or failed by recovery.
+const (
+	RunRunning          = "running"
+	RunAwaitingApproval = "awaiting_approval"
+	RunCompleted        = "completed"
+	RunFailed           = "failed"
+)
+
+// Run is the execution of a message by its agent, checkpointed after each
+// step
+type Run struct {
+	ID           uuid.UUID  `db:"id"`
+	SessionID    uuid.UUID  `db:"session_id"`
+	AgentID      uuid.UUID  `db:"agent_id"`
+	Status       string     `db:"status"`
+	Step         string     `db:"step"`
+	Checkpoint   JSONBMap   `db:"checkpoint"` // what the run needs to continue after Step
+	Result       JSONBMap   `db:"result"`
+	ErrorMessage *string    `db:"error_message"`
+	Attempts     int        `db:"attempts"`
+	ApprovalID   *uuid.UUID `db:"approval_id"`
+	HeartbeatAt  time.Time  `db:"heartbeat_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at"`
+	CompletedAt  *time.Time `db:"completed_at"`
+}
+
+// Webhook is an endpoint subscribed to lifecycle events
+type Webhook struct {
+	ID          uuid.UUID      `db:"id"`
+	URL         string         `db:"url"`
+	Events      pq.StringArray `db:"events"`     // event types; "*" subscribes to all
+	SecretEnv   *string        `db:"secret_env"` // environment variable holding the signing secret
+	Description *string        `db:"description"`
+	Enabled     bool           `db:"enabled"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+}
+
+// Webhook delivery statuses. A delivery is retried while pending and is
+// dead-lettered as dead once its attempts run out.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookDelivery is one event queued for one endpoint
+type WebhookDelivery struct {
+	ID             int64      `db:"id"`
+	WebhookID      *uuid.UUID `db:"webhook_id"` // nil for an agent's own endpoint
+	EventID        uuid.UUID  `db:"event_id"`
+	Event          string     `db:"event"`
+	URL            string     `db:"url"`
+	SecretEnv      *string    `db:"secret_env"`
+	Payload        []byte     `db:"payload"` // the JSON body posted to the endpoint
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	LastStatusCode *int       `db:"last_status_code"`
+	LastError      *string    `db:"last_error"`
+	CreatedAt      time.Time  `db:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+}
+
+// WebhookDeliveryFilter selects webhook deliveries. Nil fields match
+// everything.
+type WebhookDeliveryFilter struct {
+	WebhookID *uuid.UUID
+	Status    *string
+	Event     *string
+}
+
+// SemanticCacheEntry is a stored answer of an agent, served again for
+// prompts close enough to the one that produced it
+type SemanticCacheEntry struct {
+	ID              int64      `db:"id"`
+	AgentID         uuid.UUID  `db:"agent_id"`
+	AgentVersion    int64      `db:"agent_version"`
+	ModelName       string     `db:"model_name"`
+	Prompt          string     `db:"prompt"`
+	PromptEmbedding []float32  `db:"-"`
+	Response        string     `db:"response"`
+	TokensUsed      int        `db:"tokens_used"`
+	CostUSD         float64    `db:"cost_usd"`
+	HitCount        int        `db:"hit_count"`
+	LastHitAt       *time.Time `db:"last_hit_at"`
+	CreatedAt       time.Time  `db:"created_at"`
+	ExpiresAt       *time.Time `db:"expires_at"`
+}
+
+// SemanticCacheMatch is the closest cache entry to a prompt
+type SemanticCacheMatch struct {
+	SemanticCacheEntry
+	Similarity float64 `db:"similarity"`
+}
+
+// SessionSummary is the rolling summary of the older messages of a session,
+// given to the model in their place
+type SessionSummary struct {
+	SessionID        uuid.UUID `db:"session_id"`
+	Summary          string    `db:"summary"`
+	ThroughMessageID int64     `db:"through_message_id"` // newest message the summary covers
+	MessageCount     int       `db:"message_count"`      // messages folded into the summary
+	CreatedAt        time.Time `db:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at"`
+}
+
+// ConversationSummary is a summary of a whole session in one style and
+// length, cached for the session summary endpoint
+type ConversationSummary struct {
+	SessionID        uuid.UUID `db:"session_id"`
+	Style            string    `db:"style"`
+	Length           string    `db:"length"`
+	Summary          string    `db:"summary"`
+	ThroughMessageID int64     `db:"through_message_id"` // newest message the summary covers
+	MessageCount     int       `db:"message_count"`      // messages folded into the summary
+	CreatedAt        time.Time `db:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at"`
+}
+
+// LLMLog is a sampled LLM call of an agent with its prompt and response
+type LLMLog struct {
+	ID               int64      `db:"id"`
+	AgentID          uuid.UUID  `db:"agent_id"`
+	SessionID        *uuid.UUID `db:"session_id"`
+	RunID            *uuid.UUID `db:"run_id"`
+	Purpose          string     `db:"purpose"`
+	Model            string     `db:"model"`
+	Provider         *string    `db:"provider"`
+	Prompt           string     `db:"prompt"`
+	Response         *string    `db:"response"` // nil when the call failed
+	ErrorMessage     *string    `db:"error_message"`
+	PromptTokens     int        `db:"prompt_tokens"`
+	CompletionTokens int        `db:"completion_tokens"`
+	LatencyMs        float64    `db:"latency_ms"`
+	Redactions       JSONBMap   `db:"redactions"` // redacted values by type
+	Truncated        bool       `db:"truncated"`
+	CreatedAt        time.Time  `db:"created_at"`
+}
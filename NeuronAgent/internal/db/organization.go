@@ -0,0 +1,46 @@
+package db
+
+import "context"
+
+type organizationKey struct{}
+
+// organizationScope is the organization the agent and session queries of a
+// context are limited to. A nil id is the organization of rows with none.
+type organizationScope struct {
+	id *string
+}
+
+// WithOrganization scopes the agent and session queries run with the
+// returned context to the rows of one organization. A nil organizationID
+// scopes them to rows that have no organization. Queries run with a context
+// that has no scope, such as those of background workers and admin
+// requests, see every organization.
+func WithOrganization(ctx context.Context, organizationID *string) context.Context {
+	return context.WithValue(ctx, organizationKey{}, organizationScope{id: organizationID})
+}
+
+// OrganizationFromContext returns the organization ctx is scoped to, and
+// false if it is not scoped
+func OrganizationFromContext(ctx context.Context) (*string, bool) {
+	scope, ok := ctx.Value(organizationKey{}).(organizationScope)
+	return scope.id, ok
+}
+
+// organizationParams returns the parameters of an organization filter:
+// whether ctx is scoped, and the organization it is scoped to. Queries
+// filter with
+//
+//	(NOT $n::boolean OR organization_id IS NOT DISTINCT FROM $m::text)
+func organizationParams(ctx context.Context) (bool, *string) {
+	id, scoped := OrganizationFromContext(ctx)
+	return scoped, id
+}
+
+// newAgentOrganization returns the organization a new agent belongs to: the
+// one ctx is scoped to, or the one the agent names when ctx is not scoped
+func newAgentOrganization(ctx context.Context, agent *Agent) *string {
+	if id, scoped := OrganizationFromContext(ctx); scoped {
+		return id
+	}
+	return agent.OrganizationID
+}
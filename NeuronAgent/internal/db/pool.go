@@ -0,0 +1,376 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// Adaptive pool defaults
+const (
+	defaultPoolSampleInterval = 10 * time.Second
+	defaultPoolTargetWait     = 20 * time.Millisecond
+	defaultPoolMaxCPU         = 0.85
+	// poolShrinkAfter is the number of consecutive quiet samples after which
+	// the pool is shrunk by one step
+	poolShrinkAfter = 6
+	// slowAcquireQueryChars bounds the query text in slow acquire logs
+	slowAcquireQueryChars = 300
+)
+
+// AdaptivePoolConfig resizes the pool while the server runs. Each sample
+// interval the pool grows by a quarter when connection requests waited on
+// average at least TargetWait, unless the CPU is busier than MaxCPU, where
+// more connections would only add contention. After several quiet samples,
+// with no waits and at most half the connections in use, it shrinks by an
+// eighth. The size stays within [MinOpenConns, MaxOpenConns].
+type AdaptivePoolConfig struct {
+	Enabled        bool
+	MinOpenConns   int
+	MaxOpenConns   int
+	TargetWait     time.Duration
+	MaxCPU         float64
+	SampleInterval time.Duration
+}
+
+// withDefaults fills the unset settings, bounding the pool around the
+// configured size
+func (c AdaptivePoolConfig) withDefaults(configured int) AdaptivePoolConfig {
+	if c.MinOpenConns <= 0 {
+		c.MinOpenConns = configured
+		if c.MinOpenConns <= 0 {
+			c.MinOpenConns = 1
+		}
+	}
+	if c.MaxOpenConns <= 0 {
+		c.MaxOpenConns = 4 * c.MinOpenConns
+	}
+	if c.TargetWait <= 0 {
+		c.TargetWait = defaultPoolTargetWait
+	}
+	if c.MaxCPU <= 0 || c.MaxCPU > 1 {
+		c.MaxCPU = defaultPoolMaxCPU
+	}
+	if c.SampleInterval <= 0 {
+		c.SampleInterval = defaultPoolSampleInterval
+	}
+	return c
+}
+
+// Validate checks the bounds of the adaptive settings
+func (c AdaptivePoolConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MinOpenConns < 0 || c.MaxOpenConns < 0 {
+		return fmt.Errorf("adaptive pool bounds must not be negative: min_open_conns=%d, max_open_conns=%d", c.MinOpenConns, c.MaxOpenConns)
+	}
+	if c.MinOpenConns > 0 && c.MaxOpenConns > 0 && c.MinOpenConns > c.MaxOpenConns {
+		return fmt.Errorf("adaptive pool min_open_conns (%d) exceeds max_open_conns (%d)", c.MinOpenConns, c.MaxOpenConns)
+	}
+	if c.MaxCPU < 0 || c.MaxCPU > 1 {
+		return fmt.Errorf("adaptive pool max_cpu must be between 0 and 1, got %g", c.MaxCPU)
+	}
+	return nil
+}
+
+// PoolMonitor samples the connection pool, exports its statistics as
+// metrics and, when adaptive sizing is enabled, resizes it
+type PoolMonitor struct {
+	db       *DB
+	adaptive AdaptivePoolConfig
+	slow     time.Duration
+	interval time.Duration
+	cpu      cpuSampler
+
+	prev  sql.DBStats
+	size  int
+	quiet int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPoolMonitor creates a monitor of the pool of d, sized and tuned by the
+// pool configuration d was opened with
+func NewPoolMonitor(d *DB) (*PoolMonitor, error) {
+	config := d.poolConfig
+	if err := config.Adaptive.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &PoolMonitor{
+		db:       d,
+		slow:     config.SlowAcquireThreshold,
+		interval: defaultPoolSampleInterval,
+		size:     config.MaxOpenConns,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	if config.Adaptive.Enabled {
+		m.adaptive = config.Adaptive.withDefaults(config.MaxOpenConns)
+		m.interval = m.adaptive.SampleInterval
+		m.size = clampInt(config.MaxOpenConns, m.adaptive.MinOpenConns, m.adaptive.MaxOpenConns)
+		m.resize(m.size)
+	}
+	m.prev = d.DB.Stats()
+	return m, nil
+}
+
+// Start starts sampling the pool
+func (m *PoolMonitor) Start() {
+	go m.run()
+}
+
+// Stop stops sampling the pool
+func (m *PoolMonitor) Stop() {
+	m.cancel()
+	<-m.done
+}
+
+func (m *PoolMonitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.sample()
+		}
+	}
+}
+
+// sample exports the pool statistics gathered since the previous sample
+// and resizes the pool when adaptive sizing is enabled
+func (m *PoolMonitor) sample() {
+	stats := m.db.DB.Stats()
+	waits := stats.WaitCount - m.prev.WaitCount
+	waited := stats.WaitDuration - m.prev.WaitDuration
+	metrics.RecordDBPoolStats(stats.InUse, stats.Idle, stats.MaxOpenConnections)
+	metrics.RecordDBPoolWaits(waits, waited)
+	metrics.RecordDBPoolClosed("max_idle", stats.MaxIdleClosed-m.prev.MaxIdleClosed)
+	metrics.RecordDBPoolClosed("max_idle_time", stats.MaxIdleTimeClosed-m.prev.MaxIdleTimeClosed)
+	metrics.RecordDBPoolClosed("max_lifetime", stats.MaxLifetimeClosed-m.prev.MaxLifetimeClosed)
+	m.prev = stats
+
+	if !m.adaptive.Enabled {
+		return
+	}
+	cpu, cpuKnown := m.cpu.utilization()
+
+	switch {
+	case waits > 0 && waited/time.Duration(waits) >= m.adaptive.TargetWait:
+		m.quiet = 0
+		if m.size >= m.adaptive.MaxOpenConns {
+			return
+		}
+		if cpuKnown && cpu >= m.adaptive.MaxCPU {
+			metrics.Logger().Debug().
+				Float64("cpu", cpu).
+				Int("max_open_conns", m.size).
+				Msg("Database pool is waiting but CPU is saturated; not growing it")
+			return
+		}
+		size := clampInt(m.size+max(1, m.size/4), m.adaptive.MinOpenConns, m.adaptive.MaxOpenConns)
+		m.logResize("grow", size, stats, waits, waited, cpu)
+		m.resize(size)
+	case waits == 0 && stats.InUse <= m.size/2:
+		m.quiet++
+		if m.quiet < poolShrinkAfter || m.size <= m.adaptive.MinOpenConns {
+			return
+		}
+		m.quiet = 0
+		size := clampInt(m.size-max(1, m.size/8), m.adaptive.MinOpenConns, m.adaptive.MaxOpenConns)
+		m.logResize("shrink", size, stats, waits, waited, cpu)
+		m.resize(size)
+	default:
+		m.quiet = 0
+	}
+}
+
+func (m *PoolMonitor) logResize(direction string, size int, stats sql.DBStats, waits int64, waited time.Duration, cpu float64) {
+	metrics.RecordDBPoolResize(direction)
+	var averageWait time.Duration
+	if waits > 0 {
+		averageWait = waited / time.Duration(waits)
+	}
+	metrics.Logger().Info().
+		Str("direction", direction).
+		Int("from_max_open_conns", m.size).
+		Int("to_max_open_conns", size).
+		Int("in_use", stats.InUse).
+		Int64("waits", waits).
+		Dur("average_wait", averageWait).
+		Float64("cpu", cpu).
+		Msg("Resized database pool")
+}
+
+// resize sets the limit on open connections. Lowering it below the idle
+// limit also lowers that, so the idle limit is restored as far as the new
+// size allows.
+func (m *PoolMonitor) resize(size int) {
+	m.size = size
+	m.db.DB.SetMaxOpenConns(size)
+	m.db.DB.SetMaxIdleConns(min(m.db.poolConfig.MaxIdleConns, size))
+}
+
+// acquired records the time a query waited for a connection, logging the
+// query when it waited longer than the slow acquire threshold
+func (m *PoolMonitor) acquired(query string, wait time.Duration) {
+	slow := m.slow > 0 && wait >= m.slow
+	metrics.RecordDBPoolAcquire(wait, slow)
+	if !slow {
+		return
+	}
+	stats := m.db.DB.Stats()
+	metrics.Logger().Warn().
+		Dur("wait", wait).
+		Int("in_use", stats.InUse).
+		Int("max_open_conns", stats.MaxOpenConnections).
+		Str("query", compactQuery(query)).
+		Msg("Slow database connection acquire")
+}
+
+// compactQuery collapses the whitespace of a query and bounds its length
+// for logging
+func compactQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > slowAcquireQueryChars {
+		query = query[:slowAcquireQueryChars] + "..."
+	}
+	return query
+}
+
+// instrumentedPool runs each statement on a connection it acquires itself,
+// so the time spent waiting for the connection can be measured.
+// Transactions hold their connection throughout and are begun on the pool
+// directly.
+type instrumentedPool struct {
+	*sqlx.DB
+	monitor *PoolMonitor
+}
+
+// poolAcquireAttempts bounds the connections tried for one statement; a
+// connection found broken when the statement is sent is replaced, as
+// database/sql does for statements run on the pool
+const poolAcquireAttempts = 2
+
+func (p *instrumentedPool) withConn(ctx context.Context, query string, fn func(conn *sqlx.Conn) error) error {
+	var err error
+	for attempt := 0; attempt < poolAcquireAttempts; attempt++ {
+		start := time.Now()
+		var conn *sqlx.Conn
+		conn, err = p.DB.Connx(ctx)
+		if err != nil {
+			return err
+		}
+		p.monitor.acquired(query, time.Since(start))
+		err = fn(conn)
+		conn.Close()
+		if !errors.Is(err, driver.ErrBadConn) {
+			return err
+		}
+	}
+	return err
+}
+
+func (p *instrumentedPool) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return p.withConn(ctx, query, func(conn *sqlx.Conn) error {
+		return conn.GetContext(ctx, dest, query, args...)
+	})
+}
+
+func (p *instrumentedPool) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return p.withConn(ctx, query, func(conn *sqlx.Conn) error {
+		return conn.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+func (p *instrumentedPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := p.withConn(ctx, query, func(conn *sqlx.Conn) error {
+		var err error
+		result, err = conn.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// cpuSampler measures the share of time the host's CPUs were busy between
+// two calls, from /proc/stat. It reports nothing where that is not
+// available.
+type cpuSampler struct {
+	busy, total uint64
+	primed      bool
+}
+
+func (s *cpuSampler) utilization() (float64, bool) {
+	busy, total, err := readCPUTimes()
+	if err != nil {
+		return 0, false
+	}
+	prevBusy, prevTotal, primed := s.busy, s.total, s.primed
+	s.busy, s.total, s.primed = busy, total, true
+	if !primed || total <= prevTotal {
+		return 0, false
+	}
+	return float64(busy-prevBusy) / float64(total-prevTotal), true
+}
+
+// readCPUTimes returns the busy and total jiffies of all CPUs
+func readCPUTimes() (busy, total uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var idle uint64
+		// user nice system idle iowait irq softirq steal; guest time is
+		// already counted in user and nice
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			n, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid /proc/stat cpu line: %w", err)
+			}
+			total += n
+			if i == 3 || i == 4 {
+				idle += n
+			}
+		}
+		return total - idle, total, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+func clampInt(n, lo, hi int) int {
+	return max(lo, min(n, hi))
+}
@@ -3,33 +3,68 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/neurondb/NeuronAgent/internal/utils"
 )
 
+// ErrVersionConflict is returned by conditional updates when the row has
+// been changed since the version the caller read
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrAlreadyExists is returned when creating a row whose key is taken
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrInUse is returned when deleting a row other rows still belong to
+var ErrInUse = errors.New("in use")
+
 // Agent queries
 const (
 	createAgentQuery = `
 		INSERT INTO neurondb_agent.agents 
-		(name, description, system_prompt, model_name, memory_table, enabled_tools, config)
-		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb)
-		RETURNING id, created_at, updated_at`
-
-	getAgentByIDQuery = `SELECT * FROM neurondb_agent.agents WHERE id = $1`
-
-	listAgentsQuery = `SELECT * FROM neurondb_agent.agents ORDER BY created_at DESC`
+		(name, description, system_prompt, model_name, memory_table, enabled_tools, config, organization_id, prompt_template_id, owner_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8, $9, $10)
+		RETURNING id, version, created_at, updated_at`
+
+	// The agent and session queries that take an organization filter limit
+	// their rows to one organization when their scoped parameter is true
+	getAgentByIDQuery = `
+		SELECT * FROM neurondb_agent.agents
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	getAgentByNameQuery = `
+		SELECT * FROM neurondb_agent.agents
+		WHERE name = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	listAgentsQuery = `
+		SELECT * FROM neurondb_agent.agents
+		WHERE (NOT $1::boolean OR organization_id IS NOT DISTINCT FROM $2::text)
+		ORDER BY created_at DESC`
 
 	updateAgentQuery = `
 		UPDATE neurondb_agent.agents 
 		SET name = $2, description = $3, system_prompt = $4, model_name = $5,
-			memory_table = $6, enabled_tools = $7, config = $8::jsonb
-		WHERE id = $1
-		RETURNING updated_at`
-
-	deleteAgentQuery = `DELETE FROM neurondb_agent.agents WHERE id = $1`
+			memory_table = $6, enabled_tools = $7, config = $8::jsonb, prompt_template_id = $12
+		WHERE id = $1 AND version = $9
+		  AND (NOT $10::boolean OR organization_id IS NOT DISTINCT FROM $11::text)
+		RETURNING version, updated_at`
+
+	getAgentVersionQuery = `
+		SELECT version FROM neurondb_agent.agents
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	deleteAgentQuery = `
+		DELETE FROM neurondb_agent.agents
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
 )
 
 // Session queries
@@ -39,36 +74,233 @@ const (
 		VALUES ($1, $2, $3::jsonb)
 		RETURNING id, created_at, last_activity_at`
 
-	getSessionQuery = `SELECT * FROM neurondb_agent.sessions WHERE id = $1`
+	getSessionQuery = `
+		SELECT * FROM neurondb_agent.sessions
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
 
 	listSessionsQuery = `
 		SELECT * FROM neurondb_agent.sessions 
 		WHERE agent_id = $1 
+		  AND (NOT $4::boolean OR organization_id IS NOT DISTINCT FROM $5::text)
 		ORDER BY last_activity_at DESC 
 		LIMIT $2 OFFSET $3`
 
-	deleteSessionQuery = `DELETE FROM neurondb_agent.sessions WHERE id = $1`
+	deleteSessionQuery = `
+		DELETE FROM neurondb_agent.sessions
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	importSessionQuery = `
+		INSERT INTO neurondb_agent.sessions (agent_id, external_user_id, metadata, created_at)
+		VALUES ($1, $2, $3::jsonb, $4)
+		RETURNING id, created_at, last_activity_at`
+
+	// assignSessionPromptVersionQuery sets the prompt version of a session
+	// that has no version of the template $3 and returns the session's
+	// version, so the first assignment sticks
+	assignSessionPromptVersionQuery = `
+		UPDATE neurondb_agent.sessions
+		SET prompt_version_id = CASE
+			WHEN prompt_version_id IN (
+				SELECT id FROM neurondb_agent.prompt_template_versions WHERE template_id = $3)
+			THEN prompt_version_id ELSE $2 END
+		WHERE id = $1
+		RETURNING prompt_version_id`
+
+	setSessionActivityQuery = `
+		UPDATE neurondb_agent.sessions 
+		SET last_activity_at = $2
+		WHERE id = $1`
+)
+
+// Session archive queries
+const (
+	archivedSessionColumns = `id, agent_id, external_user_id, metadata, created_at, last_activity_at, organization_id`
+
+	listIdleSessionsQuery = `
+		SELECT * FROM neurondb_agent.sessions
+		WHERE agent_id = $1 AND last_activity_at < $2
+		ORDER BY last_activity_at
+		LIMIT $3`
+
+	insertSessionArchiveQuery = `
+		INSERT INTO neurondb_agent.sessions_archive
+			(id, agent_id, external_user_id, metadata, message_count, created_at, last_activity_at, archive)
+		VALUES ($1, $2, $3, $4::jsonb, $5, $6, $7, $8::jsonb)`
+
+	// Only deletes the session if it has had no activity since it was read
+	deleteIdleSessionQuery = `DELETE FROM neurondb_agent.sessions WHERE id = $1 AND last_activity_at = $2`
+
+	getArchivedSessionQuery = `
+		SELECT ` + archivedSessionColumns + `, archived_at
+		FROM neurondb_agent.sessions_archive
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	getSessionArchiveQuery = `
+		SELECT archive FROM neurondb_agent.sessions_archive
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	listArchivedSessionsQuery = `
+		SELECT ` + archivedSessionColumns + `, archived_at
+		FROM neurondb_agent.sessions_archive
+		WHERE agent_id = $1
+		  AND (NOT $4::boolean OR organization_id IS NOT DISTINCT FROM $5::text)
+		ORDER BY last_activity_at DESC
+		LIMIT $2 OFFSET $3`
+
+	listAllSessionsQuery = `
+		SELECT ` + archivedSessionColumns + `, NULL::timestamptz AS archived_at
+		FROM neurondb_agent.sessions
+		WHERE agent_id = $1 AND (NOT $4::boolean OR organization_id IS NOT DISTINCT FROM $5::text)
+		UNION ALL
+		SELECT ` + archivedSessionColumns + `, archived_at
+		FROM neurondb_agent.sessions_archive
+		WHERE agent_id = $1 AND (NOT $4::boolean OR organization_id IS NOT DISTINCT FROM $5::text)
+		ORDER BY last_activity_at DESC
+		LIMIT $2 OFFSET $3`
+
+	purgeSessionsQuery = `
+		WITH purged AS (
+			DELETE FROM neurondb_agent.sessions
+			WHERE agent_id = $1 AND last_activity_at < $2
+			RETURNING 1
+		)
+		SELECT count(*) FROM purged`
+
+	purgeArchivedSessionsQuery = `
+		WITH purged AS (
+			DELETE FROM neurondb_agent.sessions_archive
+			WHERE agent_id = $1 AND last_activity_at < $2
+			RETURNING 1
+		)
+		SELECT count(*) FROM purged`
+
+	deleteArchivedSessionQuery = `
+		DELETE FROM neurondb_agent.sessions_archive
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
 )
 
 // Message queries
 const (
 	createMessageQuery = `
 		INSERT INTO neurondb_agent.messages 
-		(session_id, role, content, tool_name, tool_call_id, token_count, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb)
+		(session_id, role, content, tool_name, tool_call_id, token_count, metadata, prompt_version_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8)
 		RETURNING id, created_at`
 
+	// createMessagesInsert is createMessageQuery up to VALUES, for inserting
+	// several messages in one statement
+	createMessagesInsert = `
+		INSERT INTO neurondb_agent.messages
+		(session_id, role, content, tool_name, tool_call_id, token_count, metadata, prompt_version_id)`
+
 	getMessagesQuery = `
 		SELECT * FROM neurondb_agent.messages 
 		WHERE session_id = $1 
-		ORDER BY created_at ASC 
+		ORDER BY created_at ASC, id ASC
 		LIMIT $2 OFFSET $3`
 
 	getRecentMessagesQuery = `
 		SELECT * FROM neurondb_agent.messages 
 		WHERE session_id = $1 
-		ORDER BY created_at DESC 
+		ORDER BY created_at DESC, id DESC
 		LIMIT $2`
+
+	getAllMessagesQuery = `
+		SELECT * FROM neurondb_agent.messages 
+		WHERE session_id = $1 
+		ORDER BY created_at ASC, id ASC`
+
+	importMessageQuery = `
+		INSERT INTO neurondb_agent.messages 
+		(session_id, role, content, tool_name, tool_call_id, token_count, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8)
+		RETURNING id, created_at`
+)
+
+// Message search queries
+const (
+	// messageSearchFrom selects an agent's messages matching a
+	// MessageSearchFilter; the filter is bound to $1-$5
+	messageSearchFrom = `
+		FROM neurondb_agent.messages m
+		JOIN neurondb_agent.sessions s ON s.id = m.session_id`
+
+	messageSearchWhere = `
+		WHERE s.agent_id = $1
+		  AND ($2::uuid IS NULL OR m.session_id = $2)
+		  AND ($3::text[] IS NULL OR m.role = ANY($3))
+		  AND ($4::timestamptz IS NULL OR m.created_at >= $4)
+		  AND ($5::timestamptz IS NULL OR m.created_at < $5)`
+
+	messageSearchColumns = `m.id, m.session_id, m.role, m.content, m.tool_name, m.created_at`
+
+	// messageHeadlineOptions marks matched words and keeps highlights short
+	messageHeadlineOptions = `'StartSel=<mark>, StopSel=</mark>, MaxFragments=3, MaxWords=20, MinWords=8, FragmentDelimiter=" ... "'`
+
+	searchMessagesKeywordQuery = `
+		SELECT ` + messageSearchColumns + `,
+			   ts_rank_cd(to_tsvector('english', m.content), tsq)::float8 AS score,
+			   ts_headline('english', m.content, tsq, ` + messageHeadlineOptions + `) AS highlight` +
+		messageSearchFrom + `
+		CROSS JOIN websearch_to_tsquery('english', $6) AS tsq` +
+		messageSearchWhere + `
+		  AND to_tsvector('english', m.content) @@ tsq
+		ORDER BY score DESC, m.created_at DESC, m.id DESC
+		LIMIT $7`
+
+	searchMessagesSemanticQuery = `
+		SELECT ` + messageSearchColumns + `,
+			   (1 - (e.embedding <=> $6::neurondb_vector))::float8 AS score,
+			   ts_headline('english', m.content, websearch_to_tsquery('english', $8), ` + messageHeadlineOptions + `) AS highlight` +
+		messageSearchFrom + `
+		JOIN neurondb_agent.message_embeddings e ON e.message_id = m.id AND e.model = $7` +
+		messageSearchWhere + `
+		ORDER BY e.embedding <=> $6::neurondb_vector, m.id DESC
+		LIMIT $9`
+
+	// messageUnembeddedWhere restricts a search to messages without an
+	// embedding of the model in $6
+	messageUnembeddedWhere = messageSearchWhere + `
+		  AND m.content <> ''
+		  AND NOT EXISTS (
+			  SELECT 1 FROM neurondb_agent.message_embeddings e
+			  WHERE e.message_id = m.id AND e.model = $6)`
+
+	listUnembeddedMessagesQuery = `
+		SELECT m.id, m.content` + messageSearchFrom + messageUnembeddedWhere + `
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $7`
+
+	countUnembeddedMessagesQuery = `
+		SELECT COUNT(*)` + messageSearchFrom + messageUnembeddedWhere
+
+	createMessageEmbeddingsQuery = `
+		INSERT INTO neurondb_agent.message_embeddings (message_id, model, embedding)
+		SELECT t.id, $2, t.embedding::neurondb_vector
+		FROM unnest($1::bigint[], $3::text[]) AS t(id, embedding)
+		ON CONFLICT (message_id, model) DO NOTHING`
+)
+
+// Message attachment queries
+const (
+	createMessageAttachmentQuery = `
+		INSERT INTO neurondb_agent.message_attachments
+		(session_id, filename, mime_type, size_bytes, sha256, source, source_url, status, error, text_length, chunk_count, summary)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING *`
+
+	linkMessageAttachmentsQuery = `
+		UPDATE neurondb_agent.message_attachments
+		SET message_id = $1
+		WHERE id = ANY($2::uuid[])`
+
+	setMessageAttachmentChunkCountQuery = `
+		UPDATE neurondb_agent.message_attachments SET chunk_count = $2 WHERE id = $1`
+
+	listMessageAttachmentsQuery = `
+		SELECT * FROM neurondb_agent.message_attachments
+		WHERE session_id = $1
+		ORDER BY created_at, id`
 )
 
 // Memory chunk queries
@@ -79,6 +311,12 @@ const (
 		VALUES ($1, $2, $3, $4, $5::neurondb_vector, $6, $7::jsonb)
 		RETURNING id, created_at`
 
+	// createMemoryChunksInsert is createMemoryChunkQuery up to VALUES, for
+	// inserting several chunks in one statement
+	createMemoryChunksInsert = `
+		INSERT INTO neurondb_agent.memory_chunks
+		(agent_id, session_id, message_id, content, embedding, importance_score, metadata)`
+
 	searchMemoryQuery = `
 		SELECT id, agent_id, session_id, message_id, content, importance_score, metadata, created_at,
 			   1 - (embedding <=> $1::neurondb_vector) AS similarity
@@ -86,6 +324,125 @@ const (
 		WHERE agent_id = $2
 		ORDER BY embedding <=> $1::neurondb_vector
 		LIMIT $3`
+
+	getMemoryUsageQuery = `
+		SELECT $1::uuid AS agent_id,
+			   COUNT(*) AS chunk_count,
+			   (SELECT COUNT(*) FROM neurondb_agent.memory_chunks_archive WHERE agent_id = $1) AS archived_chunk_count,
+			   COALESCE(SUM(octet_length(content)), 0) AS content_bytes,
+			   COALESCE(AVG(importance_score), 0) AS avg_importance,
+			   MIN(created_at) AS oldest_chunk_at,
+			   MAX(created_at) AS newest_chunk_at
+		FROM neurondb_agent.memory_chunks
+		WHERE agent_id = $1`
+
+	// Expired chunks: unpinned, older than the cutoff and, when a threshold
+	// is given, less important than it
+	deleteExpiredMemoryChunksQuery = `
+		WITH evicted AS (
+			DELETE FROM neurondb_agent.memory_chunks
+			WHERE agent_id = $1 AND created_at < $2 AND NOT pinned
+			  AND ($3::real IS NULL OR importance_score < $3)
+			RETURNING id
+		)
+		SELECT COUNT(*) FROM evicted`
+
+	archiveExpiredMemoryChunksQuery = `
+		WITH evicted AS (
+			DELETE FROM neurondb_agent.memory_chunks
+			WHERE agent_id = $1 AND created_at < $2 AND NOT pinned
+			  AND ($3::real IS NULL OR importance_score < $3)
+			RETURNING *
+		), archived AS (
+			INSERT INTO neurondb_agent.memory_chunks_archive
+			(id, agent_id, session_id, message_id, content, embedding, importance_score, metadata, created_at, eviction_reason)
+			SELECT id, agent_id, session_id, message_id, content, embedding, importance_score, metadata, created_at, 'max_age'
+			FROM evicted
+			ON CONFLICT (id) DO NOTHING
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM evicted`
+
+	// Excess chunks: everything beyond the $2 most important (then newest).
+	// Pinned chunks rank first and are never removed.
+	deleteExcessMemoryChunksQuery = `
+		WITH evicted AS (
+			DELETE FROM neurondb_agent.memory_chunks
+			WHERE id IN (
+				SELECT id FROM neurondb_agent.memory_chunks
+				WHERE agent_id = $1
+				ORDER BY pinned DESC, importance_score DESC, created_at DESC, id DESC
+				OFFSET $2
+			) AND NOT pinned
+			RETURNING id
+		)
+		SELECT COUNT(*) FROM evicted`
+
+	archiveExcessMemoryChunksQuery = `
+		WITH evicted AS (
+			DELETE FROM neurondb_agent.memory_chunks
+			WHERE id IN (
+				SELECT id FROM neurondb_agent.memory_chunks
+				WHERE agent_id = $1
+				ORDER BY pinned DESC, importance_score DESC, created_at DESC, id DESC
+				OFFSET $2
+			) AND NOT pinned
+			RETURNING *
+		), archived AS (
+			INSERT INTO neurondb_agent.memory_chunks_archive
+			(id, agent_id, session_id, message_id, content, embedding, importance_score, metadata, created_at, eviction_reason)
+			SELECT id, agent_id, session_id, message_id, content, embedding, importance_score, metadata, created_at, 'max_chunks'
+			FROM evicted
+			ON CONFLICT (id) DO NOTHING
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM evicted`
+
+	listMemoryChunksBySessionQuery = `
+		SELECT id, agent_id, session_id, message_id, content, embedding::text AS embedding,
+			   importance_score, metadata, created_at
+		FROM neurondb_agent.memory_chunks
+		WHERE session_id = $1
+		ORDER BY created_at ASC, id ASC`
+
+	// memoryChunkFilterClause filters an agent's chunks by content
+	// substring, session, pin and importance range
+	memoryChunkFilterClause = `
+		WHERE agent_id = $1
+		  AND ($2::text IS NULL OR content ILIKE '%' || $2 || '%')
+		  AND ($3::uuid IS NULL OR session_id = $3)
+		  AND ($4::boolean IS NULL OR pinned = $4)
+		  AND ($5::real IS NULL OR importance_score >= $5)
+		  AND ($6::real IS NULL OR importance_score <= $6)`
+
+	listMemoryChunksQuery = `
+		SELECT id, agent_id, session_id, message_id, content, importance_score, pinned, metadata, created_at
+		FROM neurondb_agent.memory_chunks` + memoryChunkFilterClause + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $7 OFFSET $8`
+
+	countMatchingMemoryChunksQuery = `
+		SELECT COUNT(*) FROM neurondb_agent.memory_chunks` + memoryChunkFilterClause
+
+	getMemoryChunkQuery = `
+		SELECT id, agent_id, session_id, message_id, content, importance_score, pinned, metadata, created_at
+		FROM neurondb_agent.memory_chunks
+		WHERE id = $1 AND agent_id = $2`
+
+	updateMemoryChunkQuery = `
+		UPDATE neurondb_agent.memory_chunks
+		SET importance_score = COALESCE($3, importance_score),
+			pinned = COALESCE($4, pinned)
+		WHERE id = $1 AND agent_id = $2
+		RETURNING id, agent_id, session_id, message_id, content, importance_score, pinned, metadata, created_at`
+
+	deleteMemoryChunkQuery = `DELETE FROM neurondb_agent.memory_chunks WHERE id = $1 AND agent_id = $2`
+
+	importMemoryChunkQuery = `
+		INSERT INTO neurondb_agent.memory_chunks 
+		(agent_id, session_id, message_id, content, embedding, importance_score, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5::neurondb_vector, $6, $7::jsonb, $8)
+		RETURNING id, created_at`
 )
 
 // Tool queries
@@ -94,18 +451,23 @@ const (
 		INSERT INTO neurondb_agent.tools 
 		(name, description, arg_schema, handler_type, handler_config, enabled)
 		VALUES ($1, $2, $3::jsonb, $4, $5::jsonb, $6)
-		RETURNING created_at, updated_at`
+		ON CONFLICT (name) DO NOTHING
+		RETURNING version, created_at, updated_at`
 
 	getToolQuery = `SELECT * FROM neurondb_agent.tools WHERE name = $1`
 
 	listToolsQuery = `SELECT * FROM neurondb_agent.tools WHERE enabled = true ORDER BY name`
 
+	listAllToolsQuery = `SELECT * FROM neurondb_agent.tools ORDER BY name`
+
 	updateToolQuery = `
 		UPDATE neurondb_agent.tools 
 		SET description = $2, arg_schema = $3::jsonb, handler_type = $4, 
 			handler_config = $5::jsonb, enabled = $6
-		WHERE name = $1
-		RETURNING updated_at`
+		WHERE name = $1 AND version = $7
+		RETURNING version, updated_at`
+
+	getToolVersionQuery = `SELECT version FROM neurondb_agent.tools WHERE name = $1`
 
 	deleteToolQuery = `DELETE FROM neurondb_agent.tools WHERE name = $1`
 )
@@ -141,12 +503,34 @@ const (
 		WHERE id = $1
 		RETURNING updated_at`
 
+	// requeueJobQuery hands a running job back to the queue without
+	// counting a retry
+	requeueJobQuery = `
+		UPDATE neurondb_agent.jobs
+		SET status = 'queued', started_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`
+
+	updateJobResultQuery = `
+		UPDATE neurondb_agent.jobs
+		SET result = $2::jsonb, updated_at = NOW()
+		WHERE id = $1`
+
 	listJobsQuery = `
 		SELECT * FROM neurondb_agent.jobs 
 		WHERE ($1::uuid IS NULL OR agent_id = $1)
 		AND ($2::uuid IS NULL OR session_id = $2)
 		ORDER BY created_at DESC 
 		LIMIT $3 OFFSET $4`
+
+	// retryJobQuery queues a failed job again with a fresh set of retries
+	retryJobQuery = `
+		UPDATE neurondb_agent.jobs
+		SET status = 'queued', retry_count = 0, error_message = NULL,
+			started_at = NULL, completed_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'failed'
+		RETURNING *`
+
+	getJobStatusQuery = `SELECT status FROM neurondb_agent.jobs WHERE id = $1`
 )
 
 // API Key queries
@@ -172,6 +556,684 @@ const (
 		WHERE id = $1`
 
 	deleteAPIKeyQuery = `DELETE FROM neurondb_agent.api_keys WHERE id = $1`
+
+	upsertIdentityAPIKeyQuery = `
+		INSERT INTO neurondb_agent.api_keys AS k
+		(key_hash, key_prefix, organization_id, user_id, rate_limit_per_minute, roles, metadata, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, NOW())
+		ON CONFLICT (key_hash) DO UPDATE SET
+			organization_id = EXCLUDED.organization_id,
+			user_id = EXCLUDED.user_id,
+			rate_limit_per_minute = EXCLUDED.rate_limit_per_minute,
+			roles = EXCLUDED.roles,
+			metadata = k.metadata || EXCLUDED.metadata,
+			last_used_at = NOW()
+		RETURNING id, metadata, created_at, last_used_at, expires_at`
+)
+
+// Usage ledger queries
+const (
+	createUsageRecordQuery = `
+		INSERT INTO neurondb_agent.usage_ledger
+		(agent_id, session_id, message_id, api_key_id, model, provider, prompt_tokens, completion_tokens, total_tokens, cost_usd)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	// Usage since the start of the current month (UTC) for an agent or,
+	// with $1 NULL, for an API key
+	getMonthlyUsageQuery = `
+		SELECT COUNT(*) AS requests,
+			   COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+			   COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+			   COALESCE(SUM(total_tokens), 0) AS total_tokens,
+			   COALESCE(SUM(cost_usd), 0)::float8 AS cost_usd
+		FROM neurondb_agent.usage_ledger
+		WHERE ($1::uuid IS NULL OR agent_id = $1)
+		  AND ($2::uuid IS NULL OR api_key_id = $2)
+		  AND created_at >= date_trunc('month', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'`
+
+	getUsageReportQuery = `
+		SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS day,
+			   agent_id, api_key_id, model,
+			   COUNT(*) AS requests,
+			   SUM(prompt_tokens) AS prompt_tokens,
+			   SUM(completion_tokens) AS completion_tokens,
+			   SUM(total_tokens) AS total_tokens,
+			   SUM(cost_usd)::float8 AS cost_usd
+		FROM neurondb_agent.usage_ledger
+		WHERE ($1::uuid IS NULL OR agent_id = $1)
+		  AND ($2::uuid IS NULL OR api_key_id = $2)
+		  AND ($3::timestamptz IS NULL OR created_at >= $3)
+		  AND ($4::timestamptz IS NULL OR created_at < $4)
+		GROUP BY 1, agent_id, api_key_id, model
+		ORDER BY 1, agent_id, api_key_id, model`
+)
+
+// Rate limit and quota queries
+const (
+	takeRateLimitTokenQuery = `SELECT allowed, remaining FROM neurondb_agent.take_rate_limit_token($1, $2)`
+
+	// Locks the subject's row, starting it again from zero on a new day
+	lockQuotaUsageQuery = `
+		INSERT INTO neurondb_agent.quota_usage AS u (scope, subject, day)
+		VALUES ($1, $2, (NOW() AT TIME ZONE 'UTC')::date)
+		ON CONFLICT (scope, subject) DO UPDATE SET
+			requests = CASE WHEN u.day = EXCLUDED.day THEN u.requests ELSE 0 END,
+			tokens = CASE WHEN u.day = EXCLUDED.day THEN u.tokens ELSE 0 END,
+			day = EXCLUDED.day
+		RETURNING *`
+
+	countQuotaRequestQuery = `
+		UPDATE neurondb_agent.quota_usage SET requests = requests + 1
+		WHERE scope = $1 AND subject = $2`
+)
+
+// Message feedback queries
+const (
+	getMessageQuery = `SELECT * FROM neurondb_agent.messages WHERE id = $1`
+
+	getMessagesUpToQuery = `
+		SELECT * FROM neurondb_agent.messages
+		WHERE session_id = $1 AND id <= $2
+		ORDER BY created_at ASC, id ASC`
+
+	// Feedback takes the prompt version of the message it rates
+	upsertMessageFeedbackQuery = `
+		INSERT INTO neurondb_agent.message_feedback
+		(message_id, session_id, agent_id, api_key_id, rating, score, comment, metadata, prompt_version_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::jsonb,
+			(SELECT prompt_version_id FROM neurondb_agent.messages WHERE id = $1))
+		ON CONFLICT (message_id, COALESCE(api_key_id, '00000000-0000-0000-0000-000000000000'::uuid))
+		DO UPDATE SET rating = EXCLUDED.rating, score = EXCLUDED.score,
+			comment = EXCLUDED.comment, metadata = EXCLUDED.metadata
+		RETURNING id, prompt_version_id, created_at, updated_at`
+
+	listMessageFeedbackQuery = `
+		SELECT * FROM neurondb_agent.message_feedback
+		WHERE message_id = $1
+		ORDER BY created_at ASC, id ASC`
+
+	deleteMessageFeedbackQuery = `
+		DELETE FROM neurondb_agent.message_feedback
+		WHERE message_id = $1 AND api_key_id IS NOT DISTINCT FROM $2`
+
+	// feedbackFilterClause selects feedback by FeedbackFilter ($1-$10)
+	feedbackFilterClause = `
+		WHERE ($1::uuid IS NULL OR agent_id = $1)
+		  AND ($2::uuid IS NULL OR api_key_id = $2)
+		  AND ($3::uuid IS NULL OR session_id = $3)
+		  AND ($4::text IS NULL OR rating = $4)
+		  AND ($5::int IS NULL OR score >= $5)
+		  AND ($6::int IS NULL OR score <= $6)
+		  AND ($7::timestamptz IS NULL OR created_at >= $7)
+		  AND ($8::timestamptz IS NULL OR created_at < $8)
+		  AND ($9::uuid IS NULL OR prompt_version_id = $9)
+		  AND ($10::uuid IS NULL OR prompt_version_id IN (
+				SELECT id FROM neurondb_agent.prompt_template_versions WHERE template_id = $10))`
+
+	listFeedbackQuery = `
+		SELECT * FROM neurondb_agent.message_feedback` + feedbackFilterClause + `
+		ORDER BY created_at ASC, id ASC
+		LIMIT $11 OFFSET $12`
+
+	getFeedbackSummaryQuery = `
+		SELECT COUNT(*) AS count,
+			   COUNT(*) FILTER (WHERE rating = 'up') AS up,
+			   COUNT(*) FILTER (WHERE rating = 'down') AS down,
+			   COUNT(score) AS scored,
+			   AVG(score)::float8 AS avg_score,
+			   COUNT(*) FILTER (WHERE comment IS NOT NULL AND comment <> '') AS with_comment
+		FROM neurondb_agent.message_feedback` + feedbackFilterClause
+
+	// getPromptVersionMetricsQuery aggregates the feedback matching a filter
+	// per version of the template in $10. Served messages are counted by the
+	// agent and date filters only.
+	getPromptVersionMetricsQuery = `
+		WITH feedback AS (
+			SELECT * FROM neurondb_agent.message_feedback` + feedbackFilterClause + `
+		)
+		SELECT v.id AS prompt_version_id, v.version, v.rollout_weight,
+			   (SELECT COUNT(*) FROM neurondb_agent.messages m
+				WHERE m.prompt_version_id = v.id
+				  AND ($1::uuid IS NULL OR m.session_id IN (
+						SELECT id FROM neurondb_agent.sessions WHERE agent_id = $1))
+				  AND ($7::timestamptz IS NULL OR m.created_at >= $7)
+				  AND ($8::timestamptz IS NULL OR m.created_at < $8)) AS messages,
+			   COUNT(f.id) AS count,
+			   COUNT(*) FILTER (WHERE f.rating = 'up') AS up,
+			   COUNT(*) FILTER (WHERE f.rating = 'down') AS down,
+			   COUNT(f.score) AS scored,
+			   AVG(f.score)::float8 AS avg_score,
+			   COUNT(*) FILTER (WHERE f.comment IS NOT NULL AND f.comment <> '') AS with_comment,
+			   CASE WHEN COUNT(f.rating) > 0
+					THEN (COUNT(*) FILTER (WHERE f.rating = 'up'))::float8 / COUNT(f.rating)
+			   END AS up_rate
+		FROM neurondb_agent.prompt_template_versions v
+		LEFT JOIN feedback f ON f.prompt_version_id = v.id
+		WHERE v.template_id = $10
+		GROUP BY v.id, v.version, v.rollout_weight
+		ORDER BY v.version`
+)
+
+// Prompt template queries
+const (
+	createPromptTemplateQuery = `
+		INSERT INTO neurondb_agent.prompt_templates (name, description, organization_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT ((COALESCE(organization_id, '')), name) DO NOTHING
+		RETURNING *`
+
+	getPromptTemplateQuery = `
+		SELECT * FROM neurondb_agent.prompt_templates
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	listPromptTemplatesQuery = `
+		SELECT * FROM neurondb_agent.prompt_templates
+		WHERE (NOT $1::boolean OR organization_id IS NOT DISTINCT FROM $2::text)
+		ORDER BY name`
+
+	deletePromptTemplateQuery = `
+		DELETE FROM neurondb_agent.prompt_templates
+		WHERE id = $1 AND (NOT $2::boolean OR organization_id IS NOT DISTINCT FROM $3::text)`
+
+	// lockPromptTemplateQuery serializes changes to a template's versions
+	lockPromptTemplateQuery = `SELECT id FROM neurondb_agent.prompt_templates WHERE id = $1 FOR UPDATE`
+
+	createPromptTemplateVersionQuery = `
+		INSERT INTO neurondb_agent.prompt_template_versions
+		(template_id, version, content, variables, rollout_weight)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3::jsonb, $4
+		FROM neurondb_agent.prompt_template_versions
+		WHERE template_id = $1
+		RETURNING *`
+
+	getPromptTemplateVersionQuery = `SELECT * FROM neurondb_agent.prompt_template_versions WHERE id = $1`
+
+	listPromptTemplateVersionsQuery = `
+		SELECT * FROM neurondb_agent.prompt_template_versions
+		WHERE template_id = $1
+		ORDER BY version`
+
+	// setPromptTemplateRolloutQuery sets the weight of every version of a
+	// template from a JSON object of version number to weight; versions it
+	// does not name get no new sessions
+	setPromptTemplateRolloutQuery = `
+		UPDATE neurondb_agent.prompt_template_versions
+		SET rollout_weight = COALESCE(($2::jsonb ->> version::text)::int, 0)
+		WHERE template_id = $1
+		RETURNING *`
+
+	touchPromptTemplateQuery = `UPDATE neurondb_agent.prompt_templates SET updated_at = NOW() WHERE id = $1`
+)
+
+// Tool approval queries
+const (
+	// expireToolApprovalsQuery marks pending approvals past their expiry,
+	// failing the runs paused for them
+	expireToolApprovalsQuery = `
+		WITH expired AS (
+			UPDATE neurondb_agent.tool_approvals
+			SET status = 'expired'
+			WHERE status = 'pending' AND expires_at <= NOW()
+			RETURNING id
+		)
+		UPDATE neurondb_agent.agent_runs
+		SET status = 'failed', error_message = 'tool approval expired', updated_at = NOW(), completed_at = NOW()
+		WHERE approval_id IN (SELECT id FROM expired) AND status = 'awaiting_approval'`
+
+	createToolApprovalQuery = `
+		INSERT INTO neurondb_agent.tool_approvals
+		(session_id, agent_id, tool_calls, run_state, expires_at)
+		VALUES ($1, $2, $3::jsonb, $4::jsonb, $5)
+		ON CONFLICT (session_id) WHERE status = 'pending' DO NOTHING
+		RETURNING *`
+
+	getToolApprovalQuery = `SELECT * FROM neurondb_agent.tool_approvals WHERE id = $1`
+
+	// getOpenToolApprovalQuery finds a session's run that is waiting for a
+	// decision or has been decided but not yet resumed
+	getOpenToolApprovalQuery = `
+		SELECT * FROM neurondb_agent.tool_approvals
+		WHERE session_id = $1
+		  AND ((status = 'pending' AND expires_at > NOW()) OR status IN ('approved', 'rejected'))
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	listToolApprovalsQuery = `
+		SELECT * FROM neurondb_agent.tool_approvals
+		WHERE ($1::text IS NULL OR status = $1)
+		  AND ($2::uuid IS NULL OR agent_id = $2)
+		  AND ($3::uuid IS NULL OR session_id = $3)
+		ORDER BY created_at DESC, id
+		LIMIT $4 OFFSET $5`
+
+	decideToolApprovalQuery = `
+		UPDATE neurondb_agent.tool_approvals
+		SET status = $2, decided_by = $3, reason = $4, decided_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND expires_at > NOW()
+		RETURNING *`
+
+	finishToolApprovalQuery = `
+		UPDATE neurondb_agent.tool_approvals
+		SET status = $2, result = $3::jsonb, error_message = $4, completed_at = NOW()
+		WHERE id = $1 AND status IN ('approved', 'rejected')`
+)
+
+// Agent run queries
+const (
+	createRunQuery = `
+		INSERT INTO neurondb_agent.agent_runs (session_id, agent_id, step, checkpoint)
+		VALUES ($1, $2, $3, $4::jsonb)
+		RETURNING *`
+
+	getRunQuery = `SELECT * FROM neurondb_agent.agent_runs WHERE id = $1`
+
+	listSessionRunsQuery = `
+		SELECT * FROM neurondb_agent.agent_runs
+		WHERE session_id = $1
+		ORDER BY created_at, id
+		LIMIT $2 OFFSET $3`
+
+	// getRunMessagesQuery returns the messages runs stored, which carry
+	// the run's id in their metadata
+	getRunMessagesQuery = `
+		SELECT * FROM neurondb_agent.messages
+		WHERE session_id = $1 AND metadata->>'run_id' = ANY($2)
+		ORDER BY id`
+
+	// getActiveRunQuery finds a session's run that is still running,
+	// whether on a live server or interrupted
+	getActiveRunQuery = `
+		SELECT * FROM neurondb_agent.agent_runs
+		WHERE session_id = $1 AND status = 'running'
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	// The updates of a running run match its attempt, so a server whose
+	// run was claimed by recovery can no longer change it
+	checkpointRunQuery = `
+		UPDATE neurondb_agent.agent_runs
+		SET step = $3, checkpoint = $4::jsonb, heartbeat_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	heartbeatRunQuery = `
+		UPDATE neurondb_agent.agent_runs
+		SET heartbeat_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	// releaseRunQuery hands a running run back for recovery by another
+	// server, which claims it at once rather than after StaleAfter
+	releaseRunQuery = `
+		UPDATE neurondb_agent.agent_runs
+		SET heartbeat_at = 'epoch', updated_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	pauseRunQuery = `
+		UPDATE neurondb_agent.agent_runs
+		SET status = 'awaiting_approval', approval_id = $3, updated_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	finishRunQuery = `
+		UPDATE neurondb_agent.agent_runs
+		SET status = $3, result = $4::jsonb, error_message = $5, updated_at = NOW(), completed_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	finishApprovalRunQuery = `
+		UPDATE neurondb_agent.agent_runs
+		SET status = $2, result = $3::jsonb, error_message = $4, updated_at = NOW(), completed_at = NOW()
+		WHERE approval_id = $1 AND status = 'awaiting_approval'`
+
+	// claimInterruptedRunQuery takes over the running run with the oldest
+	// heartbeat before $1. The new heartbeat keeps other servers from
+	// claiming it too.
+	claimInterruptedRunQuery = `
+		UPDATE neurondb_agent.agent_runs
+		SET heartbeat_at = NOW(), attempts = attempts + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM neurondb_agent.agent_runs
+			WHERE status = 'running' AND heartbeat_at < $1
+			ORDER BY heartbeat_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`
+)
+
+// Webhook queries
+const (
+	createWebhookQuery = `
+		INSERT INTO neurondb_agent.webhooks (url, events, secret_env, description, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *`
+
+	getWebhookQuery = `SELECT * FROM neurondb_agent.webhooks WHERE id = $1`
+
+	listWebhooksQuery = `SELECT * FROM neurondb_agent.webhooks ORDER BY created_at`
+
+	// listWebhooksForEventQuery finds the enabled webhooks subscribed to an
+	// event type
+	listWebhooksForEventQuery = `
+		SELECT * FROM neurondb_agent.webhooks
+		WHERE enabled AND ($1 = ANY(events) OR '*' = ANY(events))
+		ORDER BY created_at`
+
+	updateWebhookQuery = `
+		UPDATE neurondb_agent.webhooks
+		SET url = $2, events = $3, secret_env = $4, description = $5, enabled = $6
+		WHERE id = $1
+		RETURNING *`
+
+	deleteWebhookQuery = `DELETE FROM neurondb_agent.webhooks WHERE id = $1`
+
+	createWebhookDeliveryQuery = `
+		INSERT INTO neurondb_agent.webhook_deliveries
+		(webhook_id, event_id, event, url, secret_env, payload)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb)
+		RETURNING *`
+
+	// claimWebhookDeliveriesQuery takes due deliveries and pushes their next
+	// attempt back by a lease ($2 seconds), so concurrent servers skip them
+	// while they are being sent
+	claimWebhookDeliveriesQuery = `
+		UPDATE neurondb_agent.webhook_deliveries
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM neurondb_agent.webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`
+
+	markWebhookDeliveredQuery = `
+		UPDATE neurondb_agent.webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $2,
+			last_error = NULL, delivered_at = NOW()
+		WHERE id = $1`
+
+	// failWebhookDeliveryQuery records a failed attempt, dead-lettering the
+	// delivery when $5 is true and otherwise retrying it at $4
+	failWebhookDeliveryQuery = `
+		UPDATE neurondb_agent.webhook_deliveries
+		SET attempts = attempts + 1, last_status_code = $2, last_error = $3,
+			next_attempt_at = $4,
+			status = CASE WHEN $5 THEN 'dead' ELSE 'pending' END
+		WHERE id = $1`
+
+	listWebhookDeliveriesQuery = `
+		SELECT * FROM neurondb_agent.webhook_deliveries
+		WHERE ($1::uuid IS NULL OR webhook_id = $1)
+		  AND ($2::text IS NULL OR status = $2)
+		  AND ($3::text IS NULL OR event = $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5`
+
+	// redeliverWebhookDeliveryQuery queues a dead-lettered delivery again
+	// with a fresh set of attempts
+	redeliverWebhookDeliveryQuery = `
+		UPDATE neurondb_agent.webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING *`
+
+	getWebhookDeliveryStatusQuery = `SELECT status FROM neurondb_agent.webhook_deliveries WHERE id = $1`
+)
+
+// Semantic cache queries
+const (
+	semanticCacheColumns = `id, agent_id, agent_version, model_name, prompt, response, tokens_used,
+		cost_usd, hit_count, last_hit_at, created_at, expires_at`
+
+	// lookupSemanticCacheQuery finds the live entry of an agent version and
+	// model closest to a prompt embedding
+	lookupSemanticCacheQuery = `
+		SELECT ` + semanticCacheColumns + `,
+			   1 - (prompt_embedding <=> $1::neurondb_vector) AS similarity
+		FROM neurondb_agent.semantic_cache
+		WHERE agent_id = $2 AND agent_version = $3 AND model_name = $4
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY prompt_embedding <=> $1::neurondb_vector
+		LIMIT 1`
+
+	recordSemanticCacheHitQuery = `
+		UPDATE neurondb_agent.semantic_cache
+		SET hit_count = hit_count + 1, last_hit_at = NOW()
+		WHERE id = $1`
+
+	createSemanticCacheEntryQuery = `
+		INSERT INTO neurondb_agent.semantic_cache
+		(agent_id, agent_version, model_name, prompt, prompt_embedding, response, tokens_used, cost_usd, expires_at)
+		VALUES ($1, $2, $3, $4, $5::neurondb_vector, $6, $7, $8, $9)
+		RETURNING ` + semanticCacheColumns
+
+	// purgeSemanticCacheQuery removes the entries of an agent that can no
+	// longer be served: expired ones and those of older agent versions
+	purgeSemanticCacheQuery = `
+		DELETE FROM neurondb_agent.semantic_cache
+		WHERE agent_id = $1 AND (agent_version < $2 OR expires_at <= NOW())`
+)
+
+// Session summary queries
+const (
+	getSessionSummaryQuery = `
+		SELECT * FROM neurondb_agent.session_summaries WHERE session_id = $1`
+
+	// Summaries cover messages up to an ID, so the queries below order
+	// messages by ID
+
+	// upsertSessionSummaryQuery only replaces a summary with one covering
+	// newer messages, so concurrent updates cannot move it back
+	upsertSessionSummaryQuery = `
+		INSERT INTO neurondb_agent.session_summaries (session_id, summary, through_message_id, message_count)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (session_id) DO UPDATE
+		SET summary = EXCLUDED.summary,
+			through_message_id = EXCLUDED.through_message_id,
+			message_count = EXCLUDED.message_count,
+			updated_at = NOW()
+		WHERE session_summaries.through_message_id < EXCLUDED.through_message_id
+		RETURNING created_at, updated_at`
+
+	getRecentMessagesAfterQuery = `
+		SELECT * FROM neurondb_agent.messages
+		WHERE session_id = $1 AND id > $2
+		ORDER BY id DESC
+		LIMIT $3`
+
+	countMessagesAfterQuery = `
+		SELECT count(*) FROM neurondb_agent.messages WHERE session_id = $1 AND id > $2`
+
+	getMessagesRangeQuery = `
+		SELECT * FROM neurondb_agent.messages
+		WHERE session_id = $1 AND id > $2 AND id <= $3
+		ORDER BY id`
+
+	getOldestMessagesAfterQuery = `
+		SELECT * FROM neurondb_agent.messages
+		WHERE session_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3`
+)
+
+// Conversation summary queries
+const (
+	getConversationSummaryQuery = `
+		SELECT * FROM neurondb_agent.conversation_summaries
+		WHERE session_id = $1 AND style = $2 AND length = $3`
+
+	// upsertConversationSummaryQuery does not replace a summary with one
+	// covering fewer messages, so a slower concurrent request cannot move
+	// it back. A summary covering the same messages replaces it, which lets
+	// a refresh rebuild it.
+	upsertConversationSummaryQuery = `
+		INSERT INTO neurondb_agent.conversation_summaries (session_id, style, length, summary, through_message_id, message_count)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (session_id, style, length) DO UPDATE
+		SET summary = EXCLUDED.summary,
+			through_message_id = EXCLUDED.through_message_id,
+			message_count = EXCLUDED.message_count,
+			updated_at = NOW()
+		WHERE conversation_summaries.through_message_id <= EXCLUDED.through_message_id
+		RETURNING created_at, updated_at`
+)
+
+// LLM log queries
+const (
+	createLLMLogQuery = `
+		INSERT INTO neurondb_agent.llm_logs (agent_id, session_id, run_id, purpose, model, provider, prompt, response,
+			error_message, prompt_tokens, completion_tokens, latency_ms, redactions, truncated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, created_at`
+
+	listLLMLogsQuery = `
+		SELECT * FROM neurondb_agent.llm_logs
+		WHERE agent_id = $1 AND ($2::uuid IS NULL OR session_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`
+)
+
+// Memory re-embedding queries
+const (
+	countAgentMemoryChunksQuery = `SELECT COUNT(*) FROM neurondb_agent.memory_chunks WHERE agent_id = $1`
+
+	listUnstagedMemoryChunksQuery = `
+		SELECT m.id, m.content FROM neurondb_agent.memory_chunks m
+		WHERE m.agent_id = $1 AND m.id > $3
+		  AND NOT EXISTS (
+			SELECT 1 FROM neurondb_agent.memory_reembeddings r
+			WHERE r.job_id = $2 AND r.chunk_id = m.id
+		  )
+		ORDER BY m.id
+		LIMIT $4`
+
+	stageMemoryReembeddingQuery = `
+		INSERT INTO neurondb_agent.memory_reembeddings (job_id, chunk_id, embedding)
+		VALUES ($1, $2, $3::neurondb_vector)
+		ON CONFLICT (job_id, chunk_id) DO UPDATE SET embedding = EXCLUDED.embedding`
+
+	lockAgentQuery = `SELECT id FROM neurondb_agent.agents WHERE id = $1 FOR UPDATE`
+
+	countUnstagedMemoryChunksQuery = `
+		SELECT COUNT(*) FROM neurondb_agent.memory_chunks m
+		WHERE m.agent_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM neurondb_agent.memory_reembeddings r
+			WHERE r.job_id = $2 AND r.chunk_id = m.id
+		  )`
+
+	applyMemoryReembeddingsQuery = `
+		UPDATE neurondb_agent.memory_chunks m
+		SET embedding = r.embedding
+		FROM neurondb_agent.memory_reembeddings r
+		WHERE r.job_id = $2 AND r.chunk_id = m.id AND m.agent_id = $1`
+
+	// setAgentMemoryEmbeddingModelQuery sets memory.embedding_model in the
+	// agent config, keeping the other memory settings
+	setAgentMemoryEmbeddingModelQuery = `
+		UPDATE neurondb_agent.agents
+		SET config = jsonb_set(COALESCE(config, '{}'::jsonb), '{memory}',
+			CASE WHEN jsonb_typeof(config->'memory') = 'object' THEN config->'memory' ELSE '{}'::jsonb END
+				|| jsonb_build_object('embedding_model', $2::text))
+		WHERE id = $1`
+
+	deleteMemoryReembeddingsQuery = `DELETE FROM neurondb_agent.memory_reembeddings WHERE job_id = $1`
+
+	hasActiveJobQuery = `
+		SELECT EXISTS (
+			SELECT 1 FROM neurondb_agent.jobs
+			WHERE agent_id = $1 AND type = $2 AND status IN ('queued', 'running')
+		)`
+)
+
+// Organization and user queries
+const (
+	createOrganizationQuery = `
+		INSERT INTO neurondb_agent.organizations (id, name, metadata)
+		VALUES ($1, $2, $3::jsonb)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING *`
+
+	getOrganizationQuery = `SELECT * FROM neurondb_agent.organizations WHERE id = $1`
+
+	listOrganizationsQuery = `SELECT * FROM neurondb_agent.organizations ORDER BY created_at, id`
+
+	updateOrganizationQuery = `
+		UPDATE neurondb_agent.organizations SET name = $2, metadata = $3::jsonb
+		WHERE id = $1
+		RETURNING *`
+
+	// deleteOrganizationQuery deletes an organization no agent or API key
+	// belongs to; its users are deleted with it
+	deleteOrganizationQuery = `
+		DELETE FROM neurondb_agent.organizations o
+		WHERE o.id = $1
+		  AND NOT EXISTS (SELECT 1 FROM neurondb_agent.agents a WHERE a.organization_id = o.id)
+		  AND NOT EXISTS (SELECT 1 FROM neurondb_agent.api_keys k WHERE k.organization_id = o.id)`
+
+	createUserQuery = `
+		INSERT INTO neurondb_agent.users (organization_id, email, name, roles)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id, lower(email)) DO NOTHING
+		RETURNING *`
+
+	getUserQuery = `SELECT * FROM neurondb_agent.users WHERE id = $1`
+
+	listUsersQuery = `
+		SELECT * FROM neurondb_agent.users
+		WHERE organization_id = $1
+		ORDER BY created_at, id`
+
+	updateUserQuery = `
+		UPDATE neurondb_agent.users SET name = $2, roles = $3, status = $4
+		WHERE id = $1
+		RETURNING *`
+
+	activateUserQuery = `
+		UPDATE neurondb_agent.users SET status = 'active'
+		WHERE id = $1 AND status = 'invited'`
+
+	deleteUserQuery = `DELETE FROM neurondb_agent.users WHERE id = $1`
+
+	// revokeUserAPIKeysQuery deletes the API keys of a user that keep none
+	// of the roles in $2
+	revokeUserAPIKeysQuery = `
+		DELETE FROM neurondb_agent.api_keys
+		WHERE user_id = $1::text AND NOT roles && $2::text[]`
+
+	// trimUserAPIKeyRolesQuery drops the roles not in $2 from the API keys
+	// of a user
+	trimUserAPIKeyRolesQuery = `
+		UPDATE neurondb_agent.api_keys
+		SET roles = ARRAY(SELECT r FROM unnest(roles) AS r WHERE r = ANY($2::text[]))
+		WHERE user_id = $1::text AND NOT roles <@ $2::text[]`
+)
+
+// Secret queries
+const (
+	createSecretQuery = `
+		INSERT INTO neurondb_agent.secrets (name, description, ciphertext, wrapped_key, key_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING *`
+
+	getSecretQuery = `SELECT * FROM neurondb_agent.secrets WHERE name = $1`
+
+	listSecretsQuery = `SELECT * FROM neurondb_agent.secrets ORDER BY name`
+
+	updateSecretQuery = `
+		UPDATE neurondb_agent.secrets
+		SET description = $2, ciphertext = $3, wrapped_key = $4, key_id = $5, version = version + 1
+		WHERE name = $1
+		RETURNING *`
+
+	// rewrapSecretQuery replaces the wrapped data key of a secret whose
+	// value has not changed since version $2
+	rewrapSecretQuery = `
+		UPDATE neurondb_agent.secrets SET wrapped_key = $3, key_id = $4
+		WHERE name = $1 AND version = $2`
+
+	deleteSecretQuery = `DELETE FROM neurondb_agent.secrets WHERE name = $1`
 )
 
 // NeuronDB function wrappers
@@ -180,8 +1242,17 @@ const (
 	llmGenerateQuery = `SELECT neurondb_llm_generate($1, $2, $3) AS output`
 )
 
+// queryRunner is the pool queries run on: the *sqlx.DB itself, or the
+// instrumented pool of a PoolMonitor
+type queryRunner interface {
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
 type Queries struct {
-	db       *sqlx.DB
+	db       queryRunner
 	connInfo func() string // Function to get connection info string
 }
 
@@ -199,6 +1270,12 @@ func (q *Queries) SetConnInfoFunc(fn func() string) {
 	q.connInfo = fn
 }
 
+// SetPoolMonitor runs queries through the instrumented pool of monitor, so
+// the time they wait for a connection is recorded
+func (q *Queries) SetPoolMonitor(monitor *PoolMonitor) {
+	q.db = &instrumentedPool{DB: monitor.db.DB, monitor: monitor}
+}
+
 // getConnInfoString returns connection info string
 func (q *Queries) getConnInfoString() string {
 	if q.connInfo != nil {
@@ -215,9 +1292,12 @@ func (q *Queries) formatQueryError(operation string, query string, paramCount in
 }
 
 // Agent methods
+// CreateAgent creates an agent in the organization ctx is scoped to, or
+// when it is not scoped in agent.OrganizationID
 func (q *Queries) CreateAgent(ctx context.Context, agent *Agent) error {
+	agent.OrganizationID = newAgentOrganization(ctx, agent)
 	params := []interface{}{agent.Name, agent.Description, agent.SystemPrompt, agent.ModelName,
-		agent.MemoryTable, agent.EnabledTools, agent.Config}
+		agent.MemoryTable, agent.EnabledTools, agent.Config, agent.OrganizationID, agent.PromptTemplateID, agent.OwnerUserID}
 	err := q.db.GetContext(ctx, agent, createAgentQuery, params...)
 	if err != nil {
 		return q.formatQueryError("INSERT", createAgentQuery, len(params), "neurondb_agent.agents", err)
@@ -227,30 +1307,63 @@ func (q *Queries) CreateAgent(ctx context.Context, agent *Agent) error {
 
 func (q *Queries) GetAgentByID(ctx context.Context, id uuid.UUID) (*Agent, error) {
 	var agent Agent
-	err := q.db.GetContext(ctx, &agent, getAgentByIDQuery, id)
+	scoped, org := organizationParams(ctx)
+	err := q.db.GetContext(ctx, &agent, getAgentByIDQuery, id, scoped, org)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("agent not found on %s: query='%s', agent_id='%s', table='neurondb_agent.agents', error=%w",
 			q.getConnInfoString(), getAgentByIDQuery, id.String(), err)
 	}
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", getAgentByIDQuery, 1, "neurondb_agent.agents", err)
+		return nil, q.formatQueryError("SELECT", getAgentByIDQuery, 3, "neurondb_agent.agents", err)
+	}
+	return &agent, nil
+}
+
+func (q *Queries) GetAgentByName(ctx context.Context, name string) (*Agent, error) {
+	var agent Agent
+	scoped, org := organizationParams(ctx)
+	err := q.db.GetContext(ctx, &agent, getAgentByNameQuery, name, scoped, org)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("agent not found on %s: query='%s', agent_name='%s', table='neurondb_agent.agents', error=%w",
+			q.getConnInfoString(), getAgentByNameQuery, name, err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getAgentByNameQuery, 3, "neurondb_agent.agents", err)
 	}
 	return &agent, nil
 }
 
 func (q *Queries) ListAgents(ctx context.Context) ([]Agent, error) {
 	var agents []Agent
-	err := q.db.SelectContext(ctx, &agents, listAgentsQuery)
+	scoped, org := organizationParams(ctx)
+	err := q.db.SelectContext(ctx, &agents, listAgentsQuery, scoped, org)
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", listAgentsQuery, 0, "neurondb_agent.agents", err)
+		return nil, q.formatQueryError("SELECT", listAgentsQuery, 2, "neurondb_agent.agents", err)
 	}
 	return agents, nil
 }
 
+// UpdateAgent updates an agent if its version is still agent.Version and
+// sets agent.Version to the new version. It returns an error wrapping
+// ErrVersionConflict if the agent has changed since, or sql.ErrNoRows if it
+// no longer exists.
 func (q *Queries) UpdateAgent(ctx context.Context, agent *Agent) error {
+	scoped, org := organizationParams(ctx)
 	params := []interface{}{agent.ID, agent.Name, agent.Description, agent.SystemPrompt, agent.ModelName,
-		agent.MemoryTable, agent.EnabledTools, agent.Config}
+		agent.MemoryTable, agent.EnabledTools, agent.Config, agent.Version, scoped, org, agent.PromptTemplateID}
 	err := q.db.GetContext(ctx, agent, updateAgentQuery, params...)
+	if err == sql.ErrNoRows {
+		var current int64
+		if err := q.db.GetContext(ctx, &current, getAgentVersionQuery, agent.ID, scoped, org); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("agent not found on %s: query='%s', agent_id='%s', table='neurondb_agent.agents', error=%w",
+					q.getConnInfoString(), updateAgentQuery, agent.ID.String(), err)
+			}
+			return q.formatQueryError("SELECT", getAgentVersionQuery, 3, "neurondb_agent.agents", err)
+		}
+		return fmt.Errorf("agent update rejected on %s: agent_id='%s', expected_version=%d, current_version=%d, table='neurondb_agent.agents': %w",
+			q.getConnInfoString(), agent.ID.String(), agent.Version, current, ErrVersionConflict)
+	}
 	if err != nil {
 		return q.formatQueryError("UPDATE", updateAgentQuery, len(params), "neurondb_agent.agents", err)
 	}
@@ -258,9 +1371,10 @@ func (q *Queries) UpdateAgent(ctx context.Context, agent *Agent) error {
 }
 
 func (q *Queries) DeleteAgent(ctx context.Context, id uuid.UUID) error {
-	result, err := q.db.ExecContext(ctx, deleteAgentQuery, id)
+	scoped, org := organizationParams(ctx)
+	result, err := q.db.ExecContext(ctx, deleteAgentQuery, id, scoped, org)
 	if err != nil {
-		return q.formatQueryError("DELETE", deleteAgentQuery, 1, "neurondb_agent.agents", err)
+		return q.formatQueryError("DELETE", deleteAgentQuery, 3, "neurondb_agent.agents", err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -286,20 +1400,22 @@ func (q *Queries) CreateSession(ctx context.Context, session *Session) error {
 
 func (q *Queries) GetSession(ctx context.Context, id uuid.UUID) (*Session, error) {
 	var session Session
-	err := q.db.GetContext(ctx, &session, getSessionQuery, id)
+	scoped, org := organizationParams(ctx)
+	err := q.db.GetContext(ctx, &session, getSessionQuery, id, scoped, org)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("session not found on %s: query='%s', session_id='%s', table='neurondb_agent.sessions', error=%w",
 			q.getConnInfoString(), getSessionQuery, id.String(), err)
 	}
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", getSessionQuery, 1, "neurondb_agent.sessions", err)
+		return nil, q.formatQueryError("SELECT", getSessionQuery, 3, "neurondb_agent.sessions", err)
 	}
 	return &session, nil
 }
 
 func (q *Queries) ListSessions(ctx context.Context, agentID uuid.UUID, limit, offset int) ([]Session, error) {
 	var sessions []Session
-	params := []interface{}{agentID, limit, offset}
+	scoped, org := organizationParams(ctx)
+	params := []interface{}{agentID, limit, offset, scoped, org}
 	err := q.db.SelectContext(ctx, &sessions, listSessionsQuery, params...)
 	if err != nil {
 		return nil, q.formatQueryError("SELECT", listSessionsQuery, len(params), "neurondb_agent.sessions", err)
@@ -308,9 +1424,10 @@ func (q *Queries) ListSessions(ctx context.Context, agentID uuid.UUID, limit, of
 }
 
 func (q *Queries) DeleteSession(ctx context.Context, id uuid.UUID) error {
-	result, err := q.db.ExecContext(ctx, deleteSessionQuery, id)
+	scoped, org := organizationParams(ctx)
+	result, err := q.db.ExecContext(ctx, deleteSessionQuery, id, scoped, org)
 	if err != nil {
-		return q.formatQueryError("DELETE", deleteSessionQuery, 1, "neurondb_agent.sessions", err)
+		return q.formatQueryError("DELETE", deleteSessionQuery, 3, "neurondb_agent.sessions", err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -324,272 +1441,2528 @@ func (q *Queries) DeleteSession(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// Message methods
-func (q *Queries) CreateMessage(ctx context.Context, message *Message) (*Message, error) {
-	params := []interface{}{message.SessionID, message.Role, message.Content, message.ToolName,
-		message.ToolCallID, message.TokenCount, message.Metadata}
-	err := q.db.GetContext(ctx, message, createMessageQuery, params...)
+// ListIdleSessions returns up to limit sessions of an agent with no activity
+// since cutoff, least recently active first
+func (q *Queries) ListIdleSessions(ctx context.Context, agentID uuid.UUID, cutoff time.Time, limit int) ([]Session, error) {
+	var sessions []Session
+	params := []interface{}{agentID, cutoff, limit}
+	err := q.db.SelectContext(ctx, &sessions, listIdleSessionsQuery, params...)
 	if err != nil {
-		return nil, q.formatQueryError("INSERT", createMessageQuery, len(params), "neurondb_agent.messages", err)
+		return nil, q.formatQueryError("SELECT", listIdleSessionsQuery, len(params), "neurondb_agent.sessions", err)
 	}
-	return message, nil
+	return sessions, nil
 }
 
-func (q *Queries) GetMessages(ctx context.Context, sessionID uuid.UUID, limit, offset int) ([]Message, error) {
-	var messages []Message
-	params := []interface{}{sessionID, limit, offset}
-	err := q.db.SelectContext(ctx, &messages, getMessagesQuery, params...)
+// ArchiveSession stores archive, the session's archive document, in
+// sessions_archive and deletes the live session with its messages in one
+// transaction. It returns an error wrapping ErrVersionConflict if the session
+// has had activity since it was read, and leaves it in place.
+func (q *Queries) ArchiveSession(ctx context.Context, session *Session, messageCount int, archive []byte) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", getMessagesQuery, len(params), "neurondb_agent.messages", err)
+		return fmt.Errorf("session archival failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
 	}
-	return messages, nil
-}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-func (q *Queries) GetRecentMessages(ctx context.Context, sessionID uuid.UUID, limit int) ([]Message, error) {
-	var messages []Message
-	params := []interface{}{sessionID, limit}
-	err := q.db.SelectContext(ctx, &messages, getRecentMessagesQuery, params...)
-	if err != nil {
-		return nil, q.formatQueryError("SELECT", getRecentMessagesQuery, len(params), "neurondb_agent.messages", err)
+	params := []interface{}{session.ID, session.AgentID, session.ExternalUserID, session.Metadata,
+		messageCount, session.CreatedAt, session.LastActivityAt, string(archive)}
+	if _, err = tx.ExecContext(ctx, insertSessionArchiveQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", insertSessionArchiveQuery, len(params), "neurondb_agent.sessions_archive", err)
 	}
-	return messages, nil
-}
 
-// Memory chunk methods
-func (q *Queries) CreateMemoryChunk(ctx context.Context, chunk *MemoryChunk) (*MemoryChunk, error) {
-	// Convert embedding to string format for neurondb_vector
-	embeddingStr := formatVector(chunk.Embedding)
-	params := []interface{}{chunk.AgentID, chunk.SessionID, chunk.MessageID, chunk.Content,
-		embeddingStr, chunk.ImportanceScore, chunk.Metadata}
-	err := q.db.GetContext(ctx, chunk, createMemoryChunkQuery, params...)
+	result, err := tx.ExecContext(ctx, deleteIdleSessionQuery, session.ID, session.LastActivityAt)
 	if err != nil {
-		embeddingDim := len(chunk.Embedding)
-		return nil, fmt.Errorf("memory chunk creation failed on %s: query='%s', params_count=%d, agent_id='%s', session_id='%s', content_length=%d, embedding_dimension=%d, importance_score=%.2f, table='neurondb_agent.memory_chunks', error=%w",
-			q.getConnInfoString(), createMemoryChunkQuery, len(params), chunk.AgentID.String(),
-			utils.SanitizeValue(chunk.SessionID), len(chunk.Content), embeddingDim, chunk.ImportanceScore, err)
+		return q.formatQueryError("DELETE", deleteIdleSessionQuery, 2, "neurondb_agent.sessions", err)
 	}
-	return chunk, nil
-}
-
-func (q *Queries) SearchMemory(ctx context.Context, agentID uuid.UUID, queryEmbedding []float32, topK int) ([]MemoryChunkWithSimilarity, error) {
-	embeddingStr := formatVector(queryEmbedding)
-	var chunks []MemoryChunkWithSimilarity
-	params := []interface{}{embeddingStr, agentID, topK}
-	err := q.db.SelectContext(ctx, &chunks, searchMemoryQuery, params...)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		embeddingDim := len(queryEmbedding)
-		return nil, fmt.Errorf("memory search failed on %s: query='%s', params_count=%d, agent_id='%s', query_embedding_dimension=%d, top_k=%d, table='neurondb_agent.memory_chunks', error=%w",
-			q.getConnInfoString(), searchMemoryQuery, len(params), agentID.String(), embeddingDim, topK, err)
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', session_id='%s', table='neurondb_agent.sessions', error=%w",
+			q.getConnInfoString(), deleteIdleSessionQuery, session.ID.String(), err)
+	}
+	if rowsAffected == 0 {
+		err = fmt.Errorf("session archival skipped on %s: session_id='%s' was deleted or has had activity since %s: %w",
+			q.getConnInfoString(), session.ID.String(), session.LastActivityAt.Format(time.RFC3339), ErrVersionConflict)
+		return err
 	}
-	return chunks, nil
-}
 
-// Tool methods
-func (q *Queries) CreateTool(ctx context.Context, tool *Tool) error {
-	params := []interface{}{tool.Name, tool.Description, tool.ArgSchema, tool.HandlerType,
-		tool.HandlerConfig, tool.Enabled}
-	err := q.db.GetContext(ctx, tool, createToolQuery, params...)
-	if err != nil {
-		return fmt.Errorf("tool creation failed on %s: query='%s', params_count=%d, tool_name='%s', handler_type='%s', enabled=%v, table='neurondb_agent.tools', error=%w",
-			q.getConnInfoString(), createToolQuery, len(params), tool.Name, tool.HandlerType, tool.Enabled, err)
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("session archival failed on %s: could not commit transaction: session_id='%s', error=%w",
+			q.getConnInfoString(), session.ID.String(), err)
 	}
 	return nil
 }
 
-func (q *Queries) GetTool(ctx context.Context, name string) (*Tool, error) {
-	var tool Tool
-	err := q.db.GetContext(ctx, &tool, getToolQuery, name)
+// GetArchivedSession returns an archived session, with ArchivedAt set
+func (q *Queries) GetArchivedSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	var session Session
+	scoped, org := organizationParams(ctx)
+	err := q.db.GetContext(ctx, &session, getArchivedSessionQuery, id, scoped, org)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("tool not found on %s: query='%s', tool_name='%s', table='neurondb_agent.tools', error=%w",
-			q.getConnInfoString(), getToolQuery, name, err)
+		return nil, fmt.Errorf("archived session not found on %s: query='%s', session_id='%s', table='neurondb_agent.sessions_archive', error=%w",
+			q.getConnInfoString(), getArchivedSessionQuery, id.String(), err)
 	}
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", getToolQuery, 1, "neurondb_agent.tools", err)
+		return nil, q.formatQueryError("SELECT", getArchivedSessionQuery, 3, "neurondb_agent.sessions_archive", err)
 	}
-	return &tool, nil
+	return &session, nil
 }
 
-func (q *Queries) ListTools(ctx context.Context) ([]Tool, error) {
-	var tools []Tool
-	err := q.db.SelectContext(ctx, &tools, listToolsQuery)
+// GetSessionArchive returns the archive document of an archived session
+func (q *Queries) GetSessionArchive(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	var archive []byte
+	scoped, org := organizationParams(ctx)
+	err := q.db.GetContext(ctx, &archive, getSessionArchiveQuery, id, scoped, org)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("archived session not found on %s: query='%s', session_id='%s', table='neurondb_agent.sessions_archive', error=%w",
+			q.getConnInfoString(), getSessionArchiveQuery, id.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getSessionArchiveQuery, 3, "neurondb_agent.sessions_archive", err)
+	}
+	return archive, nil
+}
+
+// ListArchivedSessions lists an agent's archived sessions, most recently
+// active first
+func (q *Queries) ListArchivedSessions(ctx context.Context, agentID uuid.UUID, limit, offset int) ([]Session, error) {
+	var sessions []Session
+	scoped, org := organizationParams(ctx)
+	params := []interface{}{agentID, limit, offset, scoped, org}
+	err := q.db.SelectContext(ctx, &sessions, listArchivedSessionsQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", listArchivedSessionsQuery, len(params), "neurondb_agent.sessions_archive", err)
+	}
+	return sessions, nil
+}
+
+// ListAllSessions lists an agent's live and archived sessions together, most
+// recently active first
+func (q *Queries) ListAllSessions(ctx context.Context, agentID uuid.UUID, limit, offset int) ([]Session, error) {
+	var sessions []Session
+	scoped, org := organizationParams(ctx)
+	params := []interface{}{agentID, limit, offset, scoped, org}
+	err := q.db.SelectContext(ctx, &sessions, listAllSessionsQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", listAllSessionsQuery, len(params), "neurondb_agent.sessions, neurondb_agent.sessions_archive", err)
+	}
+	return sessions, nil
+}
+
+// PurgeSessions hard-deletes an agent's sessions, live or archived, with no
+// activity since cutoff. It returns the number of live and archived sessions
+// deleted.
+func (q *Queries) PurgeSessions(ctx context.Context, agentID uuid.UUID, cutoff time.Time) (int64, int64, error) {
+	var live, archived int64
+	if err := q.db.GetContext(ctx, &live, purgeSessionsQuery, agentID, cutoff); err != nil {
+		return 0, 0, fmt.Errorf("session purge failed on %s: query='%s', agent_id='%s', cutoff='%s', table='neurondb_agent.sessions', error=%w",
+			q.getConnInfoString(), purgeSessionsQuery, agentID.String(), cutoff.Format(time.RFC3339), err)
+	}
+	if err := q.db.GetContext(ctx, &archived, purgeArchivedSessionsQuery, agentID, cutoff); err != nil {
+		return live, 0, fmt.Errorf("session purge failed on %s: query='%s', agent_id='%s', cutoff='%s', table='neurondb_agent.sessions_archive', error=%w",
+			q.getConnInfoString(), purgeArchivedSessionsQuery, agentID.String(), cutoff.Format(time.RFC3339), err)
+	}
+	return live, archived, nil
+}
+
+// DeleteArchivedSession hard-deletes an archived session. It returns an
+// error wrapping sql.ErrNoRows if there is none with this ID.
+func (q *Queries) DeleteArchivedSession(ctx context.Context, id uuid.UUID) error {
+	scoped, org := organizationParams(ctx)
+	result, err := q.db.ExecContext(ctx, deleteArchivedSessionQuery, id, scoped, org)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteArchivedSessionQuery, 3, "neurondb_agent.sessions_archive", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', session_id='%s', table='neurondb_agent.sessions_archive', error=%w",
+			q.getConnInfoString(), deleteArchivedSessionQuery, id.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("archived session not found on %s: query='%s', session_id='%s', table='neurondb_agent.sessions_archive': %w",
+			q.getConnInfoString(), deleteArchivedSessionQuery, id.String(), sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SessionImport describes a session, its messages and its memory chunks to
+// be inserted as a unit by ImportSession
+type SessionImport struct {
+	Agent        *Agent // target agent; created first when CreateAgent is set
+	CreateAgent  bool
+	Session      *Session
+	Messages     []Message
+	MemoryChunks []MemoryChunk
+
+	// Populated by ImportSession
+	MessageIDMap         map[int64]int64 // archived message ID -> new message ID
+	MemoryChunksImported int
+	MemoryChunksSkipped  int // chunks without an embedding
+}
+
+// ImportSession inserts a session with its full history in a single
+// transaction, preserving timestamps. Message and memory chunk IDs are newly
+// assigned; memory chunk message references are remapped accordingly.
+func (q *Queries) ImportSession(ctx context.Context, imp *SessionImport) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("session import failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if imp.CreateAgent {
+		agent := imp.Agent
+		agent.OrganizationID = newAgentOrganization(ctx, agent)
+		params := []interface{}{agent.Name, agent.Description, agent.SystemPrompt, agent.ModelName,
+			agent.MemoryTable, agent.EnabledTools, agent.Config, agent.OrganizationID, agent.PromptTemplateID, agent.OwnerUserID}
+		if err = tx.GetContext(ctx, agent, createAgentQuery, params...); err != nil {
+			return q.formatQueryError("INSERT", createAgentQuery, len(params), "neurondb_agent.agents", err)
+		}
+	}
+
+	session := imp.Session
+	session.AgentID = imp.Agent.ID
+	lastActivity := session.LastActivityAt
+	params := []interface{}{session.AgentID, session.ExternalUserID, session.Metadata, session.CreatedAt}
+	if err = tx.GetContext(ctx, session, importSessionQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", importSessionQuery, len(params), "neurondb_agent.sessions", err)
+	}
+
+	imp.MessageIDMap = make(map[int64]int64, len(imp.Messages))
+	for i := range imp.Messages {
+		message := &imp.Messages[i]
+		oldID := message.ID
+		message.SessionID = session.ID
+		params := []interface{}{message.SessionID, message.Role, message.Content, message.ToolName,
+			message.ToolCallID, message.TokenCount, message.Metadata, message.CreatedAt}
+		if err = tx.GetContext(ctx, message, importMessageQuery, params...); err != nil {
+			return q.formatQueryError("INSERT", importMessageQuery, len(params), "neurondb_agent.messages", err)
+		}
+		imp.MessageIDMap[oldID] = message.ID
+	}
+
+	for i := range imp.MemoryChunks {
+		chunk := &imp.MemoryChunks[i]
+		if len(chunk.Embedding) == 0 {
+			imp.MemoryChunksSkipped++
+			continue
+		}
+		chunk.AgentID = imp.Agent.ID
+		chunk.SessionID = &session.ID
+		if chunk.MessageID != nil {
+			if newID, ok := imp.MessageIDMap[*chunk.MessageID]; ok {
+				chunk.MessageID = &newID
+			} else {
+				chunk.MessageID = nil
+			}
+		}
+		params := []interface{}{chunk.AgentID, chunk.SessionID, chunk.MessageID, chunk.Content,
+			formatVector(chunk.Embedding), chunk.ImportanceScore, chunk.Metadata, chunk.CreatedAt}
+		if err = tx.GetContext(ctx, chunk, importMemoryChunkQuery, params...); err != nil {
+			return fmt.Errorf("memory chunk import failed on %s: query='%s', params_count=%d, agent_id='%s', session_id='%s', embedding_dimension=%d, table='neurondb_agent.memory_chunks', error=%w",
+				q.getConnInfoString(), importMemoryChunkQuery, len(params), chunk.AgentID.String(),
+				session.ID.String(), len(chunk.Embedding), err)
+		}
+		imp.MemoryChunksImported++
+	}
+
+	// Inserting messages bumps last_activity_at through a trigger; restore
+	// the archived value so the session keeps its original timeline
+	if !lastActivity.IsZero() {
+		if _, err = tx.ExecContext(ctx, setSessionActivityQuery, session.ID, lastActivity); err != nil {
+			return q.formatQueryError("UPDATE", setSessionActivityQuery, 2, "neurondb_agent.sessions", err)
+		}
+		session.LastActivityAt = lastActivity
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("session import failed on %s: could not commit transaction: session_id='%s', error=%w",
+			q.getConnInfoString(), session.ID.String(), err)
+	}
+	return nil
+}
+
+// Message methods
+func (q *Queries) CreateMessage(ctx context.Context, message *Message) (*Message, error) {
+	params := []interface{}{message.SessionID, message.Role, message.Content, message.ToolName,
+		message.ToolCallID, message.TokenCount, message.Metadata, message.PromptVersionID}
+	err := q.db.GetContext(ctx, message, createMessageQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("INSERT", createMessageQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return message, nil
+}
+
+// maxRowsPerInsert bounds the rows of one multi-row INSERT, keeping its
+// parameters well under PostgreSQL's limit of 65535
+const maxRowsPerInsert = 1000
+
+// insertedRow is a row returned by a multi-row INSERT
+type insertedRow struct {
+	ID        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// insertRows inserts n rows in tx with multi-row INSERTs of at most
+// maxRowsPerInsert rows. insert is the statement up to VALUES, and casts
+// holds the cast of each parameter of a row, which params returns for row i.
+// The statements return id and created_at, which are passed to set in the
+// order of the rows.
+func (q *Queries) insertRows(ctx context.Context, tx *sqlx.Tx, table, insert string, casts []string, n int,
+	params func(i int) []interface{}, set func(i int, row insertedRow)) error {
+	for start := 0; start < n; start += maxRowsPerInsert {
+		count := n - start
+		if count > maxRowsPerInsert {
+			count = maxRowsPerInsert
+		}
+		var b strings.Builder
+		b.WriteString(insert)
+		b.WriteString(" VALUES ")
+		args := make([]interface{}, 0, count*len(casts))
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(")
+			for j, cast := range casts {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				fmt.Fprintf(&b, "$%d%s", len(args)+j+1, cast)
+			}
+			b.WriteString(")")
+			args = append(args, params(start+i)...)
+		}
+		b.WriteString(" RETURNING id, created_at")
+		query := b.String()
+
+		var rows []insertedRow
+		if err := tx.SelectContext(ctx, &rows, query, args...); err != nil {
+			return q.formatQueryError("INSERT", query, len(args), table, err)
+		}
+		if len(rows) != count {
+			return fmt.Errorf("insert failed on %s: inserted %d of %d rows, table='%s'",
+				q.getConnInfoString(), len(rows), count, table)
+		}
+		// IDs are drawn in the order of the VALUES rows, which RETURNING
+		// does not promise to keep
+		sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+		for i, row := range rows {
+			set(start+i, row)
+		}
+	}
+	return nil
+}
+
+// CreateTurnMessages stores the messages of a conversation turn in order
+// with multi-row INSERTs in one transaction, so a turn is recorded whole or
+// not at all. The attachments are linked to the first message, the turn's
+// user message. The IDs and creation times of messages are set; the
+// messages share the creation time of the transaction, so their IDs order
+// them.
+func (q *Queries) CreateTurnMessages(ctx context.Context, messages []Message, attachmentIDs []uuid.UUID) (err error) {
+	if len(messages) == 0 {
+		return nil
+	}
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("message creation failed on %s: could not begin transaction: session_id='%s', message_count=%d, error=%w",
+			q.getConnInfoString(), messages[0].SessionID.String(), len(messages), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = q.insertRows(ctx, tx, "neurondb_agent.messages", createMessagesInsert,
+		[]string{"", "", "", "", "", "", "::jsonb", ""}, len(messages),
+		func(i int) []interface{} {
+			m := &messages[i]
+			return []interface{}{m.SessionID, m.Role, m.Content, m.ToolName, m.ToolCallID, m.TokenCount, m.Metadata, m.PromptVersionID}
+		},
+		func(i int, row insertedRow) {
+			messages[i].ID, messages[i].CreatedAt = row.ID, row.CreatedAt
+		})
+	if err != nil {
+		return err
+	}
+
+	if len(attachmentIDs) > 0 {
+		ids := make([]string, len(attachmentIDs))
+		for i, id := range attachmentIDs {
+			ids[i] = id.String()
+		}
+		if _, err = tx.ExecContext(ctx, linkMessageAttachmentsQuery, messages[0].ID, pq.Array(ids)); err != nil {
+			return q.formatQueryError("UPDATE", linkMessageAttachmentsQuery, 2, "neurondb_agent.message_attachments", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("message creation failed on %s: could not commit transaction: session_id='%s', message_count=%d, error=%w",
+			q.getConnInfoString(), messages[0].SessionID.String(), len(messages), err)
+	}
+	return nil
+}
+
+func (q *Queries) GetMessages(ctx context.Context, sessionID uuid.UUID, limit, offset int) ([]Message, error) {
+	var messages []Message
+	params := []interface{}{sessionID, limit, offset}
+	err := q.db.SelectContext(ctx, &messages, getMessagesQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getMessagesQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+func (q *Queries) GetRecentMessages(ctx context.Context, sessionID uuid.UUID, limit int) ([]Message, error) {
+	var messages []Message
+	params := []interface{}{sessionID, limit}
+	err := q.db.SelectContext(ctx, &messages, getRecentMessagesQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getRecentMessagesQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// GetAllMessages returns every message of a session in chronological order
+func (q *Queries) GetAllMessages(ctx context.Context, sessionID uuid.UUID) ([]Message, error) {
+	var messages []Message
+	err := q.db.SelectContext(ctx, &messages, getAllMessagesQuery, sessionID)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getAllMessagesQuery, 1, "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// Message search methods
+
+func messageSearchFilterParams(agentID uuid.UUID, filter MessageSearchFilter) []interface{} {
+	var roles interface{}
+	if len(filter.Roles) > 0 {
+		roles = pq.Array(filter.Roles)
+	}
+	return []interface{}{agentID, filter.SessionID, roles, filter.From, filter.To}
+}
+
+// SearchMessagesKeyword finds an agent's messages matching a web search
+// style query, such as `refund -shipping "order number"`, best matches
+// first
+func (q *Queries) SearchMessagesKeyword(ctx context.Context, agentID uuid.UUID, filter MessageSearchFilter, query string, limit int) ([]MessageSearchHit, error) {
+	hits := []MessageSearchHit{}
+	params := append(messageSearchFilterParams(agentID, filter), query, limit)
+	if err := q.db.SelectContext(ctx, &hits, searchMessagesKeywordQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", searchMessagesKeywordQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return hits, nil
+}
+
+// SearchMessagesSemantic finds the agent's messages embedded with model that
+// are closest to a query embedding. The highlights mark the words of query.
+func (q *Queries) SearchMessagesSemantic(ctx context.Context, agentID uuid.UUID, filter MessageSearchFilter, queryEmbedding []float32, model, query string, limit int) ([]MessageSearchHit, error) {
+	hits := []MessageSearchHit{}
+	params := append(messageSearchFilterParams(agentID, filter), formatVector(queryEmbedding), model, query, limit)
+	if err := q.db.SelectContext(ctx, &hits, searchMessagesSemanticQuery, params...); err != nil {
+		return nil, fmt.Errorf("message search failed on %s: query='%s', params_count=%d, agent_id='%s', model='%s', query_embedding_dimension=%d, table='neurondb_agent.message_embeddings', error=%w",
+			q.getConnInfoString(), searchMessagesSemanticQuery, len(params), agentID.String(), model, len(queryEmbedding), err)
+	}
+	return hits, nil
+}
+
+// ListUnembeddedMessages returns up to limit of the agent's messages matching
+// filter that have no embedding of model, newest first
+func (q *Queries) ListUnembeddedMessages(ctx context.Context, agentID uuid.UUID, filter MessageSearchFilter, model string, limit int) ([]UnembeddedMessage, error) {
+	messages := []UnembeddedMessage{}
+	params := append(messageSearchFilterParams(agentID, filter), model, limit)
+	if err := q.db.SelectContext(ctx, &messages, listUnembeddedMessagesQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listUnembeddedMessagesQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// CountUnembeddedMessages returns the number of the agent's messages matching
+// filter that have no embedding of model
+func (q *Queries) CountUnembeddedMessages(ctx context.Context, agentID uuid.UUID, filter MessageSearchFilter, model string) (int64, error) {
+	var count int64
+	params := append(messageSearchFilterParams(agentID, filter), model)
+	if err := q.db.GetContext(ctx, &count, countUnembeddedMessagesQuery, params...); err != nil {
+		return 0, q.formatQueryError("SELECT", countUnembeddedMessagesQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return count, nil
+}
+
+// CreateMessageEmbeddings stores the embeddings of messages for model.
+// Messages already embedded with model keep their embedding.
+func (q *Queries) CreateMessageEmbeddings(ctx context.Context, model string, ids []int64, embeddings [][]float32) error {
+	if len(ids) != len(embeddings) {
+		return fmt.Errorf("message embedding failed: %d messages but %d embeddings", len(ids), len(embeddings))
+	}
+	vectors := make([]string, len(embeddings))
+	for i, embedding := range embeddings {
+		vectors[i] = formatVector(embedding)
+	}
+	if _, err := q.db.ExecContext(ctx, createMessageEmbeddingsQuery, pq.Array(ids), model, pq.Array(vectors)); err != nil {
+		return fmt.Errorf("message embedding failed on %s: query='%s', model='%s', message_count=%d, table='neurondb_agent.message_embeddings', error=%w",
+			q.getConnInfoString(), createMessageEmbeddingsQuery, model, len(ids), err)
+	}
+	return nil
+}
+
+// Message attachment methods
+
+// CreateMessageAttachment records an attachment of a session. It is linked
+// to its message with LinkMessageAttachments once the message is stored.
+func (q *Queries) CreateMessageAttachment(ctx context.Context, attachment *MessageAttachment) error {
+	params := []interface{}{attachment.SessionID, attachment.Filename, attachment.MIMEType, attachment.SizeBytes,
+		attachment.SHA256, attachment.Source, attachment.SourceURL, attachment.Status, attachment.Error,
+		attachment.TextLength, attachment.ChunkCount, attachment.Summary}
+	if err := q.db.GetContext(ctx, attachment, createMessageAttachmentQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", createMessageAttachmentQuery, len(params), "neurondb_agent.message_attachments", err)
+	}
+	return nil
+}
+
+// LinkMessageAttachments sets the message of attachments
+func (q *Queries) LinkMessageAttachments(ctx context.Context, messageID int64, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = id.String()
+	}
+	if _, err := q.db.ExecContext(ctx, linkMessageAttachmentsQuery, messageID, pq.Array(idStrings)); err != nil {
+		return q.formatQueryError("UPDATE", linkMessageAttachmentsQuery, 2, "neurondb_agent.message_attachments", err)
+	}
+	return nil
+}
+
+// SetMessageAttachmentChunkCount records how many memory chunks hold the
+// text of an attachment
+func (q *Queries) SetMessageAttachmentChunkCount(ctx context.Context, id uuid.UUID, chunkCount int) error {
+	if _, err := q.db.ExecContext(ctx, setMessageAttachmentChunkCountQuery, id, chunkCount); err != nil {
+		return q.formatQueryError("UPDATE", setMessageAttachmentChunkCountQuery, 2, "neurondb_agent.message_attachments", err)
+	}
+	return nil
+}
+
+// ListMessageAttachments returns the attachments of a session, oldest first
+func (q *Queries) ListMessageAttachments(ctx context.Context, sessionID uuid.UUID) ([]MessageAttachment, error) {
+	attachments := []MessageAttachment{}
+	if err := q.db.SelectContext(ctx, &attachments, listMessageAttachmentsQuery, sessionID); err != nil {
+		return nil, q.formatQueryError("SELECT", listMessageAttachmentsQuery, 1, "neurondb_agent.message_attachments", err)
+	}
+	return attachments, nil
+}
+
+// Memory chunk methods
+func (q *Queries) CreateMemoryChunk(ctx context.Context, chunk *MemoryChunk) (*MemoryChunk, error) {
+	// Convert embedding to string format for neurondb_vector
+	embeddingStr := formatVector(chunk.Embedding)
+	params := []interface{}{chunk.AgentID, chunk.SessionID, chunk.MessageID, chunk.Content,
+		embeddingStr, chunk.ImportanceScore, chunk.Metadata}
+	err := q.db.GetContext(ctx, chunk, createMemoryChunkQuery, params...)
+	if err != nil {
+		embeddingDim := len(chunk.Embedding)
+		return nil, fmt.Errorf("memory chunk creation failed on %s: query='%s', params_count=%d, agent_id='%s', session_id='%s', content_length=%d, embedding_dimension=%d, importance_score=%.2f, table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), createMemoryChunkQuery, len(params), chunk.AgentID.String(),
+			utils.SanitizeValue(chunk.SessionID), len(chunk.Content), embeddingDim, chunk.ImportanceScore, err)
+	}
+	return chunk, nil
+}
+
+// CreateMemoryChunks stores chunks with multi-row INSERTs in one
+// transaction, so either all of them are stored or none. The IDs and
+// creation times of chunks are set.
+func (q *Queries) CreateMemoryChunks(ctx context.Context, chunks []MemoryChunk) (err error) {
+	if len(chunks) == 0 {
+		return nil
+	}
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("memory chunk creation failed on %s: could not begin transaction: agent_id='%s', chunk_count=%d, error=%w",
+			q.getConnInfoString(), chunks[0].AgentID.String(), len(chunks), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = q.insertRows(ctx, tx, "neurondb_agent.memory_chunks", createMemoryChunksInsert,
+		[]string{"", "", "", "", "::neurondb_vector", "", "::jsonb"}, len(chunks),
+		func(i int) []interface{} {
+			c := &chunks[i]
+			return []interface{}{c.AgentID, c.SessionID, c.MessageID, c.Content, formatVector(c.Embedding), c.ImportanceScore, c.Metadata}
+		},
+		func(i int, row insertedRow) {
+			chunks[i].ID, chunks[i].CreatedAt = row.ID, row.CreatedAt
+		})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("memory chunk creation failed on %s: could not commit transaction: agent_id='%s', chunk_count=%d, error=%w",
+			q.getConnInfoString(), chunks[0].AgentID.String(), len(chunks), err)
+	}
+	return nil
+}
+
+func (q *Queries) SearchMemory(ctx context.Context, agentID uuid.UUID, queryEmbedding []float32, topK int) ([]MemoryChunkWithSimilarity, error) {
+	embeddingStr := formatVector(queryEmbedding)
+	var chunks []MemoryChunkWithSimilarity
+	params := []interface{}{embeddingStr, agentID, topK}
+	err := q.db.SelectContext(ctx, &chunks, searchMemoryQuery, params...)
+	if err != nil {
+		embeddingDim := len(queryEmbedding)
+		return nil, fmt.Errorf("memory search failed on %s: query='%s', params_count=%d, agent_id='%s', query_embedding_dimension=%d, top_k=%d, table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), searchMemoryQuery, len(params), agentID.String(), embeddingDim, topK, err)
+	}
+	return chunks, nil
+}
+
+// GetMemoryUsage returns memory chunk statistics for an agent
+func (q *Queries) GetMemoryUsage(ctx context.Context, agentID uuid.UUID) (*MemoryUsage, error) {
+	var usage MemoryUsage
+	err := q.db.GetContext(ctx, &usage, getMemoryUsageQuery, agentID)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getMemoryUsageQuery, 1, "neurondb_agent.memory_chunks", err)
+	}
+	return &usage, nil
+}
+
+// EvictExpiredMemoryChunks removes an agent's memory chunks created before
+// cutoff. When minImportance is set, chunks at or above it are kept. With
+// archive set the evicted chunks are moved to memory_chunks_archive.
+func (q *Queries) EvictExpiredMemoryChunks(ctx context.Context, agentID uuid.UUID, cutoff time.Time, minImportance *float64, archive bool) (int64, error) {
+	query := deleteExpiredMemoryChunksQuery
+	if archive {
+		query = archiveExpiredMemoryChunksQuery
+	}
+	var evicted int64
+	err := q.db.GetContext(ctx, &evicted, query, agentID, cutoff, minImportance)
+	if err != nil {
+		threshold := "none"
+		if minImportance != nil {
+			threshold = fmt.Sprintf("%.2f", *minImportance)
+		}
+		return 0, fmt.Errorf("memory chunk eviction failed on %s: query='%s', agent_id='%s', cutoff='%s', min_importance=%s, archive=%t, table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), query, agentID.String(), cutoff.Format(time.RFC3339), threshold, archive, err)
+	}
+	return evicted, nil
+}
+
+// EvictExcessMemoryChunks keeps an agent's maxChunks most important memory
+// chunks (newest first among equals) and removes or archives the rest
+func (q *Queries) EvictExcessMemoryChunks(ctx context.Context, agentID uuid.UUID, maxChunks int, archive bool) (int64, error) {
+	query := deleteExcessMemoryChunksQuery
+	if archive {
+		query = archiveExcessMemoryChunksQuery
+	}
+	var evicted int64
+	err := q.db.GetContext(ctx, &evicted, query, agentID, maxChunks)
+	if err != nil {
+		return 0, fmt.Errorf("memory chunk eviction failed on %s: query='%s', agent_id='%s', max_chunks=%d, archive=%t, table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), query, agentID.String(), maxChunks, archive, err)
+	}
+	return evicted, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in a substring to match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func memoryChunkFilterParams(agentID uuid.UUID, filter MemoryChunkFilter) []interface{} {
+	var contains *string
+	if filter.Contains != nil {
+		escaped := likeEscaper.Replace(*filter.Contains)
+		contains = &escaped
+	}
+	return []interface{}{agentID, contains, filter.SessionID, filter.Pinned, filter.MinImportance, filter.MaxImportance}
+}
+
+// ListMemoryChunks returns an agent's memory chunks matching filter, newest
+// first, without their embeddings
+func (q *Queries) ListMemoryChunks(ctx context.Context, agentID uuid.UUID, filter MemoryChunkFilter, limit, offset int) ([]MemoryChunk, error) {
+	chunks := []MemoryChunk{}
+	params := append(memoryChunkFilterParams(agentID, filter), limit, offset)
+	if err := q.db.SelectContext(ctx, &chunks, listMemoryChunksQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listMemoryChunksQuery, len(params), "neurondb_agent.memory_chunks", err)
+	}
+	return chunks, nil
+}
+
+// CountMatchingMemoryChunks returns the number of an agent's memory chunks
+// matching filter
+func (q *Queries) CountMatchingMemoryChunks(ctx context.Context, agentID uuid.UUID, filter MemoryChunkFilter) (int64, error) {
+	var count int64
+	params := memoryChunkFilterParams(agentID, filter)
+	if err := q.db.GetContext(ctx, &count, countMatchingMemoryChunksQuery, params...); err != nil {
+		return 0, q.formatQueryError("SELECT", countMatchingMemoryChunksQuery, len(params), "neurondb_agent.memory_chunks", err)
+	}
+	return count, nil
+}
+
+// GetMemoryChunk returns one of an agent's memory chunks without its
+// embedding. It returns an error wrapping sql.ErrNoRows if the agent has no
+// chunk with this ID.
+func (q *Queries) GetMemoryChunk(ctx context.Context, agentID uuid.UUID, id int64) (*MemoryChunk, error) {
+	var chunk MemoryChunk
+	err := q.db.GetContext(ctx, &chunk, getMemoryChunkQuery, id, agentID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("memory chunk not found on %s: query='%s', chunk_id=%d, agent_id='%s', table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), getMemoryChunkQuery, id, agentID.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getMemoryChunkQuery, 2, "neurondb_agent.memory_chunks", err)
+	}
+	return &chunk, nil
+}
+
+// UpdateMemoryChunk sets the importance score and pin of one of an agent's
+// memory chunks; nil leaves a value unchanged. It returns the updated chunk,
+// or an error wrapping sql.ErrNoRows if the agent has no chunk with this ID.
+func (q *Queries) UpdateMemoryChunk(ctx context.Context, agentID uuid.UUID, id int64, importanceScore *float64, pinned *bool) (*MemoryChunk, error) {
+	var chunk MemoryChunk
+	err := q.db.GetContext(ctx, &chunk, updateMemoryChunkQuery, id, agentID, importanceScore, pinned)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("memory chunk not found on %s: query='%s', chunk_id=%d, agent_id='%s', table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), updateMemoryChunkQuery, id, agentID.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("UPDATE", updateMemoryChunkQuery, 4, "neurondb_agent.memory_chunks", err)
+	}
+	return &chunk, nil
+}
+
+// DeleteMemoryChunk deletes one of an agent's memory chunks. It returns an
+// error wrapping sql.ErrNoRows if the agent has no chunk with this ID.
+func (q *Queries) DeleteMemoryChunk(ctx context.Context, agentID uuid.UUID, id int64) error {
+	result, err := q.db.ExecContext(ctx, deleteMemoryChunkQuery, id, agentID)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteMemoryChunkQuery, 2, "neurondb_agent.memory_chunks", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', chunk_id=%d, agent_id='%s', table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), deleteMemoryChunkQuery, id, agentID.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("memory chunk not found on %s: query='%s', chunk_id=%d, agent_id='%s', table='neurondb_agent.memory_chunks', rows_affected=0: %w",
+			q.getConnInfoString(), deleteMemoryChunkQuery, id, agentID.String(), sql.ErrNoRows)
+	}
+	return nil
+}
+
+// memoryChunkRow scans a memory chunk whose embedding was selected as text
+type memoryChunkRow struct {
+	MemoryChunk
+	EmbeddingText *string `db:"embedding"`
+}
+
+// ListMemoryChunksBySession returns the memory chunks of a session, including embeddings
+func (q *Queries) ListMemoryChunksBySession(ctx context.Context, sessionID uuid.UUID) ([]MemoryChunk, error) {
+	var rows []memoryChunkRow
+	err := q.db.SelectContext(ctx, &rows, listMemoryChunksBySessionQuery, sessionID)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", listMemoryChunksBySessionQuery, 1, "neurondb_agent.memory_chunks", err)
+	}
+
+	chunks := make([]MemoryChunk, len(rows))
+	for i, row := range rows {
+		chunk := row.MemoryChunk
+		if row.EmbeddingText != nil {
+			embedding, err := parseVector(*row.EmbeddingText)
+			if err != nil {
+				return nil, fmt.Errorf("memory chunk embedding parsing failed on %s: chunk_id=%d, session_id='%s', table='neurondb_agent.memory_chunks', error=%w",
+					q.getConnInfoString(), chunk.ID, sessionID.String(), err)
+			}
+			chunk.Embedding = embedding
+		}
+		chunks[i] = chunk
+	}
+	return chunks, nil
+}
+
+// Tool methods
+
+// CreateTool inserts a tool. It returns an error wrapping ErrAlreadyExists if
+// a tool with the same name exists.
+func (q *Queries) CreateTool(ctx context.Context, tool *Tool) error {
+	params := []interface{}{tool.Name, tool.Description, tool.ArgSchema, tool.HandlerType,
+		tool.HandlerConfig, tool.Enabled}
+	err := q.db.GetContext(ctx, tool, createToolQuery, params...)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("tool creation rejected on %s: tool_name='%s', table='neurondb_agent.tools': %w",
+			q.getConnInfoString(), tool.Name, ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("tool creation failed on %s: query='%s', params_count=%d, tool_name='%s', handler_type='%s', enabled=%v, table='neurondb_agent.tools', error=%w",
+			q.getConnInfoString(), createToolQuery, len(params), tool.Name, tool.HandlerType, tool.Enabled, err)
+	}
+	return nil
+}
+
+func (q *Queries) GetTool(ctx context.Context, name string) (*Tool, error) {
+	var tool Tool
+	err := q.db.GetContext(ctx, &tool, getToolQuery, name)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("tool not found on %s: query='%s', tool_name='%s', table='neurondb_agent.tools', error=%w",
+			q.getConnInfoString(), getToolQuery, name, err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getToolQuery, 1, "neurondb_agent.tools", err)
+	}
+	return &tool, nil
+}
+
+func (q *Queries) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	err := q.db.SelectContext(ctx, &tools, listToolsQuery)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", listToolsQuery, 0, "neurondb_agent.tools", err)
+	}
+	return tools, nil
+}
+
+// ListAllTools returns every tool, including disabled ones
+func (q *Queries) ListAllTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	err := q.db.SelectContext(ctx, &tools, listAllToolsQuery)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", listAllToolsQuery, 0, "neurondb_agent.tools", err)
+	}
+	return tools, nil
+}
+
+// UpdateTool updates a tool if its version is still tool.Version and sets
+// tool.Version to the new version. It returns an error wrapping
+// ErrVersionConflict if the tool has changed since, or sql.ErrNoRows if it
+// no longer exists.
+func (q *Queries) UpdateTool(ctx context.Context, tool *Tool) error {
+	params := []interface{}{tool.Name, tool.Description, tool.ArgSchema, tool.HandlerType,
+		tool.HandlerConfig, tool.Enabled, tool.Version}
+	err := q.db.GetContext(ctx, tool, updateToolQuery, params...)
+	if err == sql.ErrNoRows {
+		var current int64
+		if err := q.db.GetContext(ctx, &current, getToolVersionQuery, tool.Name); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("tool not found on %s: query='%s', tool_name='%s', table='neurondb_agent.tools', error=%w",
+					q.getConnInfoString(), updateToolQuery, tool.Name, err)
+			}
+			return q.formatQueryError("SELECT", getToolVersionQuery, 1, "neurondb_agent.tools", err)
+		}
+		return fmt.Errorf("tool update rejected on %s: tool_name='%s', expected_version=%d, current_version=%d, table='neurondb_agent.tools': %w",
+			q.getConnInfoString(), tool.Name, tool.Version, current, ErrVersionConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("tool update failed on %s: query='%s', params_count=%d, tool_name='%s', handler_type='%s', enabled=%v, table='neurondb_agent.tools', error=%w",
+			q.getConnInfoString(), updateToolQuery, len(params), tool.Name, tool.HandlerType, tool.Enabled, err)
+	}
+	return nil
+}
+
+// DeleteTool deletes a tool. It returns an error wrapping sql.ErrNoRows if
+// the tool does not exist.
+func (q *Queries) DeleteTool(ctx context.Context, name string) error {
+	result, err := q.db.ExecContext(ctx, deleteToolQuery, name)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteToolQuery, 1, "neurondb_agent.tools", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', tool_name='%s', table='neurondb_agent.tools', error=%w",
+			q.getConnInfoString(), deleteToolQuery, name, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("tool not found on %s: query='%s', tool_name='%s', table='neurondb_agent.tools', rows_affected=0: %w",
+			q.getConnInfoString(), deleteToolQuery, name, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Job methods
+func (q *Queries) CreateJob(ctx context.Context, job *Job) (*Job, error) {
+	params := []interface{}{job.AgentID, job.SessionID, job.Type, job.Status, job.Priority,
+		job.Payload, job.MaxRetries}
+	err := q.db.GetContext(ctx, job, createJobQuery, params...)
+	if err != nil {
+		agentIDStr := utils.SanitizeValue(job.AgentID)
+		sessionIDStr := utils.SanitizeValue(job.SessionID)
+		return nil, fmt.Errorf("job creation failed on %s: query='%s', params_count=%d, job_type='%s', status='%s', priority=%d, agent_id=%s, session_id=%s, max_retries=%d, table='neurondb_agent.jobs', error=%w",
+			q.getConnInfoString(), createJobQuery, len(params), job.Type, job.Status, job.Priority,
+			agentIDStr, sessionIDStr, job.MaxRetries, err)
+	}
+	return job, nil
+}
+
+func (q *Queries) GetJob(ctx context.Context, id int64) (*Job, error) {
+	var job Job
+	err := q.db.GetContext(ctx, &job, getJobQuery, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job not found on %s: query='%s', job_id=%d, table='neurondb_agent.jobs', error=%w",
+			q.getConnInfoString(), getJobQuery, id, err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getJobQuery, 1, "neurondb_agent.jobs", err)
+	}
+	return &job, nil
+}
+
+func (q *Queries) ClaimJob(ctx context.Context) (*Job, error) {
+	var job Job
+	err := q.db.GetContext(ctx, &job, claimJobQuery)
+	if err == sql.ErrNoRows {
+		return nil, nil // No jobs available
+	}
+	if err != nil {
+		return nil, q.formatQueryError("UPDATE", claimJobQuery, 0, "neurondb_agent.jobs", err)
+	}
+	return &job, nil
+}
+
+func (q *Queries) UpdateJob(ctx context.Context, id int64, status string, result map[string]interface{}, errorMsg *string, retryCount int, completedAt *sql.NullTime) error {
+	var completedAtVal interface{}
+	if completedAt != nil && completedAt.Valid {
+		completedAtVal = completedAt.Time
+	} else {
+		completedAtVal = nil
+	}
+	params := []interface{}{id, status, JSONBMap(result), errorMsg, retryCount, completedAtVal}
+	_, err := q.db.ExecContext(ctx, updateJobQuery, params...)
+	if err != nil {
+		errorMsgStr := utils.SanitizeValue(errorMsg)
+		return fmt.Errorf("job update failed on %s: query='%s', params_count=%d, job_id=%d, status='%s', retry_count=%d, error_message=%s, table='neurondb_agent.jobs', error=%w",
+			q.getConnInfoString(), updateJobQuery, len(params), id, status, retryCount, errorMsgStr, err)
+	}
+	return nil
+}
+
+// RequeueJob hands a running job back to the queue for another worker,
+// without counting a retry. A job no longer running is left unchanged.
+func (q *Queries) RequeueJob(ctx context.Context, id int64) error {
+	if _, err := q.db.ExecContext(ctx, requeueJobQuery, id); err != nil {
+		return q.formatQueryError("UPDATE", requeueJobQuery, 1, "neurondb_agent.jobs", err)
+	}
+	return nil
+}
+
+// StoreBackfillBatch inserts a batch of memory chunks and records the job's
+// progress in the same transaction, so a retried job resumes exactly after
+// the last stored batch
+func (q *Queries) StoreBackfillBatch(ctx context.Context, jobID int64, chunks []MemoryChunk, progress map[string]interface{}) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("memory backfill batch failed on %s: could not begin transaction: job_id=%d, error=%w", q.getConnInfoString(), jobID, err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i := range chunks {
+		chunk := &chunks[i]
+		params := []interface{}{chunk.AgentID, chunk.SessionID, chunk.MessageID, chunk.Content,
+			formatVector(chunk.Embedding), chunk.ImportanceScore, chunk.Metadata}
+		if err = tx.GetContext(ctx, chunk, createMemoryChunkQuery, params...); err != nil {
+			return fmt.Errorf("memory backfill batch failed on %s: query='%s', job_id=%d, chunk_index=%d, agent_id='%s', content_length=%d, embedding_dimension=%d, table='neurondb_agent.memory_chunks', error=%w",
+				q.getConnInfoString(), createMemoryChunkQuery, jobID, i, chunk.AgentID.String(), len(chunk.Content), len(chunk.Embedding), err)
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, updateJobResultQuery, jobID, JSONBMap(progress)); err != nil {
+		return q.formatQueryError("UPDATE", updateJobResultQuery, 2, "neurondb_agent.jobs", err)
+	}
+	return tx.Commit()
+}
+
+// UpdateJobResult replaces a job's result without changing its status, for
+// reporting progress while the job runs
+func (q *Queries) UpdateJobResult(ctx context.Context, id int64, result map[string]interface{}) error {
+	if _, err := q.db.ExecContext(ctx, updateJobResultQuery, id, JSONBMap(result)); err != nil {
+		return fmt.Errorf("job result update failed on %s: query='%s', job_id=%d, table='neurondb_agent.jobs', error=%w",
+			q.getConnInfoString(), updateJobResultQuery, id, err)
+	}
+	return nil
+}
+
+func (q *Queries) ListJobs(ctx context.Context, agentID *uuid.UUID, sessionID *uuid.UUID, limit, offset int) ([]Job, error) {
+	var jobs []Job
+	params := []interface{}{agentID, sessionID, limit, offset}
+	err := q.db.SelectContext(ctx, &jobs, listJobsQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", listJobsQuery, len(params), "neurondb_agent.jobs", err)
+	}
+	return jobs, nil
+}
+
+// RetryJob queues a failed job again. It returns an error wrapping
+// ErrVersionConflict if the job has not failed, or sql.ErrNoRows if it does
+// not exist.
+func (q *Queries) RetryJob(ctx context.Context, id int64) (*Job, error) {
+	var job Job
+	err := q.db.GetContext(ctx, &job, retryJobQuery, id)
+	if err == sql.ErrNoRows {
+		var status string
+		if err := q.db.GetContext(ctx, &status, getJobStatusQuery, id); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("job not found on %s: query='%s', job_id=%d, table='neurondb_agent.jobs', error=%w",
+					q.getConnInfoString(), retryJobQuery, id, err)
+			}
+			return nil, q.formatQueryError("SELECT", getJobStatusQuery, 1, "neurondb_agent.jobs", err)
+		}
+		return nil, fmt.Errorf("job retry rejected on %s: job_id=%d, status='%s', table='neurondb_agent.jobs': %w",
+			q.getConnInfoString(), id, status, ErrVersionConflict)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("UPDATE", retryJobQuery, 1, "neurondb_agent.jobs", err)
+	}
+	return &job, nil
+}
+
+// API Key methods
+func (q *Queries) CreateAPIKey(ctx context.Context, apiKey *APIKey) error {
+	// Convert metadata to JSONB-compatible format using JSONBMap.Value()
+	metadataValue, err := apiKey.Metadata.Value()
+	if err != nil {
+		return fmt.Errorf("failed to convert metadata: %w", err)
+	}
+	
+	params := []interface{}{apiKey.KeyHash, apiKey.KeyPrefix, apiKey.OrganizationID, apiKey.UserID,
+		apiKey.RateLimitPerMin, apiKey.Roles, metadataValue, apiKey.ExpiresAt}
+	err = q.db.GetContext(ctx, apiKey, createAPIKeyQuery, params...)
+	if err != nil {
+		return fmt.Errorf("API key creation failed on %s: query='%s', params_count=%d, key_prefix='%s', organization_id=%s, user_id=%s, rate_limit_per_min=%d, table='neurondb_agent.api_keys', error=%w",
+			q.getConnInfoString(), createAPIKeyQuery, len(params), apiKey.KeyPrefix,
+			utils.SanitizeValue(apiKey.OrganizationID), utils.SanitizeValue(apiKey.UserID), apiKey.RateLimitPerMin, err)
+	}
+	return nil
+}
+
+// UpsertIdentityAPIKey creates or updates the API key record of an
+// identity authenticated by an external provider, keyed by its key hash.
+// The organization, user, rate limit and roles are replaced; metadata is
+// merged, so settings stored on the record, such as budgets, are kept.
+func (q *Queries) UpsertIdentityAPIKey(ctx context.Context, apiKey *APIKey) error {
+	metadataValue, err := apiKey.Metadata.Value()
+	if err != nil {
+		return fmt.Errorf("failed to convert metadata: %w", err)
+	}
+	params := []interface{}{apiKey.KeyHash, apiKey.KeyPrefix, apiKey.OrganizationID, apiKey.UserID,
+		apiKey.RateLimitPerMin, apiKey.Roles, metadataValue}
+	err = q.db.GetContext(ctx, apiKey, upsertIdentityAPIKeyQuery, params...)
+	if err != nil {
+		return fmt.Errorf("identity API key upsert failed on %s: query='%s', params_count=%d, key_prefix='%s', organization_id=%s, user_id=%s, table='neurondb_agent.api_keys', error=%w",
+			q.getConnInfoString(), upsertIdentityAPIKeyQuery, len(params), apiKey.KeyPrefix,
+			utils.SanitizeValue(apiKey.OrganizationID), utils.SanitizeValue(apiKey.UserID), err)
+	}
+	return nil
+}
+
+func (q *Queries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	var apiKey APIKey
+	err := q.db.GetContext(ctx, &apiKey, getAPIKeyByPrefixQuery, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API key not found on %s: query='%s', key_prefix='%s', table='neurondb_agent.api_keys', error=%w",
+				q.getConnInfoString(), getAPIKeyByPrefixQuery, prefix, err)
+		}
+		// Return detailed error for debugging
+		return nil, fmt.Errorf("API key lookup failed on %s: query='%s', key_prefix='%s', error=%w (error_type=%T)",
+			q.getConnInfoString(), getAPIKeyByPrefixQuery, prefix, err, err)
+	}
+	return &apiKey, nil
+}
+
+func (q *Queries) GetAPIKeyByID(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	var apiKey APIKey
+	err := q.db.GetContext(ctx, &apiKey, getAPIKeyByIDQuery, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key not found on %s: query='%s', key_id='%s', table='neurondb_agent.api_keys', error=%w",
+			q.getConnInfoString(), getAPIKeyByIDQuery, id.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getAPIKeyByIDQuery, 1, "neurondb_agent.api_keys", err)
+	}
+	return &apiKey, nil
+}
+
+func (q *Queries) ListAPIKeys(ctx context.Context, organizationID *string) ([]APIKey, error) {
+	var keys []APIKey
+	err := q.db.SelectContext(ctx, &keys, listAPIKeysQuery, organizationID)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", listAPIKeysQuery, 1, "neurondb_agent.api_keys", err)
+	}
+	return keys, nil
+}
+
+func (q *Queries) UpdateAPIKeyLastUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, updateAPIKeyLastUsedQuery, id)
+	if err != nil {
+		return q.formatQueryError("UPDATE", updateAPIKeyLastUsedQuery, 1, "neurondb_agent.api_keys", err)
+	}
+	return nil
+}
+
+func (q *Queries) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
+	result, err := q.db.ExecContext(ctx, deleteAPIKeyQuery, id)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteAPIKeyQuery, 1, "neurondb_agent.api_keys", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', key_id='%s', table='neurondb_agent.api_keys', error=%w",
+			q.getConnInfoString(), deleteAPIKeyQuery, id.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("API key not found on %s: query='%s', key_id='%s', table='neurondb_agent.api_keys', rows_affected=0: %w",
+			q.getConnInfoString(), deleteAPIKeyQuery, id.String(), sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Usage ledger methods
+
+// CreateUsageRecords appends LLM calls to the usage ledger in one transaction
+func (q *Queries) CreateUsageRecords(ctx context.Context, records []UsageRecord) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("usage ledger insert failed on %s: could not begin transaction: record_count=%d, error=%w", q.getConnInfoString(), len(records), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i := range records {
+		record := &records[i]
+		params := []interface{}{record.AgentID, record.SessionID, record.MessageID, record.APIKeyID, record.Model, record.Provider,
+			record.PromptTokens, record.CompletionTokens, record.TotalTokens, record.CostUSD}
+		if err = tx.GetContext(ctx, record, createUsageRecordQuery, params...); err != nil {
+			return fmt.Errorf("usage ledger insert failed on %s: query='%s', record_index=%d, agent_id='%s', model='%s', total_tokens=%d, table='neurondb_agent.usage_ledger', error=%w",
+				q.getConnInfoString(), createUsageRecordQuery, i, record.AgentID.String(), record.Model, record.TotalTokens, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetMonthlyUsage returns the usage of the current calendar month (UTC) for
+// an agent, an API key, or both when both are set
+func (q *Queries) GetMonthlyUsage(ctx context.Context, agentID, apiKeyID *uuid.UUID) (*UsageTotals, error) {
+	var totals UsageTotals
+	if err := q.db.GetContext(ctx, &totals, getMonthlyUsageQuery, agentID, apiKeyID); err != nil {
+		return nil, q.formatQueryError("SELECT", getMonthlyUsageQuery, 2, "neurondb_agent.usage_ledger", err)
+	}
+	return &totals, nil
+}
+
+// GetUsageReport aggregates the usage ledger per day, agent, API key and
+// model
+func (q *Queries) GetUsageReport(ctx context.Context, filter UsageFilter) ([]UsageReportRow, error) {
+	rows := []UsageReportRow{}
+	params := []interface{}{filter.AgentID, filter.APIKeyID, filter.From, filter.To}
+	if err := q.db.SelectContext(ctx, &rows, getUsageReportQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", getUsageReportQuery, len(params), "neurondb_agent.usage_ledger", err)
+	}
+	return rows, nil
+}
+
+// Rate limit and quota methods
+
+// TakeRateLimitToken takes a request from the rate limit bucket named
+// bucket, which holds up to perMinute requests and refills at perMinute a
+// minute. It reports whether a request was available and how many remain.
+// Every server sharing the database draws from the same bucket.
+func (q *Queries) TakeRateLimitToken(ctx context.Context, bucket string, perMinute int) (bool, float64, error) {
+	var result struct {
+		Allowed   bool    `db:"allowed"`
+		Remaining float64 `db:"remaining"`
+	}
+	if err := q.db.GetContext(ctx, &result, takeRateLimitTokenQuery, bucket, perMinute); err != nil {
+		return false, 0, q.formatQueryError("SELECT", takeRateLimitTokenQuery, 2, "neurondb_agent.rate_limit_buckets", err)
+	}
+	return result.Allowed, result.Remaining, nil
+}
+
+// CountQuotaRequest counts a request against the daily quotas of subjects
+// when admit accepts their usage so far today, and returns their usage with
+// the request counted if it was. The subjects stay locked until the request
+// is counted, so servers cannot both admit the last request of a quota.
+// Subjects are locked in order, so give them in the same order every time.
+func (q *Queries) CountQuotaRequest(ctx context.Context, subjects []QuotaSubject, admit func([]QuotaUsage) bool) (usage []QuotaUsage, admitted bool, err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("quota count failed on %s: could not begin transaction: subject_count=%d, error=%w", q.getConnInfoString(), len(subjects), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	usage = make([]QuotaUsage, len(subjects))
+	for i, subject := range subjects {
+		if err = tx.GetContext(ctx, &usage[i], lockQuotaUsageQuery, subject.Scope, subject.Subject); err != nil {
+			return nil, false, q.formatQueryError("INSERT", lockQuotaUsageQuery, 2, "neurondb_agent.quota_usage", err)
+		}
+	}
+	if admitted = admit(usage); admitted {
+		for i, subject := range subjects {
+			if _, err = tx.ExecContext(ctx, countQuotaRequestQuery, subject.Scope, subject.Subject); err != nil {
+				return nil, false, q.formatQueryError("UPDATE", countQuotaRequestQuery, 2, "neurondb_agent.quota_usage", err)
+			}
+			usage[i].Requests++
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("quota count failed on %s: could not commit: error=%w", q.getConnInfoString(), err)
+	}
+	return usage, admitted, nil
+}
+
+// Message feedback methods
+
+// GetMessage returns a message by ID
+func (q *Queries) GetMessage(ctx context.Context, id int64) (*Message, error) {
+	var message Message
+	if err := q.db.GetContext(ctx, &message, getMessageQuery, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("message not found on %s: query='%s', message_id=%d, table='neurondb_agent.messages', error=%w",
+				q.getConnInfoString(), getMessageQuery, id, err)
+		}
+		return nil, q.formatQueryError("SELECT", getMessageQuery, 1, "neurondb_agent.messages", err)
+	}
+	return &message, nil
+}
+
+// GetMessagesUpTo returns a session's messages up to and including messageID,
+// oldest first
+func (q *Queries) GetMessagesUpTo(ctx context.Context, sessionID uuid.UUID, messageID int64) ([]Message, error) {
+	var messages []Message
+	if err := q.db.SelectContext(ctx, &messages, getMessagesUpToQuery, sessionID, messageID); err != nil {
+		return nil, q.formatQueryError("SELECT", getMessagesUpToQuery, 2, "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// UpsertMessageFeedback stores feedback on a message, replacing earlier
+// feedback on it from the same API key
+func (q *Queries) UpsertMessageFeedback(ctx context.Context, feedback *MessageFeedback) error {
+	params := []interface{}{feedback.MessageID, feedback.SessionID, feedback.AgentID, feedback.APIKeyID,
+		feedback.Rating, feedback.Score, feedback.Comment, feedback.Metadata}
+	if err := q.db.GetContext(ctx, feedback, upsertMessageFeedbackQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", upsertMessageFeedbackQuery, len(params), "neurondb_agent.message_feedback", err)
+	}
+	return nil
+}
+
+// ListMessageFeedback returns all feedback on a message
+func (q *Queries) ListMessageFeedback(ctx context.Context, messageID int64) ([]MessageFeedback, error) {
+	feedback := []MessageFeedback{}
+	if err := q.db.SelectContext(ctx, &feedback, listMessageFeedbackQuery, messageID); err != nil {
+		return nil, q.formatQueryError("SELECT", listMessageFeedbackQuery, 1, "neurondb_agent.message_feedback", err)
+	}
+	return feedback, nil
+}
+
+// DeleteMessageFeedback removes the feedback an API key left on a message
+func (q *Queries) DeleteMessageFeedback(ctx context.Context, messageID int64, apiKeyID *uuid.UUID) error {
+	result, err := q.db.ExecContext(ctx, deleteMessageFeedbackQuery, messageID, apiKeyID)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteMessageFeedbackQuery, 2, "neurondb_agent.message_feedback", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', message_id=%d, table='neurondb_agent.message_feedback', error=%w",
+			q.getConnInfoString(), deleteMessageFeedbackQuery, messageID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("feedback not found on %s: query='%s', message_id=%d, table='neurondb_agent.message_feedback', rows_affected=0: %w",
+			q.getConnInfoString(), deleteMessageFeedbackQuery, messageID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func feedbackFilterParams(filter FeedbackFilter) []interface{} {
+	return []interface{}{filter.AgentID, filter.APIKeyID, filter.SessionID, filter.Rating,
+		filter.MinScore, filter.MaxScore, filter.From, filter.To, filter.PromptVersionID, filter.PromptTemplateID}
+}
+
+// ListFeedback returns feedback matching filter, oldest first
+func (q *Queries) ListFeedback(ctx context.Context, filter FeedbackFilter, limit, offset int) ([]MessageFeedback, error) {
+	feedback := []MessageFeedback{}
+	params := append(feedbackFilterParams(filter), limit, offset)
+	if err := q.db.SelectContext(ctx, &feedback, listFeedbackQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listFeedbackQuery, len(params), "neurondb_agent.message_feedback", err)
+	}
+	return feedback, nil
+}
+
+// GetFeedbackSummary aggregates the feedback matching filter
+func (q *Queries) GetFeedbackSummary(ctx context.Context, filter FeedbackFilter) (*FeedbackSummary, error) {
+	var summary FeedbackSummary
+	params := feedbackFilterParams(filter)
+	if err := q.db.GetContext(ctx, &summary, getFeedbackSummaryQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", getFeedbackSummaryQuery, len(params), "neurondb_agent.message_feedback", err)
+	}
+	return &summary, nil
+}
+
+// GetPromptVersionMetrics aggregates the feedback matching filter per
+// version of the prompt template filter.PromptTemplateID, oldest version first
+func (q *Queries) GetPromptVersionMetrics(ctx context.Context, filter FeedbackFilter) ([]PromptVersionMetrics, error) {
+	metrics := []PromptVersionMetrics{}
+	params := feedbackFilterParams(filter)
+	if err := q.db.SelectContext(ctx, &metrics, getPromptVersionMetricsQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", getPromptVersionMetricsQuery, len(params), "neurondb_agent.message_feedback", err)
+	}
+	return metrics, nil
+}
+
+// Prompt template methods
+
+// CreatePromptTemplate creates a prompt template in the organization ctx is
+// scoped to, or when it is not scoped in template.OrganizationID. It returns
+// an error wrapping ErrAlreadyExists if the organization has a template of
+// the same name.
+func (q *Queries) CreatePromptTemplate(ctx context.Context, template *PromptTemplate) error {
+	if id, scoped := OrganizationFromContext(ctx); scoped {
+		template.OrganizationID = id
+	}
+	params := []interface{}{template.Name, template.Description, template.OrganizationID}
+	err := q.db.GetContext(ctx, template, createPromptTemplateQuery, params...)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("prompt template creation rejected on %s: template_name='%s', table='neurondb_agent.prompt_templates': %w",
+			q.getConnInfoString(), template.Name, ErrAlreadyExists)
+	}
+	if err != nil {
+		return q.formatQueryError("INSERT", createPromptTemplateQuery, len(params), "neurondb_agent.prompt_templates", err)
+	}
+	return nil
+}
+
+// GetPromptTemplate returns a prompt template of the organization ctx is
+// scoped to
+func (q *Queries) GetPromptTemplate(ctx context.Context, id uuid.UUID) (*PromptTemplate, error) {
+	var template PromptTemplate
+	scoped, org := organizationParams(ctx)
+	err := q.db.GetContext(ctx, &template, getPromptTemplateQuery, id, scoped, org)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prompt template not found on %s: query='%s', template_id='%s', table='neurondb_agent.prompt_templates', error=%w",
+			q.getConnInfoString(), getPromptTemplateQuery, id.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getPromptTemplateQuery, 3, "neurondb_agent.prompt_templates", err)
+	}
+	return &template, nil
+}
+
+// ListPromptTemplates returns the prompt templates of the organization ctx
+// is scoped to, by name
+func (q *Queries) ListPromptTemplates(ctx context.Context) ([]PromptTemplate, error) {
+	templates := []PromptTemplate{}
+	scoped, org := organizationParams(ctx)
+	if err := q.db.SelectContext(ctx, &templates, listPromptTemplatesQuery, scoped, org); err != nil {
+		return nil, q.formatQueryError("SELECT", listPromptTemplatesQuery, 2, "neurondb_agent.prompt_templates", err)
+	}
+	return templates, nil
+}
+
+// DeletePromptTemplate removes a prompt template and its versions. Agents
+// using it go back to their system prompt.
+func (q *Queries) DeletePromptTemplate(ctx context.Context, id uuid.UUID) error {
+	scoped, org := organizationParams(ctx)
+	result, err := q.db.ExecContext(ctx, deletePromptTemplateQuery, id, scoped, org)
+	if err != nil {
+		return q.formatQueryError("DELETE", deletePromptTemplateQuery, 3, "neurondb_agent.prompt_templates", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', template_id='%s', table='neurondb_agent.prompt_templates', error=%w",
+			q.getConnInfoString(), deletePromptTemplateQuery, id.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("prompt template not found on %s: query='%s', template_id='%s', table='neurondb_agent.prompt_templates', rows_affected=0: %w",
+			q.getConnInfoString(), deletePromptTemplateQuery, id.String(), sql.ErrNoRows)
+	}
+	return nil
+}
+
+// CreatePromptTemplateVersion adds the next version of a prompt template
+func (q *Queries) CreatePromptTemplateVersion(ctx context.Context, version *PromptTemplateVersion) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("prompt template version creation failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, lockPromptTemplateQuery, version.TemplateID); err != nil {
+		return q.formatQueryError("SELECT", lockPromptTemplateQuery, 1, "neurondb_agent.prompt_templates", err)
+	}
+	params := []interface{}{version.TemplateID, version.Content, version.Variables, version.RolloutWeight}
+	if err = tx.GetContext(ctx, version, createPromptTemplateVersionQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", createPromptTemplateVersionQuery, len(params), "neurondb_agent.prompt_template_versions", err)
+	}
+	if _, err = tx.ExecContext(ctx, touchPromptTemplateQuery, version.TemplateID); err != nil {
+		return q.formatQueryError("UPDATE", touchPromptTemplateQuery, 1, "neurondb_agent.prompt_templates", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("prompt template version creation failed on %s: could not commit transaction: template_id='%s', error=%w",
+			q.getConnInfoString(), version.TemplateID.String(), err)
+	}
+	return nil
+}
+
+// GetPromptTemplateVersion returns a prompt template version
+func (q *Queries) GetPromptTemplateVersion(ctx context.Context, id uuid.UUID) (*PromptTemplateVersion, error) {
+	var version PromptTemplateVersion
+	err := q.db.GetContext(ctx, &version, getPromptTemplateVersionQuery, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prompt template version not found on %s: query='%s', version_id='%s', table='neurondb_agent.prompt_template_versions', error=%w",
+			q.getConnInfoString(), getPromptTemplateVersionQuery, id.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getPromptTemplateVersionQuery, 1, "neurondb_agent.prompt_template_versions", err)
+	}
+	return &version, nil
+}
+
+// ListPromptTemplateVersions returns the versions of a prompt template,
+// oldest first
+func (q *Queries) ListPromptTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]PromptTemplateVersion, error) {
+	versions := []PromptTemplateVersion{}
+	if err := q.db.SelectContext(ctx, &versions, listPromptTemplateVersionsQuery, templateID); err != nil {
+		return nil, q.formatQueryError("SELECT", listPromptTemplateVersionsQuery, 1, "neurondb_agent.prompt_template_versions", err)
+	}
+	return versions, nil
+}
+
+// SetPromptTemplateRollout sets the rollout weights of a prompt template's
+// versions from a map of version number to weight. Versions missing from
+// weights get a weight of 0. It fails, changing nothing, if weights names a
+// version the template does not have.
+func (q *Queries) SetPromptTemplateRollout(ctx context.Context, templateID uuid.UUID, weights map[int]int) (versions []PromptTemplateVersion, err error) {
+	byVersion := make(map[string]int, len(weights))
+	for version, weight := range weights {
+		byVersion[strconv.Itoa(version)] = weight
+	}
+	weightsJSON, err := json.Marshal(byVersion)
+	if err != nil {
+		return nil, fmt.Errorf("prompt template rollout failed: template_id='%s', could not encode weights: %w", templateID.String(), err)
+	}
+
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("prompt template rollout failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, lockPromptTemplateQuery, templateID); err != nil {
+		return nil, q.formatQueryError("SELECT", lockPromptTemplateQuery, 1, "neurondb_agent.prompt_templates", err)
+	}
+	if err = tx.SelectContext(ctx, &versions, setPromptTemplateRolloutQuery, templateID, string(weightsJSON)); err != nil {
+		return nil, q.formatQueryError("UPDATE", setPromptTemplateRolloutQuery, 2, "neurondb_agent.prompt_template_versions", err)
+	}
+	known := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		known[v.Version] = true
+	}
+	for version := range weights {
+		if !known[version] {
+			err = fmt.Errorf("prompt template rollout rejected on %s: template_id='%s' has no version %d, table='neurondb_agent.prompt_template_versions': %w",
+				q.getConnInfoString(), templateID.String(), version, sql.ErrNoRows)
+			return nil, err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, touchPromptTemplateQuery, templateID); err != nil {
+		return nil, q.formatQueryError("UPDATE", touchPromptTemplateQuery, 1, "neurondb_agent.prompt_templates", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("prompt template rollout failed on %s: could not commit transaction: template_id='%s', error=%w",
+			q.getConnInfoString(), templateID.String(), err)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// AssignSessionPromptVersion assigns a session a prompt template version
+// unless it already has a version of the same template, and returns the
+// version the session is served: the one assigned first when messages of
+// the session race
+func (q *Queries) AssignSessionPromptVersion(ctx context.Context, sessionID uuid.UUID, version *PromptTemplateVersion) (uuid.UUID, error) {
+	var assigned uuid.UUID
+	if err := q.db.GetContext(ctx, &assigned, assignSessionPromptVersionQuery, sessionID, version.ID, version.TemplateID); err != nil {
+		return uuid.Nil, q.formatQueryError("UPDATE", assignSessionPromptVersionQuery, 3, "neurondb_agent.sessions", err)
+	}
+	return assigned, nil
+}
+
+// Tool approval methods
+
+// CreateToolApproval pauses a run for approval. It returns an error wrapping
+// ErrAlreadyExists if the session already has a run waiting for a decision.
+func (q *Queries) CreateToolApproval(ctx context.Context, approval *ToolApproval) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("tool approval creation failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// An expired approval no longer blocks the session
+	if _, err = tx.ExecContext(ctx, expireToolApprovalsQuery); err != nil {
+		return q.formatQueryError("UPDATE", expireToolApprovalsQuery, 0, "neurondb_agent.tool_approvals", err)
+	}
+
+	params := []interface{}{approval.SessionID, approval.AgentID, string(approval.ToolCalls),
+		approval.RunState, approval.ExpiresAt}
+	err = tx.GetContext(ctx, approval, createToolApprovalQuery, params...)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("tool approval creation rejected on %s: session_id='%s' already has a pending approval, table='neurondb_agent.tool_approvals': %w",
+			q.getConnInfoString(), approval.SessionID.String(), ErrAlreadyExists)
+		return err
+	}
+	if err != nil {
+		return q.formatQueryError("INSERT", createToolApprovalQuery, len(params), "neurondb_agent.tool_approvals", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("tool approval creation failed on %s: could not commit transaction: session_id='%s', error=%w",
+			q.getConnInfoString(), approval.SessionID.String(), err)
+	}
+	return nil
+}
+
+// GetToolApproval returns a tool approval
+func (q *Queries) GetToolApproval(ctx context.Context, id uuid.UUID) (*ToolApproval, error) {
+	var approval ToolApproval
+	err := q.db.GetContext(ctx, &approval, getToolApprovalQuery, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("tool approval not found on %s: query='%s', approval_id='%s', table='neurondb_agent.tool_approvals', error=%w",
+			q.getConnInfoString(), getToolApprovalQuery, id.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getToolApprovalQuery, 1, "neurondb_agent.tool_approvals", err)
+	}
+	return &approval, nil
+}
+
+// GetOpenToolApproval returns the session's run that is waiting for a
+// decision or for its resumption, or an error wrapping sql.ErrNoRows if
+// there is none
+func (q *Queries) GetOpenToolApproval(ctx context.Context, sessionID uuid.UUID) (*ToolApproval, error) {
+	var approval ToolApproval
+	err := q.db.GetContext(ctx, &approval, getOpenToolApprovalQuery, sessionID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no open tool approval on %s: query='%s', session_id='%s', table='neurondb_agent.tool_approvals', error=%w",
+			q.getConnInfoString(), getOpenToolApprovalQuery, sessionID.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getOpenToolApprovalQuery, 1, "neurondb_agent.tool_approvals", err)
+	}
+	return &approval, nil
+}
+
+// ListToolApprovals returns tool approvals matching filter, newest first.
+// Pending approvals past their expiry are marked expired first.
+func (q *Queries) ListToolApprovals(ctx context.Context, filter ToolApprovalFilter, limit, offset int) ([]ToolApproval, error) {
+	if _, err := q.db.ExecContext(ctx, expireToolApprovalsQuery); err != nil {
+		return nil, q.formatQueryError("UPDATE", expireToolApprovalsQuery, 0, "neurondb_agent.tool_approvals", err)
+	}
+	approvals := []ToolApproval{}
+	params := []interface{}{filter.Status, filter.AgentID, filter.SessionID, limit, offset}
+	if err := q.db.SelectContext(ctx, &approvals, listToolApprovalsQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listToolApprovalsQuery, len(params), "neurondb_agent.tool_approvals", err)
+	}
+	return approvals, nil
+}
+
+// DecideToolApproval records the decision on a pending approval, which must
+// be approved or rejected, and in the same transaction queues resumeJob for
+// the approval's agent and session. It returns an error wrapping
+// ErrVersionConflict if the approval has already been decided or has
+// expired, or sql.ErrNoRows if it does not exist.
+func (q *Queries) DecideToolApproval(ctx context.Context, approval *ToolApproval, resumeJob *Job) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("tool approval decision failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	params := []interface{}{approval.ID, approval.Status, approval.DecidedBy, approval.Reason}
+	err = tx.GetContext(ctx, approval, decideToolApprovalQuery, params...)
+	if err == sql.ErrNoRows {
+		var current ToolApproval
+		if err = tx.GetContext(ctx, &current, getToolApprovalQuery, approval.ID); err != nil {
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("tool approval not found on %s: query='%s', approval_id='%s', table='neurondb_agent.tool_approvals', error=%w",
+					q.getConnInfoString(), decideToolApprovalQuery, approval.ID.String(), err)
+				return err
+			}
+			return q.formatQueryError("SELECT", getToolApprovalQuery, 1, "neurondb_agent.tool_approvals", err)
+		}
+		status := current.Status
+		if status == ToolApprovalPending {
+			status = ToolApprovalExpired
+		}
+		err = fmt.Errorf("tool approval decision rejected on %s: approval_id='%s', status='%s', table='neurondb_agent.tool_approvals': %w",
+			q.getConnInfoString(), approval.ID.String(), status, ErrVersionConflict)
+		return err
+	}
+	if err != nil {
+		return q.formatQueryError("UPDATE", decideToolApprovalQuery, len(params), "neurondb_agent.tool_approvals", err)
+	}
+
+	resumeJob.AgentID, resumeJob.SessionID = &approval.AgentID, &approval.SessionID
+	jobParams := []interface{}{resumeJob.AgentID, resumeJob.SessionID, resumeJob.Type, resumeJob.Status,
+		resumeJob.Priority, resumeJob.Payload, resumeJob.MaxRetries}
+	if err = tx.GetContext(ctx, resumeJob, createJobQuery, jobParams...); err != nil {
+		return q.formatQueryError("INSERT", createJobQuery, len(jobParams), "neurondb_agent.jobs", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("tool approval decision failed on %s: could not commit transaction: approval_id='%s', error=%w",
+			q.getConnInfoString(), approval.ID.String(), err)
+	}
+	return nil
+}
+
+// FinishToolApproval records the outcome of a resumed run: completed with
+// its result, or failed with errorMessage. It returns an error wrapping
+// ErrVersionConflict if the approval was not waiting for its resumption.
+func (q *Queries) FinishToolApproval(ctx context.Context, id uuid.UUID, status string, result map[string]interface{}, errorMessage *string) error {
+	params := []interface{}{id, status, FromMap(result), errorMessage}
+	res, err := q.db.ExecContext(ctx, finishToolApprovalQuery, params...)
+	if err != nil {
+		return q.formatQueryError("UPDATE", finishToolApprovalQuery, len(params), "neurondb_agent.tool_approvals", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for UPDATE on %s: query='%s', approval_id='%s', table='neurondb_agent.tool_approvals', error=%w",
+			q.getConnInfoString(), finishToolApprovalQuery, id.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("tool approval is not awaiting resumption on %s: approval_id='%s', table='neurondb_agent.tool_approvals': %w",
+			q.getConnInfoString(), id.String(), ErrVersionConflict)
+	}
+	return nil
+}
+
+// Agent run methods
+
+// CreateRun records a run starting at run.Step
+func (q *Queries) CreateRun(ctx context.Context, run *Run) error {
+	params := []interface{}{run.SessionID, run.AgentID, run.Step, run.Checkpoint}
+	if err := q.db.GetContext(ctx, run, createRunQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", createRunQuery, len(params), "neurondb_agent.agent_runs", err)
+	}
+	return nil
+}
+
+// GetRun returns a run
+func (q *Queries) GetRun(ctx context.Context, id uuid.UUID) (*Run, error) {
+	var run Run
+	err := q.db.GetContext(ctx, &run, getRunQuery, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("run not found on %s: query='%s', run_id='%s', table='neurondb_agent.agent_runs', error=%w",
+			q.getConnInfoString(), getRunQuery, id.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getRunQuery, 1, "neurondb_agent.agent_runs", err)
+	}
+	return &run, nil
+}
+
+// ListSessionRuns returns a page of a session's runs, oldest first
+func (q *Queries) ListSessionRuns(ctx context.Context, sessionID uuid.UUID, limit, offset int) ([]Run, error) {
+	var runs []Run
+	params := []interface{}{sessionID, limit, offset}
+	if err := q.db.SelectContext(ctx, &runs, listSessionRunsQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listSessionRunsQuery, len(params), "neurondb_agent.agent_runs", err)
+	}
+	return runs, nil
+}
+
+// GetRunMessages returns the messages of a session stored by the runs
+// runIDs, in the order they were stored
+func (q *Queries) GetRunMessages(ctx context.Context, sessionID uuid.UUID, runIDs []uuid.UUID) ([]Message, error) {
+	ids := make([]string, len(runIDs))
+	for i, id := range runIDs {
+		ids[i] = id.String()
+	}
+	var messages []Message
+	if err := q.db.SelectContext(ctx, &messages, getRunMessagesQuery, sessionID, pq.Array(ids)); err != nil {
+		return nil, q.formatQueryError("SELECT", getRunMessagesQuery, 2, "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// GetActiveRun returns the session's running run, or an error wrapping
+// sql.ErrNoRows if there is none
+func (q *Queries) GetActiveRun(ctx context.Context, sessionID uuid.UUID) (*Run, error) {
+	var run Run
+	err := q.db.GetContext(ctx, &run, getActiveRunQuery, sessionID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no running run on %s: query='%s', session_id='%s', table='neurondb_agent.agent_runs', error=%w",
+			q.getConnInfoString(), getActiveRunQuery, sessionID.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getActiveRunQuery, 1, "neurondb_agent.agent_runs", err)
+	}
+	return &run, nil
+}
+
+// CheckpointRun records that attempt of a running run completed step, with
+// the state it needs to continue. It returns an error wrapping
+// ErrVersionConflict if the run is no longer running or another attempt
+// took it over.
+func (q *Queries) CheckpointRun(ctx context.Context, id uuid.UUID, attempt int, step string, checkpoint map[string]interface{}) error {
+	params := []interface{}{id, attempt, step, FromMap(checkpoint)}
+	return q.updateRun(ctx, "checkpoint", checkpointRunQuery, id, params)
+}
+
+// HeartbeatRun marks attempt of a running run as alive
+func (q *Queries) HeartbeatRun(ctx context.Context, id uuid.UUID, attempt int) error {
+	return q.updateRun(ctx, "heartbeat", heartbeatRunQuery, id, []interface{}{id, attempt})
+}
+
+// ReleaseRun hands attempt of a running run back for recovery by marking
+// its heartbeat stale
+func (q *Queries) ReleaseRun(ctx context.Context, id uuid.UUID, attempt int) error {
+	return q.updateRun(ctx, "release", releaseRunQuery, id, []interface{}{id, attempt})
+}
+
+// PauseRun marks attempt of a running run as awaiting the tool approval
+// approvalID
+func (q *Queries) PauseRun(ctx context.Context, id uuid.UUID, attempt int, approvalID uuid.UUID) error {
+	return q.updateRun(ctx, "pause", pauseRunQuery, id, []interface{}{id, attempt, approvalID})
+}
+
+// FinishRun records the outcome of attempt of a running run: completed
+// with its result, or failed with errorMessage. It returns an error
+// wrapping ErrVersionConflict if the run already finished or another
+// attempt took it over.
+func (q *Queries) FinishRun(ctx context.Context, id uuid.UUID, attempt int, status string, result map[string]interface{}, errorMessage *string) error {
+	params := []interface{}{id, attempt, status, FromMap(result), errorMessage}
+	return q.updateRun(ctx, "finish", finishRunQuery, id, params)
+}
+
+// FinishApprovalRun records the outcome of the run awaiting the tool
+// approval approvalID. A run recorded before runs were tracked has none,
+// which is not an error.
+func (q *Queries) FinishApprovalRun(ctx context.Context, approvalID uuid.UUID, status string, result map[string]interface{}, errorMessage *string) error {
+	params := []interface{}{approvalID, status, FromMap(result), errorMessage}
+	if _, err := q.db.ExecContext(ctx, finishApprovalRunQuery, params...); err != nil {
+		return q.formatQueryError("UPDATE", finishApprovalRunQuery, len(params), "neurondb_agent.agent_runs", err)
+	}
+	return nil
+}
+
+// ClaimInterruptedRun takes over a running run whose heartbeat is older
+// than staleBefore, counting the attempt. It returns nil when there is none.
+func (q *Queries) ClaimInterruptedRun(ctx context.Context, staleBefore time.Time) (*Run, error) {
+	var run Run
+	err := q.db.GetContext(ctx, &run, claimInterruptedRunQuery, staleBefore)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, q.formatQueryError("UPDATE", claimInterruptedRunQuery, 1, "neurondb_agent.agent_runs", err)
+	}
+	return &run, nil
+}
+
+// updateRun runs an update of one run, failing with ErrVersionConflict
+// when the run is not in a state the update applies to
+func (q *Queries) updateRun(ctx context.Context, action, query string, id uuid.UUID, params []interface{}) error {
+	res, err := q.db.ExecContext(ctx, query, params...)
+	if err != nil {
+		return q.formatQueryError("UPDATE", query, len(params), "neurondb_agent.agent_runs", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for UPDATE on %s: query='%s', run_id='%s', table='neurondb_agent.agent_runs', error=%w",
+			q.getConnInfoString(), query, id.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("run %s rejected on %s: run_id='%s' has finished, was taken over or does not exist, table='neurondb_agent.agent_runs': %w",
+			action, q.getConnInfoString(), id.String(), ErrVersionConflict)
+	}
+	return nil
+}
+
+// Webhook methods
+
+func (q *Queries) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	params := []interface{}{webhook.URL, webhook.Events, webhook.SecretEnv, webhook.Description, webhook.Enabled}
+	if err := q.db.GetContext(ctx, webhook, createWebhookQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", createWebhookQuery, len(params), "neurondb_agent.webhooks", err)
+	}
+	return nil
+}
+
+func (q *Queries) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	var webhook Webhook
+	err := q.db.GetContext(ctx, &webhook, getWebhookQuery, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook not found on %s: query='%s', webhook_id='%s', table='neurondb_agent.webhooks', error=%w",
+			q.getConnInfoString(), getWebhookQuery, id.String(), err)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getWebhookQuery, 1, "neurondb_agent.webhooks", err)
+	}
+	return &webhook, nil
+}
+
+func (q *Queries) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	if err := q.db.SelectContext(ctx, &webhooks, listWebhooksQuery); err != nil {
+		return nil, q.formatQueryError("SELECT", listWebhooksQuery, 0, "neurondb_agent.webhooks", err)
+	}
+	return webhooks, nil
+}
+
+// ListWebhooksForEvent returns the enabled webhooks subscribed to event
+func (q *Queries) ListWebhooksForEvent(ctx context.Context, event string) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	if err := q.db.SelectContext(ctx, &webhooks, listWebhooksForEventQuery, event); err != nil {
+		return nil, q.formatQueryError("SELECT", listWebhooksForEventQuery, 1, "neurondb_agent.webhooks", err)
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhook replaces a webhook's settings. It returns an error wrapping
+// sql.ErrNoRows if the webhook does not exist.
+func (q *Queries) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	params := []interface{}{webhook.ID, webhook.URL, webhook.Events, webhook.SecretEnv, webhook.Description, webhook.Enabled}
+	err := q.db.GetContext(ctx, webhook, updateWebhookQuery, params...)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("webhook not found on %s: query='%s', webhook_id='%s', table='neurondb_agent.webhooks', error=%w",
+			q.getConnInfoString(), updateWebhookQuery, webhook.ID.String(), err)
+	}
+	if err != nil {
+		return q.formatQueryError("UPDATE", updateWebhookQuery, len(params), "neurondb_agent.webhooks", err)
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook with its deliveries. It returns an error
+// wrapping sql.ErrNoRows if the webhook does not exist.
+func (q *Queries) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	result, err := q.db.ExecContext(ctx, deleteWebhookQuery, id)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteWebhookQuery, 1, "neurondb_agent.webhooks", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', webhook_id='%s', table='neurondb_agent.webhooks', error=%w",
+			q.getConnInfoString(), deleteWebhookQuery, id.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook not found on %s: query='%s', webhook_id='%s', table='neurondb_agent.webhooks', rows_affected=0: %w",
+			q.getConnInfoString(), deleteWebhookQuery, id.String(), sql.ErrNoRows)
+	}
+	return nil
+}
+
+// CreateWebhookDelivery queues an event for an endpoint
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	params := []interface{}{delivery.WebhookID, delivery.EventID, delivery.Event, delivery.URL,
+		delivery.SecretEnv, string(delivery.Payload)}
+	if err := q.db.GetContext(ctx, delivery, createWebhookDeliveryQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", createWebhookDeliveryQuery, len(params), "neurondb_agent.webhook_deliveries", err)
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit due deliveries and leases them
+// for lease, during which no other caller claims them
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	params := []interface{}{limit, lease.Seconds()}
+	if err := q.db.SelectContext(ctx, &deliveries, claimWebhookDeliveriesQuery, params...); err != nil {
+		return nil, q.formatQueryError("UPDATE", claimWebhookDeliveriesQuery, len(params), "neurondb_agent.webhook_deliveries", err)
+	}
+	return deliveries, nil
+}
+
+// MarkWebhookDelivered records a successful attempt
+func (q *Queries) MarkWebhookDelivered(ctx context.Context, id int64, statusCode int) error {
+	if _, err := q.db.ExecContext(ctx, markWebhookDeliveredQuery, id, statusCode); err != nil {
+		return q.formatQueryError("UPDATE", markWebhookDeliveredQuery, 2, "neurondb_agent.webhook_deliveries", err)
+	}
+	return nil
+}
+
+// FailWebhookDelivery records a failed attempt. The delivery is retried at
+// nextAttempt, or dead-lettered when dead is true. statusCode is nil when no
+// response was received.
+func (q *Queries) FailWebhookDelivery(ctx context.Context, id int64, statusCode *int, errorMessage string, nextAttempt time.Time, dead bool) error {
+	params := []interface{}{id, statusCode, errorMessage, nextAttempt, dead}
+	if _, err := q.db.ExecContext(ctx, failWebhookDeliveryQuery, params...); err != nil {
+		return q.formatQueryError("UPDATE", failWebhookDeliveryQuery, len(params), "neurondb_agent.webhook_deliveries", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns deliveries matching filter, newest first
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, filter WebhookDeliveryFilter, limit, offset int) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	params := []interface{}{filter.WebhookID, filter.Status, filter.Event, limit, offset}
+	if err := q.db.SelectContext(ctx, &deliveries, listWebhookDeliveriesQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listWebhookDeliveriesQuery, len(params), "neurondb_agent.webhook_deliveries", err)
+	}
+	return deliveries, nil
+}
+
+// RedeliverWebhookDelivery queues a dead-lettered delivery again. It returns
+// an error wrapping ErrVersionConflict if the delivery is not dead, or
+// sql.ErrNoRows if it does not exist.
+func (q *Queries) RedeliverWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := q.db.GetContext(ctx, &delivery, redeliverWebhookDeliveryQuery, id)
+	if err == sql.ErrNoRows {
+		var status string
+		if err := q.db.GetContext(ctx, &status, getWebhookDeliveryStatusQuery, id); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("webhook delivery not found on %s: query='%s', delivery_id=%d, table='neurondb_agent.webhook_deliveries', error=%w",
+					q.getConnInfoString(), redeliverWebhookDeliveryQuery, id, err)
+			}
+			return nil, q.formatQueryError("SELECT", getWebhookDeliveryStatusQuery, 1, "neurondb_agent.webhook_deliveries", err)
+		}
+		return nil, fmt.Errorf("webhook redelivery rejected on %s: delivery_id=%d, status='%s', table='neurondb_agent.webhook_deliveries': %w",
+			q.getConnInfoString(), id, status, ErrVersionConflict)
+	}
+	if err != nil {
+		return nil, q.formatQueryError("UPDATE", redeliverWebhookDeliveryQuery, 1, "neurondb_agent.webhook_deliveries", err)
+	}
+	return &delivery, nil
+}
+
+// Semantic cache methods
+
+// LookupSemanticCache returns the live cache entry of an agent version and
+// model closest to embedding. It returns an error wrapping sql.ErrNoRows
+// when the agent has no such entry.
+func (q *Queries) LookupSemanticCache(ctx context.Context, agentID uuid.UUID, agentVersion int64, modelName string, embedding []float32) (*SemanticCacheMatch, error) {
+	var match SemanticCacheMatch
+	params := []interface{}{formatVector(embedding), agentID, agentVersion, modelName}
+	err := q.db.GetContext(ctx, &match, lookupSemanticCacheQuery, params...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("semantic cache entry not found on %s: query='%s', agent_id='%s', agent_version=%d, model_name='%s', table='neurondb_agent.semantic_cache', error=%w",
+			q.getConnInfoString(), lookupSemanticCacheQuery, agentID.String(), agentVersion, modelName, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("semantic cache lookup failed on %s: query='%s', params_count=%d, agent_id='%s', query_embedding_dimension=%d, table='neurondb_agent.semantic_cache', error=%w",
+			q.getConnInfoString(), lookupSemanticCacheQuery, len(params), agentID.String(), len(embedding), err)
+	}
+	return &match, nil
+}
+
+// RecordSemanticCacheHit counts a served hit of a cache entry
+func (q *Queries) RecordSemanticCacheHit(ctx context.Context, id int64) error {
+	if _, err := q.db.ExecContext(ctx, recordSemanticCacheHitQuery, id); err != nil {
+		return q.formatQueryError("UPDATE", recordSemanticCacheHitQuery, 1, "neurondb_agent.semantic_cache", err)
+	}
+	return nil
+}
+
+// CreateSemanticCacheEntry stores an answer in the semantic cache
+func (q *Queries) CreateSemanticCacheEntry(ctx context.Context, entry *SemanticCacheEntry) error {
+	params := []interface{}{entry.AgentID, entry.AgentVersion, entry.ModelName, entry.Prompt,
+		formatVector(entry.PromptEmbedding), entry.Response, entry.TokensUsed, entry.CostUSD, entry.ExpiresAt}
+	if err := q.db.GetContext(ctx, entry, createSemanticCacheEntryQuery, params...); err != nil {
+		return fmt.Errorf("semantic cache entry creation failed on %s: query='%s', params_count=%d, agent_id='%s', prompt_length=%d, embedding_dimension=%d, table='neurondb_agent.semantic_cache', error=%w",
+			q.getConnInfoString(), createSemanticCacheEntryQuery, len(params), entry.AgentID.String(),
+			len(entry.Prompt), len(entry.PromptEmbedding), err)
+	}
+	return nil
+}
+
+// PurgeSemanticCache removes the expired cache entries of an agent and those
+// stored for versions before agentVersion, returning how many were removed
+func (q *Queries) PurgeSemanticCache(ctx context.Context, agentID uuid.UUID, agentVersion int64) (int64, error) {
+	res, err := q.db.ExecContext(ctx, purgeSemanticCacheQuery, agentID, agentVersion)
+	if err != nil {
+		return 0, q.formatQueryError("DELETE", purgeSemanticCacheQuery, 2, "neurondb_agent.semantic_cache", err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', agent_id='%s', table='neurondb_agent.semantic_cache', error=%w",
+			q.getConnInfoString(), purgeSemanticCacheQuery, agentID.String(), err)
+	}
+	return purged, nil
+}
+
+// Session summary methods
+
+// GetSessionSummary returns the rolling summary of a session, or nil when
+// the session has none
+func (q *Queries) GetSessionSummary(ctx context.Context, sessionID uuid.UUID) (*SessionSummary, error) {
+	var summary SessionSummary
+	err := q.db.GetContext(ctx, &summary, getSessionSummaryQuery, sessionID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", listToolsQuery, 0, "neurondb_agent.tools", err)
+		return nil, q.formatQueryError("SELECT", getSessionSummaryQuery, 1, "neurondb_agent.session_summaries", err)
 	}
-	return tools, nil
+	return &summary, nil
 }
 
-func (q *Queries) UpdateTool(ctx context.Context, tool *Tool) error {
-	params := []interface{}{tool.Name, tool.Description, tool.ArgSchema, tool.HandlerType,
-		tool.HandlerConfig, tool.Enabled}
-	err := q.db.GetContext(ctx, tool, updateToolQuery, params...)
+// UpsertSessionSummary stores the rolling summary of a session. It reports
+// false, leaving the stored summary, when that summary already covers
+// summary.ThroughMessageID.
+func (q *Queries) UpsertSessionSummary(ctx context.Context, summary *SessionSummary) (bool, error) {
+	params := []interface{}{summary.SessionID, summary.Summary, summary.ThroughMessageID, summary.MessageCount}
+	err := q.db.GetContext(ctx, summary, upsertSessionSummaryQuery, params...)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("tool update failed on %s: query='%s', params_count=%d, tool_name='%s', handler_type='%s', enabled=%v, table='neurondb_agent.tools', error=%w",
-			q.getConnInfoString(), updateToolQuery, len(params), tool.Name, tool.HandlerType, tool.Enabled, err)
+		return false, q.formatQueryError("INSERT", upsertSessionSummaryQuery, len(params), "neurondb_agent.session_summaries", err)
 	}
-	return nil
+	return true, nil
 }
 
-func (q *Queries) DeleteTool(ctx context.Context, name string) error {
-	result, err := q.db.ExecContext(ctx, deleteToolQuery, name)
+// Conversation summary methods
+
+// GetConversationSummary returns the cached summary of a session in a style
+// and length, or nil when there is none
+func (q *Queries) GetConversationSummary(ctx context.Context, sessionID uuid.UUID, style, length string) (*ConversationSummary, error) {
+	var summary ConversationSummary
+	params := []interface{}{sessionID, style, length}
+	err := q.db.GetContext(ctx, &summary, getConversationSummaryQuery, params...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return q.formatQueryError("DELETE", deleteToolQuery, 1, "neurondb_agent.tools", err)
+		return nil, q.formatQueryError("SELECT", getConversationSummaryQuery, len(params), "neurondb_agent.conversation_summaries", err)
+	}
+	return &summary, nil
+}
+
+// UpsertConversationSummary caches the summary of a session. It reports
+// false, leaving the stored summary, when that summary covers more
+// messages.
+func (q *Queries) UpsertConversationSummary(ctx context.Context, summary *ConversationSummary) (bool, error) {
+	params := []interface{}{summary.SessionID, summary.Style, summary.Length, summary.Summary, summary.ThroughMessageID, summary.MessageCount}
+	err := q.db.GetContext(ctx, summary, upsertConversationSummaryQuery, params...)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', tool_name='%s', table='neurondb_agent.tools', error=%w",
-			q.getConnInfoString(), deleteToolQuery, name, err)
+		return false, q.formatQueryError("INSERT", upsertConversationSummaryQuery, len(params), "neurondb_agent.conversation_summaries", err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("tool not found on %s: query='%s', tool_name='%s', table='neurondb_agent.tools', rows_affected=0",
-			q.getConnInfoString(), deleteToolQuery, name)
+	return true, nil
+}
+
+// GetRecentMessagesAfter returns the newest limit messages of a session
+// after the message afterID, newest first like GetRecentMessages
+func (q *Queries) GetRecentMessagesAfter(ctx context.Context, sessionID uuid.UUID, afterID int64, limit int) ([]Message, error) {
+	var messages []Message
+	params := []interface{}{sessionID, afterID, limit}
+	err := q.db.SelectContext(ctx, &messages, getRecentMessagesAfterQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getRecentMessagesAfterQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// CountMessagesAfter counts the messages of a session after the message
+// afterID
+func (q *Queries) CountMessagesAfter(ctx context.Context, sessionID uuid.UUID, afterID int64) (int, error) {
+	var count int
+	if err := q.db.GetContext(ctx, &count, countMessagesAfterQuery, sessionID, afterID); err != nil {
+		return 0, q.formatQueryError("SELECT", countMessagesAfterQuery, 2, "neurondb_agent.messages", err)
+	}
+	return count, nil
+}
+
+// GetMessagesRange returns the messages of a session after the message
+// afterID up to and including throughID, in chronological order
+func (q *Queries) GetMessagesRange(ctx context.Context, sessionID uuid.UUID, afterID, throughID int64) ([]Message, error) {
+	var messages []Message
+	params := []interface{}{sessionID, afterID, throughID}
+	err := q.db.SelectContext(ctx, &messages, getMessagesRangeQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getMessagesRangeQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// GetOldestMessagesAfter returns the oldest limit messages of a session
+// after the message afterID, in chronological order
+func (q *Queries) GetOldestMessagesAfter(ctx context.Context, sessionID uuid.UUID, afterID int64, limit int) ([]Message, error) {
+	var messages []Message
+	params := []interface{}{sessionID, afterID, limit}
+	err := q.db.SelectContext(ctx, &messages, getOldestMessagesAfterQuery, params...)
+	if err != nil {
+		return nil, q.formatQueryError("SELECT", getOldestMessagesAfterQuery, len(params), "neurondb_agent.messages", err)
+	}
+	return messages, nil
+}
+
+// LLM log methods
+
+// CreateLLMLog stores a logged LLM call
+func (q *Queries) CreateLLMLog(ctx context.Context, log *LLMLog) error {
+	if log.Redactions == nil {
+		log.Redactions = JSONBMap{}
+	}
+	params := []interface{}{log.AgentID, log.SessionID, log.RunID, log.Purpose, log.Model, log.Provider, log.Prompt, log.Response,
+		log.ErrorMessage, log.PromptTokens, log.CompletionTokens, log.LatencyMs, log.Redactions, log.Truncated}
+	if err := q.db.GetContext(ctx, log, createLLMLogQuery, params...); err != nil {
+		return q.formatQueryError("INSERT", createLLMLogQuery, len(params), "neurondb_agent.llm_logs", err)
 	}
 	return nil
 }
 
-// Job methods
-func (q *Queries) CreateJob(ctx context.Context, job *Job) (*Job, error) {
-	params := []interface{}{job.AgentID, job.SessionID, job.Type, job.Status, job.Priority,
-		job.Payload, job.MaxRetries}
-	err := q.db.GetContext(ctx, job, createJobQuery, params...)
+// ListLLMLogs returns a page of an agent's logged LLM calls, newest first,
+// only those of one session when sessionID is set
+func (q *Queries) ListLLMLogs(ctx context.Context, agentID uuid.UUID, sessionID *uuid.UUID, limit, offset int) ([]LLMLog, error) {
+	logs := []LLMLog{}
+	params := []interface{}{agentID, sessionID, limit, offset}
+	if err := q.db.SelectContext(ctx, &logs, listLLMLogsQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listLLMLogsQuery, len(params), "neurondb_agent.llm_logs", err)
+	}
+	return logs, nil
+}
+
+// Memory re-embedding methods
+
+// CountMemoryChunks returns the number of memory chunks an agent has
+func (q *Queries) CountMemoryChunks(ctx context.Context, agentID uuid.UUID) (int64, error) {
+	var count int64
+	if err := q.db.GetContext(ctx, &count, countAgentMemoryChunksQuery, agentID); err != nil {
+		return 0, q.formatQueryError("SELECT", countAgentMemoryChunksQuery, 1, "neurondb_agent.memory_chunks", err)
+	}
+	return count, nil
+}
+
+// ListUnstagedMemoryChunks returns the next limit memory chunks of an agent
+// with an id above afterID that a re-embed job has not staged an embedding
+// for, in id order. Only ID and Content are set.
+func (q *Queries) ListUnstagedMemoryChunks(ctx context.Context, agentID uuid.UUID, jobID, afterID int64, limit int) ([]MemoryChunk, error) {
+	var chunks []MemoryChunk
+	params := []interface{}{agentID, jobID, afterID, limit}
+	if err := q.db.SelectContext(ctx, &chunks, listUnstagedMemoryChunksQuery, params...); err != nil {
+		return nil, q.formatQueryError("SELECT", listUnstagedMemoryChunksQuery, len(params), "neurondb_agent.memory_chunks", err)
+	}
+	return chunks, nil
+}
+
+// StoreMemoryReembedBatch stages the new embeddings of a batch of memory
+// chunks for a re-embed job and records the job's progress in the same
+// transaction, so a retried job resumes after the last staged batch
+func (q *Queries) StoreMemoryReembedBatch(ctx context.Context, jobID int64, chunkIDs []int64, embeddings [][]float32, progress map[string]interface{}) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
 	if err != nil {
-		agentIDStr := utils.SanitizeValue(job.AgentID)
-		sessionIDStr := utils.SanitizeValue(job.SessionID)
-		return nil, fmt.Errorf("job creation failed on %s: query='%s', params_count=%d, job_type='%s', status='%s', priority=%d, agent_id=%s, session_id=%s, max_retries=%d, table='neurondb_agent.jobs', error=%w",
-			q.getConnInfoString(), createJobQuery, len(params), job.Type, job.Status, job.Priority,
-			agentIDStr, sessionIDStr, job.MaxRetries, err)
+		return fmt.Errorf("memory re-embed batch failed on %s: could not begin transaction: job_id=%d, error=%w", q.getConnInfoString(), jobID, err)
 	}
-	return job, nil
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i, chunkID := range chunkIDs {
+		if _, err = tx.ExecContext(ctx, stageMemoryReembeddingQuery, jobID, chunkID, formatVector(embeddings[i])); err != nil {
+			return fmt.Errorf("memory re-embed batch failed on %s: query='%s', job_id=%d, chunk_id=%d, embedding_dimension=%d, table='neurondb_agent.memory_reembeddings', error=%w",
+				q.getConnInfoString(), stageMemoryReembeddingQuery, jobID, chunkID, len(embeddings[i]), err)
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, updateJobResultQuery, jobID, JSONBMap(progress)); err != nil {
+		return q.formatQueryError("UPDATE", updateJobResultQuery, 2, "neurondb_agent.jobs", err)
+	}
+	return tx.Commit()
 }
 
-func (q *Queries) GetJob(ctx context.Context, id int64) (*Job, error) {
-	var job Job
-	err := q.db.GetContext(ctx, &job, getJobQuery, id)
+// SwitchMemoryEmbeddings copies the embeddings a re-embed job staged over the
+// agent's memory chunks, sets the agent's memory embedding model, drops the
+// staged embeddings and records the job's progress, all in one transaction.
+// The agent row stays locked meanwhile. If some chunks of the agent have no
+// staged embedding, nothing changes and their number is returned as
+// unstaged.
+func (q *Queries) SwitchMemoryEmbeddings(ctx context.Context, agentID uuid.UUID, jobID int64, model string, progress map[string]interface{}) (switched, unstaged int64, err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("memory embedding switch failed on %s: could not begin transaction: agent_id='%s', job_id=%d, error=%w",
+			q.getConnInfoString(), agentID.String(), jobID, err)
+	}
+	defer func() {
+		if err != nil || unstaged > 0 {
+			tx.Rollback()
+		}
+	}()
+
+	var locked uuid.UUID
+	if err = tx.GetContext(ctx, &locked, lockAgentQuery, agentID); err != nil {
+		return 0, 0, q.formatQueryError("SELECT", lockAgentQuery, 1, "neurondb_agent.agents", err)
+	}
+	if err = tx.GetContext(ctx, &unstaged, countUnstagedMemoryChunksQuery, agentID, jobID); err != nil {
+		return 0, 0, q.formatQueryError("SELECT", countUnstagedMemoryChunksQuery, 2, "neurondb_agent.memory_chunks", err)
+	}
+	if unstaged > 0 {
+		return 0, unstaged, nil
+	}
+
+	result, err := tx.ExecContext(ctx, applyMemoryReembeddingsQuery, agentID, jobID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("memory embedding switch failed on %s: query='%s', agent_id='%s', job_id=%d, table='neurondb_agent.memory_chunks', error=%w",
+			q.getConnInfoString(), applyMemoryReembeddingsQuery, agentID.String(), jobID, err)
+	}
+	if switched, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	if _, err = tx.ExecContext(ctx, setAgentMemoryEmbeddingModelQuery, agentID, model); err != nil {
+		return 0, 0, q.formatQueryError("UPDATE", setAgentMemoryEmbeddingModelQuery, 2, "neurondb_agent.agents", err)
+	}
+	if _, err = tx.ExecContext(ctx, deleteMemoryReembeddingsQuery, jobID); err != nil {
+		return 0, 0, q.formatQueryError("DELETE", deleteMemoryReembeddingsQuery, 1, "neurondb_agent.memory_reembeddings", err)
+	}
+	if _, err = tx.ExecContext(ctx, updateJobResultQuery, jobID, JSONBMap(progress)); err != nil {
+		return 0, 0, q.formatQueryError("UPDATE", updateJobResultQuery, 2, "neurondb_agent.jobs", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return switched, 0, nil
+}
+
+// HasActiveJob reports whether the agent has a queued or running job of the
+// given type
+func (q *Queries) HasActiveJob(ctx context.Context, agentID uuid.UUID, jobType string) (bool, error) {
+	var active bool
+	if err := q.db.GetContext(ctx, &active, hasActiveJobQuery, agentID, jobType); err != nil {
+		return false, q.formatQueryError("SELECT", hasActiveJobQuery, 2, "neurondb_agent.jobs", err)
+	}
+	return active, nil
+}
+
+// Organization methods
+
+// CreateOrganization registers an organization. It returns an error
+// wrapping ErrAlreadyExists if the ID is taken.
+func (q *Queries) CreateOrganization(ctx context.Context, org *Organization) error {
+	params := []interface{}{org.ID, org.Name, org.Metadata}
+	err := q.db.GetContext(ctx, org, createOrganizationQuery, params...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("job not found on %s: query='%s', job_id=%d, table='neurondb_agent.jobs', error=%w",
-			q.getConnInfoString(), getJobQuery, id, err)
+		return fmt.Errorf("organization creation rejected on %s: organization_id='%s', table='neurondb_agent.organizations': %w",
+			q.getConnInfoString(), org.ID, ErrAlreadyExists)
 	}
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", getJobQuery, 1, "neurondb_agent.jobs", err)
+		return q.formatQueryError("INSERT", createOrganizationQuery, len(params), "neurondb_agent.organizations", err)
 	}
-	return &job, nil
+	return nil
 }
 
-func (q *Queries) ClaimJob(ctx context.Context) (*Job, error) {
-	var job Job
-	err := q.db.GetContext(ctx, &job, claimJobQuery)
+// GetOrganization returns a registered organization. It returns an error
+// wrapping sql.ErrNoRows if it does not exist.
+func (q *Queries) GetOrganization(ctx context.Context, id string) (*Organization, error) {
+	var org Organization
+	err := q.db.GetContext(ctx, &org, getOrganizationQuery, id)
 	if err == sql.ErrNoRows {
-		return nil, nil // No jobs available
+		return nil, fmt.Errorf("organization not found on %s: query='%s', organization_id='%s', table='neurondb_agent.organizations', error=%w",
+			q.getConnInfoString(), getOrganizationQuery, id, err)
 	}
 	if err != nil {
-		return nil, q.formatQueryError("UPDATE", claimJobQuery, 0, "neurondb_agent.jobs", err)
+		return nil, q.formatQueryError("SELECT", getOrganizationQuery, 1, "neurondb_agent.organizations", err)
 	}
-	return &job, nil
+	return &org, nil
 }
 
-func (q *Queries) UpdateJob(ctx context.Context, id int64, status string, result map[string]interface{}, errorMsg *string, retryCount int, completedAt *sql.NullTime) error {
-	var completedAtVal interface{}
-	if completedAt != nil && completedAt.Valid {
-		completedAtVal = completedAt.Time
-	} else {
-		completedAtVal = nil
+func (q *Queries) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	orgs := []Organization{}
+	if err := q.db.SelectContext(ctx, &orgs, listOrganizationsQuery); err != nil {
+		return nil, q.formatQueryError("SELECT", listOrganizationsQuery, 0, "neurondb_agent.organizations", err)
+	}
+	return orgs, nil
+}
+
+// UpdateOrganization replaces an organization's name and metadata. It
+// returns an error wrapping sql.ErrNoRows if it does not exist.
+func (q *Queries) UpdateOrganization(ctx context.Context, org *Organization) error {
+	params := []interface{}{org.ID, org.Name, org.Metadata}
+	err := q.db.GetContext(ctx, org, updateOrganizationQuery, params...)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("organization not found on %s: query='%s', organization_id='%s', table='neurondb_agent.organizations', error=%w",
+			q.getConnInfoString(), updateOrganizationQuery, org.ID, err)
 	}
-	params := []interface{}{id, status, result, errorMsg, retryCount, completedAtVal}
-	_, err := q.db.ExecContext(ctx, updateJobQuery, params...)
 	if err != nil {
-		errorMsgStr := utils.SanitizeValue(errorMsg)
-		return fmt.Errorf("job update failed on %s: query='%s', params_count=%d, job_id=%d, status='%s', retry_count=%d, error_message=%s, table='neurondb_agent.jobs', error=%w",
-			q.getConnInfoString(), updateJobQuery, len(params), id, status, retryCount, errorMsgStr, err)
+		return q.formatQueryError("UPDATE", updateOrganizationQuery, len(params), "neurondb_agent.organizations", err)
 	}
 	return nil
 }
 
-func (q *Queries) ListJobs(ctx context.Context, agentID *uuid.UUID, sessionID *uuid.UUID, limit, offset int) ([]Job, error) {
-	var jobs []Job
-	params := []interface{}{agentID, sessionID, limit, offset}
-	err := q.db.SelectContext(ctx, &jobs, listJobsQuery, params...)
+// DeleteOrganization removes an organization with its users. It returns an
+// error wrapping sql.ErrNoRows if it does not exist, or ErrInUse while
+// agents or API keys belong to it.
+func (q *Queries) DeleteOrganization(ctx context.Context, id string) error {
+	if _, err := q.GetOrganization(ctx, id); err != nil {
+		return err
+	}
+	result, err := q.db.ExecContext(ctx, deleteOrganizationQuery, id)
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", listJobsQuery, len(params), "neurondb_agent.jobs", err)
+		return q.formatQueryError("DELETE", deleteOrganizationQuery, 1, "neurondb_agent.organizations", err)
 	}
-	return jobs, nil
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', organization_id='%s', table='neurondb_agent.organizations', error=%w",
+			q.getConnInfoString(), deleteOrganizationQuery, id, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("organization deletion rejected on %s: organization_id='%s', agents or API keys still belong to it: %w",
+			q.getConnInfoString(), id, ErrInUse)
+	}
+	return nil
 }
 
-// API Key methods
-func (q *Queries) CreateAPIKey(ctx context.Context, apiKey *APIKey) error {
-	// Convert metadata to JSONB-compatible format using JSONBMap.Value()
-	metadataValue, err := apiKey.Metadata.Value()
+// User methods
+
+// CreateUser invites a user into an organization. It returns an error
+// wrapping ErrAlreadyExists if the organization has a user with the email.
+func (q *Queries) CreateUser(ctx context.Context, user *User) error {
+	params := []interface{}{user.OrganizationID, user.Email, user.Name, user.Roles}
+	err := q.db.GetContext(ctx, user, createUserQuery, params...)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user creation rejected on %s: organization_id='%s', table='neurondb_agent.users': %w",
+			q.getConnInfoString(), user.OrganizationID, ErrAlreadyExists)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to convert metadata: %w", err)
+		return q.formatQueryError("INSERT", createUserQuery, len(params), "neurondb_agent.users", err)
+	}
+	return nil
+}
+
+// GetUser returns a user. It returns an error wrapping sql.ErrNoRows if the
+// user does not exist.
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
+	var user User
+	err := q.db.GetContext(ctx, &user, getUserQuery, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found on %s: query='%s', user_id='%s', table='neurondb_agent.users', error=%w",
+			q.getConnInfoString(), getUserQuery, id.String(), err)
 	}
-	
-	params := []interface{}{apiKey.KeyHash, apiKey.KeyPrefix, apiKey.OrganizationID, apiKey.UserID,
-		apiKey.RateLimitPerMin, apiKey.Roles, metadataValue, apiKey.ExpiresAt}
-	err = q.db.GetContext(ctx, apiKey, createAPIKeyQuery, params...)
 	if err != nil {
-		return fmt.Errorf("API key creation failed on %s: query='%s', params_count=%d, key_prefix='%s', organization_id=%s, user_id=%s, rate_limit_per_min=%d, table='neurondb_agent.api_keys', error=%w",
-			q.getConnInfoString(), createAPIKeyQuery, len(params), apiKey.KeyPrefix,
-			utils.SanitizeValue(apiKey.OrganizationID), utils.SanitizeValue(apiKey.UserID), apiKey.RateLimitPerMin, err)
+		return nil, q.formatQueryError("SELECT", getUserQuery, 1, "neurondb_agent.users", err)
+	}
+	return &user, nil
+}
+
+// ListUsers returns the users of an organization, oldest first
+func (q *Queries) ListUsers(ctx context.Context, organizationID string) ([]User, error) {
+	users := []User{}
+	if err := q.db.SelectContext(ctx, &users, listUsersQuery, organizationID); err != nil {
+		return nil, q.formatQueryError("SELECT", listUsersQuery, 1, "neurondb_agent.users", err)
+	}
+	return users, nil
+}
+
+// UpdateUser replaces a user's name, roles and status, and brings its API
+// keys in line in the same transaction: keys lose the roles the user no
+// longer has, and keys left with none, or all keys of a disabled user, are
+// revoked. It returns an error wrapping sql.ErrNoRows if the user does not
+// exist.
+func (q *Queries) UpdateUser(ctx context.Context, user *User) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("user update failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	params := []interface{}{user.ID, user.Name, user.Roles, user.Status}
+	err = tx.GetContext(ctx, user, updateUserQuery, params...)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found on %s: query='%s', user_id='%s', table='neurondb_agent.users', error=%w",
+			q.getConnInfoString(), updateUserQuery, user.ID.String(), err)
+	}
+	if err != nil {
+		return q.formatQueryError("UPDATE", updateUserQuery, len(params), "neurondb_agent.users", err)
+	}
+
+	keyRoles := user.Roles
+	if user.Status == UserDisabled {
+		keyRoles = pq.StringArray{}
+	}
+	if _, err = tx.ExecContext(ctx, revokeUserAPIKeysQuery, user.ID.String(), keyRoles); err != nil {
+		return q.formatQueryError("DELETE", revokeUserAPIKeysQuery, 2, "neurondb_agent.api_keys", err)
+	}
+	if _, err = tx.ExecContext(ctx, trimUserAPIKeyRolesQuery, user.ID.String(), keyRoles); err != nil {
+		return q.formatQueryError("UPDATE", trimUserAPIKeyRolesQuery, 2, "neurondb_agent.api_keys", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("user update failed on %s: commit error: %w", q.getConnInfoString(), err)
 	}
 	return nil
 }
 
-func (q *Queries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
-	var apiKey APIKey
-	err := q.db.GetContext(ctx, &apiKey, getAPIKeyByPrefixQuery, prefix)
+// ActivateUser marks an invited user active; other users are left as they
+// are
+func (q *Queries) ActivateUser(ctx context.Context, id uuid.UUID) error {
+	if _, err := q.db.ExecContext(ctx, activateUserQuery, id); err != nil {
+		return q.formatQueryError("UPDATE", activateUserQuery, 1, "neurondb_agent.users", err)
+	}
+	return nil
+}
+
+// DeleteUser removes a user and revokes its API keys. It returns an error
+// wrapping sql.ErrNoRows if the user does not exist.
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (err error) {
+	tx, err := q.db.BeginTxx(ctx, nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("API key not found on %s: query='%s', key_prefix='%s', table='neurondb_agent.api_keys', error=%w",
-				q.getConnInfoString(), getAPIKeyByPrefixQuery, prefix, err)
+		return fmt.Errorf("user deletion failed on %s: could not begin transaction: %w", q.getConnInfoString(), err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
 		}
-		// Return detailed error for debugging
-		return nil, fmt.Errorf("API key lookup failed on %s: query='%s', key_prefix='%s', error=%w (error_type=%T)",
-			q.getConnInfoString(), getAPIKeyByPrefixQuery, prefix, err, err)
+	}()
+
+	result, err := tx.ExecContext(ctx, deleteUserQuery, id)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteUserQuery, 1, "neurondb_agent.users", err)
 	}
-	return &apiKey, nil
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', user_id='%s', table='neurondb_agent.users', error=%w",
+			q.getConnInfoString(), deleteUserQuery, id.String(), err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found on %s: query='%s', user_id='%s', table='neurondb_agent.users', rows_affected=0: %w",
+			q.getConnInfoString(), deleteUserQuery, id.String(), sql.ErrNoRows)
+	}
+	if _, err = tx.ExecContext(ctx, revokeUserAPIKeysQuery, id.String(), pq.StringArray{}); err != nil {
+		return q.formatQueryError("DELETE", revokeUserAPIKeysQuery, 2, "neurondb_agent.api_keys", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("user deletion failed on %s: commit error: %w", q.getConnInfoString(), err)
+	}
+	return nil
 }
 
-func (q *Queries) GetAPIKeyByID(ctx context.Context, id uuid.UUID) (*APIKey, error) {
-	var apiKey APIKey
-	err := q.db.GetContext(ctx, &apiKey, getAPIKeyByIDQuery, id)
+// Secret methods
+
+// CreateSecret inserts a secret. It returns an error wrapping
+// ErrAlreadyExists if a secret with the same name exists.
+func (q *Queries) CreateSecret(ctx context.Context, secret *Secret) error {
+	params := []interface{}{secret.Name, secret.Description, secret.Ciphertext, secret.WrappedKey, secret.KeyID}
+	err := q.db.GetContext(ctx, secret, createSecretQuery, params...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("API key not found on %s: query='%s', key_id='%s', table='neurondb_agent.api_keys', error=%w",
-			q.getConnInfoString(), getAPIKeyByIDQuery, id.String(), err)
+		return fmt.Errorf("secret creation rejected on %s: secret_name='%s', table='neurondb_agent.secrets': %w",
+			q.getConnInfoString(), secret.Name, ErrAlreadyExists)
 	}
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", getAPIKeyByIDQuery, 1, "neurondb_agent.api_keys", err)
+		return q.formatQueryError("INSERT", createSecretQuery, len(params), "neurondb_agent.secrets", err)
 	}
-	return &apiKey, nil
+	return nil
 }
 
-func (q *Queries) ListAPIKeys(ctx context.Context, organizationID *string) ([]APIKey, error) {
-	var keys []APIKey
-	err := q.db.SelectContext(ctx, &keys, listAPIKeysQuery, organizationID)
+// GetSecret returns a secret. It returns an error wrapping sql.ErrNoRows if
+// it does not exist.
+func (q *Queries) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	var secret Secret
+	err := q.db.GetContext(ctx, &secret, getSecretQuery, name)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("secret not found on %s: query='%s', secret_name='%s', table='neurondb_agent.secrets', error=%w",
+			q.getConnInfoString(), getSecretQuery, name, err)
+	}
 	if err != nil {
-		return nil, q.formatQueryError("SELECT", listAPIKeysQuery, 1, "neurondb_agent.api_keys", err)
+		return nil, q.formatQueryError("SELECT", getSecretQuery, 1, "neurondb_agent.secrets", err)
 	}
-	return keys, nil
+	return &secret, nil
 }
 
-func (q *Queries) UpdateAPIKeyLastUsed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, updateAPIKeyLastUsedQuery, id)
+func (q *Queries) ListSecrets(ctx context.Context) ([]Secret, error) {
+	secrets := []Secret{}
+	if err := q.db.SelectContext(ctx, &secrets, listSecretsQuery); err != nil {
+		return nil, q.formatQueryError("SELECT", listSecretsQuery, 0, "neurondb_agent.secrets", err)
+	}
+	return secrets, nil
+}
+
+// UpdateSecret replaces a secret's description and encrypted value. It
+// returns an error wrapping sql.ErrNoRows if it does not exist.
+func (q *Queries) UpdateSecret(ctx context.Context, secret *Secret) error {
+	params := []interface{}{secret.Name, secret.Description, secret.Ciphertext, secret.WrappedKey, secret.KeyID}
+	err := q.db.GetContext(ctx, secret, updateSecretQuery, params...)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("secret not found on %s: query='%s', secret_name='%s', table='neurondb_agent.secrets', error=%w",
+			q.getConnInfoString(), updateSecretQuery, secret.Name, err)
+	}
 	if err != nil {
-		return q.formatQueryError("UPDATE", updateAPIKeyLastUsedQuery, 1, "neurondb_agent.api_keys", err)
+		return q.formatQueryError("UPDATE", updateSecretQuery, len(params), "neurondb_agent.secrets", err)
 	}
 	return nil
 }
 
-func (q *Queries) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
-	result, err := q.db.ExecContext(ctx, deleteAPIKeyQuery, id)
+// RewrapSecret stores a secret's data key wrapped by another master key. It
+// returns an error wrapping ErrVersionConflict if the secret changed since
+// secret.Version, whose data key may differ.
+func (q *Queries) RewrapSecret(ctx context.Context, secret *Secret) error {
+	params := []interface{}{secret.Name, secret.Version, secret.WrappedKey, secret.KeyID}
+	result, err := q.db.ExecContext(ctx, rewrapSecretQuery, params...)
 	if err != nil {
-		return q.formatQueryError("DELETE", deleteAPIKeyQuery, 1, "neurondb_agent.api_keys", err)
+		return q.formatQueryError("UPDATE", rewrapSecretQuery, len(params), "neurondb_agent.secrets", err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', key_id='%s', table='neurondb_agent.api_keys', error=%w",
-			q.getConnInfoString(), deleteAPIKeyQuery, id.String(), err)
+		return fmt.Errorf("failed to get rows affected for UPDATE on %s: query='%s', secret_name='%s', table='neurondb_agent.secrets', error=%w",
+			q.getConnInfoString(), rewrapSecretQuery, secret.Name, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("secret rewrap rejected on %s: secret_name='%s', expected_version=%d, table='neurondb_agent.secrets': %w",
+			q.getConnInfoString(), secret.Name, secret.Version, ErrVersionConflict)
+	}
+	return nil
+}
+
+// DeleteSecret deletes a secret. It returns an error wrapping sql.ErrNoRows
+// if it does not exist.
+func (q *Queries) DeleteSecret(ctx context.Context, name string) error {
+	result, err := q.db.ExecContext(ctx, deleteSecretQuery, name)
+	if err != nil {
+		return q.formatQueryError("DELETE", deleteSecretQuery, 1, "neurondb_agent.secrets", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for DELETE on %s: query='%s', secret_name='%s', table='neurondb_agent.secrets', error=%w",
+			q.getConnInfoString(), deleteSecretQuery, name, err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("API key not found on %s: query='%s', key_id='%s', table='neurondb_agent.api_keys', rows_affected=0",
-			q.getConnInfoString(), deleteAPIKeyQuery, id.String())
+		return fmt.Errorf("secret not found on %s: query='%s', secret_name='%s', table='neurondb_agent.secrets', rows_affected=0: %w",
+			q.getConnInfoString(), deleteSecretQuery, name, sql.ErrNoRows)
 	}
 	return nil
 }
@@ -610,3 +3983,31 @@ func formatVector(vec []float32) string {
 	return result
 }
 
+// FormatVector formats an embedding as a vector literal, for queries built
+// outside this package
+func FormatVector(vec []float32) string {
+	return formatVector(vec)
+}
+
+// parseVector parses the text form of a vector, e.g. "[0.1,0.2,0.3]"
+func parseVector(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("invalid vector format: %s", utils.SanitizeValue(s))
+	}
+	body := strings.TrimSpace(s[1 : len(s)-1])
+	if body == "" {
+		return []float32{}, nil
+	}
+
+	parts := strings.Split(body, ",")
+	vec := make([]float32, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector element at index %d: %w", i, err)
+		}
+		vec[i] = float32(v)
+	}
+	return vec, nil
+}
@@ -3,18 +3,34 @@ package db
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// migrationLockID is the advisory lock key held while migrations run, so
+// concurrently starting servers apply each migration once
+const migrationLockID int64 = 0x6e6167656e74 // "nagent"
+
 type Migration struct {
 	Version int
 	Name    string
 	SQL     string
+	DownSQL string // empty when the migration cannot be reverted
+}
+
+// MigrationStatus describes a known migration and whether it is applied
+type MigrationStatus struct {
+	Version    int        `json:"version"`
+	Name       string     `json:"name"`
+	Applied    bool       `json:"applied"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+	Reversible bool       `json:"reversible"`
 }
 
 type SchemaManager struct {
@@ -31,39 +47,66 @@ func NewSchemaManager(db *sqlx.DB) *SchemaManager {
 
 // LoadMigrations loads migrations from directory
 func (sm *SchemaManager) LoadMigrations(dir string) error {
-	files, err := os.ReadDir(dir)
+	return sm.LoadMigrationsFS(os.DirFS(dir))
+}
+
+// LoadMigrationsFS loads migrations from the root of fsys. Files are named
+// NNN_name.sql, with an optional NNN_name.down.sql holding the revert SQL.
+func (sm *SchemaManager) LoadMigrationsFS(fsys fs.FS) error {
+	files, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 
+	byVersion := make(map[int]*Migration)
 	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".sql") {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".sql") {
 			continue
 		}
 
 		// Parse version from filename (e.g., "001_initial_schema.sql" -> 1)
-		var version int
-		var name string
-		parts := strings.SplitN(strings.TrimSuffix(file.Name(), ".sql"), "_", 2)
-		if len(parts) >= 1 {
-			fmt.Sscanf(parts[0], "%d", &version)
+		base := strings.TrimSuffix(file.Name(), ".sql")
+		down := strings.HasSuffix(base, ".down")
+		base = strings.TrimSuffix(base, ".down")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || version <= 0 {
+			return fmt.Errorf("migration file %s does not start with a positive version number", file.Name())
 		}
-		if len(parts) >= 2 {
+		name := ""
+		if len(parts) == 2 {
 			name = parts[1]
 		}
 
-		// Read SQL file
-		path := filepath.Join(dir, file.Name())
-		sql, err := os.ReadFile(path)
+		sql, err := fs.ReadFile(fsys, file.Name())
 		if err != nil {
 			return fmt.Errorf("failed to read migration file %s: %w", file.Name(), err)
 		}
 
-		sm.migrations = append(sm.migrations, Migration{
-			Version: version,
-			Name:    name,
-			SQL:     string(sql),
-		})
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return fmt.Errorf("migration version %d has conflicting names '%s' and '%s'", version, m.Name, name)
+		}
+		if down {
+			m.DownSQL = string(sql)
+		} else {
+			if m.SQL != "" {
+				return fmt.Errorf("duplicate migration version %d", version)
+			}
+			m.SQL = string(sql)
+		}
+	}
+
+	sm.migrations = sm.migrations[:0]
+	for _, m := range byVersion {
+		if m.SQL == "" {
+			return fmt.Errorf("migration version %d has a down file but no up file", m.Version)
+		}
+		sm.migrations = append(sm.migrations, *m)
 	}
 
 	// Sort by version
@@ -80,8 +123,8 @@ func (sm *SchemaManager) GetCurrentVersion(ctx context.Context) (int, error) {
 	var exists bool
 	err := sm.db.GetContext(ctx, &exists, `
 		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_schema = 'neurondb_agent' 
+			SELECT FROM information_schema.tables
+			WHERE table_schema = 'neurondb_agent'
 			AND table_name = 'schema_migrations'
 		)
 	`)
@@ -91,7 +134,7 @@ func (sm *SchemaManager) GetCurrentVersion(ctx context.Context) (int, error) {
 
 	var version int
 	err = sm.db.GetContext(ctx, &version, `
-		SELECT version FROM neurondb_agent.schema_migrations 
+		SELECT version FROM neurondb_agent.schema_migrations
 		ORDER BY version DESC LIMIT 1
 	`)
 	if err != nil {
@@ -101,96 +144,232 @@ func (sm *SchemaManager) GetCurrentVersion(ctx context.Context) (int, error) {
 	return version, nil
 }
 
-// Migrate runs all pending migrations
-func (sm *SchemaManager) Migrate(ctx context.Context) error {
-	// Create schema_migrations table if it doesn't exist
-	_, err := sm.db.ExecContext(ctx, `
-		CREATE SCHEMA IF NOT EXISTS neurondb_agent;
-		CREATE TABLE IF NOT EXISTS neurondb_agent.schema_migrations (
-			version INT PRIMARY KEY,
-			name TEXT NOT NULL,
-			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create schema_migrations table: %w", err)
-	}
+// Migrate applies all pending migrations in version order, each in its own
+// transaction, and returns the migrations applied
+func (sm *SchemaManager) Migrate(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := sm.withLock(ctx, func(conn *sqlx.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			if err := checkUntrackedSchema(ctx, conn); err != nil {
+				return err
+			}
+		}
 
-	currentVersion, err := sm.GetCurrentVersion(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get current version: %w", err)
+		for _, migration := range sm.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := runMigrationTx(ctx, conn, migration.SQL, func(tx *sqlx.Tx) error {
+				_, err := tx.ExecContext(ctx, `
+					INSERT INTO neurondb_agent.schema_migrations (version, name)
+					VALUES ($1, $2)
+				`, migration.Version, migration.Name)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to run migration %d (%s): %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the migrations reverted. It stops at the first migration without down SQL.
+func (sm *SchemaManager) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
 	}
 
-	// Run pending migrations
-	for _, migration := range sm.migrations {
-		if migration.Version <= currentVersion {
-			continue
+	var reverted []Migration
+	err := sm.withLock(ctx, func(conn *sqlx.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		if len(versions) == 0 {
+			return fmt.Errorf("no migrations to roll back")
+		}
+		if steps > len(versions) {
+			steps = len(versions)
 		}
 
-		// Run migration in transaction
-		tx, err := sm.db.BeginTxx(ctx, nil)
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
+		for _, version := range versions[:steps] {
+			migration := sm.find(version)
+			if migration == nil {
+				return fmt.Errorf("applied migration version %d is not known to this binary", version)
+			}
+			if migration.DownSQL == "" {
+				return fmt.Errorf("migration %d (%s) has no down migration", version, migration.Name)
+			}
+			if err := runMigrationTx(ctx, conn, migration.DownSQL, func(tx *sqlx.Tx) error {
+				_, err := tx.ExecContext(ctx, `DELETE FROM neurondb_agent.schema_migrations WHERE version = $1`, version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to roll back migration %d (%s): %w", version, migration.Name, err)
+			}
+			reverted = append(reverted, *migration)
 		}
+		return nil
+	})
+	return reverted, err
+}
 
-		_, err = tx.ExecContext(ctx, migration.SQL)
+// Rollback rolls back the last migration
+func (sm *SchemaManager) Rollback(ctx context.Context) error {
+	_, err := sm.Down(ctx, 1)
+	return err
+}
+
+// Baseline records every migration up to version as applied without running
+// it, for databases whose schema was created before migrations were tracked
+func (sm *SchemaManager) Baseline(ctx context.Context, version int) ([]Migration, error) {
+	if sm.find(version) == nil {
+		return nil, fmt.Errorf("migration version %d not found", version)
+	}
+
+	var recorded []Migration
+	err := sm.withLock(ctx, func(conn *sqlx.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to run migration %d: %w", migration.Version, err)
+			return err
 		}
+		for _, migration := range sm.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, `
+				INSERT INTO neurondb_agent.schema_migrations (version, name)
+				VALUES ($1, $2)
+			`, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			}
+			recorded = append(recorded, migration)
+		}
+		return nil
+	})
+	return recorded, err
+}
 
-		// Record migration
-		_, err = tx.ExecContext(ctx, `
-			INSERT INTO neurondb_agent.schema_migrations (version, name)
-			VALUES ($1, $2)
-		`, migration.Version, migration.Name)
+// Status lists every known migration and whether it has been applied
+func (sm *SchemaManager) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := sm.withLock(ctx, func(conn *sqlx.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			return err
 		}
+		for _, migration := range sm.migrations {
+			status := MigrationStatus{
+				Version:    migration.Version,
+				Name:       migration.Name,
+				Reversible: migration.DownSQL != "",
+			}
+			if at, ok := done[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
 
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+func (sm *SchemaManager) find(version int) *Migration {
+	for i := range sm.migrations {
+		if sm.migrations[i].Version == version {
+			return &sm.migrations[i]
 		}
 	}
-
 	return nil
 }
 
-// Rollback rolls back the last migration (if supported)
-func (sm *SchemaManager) Rollback(ctx context.Context) error {
-	currentVersion, err := sm.GetCurrentVersion(ctx)
+// withLock runs fn on a dedicated connection holding the migration advisory
+// lock, after making sure schema_migrations exists
+func (sm *SchemaManager) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := sm.db.Connx(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get current version: %w", err)
+		return fmt.Errorf("failed to get migration connection: %w", err)
 	}
+	defer conn.Close()
 
-	if currentVersion == 0 {
-		return fmt.Errorf("no migrations to rollback")
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
 	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
 
-	// Find migration to rollback
-	var migrationToRollback *Migration
-	for _, m := range sm.migrations {
-		if m.Version == currentVersion {
-			migrationToRollback = &m
-			break
-		}
+	_, err = conn.ExecContext(ctx, `
+		CREATE SCHEMA IF NOT EXISTS neurondb_agent;
+		CREATE TABLE IF NOT EXISTS neurondb_agent.schema_migrations (
+			version INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
 	}
 
-	if migrationToRollback == nil {
-		return fmt.Errorf("migration version %d not found", currentVersion)
-	}
+	return fn(conn)
+}
 
-	// Note: Full rollback requires storing rollback SQL
-	// For now, we just remove the version record
-	_, err = sm.db.ExecContext(ctx, `
-		DELETE FROM neurondb_agent.schema_migrations 
-		WHERE version = $1
-	`, currentVersion)
-	if err != nil {
-		return fmt.Errorf("failed to rollback migration: %w", err)
+// appliedMigrations returns the applied versions and when they were applied
+func appliedMigrations(ctx context.Context, conn *sqlx.Conn) (map[int]time.Time, error) {
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := conn.SelectContext(ctx, &rows, `SELECT version, applied_at FROM neurondb_agent.schema_migrations`); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	done := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		done[r.Version] = r.AppliedAt
 	}
+	return done, nil
+}
 
+// checkUntrackedSchema refuses to migrate a database whose tables exist but
+// were never recorded in schema_migrations, since re-running the initial
+// migration would fail halfway
+func checkUntrackedSchema(ctx context.Context, conn *sqlx.Conn) error {
+	var exists bool
+	if err := conn.GetContext(ctx, &exists, `SELECT to_regclass('neurondb_agent.agents') IS NOT NULL`); err != nil {
+		return fmt.Errorf("failed to inspect existing schema: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("neurondb_agent tables exist but no migrations are recorded; run 'agent-server migrate baseline <version>' to record the migrations already applied")
+	}
 	return nil
 }
 
+// runMigrationTx executes sql and record in one transaction on conn
+func runMigrationTx(ctx context.Context, conn *sqlx.Conn, sql string, record func(tx *sqlx.Tx) error) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sql); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	return tx.Commit()
+}
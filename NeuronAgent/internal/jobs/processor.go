@@ -12,9 +12,13 @@ import (
 	"github.com/neurondb/NeuronAgent/internal/db"
 )
 
+// Handler processes jobs of a type registered with Processor.Register
+type Handler func(ctx context.Context, job *db.Job) (map[string]interface{}, error)
+
 type Processor struct {
 	httpClient *http.Client
 	db         *db.DB
+	handlers   map[string]Handler
 }
 
 func NewProcessor(database *db.DB) *Processor {
@@ -22,10 +26,17 @@ func NewProcessor(database *db.DB) *Processor {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		db: database,
+		db:       database,
+		handlers: make(map[string]Handler),
 	}
 }
 
+// Register adds a handler for jobType. Built-in job types cannot be
+// overridden.
+func (p *Processor) Register(jobType string, handler Handler) {
+	p.handlers[jobType] = handler
+}
+
 func (p *Processor) Process(ctx context.Context, job *db.Job) (map[string]interface{}, error) {
 	switch job.Type {
 	case "http_call":
@@ -35,6 +46,9 @@ func (p *Processor) Process(ctx context.Context, job *db.Job) (map[string]interf
 	case "shell_task":
 		return p.processShellTask(ctx, job)
 	default:
+		if handler, ok := p.handlers[job.Type]; ok {
+			return handler(ctx, job)
+		}
 		return nil, fmt.Errorf("unknown job type: %s", job.Type)
 	}
 }
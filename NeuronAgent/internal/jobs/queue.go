@@ -46,3 +46,7 @@ func (q *Queue) UpdateJob(ctx context.Context, id int64, status string, result m
 	return q.queries.UpdateJob(ctx, id, status, result, errorMsg, retryCount, completedAt)
 }
 
+// RequeueJob hands a running job back to the queue without counting a retry
+func (q *Queue) RequeueJob(ctx context.Context, id int64) error {
+	return q.queries.RequeueJob(ctx, id)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/neurondb/NeuronAgent/internal/db"
 	"github.com/neurondb/NeuronAgent/internal/metrics"
+	"github.com/neurondb/NeuronAgent/internal/webhooks"
 )
 
 type Worker struct {
@@ -18,6 +19,16 @@ type Worker struct {
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
 	retryDelay time.Duration
+	events     *webhooks.Emitter
+
+	// draining is closed once the worker stops claiming jobs
+	draining  chan struct{}
+	drainOnce sync.Once
+	// mu guards running, the jobs being processed, and handedBack, set
+	// once they were requeued by a drain
+	mu         sync.Mutex
+	running    map[int64]struct{}
+	handedBack bool
 }
 
 func NewWorker(queue *Queue, processor *Processor, workers int) *Worker {
@@ -29,6 +40,9 @@ func NewWorker(queue *Queue, processor *Processor, workers int) *Worker {
 		ctx:        ctx,
 		cancel:     cancel,
 		retryDelay: 5 * time.Second,
+		events:     webhooks.NewEmitter(queue.queries),
+		draining:   make(chan struct{}),
+		running:    make(map[int64]struct{}),
 	}
 }
 
@@ -49,6 +63,8 @@ func (w *Worker) work() {
 		select {
 		case <-w.ctx.Done():
 			return
+		case <-w.draining:
+			return
 		case <-ticker.C:
 			job, err := w.queue.ClaimJob(w.ctx)
 			if err != nil || job == nil {
@@ -61,8 +77,21 @@ func (w *Worker) work() {
 }
 
 func (w *Worker) processJob(job *db.Job) {
+	w.mu.Lock()
+	w.running[job.ID] = struct{}{}
+	w.mu.Unlock()
+
 	result, err := w.processor.Process(w.ctx, job)
-	
+
+	w.mu.Lock()
+	delete(w.running, job.ID)
+	handedBack := w.handedBack
+	w.mu.Unlock()
+	if handedBack {
+		// The job was requeued for another worker
+		return
+	}
+
 	status := "done"
 	errorMsg := (*string)(nil)
 	retryCount := job.RetryCount
@@ -99,6 +128,54 @@ func (w *Worker) processJob(job *db.Job) {
 	}
 
 	w.queue.UpdateJob(w.ctx, job.ID, status, result, errorMsg, retryCount, completedAtVal)
+
+	if status == "failed" {
+		w.events.Emit(w.ctx, webhooks.EventJobFailed, map[string]interface{}{
+			"job_id":      job.ID,
+			"type":        job.Type,
+			"agent_id":    job.AgentID,
+			"session_id":  job.SessionID,
+			"error":       *errorMsg,
+			"retry_count": retryCount,
+		})
+	}
+}
+
+// Drain stops the worker claiming jobs and waits for the jobs being
+// processed to finish. Jobs still running when ctx is done are handed back
+// to the queue for another server, without counting a retry, and their
+// processing is canceled. It returns the number of jobs handed back.
+func (w *Worker) Drain(ctx context.Context) int {
+	w.drainOnce.Do(func() { close(w.draining) })
+
+	idle := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return 0
+	case <-ctx.Done():
+	}
+
+	w.mu.Lock()
+	w.handedBack = true
+	ids := make([]int64, 0, len(w.running))
+	for id := range w.running {
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+
+	requeueCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, id := range ids {
+		if err := w.queue.RequeueJob(requeueCtx, id); err != nil {
+			metrics.Logger().Warn().Err(err).Int64("job_id", id).Msg("Failed to hand back job")
+		}
+	}
+	w.cancel()
+	return len(ids)
 }
 
 func (w *Worker) Stop() {
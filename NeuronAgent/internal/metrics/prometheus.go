@@ -63,6 +63,30 @@ var (
 		[]string{"model", "type"},
 	)
 
+	llmFailoversTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_llm_failovers_total",
+			Help: "Total number of LLM calls served by a fallback provider",
+		},
+		[]string{"provider"},
+	)
+
+	llmCostUSDTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_llm_cost_usd_total",
+			Help: "Estimated LLM cost in USD, as recorded in the usage ledger",
+		},
+		[]string{"agent_id", "model"},
+	)
+
+	llmCircuitOpen = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "neurondb_agent_llm_circuit_open",
+			Help: "Whether the circuit breaker of an LLM provider is open (1) or half open (0.5)",
+		},
+		[]string{"provider"},
+	)
+
 	// Memory metrics
 	memoryChunksStored = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -80,6 +104,139 @@ var (
 		[]string{"agent_id"},
 	)
 
+	memoryChunksCurrent = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "neurondb_agent_memory_chunks",
+			Help: "Number of memory chunks currently held per agent",
+		},
+		[]string{"agent_id"},
+	)
+
+	memoryBytesCurrent = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "neurondb_agent_memory_content_bytes",
+			Help: "Size of memory chunk content currently held per agent",
+		},
+		[]string{"agent_id"},
+	)
+
+	memoryChunksEvicted = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_memory_chunks_evicted_total",
+			Help: "Total number of memory chunks evicted by retention policies",
+		},
+		[]string{"agent_id", "reason", "action"},
+	)
+
+	sessionsRetained = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_sessions_retention_total",
+			Help: "Total number of sessions archived or purged by retention policies",
+		},
+		[]string{"agent_id", "action"},
+	)
+
+	memoryBackfillRows = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_memory_backfill_rows_total",
+			Help: "Total number of source rows processed by memory backfill jobs",
+		},
+		[]string{"agent_id", "outcome"},
+	)
+
+	memoryReembedChunks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_memory_reembed_chunks_total",
+			Help: "Total number of memory chunks processed by memory re-embed jobs",
+		},
+		[]string{"agent_id", "stage"},
+	)
+
+	memoryCurationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_memory_curations_total",
+			Help: "Total number of memory chunks updated or deleted through the API",
+		},
+		[]string{"agent_id", "action"},
+	)
+
+	// Guardrail metrics
+	guardrailViolationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_guardrail_violations_total",
+			Help: "Total number of guardrail violations",
+		},
+		[]string{"agent_id", "stage", "rule", "action"},
+	)
+
+	toolConstraintViolationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_tool_constraint_violations_total",
+			Help: "Total number of tool calls refused for breaking a tool argument constraint, by rule",
+		},
+		[]string{"agent_id", "tool", "rule"},
+	)
+
+	// Webhook metrics
+	webhookDeliveriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts",
+		},
+		[]string{"event", "outcome"},
+	)
+
+	// Semantic cache metrics
+	semanticCacheLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_semantic_cache_lookups_total",
+			Help: "Total number of semantic cache lookups",
+		},
+		[]string{"agent_id", "outcome"},
+	)
+
+	// LLM call log metrics
+	llmLogsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_llm_logs_total",
+			Help: "Total number of LLM calls written to the LLM call log",
+		},
+		[]string{"sink", "outcome"},
+	)
+
+	// Run concurrency metrics
+	runsActive = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "neurondb_agent_runs_active",
+			Help: "Number of messages an agent is processing",
+		},
+		[]string{"agent_id"},
+	)
+
+	runsRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_runs_rejected_total",
+			Help: "Total number of messages refused by a concurrency limit",
+		},
+		[]string{"agent_id", "reason"},
+	)
+
+	runsRecoveredTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_runs_recovered_total",
+			Help: "Total number of interrupted runs taken over by recovery",
+		},
+		[]string{"agent_id", "outcome"},
+	)
+
+	requestsLimitedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_requests_limited_total",
+			Help: "Total number of requests refused by a rate limit or daily quota",
+		},
+		[]string{"reason"},
+	)
+
 	// Tool metrics
 	toolExecutionsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -89,6 +246,14 @@ var (
 		[]string{"tool_name", "status"},
 	)
 
+	toolCacheLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_tool_cache_lookups_total",
+			Help: "Total number of tool result cache lookups",
+		},
+		[]string{"agent_id", "tool_name", "outcome"},
+	)
+
 	toolExecutionDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "neurondb_agent_tool_execution_duration_seconds",
@@ -98,6 +263,67 @@ var (
 		[]string{"tool_name"},
 	)
 
+	// Database pool metrics
+	dbPoolConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "neurondb_agent_db_pool_connections",
+			Help: "Number of open database connections by state (in_use or idle)",
+		},
+		[]string{"state"},
+	)
+
+	dbPoolMaxOpen = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "neurondb_agent_db_pool_max_open_connections",
+			Help: "Current limit on open database connections",
+		},
+	)
+
+	dbPoolWaitsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_db_pool_waits_total",
+			Help: "Total number of database connection requests that waited for a free connection",
+		},
+	)
+
+	dbPoolWaitSeconds = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_db_pool_wait_seconds_total",
+			Help: "Total time spent waiting for a free database connection",
+		},
+	)
+
+	dbPoolClosedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_db_pool_closed_total",
+			Help: "Total number of database connections closed by the pool, by reason (max_idle, max_idle_time or max_lifetime)",
+		},
+		[]string{"reason"},
+	)
+
+	dbPoolAcquireDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "neurondb_agent_db_pool_acquire_duration_seconds",
+			Help:    "Time taken to acquire a database connection for a query",
+			Buckets: []float64{0.0001, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+		},
+	)
+
+	dbPoolSlowAcquiresTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_db_pool_slow_acquires_total",
+			Help: "Total number of queries that waited longer than the slow acquire threshold for a connection",
+		},
+	)
+
+	dbPoolResizesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "neurondb_agent_db_pool_resizes_total",
+			Help: "Total number of adaptive resizes of the database pool, by direction (grow or shrink)",
+		},
+		[]string{"direction"},
+	)
+
 	// Job metrics
 	jobsQueued = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -134,6 +360,30 @@ func RecordLLMCall(model, status string, promptTokens, completionTokens int) {
 	llmTokensTotal.WithLabelValues(model, "completion").Add(float64(completionTokens))
 }
 
+// RecordLLMCost records the estimated cost of an LLM call
+func RecordLLMCost(agentID, model string, costUSD float64) {
+	llmCostUSDTotal.WithLabelValues(agentID, model).Add(costUSD)
+}
+
+// RecordLLMFailover records a call served by a provider other than the first
+// in the agent's chain
+func RecordLLMFailover(provider string) {
+	llmFailoversTotal.WithLabelValues(provider).Inc()
+}
+
+// RecordLLMCircuitState records the circuit breaker state ("closed", "open"
+// or "half_open") of a provider
+func RecordLLMCircuitState(provider, state string) {
+	value := 0.0
+	switch state {
+	case "open":
+		value = 1
+	case "half_open":
+		value = 0.5
+	}
+	llmCircuitOpen.WithLabelValues(provider).Set(value)
+}
+
 // RecordMemoryChunkStored records a memory chunk being stored
 func RecordMemoryChunkStored(agentID string) {
 	memoryChunksStored.WithLabelValues(agentID).Inc()
@@ -144,12 +394,144 @@ func RecordMemoryRetrieval(agentID string) {
 	memoryRetrievalsTotal.WithLabelValues(agentID).Inc()
 }
 
+// RecordMemoryUsage records the current memory footprint of an agent
+func RecordMemoryUsage(agentID string, chunks, contentBytes int64) {
+	memoryChunksCurrent.WithLabelValues(agentID).Set(float64(chunks))
+	memoryBytesCurrent.WithLabelValues(agentID).Set(float64(contentBytes))
+}
+
+// RecordMemoryEviction records memory chunks evicted for reason ("max_age" or
+// "max_chunks") with action ("deleted" or "archived")
+func RecordMemoryEviction(agentID, reason, action string, count int64) {
+	memoryChunksEvicted.WithLabelValues(agentID, reason, action).Add(float64(count))
+}
+
+// RecordSessionRetention records sessions archived or purged by retention,
+// action being "archived" or "purged"
+func RecordSessionRetention(agentID, action string, count int64) {
+	sessionsRetained.WithLabelValues(agentID, action).Add(float64(count))
+}
+
+// RecordMemoryBackfill records a memory backfill batch: rows stored as chunks
+// and rows skipped for having no text
+func RecordMemoryBackfill(agentID string, stored, skipped int) {
+	memoryBackfillRows.WithLabelValues(agentID, "stored").Add(float64(stored))
+	memoryBackfillRows.WithLabelValues(agentID, "skipped").Add(float64(skipped))
+}
+
+// RecordMemoryReembed records memory chunks of a re-embed job at a stage:
+// "embedded" when their new embeddings are staged, "switched" when they are
+// switched over to them
+func RecordMemoryReembed(agentID, stage string, count int64) {
+	memoryReembedChunks.WithLabelValues(agentID, stage).Add(float64(count))
+}
+
+// RecordMemoryCuration records a memory chunk curated through the API, with
+// action "update" or "delete"
+func RecordMemoryCuration(agentID, action string) {
+	memoryCurationsTotal.WithLabelValues(agentID, action).Inc()
+}
+
+// RecordGuardrailViolation records a guardrail violation at stage ("input",
+// "tool_result" or "output")
+func RecordGuardrailViolation(agentID, stage, rule, action string) {
+	guardrailViolationsTotal.WithLabelValues(agentID, stage, rule, action).Inc()
+}
+
+// RecordToolConstraintViolation records a tool argument breaking a
+// constraint of the agent
+func RecordToolConstraintViolation(agentID, tool, rule string) {
+	toolConstraintViolationsTotal.WithLabelValues(agentID, tool, rule).Inc()
+}
+
+// RecordWebhookDelivery records a webhook delivery attempt by outcome
+// ("delivered", "retry" or "dead")
+func RecordWebhookDelivery(event, outcome string) {
+	webhookDeliveriesTotal.WithLabelValues(event, outcome).Inc()
+}
+
+// RecordSemanticCacheLookup records a semantic cache lookup by outcome
+// ("hit", "miss" or "error")
+func RecordSemanticCacheLookup(agentID, outcome string) {
+	semanticCacheLookupsTotal.WithLabelValues(agentID, outcome).Inc()
+}
+
+// RecordLLMLog records an LLM call written to a log sink ("table" or
+// "file") by outcome ("logged" or "error")
+func RecordLLMLog(sink, outcome string) {
+	llmLogsTotal.WithLabelValues(sink, outcome).Inc()
+}
+
+// RecordRunsActive records the number of messages an agent is processing
+func RecordRunsActive(agentID string, active int) {
+	runsActive.WithLabelValues(agentID).Set(float64(active))
+}
+
+// RecordRunRejected records a message refused by a concurrency limit, by
+// reason ("session_busy" or "agent_limit")
+func RecordRunRejected(agentID, reason string) {
+	runsRejectedTotal.WithLabelValues(agentID, reason).Inc()
+}
+
+// RecordRunRecovered records an interrupted run taken over by recovery, by
+// outcome ("completed", "paused" or "failed")
+func RecordRunRecovered(agentID, outcome string) {
+	runsRecoveredTotal.WithLabelValues(agentID, outcome).Inc()
+}
+
+// RecordRateLimited records a request refused by a rate limit or quota, by
+// reason ("rate_limit", "quota_api_key" or "quota_organization")
+func RecordRateLimited(reason string) {
+	requestsLimitedTotal.WithLabelValues(reason).Inc()
+}
+
 // RecordToolExecution records a tool execution
 func RecordToolExecution(toolName, status string, duration time.Duration) {
 	toolExecutionsTotal.WithLabelValues(toolName, status).Inc()
 	toolExecutionDuration.WithLabelValues(toolName).Observe(duration.Seconds())
 }
 
+// RecordToolCacheLookup records a tool result cache lookup by outcome
+// ("hit" or "miss")
+func RecordToolCacheLookup(agentID, toolName, outcome string) {
+	toolCacheLookupsTotal.WithLabelValues(agentID, toolName, outcome).Inc()
+}
+
+// RecordDBPoolStats records the connections of the database pool and its
+// current limit
+func RecordDBPoolStats(inUse, idle, maxOpen int) {
+	dbPoolConnections.WithLabelValues("in_use").Set(float64(inUse))
+	dbPoolConnections.WithLabelValues("idle").Set(float64(idle))
+	dbPoolMaxOpen.Set(float64(maxOpen))
+}
+
+// RecordDBPoolWaits records connection requests that waited for a free
+// connection since the last sample, and how long they waited in total
+func RecordDBPoolWaits(count int64, wait time.Duration) {
+	dbPoolWaitsTotal.Add(float64(count))
+	dbPoolWaitSeconds.Add(wait.Seconds())
+}
+
+// RecordDBPoolClosed records connections closed by the pool for reason
+// ("max_idle", "max_idle_time" or "max_lifetime")
+func RecordDBPoolClosed(reason string, count int64) {
+	dbPoolClosedTotal.WithLabelValues(reason).Add(float64(count))
+}
+
+// RecordDBPoolAcquire records the time a query took to acquire a connection
+func RecordDBPoolAcquire(duration time.Duration, slow bool) {
+	dbPoolAcquireDuration.Observe(duration.Seconds())
+	if slow {
+		dbPoolSlowAcquiresTotal.Inc()
+	}
+}
+
+// RecordDBPoolResize records an adaptive resize of the database pool
+// ("grow" or "shrink")
+func RecordDBPoolResize(direction string) {
+	dbPoolResizesTotal.WithLabelValues(direction).Inc()
+}
+
 // RecordJobQueued records a job being queued
 func RecordJobQueued() {
 	jobsQueued.Inc()
@@ -0,0 +1,188 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dataKeySize is the size of the AES-256 key each secret is encrypted with
+const dataKeySize = 32
+
+// KeyWrapper encrypts and decrypts the data keys of secrets with a master
+// key that never leaves it, such as a key held in the server's environment
+// or in a KMS
+type KeyWrapper interface {
+	// KeyID names the master key Wrap uses
+	KeyID() string
+	// Wrap encrypts a data key with the current master key
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	// Unwrap decrypts a data key wrapped by the master key keyID
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyWrapper wraps data keys with AES-256-GCM master keys held in
+// memory. Only the first key wraps; the others only unwrap, so data keys
+// wrapped before a master key rotation open until they are rewrapped.
+type LocalKeyWrapper struct {
+	keys []localKey
+}
+
+type localKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewLocalKeyWrapper creates a key wrapper from 32-byte master keys, the
+// current one first
+func NewLocalKeyWrapper(current []byte, previous ...[]byte) (*LocalKeyWrapper, error) {
+	w := &LocalKeyWrapper{}
+	for i, key := range append([][]byte{current}, previous...) {
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("master key %d must be %d bytes, got %d", i, dataKeySize, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		w.keys = append(w.keys, localKey{id: localKeyID(key), aead: aead})
+	}
+	return w, nil
+}
+
+// LocalKeyWrapperFromEnv creates a key wrapper from the base64 master key
+// in the environment variable keyEnv and the comma-separated previous keys
+// in previousEnv. It returns nil when keyEnv is not set.
+func LocalKeyWrapperFromEnv(keyEnv, previousEnv string) (*LocalKeyWrapper, error) {
+	encoded := os.Getenv(keyEnv)
+	if encoded == "" {
+		return nil, nil
+	}
+	current, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a base64-encoded %d-byte key: %w", keyEnv, dataKeySize, err)
+	}
+	var previous [][]byte
+	if previousEnv != "" {
+		for i, encoded := range strings.Split(os.Getenv(previousEnv), ",") {
+			if encoded = strings.TrimSpace(encoded); encoded == "" {
+				continue
+			}
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("%s key %d must be a base64-encoded %d-byte key: %w", previousEnv, i, dataKeySize, err)
+			}
+			previous = append(previous, key)
+		}
+	}
+	return NewLocalKeyWrapper(current, previous...)
+}
+
+// localKeyID names a local master key by a prefix of its hash, so the key
+// a data key was wrapped with can be found without storing the key
+func localKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "local:" + hex.EncodeToString(sum[:4])
+}
+
+// KeyID names the current master key
+func (w *LocalKeyWrapper) KeyID() string {
+	return w.keys[0].id
+}
+
+// Wrap encrypts a data key with the current master key
+func (w *LocalKeyWrapper) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	return gcmSeal(w.keys[0].aead, dataKey, []byte(w.keys[0].id))
+}
+
+// Unwrap decrypts a data key wrapped by the current or a previous master key
+func (w *LocalKeyWrapper) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	for _, key := range w.keys {
+		if key.id == keyID {
+			return gcmOpen(key.aead, wrapped, []byte(key.id))
+		}
+	}
+	return nil, fmt.Errorf("master key '%s' is not configured", keyID)
+}
+
+// sealedValue is a secret value encrypted with its own data key, and the
+// data key wrapped by the master key keyID
+type sealedValue struct {
+	ciphertext []byte
+	wrappedKey []byte
+	keyID      string
+}
+
+// seal encrypts value with a new data key bound to the secret's name, so a
+// ciphertext copied to another secret does not decrypt
+func seal(ctx context.Context, wrapper KeyWrapper, name string, value []byte) (*sealedValue, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := gcmSeal(aead, value, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapper.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key with master key '%s': %w", wrapper.KeyID(), err)
+	}
+	return &sealedValue{ciphertext: ciphertext, wrappedKey: wrapped, keyID: wrapper.KeyID()}, nil
+}
+
+// open decrypts a sealed value of the secret name
+func open(ctx context.Context, wrapper KeyWrapper, name string, sealed *sealedValue) ([]byte, error) {
+	dataKey, err := wrapper.Unwrap(ctx, sealed.keyID, sealed.wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with master key '%s': %w", sealed.keyID, err)
+	}
+	defer clear(dataKey)
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return gcmOpen(aead, sealed.ciphertext, []byte(name))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// gcmSeal encrypts plaintext under a random nonce, returned before the
+// ciphertext
+func gcmSeal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func gcmOpen(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,157 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func newMasterKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generate master key: %v", err)
+	}
+	return key
+}
+
+func newWrapper(t *testing.T, current []byte, previous ...[]byte) *LocalKeyWrapper {
+	t.Helper()
+	w, err := NewLocalKeyWrapper(current, previous...)
+	if err != nil {
+		t.Fatalf("NewLocalKeyWrapper: %v", err)
+	}
+	return w
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	w := newWrapper(t, newMasterKey(t))
+
+	for _, value := range [][]byte{[]byte("ghp_token"), {}, bytes.Repeat([]byte{0xff}, MaxValueBytes)} {
+		sealed, err := seal(ctx, w, "github_token", value)
+		if err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		if sealed.keyID != w.KeyID() {
+			t.Errorf("keyID = %q, want %q", sealed.keyID, w.KeyID())
+		}
+		if len(value) > 0 && bytes.Contains(sealed.ciphertext, value) {
+			t.Error("ciphertext contains the value")
+		}
+		opened, err := open(ctx, w, "github_token", sealed)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		if !bytes.Equal(opened, value) {
+			t.Errorf("open returned %d bytes, want the %d sealed", len(opened), len(value))
+		}
+	}
+
+	// Each seal uses a new data key and nonce
+	a, _ := seal(ctx, w, "github_token", []byte("same"))
+	b, _ := seal(ctx, w, "github_token", []byte("same"))
+	if bytes.Equal(a.ciphertext, b.ciphertext) || bytes.Equal(a.wrappedKey, b.wrappedKey) {
+		t.Error("sealing a value twice gave the same ciphertext or wrapped key")
+	}
+}
+
+func TestOpenIsBoundToName(t *testing.T) {
+	ctx := context.Background()
+	w := newWrapper(t, newMasterKey(t))
+	sealed, err := seal(ctx, w, "github_token", []byte("ghp_token"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	if _, err := open(ctx, w, "slack_token", sealed); err == nil {
+		t.Error("a ciphertext moved to another secret name decrypted")
+	}
+
+	tampered := *sealed
+	tampered.ciphertext = append([]byte(nil), sealed.ciphertext...)
+	tampered.ciphertext[len(tampered.ciphertext)-1] ^= 1
+	if _, err := open(ctx, w, "github_token", &tampered); err == nil {
+		t.Error("a tampered ciphertext decrypted")
+	}
+
+	// Data keys are bound to the master key ID as well
+	relabelled := *sealed
+	relabelled.keyID = "local:00000000"
+	if _, err := open(ctx, w, "github_token", &relabelled); err == nil {
+		t.Error("a data key under another master key ID unwrapped")
+	}
+}
+
+func TestLocalKeyWrapperRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+	before := newWrapper(t, oldKey)
+	sealed, err := seal(ctx, before, "github_token", []byte("ghp_token"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	// After the rotation the old key still unwraps, and new values use
+	// the new key
+	rotated := newWrapper(t, newKey, oldKey)
+	if rotated.KeyID() == before.KeyID() {
+		t.Fatal("rotated wrapper kept the old key ID")
+	}
+	if opened, err := open(ctx, rotated, "github_token", sealed); err != nil || string(opened) != "ghp_token" {
+		t.Fatalf("open with the previous master key = %q, %v", opened, err)
+	}
+
+	// Rewrapping as RotateKeys does leaves the value readable once the old
+	// key is gone
+	dataKey, err := rotated.Unwrap(ctx, sealed.keyID, sealed.wrappedKey)
+	if err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	wrapped, err := rotated.Wrap(ctx, dataKey)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	rewrapped := &sealedValue{ciphertext: sealed.ciphertext, wrappedKey: wrapped, keyID: rotated.KeyID()}
+	after := newWrapper(t, newKey)
+	if opened, err := open(ctx, after, "github_token", rewrapped); err != nil || string(opened) != "ghp_token" {
+		t.Fatalf("open after rewrapping = %q, %v", opened, err)
+	}
+	if _, err := open(ctx, after, "github_token", sealed); err == nil {
+		t.Error("a value not rewrapped opened without its master key")
+	}
+}
+
+func TestNewLocalKeyWrapperChecksKeySize(t *testing.T) {
+	if _, err := NewLocalKeyWrapper(make([]byte, 16)); err == nil {
+		t.Error("16-byte master key: expected an error")
+	}
+	if _, err := NewLocalKeyWrapper(newMasterKey(t), make([]byte, 31)); err == nil {
+		t.Error("31-byte previous key: expected an error")
+	}
+}
+
+func TestLocalKeyWrapperFromEnv(t *testing.T) {
+	current, previous := newMasterKey(t), newMasterKey(t)
+	t.Setenv("TEST_SECRETS_KEY", "")
+	if w, err := LocalKeyWrapperFromEnv("TEST_SECRETS_KEY", "TEST_SECRETS_PREVIOUS"); w != nil || err != nil {
+		t.Fatalf("unset key = %v, %v, want nil, nil", w, err)
+	}
+
+	t.Setenv("TEST_SECRETS_KEY", base64.StdEncoding.EncodeToString(current))
+	t.Setenv("TEST_SECRETS_PREVIOUS", " "+base64.StdEncoding.EncodeToString(previous)+", ")
+	w, err := LocalKeyWrapperFromEnv("TEST_SECRETS_KEY", "TEST_SECRETS_PREVIOUS")
+	if err != nil {
+		t.Fatalf("LocalKeyWrapperFromEnv: %v", err)
+	}
+	if w.KeyID() != localKeyID(current) || len(w.keys) != 2 || w.keys[1].id != localKeyID(previous) {
+		t.Errorf("keys = %+v, want the current key then the previous one", w.keys)
+	}
+
+	t.Setenv("TEST_SECRETS_KEY", "not base64!")
+	if _, err := LocalKeyWrapperFromEnv("TEST_SECRETS_KEY", ""); err == nil {
+		t.Error("invalid base64: expected an error")
+	}
+}
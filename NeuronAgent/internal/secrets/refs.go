@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ReferenceScheme prefixes references to secrets in tool handler configs,
+// as in {"auth": {"token": "secret://github_token"}}
+const ReferenceScheme = "secret://"
+
+// minRedactedLength is the length below which resolved values are not
+// redacted from tool output, since short values would mask unrelated text
+const minRedactedLength = 4
+
+// NamePattern matches valid secret names
+var NamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,99}$`)
+
+// referencePattern matches references anywhere in a string, so a reference
+// may be embedded as in "Bearer secret://api_key"
+var referencePattern = regexp.MustCompile(`secret://([A-Za-z][A-Za-z0-9_-]{0,99})`)
+
+// IsReference reports whether s is exactly one reference to a secret
+func IsReference(s string) bool {
+	m := referencePattern.FindStringIndex(s)
+	return m != nil && m[0] == 0 && m[1] == len(s)
+}
+
+// References returns the sorted names of the secrets referenced by the
+// strings in v, which is a decoded JSON value
+func References(v interface{}) []string {
+	seen := map[string]bool{}
+	walkStrings(v, func(s string) string {
+		for _, m := range referencePattern.FindAllStringSubmatch(s, -1) {
+			seen[m[1]] = true
+		}
+		return s
+	})
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns a copy of v, a decoded JSON value, with references
+// replaced by the values lookup returns, and the values it substituted
+func Resolve(ctx context.Context, v interface{}, lookup func(ctx context.Context, name string) (string, error)) (interface{}, []string, error) {
+	resolved := map[string]string{}
+	var lookupErr error
+	out := walkStrings(v, func(s string) string {
+		if lookupErr != nil || !strings.Contains(s, ReferenceScheme) {
+			return s
+		}
+		return referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+			name := ref[len(ReferenceScheme):]
+			if value, ok := resolved[name]; ok {
+				return value
+			}
+			if lookupErr != nil {
+				return ref
+			}
+			value, err := lookup(ctx, name)
+			if err != nil {
+				lookupErr = err
+				return ref
+			}
+			resolved[name] = value
+			return value
+		})
+	})
+	if lookupErr != nil {
+		return nil, nil, lookupErr
+	}
+	values := make([]string, 0, len(resolved))
+	for _, value := range resolved {
+		values = append(values, value)
+	}
+	return out, values, nil
+}
+
+// Redact replaces the values in s with "[REDACTED]"
+func Redact(s string, values []string) string {
+	for _, value := range values {
+		if len(value) >= minRedactedLength {
+			s = strings.ReplaceAll(s, value, "[REDACTED]")
+		}
+	}
+	return s
+}
+
+// walkStrings returns a copy of v with every string value replaced by fn;
+// map keys are left untouched
+func walkStrings(v interface{}, fn func(string) string) interface{} {
+	switch val := v.(type) {
+	case string:
+		return fn(val)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = walkStrings(item, fn)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = walkStrings(item, fn)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	config := map[string]interface{}{
+		"url": "https://api.example.com",
+		"auth": map[string]interface{}{
+			"token": "secret://github_token",
+		},
+		"headers": []interface{}{
+			"Authorization: Bearer secret://api_key",
+			"X-Both: secret://api_key/secret://github_token",
+		},
+		"secret://not_a_value": "keys are not references",
+		"retries":              float64(3),
+	}
+	if got, want := References(config), []string{"api_key", "github_token"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("References = %v, want %v", got, want)
+	}
+
+	if !IsReference("secret://github_token") {
+		t.Error("IsReference(secret://github_token) = false")
+	}
+	for _, s := range []string{"Bearer secret://api_key", "secret://api_key ", "secret://1abc", "github_token"} {
+		if IsReference(s) {
+			t.Errorf("IsReference(%q) = true", s)
+		}
+	}
+}
+
+func TestResolveEmbeddedReferences(t *testing.T) {
+	values := map[string]string{"api_key": "sk-live-1234", "github_token": "ghp_abcd"}
+	lookups := 0
+	lookup := func(ctx context.Context, name string) (string, error) {
+		lookups++
+		value, ok := values[name]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return value, nil
+	}
+	config := map[string]interface{}{
+		"auth": map[string]interface{}{"token": "secret://github_token"},
+		"headers": []interface{}{
+			"Bearer secret://api_key",
+			"secret://api_key:secret://github_token",
+		},
+		"timeout": float64(30),
+	}
+
+	out, substituted, err := Resolve(context.Background(), config, lookup)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	want := map[string]interface{}{
+		"auth": map[string]interface{}{"token": "ghp_abcd"},
+		"headers": []interface{}{
+			"Bearer sk-live-1234",
+			"sk-live-1234:ghp_abcd",
+		},
+		"timeout": float64(30),
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("Resolve = %#v, want %#v", out, want)
+	}
+	sort.Strings(substituted)
+	if wantValues := []string{"ghp_abcd", "sk-live-1234"}; !reflect.DeepEqual(substituted, wantValues) {
+		t.Errorf("substituted = %v, want %v", substituted, wantValues)
+	}
+	if lookups != 2 {
+		t.Errorf("lookup called %d times, want once per secret", lookups)
+	}
+	// The input is not modified
+	if config["auth"].(map[string]interface{})["token"] != "secret://github_token" {
+		t.Error("Resolve modified its input")
+	}
+
+	if _, _, err := Resolve(context.Background(), map[string]interface{}{"token": "Bearer secret://missing"}, lookup); err == nil {
+		t.Error("unknown secret: expected an error")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	values := []string{"sk-live-1234", "ghp_abcd", "ab"}
+	got := Redact(`request failed: {"Authorization":"Bearer sk-live-1234","token":"ghp_abcd"} ab`, values)
+	want := `request failed: {"Authorization":"Bearer [REDACTED]","token":"[REDACTED]"} ab`
+	if got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+	if got := Redact("nothing to hide", nil); got != "nothing to hide" {
+		t.Errorf("Redact without values = %q", got)
+	}
+}
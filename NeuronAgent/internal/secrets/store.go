@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// MaxValueBytes bounds the size of a secret value
+const MaxValueBytes = 64 * 1024
+
+// Store keeps secrets in the database, each encrypted with its own data key
+// wrapped by the master key of a KeyWrapper, so values are never stored or
+// returned in the clear
+type Store struct {
+	queries *db.Queries
+	wrapper KeyWrapper
+}
+
+// NewStore creates a secrets store encrypting with wrapper
+func NewStore(queries *db.Queries, wrapper KeyWrapper) *Store {
+	return &Store{queries: queries, wrapper: wrapper}
+}
+
+// KeyID names the master key new values are encrypted with
+func (s *Store) KeyID() string {
+	return s.wrapper.KeyID()
+}
+
+// Create encrypts value and stores it as the secret name. It returns an
+// error wrapping db.ErrAlreadyExists if the secret exists.
+func (s *Store) Create(ctx context.Context, name string, description *string, value string) (*db.Secret, error) {
+	sealed, err := seal(ctx, s.wrapper, name, []byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("secret encryption failed: secret_name='%s', error=%w", name, err)
+	}
+	secret := &db.Secret{
+		Name:        name,
+		Description: description,
+		Ciphertext:  sealed.ciphertext,
+		WrappedKey:  sealed.wrappedKey,
+		KeyID:       sealed.keyID,
+	}
+	if err := s.queries.CreateSecret(ctx, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Update replaces the value of the secret name when value is not nil, and
+// its description when description is not nil
+func (s *Store) Update(ctx context.Context, name string, description *string, value *string) (*db.Secret, error) {
+	secret, err := s.queries.GetSecret(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if description != nil {
+		secret.Description = description
+	}
+	if value != nil {
+		sealed, err := seal(ctx, s.wrapper, name, []byte(*value))
+		if err != nil {
+			return nil, fmt.Errorf("secret encryption failed: secret_name='%s', error=%w", name, err)
+		}
+		secret.Ciphertext = sealed.ciphertext
+		secret.WrappedKey = sealed.wrappedKey
+		secret.KeyID = sealed.keyID
+	}
+	if err := s.queries.UpdateSecret(ctx, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Resolve returns the decrypted value of the secret name
+func (s *Store) Resolve(ctx context.Context, name string) (string, error) {
+	secret, err := s.queries.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	value, err := open(ctx, s.wrapper, name, &sealedValue{
+		ciphertext: secret.Ciphertext,
+		wrappedKey: secret.WrappedKey,
+		keyID:      secret.KeyID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("secret decryption failed: secret_name='%s', key_id='%s', error=%w", name, secret.KeyID, err)
+	}
+	return string(value), nil
+}
+
+// RotationResult reports a master key rotation
+type RotationResult struct {
+	KeyID     string   `json:"key_id"`
+	Rewrapped int      `json:"rewrapped"`
+	Current   int      `json:"current"`
+	Failed    []string `json:"failed"`
+}
+
+// RotateKeys rewraps the data keys of secrets wrapped by an older master key
+// with the current one. Values are not re-encrypted, so secrets stay
+// readable throughout; once every secret is rewrapped the old master key can
+// be removed. Secrets updated during the rotation already use the current
+// key and are skipped.
+func (s *Store) RotateKeys(ctx context.Context) (*RotationResult, error) {
+	secrets, err := s.queries.ListSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &RotationResult{KeyID: s.wrapper.KeyID(), Failed: []string{}}
+	for i := range secrets {
+		secret := &secrets[i]
+		if secret.KeyID == result.KeyID {
+			result.Current++
+			continue
+		}
+		if err := s.rewrap(ctx, secret); err != nil {
+			if errors.Is(err, db.ErrVersionConflict) {
+				result.Current++
+				continue
+			}
+			metrics.Logger().Warn().Err(err).
+				Str("secret_name", secret.Name).
+				Str("key_id", secret.KeyID).
+				Msg("Failed to rewrap secret")
+			result.Failed = append(result.Failed, secret.Name)
+			continue
+		}
+		result.Rewrapped++
+	}
+	return result, nil
+}
+
+func (s *Store) rewrap(ctx context.Context, secret *db.Secret) error {
+	dataKey, err := s.wrapper.Unwrap(ctx, secret.KeyID, secret.WrappedKey)
+	if err != nil {
+		return err
+	}
+	defer clear(dataKey)
+	wrapped, err := s.wrapper.Wrap(ctx, dataKey)
+	if err != nil {
+		return err
+	}
+	rewrapped := *secret
+	rewrapped.WrappedKey = wrapped
+	rewrapped.KeyID = s.wrapper.KeyID()
+	return s.queries.RewrapSecret(ctx, &rewrapped)
+}
@@ -0,0 +1,135 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	vaultKeyIDPrefix    = "vault-transit:"
+	defaultVaultTimeout = 10 * time.Second
+	maxVaultResponse    = 1 << 20
+)
+
+// VaultTransitKeyWrapper wraps data keys with a key of the transit secrets
+// engine of HashiCorp Vault, or of a KMS exposing the same API, so the master
+// key never leaves it. Keys rotated in Vault keep decrypting what their
+// earlier versions encrypted.
+type VaultTransitKeyWrapper struct {
+	address string
+	mount   string
+	key     string
+	token   string
+	client  *http.Client
+}
+
+// NewVaultTransitKeyWrapper creates a key wrapper using the transit key
+// named key, mounted at mount (default "transit") on the Vault server at
+// address, authenticated with token
+func NewVaultTransitKeyWrapper(address, mount, key, token string, timeout time.Duration) (*VaultTransitKeyWrapper, error) {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("vault address must be an absolute http or https URL, got '%s'", address)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("vault transit key name is required")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault token is required")
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	if timeout <= 0 {
+		timeout = defaultVaultTimeout
+	}
+	return &VaultTransitKeyWrapper{
+		address: strings.TrimRight(address, "/"),
+		mount:   strings.Trim(mount, "/"),
+		key:     key,
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// KeyID names the transit key
+func (v *VaultTransitKeyWrapper) KeyID() string {
+	return vaultKeyIDPrefix + v.key
+}
+
+// Wrap encrypts a data key with the transit key
+func (v *VaultTransitKeyWrapper) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err := v.call(ctx, "encrypt", v.key, map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data.Ciphertext == "" {
+		return nil, fmt.Errorf("vault returned no ciphertext")
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+// Unwrap decrypts a data key wrapped by a transit key
+func (v *VaultTransitKeyWrapper) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := strings.CutPrefix(keyID, vaultKeyIDPrefix)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("master key '%s' is not a vault transit key", keyID)
+	}
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := v.call(ctx, "decrypt", key, map[string]string{"ciphertext": string(wrapped)}, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+// call posts body to the transit endpoint operation/key and decodes the
+// response into out
+func (v *VaultTransitKeyWrapper) call(ctx context.Context, operation, key string, body map[string]string, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", v.address, v.mount, operation, url.PathEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", v.token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault transit %s failed: key='%s', error=%w", operation, key, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxVaultResponse))
+	if err != nil {
+		return fmt.Errorf("vault transit %s failed: key='%s', error=%w", operation, key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(data, &vaultErr)
+		return fmt.Errorf("vault transit %s failed: key='%s', status=%d, errors=%v", operation, key, resp.StatusCode, vaultErr.Errors)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("vault transit %s returned an invalid response: key='%s', error=%w", operation, key, err)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// ArchiveFormatVersion is the version written by Export and the only version
+// accepted by Import
+const ArchiveFormatVersion = 1
+
+// Archive is a portable snapshot of a session: its agent definition,
+// metadata, full message history (including tool calls and tool results)
+// and the memory chunks derived from it
+type Archive struct {
+	FormatVersion int                   `json:"format_version"`
+	ExportedAt    time.Time             `json:"exported_at"`
+	Agent         ArchivedAgent         `json:"agent"`
+	Session       ArchivedSession       `json:"session"`
+	Messages      []ArchivedMessage     `json:"messages"`
+	MemoryChunks  []ArchivedMemoryChunk `json:"memory_chunks"`
+}
+
+type ArchivedAgent struct {
+	ID           uuid.UUID              `json:"id"`
+	Name         string                 `json:"name"`
+	Description  *string                `json:"description,omitempty"`
+	SystemPrompt string                 `json:"system_prompt"`
+	ModelName    string                 `json:"model_name"`
+	MemoryTable  *string                `json:"memory_table,omitempty"`
+	EnabledTools []string               `json:"enabled_tools"`
+	Config       map[string]interface{} `json:"config"`
+}
+
+type ArchivedSession struct {
+	ID             uuid.UUID              `json:"id"`
+	ExternalUserID *string                `json:"external_user_id,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata"`
+	CreatedAt      time.Time              `json:"created_at"`
+	LastActivityAt time.Time              `json:"last_activity_at"`
+}
+
+// ArchivedMessage keeps the original message ID so memory chunks can refer
+// to it; IDs are reassigned on import
+type ArchivedMessage struct {
+	ID         int64                  `json:"id"`
+	Role       string                 `json:"role"`
+	Content    string                 `json:"content"`
+	ToolName   *string                `json:"tool_name,omitempty"`
+	ToolCallID *string                `json:"tool_call_id,omitempty"`
+	TokenCount *int                   `json:"token_count,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type ArchivedMemoryChunk struct {
+	MessageID       *int64                 `json:"message_id,omitempty"`
+	Content         string                 `json:"content"`
+	Embedding       []float32              `json:"embedding"`
+	ImportanceScore float64                `json:"importance_score"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+// ImportOptions controls which local agent an imported session is attached to.
+// Resolution order: AgentID, AgentMapping[archived agent ID], an agent with
+// the archived ID, an agent with the archived name, and finally a new agent
+// created from the archived definition when CreateAgent is set.
+type ImportOptions struct {
+	AgentID      *uuid.UUID
+	AgentMapping map[uuid.UUID]uuid.UUID
+	CreateAgent  bool
+}
+
+type ImportResult struct {
+	SessionID            uuid.UUID `json:"session_id"`
+	AgentID              uuid.UUID `json:"agent_id"`
+	AgentCreated         bool      `json:"agent_created"`
+	MessagesImported     int       `json:"messages_imported"`
+	MemoryChunksImported int       `json:"memory_chunks_imported"`
+	MemoryChunksSkipped  int       `json:"memory_chunks_skipped"`
+}
+
+// Archiver exports sessions to archives and imports them back
+type Archiver struct {
+	queries *db.Queries
+}
+
+func NewArchiver(queries *db.Queries) *Archiver {
+	return &Archiver{queries: queries}
+}
+
+// Export builds an archive of the given session
+func (a *Archiver) Export(ctx context.Context, sessionID uuid.UUID) (*Archive, error) {
+	sess, err := a.queries.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session export failed: session_id='%s', error=%w", sessionID.String(), err)
+	}
+
+	agent, err := a.queries.GetAgentByID(ctx, sess.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("session export failed: session_id='%s', agent_id='%s', error=%w",
+			sessionID.String(), sess.AgentID.String(), err)
+	}
+
+	messages, err := a.queries.GetAllMessages(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session export failed: session_id='%s', stage='messages', error=%w", sessionID.String(), err)
+	}
+
+	chunks, err := a.queries.ListMemoryChunksBySession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session export failed: session_id='%s', stage='memory_chunks', error=%w", sessionID.String(), err)
+	}
+
+	archive := &Archive{
+		FormatVersion: ArchiveFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Agent: ArchivedAgent{
+			ID:           agent.ID,
+			Name:         agent.Name,
+			Description:  agent.Description,
+			SystemPrompt: agent.SystemPrompt,
+			ModelName:    agent.ModelName,
+			MemoryTable:  agent.MemoryTable,
+			EnabledTools: []string(agent.EnabledTools),
+			Config:       agent.Config.ToMap(),
+		},
+		Session: ArchivedSession{
+			ID:             sess.ID,
+			ExternalUserID: sess.ExternalUserID,
+			Metadata:       sess.Metadata.ToMap(),
+			CreatedAt:      sess.CreatedAt,
+			LastActivityAt: sess.LastActivityAt,
+		},
+		Messages:     make([]ArchivedMessage, len(messages)),
+		MemoryChunks: make([]ArchivedMemoryChunk, len(chunks)),
+	}
+
+	for i, m := range messages {
+		archive.Messages[i] = ArchivedMessage{
+			ID:         m.ID,
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolName:   m.ToolName,
+			ToolCallID: m.ToolCallID,
+			TokenCount: m.TokenCount,
+			Metadata:   m.Metadata.ToMap(),
+			CreatedAt:  m.CreatedAt,
+		}
+	}
+
+	for i, c := range chunks {
+		archive.MemoryChunks[i] = ArchivedMemoryChunk{
+			MessageID:       c.MessageID,
+			Content:         c.Content,
+			Embedding:       c.Embedding,
+			ImportanceScore: c.ImportanceScore,
+			Metadata:        c.Metadata.ToMap(),
+			CreatedAt:       c.CreatedAt,
+		}
+	}
+
+	return archive, nil
+}
+
+// Validate checks that an archive can be imported
+func (ar *Archive) Validate() error {
+	if ar.FormatVersion != ArchiveFormatVersion {
+		return fmt.Errorf("unsupported archive format_version %d (supported: %d)", ar.FormatVersion, ArchiveFormatVersion)
+	}
+	if ar.Agent.Name == "" && ar.Agent.ID == uuid.Nil {
+		return fmt.Errorf("archive agent must have an id or a name")
+	}
+	for i, m := range ar.Messages {
+		if m.Role == "" {
+			return fmt.Errorf("archive message %d has no role", i)
+		}
+	}
+	return nil
+}
+
+// Import restores an archive as a new session. The session, its messages and
+// memory chunks receive new IDs; timestamps are preserved.
+func (a *Archiver) Import(ctx context.Context, archive *Archive, opts ImportOptions) (*ImportResult, error) {
+	if err := archive.Validate(); err != nil {
+		return nil, fmt.Errorf("session import failed: %w", err)
+	}
+
+	agent, create, err := a.resolveAgent(ctx, archive, opts)
+	if err != nil {
+		return nil, fmt.Errorf("session import failed: archived_agent_id='%s', archived_agent_name='%s', error=%w",
+			archive.Agent.ID.String(), archive.Agent.Name, err)
+	}
+
+	imp := &db.SessionImport{
+		Agent:       agent,
+		CreateAgent: create,
+		Session: &db.Session{
+			ExternalUserID: archive.Session.ExternalUserID,
+			Metadata:       nonNilMap(archive.Session.Metadata),
+			CreatedAt:      timeOrNow(archive.Session.CreatedAt),
+			LastActivityAt: archive.Session.LastActivityAt,
+		},
+		Messages:     make([]db.Message, len(archive.Messages)),
+		MemoryChunks: make([]db.MemoryChunk, len(archive.MemoryChunks)),
+	}
+
+	for i, m := range archive.Messages {
+		imp.Messages[i] = db.Message{
+			ID:         m.ID,
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolName:   m.ToolName,
+			ToolCallID: m.ToolCallID,
+			TokenCount: m.TokenCount,
+			Metadata:   nonNilMap(m.Metadata),
+			CreatedAt:  timeOrNow(m.CreatedAt),
+		}
+	}
+
+	for i, c := range archive.MemoryChunks {
+		imp.MemoryChunks[i] = db.MemoryChunk{
+			MessageID:       c.MessageID,
+			Content:         c.Content,
+			Embedding:       c.Embedding,
+			ImportanceScore: c.ImportanceScore,
+			Metadata:        nonNilMap(c.Metadata),
+			CreatedAt:       timeOrNow(c.CreatedAt),
+		}
+	}
+
+	if err := a.queries.ImportSession(ctx, imp); err != nil {
+		return nil, err
+	}
+
+	return &ImportResult{
+		SessionID:            imp.Session.ID,
+		AgentID:              imp.Agent.ID,
+		AgentCreated:         create,
+		MessagesImported:     len(imp.Messages),
+		MemoryChunksImported: imp.MemoryChunksImported,
+		MemoryChunksSkipped:  imp.MemoryChunksSkipped,
+	}, nil
+}
+
+// resolveAgent finds the local agent for an archive. The returned bool is
+// true when the agent does not exist yet and must be created.
+func (a *Archiver) resolveAgent(ctx context.Context, archive *Archive, opts ImportOptions) (*db.Agent, bool, error) {
+	if opts.AgentID != nil {
+		agent, err := a.queries.GetAgentByID(ctx, *opts.AgentID)
+		if err != nil {
+			return nil, false, fmt.Errorf("target agent_id='%s' not found: %w", opts.AgentID.String(), err)
+		}
+		return agent, false, nil
+	}
+
+	if mapped, ok := opts.AgentMapping[archive.Agent.ID]; ok {
+		agent, err := a.queries.GetAgentByID(ctx, mapped)
+		if err != nil {
+			return nil, false, fmt.Errorf("mapped agent_id='%s' not found: %w", mapped.String(), err)
+		}
+		return agent, false, nil
+	}
+
+	if archive.Agent.ID != uuid.Nil {
+		if agent, err := a.queries.GetAgentByID(ctx, archive.Agent.ID); err == nil {
+			return agent, false, nil
+		}
+	}
+
+	if archive.Agent.Name != "" {
+		if agent, err := a.queries.GetAgentByName(ctx, archive.Agent.Name); err == nil {
+			return agent, false, nil
+		}
+	}
+
+	if !opts.CreateAgent {
+		return nil, false, fmt.Errorf("no matching agent found; pass agent_id, agent_mapping or create_agent")
+	}
+	if strings.TrimSpace(archive.Agent.Name) == "" || archive.Agent.ModelName == "" || archive.Agent.SystemPrompt == "" {
+		return nil, false, fmt.Errorf("archived agent definition is incomplete: name, model_name and system_prompt are required to create it")
+	}
+
+	enabledTools := archive.Agent.EnabledTools
+	if enabledTools == nil {
+		enabledTools = []string{}
+	}
+	return &db.Agent{
+		Name:         archive.Agent.Name,
+		Description:  archive.Agent.Description,
+		SystemPrompt: archive.Agent.SystemPrompt,
+		ModelName:    archive.Agent.ModelName,
+		MemoryTable:  archive.Agent.MemoryTable,
+		EnabledTools: enabledTools,
+		Config:       nonNilMap(archive.Agent.Config),
+	}, true, nil
+}
+
+func nonNilMap(m map[string]interface{}) db.JSONBMap {
+	if m == nil {
+		return make(db.JSONBMap)
+	}
+	return db.FromMap(m)
+}
+
+func timeOrNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
@@ -5,23 +5,26 @@ import (
 	"time"
 
 	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
 )
 
+// CleanupService periodically applies session retention policies to all
+// agents
 type CleanupService struct {
-	queries   *db.Queries
-	interval  time.Duration
-	maxAge    time.Duration
-	ctx       context.Context
-	cancel    context.CancelFunc
-	done      chan struct{}
+	queries  *db.Queries
+	retainer *Retainer
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
 }
 
-func NewCleanupService(queries *db.Queries, interval, maxAge time.Duration) *CleanupService {
+func NewCleanupService(queries *db.Queries, retainer *Retainer, interval time.Duration) *CleanupService {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &CleanupService{
 		queries:  queries,
+		retainer: retainer,
 		interval: interval,
-		maxAge:   maxAge,
 		ctx:      ctx,
 		cancel:   cancel,
 		done:     make(chan struct{}),
@@ -33,7 +36,7 @@ func (s *CleanupService) Start() {
 	go s.run()
 }
 
-// Stop stops the cleanup service
+// Stop stops the cleanup service and waits for a running pass to finish
 func (s *CleanupService) Stop() {
 	s.cancel()
 	<-s.done
@@ -59,29 +62,23 @@ func (s *CleanupService) run() {
 }
 
 func (s *CleanupService) cleanup() {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Minute)
 	defer cancel()
 
-	// Delete sessions older than maxAge
-	cutoffTime := time.Now().Add(-s.maxAge)
-	
-	// Get all agents to check their sessions
 	agents, err := s.queries.ListAgents(ctx)
 	if err != nil {
 		return
 	}
 
-	for _, agent := range agents {
-		sessions, err := s.queries.ListSessions(ctx, agent.ID, 1000, 0)
-		if err != nil {
-			continue
+	for i := range agents {
+		if ctx.Err() != nil {
+			return
 		}
-
-		for _, session := range sessions {
-			if session.LastActivityAt.Before(cutoffTime) {
-				_ = s.queries.DeleteSession(ctx, session.ID)
-			}
+		// Errors are per agent; a bad policy on one agent must not stop the rest
+		if _, err := s.retainer.ApplyAgent(ctx, &agents[i]); err != nil {
+			metrics.Logger().Error().Err(err).
+				Str("agent_id", agents[i].ID.String()).
+				Msg("Session retention failed")
 		}
 	}
 }
-
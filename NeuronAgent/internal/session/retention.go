@@ -0,0 +1,238 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// retentionBatchSize bounds how many sessions of an agent one pass archives
+const retentionBatchSize = 200
+
+// RetentionPolicy bounds how long an agent keeps sessions, measured from a
+// session's last activity. The server configuration sets the defaults, and
+// the "session_retention" object of the agent config overrides them:
+//
+//	"session_retention": {
+//	  "archive_after_hours": 720,  // idle sessions are moved to sessions_archive
+//	  "purge_after_hours": 8760    // idle sessions, live or archived, are deleted
+//	}
+//
+// Zero disables the corresponding step. Purging runs first, so with
+// purge_after at or below archive_after sessions are deleted unarchived.
+type RetentionPolicy struct {
+	ArchiveAfter      time.Duration `json:"-"`
+	ArchiveAfterHours float64       `json:"archive_after_hours"`
+	PurgeAfter        time.Duration `json:"-"`
+	PurgeAfterHours   float64       `json:"purge_after_hours"`
+}
+
+// NewRetentionPolicy creates a policy from durations
+func NewRetentionPolicy(archiveAfter, purgeAfter time.Duration) RetentionPolicy {
+	return RetentionPolicy{
+		ArchiveAfter:      archiveAfter,
+		ArchiveAfterHours: archiveAfter.Hours(),
+		PurgeAfter:        purgeAfter,
+		PurgeAfterHours:   purgeAfter.Hours(),
+	}
+}
+
+// Enabled reports whether the policy archives or purges anything
+func (p *RetentionPolicy) Enabled() bool {
+	return p.ArchiveAfter > 0 || p.PurgeAfter > 0
+}
+
+// ParseRetentionPolicy returns defaults overridden by the "session_retention"
+// object of an agent config
+func ParseRetentionPolicy(config map[string]interface{}, defaults RetentionPolicy) (*RetentionPolicy, error) {
+	policy := defaults
+	raw, ok := config["session_retention"]
+	if !ok || raw == nil {
+		return &policy, nil
+	}
+	settings, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("session_retention must be an object, got %T", raw)
+	}
+
+	hours := func(key string, into *float64, duration *time.Duration) error {
+		v, ok := settings[key]
+		if !ok {
+			return nil
+		}
+		h, ok := v.(float64)
+		if !ok || h < 0 {
+			return fmt.Errorf("session_retention.%s must be a non-negative number", key)
+		}
+		*into = h
+		*duration = time.Duration(h * float64(time.Hour))
+		return nil
+	}
+	if err := hours("archive_after_hours", &policy.ArchiveAfterHours, &policy.ArchiveAfter); err != nil {
+		return nil, err
+	}
+	if err := hours("purge_after_hours", &policy.PurgeAfterHours, &policy.PurgeAfter); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// RetentionResult reports what a retention pass did for an agent
+type RetentionResult struct {
+	AgentID        uuid.UUID        `json:"agent_id"`
+	Policy         *RetentionPolicy `json:"policy"`
+	Archived       int              `json:"archived"`
+	ArchiveSkipped int              `json:"archive_skipped"` // active again since they were read
+	Purged         int64            `json:"purged"`
+	PurgedArchived int64            `json:"purged_archived"`
+	// More is set when archiving stopped at the batch size; the rest are
+	// archived by later passes
+	More bool `json:"more"`
+}
+
+// Retainer enforces session retention policies
+type Retainer struct {
+	queries    *db.Queries
+	archiver   *Archiver
+	defaults   RetentionPolicy
+	archiveDir string
+}
+
+// NewRetainer creates a retainer applying defaults to agents without their
+// own policy. When archiveDir is set, each archived session is also written
+// there as <agent_id>/<session_id>.json.
+func NewRetainer(queries *db.Queries, defaults RetentionPolicy, archiveDir string) *Retainer {
+	return &Retainer{
+		queries:    queries,
+		archiver:   NewArchiver(queries),
+		defaults:   defaults,
+		archiveDir: archiveDir,
+	}
+}
+
+// Policy returns the retention policy of an agent
+func (r *Retainer) Policy(agent *db.Agent) (*RetentionPolicy, error) {
+	return ParseRetentionPolicy(agent.Config.ToMap(), r.defaults)
+}
+
+// ApplyAgent applies the agent's retention policy: sessions idle beyond
+// purge_after are deleted first, then sessions idle beyond archive_after
+// are archived
+func (r *Retainer) ApplyAgent(ctx context.Context, agent *db.Agent) (*RetentionResult, error) {
+	policy, err := r.Policy(agent)
+	if err != nil {
+		return nil, fmt.Errorf("session retention failed: agent_id='%s', agent_name='%s', error=%w",
+			agent.ID.String(), agent.Name, err)
+	}
+	result := &RetentionResult{AgentID: agent.ID, Policy: policy}
+
+	if policy.PurgeAfter > 0 {
+		cutoff := time.Now().Add(-policy.PurgeAfter)
+		live, archived, err := r.queries.PurgeSessions(ctx, agent.ID, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("session retention failed: agent_id='%s', stage='purge', purge_after='%s', error=%w",
+				agent.ID.String(), policy.PurgeAfter, err)
+		}
+		result.Purged, result.PurgedArchived = live, archived
+		if n := live + archived; n > 0 {
+			metrics.RecordSessionRetention(agent.ID.String(), "purged", n)
+		}
+	}
+
+	if policy.ArchiveAfter > 0 {
+		cutoff := time.Now().Add(-policy.ArchiveAfter)
+		sessions, err := r.queries.ListIdleSessions(ctx, agent.ID, cutoff, retentionBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("session retention failed: agent_id='%s', stage='archive', archive_after='%s', error=%w",
+				agent.ID.String(), policy.ArchiveAfter, err)
+		}
+		for i := range sessions {
+			if err := r.archive(ctx, &sessions[i]); err != nil {
+				if errors.Is(err, db.ErrVersionConflict) {
+					result.ArchiveSkipped++
+					continue
+				}
+				return result, fmt.Errorf("session retention failed: agent_id='%s', stage='archive', archived=%d, error=%w",
+					agent.ID.String(), result.Archived, err)
+			}
+			result.Archived++
+		}
+		result.More = len(sessions) == retentionBatchSize
+		if result.Archived > 0 {
+			metrics.RecordSessionRetention(agent.ID.String(), "archived", int64(result.Archived))
+		}
+	}
+
+	return result, nil
+}
+
+// ArchiveSession archives a session now, regardless of policy
+func (r *Retainer) ArchiveSession(ctx context.Context, sessionID uuid.UUID) error {
+	sess, err := r.queries.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if err := r.archive(ctx, sess); err != nil {
+		return err
+	}
+	metrics.RecordSessionRetention(sess.AgentID.String(), "archived", 1)
+	return nil
+}
+
+// archive exports sess and moves it to sessions_archive
+func (r *Retainer) archive(ctx context.Context, sess *db.Session) error {
+	archive, err := r.archiver.Export(ctx, sess.ID)
+	if err != nil {
+		return err
+	}
+	// Export reads the session again; archive only what it saw, so a
+	// message added in between keeps the session live
+	sess.LastActivityAt = archive.Session.LastActivityAt
+
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return fmt.Errorf("session archival failed: session_id='%s', stage='encode', error=%w", sess.ID.String(), err)
+	}
+	if r.archiveDir != "" {
+		if err := writeArchiveFile(r.archiveDir, sess, data); err != nil {
+			return fmt.Errorf("session archival failed: session_id='%s', stage='export', archive_dir='%s', error=%w",
+				sess.ID.String(), r.archiveDir, err)
+		}
+	}
+	return r.queries.ArchiveSession(ctx, sess, len(archive.Messages), data)
+}
+
+// writeArchiveFile writes data to <dir>/<agent_id>/<session_id>.json. The
+// file is written under a temporary name and renamed, so a partial archive
+// is never left under the final name.
+func writeArchiveFile(dir string, sess *db.Session, data []byte) error {
+	agentDir := filepath.Join(dir, sess.AgentID.String())
+	if err := os.MkdirAll(agentDir, 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(agentDir, ".session-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(agentDir, sess.ID.String()+".json"))
+}
@@ -0,0 +1,56 @@
+-- Model stubs for tests. They live in the neurondb_stub schema, which the
+-- harness puts first on the database's search_path, so they shadow the
+-- extension's functions of the same signature without touching them.
+CREATE SCHEMA IF NOT EXISTS neurondb_stub;
+
+-- A tiny embedding model: every word of the text is hashed to one of the
+-- 768 dimensions of the agent schema's embedding columns, and the counts are
+-- normalized. Equal texts get equal vectors and texts sharing words are
+-- close, so memory retrieval and the semantic cache behave like they do
+-- with a real model.
+CREATE OR REPLACE FUNCTION neurondb_stub.neurondb_embed(input text, model text DEFAULT NULL)
+RETURNS vector
+LANGUAGE sql IMMUTABLE AS $$
+    WITH words AS (
+        SELECT abs(hashtext(word)) % 768 AS dim
+        FROM regexp_split_to_table(lower(coalesce(input, '')), '[^a-z0-9]+') AS word
+        WHERE word <> ''
+    ),
+    counts AS (
+        SELECT d.dim, count(words.dim)::float8 AS n
+        FROM generate_series(0, 767) AS d(dim)
+        LEFT JOIN words ON words.dim = d.dim
+        GROUP BY d.dim
+    ),
+    norm AS (
+        SELECT greatest(sqrt(sum(n * n)), 1) AS length FROM counts
+    )
+    SELECT ('[' || string_agg((n / length)::text, ',' ORDER BY dim) || ']')::vector
+    FROM counts, norm
+$$;
+
+CREATE OR REPLACE FUNCTION neurondb_stub.neurondb_embed_batch(inputs text[], model text DEFAULT NULL)
+RETURNS vector[]
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT coalesce(array_agg(neurondb_stub.neurondb_embed(input, model) ORDER BY ord), '{}')
+    FROM unnest(inputs) WITH ORDINALITY AS t(input, ord)
+$$;
+
+-- Canned LLM responses. A prompt gets the response of the most recently
+-- added pattern it matches (SQL LIKE), or else an echo of its end.
+CREATE TABLE IF NOT EXISTS neurondb_stub.llm_responses (
+    id SERIAL PRIMARY KEY,
+    prompt_pattern TEXT NOT NULL,
+    response TEXT NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION neurondb_stub.neurondb_llm_generate(model text, prompt text, params jsonb DEFAULT '{}')
+RETURNS text
+LANGUAGE sql STABLE AS $$
+    SELECT coalesce(
+        (SELECT response FROM neurondb_stub.llm_responses
+         WHERE $2 LIKE prompt_pattern
+         ORDER BY id DESC
+         LIMIT 1),
+        'stub response: ' || right($2, 200))
+$$;
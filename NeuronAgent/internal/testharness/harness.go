@@ -0,0 +1,307 @@
+// Package testharness runs integration tests against a real NeuronDB. It
+// starts a NeuronDB container, applies the agent schema migrations and the
+// test's SQL fixtures, and replaces the embedding and LLM functions with
+// deterministic stubs, so the runtime, memory and tools can be tested end
+// to end without models.
+//
+// A test gets a container of its own with New:
+//
+//	h := testharness.New(t, testharness.Options{})
+//	agent, err := h.CreateAgent(ctx, &db.Agent{})
+//
+// Tests of a package can share one container started in TestMain, calling
+// Reset between them:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(testharness.Main(m, testharness.Options{}, &harness))
+//	}
+//
+// Tests are skipped when no Docker daemon is reachable. The image is
+// NEURONDB_TEST_IMAGE, or neurondb:cpu-pg17 as built by NeuronDB/docker.
+package testharness
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/tools"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// DefaultImage is the NeuronDB image used when neither Options.Image nor
+// NEURONDB_TEST_IMAGE is set
+const DefaultImage = "neurondb:cpu-pg17"
+
+// StubModel is the model name agents created by the harness use. Any name
+// works with the stubs; this one makes stubbed agents easy to spot.
+const StubModel = "stub"
+
+const (
+	databaseName = "neurondb"
+	databaseUser = "neurondb"
+	databasePass = "neurondb"
+)
+
+// ErrDockerUnavailable is returned by Start when no Docker daemon is
+// reachable
+var ErrDockerUnavailable = errors.New("docker is not available")
+
+//go:embed fixtures/stubs.sql
+var stubsSQL string
+
+// Options configure the NeuronDB a harness starts
+type Options struct {
+	// Image overrides the NeuronDB image
+	Image string
+	// Fixtures are SQL files run in order after the migrations, and again
+	// after every Reset
+	Fixtures []string
+}
+
+// Harness is a NeuronDB container with the agent schema applied
+type Harness struct {
+	DB         *db.DB
+	Queries    *db.Queries
+	ConnString string
+
+	container *postgres.PostgresContainer
+	fixtures  []string
+}
+
+// New starts a harness for one test and terminates it when the test ends.
+// The test is skipped when Docker is not available.
+func New(t *testing.T, opts Options) *Harness {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	h, err := Start(ctx, opts)
+	if errors.Is(err, ErrDockerUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("failed to start test harness: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := h.Close(context.Background()); err != nil {
+			t.Logf("failed to stop test harness: %v", err)
+		}
+	})
+	return h
+}
+
+// Main runs the tests of a package against one harness stored in *h,
+// terminating it afterwards, and returns the exit code for os.Exit. Without
+// Docker the tests run with *h nil, so they should skip themselves.
+func Main(m *testing.M, opts Options, h **Harness) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	harness, err := Start(ctx, opts)
+	cancel()
+	if err != nil && !errors.Is(err, ErrDockerUnavailable) {
+		fmt.Fprintf(os.Stderr, "failed to start test harness: %v\n", err)
+		return 1
+	}
+	if harness != nil {
+		*h = harness
+		defer harness.Close(context.Background())
+	}
+	return m.Run()
+}
+
+// Start starts a NeuronDB container, installs the model stubs and applies
+// the migrations and fixtures
+func Start(ctx context.Context, opts Options) (*Harness, error) {
+	if err := checkDocker(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
+
+	image := opts.Image
+	if image == "" {
+		image = os.Getenv("NEURONDB_TEST_IMAGE")
+	}
+	if image == "" {
+		image = DefaultImage
+	}
+
+	container, err := postgres.Run(ctx, image,
+		postgres.WithDatabase(databaseName),
+		postgres.WithUsername(databaseUser),
+		postgres.WithPassword(databasePass),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start NeuronDB container: image='%s', error=%w", image, err)
+	}
+
+	h := &Harness{container: container, fixtures: opts.Fixtures}
+	if err := h.setup(ctx); err != nil {
+		h.Close(context.Background())
+		return nil, err
+	}
+	return h, nil
+}
+
+// checkDocker fails when no Docker daemon is reachable. testcontainers
+// panics when it finds no Docker host at all.
+func checkDocker(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+	return provider.Health(ctx)
+}
+
+func (h *Harness) setup(ctx context.Context) error {
+	connStr, err := h.container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return fmt.Errorf("failed to get NeuronDB connection string: %w", err)
+	}
+	h.ConnString = connStr
+
+	// The stubs go first on the search path of every later connection
+	bootstrap, err := sqlx.ConnectContext(ctx, "postgres", connStr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NeuronDB: %w", err)
+	}
+	for _, stmt := range []string{
+		"CREATE EXTENSION IF NOT EXISTS neurondb",
+		stubsSQL,
+		fmt.Sprintf("ALTER DATABASE %s SET search_path = neurondb_stub, public", databaseName),
+	} {
+		if _, err := bootstrap.ExecContext(ctx, stmt); err != nil {
+			bootstrap.Close()
+			return fmt.Errorf("failed to install model stubs: %w", err)
+		}
+	}
+	bootstrap.Close()
+
+	database, err := db.NewDB(connStr, db.PoolConfig{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 5 * time.Minute,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open NeuronDB pool: %w", err)
+	}
+	h.DB = database
+
+	runner, err := db.NewEmbeddedMigrationRunner(database.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	if _, err := runner.Run(ctx); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	h.Queries = db.NewQueries(database.DB)
+	h.Queries.SetConnInfoFunc(database.GetConnInfoString)
+	return h.loadFixtures(ctx)
+}
+
+// loadFixtures runs the fixture files in order
+func (h *Harness) loadFixtures(ctx context.Context) error {
+	for _, path := range h.fixtures {
+		sql, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read fixture: path='%s', error=%w", path, err)
+		}
+		if _, err := h.DB.ExecContext(ctx, string(sql)); err != nil {
+			return fmt.Errorf("failed to load fixture: path='%s', error=%w", path, err)
+		}
+	}
+	return nil
+}
+
+// Reset empties the agent schema and the canned LLM responses, keeping the
+// applied migrations, and loads the fixtures again
+func (h *Harness) Reset(ctx context.Context) error {
+	var tables []string
+	err := h.DB.SelectContext(ctx, &tables, `
+		SELECT format('%I.%I', schemaname, tablename) FROM pg_tables
+		WHERE schemaname IN ('neurondb_agent', 'neurondb_stub') AND tablename <> 'schema_migrations'
+		ORDER BY 1`)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	if len(tables) > 0 {
+		if _, err := h.DB.ExecContext(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+			return fmt.Errorf("failed to empty tables: %w", err)
+		}
+	}
+	return h.loadFixtures(ctx)
+}
+
+// Close closes the pool and terminates the container
+func (h *Harness) Close(ctx context.Context) error {
+	if h.DB != nil {
+		h.DB.Close()
+	}
+	return testcontainers.TerminateContainer(h.container)
+}
+
+// NewRuntime returns a runtime on the harness database with the built-in
+// tools, wired like the server's. Each runtime has its own in-memory caches
+// and limits.
+func (h *Harness) NewRuntime() *agent.Runtime {
+	embedClient := neurondb.NewEmbeddingClient(h.DB.DB)
+	return agent.NewRuntime(h.DB, h.Queries, tools.NewRegistry(h.Queries, h.DB), embedClient)
+}
+
+// CreateAgent creates an agent, defaulting its name to a unique one, its
+// model to StubModel and its system prompt to a fixed one
+func (h *Harness) CreateAgent(ctx context.Context, a *db.Agent) (*db.Agent, error) {
+	if a.Name == "" {
+		a.Name = "test-agent-" + uuid.NewString()[:8]
+	}
+	if a.ModelName == "" {
+		a.ModelName = StubModel
+	}
+	if a.SystemPrompt == "" {
+		a.SystemPrompt = "You are a test agent."
+	}
+	if a.Config == nil {
+		a.Config = db.JSONBMap{}
+	}
+	if err := h.Queries.CreateAgent(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// CreateSession creates a session of the agent
+func (h *Harness) CreateSession(ctx context.Context, agentID uuid.UUID) (*db.Session, error) {
+	session := &db.Session{AgentID: agentID, Metadata: db.JSONBMap{}}
+	if err := h.Queries.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// StubLLMResponse makes the LLM stub answer prompts matching the SQL LIKE
+// pattern with response. Later patterns take precedence.
+func (h *Harness) StubLLMResponse(ctx context.Context, promptPattern, response string) error {
+	_, err := h.DB.ExecContext(ctx,
+		`INSERT INTO neurondb_stub.llm_responses (prompt_pattern, response) VALUES ($1, $2)`,
+		promptPattern, response)
+	if err != nil {
+		return fmt.Errorf("failed to stub LLM response: pattern='%s', error=%w", promptPattern, err)
+	}
+	return nil
+}
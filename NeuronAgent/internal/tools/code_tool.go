@@ -168,3 +168,7 @@ func (t *CodeTool) Validate(args map[string]interface{}, schema map[string]inter
 	return ValidateArgs(args, schema)
 }
 
+// ValidateTool checks that the tool declares the path argument
+func (t *CodeTool) ValidateTool(tool *db.Tool) error {
+	return requireArgument(tool, "path")
+}
@@ -0,0 +1,552 @@
+package tools
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP tool defaults
+const (
+	defaultHTTPTimeout     = 30 * time.Second
+	defaultHTTPBackoff     = 500 * time.Millisecond
+	maxHTTPAttempts        = 10
+	maxHTTPRetryAfter      = 30 * time.Second
+	httpErrorBodyPreview   = 500
+	defaultSignatureHeader = "X-Signature"
+)
+
+// defaultRetryStatus are the response codes retried when retry.on_status is
+// not set
+var defaultRetryStatus = []int{429, 502, 503, 504}
+
+// httpTemplateVar matches a {{name}} placeholder
+var httpTemplateVar = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// httpStatusRange is an inclusive range of response codes
+type httpStatusRange struct {
+	min, max int
+}
+
+// httpAuthConfig signs or authenticates requests. Credentials are either
+// secret:// references, resolved from the secrets store before the tool
+// runs, or read from the server's environment; they are never stored in the
+// tool in the clear.
+type httpAuthConfig struct {
+	Type            string // bearer, basic or hmac
+	Token           string // bearer, resolved from a secret reference
+	TokenEnv        string // bearer
+	Username        string // basic
+	Password        string // basic, resolved from a secret reference
+	PasswordEnv     string // basic
+	Secret          string // hmac, resolved from a secret reference
+	SecretEnv       string // hmac
+	Header          string // hmac signature header
+	Algorithm       string // hmac: sha256, sha1 or sha512
+	Prefix          string // hmac: prepended to the hex signature, e.g. "sha256="
+	TimestampHeader string // hmac: when set, "<unix seconds>.<body>" is signed
+}
+
+// httpRetryConfig controls retries of failed requests
+type httpRetryConfig struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	OnStatus    map[int]bool
+}
+
+// httpToolConfig is the parsed handler_config of an HTTP tool. A tool with a
+// url is declarative: the request is built from the config, with {{name}}
+// placeholders filled from the call's arguments. A tool without one takes
+// url, method, headers and body from the arguments.
+type httpToolConfig struct {
+	URL           string
+	Method        string
+	Query         map[string]string
+	Headers       map[string]string
+	Body          interface{} // string or JSON value template
+	Auth          *httpAuthConfig
+	AuthHost      string            // the only host sent Auth credentials
+	Extract       string            // JSONPath applied to the response
+	Fields        map[string]string // output field -> JSONPath
+	SuccessStatus []httpStatusRange
+	Retry         httpRetryConfig
+	Timeout       time.Duration
+}
+
+// parseHTTPToolConfig parses and validates handler_config
+func parseHTTPToolConfig(config map[string]interface{}) (*httpToolConfig, error) {
+	cfg := &httpToolConfig{
+		Method:        "GET",
+		SuccessStatus: []httpStatusRange{{200, 399}},
+		Retry:         httpRetryConfig{MaxAttempts: 1, Backoff: defaultHTTPBackoff},
+		Timeout:       defaultHTTPTimeout,
+	}
+
+	if v, ok := config["url"]; ok {
+		rawURL, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("handler_config.url must be a string")
+		}
+		// Placeholders are checked with a sample value, since they may
+		// stand for any part of the URL
+		u, err := neturl.Parse(httpTemplateVar.ReplaceAllString(rawURL, "x"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("handler_config.url must be an absolute http or https URL, got '%s'", rawURL)
+		}
+		cfg.URL = rawURL
+	}
+	if v, ok := config["method"]; ok {
+		method, ok := v.(string)
+		if !ok || !httpMethods[strings.ToUpper(method)] {
+			return nil, fmt.Errorf("handler_config.method must be one of GET, POST, PUT, PATCH, DELETE or HEAD, got %v", v)
+		}
+		cfg.Method = strings.ToUpper(method)
+	}
+
+	var err error
+	if cfg.Query, err = stringMap(config, "query"); err != nil {
+		return nil, err
+	}
+	if cfg.Headers, err = stringMap(config, "headers"); err != nil {
+		return nil, err
+	}
+	if v, ok := config["body"]; ok {
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("handler_config.body requires handler_config.url")
+		}
+		cfg.Body = v
+	}
+	if len(cfg.Query) > 0 && cfg.URL == "" {
+		return nil, fmt.Errorf("handler_config.query requires handler_config.url")
+	}
+
+	if v, ok := config["auth"]; ok {
+		auth, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("handler_config.auth must be an object")
+		}
+		if cfg.Auth, err = parseHTTPAuth(auth); err != nil {
+			return nil, err
+		}
+		// Without a fixed host the model would choose where the
+		// credentials are sent
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("handler_config.auth requires handler_config.url")
+		}
+		if cfg.AuthHost = fixedHost(cfg.URL); cfg.AuthHost == "" {
+			return nil, fmt.Errorf("handler_config.auth requires a url whose host has no {{placeholders}}, got '%s'", cfg.URL)
+		}
+	}
+
+	if v, ok := config["response"]; ok {
+		response, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("handler_config.response must be an object")
+		}
+		if err := parseHTTPResponseMapping(cfg, response); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := config["success_status"]; ok {
+		if cfg.SuccessStatus, err = parseStatusRanges(v, "handler_config.success_status"); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := config["retry"]; ok {
+		retry, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("handler_config.retry must be an object")
+		}
+		if err := parseHTTPRetry(&cfg.Retry, retry); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := config["timeout_ms"]; ok {
+		ms, ok := v.(float64)
+		if !ok || ms <= 0 {
+			return nil, fmt.Errorf("handler_config.timeout_ms must be a positive number")
+		}
+		cfg.Timeout = time.Duration(ms * float64(time.Millisecond))
+	}
+
+	return cfg, nil
+}
+
+// fixedHost returns the host of a url template, or "" when a placeholder
+// can change it
+func fixedHost(rawURL string) string {
+	a, errA := neturl.Parse(httpTemplateVar.ReplaceAllString(rawURL, "a"))
+	b, errB := neturl.Parse(httpTemplateVar.ReplaceAllString(rawURL, "b"))
+	if errA != nil || errB != nil || a.Host != b.Host {
+		return ""
+	}
+	return a.Host
+}
+
+func stringMap(config map[string]interface{}, key string) (map[string]string, error) {
+	v, ok := config[key]
+	if !ok {
+		return nil, nil
+	}
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("handler_config.%s must be an object of string values", key)
+	}
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("handler_config.%s.%s must be a string", key, name)
+		}
+		values[name] = s
+	}
+	return values, nil
+}
+
+func parseHTTPAuth(auth map[string]interface{}) (*httpAuthConfig, error) {
+	str := func(key string) string {
+		s, _ := auth[key].(string)
+		return s
+	}
+
+	cfg := &httpAuthConfig{Type: str("type")}
+	switch cfg.Type {
+	case "bearer":
+		cfg.Token, cfg.TokenEnv = str("token"), str("token_env")
+		if cfg.Token == "" && cfg.TokenEnv == "" {
+			return nil, fmt.Errorf("handler_config.auth.token or token_env is required for bearer auth")
+		}
+	case "basic":
+		cfg.Username, cfg.Password, cfg.PasswordEnv = str("username"), str("password"), str("password_env")
+		if cfg.Username == "" || (cfg.Password == "" && cfg.PasswordEnv == "") {
+			return nil, fmt.Errorf("handler_config.auth.username and password or password_env are required for basic auth")
+		}
+	case "hmac":
+		cfg.Secret, cfg.SecretEnv = str("secret"), str("secret_env")
+		if cfg.Secret == "" && cfg.SecretEnv == "" {
+			return nil, fmt.Errorf("handler_config.auth.secret or secret_env is required for hmac auth")
+		}
+		cfg.Header, cfg.Prefix, cfg.TimestampHeader = str("header"), str("prefix"), str("timestamp_header")
+		if cfg.Header == "" {
+			cfg.Header = defaultSignatureHeader
+		}
+		cfg.Algorithm = strings.ToLower(str("algorithm"))
+		if cfg.Algorithm == "" {
+			cfg.Algorithm = "sha256"
+		}
+		if hmacHash(cfg.Algorithm) == nil {
+			return nil, fmt.Errorf("handler_config.auth.algorithm must be sha256, sha1 or sha512, got '%s'", cfg.Algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("handler_config.auth.type must be bearer, basic or hmac, got '%s'", cfg.Type)
+	}
+	return cfg, nil
+}
+
+func hmacHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "sha256":
+		return sha256.New
+	case "sha1":
+		return sha1.New
+	case "sha512":
+		return sha512.New
+	}
+	return nil
+}
+
+func parseHTTPResponseMapping(cfg *httpToolConfig, response map[string]interface{}) error {
+	if v, ok := response["extract"]; ok {
+		path, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("handler_config.response.extract must be a JSONPath string")
+		}
+		if _, err := parseJSONPath(path); err != nil {
+			return fmt.Errorf("handler_config.response.extract: %w", err)
+		}
+		cfg.Extract = path
+	}
+	if v, ok := response["fields"]; ok {
+		raw, ok := v.(map[string]interface{})
+		if !ok || len(raw) == 0 {
+			return fmt.Errorf("handler_config.response.fields must be an object of JSONPath strings")
+		}
+		cfg.Fields = make(map[string]string, len(raw))
+		for name, p := range raw {
+			path, ok := p.(string)
+			if !ok {
+				return fmt.Errorf("handler_config.response.fields.%s must be a JSONPath string", name)
+			}
+			if _, err := parseJSONPath(path); err != nil {
+				return fmt.Errorf("handler_config.response.fields.%s: %w", name, err)
+			}
+			cfg.Fields[name] = path
+		}
+	}
+	if cfg.Extract != "" && cfg.Fields != nil {
+		return fmt.Errorf("handler_config.response takes extract or fields, not both")
+	}
+	return nil
+}
+
+// parseStatusRanges reads a list of response codes (404) and classes
+// ("2xx")
+func parseStatusRanges(v interface{}, path string) ([]httpStatusRange, error) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("%s must be a non-empty array of status codes or classes such as \"2xx\"", path)
+	}
+	ranges := make([]httpStatusRange, 0, len(list))
+	for _, item := range list {
+		switch s := item.(type) {
+		case float64:
+			if s < 100 || s > 599 || s != float64(int(s)) {
+				return nil, fmt.Errorf("%s has invalid status code %v", path, s)
+			}
+			ranges = append(ranges, httpStatusRange{int(s), int(s)})
+		case string:
+			class := strings.ToLower(s)
+			if len(class) != 3 || class[1:] != "xx" || class[0] < '1' || class[0] > '5' {
+				return nil, fmt.Errorf("%s has invalid status class '%s'", path, s)
+			}
+			base := int(class[0]-'0') * 100
+			ranges = append(ranges, httpStatusRange{base, base + 99})
+		default:
+			return nil, fmt.Errorf("%s has invalid entry %v", path, item)
+		}
+	}
+	return ranges, nil
+}
+
+func parseHTTPRetry(retry *httpRetryConfig, config map[string]interface{}) error {
+	if v, ok := config["max_attempts"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n > maxHTTPAttempts || n != float64(int(n)) {
+			return fmt.Errorf("handler_config.retry.max_attempts must be an integer from 1 to %d", maxHTTPAttempts)
+		}
+		retry.MaxAttempts = int(n)
+	}
+	if v, ok := config["backoff_ms"]; ok {
+		ms, ok := v.(float64)
+		if !ok || ms < 0 {
+			return fmt.Errorf("handler_config.retry.backoff_ms must be a non-negative number")
+		}
+		retry.Backoff = time.Duration(ms * float64(time.Millisecond))
+	}
+	codes := defaultRetryStatus
+	if v, ok := config["on_status"]; ok {
+		ranges, err := parseStatusRanges(v, "handler_config.retry.on_status")
+		if err != nil {
+			return err
+		}
+		codes = nil
+		for _, r := range ranges {
+			for code := r.min; code <= r.max; code++ {
+				codes = append(codes, code)
+			}
+		}
+	}
+	retry.OnStatus = make(map[int]bool, len(codes))
+	for _, code := range codes {
+		retry.OnStatus[code] = true
+	}
+	return nil
+}
+
+// isSuccess reports whether a response code counts as success
+func (c *httpToolConfig) isSuccess(status int) bool {
+	for _, r := range c.SuccessStatus {
+		if status >= r.min && status <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRetry reports whether a response code is retried
+func (c *httpToolConfig) shouldRetry(status int) bool {
+	if c.Retry.OnStatus == nil {
+		for _, code := range defaultRetryStatus {
+			if code == status {
+				return true
+			}
+		}
+		return false
+	}
+	return c.Retry.OnStatus[status]
+}
+
+// templateVars returns the argument names the config's templates use
+func (c *httpToolConfig) templateVars() []string {
+	seen := make(map[string]bool)
+	collect := func(s string) {
+		for _, m := range httpTemplateVar.FindAllStringSubmatch(s, -1) {
+			seen[m[1]] = true
+		}
+	}
+	collect(c.URL)
+	for _, v := range c.Query {
+		collect(v)
+	}
+	for _, v := range c.Headers {
+		collect(v)
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			collect(v)
+		case map[string]interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(c.Body)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandTemplate replaces each {{name}} in tmpl with args[name], passed
+// through escape. A placeholder without an argument is an error.
+func expandTemplate(tmpl string, args map[string]interface{}, escape func(string) string) (string, error) {
+	var missing string
+	out := httpTemplateVar.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := httpTemplateVar.FindStringSubmatch(m)[1]
+		value, ok := args[name]
+		if !ok || value == nil {
+			if missing == "" {
+				missing = name
+			}
+			return ""
+		}
+		s := templateValue(value)
+		if escape != nil {
+			s = escape(s)
+		}
+		return s
+	})
+	if missing != "" {
+		return "", fmt.Errorf("argument '%s' used in the request template is missing", missing)
+	}
+	return out, nil
+}
+
+func templateValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// expandJSONTemplate fills the placeholders of a JSON body template. A
+// string that is exactly one placeholder takes the argument's JSON value, so
+// numbers, booleans and objects keep their type.
+func expandJSONTemplate(v interface{}, args map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		if m := httpTemplateVar.FindStringSubmatch(v); m != nil && m[0] == v {
+			value, ok := args[m[1]]
+			if !ok {
+				return nil, fmt.Errorf("argument '%s' used in the request template is missing", m[1])
+			}
+			return value, nil
+		}
+		return expandTemplate(v, args, nil)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			expanded, err := expandJSONTemplate(item, args)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = expanded
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			expanded, err := expandJSONTemplate(item, args)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// apply authenticates req, whose body is body
+func (a *httpAuthConfig) apply(req *http.Request, body []byte) error {
+	secret := func(value, env string) (string, error) {
+		if value != "" {
+			return value, nil
+		}
+		value = os.Getenv(env)
+		if value == "" {
+			return "", fmt.Errorf("%s auth: environment variable %s is not set", a.Type, env)
+		}
+		return value, nil
+	}
+
+	switch a.Type {
+	case "bearer":
+		token, err := secret(a.Token, a.TokenEnv)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		password, err := secret(a.Password, a.PasswordEnv)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(a.Username, password)
+	case "hmac":
+		key, err := secret(a.Secret, a.SecretEnv)
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(hmacHash(a.Algorithm), []byte(key))
+		if a.TimestampHeader != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set(a.TimestampHeader, timestamp)
+			mac.Write([]byte(timestamp + "."))
+		}
+		mac.Write(body)
+		req.Header.Set(a.Header, a.Prefix+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return nil
+}
@@ -1,15 +1,19 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/secrets"
 )
 
 type HTTPTool struct {
@@ -19,23 +23,42 @@ type HTTPTool struct {
 
 func NewHTTPTool() *HTTPTool {
 	return &HTTPTool{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		// Each attempt is bounded by the tool's timeout_ms instead
+		client:  &http.Client{},
 		allowed: make(map[string]bool),
 	}
 }
 
+// httpRequest is a request built from a tool call, sent once per attempt
+type httpRequest struct {
+	method  string
+	url     string
+	headers map[string]string
+	body    []byte
+}
+
 func (t *HTTPTool) Execute(ctx context.Context, tool *db.Tool, args map[string]interface{}) (string, error) {
-	url, ok := args["url"].(string)
-	if !ok {
+	cfg, err := parseHTTPToolConfig(tool.HandlerConfig)
+	if err != nil {
+		return "", fmt.Errorf("HTTP tool configuration invalid: tool_name='%s', handler_type='http', error=%w",
+			tool.Name, err)
+	}
+
+	var request *httpRequest
+	if cfg.URL != "" {
+		request, err = buildDeclarativeRequest(cfg, args)
+	} else {
+		request, err = buildArgumentRequest(cfg, args)
+	}
+	if err != nil {
 		argKeys := make([]string, 0, len(args))
 		for k := range args {
 			argKeys = append(argKeys, k)
 		}
-		return "", fmt.Errorf("HTTP tool execution failed: tool_name='%s', handler_type='http', args_count=%d, arg_keys=[%v], validation_error='url parameter is required and must be a string'",
-			tool.Name, len(args), argKeys)
+		return "", fmt.Errorf("HTTP tool execution failed: tool_name='%s', handler_type='http', args_count=%d, arg_keys=[%v], validation_error='%v'",
+			tool.Name, len(args), argKeys, err)
 	}
+	url, method := request.url, request.method
 
 	// Check allowlist if configured
 	allowlistSize := len(t.allowed)
@@ -54,77 +77,294 @@ func (t *HTTPTool) Execute(ctx context.Context, tool *db.Tool, args map[string]i
 		}
 	}
 
-	method := "GET"
-	if m, ok := args["method"].(string); ok {
-		method = strings.ToUpper(m)
+	status, header, body, attempts, err := t.send(ctx, cfg, request)
+	if err != nil {
+		return "", fmt.Errorf("HTTP tool request execution failed: tool_name='%s', handler_type='http', method='%s', url='%s', headers_count=%d, body_size=%d, timeout=%v, attempts=%d, error=%w",
+			tool.Name, method, url, len(request.headers), len(request.body), cfg.Timeout, attempts, err)
 	}
-	
-	headerCount := 0
-	if headers, ok := args["headers"].(map[string]interface{}); ok {
-		headerCount = len(headers)
+
+	if !cfg.isSuccess(status) {
+		preview := string(body)
+		if len(preview) > httpErrorBodyPreview {
+			preview = preview[:httpErrorBodyPreview] + "..."
+		}
+		return "", fmt.Errorf("HTTP tool request returned an error status: tool_name='%s', handler_type='http', method='%s', url='%s', response_status=%d, attempts=%d, response_body='%s'",
+			tool.Name, method, url, status, attempts, preview)
+	}
+
+	// Format response
+	var result map[string]interface{}
+	if cfg.Extract != "" || cfg.Fields != nil {
+		data, err := mapHTTPResponse(cfg, body)
+		if err != nil {
+			return "", fmt.Errorf("HTTP tool response mapping failed: tool_name='%s', handler_type='http', method='%s', url='%s', response_status=%d, response_body_size=%d, error=%w",
+				tool.Name, method, url, status, len(body), err)
+		}
+		result = map[string]interface{}{
+			"status_code": status,
+			"data":        data,
+		}
+	} else {
+		result = map[string]interface{}{
+			"status_code": status,
+			"headers":     header,
+			"body":        string(body),
+		}
 	}
-	
-	bodySize := 0
-	if body, ok := args["body"].(string); ok {
-		bodySize = len(body)
+
+	jsonResult, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("HTTP tool response marshaling failed: tool_name='%s', handler_type='http', method='%s', url='%s', response_status=%d, response_body_size=%d, error=%w",
+			tool.Name, method, url, status, len(body), err)
 	}
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	return string(jsonResult), nil
+}
+
+// buildDeclarativeRequest builds the request of a tool with a configured
+// url. Arguments only fill the {{name}} placeholders of the config.
+func buildDeclarativeRequest(cfg *httpToolConfig, args map[string]interface{}) (*httpRequest, error) {
+	rawURL, err := expandTemplate(cfg.URL, args, neturl.PathEscape)
 	if err != nil {
-		return "", fmt.Errorf("HTTP tool request creation failed: tool_name='%s', handler_type='http', method='%s', url='%s', headers_count=%d, body_size=%d, timeout=%v, error=%w",
-			tool.Name, method, url, headerCount, bodySize, t.client.Timeout, err)
+		return nil, err
+	}
+	if len(cfg.Query) > 0 {
+		u, err := neturl.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("expanded url '%s' is invalid: %w", rawURL, err)
+		}
+		query := u.Query()
+		for name, tmpl := range cfg.Query {
+			value, err := expandTemplate(tmpl, args, nil)
+			if err != nil {
+				return nil, err
+			}
+			query.Set(name, value)
+		}
+		u.RawQuery = query.Encode()
+		rawURL = u.String()
 	}
 
-	// Add headers
+	request := &httpRequest{method: cfg.Method, url: rawURL, headers: make(map[string]string)}
+	for name, tmpl := range cfg.Headers {
+		value, err := expandTemplate(tmpl, args, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.headers[name] = value
+	}
+
+	switch body := cfg.Body.(type) {
+	case nil:
+	case string:
+		expanded, err := expandTemplate(body, args, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.body = []byte(expanded)
+	default:
+		expanded, err := expandJSONTemplate(body, args)
+		if err != nil {
+			return nil, err
+		}
+		if request.body, err = json.Marshal(expanded); err != nil {
+			return nil, fmt.Errorf("request body could not be encoded: %w", err)
+		}
+		if !hasHeader(request.headers, "Content-Type") {
+			request.headers["Content-Type"] = "application/json"
+		}
+	}
+	return request, nil
+}
+
+// buildArgumentRequest builds the request of a tool without a configured
+// url from the url, method, headers and body arguments
+func buildArgumentRequest(cfg *httpToolConfig, args map[string]interface{}) (*httpRequest, error) {
+	url, ok := args["url"].(string)
+	if !ok {
+		return nil, fmt.Errorf("url parameter is required and must be a string")
+	}
+	request := &httpRequest{method: cfg.Method, url: url, headers: make(map[string]string)}
+	if m, ok := args["method"].(string); ok {
+		request.method = strings.ToUpper(m)
+	}
+	for name, value := range cfg.Headers {
+		request.headers[name] = value
+	}
 	if headers, ok := args["headers"].(map[string]interface{}); ok {
 		for k, v := range headers {
 			if str, ok := v.(string); ok {
-				req.Header.Set(k, str)
+				request.headers[k] = str
 			}
 		}
 	}
-
 	// Add body for POST/PUT
-	if body, ok := args["body"].(string); ok && (method == "POST" || method == "PUT" || method == "PATCH") {
-		req.Body = io.NopCloser(strings.NewReader(body))
-		req.ContentLength = int64(len(body))
+	if body, ok := args["body"].(string); ok && (request.method == "POST" || request.method == "PUT" || request.method == "PATCH") {
+		request.body = []byte(body)
 	}
+	return request, nil
+}
 
-	// Execute request
-	resp, err := t.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("HTTP tool request execution failed: tool_name='%s', handler_type='http', method='%s', url='%s', headers_count=%d, body_size=%d, timeout=%v, error=%w",
-			tool.Name, method, url, headerCount, bodySize, t.client.Timeout, err)
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
 	}
-	defer resp.Body.Close()
+	return false
+}
 
+// send performs request, retrying network errors and the response codes of
+// retry.on_status until retry.max_attempts is reached. It returns the last
+// response and the number of attempts made.
+func (t *HTTPTool) send(ctx context.Context, cfg *httpToolConfig, request *httpRequest) (int, http.Header, []byte, int, error) {
 	// Limit response size (1MB)
-	maxResponseSize := 1024 * 1024
-	limitedReader := io.LimitReader(resp.Body, int64(maxResponseSize))
-	body, err := io.ReadAll(limitedReader)
+	const maxResponseSize = 1024 * 1024
+
+	for attempt := 1; ; attempt++ {
+		status, header, body, err := t.attempt(ctx, cfg, request, maxResponseSize)
+		last := attempt >= cfg.Retry.MaxAttempts
+		if err == nil && (last || !cfg.shouldRetry(status)) {
+			return status, header, body, attempt, nil
+		}
+		if last || ctx.Err() != nil {
+			if err == nil {
+				return status, header, body, attempt, nil
+			}
+			return 0, nil, nil, attempt, err
+		}
+
+		delay := cfg.Retry.Backoff << (attempt - 1)
+		if err == nil {
+			if retryAfter, ok := parseRetryAfter(header.Get("Retry-After")); ok && retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+		if delay > maxHTTPRetryAfter {
+			delay = maxHTTPRetryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				return status, header, body, attempt, nil
+			}
+			return 0, nil, nil, attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends request once. Auth is applied last, so its headers win
+// over configured ones, and again on every attempt, so signatures carry a
+// fresh timestamp. It is only sent to the host of the configured url.
+func (t *HTTPTool) attempt(ctx context.Context, cfg *httpToolConfig, request *httpRequest, maxResponseSize int64) (int, http.Header, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if request.body != nil {
+		reader = bytes.NewReader(request.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, request.method, request.url, reader)
 	if err != nil {
-		return "", fmt.Errorf("HTTP tool response reading failed: tool_name='%s', handler_type='http', method='%s', url='%s', response_status=%d, max_response_size=%d, error=%w",
-			tool.Name, method, url, resp.StatusCode, maxResponseSize, err)
+		return 0, nil, nil, fmt.Errorf("request creation failed: %w", err)
+	}
+	for name, value := range request.headers {
+		req.Header.Set(name, value)
+	}
+	if cfg.Auth != nil {
+		if req.URL.Host != cfg.AuthHost {
+			return 0, nil, nil, fmt.Errorf("%s auth is only sent to '%s', not '%s'", cfg.Auth.Type, cfg.AuthHost, req.URL.Host)
+		}
+		if err := cfg.Auth.apply(req, request.body); err != nil {
+			return 0, nil, nil, err
+		}
 	}
 
-	// Format response
-	result := map[string]interface{}{
-		"status_code": resp.StatusCode,
-		"headers":     resp.Header,
-		"body":        string(body),
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	jsonResult, err := json.Marshal(result)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
 	if err != nil {
-		return "", fmt.Errorf("HTTP tool response marshaling failed: tool_name='%s', handler_type='http', method='%s', url='%s', response_status=%d, response_body_size=%d, error=%w",
-			tool.Name, method, url, resp.StatusCode, len(body), err)
+		return 0, nil, nil, fmt.Errorf("response reading failed: response_status=%d, max_response_size=%d, error=%w",
+			resp.StatusCode, maxResponseSize, err)
 	}
+	return resp.StatusCode, resp.Header, body, nil
+}
 
-	return string(jsonResult), nil
+// parseRetryAfter reads a Retry-After header given in seconds
+func parseRetryAfter(value string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// mapHTTPResponse applies the response mapping of cfg to a JSON body
+func mapHTTPResponse(cfg *httpToolConfig, body []byte) (interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("response body is not JSON: %w", err)
+	}
+	if cfg.Extract != "" {
+		return evalJSONPath(cfg.Extract, doc)
+	}
+	fields := make(map[string]interface{}, len(cfg.Fields))
+	for name, path := range cfg.Fields {
+		value, err := evalJSONPath(path, doc)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = value
+	}
+	return fields, nil
 }
 
 func (t *HTTPTool) Validate(args map[string]interface{}, schema map[string]interface{}) error {
 	return ValidateArgs(args, schema)
 }
 
+// httpMethods are the methods an HTTP tool may use
+var httpMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true,
+}
+
+// ValidateTool checks the handler_config of an HTTP tool. Auth credentials
+// must be secret:// references and need a url with a fixed host. A tool with a configured url must declare
+// every argument its templates use; a tool without one must declare the url
+// argument.
+func (t *HTTPTool) ValidateTool(tool *db.Tool) error {
+	cfg, err := parseHTTPToolConfig(tool.HandlerConfig)
+	if err != nil {
+		return err
+	}
+	if auth, ok := tool.HandlerConfig["auth"].(map[string]interface{}); ok {
+		for _, key := range []string{"token", "password", "secret"} {
+			if value, ok := auth[key]; ok {
+				if s, _ := value.(string); !secrets.IsReference(s) {
+					return fmt.Errorf("handler_config.auth.%s must be a secret reference such as '%sname', or set %s_env to the environment variable holding it",
+						key, secrets.ReferenceScheme, key)
+				}
+			}
+		}
+	}
+	if cfg.URL == "" {
+		if err := requireArgument(tool, "url"); err != nil {
+			return fmt.Errorf("%w, or handler_config must set url", err)
+		}
+		return nil
+	}
+	properties, _ := tool.ArgSchema["properties"].(map[string]interface{})
+	for _, name := range cfg.templateVars() {
+		if _, ok := properties[name]; !ok {
+			return fmt.Errorf("handler_config uses {{%s}}: arg_schema must declare the '%s' argument", name, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+// authRecorder is a server that records the Authorization header of every
+// request it receives
+type authRecorder struct {
+	*httptest.Server
+	mu      sync.Mutex
+	headers []string
+}
+
+func newAuthRecorder(t *testing.T) *authRecorder {
+	t.Helper()
+	r := &authRecorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.headers = append(r.headers, req.Header.Get("Authorization"))
+		r.mu.Unlock()
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *authRecorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.headers...)
+}
+
+func TestHTTPAuthIsNotSentToArgumentHosts(t *testing.T) {
+	attacker := newAuthRecorder(t)
+	tool := &db.Tool{
+		Name: "fetch",
+		ArgSchema: db.JSONBMap{
+			"type":       "object",
+			"properties": map[string]interface{}{"url": map[string]interface{}{"type": "string"}},
+		},
+		HandlerType:   "http",
+		HandlerConfig: db.JSONBMap{"auth": map[string]interface{}{"type": "bearer", "token": "secret://api_token"}},
+	}
+	httpTool := NewHTTPTool()
+
+	if err := httpTool.ValidateTool(tool); err == nil || !strings.Contains(err.Error(), "requires handler_config.url") {
+		t.Errorf("ValidateTool with auth and no url = %v, want an error", err)
+	}
+
+	// A tool stored before the check, with its secret resolved, still
+	// sends nothing
+	tool.HandlerConfig = db.JSONBMap{"auth": map[string]interface{}{"type": "bearer", "token": "resolved-token"}}
+	if _, err := httpTool.Execute(context.Background(), tool, map[string]interface{}{"url": attacker.URL}); err == nil {
+		t.Error("Execute with auth and an argument url succeeded")
+	}
+	if got := attacker.received(); len(got) != 0 {
+		t.Errorf("the argument host received %d requests, Authorization %q", len(got), got)
+	}
+}
+
+func TestHTTPAuthOnlyGoesToTheConfiguredHost(t *testing.T) {
+	api, other := newAuthRecorder(t), newAuthRecorder(t)
+	cfg, err := parseHTTPToolConfig(map[string]interface{}{
+		"url":  api.URL + "/items/{{id}}",
+		"auth": map[string]interface{}{"type": "bearer", "token": "resolved-token"},
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	httpTool := NewHTTPTool()
+
+	request, err := buildDeclarativeRequest(cfg, map[string]interface{}{"id": "1"})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if _, _, _, err := httpTool.attempt(context.Background(), cfg, request, 1024); err != nil {
+		t.Fatalf("attempt: %v", err)
+	}
+	if got := api.received(); len(got) != 1 || got[0] != "Bearer resolved-token" {
+		t.Errorf("configured host received Authorization %q, want the bearer token", got)
+	}
+
+	request.url = other.URL + "/items/1"
+	if _, _, _, err := httpTool.attempt(context.Background(), cfg, request, 1024); err == nil {
+		t.Error("attempt to another host succeeded")
+	}
+	if got := other.received(); len(got) != 0 {
+		t.Errorf("another host received %d requests, Authorization %q", len(got), got)
+	}
+
+	// A placeholder in the host would let arguments choose it
+	if _, err := parseHTTPToolConfig(map[string]interface{}{
+		"url":  "https://{{region}}.example.com/items",
+		"auth": map[string]interface{}{"type": "bearer", "token": "resolved-token"},
+	}); err == nil {
+		t.Error("parse with auth and a templated host succeeded")
+	}
+}
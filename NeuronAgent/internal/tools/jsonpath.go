@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is one step of a JSONPath: a member name, an array index or
+// a wildcard over all members or elements
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the JSONPath subset HTTP tools support: $, .name,
+// ['name'], [n] (negative counts from the end), [*] and .*
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath '%s' must start with $", path)
+	}
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath '%s' has an empty member name", path)
+			}
+			if name == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonPathStep{key: name})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath '%s' has an unclosed [", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("JSONPath '%s' has an invalid subscript [%s]", path, inner)
+				}
+				steps = append(steps, jsonPathStep{index: n, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath '%s' has unexpected '%c'", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath applies path to a decoded JSON document. A path with a
+// wildcard returns the list of matches, object members in key order;
+// otherwise it returns the single match, or nil when nothing matches.
+func evalJSONPath(path string, doc interface{}) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	matches := []interface{}{doc}
+	multi := false
+	for _, step := range steps {
+		var next []interface{}
+		for _, node := range matches {
+			switch {
+			case step.wildcard:
+				multi = true
+				switch v := node.(type) {
+				case map[string]interface{}:
+					keys := make([]string, 0, len(v))
+					for key := range v {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, v[key])
+					}
+				case []interface{}:
+					next = append(next, v...)
+				}
+			case step.isIndex:
+				if arr, ok := node.([]interface{}); ok {
+					i := step.index
+					if i < 0 {
+						i += len(arr)
+					}
+					if i >= 0 && i < len(arr) {
+						next = append(next, arr[i])
+					}
+				}
+			default:
+				if obj, ok := node.(map[string]interface{}); ok {
+					if item, ok := obj[step.key]; ok {
+						next = append(next, item)
+					}
+				}
+			}
+		}
+		matches = next
+	}
+	if multi {
+		if matches == nil {
+			matches = []interface{}{}
+		}
+		return matches, nil
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	return matches[0], nil
+}
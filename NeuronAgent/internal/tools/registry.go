@@ -3,17 +3,43 @@ package tools
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/secrets"
 )
 
+// toolCacheTTL bounds how long a tool definition is served from memory.
+// Changes made through the API invalidate the cache at once; changes made
+// directly in the database show up after at most this long.
+const toolCacheTTL = 30 * time.Second
+
+// toolNamePattern matches valid tool names
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,99}$`)
+
+type cachedTool struct {
+	tool     *db.Tool
+	cachedAt time.Time
+}
+
+// SecretResolver returns the value of a secret referenced in handler_config
+type SecretResolver interface {
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
 // Registry manages tool registration and execution
 type Registry struct {
 	queries  *db.Queries
 	db       *db.DB
 	handlers map[string]ToolHandler
+	secrets  SecretResolver
 	mu       sync.RWMutex
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedTool
 }
 
 // NewRegistry creates a new tool registry
@@ -22,6 +48,7 @@ func NewRegistry(queries *db.Queries, database *db.DB) *Registry {
 		queries:  queries,
 		db:       database,
 		handlers: make(map[string]ToolHandler),
+		cache:    make(map[string]cachedTool),
 	}
 
 	// Register built-in handlers
@@ -42,16 +69,88 @@ func (r *Registry) RegisterHandler(handlerType string, handler ToolHandler) {
 	r.handlers[handlerType] = handler
 }
 
+// SetSecrets sets the resolver of secret:// references in handler_config.
+// Without one, tools referencing secrets fail to execute.
+func (r *Registry) SetSecrets(resolver SecretResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = resolver
+}
+
 // Get retrieves a tool from the database
 // Implements agent.ToolRegistry interface
 func (r *Registry) Get(name string) (*db.Tool, error) {
+	r.cacheMu.Lock()
+	entry, ok := r.cache[name]
+	r.cacheMu.Unlock()
+	if ok && time.Since(entry.cachedAt) < toolCacheTTL {
+		tool := *entry.tool
+		return &tool, nil
+	}
+
 	tool, err := r.queries.GetTool(context.Background(), name)
 	if err != nil {
 		return nil, fmt.Errorf("tool retrieval failed: tool_name='%s', error=%w", name, err)
 	}
+	cached := *tool
+	r.cacheMu.Lock()
+	r.cache[name] = cachedTool{tool: &cached, cachedAt: time.Now()}
+	r.cacheMu.Unlock()
 	return tool, nil
 }
 
+// Invalidate drops the cached definition of tool name, so the next Get reads
+// it from the database
+func (r *Registry) Invalidate(name string) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	delete(r.cache, name)
+}
+
+// HandlerTypes returns the handler types tools can use
+func (r *Registry) HandlerTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.handlers))
+	for handlerType := range r.handlers {
+		types = append(types, handlerType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ValidateTool checks a tool definition before it is saved: its name, that a
+// handler is registered for its handler_type, that arg_schema is a usable
+// JSON Schema, and any checks the handler makes on handler_config
+func (r *Registry) ValidateTool(tool *db.Tool) error {
+	if !toolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("name must start with a letter and contain at most 100 letters, digits, '_' or '-'")
+	}
+	r.mu.RLock()
+	handler, exists := r.handlers[tool.HandlerType]
+	r.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("handler_type '%s' has no registered handler: use one of %v", tool.HandlerType, r.HandlerTypes())
+	}
+	if err := ValidateSchema(tool.ArgSchema); err != nil {
+		return err
+	}
+	if validator, ok := handler.(ToolValidator); ok {
+		if err := validator.ValidateTool(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestTool runs tool with args whether or not it is enabled or saved, so a
+// definition can be tried before it is stored
+func (r *Registry) TestTool(ctx context.Context, tool *db.Tool, args map[string]interface{}) (string, error) {
+	trial := *tool
+	trial.Enabled = true
+	return r.ExecuteTool(ctx, &trial, args)
+}
+
 // Execute executes a tool with the given arguments
 // Implements agent.ToolRegistry interface
 func (r *Registry) Execute(ctx context.Context, tool *db.Tool, args map[string]interface{}) (string, error) {
@@ -97,17 +196,74 @@ func (r *Registry) ExecuteTool(ctx context.Context, tool *db.Tool, args map[stri
 			tool.Name, tool.HandlerType, len(args), argKeys, availableHandlers)
 	}
 
+	// Resolve secret references into a copy of the tool, so values never
+	// reach the cache or the database
+	resolved, values, err := r.resolveSecrets(ctx, tool)
+	if err != nil {
+		return "", fmt.Errorf("tool execution failed: tool_name='%s', handler_type='%s', error=%w",
+			tool.Name, tool.HandlerType, err)
+	}
+
 	// Execute tool
-	result, err := handler.Execute(ctx, tool, args)
+	result, err := handler.Execute(ctx, resolved, args)
 	if err != nil {
 		argKeys := make([]string, 0, len(args))
 		for k := range args {
 			argKeys = append(argKeys, k)
 		}
+		if len(values) > 0 {
+			err = &redactedError{err: err, values: values}
+		}
 		return "", fmt.Errorf("tool execution failed: tool_name='%s', handler_type='%s', args_count=%d, arg_keys=[%v], error=%w",
 			tool.Name, tool.HandlerType, len(args), argKeys, err)
 	}
-	return result, nil
+	return secrets.Redact(result, values), nil
+}
+
+// resolveSecrets returns tool with the secret references in its
+// handler_config replaced by their values, and the values substituted
+func (r *Registry) resolveSecrets(ctx context.Context, tool *db.Tool) (*db.Tool, []string, error) {
+	if len(secrets.References(map[string]interface{}(tool.HandlerConfig))) == 0 {
+		return tool, nil, nil
+	}
+	r.mu.RLock()
+	resolver := r.secrets
+	r.mu.RUnlock()
+	if resolver == nil {
+		return nil, nil, fmt.Errorf("handler_config references secrets but no secrets store is configured")
+	}
+	config, values, err := secrets.Resolve(ctx, map[string]interface{}(tool.HandlerConfig), func(ctx context.Context, name string) (string, error) {
+		value, err := resolver.Resolve(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("secret '%s' could not be resolved: %w", name, err)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	copied := *tool
+	copied.HandlerConfig = db.JSONBMap(config.(map[string]interface{}))
+	return &copied, values, nil
+}
+
+// redactedError hides resolved secret values in a handler's error message
+type redactedError struct {
+	err    error
+	values []string
+}
+
+func (e *redactedError) Error() string {
+	return secrets.Redact(e.err.Error(), e.values)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// ListAllTools returns every tool, including disabled ones
+func (r *Registry) ListAllTools(ctx context.Context) ([]db.Tool, error) {
+	return r.queries.ListAllTools(ctx)
 }
 
 // ListTools returns all enabled tools
@@ -109,3 +109,7 @@ func (t *ShellTool) Validate(args map[string]interface{}, schema map[string]inte
 	return ValidateArgs(args, schema)
 }
 
+// ValidateTool checks that the tool declares the command argument
+func (t *ShellTool) ValidateTool(tool *db.Tool) error {
+	return requireArgument(tool, "command")
+}
@@ -151,3 +151,7 @@ func (t *SQLTool) Validate(args map[string]interface{}, schema map[string]interf
 	return ValidateArgs(args, schema)
 }
 
+// ValidateTool checks that the tool declares the query argument
+func (t *SQLTool) ValidateTool(tool *db.Tool) error {
+	return requireArgument(tool, "query")
+}
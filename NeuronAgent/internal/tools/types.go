@@ -12,6 +12,13 @@ type ToolHandler interface {
 	Validate(args map[string]interface{}, schema map[string]interface{}) error
 }
 
+// ToolValidator is implemented by handlers that check a tool definition
+// before it is saved, such as required handler_config fields or the
+// arguments the handler reads
+type ToolValidator interface {
+	ValidateTool(tool *db.Tool) error
+}
+
 // ExecutionResult represents the result of tool execution
 type ExecutionResult struct {
 	Output string
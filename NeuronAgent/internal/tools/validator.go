@@ -3,6 +3,8 @@ package tools
 import (
 	"fmt"
 	"reflect"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
 )
 
 // ValidateArgs validates arguments against a JSON Schema
@@ -82,3 +84,110 @@ func validateType(value interface{}, schema map[string]interface{}) error {
 	return nil
 }
 
+// schemaTypes are the JSON Schema types an argument may declare
+var schemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"array": true, "object": true, "null": true,
+}
+
+// ValidateSchema checks that schema is a JSON Schema ValidateArgs can apply:
+// an object schema with a properties map, whose required names are declared
+// properties. Property schemas are checked recursively for known types and
+// well-formed enum, items, properties and required keywords.
+func ValidateSchema(schema map[string]interface{}) error {
+	if schema == nil {
+		return fmt.Errorf("arg_schema is required")
+	}
+	if t, ok := schema["type"]; ok && t != "object" {
+		return fmt.Errorf("arg_schema must have type \"object\", got %v", t)
+	}
+	if _, ok := schema["properties"].(map[string]interface{}); !ok {
+		return fmt.Errorf("arg_schema must have a properties object")
+	}
+	return validateSchemaNode(schema, "arg_schema")
+}
+
+func validateSchemaNode(node map[string]interface{}, path string) error {
+	if t, ok := node["type"]; ok {
+		var types []interface{}
+		switch v := t.(type) {
+		case string:
+			types = []interface{}{v}
+		case []interface{}:
+			types = v
+		default:
+			return fmt.Errorf("%s.type must be a string or an array of strings", path)
+		}
+		for _, typ := range types {
+			name, ok := typ.(string)
+			if !ok || !schemaTypes[name] {
+				return fmt.Errorf("%s.type has unknown type %v", path, typ)
+			}
+		}
+	}
+
+	if enum, ok := node["enum"]; ok {
+		values, ok := enum.([]interface{})
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("%s.enum must be a non-empty array", path)
+		}
+	}
+
+	var properties map[string]interface{}
+	if p, ok := node["properties"]; ok {
+		if properties, ok = p.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s.properties must be an object", path)
+		}
+		for name, prop := range properties {
+			propSchema, ok := prop.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.properties.%s must be a schema object", path, name)
+			}
+			if err := validateSchemaNode(propSchema, path+".properties."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r, ok := node["required"]; ok {
+		required, ok := r.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s.required must be an array of property names", path)
+		}
+		for _, req := range required {
+			name, ok := req.(string)
+			if !ok {
+				return fmt.Errorf("%s.required must be an array of property names", path)
+			}
+			if _, declared := properties[name]; !declared {
+				return fmt.Errorf("%s.required names undeclared property '%s'", path, name)
+			}
+		}
+	}
+
+	if items, ok := node["items"]; ok {
+		itemSchema, ok := items.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.items must be a schema object", path)
+		}
+		if err := validateSchemaNode(itemSchema, path+".items"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// requireArgument checks that tool's arg_schema declares the string argument
+// name, which its handler reads
+func requireArgument(tool *db.Tool, name string) error {
+	properties, _ := tool.ArgSchema["properties"].(map[string]interface{})
+	prop, ok := properties[name].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s tools read the '%s' argument: arg_schema must declare it", tool.HandlerType, name)
+	}
+	if t, ok := prop["type"]; ok && t != "string" {
+		return fmt.Errorf("%s tools read the '%s' argument as a string: arg_schema declares type %v", tool.HandlerType, name, t)
+	}
+	return nil
+}
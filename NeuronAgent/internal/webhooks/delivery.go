@@ -0,0 +1,201 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// Headers sent with every delivery
+const (
+	// SignatureHeader carries "sha256=<hex>", the HMAC-SHA256 of the body
+	// keyed with the webhook's secret, when the webhook has one
+	SignatureHeader = "X-NeuronAgent-Signature"
+	EventHeader     = "X-NeuronAgent-Event"
+	// DeliveryHeader carries the event ID, which is the same on every retry
+	// so receivers can drop duplicates
+	DeliveryHeader = "X-NeuronAgent-Delivery"
+)
+
+const (
+	defaultMaxAttempts  = 8
+	deliveryBatchSize   = 50
+	deliveryTimeout     = 10 * time.Second
+	deliveryLease       = time.Minute
+	initialRetryDelay   = 30 * time.Second
+	maxRetryDelay       = time.Hour
+	maxErrorBodyPreview = 512
+)
+
+// Sign returns the signature header value of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RetryDelay is the wait after the given number of failed attempts: 30
+// seconds, doubling per attempt, at most an hour
+func RetryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// DeliveryService periodically sends due webhook deliveries. A failed
+// delivery is retried with exponential backoff; after maxAttempts it is
+// dead-lettered and stays in the table until redelivered.
+type DeliveryService struct {
+	queries     *db.Queries
+	client      *http.Client
+	interval    time.Duration
+	maxAttempts int
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// NewDeliveryService creates a delivery service polling every interval. A
+// non-positive maxAttempts uses the default of 8.
+func NewDeliveryService(queries *db.Queries, interval time.Duration, maxAttempts int) *DeliveryService {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &DeliveryService{
+		queries:     queries,
+		client:      &http.Client{Timeout: deliveryTimeout},
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+}
+
+// Start starts the delivery service
+func (s *DeliveryService) Start() {
+	go s.run()
+}
+
+// Stop stops the delivery service and waits for a running pass to finish
+func (s *DeliveryService) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *DeliveryService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.deliverDue()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.deliverDue()
+		}
+	}
+}
+
+// deliverDue sends due deliveries until none are left
+func (s *DeliveryService) deliverDue() {
+	for s.ctx.Err() == nil {
+		deliveries, err := s.queries.ClaimWebhookDeliveries(s.ctx, deliveryBatchSize, deliveryLease)
+		if err != nil {
+			metrics.Logger().Error().Err(err).Msg("Failed to claim webhook deliveries")
+			return
+		}
+		for i := range deliveries {
+			s.deliver(&deliveries[i])
+		}
+		if len(deliveries) < deliveryBatchSize {
+			return
+		}
+	}
+}
+
+func (s *DeliveryService) deliver(delivery *db.WebhookDelivery) {
+	statusCode, err := s.send(delivery)
+	if err == nil {
+		if err := s.queries.MarkWebhookDelivered(s.ctx, delivery.ID, statusCode); err != nil {
+			metrics.Logger().Error().Err(err).Int64("delivery_id", delivery.ID).Msg("Failed to mark webhook delivered")
+		}
+		metrics.RecordWebhookDelivery(delivery.Event, "delivered")
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	dead := attempts >= s.maxAttempts
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	if markErr := s.queries.FailWebhookDelivery(s.ctx, delivery.ID, code, err.Error(), time.Now().Add(RetryDelay(attempts)), dead); markErr != nil {
+		metrics.Logger().Error().Err(markErr).Int64("delivery_id", delivery.ID).Msg("Failed to record webhook delivery failure")
+	}
+
+	outcome := "retry"
+	if dead {
+		outcome = "dead"
+		metrics.Logger().Warn().Err(err).
+			Int64("delivery_id", delivery.ID).
+			Str("event", delivery.Event).
+			Str("url", delivery.URL).
+			Int("attempts", attempts).
+			Msg("Webhook delivery dead-lettered")
+	}
+	metrics.RecordWebhookDelivery(delivery.Event, outcome)
+}
+
+// send posts the delivery, returning the response status code, or 0 when
+// there was no response
+func (s *DeliveryService) send(delivery *db.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook request: url='%s', error=%w", delivery.URL, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.EventID.String())
+	if delivery.SecretEnv != nil && *delivery.SecretEnv != "" {
+		secret := os.Getenv(*delivery.SecretEnv)
+		if secret == "" {
+			return 0, fmt.Errorf("webhook secret environment variable %s is not set", *delivery.SecretEnv)
+		}
+		req.Header.Set(SignatureHeader, Sign(secret, delivery.Payload))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: url='%s', error=%w", delivery.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyPreview))
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d: url='%s', body='%s'", resp.StatusCode, delivery.URL, string(preview))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,142 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/metrics"
+)
+
+// Event types
+const (
+	EventAgentCreated      = "agent.created"
+	EventSessionCreated    = "session.created"
+	EventMessageCompleted  = "message.completed"
+	EventJobFailed         = "job.failed"
+	EventApprovalRequested = "approval.requested"
+)
+
+// AllEvents subscribes a webhook to every event type
+const AllEvents = "*"
+
+// EventTypes lists the event types webhooks can subscribe to
+var EventTypes = []string{
+	EventAgentCreated,
+	EventSessionCreated,
+	EventMessageCompleted,
+	EventJobFailed,
+	EventApprovalRequested,
+}
+
+// IsEventType reports whether event is a known event type or AllEvents
+func IsEventType(event string) bool {
+	if event == AllEvents {
+		return true
+	}
+	for _, t := range EventTypes {
+		if t == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON body posted to webhooks
+type Event struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Emitter queues events for delivery. Deliveries are stored in the
+// database and sent by DeliveryService, so an event survives a restart and
+// a slow endpoint never delays the operation that raised it.
+type Emitter struct {
+	queries *db.Queries
+}
+
+// NewEmitter creates an emitter
+func NewEmitter(queries *db.Queries) *Emitter {
+	return &Emitter{queries: queries}
+}
+
+// Emit queues an event for every enabled webhook subscribed to its type.
+// Failures are logged: events never fail the operation that raised them.
+func (e *Emitter) Emit(ctx context.Context, eventType string, data interface{}) {
+	webhooks, err := e.queries.ListWebhooksForEvent(ctx, eventType)
+	if err != nil {
+		logEmitError(err, eventType, "")
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	event, payload, err := newEvent(eventType, data)
+	if err != nil {
+		logEmitError(err, eventType, "")
+		return
+	}
+	for i := range webhooks {
+		webhookID := webhooks[i].ID
+		e.queue(ctx, &db.WebhookDelivery{
+			WebhookID: &webhookID,
+			EventID:   event.ID,
+			Event:     eventType,
+			URL:       webhooks[i].URL,
+			SecretEnv: webhooks[i].SecretEnv,
+			Payload:   payload,
+		})
+	}
+}
+
+// EmitTo queues an event for a single endpoint that is not a registered
+// webhook, such as an agent's tool approval webhook. secretEnv may be empty.
+func (e *Emitter) EmitTo(ctx context.Context, url, secretEnv, eventType string, data interface{}) {
+	event, payload, err := newEvent(eventType, data)
+	if err != nil {
+		logEmitError(err, eventType, url)
+		return
+	}
+	delivery := &db.WebhookDelivery{
+		EventID: event.ID,
+		Event:   eventType,
+		URL:     url,
+		Payload: payload,
+	}
+	if secretEnv != "" {
+		delivery.SecretEnv = &secretEnv
+	}
+	e.queue(ctx, delivery)
+}
+
+func (e *Emitter) queue(ctx context.Context, delivery *db.WebhookDelivery) {
+	if err := e.queries.CreateWebhookDelivery(ctx, delivery); err != nil {
+		logEmitError(err, delivery.Event, delivery.URL)
+	}
+}
+
+func newEvent(eventType string, data interface{}) (*Event, []byte, error) {
+	event := &Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	return event, payload, nil
+}
+
+func logEmitError(err error, eventType, url string) {
+	metrics.Logger().Error().Err(err).
+		Str("event", eventType).
+		Str("url", url).
+		Msg("Failed to queue webhook event")
+}
@@ -0,0 +1,9 @@
+-- Revert 001_initial_schema. The schema itself is kept because it also
+-- holds schema_migrations.
+DROP TABLE IF EXISTS neurondb_agent.api_keys;
+DROP TABLE IF EXISTS neurondb_agent.jobs;
+DROP TABLE IF EXISTS neurondb_agent.tools;
+DROP TABLE IF EXISTS neurondb_agent.memory_chunks;
+DROP TABLE IF EXISTS neurondb_agent.messages;
+DROP TABLE IF EXISTS neurondb_agent.sessions;
+DROP TABLE IF EXISTS neurondb_agent.agents;
@@ -0,0 +1,11 @@
+-- Revert 002_add_indexes
+DROP INDEX IF EXISTS neurondb_agent.idx_memory_chunks_embedding_hnsw;
+DROP INDEX IF EXISTS neurondb_agent.idx_api_keys_prefix;
+DROP INDEX IF EXISTS neurondb_agent.idx_jobs_agent_session;
+DROP INDEX IF EXISTS neurondb_agent.idx_jobs_status_created;
+DROP INDEX IF EXISTS neurondb_agent.idx_memory_chunks_session_id;
+DROP INDEX IF EXISTS neurondb_agent.idx_memory_chunks_agent_id;
+DROP INDEX IF EXISTS neurondb_agent.idx_messages_session_role;
+DROP INDEX IF EXISTS neurondb_agent.idx_messages_session_id;
+DROP INDEX IF EXISTS neurondb_agent.idx_sessions_last_activity;
+DROP INDEX IF EXISTS neurondb_agent.idx_sessions_agent_id;
@@ -0,0 +1,8 @@
+-- Revert 003_add_triggers
+DROP TRIGGER IF EXISTS messages_session_activity ON neurondb_agent.messages;
+DROP FUNCTION IF EXISTS neurondb_agent.update_session_activity();
+
+DROP TRIGGER IF EXISTS jobs_updated_at ON neurondb_agent.jobs;
+DROP TRIGGER IF EXISTS tools_updated_at ON neurondb_agent.tools;
+DROP TRIGGER IF EXISTS agents_updated_at ON neurondb_agent.agents;
+DROP FUNCTION IF EXISTS neurondb_agent.update_updated_at();
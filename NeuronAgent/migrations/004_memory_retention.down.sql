@@ -0,0 +1,3 @@
+-- Revert 004_memory_retention
+DROP INDEX IF EXISTS neurondb_agent.idx_memory_chunks_agent_importance;
+DROP TABLE IF EXISTS neurondb_agent.memory_chunks_archive;
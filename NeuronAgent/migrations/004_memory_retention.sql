@@ -0,0 +1,19 @@
+-- Memory retention: archive table for evicted memory chunks
+CREATE TABLE IF NOT EXISTS neurondb_agent.memory_chunks_archive (
+    id BIGINT PRIMARY KEY,  -- id of the original memory chunk
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    session_id UUID,
+    message_id BIGINT,
+    content TEXT NOT NULL,
+    embedding neurondb_vector(768),
+    importance_score REAL,
+    metadata JSONB DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    eviction_reason TEXT NOT NULL CHECK (eviction_reason IN ('max_age', 'max_chunks'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_chunks_archive_agent_id ON neurondb_agent.memory_chunks_archive(agent_id, archived_at DESC);
+
+-- Supports eviction ordering (lowest importance, oldest first)
+CREATE INDEX IF NOT EXISTS idx_memory_chunks_agent_importance ON neurondb_agent.memory_chunks(agent_id, importance_score, created_at);
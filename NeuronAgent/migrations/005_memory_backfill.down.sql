@@ -0,0 +1,6 @@
+-- Revert 005_memory_backfill
+DROP INDEX IF EXISTS neurondb_agent.idx_jobs_agent_type;
+DELETE FROM neurondb_agent.jobs WHERE type = 'memory_backfill';
+ALTER TABLE neurondb_agent.jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+ALTER TABLE neurondb_agent.jobs ADD CONSTRAINT jobs_type_check
+    CHECK (type IN ('http_call', 'sql_task', 'shell_task', 'custom'));
@@ -0,0 +1,7 @@
+-- Memory backfill: allow background jobs that embed existing table rows into
+-- agent memory
+ALTER TABLE neurondb_agent.jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+ALTER TABLE neurondb_agent.jobs ADD CONSTRAINT jobs_type_check
+    CHECK (type IN ('http_call', 'sql_task', 'shell_task', 'memory_backfill', 'custom'));
+
+CREATE INDEX IF NOT EXISTS idx_jobs_agent_type ON neurondb_agent.jobs(agent_id, type, created_at DESC);
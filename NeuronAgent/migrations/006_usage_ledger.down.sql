@@ -0,0 +1,2 @@
+-- Revert 006_usage_ledger
+DROP TABLE IF EXISTS neurondb_agent.usage_ledger;
@@ -0,0 +1,19 @@
+-- Usage ledger: tokens and estimated cost of every LLM call, for usage
+-- reports and monthly budgets
+CREATE TABLE IF NOT EXISTS neurondb_agent.usage_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    session_id UUID REFERENCES neurondb_agent.sessions(id) ON DELETE SET NULL,
+    message_id BIGINT REFERENCES neurondb_agent.messages(id) ON DELETE SET NULL,
+    api_key_id UUID REFERENCES neurondb_agent.api_keys(id) ON DELETE SET NULL,
+    model TEXT NOT NULL,
+    provider TEXT,
+    prompt_tokens INT NOT NULL DEFAULT 0 CHECK (prompt_tokens >= 0),
+    completion_tokens INT NOT NULL DEFAULT 0 CHECK (completion_tokens >= 0),
+    total_tokens INT NOT NULL DEFAULT 0 CHECK (total_tokens >= 0),
+    cost_usd NUMERIC(14, 6) NOT NULL DEFAULT 0 CHECK (cost_usd >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_agent_created ON neurondb_agent.usage_ledger(agent_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_api_key_created ON neurondb_agent.usage_ledger(api_key_id, created_at) WHERE api_key_id IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Revert 007_message_feedback
+DROP TABLE IF EXISTS neurondb_agent.message_feedback;
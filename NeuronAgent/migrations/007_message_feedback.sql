@@ -0,0 +1,24 @@
+-- Message feedback: ratings of assistant messages, for quality monitoring
+-- and exporting rated conversations as training data
+CREATE TABLE IF NOT EXISTS neurondb_agent.message_feedback (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES neurondb_agent.messages(id) ON DELETE CASCADE,
+    session_id UUID NOT NULL REFERENCES neurondb_agent.sessions(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    api_key_id UUID REFERENCES neurondb_agent.api_keys(id) ON DELETE SET NULL,
+    rating TEXT CHECK (rating IN ('up', 'down')),
+    score SMALLINT CHECK (score BETWEEN 1 AND 5),
+    comment TEXT,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT feedback_not_empty CHECK (rating IS NOT NULL OR score IS NOT NULL OR comment IS NOT NULL)
+);
+
+-- One feedback entry per message and API key; submitting again replaces it
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_feedback_message_key
+    ON neurondb_agent.message_feedback(message_id, COALESCE(api_key_id, '00000000-0000-0000-0000-000000000000'::uuid));
+CREATE INDEX IF NOT EXISTS idx_message_feedback_agent_created ON neurondb_agent.message_feedback(agent_id, created_at);
+
+CREATE TRIGGER message_feedback_updated_at BEFORE UPDATE ON neurondb_agent.message_feedback
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.update_updated_at();
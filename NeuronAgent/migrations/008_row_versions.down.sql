@@ -0,0 +1,6 @@
+-- Revert 008_row_versions
+DROP TRIGGER IF EXISTS tools_version ON neurondb_agent.tools;
+DROP TRIGGER IF EXISTS agents_version ON neurondb_agent.agents;
+DROP FUNCTION IF EXISTS neurondb_agent.increment_version();
+ALTER TABLE neurondb_agent.tools DROP COLUMN IF EXISTS version;
+ALTER TABLE neurondb_agent.agents DROP COLUMN IF EXISTS version;
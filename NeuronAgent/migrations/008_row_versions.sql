@@ -0,0 +1,18 @@
+-- Row versions for optimistic concurrency: every update of an agent or tool
+-- increments its version, and conditional updates match on it
+ALTER TABLE neurondb_agent.agents ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE neurondb_agent.tools ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION neurondb_agent.increment_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER agents_version BEFORE UPDATE ON neurondb_agent.agents
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.increment_version();
+
+CREATE TRIGGER tools_version BEFORE UPDATE ON neurondb_agent.tools
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.increment_version();
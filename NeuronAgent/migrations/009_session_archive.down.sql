@@ -0,0 +1,3 @@
+-- Revert 009_session_archive
+DROP INDEX IF EXISTS neurondb_agent.idx_sessions_agent_activity;
+DROP TABLE IF EXISTS neurondb_agent.sessions_archive;
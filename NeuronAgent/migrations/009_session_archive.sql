@@ -0,0 +1,19 @@
+-- Session retention: archived sessions. Each row keeps the session's
+-- columns for listing and the full session archive document, in the format
+-- of GET /sessions/{id}/export.
+CREATE TABLE IF NOT EXISTS neurondb_agent.sessions_archive (
+    id UUID PRIMARY KEY,  -- id of the original session
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    external_user_id TEXT,
+    metadata JSONB DEFAULT '{}',
+    message_count INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_activity_at TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    archive JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_archive_agent_activity ON neurondb_agent.sessions_archive(agent_id, last_activity_at DESC);
+
+-- Supports finding an agent's idle sessions
+CREATE INDEX IF NOT EXISTS idx_sessions_agent_activity ON neurondb_agent.sessions(agent_id, last_activity_at);
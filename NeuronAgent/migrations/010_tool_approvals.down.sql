@@ -0,0 +1,2 @@
+-- Revert 010_tool_approvals
+DROP TABLE IF EXISTS neurondb_agent.tool_approvals;
@@ -0,0 +1,27 @@
+-- Tool approvals: agent runs paused until an operator approves or rejects
+-- tool calls. run_state holds what the run needs to resume, so a pending
+-- run survives a restart.
+CREATE TABLE IF NOT EXISTS neurondb_agent.tool_approvals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES neurondb_agent.sessions(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'expired', 'completed', 'failed')),
+    tool_calls JSONB NOT NULL,  -- the calls awaiting a decision
+    run_state JSONB NOT NULL,
+    decided_by TEXT,
+    reason TEXT,
+    result JSONB,               -- the resumed run's answer once completed
+    error_message TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    decided_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_approvals_status_created ON neurondb_agent.tool_approvals(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_tool_approvals_agent_created ON neurondb_agent.tool_approvals(agent_id, created_at DESC);
+
+-- A session has at most one run waiting for a decision
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tool_approvals_session_pending
+    ON neurondb_agent.tool_approvals(session_id) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+-- Revert 011_webhooks
+DROP TABLE IF EXISTS neurondb_agent.webhook_deliveries;
+DROP TABLE IF EXISTS neurondb_agent.webhooks;
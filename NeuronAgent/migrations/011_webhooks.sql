@@ -0,0 +1,43 @@
+-- Webhooks: endpoints subscribed to lifecycle events, and the queue of
+-- deliveries to them. A delivery that keeps failing is dead-lettered with
+-- status 'dead' and kept for inspection and redelivery.
+CREATE TABLE IF NOT EXISTS neurondb_agent.webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    url TEXT NOT NULL,
+    events TEXT[] NOT NULL,     -- event types; '*' subscribes to all
+    secret_env TEXT,            -- environment variable holding the signing secret
+    description TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER webhooks_updated_at BEFORE UPDATE ON neurondb_agent.webhooks
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.update_updated_at();
+
+CREATE TABLE IF NOT EXISTS neurondb_agent.webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    -- NULL for deliveries to an agent's own endpoint, such as its tool
+    -- approval webhook
+    webhook_id UUID REFERENCES neurondb_agent.webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event TEXT NOT NULL,
+    url TEXT NOT NULL,
+    secret_env TEXT,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+    ON neurondb_agent.webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_created
+    ON neurondb_agent.webhook_deliveries(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created
+    ON neurondb_agent.webhook_deliveries(webhook_id, created_at DESC);
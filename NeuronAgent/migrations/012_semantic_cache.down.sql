@@ -0,0 +1,2 @@
+-- Revert 012_semantic_cache
+DROP TABLE IF EXISTS neurondb_agent.semantic_cache;
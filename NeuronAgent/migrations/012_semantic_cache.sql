@@ -0,0 +1,25 @@
+-- Semantic cache: prior answers of an agent keyed by the embedding of the
+-- prompt that produced them. An entry only serves the agent version and
+-- model it was stored under, so editing the agent invalidates its cache.
+CREATE TABLE IF NOT EXISTS neurondb_agent.semantic_cache (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    agent_version BIGINT NOT NULL,
+    model_name TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    prompt_embedding neurondb_vector(768) NOT NULL,
+    response TEXT NOT NULL,
+    tokens_used INT NOT NULL DEFAULT 0,     -- tokens the original answer took
+    cost_usd NUMERIC(14, 6) NOT NULL DEFAULT 0,  -- cost of the original answer
+    hit_count INT NOT NULL DEFAULT 0,
+    last_hit_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ                  -- NULL never expires
+);
+
+CREATE INDEX IF NOT EXISTS idx_semantic_cache_embedding_hnsw ON neurondb_agent.semantic_cache
+    USING hnsw (prompt_embedding vector_cosine_ops)
+    WITH (m = 16, ef_construction = 64);
+
+CREATE INDEX IF NOT EXISTS idx_semantic_cache_agent
+    ON neurondb_agent.semantic_cache(agent_id, agent_version, model_name);
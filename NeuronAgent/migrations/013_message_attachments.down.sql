@@ -0,0 +1,2 @@
+-- Revert 013_message_attachments
+DROP TABLE IF EXISTS neurondb_agent.message_attachments;
@@ -0,0 +1,26 @@
+-- Message attachments: files sent with a user message. Their text is
+-- chunked into the agent's memory; this table keeps what was received and
+-- how it was processed.
+CREATE TABLE IF NOT EXISTS neurondb_agent.message_attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES neurondb_agent.sessions(id) ON DELETE CASCADE,
+    -- the user message; NULL until the message is stored
+    message_id BIGINT REFERENCES neurondb_agent.messages(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('inline', 'url')),
+    source_url TEXT,            -- without its query string, so presigned credentials are not kept
+    status TEXT NOT NULL CHECK (status IN ('processed', 'unsupported', 'failed')),
+    error TEXT,
+    text_length INT NOT NULL DEFAULT 0,
+    chunk_count INT NOT NULL DEFAULT 0,
+    summary TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_attachments_session
+    ON neurondb_agent.message_attachments(session_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_message_attachments_message
+    ON neurondb_agent.message_attachments(message_id);
@@ -0,0 +1,20 @@
+-- Revert 014_organizations
+DROP TRIGGER IF EXISTS memory_chunks_archive_organization ON neurondb_agent.memory_chunks_archive;
+DROP TRIGGER IF EXISTS memory_chunks_organization ON neurondb_agent.memory_chunks;
+DROP TRIGGER IF EXISTS sessions_archive_organization ON neurondb_agent.sessions_archive;
+DROP TRIGGER IF EXISTS sessions_organization ON neurondb_agent.sessions;
+DROP FUNCTION IF EXISTS neurondb_agent.inherit_agent_organization();
+
+DROP INDEX IF EXISTS neurondb_agent.idx_memory_chunks_organization;
+DROP INDEX IF EXISTS neurondb_agent.idx_sessions_archive_organization;
+DROP INDEX IF EXISTS neurondb_agent.idx_sessions_organization;
+DROP INDEX IF EXISTS neurondb_agent.idx_agents_organization_name;
+
+-- Fails if two organizations have agents of the same name
+ALTER TABLE neurondb_agent.agents ADD CONSTRAINT agents_name_key UNIQUE (name);
+
+ALTER TABLE neurondb_agent.memory_chunks_archive DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE neurondb_agent.memory_chunks DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE neurondb_agent.sessions_archive DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE neurondb_agent.sessions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE neurondb_agent.agents DROP COLUMN IF EXISTS organization_id;
@@ -0,0 +1,40 @@
+-- Multi-tenant isolation: agents belong to the organization of the API key
+-- that created them, and sessions and memory belong to their agent's
+-- organization. Rows with no organization belong to keys with none, which
+-- keeps single-tenant deployments working unchanged.
+ALTER TABLE neurondb_agent.agents ADD COLUMN IF NOT EXISTS organization_id TEXT;
+ALTER TABLE neurondb_agent.sessions ADD COLUMN IF NOT EXISTS organization_id TEXT;
+ALTER TABLE neurondb_agent.sessions_archive ADD COLUMN IF NOT EXISTS organization_id TEXT;
+ALTER TABLE neurondb_agent.memory_chunks ADD COLUMN IF NOT EXISTS organization_id TEXT;
+ALTER TABLE neurondb_agent.memory_chunks_archive ADD COLUMN IF NOT EXISTS organization_id TEXT;
+
+-- Agent names are unique within an organization rather than globally
+ALTER TABLE neurondb_agent.agents DROP CONSTRAINT IF EXISTS agents_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_organization_name
+    ON neurondb_agent.agents(COALESCE(organization_id, ''), name);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_organization ON neurondb_agent.sessions(organization_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_archive_organization ON neurondb_agent.sessions_archive(organization_id);
+CREATE INDEX IF NOT EXISTS idx_memory_chunks_organization ON neurondb_agent.memory_chunks(organization_id);
+
+-- Sessions and memory take the organization of their agent, whichever
+-- path inserts them
+CREATE OR REPLACE FUNCTION neurondb_agent.inherit_agent_organization()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.organization_id = (SELECT organization_id FROM neurondb_agent.agents WHERE id = NEW.agent_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sessions_organization BEFORE INSERT ON neurondb_agent.sessions
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.inherit_agent_organization();
+
+CREATE TRIGGER sessions_archive_organization BEFORE INSERT ON neurondb_agent.sessions_archive
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.inherit_agent_organization();
+
+CREATE TRIGGER memory_chunks_organization BEFORE INSERT ON neurondb_agent.memory_chunks
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.inherit_agent_organization();
+
+CREATE TRIGGER memory_chunks_archive_organization BEFORE INSERT ON neurondb_agent.memory_chunks_archive
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.inherit_agent_organization();
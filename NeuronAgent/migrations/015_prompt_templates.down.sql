@@ -0,0 +1,11 @@
+-- Revert 015_prompt_templates
+DROP INDEX IF EXISTS neurondb_agent.idx_messages_prompt_version;
+DROP INDEX IF EXISTS neurondb_agent.idx_message_feedback_prompt_version;
+
+ALTER TABLE neurondb_agent.message_feedback DROP COLUMN IF EXISTS prompt_version_id;
+ALTER TABLE neurondb_agent.messages DROP COLUMN IF EXISTS prompt_version_id;
+ALTER TABLE neurondb_agent.sessions DROP COLUMN IF EXISTS prompt_version_id;
+ALTER TABLE neurondb_agent.agents DROP COLUMN IF EXISTS prompt_template_id;
+
+DROP TABLE IF EXISTS neurondb_agent.prompt_template_versions;
+DROP TABLE IF EXISTS neurondb_agent.prompt_templates;
@@ -0,0 +1,48 @@
+-- Prompt templates: versioned system prompts with variables. An agent using
+-- a template serves each session one of its versions, chosen by rollout
+-- weight and kept for the life of the session, so versions can be compared
+-- on the feedback of the messages they served.
+CREATE TABLE IF NOT EXISTS neurondb_agent.prompt_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    description TEXT,
+    organization_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_templates_organization_name
+    ON neurondb_agent.prompt_templates(COALESCE(organization_id, ''), name);
+
+CREATE TRIGGER prompt_templates_updated_at BEFORE UPDATE ON neurondb_agent.prompt_templates
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.update_updated_at();
+
+CREATE TABLE IF NOT EXISTS neurondb_agent.prompt_template_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    template_id UUID NOT NULL REFERENCES neurondb_agent.prompt_templates(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    content TEXT NOT NULL,
+    -- variable name to default value; a null default makes the variable required
+    variables JSONB NOT NULL DEFAULT '{}',
+    -- share of new sessions served this version, relative to the other versions
+    rollout_weight INT NOT NULL DEFAULT 0 CHECK (rollout_weight >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (template_id, version)
+);
+
+ALTER TABLE neurondb_agent.agents ADD COLUMN IF NOT EXISTS prompt_template_id UUID
+    REFERENCES neurondb_agent.prompt_templates(id) ON DELETE SET NULL;
+
+-- The version a session was assigned, and the version that served each
+-- assistant message and so each piece of feedback
+ALTER TABLE neurondb_agent.sessions ADD COLUMN IF NOT EXISTS prompt_version_id UUID
+    REFERENCES neurondb_agent.prompt_template_versions(id) ON DELETE SET NULL;
+ALTER TABLE neurondb_agent.messages ADD COLUMN IF NOT EXISTS prompt_version_id UUID
+    REFERENCES neurondb_agent.prompt_template_versions(id) ON DELETE SET NULL;
+ALTER TABLE neurondb_agent.message_feedback ADD COLUMN IF NOT EXISTS prompt_version_id UUID
+    REFERENCES neurondb_agent.prompt_template_versions(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_message_feedback_prompt_version
+    ON neurondb_agent.message_feedback(prompt_version_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_messages_prompt_version
+    ON neurondb_agent.messages(prompt_version_id) WHERE prompt_version_id IS NOT NULL;
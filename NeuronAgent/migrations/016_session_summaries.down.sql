@@ -0,0 +1,2 @@
+-- Revert 016_session_summaries
+DROP TABLE IF EXISTS neurondb_agent.session_summaries;
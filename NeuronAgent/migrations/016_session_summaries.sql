@@ -0,0 +1,13 @@
+-- Rolling summaries of session history. When a prompt exceeds the model's
+-- context length, the oldest messages of the session are folded into its
+-- summary, which stands in for them in later prompts. Once a session has a
+-- summary it is extended as further messages leave the history window.
+CREATE TABLE IF NOT EXISTS neurondb_agent.session_summaries (
+    session_id UUID PRIMARY KEY REFERENCES neurondb_agent.sessions(id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    -- the newest message the summary covers; later messages are not in it
+    through_message_id BIGINT NOT NULL,
+    message_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
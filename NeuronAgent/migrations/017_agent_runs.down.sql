@@ -0,0 +1,2 @@
+-- Revert 017_agent_runs
+DROP TABLE IF EXISTS neurondb_agent.agent_runs;
@@ -0,0 +1,27 @@
+-- Agent runs: the execution of a message by its agent. The run's state is
+-- checkpointed after every step, so a run whose server died can be resumed
+-- from its last checkpoint or failed by another server. A running run whose
+-- heartbeat stops is considered interrupted.
+CREATE TABLE IF NOT EXISTS neurondb_agent.agent_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES neurondb_agent.sessions(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'awaiting_approval', 'completed', 'failed')),
+    step TEXT NOT NULL,         -- the last step checkpointed
+    checkpoint JSONB NOT NULL,  -- what the run needs to continue after step
+    result JSONB,               -- the answer once completed
+    error_message TEXT,
+    attempts INT NOT NULL DEFAULT 1,  -- times the run was started or resumed
+    approval_id UUID REFERENCES neurondb_agent.tool_approvals(id) ON DELETE SET NULL,
+    heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_runs_session_created ON neurondb_agent.agent_runs(session_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_agent_runs_approval ON neurondb_agent.agent_runs(approval_id) WHERE approval_id IS NOT NULL;
+-- Interrupted runs are found by their heartbeat
+CREATE INDEX IF NOT EXISTS idx_agent_runs_running_heartbeat
+    ON neurondb_agent.agent_runs(heartbeat_at) WHERE status = 'running';
@@ -0,0 +1,6 @@
+-- Revert 018_rate_limits
+DROP TRIGGER IF EXISTS usage_ledger_quota ON neurondb_agent.usage_ledger;
+DROP FUNCTION IF EXISTS neurondb_agent.count_quota_tokens();
+DROP FUNCTION IF EXISTS neurondb_agent.take_rate_limit_token(TEXT, INT);
+DROP TABLE IF EXISTS neurondb_agent.quota_usage;
+DROP TABLE IF EXISTS neurondb_agent.rate_limit_buckets;
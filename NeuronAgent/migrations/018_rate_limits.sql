@@ -0,0 +1,77 @@
+-- Rate limits and daily quotas shared by every replica. Request rates are
+-- token buckets refilled at the key's per-minute limit; quotas count the
+-- requests and LLM tokens of an API key or organization on the current day
+-- (UTC), starting again from zero on the next.
+CREATE TABLE IF NOT EXISTS neurondb_agent.rate_limit_buckets (
+    bucket TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS neurondb_agent.quota_usage (
+    scope TEXT NOT NULL CHECK (scope IN ('api_key', 'organization')),
+    subject TEXT NOT NULL,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    tokens BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (scope, subject)
+);
+
+-- Takes a token from a bucket holding up to per_minute tokens, refilled
+-- continuously at per_minute a minute. The row lock serializes replicas
+-- taking from the same bucket, and the refill is computed once it is held.
+CREATE OR REPLACE FUNCTION neurondb_agent.take_rate_limit_token(
+    p_bucket TEXT, p_per_minute INT, OUT allowed BOOLEAN, OUT remaining DOUBLE PRECISION)
+AS $$
+BEGIN
+    INSERT INTO neurondb_agent.rate_limit_buckets (bucket, tokens, updated_at)
+    VALUES (p_bucket, p_per_minute, clock_timestamp())
+    ON CONFLICT (bucket) DO NOTHING;
+
+    UPDATE neurondb_agent.rate_limit_buckets SET
+        tokens = LEAST(p_per_minute,
+            tokens + GREATEST(0, EXTRACT(EPOCH FROM clock_timestamp() - updated_at)) * p_per_minute / 60.0),
+        updated_at = clock_timestamp()
+    WHERE bucket = p_bucket
+    RETURNING tokens INTO remaining;
+
+    allowed := remaining >= 1;
+    IF allowed THEN
+        UPDATE neurondb_agent.rate_limit_buckets SET tokens = tokens - 1
+        WHERE bucket = p_bucket
+        RETURNING tokens INTO remaining;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- LLM tokens count against the daily quotas of the call's API key and its
+-- organization. Only subjects with a quota have a row to count in. The
+-- organization's row is updated first, the order quota checks lock them in.
+CREATE OR REPLACE FUNCTION neurondb_agent.count_quota_tokens()
+RETURNS TRIGGER AS $$
+DECLARE
+    today DATE := (NOW() AT TIME ZONE 'UTC')::date;
+    key_organization TEXT;
+BEGIN
+    IF NEW.api_key_id IS NULL OR NEW.total_tokens = 0 THEN
+        RETURN NEW;
+    END IF;
+    SELECT organization_id INTO key_organization FROM neurondb_agent.api_keys WHERE id = NEW.api_key_id;
+    IF key_organization IS NOT NULL THEN
+        UPDATE neurondb_agent.quota_usage SET
+            requests = CASE WHEN day = today THEN requests ELSE 0 END,
+            tokens = CASE WHEN day = today THEN tokens ELSE 0 END + NEW.total_tokens,
+            day = today
+        WHERE scope = 'organization' AND subject = key_organization;
+    END IF;
+    UPDATE neurondb_agent.quota_usage SET
+        requests = CASE WHEN day = today THEN requests ELSE 0 END,
+        tokens = CASE WHEN day = today THEN tokens ELSE 0 END + NEW.total_tokens,
+        day = today
+    WHERE scope = 'api_key' AND subject = NEW.api_key_id::text;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER usage_ledger_quota AFTER INSERT ON neurondb_agent.usage_ledger
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.count_quota_tokens();
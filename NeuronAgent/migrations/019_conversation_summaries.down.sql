@@ -0,0 +1,2 @@
+-- Revert 019_conversation_summaries
+DROP TABLE IF EXISTS neurondb_agent.conversation_summaries;
@@ -0,0 +1,16 @@
+-- Summaries of whole sessions served by the session summary endpoint, one
+-- per style and length. Unlike session_summaries they are never part of a
+-- prompt. A cached summary is extended with the messages added after it
+-- when it is next requested.
+CREATE TABLE IF NOT EXISTS neurondb_agent.conversation_summaries (
+    session_id UUID NOT NULL REFERENCES neurondb_agent.sessions(id) ON DELETE CASCADE,
+    style TEXT NOT NULL,
+    length TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    -- the newest message the summary covers; later messages are not in it
+    through_message_id BIGINT NOT NULL,
+    message_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, style, length)
+);
@@ -0,0 +1,2 @@
+-- Revert 020_llm_logs
+DROP TABLE IF EXISTS neurondb_agent.llm_logs;
@@ -0,0 +1,27 @@
+-- Sampled LLM prompts and responses of agents with llm_logging enabled,
+-- for debugging model behavior. Secrets and PII are redacted before they
+-- are stored unless the agent turns redaction off.
+CREATE TABLE IF NOT EXISTS neurondb_agent.llm_logs (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id UUID NOT NULL REFERENCES neurondb_agent.agents(id) ON DELETE CASCADE,
+    session_id UUID REFERENCES neurondb_agent.sessions(id) ON DELETE CASCADE,
+    run_id UUID,
+    purpose TEXT NOT NULL,                  -- answer, tool_results
+    model TEXT NOT NULL,
+    provider TEXT,
+    prompt TEXT NOT NULL,
+    response TEXT,                          -- NULL when the call failed
+    error_message TEXT,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    latency_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    redactions JSONB NOT NULL DEFAULT '{}', -- redacted values by type
+    truncated BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_logs_agent_created
+    ON neurondb_agent.llm_logs(agent_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_llm_logs_session
+    ON neurondb_agent.llm_logs(session_id) WHERE session_id IS NOT NULL;
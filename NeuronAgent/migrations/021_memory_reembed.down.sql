@@ -0,0 +1,6 @@
+-- Revert 021_memory_reembed
+DROP TABLE IF EXISTS neurondb_agent.memory_reembeddings;
+DELETE FROM neurondb_agent.jobs WHERE type = 'memory_reembed';
+ALTER TABLE neurondb_agent.jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+ALTER TABLE neurondb_agent.jobs ADD CONSTRAINT jobs_type_check
+    CHECK (type IN ('http_call', 'sql_task', 'shell_task', 'memory_backfill', 'custom'));
@@ -0,0 +1,16 @@
+-- Memory re-embedding: background jobs that move an agent's memory to a new
+-- embedding model. The new vectors are staged here and copied over the old
+-- ones in one transaction once every chunk has one.
+ALTER TABLE neurondb_agent.jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+ALTER TABLE neurondb_agent.jobs ADD CONSTRAINT jobs_type_check
+    CHECK (type IN ('http_call', 'sql_task', 'shell_task', 'memory_backfill', 'memory_reembed', 'custom'));
+
+CREATE TABLE IF NOT EXISTS neurondb_agent.memory_reembeddings (
+    job_id BIGINT NOT NULL REFERENCES neurondb_agent.jobs(id) ON DELETE CASCADE,
+    chunk_id BIGINT NOT NULL REFERENCES neurondb_agent.memory_chunks(id) ON DELETE CASCADE,
+    embedding neurondb_vector NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (job_id, chunk_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_reembeddings_chunk_id ON neurondb_agent.memory_reembeddings(chunk_id);
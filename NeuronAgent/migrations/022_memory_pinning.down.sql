@@ -0,0 +1,3 @@
+-- Revert 022_memory_pinning
+DROP INDEX IF EXISTS neurondb_agent.idx_memory_chunks_agent_created;
+ALTER TABLE neurondb_agent.memory_chunks DROP COLUMN IF EXISTS pinned;
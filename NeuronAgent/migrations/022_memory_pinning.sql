@@ -0,0 +1,5 @@
+-- Memory curation: operators list an agent's chunks newest first and pin
+-- the ones memory retention must never evict
+ALTER TABLE neurondb_agent.memory_chunks ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_memory_chunks_agent_created ON neurondb_agent.memory_chunks(agent_id, created_at DESC, id DESC);
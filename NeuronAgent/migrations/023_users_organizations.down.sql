@@ -0,0 +1,4 @@
+-- Revert 023_users_organizations
+ALTER TABLE neurondb_agent.agents DROP COLUMN IF EXISTS owner_user_id;
+DROP TABLE IF EXISTS neurondb_agent.users;
+DROP TABLE IF EXISTS neurondb_agent.organizations;
@@ -0,0 +1,37 @@
+-- Organizations and their users. organizations.id is the organization_id
+-- of API keys, agents and sessions, so an organization already used by keys
+-- can be registered under its existing id. Keys and agents may still name
+-- unregistered organizations, as keys from an identity provider do.
+CREATE TABLE IF NOT EXISTS neurondb_agent.organizations (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER organizations_updated_at BEFORE UPDATE ON neurondb_agent.organizations
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.update_updated_at();
+
+-- A user is invited into one organization and becomes active when its
+-- first API key is created. Disabling a user revokes its keys.
+CREATE TABLE IF NOT EXISTS neurondb_agent.users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id TEXT NOT NULL REFERENCES neurondb_agent.organizations(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    name TEXT,
+    roles TEXT[] NOT NULL DEFAULT '{user}',
+    status TEXT NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'active', 'disabled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_user_roles CHECK (array_length(roles, 1) > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_organization_email
+    ON neurondb_agent.users(organization_id, lower(email));
+
+CREATE TRIGGER users_updated_at BEFORE UPDATE ON neurondb_agent.users
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.update_updated_at();
+
+-- The user whose API key created an agent
+ALTER TABLE neurondb_agent.agents ADD COLUMN IF NOT EXISTS owner_user_id TEXT;
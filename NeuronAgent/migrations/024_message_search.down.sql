@@ -0,0 +1,3 @@
+-- Revert 024_message_search
+DROP TABLE IF EXISTS neurondb_agent.message_embeddings;
+DROP INDEX IF EXISTS neurondb_agent.idx_messages_content_tsv;
@@ -0,0 +1,13 @@
+-- Message search: keyword search over message content, and embeddings of
+-- messages for semantic search. Messages are embedded by the searches that
+-- cover them, once per embedding model.
+CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON neurondb_agent.messages
+    USING gin (to_tsvector('english', content));
+
+CREATE TABLE IF NOT EXISTS neurondb_agent.message_embeddings (
+    message_id BIGINT NOT NULL REFERENCES neurondb_agent.messages(id) ON DELETE CASCADE,
+    model TEXT NOT NULL,
+    embedding neurondb_vector NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, model)
+);
@@ -0,0 +1,2 @@
+-- Revert 025_secrets
+DROP TABLE IF EXISTS neurondb_agent.secrets;
@@ -0,0 +1,17 @@
+-- Encrypted credentials that tool handler_config refers to as
+-- secret://name. Each value is encrypted with its own data key, stored
+-- wrapped by the master key named by key_id (envelope encryption), so only
+-- a server holding the master key can read it.
+CREATE TABLE IF NOT EXISTS neurondb_agent.secrets (
+    name TEXT PRIMARY KEY,
+    description TEXT,
+    ciphertext BYTEA NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    key_id TEXT NOT NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER secrets_updated_at BEFORE UPDATE ON neurondb_agent.secrets
+    FOR EACH ROW EXECUTE FUNCTION neurondb_agent.update_updated_at();
@@ -0,0 +1,11 @@
+// Package migrations embeds the neurondb_agent schema migrations into the
+// binary. NNN_name.sql applies version NNN and the optional
+// NNN_name.down.sql reverts it.
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
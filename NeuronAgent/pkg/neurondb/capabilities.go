@@ -0,0 +1,90 @@
+package neurondb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// NeuronDB functions the clients call
+const (
+	FunctionEmbed             = "neurondb_embed"
+	FunctionEmbedBatch        = "neurondb_embed_batch"
+	FunctionLLMGenerate       = "neurondb_llm_generate"
+	FunctionLLMComplete       = "neurondb_llm_complete"
+	FunctionLLMGenerateStream = "neurondb_llm_generate_stream"
+)
+
+var probedFunctions = []string{
+	FunctionEmbed,
+	FunctionEmbedBatch,
+	FunctionLLMGenerate,
+	FunctionLLMComplete,
+	FunctionLLMGenerateStream,
+}
+
+// Capabilities records which NeuronDB functions the database has. Builds of
+// the extension without its ML functions, or plain Postgres, lack some or
+// all of them. A nil *Capabilities assumes every function is there.
+type Capabilities struct {
+	Functions  map[string]bool `json:"functions"`
+	DetectedAt time.Time       `json:"detected_at"`
+}
+
+// DetectCapabilities probes the database for the functions the clients
+// call. Only functions visible on the search path count, as those are the
+// ones unqualified calls find.
+func DetectCapabilities(ctx context.Context, db *sqlx.DB) (*Capabilities, error) {
+	var found []string
+	err := db.SelectContext(ctx, &found, `
+		SELECT DISTINCT p.proname FROM pg_proc p
+		WHERE p.proname = ANY($1) AND pg_function_is_visible(p.oid)`,
+		pq.Array(probedFunctions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe NeuronDB functions: %w", err)
+	}
+
+	caps := &Capabilities{
+		Functions:  make(map[string]bool, len(probedFunctions)),
+		DetectedAt: time.Now().UTC(),
+	}
+	for _, name := range probedFunctions {
+		caps.Functions[name] = false
+	}
+	for _, name := range found {
+		caps.Functions[name] = true
+	}
+	return caps, nil
+}
+
+// Has reports whether the database has the function
+func (c *Capabilities) Has(function string) bool {
+	if c == nil {
+		return true
+	}
+	return c.Functions[function]
+}
+
+// Embedding reports whether NeuronDB can embed texts
+func (c *Capabilities) Embedding() bool {
+	return c.Has(FunctionEmbed)
+}
+
+// LLM reports whether NeuronDB can generate completions
+func (c *Capabilities) LLM() bool {
+	return c.Has(FunctionLLMGenerate) || c.Has(FunctionLLMComplete)
+}
+
+// Missing lists the probed functions the database lacks
+func (c *Capabilities) Missing() []string {
+	var missing []string
+	for _, name := range probedFunctions {
+		if !c.Has(name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -2,15 +2,25 @@ package neurondb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
-// EmbeddingClient handles embedding generation via NeuronDB
+// ErrEmbeddingUnavailable is returned when the database has no embedding
+// function and no embedding provider is configured
+var ErrEmbeddingUnavailable = errors.New("embeddings are unavailable: the database has no neurondb_embed function and no embedding provider is configured")
+
+// EmbeddingClient handles embedding generation via NeuronDB. When the
+// database lacks NeuronDB's embedding functions, texts are embedded with
+// the provider set by SetProvider, if any.
 type EmbeddingClient struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	caps     *Capabilities
+	provider EmbeddingProvider
 }
 
 // NewEmbeddingClient creates a new embedding client
@@ -18,8 +28,46 @@ func NewEmbeddingClient(db *sqlx.DB) *EmbeddingClient {
 	return &EmbeddingClient{db: db}
 }
 
+// SetCapabilities sets the functions the database was found to have. It
+// must be called before the client is used.
+func (c *EmbeddingClient) SetCapabilities(caps *Capabilities) {
+	c.caps = caps
+}
+
+// SetProvider sets the provider embedding texts when the database has no
+// embedding function. It must be called before the client is used.
+func (c *EmbeddingClient) SetProvider(provider EmbeddingProvider) {
+	c.provider = provider
+}
+
+// Source names what embeds texts: "neurondb", the provider as type:model,
+// or "none"
+func (c *EmbeddingClient) Source() string {
+	switch {
+	case c.caps.Embedding():
+		return "neurondb"
+	case c.provider != nil:
+		return c.provider.Name()
+	default:
+		return "none"
+	}
+}
+
+// Available reports whether texts can be embedded
+func (c *EmbeddingClient) Available() bool {
+	return c.caps.Embedding() || c.provider != nil
+}
+
 // Embed generates an embedding for the given text using the specified model
 func (c *EmbeddingClient) Embed(ctx context.Context, text string, model string) (Vector, error) {
+	if !c.caps.Embedding() {
+		embeddings, err := c.embedWithProvider(ctx, []string{text})
+		if err != nil {
+			return nil, err
+		}
+		return embeddings[0], nil
+	}
+
 	var embeddingStr string
 	query := `SELECT neurondb_embed($1, $2)::text AS embedding`
 	
@@ -45,11 +93,18 @@ func (c *EmbeddingClient) Embed(ctx context.Context, text string, model string)
 
 // EmbedBatch generates embeddings for multiple texts
 func (c *EmbeddingClient) EmbedBatch(ctx context.Context, texts []string, model string) ([]Vector, error) {
+	if !c.caps.Embedding() {
+		return c.embedWithProvider(ctx, texts)
+	}
+	if !c.caps.Has(FunctionEmbedBatch) {
+		return c.embedBatchFallback(ctx, texts, model)
+	}
+
 	// Use array format for batch embedding if available
 	query := `SELECT neurondb_embed_batch($1::text[], $2) AS embeddings`
 	
 	var embeddingsStr string
-	err := c.db.GetContext(ctx, &embeddingsStr, query, texts, model)
+	err := c.db.GetContext(ctx, &embeddingsStr, query, pq.Array(texts), model)
 	if err != nil {
 		// Fallback to individual embeddings if batch function not available
 		return c.embedBatchFallback(ctx, texts, model)
@@ -83,6 +138,15 @@ func (c *EmbeddingClient) embedBatchFallback(ctx context.Context, texts []string
 	return embeddings, nil
 }
 
+// embedWithProvider embeds texts with the provider, for databases without
+// an embedding function
+func (c *EmbeddingClient) embedWithProvider(ctx context.Context, texts []string) ([]Vector, error) {
+	if c.provider == nil {
+		return nil, ErrEmbeddingUnavailable
+	}
+	return c.provider.EmbedBatch(ctx, texts)
+}
+
 // parseVector parses a vector string like "[1.0, 2.0, 3.0]" into a Vector
 func parseVector(s string) (Vector, error) {
 	// Remove brackets
@@ -0,0 +1,180 @@
+package neurondb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Embedding provider types
+const (
+	EmbeddingProviderOpenAI = "openai"
+	EmbeddingProviderOllama = "ollama"
+)
+
+const (
+	defaultEmbeddingProviderTimeout = 60 * time.Second
+	maxEmbeddingResponseBytes       = 64 * 1024 * 1024
+)
+
+var defaultEmbeddingEndpoints = map[string]string{
+	EmbeddingProviderOpenAI: "https://api.openai.com/v1",
+	EmbeddingProviderOllama: "http://localhost:11434",
+}
+
+// EmbeddingProvider embeds texts outside the database. Its model replaces
+// the model named in each call.
+type EmbeddingProvider interface {
+	// Name names the provider as type:model
+	Name() string
+	EmbedBatch(ctx context.Context, texts []string) ([]Vector, error)
+}
+
+// HTTPEmbeddingProvider embeds texts with the OpenAI embeddings API, or an
+// API compatible with it, or with Ollama
+type HTTPEmbeddingProvider struct {
+	providerType string
+	model        string
+	endpoint     string
+	apiKey       string
+	dimensions   int // asks OpenAI for vectors of this size; 0 for the model's own
+	httpClient   *http.Client
+}
+
+// NewHTTPEmbeddingProvider creates a provider of the given type. An empty
+// endpoint selects the provider's public one, and a zero timeout 60s.
+func NewHTTPEmbeddingProvider(providerType, model, endpoint, apiKey string, dimensions int, timeout time.Duration) (*HTTPEmbeddingProvider, error) {
+	if providerType != EmbeddingProviderOpenAI && providerType != EmbeddingProviderOllama {
+		return nil, fmt.Errorf("embedding provider type must be openai or ollama, got '%s'", providerType)
+	}
+	if model == "" {
+		return nil, fmt.Errorf("embedding provider %s needs a model", providerType)
+	}
+	if endpoint == "" {
+		endpoint = defaultEmbeddingEndpoints[providerType]
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("embedding provider endpoint must be an http(s) URL, got '%s'", endpoint)
+	}
+	if providerType == EmbeddingProviderOpenAI && apiKey == "" {
+		return nil, fmt.Errorf("embedding provider openai needs an API key")
+	}
+	if timeout <= 0 {
+		timeout = defaultEmbeddingProviderTimeout
+	}
+	return &HTTPEmbeddingProvider{
+		providerType: providerType,
+		model:        model,
+		endpoint:     strings.TrimRight(endpoint, "/"),
+		apiKey:       apiKey,
+		dimensions:   dimensions,
+		httpClient:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name names the provider as type:model
+func (p *HTTPEmbeddingProvider) Name() string {
+	return p.providerType + ":" + p.model
+}
+
+// EmbedBatch embeds the texts in one request
+func (p *HTTPEmbeddingProvider) EmbedBatch(ctx context.Context, texts []string) ([]Vector, error) {
+	if len(texts) == 0 {
+		return []Vector{}, nil
+	}
+
+	var url string
+	body := map[string]interface{}{"model": p.model, "input": texts}
+	switch p.providerType {
+	case EmbeddingProviderOpenAI:
+		url = p.endpoint + "/embeddings"
+		if p.dimensions > 0 {
+			body["dimensions"] = p.dimensions
+		}
+	case EmbeddingProviderOllama:
+		url = p.endpoint + "/api/embed"
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request to %s failed: provider='%s', text_count=%d, error=%w", url, p.Name(), len(texts), err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxEmbeddingResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response from %s: %w", url, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		preview := string(respBody)
+		if len(preview) > 200 {
+			preview = preview[:200] + "..."
+		}
+		return nil, fmt.Errorf("embedding request to %s returned HTTP %d: provider='%s', body='%s'", url, resp.StatusCode, p.Name(), preview)
+	}
+
+	embeddings, err := parseEmbeddingResponse(p.providerType, respBody)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding response: provider='%s', error=%w", p.Name(), err)
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding provider '%s' returned %d embeddings for %d texts", p.Name(), len(embeddings), len(texts))
+	}
+	return embeddings, nil
+}
+
+// parseEmbeddingResponse extracts the embeddings, in input order, from a
+// provider response body
+func parseEmbeddingResponse(providerType string, body []byte) ([]Vector, error) {
+	switch providerType {
+	case EmbeddingProviderOpenAI:
+		var resp struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		embeddings := make([]Vector, len(resp.Data))
+		for _, d := range resp.Data {
+			if d.Index < 0 || d.Index >= len(embeddings) || embeddings[d.Index] != nil {
+				return nil, fmt.Errorf("embedding index %d is out of range or repeated", d.Index)
+			}
+			embeddings[d.Index] = d.Embedding
+		}
+		return embeddings, nil
+	case EmbeddingProviderOllama:
+		var resp struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		embeddings := make([]Vector, len(resp.Embeddings))
+		for i, e := range resp.Embeddings {
+			embeddings[i] = e
+		}
+		return embeddings, nil
+	default:
+		return nil, fmt.Errorf("unsupported embedding provider type '%s'", providerType)
+	}
+}
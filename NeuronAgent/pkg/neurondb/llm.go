@@ -2,7 +2,9 @@ package neurondb
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -10,9 +12,14 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// ErrLLMUnavailable is returned when the database has neither
+// neurondb_llm_generate nor neurondb_llm_complete
+var ErrLLMUnavailable = errors.New("NeuronDB LLM generation is unavailable: the database has neither neurondb_llm_generate nor neurondb_llm_complete")
+
 // LLMClient handles LLM generation via NeuronDB
 type LLMClient struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	caps *Capabilities
 }
 
 // NewLLMClient creates a new LLM client
@@ -20,6 +27,17 @@ func NewLLMClient(db *sqlx.DB) *LLMClient {
 	return &LLMClient{db: db}
 }
 
+// SetCapabilities sets the functions the database was found to have, so
+// missing ones are not called. It must be called before the client is used.
+func (c *LLMClient) SetCapabilities(caps *Capabilities) {
+	c.caps = caps
+}
+
+// Available reports whether the database can generate completions
+func (c *LLMClient) Available() bool {
+	return c.caps.LLM()
+}
+
 // Generate generates text using the LLM with the given prompt and config
 func (c *LLMClient) Generate(ctx context.Context, prompt string, config LLMConfig) (*LLMGenerateResult, error) {
 	// Build parameters JSON
@@ -40,32 +58,38 @@ func (c *LLMClient) Generate(ctx context.Context, prompt string, config LLMConfi
 			config.Model, len(prompt), err)
 	}
 
+	if !c.Available() {
+		return nil, ErrLLMUnavailable
+	}
+
 	// Try neurondb_llm_generate first, fallback to neurondb_llm_complete
 	var output string
-	query := `SELECT neurondb_llm_generate($1, $2, $3::jsonb) AS output`
-	
-	err = c.db.GetContext(ctx, &output, query, config.Model, prompt, paramsJSON)
-	if err != nil {
+	err = fmt.Errorf("function %s is not installed", FunctionLLMGenerate)
+	if c.caps.Has(FunctionLLMGenerate) {
+		query := `SELECT neurondb_llm_generate($1, $2, $3::jsonb) AS output`
+		err = c.db.GetContext(ctx, &output, query, config.Model, prompt, paramsJSON)
+	}
+	if err != nil && c.caps.Has(FunctionLLMComplete) {
 		// Fallback to neurondb_llm_complete if available
-		query = `SELECT neurondb_llm_complete($1, $2, $3::jsonb) AS output`
+		query := `SELECT neurondb_llm_complete($1, $2, $3::jsonb) AS output`
 		err = c.db.GetContext(ctx, &output, query, config.Model, prompt, paramsJSON)
-		if err != nil {
-			promptTokens := len(strings.Split(prompt, " "))
-			temperature := "default"
-			if config.Temperature != nil {
-				temperature = fmt.Sprintf("%.2f", *config.Temperature)
-			}
-			maxTokens := "default"
-			if config.MaxTokens != nil {
-				maxTokens = fmt.Sprintf("%d", *config.MaxTokens)
-			}
-			topP := "default"
-			if config.TopP != nil {
-				topP = fmt.Sprintf("%.2f", *config.TopP)
-			}
-			return nil, fmt.Errorf("LLM generation failed via NeuronDB: model_name='%s', prompt_length=%d, prompt_tokens_approx=%d, temperature=%s, max_tokens=%s, top_p=%s, function='neurondb_llm_generate' (fallback: 'neurondb_llm_complete'), error=%w",
-				config.Model, len(prompt), promptTokens, temperature, maxTokens, topP, err)
+	}
+	if err != nil {
+		promptTokens := len(strings.Split(prompt, " "))
+		temperature := "default"
+		if config.Temperature != nil {
+			temperature = fmt.Sprintf("%.2f", *config.Temperature)
+		}
+		maxTokens := "default"
+		if config.MaxTokens != nil {
+			maxTokens = fmt.Sprintf("%d", *config.MaxTokens)
+		}
+		topP := "default"
+		if config.TopP != nil {
+			topP = fmt.Sprintf("%.2f", *config.TopP)
 		}
+		return nil, fmt.Errorf("LLM generation failed via NeuronDB: model_name='%s', prompt_length=%d, prompt_tokens_approx=%d, temperature=%s, max_tokens=%s, top_p=%s, function='neurondb_llm_generate' (fallback: 'neurondb_llm_complete'), error=%w",
+			config.Model, len(prompt), promptTokens, temperature, maxTokens, topP, err)
 	}
 
 	return &LLMGenerateResult{
@@ -97,9 +121,12 @@ func (c *LLMClient) GenerateStream(ctx context.Context, prompt string, config LL
 	}
 
 	// Try streaming query - if not supported, fall back to chunked writes
-	query := `SELECT neurondb_llm_generate_stream($1, $2, $3::jsonb) AS chunk`
-	
-	rows, err := c.db.QueryContext(ctx, query, config.Model, prompt, paramsJSON)
+	var rows *sql.Rows
+	err = fmt.Errorf("function %s is not installed", FunctionLLMGenerateStream)
+	if c.caps.Has(FunctionLLMGenerateStream) {
+		query := `SELECT neurondb_llm_generate_stream($1, $2, $3::jsonb) AS chunk`
+		rows, err = c.db.QueryContext(ctx, query, config.Model, prompt, paramsJSON)
+	}
 	if err != nil {
 		// Fallback: generate full response and write in chunks
 		result, err := c.Generate(ctx, prompt, config)
@@ -1,25 +1,21 @@
 #!/bin/bash
 # Migration runner script for NeuronAgent
+#
+# Migrations are compiled into the agent-server binary; this wraps
+# "agent-server migrate". Usage: run_migrations.sh [up|down [n]|status|baseline <version>]
 
 set -e
 
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
-MIGRATIONS_DIR="$SCRIPT_DIR/../migrations"
 
-DB_NAME="${DB_NAME:-neurondb}"
-DB_USER="${DB_USER:-postgres}"
-DB_HOST="${DB_HOST:-localhost}"
-DB_PORT="${DB_PORT:-5432}"
+export DB_NAME="${DB_NAME:-neurondb}"
+export DB_USER="${DB_USER:-postgres}"
+export DB_HOST="${DB_HOST:-localhost}"
+export DB_PORT="${DB_PORT:-5432}"
 
-echo "Running migrations..."
-
-# Run migrations in order
-for migration in "$MIGRATIONS_DIR"/*.sql; do
-    if [ -f "$migration" ]; then
-        echo "Running $(basename "$migration")..."
-        psql -h "$DB_HOST" -p "$DB_PORT" -U "$DB_USER" -d "$DB_NAME" -f "$migration"
-    fi
-done
-
-echo "Migrations complete!"
+if [ $# -eq 0 ]; then
+    set -- up
+fi
 
+cd "$SCRIPT_DIR/.."
+go run ./cmd/agent-server migrate "$@"
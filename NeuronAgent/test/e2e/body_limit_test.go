@@ -0,0 +1,77 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/neurondb/NeuronAgent/internal/api"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestOversizeJSONBodiesGet413(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := h.StubLLMResponse(ctx, "%small message%", "A small answer."); err != nil {
+		t.Fatal(err)
+	}
+
+	handlers := api.NewHandlers(h.Queries, h.NewRuntime(), nil, nil, nil, nil, nil)
+	router := mux.NewRouter()
+	apiRouter := router.PathPrefix("/api/v1").Subrouter()
+	apiRouter.Use(api.BodyLimitMiddleware(1024, nil))
+	apiRouter.HandleFunc("/sessions/{session_id}/messages", handlers.SendMessage).Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(body string, announceLength bool) (int, string) {
+		t.Helper()
+		// Without a known length the body is sent chunked, so it is only
+		// cut off while the handler decodes it
+		var reader io.Reader = strings.NewReader(body)
+		if !announceLength {
+			reader = io.MultiReader(reader)
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/sessions/"+session.ID.String()+"/messages", reader)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	oversize := `{"content": "` + strings.Repeat("a", 4096) + `"}`
+	for _, announce := range []bool{true, false} {
+		if code, body := send(oversize, announce); code != http.StatusRequestEntityTooLarge || !strings.Contains(body, "request body too large") {
+			t.Errorf("oversize body (length announced: %v) = %d %s, want 413", announce, code, body)
+		}
+	}
+	// Malformed bodies under the limit are still 400
+	if code, body := send(`{"content": `, false); code != http.StatusBadRequest {
+		t.Errorf("malformed body = %d %s, want 400", code, body)
+	}
+	if code, body := send(`{"content": "A small message"}`, false); code != http.StatusOK {
+		t.Errorf("body under the limit = %d %s, want 200", code, body)
+	}
+}
@@ -0,0 +1,109 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/tools"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+func TestDetectCapabilities(t *testing.T) {
+	h := setup(t)
+	caps, err := neurondb.DetectCapabilities(context.Background(), h.DB.DB)
+	if err != nil {
+		t.Fatalf("detect capabilities: %v", err)
+	}
+	for _, name := range []string{neurondb.FunctionEmbed, neurondb.FunctionEmbedBatch, neurondb.FunctionLLMGenerate} {
+		if !caps.Has(name) {
+			t.Errorf("%s was not found, but the harness stubs it", name)
+		}
+	}
+}
+
+func TestRuntimeWithoutNeuronDBFunctions(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	missing := &neurondb.Capabilities{Functions: map[string]bool{}}
+
+	embedClient := neurondb.NewEmbeddingClient(h.DB.DB)
+	runtime := agent.NewRuntime(h.DB, h.Queries, tools.NewRegistry(h.Queries, h.DB), embedClient)
+	runtime.SetCapabilities(missing)
+	report := runtime.Capabilities()
+	if report.Embedding != "none" || report.LLM != "none" || report.MemoryEnabled || !report.Degraded {
+		t.Errorf("report = %+v, want no embeddings, no LLM and memory disabled", report)
+	}
+	if _, err := runtime.Execute(ctx, session.ID, "Hello"); !errors.Is(err, neurondb.ErrLLMUnavailable) {
+		t.Errorf("execute = %v, want ErrLLMUnavailable", err)
+	}
+
+	// An OpenAI-compatible server stands in for both fallback providers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/embeddings":
+			var req struct {
+				Input []string `json:"input"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			data := make([]map[string]interface{}, len(req.Input))
+			for i := range req.Input {
+				data[i] = map[string]interface{}{"index": i, "embedding": make([]float32, 768)}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		case "/chat/completions":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"choices": []map[string]interface{}{{"message": map[string]string{"content": "Hello from the fallback."}}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("FALLBACK_TEST_KEY", "test")
+
+	embedClient = neurondb.NewEmbeddingClient(h.DB.DB)
+	provider, err := neurondb.NewHTTPEmbeddingProvider(neurondb.EmbeddingProviderOpenAI, "test-embed", server.URL, "test", 768, 0)
+	if err != nil {
+		t.Fatalf("embedding provider: %v", err)
+	}
+	embedClient.SetProvider(provider)
+	llm, err := agent.NewFallbackLLMProvider(agent.ProviderOpenAI, "test-chat", server.URL, "FALLBACK_TEST_KEY", 0)
+	if err != nil {
+		t.Fatalf("fallback LLM provider: %v", err)
+	}
+	runtime = agent.NewRuntime(h.DB, h.Queries, tools.NewRegistry(h.Queries, h.DB), embedClient)
+	runtime.SetCapabilities(missing)
+	runtime.SetFallbackLLM(llm)
+
+	report = runtime.Capabilities()
+	if report.Embedding != "openai:test-embed" || report.LLM != "openai:test-chat" || !report.MemoryEnabled {
+		t.Errorf("report = %+v, want both fallbacks in use and memory enabled", report)
+	}
+	state, err := runtime.Execute(ctx, session.ID, "Hello")
+	if err != nil {
+		t.Fatalf("execute with fallbacks: %v", err)
+	}
+	if state.FinalAnswer != "Hello from the fallback." {
+		t.Errorf("answer = %q", state.FinalAnswer)
+	}
+	embeddings, err := embedClient.EmbedBatch(ctx, []string{"a", "b"}, "ignored-model")
+	if err != nil || len(embeddings) != 2 || len(embeddings[1]) != 768 {
+		t.Errorf("embed batch = %d embeddings, %v; want 2 of 768 dimensions", len(embeddings), err)
+	}
+}
@@ -0,0 +1,148 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/api"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+func TestDocumentIngestStoresUploadInMemory(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	ingester, err := agent.NewDocumentIngester(h.Queries, neurondb.NewEmbeddingClient(h.DB.DB), t.TempDir())
+	if err != nil {
+		t.Fatalf("new document ingester: %v", err)
+	}
+	if err := ingester.Check(a); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	file, err := ingester.CreateUploadFile()
+	if err != nil {
+		t.Fatalf("create upload file: %v", err)
+	}
+	text := strings.Repeat("The deploy runs on fridays and rolls back on failure. ", 60)
+	if _, err := file.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	req := &agent.DocumentIngestRequest{
+		Path:      file.Name(),
+		Filename:  "runbook.md",
+		MIMEType:  "text/markdown",
+		SizeBytes: int64(len(text)),
+	}
+	payload, err := req.ToPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := h.Queries.CreateJob(ctx, &db.Job{
+		AgentID:    &a.ID,
+		Type:       agent.DocumentIngestJobType,
+		Status:     "queued",
+		Payload:    payload,
+		MaxRetries: 3,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	result, err := ingester.Run(ctx, job)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	progress, err := agent.ParseDocumentIngestProgress(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !progress.Completed || progress.TotalChunks < 3 || progress.ChunksStored != progress.TotalChunks || progress.Truncated {
+		t.Errorf("progress = %+v, want every chunk of the document stored", progress)
+	}
+	count, err := h.Queries.CountMemoryChunks(ctx, a.ID)
+	if err != nil || count != int64(progress.ChunksStored) {
+		t.Errorf("memory chunk count = %d, %v; want %d", count, err, progress.ChunksStored)
+	}
+	if _, err := os.Stat(file.Name()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("uploaded file still exists after ingestion: %v", err)
+	}
+
+	// Payload paths outside the upload directory are refused
+	outside := *job
+	outside.Payload = map[string]interface{}{"path": "/etc/passwd", "filename": "passwd", "mime_type": "text/plain"}
+	if _, err := ingester.Run(ctx, &outside); err == nil {
+		t.Error("ingested a file outside the upload directory")
+	}
+}
+
+func TestDocumentUploadsAreScopedToOrganization(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	owner, other := "org-"+uuid.NewString()[:8], "org-"+uuid.NewString()[:8]
+	a, err := h.CreateAgent(ctx, &db.Agent{OrganizationID: &owner})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	payload, err := (&agent.DocumentIngestRequest{Path: "/tmp/upload", Filename: "runbook.md", MIMEType: "text/markdown"}).ToPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := h.Queries.CreateJob(ctx, &db.Job{
+		AgentID:    &a.ID,
+		Type:       agent.DocumentIngestJobType,
+		Status:     "queued",
+		Payload:    payload,
+		MaxRetries: 3,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	handlers := api.NewHandlers(h.Queries, nil, nil, nil, nil, nil, nil)
+	get := func(org string) int {
+		t.Helper()
+		router := mux.NewRouter()
+		apiRouter := router.PathPrefix("/api/v1").Subrouter()
+		apiRouter.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := &db.APIKey{ID: uuid.New(), OrganizationID: &org, Roles: []string{auth.RoleUser}}
+				next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), key)))
+			})
+		})
+		apiRouter.Use(api.OrganizationMiddleware(h.Queries))
+		apiRouter.HandleFunc("/agents/{id}/documents/{job_id}", handlers.GetDocumentUpload).Methods("GET")
+
+		rec := httptest.NewRecorder()
+		path := "/api/v1/agents/" + a.ID.String() + "/documents/" + strconv.FormatInt(job.ID, 10)
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := get(owner); code != http.StatusOK {
+		t.Errorf("upload read by its organization = %d, want 200", code)
+	}
+	if code := get(other); code != http.StatusNotFound {
+		t.Errorf("upload read by another organization = %d, want 404", code)
+	}
+}
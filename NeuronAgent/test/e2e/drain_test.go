@@ -0,0 +1,175 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/neurondb/NeuronAgent/internal/api"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestDrainFinishesInFlightRequests(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	// The tool keeps a message in progress long enough to drain under it
+	tool := &db.Tool{
+		Name:          "slow_query",
+		Description:   "Runs a slow SQL query",
+		ArgSchema:     db.JSONBMap{"type": "object"},
+		HandlerType:   "sql",
+		HandlerConfig: db.JSONBMap{},
+		Enabled:       true,
+	}
+	if err := h.Queries.CreateTool(ctx, tool); err != nil {
+		t.Fatalf("create tool: %v", err)
+	}
+	a, err := h.CreateAgent(ctx, &db.Agent{EnabledTools: []string{"slow_query"}})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	inFlight, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	refused, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := h.StubLLMResponse(ctx, "%Take your time%", `<tool:slow_query:{"query": "SELECT 1 AS done FROM pg_sleep(2)"}>`); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.StubLLMResponse(ctx, "%Tool % result%", "Done."); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := h.NewRuntime()
+	drainer := api.NewDrainer(runtime, nil, time.Minute)
+	handlers := api.NewHandlers(h.Queries, runtime, nil, nil, nil, nil, nil)
+	handlers.SetDrainer(drainer)
+
+	router := mux.NewRouter()
+	apiRouter := router.PathPrefix("/api/v1").Subrouter()
+	apiRouter.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := &db.APIKey{ID: uuid.New(), Roles: []string{auth.RoleAdmin}}
+			next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), key)))
+		})
+	})
+	apiRouter.HandleFunc("/sessions/{session_id}/messages", handlers.SendMessage).Methods("POST")
+	apiRouter.HandleFunc("/admin/drain", handlers.StartDrain).Methods("POST")
+	apiRouter.HandleFunc("/admin/drain", handlers.GetDrainStatus).Methods("GET")
+	router.HandleFunc("/health/ready", api.HandleReadiness(drainer, h.DB.HealthCheck)).Methods("GET")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	do := func(method, path, body string) (int, http.Header, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header, string(b)
+	}
+	message := func(sessionID uuid.UUID) string {
+		return "/api/v1/sessions/" + sessionID.String() + "/messages"
+	}
+
+	if code, _, _ := do("GET", "/health/ready", ""); code != http.StatusOK {
+		t.Fatalf("readiness before draining = %d, want 200", code)
+	}
+
+	type response struct {
+		code int
+		body string
+	}
+	answered := make(chan response, 1)
+	go func() {
+		req, _ := http.NewRequest("POST", server.URL+message(inFlight.ID), bytes.NewBufferString(`{"content": "Take your time"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			answered <- response{body: err.Error()}
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		answered <- response{code: resp.StatusCode, body: string(b)}
+	}()
+	for deadline := time.Now().Add(5 * time.Second); runtime.InFlightRuns() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the message never started")
+		}
+	}
+
+	code, _, body := do("POST", "/api/v1/admin/drain", `{"timeout_seconds": 60}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("start drain = %d %s, want 202", code, body)
+	}
+	var status api.DrainStatus
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatalf("drain status: %v", err)
+	}
+	if !status.Draining || status.Completed || status.InFlightRuns != 1 {
+		t.Errorf("drain status = %+v, want draining with 1 run in flight", status)
+	}
+
+	// The server stops taking traffic while the message finishes
+	if code, _, _ := do("GET", "/health/ready", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("readiness while draining = %d, want 503", code)
+	}
+	if code, _, body := do("POST", message(refused.ID), `{"content": "Hello"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("new message while draining = %d %s, want 503", code, body)
+	}
+	if code, _, _ := do("POST", "/api/v1/admin/drain", ""); code != http.StatusOK {
+		t.Errorf("second drain = %d, want 200", code)
+	}
+	// SIGTERM starts a drain the same way; one already started is kept
+	if drainer.Start(0) {
+		t.Error("Start while draining started another drain")
+	}
+
+	select {
+	case r := <-answered:
+		if r.code != http.StatusOK || !strings.Contains(r.body, "Done.") {
+			t.Errorf("in-flight message = %d %s, want its answer", r.code, r.body)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("the in-flight message did not finish")
+	}
+	select {
+	case <-drainer.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the drain did not complete")
+	}
+
+	code, _, body = do("GET", "/api/v1/admin/drain", "")
+	if err := json.Unmarshal([]byte(body), &status); code != http.StatusOK || err != nil {
+		t.Fatalf("drain status = %d %s", code, body)
+	}
+	if !status.Completed || status.InFlightRuns != 0 || status.HandedBackRuns != 0 {
+		t.Errorf("drain status = %+v, want completed with nothing handed back", status)
+	}
+	if code, _, _ := do("GET", "/health/ready", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("readiness after draining = %d, want 503", code)
+	}
+}
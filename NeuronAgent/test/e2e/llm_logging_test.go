@@ -0,0 +1,67 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestLLMCallsAreLoggedRedacted(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	a, err := h.CreateAgent(ctx, &db.Agent{Config: db.JSONBMap{
+		"llm_logging": map[string]interface{}{"enabled": true, "sample_percent": float64(100)},
+	}})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := h.StubLLMResponse(ctx, "%password reset%", "A reset link was sent."); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := h.NewRuntime().Execute(ctx, session.ID, "Send a password reset to jane@example.com, password=hunter22")
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	// Calls are logged in the background
+	var logs []db.LLMLog
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if logs, err = h.Queries.ListLLMLogs(ctx, a.ID, &session.ID, 10, 0); err != nil {
+			t.Fatalf("list LLM logs: %v", err)
+		}
+		if len(logs) > 0 {
+			break
+		}
+	}
+	if len(logs) != 1 {
+		t.Fatalf("agent has %d logged LLM calls, want 1", len(logs))
+	}
+	log := logs[0]
+	if log.Purpose != agent.LLMCallAnswer || log.RunID == nil || *log.RunID != *state.RunID {
+		t.Errorf("logged call = %+v, want the answer call of the run", log)
+	}
+	if strings.Contains(log.Prompt, "jane@example.com") || strings.Contains(log.Prompt, "hunter22") {
+		t.Errorf("logged prompt was not redacted: %q", log.Prompt)
+	}
+	if !strings.Contains(log.Prompt, "[REDACTED_EMAIL]") || !strings.Contains(log.Prompt, "password=[REDACTED_CREDENTIAL]") {
+		t.Errorf("logged prompt lacks redaction placeholders: %q", log.Prompt)
+	}
+	if log.Response == nil || *log.Response != "A reset link was sent." {
+		t.Errorf("logged response = %v", log.Response)
+	}
+	if log.Redactions["email"] != float64(1) || log.Redactions["credential"] != float64(1) {
+		t.Errorf("redactions = %v, want one email and one credential", log.Redactions)
+	}
+}
@@ -0,0 +1,84 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+func TestMemoryCurationPinsAndDeletesChunks(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	client := neurondb.NewEmbeddingClient(h.DB.DB)
+
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	var ids []int64
+	for i, content := range []string{"the user prefers metric units", "the user's name is 100% Bob", "the deploy runs on fridays"} {
+		embedding, err := client.Embed(ctx, content, agent.MemoryEmbeddingModel(a))
+		if err != nil {
+			t.Fatalf("embed: %v", err)
+		}
+		chunk, err := h.Queries.CreateMemoryChunk(ctx, &db.MemoryChunk{
+			AgentID:         a.ID,
+			Content:         content,
+			Embedding:       embedding,
+			ImportanceScore: 0.4 + 0.1*float64(i),
+			Metadata:        db.JSONBMap{},
+		})
+		if err != nil {
+			t.Fatalf("create memory chunk: %v", err)
+		}
+		ids = append(ids, chunk.ID)
+	}
+
+	contains := "100%"
+	chunks, err := h.Queries.ListMemoryChunks(ctx, a.ID, db.MemoryChunkFilter{Contains: &contains}, 50, 0)
+	if err != nil {
+		t.Fatalf("list memory chunks: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].ID != ids[1] {
+		t.Errorf("chunks containing %q = %+v, want only chunk %d", contains, chunks, ids[1])
+	}
+
+	low, pinned := 0.1, true
+	chunk, err := h.Queries.UpdateMemoryChunk(ctx, a.ID, ids[0], &low, &pinned)
+	if err != nil {
+		t.Fatalf("update memory chunk: %v", err)
+	}
+	if !chunk.Pinned || chunk.ImportanceScore < 0.09 || chunk.ImportanceScore > 0.11 {
+		t.Errorf("updated chunk = %+v, want pinned with importance 0.1", chunk)
+	}
+	total, err := h.Queries.CountMatchingMemoryChunks(ctx, a.ID, db.MemoryChunkFilter{Pinned: &pinned})
+	if err != nil || total != 1 {
+		t.Errorf("pinned chunk count = %d, %v; want 1", total, err)
+	}
+
+	// The pinned chunk has the lowest score but outlasts capacity eviction
+	evicted, err := h.Queries.EvictExcessMemoryChunks(ctx, a.ID, 1, false)
+	if err != nil {
+		t.Fatalf("evict memory chunks: %v", err)
+	}
+	if evicted != 2 {
+		t.Errorf("evicted %d chunks, want 2", evicted)
+	}
+	if _, err := h.Queries.GetMemoryChunk(ctx, a.ID, ids[0]); err != nil {
+		t.Errorf("pinned chunk was evicted: %v", err)
+	}
+
+	if err := h.Queries.DeleteMemoryChunk(ctx, a.ID, ids[0]); err != nil {
+		t.Fatalf("delete memory chunk: %v", err)
+	}
+	if err := h.Queries.DeleteMemoryChunk(ctx, a.ID, ids[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second delete = %v, want sql.ErrNoRows", err)
+	}
+}
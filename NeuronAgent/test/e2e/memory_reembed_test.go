@@ -0,0 +1,95 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+func TestMemoryReembedSwitchesModel(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	client := neurondb.NewEmbeddingClient(h.DB.DB)
+
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	fromModel := agent.MemoryEmbeddingModel(a)
+	for _, content := range []string{"the user prefers metric units", "the deploy runs on fridays", "the api key rotates monthly"} {
+		embedding, err := client.Embed(ctx, content, fromModel)
+		if err != nil {
+			t.Fatalf("embed: %v", err)
+		}
+		if _, err := h.Queries.CreateMemoryChunk(ctx, &db.MemoryChunk{
+			AgentID:         a.ID,
+			Content:         content,
+			Embedding:       embedding,
+			ImportanceScore: 0.5,
+			Metadata:        db.JSONBMap{},
+		}); err != nil {
+			t.Fatalf("create memory chunk: %v", err)
+		}
+	}
+
+	req := &agent.MemoryReembedRequest{Model: "new-embedding-model", BatchSize: 2}
+	if err := req.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	reembedder := agent.NewMemoryReembedder(h.Queries, client)
+	if err := reembedder.Check(ctx, a, req); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	payload, err := req.ToPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := h.Queries.CreateJob(ctx, &db.Job{
+		AgentID:    &a.ID,
+		Type:       agent.MemoryReembedJobType,
+		Status:     "queued",
+		Payload:    payload,
+		MaxRetries: 3,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	result, err := reembedder.Run(ctx, job)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	progress, err := agent.ParseMemoryReembedProgress(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !progress.Completed || !progress.Switched || progress.ChunksEmbedded != 3 || progress.ChunksSwitched != 3 || progress.Batches != 2 {
+		t.Errorf("progress = %+v, want 3 chunks embedded in 2 batches and switched", progress)
+	}
+	if progress.FromModel != fromModel {
+		t.Errorf("from_model = %q, want the agent's previous model", progress.FromModel)
+	}
+
+	a, err = h.Queries.GetAgentByID(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("get agent: %v", err)
+	}
+	if model := agent.MemoryEmbeddingModel(a); model != req.Model {
+		t.Errorf("agent memory embedding model = %q, want %q", model, req.Model)
+	}
+	var staged int
+	if err := h.DB.GetContext(ctx, &staged, `SELECT COUNT(*) FROM neurondb_agent.memory_reembeddings WHERE job_id = $1`, job.ID); err != nil {
+		t.Fatalf("count staged embeddings: %v", err)
+	}
+	if staged != 0 {
+		t.Errorf("%d staged embeddings left after the switch", staged)
+	}
+	if err := reembedder.Check(ctx, a, req); err == nil {
+		t.Error("check accepted re-embedding with the model the agent already uses")
+	}
+}
@@ -0,0 +1,63 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestSearchMessagesKeywordAcrossSessions(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	first, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	second, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	addMessage := func(session *db.Session, role, content string) {
+		t.Helper()
+		if _, err := h.Queries.CreateMessage(ctx, &db.Message{SessionID: session.ID, Role: role, Content: content, Metadata: db.JSONBMap{}}); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+	addMessage(first, "user", "How do refunds work for annual plans?")
+	addMessage(first, "assistant", "Annual plans are refunded pro rata within 30 days.")
+	addMessage(second, "user", "Can I get a refund?")
+	addMessage(second, "user", "What is the weather today?")
+
+	hits, err := h.Queries.SearchMessagesKeyword(ctx, a.ID, db.MessageSearchFilter{}, "refund", 10)
+	if err != nil {
+		t.Fatalf("search messages: %v", err)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("hits = %d, want 3 across both sessions", len(hits))
+	}
+	for _, hit := range hits {
+		if !strings.Contains(hit.Highlight, "<mark>") {
+			t.Errorf("highlight %q has no match marked", hit.Highlight)
+		}
+		if hit.Score <= 0 {
+			t.Errorf("score = %v, want > 0", hit.Score)
+		}
+	}
+
+	filtered, err := h.Queries.SearchMessagesKeyword(ctx, a.ID, db.MessageSearchFilter{SessionID: &first.ID, Roles: []string{"user"}}, "refund", 10)
+	if err != nil {
+		t.Fatalf("search messages with filters: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].SessionID != first.ID || filtered[0].Role != "user" {
+		t.Errorf("filtered hits = %+v, want the user message of the first session", filtered)
+	}
+}
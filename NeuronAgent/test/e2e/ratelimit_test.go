@@ -0,0 +1,94 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestPostgresRateLimitIsShared(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	// Two limiters stand for two replicas
+	first, err := auth.NewLimiter(auth.RateLimitBackendPostgres, h.Queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := auth.NewLimiter(auth.RateLimitBackendPostgres, h.Queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyID := uuid.NewString()
+	for i, limiter := range []auth.Limiter{first, second, first} {
+		status, err := limiter.Take(ctx, keyID, 3)
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+		if !status.Allowed {
+			t.Fatalf("take %d refused, want allowed", i)
+		}
+	}
+	status, err := second.Take(ctx, keyID, 3)
+	if err != nil {
+		t.Fatalf("take: %v", err)
+	}
+	if status.Allowed {
+		t.Error("fourth request allowed, want refused")
+	}
+	if status.RetryAfter <= 0 {
+		t.Errorf("retry after = %v, want > 0", status.RetryAfter)
+	}
+}
+
+func TestQuotaRefusesRequestsOverDailyLimit(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	org := "org-" + uuid.NewString()[:8]
+	quotas := auth.NewQuotas(h.Queries, map[string]auth.QuotaLimits{org: {RequestsPerDay: 3}})
+	apiKey := &db.APIKey{
+		ID:             uuid.New(),
+		OrganizationID: &org,
+		Metadata:       db.JSONBMap{"daily_request_quota": float64(2)},
+	}
+
+	for i := 0; i < 2; i++ {
+		status, err := quotas.Check(ctx, apiKey)
+		if err != nil {
+			t.Fatalf("check %d: %v", i, err)
+		}
+		if !status.Allowed {
+			t.Fatalf("check %d refused, want allowed", i)
+		}
+	}
+	status, err := quotas.Check(ctx, apiKey)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if status.Allowed || status.ExceededScope != db.QuotaScopeAPIKey || status.ExceededUnit != "requests" {
+		t.Errorf("status = %+v, want refused by the api_key requests quota", status)
+	}
+	if status.RequestsRemaining != 0 {
+		t.Errorf("requests remaining = %d, want 0", status.RequestsRemaining)
+	}
+
+	// Another key of the organization uses up what is left of its quota
+	other := &db.APIKey{ID: uuid.New(), OrganizationID: &org, Metadata: db.JSONBMap{}}
+	if status, err = quotas.Check(ctx, other); err != nil || !status.Allowed {
+		t.Fatalf("check other key = %+v, %v, want allowed", status, err)
+	}
+	status, err = quotas.Check(ctx, other)
+	if err != nil {
+		t.Fatalf("check other key: %v", err)
+	}
+	if status.Allowed || status.ExceededScope != db.QuotaScopeOrganization {
+		t.Errorf("status = %+v, want refused by the organization quota", status)
+	}
+}
@@ -0,0 +1,100 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/neurondb/NeuronAgent/internal/api"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestRunsAreScopedToOrganization(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	owner, other := "org-"+uuid.NewString()[:8], "org-"+uuid.NewString()[:8]
+	agent, err := h.CreateAgent(ctx, &db.Agent{OrganizationID: &owner})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, agent.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	run := &db.Run{SessionID: session.ID, AgentID: agent.ID, Step: "started", Checkpoint: db.JSONBMap{}}
+	if err := h.Queries.CreateRun(ctx, run); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+
+	handlers := api.NewHandlers(h.Queries, nil, nil, nil, nil, nil, nil)
+	get := func(org string) int {
+		t.Helper()
+		router := mux.NewRouter()
+		apiRouter := router.PathPrefix("/api/v1").Subrouter()
+		apiRouter.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := &db.APIKey{ID: uuid.New(), OrganizationID: &org, Roles: []string{auth.RoleUser}}
+				next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), key)))
+			})
+		})
+		apiRouter.Use(api.OrganizationMiddleware(h.Queries))
+		apiRouter.HandleFunc("/runs/{id}", handlers.GetRun).Methods("GET")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runs/"+run.ID.String(), nil))
+		return rec.Code
+	}
+
+	if code := get(owner); code != http.StatusOK {
+		t.Errorf("run read by its organization = %d, want 200", code)
+	}
+	if code := get(other); code != http.StatusNotFound {
+		t.Errorf("run read by another organization = %d, want 404", code)
+	}
+}
+
+func TestAgentRoutesAreScopedToOrganization(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	owner, other := "org-"+uuid.NewString()[:8], "org-"+uuid.NewString()[:8]
+	agent, err := h.CreateAgent(ctx, &db.Agent{OrganizationID: &owner})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	// The handler checks nothing itself, so only the middleware stands
+	// between it and other organizations
+	reached := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	get := func(org string) int {
+		t.Helper()
+		router := mux.NewRouter()
+		apiRouter := router.PathPrefix("/api/v1").Subrouter()
+		apiRouter.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := &db.APIKey{ID: uuid.New(), OrganizationID: &org, Roles: []string{auth.RoleUser}}
+				next.ServeHTTP(w, r.WithContext(auth.WithAPIKey(r.Context(), key)))
+			})
+		})
+		apiRouter.Use(api.OrganizationMiddleware(h.Queries))
+		apiRouter.HandleFunc("/agents/{id}/memory/backfill/{job_id}", reached).Methods("GET")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/agents/"+agent.ID.String()+"/memory/backfill/1", nil))
+		return rec.Code
+	}
+
+	if code := get(owner); code != http.StatusOK {
+		t.Errorf("agent route used by its organization = %d, want 200", code)
+	}
+	if code := get(other); code != http.StatusNotFound {
+		t.Errorf("agent route used by another organization = %d, want 404", code)
+	}
+}
@@ -0,0 +1,106 @@
+//go:build e2e
+
+// Package e2e runs the agent against a NeuronDB container. Run it with
+// make test-e2e, or go test -tags e2e ./test/e2e/.
+package e2e
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/testharness"
+	"github.com/neurondb/NeuronAgent/pkg/neurondb"
+)
+
+var harness *testharness.Harness
+
+func TestMain(m *testing.M) {
+	os.Exit(testharness.Main(m, testharness.Options{}, &harness))
+}
+
+// setup returns the shared harness, emptied of earlier tests' data
+func setup(t *testing.T) *testharness.Harness {
+	t.Helper()
+	if harness == nil {
+		t.Skip("docker is not available")
+	}
+	if err := harness.Reset(context.Background()); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	return harness
+}
+
+func TestExecuteStoresTurnAndRun(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	agent, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, agent.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := h.StubLLMResponse(ctx, "%capital of France%", "Paris."); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := h.NewRuntime().Execute(ctx, session.ID, "What is the capital of France?")
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if state.FinalAnswer != "Paris." {
+		t.Errorf("answer = %q, want %q", state.FinalAnswer, "Paris.")
+	}
+
+	messages, err := h.Queries.GetMessages(ctx, session.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("get messages: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("stored %d messages, want the user message and the answer", len(messages))
+	}
+	// The turn is stored in one transaction, so both messages have the same
+	// creation time and are ordered by ID
+	if messages[0].Role != "user" || messages[1].Role != "assistant" || messages[0].ID >= messages[1].ID {
+		t.Errorf("messages stored out of order: %s %d, %s %d", messages[0].Role, messages[0].ID, messages[1].Role, messages[1].ID)
+	}
+
+	if state.RunID == nil {
+		t.Fatal("execution has no run")
+	}
+	run, err := h.Queries.GetRun(ctx, *state.RunID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if run.Status != db.RunCompleted || run.Step != "answered" {
+		t.Errorf("run status = %s at step %s, want completed at answered", run.Status, run.Step)
+	}
+}
+
+func TestStubEmbeddings(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	client := neurondb.NewEmbeddingClient(h.DB.DB)
+
+	embed := func(text string) neurondb.Vector {
+		t.Helper()
+		v, err := client.Embed(ctx, text, testharness.StubModel)
+		if err != nil {
+			t.Fatalf("embed %q: %v", text, err)
+		}
+		return v
+	}
+	a, again := embed("vector search in postgres"), embed("vector search in postgres")
+	if d := neurondb.Distance(a, again, "cosine"); d > 1e-6 {
+		t.Errorf("equal texts are %g apart", d)
+	}
+	related := neurondb.Distance(a, embed("postgres vector search engine"), "cosine")
+	unrelated := neurondb.Distance(a, embed("bake the bread for an hour"), "cosine")
+	if related >= unrelated {
+		t.Errorf("texts sharing words are %g apart, unrelated texts %g", related, unrelated)
+	}
+}
@@ -0,0 +1,125 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/secrets"
+)
+
+func masterKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generate master key: %v", err)
+	}
+	return key
+}
+
+func keyWrapper(t *testing.T, current []byte, previous ...[]byte) *secrets.LocalKeyWrapper {
+	t.Helper()
+	w, err := secrets.NewLocalKeyWrapper(current, previous...)
+	if err != nil {
+		t.Fatalf("key wrapper: %v", err)
+	}
+	return w
+}
+
+func TestSecretStoreRoundTrip(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	store := secrets.NewStore(h.Queries, keyWrapper(t, masterKey(t)))
+
+	secret, err := store.Create(ctx, "github_token", nil, "ghp_first")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if bytes.Contains(secret.Ciphertext, []byte("ghp_first")) {
+		t.Error("the value is stored in the clear")
+	}
+	if value, err := store.Resolve(ctx, "github_token"); err != nil || value != "ghp_first" {
+		t.Fatalf("resolve = %q, %v", value, err)
+	}
+
+	second := "ghp_second"
+	if _, err := store.Update(ctx, "github_token", nil, &second); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if value, err := store.Resolve(ctx, "github_token"); err != nil || value != second {
+		t.Fatalf("resolve after update = %q, %v", value, err)
+	}
+
+	// A ciphertext copied to another secret does not decrypt
+	if _, err := store.Create(ctx, "slack_token", nil, "xoxb"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stored, err := h.Queries.GetSecret(ctx, "github_token")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, err := h.DB.ExecContext(ctx, `UPDATE neurondb_agent.secrets SET ciphertext = $2, wrapped_key = $3, key_id = $4 WHERE name = $1`,
+		"slack_token", stored.Ciphertext, stored.WrappedKey, stored.KeyID); err != nil {
+		t.Fatalf("copy ciphertext: %v", err)
+	}
+	if value, err := store.Resolve(ctx, "slack_token"); err == nil {
+		t.Errorf("copied ciphertext resolved to %q", value)
+	}
+}
+
+func TestSecretStoreRotateKeys(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	oldKey, newKey := masterKey(t), masterKey(t)
+
+	before := secrets.NewStore(h.Queries, keyWrapper(t, oldKey))
+	for name, value := range map[string]string{"github_token": "ghp_token", "slack_token": "xoxb_token"} {
+		if _, err := before.Create(ctx, name, nil, value); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+	rotating := secrets.NewStore(h.Queries, keyWrapper(t, newKey, oldKey))
+	if _, err := rotating.Create(ctx, "api_key", nil, "sk_token"); err != nil {
+		t.Fatalf("create api_key: %v", err)
+	}
+
+	result, err := rotating.RotateKeys(ctx)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if result.KeyID != rotating.KeyID() || result.Rewrapped != 2 || result.Current != 1 || len(result.Failed) != 0 {
+		t.Errorf("rotation = %+v, want 2 rewrapped and 1 current", result)
+	}
+
+	// Every secret opens once the old master key is removed
+	after := secrets.NewStore(h.Queries, keyWrapper(t, newKey))
+	for name, want := range map[string]string{"github_token": "ghp_token", "slack_token": "xoxb_token", "api_key": "sk_token"} {
+		if value, err := after.Resolve(ctx, name); err != nil || value != want {
+			t.Errorf("resolve %s after rotation = %q, %v", name, value, err)
+		}
+	}
+
+	// A second rotation has nothing to do
+	result, err = after.RotateKeys(ctx)
+	if err != nil {
+		t.Fatalf("rotate again: %v", err)
+	}
+	if result.Rewrapped != 0 || result.Current != 3 {
+		t.Errorf("second rotation = %+v, want 3 current", result)
+	}
+
+	// Secrets whose master key is not configured are reported, not lost
+	if _, err := before.Create(ctx, "orphan", nil, "value"); err != nil {
+		t.Fatalf("create orphan: %v", err)
+	}
+	result, err = after.RotateKeys(ctx)
+	if err != nil {
+		t.Fatalf("rotate with an unknown key: %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0] != "orphan" {
+		t.Errorf("failed = %v, want [orphan]", result.Failed)
+	}
+}
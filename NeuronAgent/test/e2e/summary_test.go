@@ -0,0 +1,85 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestSessionSummaryIsCachedAndExtended(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	addMessage := func(role, content string) {
+		t.Helper()
+		if _, err := h.Queries.CreateMessage(ctx, &db.Message{SessionID: session.ID, Role: role, Content: content, Metadata: db.JSONBMap{}}); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+	runtime := h.NewRuntime()
+	opts, err := agent.ParseSummaryOptions("bullets", "short", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty, generated, err := runtime.SummarizeSession(ctx, session.ID, opts)
+	if err != nil {
+		t.Fatalf("summarize empty session: %v", err)
+	}
+	if empty.Summary != "" || generated {
+		t.Errorf("empty session summary = %q, generated = %v", empty.Summary, generated)
+	}
+
+	addMessage("user", "What were sales in March?")
+	addMessage("assistant", "Sales in March were 120 units.")
+	if err := h.StubLLMResponse(ctx, "%sales in March%", "- March sales were 120 units"); err != nil {
+		t.Fatal(err)
+	}
+	first, generated, err := runtime.SummarizeSession(ctx, session.ID, opts)
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if !generated || first.Summary != "- March sales were 120 units" || first.MessageCount != 2 {
+		t.Fatalf("summary = %+v, generated = %v", first, generated)
+	}
+
+	cached, generated, err := runtime.SummarizeSession(ctx, session.ID, opts)
+	if err != nil {
+		t.Fatalf("summarize again: %v", err)
+	}
+	if generated || cached.Summary != first.Summary {
+		t.Errorf("second request generated = %v, summary = %q, want the cached summary", generated, cached.Summary)
+	}
+
+	// New messages extend the cached summary rather than rebuilding it
+	addMessage("user", "And in April?")
+	if err := h.StubLLMResponse(ctx, "%March sales were 120 units%And in April%", "- March sales were 120 units\n- April was asked about"); err != nil {
+		t.Fatal(err)
+	}
+	extended, generated, err := runtime.SummarizeSession(ctx, session.ID, opts)
+	if err != nil {
+		t.Fatalf("summarize extended session: %v", err)
+	}
+	if !generated || extended.MessageCount != 3 || extended.ThroughMessageID <= first.ThroughMessageID {
+		t.Errorf("extended summary = %+v, generated = %v", extended, generated)
+	}
+
+	// Other styles and lengths are summarized separately
+	prose, _ := agent.ParseSummaryOptions("", "", false)
+	stored, err := h.Queries.GetConversationSummary(ctx, session.ID, prose.Style, prose.Length)
+	if err != nil || stored != nil {
+		t.Errorf("prose summary = %+v, %v, want none", stored, err)
+	}
+}
@@ -0,0 +1,133 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/db"
+	"github.com/neurondb/NeuronAgent/internal/testharness"
+)
+
+// constrainedSQLAgent creates an sql tool and an agent that may only read
+// the public schema with it
+func constrainedSQLAgent(t *testing.T, ctx context.Context, h *testharness.Harness) (*db.Agent, *db.Session) {
+	t.Helper()
+	tool := &db.Tool{
+		Name:        "query_db",
+		Description: "Runs a read-only SQL query",
+		ArgSchema: db.JSONBMap{
+			"type":       "object",
+			"properties": map[string]interface{}{"query": map[string]interface{}{"type": "string"}},
+			"required":   []interface{}{"query"},
+		},
+		HandlerType:   "sql",
+		HandlerConfig: db.JSONBMap{},
+		Enabled:       true,
+	}
+	if err := h.Queries.CreateTool(ctx, tool); err != nil {
+		t.Fatalf("create tool: %v", err)
+	}
+	agent, err := h.CreateAgent(ctx, &db.Agent{
+		EnabledTools: []string{"query_db"},
+		Config: db.JSONBMap{
+			"tool_constraints": []interface{}{
+				map[string]interface{}{
+					"handler_types": []interface{}{"sql"},
+					"arguments": map[string]interface{}{
+						"query": map[string]interface{}{"allowed_schemas": []interface{}{"public"}},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, agent.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return agent, session
+}
+
+func TestToolConstraintsRefuseViolatingCalls(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	_, session := constrainedSQLAgent(t, ctx, h)
+
+	if err := h.StubLLMResponse(ctx, "%How many agents%",
+		`<tool:query_db:{"query": "SELECT count(*) FROM neurondb_agent.agents"}>`); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.StubLLMResponse(ctx, "%violate the agent's tool constraints%", "I may not read that schema."); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := h.NewRuntime().Execute(ctx, session.ID, "How many agents are there?")
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(state.ToolResults) != 1 {
+		t.Fatalf("execution has %d tool results, want 1", len(state.ToolResults))
+	}
+	result := state.ToolResults[0]
+	if result.Error == nil || result.Content != "" {
+		t.Errorf("refused call ran: content=%q, error=%v", result.Content, result.Error)
+	}
+	if len(result.ConstraintViolations) != 1 {
+		t.Fatalf("call has %d violations, want 1", len(result.ConstraintViolations))
+	}
+	violation := result.ConstraintViolations[0]
+	if violation.Tool != "query_db" || violation.Argument != "query" || violation.Rule != "allowed_schemas" {
+		t.Errorf("violation = %+v, want query_db's query breaking allowed_schemas", violation)
+	}
+	// The violations reach the LLM, which answers from them
+	if state.FinalAnswer != "I may not read that schema." {
+		t.Errorf("final answer = %q, want the stubbed answer to the violations", state.FinalAnswer)
+	}
+
+	messages, err := h.Queries.GetRunMessages(ctx, session.ID, []uuid.UUID{*state.RunID})
+	if err != nil {
+		t.Fatalf("get run messages: %v", err)
+	}
+	stored := false
+	for _, m := range messages {
+		if m.Role == "tool" {
+			_, stored = m.Metadata["tool_constraint_violations"]
+		}
+	}
+	if !stored {
+		t.Error("the tool message does not hold tool_constraint_violations metadata")
+	}
+}
+
+func TestToolConstraintsAllowCompliantCalls(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	_, session := constrainedSQLAgent(t, ctx, h)
+
+	if err := h.StubLLMResponse(ctx, "%Say one%", `<tool:query_db:{"query": "SELECT 1 AS one"}>`); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.StubLLMResponse(ctx, "%Tool % result%", "One."); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := h.NewRuntime().Execute(ctx, session.ID, "Say one")
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if len(state.ToolResults) != 1 {
+		t.Fatalf("execution has %d tool results, want 1", len(state.ToolResults))
+	}
+	result := state.ToolResults[0]
+	if result.Error != nil || len(result.ConstraintViolations) != 0 {
+		t.Errorf("compliant call refused: error=%v, violations=%+v", result.Error, result.ConstraintViolations)
+	}
+	if result.Content == "" {
+		t.Error("compliant call returned no content")
+	}
+}
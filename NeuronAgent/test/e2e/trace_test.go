@@ -0,0 +1,67 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/neurondb/NeuronAgent/internal/agent"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestRunTraceLinksMessagesAndCalls(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	a, err := h.CreateAgent(ctx, &db.Agent{})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	session, err := h.CreateSession(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := h.StubLLMResponse(ctx, "%tallest mountain%", "Mount Everest."); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := h.NewRuntime().Execute(ctx, session.ID, "What is the tallest mountain?")
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if state.RunID == nil {
+		t.Fatal("execution has no run")
+	}
+
+	runs, err := h.Queries.ListSessionRuns(ctx, session.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != *state.RunID {
+		t.Fatalf("session has %d runs, want the execution's run", len(runs))
+	}
+	trace, err := agent.RunTraceOf(&runs[0])
+	if err != nil {
+		t.Fatalf("run trace: %v", err)
+	}
+	if len(trace.LLMCalls) != 1 {
+		t.Fatalf("trace has %d LLM calls, want 1", len(trace.LLMCalls))
+	}
+	call := trace.LLMCalls[0]
+	if call.Purpose != agent.LLMCallAnswer || call.PromptChars == 0 {
+		t.Errorf("LLM call = %+v, want an answer call with its prompt size", call)
+	}
+	if len(trace.ToolCalls) != 0 {
+		t.Errorf("trace has %d tool calls, want none", len(trace.ToolCalls))
+	}
+
+	messages, err := h.Queries.GetRunMessages(ctx, session.ID, []uuid.UUID{*state.RunID})
+	if err != nil {
+		t.Fatalf("get run messages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Role != "user" || messages[1].Content != "Mount Everest." {
+		t.Errorf("run stored %d messages, want the user message and the answer", len(messages))
+	}
+}
@@ -0,0 +1,110 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/neurondb/NeuronAgent/internal/auth"
+	"github.com/neurondb/NeuronAgent/internal/db"
+)
+
+func TestUserRolesAndStatusApplyToKeys(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+	keys := auth.NewAPIKeyManager(h.Queries)
+
+	org := &db.Organization{ID: "org-" + uuid.NewString()[:8], Name: "Acme", Metadata: db.JSONBMap{}}
+	if err := h.Queries.CreateOrganization(ctx, org); err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	if err := h.Queries.CreateOrganization(ctx, org); !errors.Is(err, db.ErrAlreadyExists) {
+		t.Errorf("create organization again = %v, want ErrAlreadyExists", err)
+	}
+
+	user := &db.User{OrganizationID: org.ID, Email: "ada@example.com", Roles: pq.StringArray{"user", "read-only"}}
+	if err := h.Queries.CreateUser(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if user.Status != db.UserInvited {
+		t.Errorf("status = %s, want invited", user.Status)
+	}
+	duplicate := &db.User{OrganizationID: org.ID, Email: "ADA@example.com", Roles: pq.StringArray{"user"}}
+	if err := h.Queries.CreateUser(ctx, duplicate); !errors.Is(err, db.ErrAlreadyExists) {
+		t.Errorf("create user with the same email = %v, want ErrAlreadyExists", err)
+	}
+
+	userID := user.ID.String()
+	_, both, err := keys.GenerateAPIKey(ctx, &org.ID, &userID, 60, []string{"user", "read-only"})
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	_, userOnly, err := keys.GenerateAPIKey(ctx, &org.ID, &userID, 60, []string{"user"})
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	// Dropping the user role trims one key and revokes the other
+	user.Roles = pq.StringArray{"read-only"}
+	if err := h.Queries.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	trimmed, err := h.Queries.GetAPIKeyByID(ctx, both.ID)
+	if err != nil {
+		t.Fatalf("get trimmed key: %v", err)
+	}
+	if len(trimmed.Roles) != 1 || trimmed.Roles[0] != "read-only" {
+		t.Errorf("trimmed key roles = %v, want [read-only]", trimmed.Roles)
+	}
+	if _, err := h.Queries.GetAPIKeyByID(ctx, userOnly.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("key left without roles = %v, want revoked", err)
+	}
+
+	user.Status = db.UserDisabled
+	if err := h.Queries.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+	if _, err := h.Queries.GetAPIKeyByID(ctx, both.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("key of a disabled user = %v, want revoked", err)
+	}
+}
+
+func TestDeleteOrganizationInUse(t *testing.T) {
+	h := setup(t)
+	ctx := context.Background()
+
+	org := &db.Organization{ID: "org-" + uuid.NewString()[:8], Name: "Acme", Metadata: db.JSONBMap{}}
+	if err := h.Queries.CreateOrganization(ctx, org); err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	user := &db.User{OrganizationID: org.ID, Email: "ada@example.com", Roles: pq.StringArray{"user"}}
+	if err := h.Queries.CreateUser(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	owner := user.ID.String()
+	a, err := h.CreateAgent(ctx, &db.Agent{OrganizationID: &org.ID, OwnerUserID: &owner})
+	if err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	if err := h.Queries.DeleteOrganization(ctx, org.ID); !errors.Is(err, db.ErrInUse) {
+		t.Fatalf("delete organization with an agent = %v, want ErrInUse", err)
+	}
+	if err := h.Queries.DeleteAgent(ctx, a.ID); err != nil {
+		t.Fatalf("delete agent: %v", err)
+	}
+	if err := h.Queries.DeleteOrganization(ctx, org.ID); err != nil {
+		t.Fatalf("delete organization: %v", err)
+	}
+	if _, err := h.Queries.GetUser(ctx, user.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("user of a deleted organization = %v, want deleted with it", err)
+	}
+	if err := h.Queries.DeleteOrganization(ctx, org.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("delete organization again = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -5,19 +5,29 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/neurondb/NeuronMCP/internal/client"
 )
 
 func main() {
 	var (
-		configPath = flag.String("c", "", "Path to NeuronMCP server configuration file (required)")
-		execute    = flag.String("e", "", "Execute a single command (format: tool_name or tool_name:arg1=val1,arg2=val2)")
-		file       = flag.String("f", "", "Path to file containing commands to execute (one per line)")
-		output     = flag.String("o", "", "Output file path for results (default: results_<timestamp>.json)")
-		verbose    = flag.Bool("v", false, "Enable verbose output")
-		serverName = flag.String("server-name", "neurondb", "Server name from config (default: neurondb)")
+		configPath  = flag.String("c", "", "Path to NeuronMCP server configuration file (required)")
+		execute     = flag.String("e", "", "Execute a single command (format: tool_name or tool_name:arg1=val1,arg2=val2)")
+		file        = flag.String("f", "", "Path to file containing commands to execute (one per line)")
+		interactive = flag.Bool("i", false, "Start an interactive session (REPL)")
+		noColor     = flag.Bool("no-color", false, "Disable colored output in interactive mode")
+		parallel    = flag.Int("parallel", 1, "Number of commands from -f to run concurrently (each worker starts its own server)")
+		output      = flag.String("o", "", "Output file path for results (default: results_<timestamp>.json)")
+		report      = flag.String("report", "", "Also write a test report of the commands run with -e or -f: junit or tap")
+		reportFile  = flag.String("report-file", "", "Report file path (default: the results file with a .xml or .tap extension; - for stdout)")
+		verbose     = flag.Bool("v", false, "Enable verbose output")
+		serverName  = flag.String("server-name", "neurondb", "Server name from config (default: neurondb)")
+		roots       stringList
 	)
+	flag.Var(&roots, "root", "Directory shared with the server as an MCP root (repeatable; adds to the config's \"roots\")")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "NeuronMCP CLI Client - Connect to MCP servers and execute commands\n\n")
@@ -27,8 +37,16 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -e \"list_tools\"\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Execute commands from file\n")
 		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -f commands.txt\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Interactive session with tab completion and history\n")
+		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -i\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Execute commands from file with 4 parallel workers\n")
+		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -f commands.txt --parallel 4\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Execute commands and save output\n")
 		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -f commands.txt -o results.json\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Run a command file as a smoke test and write a JUnit report for CI\n")
+		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -f commands.txt --report junit --report-file smoke.xml\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Let the server read local files under ./docs\n")
+		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -e \"ingest_document:table=docs,path=$PWD/docs/guide.md\" --root ./docs\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Verbose mode\n")
 		fmt.Fprintf(os.Stderr, "  %s -c neuronmcp_server.json -e \"list_tools\" -v\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Options:\n")
@@ -44,24 +62,62 @@ func main() {
 		os.Exit(1)
 	}
 
-	if *execute == "" && *file == "" {
-		fmt.Fprintf(os.Stderr, "Error: Either -e/--execute or -f/--file must be provided\n")
+	modes := 0
+	for _, set := range []bool{*execute != "", *file != "", *interactive} {
+		if set {
+			modes++
+		}
+	}
+
+	if modes == 0 {
+		fmt.Fprintf(os.Stderr, "Error: One of -e/--execute, -f/--file or -i/--interactive must be provided\n")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	if *execute != "" && *file != "" {
-		fmt.Fprintf(os.Stderr, "Error: Cannot use both -e/--execute and -f/--file\n")
+	if modes > 1 {
+		fmt.Fprintf(os.Stderr, "Error: Cannot combine -e/--execute, -f/--file and -i/--interactive\n")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if *parallel < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --parallel must be at least 1\n")
+		os.Exit(1)
+	}
+
+	if *report != "" && client.ReportExtension(*report) == "" {
+		fmt.Fprintf(os.Stderr, "Error: --report must be junit or tap\n")
+		os.Exit(1)
+	}
+	if *report != "" && *interactive {
+		fmt.Fprintf(os.Stderr, "Error: --report applies to -e/--execute and -f/--file only\n")
+		os.Exit(1)
+	}
+	if *reportFile != "" && *report == "" {
+		fmt.Fprintf(os.Stderr, "Error: --report-file needs --report\n")
+		os.Exit(1)
+	}
+
+	// Read the command file up front so syntax errors surface before any
+	// server process is started
+	var commands []client.BatchCommand
+	if *file != "" {
+		var err error
+		commands, err = readCommandsFile(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading command file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Load configuration
 	config, err := client.LoadConfig(*configPath, *serverName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
+	config.Roots = append(config.Roots, roots...)
 
 	// Initialize output manager
 	outputMgr := client.NewOutputManager(*output)
@@ -80,8 +136,23 @@ func main() {
 	defer mcpClient.Disconnect()
 
 	// Execute commands
-	if *execute != "" {
+	var reportResults []client.BatchResult
+	reportSuite := *file
+	reportStart := time.Now()
+	if *interactive {
+		repl := client.NewREPL(mcpClient, outputMgr, !*noColor && os.Getenv("NO_COLOR") == "")
+		if err := repl.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Interactive results are only written out when explicitly requested
+		if *output == "" {
+			return
+		}
+	} else if *execute != "" {
 		// Single command execution
+		start := time.Now()
 		result, err := mcpClient.ExecuteCommand(*execute)
 		if err != nil {
 			result = map[string]interface{}{
@@ -89,6 +160,13 @@ func main() {
 			}
 		}
 		outputMgr.AddResult(*execute, result)
+		reportSuite = "execute"
+		reportResults = []client.BatchResult{{
+			Command:   client.BatchCommand{Command: *execute},
+			Result:    result,
+			StartedAt: start,
+			Duration:  time.Since(start),
+		}}
 		if *verbose {
 			fmt.Printf("Command executed: %s\n", *execute)
 			resultJSON, _ := json.MarshalIndent(result, "", "  ")
@@ -97,30 +175,54 @@ func main() {
 			fmt.Printf("Command executed: %s\n", *execute)
 		}
 	} else if *file != "" {
-		// Batch command execution
-		commands, err := readCommandsFile(*file)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading command file: %v\n", err)
-			os.Exit(1)
+		// Batch command execution; every extra worker gets its own
+		// connection since a transport handles one request at a time
+		workers := *parallel
+		if workers > len(commands) {
+			workers = len(commands)
 		}
-
-		fmt.Printf("Executing %d commands from %s...\n", len(commands), *file)
-		for i, command := range commands {
-			fmt.Printf("[%d/%d] Executing: %s\n", i+1, len(commands), command)
-			result, err := mcpClient.ExecuteCommand(command)
+		clients := []*client.MCPClient{mcpClient}
+		for len(clients) < workers {
+			workerClient, err := client.NewMCPClient(config, *verbose)
 			if err != nil {
-				result = map[string]interface{}{
-					"error":   err.Error(),
-					"command": command,
-				}
-				fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+				fmt.Fprintf(os.Stderr, "Error creating MCP client for worker %d: %v\n", len(clients)+1, err)
+				os.Exit(1)
+			}
+			if err := workerClient.Connect(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error connecting worker %d to MCP server: %v\n", len(clients)+1, err)
+				os.Exit(1)
+			}
+			defer workerClient.Disconnect()
+			clients = append(clients, workerClient)
+		}
+
+		if workers > 1 {
+			fmt.Printf("Executing %d commands from %s with %d workers...\n", len(commands), *file, workers)
+		} else {
+			fmt.Printf("Executing %d commands from %s...\n", len(commands), *file)
+		}
+		batchStart := time.Now()
+		results := client.RunBatch(commands, clients, func(cmd client.BatchCommand) {
+			fmt.Printf("[%d/%d] Executing: %s\n", cmd.Index+1, len(commands), cmd.Command)
+		})
+		batchEnd := time.Now()
+		reportStart = batchStart
+		reportResults = results
+
+		for _, r := range results {
+			if r.Skipped {
+				fmt.Fprintf(os.Stderr, "[%d/%d] Skipped: %s (%v)\n", r.Command.Index+1, len(commands), r.Command.Command, r.Result["error"])
+			} else if errMsg, hasError := r.Result["error"]; hasError {
+				fmt.Fprintf(os.Stderr, "[%d/%d] Error: %v\n", r.Command.Index+1, len(commands), errMsg)
 			}
-			outputMgr.AddResult(command, result)
 			if *verbose {
-				resultJSON, _ := json.MarshalIndent(result, "", "  ")
-				fmt.Printf("  Result: %s\n", string(resultJSON))
+				resultJSON, _ := json.MarshalIndent(r.Result, "", "  ")
+				fmt.Printf("[%d/%d] %s (%s)\n  Result: %s\n", r.Command.Index+1, len(commands), r.Command.Command, r.Duration, string(resultJSON))
 			}
+			outputMgr.AddBatchResult(r)
 		}
+		outputMgr.SetMetadata("parallel_workers", workers)
+		outputMgr.SetMetadata("batch_duration_ms", float64(batchEnd.Sub(batchStart).Microseconds())/1000)
 	}
 
 	// Save output
@@ -130,58 +232,60 @@ func main() {
 		os.Exit(1)
 	}
 	fmt.Printf("\nResults saved to: %s\n", outputFile)
-}
-
-func readCommandsFile(filePath string) ([]string, error) {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
 
-	var commands []string
-	lines := splitLines(string(data))
-	for _, line := range lines {
-		line = trimSpace(line)
-		// Skip empty lines and comments
-		if line == "" || line[0] == '#' {
-			continue
+	if *report != "" {
+		path := *reportFile
+		if path == "" {
+			path = strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + client.ReportExtension(*report)
+		}
+		if err := writeReport(path, *report, reportSuite, reportStart, reportResults); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+			os.Exit(1)
+		}
+		if path != "-" {
+			fmt.Printf("Report saved to: %s\n", path)
 		}
-		commands = append(commands, line)
 	}
-
-	return commands, nil
 }
 
-func splitLines(s string) []string {
-	var lines []string
-	var current []rune
-	for _, r := range s {
-		if r == '\n' {
-			if len(current) > 0 {
-				lines = append(lines, string(current))
-				current = nil
-			} else {
-				lines = append(lines, "")
-			}
-		} else if r != '\r' {
-			current = append(current, r)
+// writeReport writes the test report to path, or to stdout for "-"
+func writeReport(path, format, suite string, startedAt time.Time, results []client.BatchResult) error {
+	if path == "-" {
+		return client.WriteReport(os.Stdout, format, suite, startedAt, results)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
 		}
 	}
-	if len(current) > 0 {
-		lines = append(lines, string(current))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
 	}
-	return lines
+	if err := client.WriteReport(f, format, suite, startedAt, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
-func trimSpace(s string) string {
-	start := 0
-	end := len(s)
-	for start < end && (s[start] == ' ' || s[start] == '\t') {
-		start++
-	}
-	for end > start && (s[end-1] == ' ' || s[end-1] == '\t') {
-		end--
+func readCommandsFile(filePath string) ([]client.BatchCommand, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
 	}
-	return s[start:end]
+
+	return client.ParseBatchCommands(string(data))
 }
 
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,6 +11,9 @@ import (
 )
 
 func main() {
+	mock := flag.Bool("mock", false, "run without a database, answering tools with canned data flagged as mock")
+	flag.Parse()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -23,7 +27,7 @@ func main() {
 	}()
 
 	// Create and start server
-	srv, err := server.NewServer()
+	srv, err := server.NewServerWithOptions(server.Options{Mock: *mock})
 	if err != nil {
 		os.Stderr.WriteString("Failed to create server: " + err.Error() + "\n")
 		os.Exit(1)
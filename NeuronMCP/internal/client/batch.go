@@ -0,0 +1,277 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BatchCommand is a single command from a command file together with the
+// directives that preceded it
+type BatchCommand struct {
+	Index   int      // position in the file, starting at 0
+	Line    int      // 1-based line number in the file
+	Label   string   // set by "@label name"
+	Depends []string // set by "@depends a,b"
+	Command string
+}
+
+// BatchResult is the outcome of running one BatchCommand
+type BatchResult struct {
+	Command   BatchCommand
+	Result    map[string]interface{}
+	StartedAt time.Time
+	Duration  time.Duration
+	Skipped   bool
+}
+
+// Failed reports whether the command errored, returned a tool error or was
+// skipped because a dependency failed
+func (r *BatchResult) Failed() bool {
+	return r.Skipped || resultFailed(r.Result)
+}
+
+func resultFailed(result map[string]interface{}) bool {
+	if _, hasError := result["error"]; hasError {
+		return true
+	}
+	isError, _ := result["isError"].(bool)
+	return isError
+}
+
+// ParseBatchCommands parses the contents of a command file. Blank lines and
+// lines starting with '#' are ignored. Lines starting with '@' are
+// directives that apply to the next command:
+//
+//	@label <name>        name the next command
+//	@depends <a>[,<b>]   run the next command only after the labelled
+//	                     commands have finished successfully
+//
+// A command may only depend on labels defined earlier in the file, which
+// rules out cycles.
+func ParseBatchCommands(content string) ([]BatchCommand, error) {
+	var commands []BatchCommand
+	labels := make(map[string]int)
+
+	var pendingLabel string
+	var pendingDepends []string
+	pendingLine := 0
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, raw := range lines {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '@' {
+			fields := strings.Fields(line[1:])
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: empty directive", lineNo)
+			}
+			directive := fields[0]
+			value := strings.TrimSpace(strings.TrimPrefix(line[1:], directive))
+
+			switch directive {
+			case "label":
+				if value == "" || strings.ContainsAny(value, " \t,") {
+					return nil, fmt.Errorf("line %d: @label requires a single name without spaces or commas", lineNo)
+				}
+				if pendingLabel != "" {
+					return nil, fmt.Errorf("line %d: @label %q already set for the next command at line %d", lineNo, pendingLabel, pendingLine)
+				}
+				if prev, exists := labels[value]; exists {
+					return nil, fmt.Errorf("line %d: duplicate label %q (first defined at line %d)", lineNo, value, commands[prev].Line)
+				}
+				pendingLabel = value
+			case "depends":
+				if value == "" {
+					return nil, fmt.Errorf("line %d: @depends requires at least one label", lineNo)
+				}
+				for _, dep := range strings.Split(value, ",") {
+					dep = strings.TrimSpace(dep)
+					if dep == "" {
+						continue
+					}
+					if containsString(pendingDepends, dep) {
+						continue
+					}
+					if _, exists := labels[dep]; !exists {
+						return nil, fmt.Errorf("line %d: @depends references unknown label %q (labels must be defined on an earlier command)", lineNo, dep)
+					}
+					pendingDepends = append(pendingDepends, dep)
+				}
+			default:
+				return nil, fmt.Errorf("line %d: unknown directive @%s (supported: @label, @depends)", lineNo, directive)
+			}
+			pendingLine = lineNo
+			continue
+		}
+
+		cmd := BatchCommand{
+			Index:   len(commands),
+			Line:    lineNo,
+			Label:   pendingLabel,
+			Depends: pendingDepends,
+			Command: line,
+		}
+		if cmd.Label != "" {
+			labels[cmd.Label] = cmd.Index
+		}
+		commands = append(commands, cmd)
+
+		pendingLabel = ""
+		pendingDepends = nil
+	}
+
+	if pendingLabel != "" || pendingDepends != nil {
+		return nil, fmt.Errorf("line %d: directive is not followed by a command", pendingLine)
+	}
+
+	return commands, nil
+}
+
+// RunBatch executes commands using one worker per client. Whenever a worker
+// is free it takes the earliest command in file order whose dependencies have
+// all finished, so with a single client commands run exactly in file order.
+// Commands whose dependencies failed are skipped, as are their own
+// dependents. Results are returned in file order. onStart, if non-nil, is
+// called from the scheduling goroutine just before each command is sent.
+func RunBatch(commands []BatchCommand, clients []*MCPClient, onStart func(cmd BatchCommand)) []BatchResult {
+	results := make([]BatchResult, len(commands))
+	if len(commands) == 0 || len(clients) == 0 {
+		return results
+	}
+
+	labelIndex := make(map[string]int, len(commands))
+	for _, cmd := range commands {
+		if cmd.Label != "" {
+			labelIndex[cmd.Label] = cmd.Index
+		}
+	}
+
+	// Build the dependency graph
+	remaining := make([]int, len(commands))
+	dependents := make([][]int, len(commands))
+	for _, cmd := range commands {
+		for _, dep := range cmd.Depends {
+			depIndex := labelIndex[dep]
+			remaining[cmd.Index]++
+			dependents[depIndex] = append(dependents[depIndex], cmd.Index)
+		}
+	}
+
+	var ready []int
+	for i := range commands {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	type completion struct {
+		index  int
+		client *MCPClient
+	}
+	completions := make(chan completion)
+	free := append([]*MCPClient(nil), clients...)
+	running := 0
+	finished := 0
+
+	for finished < len(commands) {
+		// Dispatch ready commands in file order while workers are free
+		for len(free) > 0 && len(ready) > 0 {
+			index := ready[0]
+			ready = ready[1:]
+			c := free[len(free)-1]
+			free = free[:len(free)-1]
+			running++
+
+			if onStart != nil {
+				onStart(commands[index])
+			}
+			go func(cmd BatchCommand, c *MCPClient) {
+				start := time.Now()
+				result, err := c.ExecuteCommand(cmd.Command)
+				if err != nil {
+					result = map[string]interface{}{
+						"error":   err.Error(),
+						"command": cmd.Command,
+					}
+				}
+				results[cmd.Index] = BatchResult{
+					Command:   cmd,
+					Result:    result,
+					StartedAt: start,
+					Duration:  time.Since(start),
+				}
+				completions <- completion{index: cmd.Index, client: c}
+			}(commands[index], c)
+		}
+
+		if running == 0 {
+			// Nothing is running and nothing is ready; cannot happen with
+			// labels that only point backwards, but never spin forever
+			break
+		}
+
+		done := <-completions
+		running--
+		free = append(free, done.client)
+
+		// Release dependents, cascading skips through failed dependencies
+		finishedNow := []int{done.index}
+		for len(finishedNow) > 0 {
+			index := finishedNow[0]
+			finishedNow = finishedNow[1:]
+			finished++
+
+			for _, dependent := range dependents[index] {
+				if results[dependent].Skipped {
+					continue
+				}
+				if results[index].Failed() {
+					dep := commands[index]
+					results[dependent] = BatchResult{
+						Command:   commands[dependent],
+						StartedAt: time.Now(),
+						Skipped:   true,
+						Result: map[string]interface{}{
+							"error":   fmt.Sprintf("skipped: dependency %q (line %d) failed", dep.Label, dep.Line),
+							"command": commands[dependent].Command,
+						},
+					}
+					finishedNow = append(finishedNow, dependent)
+					continue
+				}
+				remaining[dependent]--
+				if remaining[dependent] == 0 {
+					ready = insertSorted(ready, dependent)
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// insertSorted inserts v into the ascending slice s
+func insertSorted(s []int, v int) []int {
+	i := len(s)
+	for i > 0 && s[i-1] > v {
+		i--
+	}
+	s = append(s, 0)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
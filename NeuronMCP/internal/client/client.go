@@ -3,7 +3,9 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/neurondb/NeuronMCP/pkg/mcp"
@@ -14,6 +16,8 @@ type MCPConfig struct {
 	Command string
 	Env     map[string]string
 	Args    []string
+	// Roots are the directories shared with the server as MCP roots
+	Roots []string
 }
 
 // GetEnv returns environment variables, merging with current environment
@@ -65,6 +69,7 @@ func (c *MCPClient) Connect() error {
 	}
 
 	c.transport = transport
+	c.transport.SetRequestHandler(c.handleServerRequest)
 
 	// Start server process
 	if c.verbose {
@@ -92,7 +97,7 @@ func (c *MCPClient) initialize() error {
 		Method:  "initialize",
 		Params: json.RawMessage(`{
 			"protocolVersion": "2025-06-18",
-			"capabilities": {},
+			"capabilities": ` + c.capabilities() + `,
 			"clientInfo": {
 				"name": "neurondb-mcp-client",
 				"version": "1.0.0"
@@ -229,6 +234,12 @@ func (c *MCPClient) ExecuteCommand(commandStr string) (map[string]interface{}, e
 		}, nil
 	}
 
+	return c.Execute(toolName, arguments)
+}
+
+// Execute runs an already parsed command, dispatching the special
+// list_tools and resources/* commands before falling back to tools/call
+func (c *MCPClient) Execute(toolName string, arguments map[string]interface{}) (map[string]interface{}, error) {
 	// Handle special commands
 	if toolName == "list_tools" {
 		return c.ListTools()
@@ -329,3 +340,39 @@ func generateID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
+// capabilities returns the client capabilities sent on initialize. Roots are
+// declared only when some are configured.
+func (c *MCPClient) capabilities() string {
+	if len(c.config.Roots) == 0 {
+		return `{}`
+	}
+	return `{"roots": {"listChanged": false}}`
+}
+
+// handleServerRequest answers requests the server sends to the client
+func (c *MCPClient) handleServerRequest(method string, params json.RawMessage) (interface{}, *mcp.JSONRPCError) {
+	switch method {
+	case "roots/list":
+		roots, err := c.roots()
+		if err != nil {
+			return nil, &mcp.JSONRPCError{Code: mcp.ErrCodeInternalError, Message: err.Error()}
+		}
+		return mcp.ListRootsResult{Roots: roots}, nil
+	default:
+		return nil, &mcp.JSONRPCError{Code: mcp.ErrCodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+	}
+}
+
+// roots returns the configured root directories as file URIs
+func (c *MCPClient) roots() ([]mcp.Root, error) {
+	roots := make([]mcp.Root, 0, len(c.config.Roots))
+	for _, dir := range c.config.Roots {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root '%s': %w", dir, err)
+		}
+		uri := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+		roots = append(roots, mcp.Root{URI: uri.String(), Name: filepath.Base(abs)})
+	}
+	return roots, nil
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// ANSI color codes used for result printing
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorBold    = "\x1b[1m"
+)
+
+// colorize wraps s in the given color when enabled
+func colorize(enabled bool, color, s string) string {
+	if !enabled {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// FormatResult pretty-prints a command result as indented JSON, optionally
+// highlighting keys, strings, numbers and literals with ANSI colors
+func FormatResult(result map[string]interface{}, color bool) string {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return colorize(color, colorRed, "failed to format result: "+err.Error())
+	}
+	if !color {
+		return string(data)
+	}
+	return highlightJSON(data)
+}
+
+// highlightJSON colors already indented JSON without re-parsing it
+func highlightJSON(data []byte) string {
+	var out strings.Builder
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case c == '"':
+			end := scanJSONString(data, i)
+			token := string(data[i:end])
+			// A string followed by a colon is an object key
+			rest := bytes.TrimLeft(data[end:], " ")
+			if len(rest) > 0 && rest[0] == ':' {
+				out.WriteString(colorCyan + token + colorReset)
+			} else {
+				out.WriteString(colorGreen + token + colorReset)
+			}
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(data) && strings.IndexByte("0123456789.eE+-", data[end]) >= 0 {
+				end++
+			}
+			out.WriteString(colorYellow + string(data[i:end]) + colorReset)
+			i = end
+		case bytes.HasPrefix(data[i:], []byte("true")):
+			out.WriteString(colorMagenta + "true" + colorReset)
+			i += 4
+		case bytes.HasPrefix(data[i:], []byte("false")):
+			out.WriteString(colorMagenta + "false" + colorReset)
+			i += 5
+		case bytes.HasPrefix(data[i:], []byte("null")):
+			out.WriteString(colorMagenta + "null" + colorReset)
+			i += 4
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// scanJSONString returns the index just past the closing quote of the JSON
+// string starting at data[start]
+func scanJSONString(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
@@ -60,10 +60,21 @@ func LoadConfig(configPath, serverName string) (*MCPConfig, error) {
 		}
 	}
 
+	// Extract roots shared with the server (if any)
+	var roots []string
+	if rootsList, ok := serverConfig["roots"].([]interface{}); ok {
+		for _, root := range rootsList {
+			if str, ok := root.(string); ok {
+				roots = append(roots, str)
+			}
+		}
+	}
+
 	return &MCPConfig{
 		Command: command,
 		Env:     env,
 		Args:    args,
+		Roots:   roots,
 	}, nil
 }
 
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// CompleteFunc returns candidate completions for the word that ends at the
+// cursor. line is the text before the cursor.
+type CompleteFunc func(line string) (word string, candidates []string)
+
+// LineEditor reads lines from a terminal with history navigation and tab
+// completion. When stdin is not a terminal it falls back to plain line reads.
+type LineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	tty      bool
+	history  []string
+	complete CompleteFunc
+}
+
+// NewLineEditor creates a line editor on stdin/stdout
+func NewLineEditor(complete CompleteFunc) *LineEditor {
+	fd := int(os.Stdin.Fd())
+	return &LineEditor{
+		in:       bufio.NewReader(os.Stdin),
+		out:      os.Stdout,
+		fd:       fd,
+		tty:      isTerminal(fd),
+		complete: complete,
+	}
+}
+
+// IsTerminal reports whether the editor is attached to an interactive terminal
+func (e *LineEditor) IsTerminal() bool {
+	return e.tty
+}
+
+// AddHistory appends an entry to the in-memory history, skipping blanks and
+// immediate duplicates
+func (e *LineEditor) AddHistory(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+// History returns the current history entries, oldest first
+func (e *LineEditor) History() []string {
+	return e.history
+}
+
+// ReadLine prints prompt and reads one line of input. It returns io.EOF on
+// Ctrl-D at an empty line and ErrInterrupted on Ctrl-C.
+func (e *LineEditor) ReadLine(prompt string) (string, error) {
+	if !e.tty {
+		return e.readPlain(prompt)
+	}
+
+	state, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer state.restore()
+
+	return e.readRaw(prompt)
+}
+
+func (e *LineEditor) readPlain(prompt string) (string, error) {
+	if e.tty {
+		fmt.Fprint(e.out, prompt)
+	}
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (e *LineEditor) readRaw(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	histIdx := len(e.history)
+	var pending []rune // line being edited before browsing history
+	lastWasTab := false
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(s []rune) {
+		buf = append([]rune(nil), s...)
+		pos = len(buf)
+		redraw()
+	}
+
+	fmt.Fprint(e.out, prompt)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		isTab := r == '\t'
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\n")
+			return "", ErrInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+				redraw()
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+				redraw()
+			}
+		case 1: // Ctrl-A
+			pos = 0
+			redraw()
+		case 5: // Ctrl-E
+			pos = len(buf)
+			redraw()
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+			redraw()
+		case 21: // Ctrl-U
+			buf = append([]rune(nil), buf[pos:]...)
+			pos = 0
+			redraw()
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			redraw()
+		case '\t':
+			if e.complete == nil {
+				break
+			}
+			word, candidates := e.complete(string(buf[:pos]))
+			if len(candidates) == 0 {
+				break
+			}
+			if len(candidates) == 1 {
+				insert := []rune(strings.TrimPrefix(candidates[0], word))
+				buf = append(buf[:pos], append(insert, buf[pos:]...)...)
+				pos += len(insert)
+				redraw()
+				break
+			}
+			prefix := commonPrefix(candidates)
+			if len(prefix) > len(word) {
+				insert := []rune(strings.TrimPrefix(prefix, word))
+				buf = append(buf[:pos], append(insert, buf[pos:]...)...)
+				pos += len(insert)
+				redraw()
+			} else if lastWasTab {
+				fmt.Fprint(e.out, "\n"+strings.Join(candidates, "  ")+"\n")
+				fmt.Fprint(e.out, prompt)
+				redraw()
+			}
+		case 27: // Escape sequence
+			seq := e.readEscape()
+			switch seq {
+			case "[A": // Up
+				if histIdx > 0 {
+					if histIdx == len(e.history) {
+						pending = append([]rune(nil), buf...)
+					}
+					histIdx--
+					setLine([]rune(e.history[histIdx]))
+				}
+			case "[B": // Down
+				if histIdx < len(e.history) {
+					histIdx++
+					if histIdx == len(e.history) {
+						setLine(pending)
+					} else {
+						setLine([]rune(e.history[histIdx]))
+					}
+				}
+			case "[C": // Right
+				if pos < len(buf) {
+					pos++
+					redraw()
+				}
+			case "[D": // Left
+				if pos > 0 {
+					pos--
+					redraw()
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+				redraw()
+			case "[F", "OF", "[4~":
+				pos = len(buf)
+				redraw()
+			case "[3~": // Delete
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+					redraw()
+				}
+			}
+		default:
+			if r >= 32 {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+				redraw()
+			}
+		}
+		lastWasTab = isTab
+	}
+}
+
+// readEscape reads the remainder of an ANSI escape sequence after ESC
+func (e *LineEditor) readEscape() string {
+	var seq strings.Builder
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return ""
+	}
+	seq.WriteRune(r)
+	if r != '[' && r != 'O' {
+		return seq.String()
+	}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return seq.String()
+		}
+		seq.WriteRune(r)
+		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || r == '~' {
+			return seq.String()
+		}
+	}
+}
+
+// commonPrefix returns the longest prefix shared by all candidates
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
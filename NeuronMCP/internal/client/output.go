@@ -13,6 +13,7 @@ type OutputManager struct {
 	outputPath string
 	results    []ResultEntry
 	startTime  time.Time
+	metadata   map[string]interface{}
 }
 
 // ResultEntry represents a single command result
@@ -20,6 +21,14 @@ type ResultEntry struct {
 	Timestamp string                 `json:"timestamp"`
 	Command   string                 `json:"command"`
 	Result    map[string]interface{} `json:"result"`
+
+	// Batch execution details, only set for commands run from a file
+	Label      string   `json:"label,omitempty"`
+	Line       int      `json:"line,omitempty"`
+	DependsOn  []string `json:"depends_on,omitempty"`
+	StartedAt  string   `json:"started_at,omitempty"`
+	DurationMs *float64 `json:"duration_ms,omitempty"`
+	Skipped    bool     `json:"skipped,omitempty"`
 }
 
 // NewOutputManager creates a new output manager
@@ -28,9 +37,15 @@ func NewOutputManager(outputPath string) *OutputManager {
 		outputPath: outputPath,
 		results:    make([]ResultEntry, 0),
 		startTime:  time.Now(),
+		metadata:   make(map[string]interface{}),
 	}
 }
 
+// SetMetadata adds an extra key to the metadata section of the output file
+func (om *OutputManager) SetMetadata(key string, value interface{}) {
+	om.metadata[key] = value
+}
+
 // AddResult adds a command result
 func (om *OutputManager) AddResult(command string, result map[string]interface{}) {
 	entry := ResultEntry{
@@ -41,6 +56,57 @@ func (om *OutputManager) AddResult(command string, result map[string]interface{}
 	om.results = append(om.results, entry)
 }
 
+// AddBatchResult adds the result of a command run from a command file,
+// including its label, dependencies and timing
+func (om *OutputManager) AddBatchResult(result BatchResult) {
+	entry := ResultEntry{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Command:   result.Command.Command,
+		Result:    result.Result,
+		Label:     result.Command.Label,
+		Line:      result.Command.Line,
+		DependsOn: result.Command.Depends,
+		Skipped:   result.Skipped,
+	}
+	if !result.Skipped {
+		durationMs := float64(result.Duration.Microseconds()) / 1000
+		entry.StartedAt = result.StartedAt.Format(time.RFC3339Nano)
+		entry.DurationMs = &durationMs
+	}
+	om.results = append(om.results, entry)
+}
+
+// timingSummary aggregates per-command durations, or returns nil when no
+// result carries timing information
+func (om *OutputManager) timingSummary() map[string]interface{} {
+	var total, minMs, maxMs float64
+	timed := 0
+	for _, r := range om.results {
+		if r.DurationMs == nil {
+			continue
+		}
+		d := *r.DurationMs
+		if timed == 0 || d < minMs {
+			minMs = d
+		}
+		if d > maxMs {
+			maxMs = d
+		}
+		total += d
+		timed++
+	}
+	if timed == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"timed_commands":     timed,
+		"total_command_ms":   total,
+		"average_command_ms": total / float64(timed),
+		"min_command_ms":     minMs,
+		"max_command_ms":     maxMs,
+	}
+}
+
 // Save saves results to file
 func (om *OutputManager) Save() (string, error) {
 	var outputFile string
@@ -63,7 +129,7 @@ func (om *OutputManager) Save() (string, error) {
 	successful := 0
 	failed := 0
 	for _, r := range om.results {
-		if _, hasError := r.Result["error"]; hasError {
+		if resultFailed(r.Result) {
 			failed++
 		} else {
 			successful++
@@ -71,15 +137,22 @@ func (om *OutputManager) Save() (string, error) {
 	}
 
 	// Prepare output data
+	metadata := map[string]interface{}{
+		"start_time":        om.startTime.Format(time.RFC3339),
+		"end_time":          time.Now().Format(time.RFC3339),
+		"total_commands":    len(om.results),
+		"successful_commands": successful,
+		"failed_commands":    failed,
+	}
+	if timing := om.timingSummary(); timing != nil {
+		metadata["timing"] = timing
+	}
+	for k, v := range om.metadata {
+		metadata[k] = v
+	}
 	outputData := map[string]interface{}{
-		"metadata": map[string]interface{}{
-			"start_time":        om.startTime.Format(time.RFC3339),
-			"end_time":          time.Now().Format(time.RFC3339),
-			"total_commands":    len(om.results),
-			"successful_commands": successful,
-			"failed_commands":    failed,
-		},
-		"results": om.results,
+		"metadata": metadata,
+		"results":  om.results,
 	}
 
 	// Write to file
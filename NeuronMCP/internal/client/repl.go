@@ -0,0 +1,435 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	replPrompt             = "mcp> "
+	replContinuationPrompt = "...> "
+	replHistoryFile        = ".neurondb_mcp_history"
+	replHistoryLimit       = 1000
+)
+
+// specialCommands are handled by the client itself rather than tools/call
+var specialCommands = []string{"list_tools", "resources/list", "resources/read"}
+
+// metaCommands control the REPL and are never sent to the server
+var metaCommands = []string{".help", ".tools", ".save", ".history", ".exit", ".quit"}
+
+// REPL is an interactive read-eval-print loop on top of an MCPClient
+type REPL struct {
+	client      *MCPClient
+	outputMgr   *OutputManager
+	editor      *LineEditor
+	color       bool
+	historyPath string
+	tools       map[string][]string // tool name -> argument names
+	lastCommand string
+	lastResult  map[string]interface{}
+}
+
+// NewREPL creates a REPL. Colors are only used when color is true and stdin
+// is an interactive terminal.
+func NewREPL(client *MCPClient, outputMgr *OutputManager, color bool) *REPL {
+	r := &REPL{
+		client:    client,
+		outputMgr: outputMgr,
+		tools:     make(map[string][]string),
+	}
+	r.editor = NewLineEditor(r.completeLine)
+	r.color = color && r.editor.IsTerminal()
+
+	if home, err := os.UserHomeDir(); err == nil {
+		r.historyPath = filepath.Join(home, replHistoryFile)
+	}
+	return r
+}
+
+// Run reads commands until EOF or .exit
+func (r *REPL) Run() error {
+	r.loadHistory()
+	if err := r.refreshTools(); err != nil {
+		r.printError(fmt.Sprintf("Failed to load tool list (completion disabled): %v", err))
+	}
+
+	if r.editor.IsTerminal() {
+		fmt.Printf("NeuronMCP interactive client - %d tools available. Type .help for help.\n", len(r.tools))
+	}
+
+	for {
+		input, err := r.readInput()
+		if errors.Is(err, ErrInterrupted) {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+		r.addHistory(input)
+
+		if strings.HasPrefix(input, ".") || input == "exit" || input == "quit" {
+			if done := r.handleMeta(input); done {
+				return nil
+			}
+			continue
+		}
+
+		r.execute(input)
+	}
+}
+
+// readInput reads one logical command. A command whose JSON arguments have
+// unbalanced braces or brackets continues on the following lines.
+func (r *REPL) readInput() (string, error) {
+	line, err := r.editor.ReadLine(r.prompt(replPrompt))
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{line}
+	for jsonDepth(strings.Join(lines, "\n")) > 0 {
+		next, err := r.editor.ReadLine(r.prompt(replContinuationPrompt))
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, next)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (r *REPL) prompt(p string) string {
+	return colorize(r.color, colorBold+colorBlue, p)
+}
+
+// execute runs a command in either tool:arg=val form or tool {json} form
+func (r *REPL) execute(input string) {
+	toolName, arguments, err := ParseREPLCommand(input)
+	if err != nil {
+		r.printError(err.Error())
+		return
+	}
+
+	start := time.Now()
+	result, err := r.client.Execute(toolName, arguments)
+	elapsed := time.Since(start)
+	if err != nil {
+		result = map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	command := strings.Join(strings.Fields(input), " ")
+	r.lastCommand = command
+	r.lastResult = result
+	if r.outputMgr != nil {
+		r.outputMgr.AddResult(command, result)
+	}
+
+	_, hasError := result["error"]
+	isError, _ := result["isError"].(bool)
+	if hasError || isError {
+		fmt.Println(colorize(r.color, colorRed, fmt.Sprintf("Error (%s):", elapsed.Round(time.Millisecond))))
+	} else {
+		fmt.Println(colorize(r.color, colorGreen, fmt.Sprintf("OK (%s):", elapsed.Round(time.Millisecond))))
+	}
+	fmt.Println(FormatResult(result, r.color))
+
+	// Keep completion in sync if the user listed tools explicitly
+	if toolName == "list_tools" && !hasError {
+		r.setTools(result)
+	}
+}
+
+// handleMeta runs a REPL meta command, returning true when the REPL should exit
+func (r *REPL) handleMeta(input string) bool {
+	fields := strings.Fields(input)
+	switch fields[0] {
+	case ".exit", ".quit", "exit", "quit":
+		return true
+	case ".help":
+		r.printHelp()
+	case ".tools":
+		if err := r.refreshTools(); err != nil {
+			r.printError(fmt.Sprintf("Failed to list tools: %v", err))
+			return false
+		}
+		names := r.toolNames()
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Printf("%d tools\n", len(names))
+	case ".history":
+		for i, entry := range r.editor.History() {
+			fmt.Printf("%5d  %s\n", i+1, entry)
+		}
+	case ".save":
+		path := ""
+		if len(fields) > 1 {
+			path = fields[1]
+		}
+		saved, err := r.saveLastResult(path)
+		if err != nil {
+			r.printError(err.Error())
+			return false
+		}
+		fmt.Printf("Last result saved to: %s\n", saved)
+	default:
+		r.printError(fmt.Sprintf("Unknown command: %s (type .help for help)", fields[0]))
+	}
+	return false
+}
+
+func (r *REPL) printHelp() {
+	fmt.Println(`Commands:
+  tool_name                          Call a tool without arguments
+  tool_name:arg1=val1,arg2=val2      Call a tool with key=value arguments
+  tool_name {"arg1": "val1", ...}    Call a tool with JSON arguments; an
+                                     unclosed { continues on the next line
+  list_tools                         List tools exposed by the server
+  resources/list                     List resources
+  resources/read:uri=<uri>           Read a resource
+
+Meta commands:
+  .tools                             Refresh and print tool names
+  .save [file]                       Save the last result as JSON
+  .history                           Show command history
+  .help                              Show this help
+  .exit, .quit                       Leave the REPL (Ctrl-D also works)
+
+Keys: Tab completes tool and argument names, Up/Down browse history,
+Ctrl-C cancels the current input.`)
+}
+
+func (r *REPL) printError(msg string) {
+	fmt.Fprintln(os.Stderr, colorize(r.color, colorRed, msg))
+}
+
+// saveLastResult writes the result of the last call to path, or to
+// result_<timestamp>.json when path is empty
+func (r *REPL) saveLastResult(path string) (string, error) {
+	if r.lastResult == nil {
+		return "", fmt.Errorf("no result to save: no command has been executed yet")
+	}
+	if path == "" {
+		path = fmt.Sprintf("result_%s.json", time.Now().Format("20060102_150405"))
+	}
+
+	dir := filepath.Dir(path)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(ResultEntry{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Command:   r.lastCommand,
+		Result:    r.lastResult,
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write result file: %w", err)
+	}
+	return path, nil
+}
+
+// refreshTools reloads tool names and argument names from tools/list
+func (r *REPL) refreshTools() error {
+	result, err := r.client.ListTools()
+	if err != nil {
+		return err
+	}
+	if msg, ok := result["error"]; ok {
+		return fmt.Errorf("%v", msg)
+	}
+	r.setTools(result)
+	return nil
+}
+
+func (r *REPL) setTools(result map[string]interface{}) {
+	toolList, ok := result["tools"].([]interface{})
+	if !ok {
+		return
+	}
+
+	tools := make(map[string][]string, len(toolList))
+	for _, t := range toolList {
+		tool, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := tool["name"].(string)
+		if name == "" {
+			continue
+		}
+		var args []string
+		if schema, ok := tool["inputSchema"].(map[string]interface{}); ok {
+			if props, ok := schema["properties"].(map[string]interface{}); ok {
+				for arg := range props {
+					args = append(args, arg)
+				}
+			}
+		}
+		sort.Strings(args)
+		tools[name] = args
+	}
+	r.tools = tools
+}
+
+func (r *REPL) toolNames() []string {
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeLine implements CompleteFunc: the first word completes to tool,
+// special and meta command names; after "tool:" it completes argument names.
+func (r *REPL) completeLine(line string) (string, []string) {
+	if idx := strings.Index(line, ":"); idx >= 0 && !strings.ContainsAny(line[:idx], " {") {
+		toolName := strings.TrimSpace(line[:idx])
+		segment := line[idx+1:]
+		if comma := strings.LastIndex(segment, ","); comma >= 0 {
+			segment = segment[comma+1:]
+		}
+		if strings.Contains(segment, "=") {
+			return segment, nil
+		}
+		var candidates []string
+		for _, arg := range r.tools[toolName] {
+			if strings.HasPrefix(arg, segment) {
+				candidates = append(candidates, arg+"=")
+			}
+		}
+		return segment, candidates
+	}
+
+	if strings.ContainsAny(line, " {") {
+		return "", nil
+	}
+
+	var pool []string
+	if strings.HasPrefix(line, ".") {
+		pool = metaCommands
+	} else {
+		pool = append(append([]string{}, specialCommands...), r.toolNames()...)
+	}
+
+	var candidates []string
+	for _, name := range pool {
+		if strings.HasPrefix(name, line) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return line, candidates
+}
+
+func (r *REPL) loadHistory() {
+	if r.historyPath == "" {
+		return
+	}
+	f, err := os.Open(r.historyPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entries = append(entries, scanner.Text())
+	}
+	if len(entries) > replHistoryLimit {
+		entries = entries[len(entries)-replHistoryLimit:]
+	}
+	for _, entry := range entries {
+		r.editor.AddHistory(entry)
+	}
+}
+
+// addHistory records a command in memory and appends it to the history file.
+// Multi-line input is stored on a single line.
+func (r *REPL) addHistory(input string) {
+	entry := strings.Join(strings.Fields(input), " ")
+	r.editor.AddHistory(entry)
+
+	if r.historyPath == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, entry)
+}
+
+// ParseREPLCommand parses interactive input. In addition to the batch
+// format understood by ParseCommand it accepts "tool_name {json}", where the
+// JSON object becomes the tool arguments.
+func ParseREPLCommand(input string) (string, map[string]interface{}, error) {
+	input = strings.TrimSpace(input)
+	brace := strings.Index(input, "{")
+	colon := strings.Index(input, ":")
+	if brace < 0 || (colon >= 0 && colon < brace) {
+		return ParseCommand(input)
+	}
+
+	toolName := strings.TrimSpace(input[:brace])
+	if toolName == "" {
+		return "", nil, fmt.Errorf("missing tool name before JSON arguments")
+	}
+
+	arguments := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(input[brace:]), &arguments); err != nil {
+		return "", nil, fmt.Errorf("invalid JSON arguments for %s: %w", toolName, err)
+	}
+	return toolName, arguments, nil
+}
+
+// jsonDepth returns the number of unclosed braces and brackets in s,
+// ignoring any that appear inside string literals
+func jsonDepth(s string) int {
+	depth := 0
+	inString := false
+	escaped := false
+	for _, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return depth
+}
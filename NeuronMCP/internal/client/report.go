@@ -0,0 +1,216 @@
+package client
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report formats for --report
+const (
+	ReportJUnit = "junit"
+	ReportTAP   = "tap"
+)
+
+// maxReportMessage bounds the failure message of a test case; the full
+// result is in the results JSON
+const maxReportMessage = 500
+
+// ReportExtension returns the file extension of a report format, or "" for
+// an unknown format
+func ReportExtension(format string) string {
+	switch format {
+	case ReportJUnit:
+		return ".xml"
+	case ReportTAP:
+		return ".tap"
+	}
+	return ""
+}
+
+// WriteReport writes batch results as a test report, one test case per
+// command. A command that could not be run or whose request failed is an
+// error, a tool that returned an error is a failure and a command skipped
+// because a dependency failed is skipped.
+func WriteReport(w io.Writer, format, suite string, startedAt time.Time, results []BatchResult) error {
+	switch format {
+	case ReportJUnit:
+		return writeJUnit(w, suite, startedAt, results)
+	case ReportTAP:
+		return writeTAP(w, results)
+	}
+	return fmt.Errorf("unknown report format %q (supported: junit, tap)", format)
+}
+
+// testCaseName names a command's test case by its label or line
+func testCaseName(r BatchResult) string {
+	name := r.Command.Command
+	if r.Command.Label != "" {
+		name = r.Command.Label + ": " + name
+	}
+	if r.Command.Line > 0 {
+		name = fmt.Sprintf("line %d: %s", r.Command.Line, name)
+	}
+	return name
+}
+
+// resultMessage returns the error of a failed result: the client's error,
+// or the text a tool returned with isError
+func resultMessage(result map[string]interface{}) string {
+	var message string
+	if errMsg, ok := result["error"]; ok {
+		message = fmt.Sprint(errMsg)
+	} else if content, ok := result["content"].([]interface{}); ok {
+		var texts []string
+		for _, item := range content {
+			if m, ok := item.(map[string]interface{}); ok {
+				if text, ok := m["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		message = strings.Join(texts, "\n")
+	}
+	if message == "" {
+		message = "tool returned an error"
+	}
+	if len(message) > maxReportMessage {
+		message = message[:maxReportMessage] + "..."
+	}
+	return message
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Tests   int              `xml:"tests,attr"`
+	Errors  int              `xml:"errors,attr"`
+	Failed  int              `xml:"failures,attr"`
+	Skipped int              `xml:"skipped,attr"`
+	Time    string           `xml:"time,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Failed    int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Skipped   *junitProblem `xml:"skipped,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func writeJUnit(w io.Writer, suite string, startedAt time.Time, results []BatchResult) error {
+	s := junitTestSuite{
+		Name:      suite,
+		Tests:     len(results),
+		Timestamp: startedAt.Format("2006-01-02T15:04:05"),
+	}
+	var total time.Duration
+	for _, r := range results {
+		total += r.Duration
+		tc := junitTestCase{
+			Name:      testCaseName(r),
+			Classname: suite,
+			Time:      junitSeconds(r.Duration),
+		}
+		switch {
+		case r.Skipped:
+			s.Skipped++
+			tc.Skipped = &junitProblem{Message: resultMessage(r.Result)}
+		case !r.Failed():
+		case r.Result["error"] != nil:
+			s.Errors++
+			tc.Error = &junitProblem{Message: resultMessage(r.Result), Type: "error"}
+		default:
+			s.Failed++
+			detail, _ := json.MarshalIndent(r.Result, "", "  ")
+			tc.Failure = &junitProblem{Message: resultMessage(r.Result), Type: "tool_error", Text: string(detail)}
+		}
+		s.Cases = append(s.Cases, tc)
+	}
+	s.Time = junitSeconds(total)
+
+	report := junitTestSuites{
+		Tests:   s.Tests,
+		Errors:  s.Errors,
+		Failed:  s.Failed,
+		Skipped: s.Skipped,
+		Time:    s.Time,
+		Suites:  []junitTestSuite{s},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// tapEscape keeps a description on one line and stops a '#' in it from
+// starting a directive
+func tapEscape(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "#", `\#`)
+}
+
+func writeTAP(w io.Writer, results []BatchResult) error {
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", len(results))
+	for i, r := range results {
+		description := tapEscape(testCaseName(r))
+		switch {
+		case r.Skipped:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP %s\n", i+1, description, tapEscape(resultMessage(r.Result)))
+			continue
+		case r.Failed():
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, description)
+		default:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, description)
+		}
+
+		// YAML diagnostics; the message is quoted as JSON, which YAML reads
+		b.WriteString("  ---\n")
+		fmt.Fprintf(&b, "  duration_ms: %.3f\n", float64(r.Duration.Microseconds())/1000)
+		if r.Failed() {
+			severity := "fail"
+			if r.Result["error"] != nil {
+				severity = "error"
+			}
+			message, _ := json.Marshal(resultMessage(r.Result))
+			fmt.Fprintf(&b, "  severity: %s\n", severity)
+			fmt.Fprintf(&b, "  message: %s\n", message)
+		}
+		b.WriteString("  ...\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func reportResults() []BatchResult {
+	return []BatchResult{
+		{Command: BatchCommand{Line: 1, Label: "index", Command: "create_hnsw_index:table=docs"}, Result: map[string]interface{}{"content": []interface{}{}}, Duration: 1500 * time.Millisecond},
+		{Command: BatchCommand{Line: 2, Command: "vector_search:table=docs"}, Result: map[string]interface{}{
+			"isError": true,
+			"content": []interface{}{map[string]interface{}{"type": "text", "text": "table # docs not found"}},
+		}, Duration: 20 * time.Millisecond},
+		{Command: BatchCommand{Line: 3, Command: "bad command"}, Result: map[string]interface{}{"error": "Failed to parse command"}},
+		{Command: BatchCommand{Line: 4, Command: "index_status"}, Skipped: true, Result: map[string]interface{}{"error": `skipped: dependency "index" (line 1) failed`}},
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	var b strings.Builder
+	if err := WriteReport(&b, ReportJUnit, "smoke.txt", time.Now(), reportResults()); err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal([]byte(b.String()), &report); err != nil {
+		t.Fatalf("report is not valid XML: %v\n%s", err, b.String())
+	}
+	if report.Tests != 4 || report.Failed != 1 || report.Errors != 1 || report.Skipped != 1 {
+		t.Errorf("totals = %d tests, %d failures, %d errors, %d skipped; want 4, 1, 1, 1", report.Tests, report.Failed, report.Errors, report.Skipped)
+	}
+	cases := report.Suites[0].Cases
+	if cases[0].Name != "line 1: index: create_hnsw_index:table=docs" || cases[0].Time != "1.500" {
+		t.Errorf("first case = %+v", cases[0])
+	}
+	if cases[1].Failure == nil || cases[1].Failure.Message != "table # docs not found" {
+		t.Errorf("tool error case = %+v, want a failure with the tool's text", cases[1])
+	}
+	if cases[2].Error == nil || cases[3].Skipped == nil {
+		t.Errorf("cases = %+v, want an error then a skip", cases[2:])
+	}
+}
+
+func TestWriteTAPReport(t *testing.T) {
+	var b strings.Builder
+	if err := WriteReport(&b, ReportTAP, "smoke.txt", time.Now(), reportResults()); err != nil {
+		t.Fatal(err)
+	}
+	tap := b.String()
+	for _, want := range []string{
+		"TAP version 13\n1..4\n",
+		"ok 1 - line 1: index: create_hnsw_index:table=docs\n  ---\n  duration_ms: 1500.000\n  ...\n",
+		"not ok 2 - line 2: vector_search:table=docs\n",
+		`  message: "table # docs not found"`,
+		"not ok 3 - line 3: bad command\n",
+		"ok 4 - line 4: index_status # SKIP skipped: dependency \"index\" (line 1) failed\n",
+	} {
+		if !strings.Contains(tap, want) {
+			t.Errorf("report lacks %q:\n%s", want, tap)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package client
+
+import "errors"
+
+// terminalState is a placeholder on platforms without termios support
+type terminalState struct{}
+
+// isTerminal always reports false so the REPL falls back to plain line input
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func (s *terminalState) restore() error {
+	return nil
+}
@@ -0,0 +1,49 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package client
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// terminalState holds the terminal attributes to restore after raw mode
+type terminalState struct {
+	fd      int
+	termios unix.Termios
+}
+
+// isTerminal reports whether fd refers to a terminal
+func isTerminal(fd int) bool {
+	_, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	return err == nil
+}
+
+// makeRaw puts the terminal into raw mode so that single key presses
+// (tab, arrows, ctrl sequences) can be read without waiting for enter.
+// Output post-processing is left enabled so "\n" still renders as "\r\n".
+func makeRaw(fd int) (*terminalState, error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &terminalState{fd: fd, termios: *termios}
+
+	raw := *termios
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &raw); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// restore puts the terminal back into the state captured by makeRaw
+func (s *terminalState) restore() error {
+	return unix.IoctlSetTermios(s.fd, ioctlWriteTermios, &s.termios)
+}
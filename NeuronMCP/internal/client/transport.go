@@ -21,6 +21,10 @@ type ClientTransport struct {
 	stdin   io.WriteCloser
 	stdout  *bufio.Reader
 	stderr  io.ReadCloser
+
+	// onRequest answers requests the server sends while a response is
+	// awaited, such as roots/list
+	onRequest func(method string, params json.RawMessage) (interface{}, *mcp.JSONRPCError)
 }
 
 // NewClientTransport creates a new client transport
@@ -32,6 +36,12 @@ func NewClientTransport(command string, env map[string]string, args []string) (*
 	}, nil
 }
 
+// SetRequestHandler sets how requests from the server are answered.
+// Without one they get a method not found error.
+func (t *ClientTransport) SetRequestHandler(handler func(method string, params json.RawMessage) (interface{}, *mcp.JSONRPCError)) {
+	t.onRequest = handler
+}
+
 // Start starts the MCP server process
 func (t *ClientTransport) Start() error {
 	if t.process != nil {
@@ -161,12 +171,11 @@ func (t *ClientTransport) readResponse(expectedID json.RawMessage) (*mcp.JSONRPC
 		firstLine = strings.TrimRight(firstLine, "\r\n")
 
 		var response mcp.JSONRPCResponse
+		var data []byte
 
 		// Claude Desktop format: JSON directly (starts with '{')
 		if strings.HasPrefix(firstLine, "{") {
-			if err := json.Unmarshal([]byte(firstLine), &response); err != nil {
-				return nil, fmt.Errorf("failed to parse JSON response: %w", err)
-			}
+			data = []byte(firstLine)
 		} else {
 			// Standard MCP format: Content-Length headers
 			// First line is a header, continue reading headers
@@ -208,10 +217,15 @@ func (t *ClientTransport) readResponse(expectedID json.RawMessage) (*mcp.JSONRPC
 				return nil, fmt.Errorf("failed to read response body: %w", err)
 			}
 
-			// Parse JSON response
-			if err := json.Unmarshal(body, &response); err != nil {
-				return nil, fmt.Errorf("failed to parse JSON response: %w", err)
-			}
+			data = body
+		}
+
+		// The server may ask something of the client before it answers
+		if t.answerServerRequest(data) {
+			continue
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON response: %w", err)
 		}
 
 		// Check if this is a notification (no ID) - skip it and continue
@@ -238,3 +252,34 @@ func (t *ClientTransport) readResponse(expectedID json.RawMessage) (*mcp.JSONRPC
 	return nil, fmt.Errorf("failed to find matching response after %d attempts", maxAttempts)
 }
 
+// answerServerRequest replies to data if it is a request from the server,
+// reporting whether it was one
+func (t *ClientTransport) answerServerRequest(data []byte) bool {
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil || req.Method == "" || len(req.ID) == 0 {
+		return false
+	}
+
+	response := mcp.CreateErrorResponse(req.ID, mcp.ErrCodeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method), nil)
+	if t.onRequest != nil {
+		result, rpcErr := t.onRequest(req.Method, req.Params)
+		if rpcErr != nil {
+			response = &mcp.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+		} else {
+			response = mcp.CreateResponse(req.ID, result)
+		}
+	}
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return true
+	}
+	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(responseJSON))
+	if _, err := t.stdin.Write([]byte(header)); err == nil {
+		t.stdin.Write(responseJSON)
+	}
+	return true
+}
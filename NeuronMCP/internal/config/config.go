@@ -1,13 +1,26 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 )
 
+// validationErrors lists the problems found in an invalid configuration
+type validationErrors []string
+
+func (e validationErrors) Error() string {
+	return "invalid configuration: " + strings.Join(e, "; ")
+}
+
 // ConfigManager manages configuration loading and access
 type ConfigManager struct {
-	config *ServerConfig
+	mu         sync.RWMutex
+	config     *ServerConfig
+	configPath string // path passed to Load
+	path       string // config file actually read, if any
 }
 
 // NewConfigManager creates a new config manager
@@ -17,16 +30,43 @@ func NewConfigManager() *ConfigManager {
 
 // Load loads configuration from file and environment
 func (m *ConfigManager) Load(configPath string) (*ServerConfig, error) {
-	if m.config != nil {
-		return m.config, nil
+	m.mu.RLock()
+	loaded := m.config
+	m.mu.RUnlock()
+	if loaded != nil {
+		return loaded, nil
 	}
 
+	config, path, err := m.read(configPath)
+	if err != nil {
+		var invalid validationErrors
+		if !errors.As(err, &invalid) {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Configuration validation errors:\n")
+		for _, msg := range invalid {
+			fmt.Fprintf(os.Stderr, "  - %s\n", msg)
+		}
+		return nil, fmt.Errorf("invalid configuration")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+	m.configPath = configPath
+	m.path = path
+	return m.config, nil
+}
+
+// read loads configuration from file and environment and validates it. path
+// is the config file read, if any.
+func (m *ConfigManager) read(configPath string) (config *ServerConfig, path string, err error) {
 	loader := NewConfigLoader()
 
 	// Load from file or use defaults
-	fileConfig, err := loader.LoadFromFile(configPath)
+	fileConfig, path, err := loader.LoadFromFileWithPath(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config file: %w", err)
+		return nil, "", fmt.Errorf("failed to load config file: %w", err)
 	}
 
 	var baseConfig *ServerConfig
@@ -37,32 +77,57 @@ func (m *ConfigManager) Load(configPath string) (*ServerConfig, error) {
 	}
 
 	// Merge with environment variables
-	m.config = loader.MergeWithEnv(baseConfig)
+	config = loader.MergeWithEnv(baseConfig)
 
 	// Validate configuration
 	validator := NewConfigValidator()
-	valid, errors := validator.Validate(m.config)
-	if !valid {
-		fmt.Fprintf(os.Stderr, "Configuration validation errors:\n")
-		for _, err := range errors {
-			fmt.Fprintf(os.Stderr, "  - %s\n", err)
-		}
-		return nil, fmt.Errorf("invalid configuration")
+	if valid, problems := validator.Validate(config); !valid {
+		return nil, path, validationErrors(problems)
 	}
+	return config, path, nil
+}
 
-	return m.config, nil
+// LoadFresh re-reads the configuration from the same sources as Load
+// without replacing the current configuration
+func (m *ConfigManager) LoadFresh() (*ServerConfig, error) {
+	m.mu.RLock()
+	configPath := m.configPath
+	m.mu.RUnlock()
+
+	config, _, err := m.read(configPath)
+	return config, err
+}
+
+// Replace makes config the current configuration
+func (m *ConfigManager) Replace(config *ServerConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+}
+
+// Path returns the config file that was loaded, or "" when the defaults
+// are in use
+func (m *ConfigManager) Path() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.path
 }
 
 // GetConfig returns the current configuration
 func (m *ConfigManager) GetConfig() *ServerConfig {
-	if m.config == nil {
+	m.mu.RLock()
+	config := m.config
+	m.mu.RUnlock()
+	if config == nil {
 		// Load with default path
-		if _, err := m.Load(""); err != nil {
+		loaded, err := m.Load("")
+		if err != nil {
 			// Return defaults if loading fails
 			return GetDefaultConfig()
 		}
+		return loaded
 	}
-	return m.config
+	return config
 }
 
 // GetDatabaseConfig returns database configuration
@@ -70,6 +135,12 @@ func (m *ConfigManager) GetDatabaseConfig() *DatabaseConfig {
 	return &m.GetConfig().Database
 }
 
+// GetDatabaseTargets returns the named database targets other than the
+// default database
+func (m *ConfigManager) GetDatabaseTargets() map[string]DatabaseConfig {
+	return m.GetConfig().Databases
+}
+
 // GetServerSettings returns server settings
 func (m *ConfigManager) GetServerSettings() *ServerSettings {
 	return &m.GetConfig().Server
@@ -89,4 +160,3 @@ func (m *ConfigManager) GetFeaturesConfig() *FeaturesConfig {
 func (m *ConfigManager) GetPlugins() []PluginConfig {
 	return m.GetConfig().Plugins
 }
-
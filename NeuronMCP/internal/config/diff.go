@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// ChangedFields returns the settings that differ between two configurations
+// as sorted JSON paths such as "logging.level" or "database.pool.max".
+// Arrays are compared as a whole.
+func ChangedFields(old, new *ServerConfig) []string {
+	before, after := flattenConfig(old), flattenConfig(new)
+	var changed []string
+	for field, value := range after {
+		if prev, ok := before[field]; !ok || !reflect.DeepEqual(prev, value) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range before {
+		if _, ok := after[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// flattenConfig maps each leaf setting of config to its JSON value
+func flattenConfig(config *ServerConfig) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if config == nil {
+		return fields
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fields
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return fields
+	}
+	flattenInto(fields, "", tree)
+	return fields
+}
+
+func flattenInto(fields map[string]interface{}, prefix string, node map[string]interface{}) {
+	for key, value := range node {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if child, ok := value.(map[string]interface{}); ok {
+			flattenInto(fields, path, child)
+			continue
+		}
+		fields[path] = value
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedFields(t *testing.T) {
+	old := GetDefaultConfig()
+	if got := ChangedFields(old, GetDefaultConfig()); len(got) != 0 {
+		t.Fatalf("ChangedFields() of equal configs = %v, want none", got)
+	}
+
+	next := GetDefaultConfig()
+	next.Logging.Level = "debug"
+	max := 20
+	next.Database.Pool.Max = &max
+	policyFile := "/etc/neurondb/policy.json"
+	next.Server.PolicyFile = &policyFile
+	next.Features.ML.Algorithms = []string{"knn"}
+
+	want := []string{"database.pool.max", "features.ml.algorithms", "logging.level", "server.policyFile"}
+	if got := ChangedFields(old, next); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFields() = %v, want %v", got, want)
+	}
+	// policyFile is unset in old, so reversing the diff also covers a
+	// removed setting
+	if got := ChangedFields(next, old); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFields() reversed = %v, want %v", got, want)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // ConfigLoader handles loading configuration from multiple sources
@@ -113,6 +114,13 @@ func GetDefaultConfig() *ServerConfig {
 
 // LoadFromFile loads configuration from a JSON file
 func (l *ConfigLoader) LoadFromFile(configPath string) (*ServerConfig, error) {
+	config, _, err := l.LoadFromFileWithPath(configPath)
+	return config, err
+}
+
+// LoadFromFileWithPath loads configuration like LoadFromFile and also returns
+// the path of the file read, or "" when no config file was found
+func (l *ConfigLoader) LoadFromFileWithPath(configPath string) (*ServerConfig, string, error) {
 	possiblePaths := []string{}
 
 	if configPath != "" {
@@ -139,13 +147,13 @@ func (l *ConfigLoader) LoadFromFile(configPath string) (*ServerConfig, error) {
 		if data, err := os.ReadFile(path); err == nil {
 			var config ServerConfig
 			if err := json.Unmarshal(data, &config); err != nil {
-				return nil, fmt.Errorf("failed to parse config from %s: %w", path, err)
+				return nil, "", fmt.Errorf("failed to parse config from %s: %w", path, err)
 			}
-			return &config, nil
+			return &config, path, nil
 		}
 	}
 
-	return nil, nil // No config file found
+	return nil, "", nil // No config file found
 }
 
 // MergeWithEnv merges configuration with environment variables
@@ -174,6 +182,70 @@ func (l *ConfigLoader) MergeWithEnv(config *ServerConfig) *ServerConfig {
 		merged.Database.Password = &pass
 	}
 
+	// Server settings from env
+	if policyFile := os.Getenv("NEURONDB_MCP_POLICY_FILE"); policyFile != "" {
+		merged.Server.PolicyFile = &policyFile
+	}
+	if sizeStr := os.Getenv("NEURONDB_MCP_MAX_RESULT_SIZE"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil {
+			merged.Server.MaxResultSize = &size
+		}
+	}
+	if nStr := os.Getenv("NEURONDB_MCP_MAX_CONCURRENT_REQUESTS"); nStr != "" {
+		if n, err := strconv.Atoi(nStr); err == nil {
+			merged.Server.MaxConcurrentRequests = &n
+		}
+	}
+	if resultDir := os.Getenv("NEURONDB_MCP_RESULT_DIR"); resultDir != "" {
+		merged.Server.ResultDir = &resultDir
+	}
+	if exportDir := os.Getenv("NEURONDB_MCP_EXPORT_DIR"); exportDir != "" {
+		merged.Server.ExportDir = &exportDir
+	}
+	if dirs := os.Getenv("NEURONDB_MCP_LOCAL_FILE_DIRS"); dirs != "" {
+		merged.Server.LocalFileDirs = nil
+		for _, dir := range strings.Split(dirs, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				merged.Server.LocalFileDirs = append(merged.Server.LocalFileDirs, dir)
+			}
+		}
+	}
+	if role := os.Getenv("NEURONDB_MCP_READONLY_ROLE"); role != "" {
+		merged.Server.ReadOnlyRole = &role
+	}
+	if usageFile := os.Getenv("NEURONDB_MCP_USAGE_FILE"); usageFile != "" {
+		merged.Server.UsageFile = &usageFile
+	}
+	if timezone := os.Getenv("NEURONDB_MCP_RESULT_TIMEZONE"); timezone != "" {
+		results := merged.Server.GetResultSettings()
+		results.Timezone = &timezone
+		merged.Server.Results = &results
+	}
+	if timestamps := os.Getenv("NEURONDB_MCP_RESULT_TIMESTAMPS"); timestamps != "" {
+		results := merged.Server.GetResultSettings()
+		results.Timestamps = &timestamps
+		merged.Server.Results = &results
+	}
+	if precisionStr := os.Getenv("NEURONDB_MCP_RESULT_NUMERIC_PRECISION"); precisionStr != "" {
+		if precision, err := strconv.Atoi(precisionStr); err == nil {
+			results := merged.Server.GetResultSettings()
+			results.NumericPrecision = &precision
+			merged.Server.Results = &results
+		}
+	}
+	if watch := os.Getenv("NEURONDB_MCP_WATCH_CONFIG"); watch != "" {
+		watchConfig := watch == "true"
+		merged.Server.WatchConfig = &watchConfig
+	}
+	if channels := os.Getenv("NEURONDB_MCP_LISTEN_CHANNELS"); channels != "" {
+		merged.Server.ListenChannels = nil
+		for _, channel := range strings.Split(channels, ",") {
+			if channel = strings.TrimSpace(channel); channel != "" {
+				merged.Server.ListenChannels = append(merged.Server.ListenChannels, channel)
+			}
+		}
+	}
+
 	// Logging config from env
 	if level := os.Getenv("NEURONDB_LOG_LEVEL"); level != "" {
 		merged.Logging.Level = level
@@ -186,6 +258,14 @@ func (l *ConfigLoader) MergeWithEnv(config *ServerConfig) *ServerConfig {
 	}
 
 	// Feature flags from env
+	if warmup := os.Getenv("NEURONDB_MCP_WARMUP_MODELS"); warmup != "" {
+		models := ModelsFeatureConfig{}
+		if merged.Features.Models != nil {
+			models = *merged.Features.Models
+		}
+		models.WarmupOnStart = warmup == "true"
+		merged.Features.Models = &models
+	}
 	if gpu := os.Getenv("NEURONDB_ENABLE_GPU"); gpu != "" {
 		gpuEnabled := gpu == "true"
 		if merged.Features.ML != nil {
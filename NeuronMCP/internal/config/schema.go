@@ -1,10 +1,17 @@
 package config
 
-import "time"
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
 
 // ServerConfig is the root configuration structure
 type ServerConfig struct {
 	Database DatabaseConfig `json:"database"`
+	// Databases are further named targets tools can be routed to with their
+	// database argument. The database above is the target "default".
+	Databases map[string]DatabaseConfig `json:"databases,omitempty"`
 	Server   ServerSettings `json:"server"`
 	Logging  LoggingConfig  `json:"logging"`
 	Features FeaturesConfig `json:"features"`
@@ -12,6 +19,9 @@ type ServerConfig struct {
 	Middleware []MiddlewareConfig `json:"middleware,omitempty"`
 }
 
+// DefaultDatabaseTarget names the database setting among database targets
+const DefaultDatabaseTarget = "default"
+
 // DatabaseConfig holds database connection configuration
 type DatabaseConfig struct {
 	ConnectionString *string   `json:"connectionString,omitempty"`
@@ -48,6 +58,29 @@ type ServerSettings struct {
 	MaxRequestSize  *int    `json:"maxRequestSize,omitempty"`
 	EnableMetrics   *bool   `json:"enableMetrics,omitempty"`
 	EnableHealthCheck *bool `json:"enableHealthCheck,omitempty"`
+	PolicyFile      *string `json:"policyFile,omitempty"`
+	MaxResultSize   *int    `json:"maxResultSize,omitempty"`
+	ResultDir       *string `json:"resultDir,omitempty"`
+	ListenChannels  []string `json:"listenChannels,omitempty"`
+	WatchConfig     *bool    `json:"watchConfig,omitempty"`
+	ExportDir       *string  `json:"exportDir,omitempty"`
+	ReadOnlyRole    *string  `json:"readOnlyRole,omitempty"`
+	// LocalFileDirs are the directories tools may read local files from for
+	// clients that share no roots
+	LocalFileDirs   []string `json:"localFileDirs,omitempty"`
+	UsageFile       *string  `json:"usageFile,omitempty"`
+	MaxConcurrentRequests *int `json:"maxConcurrentRequests,omitempty"`
+	Results         *ResultSettings `json:"results,omitempty"`
+}
+
+// ResultSettings post-process the values of query results for every call;
+// the result_options argument of a call overrides them. Timezone converts
+// timestamptz values to an IANA zone, Timestamps is "raw" or "iso8601", and
+// NumericPrecision rounds floats and numerics to that many decimal places.
+type ResultSettings struct {
+	Timezone         *string `json:"timezone,omitempty"`
+	Timestamps       *string `json:"timestamps,omitempty"`
+	NumericPrecision *int    `json:"numericPrecision,omitempty"`
 }
 
 // LoggingConfig holds logging configuration
@@ -74,6 +107,8 @@ type FeaturesConfig struct {
 	Hybrid        *HybridFeatureConfig        `json:"hybrid,omitempty"`
 	Workers       *WorkersFeatureConfig       `json:"workers,omitempty"`
 	Indexing      *IndexingFeatureConfig      `json:"indexing,omitempty"`
+	Models        *ModelsFeatureConfig        `json:"models,omitempty"`
+	Federation    *FederationFeatureConfig    `json:"federation,omitempty"`
 }
 
 // VectorFeatureConfig holds vector feature settings
@@ -155,6 +190,35 @@ type IndexingFeatureConfig struct {
 	DefaultHNSWEFConstruction *int `json:"defaultHNSWEFConstruction,omitempty"`
 }
 
+// ModelsFeatureConfig lists the models warmup_models and model_health call
+// when a call names none
+type ModelsFeatureConfig struct {
+	Embedding  []string `json:"embedding,omitempty"`
+	Generation []string `json:"generation,omitempty"`
+	// WarmupOnStart warms up the listed models in the background when the
+	// server starts, so the first real calls skip the models' cold start
+	WarmupOnStart bool `json:"warmupOnStart,omitempty"`
+}
+
+// FederationFeatureConfig lists the remote NeuronDB servers
+// federated_vector_search fans out to
+type FederationFeatureConfig struct {
+	Remotes []FederationRemoteConfig `json:"remotes,omitempty"`
+	// TimeoutMillis bounds the search of each server; 10000 when unset
+	TimeoutMillis *int `json:"timeoutMillis,omitempty"`
+}
+
+// FederationRemoteConfig is a remote NeuronDB reached through dblink
+type FederationRemoteConfig struct {
+	// Name labels the remote's results
+	Name string `json:"name"`
+	// Server is a foreign server of the connected database, such as one
+	// created for postgres_fdw, with a user mapping for the connecting user
+	Server string `json:"server"`
+	// Table replaces the table a call names on this remote
+	Table *string `json:"table,omitempty"`
+}
+
 // PluginConfig holds plugin configuration
 type PluginConfig struct {
 	Name     string                 `json:"name"`
@@ -243,6 +307,87 @@ func (s *ServerSettings) GetVersion() string {
 	return "1.0.0"
 }
 
+// GetPolicyFile returns the tool authorization policy file, or "" when no
+// policy is configured
+func (s *ServerSettings) GetPolicyFile() string {
+	if s.PolicyFile != nil {
+		return *s.PolicyFile
+	}
+	return ""
+}
+
+// GetMaxResultSize returns the largest tool result, in bytes, returned inline.
+// Larger results are spilled to the result store; 0 disables the limit.
+func (s *ServerSettings) GetMaxResultSize() int {
+	if s.MaxResultSize != nil {
+		return *s.MaxResultSize
+	}
+	return 1024 * 1024
+}
+
+// GetResultDir returns the directory holding spilled tool results
+func (s *ServerSettings) GetResultDir() string {
+	if s.ResultDir != nil {
+		return *s.ResultDir
+	}
+	return filepath.Join(os.TempDir(), "neurondb-mcp-results")
+}
+
+// GetListenChannels returns the PostgreSQL notification channels subscribed
+// at startup
+func (s *ServerSettings) GetListenChannels() []string {
+	return s.ListenChannels
+}
+
+// GetMaxConcurrentRequests returns how many MCP requests are handled at once
+func (s *ServerSettings) GetMaxConcurrentRequests() int {
+	if s.MaxConcurrentRequests != nil {
+		return *s.MaxConcurrentRequests
+	}
+	return 8
+}
+
+// GetWatchConfig reports whether the config file is polled for changes and
+// reloaded, in addition to reloading on SIGHUP
+func (s *ServerSettings) GetWatchConfig() bool {
+	return s.WatchConfig != nil && *s.WatchConfig
+}
+
+// GetExportDir returns the directory export tools may write files to, or ""
+// when writing files on the server is disabled
+func (s *ServerSettings) GetExportDir() string {
+	if s.ExportDir != nil {
+		return *s.ExportDir
+	}
+	return ""
+}
+
+// GetReadOnlyRole returns the role queries written by callers run as, or ""
+// to run them as the connecting user
+func (s *ServerSettings) GetReadOnlyRole() string {
+	if s.ReadOnlyRole != nil {
+		return *s.ReadOnlyRole
+	}
+	return ""
+}
+
+// GetUsageFile returns the file tool usage statistics are kept in across
+// restarts, or "" when they are kept in memory only
+func (s *ServerSettings) GetUsageFile() string {
+	if s.UsageFile != nil {
+		return *s.UsageFile
+	}
+	return ""
+}
+
+// GetResultSettings returns the result post-processing settings
+func (s *ServerSettings) GetResultSettings() ResultSettings {
+	if s.Results != nil {
+		return *s.Results
+	}
+	return ResultSettings{}
+}
+
 func (s *ServerSettings) GetTimeout() time.Duration {
 	if s.Timeout != nil {
 		return time.Duration(*s.Timeout) * time.Millisecond
@@ -1,6 +1,15 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// databaseTargetName matches the names allowed for database targets
+var databaseTargetName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
 
 // ConfigValidator validates configuration
 type ConfigValidator struct{}
@@ -16,6 +25,7 @@ func (v *ConfigValidator) Validate(config *ServerConfig) (bool, []string) {
 
 	// Validate database config
 	errors = append(errors, v.validateDatabase(&config.Database)...)
+	errors = append(errors, v.validateDatabaseTargets(config.Databases)...)
 
 	// Validate server settings
 	errors = append(errors, v.validateServer(&config.Server)...)
@@ -61,6 +71,32 @@ func (v *ConfigValidator) validateDatabase(config *DatabaseConfig) []string {
 	return errors
 }
 
+func (v *ConfigValidator) validateDatabaseTargets(targets map[string]DatabaseConfig) []string {
+	var errors []string
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == DefaultDatabaseTarget {
+			errors = append(errors, fmt.Sprintf("Database target name '%s' is reserved for the database setting", name))
+			continue
+		}
+		if !databaseTargetName.MatchString(name) {
+			errors = append(errors, fmt.Sprintf("Database target name '%s' must be 1-63 letters, digits, '_' or '-'", name))
+			continue
+		}
+		target := targets[name]
+		for _, problem := range v.validateDatabase(&target) {
+			errors = append(errors, fmt.Sprintf("databases.%s: %s", name, problem))
+		}
+	}
+
+	return errors
+}
+
 func (v *ConfigValidator) validateServer(config *ServerSettings) []string {
 	var errors []string
 
@@ -72,6 +108,34 @@ func (v *ConfigValidator) validateServer(config *ServerSettings) []string {
 		errors = append(errors, "Server maxRequestSize must be >= 0")
 	}
 
+	if config.MaxResultSize != nil && *config.MaxResultSize < 0 {
+		errors = append(errors, "Server maxResultSize must be >= 0")
+	}
+
+	for i, dir := range config.LocalFileDirs {
+		if !filepath.IsAbs(dir) {
+			errors = append(errors, fmt.Sprintf("Server localFileDirs[%d] must be an absolute path, got '%s'", i, dir))
+		}
+	}
+
+	if config.MaxConcurrentRequests != nil && *config.MaxConcurrentRequests < 1 {
+		errors = append(errors, "Server maxConcurrentRequests must be >= 1")
+	}
+
+	if results := config.Results; results != nil {
+		if results.Timezone != nil {
+			if _, err := time.LoadLocation(*results.Timezone); err != nil {
+				errors = append(errors, fmt.Sprintf("Server results.timezone '%s' is not an IANA time zone", *results.Timezone))
+			}
+		}
+		if results.Timestamps != nil && *results.Timestamps != "raw" && *results.Timestamps != "iso8601" {
+			errors = append(errors, "Server results.timestamps must be raw or iso8601")
+		}
+		if results.NumericPrecision != nil && (*results.NumericPrecision < 0 || *results.NumericPrecision > 20) {
+			errors = append(errors, "Server results.numericPrecision must be between 0 and 20")
+		}
+	}
+
 	return errors
 }
 
@@ -133,6 +197,60 @@ func (v *ConfigValidator) validateFeatures(config *FeaturesConfig) []string {
 		}
 	}
 
+	if config.Models != nil {
+		errors = append(errors, v.validateModels(config.Models)...)
+	}
+	if config.Federation != nil {
+		errors = append(errors, v.validateFederation(config.Federation)...)
+	}
+
+	return errors
+}
+
+func (v *ConfigValidator) validateModels(config *ModelsFeatureConfig) []string {
+	var errors []string
+	for _, list := range []struct {
+		field string
+		names []string
+	}{
+		{"embedding", config.Embedding},
+		{"generation", config.Generation},
+	} {
+		for i, name := range list.names {
+			if name == "" {
+				errors = append(errors, fmt.Sprintf("features.models.%s[%d] must not be empty", list.field, i))
+			}
+		}
+	}
+	if config.WarmupOnStart && len(config.Embedding) == 0 && len(config.Generation) == 0 {
+		errors = append(errors, "features.models.warmupOnStart needs at least one embedding or generation model")
+	}
+	return errors
+}
+
+func (v *ConfigValidator) validateFederation(config *FederationFeatureConfig) []string {
+	var errors []string
+	names := make(map[string]bool, len(config.Remotes))
+	for i, remote := range config.Remotes {
+		switch {
+		case remote.Name == "":
+			errors = append(errors, fmt.Sprintf("features.federation.remotes[%d].name must not be empty", i))
+		case remote.Name == "local":
+			errors = append(errors, fmt.Sprintf("features.federation.remotes[%d].name 'local' is reserved for the connected database", i))
+		case names[remote.Name]:
+			errors = append(errors, fmt.Sprintf("features.federation.remotes[%d].name '%s' is already used", i, remote.Name))
+		}
+		names[remote.Name] = true
+		if remote.Server == "" {
+			errors = append(errors, fmt.Sprintf("features.federation.remotes[%d].server must not be empty", i))
+		}
+		if remote.Table != nil && *remote.Table == "" {
+			errors = append(errors, fmt.Sprintf("features.federation.remotes[%d].table must not be empty when set", i))
+		}
+	}
+	if config.TimeoutMillis != nil && *config.TimeoutMillis <= 0 {
+		errors = append(errors, "features.federation.timeoutMillis must be positive")
+	}
 	return errors
 }
 
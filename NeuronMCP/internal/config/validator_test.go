@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateDatabaseTargets(t *testing.T) {
+	host := "replica.internal"
+	port := 70000
+	v := NewConfigValidator()
+
+	if errs := v.validateDatabaseTargets(map[string]DatabaseConfig{"tenant-a": {Host: &host}}); len(errs) != 0 {
+		t.Errorf("valid target rejected: %v", errs)
+	}
+
+	errs := v.validateDatabaseTargets(map[string]DatabaseConfig{
+		"default":  {Host: &host},
+		"bad name": {Host: &host},
+		"staging":  {Host: &host, Port: &port},
+		"empty":    {},
+	})
+	if len(errs) != 4 {
+		t.Fatalf("got %d errors, want 4: %v", len(errs), errs)
+	}
+	if !strings.HasPrefix(errs[2], "databases.empty: ") || !strings.HasPrefix(errs[3], "databases.staging: ") {
+		t.Errorf("target errors not prefixed: %v", errs)
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	v := NewConfigValidator()
+
+	if errs := v.validateModels(&ModelsFeatureConfig{Embedding: []string{"default"}, WarmupOnStart: true}); len(errs) != 0 {
+		t.Errorf("valid models rejected: %v", errs)
+	}
+	if errs := v.validateModels(&ModelsFeatureConfig{WarmupOnStart: true}); len(errs) != 1 {
+		t.Errorf("warm-up without models: got %v, want 1 error", errs)
+	}
+	if errs := v.validateModels(&ModelsFeatureConfig{Generation: []string{"gpt-4o-mini", ""}}); len(errs) != 1 || !strings.Contains(errs[0], "generation[1]") {
+		t.Errorf("empty model name: got %v", errs)
+	}
+}
+
+func TestValidateFederation(t *testing.T) {
+	v := NewConfigValidator()
+
+	table := "docs"
+	valid := &FederationFeatureConfig{Remotes: []FederationRemoteConfig{
+		{Name: "eu", Server: "neurondb_eu"},
+		{Name: "us", Server: "neurondb_us", Table: &table},
+	}}
+	if errs := v.validateFederation(valid); len(errs) != 0 {
+		t.Errorf("valid federation rejected: %v", errs)
+	}
+
+	empty, timeout := "", 0
+	errs := v.validateFederation(&FederationFeatureConfig{
+		Remotes: []FederationRemoteConfig{
+			{Name: "local", Server: "neurondb_local"},
+			{Name: "eu", Server: ""},
+			{Name: "eu", Server: "neurondb_eu", Table: &empty},
+		},
+		TimeoutMillis: &timeout,
+	})
+	if len(errs) != 5 {
+		t.Fatalf("got %d errors, want 5: %v", len(errs), errs)
+	}
+	for i, want := range []string{"remotes[0].name", "remotes[1].server", "remotes[2].name", "remotes[2].table", "timeoutMillis"} {
+		if !strings.Contains(errs[i], want) {
+			t.Errorf("error %d = %q, want it to mention %s", i, errs[i], want)
+		}
+	}
+}
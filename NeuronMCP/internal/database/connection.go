@@ -19,11 +19,31 @@ type Database struct {
 	port     int
 	database string
 	user     string
+	breaker  *CircuitBreaker
+	settings *SessionSettings
+	// statements caches the prepared statements of QueryPrepared
+	statements *StatementCache
 }
 
 // NewDatabase creates a new database instance
 func NewDatabase() *Database {
-	return &Database{}
+	return &Database{
+		breaker:    NewCircuitBreaker(DefaultCircuitThreshold, DefaultCircuitCooldown),
+		settings:   NewSessionSettings(),
+		statements: NewStatementCache(),
+	}
+}
+
+// Breaker returns the circuit breaker guarding tool queries, or nil if the
+// database was not created with NewDatabase
+func (d *Database) Breaker() *CircuitBreaker {
+	return d.breaker
+}
+
+// Settings returns the run-time parameters set for the MCP session, or nil
+// if the database was not created with NewDatabase
+func (d *Database) Settings() *SessionSettings {
+	return d.settings
 }
 
 // Connect connects to the database using the provided configuration
@@ -104,6 +124,26 @@ func (d *Database) ConnectWithRetry(cfg *config.DatabaseConfig, maxRetries int,
 		return nil
 	}
 
+	// Bring each connection up to the session's settings as it is acquired
+	if settings := d.settings; settings != nil {
+		poolConfig.PrepareConn = func(ctx context.Context, conn *pgx.Conn) (bool, error) {
+			if err := settings.prepare(ctx, conn); err != nil {
+				// The connection's settings are unknown now
+				return false, err
+			}
+			return true, nil
+		}
+	}
+	// Forget what was recorded about each connection when it closes
+	poolConfig.BeforeClose = func(conn *pgx.Conn) {
+		if d.settings != nil {
+			d.settings.forget(conn)
+		}
+		if d.statements != nil {
+			d.statements.forget(conn)
+		}
+	}
+
 	// Apply pool settings
 	if cfg.Pool != nil {
 		poolConfig.MinConns = int32(cfg.Pool.GetMin())
@@ -223,6 +263,19 @@ func (d *Database) Begin(ctx context.Context) (pgx.Tx, error) {
 	return tx, nil
 }
 
+// Acquire takes a dedicated connection from the pool. The caller must
+// Release it.
+func (d *Database) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if d.pool == nil {
+		return nil, fmt.Errorf("database connection not established: database '%s' on host '%s:%d' as user '%s' (connection pool is nil, ensure Connect() was called successfully)", d.database, d.host, d.port, d.user)
+	}
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection on database '%s' on host '%s:%d' as user '%s': %w", d.database, d.host, d.port, d.user, err)
+	}
+	return conn, nil
+}
+
 // Close closes the connection pool
 func (d *Database) Close() {
 	if d.pool != nil {
@@ -253,6 +306,7 @@ func (d *Database) GetPoolStats() *PoolStats {
 		AcquiredConns:  stats.AcquiredConns(),
 		IdleConns:      stats.IdleConns(),
 		ConstructingConns: stats.ConstructingConns(),
+		MaxConns:       stats.MaxConns(),
 	}
 }
 
@@ -262,6 +316,7 @@ type PoolStats struct {
 	AcquiredConns   int32
 	IdleConns       int32
 	ConstructingConns int32
+	MaxConns        int32
 }
 
 // EscapeIdentifier escapes a SQL identifier
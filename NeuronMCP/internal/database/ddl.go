@@ -0,0 +1,172 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ddlStarts are the keywords a schema change may start with
+var ddlStarts = map[string]bool{
+	"create":   true,
+	"alter":    true,
+	"drop":     true,
+	"comment":  true,
+	"grant":    true,
+	"revoke":   true,
+	"truncate": true,
+}
+
+// ddlModifiers are the words between CREATE and the type of object created
+var ddlModifiers = map[string]bool{
+	"or":         true,
+	"replace":    true,
+	"unique":     true,
+	"temp":       true,
+	"temporary":  true,
+	"unlogged":   true,
+	"global":     true,
+	"local":      true,
+	"recursive":  true,
+	"constraint": true,
+	"trusted":    true,
+	"procedural": true,
+}
+
+// ddlTwoWordObjects are object types named by two words, such as
+// MATERIALIZED VIEW
+var ddlTwoWordObjects = map[string]bool{
+	"materialized": true,
+	"foreign":      true,
+	"event":        true,
+	"access":       true,
+	"text":         true,
+	"default":      true,
+}
+
+// nonTransactionalObjects cannot be created, altered or dropped inside a
+// transaction block
+var nonTransactionalObjects = map[string]bool{
+	"database":   true,
+	"tablespace": true,
+	"system":     true,
+}
+
+// ddlKeptDrops are what ALTER ... DROP removes without losing data: a NOT
+// NULL constraint, a default, an identity or a generation expression
+var ddlKeptDrops = map[string]bool{
+	"not":        true,
+	"default":    true,
+	"identity":   true,
+	"expression": true,
+}
+
+// ddlDroppedParts are the parts of an object ALTER ... DROP names
+var ddlDroppedParts = map[string]bool{
+	"column":     true,
+	"constraint": true,
+	"attribute":  true,
+}
+
+// DDLStatement is a schema change checked by CheckDDLStatement
+type DDLStatement struct {
+	// Command is the statement and the type of object it changes, such as
+	// "CREATE TABLE" or "ALTER INDEX"; GRANT, REVOKE, COMMENT and TRUNCATE
+	// are named alone
+	Command string
+	// Destructive is set when the statement drops objects, deletes rows or
+	// changes the type of a column; Reason says which
+	Destructive bool
+	Reason      string
+}
+
+// CheckDDLStatement parses statement into tokens and returns what it
+// changes, or an error unless it is a single CREATE, ALTER, DROP, COMMENT,
+// GRANT, REVOKE or TRUNCATE statement that can run in a transaction. Like
+// CheckReadOnlyQuery it understands comments, string constants and quoted
+// identifiers, so a keyword inside them does not count.
+func CheckDDLStatement(statement string) (*DDLStatement, error) {
+	tokens, err := tokenizeSQL(statement)
+	if err != nil {
+		return nil, err
+	}
+	for len(tokens) > 0 && isPunct(tokens[len(tokens)-1], ";") {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("statement is empty")
+	}
+	for _, tok := range tokens {
+		if isPunct(tok, ";") {
+			return nil, fmt.Errorf("statement must be a single statement")
+		}
+	}
+	first := tokens[0]
+	if first.kind != sqlWord || !ddlStarts[first.value] {
+		return nil, fmt.Errorf("statement must be a CREATE, ALTER, DROP, COMMENT, GRANT, REVOKE or TRUNCATE statement")
+	}
+
+	ddl := &DDLStatement{Command: strings.ToUpper(first.value)}
+	object := ""
+	if first.value == "create" || first.value == "alter" || first.value == "drop" {
+		i := 1
+		for i < len(tokens) && tokens[i].kind == sqlWord && ddlModifiers[tokens[i].value] {
+			i++
+		}
+		if i < len(tokens) && tokens[i].kind == sqlWord {
+			object = tokens[i].value
+			if ddlTwoWordObjects[object] && i+1 < len(tokens) && tokens[i+1].kind == sqlWord {
+				object += " " + tokens[i+1].value
+			}
+			ddl.Command += " " + strings.ToUpper(object)
+		}
+		if nonTransactionalObjects[object] {
+			return nil, fmt.Errorf("%s cannot run inside a transaction", ddl.Command)
+		}
+	}
+
+	for _, tok := range tokens {
+		if tok.kind == sqlWord && tok.value == "concurrently" {
+			return nil, fmt.Errorf("%s CONCURRENTLY cannot run inside a transaction", ddl.Command)
+		}
+	}
+
+	switch first.value {
+	case "drop":
+		ddl.Destructive, ddl.Reason = true, fmt.Sprintf("drops the %s", object)
+	case "truncate":
+		ddl.Destructive, ddl.Reason = true, "deletes every row"
+	case "alter":
+		ddl.Destructive, ddl.Reason = alterIsDestructive(tokens)
+	}
+	return ddl, nil
+}
+
+// alterIsDestructive reports whether an ALTER statement drops a column, a
+// constraint or another part of the object, or changes the type of a
+// column, which rewrites it and may fail or lose precision
+func alterIsDestructive(tokens []sqlToken) (bool, string) {
+	for i, tok := range tokens {
+		if tok.kind != sqlWord {
+			continue
+		}
+		switch tok.value {
+		case "drop":
+			if i+1 >= len(tokens) || tokens[i+1].kind != sqlWord || !ddlKeptDrops[tokens[i+1].value] {
+				// COLUMN is optional: ALTER TABLE t DROP c
+				what := "column"
+				if i+1 < len(tokens) && tokens[i+1].kind == sqlWord && ddlDroppedParts[tokens[i+1].value] {
+					what = tokens[i+1].value
+				}
+				return true, "drops the " + what
+			}
+		case "type":
+			// ALTER [COLUMN] name [SET DATA] TYPE
+			if i > 0 && tokens[i-1].kind == sqlWord && tokens[i-1].value == "data" ||
+				i > 1 && tokens[i-2].kind == sqlWord && tokens[i-2].value == "alter" ||
+				i > 2 && tokens[i-2].kind == sqlWord && tokens[i-2].value == "column" && tokens[i-3].kind == sqlWord && tokens[i-3].value == "alter" {
+				return true, "changes the type of a column"
+			}
+		}
+	}
+	return false, ""
+}
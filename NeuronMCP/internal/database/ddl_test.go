@@ -0,0 +1,63 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckDDLStatement(t *testing.T) {
+	for _, c := range []struct {
+		statement   string
+		command     string
+		destructive string
+	}{
+		{"CREATE TABLE docs (id bigint PRIMARY KEY, body text)", "CREATE TABLE", ""},
+		{"create table if not exists docs (id int);", "CREATE TABLE", ""},
+		{"CREATE UNIQUE INDEX docs_body ON docs (body)", "CREATE INDEX", ""},
+		{"CREATE OR REPLACE VIEW recent AS SELECT * FROM docs", "CREATE VIEW", ""},
+		{"CREATE MATERIALIZED VIEW counts AS SELECT count(*) FROM docs", "CREATE MATERIALIZED VIEW", ""},
+		{"ALTER TABLE docs ADD COLUMN type text", "ALTER TABLE", ""},
+		{"ALTER TABLE docs ALTER COLUMN body DROP NOT NULL", "ALTER TABLE", ""},
+		{"ALTER TABLE docs ALTER body DROP DEFAULT", "ALTER TABLE", ""},
+		{"ALTER TABLE docs RENAME COLUMN kind TO type", "ALTER TABLE", ""},
+		{"COMMENT ON TABLE docs IS 'drop table docs'", "COMMENT", ""},
+		{"GRANT SELECT ON docs TO reader", "GRANT", ""},
+		// Keywords inside strings, comments and quoted identifiers do not count
+		{"CREATE TABLE \"drop\" (x int) -- drop table docs", "CREATE TABLE", ""},
+		{"DROP TABLE IF EXISTS docs", "DROP TABLE", "drops the table"},
+		{"DROP INDEX docs_body", "DROP INDEX", "drops the index"},
+		{"TRUNCATE docs", "TRUNCATE", "deletes every row"},
+		{"ALTER TABLE docs DROP COLUMN body", "ALTER TABLE", "drops the column"},
+		{"ALTER TABLE docs DROP body", "ALTER TABLE", "drops the column"},
+		{"ALTER TABLE docs DROP CONSTRAINT docs_pkey", "ALTER TABLE", "drops the constraint"},
+		{"ALTER TABLE docs ALTER COLUMN id TYPE bigint", "ALTER TABLE", "changes the type of a column"},
+		{"ALTER TABLE docs ALTER id SET DATA TYPE bigint", "ALTER TABLE", "changes the type of a column"},
+	} {
+		ddl, err := CheckDDLStatement(c.statement)
+		if err != nil {
+			t.Errorf("CheckDDLStatement(%q) = %v", c.statement, err)
+			continue
+		}
+		if ddl.Command != c.command || ddl.Destructive != (c.destructive != "") || ddl.Reason != c.destructive {
+			t.Errorf("CheckDDLStatement(%q) = %+v, want %s destructive %q", c.statement, ddl, c.command, c.destructive)
+		}
+	}
+
+	rejected := map[string]string{
+		"":                                     "empty",
+		"SELECT * FROM docs":                   "must be a CREATE",
+		"INSERT INTO docs VALUES (1)":          "must be a CREATE",
+		"BEGIN":                                "must be a CREATE",
+		"CREATE TABLE a (x int); DROP TABLE b": "single statement",
+		"CREATE INDEX CONCURRENTLY i ON docs (body)": "CONCURRENTLY",
+		"DROP INDEX CONCURRENTLY i":                  "CONCURRENTLY",
+		"CREATE DATABASE other":                      "inside a transaction",
+		"ALTER SYSTEM SET work_mem = '1GB'":          "inside a transaction",
+		"CREATE TABLE docs (body text DEFAULT 'x)":   "unterminated",
+	}
+	for statement, want := range rejected {
+		if _, err := CheckDDLStatement(statement); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("CheckDDLStatement(%q) = %v, want an error containing %q", statement, err, want)
+		}
+	}
+}
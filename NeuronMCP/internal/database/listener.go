@@ -0,0 +1,337 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	listenerMinBackoff = 1 * time.Second
+	listenerMaxBackoff = 30 * time.Second
+)
+
+// channelNamePattern matches the channel names accepted by the listener:
+// plain identifiers, at most 63 bytes (NAMEDATALEN - 1)
+var channelNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]{0,62}$`)
+
+// ValidateChannelName checks that name can be used as a LISTEN channel
+func ValidateChannelName(name string) error {
+	if !channelNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid channel name '%s': must start with a letter or underscore, contain only letters, digits, '_' or '$', and be at most 63 characters", name)
+	}
+	return nil
+}
+
+// Notification is a payload received on a LISTEN channel
+type Notification struct {
+	Channel    string
+	Payload    string
+	PID        uint32
+	ReceivedAt time.Time
+}
+
+// NotificationHandler receives notifications from a Listener. It runs on the
+// listener goroutine, so it should not block for long.
+type NotificationHandler func(n *Notification)
+
+// listenRequest asks the listener goroutine to LISTEN or UNLISTEN a channel
+type listenRequest struct {
+	channel string
+	listen  bool
+	done    chan error
+}
+
+// Listener holds a dedicated connection subscribed to PostgreSQL
+// notification channels and passes every notification to a handler. Channels
+// can be added and removed while it runs; after a lost connection it
+// reconnects and subscribes to them again.
+type Listener struct {
+	db      *Database
+	handler NotificationHandler
+
+	mu       sync.Mutex
+	channels map[string]struct{}
+	wake     context.CancelFunc
+	running  bool
+
+	requests chan listenRequest
+}
+
+// NewListener creates a listener for channels. It does nothing until Run is
+// called.
+func NewListener(db *Database, channels []string, handler NotificationHandler) (*Listener, error) {
+	l := &Listener{
+		db:       db,
+		handler:  handler,
+		channels: make(map[string]struct{}),
+		requests: make(chan listenRequest, 16),
+	}
+	for _, channel := range channels {
+		if err := ValidateChannelName(channel); err != nil {
+			return nil, err
+		}
+		l.channels[channel] = struct{}{}
+	}
+	return l, nil
+}
+
+// Channels returns the subscribed channels in sorted order
+func (l *Listener) Channels() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	channels := make([]string, 0, len(l.channels))
+	for channel := range l.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Subscribe starts listening on channel. When the listener is disconnected
+// the channel is recorded and subscribed on reconnect.
+func (l *Listener) Subscribe(ctx context.Context, channel string) error {
+	if err := ValidateChannelName(channel); err != nil {
+		return err
+	}
+	return l.send(ctx, listenRequest{channel: channel, listen: true, done: make(chan error, 1)})
+}
+
+// Unsubscribe stops listening on channel
+func (l *Listener) Unsubscribe(ctx context.Context, channel string) error {
+	if err := ValidateChannelName(channel); err != nil {
+		return err
+	}
+	return l.send(ctx, listenRequest{channel: channel, done: make(chan error, 1)})
+}
+
+// send hands req to the listener goroutine and waits for it to be applied
+func (l *Listener) send(ctx context.Context, req listenRequest) error {
+	l.mu.Lock()
+	running := l.running
+	l.mu.Unlock()
+	if !running {
+		l.record(req)
+		return nil
+	}
+
+	select {
+	case l.requests <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// Interrupt a pending wait so the request is applied promptly
+	l.mu.Lock()
+	if l.wake != nil {
+		l.wake()
+	}
+	l.mu.Unlock()
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// record updates the channel set for req
+func (l *Listener) record(req listenRequest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if req.listen {
+		l.channels[req.channel] = struct{}{}
+	} else {
+		delete(l.channels, req.channel)
+	}
+}
+
+// Run listens until ctx is cancelled, reconnecting with backoff when the
+// connection fails. A connection is held only while at least one channel is
+// subscribed.
+func (l *Listener) Run(ctx context.Context, onError func(err error)) {
+	l.mu.Lock()
+	l.running = true
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.running = false
+		l.wake = nil
+		l.mu.Unlock()
+		l.drain(nil)
+	}()
+
+	backoff := listenerMinBackoff
+	for {
+		var first *listenRequest
+		if len(l.Channels()) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case req := <-l.requests:
+				first = &req
+			}
+		}
+
+		connected, err := l.listen(ctx, first)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			// Every channel was unsubscribed
+			continue
+		}
+		if onError != nil {
+			onError(err)
+		}
+		if connected {
+			backoff = listenerMinBackoff
+		}
+
+		timer := time.NewTimer(backoff)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case req := <-l.requests:
+				// Disconnected: apply the request on reconnect
+				l.record(req)
+				req.done <- nil
+			case <-timer.C:
+				break wait
+			}
+		}
+		if backoff *= 2; backoff > listenerMaxBackoff {
+			backoff = listenerMaxBackoff
+		}
+	}
+}
+
+// listen subscribes a fresh connection to every channel, applies first if
+// set, and forwards notifications until the connection fails or no channels
+// remain. connected reports whether the subscriptions were established.
+func (l *Listener) listen(ctx context.Context, first *listenRequest) (connected bool, err error) {
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		if first != nil {
+			l.record(*first)
+			first.done <- nil
+		}
+		return false, err
+	}
+	// The connection carries LISTEN state, so it is closed rather than
+	// returned to the pool for reuse
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	for _, channel := range l.Channels() {
+		if err := execListen(ctx, conn, channel, true); err != nil {
+			if first != nil {
+				l.record(*first)
+				first.done <- nil
+			}
+			return false, err
+		}
+	}
+	if first != nil {
+		if err := l.apply(conn, *first); err != nil && conn.Conn().IsClosed() {
+			return true, err
+		}
+	}
+
+	for {
+		if err := l.drain(conn); err != nil {
+			return true, err
+		}
+		if len(l.Channels()) == 0 {
+			return true, nil
+		}
+
+		waitCtx, cancel := context.WithCancel(ctx)
+		l.mu.Lock()
+		l.wake = cancel
+		l.mu.Unlock()
+		// A request queued before wake was set would not interrupt the wait
+		if len(l.requests) > 0 {
+			cancel()
+		}
+
+		n, err := conn.Conn().WaitForNotification(waitCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return true, ctx.Err()
+			}
+			if errors.Is(err, context.Canceled) && !conn.Conn().IsClosed() {
+				// Woken to apply a request
+				continue
+			}
+			return true, fmt.Errorf("waiting for notification failed: %w", err)
+		}
+		if l.handler != nil {
+			l.handler(&Notification{
+				Channel:    n.Channel,
+				Payload:    n.Payload,
+				PID:        n.PID,
+				ReceivedAt: time.Now(),
+			})
+		}
+	}
+}
+
+// drain applies queued requests on conn. With a nil conn (the listener is
+// stopping) they are only recorded.
+func (l *Listener) drain(conn *pgxpool.Conn) error {
+	for {
+		select {
+		case req := <-l.requests:
+			if conn == nil {
+				l.record(req)
+				req.done <- nil
+				continue
+			}
+			if err := l.apply(conn, req); err != nil && conn.Conn().IsClosed() {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// apply runs req on conn and reports the result to its sender
+func (l *Listener) apply(conn *pgxpool.Conn, req listenRequest) error {
+	// Requests run on the listener goroutine, outside the caller's context,
+	// so a timeout keeps an unresponsive server from stalling the loop
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := execListen(ctx, conn, req.channel, req.listen)
+	if err == nil {
+		l.record(req)
+	}
+	req.done <- err
+	return err
+}
+
+// execListen runs LISTEN or UNLISTEN for channel on conn
+func execListen(ctx context.Context, conn *pgxpool.Conn, channel string, listen bool) error {
+	command := "UNLISTEN"
+	if listen {
+		command = "LISTEN"
+	}
+	if _, err := conn.Exec(ctx, command+" "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("%s %s failed: %w", command, channel, err)
+	}
+	return nil
+}
@@ -155,6 +155,80 @@ func (qb *QueryBuilder) VectorSearch(table, vectorColumn string, queryVector []f
 	return query, params
 }
 
+// Bounds of the NeuronDB index scan settings
+const (
+	MaxEfSearch = 10000
+	MaxProbes   = 1000
+)
+
+// SearchTuning trades recall against latency for a single vector search. Zero
+// fields keep the database setting.
+type SearchTuning struct {
+	// EfSearch is the HNSW candidate list size (neurondb.hnsw_ef_search)
+	EfSearch int
+	// Probes is the number of IVF lists scanned (neurondb.ivf_probes)
+	Probes int
+	// RefineK is how many candidates the index returns before they are
+	// re-ranked by exact distance and cut to the limit
+	RefineK int
+}
+
+// IsZero reports whether the tuning changes nothing
+func (t *SearchTuning) IsZero() bool {
+	return t == nil || (t.EfSearch == 0 && t.Probes == 0 && t.RefineK == 0)
+}
+
+// Validate checks the settings against the ranges NeuronDB accepts. RefineK
+// must be at least the limit, since it widens the candidate pool.
+func (t *SearchTuning) Validate(limit int) error {
+	if t == nil {
+		return nil
+	}
+	if t.EfSearch < 0 || t.EfSearch > MaxEfSearch {
+		return fmt.Errorf("ef_search must be between 1 and %d, got %d", MaxEfSearch, t.EfSearch)
+	}
+	if t.Probes < 0 || t.Probes > MaxProbes {
+		return fmt.Errorf("probes must be between 1 and %d, got %d", MaxProbes, t.Probes)
+	}
+	if t.RefineK != 0 && (t.RefineK < limit || t.RefineK > 10000) {
+		return fmt.Errorf("refine_k must be between the limit (%d) and 10000, got %d", limit, t.RefineK)
+	}
+	return nil
+}
+
+// SearchSettings builds the SET LOCAL statements for a tuned search. They
+// must run in the transaction of the search, so the settings end with it.
+// SET takes no bind parameters; the values are validated integers.
+func (qb *QueryBuilder) SearchSettings(tuning *SearchTuning) []string {
+	if tuning == nil {
+		return nil
+	}
+	var statements []string
+	if tuning.EfSearch > 0 {
+		statements = append(statements, fmt.Sprintf("SET LOCAL neurondb.hnsw_ef_search = %d", tuning.EfSearch))
+	}
+	if tuning.Probes > 0 {
+		statements = append(statements, fmt.Sprintf("SET LOCAL neurondb.ivf_probes = %d", tuning.Probes))
+	}
+	return statements
+}
+
+// TunedVectorSearch builds a vector search that fetches tuning.RefineK
+// candidates through the index and returns the limit nearest of them by
+// exact distance. Without a RefineK above the limit it is VectorSearch.
+func (qb *QueryBuilder) TunedVectorSearch(table, vectorColumn string, queryVector []float32, distanceMetric string, limit int, additionalColumns []string, minkowskiP *float64, tuning *SearchTuning) (string, []interface{}) {
+	if tuning == nil || tuning.RefineK <= limit {
+		return qb.VectorSearch(table, vectorColumn, queryVector, distanceMetric, limit, additionalColumns, minkowskiP)
+	}
+	inner, params := qb.VectorSearch(table, vectorColumn, queryVector, distanceMetric, tuning.RefineK, additionalColumns, minkowskiP)
+	if inner == "" {
+		return "", nil
+	}
+	params = append(params, limit)
+	query := fmt.Sprintf("SELECT * FROM (%s) candidates ORDER BY distance ASC LIMIT $%d", inner, len(params))
+	return query, params
+}
+
 // formatVector formats a float32 slice as a PostgreSQL vector string
 func formatVector(vec []float32) string {
 	var parts []string
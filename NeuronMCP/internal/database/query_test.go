@@ -0,0 +1,61 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchSettings(t *testing.T) {
+	qb := &QueryBuilder{}
+	if got := qb.SearchSettings(nil); got != nil {
+		t.Errorf("nil tuning = %v", got)
+	}
+	got := qb.SearchSettings(&SearchTuning{EfSearch: 200, Probes: 32})
+	want := []string{
+		"SET LOCAL neurondb.hnsw_ef_search = 200",
+		"SET LOCAL neurondb.ivf_probes = 32",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("settings = %v, want %v", got, want)
+	}
+	if got := qb.SearchSettings(&SearchTuning{RefineK: 50}); len(got) != 0 {
+		t.Errorf("refine_k alone set %v", got)
+	}
+}
+
+func TestSearchTuningValidate(t *testing.T) {
+	cases := []struct {
+		tuning *SearchTuning
+		ok     bool
+	}{
+		{nil, true},
+		{&SearchTuning{EfSearch: 64, Probes: 10, RefineK: 10}, true},
+		{&SearchTuning{EfSearch: MaxEfSearch + 1}, false},
+		{&SearchTuning{Probes: MaxProbes + 1}, false},
+		{&SearchTuning{RefineK: 5}, false},
+	}
+	for _, c := range cases {
+		if err := c.tuning.Validate(10); (err == nil) != c.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", c.tuning, err, c.ok)
+		}
+	}
+}
+
+func TestTunedVectorSearch(t *testing.T) {
+	qb := &QueryBuilder{}
+	vec := []float32{1, 2}
+
+	plain, _ := qb.VectorSearch("docs", "embedding", vec, "cosine", 10, nil, nil)
+	if got, _ := qb.TunedVectorSearch("docs", "embedding", vec, "cosine", 10, nil, nil, &SearchTuning{EfSearch: 100}); got != plain {
+		t.Errorf("without refine_k = %s, want %s", got, plain)
+	}
+
+	query, params := qb.TunedVectorSearch("docs", "embedding", vec, "cosine", 10, nil, nil, &SearchTuning{RefineK: 100})
+	if !strings.HasPrefix(query, "SELECT * FROM (SELECT ") || !strings.HasSuffix(query, ") candidates ORDER BY distance ASC LIMIT $3") {
+		t.Errorf("query = %s", query)
+	}
+	if !reflect.DeepEqual(params, []interface{}{"[1,2]", 100, 10}) {
+		t.Errorf("params = %v", params)
+	}
+}
@@ -0,0 +1,349 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// readOnlyStarts are the keywords a read-only query may start with
+var readOnlyStarts = map[string]bool{
+	"select": true,
+	"with":   true,
+	"values": true,
+	"table":  true,
+}
+
+// modifyingStatements are the statements that may appear inside a query, as
+// a data-modifying CTE or after the CTE list of a WITH
+var modifyingStatements = map[string]bool{
+	"insert": true,
+	"update": true,
+	"delete": true,
+	"merge":  true,
+}
+
+// deniedFunctions have effects a read-only transaction does not prevent:
+// they signal backends, change settings, take locks, or reach the server's
+// file system or other servers. Functions that run SQL given as a string
+// are denied too, since the calls in that string are not tokens of the
+// query and escape this check. The list is a first line of defence; run
+// the queries as a role without EXECUTE on such functions as well.
+var deniedFunctions = map[string]bool{
+	"pg_terminate_backend":             true,
+	"pg_cancel_backend":                true,
+	"pg_reload_conf":                   true,
+	"pg_rotate_logfile":                true,
+	"pg_promote":                       true,
+	"pg_switch_wal":                    true,
+	"pg_create_restore_point":          true,
+	"pg_log_backend_memory_contexts":   true,
+	"set_config":                       true,
+	"pg_advisory_lock":                 true,
+	"pg_advisory_lock_shared":          true,
+	"pg_advisory_xact_lock":            true,
+	"pg_advisory_xact_lock_shared":     true,
+	"pg_try_advisory_lock":             true,
+	"pg_try_advisory_lock_shared":      true,
+	"pg_try_advisory_xact_lock":        true,
+	"pg_try_advisory_xact_lock_shared": true,
+	"pg_read_file":                     true,
+	"pg_read_binary_file":              true,
+	"pg_ls_dir":                        true,
+	"pg_stat_file":                     true,
+	"pg_ls_logdir":                     true,
+	"pg_ls_waldir":                     true,
+	"pg_ls_tmpdir":                     true,
+	"pg_ls_archive_statusdir":          true,
+	"pg_ls_logicalsnapdir":             true,
+	"pg_ls_logicalmapdir":              true,
+	"pg_ls_replslotdir":                true,
+	"pg_file_write":                    true,
+	"pg_file_rename":                   true,
+	"pg_file_unlink":                   true,
+	"pg_file_sync":                     true,
+	"lo_import":                        true,
+	"lo_export":                        true,
+	"dblink":                           true,
+	"dblink_exec":                      true,
+	"dblink_connect":                   true,
+	"dblink_send_query":                true,
+	"dblink_connect_u":                 true,
+	"dblink_open":                      true,
+	"dblink_fetch":                     true,
+	// These run the SQL of a string argument
+	"query_to_xml":               true,
+	"query_to_xmlschema":         true,
+	"query_to_xml_and_xmlschema": true,
+	"cursor_to_xml":              true,
+	"cursor_to_xmlschema":        true,
+	"ts_stat":                    true,
+	"ts_rewrite":                 true,
+}
+
+// Kinds of SQL tokens
+const (
+	sqlWord   = iota // keyword or unquoted identifier, lower-cased
+	sqlQuoted        // quoted identifier
+	sqlString        // string constant of any quoting
+	sqlNumber
+	sqlParam // $1
+	sqlPunct // operator or punctuation, one character
+)
+
+type sqlToken struct {
+	kind  int
+	value string
+}
+
+// CheckReadOnlyQuery parses query into tokens and returns an error unless
+// it is a single SELECT, WITH, VALUES or TABLE statement without
+// data-modifying CTEs, SELECT INTO, locking clauses or calls of functions
+// with side effects. Unlike IsReadOnlyStatement it understands comments,
+// string constants and quoted identifiers, so a keyword inside them does
+// not count. It is meant to reject writes early with a clear message; run
+// the query in a READ ONLY transaction as well, which also stops writes
+// made by functions it calls.
+func CheckReadOnlyQuery(query string) error {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return err
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].value == ";" && tokens[len(tokens)-1].kind == sqlPunct {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("query is empty")
+	}
+
+	start := 0
+	for start < len(tokens) && isPunct(tokens[start], "(") {
+		start++
+	}
+	if start == len(tokens) || tokens[start].kind != sqlWord || !readOnlyStarts[tokens[start].value] {
+		return fmt.Errorf("query must be a SELECT, WITH, VALUES or TABLE statement")
+	}
+
+	for i, tok := range tokens {
+		if isPunct(tok, ";") {
+			return fmt.Errorf("query must be a single statement")
+		}
+		if tok.kind == sqlWord {
+			var prev *sqlToken
+			if i > 0 {
+				prev = &tokens[i-1]
+			}
+			switch {
+			case tok.value == "into":
+				return fmt.Errorf("SELECT INTO is not allowed")
+			case tok.value == "for" && i+1 < len(tokens) && isLockStrength(tokens[i+1]):
+				return fmt.Errorf("locking clauses (FOR UPDATE, FOR SHARE) are not allowed")
+			case modifyingStatements[tok.value] && prev != nil && (isPunct(*prev, "(") || isPunct(*prev, ")")):
+				return fmt.Errorf("%s statements are not allowed", strings.ToUpper(tok.value))
+			}
+		}
+		if (tok.kind == sqlWord || tok.kind == sqlQuoted) && deniedFunctions[tok.value] &&
+			i+1 < len(tokens) && isPunct(tokens[i+1], "(") {
+			return fmt.Errorf("function %s is not allowed", tok.value)
+		}
+	}
+	return nil
+}
+
+// MaxQueryParameter returns the highest $n placeholder of query, or 0 when
+// it has none. Placeholders inside comments, strings and quoted identifiers
+// are not counted.
+func MaxQueryParameter(query string) (int, error) {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return 0, err
+	}
+	highest := 0
+	for _, tok := range tokens {
+		if tok.kind != sqlParam {
+			continue
+		}
+		n, err := strconv.Atoi(tok.value[1:])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid placeholder %s", tok.value)
+		}
+		highest = max(highest, n)
+	}
+	return highest, nil
+}
+
+func isPunct(tok sqlToken, value string) bool {
+	return tok.kind == sqlPunct && tok.value == value
+}
+
+// isLockStrength reports whether tok follows FOR in a locking clause: FOR
+// UPDATE, FOR NO KEY UPDATE, FOR SHARE or FOR KEY SHARE
+func isLockStrength(tok sqlToken) bool {
+	if tok.kind != sqlWord {
+		return false
+	}
+	switch tok.value {
+	case "update", "share", "no", "key":
+		return true
+	}
+	return false
+}
+
+// tokenizeSQL splits query into tokens following PostgreSQL's lexical rules,
+// skipping whitespace and comments. Unterminated comments, strings and
+// quoted identifiers are errors, since what follows them is ambiguous.
+// Unicode escape identifiers and strings (U&"..", U&'..') are errors too,
+// since their escapes could spell a denied function name.
+func tokenizeSQL(query string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	i, n := 0, len(query)
+	for i < n {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+
+		case c == '-' && i+1 < n && query[i+1] == '-':
+			for i < n && query[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < n && query[i+1] == '*':
+			// Block comments nest
+			depth := 0
+			for {
+				if i+1 >= n {
+					return nil, fmt.Errorf("unterminated comment")
+				}
+				if query[i] == '/' && query[i+1] == '*' {
+					depth++
+					i += 2
+				} else if query[i] == '*' && query[i+1] == '/' {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+
+		case c == '\'':
+			end, err := scanQuoted(query, i, '\'', false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sqlToken{kind: sqlString, value: query[i:end]})
+			i = end
+
+		case c == '"':
+			end, err := scanQuoted(query, i, '"', false)
+			if err != nil {
+				return nil, err
+			}
+			name := strings.ReplaceAll(query[i+1:end-1], `""`, `"`)
+			tokens = append(tokens, sqlToken{kind: sqlQuoted, value: name})
+			i = end
+
+		case c == '$' && i+1 < n && isDigit(query[i+1]):
+			end := i + 1
+			for end < n && isDigit(query[end]) {
+				end++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlParam, value: query[i:end]})
+			i = end
+
+		case c == '$':
+			// A dollar-quoted string: $tag$ ... $tag$
+			end := i + 1
+			for end < n && isIdentChar(query[end]) && query[end] != '$' {
+				end++
+			}
+			if end >= n || query[end] != '$' {
+				tokens = append(tokens, sqlToken{kind: sqlPunct, value: "$"})
+				i++
+				continue
+			}
+			delimiter := query[i : end+1]
+			closing := strings.Index(query[end+1:], delimiter)
+			if closing < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string")
+			}
+			stop := end + 1 + closing + len(delimiter)
+			tokens = append(tokens, sqlToken{kind: sqlString, value: query[i:stop]})
+			i = stop
+
+		case isIdentStart(c):
+			end := i + 1
+			for end < n && isIdentChar(query[end]) {
+				end++
+			}
+			word := strings.ToLower(query[i:end])
+			if word == "u" && end+1 < n && query[end] == '&' && (query[end+1] == '"' || query[end+1] == '\'') {
+				return nil, fmt.Errorf("unicode escapes (U&) are not allowed")
+			}
+			// A string constant with a prefix: E'..', B'..', X'..', N'..'
+			if end < n && query[end] == '\'' && (word == "e" || word == "b" || word == "x" || word == "n") {
+				stop, err := scanQuoted(query, end, '\'', word == "e")
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, sqlToken{kind: sqlString, value: query[i:stop]})
+				i = stop
+				continue
+			}
+			tokens = append(tokens, sqlToken{kind: sqlWord, value: word})
+			i = end
+
+		case isDigit(c) || (c == '.' && i+1 < n && isDigit(query[i+1])):
+			end := i + 1
+			for end < n && (isIdentChar(query[end]) || query[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlNumber, value: query[i:end]})
+			i = end
+
+		default:
+			tokens = append(tokens, sqlToken{kind: sqlPunct, value: string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// scanQuoted returns the index just past the quoted text starting at
+// query[start], where a doubled quote stands for itself. With backslashes
+// set, a backslash escapes the next character, as in E'..' strings.
+func scanQuoted(query string, start int, quote byte, backslashes bool) (int, error) {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslashes {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	if quote == '"' {
+		return 0, fmt.Errorf("unterminated quoted identifier")
+	}
+	return 0, fmt.Errorf("unterminated string constant")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckReadOnlyQuery(t *testing.T) {
+	reads := []string{
+		"SELECT * FROM docs",
+		"SELECT * FROM docs;",
+		"(SELECT 1) UNION (SELECT 2)",
+		"WITH q AS (SELECT id FROM docs) SELECT * FROM q",
+		"TABLE docs",
+		"VALUES (1, 'a'), (2, 'b')",
+		// Keywords inside strings, comments and quoted identifiers do not count
+		"SELECT 'insert into t; delete from t' AS s",
+		"SELECT $$ drop table docs; $$, $tag$ it's $$ fine $tag$",
+		"SELECT E'it\\'s; update' AS s",
+		`SELECT "into", "update" FROM docs`,
+		"SELECT 1 -- ; delete from docs\n",
+		"SELECT 1 /* outer /* nested; */ still comment; */",
+		"SELECT substring(title FROM 1 FOR 10) FROM docs",
+		"SELECT * FROM docs WHERE id = $1 AND title = $2",
+		"SELECT pg_advisory_lock_status FROM locks",
+		"SELECT u & 1, u&1 FROM flags",
+	}
+	for _, q := range reads {
+		if err := CheckReadOnlyQuery(q); err != nil {
+			t.Errorf("CheckReadOnlyQuery(%q) = %v", q, err)
+		}
+	}
+
+	writes := map[string]string{
+		"":                                 "empty",
+		"INSERT INTO docs VALUES (1)":      "must be a SELECT",
+		"EXPLAIN ANALYZE DELETE FROM docs": "must be a SELECT",
+		"SELECT 1; DELETE FROM docs":       "single statement",
+		"SELECT 1; SELECT 2":               "single statement",
+		"WITH d AS (DELETE FROM docs RETURNING *) SELECT * FROM d": "DELETE",
+		"WITH d AS (SELECT 1) UPDATE docs SET title = 'x'":         "UPDATE",
+		"SELECT * INTO backup FROM docs":                           "SELECT INTO",
+		"SELECT * FROM docs FOR UPDATE":                            "locking",
+		"SELECT * FROM docs FOR NO KEY UPDATE":                     "locking",
+		"SELECT * FROM docs FOR SHARE":                             "locking",
+		"SELECT pg_terminate_backend(42)":                          "pg_terminate_backend",
+		"SELECT pg_catalog.set_config('role', 'admin', false)":     "set_config",
+		`SELECT "pg_read_file"('/etc/passwd')`:                     "pg_read_file",
+		"SELECT 'unterminated":                                     "unterminated string",
+		"SELECT 1 /* open":                                         "unterminated comment",
+		"SELECT $x$ open":                                          "unterminated dollar",
+		`SELECT "open`:                                             "unterminated quoted identifier",
+		`SELECT U&"set\0063onfig"('role', 'admin', false)`:         "unicode escapes",
+		`SELECT u&"pg_read_fil\0065"('/etc/passwd')`:               "unicode escapes",
+		`SELECT U&'\0041' AS a`:                                    "unicode escapes",
+	}
+	for q, want := range writes {
+		err := CheckReadOnlyQuery(q)
+		if err == nil {
+			t.Errorf("CheckReadOnlyQuery(%q) = nil, want an error about %q", q, want)
+			continue
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("CheckReadOnlyQuery(%q) = %v, want an error about %q", q, err, want)
+		}
+	}
+}
+
+func TestCheckReadOnlyQueryStringSQL(t *testing.T) {
+	// A denied call inside a string is not a token of the query, so the
+	// functions that run such strings are denied themselves
+	for q, want := range map[string]string{
+		"SELECT query_to_xml('select pg_terminate_backend(123)', true, false, '')":         "query_to_xml",
+		"SELECT pg_catalog.query_to_xmlschema('select pg_reload_conf()', true, false, '')": "query_to_xmlschema",
+		"SELECT query_to_xml_and_xmlschema('select 1', true, false, '')":                   "query_to_xml_and_xmlschema",
+		"SELECT cursor_to_xml('c', 1, true, false, '')":                                    "cursor_to_xml",
+		"SELECT * FROM ts_stat('select set_config(''role'', ''admin'', false)::tsvector')": "ts_stat",
+		"SELECT dblink_open('c', 'select pg_terminate_backend(1)')":                        "dblink_open",
+		"SELECT dblink_connect_u('host=localhost')":                                        "dblink_connect_u",
+		"SELECT * FROM pg_ls_logdir()":                                                     "pg_ls_logdir",
+		"SELECT * FROM pg_ls_waldir()":                                                     "pg_ls_waldir",
+	} {
+		err := CheckReadOnlyQuery(q)
+		if err == nil || !strings.Contains(err.Error(), "function "+want+" ") {
+			t.Errorf("CheckReadOnlyQuery(%q) = %v, want %s denied", q, err, want)
+		}
+	}
+}
+
+func TestMaxQueryParameter(t *testing.T) {
+	for q, want := range map[string]int{
+		"SELECT 1": 0,
+		"SELECT * FROM t WHERE a = $1 AND b = $3":        3,
+		"SELECT '$9', \"$8\" -- $7\nFROM t WHERE a = $2": 2,
+		"SELECT $$ $5 $$, $tag$ $6 $tag$":                0,
+	} {
+		got, err := MaxQueryParameter(q)
+		if err != nil || got != want {
+			t.Errorf("MaxQueryParameter(%q) = %d, %v; want %d", q, got, err, want)
+		}
+	}
+	if _, err := MaxQueryParameter("SELECT $0"); err == nil {
+		t.Error("MaxQueryParameter should reject $0")
+	}
+}
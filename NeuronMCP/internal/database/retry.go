@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrCircuitOpen is returned without contacting the database while the
+// circuit breaker is open
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// transientSQLStates are the PostgreSQL error codes after which the same
+// statement may succeed if run again. Class 08 (connection exception) is
+// matched separately.
+var transientSQLStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+	"25006": true, // read_only_sql_transaction, seen on a demoted primary during failover
+}
+
+// IsTransient reports whether err is a database error that may not recur:
+// a serialization failure or deadlock, a server that is shutting down or
+// starting up, or a lost connection. Timeouts and cancellations are not
+// transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return transientSQLStates[pgErr.Code] || strings.HasPrefix(pgErr.Code, "08")
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// modifyingKeyword matches SQL keywords that write data or change the schema
+var modifyingKeyword = regexp.MustCompile(`(?i)\b(insert|update|delete|merge|truncate|create|drop|alter|grant|revoke|copy|call|refresh)\b`)
+
+// IsReadOnlyStatement reports whether query looks like a plain read: it
+// starts with SELECT, WITH, VALUES, TABLE, SHOW or EXPLAIN and has no
+// keyword that writes data or changes the schema. The check is lexical, so
+// a read that mentions such a keyword in a string literal is treated as a
+// write.
+func IsReadOnlyStatement(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(strings.TrimLeft(fields[0], "(")) {
+	case "SELECT", "WITH", "VALUES", "TABLE", "SHOW", "EXPLAIN":
+	default:
+		return false
+	}
+	return !modifyingKeyword.MatchString(query)
+}
+
+// RetryPolicy controls how transient errors are retried
+type RetryPolicy struct {
+	MaxAttempts int           // attempts including the first
+	BaseDelay   time.Duration // backoff before the second attempt
+	MaxDelay    time.Duration // backoff cap
+}
+
+// DefaultRetryPolicy retries twice with jittered backoff of up to 100ms and
+// then 200ms
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+// Backoff returns the delay before attempt (1 for the first retry): a random
+// duration up to BaseDelay*2^(attempt-1), capped at MaxDelay
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << uint(attempt-1)
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+// Circuit breaker defaults
+const (
+	DefaultCircuitThreshold = 5
+	DefaultCircuitCooldown  = 30 * time.Second
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops sending statements to the database after repeated
+// transient failures. Once Cooldown has passed it lets one statement through
+// (half-open); success closes the circuit and failure opens it again.
+type CircuitBreaker struct {
+	Threshold int           // consecutive transient failures that open the circuit
+	Cooldown  time.Duration // time the circuit stays open
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	trips     int64
+	lastError string
+	now       func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, state: CircuitClosed, now: time.Now}
+}
+
+// Allow returns an error wrapping ErrCircuitOpen if a statement must not be
+// sent now. A nil breaker allows everything.
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		wait := b.Cooldown - b.now().Sub(b.openedAt)
+		if wait > 0 {
+			return fmt.Errorf("%w after %d consecutive transient failures (last error: %s), retry in %v",
+				ErrCircuitOpen, b.failures, b.lastError, wait.Round(time.Millisecond))
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: a probe statement is in progress", ErrCircuitOpen)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record updates the breaker with the outcome of a statement. Only transient
+// errors count as failures. Cancellations and timeouts say nothing about the
+// database: they leave the failure count alone, and a cancelled probe
+// returns the circuit to open. Any other outcome shows the database is
+// reachable and closes the circuit.
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		if b.state == CircuitHalfOpen {
+			b.state = CircuitOpen
+		}
+		return
+	}
+	if !IsTransient(err) {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	b.lastError = err.Error()
+	if b.state == CircuitHalfOpen || b.failures >= b.Threshold {
+		if b.state != CircuitOpen {
+			b.trips++
+		}
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// CircuitState is a snapshot of a circuit breaker
+type CircuitState struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Threshold           int        `json:"threshold"`
+	CooldownMs          int64      `json:"cooldown_ms"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	Trips               int64      `json:"trips"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// State returns a snapshot of the breaker
+func (b *CircuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitState{State: CircuitClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := CircuitState{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Threshold:           b.Threshold,
+		CooldownMs:          b.Cooldown.Milliseconds(),
+		Trips:               b.trips,
+		LastError:           b.lastError,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		state.OpenedAt = &openedAt
+	}
+	return state
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{fmt.Errorf("query failed: %w", &pgconn.PgError{Code: "08006"}), true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{&pgconn.PgError{Code: "42P01"}, false},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{context.DeadlineExceeded, false},
+		{errors.New("syntax error"), false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := IsTransient(c.err); got != c.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestIsReadOnlyStatement(t *testing.T) {
+	reads := []string{
+		"SELECT * FROM docs",
+		"  with q AS (SELECT 1) SELECT * FROM q",
+		"(SELECT 1) UNION (SELECT 2)",
+		"SELECT neurondb.create_model_name()",
+	}
+	for _, q := range reads {
+		if !IsReadOnlyStatement(q) {
+			t.Errorf("IsReadOnlyStatement(%q) = false", q)
+		}
+	}
+	writes := []string{
+		"INSERT INTO docs VALUES (1)",
+		"WITH d AS (DELETE FROM docs RETURNING *) SELECT * FROM d",
+		"SELECT * FROM docs FOR UPDATE",
+		"CREATE INDEX ON docs (id)",
+		"",
+	}
+	for _, q := range writes {
+		if IsReadOnlyStatement(q) {
+			t.Errorf("IsReadOnlyStatement(%q) = true", q)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond}
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: 250 * time.Millisecond} {
+		for i := 0; i < 50; i++ {
+			if d := p.Backoff(attempt); d <= 0 || d > ceiling {
+				t.Fatalf("Backoff(%d) = %v, want (0, %v]", attempt, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewCircuitBreaker(2, time.Second)
+	b.now = func() time.Time { return now }
+	transient := &pgconn.PgError{Code: "57P03"}
+
+	b.Record(transient)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() after one failure = %v", err)
+	}
+	b.Record(transient)
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() after threshold = %v, want ErrCircuitOpen", err)
+	}
+
+	// After the cooldown one probe goes through; a failed probe reopens
+	now = now.Add(time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow() = %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second Allow() during probe = %v, want ErrCircuitOpen", err)
+	}
+	b.Record(transient)
+	if state := b.State(); state.State != CircuitOpen || state.Trips != 2 {
+		t.Fatalf("state after failed probe = %+v", state)
+	}
+
+	// A non-transient outcome shows the database is reachable
+	now = now.Add(time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow() = %v", err)
+	}
+	b.Record(errors.New("relation does not exist"))
+	if state := b.State(); state.State != CircuitClosed || state.ConsecutiveFailures != 0 {
+		t.Fatalf("state after successful probe = %+v", state)
+	}
+
+	var nilBreaker *CircuitBreaker
+	if err := nilBreaker.Allow(); err != nil {
+		t.Errorf("nil breaker Allow() = %v", err)
+	}
+}
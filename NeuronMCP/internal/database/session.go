@@ -0,0 +1,283 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxSessionTables bounds the temporary result sets a session may hold
+const MaxSessionTables = 32
+
+// sessionCleanupTimeout bounds dropping the session's tables when it ends
+const sessionCleanupTimeout = 5 * time.Second
+
+// ErrSessionTableNotFound is returned for a session table that does not exist
+var ErrSessionTableNotFound = errors.New("session table not found")
+
+// ErrSessionTableExists is returned when creating a session table whose name
+// is taken and replace was not asked for
+var ErrSessionTableExists = errors.New("session table already exists")
+
+// sessionTableNamePattern matches session table names: plain identifiers, at
+// most 63 bytes (NAMEDATALEN - 1)
+var sessionTableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// ValidateSessionTableName checks that name can be used as a session table
+func ValidateSessionTableName(name string) error {
+	if !sessionTableNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid session table name '%s': must start with a letter or underscore, contain only letters, digits or '_', and be at most 63 characters", name)
+	}
+	return nil
+}
+
+// SessionColumn is a column of a session table
+type SessionColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// SessionTable describes a temporary result set of the session
+type SessionTable struct {
+	Name      string          `json:"name"`
+	Columns   []SessionColumn `json:"columns"`
+	RowCount  int64           `json:"row_count"`
+	Query     string          `json:"query"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SessionState holds the temporary result sets of the MCP session. They are
+// TEMP tables on a connection pinned to the session while any exist, so
+// later calls can query them and they vanish with the session. If the pinned
+// connection is lost, so are its tables.
+type SessionState struct {
+	db *Database
+
+	mu     sync.Mutex
+	conn   *pgxpool.Conn
+	tables map[string]*SessionTable
+}
+
+// NewSessionState creates an empty session state on db. It takes a
+// connection only when the first table is created.
+func NewSessionState(db *Database) *SessionState {
+	return &SessionState{db: db, tables: make(map[string]*SessionTable)}
+}
+
+// Database returns the database the session tables live on
+func (s *SessionState) Database() *Database {
+	return s.db
+}
+
+// Tables returns the session tables sorted by name
+func (s *SessionState) Tables() []SessionTable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkConnection()
+	tables := make([]SessionTable, 0, len(s.tables))
+	for _, t := range s.tables {
+		tables = append(tables, *t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
+
+// Create stores the rows of query, which must be a read-only statement, in
+// the session table name. With replace an existing table of that name is
+// dropped first; otherwise it is an error wrapping ErrSessionTableExists.
+func (s *SessionState) Create(ctx context.Context, name, query string, replace bool) (*SessionTable, error) {
+	if err := ValidateSessionTableName(name); err != nil {
+		return nil, err
+	}
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	if !IsReadOnlyStatement(query) {
+		return nil, fmt.Errorf("session table '%s' must be created from a read-only query (SELECT, WITH, VALUES or TABLE) that does not modify data", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkConnection()
+	_, exists := s.tables[name]
+	if exists && !replace {
+		return nil, fmt.Errorf("%w: '%s' (pass replace to overwrite it)", ErrSessionTableExists, name)
+	}
+	if !exists && len(s.tables) >= MaxSessionTables {
+		return nil, fmt.Errorf("session already holds %d tables, the maximum: drop one before creating '%s'", MaxSessionTables, name)
+	}
+
+	conn, err := s.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseIfEmpty()
+
+	ident := pgx.Identifier{name}.Sanitize()
+	if exists {
+		if _, err := conn.Exec(ctx, "DROP TABLE IF EXISTS pg_temp."+ident); err != nil {
+			s.checkConnection()
+			return nil, fmt.Errorf("failed to replace session table '%s': %w", name, err)
+		}
+		delete(s.tables, name)
+	}
+	tag, err := conn.Exec(ctx, "CREATE TEMP TABLE "+ident+" AS "+query)
+	if err != nil {
+		s.checkConnection()
+		return nil, fmt.Errorf("failed to create session table '%s': query='%s', error=%w", name, query, err)
+	}
+
+	columns, err := sessionTableColumns(ctx, conn, name)
+	if err != nil {
+		s.checkConnection()
+		return nil, fmt.Errorf("failed to describe session table '%s': %w", name, err)
+	}
+	table := &SessionTable{
+		Name:      name,
+		Columns:   columns,
+		RowCount:  tag.RowsAffected(),
+		Query:     query,
+		CreatedAt: time.Now(),
+	}
+	s.tables[name] = table
+	copied := *table
+	return &copied, nil
+}
+
+// Query runs query, which may read session tables, in a read-only
+// transaction on the session's connection and passes its rows to scan
+func (s *SessionState) Query(ctx context.Context, query string, args []interface{}, scan func(pgx.Rows) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkConnection()
+
+	conn, err := s.connection(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.releaseIfEmpty()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		s.checkConnection()
+		return fmt.Errorf("failed to begin read-only transaction for session query: %w", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		s.checkConnection()
+		return fmt.Errorf("session query failed: query='%s', error=%w", query, err)
+	}
+	defer rows.Close()
+	if err := scan(rows); err != nil {
+		s.checkConnection()
+		return err
+	}
+	return nil
+}
+
+// Drop removes the session table name. It returns an error wrapping
+// ErrSessionTableNotFound if there is no such table.
+func (s *SessionState) Drop(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkConnection()
+	if _, ok := s.tables[name]; !ok {
+		return fmt.Errorf("%w: '%s'", ErrSessionTableNotFound, name)
+	}
+
+	if _, err := s.conn.Exec(ctx, "DROP TABLE IF EXISTS pg_temp."+pgx.Identifier{name}.Sanitize()); err != nil {
+		s.checkConnection()
+		return fmt.Errorf("failed to drop session table '%s': %w", name, err)
+	}
+	delete(s.tables, name)
+	s.releaseIfEmpty()
+	return nil
+}
+
+// Close drops every session table and returns the pinned connection to the
+// pool
+func (s *SessionState) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.tables {
+		delete(s.tables, name)
+	}
+	s.releaseIfEmpty()
+}
+
+// connection returns the pinned connection, acquiring one if needed. The
+// caller holds s.mu.
+func (s *SessionState) connection(ctx context.Context) (*pgxpool.Conn, error) {
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("session state unavailable: %w", err)
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// checkConnection forgets the tables of a pinned connection that was lost,
+// as they went with it. The caller holds s.mu.
+func (s *SessionState) checkConnection() {
+	if s.conn == nil || !s.conn.Conn().IsClosed() {
+		return
+	}
+	s.conn.Release()
+	s.conn = nil
+	for name := range s.tables {
+		delete(s.tables, name)
+	}
+}
+
+// releaseIfEmpty returns the pinned connection to the pool once the session
+// holds no tables, dropping any temporary tables left on it first. The
+// caller holds s.mu.
+func (s *SessionState) releaseIfEmpty() {
+	if s.conn == nil || len(s.tables) > 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionCleanupTimeout)
+	defer cancel()
+	if _, err := s.conn.Exec(ctx, "DISCARD TEMP"); err != nil {
+		// Temporary tables must not leak to the pool's next user
+		s.conn.Hijack().Close(ctx)
+		s.conn = nil
+		return
+	}
+	s.conn.Release()
+	s.conn = nil
+}
+
+// sessionTableColumns returns the columns of the temporary table name
+func sessionTableColumns(ctx context.Context, conn *pgxpool.Conn, name string) ([]SessionColumn, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+		FROM pg_attribute a
+		WHERE a.attrelid = ('pg_temp.' || quote_ident($1))::regclass
+		  AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := []SessionColumn{}
+	for rows.Next() {
+		var c SessionColumn
+		if err := rows.Scan(&c.Name, &c.Type); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
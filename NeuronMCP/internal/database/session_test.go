@@ -0,0 +1,21 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSessionTableName(t *testing.T) {
+	valid := []string{"hits", "_tmp", "Results_2", strings.Repeat("a", 63)}
+	for _, name := range valid {
+		if err := ValidateSessionTableName(name); err != nil {
+			t.Errorf("ValidateSessionTableName(%q) = %v", name, err)
+		}
+	}
+	invalid := []string{"", "2hits", "bad name", "hits;drop", "public.hits", `"hits"`, strings.Repeat("a", 64)}
+	for _, name := range invalid {
+		if err := ValidateSessionTableName(name); err == nil {
+			t.Errorf("ValidateSessionTableName(%q) succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// settingNamePattern matches the qualified names of extension run-time
+// parameters, such as neurondb.gpu_device
+var settingNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*\.[a-z_][a-z0-9_]*$`)
+
+// SessionSettings are run-time parameters (GUCs) set for the MCP session.
+// Each pooled connection picks up the current settings when it is next
+// acquired, so every later query of the session sees them while the
+// database's own defaults stay untouched. A connection pinned before a
+// change, such as the one holding session tables, sees it once released.
+type SessionSettings struct {
+	mu      sync.Mutex
+	values  map[string]string
+	version uint64
+	// applied records the settings version and names each connection was
+	// last brought up to
+	applied map[*pgx.Conn]appliedSettings
+}
+
+type appliedSettings struct {
+	version uint64
+	names   []string
+}
+
+// NewSessionSettings creates an empty set of session settings
+func NewSessionSettings() *SessionSettings {
+	return &SessionSettings{
+		values:  make(map[string]string),
+		applied: make(map[*pgx.Conn]appliedSettings),
+	}
+}
+
+// ValidateSettingName checks that name is a qualified extension parameter
+func ValidateSettingName(name string) error {
+	if !settingNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid setting name '%s': must be a lowercase qualified name such as 'neurondb.gpu_device'", name)
+	}
+	return nil
+}
+
+// Set overrides a parameter for the session
+func (s *SessionSettings) Set(name, value string) error {
+	if err := ValidateSettingName(name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.values[name]; ok && current == value {
+		return nil
+	}
+	s.values[name] = value
+	s.version++
+	return nil
+}
+
+// Reset removes the session's override of a parameter, returning it to
+// the database default. It reports whether there was one.
+func (s *SessionSettings) Reset(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[name]; !ok {
+		return false
+	}
+	delete(s.values, name)
+	s.version++
+	return true
+}
+
+// Values returns a copy of the session's overrides
+func (s *SessionSettings) Values() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make(map[string]string, len(s.values))
+	for name, value := range s.values {
+		values[name] = value
+	}
+	return values
+}
+
+// prepare brings conn up to the current settings: parameters no longer
+// overridden are reset and the others set for the connection's session
+func (s *SessionSettings) prepare(ctx context.Context, conn *pgx.Conn) error {
+	s.mu.Lock()
+	applied := s.applied[conn]
+	if applied.version == s.version {
+		s.mu.Unlock()
+		return nil
+	}
+	version := s.version
+	statements := settingStatements(applied.names, s.values)
+	names := make([]string, 0, len(s.values))
+	for name := range s.values {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+
+	for _, stmt := range statements {
+		if _, err := conn.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+			return fmt.Errorf("failed to apply session setting: query='%s', error=%w", stmt.sql, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.applied[conn] = appliedSettings{version: version, names: names}
+	s.mu.Unlock()
+	return nil
+}
+
+// forget drops the record of a closed connection
+func (s *SessionSettings) forget(conn *pgx.Conn) {
+	s.mu.Lock()
+	delete(s.applied, conn)
+	s.mu.Unlock()
+}
+
+type settingStatement struct {
+	sql  string
+	args []interface{}
+}
+
+// settingStatements returns the statements moving a connection that had
+// the previous names overridden to the current values, in name order
+func settingStatements(previous []string, values map[string]string) []settingStatement {
+	var stale []string
+	for _, name := range previous {
+		if _, ok := values[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statements := make([]settingStatement, 0, len(stale)+len(names))
+	for _, name := range stale {
+		statements = append(statements, settingStatement{sql: "RESET " + pgx.Identifier(strings.Split(name, ".")).Sanitize()})
+	}
+	for _, name := range names {
+		statements = append(statements, settingStatement{sql: "SELECT set_config($1, $2, false)", args: []interface{}{name, values[name]}})
+	}
+	return statements
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSessionSettings(t *testing.T) {
+	s := NewSessionSettings()
+	if err := s.Set("gpu_device", "1"); err == nil {
+		t.Error("Set accepted an unqualified name")
+	}
+	if err := s.Set(`neurondb.gpu_device"; RESET ALL; --`, "1"); err == nil {
+		t.Error("Set accepted an invalid name")
+	}
+	if err := s.Set("neurondb.gpu_device", "1"); err != nil {
+		t.Fatal(err)
+	}
+	version := s.version
+	s.Set("neurondb.gpu_device", "1")
+	if s.version != version {
+		t.Error("setting an unchanged value bumped the version")
+	}
+	if s.Reset("neurondb.compute_mode") {
+		t.Error("Reset reported an override that was never set")
+	}
+	if !s.Reset("neurondb.gpu_device") || len(s.Values()) != 0 {
+		t.Errorf("Reset left %v", s.Values())
+	}
+}
+
+func TestSettingStatements(t *testing.T) {
+	statements := settingStatements(
+		[]string{"neurondb.gpu_device", "neurondb.compute_mode"},
+		map[string]string{"neurondb.compute_mode": "2", "neurondb.gpu_streams": "4"},
+	)
+	want := []settingStatement{
+		{sql: `RESET "neurondb"."gpu_device"`},
+		{sql: "SELECT set_config($1, $2, false)", args: []interface{}{"neurondb.compute_mode", "2"}},
+		{sql: "SELECT set_config($1, $2, false)", args: []interface{}{"neurondb.gpu_streams", "4"}},
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("settingStatements = %+v, want %+v", statements, want)
+	}
+}
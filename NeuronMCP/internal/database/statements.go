@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxStatementsPerShape bounds the prepared statements a query shape keeps
+// on one connection. A shape whose SQL varies, such as a vector search over
+// several tables, keeps its most recently used statements.
+const MaxStatementsPerShape = 8
+
+// statementDeallocateTimeout bounds dropping an evicted or invalidated
+// statement, which may happen after the call's context has ended
+const statementDeallocateTimeout = 5 * time.Second
+
+// StatementCache prepares the hot queries of tools once per connection, so
+// the server parses and plans them once rather than on every call. Each
+// query shape, a name such as "vector_search" given by the caller, keeps
+// its statements apart from other shapes. A statement is named after the
+// shape and its SQL, so when search parameters change the SQL of a shape a
+// new statement is prepared, and the least recently used one is deallocated
+// once the shape holds MaxStatementsPerShape. Statements the server
+// invalidates, after a schema change or a DISCARD, are deallocated when a
+// query fails on them and prepared again by the next call.
+type StatementCache struct {
+	mu sync.Mutex
+	// conns holds the statement names of each shape prepared on each
+	// connection, most recently used first
+	conns map[*pgx.Conn]map[string][]string
+
+	prepares      atomic.Int64
+	executions    atomic.Int64
+	evictions     atomic.Int64
+	invalidations atomic.Int64
+}
+
+// StatementStats counts the work of a statement cache since the server
+// started
+type StatementStats struct {
+	// Prepares is the number of statements prepared
+	Prepares int64 `json:"prepares"`
+	// Executions is the number of queries run as prepared statements;
+	// executions beyond prepares reused a statement
+	Executions int64 `json:"executions"`
+	// Evictions is the number of statements deallocated to make room for
+	// another SQL text of their shape
+	Evictions int64 `json:"evictions"`
+	// Invalidations is the number of statements dropped because the server
+	// no longer accepted them
+	Invalidations int64 `json:"invalidations"`
+	// Cached is the number of statements prepared on open connections
+	Cached int `json:"cached"`
+}
+
+// NewStatementCache creates an empty statement cache
+func NewStatementCache() *StatementCache {
+	return &StatementCache{conns: make(map[*pgx.Conn]map[string][]string)}
+}
+
+// Stats returns the counters of the cache
+func (c *StatementCache) Stats() StatementStats {
+	c.mu.Lock()
+	cached := 0
+	for _, shapes := range c.conns {
+		for _, names := range shapes {
+			cached += len(names)
+		}
+	}
+	c.mu.Unlock()
+	return StatementStats{
+		Prepares:      c.prepares.Load(),
+		Executions:    c.executions.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+		Cached:        cached,
+	}
+}
+
+// statementName names the statement of a shape's SQL
+func statementName(shape, query string) string {
+	sum := sha256.Sum256([]byte(shape + "\x00" + query))
+	return "neurondb_mcp_" + hex.EncodeToString(sum[:8])
+}
+
+// prepare returns the name of the statement of shape's query on conn,
+// preparing it if the connection does not have it yet
+func (c *StatementCache) prepare(ctx context.Context, conn *pgx.Conn, shape, query string) (string, error) {
+	name := statementName(shape, query)
+	if c.use(conn, shape, name) {
+		c.executions.Add(1)
+		return name, nil
+	}
+	if _, err := conn.Prepare(ctx, name, query); err != nil {
+		return "", fmt.Errorf("failed to prepare statement: shape='%s', error=%w", shape, err)
+	}
+	c.prepares.Add(1)
+	if evicted := c.add(conn, shape, name); evicted != "" {
+		c.evictions.Add(1)
+		deallocate(conn, evicted)
+	}
+	c.executions.Add(1)
+	return name, nil
+}
+
+// use reports whether conn has the statement, marking it most recently used
+func (c *StatementCache) use(conn *pgx.Conn, shape, name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := c.conns[conn][shape]
+	for i, n := range names {
+		if n == name {
+			copy(names[1:i+1], names[:i])
+			names[0] = name
+			return true
+		}
+	}
+	return false
+}
+
+// add records a statement prepared on conn and returns the statement of
+// the same shape it evicts, or ""
+func (c *StatementCache) add(conn *pgx.Conn, shape, name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	shapes := c.conns[conn]
+	if shapes == nil {
+		shapes = make(map[string][]string)
+		c.conns[conn] = shapes
+	}
+	names := append([]string{name}, shapes[shape]...)
+	evicted := ""
+	if len(names) > MaxStatementsPerShape {
+		evicted = names[len(names)-1]
+		names = names[:MaxStatementsPerShape]
+	}
+	shapes[shape] = names
+	return evicted
+}
+
+// invalidate drops a statement the server no longer accepts, so the next
+// call prepares it again
+func (c *StatementCache) invalidate(conn *pgx.Conn, shape, name string) {
+	c.mu.Lock()
+	names := c.conns[conn][shape]
+	for i, n := range names {
+		if n == name {
+			c.conns[conn][shape] = append(names[:i:i], names[i+1:]...)
+			break
+		}
+	}
+	c.mu.Unlock()
+	c.invalidations.Add(1)
+	deallocate(conn, name)
+}
+
+// forget drops the statements of a closed connection
+func (c *StatementCache) forget(conn *pgx.Conn) {
+	c.mu.Lock()
+	delete(c.conns, conn)
+	c.mu.Unlock()
+}
+
+// deallocate drops a statement from conn. Failures are ignored: the
+// statement may already be gone from the server, and pgx forgets it either
+// way.
+func deallocate(conn *pgx.Conn, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), statementDeallocateTimeout)
+	defer cancel()
+	_ = conn.Deallocate(ctx, name)
+}
+
+// isStatementInvalidated reports whether err shows that a prepared
+// statement must be prepared again: its result type changed with the
+// schema, or it no longer exists on the server
+func isStatementInvalidated(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "0A000": // cached plan must not change result type
+		return pgErr.Message == "cached plan must not change result type"
+	case "26000": // invalid_sql_statement_name
+		return true
+	}
+	return false
+}
+
+// QueryPrepared runs query as the prepared statement of shape, preparing it
+// on the connection first if needed. Use it for queries tools run often
+// whose SQL takes only a few forms. The connection is held until the rows
+// are closed or read to the end.
+func (d *Database) QueryPrepared(ctx context.Context, shape, query string, args ...interface{}) (pgx.Rows, error) {
+	if d.statements == nil {
+		return d.Query(ctx, query, args...)
+	}
+	conn, err := d.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name, err := d.statements.prepare(ctx, conn.Conn(), shape, query)
+	if err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("query execution failed on database '%s' on host '%s:%d' as user '%s': query='%s', error=%w", d.database, d.host, d.port, d.user, query, err)
+	}
+	rows, err := conn.Conn().Query(ctx, name, args...)
+	if err != nil {
+		d.releasePrepared(conn, shape, name, err)
+		return nil, fmt.Errorf("query execution failed on database '%s' on host '%s:%d' as user '%s': query='%s', error=%w", d.database, d.host, d.port, d.user, query, err)
+	}
+	return &preparedRows{Rows: rows, release: func(err error) { d.releasePrepared(conn, shape, name, err) }}, nil
+}
+
+// StatementStats returns the counters of the prepared statement cache
+func (d *Database) StatementStats() StatementStats {
+	if d.statements == nil {
+		return StatementStats{}
+	}
+	return d.statements.Stats()
+}
+
+// releasePrepared returns the connection of a prepared query to the pool,
+// dropping the statement first if err shows the server invalidated it
+func (d *Database) releasePrepared(conn *pgxpool.Conn, shape, name string, err error) {
+	if err != nil && isStatementInvalidated(err) {
+		d.statements.invalidate(conn.Conn(), shape, name)
+	}
+	conn.Release()
+}
+
+// preparedRows releases the connection of a prepared query once its rows
+// are closed or read to the end
+type preparedRows struct {
+	pgx.Rows
+	release func(err error)
+	done    bool
+}
+
+func (r *preparedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.finish()
+	return false
+}
+
+func (r *preparedRows) Close() {
+	r.Rows.Close()
+	r.finish()
+}
+
+func (r *preparedRows) finish() {
+	if !r.done {
+		r.done = true
+		r.release(r.Rows.Err())
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestStatementName(t *testing.T) {
+	name := statementName("vector_search", "SELECT 1")
+	if name != statementName("vector_search", "SELECT 1") {
+		t.Error("statementName is not stable")
+	}
+	if name == statementName("vector_search", "SELECT 2") || name == statementName("embed_text", "SELECT 1") {
+		t.Error("statementName should change with the shape and the SQL")
+	}
+	if len(name) > 63 {
+		t.Errorf("statement name %q is longer than an identifier", name)
+	}
+}
+
+func TestStatementCacheShapes(t *testing.T) {
+	c := NewStatementCache()
+	conn, other := &pgx.Conn{}, &pgx.Conn{}
+
+	for i := 0; i < MaxStatementsPerShape; i++ {
+		if evicted := c.add(conn, "vector_search", fmt.Sprintf("s%d", i)); evicted != "" {
+			t.Fatalf("add %d evicted %q", i, evicted)
+		}
+	}
+	// s0 is used again, so s1 is now the least recently used
+	if !c.use(conn, "vector_search", "s0") {
+		t.Fatal("s0 not found")
+	}
+	if evicted := c.add(conn, "vector_search", "new"); evicted != "s1" {
+		t.Errorf("evicted %q, want s1", evicted)
+	}
+	if c.use(conn, "embed_text", "s0") || c.use(other, "vector_search", "s0") {
+		t.Error("statements leaked to another shape or connection")
+	}
+	c.add(conn, "embed_text", "e0")
+	if got := c.Stats().Cached; got != MaxStatementsPerShape+1 {
+		t.Errorf("cached = %d, want %d", got, MaxStatementsPerShape+1)
+	}
+
+	c.forget(conn)
+	if got := c.Stats().Cached; got != 0 {
+		t.Errorf("cached after forget = %d, want 0", got)
+	}
+}
+
+func TestStatementCacheOrder(t *testing.T) {
+	c := NewStatementCache()
+	conn := &pgx.Conn{}
+	for _, name := range []string{"a", "b", "c"} {
+		c.add(conn, "shape", name)
+	}
+	c.use(conn, "shape", "a")
+	if got, want := c.conns[conn]["shape"], []string{"a", "c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestIsStatementInvalidated(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "0A000", Message: "cached plan must not change result type"}, true},
+		{fmt.Errorf("query failed: %w", &pgconn.PgError{Code: "26000", Message: `prepared statement "x" does not exist`}), true},
+		{&pgconn.PgError{Code: "0A000", Message: "unsupported feature"}, false},
+		{&pgconn.PgError{Code: "42P01", Message: `relation "t" does not exist`}, false},
+		{fmt.Errorf("connection reset"), false},
+	} {
+		if got := isStatementInvalidated(c.err); got != c.want {
+			t.Errorf("isStatementInvalidated(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+)
+
+// ErrUnknownTarget is returned for a database target that is not configured
+var ErrUnknownTarget = errors.New("unknown database target")
+
+// targetConnectAttempts and targetConnectDelay bound connecting to a named
+// target on its first use, which happens while a tool call waits
+const (
+	targetConnectAttempts = 2
+	targetConnectDelay    = 500 * time.Millisecond
+)
+
+// Targets holds the databases tools can be routed to by name. The default
+// target is the database the server connected to at startup; each named
+// target gets its own pool and circuit breaker, connected on first use.
+type Targets struct {
+	primary *Database
+	configs map[string]config.DatabaseConfig
+
+	mu  sync.Mutex
+	dbs map[string]*Database
+}
+
+// NewTargets creates the targets for primary and the named configs
+func NewTargets(primary *Database, configs map[string]config.DatabaseConfig) *Targets {
+	return &Targets{primary: primary, configs: configs, dbs: make(map[string]*Database)}
+}
+
+// Names returns the target names, the default target first
+func (t *Targets) Names() []string {
+	names := make([]string, 0, len(t.configs))
+	for name := range t.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{config.DefaultDatabaseTarget}, names...)
+}
+
+// Routed reports whether any target besides the default is configured
+func (t *Targets) Routed() bool {
+	return t != nil && len(t.configs) > 0
+}
+
+// Get returns the database of target name, connecting it if this is its
+// first use. "" names the default target. A failed connection is not kept,
+// so the next call tries again.
+func (t *Targets) Get(name string) (*Database, error) {
+	if name == "" || name == config.DefaultDatabaseTarget {
+		return t.primary, nil
+	}
+	cfg, ok := t.configs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s': configured targets are %s", ErrUnknownTarget, name, strings.Join(t.Names(), ", "))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if db, ok := t.dbs[name]; ok {
+		return db, nil
+	}
+	db := NewDatabase()
+	if err := db.ConnectWithRetry(&cfg, targetConnectAttempts, targetConnectDelay); err != nil {
+		return nil, fmt.Errorf("database target '%s': %w", name, err)
+	}
+	t.dbs[name] = db
+	return db, nil
+}
+
+// Connected returns the named targets connected so far
+func (t *Targets) Connected() map[string]*Database {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dbs := make(map[string]*Database, len(t.dbs))
+	for name, db := range t.dbs {
+		dbs[name] = db
+	}
+	return dbs
+}
+
+// Close closes the pools of the named targets. The default target is left
+// to its owner.
+func (t *Targets) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, db := range t.dbs {
+		db.Close()
+		delete(t.dbs, name)
+	}
+}
@@ -3,6 +3,7 @@ package logging
 import (
 	"io"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/neurondb/NeuronMCP/internal/config"
@@ -12,25 +13,30 @@ import (
 // Logger provides structured logging
 type Logger struct {
 	logger zerolog.Logger
-	level  zerolog.Level
+	// level is shared with child loggers so SetLevel applies to all of them
+	level *atomic.Int32
 }
 
-// NewLogger creates a new logger
-func NewLogger(cfg *config.LoggingConfig) *Logger {
-	// Parse level
-	var level zerolog.Level
-	switch cfg.Level {
+// parseLevel maps a configured level name to a zerolog level, defaulting to
+// info
+func parseLevel(name string) zerolog.Level {
+	switch name {
 	case "debug":
-		level = zerolog.DebugLevel
+		return zerolog.DebugLevel
 	case "info":
-		level = zerolog.InfoLevel
+		return zerolog.InfoLevel
 	case "warn":
-		level = zerolog.WarnLevel
+		return zerolog.WarnLevel
 	case "error":
-		level = zerolog.ErrorLevel
+		return zerolog.ErrorLevel
 	default:
-		level = zerolog.InfoLevel
+		return zerolog.InfoLevel
 	}
+}
+
+// NewLogger creates a new logger
+func NewLogger(cfg *config.LoggingConfig) *Logger {
+	level := parseLevel(cfg.Level)
 
 	// Determine output
 	var output io.Writer
@@ -59,12 +65,25 @@ func NewLogger(cfg *config.LoggingConfig) *Logger {
 		output = zerolog.ConsoleWriter{Out: output, TimeFormat: time.RFC3339}
 	}
 
-	logger := zerolog.New(output).With().Timestamp().Logger().Level(level)
+	// Levels are filtered in log so they can be changed while running
+	logger := zerolog.New(output).With().Timestamp().Logger()
 
-	return &Logger{
+	l := &Logger{
 		logger: logger,
-		level:  level,
+		level:  new(atomic.Int32),
 	}
+	l.level.Store(int32(level))
+	return l
+}
+
+// SetLevel changes the level of the logger and its children
+func (l *Logger) SetLevel(name string) {
+	l.level.Store(int32(parseLevel(name)))
+}
+
+// Level returns the name of the current level
+func (l *Logger) Level() string {
+	return zerolog.Level(l.level.Load()).String()
 }
 
 // Debug logs a debug message
@@ -95,7 +114,7 @@ func (l *Logger) Error(message string, err error, metadata map[string]interface{
 }
 
 func (l *Logger) log(level zerolog.Level, message string, metadata map[string]interface{}) {
-	if level < l.level {
+	if level < zerolog.Level(l.level.Load()) {
 		return
 	}
 
@@ -2,6 +2,7 @@ package builtin
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/neurondb/NeuronMCP/internal/logging"
@@ -10,18 +11,22 @@ import (
 
 // LoggingMiddleware logs requests and responses
 type LoggingMiddleware struct {
-	logger              *logging.Logger
-	enableRequestLogging  bool
-	enableResponseLogging bool
+	logger                *logging.Logger
+	enableRequestLogging  atomic.Bool
+	enableResponseLogging atomic.Bool
 }
 
 // NewLoggingMiddleware creates a new logging middleware
 func NewLoggingMiddleware(logger *logging.Logger, enableRequest, enableResponse bool) *LoggingMiddleware {
-	return &LoggingMiddleware{
-		logger:                logger,
-		enableRequestLogging:  enableRequest,
-		enableResponseLogging: enableResponse,
-	}
+	m := &LoggingMiddleware{logger: logger}
+	m.SetOptions(enableRequest, enableResponse)
+	return m
+}
+
+// SetOptions changes whether requests and responses are logged
+func (m *LoggingMiddleware) SetOptions(enableRequest, enableResponse bool) {
+	m.enableRequestLogging.Store(enableRequest)
+	m.enableResponseLogging.Store(enableResponse)
 }
 
 // Name returns the middleware name
@@ -43,7 +48,7 @@ func (m *LoggingMiddleware) Enabled() bool {
 func (m *LoggingMiddleware) Execute(ctx context.Context, req *middleware.MCPRequest, next middleware.Handler) (*middleware.MCPResponse, error) {
 	start := time.Now()
 
-	if m.enableRequestLogging {
+	if m.enableRequestLogging.Load() {
 		m.logger.Info("Request", map[string]interface{}{
 			"method":   req.Method,
 			"params":   req.Params,
@@ -63,7 +68,7 @@ func (m *LoggingMiddleware) Execute(ctx context.Context, req *middleware.MCPRequ
 		return nil, err
 	}
 
-	if m.enableResponseLogging {
+	if m.enableResponseLogging.Load() {
 		m.logger.Info("Response", map[string]interface{}{
 			"method":   req.Method,
 			"duration": duration,
@@ -3,6 +3,7 @@ package builtin
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/neurondb/NeuronMCP/internal/logging"
@@ -11,16 +12,25 @@ import (
 
 // TimeoutMiddleware adds timeout to requests
 type TimeoutMiddleware struct {
-	timeout time.Duration
+	timeout atomic.Int64 // time.Duration; 0 disables the middleware
 	logger  *logging.Logger
 }
 
 // NewTimeoutMiddleware creates a new timeout middleware
 func NewTimeoutMiddleware(timeout time.Duration, logger *logging.Logger) *TimeoutMiddleware {
-	return &TimeoutMiddleware{
-		timeout: timeout,
-		logger:  logger,
-	}
+	m := &TimeoutMiddleware{logger: logger}
+	m.SetTimeout(timeout)
+	return m
+}
+
+// SetTimeout changes the timeout of subsequent requests
+func (m *TimeoutMiddleware) SetTimeout(timeout time.Duration) {
+	m.timeout.Store(int64(timeout))
+}
+
+// Timeout returns the current request timeout
+func (m *TimeoutMiddleware) Timeout() time.Duration {
+	return time.Duration(m.timeout.Load())
 }
 
 // Name returns the middleware name
@@ -35,12 +45,13 @@ func (m *TimeoutMiddleware) Order() int {
 
 // Enabled returns whether the middleware is enabled
 func (m *TimeoutMiddleware) Enabled() bool {
-	return true
+	return m.Timeout() > 0
 }
 
 // Execute executes the middleware
 func (m *TimeoutMiddleware) Execute(ctx context.Context, req *middleware.MCPRequest, next middleware.Handler) (*middleware.MCPResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	timeout := m.Timeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	done := make(chan *middleware.MCPResponse, 1)
@@ -63,11 +74,11 @@ func (m *TimeoutMiddleware) Execute(ctx context.Context, req *middleware.MCPRequ
 	case <-ctx.Done():
 		m.logger.Warn("Request timeout", map[string]interface{}{
 			"method":  req.Method,
-			"timeout": m.timeout,
+			"timeout": timeout,
 		})
 		return &middleware.MCPResponse{
 			Content: []middleware.ContentBlock{
-				{Type: "text", Text: fmt.Sprintf("Request timeout after %v", m.timeout)},
+				{Type: "text", Text: fmt.Sprintf("Request timeout after %v", timeout)},
 			},
 			IsError: true,
 		}, nil
@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Engine holds the active policy and reloads it when the policy file changes.
+// An engine without a policy file allows every call.
+type Engine struct {
+	mu      sync.RWMutex
+	policy  *Policy
+	path    string
+	modTime time.Time
+	logger  *logging.Logger
+}
+
+// NewEngine creates an engine for the given policy file. An empty filename
+// yields a permissive engine; a file that cannot be loaded is an error so a
+// broken policy never silently opens up every tool.
+func NewEngine(filename string, logger *logging.Logger) (*Engine, error) {
+	e := &Engine{
+		policy: &Policy{},
+		path:   filename,
+		logger: logger,
+	}
+	if filename == "" {
+		return e, nil
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	p, err := LoadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	e.policy = p
+	e.modTime = info.ModTime()
+	return e, nil
+}
+
+// Policy returns the active policy
+func (e *Engine) Policy() *Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policy
+}
+
+// Path returns the policy file being watched, if any
+func (e *Engine) Path() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.path
+}
+
+// Reload loads filename, which may differ from the current policy file, and
+// makes it the active policy and the file watched. An empty filename
+// removes the policy. On error the current policy and file are kept.
+func (e *Engine) Reload(filename string) error {
+	p := &Policy{}
+	var modTime time.Time
+	if filename != "" {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if p, err = LoadFile(filename); err != nil {
+			return err
+		}
+		modTime = info.ModTime()
+	}
+
+	e.mu.Lock()
+	e.policy = p
+	e.path = filename
+	e.modTime = modTime
+	e.mu.Unlock()
+	return nil
+}
+
+// Evaluate evaluates a tool call against the active policy
+func (e *Engine) Evaluate(tool string, roles []string) Decision {
+	return e.Policy().Evaluate(tool, roles)
+}
+
+// Watch polls the policy file every interval and reloads it on change until
+// ctx is cancelled. A file that fails to load keeps the previous policy.
+// The file polled is whichever one the last Reload selected.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.reloadIfChanged()
+		}
+	}
+}
+
+func (e *Engine) reloadIfChanged() {
+	path := e.Path()
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		e.logger.Warn("Policy file not accessible, keeping current policy", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	e.mu.RLock()
+	unchanged := info.ModTime().Equal(e.modTime)
+	e.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	p, err := LoadFile(path)
+
+	e.mu.Lock()
+	if e.path != path {
+		// Reload switched files while this one was being read
+		e.mu.Unlock()
+		return
+	}
+	// Record the mod time even on failure so a broken file is reported once
+	e.modTime = info.ModTime()
+	if err == nil {
+		e.policy = p
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		e.logger.Warn("Failed to reload policy file, keeping current policy", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return
+	}
+	e.logger.Info("Reloaded tool policy", map[string]interface{}{
+		"path":       path,
+		"read_only":  p.ReadOnly,
+		"allow":      len(p.Allow),
+		"deny":       len(p.Deny),
+		"tool_roles": len(p.ToolRoles),
+		"redaction":  p.Redaction != nil && p.Redaction.Enabled,
+	})
+}
@@ -0,0 +1,221 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+)
+
+// DefaultWriteTools are the tool name patterns treated as mutating when a
+// policy does not list its own writeTools. Read-only mode denies them.
+var DefaultWriteTools = []string{
+	"create_*",
+	"drop_*",
+	"delete_*",
+	"train_*",
+	"tune_*",
+	"load_*",
+	"ingest_*",
+	"upsert_*",
+	"configure_*",
+	"automl",
+	"worker_management",
+	"manage_schema",
+	"extract_entities",
+	"record_*",
+	"copy_from",
+	"rebuild_*",
+	"vacuum_*",
+	"manage_embedding_column",
+	"generate_test_data",
+	"dedupe_table",
+	"cluster_vectors",
+	"sparse_embed_column",
+}
+
+// Policy controls which tools a client may call. Patterns use path.Match
+// glob syntax, e.g. "vector_*".
+type Policy struct {
+	// ReadOnly denies every tool matching WriteTools
+	ReadOnly bool `json:"readOnly"`
+	// Allow, when non-empty, is the exhaustive list of callable tools
+	Allow []string `json:"allow,omitempty"`
+	// Deny always wins over Allow and role grants
+	Deny []string `json:"deny,omitempty"`
+	// WriteTools overrides DefaultWriteTools
+	WriteTools []string `json:"writeTools,omitempty"`
+	// ToolRoles maps a tool pattern to the roles allowed to call matching
+	// tools; a caller needs at least one of the roles of every matching pattern
+	ToolRoles map[string][]string `json:"toolRoles,omitempty"`
+	// DefaultRoles are granted to every client. The clientInfo.name a
+	// client sends in initialize is its own claim, so it grants nothing.
+	DefaultRoles []string `json:"defaultRoles,omitempty"`
+	// QueryTagRoles maps a saved query permission tag to the roles allowed
+	// to run queries carrying it; a caller needs at least one of the roles
+	// of every listed tag. Tags without an entry are not restricted.
+	QueryTagRoles map[string][]string `json:"queryTagRoles,omitempty"`
+	// Redaction masks sensitive values in tool results
+	Redaction *RedactionPolicy `json:"redaction,omitempty"`
+
+	redactorOnce sync.Once
+	redactor     *Redactor
+}
+
+// Decision is the outcome of evaluating a tool call against a policy
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+}
+
+// LoadFile reads and validates a JSON policy file
+func LoadFile(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", filename, err)
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", filename, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", filename, err)
+	}
+	return &p, nil
+}
+
+// Validate checks that every pattern in the policy is well formed
+func (p *Policy) Validate() error {
+	check := func(field string, patterns []string) error {
+		for _, pattern := range patterns {
+			if pattern == "" {
+				return fmt.Errorf("%s contains an empty pattern", field)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s pattern %q is invalid: %w", field, pattern, err)
+			}
+		}
+		return nil
+	}
+	if err := check("allow", p.Allow); err != nil {
+		return err
+	}
+	if err := check("deny", p.Deny); err != nil {
+		return err
+	}
+	if err := check("writeTools", p.WriteTools); err != nil {
+		return err
+	}
+	for pattern, roles := range p.ToolRoles {
+		if err := check("toolRoles", []string{pattern}); err != nil {
+			return err
+		}
+		if len(roles) == 0 {
+			return fmt.Errorf("toolRoles pattern %q has no roles", pattern)
+		}
+	}
+	for tag, roles := range p.QueryTagRoles {
+		if tag == "" {
+			return fmt.Errorf("queryTagRoles contains an empty tag")
+		}
+		if len(roles) == 0 {
+			return fmt.Errorf("queryTagRoles tag %q has no roles", tag)
+		}
+	}
+	if _, err := p.Redaction.Compile(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsWriteTool reports whether a tool is considered mutating
+func (p *Policy) IsWriteTool(tool string) bool {
+	patterns := p.WriteTools
+	if len(patterns) == 0 {
+		patterns = DefaultWriteTools
+	}
+	_, ok := matchAny(patterns, tool)
+	return ok
+}
+
+// Evaluate decides whether a caller holding roles may call tool. Rules are
+// applied in order: deny list, allow list, read-only mode, role requirements.
+func (p *Policy) Evaluate(tool string, roles []string) Decision {
+	if pattern, ok := matchAny(p.Deny, tool); ok {
+		return Decision{Reason: fmt.Sprintf("tool '%s' is denied by policy", tool), Rule: "deny:" + pattern}
+	}
+
+	if len(p.Allow) > 0 {
+		if _, ok := matchAny(p.Allow, tool); !ok {
+			return Decision{Reason: fmt.Sprintf("tool '%s' is not in the policy allow list", tool), Rule: "allow"}
+		}
+	}
+
+	if p.ReadOnly && p.IsWriteTool(tool) {
+		return Decision{Reason: fmt.Sprintf("tool '%s' modifies data and the server is in read-only mode", tool), Rule: "readOnly"}
+	}
+
+	// Iterate in a stable order so the reported rule is deterministic
+	patterns := make([]string, 0, len(p.ToolRoles))
+	for pattern := range p.ToolRoles {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, tool); !matched {
+			continue
+		}
+		required := p.ToolRoles[pattern]
+		if !hasAnyRole(roles, required) {
+			return Decision{
+				Reason: fmt.Sprintf("tool '%s' requires one of roles %v, caller has %v", tool, required, roles),
+				Rule:   "toolRoles:" + pattern,
+			}
+		}
+	}
+
+	return Decision{Allowed: true}
+}
+
+// EvaluateQueryTags decides whether a caller holding roles may run a saved
+// query carrying tags
+func (p *Policy) EvaluateQueryTags(tags []string, roles []string) Decision {
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	for _, tag := range sorted {
+		required, ok := p.QueryTagRoles[tag]
+		if !ok {
+			continue
+		}
+		if !hasAnyRole(roles, required) {
+			return Decision{
+				Reason: fmt.Sprintf("saved queries tagged '%s' require one of roles %v, caller has %v", tag, required, roles),
+				Rule:   "queryTagRoles:" + tag,
+			}
+		}
+	}
+	return Decision{Allowed: true}
+}
+
+func matchAny(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+func hasAnyRole(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
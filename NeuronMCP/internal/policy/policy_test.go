@@ -0,0 +1,135 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+func TestEvaluate(t *testing.T) {
+	p := &Policy{
+		ReadOnly: true,
+		Allow:    []string{"vector_*", "create_*", "train_model", "postgresql_*"},
+		Deny:     []string{"postgresql_settings"},
+		ToolRoles: map[string][]string{
+			"train_*": {"ml", "admin"},
+		},
+	}
+
+	tests := []struct {
+		tool    string
+		roles   []string
+		allowed bool
+		rule    string
+	}{
+		{"vector_search", nil, true, ""},
+		{"postgresql_settings", []string{"admin"}, false, "deny:postgresql_settings"},
+		{"load_dataset", []string{"admin"}, false, "allow"},
+		{"create_hnsw_index", []string{"admin"}, false, "readOnly"},
+		{"train_model", []string{"reader"}, false, "readOnly"},
+	}
+	for _, tt := range tests {
+		d := p.Evaluate(tt.tool, tt.roles)
+		if d.Allowed != tt.allowed || d.Rule != tt.rule {
+			t.Errorf("Evaluate(%q, %v) = {%v %q}, want {%v %q}", tt.tool, tt.roles, d.Allowed, d.Rule, tt.allowed, tt.rule)
+		}
+	}
+
+	// Role requirements apply once read-only mode is off
+	p.ReadOnly = false
+	if d := p.Evaluate("train_model", []string{"reader"}); d.Allowed || d.Rule != "toolRoles:train_*" {
+		t.Errorf("train_model without ml role = %+v, want denied by toolRoles", d)
+	}
+	if d := p.Evaluate("train_model", []string{"reader", "ml"}); !d.Allowed {
+		t.Errorf("train_model with ml role = %+v, want allowed", d)
+	}
+}
+
+func TestReadOnlyDeniesDefaultWriteTools(t *testing.T) {
+	p := &Policy{ReadOnly: true}
+	for _, tool := range []string{"manage_embedding_column", "generate_test_data", "dedupe_table", "cluster_vectors", "sparse_embed_column"} {
+		if d := p.Evaluate(tool, []string{"admin"}); d.Allowed || d.Rule != "readOnly" {
+			t.Errorf("Evaluate(%q) in read-only mode = %+v, want denied by readOnly", tool, d)
+		}
+	}
+	if d := p.Evaluate("vector_search", nil); !d.Allowed {
+		t.Errorf("Evaluate(vector_search) in read-only mode = %+v, want allowed", d)
+	}
+}
+
+func TestEvaluateQueryTags(t *testing.T) {
+	p := &Policy{QueryTagRoles: map[string][]string{
+		"finance": {"analyst", "admin"},
+		"pii":     {"admin"},
+	}}
+
+	tests := []struct {
+		tags    []string
+		roles   []string
+		allowed bool
+		rule    string
+	}{
+		{nil, nil, true, ""},
+		{[]string{"marketing"}, nil, true, ""},
+		{[]string{"finance"}, []string{"analyst"}, true, ""},
+		{[]string{"pii", "finance"}, []string{"analyst"}, false, "queryTagRoles:pii"},
+		{[]string{"finance"}, []string{"reader"}, false, "queryTagRoles:finance"},
+	}
+	for _, tt := range tests {
+		d := p.EvaluateQueryTags(tt.tags, tt.roles)
+		if d.Allowed != tt.allowed || d.Rule != tt.rule {
+			t.Errorf("EvaluateQueryTags(%v, %v) = {%v %q}, want {%v %q}", tt.tags, tt.roles, d.Allowed, d.Rule, tt.allowed, tt.rule)
+		}
+	}
+}
+
+func TestEmptyPolicyAllowsEverything(t *testing.T) {
+	p := &Policy{}
+	for _, tool := range []string{"drop_index", "load_dataset", "vector_search"} {
+		if d := p.Evaluate(tool, nil); !d.Allowed {
+			t.Errorf("Evaluate(%q) = %+v, want allowed", tool, d)
+		}
+	}
+}
+
+func TestLoadFileRejectsInvalidPattern(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(filename, []byte(`{"deny": ["vector_["]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(filename); err == nil {
+		t.Fatal("LoadFile() with malformed pattern succeeded, want error")
+	}
+}
+
+func TestEngineReload(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(filename, []byte(`{"deny": ["drop_*"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logging.NewLogger(&config.LoggingConfig{Level: "error", Format: "json"})
+	e, err := NewEngine(filename, logger)
+	if err != nil {
+		t.Fatalf("NewEngine() error: %v", err)
+	}
+	if e.Evaluate("drop_index", nil).Allowed {
+		t.Fatal("drop_index allowed, want denied")
+	}
+
+	if err := os.WriteFile(filename, []byte(`{"readOnly": false}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Force a visible modification time change on coarse-grained filesystems
+	e.modTime = e.modTime.Add(-1)
+	e.reloadIfChanged()
+
+	if !e.Evaluate("drop_index", nil).Allowed {
+		t.Fatal("drop_index denied after reload, want allowed")
+	}
+}
@@ -0,0 +1,273 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in detectors of sensitive values in tool results
+const (
+	DetectorEmail      = "email"
+	DetectorPhone      = "phone"
+	DetectorCreditCard = "credit_card"
+)
+
+// builtinDetectors are the patterns of the built-in detectors, applied in
+// this order so a card number is not also taken for a phone number
+var builtinDetectors = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{DetectorCreditCard, regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)},
+	{DetectorEmail, regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{DetectorPhone, regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]\d{3}[ .-]\d{4}\b|\+\d{8,15}\b`)},
+}
+
+// redactedColumn replaces the values of masked columns
+const redactedColumn = "[REDACTED]"
+
+// RedactionPolicy masks sensitive values in tool results before they are
+// returned to the client. Strings are scanned with the detectors and
+// patterns; fields whose name matches Columns are masked whole. Column
+// patterns use path.Match glob syntax and ignore case.
+type RedactionPolicy struct {
+	Enabled bool `json:"enabled"`
+	// Detectors are the built-in detectors to run: email, phone and
+	// credit_card. Empty runs all of them.
+	Detectors []string `json:"detectors,omitempty"`
+	// Patterns maps a rule name to a regular expression whose matches are
+	// masked
+	Patterns map[string]string `json:"patterns,omitempty"`
+	// Columns are field name patterns whose values are masked
+	Columns []string `json:"columns,omitempty"`
+	// ExemptTools are tool name patterns whose results are not redacted
+	ExemptTools []string `json:"exemptTools,omitempty"`
+}
+
+// Redactor applies a compiled RedactionPolicy
+type Redactor struct {
+	rules       []redactionRule
+	columns     []string
+	exemptTools []string
+}
+
+type redactionRule struct {
+	name    string
+	pattern *regexp.Regexp
+	// luhn requires a match to pass the Luhn checksum, so only plausible
+	// card numbers are masked
+	luhn bool
+}
+
+// RedactionReport counts the values a redaction masked, by rule. Masked
+// columns are counted as "column:<name>".
+type RedactionReport map[string]int
+
+// Total returns the number of masked values
+func (r RedactionReport) Total() int {
+	total := 0
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+// Compile checks the policy and returns its redactor, or nil when the
+// policy is disabled
+func (p *RedactionPolicy) Compile() (*Redactor, error) {
+	if p == nil || !p.Enabled {
+		return nil, nil
+	}
+	r := &Redactor{}
+
+	detectors := p.Detectors
+	if len(detectors) == 0 {
+		detectors = []string{DetectorEmail, DetectorPhone, DetectorCreditCard}
+	}
+	enabled := make(map[string]bool, len(detectors))
+	for _, name := range detectors {
+		known := false
+		for _, d := range builtinDetectors {
+			known = known || d.name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("redaction detector %q is unknown: use %s, %s or %s", name, DetectorEmail, DetectorPhone, DetectorCreditCard)
+		}
+		enabled[name] = true
+	}
+	for _, d := range builtinDetectors {
+		if enabled[d.name] {
+			r.rules = append(r.rules, redactionRule{name: d.name, pattern: d.pattern, luhn: d.name == DetectorCreditCard})
+		}
+	}
+
+	names := make([]string, 0, len(p.Patterns))
+	for name := range p.Patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, "column:") {
+			return nil, fmt.Errorf("redaction pattern name %q is invalid", name)
+		}
+		pattern, err := regexp.Compile(p.Patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q is invalid: %w", name, err)
+		}
+		r.rules = append(r.rules, redactionRule{name: name, pattern: pattern})
+	}
+
+	for _, column := range p.Columns {
+		if column == "" {
+			return nil, fmt.Errorf("redaction columns contain an empty pattern")
+		}
+		column = strings.ToLower(column)
+		if _, err := path.Match(column, ""); err != nil {
+			return nil, fmt.Errorf("redaction column pattern %q is invalid: %w", column, err)
+		}
+		r.columns = append(r.columns, column)
+	}
+	for _, tool := range p.ExemptTools {
+		if tool == "" {
+			return nil, fmt.Errorf("redaction exemptTools contains an empty pattern")
+		}
+		if _, err := path.Match(tool, ""); err != nil {
+			return nil, fmt.Errorf("redaction exemptTools pattern %q is invalid: %w", tool, err)
+		}
+	}
+	r.exemptTools = p.ExemptTools
+	return r, nil
+}
+
+// Applies reports whether results of tool are redacted
+func (r *Redactor) Applies(tool string) bool {
+	if r == nil {
+		return false
+	}
+	_, exempt := matchAny(r.exemptTools, tool)
+	return !exempt
+}
+
+// Redact returns a copy of v with sensitive values masked, and what was
+// masked. Maps, slices and strings are walked; other values are checked
+// through their JSON encoding and replaced by its redacted form only when
+// something was masked, so untouched values keep their types.
+func (r *Redactor) Redact(v interface{}) (interface{}, RedactionReport) {
+	report := RedactionReport{}
+	return r.redactValue(v, report), report
+}
+
+// RedactString masks the sensitive values of s
+func (r *Redactor) RedactString(s string, report RedactionReport) string {
+	for _, rule := range r.rules {
+		s = rule.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.luhn && !luhnValid(match) {
+				return match
+			}
+			report[rule.name]++
+			return "[REDACTED:" + rule.name + "]"
+		})
+	}
+	return s
+}
+
+func (r *Redactor) redactValue(v interface{}, report RedactionReport) interface{} {
+	switch t := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case string:
+		return r.RedactString(t, report)
+	case []string:
+		redacted := make([]string, len(t))
+		for i, s := range t {
+			redacted[i] = r.RedactString(s, report)
+		}
+		return redacted
+	case map[string]interface{}:
+		return r.redactMap(t, report)
+	case []map[string]interface{}:
+		redacted := make([]map[string]interface{}, len(t))
+		for i, m := range t {
+			redacted[i] = r.redactMap(m, report)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(t))
+		for i, item := range t {
+			redacted[i] = r.redactValue(item, report)
+		}
+		return redacted
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return v
+	}
+	inner := RedactionReport{}
+	redacted := r.redactValue(generic, inner)
+	if len(inner) == 0 {
+		return v
+	}
+	for rule, n := range inner {
+		report[rule] += n
+	}
+	return redacted
+}
+
+func (r *Redactor) redactMap(m map[string]interface{}, report RedactionReport) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if value != nil && r.maskedColumn(key) {
+			redacted[key] = redactedColumn
+			report["column:"+key]++
+			continue
+		}
+		redacted[key] = r.redactValue(value, report)
+	}
+	return redacted
+}
+
+func (r *Redactor) maskedColumn(name string) bool {
+	_, ok := matchAny(r.columns, strings.ToLower(name))
+	return ok
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum
+func luhnValid(s string) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// Redactor returns the redactor of the policy's redaction settings, or nil
+// when redaction is off. The settings are compiled on first use; policies
+// are replaced, not modified, on reload.
+func (p *Policy) Redactor() *Redactor {
+	p.redactorOnce.Do(func() {
+		// Validate rejects invalid settings when a policy is loaded
+		p.redactor, _ = p.Redaction.Compile()
+	})
+	return p.redactor
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactString(t *testing.T) {
+	r, err := (&RedactionPolicy{Enabled: true}).Compile()
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"contact jane.doe@example.com today", "contact [REDACTED:email] today"},
+		{"call (555) 123-4567 or +1 555.123.4567", "call [REDACTED:phone] or [REDACTED:phone]"},
+		{"card 4111 1111 1111 1111 on file", "card [REDACTED:credit_card] on file"},
+		// Fails the Luhn check, so it is not taken for a card number
+		{"order 1234567890123", "order 1234567890123"},
+		{"id 42, score 0.93", "id 42, score 0.93"},
+	}
+	for _, tt := range tests {
+		report := RedactionReport{}
+		if got := r.RedactString(tt.in, report); got != tt.want {
+			t.Errorf("RedactString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactWalksResults(t *testing.T) {
+	r, err := (&RedactionPolicy{
+		Enabled:   true,
+		Detectors: []string{DetectorEmail},
+		Patterns:  map[string]string{"ssn": `\b\d{3}-\d{2}-\d{4}\b`},
+		Columns:   []string{"*password*", "SSN"},
+	}).Compile()
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	rows := []map[string]interface{}{
+		{"id": 1, "email": "a@example.com", "db_password": "hunter2", "note": "ssn 123-45-6789"},
+		{"id": 2, "email": nil, "ssn": "987-65-4321", "tags": []string{"b@example.org"}},
+	}
+	redacted, report := r.Redact(map[string]interface{}{"rows": rows, "count": 2})
+
+	out := redacted.(map[string]interface{})
+	got := out["rows"].([]map[string]interface{})
+	if got[0]["id"] != 1 || out["count"] != 2 {
+		t.Errorf("non-string values changed: %v", out)
+	}
+	if got[0]["email"] != "[REDACTED:email]" || got[0]["db_password"] != "[REDACTED]" || got[0]["note"] != "ssn [REDACTED:ssn]" {
+		t.Errorf("row 0 = %v", got[0])
+	}
+	if got[1]["email"] != nil || got[1]["ssn"] != "[REDACTED]" || got[1]["tags"].([]string)[0] != "[REDACTED:email]" {
+		t.Errorf("row 1 = %v", got[1])
+	}
+	if rows[0]["email"] != "a@example.com" {
+		t.Error("Redact modified its input")
+	}
+	want := RedactionReport{"email": 2, "ssn": 1, "column:db_password": 1, "column:ssn": 1}
+	if len(report) != len(want) || report.Total() != 5 {
+		t.Errorf("report = %v, want %v", report, want)
+	}
+	for rule, n := range want {
+		if report[rule] != n {
+			t.Errorf("report[%q] = %d, want %d", rule, report[rule], n)
+		}
+	}
+}
+
+func TestRedactStructs(t *testing.T) {
+	r, _ := (&RedactionPolicy{Enabled: true}).Compile()
+	type hit struct {
+		ID   int    `json:"id"`
+		Text string `json:"text"`
+	}
+
+	clean := []hit{{1, "nothing to hide"}}
+	if got, report := r.Redact(clean); report.Total() != 0 {
+		t.Errorf("Redact(clean) masked %v", report)
+	} else if _, ok := got.([]hit); !ok {
+		t.Errorf("Redact(clean) changed the type to %T", got)
+	}
+
+	got, report := r.Redact([]hit{{1, "mail x@example.com"}})
+	if report["email"] != 1 || !strings.Contains(got.([]interface{})[0].(map[string]interface{})["text"].(string), "[REDACTED:email]") {
+		t.Errorf("Redact(struct) = %v, %v", got, report)
+	}
+}
+
+func TestRedactionCompile(t *testing.T) {
+	if r, err := (*RedactionPolicy)(nil).Compile(); r != nil || err != nil {
+		t.Errorf("nil policy = %v, %v", r, err)
+	}
+	if r, err := (&RedactionPolicy{Columns: []string{"["}}).Compile(); r != nil || err != nil {
+		t.Errorf("disabled policy = %v, %v", r, err)
+	}
+
+	for _, p := range []RedactionPolicy{
+		{Enabled: true, Detectors: []string{"ssn"}},
+		{Enabled: true, Patterns: map[string]string{"bad": "("}},
+		{Enabled: true, Patterns: map[string]string{"column:x": "x"}},
+		{Enabled: true, Columns: []string{"["}},
+		{Enabled: true, ExemptTools: []string{""}},
+	} {
+		if _, err := p.Compile(); err == nil {
+			t.Errorf("Compile(%+v) succeeded, want an error", p)
+		}
+	}
+
+	bad := &Policy{Redaction: &RedactionPolicy{Enabled: true, Detectors: []string{"ssn"}}}
+	if err := bad.Validate(); err == nil {
+		t.Error("Validate accepted an unknown detector")
+	}
+}
+
+func TestRedactorApplies(t *testing.T) {
+	var none *Redactor
+	if none.Applies("vector_search") {
+		t.Error("nil redactor applies")
+	}
+	p := &Policy{Redaction: &RedactionPolicy{Enabled: true, ExemptTools: []string{"postgresql_*"}}}
+	r := p.Redactor()
+	if !r.Applies("vector_search") || r.Applies("postgresql_stats") {
+		t.Error("exemptTools not honoured")
+	}
+	if (&Policy{}).Redactor() != nil {
+		t.Error("policy without redaction returned a redactor")
+	}
+}
@@ -3,6 +3,7 @@ package resources
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/neurondb/NeuronMCP/internal/database"
@@ -79,6 +80,7 @@ func scanRowToMap(rows pgx.Rows) (map[string]interface{}, error) {
 type Manager struct {
 	resources map[string]Resource
 	db        *database.Database
+	results   *ResultStore
 }
 
 // NewManager creates a new resource manager
@@ -105,8 +107,29 @@ func (m *Manager) Register(resource Resource) {
 	m.resources[resource.URI()] = resource
 }
 
+// SetResultStore exposes the results in store as resources
+func (m *Manager) SetResultStore(store *ResultStore) {
+	m.results = store
+}
+
 // HandleResource handles a resource request
 func (m *Manager) HandleResource(ctx context.Context, uri string) (*ReadResourceResponse, error) {
+	if m.results != nil && strings.HasPrefix(uri, ResultURIPrefix) {
+		data, err := m.results.Read(uri)
+		if err != nil {
+			return nil, err
+		}
+		return &ReadResourceResponse{
+			Contents: []ResourceContent{
+				{URI: uri, MimeType: "application/json", Text: string(data)},
+			},
+		}, nil
+	}
+
+	if strings.HasPrefix(uri, RowURIPrefix) {
+		return m.readRowResource(ctx, uri)
+	}
+
 	resource, exists := m.resources[uri]
 	if !exists {
 		return nil, &ResourceNotFoundError{URI: uri}
@@ -144,9 +167,18 @@ func (m *Manager) ListResources() []ResourceDefinition {
 			MimeType:    resource.MimeType(),
 		})
 	}
+	if m.results != nil {
+		definitions = append(definitions, m.results.List()...)
+	}
 	return definitions
 }
 
+// ListResourceTemplates returns the templates of resources that are read
+// by URI rather than listed
+func (m *Manager) ListResourceTemplates() []ResourceTemplateDefinition {
+	return []ResourceTemplateDefinition{rowTemplate}
+}
+
 // ResourceDefinition represents a resource definition
 type ResourceDefinition struct {
 	URI         string `json:"uri"`
@@ -162,9 +194,10 @@ type ReadResourceResponse struct {
 
 // ResourceContent represents resource content
 type ResourceContent struct {
-	URI      string `json:"uri"`
-	MimeType string `json:"mimeType"`
-	Text     string `json:"text"`
+	URI      string                 `json:"uri"`
+	MimeType string                 `json:"mimeType"`
+	Text     string                 `json:"text"`
+	Meta     map[string]interface{} `json:"_meta,omitempty"`
 }
 
 // ResourceNotFoundError is returned when a resource is not found
@@ -0,0 +1,134 @@
+package resources
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultURIPrefix is the URI prefix of tool results spilled to the result store
+const ResultURIPrefix = "neurondb://results/"
+
+// ResultStore keeps tool results too large to return inline as temporary
+// files, exposed as resources until they expire
+type ResultStore struct {
+	dir     string
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*storedResult
+}
+
+type storedResult struct {
+	path      string
+	toolName  string
+	size      int
+	createdAt time.Time
+}
+
+// NewResultStore creates a store writing to dir. Results are removed ttl
+// after they were stored.
+func NewResultStore(dir string, ttl time.Duration) (*ResultStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create result directory %s: %w", dir, err)
+	}
+	return &ResultStore{
+		dir:     dir,
+		ttl:     ttl,
+		entries: make(map[string]*storedResult),
+	}, nil
+}
+
+// Save stores data produced by toolName and returns its resource URI
+func (s *ResultStore) Save(toolName string, data []byte) (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate result id: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+	path := filepath.Join(s.dir, id+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write result file %s: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeLocked(time.Now())
+	s.entries[id] = &storedResult{
+		path:      path,
+		toolName:  toolName,
+		size:      len(data),
+		createdAt: time.Now(),
+	}
+	return ResultURIPrefix + id, nil
+}
+
+// Read returns the stored result for uri
+func (s *ResultStore) Read(uri string) ([]byte, error) {
+	id := strings.TrimPrefix(uri, ResultURIPrefix)
+
+	s.mu.Lock()
+	s.purgeLocked(time.Now())
+	entry, ok := s.entries[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, &ResourceNotFoundError{URI: uri}
+	}
+
+	data, err := os.ReadFile(entry.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result file %s: %w", entry.path, err)
+	}
+	return data, nil
+}
+
+// List returns the definitions of the stored results, oldest first
+func (s *ResultStore) List() []ResourceDefinition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeLocked(time.Now())
+
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return s.entries[ids[i]].createdAt.Before(s.entries[ids[j]].createdAt)
+	})
+
+	definitions := make([]ResourceDefinition, 0, len(ids))
+	for _, id := range ids {
+		entry := s.entries[id]
+		definitions = append(definitions, ResourceDefinition{
+			URI:         ResultURIPrefix + id,
+			Name:        fmt.Sprintf("Result of %s", entry.toolName),
+			Description: fmt.Sprintf("Full %d byte result of %s at %s, available until %s", entry.size, entry.toolName, entry.createdAt.Format(time.RFC3339), entry.createdAt.Add(s.ttl).Format(time.RFC3339)),
+			MimeType:    "application/json",
+		})
+	}
+	return definitions
+}
+
+// Close removes every stored result
+func (s *ResultStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, entry := range s.entries {
+		os.Remove(entry.path)
+		delete(s.entries, id)
+	}
+}
+
+func (s *ResultStore) purgeLocked(now time.Time) {
+	for id, entry := range s.entries {
+		if now.Sub(entry.createdAt) > s.ttl {
+			os.Remove(entry.path)
+			delete(s.entries, id)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+// RowURIPrefix is the URI prefix of table rows read as resources
+const RowURIPrefix = "neurondb://table/"
+
+// RowURITemplate is the RFC 6570 template of row resources
+const RowURITemplate = "neurondb://table/{schema}/{table}/row/{id}{?format,column,key,offset,length}"
+
+// MaxRowResourceBytes is the most bytes one read of a row returns; larger
+// rows are read in ranges
+const MaxRowResourceBytes = 1 << 20
+
+// defaultRowTextColumn is the column read by format=text when no column is
+// given, the text column of tables written by ingest_document and
+// upsert_embeddings
+const defaultRowTextColumn = "content"
+
+// Formats of a row resource
+const (
+	rowFormatJSON = "json"
+	rowFormatText = "text"
+)
+
+// rowRef is a parsed row resource URI
+type rowRef struct {
+	schema string
+	table  string
+	id     string
+	format string
+	column string
+	key    string
+	offset int
+	length int
+}
+
+// ResourceTemplateDefinition represents a resource template definition
+type ResourceTemplateDefinition struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+// rowTemplate describes the row resources
+var rowTemplate = ResourceTemplateDefinition{
+	URITemplate: RowURITemplate,
+	Name:        "Table Row",
+	Description: "One row of a table, found by its primary key or by the unique column named in key. " +
+		"format=json (the default) returns the row as a JSON object; format=text returns the text of column (default content). " +
+		"Reads return at most 1 MiB; offset and length select a byte range of larger rows, and _meta.next_uri reads the next range.",
+	MimeType: "application/json",
+}
+
+// parseRowURI parses a row resource URI. Path segments are percent-decoded,
+// so IDs containing a slash can be addressed.
+func parseRowURI(uri string) (*rowRef, error) {
+	rest := strings.TrimPrefix(uri, RowURIPrefix)
+	path, rawQuery, _ := strings.Cut(rest, "?")
+	segments := strings.Split(path, "/")
+	if len(segments) != 4 || segments[2] != "row" {
+		return nil, fmt.Errorf("invalid row URI %s: expected %s", uri, "neurondb://table/{schema}/{table}/row/{id}")
+	}
+	ref := &rowRef{format: rowFormatJSON, length: MaxRowResourceBytes}
+	for i, dst := range []*string{&ref.schema, &ref.table, nil, &ref.id} {
+		if dst == nil {
+			continue
+		}
+		value, err := url.PathUnescape(segments[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid row URI %s: %w", uri, err)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("invalid row URI %s: empty path segment", uri)
+		}
+		*dst = value
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid row URI %s: %w", uri, err)
+	}
+	for name := range query {
+		switch name {
+		case "format", "column", "key", "offset", "length":
+		default:
+			return nil, fmt.Errorf("invalid row URI %s: unknown parameter %s", uri, name)
+		}
+	}
+	if format := query.Get("format"); format != "" {
+		if format != rowFormatJSON && format != rowFormatText {
+			return nil, fmt.Errorf("invalid row URI %s: format must be json or text", uri)
+		}
+		ref.format = format
+	}
+	ref.column = query.Get("column")
+	if ref.column != "" && ref.format != rowFormatText {
+		return nil, fmt.Errorf("invalid row URI %s: column requires format=text", uri)
+	}
+	if ref.format == rowFormatText && ref.column == "" {
+		ref.column = defaultRowTextColumn
+	}
+	ref.key = query.Get("key")
+	if v := query.Get("offset"); v != "" {
+		if ref.offset, err = strconv.Atoi(v); err != nil || ref.offset < 0 {
+			return nil, fmt.Errorf("invalid row URI %s: offset must be a non-negative integer", uri)
+		}
+	}
+	if v := query.Get("length"); v != "" {
+		if ref.length, err = strconv.Atoi(v); err != nil || ref.length < 1 || ref.length > MaxRowResourceBytes {
+			return nil, fmt.Errorf("invalid row URI %s: length must be between 1 and %d", uri, MaxRowResourceBytes)
+		}
+	}
+	return ref, nil
+}
+
+// mimeType returns the MIME type of the row's content
+func (ref *rowRef) mimeType() string {
+	if ref.format == rowFormatText {
+		return "text/plain"
+	}
+	return "application/json"
+}
+
+// rowKeyQuery returns the primary key column of a table and its type;
+// rowColumnTypeQuery returns the type of a named column
+const (
+	rowKeyQuery = `SELECT a.attname::text, format_type(a.atttypid, a.atttypmod)
+		FROM pg_index i JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = to_regclass($1) AND i.indisprimary`
+	rowColumnTypeQuery = `SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attname = $2 AND attnum > 0 AND NOT attisdropped`
+)
+
+// readRow returns the JSON of a row, or the text of one of its columns
+func readRow(ctx context.Context, db *database.Database, ref *rowRef) (string, error) {
+	table := pgx.Identifier{ref.schema, ref.table}.Sanitize()
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		return "", fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	if !exists {
+		return "", fmt.Errorf("table %s does not exist", table)
+	}
+
+	key, keyType := ref.key, ""
+	if key == "" {
+		rows, err := db.Query(ctx, rowKeyQuery, table)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up the primary key of %s: %w", table, err)
+		}
+		var columns, types []string
+		for rows.Next() {
+			var column, columnType string
+			if err := rows.Scan(&column, &columnType); err != nil {
+				rows.Close()
+				return "", err
+			}
+			columns = append(columns, column)
+			types = append(types, columnType)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		if len(columns) != 1 {
+			return "", fmt.Errorf("table %s has no single-column primary key; name a unique column with key", table)
+		}
+		key, keyType = columns[0], types[0]
+	}
+	if keyType == "" {
+		err := db.QueryRow(ctx, rowColumnTypeQuery, table, key).Scan(&keyType)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("column %s does not exist in %s", key, table)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to look up column %s of %s: %w", key, table, err)
+		}
+	}
+
+	selected := "to_jsonb(t)::text"
+	if ref.format == rowFormatText {
+		selected = "t." + pgx.Identifier{ref.column}.Sanitize() + "::text"
+	}
+	// keyType comes from format_type, so it is a valid type name; the ID is
+	// cast through text so any key type can be matched
+	query := fmt.Sprintf("SELECT %s FROM %s AS t WHERE t.%s = $1::text::%s LIMIT 2",
+		selected, table, pgx.Identifier{key}.Sanitize(), keyType)
+	rows, err := db.Query(ctx, query, ref.id)
+	if err != nil {
+		return "", fmt.Errorf("failed to read row %s of %s: %w", ref.id, table, err)
+	}
+	defer rows.Close()
+	var content *string
+	found := 0
+	for rows.Next() {
+		found++
+		if err := rows.Scan(&content); err != nil {
+			return "", err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read row %s of %s: %w", ref.id, table, err)
+	}
+	switch {
+	case found == 0:
+		return "", &ResourceNotFoundError{URI: fmt.Sprintf("%s%s/%s/row/%s", RowURIPrefix, ref.schema, ref.table, ref.id)}
+	case found > 1:
+		return "", fmt.Errorf("%s = %s matches more than one row of %s; key must name a unique column", key, ref.id, table)
+	}
+	if content == nil {
+		return "", nil
+	}
+	return *content, nil
+}
+
+// sliceContent returns the bytes of content in [offset, offset+length),
+// moving the end back to a UTF-8 character boundary so no character is
+// split, and the offset where the next range starts
+func sliceContent(content string, offset, length int) (string, int) {
+	if offset >= len(content) {
+		return "", len(content)
+	}
+	end := offset + length
+	if end >= len(content) {
+		return content[offset:], len(content)
+	}
+	for end > offset && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	if end == offset {
+		// length is shorter than the character at offset
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		end = offset + size
+	}
+	return content[offset:end], end
+}
+
+// rangeURI returns uri with its offset parameter set to offset
+func rangeURI(uri string, offset int) string {
+	path, rawQuery, _ := strings.Cut(uri, "?")
+	query, _ := url.ParseQuery(rawQuery)
+	query.Set("offset", strconv.Itoa(offset))
+	return path + "?" + query.Encode()
+}
+
+// readRowResource reads the range of the row uri names
+func (m *Manager) readRowResource(ctx context.Context, uri string) (*ReadResourceResponse, error) {
+	ref, err := parseRowURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	content, err := readRow(ctx, m.db, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	text, next := sliceContent(content, ref.offset, ref.length)
+	truncated := next < len(content)
+	meta := map[string]interface{}{
+		"offset":      ref.offset,
+		"length":      len(text),
+		"total_bytes": len(content),
+		"truncated":   truncated,
+	}
+	if truncated {
+		meta["next_uri"] = rangeURI(uri, next)
+	}
+	return &ReadResourceResponse{
+		Contents: []ResourceContent{
+			{URI: uri, MimeType: ref.mimeType(), Text: text, Meta: meta},
+		},
+	}, nil
+}
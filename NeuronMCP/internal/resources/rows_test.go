@@ -0,0 +1,76 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRowURI(t *testing.T) {
+	ref, err := parseRowURI("neurondb://table/public/docs/row/42")
+	if err != nil {
+		t.Fatalf("parseRowURI: %v", err)
+	}
+	if ref.schema != "public" || ref.table != "docs" || ref.id != "42" || ref.format != rowFormatJSON ||
+		ref.offset != 0 || ref.length != MaxRowResourceBytes || ref.mimeType() != "application/json" {
+		t.Errorf("parseRowURI = %+v", ref)
+	}
+
+	ref, err = parseRowURI("neurondb://table/my%20schema/docs/row/a%2Fb?format=text&key=slug&offset=10&length=100")
+	if err != nil {
+		t.Fatalf("parseRowURI: %v", err)
+	}
+	if ref.schema != "my schema" || ref.id != "a/b" || ref.column != defaultRowTextColumn || ref.key != "slug" ||
+		ref.offset != 10 || ref.length != 100 || ref.mimeType() != "text/plain" {
+		t.Errorf("parseRowURI = %+v", ref)
+	}
+
+	for uri, want := range map[string]string{
+		"neurondb://table/public/docs/42":                      "expected",
+		"neurondb://table/public/docs/rows/42":                 "expected",
+		"neurondb://table/public//row/42":                      "empty path segment",
+		"neurondb://table/public/docs/row/42?format=xml":       "format must be json or text",
+		"neurondb://table/public/docs/row/42?column=body":      "column requires format=text",
+		"neurondb://table/public/docs/row/42?offset=-1":        "offset",
+		"neurondb://table/public/docs/row/42?length=0":         "length",
+		"neurondb://table/public/docs/row/42?length=104857600": "length",
+		"neurondb://table/public/docs/row/42?limit=5":          "unknown parameter limit",
+	} {
+		_, err := parseRowURI(uri)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseRowURI(%q) = %v, want an error about %q", uri, err, want)
+		}
+	}
+}
+
+func TestSliceContent(t *testing.T) {
+	cases := []struct {
+		content        string
+		offset, length int
+		want           string
+		next           int
+	}{
+		{"hello world", 0, 5, "hello", 5},
+		{"hello world", 6, 100, "world", 11},
+		{"hello", 10, 5, "", 5},
+		// "é" is two bytes; a range is not allowed to split it
+		{"aéb", 0, 2, "a", 1},
+		{"aéb", 1, 1, "é", 3},
+	}
+	for _, c := range cases {
+		got, next := sliceContent(c.content, c.offset, c.length)
+		if got != c.want || next != c.next {
+			t.Errorf("sliceContent(%q, %d, %d) = %q, %d; want %q, %d", c.content, c.offset, c.length, got, next, c.want, c.next)
+		}
+	}
+}
+
+func TestRangeURI(t *testing.T) {
+	got := rangeURI("neurondb://table/public/docs/row/42?format=text&offset=0", 1024)
+	if got != "neurondb://table/public/docs/row/42?format=text&offset=1024" {
+		t.Errorf("rangeURI = %q", got)
+	}
+	got = rangeURI("neurondb://table/public/docs/row/42", 10)
+	if got != "neurondb://table/public/docs/row/42?offset=10" {
+		t.Errorf("rangeURI = %q", got)
+	}
+}
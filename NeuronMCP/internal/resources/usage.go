@@ -0,0 +1,494 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageURI is the URI of the tool usage analytics resource
+const UsageURI = "neurondb://tool-usage"
+
+const (
+	// usageBucket is the time span one aggregate covers
+	usageBucket = 10 * time.Minute
+	// usageRetention is how long aggregates are kept
+	usageRetention = 7 * 24 * time.Hour
+	// maxUsageKeys bounds the argument patterns and error codes counted per
+	// tool and bucket; further ones are counted under usageOtherKey
+	maxUsageKeys  = 20
+	usageOtherKey = "(other)"
+	// usageTopN is how many tools, patterns and error codes a summary lists
+	usageTopN = 5
+)
+
+// usageWindows are the time windows the usage resource summarizes
+var usageWindows = []struct {
+	name string
+	span time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// Thresholds of the usage recommendations
+const (
+	recommendMinCalls     = 5
+	recommendErrorRate    = 0.25
+	recommendSlowLatency  = 5000.0 // milliseconds
+	recommendUnusedWindow = "7d"
+)
+
+// UsageStats aggregates tool calls in ten-minute buckets kept for a week. A
+// bucket holds per-tool call and error counts, error codes, latency sums and
+// maxima, and how often each set of argument names was sent. Argument values
+// are never kept.
+type UsageStats struct {
+	mu      sync.Mutex
+	buckets map[int64]map[string]*toolUsage
+	dirty   bool
+}
+
+// toolUsage is the aggregate of one tool's calls in one bucket
+type toolUsage struct {
+	Calls        int64            `json:"calls"`
+	Errors       int64            `json:"errors"`
+	LatencyMsSum float64          `json:"latency_ms_sum"`
+	LatencyMsMax float64          `json:"latency_ms_max"`
+	ErrorCodes   map[string]int64 `json:"error_codes,omitempty"`
+	Patterns     map[string]int64 `json:"patterns,omitempty"`
+}
+
+// NewUsageStats creates empty usage statistics
+func NewUsageStats() *UsageStats {
+	return &UsageStats{buckets: make(map[int64]map[string]*toolUsage)}
+}
+
+// Record adds a call of tool made at at that took latency. errorCode is
+// empty for successful calls.
+func (u *UsageStats) Record(tool string, arguments map[string]interface{}, latency time.Duration, errorCode string, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := at.Unix() / int64(usageBucket/time.Second)
+	tools, ok := u.buckets[key]
+	if !ok {
+		tools = make(map[string]*toolUsage)
+		u.buckets[key] = tools
+		u.prune(at)
+	}
+	usage, ok := tools[tool]
+	if !ok {
+		usage = &toolUsage{}
+		tools[tool] = usage
+	}
+
+	ms := float64(latency) / float64(time.Millisecond)
+	usage.Calls++
+	usage.LatencyMsSum += ms
+	if ms > usage.LatencyMsMax {
+		usage.LatencyMsMax = ms
+	}
+	if errorCode != "" {
+		usage.Errors++
+		usage.ErrorCodes = countKey(usage.ErrorCodes, errorCode)
+	}
+	usage.Patterns = countKey(usage.Patterns, argumentPattern(arguments))
+	u.dirty = true
+}
+
+// prune drops buckets older than usageRetention
+func (u *UsageStats) prune(now time.Time) {
+	oldest := now.Add(-usageRetention).Unix() / int64(usageBucket/time.Second)
+	for key := range u.buckets {
+		if key < oldest {
+			delete(u.buckets, key)
+		}
+	}
+}
+
+// countKey increments key in counts, counting it under usageOtherKey once
+// counts holds maxUsageKeys keys
+func countKey(counts map[string]int64, key string) map[string]int64 {
+	return addCount(counts, key, 1)
+}
+
+// argumentPattern names the arguments of a call, sorted, as "a,b,c"
+func argumentPattern(arguments map[string]interface{}) string {
+	if len(arguments) == 0 {
+		return "(none)"
+	}
+	names := make([]string, 0, len(arguments))
+	for name := range arguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// UsageSummary sums the calls of one time window
+type UsageSummary struct {
+	Window string    `json:"window"`
+	Since  time.Time `json:"since"`
+	Calls  int64     `json:"calls"`
+	Errors int64     `json:"errors"`
+	// TopTools are the most called tools
+	TopTools []ToolUsageSummary `json:"top_tools"`
+	// ErrorProneTools are the tools with the highest error rate among those
+	// that failed at least once
+	ErrorProneTools []ToolUsageSummary `json:"error_prone_tools"`
+	// SlowestTools are the tools with the highest average latency
+	SlowestTools []ToolUsageSummary `json:"slowest_tools"`
+	// Tools holds every tool called in the window, by name
+	Tools map[string]ToolUsageSummary `json:"-"`
+}
+
+// ToolUsageSummary sums one tool's calls in a time window
+type ToolUsageSummary struct {
+	Name         string      `json:"name"`
+	Calls        int64       `json:"calls"`
+	Errors       int64       `json:"errors"`
+	ErrorRate    float64     `json:"error_rate"`
+	AvgLatencyMs float64     `json:"avg_latency_ms"`
+	MaxLatencyMs float64     `json:"max_latency_ms"`
+	ErrorCodes   []CountedAs `json:"error_codes,omitempty"`
+	Patterns     []CountedAs `json:"argument_patterns,omitempty"`
+}
+
+// CountedAs is a value and how often it was seen
+type CountedAs struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Summary sums the calls made in the span before now
+func (u *UsageStats) Summary(name string, span time.Duration, now time.Time) *UsageSummary {
+	since := now.Add(-span)
+	first := since.Unix() / int64(usageBucket/time.Second)
+	last := now.Unix() / int64(usageBucket/time.Second)
+
+	totals := make(map[string]*toolUsage)
+	u.mu.Lock()
+	for key, tools := range u.buckets {
+		if key < first || key > last {
+			continue
+		}
+		for tool, usage := range tools {
+			total, ok := totals[tool]
+			if !ok {
+				total = &toolUsage{ErrorCodes: map[string]int64{}, Patterns: map[string]int64{}}
+				totals[tool] = total
+			}
+			total.Calls += usage.Calls
+			total.Errors += usage.Errors
+			total.LatencyMsSum += usage.LatencyMsSum
+			if usage.LatencyMsMax > total.LatencyMsMax {
+				total.LatencyMsMax = usage.LatencyMsMax
+			}
+			for code, n := range usage.ErrorCodes {
+				total.ErrorCodes[code] += n
+			}
+			for pattern, n := range usage.Patterns {
+				total.Patterns[pattern] += n
+			}
+		}
+	}
+	u.mu.Unlock()
+
+	summary := &UsageSummary{Window: name, Since: since, Tools: make(map[string]ToolUsageSummary, len(totals))}
+	all := make([]ToolUsageSummary, 0, len(totals))
+	for tool, total := range totals {
+		s := ToolUsageSummary{
+			Name:         tool,
+			Calls:        total.Calls,
+			Errors:       total.Errors,
+			ErrorRate:    float64(total.Errors) / float64(total.Calls),
+			AvgLatencyMs: total.LatencyMsSum / float64(total.Calls),
+			MaxLatencyMs: total.LatencyMsMax,
+			ErrorCodes:   topCounts(total.ErrorCodes),
+			Patterns:     topCounts(total.Patterns),
+		}
+		summary.Calls += s.Calls
+		summary.Errors += s.Errors
+		summary.Tools[tool] = s
+		all = append(all, s)
+	}
+
+	summary.TopTools = topTools(all, func(a, b ToolUsageSummary) bool { return a.Calls > b.Calls })
+	var failing []ToolUsageSummary
+	for _, s := range all {
+		if s.Errors > 0 {
+			failing = append(failing, s)
+		}
+	}
+	summary.ErrorProneTools = topTools(failing, func(a, b ToolUsageSummary) bool {
+		if a.ErrorRate != b.ErrorRate {
+			return a.ErrorRate > b.ErrorRate
+		}
+		return a.Errors > b.Errors
+	})
+	summary.SlowestTools = topTools(all, func(a, b ToolUsageSummary) bool { return a.AvgLatencyMs > b.AvgLatencyMs })
+	return summary
+}
+
+// topTools returns the first usageTopN tools in the order of less, ties by name
+func topTools(tools []ToolUsageSummary, less func(a, b ToolUsageSummary) bool) []ToolUsageSummary {
+	sorted := append([]ToolUsageSummary{}, tools...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if less(sorted[i], sorted[j]) {
+			return true
+		}
+		if less(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if len(sorted) > usageTopN {
+		sorted = sorted[:usageTopN]
+	}
+	return sorted
+}
+
+// topCounts returns the usageTopN most frequent values of counts
+func topCounts(counts map[string]int64) []CountedAs {
+	values := make([]CountedAs, 0, len(counts))
+	for value, n := range counts {
+		values = append(values, CountedAs{Value: value, Count: n})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > usageTopN {
+		values = values[:usageTopN]
+	}
+	return values
+}
+
+// UsageRecommendation is a suggestion drawn from the usage of a tool
+type UsageRecommendation struct {
+	Tool    string `json:"tool"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// recommend suggests what to look at, from the 24 hour summary: tools that
+// fail often or are slow. Tools offered but not called in a week are listed
+// as unused.
+func recommend(day, week *UsageSummary, offered []string) []UsageRecommendation {
+	recommendations := []UsageRecommendation{}
+	names := make([]string, 0, len(day.Tools))
+	for name := range day.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := day.Tools[name]
+		if s.Calls < recommendMinCalls {
+			continue
+		}
+		if s.ErrorRate >= recommendErrorRate {
+			message := fmt.Sprintf("%s failed %.0f%% of %d calls in the last 24h", name, s.ErrorRate*100, s.Calls)
+			if len(s.ErrorCodes) > 0 {
+				message += fmt.Sprintf(", most often with %s", s.ErrorCodes[0].Value)
+				if s.ErrorCodes[0].Value == "VALIDATION_ERROR" {
+					message += "; check the arguments against the tool's input schema"
+				}
+			}
+			recommendations = append(recommendations, UsageRecommendation{Tool: name, Kind: "error_prone", Message: message})
+		}
+		if s.AvgLatencyMs >= recommendSlowLatency {
+			recommendations = append(recommendations, UsageRecommendation{
+				Tool:    name,
+				Kind:    "slow",
+				Message: fmt.Sprintf("%s took %.0f ms on average over %d calls in the last 24h; narrow its requests or check the indexes it uses", name, s.AvgLatencyMs, s.Calls),
+			})
+		}
+	}
+
+	if week.Calls > 0 {
+		var unused []string
+		for _, name := range offered {
+			if _, ok := week.Tools[name]; !ok {
+				unused = append(unused, name)
+			}
+		}
+		if len(unused) > 0 {
+			sort.Strings(unused)
+			recommendations = append(recommendations, UsageRecommendation{
+				Kind:    "unused",
+				Message: fmt.Sprintf("%d offered tools were not called in the last %s; disabling unused features shortens tools/list: %s", len(unused), recommendUnusedWindow, strings.Join(unused, ", ")),
+			})
+		}
+	}
+	return recommendations
+}
+
+// usageFile is the form usage statistics are saved in
+type usageFile struct {
+	BucketSeconds int64                            `json:"bucket_seconds"`
+	Buckets       map[string]map[string]*toolUsage `json:"buckets"`
+}
+
+// Load reads statistics saved by Save, adding them to the current ones. A
+// missing file is not an error.
+func (u *UsageStats) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read usage statistics %s: %w", path, err)
+	}
+	var file usageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse usage statistics %s: %w", path, err)
+	}
+	if file.BucketSeconds != int64(usageBucket/time.Second) {
+		// Saved with another bucket size; start over
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for rawKey, tools := range file.Buckets {
+		var key int64
+		if _, err := fmt.Sscan(rawKey, &key); err != nil {
+			continue
+		}
+		if _, ok := u.buckets[key]; !ok {
+			u.buckets[key] = make(map[string]*toolUsage)
+		}
+		for tool, usage := range tools {
+			if existing, ok := u.buckets[key][tool]; ok {
+				existing.Calls += usage.Calls
+				existing.Errors += usage.Errors
+				existing.LatencyMsSum += usage.LatencyMsSum
+				if usage.LatencyMsMax > existing.LatencyMsMax {
+					existing.LatencyMsMax = usage.LatencyMsMax
+				}
+				for code, n := range usage.ErrorCodes {
+					existing.ErrorCodes = addCount(existing.ErrorCodes, code, n)
+				}
+				for pattern, n := range usage.Patterns {
+					existing.Patterns = addCount(existing.Patterns, pattern, n)
+				}
+				continue
+			}
+			u.buckets[key][tool] = usage
+		}
+	}
+	u.prune(time.Now())
+	return nil
+}
+
+// addCount adds n to key in counts, or to usageOtherKey once counts holds
+// maxUsageKeys keys
+func addCount(counts map[string]int64, key string, n int64) map[string]int64 {
+	if counts == nil {
+		counts = make(map[string]int64)
+	}
+	if _, ok := counts[key]; !ok && len(counts) >= maxUsageKeys {
+		key = usageOtherKey
+	}
+	counts[key] += n
+	return counts
+}
+
+// Save writes the statistics to path if they changed since the last save.
+// The file is replaced atomically.
+func (u *UsageStats) Save(path string) error {
+	u.mu.Lock()
+	if !u.dirty {
+		u.mu.Unlock()
+		return nil
+	}
+	file := usageFile{
+		BucketSeconds: int64(usageBucket / time.Second),
+		Buckets:       make(map[string]map[string]*toolUsage, len(u.buckets)),
+	}
+	for key, tools := range u.buckets {
+		file.Buckets[fmt.Sprint(key)] = tools
+	}
+	data, err := json.Marshal(file)
+	u.dirty = false
+	u.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".usage-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save usage statistics %s: %w", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save usage statistics %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save usage statistics %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save usage statistics %s: %w", path, err)
+	}
+	return nil
+}
+
+// UsageResource exposes tool usage statistics and recommendations
+type UsageResource struct {
+	stats   *UsageStats
+	offered func() []string
+}
+
+// NewUsageResource creates the usage resource. offered lists the tools the
+// client is offered, for finding unused ones.
+func NewUsageResource(stats *UsageStats, offered func() []string) *UsageResource {
+	return &UsageResource{stats: stats, offered: offered}
+}
+
+// URI returns the resource URI
+func (r *UsageResource) URI() string {
+	return UsageURI
+}
+
+// Name returns the resource name
+func (r *UsageResource) Name() string {
+	return "Tool Usage Analytics"
+}
+
+// Description returns the resource description
+func (r *UsageResource) Description() string {
+	return "Top, error-prone and slowest tools over the last hour, day and week, with recommendations"
+}
+
+// MimeType returns the MIME type
+func (r *UsageResource) MimeType() string {
+	return "application/json"
+}
+
+// GetContent returns the usage summaries
+func (r *UsageResource) GetContent(ctx context.Context) (interface{}, error) {
+	now := time.Now()
+	windows := make(map[string]*UsageSummary, len(usageWindows))
+	for _, w := range usageWindows {
+		windows[w.name] = r.stats.Summary(w.name, w.span, now)
+	}
+	return map[string]interface{}{
+		"generated_at":    now.UTC(),
+		"windows":         windows,
+		"recommendations": recommend(windows["24h"], windows[recommendUnusedWindow], r.offered()),
+	}, nil
+}
@@ -0,0 +1,123 @@
+package resources
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageSummary(t *testing.T) {
+	u := NewUsageStats()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		u.Record("vector_search", map[string]interface{}{"table": "docs", "query_vector": []float64{1}}, 20*time.Millisecond, "", now.Add(-time.Minute))
+	}
+	u.Record("vector_search", map[string]interface{}{"table": "docs"}, 40*time.Millisecond, "VALIDATION_ERROR", now.Add(-time.Minute))
+	u.Record("ingest_document", nil, 2*time.Second, "EXECUTION_ERROR", now.Add(-2*time.Hour))
+	u.Record("old_tool", nil, time.Millisecond, "", now.Add(-8*24*time.Hour))
+
+	hour := u.Summary("1h", time.Hour, now)
+	if hour.Calls != 7 || hour.Errors != 1 || len(hour.TopTools) != 1 {
+		t.Fatalf("1h summary = %+v, want 7 calls of one tool with 1 error", hour)
+	}
+	search := hour.TopTools[0]
+	if search.Name != "vector_search" || search.MaxLatencyMs != 40 || search.AvgLatencyMs < 22 || search.AvgLatencyMs > 23 {
+		t.Errorf("vector_search = %+v", search)
+	}
+	if len(search.Patterns) != 2 || search.Patterns[0] != (CountedAs{Value: "query_vector,table", Count: 6}) {
+		t.Errorf("argument patterns = %+v, want sorted argument names without values", search.Patterns)
+	}
+	if len(search.ErrorCodes) != 1 || search.ErrorCodes[0] != (CountedAs{Value: "VALIDATION_ERROR", Count: 1}) {
+		t.Errorf("error codes = %+v", search.ErrorCodes)
+	}
+
+	day := u.Summary("24h", 24*time.Hour, now)
+	if day.Calls != 8 || len(day.ErrorProneTools) != 2 || day.ErrorProneTools[0].Name != "ingest_document" {
+		t.Errorf("24h summary = %+v, want ingest_document as the most error-prone tool", day)
+	}
+	if len(day.SlowestTools) == 0 || day.SlowestTools[0].Name != "ingest_document" {
+		t.Errorf("slowest tools = %+v", day.SlowestTools)
+	}
+	if week := u.Summary("7d", 7*24*time.Hour, now); week.Calls != 8 {
+		t.Errorf("7d summary counts %d calls, want 8 without the one from 8 days ago", week.Calls)
+	}
+}
+
+func TestUsageKeysAreCapped(t *testing.T) {
+	u := NewUsageStats()
+	now := time.Now()
+	for i := 0; i < maxUsageKeys+5; i++ {
+		u.Record("query", map[string]interface{}{fmt.Sprintf("arg%d", i): 1}, time.Millisecond, fmt.Sprintf("CODE_%d", i), now)
+	}
+	usage := u.buckets[now.Unix()/int64(usageBucket/time.Second)]["query"]
+	if len(usage.Patterns) != maxUsageKeys+1 || usage.Patterns[usageOtherKey] != 5 {
+		t.Errorf("patterns hold %d keys, %d under %s; want %d keys", len(usage.Patterns), usage.Patterns[usageOtherKey], usageOtherKey, maxUsageKeys+1)
+	}
+	if len(usage.ErrorCodes) != maxUsageKeys+1 || usage.ErrorCodes[usageOtherKey] != 5 {
+		t.Errorf("error codes hold %d keys, %d under %s", len(usage.ErrorCodes), usage.ErrorCodes[usageOtherKey], usageOtherKey)
+	}
+}
+
+func TestUsageRecommendations(t *testing.T) {
+	u := NewUsageStats()
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		code := ""
+		if i%2 == 0 {
+			code = "VALIDATION_ERROR"
+		}
+		u.Record("hybrid_search", map[string]interface{}{"table": "docs"}, time.Millisecond, code, now)
+		u.Record("train_model", nil, 6*time.Second, "", now)
+	}
+	u.Record("rare_tool", nil, time.Minute, "EXECUTION_ERROR", now)
+
+	day := u.Summary("24h", 24*time.Hour, now)
+	week := u.Summary("7d", 7*24*time.Hour, now)
+	kinds := map[string]string{}
+	for _, r := range recommend(day, week, []string{"hybrid_search", "train_model", "rare_tool", "cluster_data", "analyze_data"}) {
+		kinds[r.Kind] = kinds[r.Kind] + r.Tool + " " + r.Message + "\n"
+	}
+	if !strings.Contains(kinds["error_prone"], "hybrid_search") || !strings.Contains(kinds["error_prone"], "input schema") {
+		t.Errorf("error_prone recommendations = %q", kinds["error_prone"])
+	}
+	if !strings.Contains(kinds["slow"], "train_model") {
+		t.Errorf("slow recommendations = %q", kinds["slow"])
+	}
+	if strings.Contains(kinds["error_prone"]+kinds["slow"], "rare_tool") {
+		t.Error("recommended a tool called fewer than the minimum number of times")
+	}
+	if !strings.Contains(kinds["unused"], "analyze_data, cluster_data") {
+		t.Errorf("unused recommendation = %q", kinds["unused"])
+	}
+
+	empty := NewUsageStats()
+	if r := recommend(empty.Summary("24h", 24*time.Hour, now), empty.Summary("7d", 7*24*time.Hour, now), []string{"query"}); len(r) != 0 {
+		t.Errorf("recommendations without any calls = %+v", r)
+	}
+}
+
+func TestUsageSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	u := NewUsageStats()
+	if err := u.Load(path); err != nil {
+		t.Fatalf("Load of a missing file: %v", err)
+	}
+	now := time.Now()
+	u.Record("vector_search", map[string]interface{}{"table": "docs"}, 10*time.Millisecond, "", now)
+	u.Record("vector_search", map[string]interface{}{"table": "docs"}, 30*time.Millisecond, "QUERY_ERROR", now)
+	if err := u.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded := NewUsageStats()
+	loaded.Record("vector_search", nil, 20*time.Millisecond, "", now)
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	s := loaded.Summary("1h", time.Hour, now).Tools["vector_search"]
+	if s.Calls != 3 || s.Errors != 1 || s.MaxLatencyMs != 30 || s.AvgLatencyMs != 20 {
+		t.Errorf("loaded summary = %+v, want the saved calls added to the current ones", s)
+	}
+}
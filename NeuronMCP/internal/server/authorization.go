@@ -0,0 +1,79 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+// callerRoles returns the roles of the connected client: the policy's
+// default roles plus any listed in NEURONDB_MCP_ROLES by the process that
+// launches the server. The client is not authenticated, so nothing it sends,
+// such as its clientInfo.name, grants roles.
+func (s *Server) callerRoles() []string {
+	roles := append([]string(nil), s.policy.Policy().DefaultRoles...)
+	for _, role := range strings.Split(os.Getenv("NEURONDB_MCP_ROLES"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// authorizeTool evaluates a tools/call against the active policy and returns
+// an ErrCodeToolDenied error when the call is not allowed
+func (s *Server) authorizeTool(toolName string) error {
+	roles := s.callerRoles()
+	decision := s.policy.Evaluate(toolName, roles)
+	if decision.Allowed {
+		return nil
+	}
+
+	s.logger.Warn("Tool call denied by policy", map[string]interface{}{
+		"tool_name": toolName,
+		"client":    s.mcpServer.ClientName(),
+		"roles":     roles,
+		"rule":      decision.Rule,
+	})
+	return &mcp.Error{
+		Code:    mcp.ErrCodeToolDenied,
+		Message: "Tool call denied: " + decision.Reason,
+		Data: map[string]interface{}{
+			"tool": toolName,
+			"rule": decision.Rule,
+		},
+	}
+}
+
+// authorizeQueryTags evaluates the permission tags of a saved query against
+// the active policy
+func (s *Server) authorizeQueryTags(tags []string) error {
+	roles := s.callerRoles()
+	decision := s.policy.Policy().EvaluateQueryTags(tags, roles)
+	if decision.Allowed {
+		return nil
+	}
+
+	s.logger.Warn("Saved query denied by policy", map[string]interface{}{
+		"tags":   tags,
+		"client": s.mcpServer.ClientName(),
+		"roles":  roles,
+		"rule":   decision.Rule,
+	})
+	return errors.New(decision.Reason)
+}
+
+// filterToolsByPolicy hides tools the connected client is not allowed to call
+func (s *Server) filterToolsByPolicy(definitions []tools.ToolDefinition) []tools.ToolDefinition {
+	roles := s.callerRoles()
+	filtered := make([]tools.ToolDefinition, 0, len(definitions))
+	for _, def := range definitions {
+		if s.policy.Evaluate(def.Name, roles).Allowed {
+			filtered = append(filtered, def)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+	"github.com/neurondb/NeuronMCP/internal/policy"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+func TestCallerRolesComeFromServerConfig(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policyFile, []byte(`{"toolRoles": {"create_*": ["admin"]}, "defaultRoles": ["reader"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	engine, err := policy.NewEngine(policyFile, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{logger: logger, policy: engine}
+	definitions := []tools.ToolDefinition{{Name: "vector_search"}, {Name: "create_index"}}
+
+	t.Setenv("NEURONDB_MCP_ROLES", "")
+	if roles := s.callerRoles(); !reflect.DeepEqual(roles, []string{"reader"}) {
+		t.Errorf("callerRoles() = %v, want [reader]", roles)
+	}
+	if got := s.filterToolsByPolicy(definitions); len(got) != 1 || got[0].Name != "vector_search" {
+		t.Errorf("filterToolsByPolicy() = %v, want only vector_search", got)
+	}
+
+	t.Setenv("NEURONDB_MCP_ROLES", " ml, admin ,")
+	if roles := s.callerRoles(); !reflect.DeepEqual(roles, []string{"reader", "ml", "admin"}) {
+		t.Errorf("callerRoles() = %v, want [reader ml admin]", roles)
+	}
+	if got := s.filterToolsByPolicy(definitions); len(got) != 2 {
+		t.Errorf("filterToolsByPolicy() = %v, want both tools", got)
+	}
+}
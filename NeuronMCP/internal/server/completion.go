@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+// completionTimeout bounds the catalog queries of one completion, which run
+// while the user types
+const completionTimeout = 2 * time.Second
+
+// Sources of completion values for a tool argument
+const (
+	completeNone    = ""
+	completeEnum    = "enum"
+	completeTable   = "table"
+	completeColumn  = "column"
+	completeModel   = "model"
+	completeModelID = "model_id"
+)
+
+// Catalog queries for completions. $1 is a LIKE pattern matching the typed
+// prefix. Tables outside the search path are listed schema-qualified.
+const (
+	completeTablesQuery = `SELECT name FROM (
+		SELECT CASE WHEN pg_table_is_visible(c.oid) THEN quote_ident(c.relname)
+			ELSE quote_ident(n.nspname) || '.' || quote_ident(c.relname) END AS name
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f')
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		  AND n.nspname NOT LIKE 'pg\_toast%'
+		  AND has_table_privilege(c.oid, 'SELECT')
+	) t WHERE name ILIKE $1 ORDER BY name LIMIT $2`
+	completeColumnsQuery = `SELECT attname::text AS name FROM pg_attribute
+		WHERE attrelid = to_regclass($2) AND attnum > 0 AND NOT attisdropped AND attname ILIKE $1
+		ORDER BY attnum LIMIT $3`
+	completeModelsQuery   = `SELECT model_name AS name FROM list_embedding_model_configs() WHERE model_name ILIKE $1 ORDER BY model_name LIMIT $2`
+	completeModelIDsQuery = `SELECT model_id::text AS name FROM neurondb.ml_models WHERE model_id::text LIKE $1 ORDER BY model_id DESC LIMIT $2`
+)
+
+// setupCompletionHandlers sets up the completion/complete handler
+func (s *Server) setupCompletionHandlers() {
+	s.mcpServer.SetHandler("completion/complete", s.handleComplete)
+}
+
+// handleComplete suggests values for a tool argument. Arguments with an enum
+// complete from it; tables, columns and models complete from the catalog of
+// the database the call would be routed to. Prompts and resources take no
+// arguments here, so they get no values.
+func (s *Server) handleComplete(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var req mcp.CompleteRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("failed to parse completion/complete request: %w", err)
+	}
+	if req.Ref.Type != mcp.RefTool {
+		return mcp.CompleteResponse{Completion: mcp.Completion{Values: []string{}}}, nil
+	}
+
+	def, ok := s.toolRegistry.GetDefinition(req.Ref.Name)
+	if !ok {
+		return nil, &mcp.Error{
+			Code:    mcp.ErrCodeToolNotFound,
+			Message: fmt.Sprintf("cannot complete arguments of unknown tool '%s'", req.Ref.Name),
+		}
+	}
+	if err := s.authorizeTool(def.Name); err != nil {
+		return nil, err
+	}
+	schema := def.InputSchema
+	if s.targets.Routed() {
+		schema = withDatabaseArgument(schema, s.targets.Names())
+	}
+	if tools.SupportsResultFormat(def.Name) {
+		schema = withResultOptionsArgument(withResultFormatArgument(schema))
+	}
+
+	arguments := make(map[string]interface{})
+	if req.Context != nil {
+		for name, value := range req.Context.Arguments {
+			arguments[name] = value
+		}
+	}
+	// The database argument itself completes from its enum
+	if req.Argument.Name == databaseArgument {
+		delete(arguments, databaseArgument)
+	}
+	ctx, err := s.routeDatabase(ctx, def.Name, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := s.completionValues(ctx, schema, req.Argument, arguments)
+	if err != nil {
+		// Completion is a convenience: a failed lookup offers nothing
+		s.logger.Debug("Completion lookup failed", map[string]interface{}{
+			"tool_name": def.Name,
+			"argument":  req.Argument.Name,
+			"error":     err.Error(),
+		})
+		values = nil
+	}
+	return mcp.CompleteResponse{Completion: limitCompletion(values)}, nil
+}
+
+func (s *Server) completionValues(ctx context.Context, schema map[string]interface{}, arg mcp.CompleteArgument, arguments map[string]interface{}) ([]string, error) {
+	source, enum := completionSource(schema, arg.Name)
+	switch source {
+	case completeEnum:
+		return matchPrefix(enum, arg.Value), nil
+	case completeNone:
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+	executor := tools.NewQueryExecutor(s.db)
+	pattern := likePrefix(arg.Value)
+	// One more row than can be returned shows whether there are more
+	limit := mcp.MaxCompletionValues + 1
+
+	switch source {
+	case completeTable:
+		return queryNames(ctx, executor, completeTablesQuery, pattern, limit)
+	case completeColumn:
+		table, _ := arguments[tableArgumentFor(arg.Name)].(string)
+		if table == "" {
+			return nil, nil
+		}
+		return queryNames(ctx, executor, completeColumnsQuery, pattern, table, limit)
+	case completeModelID:
+		return queryNames(ctx, executor, completeModelIDsQuery, pattern, limit)
+	}
+
+	// Models come from the configuration and from stored embedding model
+	// configs, when the extension has them
+	var configured []string
+	for _, target := range modelTargets(s.config.GetFeaturesConfig().Models) {
+		configured = append(configured, target.Name)
+	}
+	stored, err := queryNames(ctx, executor, completeModelsQuery, pattern, limit)
+	if err != nil {
+		stored = nil
+	}
+	return mergeNames(matchPrefix(configured, arg.Value), stored), nil
+}
+
+// completionSource picks where the values of a tool argument come from: the
+// enum of its schema, or the catalog when its name says it holds a table, a
+// column or a model
+func completionSource(schema map[string]interface{}, argument string) (string, []string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	property, ok := properties[argument].(map[string]interface{})
+	if !ok {
+		return completeNone, nil
+	}
+	if items, ok := property["items"].(map[string]interface{}); ok {
+		property = items
+	}
+	if enum, ok := property["enum"].([]interface{}); ok {
+		values := make([]string, 0, len(enum))
+		for _, v := range enum {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return completeEnum, values
+	}
+	// Model IDs are numbers; their values are offered as strings like any
+	// other completion
+	if argument == "model_id" {
+		return completeModelID, nil
+	}
+	if t, _ := property["type"].(string); t != "string" {
+		return completeNone, nil
+	}
+
+	switch {
+	case argument == "table" || strings.HasSuffix(argument, "_table"):
+		return completeTable, nil
+	case argument == "column" || strings.HasSuffix(argument, "_column") || strings.HasSuffix(argument, "_columns") ||
+		argument == "left_key" || argument == "right_key":
+		return completeColumn, nil
+	case argument == "model" || strings.HasSuffix(argument, "_model") || strings.HasSuffix(argument, "_models"):
+		return completeModel, nil
+	}
+	return completeNone, nil
+}
+
+// tableArgumentFor names the argument holding the table of a column
+// argument: left_table for left_column, right_table for right_key and table
+// otherwise
+func tableArgumentFor(column string) string {
+	for _, side := range []string{"left_", "right_"} {
+		if strings.HasPrefix(column, side) {
+			return side + "table"
+		}
+	}
+	return "table"
+}
+
+func queryNames(ctx context.Context, executor *tools.QueryExecutor, query string, params ...interface{}) ([]string, error) {
+	rows, err := executor.ExecuteQuery(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if name, ok := row["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// likePrefix returns a LIKE pattern matching values starting with prefix
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// matchPrefix returns the values starting with prefix, ignoring case
+func matchPrefix(values []string, prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	matched := make([]string, 0, len(values))
+	for _, v := range values {
+		if strings.HasPrefix(strings.ToLower(v), prefix) {
+			matched = append(matched, v)
+		}
+	}
+	return matched
+}
+
+// mergeNames joins name lists, dropping duplicates and sorting the result
+func mergeNames(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, list := range lists {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				merged = append(merged, name)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// limitCompletion caps values at the protocol's limit of 100. Catalog
+// queries fetch one value more than the limit, so a full page reports
+// hasMore without an exact total.
+func limitCompletion(values []string) mcp.Completion {
+	if len(values) <= mcp.MaxCompletionValues {
+		if values == nil {
+			values = []string{}
+		}
+		return mcp.Completion{Values: values, Total: len(values)}
+	}
+	return mcp.Completion{Values: values[:mcp.MaxCompletionValues], HasMore: true}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+func TestCompletionSource(t *testing.T) {
+	str := map[string]interface{}{"type": "string"}
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"table":            str,
+			"right_table":      str,
+			"vector_column":    str,
+			"left_key":         str,
+			"left_columns":     map[string]interface{}{"type": "array", "items": str},
+			"model":            str,
+			"model_id":         map[string]interface{}{"type": "number"},
+			"embedding_models": map[string]interface{}{"type": "array", "items": str},
+			"distance_metric":  map[string]interface{}{"type": "string", "enum": []interface{}{"l2", "cosine"}},
+			"limit":            map[string]interface{}{"type": "number"},
+			"query":            str,
+		},
+	}
+
+	for arg, want := range map[string]string{
+		"table":            completeTable,
+		"right_table":      completeTable,
+		"vector_column":    completeColumn,
+		"left_key":         completeColumn,
+		"left_columns":     completeColumn,
+		"model":            completeModel,
+		"model_id":         completeModelID,
+		"embedding_models": completeModel,
+		"distance_metric":  completeEnum,
+		"limit":            completeNone,
+		"query":            completeNone,
+		"missing":          completeNone,
+	} {
+		if got, _ := completionSource(schema, arg); got != want {
+			t.Errorf("%s: source = %q, want %q", arg, got, want)
+		}
+	}
+
+	if _, enum := completionSource(schema, "distance_metric"); !reflect.DeepEqual(enum, []string{"l2", "cosine"}) {
+		t.Errorf("enum = %v", enum)
+	}
+}
+
+func TestTableArgumentFor(t *testing.T) {
+	for column, want := range map[string]string{
+		"vector_column": "table",
+		"left_column":   "left_table",
+		"right_key":     "right_table",
+	} {
+		if got := tableArgumentFor(column); got != want {
+			t.Errorf("tableArgumentFor(%s) = %s, want %s", column, got, want)
+		}
+	}
+}
+
+func TestCompletionHelpers(t *testing.T) {
+	if got := likePrefix(`doc_%\`); got != `doc\_\%\\%` {
+		t.Errorf("likePrefix = %s", got)
+	}
+	if got := matchPrefix([]string{"Cosine", "l2", "inner_product"}, "co"); !reflect.DeepEqual(got, []string{"Cosine"}) {
+		t.Errorf("matchPrefix = %v", got)
+	}
+	if got := mergeNames([]string{"b", "a"}, []string{"a", "c"}); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("mergeNames = %v", got)
+	}
+
+	if c := limitCompletion(nil); c.Values == nil || c.Total != 0 || c.HasMore {
+		t.Errorf("empty completion = %+v", c)
+	}
+	many := make([]string, mcp.MaxCompletionValues+1)
+	if c := limitCompletion(many); len(c.Values) != mcp.MaxCompletionValues || !c.HasMore {
+		t.Errorf("long completion: %d values, hasMore=%v", len(c.Values), c.HasMore)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+// dryRunArgument is the tools/call argument asking a mutating tool for a
+// plan of what it would do instead of doing it
+const dryRunArgument = "dry_run"
+
+// applyDryRun removes the dry_run argument from arguments. When it is true
+// the returned context asks the tool for a plan; tools that cannot plan
+// reject it, so a dry run never falls through to a real call.
+func (s *Server) applyDryRun(ctx context.Context, toolName string, arguments map[string]interface{}) (context.Context, error) {
+	value, ok := arguments[dryRunArgument]
+	if !ok {
+		return ctx, nil
+	}
+	delete(arguments, dryRunArgument)
+
+	dryRun, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s argument for tool '%s': expected a boolean, got %T", dryRunArgument, toolName, value)
+	}
+	if !dryRun {
+		return ctx, nil
+	}
+	if !tools.SupportsDryRun(toolName) {
+		return nil, fmt.Errorf("tool '%s' does not support %s", toolName, dryRunArgument)
+	}
+	s.logger.Debug("Planning tool call", map[string]interface{}{
+		"tool_name": toolName,
+	})
+	return tools.WithDryRun(ctx), nil
+}
+
+// withDryRunArgument returns a copy of schema that also accepts the dry_run
+// argument, leaving the registry's schema unmodified
+func withDryRunArgument(schema map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	if existing, ok := schema["properties"].(map[string]interface{}); ok {
+		for key, value := range existing {
+			properties[key] = value
+		}
+	}
+	properties[dryRunArgument] = map[string]interface{}{
+		"type":        "boolean",
+		"default":     false,
+		"description": "Return the SQL this call would run, with parameters, estimated affected rows and required permissions, without running it",
+	}
+
+	planned := make(map[string]interface{}, len(schema)+1)
+	for key, value := range schema {
+		planned[key] = value
+	}
+	planned["properties"] = properties
+	return planned
+}
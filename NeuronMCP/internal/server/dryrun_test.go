@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+func TestApplyDryRun(t *testing.T) {
+	s := &Server{logger: logging.NewLogger(&config.LoggingConfig{Level: "error"})}
+
+	args := map[string]interface{}{"index_name": "docs_idx", dryRunArgument: true}
+	ctx, err := s.applyDryRun(context.Background(), "drop_index", args)
+	if err != nil || !tools.IsDryRun(ctx) {
+		t.Fatalf("dry run not requested: err=%v", err)
+	}
+	if _, ok := args[dryRunArgument]; ok {
+		t.Error("dry_run argument was passed on to the tool")
+	}
+
+	args[dryRunArgument] = false
+	if ctx, err := s.applyDryRun(context.Background(), "drop_index", args); err != nil || tools.IsDryRun(ctx) {
+		t.Errorf("dry_run false requested a dry run: err=%v", err)
+	}
+
+	args[dryRunArgument] = true
+	if _, err := s.applyDryRun(context.Background(), "worker_management", args); err == nil {
+		t.Error("dry run accepted by a tool that cannot plan")
+	}
+
+	args[dryRunArgument] = "yes"
+	if _, err := s.applyDryRun(context.Background(), "drop_index", args); err == nil {
+		t.Error("non-boolean dry_run accepted")
+	}
+}
+
+func TestWithDryRunArgument(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"index_name": map[string]interface{}{"type": "string"}},
+	}
+	planned := withDryRunArgument(schema)
+
+	properties := planned["properties"].(map[string]interface{})
+	if _, ok := properties["index_name"]; !ok {
+		t.Error("existing property dropped")
+	}
+	if _, ok := properties[dryRunArgument]; !ok {
+		t.Error("dry_run property missing")
+	}
+	if _, ok := schema["properties"].(map[string]interface{})[dryRunArgument]; ok {
+		t.Error("registry schema was modified")
+	}
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+// federation converts the configured remotes of federated_vector_search,
+// returning false when none are configured
+func federation(cfg *config.FederationFeatureConfig) (tools.Federation, bool) {
+	if cfg == nil || len(cfg.Remotes) == 0 {
+		return tools.Federation{}, false
+	}
+	federation := tools.Federation{Remotes: make([]tools.FederationRemote, len(cfg.Remotes))}
+	for i, remote := range cfg.Remotes {
+		federation.Remotes[i] = tools.FederationRemote{Name: remote.Name, Server: remote.Server}
+		if remote.Table != nil {
+			federation.Remotes[i].Table = *remote.Table
+		}
+	}
+	if cfg.TimeoutMillis != nil {
+		federation.Timeout = time.Duration(*cfg.TimeoutMillis) * time.Millisecond
+	}
+	return federation, true
+}
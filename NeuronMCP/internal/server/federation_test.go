@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+)
+
+func TestFederation(t *testing.T) {
+	if _, ok := federation(nil); ok {
+		t.Error("federation without config reported as configured")
+	}
+	if _, ok := federation(&config.FederationFeatureConfig{}); ok {
+		t.Error("federation without remotes reported as configured")
+	}
+
+	table, timeout := "archive.docs", 2500
+	got, ok := federation(&config.FederationFeatureConfig{
+		Remotes: []config.FederationRemoteConfig{
+			{Name: "eu", Server: "neurondb_eu"},
+			{Name: "us", Server: "neurondb_us", Table: &table},
+		},
+		TimeoutMillis: &timeout,
+	})
+	if !ok {
+		t.Fatal("configured federation not reported")
+	}
+	if got.Timeout != 2500*time.Millisecond {
+		t.Errorf("timeout = %v, want 2.5s", got.Timeout)
+	}
+	if len(got.Remotes) != 2 || got.Remotes[0].Table != "" || got.Remotes[1].Table != "archive.docs" || got.Remotes[1].Server != "neurondb_us" {
+		t.Errorf("remotes = %+v", got.Remotes)
+	}
+}
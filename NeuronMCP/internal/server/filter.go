@@ -87,7 +87,7 @@ func isAnalyticsTool(name string) bool {
 }
 
 func isRAGTool(name string) bool {
-	ragPrefixes := []string{"rag_", "chunk_"}
+	ragPrefixes := []string{"rag_", "chunk_", "extract_entities", "graph_neighborhood_search"}
 	for _, prefix := range ragPrefixes {
 		if len(name) >= len(prefix) && name[:len(prefix)] == prefix {
 			return true
@@ -3,7 +3,10 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/neurondb/NeuronMCP/internal/middleware"
 	"github.com/neurondb/NeuronMCP/internal/tools"
@@ -30,14 +33,31 @@ func (s *Server) setupToolHandlers() {
 // handleListTools handles the tools/list request
 func (s *Server) handleListTools(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	definitions := s.toolRegistry.GetAllDefinitions()
-	filtered := s.filterToolsByFeatures(definitions)
+	filtered := s.filterToolsByPolicy(s.filterToolsByFeatures(definitions))
 	
+	var targets []string
+	if s.targets.Routed() {
+		targets = s.targets.Names()
+	}
+
 	mcpTools := make([]mcp.ToolDefinition, len(filtered))
 	for i, def := range filtered {
+		inputSchema := def.InputSchema
+		if targets != nil {
+			inputSchema = withDatabaseArgument(inputSchema, targets)
+		}
+		if tools.SupportsDryRun(def.Name) {
+			inputSchema = withDryRunArgument(inputSchema)
+		}
+		if tools.SupportsResultFormat(def.Name) {
+			inputSchema = withResultOptionsArgument(withResultFormatArgument(inputSchema))
+		}
 		mcpTools[i] = mcp.ToolDefinition{
 			Name:        def.Name,
 			Description: def.Description,
-			InputSchema: def.InputSchema,
+			InputSchema: inputSchema,
+			Version:     def.Version,
+			Versions:    def.Versions,
 		}
 	}
 	
@@ -55,6 +75,53 @@ func (s *Server) handleCallTool(ctx context.Context, params json.RawMessage) (in
 		return nil, fmt.Errorf("tool name is required in tools/call request: received empty name, params=%v", req)
 	}
 
+	if err := s.authorizeTool(req.Name); err != nil {
+		return nil, err
+	}
+	ctx, err := s.routeDatabase(ctx, req.Name, req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = s.applyDryRun(ctx, req.Name, req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = s.applyResultFormat(ctx, req.Name, req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = s.applyResultOptions(ctx, req.Name, req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.withClientSampler(ctx)
+	ctx = s.withClientRoots(ctx)
+	ctx = s.withProgress(ctx, req.Meta)
+	ctx = withToolVersion(ctx, req.Meta)
+	if s.listener != nil {
+		ctx = tools.WithChannelSubscriber(ctx, s.listener)
+	}
+	if s.mock != nil {
+		ctx = tools.WithMockDatabase(ctx, s.mock)
+	}
+	ctx = tools.WithSessionTables(ctx, s.sessions)
+	ctx = tools.WithQueryTagAuthorizer(ctx, s.authorizeQueryTags)
+	if dir := s.config.GetServerSettings().GetExportDir(); dir != "" {
+		ctx = tools.WithExportDir(ctx, dir)
+	}
+	if dirs := s.config.GetServerSettings().LocalFileDirs; len(dirs) > 0 {
+		ctx = tools.WithLocalFileDirs(ctx, dirs)
+	}
+	if role := s.config.GetServerSettings().GetReadOnlyRole(); role != "" {
+		ctx = tools.WithReadOnlyRole(ctx, role)
+	}
+	if targets := modelTargets(s.config.GetFeaturesConfig().Models); len(targets) > 0 {
+		ctx = tools.WithModelTargets(ctx, targets)
+	}
+	if federation, ok := federation(s.config.GetFeaturesConfig().Federation); ok {
+		ctx = tools.WithFederation(ctx, federation)
+	}
+
 	mcpReq := &middleware.MCPRequest{
 		Method: "tools/call",
 		Params: map[string]interface{}{
@@ -63,9 +130,16 @@ func (s *Server) handleCallTool(ctx context.Context, params json.RawMessage) (in
 		},
 	}
 
-	return s.middleware.Execute(ctx, mcpReq, func(ctx context.Context) (*middleware.MCPResponse, error) {
-		return s.executeTool(ctx, req.Name, req.Arguments)
+	start := time.Now()
+	resp, err := s.middleware.Execute(ctx, mcpReq, func(ctx context.Context) (*middleware.MCPResponse, error) {
+		resp, err := s.executeTool(ctx, req.Name, req.Arguments)
+		if err == nil && s.mock != nil {
+			resp = flagMockResult(resp)
+		}
+		return resp, err
 	})
+	s.recordUsage(req.Name, req.Arguments, time.Since(start), resp, err)
+	return resp, err
 }
 
 // executeTool executes a tool and returns the response
@@ -94,6 +168,53 @@ func (s *Server) executeTool(ctx context.Context, toolName string, arguments map
 		}, nil
 	}
 
+	version, adapter, err := s.toolRegistry.ResolveVersion(toolName, requestedToolVersion(ctx))
+	if err != nil {
+		details := map[string]interface{}{}
+		var unsupported *tools.UnsupportedVersionError
+		if errors.As(err, &unsupported) {
+			details["requested"] = unsupported.Requested
+			details["versions"] = unsupported.Versions
+		}
+		return withSchemaVersion(s.formatToolError(tools.Error(err.Error(), "UNSUPPORTED_VERSION", details)), tools.ToolVersion(tool)), nil
+	}
+	resp, err := s.runTool(ctx, tool, adapter, arguments)
+	return withSchemaVersion(resp, version), err
+}
+
+// runTool validates a call's arguments and runs the tool. With an adapter,
+// the call is for the tool's previous version: the arguments are validated
+// against that version's schema and converted, and the result converted
+// back.
+func (s *Server) runTool(ctx context.Context, tool tools.Tool, adapter *tools.CompatibilityAdapter, arguments map[string]interface{}) (*middleware.MCPResponse, error) {
+	toolName := tool.Name()
+	schema := tool.InputSchema()
+	if adapter != nil {
+		schema = adapter.InputSchema
+	}
+
+	// Every tool's arguments are checked against its input schema, whether
+	// or not the tool validates them itself
+	if errs := tools.ValidateArguments(schema, arguments); len(errs) > 0 {
+		return s.formatToolError(tools.Error(
+			fmt.Sprintf("Invalid parameters for %s tool: %s", toolName, strings.Join(errs, "; ")),
+			"VALIDATION_ERROR",
+			map[string]interface{}{"errors": errs},
+		)), nil
+	}
+
+	if adapter != nil {
+		adapted, err := adapter.Arguments(arguments)
+		if err != nil {
+			return s.formatToolError(tools.Error(
+				fmt.Sprintf("Invalid parameters for %s tool: %v", toolName, err),
+				"VALIDATION_ERROR",
+				nil,
+			)), nil
+		}
+		arguments = adapted
+	}
+
 	// Log tool execution start
 	s.logger.Info("Executing tool", map[string]interface{}{
 		"tool_name": toolName,
@@ -110,16 +231,27 @@ func (s *Server) executeTool(ctx context.Context, toolName string, arguments map
 		}, nil
 	}
 
-	return s.formatToolResult(result)
+	if adapter != nil && adapter.Result != nil {
+		result = adapter.Result(result)
+	}
+	result = s.redactToolResult(toolName, result)
+
+	if result.Success && tools.ResultFormatFromContext(ctx) != tools.ResultFormatJSON {
+		return s.formatTabularResult(ctx, toolName, result)
+	}
+	return s.formatToolResult(toolName, result)
 }
 
 // formatToolResult formats a tool result as an MCP response
-func (s *Server) formatToolResult(result *tools.ToolResult) (*middleware.MCPResponse, error) {
+func (s *Server) formatToolResult(toolName string, result *tools.ToolResult) (*middleware.MCPResponse, error) {
 	if !result.Success {
 		return s.formatToolError(result), nil
 	}
 
 	resultJSON, _ := json.MarshalIndent(result.Data, "", "  ")
+	if s.maxResultSize > 0 && len(resultJSON) > s.maxResultSize {
+		return s.spillToolResult(toolName, resultJSON, result.Metadata), nil
+	}
 	return &middleware.MCPResponse{
 		Content: []middleware.ContentBlock{
 			{Type: "text", Text: string(resultJSON)},
@@ -143,6 +275,9 @@ func (s *Server) formatToolError(result *tools.ToolResult) *middleware.MCPRespon
 			errorMetadata["details"] = result.Error.Details
 		}
 	}
+	if note, ok := result.Metadata["redaction"]; ok {
+		errorMetadata["redaction"] = note
+	}
 	
 	return &middleware.MCPResponse{
 		Content: []middleware.ContentBlock{
@@ -306,3 +306,47 @@ func TestExecuteTool_ErrorConditions(t *testing.T) {
 	}()
 }
 
+
+// recordingTool counts its executions
+type recordingTool struct {
+	*tools.BaseTool
+	calls int
+}
+
+func (t *recordingTool) Execute(ctx context.Context, params map[string]interface{}) (*tools.ToolResult, error) {
+	t.calls++
+	return tools.Success(params, nil), nil
+}
+
+func TestExecuteToolValidatesArguments(t *testing.T) {
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	db := database.NewDatabase()
+	registry := tools.NewToolRegistry(db, logger)
+	tool := &recordingTool{BaseTool: tools.NewBaseTool("score", "Scores items", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"items": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "integer", "minimum": 1},
+			},
+		},
+		"required": []interface{}{"items"},
+	})}
+	registry.Register(tool)
+	s := &Server{logger: logger, toolRegistry: registry}
+
+	resp, err := s.executeTool(context.Background(), "score", map[string]interface{}{"items": []interface{}{1, 0}})
+	if err != nil {
+		t.Fatalf("executeTool() error = %v", err)
+	}
+	if !resp.IsError || tool.calls != 0 {
+		t.Fatalf("invalid arguments reached the tool: %+v", resp)
+	}
+	if resp.Metadata["code"] != "VALIDATION_ERROR" {
+		t.Errorf("metadata = %v", resp.Metadata)
+	}
+	errs, _ := resp.Metadata["details"].(map[string]interface{})["errors"].([]string)
+	if len(errs) != 1 || errs[0] != "/items/1: minimum: got 0, want 1" {
+		t.Errorf("errors = %q", errs)
+	}
+}
@@ -1,14 +1,17 @@
 package server
 
 import (
+	"time"
+
 	"github.com/neurondb/NeuronMCP/internal/config"
 	"github.com/neurondb/NeuronMCP/internal/logging"
 	"github.com/neurondb/NeuronMCP/internal/middleware"
 	"github.com/neurondb/NeuronMCP/internal/middleware/builtin"
 )
 
-// setupBuiltInMiddleware registers all built-in middleware
-func setupBuiltInMiddleware(mgr *middleware.Manager, cfgMgr *config.ConfigManager, logger *logging.Logger) {
+// setupBuiltInMiddleware registers all built-in middleware and returns the
+// logging and timeout middleware, whose settings can be changed on reload
+func setupBuiltInMiddleware(mgr *middleware.Manager, cfgMgr *config.ConfigManager, logger *logging.Logger) (*builtin.LoggingMiddleware, *builtin.TimeoutMiddleware) {
 	loggingCfg := cfgMgr.GetLoggingConfig()
 	serverCfg := cfgMgr.GetServerSettings()
 
@@ -16,21 +19,30 @@ func setupBuiltInMiddleware(mgr *middleware.Manager, cfgMgr *config.ConfigManage
 	mgr.Register(builtin.NewValidationMiddleware())
 
 	// Logging middleware (order: 2)
-	mgr.Register(builtin.NewLoggingMiddleware(
+	loggingMw := builtin.NewLoggingMiddleware(
 		logger,
 		loggingCfg.EnableRequestLogging != nil && *loggingCfg.EnableRequestLogging,
 		loggingCfg.EnableResponseLogging != nil && *loggingCfg.EnableResponseLogging,
-	))
+	)
+	mgr.Register(loggingMw)
 
-	// Timeout middleware (order: 3) - only if timeout is configured
-	if serverCfg.Timeout != nil {
-		mgr.Register(builtin.NewTimeoutMiddleware(serverCfg.GetTimeout(), logger))
-	}
+	// Timeout middleware (order: 3) - disabled while no timeout is configured
+	timeoutMw := builtin.NewTimeoutMiddleware(requestTimeout(serverCfg), logger)
+	mgr.Register(timeoutMw)
 
 	// Error handling middleware (order: 100) - always last
 	mgr.Register(builtin.NewErrorHandlingMiddleware(
 		logger,
 		loggingCfg.EnableErrorStack != nil && *loggingCfg.EnableErrorStack,
 	))
+
+	return loggingMw, timeoutMw
 }
 
+// requestTimeout returns the timeout applied to each request, or 0 for none
+func requestTimeout(settings *config.ServerSettings) time.Duration {
+	if settings.Timeout == nil {
+		return 0
+	}
+	return settings.GetTimeout()
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+)
+
+// mockNotice is the first content block of every tool result in mock mode
+const mockNotice = "[mock] NeuronMCP is running in mock mode: this result is canned data, no database or model was used."
+
+// flagMockResult marks resp as produced in mock mode, with a notice before
+// its content and mock set in its metadata, so neither clients nor models
+// mistake it for real data
+func flagMockResult(resp *middleware.MCPResponse) *middleware.MCPResponse {
+	if resp == nil {
+		return nil
+	}
+	flagged := *resp
+	flagged.Content = append([]middleware.ContentBlock{{Type: "text", Text: mockNotice}}, resp.Content...)
+	metadata := make(map[string]interface{}, len(resp.Metadata)+1)
+	for k, v := range resp.Metadata {
+		metadata[k] = v
+	}
+	metadata["mock"] = true
+	flagged.Metadata = metadata
+	return &flagged
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+)
+
+func TestFlagMockResult(t *testing.T) {
+	resp := &middleware.MCPResponse{
+		Content:  []middleware.ContentBlock{{Type: "text", Text: "[]"}},
+		Metadata: map[string]interface{}{"count": 0},
+	}
+	flagged := flagMockResult(resp)
+	if len(flagged.Content) != 2 || flagged.Content[0].Text != mockNotice || flagged.Content[1].Text != "[]" {
+		t.Errorf("content = %v", flagged.Content)
+	}
+	if flagged.Metadata["mock"] != true || flagged.Metadata["count"] != 0 {
+		t.Errorf("metadata = %v", flagged.Metadata)
+	}
+	if _, ok := resp.Metadata["mock"]; ok || len(resp.Content) != 1 {
+		t.Error("flagging modified the response")
+	}
+}
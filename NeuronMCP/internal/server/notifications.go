@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+// ChannelNotificationMethod is the MCP notification carrying a PostgreSQL
+// NOTIFY payload
+const ChannelNotificationMethod = "notifications/neurondb/channel"
+
+// channelNotification is the params of a ChannelNotificationMethod
+// notification
+type channelNotification struct {
+	Channel    string `json:"channel"`
+	Payload    string `json:"payload"`
+	PID        uint32 `json:"pid"`
+	ReceivedAt string `json:"received_at"`
+}
+
+// forwardNotification sends a PostgreSQL notification to the MCP client
+func (s *Server) forwardNotification(n *database.Notification) {
+	err := s.mcpServer.Notify(ChannelNotificationMethod, channelNotification{
+		Channel:    n.Channel,
+		Payload:    n.Payload,
+		PID:        n.PID,
+		ReceivedAt: n.ReceivedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		s.logger.Warn("Failed to forward channel notification", map[string]interface{}{
+			"channel": n.Channel,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// runListener forwards notifications until ctx is cancelled
+func (s *Server) runListener(ctx context.Context) {
+	if channels := s.listener.Channels(); len(channels) > 0 {
+		s.logger.Info("Listening for channel notifications", map[string]interface{}{
+			"channels": channels,
+		})
+	}
+	s.listener.Run(ctx, func(err error) {
+		s.logger.Warn("Channel listener disconnected, reconnecting", map[string]interface{}{
+			"error": err.Error(),
+		})
+	})
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+// withProgress sends the progress a tool reports to the client as
+// notifications/progress, when the call carries a progress token
+func (s *Server) withProgress(ctx context.Context, meta *mcp.RequestMeta) context.Context {
+	if meta == nil || meta.ProgressToken == nil {
+		return ctx
+	}
+	token := meta.ProgressToken
+	return tools.WithProgress(ctx, func(progress, total float64, message string) {
+		err := s.mcpServer.Notify(mcp.MethodProgress, mcp.ProgressNotification{
+			ProgressToken: token,
+			Progress:      progress,
+			Total:         total,
+			Message:       message,
+		})
+		if err != nil {
+			s.logger.Warn("Failed to send progress notification", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	})
+}
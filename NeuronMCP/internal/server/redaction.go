@@ -0,0 +1,59 @@
+package server
+
+import (
+	"github.com/neurondb/NeuronMCP/internal/policy"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+// redactToolResult masks sensitive values in a tool result according to the
+// redaction settings of the active policy. When anything was masked the
+// result's metadata gets a "redaction" note with the count per rule, so
+// clients and audits can tell the result was altered.
+func (s *Server) redactToolResult(toolName string, result *tools.ToolResult) *tools.ToolResult {
+	redactor := s.policy.Policy().Redactor()
+	if !redactor.Applies(toolName) {
+		return result
+	}
+
+	redacted := *result
+	report := policy.RedactionReport{}
+	if result.Data != nil {
+		data, dataReport := redactor.Redact(result.Data)
+		redacted.Data = data
+		for rule, n := range dataReport {
+			report[rule] += n
+		}
+	}
+	if result.Error != nil {
+		toolErr := *result.Error
+		toolErr.Message = redactor.RedactString(toolErr.Message, report)
+		if toolErr.Details != nil {
+			details, detailsReport := redactor.Redact(toolErr.Details)
+			toolErr.Details = details
+			for rule, n := range detailsReport {
+				report[rule] += n
+			}
+		}
+		redacted.Error = &toolErr
+	}
+	if report.Total() == 0 {
+		return result
+	}
+
+	metadata := make(map[string]interface{}, len(result.Metadata)+1)
+	for k, v := range result.Metadata {
+		metadata[k] = v
+	}
+	metadata["redaction"] = map[string]interface{}{
+		"redacted": report.Total(),
+		"rules":    map[string]int(report),
+	}
+	redacted.Metadata = metadata
+
+	s.logger.Info("Redacted tool result", map[string]interface{}{
+		"tool_name": toolName,
+		"redacted":  report.Total(),
+		"rules":     map[string]int(report),
+	})
+	return &redacted
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+	"github.com/neurondb/NeuronMCP/internal/policy"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+func TestRedactToolResult(t *testing.T) {
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policyFile, []byte(`{"redaction": {"enabled": true, "columns": ["token"], "exemptTools": ["postgresql_*"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := policy.NewEngine(policyFile, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{logger: logger, policy: engine}
+
+	result := tools.Success([]map[string]interface{}{{"id": 1, "email": "a@example.com", "token": "abc"}}, map[string]interface{}{"count": 1})
+	redacted := s.redactToolResult("vector_search", result)
+	rows := redacted.Data.([]map[string]interface{})
+	if rows[0]["email"] != "[REDACTED:email]" || rows[0]["token"] != "[REDACTED]" || rows[0]["id"] != 1 {
+		t.Errorf("rows = %v", rows)
+	}
+	note, ok := redacted.Metadata["redaction"].(map[string]interface{})
+	if !ok || note["redacted"] != 2 || redacted.Metadata["count"] != 1 {
+		t.Errorf("metadata = %v", redacted.Metadata)
+	}
+	if _, ok := result.Metadata["redaction"]; ok {
+		t.Error("redaction modified the tool's metadata")
+	}
+
+	if got := s.redactToolResult("postgresql_stats", result); got != result {
+		t.Error("exempt tool was redacted")
+	}
+	clean := tools.Success(map[string]interface{}{"rows": 0}, nil)
+	if got := s.redactToolResult("vector_search", clean); got != clean || got.Metadata != nil {
+		t.Errorf("clean result was altered: %+v", got)
+	}
+
+	failed := tools.Error("duplicate key a@example.com", "QUERY_ERROR", nil)
+	resp := s.formatToolError(s.redactToolResult("vector_search", failed))
+	if resp.Content[0].Text != "Error: duplicate key [REDACTED:email]" || resp.Metadata["redaction"] == nil {
+		t.Errorf("error response = %+v", resp)
+	}
+}
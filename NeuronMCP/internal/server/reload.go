@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+)
+
+// configReloadInterval is how often the config file is checked for changes
+// when watchConfig is enabled
+const configReloadInterval = 2 * time.Second
+
+// liveConfigFields are the settings a reload applies to the running server
+var liveConfigFields = map[string]bool{
+	"logging.level":                     true,
+	"logging.enableRequestLogging":      true,
+	"logging.enableResponseLogging":     true,
+	"server.timeout":                    true,
+	"server.policyFile":                 true,
+	"server.exportDir":                  true,
+	"server.readOnlyRole":               true,
+	"server.localFileDirs":              true,
+	"server.results.timezone":           true,
+	"server.results.timestamps":         true,
+	"server.results.numericPrecision":   true,
+	"features.models.embedding":         true,
+	"features.models.generation":        true,
+	"features.federation.remotes":       true,
+	"features.federation.timeoutMillis": true,
+}
+
+// isLiveConfigField reports whether a changed setting takes effect without a
+// restart. Feature flags are read on every tools/list, so they apply too.
+func isLiveConfigField(field string) bool {
+	if liveConfigFields[field] {
+		return true
+	}
+	return strings.HasPrefix(field, "features.") && strings.HasSuffix(field, ".enabled")
+}
+
+// ReloadResult lists the settings changed by a reload
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RequiresRestart []string `json:"requires_restart"`
+}
+
+// Reload re-reads the configuration and applies the settings that can change
+// while the server runs: log level, request and response logging, the
+// request timeout, the tool policy and feature flags. Other changed settings
+// are reported in RequiresRestart and keep their running values until the
+// server restarts. The tool policy file is re-read even if unchanged. When
+// the new configuration is invalid or its policy cannot be loaded, nothing
+// is applied.
+func (s *Server) Reload() (*ReloadResult, error) {
+	next, err := s.config.LoadFresh()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.Reload(next.Server.GetPolicyFile()); err != nil {
+		return nil, fmt.Errorf("failed to load tool policy: %w", err)
+	}
+
+	result := &ReloadResult{Applied: []string{}, RequiresRestart: []string{}}
+	for _, field := range config.ChangedFields(s.config.GetConfig(), next) {
+		if isLiveConfigField(field) {
+			result.Applied = append(result.Applied, field)
+		}
+	}
+	// Compared with the startup configuration so a pending change is
+	// reported again on every reload until the server restarts
+	for _, field := range config.ChangedFields(s.startConfig, next) {
+		if !isLiveConfigField(field) {
+			result.RequiresRestart = append(result.RequiresRestart, field)
+		}
+	}
+
+	s.logger.SetLevel(next.Logging.Level)
+	s.loggingMiddleware.SetOptions(
+		next.Logging.EnableRequestLogging != nil && *next.Logging.EnableRequestLogging,
+		next.Logging.EnableResponseLogging != nil && *next.Logging.EnableResponseLogging,
+	)
+	s.timeoutMiddleware.SetTimeout(requestTimeout(&next.Server))
+	s.config.Replace(next)
+
+	return result, nil
+}
+
+// reload runs Reload and logs its outcome
+func (s *Server) reload(trigger string) {
+	result, err := s.Reload()
+	if err != nil {
+		s.logger.Warn("Configuration reload failed, keeping current configuration", map[string]interface{}{
+			"trigger": trigger,
+			"error":   err.Error(),
+		})
+		return
+	}
+	s.logger.Info("Configuration reloaded", map[string]interface{}{
+		"trigger": trigger,
+		"path":    s.config.Path(),
+		"applied": result.Applied,
+	})
+	if len(result.RequiresRestart) > 0 {
+		s.logger.Warn("Configuration changes need a restart to take effect", map[string]interface{}{
+			"fields": result.RequiresRestart,
+		})
+	}
+}
+
+// watchConfig reloads the configuration on SIGHUP and, when watchConfig is
+// enabled, whenever the config file changes, until ctx is cancelled
+func (s *Server) watchConfig(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	var modTime time.Time
+	path := s.config.Path()
+	if s.config.GetServerSettings().GetWatchConfig() && path != "" {
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		ticker := time.NewTicker(configReloadInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.reload("SIGHUP")
+		case <-poll:
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			s.reload("file change")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+	"github.com/neurondb/NeuronMCP/internal/policy"
+)
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mcp-config.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"database": {"host": "db1"}, "server": {"timeout": 1000}, "logging": {"level": "info", "format": "json"}}`)
+
+	cfgMgr := config.NewConfigManager()
+	if _, err := cfgMgr.Load(path); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	logger := logging.NewLogger(cfgMgr.GetLoggingConfig())
+	engine, err := policy.NewEngine("", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loggingMw, timeoutMw := setupBuiltInMiddleware(middleware.NewManager(logger), cfgMgr, logger)
+	s := &Server{
+		config:            cfgMgr,
+		logger:            logger,
+		policy:            engine,
+		loggingMiddleware: loggingMw,
+		timeoutMiddleware: timeoutMw,
+		startConfig:       cfgMgr.GetConfig(),
+	}
+
+	policyFile := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(policyFile, []byte(`{"deny": ["drop_*"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	write(`{"database": {"host": "db2"}, "server": {"timeout": 2000, "policyFile": "` + policyFile + `"}, "logging": {"level": "debug", "format": "json"}}`)
+
+	result, err := s.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if want := []string{"logging.level", "server.policyFile", "server.timeout"}; !reflect.DeepEqual(result.Applied, want) {
+		t.Errorf("Applied = %v, want %v", result.Applied, want)
+	}
+	if want := []string{"database.host"}; !reflect.DeepEqual(result.RequiresRestart, want) {
+		t.Errorf("RequiresRestart = %v, want %v", result.RequiresRestart, want)
+	}
+	if logger.Level() != "debug" {
+		t.Errorf("log level = %s, want debug", logger.Level())
+	}
+	if timeoutMw.Timeout() != 2*time.Second {
+		t.Errorf("timeout = %v, want 2s", timeoutMw.Timeout())
+	}
+	if engine.Evaluate("drop_index", nil).Allowed {
+		t.Error("policy file was not loaded")
+	}
+
+	// A pending restart is reported again; an invalid file changes nothing
+	result, err = s.Reload()
+	if err != nil || len(result.Applied) != 0 || !reflect.DeepEqual(result.RequiresRestart, []string{"database.host"}) {
+		t.Errorf("second Reload() = %+v, %v", result, err)
+	}
+	write(`{"database": {"host": "db2"}, "logging": {"level": "verbose"}}`)
+	if _, err := s.Reload(); err == nil {
+		t.Error("Reload() of an invalid config succeeded")
+	}
+	if logger.Level() != "debug" || timeoutMw.Timeout() != 2*time.Second {
+		t.Error("invalid config was partly applied")
+	}
+}
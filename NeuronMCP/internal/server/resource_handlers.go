@@ -13,6 +13,9 @@ func (s *Server) setupResourceHandlers() {
 	// List resources handler
 	s.mcpServer.SetHandler("resources/list", s.handleListResources)
 
+	// Resource templates handler
+	s.mcpServer.SetHandler("resources/templates/list", s.handleListResourceTemplates)
+
 	// Read resource handler
 	s.mcpServer.SetHandler("resources/read", s.handleReadResource)
 }
@@ -34,6 +37,23 @@ func (s *Server) handleListResources(ctx context.Context, params json.RawMessage
 	return mcp.ListResourcesResponse{Resources: mcpDefs}, nil
 }
 
+// handleListResourceTemplates handles the resources/templates/list request
+func (s *Server) handleListResourceTemplates(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	templates := s.resources.ListResourceTemplates()
+
+	mcpTemplates := make([]mcp.ResourceTemplate, len(templates))
+	for i, t := range templates {
+		mcpTemplates[i] = mcp.ResourceTemplate{
+			URITemplate: t.URITemplate,
+			Name:        t.Name,
+			Description: t.Description,
+			MimeType:    t.MimeType,
+		}
+	}
+
+	return mcp.ListResourceTemplatesResponse{ResourceTemplates: mcpTemplates}, nil
+}
+
 // handleReadResource handles the resources/read request
 func (s *Server) handleReadResource(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var req mcp.ReadResourceRequest
@@ -52,6 +72,7 @@ func (s *Server) handleReadResource(ctx context.Context, params json.RawMessage)
 			URI:      content.URI,
 			MimeType: content.MimeType,
 			Text:     content.Text,
+			Meta:     content.Meta,
 		}
 	}
 
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+// resultFormatArgument is the tools/call argument choosing how the rows of
+// a query-style tool are encoded
+const resultFormatArgument = "response_format"
+
+// applyResultFormat removes the response_format argument from arguments and
+// returns a context asking for that format. json is the default and needs
+// no context.
+func (s *Server) applyResultFormat(ctx context.Context, toolName string, arguments map[string]interface{}) (context.Context, error) {
+	value, ok := arguments[resultFormatArgument]
+	if !ok {
+		return ctx, nil
+	}
+	delete(arguments, resultFormatArgument)
+
+	format, ok := value.(string)
+	if !ok || !tools.ValidResultFormat(format) {
+		return nil, fmt.Errorf("invalid %s argument for tool '%s': expected json, csv or arrow, got %v", resultFormatArgument, toolName, value)
+	}
+	if format == tools.ResultFormatJSON {
+		return ctx, nil
+	}
+	if !tools.SupportsResultFormat(toolName) {
+		return nil, fmt.Errorf("tool '%s' does not support %s '%s'", toolName, resultFormatArgument, format)
+	}
+	return tools.WithResultFormat(ctx, format), nil
+}
+
+// withResultFormatArgument returns a copy of schema that also accepts the
+// response_format argument, leaving the registry's schema unmodified
+func withResultFormatArgument(schema map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	if existing, ok := schema["properties"].(map[string]interface{}); ok {
+		for key, value := range existing {
+			properties[key] = value
+		}
+	}
+	properties[resultFormatArgument] = map[string]interface{}{
+		"type":        "string",
+		"enum":        []interface{}{tools.ResultFormatJSON, tools.ResultFormatCSV, tools.ResultFormatArrow},
+		"default":     tools.ResultFormatJSON,
+		"description": "Encoding of the result rows: JSON objects, CSV with a header row, or a base64 Arrow IPC stream",
+	}
+
+	formatted := make(map[string]interface{}, len(schema)+1)
+	for key, value := range schema {
+		formatted[key] = value
+	}
+	formatted["properties"] = properties
+	return formatted
+}
+
+// formatTabularResult returns the rows of a successful result encoded as
+// the call asked. The encoded text is the content; the format, its content
+// type, the row count and any other fields of the result go in the
+// metadata.
+func (s *Server) formatTabularResult(ctx context.Context, toolName string, result *tools.ToolResult) (*middleware.MCPResponse, error) {
+	formatted, err := tools.FormatResult(ctx, result.Data)
+	if err != nil {
+		return &middleware.MCPResponse{
+			Content: []middleware.ContentBlock{
+				{Type: "text", Text: fmt.Sprintf("Error: cannot encode result of %s as %s: %v", toolName, tools.ResultFormatFromContext(ctx), err)},
+			},
+			IsError: true,
+		}, nil
+	}
+
+	metadata := make(map[string]interface{}, len(result.Metadata)+len(formatted.Fields)+3)
+	for k, v := range formatted.Fields {
+		metadata[k] = v
+	}
+	for k, v := range result.Metadata {
+		metadata[k] = v
+	}
+	metadata["format"] = formatted.Format
+	metadata["content_type"] = formatted.ContentType
+	metadata["rows"] = formatted.Rows
+	if formatted.Format == tools.ResultFormatArrow {
+		metadata["encoding"] = "base64"
+	}
+
+	if s.maxResultSize > 0 && len(formatted.Text) > s.maxResultSize {
+		return s.spillToolResult(toolName, []byte(formatted.Text), metadata), nil
+	}
+	return &middleware.MCPResponse{
+		Content: []middleware.ContentBlock{
+			{Type: "text", Text: formatted.Text},
+		},
+		Metadata: metadata,
+	}, nil
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+func TestApplyResultFormat(t *testing.T) {
+	s := &Server{}
+
+	args := map[string]interface{}{"table": "docs", resultFormatArgument: "csv"}
+	ctx, err := s.applyResultFormat(context.Background(), "vector_search", args)
+	if err != nil || tools.ResultFormatFromContext(ctx) != tools.ResultFormatCSV {
+		t.Fatalf("csv not requested: err=%v", err)
+	}
+	if _, ok := args[resultFormatArgument]; ok {
+		t.Error("response_format argument was passed on to the tool")
+	}
+
+	args[resultFormatArgument] = "json"
+	if ctx, err := s.applyResultFormat(context.Background(), "drop_index", args); err != nil || tools.ResultFormatFromContext(ctx) != tools.ResultFormatJSON {
+		t.Errorf("json rejected or changed the context: err=%v", err)
+	}
+
+	args[resultFormatArgument] = "arrow"
+	if _, err := s.applyResultFormat(context.Background(), "drop_index", args); err == nil {
+		t.Error("arrow accepted by a tool without rows")
+	}
+
+	args[resultFormatArgument] = "parquet"
+	if _, err := s.applyResultFormat(context.Background(), "vector_search", args); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestFormatTabularResult(t *testing.T) {
+	s := &Server{}
+	ctx := tools.WithResultFormat(context.Background(), tools.ResultFormatCSV)
+	result := tools.Success(map[string]interface{}{
+		"connections": []map[string]interface{}{{"pid": int32(42)}},
+		"count":       1,
+	}, map[string]interface{}{"tool": "postgresql_connections"})
+
+	resp, err := s.formatTabularResult(ctx, "postgresql_connections", result)
+	if err != nil || resp.IsError {
+		t.Fatalf("formatTabularResult() = %+v, %v", resp, err)
+	}
+	if got := resp.Content[0].Text; !strings.HasPrefix(got, "pid\n42\n") {
+		t.Errorf("content = %q", got)
+	}
+	if resp.Metadata["count"] != 1 || resp.Metadata["rows"] != 1 || resp.Metadata["content_type"] != "text/csv" {
+		t.Errorf("metadata = %v", resp.Metadata)
+	}
+
+	resp, _ = s.formatTabularResult(ctx, "postgresql_connections", tools.Success("no rows", nil))
+	if !resp.IsError {
+		t.Error("result without rows was encoded")
+	}
+}
+
+func TestApplyResultOptions(t *testing.T) {
+	s := &Server{}
+
+	args := map[string]interface{}{
+		"query":               "SELECT now()",
+		resultOptionsArgument: map[string]interface{}{"timezone": "UTC", "timestamps": "iso8601", "numeric_precision": float64(3)},
+	}
+	ctx := context.Background()
+	got, err := s.applyResultOptions(ctx, "run_sql_readonly", args)
+	if err != nil {
+		t.Fatalf("applyResultOptions() error = %v", err)
+	}
+	if got == ctx {
+		t.Error("options were not added to the context")
+	}
+	if _, ok := args[resultOptionsArgument]; ok {
+		t.Error("result_options argument was passed on to the tool")
+	}
+
+	// Without the argument or settings the context is unchanged
+	if got, err := s.applyResultOptions(ctx, "drop_index", map[string]interface{}{}); err != nil || got != ctx {
+		t.Errorf("context changed without options: err=%v", err)
+	}
+
+	for _, options := range []interface{}{
+		"UTC",
+		map[string]interface{}{"timezone": "Nowhere/Else"},
+		map[string]interface{}{"numeric_precision": 1.5},
+		map[string]interface{}{"locale": "de-DE"},
+	} {
+		args := map[string]interface{}{resultOptionsArgument: options}
+		if _, err := s.applyResultOptions(ctx, "run_sql_readonly", args); err == nil {
+			t.Errorf("result_options %v accepted", options)
+		}
+	}
+
+	args = map[string]interface{}{resultOptionsArgument: map[string]interface{}{"timestamps": "iso8601"}}
+	if _, err := s.applyResultOptions(ctx, "drop_index", args); err == nil {
+		t.Error("result_options accepted by a tool without rows")
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+// resultOptionsArgument is the tools/call argument post-processing the
+// values of a query-style tool's rows
+const resultOptionsArgument = "result_options"
+
+// applyResultOptions removes the result_options argument from arguments and
+// returns a context post-processing query results with the configured
+// result settings overridden by that argument
+func (s *Server) applyResultOptions(ctx context.Context, toolName string, arguments map[string]interface{}) (context.Context, error) {
+	opts := tools.DefaultResultOptions()
+	if s.config != nil {
+		settings := s.config.GetServerSettings().GetResultSettings()
+		var timezone, timestamps string
+		if settings.Timezone != nil {
+			timezone = *settings.Timezone
+		}
+		if settings.Timestamps != nil {
+			timestamps = *settings.Timestamps
+		}
+		var err error
+		if opts, err = tools.ParseResultOptions(opts, timezone, timestamps, settings.NumericPrecision); err != nil {
+			return nil, fmt.Errorf("invalid server results setting: %w", err)
+		}
+	}
+
+	value, ok := arguments[resultOptionsArgument]
+	if ok {
+		delete(arguments, resultOptionsArgument)
+		if !tools.SupportsResultFormat(toolName) {
+			return nil, fmt.Errorf("tool '%s' does not support %s", toolName, resultOptionsArgument)
+		}
+		options, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s argument for tool '%s': expected an object, got %T", resultOptionsArgument, toolName, value)
+		}
+		var timezone, timestamps string
+		var precision *int
+		for key, v := range options {
+			switch key {
+			case "timezone":
+				if timezone, ok = v.(string); !ok {
+					return nil, fmt.Errorf("invalid %s.timezone for tool '%s': expected a string, got %T", resultOptionsArgument, toolName, v)
+				}
+			case "timestamps":
+				if timestamps, ok = v.(string); !ok {
+					return nil, fmt.Errorf("invalid %s.timestamps for tool '%s': expected a string, got %T", resultOptionsArgument, toolName, v)
+				}
+			case "numeric_precision":
+				n, ok := v.(float64)
+				if !ok || n != float64(int(n)) {
+					return nil, fmt.Errorf("invalid %s.numeric_precision for tool '%s': expected an integer, got %v", resultOptionsArgument, toolName, v)
+				}
+				places := int(n)
+				precision = &places
+			default:
+				return nil, fmt.Errorf("invalid %s argument for tool '%s': unknown option '%s'", resultOptionsArgument, toolName, key)
+			}
+		}
+		var err error
+		if opts, err = tools.ParseResultOptions(opts, timezone, timestamps, precision); err != nil {
+			return nil, fmt.Errorf("invalid %s argument for tool '%s': %w", resultOptionsArgument, toolName, err)
+		}
+	}
+	return tools.WithResultOptions(ctx, opts), nil
+}
+
+// withResultOptionsArgument returns a copy of schema that also accepts the
+// result_options argument, leaving the registry's schema unmodified
+func withResultOptionsArgument(schema map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	if existing, ok := schema["properties"].(map[string]interface{}); ok {
+		for key, value := range existing {
+			properties[key] = value
+		}
+	}
+	properties[resultOptionsArgument] = map[string]interface{}{
+		"type":        "object",
+		"description": "Post-processing of result values, overriding the server's results settings",
+		"properties": map[string]interface{}{
+			"timezone": map[string]interface{}{
+				"type":        "string",
+				"description": "IANA time zone timestamptz values are converted to, such as 'Europe/Berlin'",
+			},
+			"timestamps": map[string]interface{}{
+				"type":        "string",
+				"enum":        []interface{}{tools.TimestampsRaw, tools.TimestampsISO8601},
+				"description": "iso8601 returns dates, times, timestamps and intervals as ISO-8601 strings",
+			},
+			"numeric_precision": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     0,
+				"maximum":     tools.MaxNumericPrecision,
+				"description": "Decimal places floats and numerics are rounded to",
+			},
+		},
+		"additionalProperties": false,
+	}
+
+	formatted := make(map[string]interface{}, len(schema)+1)
+	for key, value := range schema {
+		formatted[key] = value
+	}
+	formatted["properties"] = properties
+	return formatted
+}
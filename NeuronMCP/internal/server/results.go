@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+)
+
+const (
+	// resultTTL is how long a spilled result stays readable
+	resultTTL = 1 * time.Hour
+	// resultPreviewSize is the number of bytes of a spilled result returned
+	// inline
+	resultPreviewSize = 4096
+)
+
+// spillToolResult stores a result larger than maxResultSize in the result
+// store and returns a truncated preview with the URI of the full result
+func (s *Server) spillToolResult(toolName string, resultJSON []byte, metadata map[string]interface{}) *middleware.MCPResponse {
+	if s.results == nil {
+		return &middleware.MCPResponse{
+			Content: []middleware.ContentBlock{
+				{Type: "text", Text: fmt.Sprintf("Error: result of %s is %d bytes, over the %d byte limit, and the result store is unavailable; narrow the request (e.g. a smaller limit)", toolName, len(resultJSON), s.maxResultSize)},
+			},
+			IsError: true,
+			Metadata: map[string]interface{}{
+				"code":              "RESULT_TOO_LARGE",
+				"result_size_bytes": len(resultJSON),
+				"max_result_size":   s.maxResultSize,
+			},
+		}
+	}
+
+	uri, err := s.results.Save(toolName, resultJSON)
+	if err != nil {
+		s.logger.Warn("Failed to spill tool result", map[string]interface{}{
+			"tool_name":  toolName,
+			"size_bytes": len(resultJSON),
+			"error":      err.Error(),
+		})
+		return &middleware.MCPResponse{
+			Content: []middleware.ContentBlock{
+				{Type: "text", Text: fmt.Sprintf("Error: result of %s is %d bytes, over the %d byte limit, and could not be stored: %v", toolName, len(resultJSON), s.maxResultSize, err)},
+			},
+			IsError: true,
+			Metadata: map[string]interface{}{
+				"code":              "RESULT_TOO_LARGE",
+				"result_size_bytes": len(resultJSON),
+				"max_result_size":   s.maxResultSize,
+			},
+		}
+	}
+
+	previewSize := resultPreviewSize
+	if previewSize > s.maxResultSize/2 {
+		previewSize = s.maxResultSize / 2
+	}
+	summary := map[string]interface{}{
+		"truncated":         true,
+		"resource_uri":      uri,
+		"result_size_bytes": len(resultJSON),
+		"max_result_size":   s.maxResultSize,
+		"preview":           truncateUTF8(resultJSON, previewSize),
+		"message":           fmt.Sprintf("The full result is available for %s via resources/read", resultTTL),
+	}
+	summaryJSON, _ := json.MarshalIndent(summary, "", "  ")
+
+	responseMetadata := make(map[string]interface{}, len(metadata)+3)
+	for k, v := range metadata {
+		responseMetadata[k] = v
+	}
+	responseMetadata["truncated"] = true
+	responseMetadata["resource_uri"] = uri
+	responseMetadata["result_size_bytes"] = len(resultJSON)
+
+	s.logger.Info("Spilled oversized tool result", map[string]interface{}{
+		"tool_name":    toolName,
+		"size_bytes":   len(resultJSON),
+		"resource_uri": uri,
+	})
+
+	return &middleware.MCPResponse{
+		Content: []middleware.ContentBlock{
+			{Type: "text", Text: string(summaryJSON)},
+		},
+		Metadata: responseMetadata,
+	}
+}
+
+// truncateUTF8 returns at most n bytes of data without splitting a
+// multi-byte character
+func truncateUTF8(data []byte, n int) string {
+	if len(data) <= n {
+		return string(data)
+	}
+	for n > 0 && !utf8.RuneStart(data[n]) {
+		n--
+	}
+	return string(data[:n])
+}
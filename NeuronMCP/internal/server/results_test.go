@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+	"github.com/neurondb/NeuronMCP/internal/resources"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+func TestFormatToolResultSpillsLargeResults(t *testing.T) {
+	store, err := resources.NewResultStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewResultStore() error = %v", err)
+	}
+	manager := resources.NewManager(database.NewDatabase())
+	manager.SetResultStore(store)
+
+	s := &Server{
+		logger:        logging.NewLogger(config.NewConfigManager().GetLoggingConfig()),
+		resources:     manager,
+		results:       store,
+		maxResultSize: 256,
+	}
+
+	small, err := s.formatToolResult("vector_search", tools.Success(map[string]interface{}{"rows": 1}, nil))
+	if err != nil {
+		t.Fatalf("formatToolResult() error = %v", err)
+	}
+	if _, ok := small.Metadata["resource_uri"]; ok {
+		t.Fatalf("small result was spilled: %v", small.Metadata)
+	}
+
+	rows := make([]string, 100)
+	for i := range rows {
+		rows[i] = "row with some content é"
+	}
+	large, err := s.formatToolResult("vector_search", tools.Success(map[string]interface{}{"rows": rows}, nil))
+	if err != nil {
+		t.Fatalf("formatToolResult() error = %v", err)
+	}
+	if large.IsError {
+		t.Fatalf("large result returned an error: %s", large.Content[0].Text)
+	}
+	if len(large.Content[0].Text) > 1024 {
+		t.Errorf("inline response is %d bytes, want a truncated preview", len(large.Content[0].Text))
+	}
+
+	var summary map[string]interface{}
+	if err := json.Unmarshal([]byte(large.Content[0].Text), &summary); err != nil {
+		t.Fatalf("summary is not JSON: %v", err)
+	}
+	uri, _ := summary["resource_uri"].(string)
+	if !strings.HasPrefix(uri, resources.ResultURIPrefix) {
+		t.Fatalf("resource_uri = %q, want prefix %q", uri, resources.ResultURIPrefix)
+	}
+
+	resp, err := manager.HandleResource(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("HandleResource() error = %v", err)
+	}
+	var full map[string][]string
+	if err := json.Unmarshal([]byte(resp.Contents[0].Text), &full); err != nil {
+		t.Fatalf("spilled result is not JSON: %v", err)
+	}
+	if len(full["rows"]) != len(rows) {
+		t.Errorf("spilled result has %d rows, want %d", len(full["rows"]), len(rows))
+	}
+
+	store.Close()
+	if _, err := manager.HandleResource(context.Background(), uri); err == nil {
+		t.Error("HandleResource() succeeded after the store was closed")
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	if got := truncateUTF8([]byte("héllo"), 2); got != "h" {
+		t.Errorf("truncateUTF8 split a character: %q", got)
+	}
+	if got := truncateUTF8([]byte("abc"), 10); got != "abc" {
+		t.Errorf("truncateUTF8(short) = %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+// clientRoots lists the client's roots for tools reading local files
+type clientRoots struct {
+	mcpServer *mcp.Server
+}
+
+// Roots returns the directories of the client's file:// roots. Roots with
+// other schemes name nothing on this filesystem and are left out.
+func (c *clientRoots) Roots(ctx context.Context) ([]string, error) {
+	roots, err := c.mcpServer.ListRoots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rootPaths(roots), nil
+}
+
+// rootPaths converts file:// root URIs to local paths
+func rootPaths(roots []mcp.Root) []string {
+	paths := make([]string, 0, len(roots))
+	for _, root := range roots {
+		u, err := url.Parse(root.URI)
+		if err != nil || u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") || u.Path == "" {
+			continue
+		}
+		paths = append(paths, filepath.FromSlash(u.Path))
+	}
+	return paths
+}
+
+// withClientRoots attaches the client's roots to ctx when the client
+// supports roots, so file paths given to tools are checked against them
+func (s *Server) withClientRoots(ctx context.Context) context.Context {
+	if !s.mcpServer.ClientSupportsRoots() {
+		return ctx
+	}
+	return tools.WithRoots(ctx, &clientRoots{mcpServer: s.mcpServer})
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+func TestRootPaths(t *testing.T) {
+	roots := []mcp.Root{
+		{URI: "file:///home/user/project", Name: "project"},
+		{URI: "file://localhost/srv/docs%20archive"},
+		{URI: "https://example.com/repo"},
+		{URI: "file://otherhost/share"},
+	}
+	want := []string{"/home/user/project", "/srv/docs archive"}
+	if got := rootPaths(roots); !reflect.DeepEqual(got, want) {
+		t.Errorf("rootPaths() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+// databaseArgument is the tools/call argument naming the database target a
+// call runs on
+const databaseArgument = "database"
+
+// routeDatabase removes the database argument from arguments and returns a
+// context routing the call to that target. Without the argument the call
+// runs on the default database. Only configured targets are accepted.
+func (s *Server) routeDatabase(ctx context.Context, toolName string, arguments map[string]interface{}) (context.Context, error) {
+	value, ok := arguments[databaseArgument]
+	if !ok {
+		return ctx, nil
+	}
+	delete(arguments, databaseArgument)
+
+	name, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s argument for tool '%s': expected a target name string, got %T", databaseArgument, toolName, value)
+	}
+	db, err := s.targets.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot route tool '%s': %w", toolName, err)
+	}
+	if name != "" && name != config.DefaultDatabaseTarget {
+		s.logger.Debug("Routing tool call", map[string]interface{}{
+			"tool_name": toolName,
+			"database":  name,
+		})
+	}
+	return tools.WithDatabase(ctx, db), nil
+}
+
+// withDatabaseArgument returns a copy of schema that also accepts the
+// database argument, limited to targets. schema itself is not modified,
+// since the registry shares it between requests.
+func withDatabaseArgument(schema map[string]interface{}, targets []string) map[string]interface{} {
+	enum := make([]interface{}, len(targets))
+	for i, name := range targets {
+		enum[i] = name
+	}
+
+	properties := make(map[string]interface{})
+	if existing, ok := schema["properties"].(map[string]interface{}); ok {
+		for key, value := range existing {
+			properties[key] = value
+		}
+	}
+	properties[databaseArgument] = map[string]interface{}{
+		"type":        "string",
+		"enum":        enum,
+		"default":     config.DefaultDatabaseTarget,
+		"description": "Database target to run on",
+	}
+
+	routed := make(map[string]interface{}, len(schema)+1)
+	for key, value := range schema {
+		routed[key] = value
+	}
+	routed["properties"] = properties
+	return routed
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+func TestRouteDatabase(t *testing.T) {
+	primary := database.NewDatabase()
+	host := "staging.internal"
+	s := &Server{
+		logger:  logging.NewLogger(&config.LoggingConfig{Level: "error"}),
+		targets: database.NewTargets(primary, map[string]config.DatabaseConfig{"staging": {Host: &host}}),
+	}
+
+	args := map[string]interface{}{"table": "docs"}
+	ctx, err := s.routeDatabase(context.Background(), "vector_search", args)
+	if err != nil || tools.DatabaseFromContext(ctx, nil) != nil {
+		t.Errorf("no argument routed the call: err=%v", err)
+	}
+
+	args[databaseArgument] = "default"
+	ctx, err = s.routeDatabase(context.Background(), "vector_search", args)
+	if err != nil || tools.DatabaseFromContext(ctx, nil) != primary {
+		t.Errorf("default target = %v", err)
+	}
+	if _, ok := args[databaseArgument]; ok {
+		t.Error("database argument was passed on to the tool")
+	}
+
+	args[databaseArgument] = "prod"
+	if _, err := s.routeDatabase(context.Background(), "vector_search", args); !errors.Is(err, database.ErrUnknownTarget) {
+		t.Errorf("unknown target error = %v", err)
+	}
+
+	args[databaseArgument] = 3.0
+	if _, err := s.routeDatabase(context.Background(), "vector_search", args); err == nil {
+		t.Error("non-string target accepted")
+	}
+}
+
+func TestWithDatabaseArgument(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"table": map[string]interface{}{"type": "string"}},
+		"required":   []interface{}{"table"},
+	}
+	routed := withDatabaseArgument(schema, []string{"default", "staging"})
+
+	properties := routed["properties"].(map[string]interface{})
+	if _, ok := properties["table"]; !ok {
+		t.Error("existing property dropped")
+	}
+	arg, ok := properties[databaseArgument].(map[string]interface{})
+	if !ok || len(arg["enum"].([]interface{})) != 2 {
+		t.Errorf("database property = %v", properties[databaseArgument])
+	}
+	if _, ok := schema["properties"].(map[string]interface{})[databaseArgument]; ok {
+		t.Error("registry schema was modified")
+	}
+}
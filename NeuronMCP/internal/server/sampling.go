@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neurondb/NeuronMCP/internal/tools"
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+// clientSampler forwards tool sampling requests to the MCP client
+type clientSampler struct {
+	mcpServer *mcp.Server
+}
+
+// Sample sends a sampling/createMessage request to the client
+func (c *clientSampler) Sample(ctx context.Context, req *tools.SamplingRequest) (*tools.SamplingResult, error) {
+	msgReq := &mcp.CreateMessageRequest{
+		SystemPrompt: req.SystemPrompt,
+		MaxTokens:    req.MaxTokens,
+		Temperature:  req.Temperature,
+	}
+	for _, m := range req.Messages {
+		msgReq.Messages = append(msgReq.Messages, mcp.SamplingMessage{
+			Role:    m.Role,
+			Content: mcp.ContentBlock{Type: "text", Text: m.Text},
+		})
+	}
+	if req.ModelHint != "" {
+		msgReq.ModelPreferences = &mcp.ModelPreferences{
+			Hints: []mcp.ModelHint{{Name: req.ModelHint}},
+		}
+	}
+
+	result, err := c.mcpServer.CreateMessage(ctx, msgReq)
+	if err != nil {
+		return nil, err
+	}
+	if result.Content.Type != "text" {
+		return nil, fmt.Errorf("client returned %s content, expected text", result.Content.Type)
+	}
+	return &tools.SamplingResult{
+		Text:       result.Content.Text,
+		Model:      result.Model,
+		StopReason: result.StopReason,
+	}, nil
+}
+
+// withClientSampler attaches a sampler to ctx when the client supports
+// sampling, so tools can fall back to the client's model
+func (s *Server) withClientSampler(ctx context.Context) context.Context {
+	if !s.mcpServer.ClientSupportsSampling() {
+		return ctx
+	}
+	return tools.WithSampler(ctx, &clientSampler{mcpServer: s.mcpServer})
+}
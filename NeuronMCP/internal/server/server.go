@@ -3,16 +3,22 @@ package server
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/neurondb/NeuronMCP/internal/config"
 	"github.com/neurondb/NeuronMCP/internal/database"
 	"github.com/neurondb/NeuronMCP/internal/logging"
 	"github.com/neurondb/NeuronMCP/internal/middleware"
+	"github.com/neurondb/NeuronMCP/internal/middleware/builtin"
+	"github.com/neurondb/NeuronMCP/internal/policy"
 	"github.com/neurondb/NeuronMCP/internal/resources"
 	"github.com/neurondb/NeuronMCP/internal/tools"
 	"github.com/neurondb/NeuronMCP/pkg/mcp"
 )
 
+// policyReloadInterval is how often the policy file is checked for changes
+const policyReloadInterval = 2 * time.Second
+
 // Server is the main MCP server
 type Server struct {
 	mcpServer    *mcp.Server
@@ -22,10 +28,41 @@ type Server struct {
 	middleware   *middleware.Manager
 	toolRegistry *tools.ToolRegistry
 	resources    *resources.Manager
+	policy       *policy.Engine
+
+	results       *resources.ResultStore
+	maxResultSize int
+	// usage aggregates tool calls for the tool usage resource
+	usage *resources.UsageStats
+
+	listener *database.Listener
+	// sessions holds the temporary tables of the MCP session
+	sessions *database.SessionState
+	// targets are the databases tool calls can be routed to
+	targets *database.Targets
+
+	loggingMiddleware *builtin.LoggingMiddleware
+	timeoutMiddleware *builtin.TimeoutMiddleware
+	// startConfig is the configuration the server started with
+	startConfig *config.ServerConfig
+	// mock answers tool queries in mock mode, when no database is used
+	mock *tools.MockDatabase
+}
+
+// Options change how a server runs
+type Options struct {
+	// Mock runs the server without a database. Tool queries are answered
+	// with canned data by a tools.MockDatabase and results are flagged.
+	Mock bool
 }
 
 // NewServer creates a new server
 func NewServer() (*Server, error) {
+	return NewServerWithOptions(Options{})
+}
+
+// NewServerWithOptions creates a new server with opts
+func NewServerWithOptions(opts Options) (*Server, error) {
 	cfgMgr := config.NewConfigManager()
 	_, err := cfgMgr.Load("")
 	if err != nil {
@@ -47,7 +84,11 @@ func NewServer() (*Server, error) {
 	
 	// Try to connect, but don't fail server startup if it fails
 	// The server can start and tools will fail gracefully with proper error messages
-	if err := db.Connect(dbCfg); err != nil {
+	if opts.Mock {
+		logger.Warn("Running in mock mode", map[string]interface{}{
+			"note": "No database is used. Tools return canned data flagged as mock.",
+		})
+	} else if err := db.Connect(dbCfg); err != nil {
 		logger.Warn("Failed to connect to database at startup", map[string]interface{}{
 			"error": err.Error(),
 			"host":     dbCfg.GetHost(),
@@ -67,24 +108,78 @@ func NewServer() (*Server, error) {
 
 	serverSettings := cfgMgr.GetServerSettings()
 	mcpServer := mcp.NewServer(serverSettings.GetName(), serverSettings.GetVersion())
+	mcpServer.SetMaxConcurrentRequests(serverSettings.GetMaxConcurrentRequests())
 
 	mwManager := middleware.NewManager(logger)
-	setupBuiltInMiddleware(mwManager, cfgMgr, logger)
+	loggingMw, timeoutMw := setupBuiltInMiddleware(mwManager, cfgMgr, logger)
 
 	toolRegistry := tools.NewToolRegistry(db, logger)
 	tools.RegisterAllTools(toolRegistry, db, logger)
 
 	resourcesManager := resources.NewManager(db)
 
+	policyEngine, err := policy.NewEngine(serverSettings.GetPolicyFile(), logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tool policy: %w", err)
+	}
+	if path := policyEngine.Path(); path != "" {
+		logger.Info("Loaded tool policy", map[string]interface{}{
+			"path":      path,
+			"read_only": policyEngine.Policy().ReadOnly,
+		})
+	}
+
 	s := &Server{
-		mcpServer:    mcpServer,
-		db:           db,
-		config:       cfgMgr,
-		logger:       logger,
-		middleware:   mwManager,
-		toolRegistry: toolRegistry,
-		resources:    resourcesManager,
+		mcpServer:     mcpServer,
+		db:            db,
+		config:        cfgMgr,
+		logger:        logger,
+		middleware:    mwManager,
+		toolRegistry:  toolRegistry,
+		resources:     resourcesManager,
+		policy:        policyEngine,
+		targets:       database.NewTargets(db, cfgMgr.GetDatabaseTargets()),
+		sessions:      database.NewSessionState(db),
+		maxResultSize: serverSettings.GetMaxResultSize(),
+
+		loggingMiddleware: loggingMw,
+		timeoutMiddleware: timeoutMw,
+		startConfig:       cfgMgr.GetConfig(),
+		usage:             resources.NewUsageStats(),
+	}
+	if opts.Mock {
+		s.mock = tools.NewMockDatabase()
+	}
+	if s.maxResultSize > 0 {
+		results, err := resources.NewResultStore(serverSettings.GetResultDir(), resultTTL)
+		if err != nil {
+			// Oversized results are rejected instead of spilled
+			logger.Warn("Result store unavailable", map[string]interface{}{
+				"dir":   serverSettings.GetResultDir(),
+				"error": err.Error(),
+			})
+		} else {
+			s.results = results
+			resourcesManager.SetResultStore(results)
+		}
+	}
+
+	if path := serverSettings.GetUsageFile(); path != "" {
+		if err := s.usage.Load(path); err != nil {
+			// Statistics start over and the file is replaced on the next save
+			logger.Warn("Failed to load tool usage statistics", map[string]interface{}{
+				"path":  path,
+				"error": err.Error(),
+			})
+		}
 	}
+	resourcesManager.Register(resources.NewUsageResource(s.usage, s.offeredToolNames))
+
+	listener, err := database.NewListener(db, serverSettings.GetListenChannels(), s.forwardNotification)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen channel: %w", err)
+	}
+	s.listener = listener
 
 	s.setupHandlers()
 
@@ -94,17 +189,27 @@ func NewServer() (*Server, error) {
 func (s *Server) setupHandlers() {
 	s.setupToolHandlers()
 	s.setupResourceHandlers()
+	s.setupCompletionHandlers()
 	
-	// Set capabilities
+	// Set capabilities. Tool versioning tells clients that tools/list
+	// reports schema versions and tools/call accepts _meta.toolVersion.
 	s.mcpServer.SetCapabilities(mcp.ServerCapabilities{
-		Tools:     make(map[string]interface{}),
-		Resources: make(map[string]interface{}),
+		Tools:       map[string]interface{}{"versioning": true},
+		Resources:   make(map[string]interface{}),
+		Completions: make(map[string]interface{}),
 	})
 }
 
 // Start starts the server
 func (s *Server) Start(ctx context.Context) error {
 	s.logger.Info("Starting Neurondb MCP server", nil)
+	go s.policy.Watch(ctx, policyReloadInterval)
+	go s.watchConfig(ctx)
+	go s.persistUsage(ctx)
+	if s.mock == nil {
+		go s.runListener(ctx)
+		go s.warmupModels(ctx)
+	}
 	// Run the MCP server - this will block until context is cancelled or EOF
 	err := s.mcpServer.Run(ctx)
 	if err != nil && err != context.Canceled {
@@ -118,6 +223,12 @@ func (s *Server) Start(ctx context.Context) error {
 // Stop stops the server
 func (s *Server) Stop() error {
 	s.logger.Info("Stopping Neurondb MCP server", nil)
+	if s.results != nil {
+		s.results.Close()
+	}
+	s.saveUsage()
+	s.sessions.Close()
+	s.targets.Close()
 	s.db.Close()
 	return nil
 }
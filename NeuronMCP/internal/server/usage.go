@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+)
+
+// usageSaveInterval is how often tool usage statistics are written to the
+// usage file
+const usageSaveInterval = time.Minute
+
+// recordUsage adds a tool call to the usage statistics. Calls of tools the
+// server does not have are left out, so a client guessing names cannot fill
+// the statistics.
+func (s *Server) recordUsage(name string, arguments map[string]interface{}, latency time.Duration, resp *middleware.MCPResponse, err error) {
+	if s.usage == nil || s.toolRegistry.GetTool(name) == nil {
+		return
+	}
+	s.usage.Record(name, arguments, latency, usageErrorCode(resp, err), time.Now())
+}
+
+// usageErrorCode names how a tool call failed, or returns "" when it
+// succeeded
+func usageErrorCode(resp *middleware.MCPResponse, err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "TIMEOUT"
+	case errors.Is(err, context.Canceled):
+		return "CANCELLED"
+	case err != nil:
+		return "INTERNAL_ERROR"
+	case resp == nil || !resp.IsError:
+		return ""
+	}
+	if code, ok := resp.Metadata["code"].(string); ok && code != "" {
+		return code
+	}
+	return "EXECUTION_ERROR"
+}
+
+// offeredToolNames lists the tools tools/list offers the client
+func (s *Server) offeredToolNames() []string {
+	definitions := s.filterToolsByPolicy(s.filterToolsByFeatures(s.toolRegistry.GetAllDefinitions()))
+	names := make([]string, 0, len(definitions))
+	for _, def := range definitions {
+		names = append(names, def.Name)
+	}
+	return names
+}
+
+// saveUsage writes the usage statistics to the usage file, if one is set
+func (s *Server) saveUsage() {
+	path := s.startConfig.Server.GetUsageFile()
+	if s.usage == nil || path == "" {
+		return
+	}
+	if err := s.usage.Save(path); err != nil {
+		s.logger.Warn("Failed to save tool usage statistics", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+}
+
+// persistUsage saves the usage statistics every usageSaveInterval until ctx
+// is done
+func (s *Server) persistUsage(ctx context.Context) {
+	if s.startConfig.Server.GetUsageFile() == "" {
+		return
+	}
+	ticker := time.NewTicker(usageSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.saveUsage()
+		}
+	}
+}
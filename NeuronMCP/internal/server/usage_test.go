@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+)
+
+func TestUsageErrorCode(t *testing.T) {
+	for _, tt := range []struct {
+		resp *middleware.MCPResponse
+		err  error
+		want string
+	}{
+		{&middleware.MCPResponse{}, nil, ""},
+		{nil, nil, ""},
+		{&middleware.MCPResponse{IsError: true, Metadata: map[string]interface{}{"code": "VALIDATION_ERROR"}}, nil, "VALIDATION_ERROR"},
+		{&middleware.MCPResponse{IsError: true}, nil, "EXECUTION_ERROR"},
+		{nil, fmt.Errorf("tool failed: %w", context.DeadlineExceeded), "TIMEOUT"},
+		{nil, context.Canceled, "CANCELLED"},
+		{nil, errors.New("tool not allowed"), "INTERNAL_ERROR"},
+	} {
+		if got := usageErrorCode(tt.resp, tt.err); got != tt.want {
+			t.Errorf("usageErrorCode(%+v, %v) = %q, want %q", tt.resp, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+
+	"github.com/neurondb/NeuronMCP/internal/middleware"
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+// schemaVersionKey is the result metadata key naming the schema version a
+// result was produced under
+const schemaVersionKey = "schema_version"
+
+type toolVersionKey struct{}
+
+// withToolVersion records the tool version a call requests in its _meta
+func withToolVersion(ctx context.Context, meta *mcp.RequestMeta) context.Context {
+	if meta == nil || meta.ToolVersion == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, toolVersionKey{}, meta.ToolVersion)
+}
+
+// requestedToolVersion returns the tool version a call requests, or 0 for
+// the current one
+func requestedToolVersion(ctx context.Context) int {
+	version, _ := ctx.Value(toolVersionKey{}).(int)
+	return version
+}
+
+// withSchemaVersion adds the schema version to a response's metadata
+func withSchemaVersion(resp *middleware.MCPResponse, version int) *middleware.MCPResponse {
+	if resp == nil {
+		return nil
+	}
+	metadata := make(map[string]interface{}, len(resp.Metadata)+1)
+	for k, v := range resp.Metadata {
+		metadata[k] = v
+	}
+	metadata[schemaVersionKey] = version
+	resp.Metadata = metadata
+	return resp
+}
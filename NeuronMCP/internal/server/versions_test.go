@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+	"github.com/neurondb/NeuronMCP/internal/policy"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+	"github.com/neurondb/NeuronMCP/pkg/mcp"
+)
+
+func TestExecuteToolVersions(t *testing.T) {
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	registry := tools.NewToolRegistry(database.NewDatabase(), logger)
+	// Version 2 renamed "k" to "limit"
+	tool := &recordingTool{BaseTool: tools.NewBaseTool("search", "Searches", map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"limit": map[string]interface{}{"type": "integer"}},
+		"required":   []interface{}{"limit"},
+	}).WithVersion(2)}
+	registry.Register(tool)
+	err := registry.RegisterAdapter("search", tools.CompatibilityAdapter{
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"k": map[string]interface{}{"type": "integer"}},
+			"required":   []interface{}{"k"},
+		},
+		Arguments: func(arguments map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"limit": arguments["k"]}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterAdapter() error = %v", err)
+	}
+	if def, _ := registry.GetDefinition("search"); def.Version != 2 || len(def.Versions) != 2 || def.Versions[1] != 1 {
+		t.Errorf("definition = %+v, want version 2 callable as versions [2 1]", def)
+	}
+	engine, err := policy.NewEngine("", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{logger: logger, toolRegistry: registry, policy: engine}
+
+	for _, tt := range []struct {
+		meta      *mcp.RequestMeta
+		arguments map[string]interface{}
+		wantError string
+		want      int
+	}{
+		{nil, map[string]interface{}{"limit": 5}, "", 2},
+		{&mcp.RequestMeta{ToolVersion: 2}, map[string]interface{}{"limit": 5}, "", 2},
+		{&mcp.RequestMeta{ToolVersion: 1}, map[string]interface{}{"k": 5}, "", 1},
+		{&mcp.RequestMeta{ToolVersion: 1}, map[string]interface{}{"limit": 5}, "VALIDATION_ERROR", 1},
+		{&mcp.RequestMeta{ToolVersion: 3}, map[string]interface{}{"limit": 5}, "UNSUPPORTED_VERSION", 2},
+	} {
+		ctx := withToolVersion(context.Background(), tt.meta)
+		resp, err := s.executeTool(ctx, "search", tt.arguments)
+		if err != nil {
+			t.Fatalf("executeTool(%+v) error = %v", tt.meta, err)
+		}
+		if resp.Metadata[schemaVersionKey] != tt.want {
+			t.Errorf("executeTool(%+v) schema_version = %v, want %d", tt.meta, resp.Metadata[schemaVersionKey], tt.want)
+		}
+		if tt.wantError != "" {
+			if !resp.IsError || resp.Metadata["code"] != tt.wantError {
+				t.Errorf("executeTool(%+v, %v) = %+v, want %s", tt.meta, tt.arguments, resp, tt.wantError)
+			}
+			continue
+		}
+		if resp.IsError || resp.Content[0].Text != "{\n  \"limit\": 5\n}" {
+			t.Errorf("executeTool(%+v, %v) = %+v, want the tool called with limit 5", tt.meta, tt.arguments, resp)
+		}
+	}
+
+	if err := registry.RegisterAdapter("score", tools.CompatibilityAdapter{}); err == nil {
+		t.Error("RegisterAdapter() accepted an adapter for an unknown tool")
+	}
+	registry.Register(&recordingTool{BaseTool: tools.NewBaseTool("count", "Counts", map[string]interface{}{"type": "object"})})
+	if err := registry.RegisterAdapter("count", tools.CompatibilityAdapter{}); err == nil {
+		t.Error("RegisterAdapter() accepted an adapter for a tool at version 1")
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/tools"
+)
+
+// modelTargets lists the configured models, embedding models first
+func modelTargets(models *config.ModelsFeatureConfig) []tools.ModelTarget {
+	if models == nil {
+		return nil
+	}
+	targets := make([]tools.ModelTarget, 0, len(models.Embedding)+len(models.Generation))
+	for _, name := range models.Embedding {
+		targets = append(targets, tools.ModelTarget{Name: name, Kind: tools.ModelKindEmbedding})
+	}
+	for _, name := range models.Generation {
+		targets = append(targets, tools.ModelTarget{Name: name, Kind: tools.ModelKindGeneration})
+	}
+	return targets
+}
+
+// warmupModels calls each configured model once when warmupOnStart is set.
+// It runs next to the MCP server, so requests are served while models load;
+// failures are only logged.
+func (s *Server) warmupModels(ctx context.Context) {
+	models := s.config.GetFeaturesConfig().Models
+	if models == nil || !models.WarmupOnStart {
+		return
+	}
+	probes := tools.WarmupModels(ctx, tools.NewQueryExecutor(s.db), modelTargets(models))
+	for _, p := range probes {
+		fields := map[string]interface{}{
+			"model":      p.Model,
+			"kind":       p.Kind,
+			"latency_ms": p.LatencyMs,
+		}
+		if !p.Available {
+			fields["error"] = p.Error
+			s.logger.Warn("Model warm-up failed", fields)
+			continue
+		}
+		s.logger.Info("Model warmed up", fields)
+	}
+}
@@ -194,106 +194,6 @@ func (t *ClusterDataTool) Execute(ctx context.Context, params map[string]interfa
 	}), nil
 }
 
-// DetectOutliersTool detects outliers in data
-type DetectOutliersTool struct {
-	*BaseTool
-	executor *QueryExecutor
-	logger   *logging.Logger
-}
-
-// NewDetectOutliersTool creates a new detect outliers tool
-func NewDetectOutliersTool(db *database.Database, logger *logging.Logger) *DetectOutliersTool {
-	return &DetectOutliersTool{
-		BaseTool: NewBaseTool(
-			"detect_outliers",
-			"Detect outliers in vector data using Z-score method",
-			map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"table": map[string]interface{}{
-						"type":        "string",
-						"description": "Table name containing vectors",
-					},
-					"vector_column": map[string]interface{}{
-						"type":        "string",
-						"description": "Vector column name",
-					},
-					"threshold": map[string]interface{}{
-						"type":        "number",
-						"default":     3.0,
-						"description": "Z-score threshold",
-					},
-				},
-				"required": []interface{}{"table", "vector_column"},
-			},
-		),
-		executor: NewQueryExecutor(db),
-		logger:   logger,
-	}
-}
-
-// Execute executes the outlier detection
-func (t *DetectOutliersTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
-	valid, errors := t.ValidateParams(params, t.InputSchema())
-	if !valid {
-		return Error(fmt.Sprintf("Invalid parameters for detect_outliers tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
-			"errors": errors,
-			"params": params,
-		}), nil
-	}
-
-	table, _ := params["table"].(string)
-	vectorColumn, _ := params["vector_column"].(string)
-	threshold := 3.0
-	if t, ok := params["threshold"].(float64); ok {
-		threshold = t
-	}
-
-	if table == "" {
-		return Error("table parameter is required and cannot be empty for detect_outliers tool", "VALIDATION_ERROR", map[string]interface{}{
-			"parameter": "table",
-			"params":    params,
-		}), nil
-	}
-
-	if vectorColumn == "" {
-		return Error(fmt.Sprintf("vector_column parameter is required and cannot be empty for detect_outliers tool: table='%s'", table), "VALIDATION_ERROR", map[string]interface{}{
-			"parameter": "vector_column",
-			"table":     table,
-			"params":    params,
-		}), nil
-	}
-
-	if threshold <= 0 {
-		return Error(fmt.Sprintf("threshold must be greater than 0 for detect_outliers tool: table='%s', vector_column='%s', received threshold=%g", table, vectorColumn, threshold), "VALIDATION_ERROR", map[string]interface{}{
-			"parameter":     "threshold",
-			"table":         table,
-			"vector_column": vectorColumn,
-			"threshold":     threshold,
-			"params":        params,
-		}), nil
-	}
-
-	// Use NeuronDB's outlier detection function: detect_outliers_zscore(table, column, threshold, method)
-	query := `SELECT detect_outliers_zscore($1, $2, $3, 'zscore') AS outliers`
-	result, err := t.executor.ExecuteQueryOne(ctx, query, []interface{}{table, vectorColumn, threshold})
-	if err != nil {
-		t.logger.Error("Outlier detection failed", err, params)
-		return Error(fmt.Sprintf("Outlier detection execution failed: table='%s', vector_column='%s', threshold=%g, method='zscore', error=%v", table, vectorColumn, threshold, err), "OUTLIER_ERROR", map[string]interface{}{
-			"table":          table,
-			"vector_column": vectorColumn,
-			"threshold":     threshold,
-			"method":         "zscore",
-			"error":          err.Error(),
-		}), nil
-	}
-
-	return Success(result, map[string]interface{}{
-		"method":    "zscore",
-		"threshold": threshold,
-	}), nil
-}
-
 // ReduceDimensionalityTool reduces dimensionality using PCA
 type ReduceDimensionalityTool struct {
 	*BaseTool
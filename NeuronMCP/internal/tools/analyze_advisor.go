@@ -0,0 +1,523 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+const (
+	defaultAnalyzeStaleFraction = 0.1
+	defaultAnalyzeMaxTables     = 5
+	maxAnalyzeTables            = 50
+	defaultAnalyzeMaxTableMB    = 1024
+	defaultAnalyzeTimeoutMs     = 60000
+	maxAnalyzeTimeoutMs         = 600000
+
+	// staleMinRows is the fewest modified rows that make statistics stale,
+	// as autovacuum_analyze_threshold does for autovacuum
+	staleMinRows = 50
+	// highChurnFraction is the share of live rows updated or deleted since
+	// the statistics were reset above which a table counts as high churn
+	highChurnFraction = 0.5
+	// highDeadFraction is the share of dead rows above which a table counts
+	// as high churn whatever its write counts
+	highDeadFraction = 0.2
+	// largeTableRows is the row count above which autovacuum scale factors
+	// are tightened further, as a fixed fraction of a large table is many rows
+	largeTableRows = 1000000
+)
+
+// vectorTypeNames are the column types that make a table a vector table
+var vectorTypeNames = []string{"vector", "halfvec", "sparsevec"}
+
+// vectorTableStats are the activity statistics of a table with vector
+// columns
+type vectorTableStats struct {
+	Schema               string     `json:"schema"`
+	Name                 string     `json:"name"`
+	VectorColumns        []string   `json:"vector_columns"`
+	LiveRows             int64      `json:"live_rows"`
+	DeadRows             int64      `json:"dead_rows"`
+	ModifiedSinceAnalyze int64      `json:"modified_since_analyze"`
+	ModifiedFraction     float64    `json:"modified_fraction"`
+	UpdatedDeleted       int64      `json:"updated_deleted_rows"`
+	LastAnalyzed         *time.Time `json:"last_analyzed"`
+	SizeBytes            int64      `json:"size_bytes"`
+	Stale                bool       `json:"stale"`
+	HighChurn            bool       `json:"high_churn"`
+
+	reloptions []string
+}
+
+// identifier returns the table as a quoted, schema-qualified identifier
+func (s *vectorTableStats) identifier() string {
+	return pgx.Identifier{s.Schema, s.Name}.Sanitize()
+}
+
+// classify sets Stale and HighChurn. Statistics are stale when the table
+// was never analyzed, or when more than staleFraction of its rows, and at
+// least staleMinRows, changed since.
+func (s *vectorTableStats) classify(staleFraction float64) {
+	base := s.LiveRows
+	if base < 1 {
+		base = 1
+	}
+	s.ModifiedFraction = float64(s.ModifiedSinceAnalyze) / float64(base)
+	if s.LastAnalyzed == nil {
+		s.Stale = s.LiveRows > 0 || s.ModifiedSinceAnalyze > 0
+	} else {
+		s.Stale = s.ModifiedSinceAnalyze >= staleMinRows && s.ModifiedFraction > staleFraction
+	}
+
+	deadFraction := float64(s.DeadRows) / float64(base+s.DeadRows)
+	s.HighChurn = float64(s.UpdatedDeleted)/float64(base) >= highChurnFraction || deadFraction >= highDeadFraction
+}
+
+// autovacuumAdvice recommends per-table autovacuum settings for a high
+// churn table
+type autovacuumAdvice struct {
+	Settings map[string]string `json:"settings"`
+	Current  map[string]string `json:"current"`
+	SQL      string            `json:"sql"`
+	Reason   string            `json:"reason"`
+}
+
+// recommendAutovacuum returns settings that make autovacuum vacuum and
+// analyze a high churn table sooner, leaving out those its reloptions
+// already set as tight or tighter. It returns nil when there is nothing to
+// change.
+func recommendAutovacuum(s *vectorTableStats) *autovacuumAdvice {
+	if !s.HighChurn {
+		return nil
+	}
+	recommended := map[string]float64{
+		"autovacuum_vacuum_scale_factor":  0.05,
+		"autovacuum_analyze_scale_factor": 0.02,
+	}
+	if s.LiveRows >= largeTableRows {
+		recommended["autovacuum_vacuum_scale_factor"] = 0.01
+		recommended["autovacuum_analyze_scale_factor"] = 0.005
+	}
+
+	current := map[string]string{}
+	for _, option := range s.reloptions {
+		if name, value, ok := strings.Cut(option, "="); ok {
+			current[name] = value
+		}
+	}
+	settings := map[string]string{}
+	var assignments []string
+	for _, name := range []string{"autovacuum_vacuum_scale_factor", "autovacuum_analyze_scale_factor"} {
+		if v, err := strconv.ParseFloat(current[name], 64); err == nil && v <= recommended[name] {
+			continue
+		}
+		value := strconv.FormatFloat(recommended[name], 'g', -1, 64)
+		settings[name] = value
+		assignments = append(assignments, name+" = "+value)
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+
+	reason := fmt.Sprintf("%d rows were updated or deleted for %d live rows", s.UpdatedDeleted, s.LiveRows)
+	if s.DeadRows > 0 {
+		reason += fmt.Sprintf(", and %d dead rows are waiting for vacuum", s.DeadRows)
+	}
+	return &autovacuumAdvice{
+		Settings: settings,
+		Current:  current,
+		SQL:      fmt.Sprintf("ALTER TABLE %s SET (%s)", s.identifier(), strings.Join(assignments, ", ")),
+		Reason:   reason + "; vector indexes degrade as dead rows accumulate and stale statistics mislead the planner",
+	}
+}
+
+// selectAnalyzeTargets picks the stale tables to analyze, most modified
+// first, up to maxTables. Stale tables over maxBytes, or beyond the
+// maxTables cap, are returned as skipped with the reason.
+func selectAnalyzeTargets(tables []*vectorTableStats, maxTables int, maxBytes int64) ([]*vectorTableStats, map[*vectorTableStats]string) {
+	var stale []*vectorTableStats
+	for _, t := range tables {
+		if t.Stale {
+			stale = append(stale, t)
+		}
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].ModifiedFraction > stale[j].ModifiedFraction
+	})
+
+	var targets []*vectorTableStats
+	skipped := map[*vectorTableStats]string{}
+	for _, t := range stale {
+		switch {
+		case t.SizeBytes > maxBytes:
+			skipped[t] = fmt.Sprintf("table is %d MB, over max_table_mb", t.SizeBytes>>20)
+		case len(targets) >= maxTables:
+			skipped[t] = fmt.Sprintf("max_tables (%d) reached", maxTables)
+		default:
+			targets = append(targets, t)
+		}
+	}
+	return targets, skipped
+}
+
+// planEstimate is the planner's estimate for reading a table's vectors
+type planEstimate struct {
+	EstimatedRows float64 `json:"estimated_rows"`
+	TotalCost     float64 `json:"total_cost"`
+}
+
+// AnalyzeVectorTablesTool finds vector tables with stale planner statistics,
+// analyzes them within cost caps and recommends autovacuum settings for
+// tables with high churn
+type AnalyzeVectorTablesTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewAnalyzeVectorTablesTool creates a new analyze vector tables tool
+func NewAnalyzeVectorTablesTool(db *database.Database, logger *logging.Logger) *AnalyzeVectorTablesTool {
+	return &AnalyzeVectorTablesTool{
+		BaseTool: NewBaseTool(
+			"analyze_vector_tables",
+			"Find tables with vector columns whose planner statistics are stale in pg_stat_user_tables, run ANALYZE on them within a table count, size and time budget, report the planner's row and cost estimates before and after, and recommend autovacuum settings for high-churn embedding tables",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tables": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Tables to check, as table or schema.table; all vector tables when absent",
+					},
+					"stale_fraction": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultAnalyzeStaleFraction,
+						"minimum":     0,
+						"maximum":     1,
+						"description": "Share of rows modified since the last ANALYZE above which statistics are stale",
+					},
+					"analyze": map[string]interface{}{
+						"type":        "boolean",
+						"default":     true,
+						"description": "Run ANALYZE on stale tables; false only reports them",
+					},
+					"max_tables": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultAnalyzeMaxTables,
+						"minimum":     1,
+						"maximum":     maxAnalyzeTables,
+						"description": "Most tables to analyze in one call, most modified first",
+					},
+					"max_table_mb": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultAnalyzeMaxTableMB,
+						"minimum":     1,
+						"description": "Skip tables larger than this many MB",
+					},
+					"timeout_ms": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultAnalyzeTimeoutMs,
+						"minimum":     100,
+						"maximum":     maxAnalyzeTimeoutMs,
+						"description": "statement_timeout of each ANALYZE in milliseconds",
+					},
+				},
+				"required": []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute checks the vector tables and analyzes the stale ones
+func (t *AnalyzeVectorTablesTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for analyze_vector_tables tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	var tables []string
+	if raw, ok := params["tables"].([]interface{}); ok {
+		for i, v := range raw {
+			name, _ := v.(string)
+			table, err := parseQualifiedIdentifier(name)
+			if err != nil {
+				return Error(fmt.Sprintf("tables element %d is not a valid table name '%s': %v", i, name, err), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "tables",
+					"index":     i,
+				}), nil
+			}
+			tables = append(tables, table.Sanitize())
+		}
+	}
+	staleFraction := defaultAnalyzeStaleFraction
+	if v, ok := params["stale_fraction"].(float64); ok {
+		staleFraction = v
+	}
+	runAnalyze := true
+	if v, ok := params["analyze"].(bool); ok {
+		runAnalyze = v
+	}
+	maxTables, invalid := intParamInRange(params, "max_tables", defaultAnalyzeMaxTables, 1, maxAnalyzeTables)
+	if invalid != nil {
+		return invalid, nil
+	}
+	maxTableMB, invalid := intParamInRange(params, "max_table_mb", defaultAnalyzeMaxTableMB, 1, 1<<30)
+	if invalid != nil {
+		return invalid, nil
+	}
+	timeoutMs, invalid := intParamInRange(params, "timeout_ms", defaultAnalyzeTimeoutMs, 100, maxAnalyzeTimeoutMs)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for analyze_vector_tables", "DATABASE_ERROR", nil), nil
+	}
+
+	if missing, err := missingTables(ctx, db, tables); err != nil {
+		return t.analyzeError("tables", err), nil
+	} else if len(missing) > 0 {
+		return Error(fmt.Sprintf("Tables do not exist: %s", strings.Join(missing, ", ")), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "tables",
+			"missing":   missing,
+		}), nil
+	}
+	stats, err := readVectorTableStats(ctx, db, tables)
+	if err != nil {
+		return t.analyzeError("statistics", err), nil
+	}
+	for _, s := range stats {
+		s.classify(staleFraction)
+	}
+	targets, skipped := selectAnalyzeTargets(stats, maxTables, int64(maxTableMB)<<20)
+	if !runAnalyze {
+		for _, target := range targets {
+			skipped[target] = "analyze is false"
+		}
+		targets = nil
+	}
+
+	if IsDryRun(ctx) {
+		statements := make([]PlannedStatement, len(targets))
+		permissions := make([]Permission, len(targets))
+		for i, target := range targets {
+			statements[i] = PlannedStatement{
+				SQL:    "ANALYZE " + target.identifier(),
+				rowsOf: target.identifier(),
+				Note:   fmt.Sprintf("samples the table to refresh planner statistics, with statement_timeout %d ms; rows are not changed", timeoutMs),
+			}
+			permissions[i] = tablePermission("OWNER", target.identifier())
+		}
+		return dryRunResult(ctx, db, t.Name(), statements, permissions), nil
+	}
+
+	analyzed := map[*vectorTableStats]map[string]interface{}{}
+	for _, target := range targets {
+		outcome := map[string]interface{}{}
+		before, err := estimateVectorRead(ctx, db, target)
+		if err == nil {
+			outcome["before"] = before
+		}
+		start := time.Now()
+		if err := analyzeTable(ctx, db, target, timeoutMs); err != nil {
+			skipped[target] = analyzeFailure(err, timeoutMs)
+			t.logger.Warn("Analyze of vector table failed", map[string]interface{}{
+				"table": target.identifier(),
+				"error": err.Error(),
+			})
+			continue
+		}
+		outcome["duration_ms"] = msSince(start)
+		// Planning again after ANALYZE loads the new statistics, and the
+		// estimate shows what they changed
+		if after, err := estimateVectorRead(ctx, db, target); err == nil {
+			outcome["after"] = after
+		}
+		analyzed[target] = outcome
+	}
+
+	results := make([]map[string]interface{}, len(stats))
+	var analyzedNames []string
+	staleCount := 0
+	recommendations := []string{}
+	for i, s := range stats {
+		entry := map[string]interface{}{
+			"table":                  s.Schema + "." + s.Name,
+			"vector_columns":         s.VectorColumns,
+			"live_rows":              s.LiveRows,
+			"dead_rows":              s.DeadRows,
+			"modified_since_analyze": s.ModifiedSinceAnalyze,
+			"modified_fraction":      s.ModifiedFraction,
+			"last_analyzed":          s.LastAnalyzed,
+			"size_bytes":             s.SizeBytes,
+			"stale":                  s.Stale,
+			"high_churn":             s.HighChurn,
+		}
+		if s.Stale {
+			staleCount++
+		}
+		switch {
+		case analyzed[s] != nil:
+			entry["action"] = "analyzed"
+			for k, v := range analyzed[s] {
+				entry[k] = v
+			}
+			analyzedNames = append(analyzedNames, s.Schema+"."+s.Name)
+		case skipped[s] != "":
+			entry["action"] = "skipped"
+			entry["skip_reason"] = skipped[s]
+		default:
+			entry["action"] = "none"
+		}
+		if advice := recommendAutovacuum(s); advice != nil {
+			entry["autovacuum"] = advice
+			recommendations = append(recommendations, advice.SQL)
+		}
+		results[i] = entry
+	}
+
+	return Success(map[string]interface{}{
+		"tables_checked":  len(stats),
+		"stale_tables":    staleCount,
+		"analyzed":        analyzedNames,
+		"tables":          results,
+		"recommendations": recommendations,
+	}, map[string]interface{}{
+		"stale_fraction": staleFraction,
+		"max_tables":     maxTables,
+		"max_table_mb":   maxTableMB,
+		"timeout_ms":     timeoutMs,
+	}), nil
+}
+
+func (t *AnalyzeVectorTablesTool) analyzeError(stage string, err error) *ToolResult {
+	t.logger.Error("Analyze vector tables failed", err, map[string]interface{}{"stage": stage})
+	return Error(fmt.Sprintf("Failed to read %s for analyze_vector_tables: %v", stage, err), "QUERY_ERROR", map[string]interface{}{
+		"stage": stage,
+		"error": err.Error(),
+	})
+}
+
+// missingTables returns the tables that do not exist
+func missingTables(ctx context.Context, db *database.Database, tables []string) ([]string, error) {
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	rows, err := db.Query(queryCtx, "SELECT t FROM unnest($1::text[]) AS t WHERE to_regclass(t) IS NULL", tables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		missing = append(missing, name)
+	}
+	return missing, rows.Err()
+}
+
+// readVectorTableStats reads pg_stat_user_tables for the tables with a
+// vector column, or only for the given tables when there are any
+func readVectorTableStats(ctx context.Context, db *database.Database, tables []string) ([]*vectorTableStats, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	if tables == nil {
+		tables = []string{}
+	}
+	rows, err := db.Query(queryCtx, `
+		SELECT s.schemaname, s.relname, array_agg(a.attname::text ORDER BY a.attnum),
+		       s.n_live_tup, s.n_dead_tup, s.n_mod_since_analyze, s.n_tup_upd + s.n_tup_del,
+		       GREATEST(s.last_analyze, s.last_autoanalyze), pg_table_size(s.relid),
+		       COALESCE(c.reloptions, '{}')
+		FROM pg_stat_user_tables s
+		JOIN pg_class c ON c.oid = s.relid
+		JOIN pg_attribute a ON a.attrelid = s.relid AND a.attnum > 0 AND NOT a.attisdropped
+		JOIN pg_type t ON t.oid = a.atttypid
+		WHERE t.typname = ANY($1)
+		  AND (cardinality($2::text[]) = 0 OR s.relid IN (SELECT to_regclass(n) FROM unnest($2::text[]) AS n))
+		GROUP BY s.relid, s.schemaname, s.relname, s.n_live_tup, s.n_dead_tup, s.n_mod_since_analyze,
+		         s.n_tup_upd, s.n_tup_del, s.last_analyze, s.last_autoanalyze, c.reloptions
+		ORDER BY s.schemaname, s.relname`, vectorTypeNames, tables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*vectorTableStats{}
+	for rows.Next() {
+		s := &vectorTableStats{}
+		if err := rows.Scan(&s.Schema, &s.Name, &s.VectorColumns, &s.LiveRows, &s.DeadRows, &s.ModifiedSinceAnalyze,
+			&s.UpdatedDeleted, &s.LastAnalyzed, &s.SizeBytes, &s.reloptions); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// estimateVectorRead plans reading the table's non-NULL vectors of its first
+// vector column
+func estimateVectorRead(ctx context.Context, db *database.Database, s *vectorTableStats) (*planEstimate, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	var raw string
+	query := fmt.Sprintf("EXPLAIN (FORMAT JSON) SELECT * FROM %s WHERE %s IS NOT NULL", s.identifier(), pgx.Identifier{s.VectorColumns[0]}.Sanitize())
+	if err := db.QueryRow(queryCtx, query).Scan(&raw); err != nil {
+		return nil, err
+	}
+	summary, err := summarizeExplainPlan([]byte(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &planEstimate{EstimatedRows: summary.EstimatedRows, TotalCost: summary.TotalCost}, nil
+}
+
+// analyzeFailure says why ANALYZE of a table did not complete
+func analyzeFailure(err error, timeoutMs int) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "57014" { // query_canceled, raised by statement_timeout
+		return fmt.Sprintf("ANALYZE exceeded timeout_ms (%d)", timeoutMs)
+	}
+	return fmt.Sprintf("ANALYZE failed: %v", err)
+}
+
+// analyzeTable runs ANALYZE on the table with the given statement_timeout
+func analyzeTable(ctx context.Context, db *database.Database, s *vectorTableStats, timeoutMs int) error {
+	// The context outlives the statement timeout slightly, so the server
+	// reports the timeout rather than the client cancelling the query
+	queryCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond+5*time.Second)
+	defer cancel()
+	tx, err := db.Begin(queryCtx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+	if _, err := tx.Exec(queryCtx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeoutMs)); err != nil {
+		return fmt.Errorf("failed to set statement_timeout: %w", err)
+	}
+	if _, err := tx.Exec(queryCtx, "ANALYZE "+s.identifier()); err != nil {
+		return err
+	}
+	return tx.Commit(queryCtx)
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVectorTableStatsClassify(t *testing.T) {
+	analyzed := time.Now()
+	tests := []struct {
+		name          string
+		stats         vectorTableStats
+		wantStale     bool
+		wantHighChurn bool
+	}{
+		{"never analyzed", vectorTableStats{LiveRows: 10}, true, false},
+		{"empty and never analyzed", vectorTableStats{}, false, false},
+		{"fresh", vectorTableStats{LiveRows: 10000, ModifiedSinceAnalyze: 500, LastAnalyzed: &analyzed}, false, false},
+		{"stale", vectorTableStats{LiveRows: 10000, ModifiedSinceAnalyze: 2000, LastAnalyzed: &analyzed}, true, false},
+		{"too few changes", vectorTableStats{LiveRows: 100, ModifiedSinceAnalyze: 40, LastAnalyzed: &analyzed}, false, false},
+		{"updates", vectorTableStats{LiveRows: 1000, UpdatedDeleted: 600, LastAnalyzed: &analyzed}, false, true},
+		{"dead rows", vectorTableStats{LiveRows: 1000, DeadRows: 300, LastAnalyzed: &analyzed}, false, true},
+	}
+	for _, tt := range tests {
+		s := tt.stats
+		s.classify(0.1)
+		if s.Stale != tt.wantStale || s.HighChurn != tt.wantHighChurn {
+			t.Errorf("%s: stale=%v high_churn=%v, want %v and %v", tt.name, s.Stale, s.HighChurn, tt.wantStale, tt.wantHighChurn)
+		}
+	}
+}
+
+func TestSelectAnalyzeTargets(t *testing.T) {
+	small := &vectorTableStats{Name: "small", Stale: true, ModifiedFraction: 0.2, SizeBytes: 1 << 20}
+	busiest := &vectorTableStats{Name: "busiest", Stale: true, ModifiedFraction: 0.9, SizeBytes: 1 << 20}
+	huge := &vectorTableStats{Name: "huge", Stale: true, ModifiedFraction: 0.5, SizeBytes: 10 << 30}
+	later := &vectorTableStats{Name: "later", Stale: true, ModifiedFraction: 0.15, SizeBytes: 1 << 20}
+	fresh := &vectorTableStats{Name: "fresh"}
+
+	targets, skipped := selectAnalyzeTargets([]*vectorTableStats{small, busiest, huge, later, fresh}, 2, 1<<30)
+	if len(targets) != 2 || targets[0] != busiest || targets[1] != small {
+		t.Errorf("targets = %v, want busiest then small", targets)
+	}
+	if !strings.Contains(skipped[huge], "max_table_mb") || !strings.Contains(skipped[later], "max_tables") {
+		t.Errorf("skipped = %v, want huge over the size cap and later over the table cap", skipped)
+	}
+	if _, ok := skipped[fresh]; ok {
+		t.Error("a fresh table was reported as skipped")
+	}
+}
+
+func TestRecommendAutovacuum(t *testing.T) {
+	if advice := recommendAutovacuum(&vectorTableStats{LiveRows: 1000}); advice != nil {
+		t.Errorf("advice for a quiet table = %+v, want none", advice)
+	}
+
+	s := &vectorTableStats{Schema: "public", Name: "docs", LiveRows: 2000000, HighChurn: true,
+		reloptions: []string{"autovacuum_analyze_scale_factor=0.001"}}
+	advice := recommendAutovacuum(s)
+	if advice == nil {
+		t.Fatal("no advice for a high churn table")
+	}
+	if advice.Settings["autovacuum_vacuum_scale_factor"] != "0.01" {
+		t.Errorf("settings = %v, want the large table vacuum scale factor", advice.Settings)
+	}
+	if _, ok := advice.Settings["autovacuum_analyze_scale_factor"]; ok {
+		t.Errorf("settings = %v, the table's own tighter analyze scale factor should be kept", advice.Settings)
+	}
+	if advice.SQL != `ALTER TABLE "public"."docs" SET (autovacuum_vacuum_scale_factor = 0.01)` {
+		t.Errorf("sql = %s", advice.SQL)
+	}
+
+	s.reloptions = append(s.reloptions, "autovacuum_vacuum_scale_factor=0.01")
+	if advice := recommendAutovacuum(s); advice != nil {
+		t.Errorf("advice for a table already tuned = %+v, want none", advice)
+	}
+}
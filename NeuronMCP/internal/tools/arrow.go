@@ -0,0 +1,325 @@
+package tools
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// This file writes the Arrow IPC streaming format
+// (https://arrow.apache.org/docs/format/Columnar.html#ipc-streaming-format)
+// for result tables: a Schema message, one RecordBatch and the end-of-stream
+// marker. Only the types result columns map to are supported: Int64,
+// Float64, Bool and Utf8, all nullable.
+
+// Arrow type ids (the Type union of Schema.fbs)
+const (
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+)
+
+// Arrow message header types (the MessageHeader union of Message.fbs)
+const (
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+)
+
+const (
+	arrowMetadataV5     = 4
+	arrowPrecisionFloat = 2 // DOUBLE
+	arrowContinuation   = 0xFFFFFFFF
+)
+
+// encodeArrowStream encodes a result table as an Arrow IPC stream
+func encodeArrowStream(table *resultTable) []byte {
+	var out []byte
+	out = appendArrowMessage(out, arrowSchema(table), arrowHeaderSchema, nil)
+
+	nodes, buffers, body := arrowRecordBatchBody(table)
+	batch := fbTable{
+		fbInt64(int64(len(table.Rows))),
+		fbRef(fbStructs(nodes)),
+		fbRef(fbStructs(buffers)),
+	}
+	out = appendArrowMessage(out, batch, arrowHeaderRecordBatch, body)
+
+	// End of stream: a continuation marker with empty metadata
+	out = binary.LittleEndian.AppendUint32(out, arrowContinuation)
+	return binary.LittleEndian.AppendUint32(out, 0)
+}
+
+func arrowSchema(table *resultTable) fbTable {
+	fields := make(fbTables, len(table.Columns))
+	for i, column := range table.Columns {
+		var typeID uint8
+		var typ fbTable
+		switch column.Kind {
+		case columnInt64:
+			typeID, typ = arrowTypeInt, fbTable{fbInt32(64), fbBool(true)}
+		case columnFloat64:
+			typeID, typ = arrowTypeFloatingPoint, fbTable{fbInt16(arrowPrecisionFloat)}
+		case columnBool:
+			typeID, typ = arrowTypeBool, fbTable{}
+		default:
+			typeID, typ = arrowTypeUtf8, fbTable{}
+		}
+		fields[i] = fbTable{
+			fbRef(fbString(column.Name)),
+			fbBool(true),
+			fbUint8(typeID),
+			fbRef(typ),
+			{},                   // dictionary
+			fbRef(fbTables(nil)), // children; readers require the vector
+		}
+	}
+	return fbTable{
+		fbInt16(0), // little endian
+		fbRef(fields),
+	}
+}
+
+// arrowRecordBatchBody lays out the buffers of every column: a validity
+// bitmap, then the values, or for Utf8 the offsets and then the bytes. It
+// returns the FieldNode and Buffer structs describing them.
+func arrowRecordBatchBody(table *resultTable) (nodes, buffers []byte, body []byte) {
+	n := len(table.Rows)
+	addBuffer := func(data []byte) {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(body)))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(data)))
+		body = append(body, data...)
+		body = padTo(body, 8)
+	}
+
+	for c, column := range table.Columns {
+		validity := make([]byte, (n+7)/8)
+		nulls := 0
+		for r, row := range table.Rows {
+			if row[c] == nil {
+				nulls++
+			} else {
+				validity[r/8] |= 1 << (r % 8)
+			}
+		}
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(n))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(nulls))
+		addBuffer(validity)
+
+		switch column.Kind {
+		case columnInt64:
+			values := make([]byte, 0, 8*n)
+			for _, row := range table.Rows {
+				v, _ := row[c].(int64)
+				values = binary.LittleEndian.AppendUint64(values, uint64(v))
+			}
+			addBuffer(values)
+		case columnFloat64:
+			values := make([]byte, 0, 8*n)
+			for _, row := range table.Rows {
+				values = binary.LittleEndian.AppendUint64(values, math.Float64bits(cellFloat(row[c])))
+			}
+			addBuffer(values)
+		case columnBool:
+			values := make([]byte, (n+7)/8)
+			for r, row := range table.Rows {
+				if v, _ := row[c].(bool); v {
+					values[r/8] |= 1 << (r % 8)
+				}
+			}
+			addBuffer(values)
+		default:
+			offsets := make([]byte, 0, 4*(n+1))
+			var data []byte
+			offsets = binary.LittleEndian.AppendUint32(offsets, 0)
+			for _, row := range table.Rows {
+				if row[c] != nil {
+					data = append(data, cellString(row[c])...)
+				}
+				offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+			}
+			addBuffer(offsets)
+			addBuffer(data)
+		}
+	}
+	return nodes, buffers, body
+}
+
+// appendArrowMessage appends an encapsulated message: the continuation
+// marker, the metadata length, the Message flatbuffer padded to 8 bytes and
+// the body
+func appendArrowMessage(out []byte, header fbTable, headerType uint8, body []byte) []byte {
+	message := fbTable{
+		fbInt16(arrowMetadataV5),
+		fbUint8(headerType),
+		fbRef(header),
+		fbInt64(int64(len(body))),
+	}
+	metadata := padTo(fbFinish(message), 8)
+	out = binary.LittleEndian.AppendUint32(out, arrowContinuation)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(metadata)))
+	out = append(out, metadata...)
+	return append(out, body...)
+}
+
+func padTo(b []byte, align int) []byte {
+	for len(b)%align != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// A minimal FlatBuffers writer for the Arrow metadata. Unlike the reference
+// builder it lays objects out front to back: each object is written before
+// the objects it references, so every unsigned offset points forward as the
+// format requires. Tables start 8-aligned and place their widest fields
+// first, so every scalar is naturally aligned.
+
+// fbObject is a table, vector or string that can be referenced
+type fbObject interface {
+	// writeFB appends the object and returns the position offsets to it
+	// must point at
+	writeFB(b *fbBuilder) int
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+func (b *fbBuilder) align(n int) {
+	b.buf = padTo(b.buf, n)
+}
+
+// putOffset stores at pos the unsigned offset from pos to target
+func (b *fbBuilder) putOffset(pos, target int) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+// fbFinish writes a root table and returns the buffer
+func fbFinish(root fbObject) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 256)}
+	b.putOffset(0, root.writeFB(b))
+	return b.buf
+}
+
+// fbSlot is one field of a table: an inline scalar, a reference to another
+// object or, when both are empty, an absent field
+type fbSlot struct {
+	scalar []byte
+	ref    fbObject
+}
+
+func fbUint8(v uint8) fbSlot { return fbSlot{scalar: []byte{v}} }
+
+func fbBool(v bool) fbSlot {
+	if v {
+		return fbUint8(1)
+	}
+	return fbUint8(0)
+}
+
+func fbInt16(v int16) fbSlot {
+	return fbSlot{scalar: binary.LittleEndian.AppendUint16(nil, uint16(v))}
+}
+
+func fbInt32(v int32) fbSlot {
+	return fbSlot{scalar: binary.LittleEndian.AppendUint32(nil, uint32(v))}
+}
+
+func fbInt64(v int64) fbSlot {
+	return fbSlot{scalar: binary.LittleEndian.AppendUint64(nil, uint64(v))}
+}
+
+func fbRef(obj fbObject) fbSlot { return fbSlot{ref: obj} }
+
+func (s fbSlot) width() int {
+	if s.ref != nil {
+		return 4
+	}
+	return len(s.scalar)
+}
+
+// fbTable is a table whose slot i is field id i
+type fbTable []fbSlot
+
+func (t fbTable) writeFB(b *fbBuilder) int {
+	// The table starts with the signed offset to its vtable
+	offsets := make([]int, len(t))
+	size := 4
+	for _, width := range []int{8, 4, 2, 1} {
+		for i, slot := range t {
+			if slot.width() != width {
+				continue
+			}
+			for size%width != 0 {
+				size++
+			}
+			offsets[i] = size
+			size += width
+		}
+	}
+
+	b.align(2)
+	vtable := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(t)))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	for _, off := range offsets {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(off))
+	}
+
+	b.align(8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vtable)))
+	for i, slot := range t {
+		if slot.scalar != nil {
+			copy(b.buf[pos+offsets[i]:], slot.scalar)
+		}
+	}
+	for i, slot := range t {
+		if slot.ref != nil {
+			b.putOffset(pos+offsets[i], slot.ref.writeFB(b))
+		}
+	}
+	return pos
+}
+
+// fbTables is a vector of tables
+type fbTables []fbObject
+
+func (v fbTables) writeFB(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, obj := range v {
+		b.putOffset(pos+4+4*i, obj.writeFB(b))
+	}
+	return pos
+}
+
+// fbStructs is a vector of 16-byte structs of two longs, the FieldNode and
+// Buffer structs of a RecordBatch
+type fbStructs []byte
+
+func (v fbStructs) writeFB(b *fbBuilder) int {
+	// The elements hold longs, so they start 8-aligned after the length
+	for len(b.buf)%8 != 4 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)/16))
+	b.buf = append(b.buf, v...)
+	return pos
+}
+
+// fbString is a NUL-terminated string
+type fbString string
+
+func (s fbString) writeFB(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
@@ -1,10 +1,5 @@
 package tools
 
-import (
-	"fmt"
-	"reflect"
-)
-
 // ToolResult represents the result of tool execution
 type ToolResult struct {
 	Success  bool                   `json:"success"`
@@ -25,6 +20,7 @@ type BaseTool struct {
 	name        string
 	description string
 	inputSchema map[string]interface{}
+	version     int
 }
 
 // NewBaseTool creates a new base tool
@@ -51,101 +47,29 @@ func (b *BaseTool) InputSchema() map[string]interface{} {
 	return b.inputSchema
 }
 
-// ValidateParams validates parameters against the schema
-func (b *BaseTool) ValidateParams(params map[string]interface{}, schema map[string]interface{}) (bool, []string) {
-	var errors []string
-
-	// Check required fields
-	if required, ok := schema["required"].([]interface{}); ok {
-		for _, req := range required {
-			if reqStr, ok := req.(string); ok {
-				if _, exists := params[reqStr]; !exists {
-					errors = append(errors, fmt.Sprintf("Missing required parameter: %s", reqStr))
-				}
-			}
-		}
-	}
-
-	// Validate parameter types
-	if properties, ok := schema["properties"].(map[string]interface{}); ok {
-		for key, value := range params {
-			if propSchema, exists := properties[key]; exists {
-				if propMap, ok := propSchema.(map[string]interface{}); ok {
-					if typeError := validateType(value, propMap); typeError != "" {
-						errors = append(errors, fmt.Sprintf("Invalid type for %s: %s", key, typeError))
-					}
-				}
-			}
-		}
+// Version returns the version of the tool's schema, DefaultToolVersion
+// unless set with WithVersion
+func (b *BaseTool) Version() int {
+	if b.version == 0 {
+		return DefaultToolVersion
 	}
-
-	return len(errors) == 0, errors
+	return b.version
 }
 
-func validateType(value interface{}, schema map[string]interface{}) string {
-	schemaType, ok := schema["type"].(string)
-	if !ok {
-		return ""
-	}
-
-	valueType := reflect.TypeOf(value).Kind()
-
-	switch schemaType {
-	case "string":
-		if valueType != reflect.String {
-			return "expected string"
-		}
-	case "number":
-		if valueType != reflect.Float64 && valueType != reflect.Int && valueType != reflect.Int64 {
-			return "expected number"
-		}
-	case "integer":
-		if valueType != reflect.Int && valueType != reflect.Int64 {
-			return "expected integer"
-		}
-	case "boolean":
-		if valueType != reflect.Bool {
-			return "expected boolean"
-		}
-	case "array":
-		if valueType != reflect.Slice && valueType != reflect.Array {
-			return "expected array"
-		}
-	case "object":
-		if valueType != reflect.Map {
-			return "expected object"
-		}
-	}
-
-	// Validate enum
-	if enum, ok := schema["enum"].([]interface{}); ok {
-		found := false
-		for _, e := range enum {
-			if reflect.DeepEqual(value, e) {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Sprintf("must be one of: %v", enum)
-		}
-	}
-
-	// Validate number constraints
-	if schemaType == "number" || schemaType == "integer" {
-		if min, ok := schema["minimum"].(float64); ok {
-			if val, ok := value.(float64); ok && val < min {
-				return fmt.Sprintf("must be >= %g", min)
-			}
-		}
-		if max, ok := schema["maximum"].(float64); ok {
-			if val, ok := value.(float64); ok && val > max {
-				return fmt.Sprintf("must be <= %g", max)
-			}
-		}
-	}
+// WithVersion sets the version of the tool's schema. Raise it whenever the
+// input schema or result changes in a way that breaks existing clients.
+func (b *BaseTool) WithVersion(version int) *BaseTool {
+	b.version = version
+	return b
+}
 
-	return ""
+// ValidateParams validates parameters against the schema with a JSON
+// Schema (draft 2020-12) validator, so nested objects, array items, enums
+// and bounds are all enforced. Each error names the JSON Pointer of the
+// offending value, e.g. "/vectors/2: got string, want number".
+func (b *BaseTool) ValidateParams(params map[string]interface{}, schema map[string]interface{}) (bool, []string) {
+	errors := ValidateArguments(schema, params)
+	return len(errors) == 0, errors
 }
 
 // Success creates a success result
@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Batch embedding limits
+const (
+	maxBatchEmbeddingTexts       = 1000
+	defaultEmbeddingSubBatchSize = 100
+	defaultEmbeddingParallelism  = 2
+	maxEmbeddingParallelism      = 8
+)
+
+// Sub-batch statuses reported by batch_embedding
+const (
+	subBatchSucceeded = "succeeded"
+	subBatchFailed    = "failed"
+	subBatchSkipped   = "skipped" // not run: stopped early, cancelled, or done in an earlier call
+)
+
+// batchEmbeddingRequest is a parsed batch_embedding call
+type batchEmbeddingRequest struct {
+	texts       []string
+	model       string
+	batchSize   int
+	parallelism int
+	stopOnError bool
+	// pending holds the sub-batches to run; nil runs all of them
+	pending map[int]bool
+}
+
+// embeddingResumeToken is what batch_embedding needs to continue a batch:
+// the batches left to run and a digest tying them to the same texts
+type embeddingResumeToken struct {
+	Version   int    `json:"v"`
+	Model     string `json:"model"`
+	BatchSize int    `json:"batch_size"`
+	Texts     int    `json:"texts"`
+	Digest    string `json:"digest"`
+	Pending   []int  `json:"pending"`
+}
+
+// subBatchResult reports one sub-batch of a batch_embedding call
+type subBatchResult struct {
+	Index      int    `json:"index"`
+	Start      int    `json:"start"`
+	Count      int    `json:"count"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+// parseBatchEmbeddingRequest validates the parameters of batch_embedding
+func parseBatchEmbeddingRequest(params map[string]interface{}) (*batchEmbeddingRequest, *ToolResult) {
+	texts, _ := params["texts"].([]interface{})
+	if len(texts) == 0 {
+		return nil, Error("texts parameter is required and cannot be empty array for batch_embedding tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter":   "texts",
+			"texts_count": 0,
+		})
+	}
+	if len(texts) > maxBatchEmbeddingTexts {
+		return nil, Error(fmt.Sprintf("texts array exceeds maximum size of %d: received %d texts for batch_embedding tool", maxBatchEmbeddingTexts, len(texts)), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter":   "texts",
+			"texts_count": len(texts),
+			"max_count":   maxBatchEmbeddingTexts,
+		})
+	}
+
+	req := &batchEmbeddingRequest{
+		model:       stringParam(params, "model", "default"),
+		batchSize:   defaultEmbeddingSubBatchSize,
+		parallelism: defaultEmbeddingParallelism,
+		texts:       make([]string, 0, len(texts)),
+	}
+	for i, text := range texts {
+		textStr, ok := text.(string)
+		if !ok {
+			return nil, Error(fmt.Sprintf("texts array element at index %d must be a string for batch_embedding tool: got %T", i, text), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter":     "texts",
+				"invalid_index": i,
+				"received_type": fmt.Sprintf("%T", text),
+			})
+		}
+		if textStr == "" {
+			return nil, Error(fmt.Sprintf("texts array element at index %d is empty string for batch_embedding tool", i), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter":   "texts",
+				"empty_index": i,
+			})
+		}
+		req.texts = append(req.texts, textStr)
+	}
+
+	if v, ok := params["batch_size"].(float64); ok {
+		req.batchSize = int(v)
+		if req.batchSize < 1 || req.batchSize > maxBatchEmbeddingTexts {
+			return nil, Error(fmt.Sprintf("batch_size must be between 1 and %d, got %d", maxBatchEmbeddingTexts, req.batchSize), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "batch_size",
+			})
+		}
+	}
+	if v, ok := params["parallelism"].(float64); ok {
+		req.parallelism = int(v)
+		if req.parallelism < 1 || req.parallelism > maxEmbeddingParallelism {
+			return nil, Error(fmt.Sprintf("parallelism must be between 1 and %d, got %d", maxEmbeddingParallelism, req.parallelism), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "parallelism",
+			})
+		}
+	}
+	if v, ok := params["stop_on_error"].(bool); ok {
+		req.stopOnError = v
+	}
+
+	if raw := stringParam(params, "resume_token", ""); raw != "" {
+		token, err := decodeEmbeddingResumeToken(raw)
+		if err != nil {
+			return nil, Error(fmt.Sprintf("invalid resume_token: %v", err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "resume_token",
+			})
+		}
+		if token.Texts != len(req.texts) || token.Digest != textsDigest(req.texts) {
+			return nil, Error("resume_token does not match texts: resend the texts of the batch the token was returned for", "VALIDATION_ERROR", map[string]interface{}{
+				"parameter":   "resume_token",
+				"texts_count": len(req.texts),
+				"token_texts": token.Texts,
+			})
+		}
+		if _, set := params["model"]; set && req.model != token.Model {
+			return nil, Error(fmt.Sprintf("model '%s' does not match the resume_token model '%s'", req.model, token.Model), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "model",
+			})
+		}
+		if _, set := params["batch_size"]; set && req.batchSize != token.BatchSize {
+			return nil, Error(fmt.Sprintf("batch_size %d does not match the resume_token batch_size %d", req.batchSize, token.BatchSize), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "batch_size",
+			})
+		}
+		req.model, req.batchSize = token.Model, token.BatchSize
+		batches := req.batchCount()
+		req.pending = make(map[int]bool, len(token.Pending))
+		for _, index := range token.Pending {
+			if index < 0 || index >= batches {
+				return nil, Error(fmt.Sprintf("invalid resume_token: batch %d out of range", index), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "resume_token",
+				})
+			}
+			req.pending[index] = true
+		}
+	}
+
+	return req, nil
+}
+
+// batchCount returns the number of sub-batches the texts split into
+func (r *batchEmbeddingRequest) batchCount() int {
+	return (len(r.texts) + r.batchSize - 1) / r.batchSize
+}
+
+// batchBounds returns the range of texts in sub-batch index
+func (r *batchEmbeddingRequest) batchBounds(index int) (int, int) {
+	start := index * r.batchSize
+	end := start + r.batchSize
+	if end > len(r.texts) {
+		end = len(r.texts)
+	}
+	return start, end
+}
+
+// embedBatchFunc embeds one sub-batch, returning an embedding per text
+type embedBatchFunc func(ctx context.Context, model string, texts []string) ([]interface{}, error)
+
+// runEmbeddingSubBatches embeds the texts of a request in sub-batches, up to
+// parallelism at a time. It returns an embedding per text, nil for texts
+// whose sub-batch did not succeed, and a report per sub-batch.
+func runEmbeddingSubBatches(ctx context.Context, req *batchEmbeddingRequest, embed embedBatchFunc) ([]interface{}, []subBatchResult) {
+	embeddings := make([]interface{}, len(req.texts))
+	results := make([]subBatchResult, req.batchCount())
+	for i := range results {
+		start, end := req.batchBounds(i)
+		results[i] = subBatchResult{Index: i, Start: start, Count: end - start, Status: subBatchSkipped}
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	sem := make(chan struct{}, req.parallelism)
+	var wg sync.WaitGroup
+	for i := range results {
+		if req.pending != nil && !req.pending[i] {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-runCtx.Done():
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result := &results[i]
+			start, end := req.batchBounds(i)
+			began := time.Now()
+			batch, err := embed(runCtx, req.model, req.texts[start:end])
+			if err == nil && len(batch) != end-start {
+				err = fmt.Errorf("embedding function returned %d embeddings for %d texts", len(batch), end-start)
+			}
+			result.DurationMS = time.Since(began).Milliseconds()
+			if err != nil {
+				result.Status, result.Error = subBatchFailed, err.Error()
+				if req.stopOnError {
+					stop()
+				}
+				return
+			}
+			copy(embeddings[start:end], batch)
+			result.Status = subBatchSucceeded
+		}(i)
+	}
+	wg.Wait()
+	return embeddings, results
+}
+
+// unfinishedBatches returns the sub-batches of the call that still need to
+// run: those that failed, and those skipped that were due to run
+func unfinishedBatches(req *batchEmbeddingRequest, results []subBatchResult) []int {
+	var pending []int
+	for _, result := range results {
+		due := req.pending == nil || req.pending[result.Index]
+		if result.Status == subBatchFailed || (result.Status == subBatchSkipped && due) {
+			pending = append(pending, result.Index)
+		}
+	}
+	return pending
+}
+
+// textsDigest identifies the texts a resume token applies to
+func textsDigest(texts []string) string {
+	h := sha256.New()
+	for _, text := range texts {
+		h.Write([]byte(text))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// encodeEmbeddingResumeToken returns the token continuing a batch with the
+// pending sub-batches
+func encodeEmbeddingResumeToken(req *batchEmbeddingRequest, pending []int) string {
+	data, _ := json.Marshal(embeddingResumeToken{
+		Version:   1,
+		Model:     req.model,
+		BatchSize: req.batchSize,
+		Texts:     len(req.texts),
+		Digest:    textsDigest(req.texts),
+		Pending:   pending,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeEmbeddingResumeToken(raw string) (*embeddingResumeToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var token embeddingResumeToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	if token.Version != 1 || token.BatchSize < 1 || token.Model == "" {
+		return nil, fmt.Errorf("unsupported token")
+	}
+	return &token, nil
+}
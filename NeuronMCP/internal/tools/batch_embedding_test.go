@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func batchEmbeddingTexts(n int) []interface{} {
+	texts := make([]interface{}, n)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text %d", i)
+	}
+	return texts
+}
+
+func TestParseBatchEmbeddingRequest(t *testing.T) {
+	req, invalid := parseBatchEmbeddingRequest(map[string]interface{}{"texts": batchEmbeddingTexts(250)})
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if req.model != "default" || req.batchSize != defaultEmbeddingSubBatchSize || req.parallelism != defaultEmbeddingParallelism {
+		t.Errorf("unexpected defaults: %+v", req)
+	}
+	if got := req.batchCount(); got != 3 {
+		t.Errorf("batchCount() = %d, want 3", got)
+	}
+	if start, end := req.batchBounds(2); start != 200 || end != 250 {
+		t.Errorf("batchBounds(2) = %d, %d, want 200, 250", start, end)
+	}
+
+	for name, params := range map[string]map[string]interface{}{
+		"empty":           {"texts": []interface{}{}},
+		"too many":        {"texts": batchEmbeddingTexts(maxBatchEmbeddingTexts + 1)},
+		"empty text":      {"texts": []interface{}{"a", ""}},
+		"non-string":      {"texts": []interface{}{"a", 1.0}},
+		"batch_size":      {"texts": batchEmbeddingTexts(2), "batch_size": float64(0)},
+		"parallelism":     {"texts": batchEmbeddingTexts(2), "parallelism": float64(maxEmbeddingParallelism + 1)},
+		"malformed token": {"texts": batchEmbeddingTexts(2), "resume_token": "not a token"},
+	} {
+		if _, invalid := parseBatchEmbeddingRequest(params); invalid == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestRunEmbeddingSubBatchesPartialFailureAndResume(t *testing.T) {
+	params := map[string]interface{}{
+		"texts":       batchEmbeddingTexts(10),
+		"model":       "m",
+		"batch_size":  float64(3),
+		"parallelism": float64(2),
+	}
+	req, invalid := parseBatchEmbeddingRequest(params)
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+
+	var calls int32
+	embed := func(ctx context.Context, model string, texts []string) ([]interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if texts[0] == "text 3" {
+			return nil, errors.New("statement timeout")
+		}
+		out := make([]interface{}, len(texts))
+		for i, text := range texts {
+			out[i] = "[" + text + "]"
+		}
+		return out, nil
+	}
+
+	embeddings, results := runEmbeddingSubBatches(context.Background(), req, embed)
+	if calls != 4 {
+		t.Errorf("embedded %d sub-batches, want 4", calls)
+	}
+	if results[1].Status != subBatchFailed || results[1].Error != "statement timeout" {
+		t.Errorf("sub-batch 1 = %+v, want failed", results[1])
+	}
+	if results[3].Status != subBatchSucceeded || results[3].Count != 1 {
+		t.Errorf("sub-batch 3 = %+v, want one text succeeded", results[3])
+	}
+	if embeddings[0] != "[text 0]" || embeddings[3] != nil || embeddings[9] != "[text 9]" {
+		t.Errorf("unexpected embeddings: %v", embeddings)
+	}
+
+	pending := unfinishedBatches(req, results)
+	if len(pending) != 1 || pending[0] != 1 {
+		t.Fatalf("unfinishedBatches() = %v, want [1]", pending)
+	}
+
+	// Resuming runs only the failed sub-batch, with the token's settings
+	resumed, invalid := parseBatchEmbeddingRequest(map[string]interface{}{
+		"texts":        params["texts"],
+		"resume_token": encodeEmbeddingResumeToken(req, pending),
+	})
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if resumed.model != "m" || resumed.batchSize != 3 {
+		t.Errorf("resumed request did not take the token settings: %+v", resumed)
+	}
+	calls = 0
+	embed2 := func(ctx context.Context, model string, texts []string) ([]interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return make([]interface{}, len(texts)), nil
+	}
+	_, results = runEmbeddingSubBatches(context.Background(), resumed, embed2)
+	if calls != 1 || results[1].Status != subBatchSucceeded || results[0].Status != subBatchSkipped {
+		t.Errorf("resume ran %d sub-batches: %+v", calls, results)
+	}
+	if pending := unfinishedBatches(resumed, results); len(pending) != 0 {
+		t.Errorf("unfinishedBatches() after resume = %v, want none", pending)
+	}
+}
+
+func TestEmbeddingResumeTokenRejectsOtherTexts(t *testing.T) {
+	req, _ := parseBatchEmbeddingRequest(map[string]interface{}{"texts": []interface{}{"a", "b"}})
+	token := encodeEmbeddingResumeToken(req, []int{0})
+
+	_, invalid := parseBatchEmbeddingRequest(map[string]interface{}{"texts": []interface{}{"a", "c"}, "resume_token": token})
+	if invalid == nil || !strings.Contains(invalid.Error.Message, "does not match texts") {
+		t.Errorf("expected a texts mismatch error, got %+v", invalid)
+	}
+	_, invalid = parseBatchEmbeddingRequest(map[string]interface{}{"texts": []interface{}{"a", "b"}, "resume_token": token, "model": "other"})
+	if invalid == nil {
+		t.Error("expected a model mismatch error")
+	}
+}
+
+func TestRunEmbeddingSubBatchesStopOnError(t *testing.T) {
+	req, _ := parseBatchEmbeddingRequest(map[string]interface{}{
+		"texts":         batchEmbeddingTexts(5),
+		"batch_size":    float64(1),
+		"parallelism":   float64(1),
+		"stop_on_error": true,
+	})
+	embed := func(ctx context.Context, model string, texts []string) ([]interface{}, error) {
+		if texts[0] == "text 1" {
+			return nil, errors.New("boom")
+		}
+		return []interface{}{"v"}, nil
+	}
+	_, results := runEmbeddingSubBatches(context.Background(), req, embed)
+	if results[0].Status != subBatchSucceeded || results[1].Status != subBatchFailed || results[4].Status != subBatchSkipped {
+		t.Errorf("unexpected statuses: %+v", results)
+	}
+	if pending := unfinishedBatches(req, results); len(pending) != 4 {
+		t.Errorf("unfinishedBatches() = %v, want the failed and skipped sub-batches", pending)
+	}
+}
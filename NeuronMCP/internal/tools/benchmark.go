@@ -0,0 +1,711 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Benchmark limits
+const (
+	// BenchmarkTimeout bounds a whole benchmark_search call
+	BenchmarkTimeout            = 10 * time.Minute
+	defaultBenchmarkQueries     = 100
+	maxBenchmarkQueries         = 1000
+	defaultBenchmarkK           = 10
+	maxBenchmarkK               = 100
+	defaultBenchmarkConcurrency = 4
+	maxBenchmarkConcurrency     = 32
+	maxBenchmarkConfigurations  = 8
+	defaultBenchmarkWarmup      = 5
+)
+
+// exactSearchSettings turn off index scans, so a search scans the table
+// and returns the true nearest neighbours
+var exactSearchSettings = []string{
+	"SET LOCAL enable_indexscan = off",
+	"SET LOCAL enable_indexonlyscan = off",
+	"SET LOCAL enable_bitmapscan = off",
+}
+
+// benchmarkConfig is one search setup benchmark_search measures
+type benchmarkConfig struct {
+	name   string
+	metric string
+	tuning *database.SearchTuning
+}
+
+// benchmarkQuery is one query of the workload. truth is the ground truth
+// the caller provided, nil when it is computed by exact search.
+type benchmarkQuery struct {
+	vector []float32
+	truth  []string
+}
+
+// workloadStats is what running a workload measured
+type workloadStats struct {
+	latenciesMS []float64 // of the queries that succeeded
+	wall        time.Duration
+	errors      int
+	firstError  string
+}
+
+// BenchmarkSearchTool measures recall and latency of vector search setups
+type BenchmarkSearchTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewBenchmarkSearchTool creates a new search benchmarking tool
+func NewBenchmarkSearchTool(db *database.Database, logger *logging.Logger) *BenchmarkSearchTool {
+	return &BenchmarkSearchTool{
+		BaseTool: NewBaseTool(
+			"benchmark_search",
+			"Benchmark vector search on a table: recall@k against exact search, P50/P95 latency and throughput at a given concurrency, compared across distance metrics and index settings",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the vector column",
+					},
+					"id_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column identifying rows, compared against the ground truth",
+					},
+					"k": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultBenchmarkK,
+						"minimum":     1,
+						"maximum":     maxBenchmarkK,
+						"description": "Neighbours fetched per query; recall is measured at k",
+					},
+					"num_queries": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultBenchmarkQueries,
+						"minimum":     1,
+						"maximum":     maxBenchmarkQueries,
+						"description": "Query vectors sampled from the table when queries is not given",
+					},
+					"queries": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"vector": map[string]interface{}{
+									"type":  "array",
+									"items": map[string]interface{}{"type": "number"},
+								},
+								"ground_truth": map[string]interface{}{
+									"type":        "array",
+									"description": "id_column values of the true nearest neighbours; computed by exact search when absent",
+								},
+							},
+							"required": []interface{}{"vector"},
+						},
+						"description": "Query vectors to run instead of sampling, with optional ground truth",
+					},
+					"concurrency": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultBenchmarkConcurrency,
+						"minimum":     1,
+						"maximum":     maxBenchmarkConcurrency,
+						"description": "Queries run at the same time",
+					},
+					"warmup_queries": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultBenchmarkWarmup,
+						"minimum":     0,
+						"maximum":     100,
+						"description": "Queries run untimed before each configuration, to load the index into cache",
+					},
+					"configurations": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": withSearchTuning(map[string]interface{}{
+								"name": map[string]interface{}{
+									"type":        "string",
+									"description": "Label of the configuration in the results",
+								},
+								"distance_metric": map[string]interface{}{
+									"type":    "string",
+									"enum":    []interface{}{"l2", "cosine", "inner_product"},
+									"default": "l2",
+								},
+							}),
+						},
+						"maxItems":    maxBenchmarkConfigurations,
+						"description": "Search setups to compare; defaults to one l2 search with the database settings",
+					},
+				},
+				"required": []interface{}{"table", "vector_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute runs the benchmark
+func (t *BenchmarkSearchTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for benchmark_search tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	tableName, _ := params["table"].(string)
+	vectorColumn, _ := params["vector_column"].(string)
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	if vectorColumn == "" {
+		return Error("vector_column parameter is required and cannot be empty for benchmark_search tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "vector_column",
+		}), nil
+	}
+	idColumn := stringParam(params, "id_column", "id")
+
+	k, errResult := intParamInRange(params, "k", defaultBenchmarkK, 1, maxBenchmarkK)
+	if errResult != nil {
+		return errResult, nil
+	}
+	numQueries, errResult := intParamInRange(params, "num_queries", defaultBenchmarkQueries, 1, maxBenchmarkQueries)
+	if errResult != nil {
+		return errResult, nil
+	}
+	concurrency, errResult := intParamInRange(params, "concurrency", defaultBenchmarkConcurrency, 1, maxBenchmarkConcurrency)
+	if errResult != nil {
+		return errResult, nil
+	}
+	warmup, errResult := intParamInRange(params, "warmup_queries", defaultBenchmarkWarmup, 0, 100)
+	if errResult != nil {
+		return errResult, nil
+	}
+	configs, err := parseBenchmarkConfigurations(params["configurations"], k)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid configurations for benchmark_search tool: %v", err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "configurations",
+		}), nil
+	}
+	queries, err := parseBenchmarkQueries(params["queries"])
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid queries for benchmark_search tool: %v", err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "queries",
+		}), nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for benchmark_search", "DATABASE_ERROR", map[string]interface{}{
+			"table": tableName,
+		}), nil
+	}
+
+	benchCtx, cancel := context.WithTimeout(ctx, BenchmarkTimeout)
+	defer cancel()
+
+	querySource := "provided"
+	if queries == nil {
+		querySource = "sampled"
+		if queries, err = sampleBenchmarkQueries(benchCtx, db, table, vectorColumn, numQueries); err != nil {
+			return t.benchmarkError(tableName, vectorColumn, "query sample", err), nil
+		}
+		if len(queries) == 0 {
+			return Error(fmt.Sprintf("Table '%s' has no rows with a '%s' vector to sample queries from", tableName, vectorColumn), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "table",
+			}), nil
+		}
+	}
+
+	// Ground truth and the exact search baseline, once per metric
+	truth := map[string][][]string{}
+	exact := map[string]interface{}{}
+	for _, config := range configs {
+		if _, done := truth[config.metric]; done {
+			continue
+		}
+		results := make([][]string, len(queries))
+		var pending []int
+		for i, q := range queries {
+			if q.truth != nil {
+				results[i] = q.truth
+			} else {
+				pending = append(pending, i)
+			}
+		}
+		truth[config.metric] = results
+		if len(pending) == 0 {
+			continue
+		}
+		query := benchmarkSearchQuery(table, idColumn, vectorColumn, config.metric, k, nil)
+		stats := runBenchmarkWorkload(benchCtx, len(pending), concurrency, func(ctx context.Context, i int) error {
+			ids, err := runBenchmarkSearch(ctx, db, exactSearchSettings, query, queries[pending[i]].vector, k)
+			results[pending[i]] = ids
+			return err
+		})
+		if len(stats.latenciesMS) == 0 {
+			return t.benchmarkError(tableName, vectorColumn, "exact search", fmt.Errorf("%s", stats.firstError)), nil
+		}
+		exact[config.metric] = workloadSummary(stats)
+	}
+
+	results := make([]map[string]interface{}, 0, len(configs))
+	for _, config := range configs {
+		query := benchmarkSearchQuery(table, idColumn, vectorColumn, config.metric, k, config.tuning)
+		settings := (&database.QueryBuilder{}).SearchSettings(config.tuning)
+
+		for i := 0; i < warmup && i < len(queries); i++ {
+			runBenchmarkSearch(benchCtx, db, settings, query, queries[i].vector, k)
+		}
+
+		found := make([][]string, len(queries))
+		stats := runBenchmarkWorkload(benchCtx, len(queries), concurrency, func(ctx context.Context, i int) error {
+			ids, err := runBenchmarkSearch(ctx, db, settings, query, queries[i].vector, k)
+			found[i] = ids
+			return err
+		})
+
+		result := workloadSummary(stats)
+		result["name"] = config.name
+		result["distance_metric"] = config.metric
+		searchTuningMetadata(result, config.tuning)
+		if len(stats.latenciesMS) > 0 {
+			recalls := make([]float64, 0, len(queries))
+			for i := range queries {
+				if found[i] != nil && truth[config.metric][i] != nil {
+					recalls = append(recalls, recallAtK(found[i], truth[config.metric][i], k))
+				}
+			}
+			if len(recalls) > 0 {
+				result["recall_at_k"] = mean(recalls)
+				result["min_recall"] = minFloat(recalls)
+			}
+		}
+		if scan, err := benchmarkIndexScan(benchCtx, db, settings, query, queries[0].vector, k); err == nil {
+			result["index_scan"] = scan
+		}
+		results = append(results, result)
+	}
+
+	return Success(map[string]interface{}{
+		"table":          tableName,
+		"vector_column":  vectorColumn,
+		"k":              k,
+		"queries":        len(queries),
+		"query_source":   querySource,
+		"concurrency":    concurrency,
+		"configurations": results,
+		"exact":          exact,
+		"best":           bestBenchmarkConfigurations(results),
+	}, map[string]interface{}{
+		"tool": "benchmark_search",
+	}), nil
+}
+
+func (t *BenchmarkSearchTool) benchmarkError(table, vectorColumn, stage string, err error) *ToolResult {
+	t.logger.Error("Search benchmark failed", err, map[string]interface{}{
+		"table":         table,
+		"vector_column": vectorColumn,
+		"stage":         stage,
+	})
+	return Error(fmt.Sprintf("Benchmark failed during %s: table='%s', vector_column='%s', error=%v", stage, table, vectorColumn, err), "BENCHMARK_ERROR", map[string]interface{}{
+		"table":         table,
+		"vector_column": vectorColumn,
+		"stage":         stage,
+		"error":         err.Error(),
+	})
+}
+
+// intParamInRange reads an integer parameter, or def when it is absent
+func intParamInRange(params map[string]interface{}, name string, def, min, max int) (int, *ToolResult) {
+	v, ok := params[name].(float64)
+	if !ok {
+		return def, nil
+	}
+	if v != math.Trunc(v) || int(v) < min || int(v) > max {
+		return 0, Error(fmt.Sprintf("%s must be an integer between %d and %d, got %v", name, min, max, v), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": name,
+		})
+	}
+	return int(v), nil
+}
+
+// parseBenchmarkConfigurations reads the configurations parameter. Without
+// one the benchmark measures a single l2 search.
+func parseBenchmarkConfigurations(raw interface{}, k int) ([]benchmarkConfig, error) {
+	items, _ := raw.([]interface{})
+	if len(items) == 0 {
+		return []benchmarkConfig{{name: "l2", metric: "l2"}}, nil
+	}
+	if len(items) > maxBenchmarkConfigurations {
+		return nil, fmt.Errorf("at most %d configurations can be compared, got %d", maxBenchmarkConfigurations, len(items))
+	}
+	configs := make([]benchmarkConfig, 0, len(items))
+	names := map[string]bool{}
+	for i, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("configuration %d must be an object", i)
+		}
+		config := benchmarkConfig{metric: stringParam(obj, "distance_metric", "l2")}
+		if _, ok := similarityJoinOperators[config.metric]; !ok {
+			return nil, fmt.Errorf("configuration %d: distance_metric must be l2, cosine or inner_product, got '%s'", i, config.metric)
+		}
+		tuning, err := searchTuningParam(obj, k)
+		if err != nil {
+			return nil, fmt.Errorf("configuration %d: %w", i, err)
+		}
+		config.tuning = tuning
+		config.name = stringParam(obj, "name", benchmarkConfigName(config))
+		if names[config.name] {
+			return nil, fmt.Errorf("configuration %d: name '%s' is used twice", i, config.name)
+		}
+		names[config.name] = true
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// benchmarkConfigName labels a configuration by its metric and settings,
+// such as "cosine ef_search=64"
+func benchmarkConfigName(config benchmarkConfig) string {
+	parts := []string{config.metric}
+	if config.tuning != nil {
+		if config.tuning.EfSearch > 0 {
+			parts = append(parts, fmt.Sprintf("ef_search=%d", config.tuning.EfSearch))
+		}
+		if config.tuning.Probes > 0 {
+			parts = append(parts, fmt.Sprintf("probes=%d", config.tuning.Probes))
+		}
+		if config.tuning.RefineK > 0 {
+			parts = append(parts, fmt.Sprintf("refine_k=%d", config.tuning.RefineK))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseBenchmarkQueries reads the queries parameter, returning nil when
+// none are given
+func parseBenchmarkQueries(raw interface{}) ([]benchmarkQuery, error) {
+	items, _ := raw.([]interface{})
+	if len(items) == 0 {
+		return nil, nil
+	}
+	if len(items) > maxBenchmarkQueries {
+		return nil, fmt.Errorf("at most %d queries can be run, got %d", maxBenchmarkQueries, len(items))
+	}
+	queries := make([]benchmarkQuery, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("query %d must be an object with a vector", i)
+		}
+		values, _ := obj["vector"].([]interface{})
+		if len(values) == 0 {
+			return nil, fmt.Errorf("query %d has no vector", i)
+		}
+		vec := make([]float32, len(values))
+		for j, v := range values {
+			f, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("query %d: vector element %d must be a number, got %T", i, j, v)
+			}
+			vec[j] = float32(f)
+		}
+		queries[i].vector = vec
+		if raw, ok := obj["ground_truth"].([]interface{}); ok {
+			queries[i].truth = make([]string, len(raw))
+			for j, id := range raw {
+				queries[i].truth[j] = benchmarkID(id)
+			}
+		}
+	}
+	return queries, nil
+}
+
+// benchmarkID renders an id_column value as the text the database returns
+// for it, so JSON numbers such as 42 match "42"
+func benchmarkID(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// sampleBenchmarkQueries takes n random vectors of the table as queries
+func sampleBenchmarkQueries(ctx context.Context, db *database.Database, table pgx.Identifier, vectorColumn string, n int) ([]benchmarkQuery, error) {
+	column := pgx.Identifier{vectorColumn}.Sanitize()
+	rows, err := db.Query(ctx, fmt.Sprintf(
+		"SELECT %s::text FROM %s WHERE %s IS NOT NULL ORDER BY random() LIMIT $1", column, table.Sanitize(), column), n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var queries []benchmarkQuery
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, err
+		}
+		vec, err := parseVectorText(text)
+		if err != nil {
+			return nil, fmt.Errorf("column '%s' is not a vector column: %w", vectorColumn, err)
+		}
+		queries = append(queries, benchmarkQuery{vector: vec})
+	}
+	return queries, rows.Err()
+}
+
+// benchmarkSearchQuery builds the search a configuration runs, returning
+// the id_column of the k nearest rows as text. With refine_k above k the
+// index returns refine_k candidates, re-ranked by exact distance. $1 is the
+// query vector and $2 is k.
+func benchmarkSearchQuery(table pgx.Identifier, idColumn, vectorColumn, metric string, k int, tuning *database.SearchTuning) string {
+	distance := fmt.Sprintf("%s %s $1::vector", pgx.Identifier{vectorColumn}.Sanitize(), similarityJoinOperators[metric])
+	id := pgx.Identifier{idColumn}.Sanitize()
+	if tuning != nil && tuning.RefineK > k {
+		return fmt.Sprintf(
+			"SELECT id FROM (SELECT %s::text AS id, %s AS distance FROM %s ORDER BY distance LIMIT %d) candidates ORDER BY distance LIMIT $2",
+			id, distance, table.Sanitize(), tuning.RefineK)
+	}
+	return fmt.Sprintf("SELECT %s::text FROM %s ORDER BY %s LIMIT $2", id, table.Sanitize(), distance)
+}
+
+// runBenchmarkSearch runs one search, applying settings in its own
+// transaction, and returns the ids it found
+func runBenchmarkSearch(ctx context.Context, db *database.Database, settings []string, query string, vector []float32, k int) ([]string, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, VectorSearchTimeout)
+	defer cancel()
+
+	var rows pgx.Rows
+	var err error
+	if len(settings) == 0 {
+		rows, err = db.Query(queryCtx, query, formatFloat32Vector(vector), k)
+	} else {
+		tx, txErr := db.Begin(queryCtx)
+		if txErr != nil {
+			return nil, txErr
+		}
+		defer tx.Rollback(queryCtx)
+		for _, setting := range settings {
+			if _, err := tx.Exec(queryCtx, setting); err != nil {
+				return nil, fmt.Errorf("failed to apply '%s': %w", setting, err)
+			}
+		}
+		rows, err = tx.Query(queryCtx, query, formatFloat32Vector(vector), k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]string, 0, k)
+	for rows.Next() {
+		var id *string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if id != nil {
+			ids = append(ids, *id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// benchmarkIndexScan reports whether the plan of a configuration's search
+// uses an index
+func benchmarkIndexScan(ctx context.Context, db *database.Database, settings []string, query string, vector []float32, k int) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	for _, setting := range settings {
+		if _, err := tx.Exec(ctx, setting); err != nil {
+			return false, err
+		}
+	}
+	rows, err := tx.Query(ctx, "EXPLAIN "+query, formatFloat32Vector(vector), k)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	scan := false
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return false, err
+		}
+		if strings.Contains(line, "Index Scan") || strings.Contains(line, "Index Only Scan") {
+			scan = true
+		}
+	}
+	return scan, rows.Err()
+}
+
+// runBenchmarkWorkload runs queries 0..n-1 with up to concurrency at a time
+// and measures the latency of each and the wall time of the whole run
+func runBenchmarkWorkload(ctx context.Context, n, concurrency int, run func(ctx context.Context, i int) error) workloadStats {
+	latencies := make([]float64, n)
+	errs := make([]error, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	began := time.Now()
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				start := time.Now()
+				errs[i] = run(ctx, i)
+				latencies[i] = float64(time.Since(start).Microseconds()) / 1000
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	stats := workloadStats{wall: time.Since(began)}
+	for i, err := range errs {
+		if err != nil {
+			stats.errors++
+			if stats.firstError == "" {
+				stats.firstError = err.Error()
+			}
+			continue
+		}
+		stats.latenciesMS = append(stats.latenciesMS, latencies[i])
+	}
+	return stats
+}
+
+// workloadSummary reports the latency percentiles, throughput and errors
+// of a run
+func workloadSummary(stats workloadStats) map[string]interface{} {
+	summary := map[string]interface{}{
+		"errors": stats.errors,
+	}
+	if stats.firstError != "" {
+		summary["first_error"] = stats.firstError
+	}
+	if len(stats.latenciesMS) == 0 {
+		return summary
+	}
+	summary["latency_ms"] = map[string]interface{}{
+		"p50":  percentileOf(stats.latenciesMS, 50),
+		"p95":  percentileOf(stats.latenciesMS, 95),
+		"p99":  percentileOf(stats.latenciesMS, 99),
+		"mean": mean(stats.latenciesMS),
+		"max":  percentileOf(stats.latenciesMS, 100),
+	}
+	if seconds := stats.wall.Seconds(); seconds > 0 {
+		summary["throughput_qps"] = float64(len(stats.latenciesMS)) / seconds
+	}
+	return summary
+}
+
+// recallAtK is the share of the first k ground truth ids that were found
+func recallAtK(found, truth []string, k int) float64 {
+	if len(truth) > k {
+		truth = truth[:k]
+	}
+	if len(truth) == 0 {
+		return 1
+	}
+	seen := make(map[string]bool, len(found))
+	for _, id := range found {
+		seen[id] = true
+	}
+	hits := 0
+	for _, id := range truth {
+		if seen[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(truth))
+}
+
+// bestBenchmarkConfigurations names the configuration with the highest
+// recall, the lowest P95 latency and the highest throughput
+func bestBenchmarkConfigurations(results []map[string]interface{}) map[string]interface{} {
+	best := map[string]interface{}{}
+	pick := func(key string, value func(map[string]interface{}) (float64, bool), higher bool) {
+		var bestName interface{}
+		bestValue := 0.0
+		for _, result := range results {
+			v, ok := value(result)
+			if !ok {
+				continue
+			}
+			if bestName == nil || (higher && v > bestValue) || (!higher && v < bestValue) {
+				bestName, bestValue = result["name"], v
+			}
+		}
+		if bestName != nil {
+			best[key] = bestName
+		}
+	}
+	pick("recall", func(r map[string]interface{}) (float64, bool) {
+		v, ok := r["recall_at_k"].(float64)
+		return v, ok
+	}, true)
+	pick("p95_latency", func(r map[string]interface{}) (float64, bool) {
+		latency, ok := r["latency_ms"].(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+		v, ok := latency["p95"].(float64)
+		return v, ok
+	}, false)
+	pick("throughput", func(r map[string]interface{}) (float64, bool) {
+		v, ok := r["throughput_qps"].(float64)
+		return v, ok
+	}, true)
+	return best
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func minFloat(values []float64) float64 {
+	min := values[0]
+	for _, v := range values[1:] {
+		min = math.Min(min, v)
+	}
+	return min
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+func TestRecallAtK(t *testing.T) {
+	cases := []struct {
+		found, truth []string
+		k            int
+		want         float64
+	}{
+		{[]string{"1", "2", "3"}, []string{"1", "2", "3"}, 3, 1},
+		{[]string{"1", "9", "3"}, []string{"1", "2", "3"}, 3, 2.0 / 3},
+		{[]string{"3", "2", "1"}, []string{"1", "2", "3"}, 3, 1}, // order does not matter
+		{[]string{"1", "2"}, []string{"1", "2", "3", "4"}, 2, 1}, // truth is cut to k
+		{nil, []string{"1"}, 1, 0},
+		{nil, nil, 5, 1},
+	}
+	for _, c := range cases {
+		if got := recallAtK(c.found, c.truth, c.k); got != c.want {
+			t.Errorf("recallAtK(%v, %v, %d) = %g, want %g", c.found, c.truth, c.k, got, c.want)
+		}
+	}
+}
+
+func TestParseBenchmarkConfigurations(t *testing.T) {
+	configs, err := parseBenchmarkConfigurations(nil, 10)
+	if err != nil || len(configs) != 1 || configs[0].metric != "l2" || configs[0].tuning != nil {
+		t.Fatalf("default configurations = %+v, %v; want one untuned l2 search", configs, err)
+	}
+
+	configs, err = parseBenchmarkConfigurations([]interface{}{
+		map[string]interface{}{"distance_metric": "cosine", "ef_search": float64(64)},
+		map[string]interface{}{"distance_metric": "cosine", "ef_search": float64(200), "refine_k": float64(40)},
+		map[string]interface{}{"name": "baseline"},
+	}, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, want := range []string{"cosine ef_search=64", "cosine ef_search=200 refine_k=40", "baseline"} {
+		if configs[i].name != want {
+			t.Errorf("configuration %d name = %q, want %q", i, configs[i].name, want)
+		}
+	}
+
+	for name, raw := range map[string][]interface{}{
+		"unindexed metric": {map[string]interface{}{"distance_metric": "l1"}},
+		"duplicate name":   {map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "a", "probes": float64(4)}},
+		"refine_k below k": {map[string]interface{}{"refine_k": float64(5)}},
+		"not an object":    {"l2"},
+	} {
+		if _, err := parseBenchmarkConfigurations(raw, 10); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseBenchmarkQueries(t *testing.T) {
+	queries, err := parseBenchmarkQueries([]interface{}{
+		map[string]interface{}{"vector": []interface{}{0.5, 1.0}, "ground_truth": []interface{}{float64(42), "doc-7"}},
+		map[string]interface{}{"vector": []interface{}{1.0, 0.0}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queries) != 2 || len(queries[0].vector) != 2 {
+		t.Fatalf("queries = %+v", queries)
+	}
+	if got := strings.Join(queries[0].truth, ","); got != "42,doc-7" {
+		t.Errorf("ground truth = %q, want numbers rendered as the database renders them", got)
+	}
+	if queries[1].truth != nil {
+		t.Error("a query without ground_truth should be left for exact search")
+	}
+
+	if queries, err := parseBenchmarkQueries(nil); err != nil || queries != nil {
+		t.Errorf("no queries = %v, %v; want nil so the table is sampled", queries, err)
+	}
+	if _, err := parseBenchmarkQueries([]interface{}{map[string]interface{}{"vector": []interface{}{"x"}}}); err == nil {
+		t.Error("expected an error for a non-numeric vector element")
+	}
+}
+
+func TestBenchmarkSearchQuery(t *testing.T) {
+	table := pgx.Identifier{"public", "docs"}
+	query := benchmarkSearchQuery(table, "id", "embedding", "cosine", 10, nil)
+	want := `SELECT "id"::text FROM "public"."docs" ORDER BY "embedding" <=> $1::vector LIMIT $2`
+	if query != want {
+		t.Errorf("query = %s\nwant %s", query, want)
+	}
+
+	refined := benchmarkSearchQuery(table, "id", "embedding", "l2", 10, &database.SearchTuning{RefineK: 50})
+	for _, want := range []string{`"embedding" <-> $1::vector AS distance`, "LIMIT 50) candidates", "ORDER BY distance LIMIT $2"} {
+		if !strings.Contains(refined, want) {
+			t.Errorf("refined query missing %q:\n%s", want, refined)
+		}
+	}
+}
+
+func TestRunBenchmarkWorkload(t *testing.T) {
+	var running, peak int32
+	stats := runBenchmarkWorkload(context.Background(), 20, 3, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		if i%5 == 0 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if peak > 3 {
+		t.Errorf("ran %d queries at once, want at most 3", peak)
+	}
+	if stats.errors != 4 || stats.firstError != "boom" {
+		t.Errorf("errors = %d (%q), want 4", stats.errors, stats.firstError)
+	}
+	if len(stats.latenciesMS) != 16 {
+		t.Errorf("latencies = %d, want one per successful query", len(stats.latenciesMS))
+	}
+
+	summary := workloadSummary(stats)
+	latency, ok := summary["latency_ms"].(map[string]interface{})
+	if !ok || latency["p50"].(float64) <= 0 || latency["p95"].(float64) < latency["p50"].(float64) {
+		t.Errorf("latency summary = %v", summary["latency_ms"])
+	}
+	if qps, ok := summary["throughput_qps"].(float64); !ok || qps <= 0 {
+		t.Errorf("throughput_qps = %v", summary["throughput_qps"])
+	}
+}
+
+func TestBestBenchmarkConfigurations(t *testing.T) {
+	best := bestBenchmarkConfigurations([]map[string]interface{}{
+		{"name": "fast", "recall_at_k": 0.8, "latency_ms": map[string]interface{}{"p95": 1.0}, "throughput_qps": 900.0},
+		{"name": "accurate", "recall_at_k": 0.99, "latency_ms": map[string]interface{}{"p95": 4.0}, "throughput_qps": 300.0},
+		{"name": "broken", "errors": 100},
+	})
+	if best["recall"] != "accurate" || best["p95_latency"] != "fast" || best["throughput"] != "fast" {
+		t.Errorf("best = %v", best)
+	}
+}
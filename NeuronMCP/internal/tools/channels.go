@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// ChannelSubscriber manages the PostgreSQL notification channels forwarded
+// to the MCP client
+type ChannelSubscriber interface {
+	Subscribe(ctx context.Context, channel string) error
+	Unsubscribe(ctx context.Context, channel string) error
+	Channels() []string
+}
+
+type channelSubscriberKey struct{}
+
+// WithChannelSubscriber returns a context carrying subscriber for tool
+// execution
+func WithChannelSubscriber(ctx context.Context, subscriber ChannelSubscriber) context.Context {
+	return context.WithValue(ctx, channelSubscriberKey{}, subscriber)
+}
+
+// ChannelSubscriberFromContext returns the subscriber attached to ctx, if
+// notification forwarding is available
+func ChannelSubscriberFromContext(ctx context.Context) (ChannelSubscriber, bool) {
+	subscriber, ok := ctx.Value(channelSubscriberKey{}).(ChannelSubscriber)
+	return subscriber, ok && subscriber != nil
+}
+
+// SubscribeChannelTool subscribes the client to PostgreSQL NOTIFY channels
+type SubscribeChannelTool struct {
+	*BaseTool
+	logger *logging.Logger
+}
+
+// NewSubscribeChannelTool creates a new subscribe channel tool
+func NewSubscribeChannelTool(db *database.Database, logger *logging.Logger) *SubscribeChannelTool {
+	return &SubscribeChannelTool{
+		BaseTool: NewBaseTool(
+			"subscribe_channel",
+			"Subscribe to a PostgreSQL LISTEN channel; each NOTIFY payload is sent to the client as a notifications/neurondb/channel notification. Also unsubscribes or lists subscribed channels.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"channel": map[string]interface{}{
+						"type":        "string",
+						"description": "Channel name (an unquoted identifier, matched case-sensitively); required for subscribe and unsubscribe",
+					},
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"subscribe", "unsubscribe", "list"},
+						"default":     "subscribe",
+						"description": "Whether to subscribe, unsubscribe or list subscribed channels",
+					},
+				},
+				"required": []interface{}{},
+			},
+		),
+		logger: logger,
+	}
+}
+
+// Execute executes the subscription change
+func (t *SubscribeChannelTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for subscribe_channel tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{"errors": errors, "params": params}), nil
+	}
+
+	subscriber, ok := ChannelSubscriberFromContext(ctx)
+	if !ok {
+		return Error("Channel notifications are not available on this server", "NOTIFICATIONS_UNAVAILABLE", nil), nil
+	}
+
+	action := "subscribe"
+	if a, ok := params["action"].(string); ok && a != "" {
+		action = a
+	}
+	if action == "list" {
+		return Success(map[string]interface{}{
+			"channels": subscriber.Channels(),
+		}, nil), nil
+	}
+
+	channel, _ := params["channel"].(string)
+	if channel == "" {
+		return Error(fmt.Sprintf("channel parameter is required for action '%s'", action), "VALIDATION_ERROR", map[string]interface{}{"params": params}), nil
+	}
+	if err := database.ValidateChannelName(channel); err != nil {
+		return Error(err.Error(), "VALIDATION_ERROR", map[string]interface{}{"channel": channel}), nil
+	}
+
+	var err error
+	if action == "unsubscribe" {
+		err = subscriber.Unsubscribe(ctx, channel)
+	} else {
+		err = subscriber.Subscribe(ctx, channel)
+	}
+	if err != nil {
+		t.logger.Error("Channel subscription change failed", err, map[string]interface{}{
+			"channel": channel,
+			"action":  action,
+		})
+		return Error(fmt.Sprintf("Failed to %s channel '%s': %v", action, channel, err), "SUBSCRIPTION_ERROR", map[string]interface{}{
+			"channel": channel,
+			"action":  action,
+			"error":   err.Error(),
+		}), nil
+	}
+
+	return Success(map[string]interface{}{
+		"channel":  channel,
+		"action":   action,
+		"channels": subscriber.Channels(),
+	}, nil), nil
+}
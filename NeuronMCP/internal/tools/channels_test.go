@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+type fakeSubscriber struct {
+	channels map[string]bool
+}
+
+func (f *fakeSubscriber) Subscribe(ctx context.Context, channel string) error {
+	f.channels[channel] = true
+	return nil
+}
+
+func (f *fakeSubscriber) Unsubscribe(ctx context.Context, channel string) error {
+	delete(f.channels, channel)
+	return nil
+}
+
+func (f *fakeSubscriber) Channels() []string {
+	var channels []string
+	for channel := range f.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+func TestSubscribeChannelTool(t *testing.T) {
+	tool := NewSubscribeChannelTool(nil, logging.NewLogger(config.NewConfigManager().GetLoggingConfig()))
+
+	result, _ := tool.Execute(context.Background(), map[string]interface{}{"channel": "documents"})
+	if result.Success {
+		t.Fatal("Execute() succeeded without a subscriber")
+	}
+
+	sub := &fakeSubscriber{channels: map[string]bool{}}
+	ctx := WithChannelSubscriber(context.Background(), sub)
+
+	result, _ = tool.Execute(ctx, map[string]interface{}{"channel": "new_documents"})
+	if !result.Success {
+		t.Fatalf("subscribe failed: %+v", result.Error)
+	}
+	if !sub.channels["new_documents"] {
+		t.Error("channel was not subscribed")
+	}
+
+	result, _ = tool.Execute(ctx, map[string]interface{}{"channel": "bad; DROP TABLE x"})
+	if result.Success {
+		t.Error("invalid channel name was accepted")
+	}
+
+	result, _ = tool.Execute(ctx, map[string]interface{}{"action": "unsubscribe"})
+	if result.Success {
+		t.Error("unsubscribe without a channel succeeded")
+	}
+
+	result, _ = tool.Execute(ctx, map[string]interface{}{"channel": "new_documents", "action": "unsubscribe"})
+	if !result.Success || sub.channels["new_documents"] {
+		t.Errorf("unsubscribe failed: %+v", result.Error)
+	}
+}
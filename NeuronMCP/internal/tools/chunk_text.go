@@ -0,0 +1,230 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// ChunkTextTool splits raw text into chunks with a choice of strategies and
+// optionally embeds each chunk
+type ChunkTextTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewChunkTextTool creates a new chunk text tool
+func NewChunkTextTool(db *database.Database, logger *logging.Logger) *ChunkTextTool {
+	return &ChunkTextTool{
+		BaseTool: NewBaseTool(
+			"chunk_text",
+			"Split text into chunks using fixed-size, sentence-aware, recursive or semantic strategies; returns chunk texts with character offsets and optional embeddings",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "Text to chunk",
+					},
+					"strategy": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{ChunkStrategyFixed, ChunkStrategySentence, ChunkStrategyRecursive, ChunkStrategySemantic},
+						"default":     ChunkStrategyRecursive,
+						"description": "fixed: fixed-size windows; sentence: pack whole sentences; recursive: split on paragraphs, lines, sentences then words; semantic: break where adjacent sentence embeddings diverge",
+					},
+					"chunk_size": map[string]interface{}{
+						"type":        "number",
+						"default":     1000,
+						"minimum":     1,
+						"description": "Maximum chunk size in characters",
+					},
+					"overlap": map[string]interface{}{
+						"type":        "number",
+						"default":     100,
+						"minimum":     0,
+						"description": "Characters shared between consecutive chunks (not used by the semantic strategy)",
+					},
+					"separators": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Separators for the recursive strategy, most significant first (default: paragraph, line, sentence, word, character)",
+					},
+					"breakpoint_percentile": map[string]interface{}{
+						"type":        "number",
+						"default":     25,
+						"minimum":     1,
+						"maximum":     99,
+						"description": "Semantic strategy: adjacent sentences whose similarity is at or below this percentile start a new chunk",
+					},
+					"embed": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Generate an embedding for each chunk",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "Embedding model for the semantic strategy and for chunk embeddings (optional)",
+					},
+				},
+				"required": []interface{}{"text"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute executes the text chunking
+func (t *ChunkTextTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for chunk_text tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	text, _ := params["text"].(string)
+	if strings.TrimSpace(text) == "" {
+		return Error("text parameter is required and cannot be empty for chunk_text tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter":   "text",
+			"text_length": len(text),
+		}), nil
+	}
+
+	strategy := ChunkStrategyRecursive
+	if s, ok := params["strategy"].(string); ok && s != "" {
+		strategy = s
+	}
+	opts := ChunkOptions{ChunkSize: 1000, Overlap: 100}
+	if c, ok := params["chunk_size"].(float64); ok {
+		opts.ChunkSize = int(c)
+	}
+	if o, ok := params["overlap"].(float64); ok {
+		opts.Overlap = int(o)
+	}
+	if p, ok := params["breakpoint_percentile"].(float64); ok {
+		opts.BreakpointPercentile = p
+	}
+	if seps, ok := params["separators"].([]interface{}); ok {
+		for i, sep := range seps {
+			s, ok := sep.(string)
+			if !ok {
+				return Error(fmt.Sprintf("separators element at index %d must be a string for chunk_text tool: got %T", i, sep), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "separators",
+					"index":     i,
+				}), nil
+			}
+			opts.Separators = append(opts.Separators, s)
+		}
+	}
+	generateEmbeddings, _ := params["embed"].(bool)
+	model, _ := params["model"].(string)
+	if model == "" {
+		model = "default"
+	}
+
+	embed := func(ctx context.Context, texts []string) ([][]float32, error) {
+		return t.embedTexts(ctx, model, texts)
+	}
+
+	chunks, err := ChunkText(ctx, text, strategy, opts, embed)
+	if err != nil {
+		return Error(fmt.Sprintf("Text chunking failed: strategy='%s', text_length=%d, chunk_size=%d, overlap=%d, error=%v", strategy, len(text), opts.ChunkSize, opts.Overlap, err), "VALIDATION_ERROR", map[string]interface{}{
+			"strategy":   strategy,
+			"chunk_size": opts.ChunkSize,
+			"overlap":    opts.Overlap,
+			"error":      err.Error(),
+		}), nil
+	}
+
+	if generateEmbeddings && len(chunks) > 0 {
+		texts := make([]string, len(chunks))
+		for i, c := range chunks {
+			texts[i] = c.Text
+		}
+		vectors, err := t.embedTexts(ctx, model, texts)
+		if err != nil {
+			t.logger.Error("Chunk embedding failed", err, map[string]interface{}{
+				"chunk_count": len(chunks),
+				"model":       model,
+			})
+			return Error(fmt.Sprintf("Chunk embedding failed: chunk_count=%d, model='%s', error=%v", len(chunks), model, err), "EMBEDDING_ERROR", map[string]interface{}{
+				"chunk_count": len(chunks),
+				"model":       model,
+				"error":       err.Error(),
+			}), nil
+		}
+		for i := range chunks {
+			chunks[i].Embedding = vectors[i]
+		}
+	}
+
+	metadata := map[string]interface{}{
+		"strategy":    strategy,
+		"chunk_count": len(chunks),
+		"chunk_size":  opts.ChunkSize,
+		"overlap":     opts.Overlap,
+		"text_length": len([]rune(text)),
+	}
+	if generateEmbeddings || strategy == ChunkStrategySemantic {
+		metadata["model"] = model
+	}
+	return Success(map[string]interface{}{"chunks": chunks}, metadata), nil
+}
+
+// embedTexts embeds texts in one round trip with neurondb.embed_batch
+func (t *ChunkTextTool) embedTexts(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	return embedBatch(ctx, t.executor, model, texts)
+}
+
+// embedBatch embeds texts with neurondb.embed_batch and parses the vectors
+func embedBatch(ctx context.Context, executor *QueryExecutor, model string, texts []string) ([][]float32, error) {
+	query := "SELECT json_agg(embedding::text) AS embeddings FROM unnest(neurondb.embed_batch($1, $2::text[])) AS embedding"
+	result, err := executor.ExecutePreparedQueryOne(ctx, statementEmbedBatch, query, []interface{}{model, texts}, EmbeddingQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := result["embeddings"].([]interface{})
+	if !ok || len(raw) != len(texts) {
+		return nil, fmt.Errorf("unexpected embed_batch result: expected %d embeddings, got %T with %d entries", len(texts), result["embeddings"], len(raw))
+	}
+	vectors := make([][]float32, len(raw))
+	for i, v := range raw {
+		s, _ := v.(string)
+		vec, err := parseVectorText(s)
+		if err != nil {
+			return nil, fmt.Errorf("embedding %d: %w", i, err)
+		}
+		vectors[i] = vec
+	}
+	return vectors, nil
+}
+
+// parseVectorText parses the text form of a vector, e.g. "[0.1,0.2,0.3]"
+func parseVectorText(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("invalid vector text: %.40q", s)
+	}
+	body := strings.TrimSpace(s[1 : len(s)-1])
+	if body == "" {
+		return []float32{}, nil
+	}
+	parts := strings.Split(body, ",")
+	vec := make([]float32, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector element at index %d: %w", i, err)
+		}
+		vec[i] = float32(v)
+	}
+	return vec, nil
+}
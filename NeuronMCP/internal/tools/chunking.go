@@ -0,0 +1,341 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Chunking strategies supported by chunk_text
+const (
+	ChunkStrategyFixed     = "fixed"
+	ChunkStrategySentence  = "sentence"
+	ChunkStrategyRecursive = "recursive"
+	ChunkStrategySemantic  = "semantic"
+)
+
+// DefaultRecursiveSeparators are tried in order by the recursive strategy,
+// from paragraph breaks down to single characters
+var DefaultRecursiveSeparators = []string{"\n\n", "\n", ". ", " ", ""}
+
+// TextChunk is a piece of a document. Start and End are character (rune)
+// offsets into the original text, End exclusive.
+type TextChunk struct {
+	Index     int       `json:"index"`
+	Text      string    `json:"text"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// ChunkOptions configures a chunking run. Sizes are in characters.
+type ChunkOptions struct {
+	ChunkSize  int
+	Overlap    int
+	Separators []string // recursive strategy only
+	// Semantic strategy: consecutive sentences whose embedding similarity
+	// falls below the BreakpointPercentile-th percentile of all adjacent
+	// similarities start a new chunk
+	BreakpointPercentile float64
+}
+
+// EmbedFunc embeds a batch of texts, returning one vector per text
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// span is a half-open rune range of the source text
+type span struct {
+	start, end int
+}
+
+// ChunkText splits text with the given strategy. embed is only used by the
+// semantic strategy and may be nil otherwise.
+func ChunkText(ctx context.Context, text, strategy string, opts ChunkOptions, embed EmbedFunc) ([]TextChunk, error) {
+	if opts.ChunkSize <= 0 {
+		return nil, fmt.Errorf("chunk_size must be greater than 0, got %d", opts.ChunkSize)
+	}
+	if opts.Overlap < 0 || opts.Overlap >= opts.ChunkSize {
+		return nil, fmt.Errorf("overlap must be >= 0 and less than chunk_size (%d), got %d", opts.ChunkSize, opts.Overlap)
+	}
+
+	runes := []rune(text)
+	var spans []span
+	switch strategy {
+	case ChunkStrategyFixed:
+		spans = fixedSpans(span{0, len(runes)}, opts.ChunkSize, opts.Overlap)
+	case ChunkStrategySentence:
+		spans = packSpans(splitSentences(runes), opts.ChunkSize, opts.Overlap)
+	case ChunkStrategyRecursive:
+		separators := opts.Separators
+		if len(separators) == 0 {
+			separators = DefaultRecursiveSeparators
+		}
+		pieces := recursiveSplit(runes, span{0, len(runes)}, separators, opts.ChunkSize)
+		spans = packSpans(pieces, opts.ChunkSize, opts.Overlap)
+	case ChunkStrategySemantic:
+		if embed == nil {
+			return nil, fmt.Errorf("semantic chunking requires an embedding function")
+		}
+		var err error
+		spans, err = semanticSpans(ctx, runes, opts, embed)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy '%s' (supported: %s, %s, %s, %s)", strategy,
+			ChunkStrategyFixed, ChunkStrategySentence, ChunkStrategyRecursive, ChunkStrategySemantic)
+	}
+
+	chunks := make([]TextChunk, 0, len(spans))
+	for _, s := range spans {
+		s = trimSpan(runes, s)
+		if s.end <= s.start {
+			continue
+		}
+		chunks = append(chunks, TextChunk{
+			Index: len(chunks),
+			Text:  string(runes[s.start:s.end]),
+			Start: s.start,
+			End:   s.end,
+		})
+	}
+	return chunks, nil
+}
+
+// fixedSpans cuts s into windows of size characters advancing by size-overlap
+func fixedSpans(s span, size, overlap int) []span {
+	var spans []span
+	step := size - overlap
+	for start := s.start; start < s.end; start += step {
+		end := start + size
+		if end > s.end {
+			end = s.end
+		}
+		spans = append(spans, span{start, end})
+		if end == s.end {
+			break
+		}
+	}
+	return spans
+}
+
+// splitSentences splits text after sentence-ending punctuation followed by
+// whitespace and at blank lines. Trailing whitespace stays with the sentence
+// so the spans cover the whole text.
+func splitSentences(runes []rune) []span {
+	var spans []span
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		boundary := false
+		switch {
+		case r == '.' || r == '!' || r == '?':
+			boundary = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		case r == '\n':
+			boundary = i+1 < len(runes) && runes[i+1] == '\n'
+		}
+		if !boundary {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		spans = append(spans, span{start, end})
+		start = end
+		i = end - 1
+	}
+	if start < len(runes) {
+		spans = append(spans, span{start, len(runes)})
+	}
+	return spans
+}
+
+// recursiveSplit splits s on the first separator that occurs in it and
+// recurses with the remaining separators into pieces still larger than size
+func recursiveSplit(runes []rune, s span, separators []string, size int) []span {
+	if s.end-s.start <= size {
+		return []span{s}
+	}
+	if len(separators) == 0 {
+		return fixedSpans(s, size, 0)
+	}
+
+	sep := []rune(separators[0])
+	rest := separators[1:]
+	if len(sep) == 0 {
+		return fixedSpans(s, size, 0)
+	}
+
+	var pieces []span
+	start := s.start
+	for i := s.start; i+len(sep) <= s.end; i++ {
+		if !hasRunePrefix(runes[i:], sep) {
+			continue
+		}
+		// Keep the separator with the preceding piece
+		pieces = append(pieces, span{start, i + len(sep)})
+		start = i + len(sep)
+		i = start - 1
+	}
+	if start < s.end {
+		pieces = append(pieces, span{start, s.end})
+	}
+	if len(pieces) == 1 {
+		return recursiveSplit(runes, s, rest, size)
+	}
+
+	var out []span
+	for _, p := range pieces {
+		if p.end-p.start > size {
+			out = append(out, recursiveSplit(runes, p, rest, size)...)
+		} else {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// packSpans merges adjacent pieces into chunks of at most size characters.
+// Each new chunk starts with as many trailing pieces of the previous chunk as
+// fit in overlap. Pieces larger than size are cut with fixedSpans.
+func packSpans(pieces []span, size, overlap int) []span {
+	var chunks []span
+	var current []span
+
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		chunks = append(chunks, span{current[0].start, current[len(current)-1].end})
+		// Carry trailing pieces into the next chunk as overlap
+		keep := len(current)
+		for keep > 0 && current[len(current)-1].end-current[keep-1].start <= overlap {
+			keep--
+		}
+		current = append([]span(nil), current[keep:]...)
+	}
+
+	for _, p := range pieces {
+		if p.end-p.start > size {
+			flush()
+			current = nil
+			chunks = append(chunks, fixedSpans(p, size, overlap)...)
+			continue
+		}
+		if len(current) > 0 && p.end-current[0].start > size {
+			flush()
+			// Drop overlap pieces that would not leave room for p
+			for len(current) > 0 && p.end-current[0].start > size {
+				current = current[1:]
+			}
+		}
+		current = append(current, p)
+	}
+	if len(current) > 0 {
+		// Skip a final chunk made only of overlap already emitted
+		last := span{current[0].start, current[len(current)-1].end}
+		if len(chunks) == 0 || last.end > chunks[len(chunks)-1].end {
+			chunks = append(chunks, last)
+		}
+	}
+	return chunks
+}
+
+// semanticSpans groups sentences into chunks, breaking between sentences
+// whose embeddings are least similar and whenever size would be exceeded
+func semanticSpans(ctx context.Context, runes []rune, opts ChunkOptions, embed EmbedFunc) ([]span, error) {
+	sentences := splitSentences(runes)
+	if len(sentences) <= 1 {
+		return packSpans(sentences, opts.ChunkSize, 0), nil
+	}
+
+	texts := make([]string, len(sentences))
+	for i, s := range sentences {
+		texts[i] = strings.TrimSpace(string(runes[s.start:s.end]))
+	}
+	vectors, err := embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed %d sentences for semantic chunking: %w", len(texts), err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedding returned %d vectors for %d sentences", len(vectors), len(texts))
+	}
+
+	similarities := make([]float64, len(sentences)-1)
+	for i := range similarities {
+		similarities[i] = cosineSimilarity(vectors[i], vectors[i+1])
+	}
+	percentile := opts.BreakpointPercentile
+	if percentile <= 0 || percentile >= 100 {
+		percentile = 25
+	}
+	threshold := percentileOf(similarities, percentile)
+
+	var spans []span
+	current := sentences[0]
+	for i := 1; i < len(sentences); i++ {
+		next := sentences[i]
+		if similarities[i-1] <= threshold || next.end-current.start > opts.ChunkSize {
+			spans = append(spans, packSpans([]span{current}, opts.ChunkSize, 0)...)
+			current = next
+			continue
+		}
+		current.end = next.end
+	}
+	spans = append(spans, packSpans([]span{current}, opts.ChunkSize, 0)...)
+	return spans, nil
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentileOf returns the p-th percentile (0-100) of values using
+// nearest-rank on a sorted copy
+func percentileOf(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// trimSpan shrinks s to exclude leading and trailing whitespace
+func trimSpan(runes []rune, s span) span {
+	for s.start < s.end && unicode.IsSpace(runes[s.start]) {
+		s.start++
+	}
+	for s.end > s.start && unicode.IsSpace(runes[s.end-1]) {
+		s.end--
+	}
+	return s
+}
+
+func hasRunePrefix(runes, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if runes[i] != r {
+			return false
+		}
+	}
+	return true
+}
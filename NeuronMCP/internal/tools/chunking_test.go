@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// checkChunks verifies that every chunk fits the size limit and that its
+// offsets point back at its text
+func checkChunks(t *testing.T, text string, chunks []TextChunk, size int) {
+	t.Helper()
+	runes := []rune(text)
+	if len(chunks) == 0 {
+		t.Fatal("no chunks returned")
+	}
+	for i, c := range chunks {
+		if c.Index != i {
+			t.Errorf("chunk %d has index %d", i, c.Index)
+		}
+		if n := len([]rune(c.Text)); n > size {
+			t.Errorf("chunk %d has %d characters, limit %d", i, n, size)
+		}
+		if got := string(runes[c.Start:c.End]); got != c.Text {
+			t.Errorf("chunk %d offsets [%d,%d) give %q, want %q", i, c.Start, c.End, got, c.Text)
+		}
+	}
+}
+
+func TestChunkTextFixed(t *testing.T) {
+	text := strings.Repeat("abcdefghij", 5)
+	chunks, err := ChunkText(context.Background(), text, ChunkStrategyFixed, ChunkOptions{ChunkSize: 20, Overlap: 5}, nil)
+	if err != nil {
+		t.Fatalf("ChunkText() error: %v", err)
+	}
+	checkChunks(t, text, chunks, 20)
+	if len(chunks) != 3 || chunks[1].Start != 15 || chunks[2].End != 50 {
+		t.Errorf("unexpected fixed chunks: %+v", chunks)
+	}
+}
+
+func TestChunkTextSentence(t *testing.T) {
+	text := "First sentence here. Second one is here! Is this the third? Yes it is."
+	chunks, err := ChunkText(context.Background(), text, ChunkStrategySentence, ChunkOptions{ChunkSize: 45, Overlap: 0}, nil)
+	if err != nil {
+		t.Fatalf("ChunkText() error: %v", err)
+	}
+	checkChunks(t, text, chunks, 45)
+	for _, c := range chunks {
+		last := c.Text[len(c.Text)-1]
+		if last != '.' && last != '!' && last != '?' {
+			t.Errorf("sentence chunk %q does not end at a sentence boundary", c.Text)
+		}
+	}
+}
+
+func TestChunkTextRecursive(t *testing.T) {
+	text := "Paragraph one talks about vectors.\n\nParagraph two is about indexes and is a little longer than the first.\n\nThree."
+	chunks, err := ChunkText(context.Background(), text, ChunkStrategyRecursive, ChunkOptions{ChunkSize: 40, Overlap: 10}, nil)
+	if err != nil {
+		t.Fatalf("ChunkText() error: %v", err)
+	}
+	checkChunks(t, text, chunks, 40)
+	if chunks[0].Text != "Paragraph one talks about vectors." {
+		t.Errorf("first chunk = %q, want the first paragraph", chunks[0].Text)
+	}
+}
+
+func TestChunkTextSemantic(t *testing.T) {
+	text := "Cats purr. Cats meow. Stocks fell today. Markets were volatile."
+	// Sentences about the same topic get identical vectors
+	embed := func(ctx context.Context, texts []string) ([][]float32, error) {
+		vectors := make([][]float32, len(texts))
+		for i, s := range texts {
+			if strings.HasPrefix(s, "Cats") {
+				vectors[i] = []float32{1, 0}
+			} else {
+				vectors[i] = []float32{0, 1}
+			}
+		}
+		return vectors, nil
+	}
+	chunks, err := ChunkText(context.Background(), text, ChunkStrategySemantic, ChunkOptions{ChunkSize: 200, BreakpointPercentile: 30}, embed)
+	if err != nil {
+		t.Fatalf("ChunkText() error: %v", err)
+	}
+	checkChunks(t, text, chunks, 200)
+	if len(chunks) != 2 || chunks[0].Text != "Cats purr. Cats meow." {
+		t.Errorf("unexpected semantic chunks: %+v", chunks)
+	}
+}
+
+func TestChunkTextRejectsBadOptions(t *testing.T) {
+	ctx := context.Background()
+	if _, err := ChunkText(ctx, "text", ChunkStrategyFixed, ChunkOptions{ChunkSize: 10, Overlap: 10}, nil); err == nil {
+		t.Error("overlap equal to chunk_size accepted")
+	}
+	if _, err := ChunkText(ctx, "text", "paragraphs", ChunkOptions{ChunkSize: 10}, nil); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+	if _, err := ChunkText(ctx, "a. b.", ChunkStrategySemantic, ChunkOptions{ChunkSize: 10}, nil); err == nil {
+		t.Error("semantic strategy without embedder accepted")
+	}
+}
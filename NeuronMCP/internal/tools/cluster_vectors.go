@@ -0,0 +1,717 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Clustering limits
+const (
+	// ClusterVectorsTimeout bounds a whole cluster_vectors call
+	ClusterVectorsTimeout = 15 * time.Minute
+	// maxVectorFunctionRows is the most vectors the NeuronDB clustering and
+	// outlier functions read from a table
+	maxVectorFunctionRows   = 500000
+	defaultClusterK         = 8
+	maxClusterK             = 1000
+	defaultClusterExemplars = 3
+	maxClusterExemplars     = 50
+	// clusterNoise is the cluster of rows no cluster took, such as DBSCAN
+	// noise and the members of clusters below min_cluster_size
+	clusterNoise = -1
+)
+
+// Temporary relations cluster_vectors clusters from. The NeuronDB
+// clustering functions read "SELECT <column> FROM <table>" and return one
+// assignment per row read, so the rows are copied with their position and
+// served to the function in that order through a view (vectorInputQuery).
+const (
+	clusterInputTable       = "neurondb_cluster_input"
+	clusterInputView        = "neurondb_cluster_vectors"
+	clusterAssignmentsTable = "neurondb_cluster_assignments"
+)
+
+// clusterAlgorithms are the algorithms cluster_vectors runs, by the NeuronDB
+// function that runs them
+var clusterAlgorithms = map[string]string{
+	"kmeans":           "cluster_kmeans",
+	"minibatch_kmeans": "cluster_minibatch_kmeans",
+	"gmm":              "cluster_gmm",
+	"hierarchical":     "cluster_hierarchical",
+	"dbscan":           "cluster_dbscan",
+}
+
+// clusterRequest is a validated cluster_vectors call
+type clusterRequest struct {
+	table          pgx.Identifier
+	tableName      string
+	vectorColumn   string
+	idColumn       string
+	algorithm      string
+	k              int
+	maxIter        int
+	batchSize      int
+	linkage        string
+	eps            float64
+	minSamples     int
+	minClusterSize int
+	exemplars      int
+	columns        []string
+	centroids      bool
+	writeTo        string
+	outputColumn   string
+	output         pgx.Identifier
+	outputName     string
+	overwrite      bool
+}
+
+// ClusterVectorsTool clusters the vectors of a table and describes the
+// clusters for labeling
+type ClusterVectorsTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewClusterVectorsTool creates a new vector clustering tool
+func NewClusterVectorsTool(db *database.Database, logger *logging.Logger) *ClusterVectorsTool {
+	return &ClusterVectorsTool{
+		BaseTool: NewBaseTool(
+			"cluster_vectors",
+			"Cluster the vectors of a table with k-means, mini-batch k-means, GMM, hierarchical clustering or DBSCAN; returns each cluster's size, centroid and the rows nearest its centroid for labeling, and optionally writes the assignments to a column or a table",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the vector column; rows where it is NULL are not clustered",
+					},
+					"id_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column identifying rows, used to write assignments back and to name exemplars",
+					},
+					"algorithm": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"kmeans", "minibatch_kmeans", "gmm", "hierarchical", "dbscan"},
+						"default":     "kmeans",
+						"description": "Clustering algorithm. dbscan finds the number of clusters itself and leaves outliers unclustered",
+					},
+					"k": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultClusterK,
+						"minimum":     2,
+						"maximum":     maxClusterK,
+						"description": "Number of clusters (kmeans, minibatch_kmeans, gmm, hierarchical)",
+					},
+					"max_iter": map[string]interface{}{
+						"type":        "integer",
+						"default":     100,
+						"minimum":     1,
+						"maximum":     10000,
+						"description": "Maximum iterations (kmeans, minibatch_kmeans, gmm)",
+					},
+					"batch_size": map[string]interface{}{
+						"type":        "integer",
+						"default":     100,
+						"minimum":     1,
+						"maximum":     100000,
+						"description": "Vectors per mini-batch (minibatch_kmeans)",
+					},
+					"linkage": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"average", "complete", "single"},
+						"default":     "average",
+						"description": "Distance between clusters (hierarchical)",
+					},
+					"eps": map[string]interface{}{
+						"type":        "number",
+						"default":     0.5,
+						"description": "Neighbourhood radius (dbscan)",
+					},
+					"min_samples": map[string]interface{}{
+						"type":        "integer",
+						"default":     5,
+						"minimum":     1,
+						"maximum":     10000,
+						"description": "Neighbours within eps that make a point a core point (dbscan)",
+					},
+					"min_cluster_size": map[string]interface{}{
+						"type":        "integer",
+						"default":     1,
+						"minimum":     1,
+						"description": "Clusters with fewer members are dissolved and their rows assigned -1, like noise",
+					},
+					"exemplars": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultClusterExemplars,
+						"minimum":     0,
+						"maximum":     maxClusterExemplars,
+						"description": "Rows nearest each centroid returned per cluster",
+					},
+					"exemplar_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Columns returned for each exemplar, such as a title or text column",
+					},
+					"include_centroids": map[string]interface{}{
+						"type":        "boolean",
+						"default":     true,
+						"description": "Return each cluster's centroid vector",
+					},
+					"write_to": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"none", "column", "table"},
+						"default":     "none",
+						"description": "none only returns the clusters; column writes each row's cluster to output_column of the table; table creates output_table of id_column and output_column",
+					},
+					"output_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "cluster_id",
+						"description": "Integer column the cluster of each row is written to (column, table)",
+					},
+					"output_table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table created with the assignments (table), optionally schema-qualified",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace an existing output_column or output_table",
+					},
+				},
+				"required": []interface{}{"table", "vector_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute clusters the table
+func (t *ClusterVectorsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for cluster_vectors tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	req, errResult := parseClusterRequest(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for cluster_vectors", "DATABASE_ERROR", map[string]interface{}{
+			"table": req.tableName,
+		}), nil
+	}
+
+	clusterCtx, cancel := context.WithTimeout(ctx, ClusterVectorsTimeout)
+	defer cancel()
+	start := time.Now()
+
+	// Everything runs in one transaction. Without write_to it is rolled
+	// back, leaving nothing behind.
+	tx, err := db.Begin(clusterCtx)
+	if err != nil {
+		return t.clusterError(req, "transaction", err), nil
+	}
+	defer tx.Rollback(clusterCtx)
+
+	tag, err := tx.Exec(clusterCtx, vectorInputQuery(clusterInputTable, req.table, req.idColumn, req.vectorColumn))
+	if err != nil {
+		return t.clusterError(req, "reading vectors", err), nil
+	}
+	rows := int(tag.RowsAffected())
+	minRows := 2
+	if req.algorithm != "dbscan" {
+		minRows = req.k
+	}
+	if rows < minRows {
+		return Error(fmt.Sprintf("Table '%s' has %d rows with a '%s' vector, at least %d are needed", req.tableName, rows, req.vectorColumn, minRows), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"rows":      rows,
+		}), nil
+	}
+	total := rows
+	if rows == maxVectorFunctionRows {
+		query := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s IS NOT NULL", req.table.Sanitize(), pgx.Identifier{req.vectorColumn}.Sanitize())
+		if err := tx.QueryRow(clusterCtx, query).Scan(&total); err != nil {
+			return t.clusterError(req, "counting rows", err), nil
+		}
+	}
+	if _, err := tx.Exec(clusterCtx, vectorInputViewQuery(clusterInputView, clusterInputTable)); err != nil {
+		return t.clusterError(req, "reading vectors", err), nil
+	}
+
+	labels, err := runClustering(clusterCtx, tx, req)
+	if err != nil {
+		return t.clusterError(req, "clustering", err), nil
+	}
+	if len(labels) != rows {
+		return t.clusterError(req, "clustering", fmt.Errorf("%s returned %d assignments for %d rows", clusterAlgorithms[req.algorithm], len(labels), rows)), nil
+	}
+	assignments := normalizeClusterLabels(labels, req.minClusterSize)
+
+	if _, err := tx.Exec(clusterCtx, fmt.Sprintf(
+		"CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT ord, cluster FROM unnest($1::int[]) WITH ORDINALITY AS a(cluster, ord)",
+		clusterAssignmentsTable), assignments); err != nil {
+		return t.clusterError(req, "assignments", err), nil
+	}
+	clusters, err := describeClusters(clusterCtx, tx, req)
+	if err != nil {
+		return t.clusterError(req, "cluster summary", err), nil
+	}
+	noise := 0
+	for _, a := range assignments {
+		if a == clusterNoise {
+			noise++
+		}
+	}
+
+	result := map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"algorithm":     req.algorithm,
+		"rows":          rows,
+		"truncated":     total > rows,
+		"num_clusters":  len(clusters),
+		"noise":         noise,
+		"clusters":      clusters,
+		"write_to":      req.writeTo,
+	}
+	if total > rows {
+		result["note"] = fmt.Sprintf("clustered the first %d of %d rows by %s", rows, total, req.idColumn)
+	}
+
+	if req.writeTo != "none" {
+		if _, err := tx.Exec(clusterCtx, "DROP VIEW "+clusterInputView); err != nil {
+			return t.clusterError(req, "writing assignments", err), nil
+		}
+		written, errResult, err := writeClusterAssignments(clusterCtx, tx, req)
+		if errResult != nil {
+			return errResult, nil
+		}
+		if err != nil {
+			return t.clusterError(req, "writing assignments", err), nil
+		}
+		if err := tx.Commit(clusterCtx); err != nil {
+			return t.clusterError(req, "commit", err), nil
+		}
+		result["rows_written"] = written
+		if req.writeTo == "column" {
+			result["output_column"] = req.outputColumn
+		} else {
+			result["output_table"] = req.outputName
+		}
+		t.logger.Info("Cluster assignments written", map[string]interface{}{
+			"table":     req.tableName,
+			"algorithm": req.algorithm,
+			"write_to":  req.writeTo,
+			"rows":      written,
+		})
+	}
+
+	return Success(result, map[string]interface{}{
+		"tool":       "cluster_vectors",
+		"elapsed_ms": msSince(start),
+	}), nil
+}
+
+// parseClusterRequest validates the cluster_vectors parameters, returning
+// a validation error result when they are unusable
+func parseClusterRequest(params map[string]interface{}) (clusterRequest, *ToolResult) {
+	req := clusterRequest{
+		tableName:    stringParam(params, "table", ""),
+		vectorColumn: stringParam(params, "vector_column", ""),
+		idColumn:     stringParam(params, "id_column", "id"),
+		algorithm:    stringParam(params, "algorithm", "kmeans"),
+		linkage:      stringParam(params, "linkage", "average"),
+		eps:          0.5,
+		centroids:    true,
+		writeTo:      stringParam(params, "write_to", "none"),
+		outputColumn: stringParam(params, "output_column", "cluster_id"),
+		outputName:   stringParam(params, "output_table", ""),
+	}
+	table, err := parseQualifiedIdentifier(req.tableName)
+	if err != nil {
+		return req, Error(fmt.Sprintf("Invalid table '%s': %v", req.tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		})
+	}
+	req.table = table
+	if req.vectorColumn == "" {
+		return req, Error("vector_column parameter is required and cannot be empty for cluster_vectors tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "vector_column",
+		})
+	}
+	if _, ok := clusterAlgorithms[req.algorithm]; !ok {
+		return req, Error(fmt.Sprintf("Unsupported clustering algorithm '%s' for cluster_vectors tool", req.algorithm), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "algorithm",
+		})
+	}
+	switch req.linkage {
+	case "average", "complete", "single":
+	default:
+		return req, Error(fmt.Sprintf("linkage must be average, complete or single, got '%s'", req.linkage), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "linkage",
+		})
+	}
+
+	var errResult *ToolResult
+	for _, p := range []struct {
+		name          string
+		dst           *int
+		def, min, max int
+	}{
+		{"k", &req.k, defaultClusterK, 2, maxClusterK},
+		{"max_iter", &req.maxIter, 100, 1, 10000},
+		{"batch_size", &req.batchSize, 100, 1, 100000},
+		{"min_samples", &req.minSamples, 5, 1, 10000},
+		{"min_cluster_size", &req.minClusterSize, 1, 1, maxVectorFunctionRows},
+		{"exemplars", &req.exemplars, defaultClusterExemplars, 0, maxClusterExemplars},
+	} {
+		if *p.dst, errResult = intParamInRange(params, p.name, p.def, p.min, p.max); errResult != nil {
+			return req, errResult
+		}
+	}
+	if v, ok := params["eps"].(float64); ok {
+		if v <= 0 {
+			return req, Error(fmt.Sprintf("eps must be positive, got %v", v), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "eps",
+			})
+		}
+		req.eps = v
+	}
+	if v, ok := params["include_centroids"].(bool); ok {
+		req.centroids = v
+	}
+	if raw, ok := params["exemplar_columns"].([]interface{}); ok {
+		for _, c := range raw {
+			name, ok := c.(string)
+			if !ok || name == "" {
+				return req, Error("exemplar_columns must be a list of column names", "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "exemplar_columns",
+				})
+			}
+			req.columns = append(req.columns, name)
+		}
+	}
+
+	req.overwrite, _ = params["overwrite"].(bool)
+	switch req.writeTo {
+	case "none", "column":
+	case "table":
+		output, err := parseQualifiedIdentifier(req.outputName)
+		if err != nil {
+			return req, Error(fmt.Sprintf("Invalid output_table '%s': %v", req.outputName, err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "output_table",
+			})
+		}
+		req.output = output
+		if req.outputColumn == req.idColumn {
+			return req, Error(fmt.Sprintf("output_column '%s' cannot be id_column", req.outputColumn), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "output_column",
+			})
+		}
+	default:
+		return req, Error(fmt.Sprintf("write_to must be none, column or table, got '%s'", req.writeTo), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "write_to",
+		})
+	}
+	if req.writeTo == "column" && (req.outputColumn == req.vectorColumn || req.outputColumn == req.idColumn) {
+		return req, Error(fmt.Sprintf("output_column '%s' cannot be the vector or id column", req.outputColumn), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "output_column",
+		})
+	}
+	return req, nil
+}
+
+// vectorInputQuery copies the rows of a table that have a vector into the
+// temporary table name, as row_id and embedding, numbered from 1 by
+// idColumn in ord. A NeuronDB function reading a view of embedding ordered
+// by ord returns its results in that order.
+func vectorInputQuery(name string, table pgx.Identifier, idColumn, vectorColumn string) string {
+	id := pgx.Identifier{idColumn}.Sanitize()
+	vec := pgx.Identifier{vectorColumn}.Sanitize()
+	return fmt.Sprintf(
+		"CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT row_number() OVER (ORDER BY %s) AS ord, %s AS row_id, %s AS embedding FROM %s WHERE %s IS NOT NULL ORDER BY %s LIMIT %d",
+		name, id, id, vec, table.Sanitize(), vec, id, maxVectorFunctionRows)
+}
+
+// vectorInputViewQuery creates the view a NeuronDB function reads the
+// vectors copied by vectorInputQuery from
+func vectorInputViewQuery(view, input string) string {
+	return fmt.Sprintf("CREATE TEMP VIEW %s AS SELECT embedding FROM %s ORDER BY ord", view, input)
+}
+
+// runClustering runs the algorithm over the input view and returns the
+// cluster of each row in order. GMM assigns each row the component it most
+// likely belongs to.
+func runClustering(ctx context.Context, tx pgx.Tx, req clusterRequest) ([]int, error) {
+	var query string
+	args := []interface{}{clusterInputView, "embedding"}
+	switch req.algorithm {
+	case "kmeans", "gmm":
+		query = fmt.Sprintf("SELECT %s($1, $2, $3, $4)", clusterAlgorithms[req.algorithm])
+		args = append(args, req.k, req.maxIter)
+	case "minibatch_kmeans":
+		query = "SELECT cluster_minibatch_kmeans($1, $2, $3, $4, $5)"
+		args = append(args, req.k, req.batchSize, req.maxIter)
+	case "hierarchical":
+		query = "SELECT cluster_hierarchical($1, $2, $3, $4)"
+		args = append(args, req.k, req.linkage)
+	case "dbscan":
+		query = "SELECT cluster_dbscan($1, $2, $3, $4)"
+		args = append(args, req.eps, req.minSamples)
+	}
+
+	if req.algorithm == "gmm" {
+		var probabilities [][]float64
+		if err := tx.QueryRow(ctx, query, args...).Scan(&probabilities); err != nil {
+			return nil, err
+		}
+		labels := make([]int, len(probabilities))
+		for i, row := range probabilities {
+			labels[i] = argmax(row)
+		}
+		return labels, nil
+	}
+	var raw []int32
+	if err := tx.QueryRow(ctx, query, args...).Scan(&raw); err != nil {
+		return nil, err
+	}
+	labels := make([]int, len(raw))
+	for i, l := range raw {
+		labels[i] = int(l)
+	}
+	return labels, nil
+}
+
+// argmax returns the index of the largest value
+func argmax(values []float64) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// normalizeClusterLabels renumbers the clusters from 0 by decreasing size,
+// so cluster 0 is the largest whatever the algorithm numbers from. Negative
+// labels, and the members of clusters smaller than minSize, become -1.
+func normalizeClusterLabels(labels []int, minSize int) []int32 {
+	sizes := map[int]int{}
+	for _, l := range labels {
+		if l >= 0 {
+			sizes[l]++
+		}
+	}
+	kept := make([]int, 0, len(sizes))
+	for l, size := range sizes {
+		if size >= minSize {
+			kept = append(kept, l)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		if sizes[kept[i]] != sizes[kept[j]] {
+			return sizes[kept[i]] > sizes[kept[j]]
+		}
+		return kept[i] < kept[j]
+	})
+	renumber := make(map[int]int32, len(kept))
+	for i, l := range kept {
+		renumber[l] = int32(i)
+	}
+	normalized := make([]int32, len(labels))
+	for i, l := range labels {
+		if n, ok := renumber[l]; ok {
+			normalized[i] = n
+		} else {
+			normalized[i] = clusterNoise
+		}
+	}
+	return normalized
+}
+
+// clusterSummaryQuery computes each cluster's size and centroid and finds
+// the members nearest the centroid. $1 is the number of exemplars and $2
+// the exemplar columns.
+func clusterSummaryQuery(req clusterRequest) string {
+	fields := "NULL::jsonb"
+	join := ""
+	if len(req.columns) > 0 {
+		fields = "(SELECT jsonb_object_agg(f.key, f.value) FROM jsonb_each(r.fields) f WHERE f.key = ANY($2::text[]))"
+		join = fmt.Sprintf("\n\tLEFT JOIN LATERAL (SELECT to_jsonb(t) AS fields FROM %s t WHERE t.%s = e.row_id LIMIT 1) r ON true",
+			req.table.Sanitize(), pgx.Identifier{req.idColumn}.Sanitize())
+	}
+	return fmt.Sprintf(`WITH members AS (
+		SELECT i.row_id, i.embedding, a.cluster
+		FROM %s i JOIN %s a USING (ord)
+		WHERE a.cluster >= 0
+	), centroids AS (
+		SELECT cluster, count(*) AS size, vector_avg(embedding) AS centroid
+		FROM members GROUP BY cluster
+	)
+	SELECT c.cluster, c.size, c.centroid::text, e.row_id::text, e.distance, %s
+	FROM centroids c
+	LEFT JOIN LATERAL (
+		SELECT m.row_id, m.embedding <-> c.centroid AS distance
+		FROM members m WHERE m.cluster = c.cluster
+		ORDER BY distance LIMIT $1
+	) e ON true%s
+	ORDER BY c.cluster, e.distance`, clusterInputTable, clusterAssignmentsTable, fields, join)
+}
+
+// describeClusters returns the size, centroid and exemplars of each
+// cluster, largest first
+func describeClusters(ctx context.Context, tx pgx.Tx, req clusterRequest) ([]map[string]interface{}, error) {
+	args := []interface{}{req.exemplars}
+	if len(req.columns) > 0 {
+		args = append(args, req.columns)
+	}
+	rows, err := tx.Query(ctx, clusterSummaryQuery(req), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clusters []map[string]interface{}
+	var current map[string]interface{}
+	for rows.Next() {
+		var cluster, size int
+		var centroid string
+		var rowID *string
+		var distance *float64
+		var fields map[string]interface{}
+		if err := rows.Scan(&cluster, &size, &centroid, &rowID, &distance, &fields); err != nil {
+			return nil, err
+		}
+		if current == nil || current["cluster"] != cluster {
+			current = map[string]interface{}{
+				"cluster":   cluster,
+				"size":      size,
+				"exemplars": []map[string]interface{}{},
+			}
+			if req.centroids {
+				vec, err := parseVectorText(centroid)
+				if err != nil {
+					return nil, fmt.Errorf("cluster %d centroid: %w", cluster, err)
+				}
+				current["centroid"] = vec
+			}
+			clusters = append(clusters, current)
+		}
+		if rowID != nil {
+			exemplar := map[string]interface{}{
+				req.idColumn: *rowID,
+				"distance":   *distance,
+			}
+			for k, v := range fields {
+				if k != req.idColumn && k != "distance" {
+					exemplar[k] = v
+				}
+			}
+			current["exemplars"] = append(current["exemplars"].([]map[string]interface{}), exemplar)
+		}
+	}
+	return clusters, rows.Err()
+}
+
+// writeClusterAssignments writes each clustered row's cluster to
+// output_column of the table, or creates output_table with them, and
+// returns the number of rows written
+func writeClusterAssignments(ctx context.Context, tx pgx.Tx, req clusterRequest) (int64, *ToolResult, error) {
+	id := pgx.Identifier{req.idColumn}.Sanitize()
+	column := pgx.Identifier{req.outputColumn}.Sanitize()
+	assigned := fmt.Sprintf("SELECT i.row_id, a.cluster AS value FROM %s i JOIN %s a USING (ord)", clusterInputTable, clusterAssignmentsTable)
+
+	if req.writeTo == "table" {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", req.output.Sanitize()).Scan(&exists); err != nil {
+			return 0, nil, err
+		}
+		if exists {
+			if !req.overwrite {
+				return 0, Error(fmt.Sprintf("Output table %s already exists: set overwrite to replace it", req.outputName), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "output_table",
+				}), nil
+			}
+			if _, err := tx.Exec(ctx, "DROP TABLE "+req.output.Sanitize()); err != nil {
+				return 0, nil, fmt.Errorf("failed to drop %s: %w", req.outputName, err)
+			}
+		}
+		tag, err := tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s AS SELECT row_id AS %s, value AS %s FROM (%s) assigned",
+			req.output.Sanitize(), id, column, assigned))
+		if err != nil {
+			return 0, nil, err
+		}
+		return tag.RowsAffected(), nil, nil
+	}
+
+	return writeInputColumn(ctx, tx, req.table, req.tableName, req.idColumn, req.outputColumn, "integer", "output_column", req.overwrite, assigned)
+}
+
+// writeInputColumn stores a value for each row copied by vectorInputQuery
+// in column of the table, adding the column with columnType when it is
+// missing. values selects the row_id and value of the rows. An existing
+// column is only written with overwrite, and then rows without a value are
+// set to NULL. param names the parameter the column came from.
+func writeInputColumn(ctx context.Context, tx pgx.Tx, table pgx.Identifier, tableName, idColumn, column, columnType, param string, overwrite bool, values string, args ...interface{}) (int64, *ToolResult, error) {
+	quoted := pgx.Identifier{column}.Sanitize()
+	var exists bool
+	if err := tx.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_attribute WHERE attrelid = $1::regclass AND attname = $2 AND attnum > 0 AND NOT attisdropped)",
+		table.Sanitize(), column).Scan(&exists); err != nil {
+		return 0, nil, err
+	}
+	if exists {
+		if !overwrite {
+			return 0, Error(fmt.Sprintf("Column '%s' of table '%s' already exists: set overwrite to replace its values", column, tableName), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": param,
+			}), nil
+		}
+		// Rows without a value this time must not keep an earlier one
+		if _, err := tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s IS NOT NULL", table.Sanitize(), quoted, quoted)); err != nil {
+			return 0, nil, err
+		}
+	} else if _, err := tx.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table.Sanitize(), quoted, columnType)); err != nil {
+		return 0, nil, err
+	}
+	tag, err := tx.Exec(ctx, fmt.Sprintf("UPDATE %s t SET %s = v.value FROM (%s) v WHERE t.%s = v.row_id",
+		table.Sanitize(), quoted, values, pgx.Identifier{idColumn}.Sanitize()), args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	return tag.RowsAffected(), nil, nil
+}
+
+func (t *ClusterVectorsTool) clusterError(req clusterRequest, stage string, err error) *ToolResult {
+	t.logger.Error("Vector clustering failed", err, map[string]interface{}{
+		"table":     req.tableName,
+		"algorithm": req.algorithm,
+		"stage":     stage,
+	})
+	return Error(fmt.Sprintf("Clustering failed during %s: table='%s', vector_column='%s', algorithm='%s', error=%v", stage, req.tableName, req.vectorColumn, req.algorithm, err), "CLUSTERING_ERROR", map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"algorithm":     req.algorithm,
+		"stage":         stage,
+		"error":         err.Error(),
+	})
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeClusterLabels(t *testing.T) {
+	// k-means numbers from 1; cluster 3 is the largest, 1 the smallest
+	got := normalizeClusterLabels([]int{1, 3, 3, 2, 3, 2}, 1)
+	want := []int32{2, 0, 0, 1, 0, 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeClusterLabels = %v, want %v", got, want)
+	}
+
+	// DBSCAN noise stays -1, and clusters under min_cluster_size join it
+	got = normalizeClusterLabels([]int{0, 0, -1, 1, 2, 2, 2}, 2)
+	want = []int32{1, 1, -1, -1, 0, 0, 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeClusterLabels with min size = %v, want %v", got, want)
+	}
+}
+
+func TestArgmax(t *testing.T) {
+	if got := argmax([]float64{0.1, 0.7, 0.2}); got != 1 {
+		t.Errorf("argmax = %d, want 1", got)
+	}
+	if got := argmax([]float64{0.5, 0.5}); got != 0 {
+		t.Errorf("argmax of a tie = %d, want the first", got)
+	}
+}
+
+func TestParseClusterRequest(t *testing.T) {
+	req, errResult := parseClusterRequest(map[string]interface{}{
+		"table":            "public.docs",
+		"vector_column":    "embedding",
+		"algorithm":        "dbscan",
+		"eps":              0.3,
+		"exemplar_columns": []interface{}{"title"},
+		"write_to":         "table",
+		"output_table":     "doc_clusters",
+	})
+	if errResult != nil {
+		t.Fatalf("unexpected error: %v", errResult.Error)
+	}
+	if req.k != defaultClusterK || req.eps != 0.3 || req.minSamples != 5 || !req.centroids {
+		t.Errorf("request = %+v", req)
+	}
+	if req.output.Sanitize() != `"doc_clusters"` || !reflect.DeepEqual(req.columns, []string{"title"}) {
+		t.Errorf("output = %v, columns = %v", req.output, req.columns)
+	}
+
+	base := func(extra map[string]interface{}) map[string]interface{} {
+		params := map[string]interface{}{"table": "docs", "vector_column": "embedding"}
+		for k, v := range extra {
+			params[k] = v
+		}
+		return params
+	}
+	for name, params := range map[string]map[string]interface{}{
+		"unknown algorithm":         base(map[string]interface{}{"algorithm": "hdbscan"}),
+		"k of one":                  base(map[string]interface{}{"k": float64(1)}),
+		"negative eps":              base(map[string]interface{}{"eps": -1.0}),
+		"table without output":      base(map[string]interface{}{"write_to": "table"}),
+		"column over vector column": base(map[string]interface{}{"write_to": "column", "output_column": "embedding"}),
+		"unknown linkage":           base(map[string]interface{}{"linkage": "ward"}),
+	} {
+		if _, errResult := parseClusterRequest(params); errResult == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestClusterSummaryQuery(t *testing.T) {
+	req, _ := parseClusterRequest(map[string]interface{}{"table": "public.docs", "vector_column": "embedding"})
+	query := clusterSummaryQuery(req)
+	if strings.Contains(query, "$2") || strings.Contains(query, `"public"."docs"`) {
+		t.Errorf("without exemplar_columns the table should not be read again:\n%s", query)
+	}
+
+	req.columns = []string{"title"}
+	query = clusterSummaryQuery(req)
+	for _, want := range []string{`FROM "public"."docs" t WHERE t."id" = e.row_id`, "f.key = ANY($2::text[])", "vector_avg(embedding)"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("summary query missing %q:\n%s", want, query)
+		}
+	}
+}
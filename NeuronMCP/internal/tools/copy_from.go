@@ -0,0 +1,527 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// copy_from limits
+const (
+	// copyFromMaxChunkBytes caps the decoded size of one appended chunk
+	copyFromMaxChunkBytes = 8 << 20
+	// copyFromMaxUploadBytes caps the data spooled for one upload
+	copyFromMaxUploadBytes = 1 << 30
+	// copyFromMaxUploads caps the uploads open at once
+	copyFromMaxUploads = 16
+	// copyFromUploadTTL is how long an upload stays open without a call
+	copyFromUploadTTL = 30 * time.Minute
+	// copyFromCommitTimeout bounds the COPY of a committed upload
+	copyFromCommitTimeout = 30 * time.Minute
+)
+
+// copyUpload is data spooled to a temporary file by begin and append calls
+// until commit streams it into its table
+type copyUpload struct {
+	id        string
+	db        *database.Database
+	table     pgx.Identifier
+	tableName string
+	columns   []string
+	format    string
+	header    bool
+	delimiter string
+	null      string
+	truncate  bool
+
+	mu       sync.Mutex
+	file     *os.File
+	hash     hash.Hash
+	bytes    int64
+	chunks   int
+	lastUsed time.Time
+	closed   bool
+}
+
+// write appends chunk number index to the upload and returns the chunks and
+// bytes received so far. Resending the last chunk is acknowledged without
+// writing it again, so a client can retry an append whose response it lost.
+func (u *copyUpload) write(index int, data []byte, now time.Time) (chunks int, bytes int64, duplicate bool, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return u.chunks, u.bytes, false, fmt.Errorf("upload %s was committed or aborted", u.id)
+	}
+	u.lastUsed = now
+	if index == u.chunks-1 {
+		return u.chunks, u.bytes, true, nil
+	}
+	if index != u.chunks {
+		return u.chunks, u.bytes, false, fmt.Errorf("expected chunk %d, got %d", u.chunks, index)
+	}
+	if u.bytes+int64(len(data)) > copyFromMaxUploadBytes {
+		return u.chunks, u.bytes, false, fmt.Errorf("upload would exceed %d bytes", copyFromMaxUploadBytes)
+	}
+	if _, err := u.file.Write(data); err != nil {
+		return u.chunks, u.bytes, false, fmt.Errorf("failed to spool chunk %d: %w", index, err)
+	}
+	u.hash.Write(data)
+	u.bytes += int64(len(data))
+	u.chunks++
+	return u.chunks, u.bytes, false, nil
+}
+
+// close marks the upload finished and removes its spool file. It is safe
+// to call more than once.
+func (u *copyUpload) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return
+	}
+	u.closed = true
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// statement returns the COPY statement loading the upload
+func (u *copyUpload) statement() string {
+	var b strings.Builder
+	b.WriteString("COPY ")
+	b.WriteString(u.table.Sanitize())
+	if len(u.columns) > 0 {
+		quoted := make([]string, len(u.columns))
+		for i, c := range u.columns {
+			quoted[i] = pgx.Identifier{c}.Sanitize()
+		}
+		b.WriteString(" (" + strings.Join(quoted, ", ") + ")")
+	}
+	b.WriteString(" FROM STDIN WITH (FORMAT " + u.format)
+	if u.format == "csv" {
+		fmt.Fprintf(&b, ", HEADER %t", u.header)
+	}
+	if u.delimiter != "" {
+		b.WriteString(", DELIMITER " + quoteLiteral(u.delimiter))
+	}
+	if u.null != "" {
+		b.WriteString(", NULL " + quoteLiteral(u.null))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// copyUploads holds the open uploads of a copy_from tool
+type copyUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*copyUpload
+}
+
+// add registers a new upload, first dropping uploads idle for longer than
+// copyFromUploadTTL
+func (s *copyUploads) add(u *copyUpload, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(now)
+	if len(s.uploads) >= copyFromMaxUploads {
+		return fmt.Errorf("%d uploads are already open: commit or abort one first", len(s.uploads))
+	}
+	if s.uploads == nil {
+		s.uploads = make(map[string]*copyUpload)
+	}
+	s.uploads[u.id] = u
+	return nil
+}
+
+// get returns an open upload
+func (s *copyUploads) get(id string, now time.Time) (*copyUpload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(now)
+	u, ok := s.uploads[id]
+	return u, ok
+}
+
+// remove takes an upload out of the open uploads; the caller closes it
+func (s *copyUploads) remove(id string, now time.Time) (*copyUpload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(now)
+	u, ok := s.uploads[id]
+	delete(s.uploads, id)
+	return u, ok
+}
+
+func (s *copyUploads) expireLocked(now time.Time) {
+	for id, u := range s.uploads {
+		u.mu.Lock()
+		idle := now.Sub(u.lastUsed)
+		u.mu.Unlock()
+		if idle > copyFromUploadTTL {
+			delete(s.uploads, id)
+			u.close()
+		}
+	}
+}
+
+// CopyFromTool loads large data into a table over several calls: begin
+// opens an upload, append adds base64 chunks to it and commit streams the
+// spooled data into the table with COPY
+type CopyFromTool struct {
+	*BaseTool
+	db      *database.Database
+	logger  *logging.Logger
+	uploads copyUploads
+}
+
+// NewCopyFromTool creates a new chunked COPY tool
+func NewCopyFromTool(db *database.Database, logger *logging.Logger) *CopyFromTool {
+	return &CopyFromTool{
+		BaseTool: NewBaseTool(
+			"copy_from",
+			"Import data larger than one message into an existing table with COPY. Call begin with the table and format to get an upload_id, append the data as base64 chunks numbered from 0 (up to 8 MiB decoded each; resending the last chunk is safe), then commit to stream it into the table in one transaction, or abort to discard it. Uploads are held on the server for 30 minutes after their last call.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"begin", "append", "commit", "abort"},
+						"description": "Step of the upload",
+					},
+					"upload_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Upload returned by begin (append, commit and abort)",
+					},
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Target table, optionally schema-qualified (begin)",
+					},
+					"columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Columns the data fills, in order; all columns of the table when omitted (begin)",
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"csv", "text"},
+						"default":     "csv",
+						"description": "COPY format of the data (begin)",
+					},
+					"header": map[string]interface{}{
+						"type":        "boolean",
+						"default":     true,
+						"description": "The first CSV line is a header and is skipped (begin)",
+					},
+					"delimiter": map[string]interface{}{
+						"type":        "string",
+						"description": "Single-character field delimiter; comma for csv and tab for text when omitted (begin)",
+					},
+					"null": map[string]interface{}{
+						"type":        "string",
+						"description": "String standing for NULL; an unquoted empty field for csv and \\N for text when omitted (begin)",
+					},
+					"truncate": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Empty the table in the commit transaction before copying, replacing its rows (begin)",
+					},
+					"chunk": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     0,
+						"description": "Number of the chunk, starting at 0 (append)",
+					},
+					"data": map[string]interface{}{
+						"type":        "string",
+						"description": "Base64 of the chunk; chunks may split lines anywhere (append)",
+					},
+					"sha256": map[string]interface{}{
+						"type":        "string",
+						"description": "Hex SHA-256 of the whole data; commit fails without copying when it differs (commit)",
+					},
+				},
+				"required": []interface{}{"action"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute runs one step of an upload
+func (t *CopyFromTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for copy_from tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+
+	action, _ := params["action"].(string)
+	if action == "begin" {
+		return t.begin(ctx, params), nil
+	}
+	id, _ := params["upload_id"].(string)
+	if id == "" {
+		return Error(fmt.Sprintf("upload_id is required for %s", action), "VALIDATION_ERROR", map[string]interface{}{"parameter": "upload_id"}), nil
+	}
+	switch action {
+	case "append":
+		return t.append(id, params), nil
+	case "commit":
+		return t.commit(ctx, id, params), nil
+	case "abort":
+		u, ok := t.uploads.remove(id, time.Now())
+		if !ok {
+			return uploadNotFound(id), nil
+		}
+		u.close()
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return Success(map[string]interface{}{
+			"upload_id": id,
+			"aborted":   true,
+			"bytes":     u.bytes,
+			"chunks":    u.chunks,
+		}, nil), nil
+	}
+	return Error(fmt.Sprintf("action must be begin, append, commit or abort, got '%s'", action), "VALIDATION_ERROR", map[string]interface{}{"parameter": "action"}), nil
+}
+
+// begin opens an upload for an existing table
+func (t *CopyFromTool) begin(ctx context.Context, params map[string]interface{}) *ToolResult {
+	tableName, _ := params["table"].(string)
+	if tableName == "" {
+		return Error("table is required for begin", "VALIDATION_ERROR", map[string]interface{}{"parameter": "table"})
+	}
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{"parameter": "table"})
+	}
+	u := &copyUpload{
+		table:     table,
+		tableName: tableName,
+		format:    stringParam(params, "format", "csv"),
+		header:    true,
+		delimiter: stringParam(params, "delimiter", ""),
+		null:      stringParam(params, "null", ""),
+	}
+	if header, ok := params["header"].(bool); ok {
+		u.header = header
+	}
+	u.truncate, _ = params["truncate"].(bool)
+	if u.format != "csv" && u.format != "text" {
+		return Error(fmt.Sprintf("format must be csv or text, got '%s'", u.format), "VALIDATION_ERROR", map[string]interface{}{"parameter": "format"})
+	}
+	if u.delimiter != "" && len([]rune(u.delimiter)) != 1 {
+		return Error("delimiter must be a single character", "VALIDATION_ERROR", map[string]interface{}{"parameter": "delimiter"})
+	}
+	if strings.ContainsAny(u.null, "\r\n") {
+		return Error("null must not contain a line break", "VALIDATION_ERROR", map[string]interface{}{"parameter": "null"})
+	}
+	if raw, ok := params["columns"].([]interface{}); ok {
+		seen := map[string]bool{}
+		for _, item := range raw {
+			column, _ := item.(string)
+			if strings.TrimSpace(column) == "" {
+				return Error("columns must be non-empty strings", "VALIDATION_ERROR", map[string]interface{}{"parameter": "columns"})
+			}
+			if seen[column] {
+				return Error(fmt.Sprintf("column '%s' is listed twice", column), "VALIDATION_ERROR", map[string]interface{}{"parameter": "columns"})
+			}
+			seen[column] = true
+			u.columns = append(u.columns, column)
+		}
+	}
+
+	u.db = DatabaseFromContext(ctx, t.db)
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	var exists bool
+	if err := u.db.QueryRow(queryCtx, "SELECT to_regclass($1) IS NOT NULL", table.Sanitize()).Scan(&exists); err != nil {
+		return t.copyError(tableName, "begin", err)
+	}
+	if !exists {
+		return Error(fmt.Sprintf("Table %s does not exist", tableName), "VALIDATION_ERROR", map[string]interface{}{"parameter": "table"})
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return t.copyError(tableName, "begin", err)
+	}
+	u.id = hex.EncodeToString(id)
+	u.file, err = os.CreateTemp("", "neurondb-mcp-copy-*")
+	if err != nil {
+		return t.copyError(tableName, "begin", fmt.Errorf("failed to create spool file: %w", err))
+	}
+	u.hash = sha256.New()
+	u.lastUsed = time.Now()
+	if err := t.uploads.add(u, u.lastUsed); err != nil {
+		u.close()
+		return Error(err.Error(), "COPY_LIMIT", nil)
+	}
+
+	return Success(map[string]interface{}{
+		"upload_id":             u.id,
+		"table":                 tableName,
+		"statement":             u.statement(),
+		"max_chunk_bytes":       copyFromMaxChunkBytes,
+		"max_upload_bytes":      copyFromMaxUploadBytes,
+		"expires_after_seconds": int(copyFromUploadTTL.Seconds()),
+	}, nil)
+}
+
+// append spools one chunk of an upload
+func (t *CopyFromTool) append(id string, params map[string]interface{}) *ToolResult {
+	index, errResult := intParamInRange(params, "chunk", -1, 0, copyFromMaxUploadBytes)
+	if errResult != nil {
+		return errResult
+	}
+	if index < 0 {
+		return Error("chunk is required for append", "VALIDATION_ERROR", map[string]interface{}{"parameter": "chunk"})
+	}
+	encoded, _ := params["data"].(string)
+	if encoded == "" {
+		return Error("data is required for append", "VALIDATION_ERROR", map[string]interface{}{"parameter": "data"})
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > copyFromMaxChunkBytes+2 {
+		return Error(fmt.Sprintf("chunk exceeds %d bytes: split it", copyFromMaxChunkBytes), "VALIDATION_ERROR", map[string]interface{}{"parameter": "data"})
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Error(fmt.Sprintf("data is not valid base64: %v", err), "VALIDATION_ERROR", map[string]interface{}{"parameter": "data"})
+	}
+	if len(data) > copyFromMaxChunkBytes {
+		return Error(fmt.Sprintf("chunk exceeds %d bytes: split it", copyFromMaxChunkBytes), "VALIDATION_ERROR", map[string]interface{}{"parameter": "data"})
+	}
+
+	u, ok := t.uploads.get(id, time.Now())
+	if !ok {
+		return uploadNotFound(id)
+	}
+	chunks, bytes, duplicate, err := u.write(index, data, time.Now())
+	if err != nil {
+		return Error(fmt.Sprintf("Cannot append to upload %s: %v", id, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter":      "chunk",
+			"expected_chunk": chunks,
+		})
+	}
+	return Success(map[string]interface{}{
+		"upload_id":      id,
+		"chunk":          index,
+		"duplicate":      duplicate,
+		"chunks":         chunks,
+		"received_bytes": bytes,
+	}, nil)
+}
+
+// commit streams an upload into its table with COPY and closes it, whether
+// the copy succeeds or not. A dry run returns the plan and keeps the upload
+// open.
+func (t *CopyFromTool) commit(ctx context.Context, id string, params map[string]interface{}) *ToolResult {
+	if IsDryRun(ctx) {
+		u, ok := t.uploads.get(id, time.Now())
+		if !ok {
+			return uploadNotFound(id)
+		}
+		return t.planCommit(ctx, u)
+	}
+
+	u, ok := t.uploads.remove(id, time.Now())
+	if !ok {
+		return uploadNotFound(id)
+	}
+	defer u.close()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	sum := hex.EncodeToString(u.hash.Sum(nil))
+	if expected, _ := params["sha256"].(string); expected != "" && !strings.EqualFold(expected, sum) {
+		return Error(fmt.Sprintf("Upload %s has SHA-256 %s, not %s: nothing was copied", id, sum, expected), "CHECKSUM_MISMATCH", map[string]interface{}{
+			"upload_id": id,
+			"sha256":    sum,
+			"bytes":     u.bytes,
+			"chunks":    u.chunks,
+		})
+	}
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		return t.copyError(u.tableName, "commit", err)
+	}
+
+	start := time.Now()
+	copyCtx, cancel := context.WithTimeout(ctx, copyFromCommitTimeout)
+	defer cancel()
+	tx, err := u.db.Begin(copyCtx)
+	if err != nil {
+		return t.copyError(u.tableName, "commit", err)
+	}
+	defer tx.Rollback(context.Background())
+	if u.truncate {
+		if _, err := tx.Exec(copyCtx, "TRUNCATE "+u.table.Sanitize()); err != nil {
+			return t.copyError(u.tableName, "commit", fmt.Errorf("failed to truncate: %w", err))
+		}
+	}
+	tag, err := tx.Conn().PgConn().CopyFrom(copyCtx, u.file, u.statement())
+	if err != nil {
+		return t.copyError(u.tableName, "commit", err)
+	}
+	if err := tx.Commit(copyCtx); err != nil {
+		return t.copyError(u.tableName, "commit", err)
+	}
+
+	return Success(map[string]interface{}{
+		"upload_id":   id,
+		"table":       u.tableName,
+		"rows_copied": tag.RowsAffected(),
+		"truncated":   u.truncate,
+		"bytes":       u.bytes,
+		"chunks":      u.chunks,
+		"sha256":      sum,
+	}, map[string]interface{}{
+		"duration_ms": msSince(start),
+	})
+}
+
+// planCommit returns the dry run plan of a commit
+func (t *CopyFromTool) planCommit(ctx context.Context, u *copyUpload) *ToolResult {
+	u.mu.Lock()
+	bytes, chunks := u.bytes, u.chunks
+	u.mu.Unlock()
+
+	var statements []PlannedStatement
+	permissions := []Permission{tablePermission("INSERT", u.table.Sanitize())}
+	if u.truncate {
+		statements = append(statements, PlannedStatement{SQL: "TRUNCATE " + u.table.Sanitize(), rowsOf: u.table.Sanitize()})
+		permissions = append(permissions, tablePermission("TRUNCATE", u.table.Sanitize()))
+	}
+	statements = append(statements, PlannedStatement{
+		SQL:  u.statement(),
+		Note: fmt.Sprintf("streams the %d bytes of %d chunks spooled for upload %s; the upload stays open", bytes, chunks, u.id),
+	})
+	return dryRunResult(ctx, u.db, t.Name(), statements, permissions)
+}
+
+// copyError reports a failed step of an upload
+func (t *CopyFromTool) copyError(table, action string, err error) *ToolResult {
+	t.logger.Error("COPY upload failed", err, map[string]interface{}{"table": table, "action": action})
+	return Error(fmt.Sprintf("COPY upload failed: table='%s', action='%s', error=%v", table, action, err), "COPY_ERROR", map[string]interface{}{
+		"table":  table,
+		"action": action,
+		"error":  err.Error(),
+	})
+}
+
+func uploadNotFound(id string) *ToolResult {
+	return Error(fmt.Sprintf("Upload %s does not exist: it was committed, aborted or expired after %s without a call", id, copyFromUploadTTL), "NOT_FOUND", map[string]interface{}{
+		"upload_id": id,
+	})
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func newTestUpload(t *testing.T, id string, now time.Time) *copyUpload {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "copy-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &copyUpload{id: id, table: pgx.Identifier{"t"}, format: "csv", file: file, hash: sha256.New(), lastUsed: now}
+}
+
+func TestCopyUploadStatement(t *testing.T) {
+	u := &copyUpload{
+		table:     pgx.Identifier{"public", "items"},
+		columns:   []string{"id", "Name"},
+		format:    "csv",
+		header:    true,
+		delimiter: ";",
+		null:      "it's null",
+	}
+	want := `COPY "public"."items" ("id", "Name") FROM STDIN WITH (FORMAT csv, HEADER true, DELIMITER ';', NULL 'it''s null')`
+	if got := u.statement(); got != want {
+		t.Errorf("statement = %s\nwant %s", got, want)
+	}
+
+	u = &copyUpload{table: pgx.Identifier{"items"}, format: "text", header: true}
+	want = `COPY "items" FROM STDIN WITH (FORMAT text)`
+	if got := u.statement(); got != want {
+		t.Errorf("statement = %s\nwant %s", got, want)
+	}
+}
+
+func TestCopyUploadWriteOrder(t *testing.T) {
+	now := time.Now()
+	u := newTestUpload(t, "u", now)
+	defer u.close()
+
+	if _, _, _, err := u.write(1, []byte("x"), now); err == nil {
+		t.Error("chunk 1 before chunk 0 was accepted")
+	}
+	if chunks, bytes, duplicate, err := u.write(0, []byte("id,name\n1,a"), now); err != nil || duplicate || chunks != 1 || bytes != 11 {
+		t.Fatalf("write chunk 0 = %d chunks, %d bytes, duplicate %v, %v", chunks, bytes, duplicate, err)
+	}
+	if chunks, bytes, duplicate, err := u.write(0, []byte("id,name\n1,a"), now); err != nil || !duplicate || chunks != 1 || bytes != 11 {
+		t.Errorf("resend chunk 0 = %d chunks, %d bytes, duplicate %v, %v; want acknowledged as a duplicate", chunks, bytes, duplicate, err)
+	}
+	if _, bytes, _, err := u.write(1, []byte("\n2,b\n"), now); err != nil || bytes != 16 {
+		t.Fatalf("write chunk 1 = %d bytes, %v", bytes, err)
+	}
+
+	data, err := os.ReadFile(u.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "id,name\n1,a\n2,b\n" {
+		t.Errorf("spooled %q", data)
+	}
+	if sum := sha256.Sum256(data); string(u.hash.Sum(nil)) != string(sum[:]) {
+		t.Error("running hash differs from the hash of the spooled data")
+	}
+
+	u.close()
+	if _, err := os.Stat(u.file.Name()); !os.IsNotExist(err) {
+		t.Errorf("spool file left after close: %v", err)
+	}
+	if _, _, _, err := u.write(2, []byte("3,c\n"), now); err == nil {
+		t.Error("write after close was accepted")
+	}
+}
+
+func TestCopyUploadsExpireAndLimit(t *testing.T) {
+	start := time.Now()
+	var uploads copyUploads
+	stale := newTestUpload(t, "stale", start)
+	if err := uploads.add(stale, start); err != nil {
+		t.Fatal(err)
+	}
+	later := start.Add(copyFromUploadTTL + time.Minute)
+	for i := 0; i < copyFromMaxUploads; i++ {
+		u := newTestUpload(t, string(rune('a'+i)), later)
+		if err := uploads.add(u, later); err != nil {
+			t.Fatalf("add upload %d: %v", i, err)
+		}
+		defer u.close()
+	}
+	if _, ok := uploads.get("stale", later); ok {
+		t.Error("idle upload was not expired")
+	}
+	if !stale.closed {
+		t.Error("expired upload was not closed")
+	}
+	if err := uploads.add(newTestUpload(t, "extra", later), later); err == nil {
+		t.Errorf("upload %d was accepted", copyFromMaxUploads+1)
+	}
+	if _, ok := uploads.remove("a", later); !ok {
+		t.Error("remove did not find an open upload")
+	}
+	if _, ok := uploads.get("a", later); ok {
+		t.Error("removed upload is still open")
+	}
+}
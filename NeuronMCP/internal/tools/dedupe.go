@@ -0,0 +1,669 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+const (
+	// DedupeTimeout bounds a deduplication run, from reading the table to
+	// writing the groups
+	DedupeTimeout = 10 * time.Minute
+	// maxDedupeRows bounds the rows fingerprinted in one run
+	maxDedupeRows = 1000000
+	// maxDedupeHamming bounds the SimHash distance of candidates; larger
+	// distances make the bands too narrow to prune anything
+	maxDedupeHamming = 10
+	// maxDedupeCandidates bounds the candidate pairs confirmed by vector
+	// distance in one run
+	maxDedupeCandidates = 500000
+	// dedupeConfirmBatch is the number of candidate pairs whose vector
+	// distance is computed per query
+	dedupeConfirmBatch = 1000
+	// dedupeSampleGroups is the number of groups returned in the result
+	dedupeSampleGroups = 10
+)
+
+// Ways of choosing the canonical row of a duplicate group
+const (
+	canonicalLowestKey   = "lowest_key"
+	canonicalHighestKey  = "highest_key"
+	canonicalLongestText = "longest_text"
+)
+
+// DedupeTableTool finds groups of near-duplicate rows in a table
+type DedupeTableTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewDedupeTableTool creates a new table deduplication tool
+func NewDedupeTableTool(db *database.Database, logger *logging.Logger) *DedupeTableTool {
+	return &DedupeTableTool{
+		BaseTool: NewBaseTool(
+			"dedupe_table",
+			"Find near-duplicate rows of a table: SimHash fingerprints of a text column pick candidate pairs cheaply, and the distance between their vectors confirms them. Duplicate groups are written to output_table with one canonical row per group",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table to deduplicate, optionally schema-qualified",
+					},
+					"text_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Column whose text is fingerprinted; rows where it is NULL or has no words are skipped",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Vector column compared to confirm candidates; rows where it is NULL are skipped",
+					},
+					"key_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column identifying rows; it also orders them for lowest_key and highest_key",
+					},
+					"hamming_threshold": map[string]interface{}{
+						"type":        "number",
+						"default":     3,
+						"minimum":     0,
+						"maximum":     maxDedupeHamming,
+						"description": "Largest number of differing bits between the 64-bit SimHash fingerprints of a candidate pair",
+					},
+					"shingle_size": map[string]interface{}{
+						"type":        "number",
+						"default":     2,
+						"minimum":     1,
+						"maximum":     5,
+						"description": "Words per shingle hashed into a fingerprint; larger values are more sensitive to word order",
+					},
+					"distance_metric": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"l2", "cosine", "inner_product"},
+						"default":     "cosine",
+						"description": "Distance metric confirming candidates",
+					},
+					"max_distance": map[string]interface{}{
+						"type":        "number",
+						"default":     0.05,
+						"description": "Largest vector distance of a confirmed pair, as returned by the metric's operator (negative inner product for inner_product)",
+					},
+					"canonical": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{canonicalLowestKey, canonicalHighestKey, canonicalLongestText},
+						"default":     canonicalLowestKey,
+						"description": "Row kept as the canonical one of each group: the lowest or highest key, or the longest text (ties go to the lowest key)",
+					},
+					"max_rows": map[string]interface{}{
+						"type":        "number",
+						"default":     100000,
+						"minimum":     2,
+						"maximum":     maxDedupeRows,
+						"description": "Most rows fingerprinted; the call fails on larger tables rather than deduplicating part of them",
+					},
+					"output_table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table created with one row per duplicate: group_id, row_key, canonical_key, is_canonical and group_size",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace output_table if it exists",
+					},
+				},
+				"required": []interface{}{"table", "text_column", "vector_column", "output_table"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// dedupeRequest holds the parsed dedupe_table parameters
+type dedupeRequest struct {
+	tableName    string
+	table        pgx.Identifier
+	textColumn   string
+	vectorColumn string
+	keyColumn    string
+	hamming      int
+	shingleSize  int
+	metric       string
+	maxDistance  float64
+	canonical    string
+	maxRows      int
+	outputName   string
+	output       pgx.Identifier
+	overwrite    bool
+}
+
+// dedupeRow is a fingerprinted row. Rows are held in key order, so their
+// index ranks their keys.
+type dedupeRow struct {
+	ctid       string
+	key        string
+	textLength int
+	simhash    uint64
+}
+
+// dedupePair is a candidate pair of row indexes, lower index first
+type dedupePair [2]int
+
+// Execute runs the deduplication
+func (t *DedupeTableTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for dedupe_table tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	req, invalid := parseDedupeRequest(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+	if IsDryRun(ctx) {
+		return t.planDedupe(ctx, req), nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, DedupeTimeout)
+	defer cancel()
+	start := time.Now()
+
+	// The rows are found again by ctid to compare their vectors, so the
+	// whole run sees one snapshot
+	tx, err := db.Begin(queryCtx)
+	if err != nil {
+		return t.dedupeError(req, err), nil
+	}
+	defer tx.Rollback(queryCtx)
+	if _, err := tx.Exec(queryCtx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return t.dedupeError(req, err), nil
+	}
+
+	var exists bool
+	if err := tx.QueryRow(queryCtx, "SELECT to_regclass($1) IS NOT NULL", req.output.Sanitize()).Scan(&exists); err != nil {
+		return t.dedupeError(req, err), nil
+	}
+	if exists && !req.overwrite {
+		return Error(fmt.Sprintf("Output table %s already exists: set overwrite to replace it", req.outputName), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "output_table",
+		}), nil
+	}
+
+	rows, skipped, err := t.readRows(queryCtx, tx, req)
+	if err != nil {
+		return t.dedupeError(req, err), nil
+	}
+	if len(rows)+skipped > req.maxRows {
+		return Error(fmt.Sprintf("Table %s has more than max_rows=%d rows to compare: raise max_rows (up to %d)", req.tableName, req.maxRows, maxDedupeRows), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "max_rows",
+		}), nil
+	}
+
+	candidates := dedupeCandidates(rows, req.hamming)
+	if len(candidates) > maxDedupeCandidates {
+		return Error(fmt.Sprintf("Deduplication of %s found %d candidate pairs, more than the %d that can be confirmed: lower hamming_threshold or raise shingle_size", req.tableName, len(candidates), maxDedupeCandidates), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "hamming_threshold",
+		}), nil
+	}
+	confirmed, err := t.confirmPairs(queryCtx, tx, req, rows, candidates)
+	if err != nil {
+		return t.dedupeError(req, err), nil
+	}
+	groups := dedupeGroups(rows, confirmed, req.canonical)
+
+	if exists {
+		if _, err := tx.Exec(queryCtx, "DROP TABLE "+req.output.Sanitize()); err != nil {
+			return t.dedupeError(req, fmt.Errorf("failed to drop %s: %w", req.outputName, err)), nil
+		}
+	}
+	if _, err := tx.Exec(queryCtx, dedupeCreateOutputQuery(req.output)); err != nil {
+		return t.dedupeError(req, fmt.Errorf("failed to create %s: %w", req.outputName, err)), nil
+	}
+	written, err := tx.CopyFrom(queryCtx, req.output, dedupeOutputColumns, pgx.CopyFromRows(dedupeOutputRows(rows, groups)))
+	if err != nil {
+		return t.dedupeError(req, fmt.Errorf("failed to write %s: %w", req.outputName, err)), nil
+	}
+	if err := tx.Commit(queryCtx); err != nil {
+		return t.dedupeError(req, fmt.Errorf("failed to commit %s: %w", req.outputName, err)), nil
+	}
+
+	duplicates := 0
+	sample := make([]map[string]interface{}, 0, dedupeSampleGroups)
+	for i, group := range groups {
+		duplicates += len(group) - 1
+		if i < dedupeSampleGroups {
+			keys := make([]string, len(group))
+			for j, idx := range group {
+				keys[j] = rows[idx].key
+			}
+			sample = append(sample, map[string]interface{}{
+				"group_id":      i + 1,
+				"canonical_key": keys[0],
+				"keys":          keys,
+			})
+		}
+	}
+
+	t.logger.Info("Table deduplicated", map[string]interface{}{
+		"table":        req.tableName,
+		"output_table": req.outputName,
+		"rows":         len(rows),
+		"groups":       len(groups),
+		"duplicates":   duplicates,
+	})
+	return Success(map[string]interface{}{
+		"output_table":    req.outputName,
+		"rows_compared":   len(rows),
+		"rows_skipped":    skipped,
+		"candidate_pairs": len(candidates),
+		"confirmed_pairs": len(confirmed),
+		"groups":          len(groups),
+		"duplicate_rows":  duplicates,
+		"rows_written":    written,
+		"sample_groups":   sample,
+	}, map[string]interface{}{
+		"table":             req.tableName,
+		"distance_metric":   req.metric,
+		"max_distance":      req.maxDistance,
+		"hamming_threshold": req.hamming,
+		"shingle_size":      req.shingleSize,
+		"canonical":         req.canonical,
+		"elapsed_ms":        msSince(start),
+	}), nil
+}
+
+// parseDedupeRequest validates the deduplication parameters, returning a
+// validation error result when they are unusable
+func parseDedupeRequest(params map[string]interface{}) (dedupeRequest, *ToolResult) {
+	req := dedupeRequest{
+		textColumn:   stringParam(params, "text_column", ""),
+		vectorColumn: stringParam(params, "vector_column", ""),
+		keyColumn:    stringParam(params, "key_column", "id"),
+		hamming:      3,
+		shingleSize:  2,
+		metric:       stringParam(params, "distance_metric", "cosine"),
+		maxDistance:  0.05,
+		canonical:    stringParam(params, "canonical", canonicalLowestKey),
+		maxRows:      100000,
+	}
+	req.overwrite, _ = params["overwrite"].(bool)
+
+	for _, table := range []struct {
+		param string
+		name  *string
+		ident *pgx.Identifier
+	}{
+		{"table", &req.tableName, &req.table},
+		{"output_table", &req.outputName, &req.output},
+	} {
+		*table.name, _ = params[table.param].(string)
+		ident, err := parseQualifiedIdentifier(*table.name)
+		if err != nil {
+			return req, Error(fmt.Sprintf("Invalid %s '%s': %v", table.param, *table.name, err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": table.param,
+			})
+		}
+		*table.ident = ident
+	}
+	if req.table.Sanitize() == req.output.Sanitize() {
+		return req, Error("output_table must differ from table", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "output_table",
+		})
+	}
+	for param, column := range map[string]string{"text_column": req.textColumn, "vector_column": req.vectorColumn} {
+		if column == "" {
+			return req, Error(fmt.Sprintf("%s is required", param), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": param,
+			})
+		}
+	}
+
+	for _, n := range []struct {
+		param    string
+		into     *int
+		min, max int
+	}{
+		{"hamming_threshold", &req.hamming, 0, maxDedupeHamming},
+		{"shingle_size", &req.shingleSize, 1, 5},
+		{"max_rows", &req.maxRows, 2, maxDedupeRows},
+	} {
+		if v, ok := params[n.param].(float64); ok {
+			*n.into = int(v)
+		}
+		if *n.into < n.min || *n.into > n.max {
+			return req, Error(fmt.Sprintf("%s must be between %d and %d, got %d", n.param, n.min, n.max, *n.into), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": n.param,
+			})
+		}
+	}
+
+	if _, ok := similarityJoinOperators[req.metric]; !ok {
+		return req, Error(fmt.Sprintf("Unsupported distance_metric '%s': use l2, cosine or inner_product", req.metric), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "distance_metric",
+		})
+	}
+	if v, ok := params["max_distance"].(float64); ok {
+		req.maxDistance = v
+	}
+	switch req.canonical {
+	case canonicalLowestKey, canonicalHighestKey, canonicalLongestText:
+	default:
+		return req, Error(fmt.Sprintf("Unsupported canonical '%s': use %s, %s or %s", req.canonical, canonicalLowestKey, canonicalHighestKey, canonicalLongestText), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "canonical",
+		})
+	}
+	return req, nil
+}
+
+// dedupeReadQuery reads the rows to fingerprint in key order, $1 being one
+// more than max_rows so larger tables are noticed
+func dedupeReadQuery(req dedupeRequest) string {
+	key := pgx.Identifier{req.keyColumn}.Sanitize()
+	text := pgx.Identifier{req.textColumn}.Sanitize()
+	vector := pgx.Identifier{req.vectorColumn}.Sanitize()
+	return fmt.Sprintf("SELECT ctid::text, %s::text, %s::text FROM %s WHERE %s IS NOT NULL AND %s IS NOT NULL ORDER BY %s LIMIT $1",
+		key, text, req.table.Sanitize(), text, vector, key)
+}
+
+// dedupeConfirmQuery returns the vector distance of candidate pairs, given
+// as arrays of ctids $1 and $2, by their 1-based position in the arrays
+func dedupeConfirmQuery(req dedupeRequest) string {
+	vector := pgx.Identifier{req.vectorColumn}.Sanitize()
+	return fmt.Sprintf("SELECT p.ord, (a.%s %s b.%s)::float8 FROM unnest($1::text[]::tid[], $2::text[]::tid[]) WITH ORDINALITY AS p(l, r, ord) "+
+		"JOIN %s a ON a.ctid = p.l JOIN %s b ON b.ctid = p.r",
+		vector, similarityJoinOperators[req.metric], vector, req.table.Sanitize(), req.table.Sanitize())
+}
+
+// dedupeOutputColumns are the columns of the output table
+var dedupeOutputColumns = []string{"group_id", "row_key", "canonical_key", "is_canonical", "group_size"}
+
+// dedupeCreateOutputQuery creates the output table
+func dedupeCreateOutputQuery(output pgx.Identifier) string {
+	return fmt.Sprintf("CREATE TABLE %s (group_id integer NOT NULL, row_key text NOT NULL, canonical_key text NOT NULL, "+
+		"is_canonical boolean NOT NULL, group_size integer NOT NULL)", output.Sanitize())
+}
+
+// readRows reads and fingerprints the rows of the table. Rows whose text has
+// no words are skipped and counted.
+func (t *DedupeTableTool) readRows(ctx context.Context, tx pgx.Tx, req dedupeRequest) ([]dedupeRow, int, error) {
+	result, err := tx.Query(ctx, dedupeReadQuery(req), req.maxRows+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer result.Close()
+
+	var rows []dedupeRow
+	skipped := 0
+	for result.Next() {
+		var row dedupeRow
+		var text string
+		if err := result.Scan(&row.ctid, &row.key, &text); err != nil {
+			return nil, 0, err
+		}
+		fingerprint, ok := simHash(text, req.shingleSize)
+		if !ok {
+			skipped++
+			continue
+		}
+		row.simhash = fingerprint
+		row.textLength = len(text)
+		rows = append(rows, row)
+	}
+	return rows, skipped, result.Err()
+}
+
+// confirmPairs returns the candidate pairs whose vectors are within
+// max_distance
+func (t *DedupeTableTool) confirmPairs(ctx context.Context, tx pgx.Tx, req dedupeRequest, rows []dedupeRow, candidates []dedupePair) ([]dedupePair, error) {
+	query := dedupeConfirmQuery(req)
+	var confirmed []dedupePair
+	for start := 0; start < len(candidates); start += dedupeConfirmBatch {
+		batch := candidates[start:min(start+dedupeConfirmBatch, len(candidates))]
+		left := make([]string, len(batch))
+		right := make([]string, len(batch))
+		for i, pair := range batch {
+			left[i], right[i] = rows[pair[0]].ctid, rows[pair[1]].ctid
+		}
+
+		result, err := tx.Query(ctx, query, left, right)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare vectors: %w", err)
+		}
+		for result.Next() {
+			var ord int64
+			var distance float64
+			if err := result.Scan(&ord, &distance); err != nil {
+				result.Close()
+				return nil, err
+			}
+			if distance <= req.maxDistance {
+				confirmed = append(confirmed, batch[ord-1])
+			}
+		}
+		result.Close()
+		if err := result.Err(); err != nil {
+			return nil, fmt.Errorf("failed to compare vectors: %w", err)
+		}
+	}
+	return confirmed, nil
+}
+
+// simHash returns the 64-bit SimHash of text: every shingle of size
+// consecutive lowercased words is hashed, and each bit of the fingerprint
+// is the majority vote of that bit over the shingle hashes. It reports
+// false when text has no words.
+func simHash(text string, size int) (uint64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0, false
+	}
+	size = min(size, len(words))
+
+	var votes [64]int
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				votes[bit]++
+			} else {
+				votes[bit]--
+			}
+		}
+	}
+	var fingerprint uint64
+	for bit, vote := range votes {
+		if vote > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint, true
+}
+
+// dedupeCandidates returns the pairs of rows whose fingerprints differ in
+// at most hamming bits. Rows with the same fingerprint are paired with the
+// first of them only, and other fingerprints with that row, so exact
+// duplicates add one pair per row rather than one per pair of rows.
+// Fingerprints are split into hamming+1 bands: two within hamming bits
+// agree on at least one band, so only fingerprints sharing a band value
+// are compared.
+func dedupeCandidates(rows []dedupeRow, hamming int) []dedupePair {
+	var candidates []dedupePair
+	first := map[uint64]int{}
+	var fingerprints []uint64
+	for i, row := range rows {
+		if j, ok := first[row.simhash]; ok {
+			candidates = append(candidates, dedupePair{j, i})
+			continue
+		}
+		first[row.simhash] = i
+		fingerprints = append(fingerprints, row.simhash)
+	}
+
+	bands := hamming + 1
+	seen := map[dedupePair]bool{}
+	for band := 0; band < bands; band++ {
+		lo, hi := band*64/bands, (band+1)*64/bands
+		mask := uint64(1)<<(hi-lo) - 1
+		if hi-lo == 64 {
+			mask = ^uint64(0)
+		}
+		buckets := map[uint64][]uint64{}
+		for _, fp := range fingerprints {
+			value := fp >> lo & mask
+			buckets[value] = append(buckets[value], fp)
+		}
+		for _, bucket := range buckets {
+			for i := 0; i < len(bucket); i++ {
+				for j := i + 1; j < len(bucket); j++ {
+					if bits.OnesCount64(bucket[i]^bucket[j]) > hamming {
+						continue
+					}
+					pair := dedupePair{first[bucket[i]], first[bucket[j]]}
+					if pair[0] > pair[1] {
+						pair[0], pair[1] = pair[1], pair[0]
+					}
+					if !seen[pair] {
+						seen[pair] = true
+						candidates = append(candidates, pair)
+					}
+				}
+			}
+			// A pathological bucket cannot grow the candidates far past
+			// the limit the caller enforces
+			if len(candidates) > maxDedupeCandidates {
+				return candidates
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i][0] != candidates[j][0] {
+			return candidates[i][0] < candidates[j][0]
+		}
+		return candidates[i][1] < candidates[j][1]
+	})
+	return candidates
+}
+
+// dedupeGroups returns the connected groups of confirmed pairs, each with
+// its canonical row first and the rest in key order. Groups are ordered by
+// their canonical row.
+func dedupeGroups(rows []dedupeRow, confirmed []dedupePair, canonical string) [][]int {
+	parent := map[int]int{}
+	for _, pair := range confirmed {
+		parent[pair[0]], parent[pair[1]] = pair[0], pair[1]
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] == i {
+			return i
+		}
+		root := find(parent[i])
+		parent[i] = root
+		return root
+	}
+	for _, pair := range confirmed {
+		a, b := find(pair[0]), find(pair[1])
+		if a != b {
+			parent[max(a, b)] = min(a, b)
+		}
+	}
+
+	members := map[int][]int{}
+	for i := range parent {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+
+	groups := make([][]int, 0, len(members))
+	for _, group := range members {
+		sort.Ints(group)
+		best := 0
+		for i, idx := range group {
+			switch canonical {
+			case canonicalHighestKey:
+				best = i
+			case canonicalLongestText:
+				if rows[idx].textLength > rows[group[best]].textLength {
+					best = i
+				}
+			}
+		}
+		canonicalRow := group[best]
+		group = append(group[:best:best], group[best+1:]...)
+		groups = append(groups, append([]int{canonicalRow}, group...))
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// dedupeOutputRows returns the rows written to the output table
+func dedupeOutputRows(rows []dedupeRow, groups [][]int) [][]interface{} {
+	var out [][]interface{}
+	for g, group := range groups {
+		canonicalKey := rows[group[0]].key
+		for i, idx := range group {
+			out = append(out, []interface{}{int32(g + 1), rows[idx].key, canonicalKey, i == 0, int32(len(group))})
+		}
+	}
+	return out
+}
+
+// planDedupe returns the dry run plan of a deduplication
+func (t *DedupeTableTool) planDedupe(ctx context.Context, req dedupeRequest) *ToolResult {
+	statements := []PlannedStatement{
+		{SQL: dedupeReadQuery(req), Params: []interface{}{req.maxRows + 1}, Note: "reads only; the rows are fingerprinted by the server"},
+		{SQL: dedupeConfirmQuery(req), Note: "reads only; runs once per " + strconv.Itoa(dedupeConfirmBatch) + " candidate pairs with their ctids"},
+	}
+	permissions := []Permission{tablePermission("SELECT", req.table.Sanitize())}
+	if req.overwrite {
+		statements = append(statements, PlannedStatement{
+			SQL:    "DROP TABLE " + req.output.Sanitize(),
+			rowsOf: req.output.Sanitize(),
+			Note:   "runs only when the table exists",
+		})
+		permissions = append(permissions, tablePermission("OWNER", req.output.Sanitize()))
+	}
+	statements = append(statements,
+		PlannedStatement{SQL: dedupeCreateOutputQuery(req.output)},
+		PlannedStatement{
+			SQL:  fmt.Sprintf("COPY %s (%s) FROM STDIN", req.output.Sanitize(), strings.Join(dedupeOutputColumns, ", ")),
+			Note: "writes one row per member of each duplicate group",
+		},
+	)
+	permissions = append(permissions, schemaPermission("CREATE", schemaOf(req.output)))
+	return dryRunResult(ctx, DatabaseFromContext(ctx, t.db), t.Name(), statements, permissions)
+}
+
+// dedupeError reports a failed deduplication
+func (t *DedupeTableTool) dedupeError(req dedupeRequest, err error) *ToolResult {
+	t.logger.Error("Deduplication failed", err, map[string]interface{}{"table": req.tableName, "output_table": req.outputName})
+	return Error(fmt.Sprintf("Deduplication failed: table='%s', output_table='%s', error=%v", req.tableName, req.outputName, err), "DEDUPE_ERROR", map[string]interface{}{
+		"table":        req.tableName,
+		"output_table": req.outputName,
+		"error":        err.Error(),
+	})
+}
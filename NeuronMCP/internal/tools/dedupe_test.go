@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"math/bits"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSimHash(t *testing.T) {
+	a, ok := simHash("The quick brown fox jumps over the lazy dog near the river bank", 2)
+	if !ok {
+		t.Fatal("simHash reported no words")
+	}
+	b, _ := simHash("the QUICK brown fox jumps over the lazy dog, near the river bank!", 2)
+	if a != b {
+		t.Errorf("case and punctuation changed the fingerprint: %x != %x", a, b)
+	}
+	c, _ := simHash("The quick brown fox jumps over the lazy cat near the river bank", 2)
+	d, _ := simHash("Quarterly revenue grew eleven percent on strong subscription sales", 2)
+	if near, far := bits.OnesCount64(a^c), bits.OnesCount64(a^d); near >= far {
+		t.Errorf("a one-word edit is %d bits away, unrelated text %d", near, far)
+	}
+	if _, ok := simHash(" ... !? ", 2); ok {
+		t.Error("text without words should not be fingerprinted")
+	}
+}
+
+func TestDedupeCandidates(t *testing.T) {
+	rows := []dedupeRow{
+		{key: "1", simhash: 0xFFFF},
+		{key: "2", simhash: 0xFFFF},
+		{key: "3", simhash: 0xFFFF},
+		{key: "4", simhash: 0xFFFE},                  // one bit from 1
+		{key: "5", simhash: 0xFFFF ^ 0xF0000000F000}, // eight bits from 1
+	}
+	got := dedupeCandidates(rows, 3)
+	want := []dedupePair{{0, 1}, {0, 2}, {0, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeCandidates = %v, want %v", got, want)
+	}
+	got = dedupeCandidates(rows, 8)
+	want = []dedupePair{{0, 1}, {0, 2}, {0, 3}, {0, 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeCandidates with hamming 8 = %v, want %v", got, want)
+	}
+}
+
+func TestDedupeGroups(t *testing.T) {
+	rows := []dedupeRow{
+		{key: "a", textLength: 5},
+		{key: "b", textLength: 9},
+		{key: "c", textLength: 7},
+		{key: "d", textLength: 1},
+		{key: "e", textLength: 2},
+	}
+	confirmed := []dedupePair{{0, 2}, {1, 2}, {3, 4}}
+
+	for canonical, want := range map[string][][]int{
+		canonicalLowestKey:   {{0, 1, 2}, {3, 4}},
+		canonicalHighestKey:  {{2, 0, 1}, {4, 3}},
+		canonicalLongestText: {{1, 0, 2}, {4, 3}},
+	} {
+		if got := dedupeGroups(rows, confirmed, canonical); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: dedupeGroups = %v, want %v", canonical, got, want)
+		}
+	}
+
+	out := dedupeOutputRows(rows, dedupeGroups(rows, confirmed, canonicalLowestKey))
+	if len(out) != 5 || !reflect.DeepEqual(out[1], []interface{}{int32(1), "b", "a", false, int32(3)}) {
+		t.Errorf("dedupeOutputRows = %v", out)
+	}
+}
+
+func TestParseDedupeRequest(t *testing.T) {
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"table":         "public.articles",
+			"text_column":   "body",
+			"vector_column": "embedding",
+			"output_table":  "public.article_dupes",
+		}
+	}
+
+	req, invalid := parseDedupeRequest(base())
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if req.keyColumn != "id" || req.hamming != 3 || req.metric != "cosine" || req.canonical != canonicalLowestKey {
+		t.Errorf("defaults = %+v", req)
+	}
+	if query := dedupeReadQuery(req); !strings.Contains(query, `FROM "public"."articles" WHERE "body" IS NOT NULL AND "embedding" IS NOT NULL ORDER BY "id" LIMIT $1`) {
+		t.Errorf("read query = %s", query)
+	}
+	if query := dedupeConfirmQuery(req); !strings.Contains(query, `(a."embedding" <=> b."embedding")`) {
+		t.Errorf("confirm query = %s", query)
+	}
+
+	for name, change := range map[string]func(map[string]interface{}){
+		"hamming_threshold": func(p map[string]interface{}) { p["hamming_threshold"] = float64(11) },
+		"canonical":         func(p map[string]interface{}) { p["canonical"] = "newest" },
+		"distance_metric":   func(p map[string]interface{}) { p["distance_metric"] = "hamming" },
+		"output_table":      func(p map[string]interface{}) { p["output_table"] = "public.articles" },
+		"vector_column":     func(p map[string]interface{}) { delete(p, "vector_column") },
+	} {
+		params := base()
+		change(params)
+		if _, invalid := parseDedupeRequest(params); invalid == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,688 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Diagnostic check statuses
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip" // not run, such as database checks when it is unreachable
+)
+
+const (
+	// diagnosticCheckTimeout bounds each database check
+	diagnosticCheckTimeout = 10 * time.Second
+	// diagnosticReportVersion is bumped when the diagnose report changes shape
+	diagnosticReportVersion = 1
+
+	poolSaturationWarn     = 0.8
+	defaultTempUsageWarnMB = 1024
+	neurondbExtensionName  = "neurondb"
+)
+
+// requiredFunction is a database function tools call
+type requiredFunction struct {
+	Name string
+	// Required functions fail readiness when missing; the others only
+	// disable the tools listed in Tools
+	Required bool
+	Tools    []string
+}
+
+// neurondbFunctions are the NeuronDB functions checked by readiness_check
+// and diagnose. Unqualified names are looked up on the search path, as the
+// tools call them.
+var neurondbFunctions = []requiredFunction{
+	{Name: "embed_text", Required: true, Tools: []string{"generate_embedding", "retrieve_context", "model_health"}},
+	{Name: "neurondb.embed", Required: true, Tools: []string{"generate_embedding", "model_health"}},
+	{Name: "neurondb.embed_batch", Required: true, Tools: []string{"batch_embedding", "chunk_text", "ingest_document"}},
+	{Name: "neurondb.llm", Tools: []string{"generate_sql", "model_health", "generate_response"}},
+	{Name: "rerank_cross_encoder", Tools: []string{"rerank_cross_encoder"}},
+	{Name: "rerank_llm", Tools: []string{"rerank_llm"}},
+	{Name: "rerank_cohere", Tools: []string{"rerank_cohere"}},
+	{Name: "rerank_colbert", Tools: []string{"rerank_colbert"}},
+	{Name: "embed_image", Tools: []string{"embed_image"}},
+	{Name: "embed_multimodal", Tools: []string{"embed_multimodal"}},
+	{Name: "embed_cached", Tools: []string{"embed_cached"}},
+	{Name: "neurondb.detect_anomalies_lof", Tools: []string{"detect_outliers"}},
+	{Name: "neurondb.detect_anomalies_isolation_forest", Tools: []string{"detect_outliers"}},
+}
+
+// DiagnosticCheck is the outcome of one check of the server's dependencies
+type DiagnosticCheck struct {
+	Name       string                 `json:"name"`
+	Status     string                 `json:"status"`
+	Message    string                 `json:"message"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	DurationMs float64                `json:"duration_ms"`
+}
+
+// diagnosticOptions selects the checks to run beyond connectivity, the
+// circuit breaker and the extension
+type diagnosticOptions struct {
+	functions     bool
+	pool          bool
+	models        []ModelTarget
+	checkModels   bool
+	tempUsage     bool
+	tempWarnBytes int64
+}
+
+// runDiagnosticChecks runs the selected checks in order. When the database
+// cannot be reached, the checks that need it are skipped.
+func runDiagnosticChecks(ctx context.Context, db *database.Database, opts diagnosticOptions) []DiagnosticCheck {
+	checks := []DiagnosticCheck{timeCheck(func() DiagnosticCheck { return connectivityCheck(ctx, db) })}
+	reachable := checks[0].Status == CheckPass
+	checks = append(checks, circuitCheck(db))
+
+	dbCheck := func(name string, check func(context.Context) DiagnosticCheck) {
+		if !reachable {
+			checks = append(checks, DiagnosticCheck{Name: name, Status: CheckSkip, Message: "database is unreachable"})
+			return
+		}
+		checks = append(checks, timeCheck(func() DiagnosticCheck {
+			checkCtx, cancel := context.WithTimeout(ctx, diagnosticCheckTimeout)
+			defer cancel()
+			return check(checkCtx)
+		}))
+	}
+
+	dbCheck("extension", func(ctx context.Context) DiagnosticCheck { return extensionCheck(ctx, db) })
+	if opts.functions {
+		dbCheck("functions", func(ctx context.Context) DiagnosticCheck { return functionsCheck(ctx, db) })
+	}
+	if opts.pool {
+		var stats *database.PoolStats
+		if db != nil {
+			stats = db.GetPoolStats()
+		}
+		checks = append(checks, poolCheck(stats))
+	}
+	if opts.checkModels {
+		if len(opts.models) == 0 {
+			checks = append(checks, DiagnosticCheck{Name: "models", Status: CheckSkip, Message: "no models configured or given"})
+		} else {
+			// Model calls carry their own timeout, which can exceed the
+			// check timeout
+			executor := NewQueryExecutor(db)
+			if reachable {
+				checks = append(checks, timeCheck(func() DiagnosticCheck { return modelsCheck(ctx, executor, opts.models) }))
+			} else {
+				checks = append(checks, DiagnosticCheck{Name: "models", Status: CheckSkip, Message: "database is unreachable"})
+			}
+		}
+	}
+	if opts.tempUsage {
+		dbCheck("temp_usage", func(ctx context.Context) DiagnosticCheck { return tempUsageCheck(ctx, db, opts.tempWarnBytes) })
+	}
+	return checks
+}
+
+// timeCheck runs a check and sets its duration
+func timeCheck(check func() DiagnosticCheck) DiagnosticCheck {
+	start := time.Now()
+	result := check()
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return result
+}
+
+// connectivityCheck pings the database, bypassing the circuit breaker
+func connectivityCheck(ctx context.Context, db *database.Database) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "connectivity"}
+	if db == nil || !db.IsConnected() {
+		check.Status, check.Message = CheckFail, "database connection pool is not initialized"
+		return check
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+	if err := db.TestConnection(pingCtx); err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check
+	}
+
+	var version string
+	if err := db.QueryRow(pingCtx, "SELECT current_setting('server_version')").Scan(&version); err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("database answered the ping but not a query: %v", err)
+		return check
+	}
+	check.Status, check.Message = CheckPass, "database is reachable"
+	check.Details = map[string]interface{}{"server_version": version}
+	return check
+}
+
+// circuitCheck reports the query circuit breaker. An open circuit fails
+// tool queries at once even when the database is back.
+func circuitCheck(db *database.Database) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "circuit_breaker"}
+	if db == nil {
+		check.Status, check.Message = CheckSkip, "no database configured"
+		return check
+	}
+	state := db.Breaker().State()
+	check.Details = map[string]interface{}{"circuit": state}
+	if state.State == database.CircuitClosed {
+		check.Status, check.Message = CheckPass, "circuit is closed"
+	} else {
+		check.Status, check.Message = CheckWarn, fmt.Sprintf("circuit is %s: tool queries are being refused", state.State)
+	}
+	return check
+}
+
+// extensionCheck reports the installed NeuronDB extension version
+func extensionCheck(ctx context.Context, db *database.Database) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "extension"}
+	var installed, available *string
+	err := db.QueryRow(ctx, `
+		SELECT e.extversion, a.default_version
+		FROM pg_available_extensions a
+		LEFT JOIN pg_extension e ON e.extname = a.name
+		WHERE a.name = $1`, neurondbExtensionName).Scan(&installed, &available)
+	if errors.Is(err, pgx.ErrNoRows) {
+		check.Status, check.Message = CheckFail, "the neurondb extension is not available on the database server"
+		return check
+	}
+	if err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("failed to read extensions: %v", err)
+		return check
+	}
+
+	check.Details = map[string]interface{}{"name": neurondbExtensionName, "available_version": available}
+	switch {
+	case installed == nil:
+		check.Status, check.Message = CheckFail, "the neurondb extension is not installed: run CREATE EXTENSION neurondb"
+	case available != nil && *available != *installed:
+		check.Details["installed_version"] = *installed
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("neurondb %s is installed and %s is available: run ALTER EXTENSION neurondb UPDATE", *installed, *available)
+	default:
+		check.Details["installed_version"] = *installed
+		check.Status, check.Message = CheckPass, fmt.Sprintf("neurondb %s is installed", *installed)
+	}
+	return check
+}
+
+// functionsCheck reports which NeuronDB functions the tools call are
+// missing
+func functionsCheck(ctx context.Context, db *database.Database) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "functions"}
+	names := make([]string, len(neurondbFunctions))
+	for i, fn := range neurondbFunctions {
+		names[i] = fn.Name
+	}
+	rows, err := db.Query(ctx, `
+		SELECT f.name, EXISTS (
+			SELECT 1 FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+			WHERE p.proname = substring(f.name from '[^.]+$')
+			  AND CASE WHEN position('.' in f.name) > 0
+			           THEN n.nspname = split_part(f.name, '.', 1)
+			           ELSE pg_function_is_visible(p.oid) END)
+		FROM unnest($1::text[]) AS f(name)`, names)
+	if err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("failed to read functions: %v", err)
+		return check
+	}
+	present := map[string]bool{}
+	for rows.Next() {
+		var name string
+		var found bool
+		if err := rows.Scan(&name, &found); err != nil {
+			rows.Close()
+			check.Status, check.Message = CheckFail, fmt.Sprintf("failed to read functions: %v", err)
+			return check
+		}
+		present[name] = found
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("failed to read functions: %v", err)
+		return check
+	}
+	return summarizeFunctions(present)
+}
+
+// summarizeFunctions turns the presence of each function into a check
+func summarizeFunctions(present map[string]bool) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "functions", Status: CheckPass}
+	var missingRequired, missingOptional []string
+	var disabled []string
+	for _, fn := range neurondbFunctions {
+		if present[fn.Name] {
+			continue
+		}
+		if fn.Required {
+			missingRequired = append(missingRequired, fn.Name)
+		} else {
+			missingOptional = append(missingOptional, fn.Name)
+		}
+		disabled = append(disabled, fn.Tools...)
+	}
+	check.Details = map[string]interface{}{
+		"checked":          len(neurondbFunctions),
+		"missing_required": missingRequired,
+		"missing_optional": missingOptional,
+		"affected_tools":   disabled,
+	}
+	switch {
+	case len(missingRequired) > 0:
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("%d required functions are missing", len(missingRequired))
+	case len(missingOptional) > 0:
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("%d optional functions are missing", len(missingOptional))
+	default:
+		check.Message = "all functions are present"
+	}
+	return check
+}
+
+// poolCheck reports how much of the connection pool is in use. A saturated
+// pool makes tool calls wait for a connection.
+func poolCheck(stats *database.PoolStats) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "pool"}
+	if stats == nil {
+		check.Status, check.Message = CheckSkip, "connection pool is not initialized"
+		return check
+	}
+	saturation := 0.0
+	if stats.MaxConns > 0 {
+		saturation = float64(stats.AcquiredConns) / float64(stats.MaxConns)
+	}
+	check.Details = map[string]interface{}{
+		"total_conns":        stats.TotalConns,
+		"acquired_conns":     stats.AcquiredConns,
+		"idle_conns":         stats.IdleConns,
+		"constructing_conns": stats.ConstructingConns,
+		"max_conns":          stats.MaxConns,
+		"saturation":         saturation,
+	}
+	switch {
+	case saturation >= 1:
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("all %d connections are in use", stats.MaxConns)
+	case saturation >= poolSaturationWarn:
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("%d of %d connections are in use", stats.AcquiredConns, stats.MaxConns)
+	default:
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("%d of %d connections are in use", stats.AcquiredConns, stats.MaxConns)
+	}
+	return check
+}
+
+// modelsCheck calls each model once, as model_health does
+func modelsCheck(ctx context.Context, executor *QueryExecutor, targets []ModelTarget) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "models"}
+	probes := WarmupModels(ctx, executor, targets)
+	var unavailable []string
+	for _, probe := range probes {
+		if !probe.Available {
+			unavailable = append(unavailable, probe.Model)
+		}
+	}
+	check.Details = map[string]interface{}{"models": probes}
+	switch {
+	case len(probes) < len(targets):
+		check.Status, check.Message = CheckFail, "model checks were cancelled"
+	case len(unavailable) == len(probes):
+		check.Status, check.Message = CheckFail, "no model is available"
+	case len(unavailable) > 0:
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("%d of %d models are unavailable", len(unavailable), len(probes))
+	default:
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("all %d models are available", len(probes))
+	}
+	return check
+}
+
+// tempUsageCheck reports the temporary files of the database server, which
+// large sorts, hashes and index builds spill to
+func tempUsageCheck(ctx context.Context, db *database.Database, warnBytes int64) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "temp_usage"}
+	var tempFiles, tempBytes int64
+	var tempFileLimit, tempTablespaces string
+	err := db.QueryRow(ctx, `
+		SELECT temp_files, temp_bytes, current_setting('temp_file_limit'), current_setting('temp_tablespaces')
+		FROM pg_stat_database WHERE datname = current_database()`).Scan(&tempFiles, &tempBytes, &tempFileLimit, &tempTablespaces)
+	if err != nil {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("failed to read temp file statistics: %v", err)
+		return check
+	}
+	check.Details = map[string]interface{}{
+		"temp_files_total": tempFiles,
+		"temp_bytes_total": tempBytes,
+		"temp_file_limit":  tempFileLimit,
+		"temp_tablespaces": tempTablespaces,
+		"warn_bytes":       warnBytes,
+	}
+
+	// Listing the temp directory needs superuser or pg_monitor
+	var currentFiles, currentBytes int64
+	err = db.QueryRow(ctx, "SELECT count(*), coalesce(sum(size), 0) FROM pg_ls_tmpdir()").Scan(&currentFiles, &currentBytes)
+	if err != nil {
+		check.Status = CheckSkip
+		check.Message = fmt.Sprintf("current temp files cannot be listed (needs pg_monitor): %v", err)
+		return check
+	}
+	check.Details["current_files"] = currentFiles
+	check.Details["current_bytes"] = currentBytes
+	return summarizeTempUsage(check, currentBytes, warnBytes)
+}
+
+// summarizeTempUsage sets the status of a temp usage check from the bytes
+// currently held in temp files
+func summarizeTempUsage(check DiagnosticCheck, currentBytes, warnBytes int64) DiagnosticCheck {
+	if currentBytes > warnBytes {
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("temp files hold %d MB, over the %d MB threshold", currentBytes>>20, warnBytes>>20)
+	} else {
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("temp files hold %d MB", currentBytes>>20)
+	}
+	return check
+}
+
+// summarizeChecks returns the overall status of checks: unhealthy when one
+// failed, degraded when one warned, and healthy otherwise. It also counts
+// the checks by status.
+func summarizeChecks(checks []DiagnosticCheck) (string, map[string]int) {
+	counts := map[string]int{CheckPass: 0, CheckWarn: 0, CheckFail: 0, CheckSkip: 0}
+	for _, check := range checks {
+		counts[check.Status]++
+	}
+	switch {
+	case counts[CheckFail] > 0:
+		return "unhealthy", counts
+	case counts[CheckWarn] > 0:
+		return "degraded", counts
+	}
+	return "healthy", counts
+}
+
+// diagnosticModels returns the models to check: those named in params, or
+// the configured ones
+func diagnosticModels(ctx context.Context, params map[string]interface{}) ([]ModelTarget, *ToolResult) {
+	_, hasEmbedding := params["embedding_models"]
+	_, hasGeneration := params["generation_models"]
+	if !hasEmbedding && !hasGeneration {
+		return ModelTargetsFromContext(ctx), nil
+	}
+	return modelTargetsParam(ctx, params)
+}
+
+// HealthCheckTool is a quick liveness check: the database answers and has
+// the extension installed
+type HealthCheckTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewHealthCheckTool creates a new health check tool
+func NewHealthCheckTool(db *database.Database, logger *logging.Logger) *HealthCheckTool {
+	return &HealthCheckTool{
+		BaseTool: NewBaseTool(
+			"health_check",
+			"Quick liveness check: database connectivity, the query circuit breaker and the NeuronDB extension",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+				"required":   []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute runs the liveness checks
+func (t *HealthCheckTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	checks := runDiagnosticChecks(ctx, DatabaseFromContext(ctx, t.db), diagnosticOptions{})
+	status, _ := summarizeChecks(checks)
+	if status != "healthy" {
+		t.logger.Warn("Health check is not healthy", map[string]interface{}{"status": status})
+	}
+	return Success(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	}, map[string]interface{}{
+		"tool": "health_check",
+	}), nil
+}
+
+// ReadinessCheckTool reports whether the server can serve tool calls: the
+// liveness checks plus the functions tools call, pool headroom and models
+type ReadinessCheckTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewReadinessCheckTool creates a new readiness check tool
+func NewReadinessCheckTool(db *database.Database, logger *logging.Logger) *ReadinessCheckTool {
+	properties := map[string]interface{}{
+		"check_models": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Call each model once. Models are the ones given, or the configured ones.",
+			"default":     true,
+		},
+	}
+	for name, schema := range modelListProperties {
+		properties[name] = schema
+	}
+	return &ReadinessCheckTool{
+		BaseTool: NewBaseTool(
+			"readiness_check",
+			"Check the server is ready to serve tool calls: connectivity, the NeuronDB extension and the functions tools call, connection pool headroom and model availability",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute runs the readiness checks
+func (t *ReadinessCheckTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	models, invalid := diagnosticModels(ctx, params)
+	if invalid != nil {
+		return invalid, nil
+	}
+	checkModels := true
+	if v, ok := params["check_models"].(bool); ok {
+		checkModels = v
+	}
+
+	checks := runDiagnosticChecks(ctx, DatabaseFromContext(ctx, t.db), diagnosticOptions{
+		functions:   true,
+		pool:        true,
+		models:      models,
+		checkModels: checkModels,
+	})
+	status, _ := summarizeChecks(checks)
+	var failed []string
+	for _, check := range checks {
+		if check.Status == CheckFail {
+			failed = append(failed, check.Name)
+		}
+	}
+	if len(failed) > 0 {
+		t.logger.Warn("Readiness check failed", map[string]interface{}{"failed_checks": failed})
+	}
+
+	return Success(map[string]interface{}{
+		"ready":         len(failed) == 0,
+		"status":        status,
+		"failed_checks": failed,
+		"checks":        checks,
+	}, map[string]interface{}{
+		"tool": "readiness_check",
+	}), nil
+}
+
+// DiagnoseTool produces a diagnostic report for support tickets: every
+// check, with the database settings and server runtime they depend on
+type DiagnoseTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewDiagnoseTool creates a new diagnose tool
+func NewDiagnoseTool(db *database.Database, logger *logging.Logger) *DiagnoseTool {
+	properties := map[string]interface{}{
+		"check_models": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Call each model once. Models are the ones given, or the configured ones.",
+			"default":     true,
+		},
+		"temp_warn_mb": map[string]interface{}{
+			"type":        "integer",
+			"description": "Warn when the server's temp files hold more than this many MB",
+			"default":     defaultTempUsageWarnMB,
+			"minimum":     1,
+		},
+	}
+	for name, schema := range modelListProperties {
+		properties[name] = schema
+	}
+	return &DiagnoseTool{
+		BaseTool: NewBaseTool(
+			"diagnose",
+			"Produce a machine-readable diagnostic report for support: connectivity, circuit breaker, NeuronDB extension and functions, connection pool, models, temp file usage, database settings and server runtime",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute runs every check and assembles the report
+func (t *DiagnoseTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	models, invalid := diagnosticModels(ctx, params)
+	if invalid != nil {
+		return invalid, nil
+	}
+	checkModels := true
+	if v, ok := params["check_models"].(bool); ok {
+		checkModels = v
+	}
+	tempWarnMB := int64(defaultTempUsageWarnMB)
+	if v, ok := params["temp_warn_mb"].(float64); ok {
+		if v < 1 {
+			return Error(fmt.Sprintf("temp_warn_mb must be at least 1, got %v", v), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "temp_warn_mb",
+			}), nil
+		}
+		tempWarnMB = int64(v)
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	checks := runDiagnosticChecks(ctx, db, diagnosticOptions{
+		functions:     true,
+		pool:          true,
+		models:        models,
+		checkModels:   checkModels,
+		tempUsage:     true,
+		tempWarnBytes: tempWarnMB << 20,
+	})
+	status, counts := summarizeChecks(checks)
+
+	report := map[string]interface{}{
+		"report_version": diagnosticReportVersion,
+		"generated_at":   time.Now().UTC().Format(time.RFC3339),
+		"status":         status,
+		"summary":        counts,
+		"checks":         checks,
+		"runtime": map[string]interface{}{
+			"go_version": runtime.Version(),
+			"os":         runtime.GOOS,
+			"arch":       runtime.GOARCH,
+			"goroutines": runtime.NumGoroutine(),
+		},
+		"retry_policy": map[string]interface{}{
+			"max_attempts":  database.DefaultRetryPolicy.MaxAttempts,
+			"base_delay_ms": database.DefaultRetryPolicy.BaseDelay.Milliseconds(),
+			"max_delay_ms":  database.DefaultRetryPolicy.MaxDelay.Milliseconds(),
+		},
+	}
+	if checks[0].Status == CheckPass {
+		settings, err := t.databaseSettings(ctx, db)
+		if err != nil {
+			report["database_error"] = err.Error()
+		} else {
+			report["database"] = settings
+		}
+	}
+
+	return Success(report, map[string]interface{}{
+		"tool": "diagnose",
+	}), nil
+}
+
+// diagnosticSettings are the server settings the report includes
+var diagnosticSettings = []string{
+	"server_version", "max_connections", "shared_buffers", "work_mem", "maintenance_work_mem",
+	"statement_timeout", "temp_file_limit", "search_path", "shared_preload_libraries",
+}
+
+// databaseSettings reads the database identity and the settings tools
+// depend on
+func (t *DiagnoseTool) databaseSettings(ctx context.Context, db *database.Database) (map[string]interface{}, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, diagnosticCheckTimeout)
+	defer cancel()
+
+	var name, user string
+	var connections int64
+	if err := db.QueryRow(queryCtx, `
+		SELECT current_database(), current_user,
+		       (SELECT count(*) FROM pg_stat_activity WHERE datname = current_database())`).Scan(&name, &user, &connections); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(queryCtx, "SELECT name, setting, unit FROM pg_settings WHERE name = ANY($1)", diagnosticSettings)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	settings := map[string]interface{}{}
+	for rows.Next() {
+		var setting, value string
+		var unit *string
+		if err := rows.Scan(&setting, &value, &unit); err != nil {
+			return nil, err
+		}
+		if unit != nil {
+			value += " " + *unit
+		}
+		settings[setting] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"name":        name,
+		"user":        user,
+		"connections": connections,
+		"settings":    settings,
+	}, nil
+}
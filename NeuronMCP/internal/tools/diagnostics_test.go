@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+func TestRunDiagnosticChecksWithoutDatabase(t *testing.T) {
+	checks := runDiagnosticChecks(context.Background(), nil, diagnosticOptions{
+		functions:   true,
+		pool:        true,
+		checkModels: true,
+		models:      []ModelTarget{{Name: "m", Kind: ModelKindEmbedding}},
+		tempUsage:   true,
+	})
+
+	want := map[string]string{
+		"connectivity":    CheckFail,
+		"circuit_breaker": CheckSkip,
+		"extension":       CheckSkip,
+		"functions":       CheckSkip,
+		"pool":            CheckSkip,
+		"models":          CheckSkip,
+		"temp_usage":      CheckSkip,
+	}
+	if len(checks) != len(want) {
+		t.Fatalf("got %d checks, want %d: %+v", len(checks), len(want), checks)
+	}
+	for _, check := range checks {
+		if check.Status != want[check.Name] {
+			t.Errorf("%s: status %q, want %q", check.Name, check.Status, want[check.Name])
+		}
+	}
+	if status, counts := summarizeChecks(checks); status != "unhealthy" || counts[CheckFail] != 1 || counts[CheckSkip] != 6 {
+		t.Errorf("summarizeChecks() = %s, %v", status, counts)
+	}
+}
+
+func TestSummarizeFunctions(t *testing.T) {
+	present := map[string]bool{}
+	for _, fn := range neurondbFunctions {
+		present[fn.Name] = true
+	}
+	if check := summarizeFunctions(present); check.Status != CheckPass {
+		t.Errorf("all present: status %q, want pass", check.Status)
+	}
+
+	present["rerank_cohere"] = false
+	check := summarizeFunctions(present)
+	if check.Status != CheckWarn {
+		t.Errorf("optional missing: status %q, want warn", check.Status)
+	}
+	if tools, _ := check.Details["affected_tools"].([]string); len(tools) != 1 || tools[0] != "rerank_cohere" {
+		t.Errorf("affected_tools = %v, want [rerank_cohere]", check.Details["affected_tools"])
+	}
+
+	present["neurondb.embed_batch"] = false
+	if check := summarizeFunctions(present); check.Status != CheckFail {
+		t.Errorf("required missing: status %q, want fail", check.Status)
+	}
+}
+
+func TestPoolCheck(t *testing.T) {
+	for _, tc := range []struct {
+		acquired int32
+		want     string
+	}{
+		{acquired: 2, want: CheckPass},
+		{acquired: 8, want: CheckWarn},
+		{acquired: 10, want: CheckFail},
+	} {
+		check := poolCheck(&database.PoolStats{AcquiredConns: tc.acquired, TotalConns: 10, MaxConns: 10})
+		if check.Status != tc.want {
+			t.Errorf("%d of 10 acquired: status %q, want %q", tc.acquired, check.Status, tc.want)
+		}
+	}
+}
+
+func TestSummarizeTempUsage(t *testing.T) {
+	if check := summarizeTempUsage(DiagnosticCheck{}, 512<<20, 1024<<20); check.Status != CheckPass {
+		t.Errorf("under threshold: status %q, want pass", check.Status)
+	}
+	if check := summarizeTempUsage(DiagnosticCheck{}, 2048<<20, 1024<<20); check.Status != CheckWarn {
+		t.Errorf("over threshold: status %q, want warn", check.Status)
+	}
+}
+
+func TestSummarizeChecks(t *testing.T) {
+	status, _ := summarizeChecks([]DiagnosticCheck{{Status: CheckPass}, {Status: CheckSkip}})
+	if status != "healthy" {
+		t.Errorf("status %q, want healthy", status)
+	}
+	status, _ = summarizeChecks([]DiagnosticCheck{{Status: CheckPass}, {Status: CheckWarn}})
+	if status != "degraded" {
+		t.Errorf("status %q, want degraded", status)
+	}
+}
@@ -0,0 +1,300 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+// dryRunTools are the mutating tools that honor dry runs. Called with a dry
+// run context they validate their parameters as usual and then return a
+// plan of the statements they would run instead of running them.
+var dryRunTools = map[string]bool{
+	"create_hnsw_index":             true,
+	"create_ivf_index":              true,
+	"drop_index":                    true,
+	"train_model":                   true,
+	"delete_model":                  true,
+	"configure_embedding_model":     true,
+	"delete_embedding_model_config": true,
+	"ingest_document":               true,
+	"upsert_embeddings":             true,
+	"sparse_embed_column":           true,
+	"vector_similarity_join":        true,
+	"dedupe_table":                  true,
+	"manage_embedding_column":       true,
+	"generate_test_data":            true,
+	"manage_schema":                 true,
+	"analyze_vector_tables":         true,
+	"copy_from":                     true,
+	"rebuild_vector_index":          true,
+	"vacuum_vector_table":           true,
+}
+
+// SupportsDryRun reports whether a tool honors dry runs
+func SupportsDryRun(toolName string) bool {
+	return dryRunTools[toolName]
+}
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context asking the tool for a plan instead of
+// running its statements
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx asks for a dry run
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// PlannedStatement is a statement a dry run would have executed
+type PlannedStatement struct {
+	SQL    string        `json:"sql"`
+	Params []interface{} `json:"params"`
+	// EstimatedRows is the number of rows the statement is expected to
+	// write; for an index build or drop, the rows of the relation it covers
+	EstimatedRows *int64 `json:"estimated_rows"`
+	// EstimateSource is "explain", "statistics" or "tool"
+	EstimateSource string `json:"estimate_source,omitempty"`
+	Note           string `json:"note,omitempty"`
+
+	// rowsOf names a relation whose planner statistics estimate the rows,
+	// for statements EXPLAIN cannot plan or that call a function
+	rowsOf string
+}
+
+// Permission is a privilege the current role needs for a planned call
+type Permission struct {
+	// Privilege is SELECT, INSERT, UPDATE, DELETE, TRUNCATE, CREATE, EXECUTE
+	// or OWNER
+	Privilege string `json:"privilege"`
+	// ObjectType is table, schema or function
+	ObjectType string `json:"object_type"`
+	Object     string `json:"object"`
+	// Granted is nil when the object does not exist or was not checked
+	Granted *bool `json:"granted"`
+}
+
+// tablePermission requires privilege on a table or index; OWNER requires
+// membership in the role owning it
+func tablePermission(privilege, table string) Permission {
+	return Permission{Privilege: privilege, ObjectType: "table", Object: table}
+}
+
+// schemaPermission requires privilege on a schema; an empty schema stands
+// for the current schema
+func schemaPermission(privilege, schema string) Permission {
+	return Permission{Privilege: privilege, ObjectType: "schema", Object: schema}
+}
+
+// functionPermission requires EXECUTE on every overload of a function,
+// given as name or schema.name
+func functionPermission(function string) Permission {
+	return Permission{Privilege: "EXECUTE", ObjectType: "function", Object: function}
+}
+
+// schemaOf returns the schema part of a parsed table name, or "" when it is
+// unqualified
+func schemaOf(table pgx.Identifier) string {
+	if len(table) == 2 {
+		return table[0]
+	}
+	return ""
+}
+
+// dryRunResult returns the plan of a dry run: statements are estimated with
+// EXPLAIN, which plans them without running them, or from the planner
+// statistics of rowsOf, and permissions are checked against the current
+// role. A statement that cannot be estimated, for example because an
+// earlier planned statement creates the table it uses, keeps a nil estimate
+// and a note saying why.
+func dryRunResult(ctx context.Context, db *database.Database, tool string, statements []PlannedStatement, permissions []Permission, notes ...string) *ToolResult {
+	connected := db != nil && db.IsConnected()
+	if !connected {
+		notes = append(notes, "database connection not available: row estimates and permission checks were skipped")
+	}
+
+	for i := range statements {
+		stmt := &statements[i]
+		if stmt.Params == nil {
+			stmt.Params = []interface{}{}
+		}
+		if stmt.EstimatedRows != nil {
+			if stmt.EstimateSource == "" {
+				stmt.EstimateSource = "tool"
+			}
+			continue
+		}
+		if !connected {
+			continue
+		}
+		rows, source, err := estimateStatementRows(ctx, db, stmt)
+		if err != nil {
+			stmt.Note = joinNote(stmt.Note, fmt.Sprintf("rows not estimated: %v", err))
+			continue
+		}
+		if source != "" {
+			stmt.EstimatedRows, stmt.EstimateSource = &rows, source
+		}
+	}
+
+	if connected {
+		for i := range permissions {
+			granted, err := checkPermission(ctx, db, permissions[i])
+			if err != nil {
+				notes = append(notes, fmt.Sprintf("could not check %s on %s %s: %v", permissions[i].Privilege, permissions[i].ObjectType, permissions[i].Object, err))
+				continue
+			}
+			permissions[i].Granted = granted
+		}
+	}
+	if permissions == nil {
+		permissions = []Permission{}
+	}
+	if notes == nil {
+		notes = []string{}
+	}
+
+	return Success(map[string]interface{}{
+		"dry_run":     true,
+		"executed":    false,
+		"tool":        tool,
+		"statements":  statements,
+		"permissions": permissions,
+		"notes":       notes,
+	}, map[string]interface{}{
+		"dry_run": true,
+	})
+}
+
+// estimateStatementRows estimates the rows of stmt, returning an empty
+// source when the statement can be neither explained nor looked up
+func estimateStatementRows(ctx context.Context, db *database.Database, stmt *PlannedStatement) (int64, string, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	if stmt.rowsOf != "" {
+		var reltuples *float64
+		err := db.QueryRow(queryCtx, "SELECT c.reltuples::float8 FROM pg_class c WHERE c.oid = to_regclass($1)", stmt.rowsOf).Scan(&reltuples)
+		if err == pgx.ErrNoRows {
+			return 0, "", fmt.Errorf("relation %s does not exist", stmt.rowsOf)
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		if reltuples == nil || *reltuples < 0 {
+			return 0, "", fmt.Errorf("relation %s has not been analyzed", stmt.rowsOf)
+		}
+		return int64(*reltuples), "statistics", nil
+	}
+
+	if !explainable(stmt.SQL) {
+		return 0, "", nil
+	}
+	var plan []byte
+	if err := db.QueryRow(queryCtx, "EXPLAIN (FORMAT JSON) "+stmt.SQL, stmt.Params...).Scan(&plan); err != nil {
+		return 0, "", err
+	}
+	rows, err := estimatedRowsFromPlan(plan)
+	if err != nil {
+		return 0, "", err
+	}
+	return rows, "explain", nil
+}
+
+// explainable reports whether EXPLAIN can plan sql without running it
+func explainable(sql string) bool {
+	fields := strings.Fields(strings.ToUpper(sql))
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.TrimLeft(fields[0], "(") {
+	case "SELECT", "WITH", "VALUES", "INSERT", "UPDATE", "DELETE", "MERGE":
+		return true
+	case "CREATE":
+		// CREATE TABLE ... AS SELECT
+		for _, f := range fields {
+			if f == "AS" {
+				return len(fields) > 1 && fields[1] == "TABLE"
+			}
+		}
+	}
+	return false
+}
+
+// estimatedRowsFromPlan reads the row estimate from EXPLAIN (FORMAT JSON)
+// output. For INSERT, UPDATE and DELETE the top node is ModifyTable, which
+// reports no rows without RETURNING, so the estimate of its input is used.
+func estimatedRowsFromPlan(raw []byte) (int64, error) {
+	var explained []struct {
+		Plan map[string]interface{} `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &explained); err != nil {
+		return 0, fmt.Errorf("unexpected EXPLAIN output: %w", err)
+	}
+	if len(explained) == 0 || explained[0].Plan == nil {
+		return 0, fmt.Errorf("EXPLAIN returned no plan")
+	}
+	node := explained[0].Plan
+	if node["Node Type"] == "ModifyTable" {
+		if children, ok := node["Plans"].([]interface{}); ok && len(children) > 0 {
+			if child, ok := children[0].(map[string]interface{}); ok {
+				node = child
+			}
+		}
+	}
+	rows, ok := node["Plan Rows"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("EXPLAIN plan has no row estimate")
+	}
+	return int64(rows), nil
+}
+
+// checkPermission checks a permission against the current role. A nil
+// result means the object does not exist.
+func checkPermission(ctx context.Context, db *database.Database, p Permission) (*bool, error) {
+	var query string
+	var args []interface{}
+	switch {
+	case p.ObjectType == "table" && p.Privilege == "OWNER":
+		query = "SELECT pg_has_role(c.relowner, 'USAGE') FROM pg_class c WHERE c.oid = to_regclass($1)"
+		args = []interface{}{p.Object}
+	case p.ObjectType == "table":
+		query = "SELECT CASE WHEN to_regclass($1) IS NULL THEN NULL ELSE has_table_privilege(to_regclass($1), $2) END"
+		args = []interface{}{p.Object, p.Privilege}
+	case p.ObjectType == "schema":
+		query = "SELECT CASE WHEN to_regnamespace(s.name) IS NULL THEN NULL ELSE has_schema_privilege(s.name, $2) END FROM (SELECT COALESCE(NULLIF($1, ''), current_schema()) AS name) s"
+		args = []interface{}{p.Object, p.Privilege}
+	case p.ObjectType == "function":
+		schema, name := "", p.Object
+		if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+			schema, name = name[:dot], name[dot+1:]
+		}
+		query = "SELECT bool_and(has_function_privilege(p.oid, 'EXECUTE')) FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE p.proname = $1 AND ($2 = '' OR n.nspname = $2)"
+		args = []interface{}{name, schema}
+	default:
+		return nil, fmt.Errorf("unknown object type %q", p.ObjectType)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	var granted *bool
+	if err := db.QueryRow(queryCtx, query, args...).Scan(&granted); err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	return granted, nil
+}
+
+func joinNote(note, more string) string {
+	if note == "" {
+		return more
+	}
+	return note + "; " + more
+}
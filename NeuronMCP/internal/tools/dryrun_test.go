@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEstimatedRowsFromPlan(t *testing.T) {
+	del := `[{"Plan": {"Node Type": "ModifyTable", "Operation": "Delete", "Plan Rows": 0,
+		"Plans": [{"Node Type": "Seq Scan", "Plan Rows": 42}]}}]`
+	if rows, err := estimatedRowsFromPlan([]byte(del)); err != nil || rows != 42 {
+		t.Errorf("delete estimate = %d, %v; want 42", rows, err)
+	}
+
+	sel := `[{"Plan": {"Node Type": "Limit", "Plan Rows": 7}}]`
+	if rows, err := estimatedRowsFromPlan([]byte(sel)); err != nil || rows != 7 {
+		t.Errorf("select estimate = %d, %v; want 7", rows, err)
+	}
+
+	if _, err := estimatedRowsFromPlan([]byte(`[]`)); err == nil {
+		t.Error("empty EXPLAIN output accepted")
+	}
+}
+
+func TestExplainable(t *testing.T) {
+	for sql, want := range map[string]bool{
+		"DELETE FROM t WHERE id = $1":              true,
+		"UPDATE t SET v = f(x)":                    true,
+		"CREATE TABLE out AS SELECT 1":             true,
+		"CREATE TABLE IF NOT EXISTS t (id int)":    false,
+		"DROP INDEX IF EXISTS idx":                 false,
+		"ALTER TABLE t ADD COLUMN v sparse_vector": false,
+	} {
+		if got := explainable(sql); got != want {
+			t.Errorf("explainable(%q) = %v, want %v", sql, got, want)
+		}
+	}
+}
+
+func TestDryRunResultWithoutDatabase(t *testing.T) {
+	rows := int64(3)
+	result := dryRunResult(context.Background(), nil, "ingest_document", []PlannedStatement{
+		{SQL: "INSERT INTO docs VALUES ($1)", Params: []interface{}{"x"}, EstimatedRows: &rows},
+		{SQL: "DELETE FROM docs"},
+	}, []Permission{tablePermission("INSERT", "docs")})
+	if !result.Success {
+		t.Fatalf("dry run failed: %+v", result.Error)
+	}
+
+	data := result.Data.(map[string]interface{})
+	if data["executed"] != false {
+		t.Error("plan reports execution")
+	}
+	statements := data["statements"].([]PlannedStatement)
+	if statements[0].EstimateSource != "tool" || statements[1].EstimatedRows != nil {
+		t.Errorf("statements = %+v", statements)
+	}
+	if perms := data["permissions"].([]Permission); perms[0].Granted != nil {
+		t.Error("permission checked without a database")
+	}
+	if notes := data["notes"].([]string); len(notes) != 1 {
+		t.Errorf("notes = %v", notes)
+	}
+}
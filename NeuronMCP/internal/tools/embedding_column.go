@@ -0,0 +1,732 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// manage_embedding_column limits
+const (
+	defaultEmbeddingColumnBatchSize = 64
+	maxEmbeddingColumnBatchSize     = 1000
+	defaultEmbeddingColumnMaxRows   = 10000
+	maxEmbeddingColumnMaxRows       = 1000000
+)
+
+// How an embedding column is kept in sync with its source column
+const (
+	// EmbeddingSyncTrigger embeds inserted and changed rows in a trigger
+	EmbeddingSyncTrigger = "trigger"
+	// EmbeddingSyncJob leaves it to a periodic backfill job
+	EmbeddingSyncJob = "job"
+	// EmbeddingSyncNone removes the sync trigger
+	EmbeddingSyncNone = "none"
+)
+
+// embeddingSyncFunction is the trigger function keeping embedding columns in
+// sync, created in the schema of the table. The trigger passes it the source
+// column, the embedding column and the model.
+const embeddingSyncFunction = "neurondb_sync_embedding"
+
+// ManageEmbeddingColumnTool adds, alters and backfills the vector column
+// holding the embeddings of a text column, and keeps it in sync
+type ManageEmbeddingColumnTool struct {
+	*BaseTool
+	db       *database.Database
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewManageEmbeddingColumnTool creates a new embedding column tool
+func NewManageEmbeddingColumnTool(db *database.Database, logger *logging.Logger) *ManageEmbeddingColumnTool {
+	return &ManageEmbeddingColumnTool{
+		BaseTool: NewBaseTool(
+			"manage_embedding_column",
+			"Manage the vector column holding the embeddings of a text column: add it sized to a model's dimension, alter it for another model, backfill it in batches with progress, and keep it in sync for new rows with a trigger or a documented job",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"add", "alter", "backfill", "sync"},
+						"default":     "add",
+						"description": "add creates the column (a no-op when it already fits the model), alter changes it to the model's dimension and clears its embeddings, backfill embeds rows without an embedding, sync only changes how the column is kept in sync",
+					},
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"column": map[string]interface{}{
+						"type":        "string",
+						"default":     "embedding",
+						"description": "Vector column holding the embeddings",
+					},
+					"source_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Column holding the text to embed; required to backfill or sync",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "Embedding model; defaults to the model of the column's sync trigger, or the default model",
+					},
+					"backfill": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "After add or alter, embed the rows without an embedding",
+					},
+					"sync": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{EmbeddingSyncTrigger, EmbeddingSyncJob, EmbeddingSyncNone},
+						"description": "trigger embeds inserted rows and rows whose source text changes as they are written; job removes the trigger and returns a backfill statement to schedule; none removes the trigger. Left unchanged when omitted, except for action sync, which defaults to trigger.",
+					},
+					"batch_size": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultEmbeddingColumnBatchSize,
+						"minimum":     1,
+						"maximum":     maxEmbeddingColumnBatchSize,
+						"description": "Rows embedded and committed together while backfilling",
+					},
+					"max_rows": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultEmbeddingColumnMaxRows,
+						"minimum":     1,
+						"maximum":     maxEmbeddingColumnMaxRows,
+						"description": "Embed at most this many rows per call; call again until remaining is 0",
+					},
+				},
+				"required": []interface{}{"table"},
+			},
+		),
+		db:       db,
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// embeddingColumn is the embedding column managed by a call
+type embeddingColumn struct {
+	table  pgx.Identifier
+	column string
+	source string
+}
+
+// embeddingColumnState is what the catalog says about the column
+type embeddingColumnState struct {
+	tableExists  bool
+	columnExists bool
+	columnType   string
+	// dimension is the declared dimension of a vector column, 0 if none
+	dimension    int
+	sourceExists bool
+	// trigger is the sync trigger of the column, nil when it has none
+	trigger *embeddingSyncTrigger
+}
+
+// embeddingSyncTrigger is an installed sync trigger
+type embeddingSyncTrigger struct {
+	source string
+	model  string
+}
+
+// embeddingBackfill reports what a backfill did
+type embeddingBackfill struct {
+	Embedded  int     `json:"embedded"`
+	Batches   int     `json:"batches"`
+	Remaining int64   `json:"remaining"`
+	EmbedMs   float64 `json:"embed_ms"`
+	WriteMs   float64 `json:"write_ms"`
+}
+
+// Execute manages the embedding column
+func (t *ManageEmbeddingColumnTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for manage_embedding_column tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+		}), nil
+	}
+
+	tableName, _ := params["table"].(string)
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table for manage_embedding_column tool: table='%s', error=%v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"table":     tableName,
+		}), nil
+	}
+	target := embeddingColumn{
+		table:  table,
+		column: stringParam(params, "column", "embedding"),
+		source: stringParam(params, "source_column", ""),
+	}
+	action := stringParam(params, "action", "add")
+	backfill, _ := params["backfill"].(bool)
+	sync := stringParam(params, "sync", "")
+	if action == "sync" && sync == "" {
+		sync = EmbeddingSyncTrigger
+	}
+	batchSize, errResult := intParamInRange(params, "batch_size", defaultEmbeddingColumnBatchSize, 1, maxEmbeddingColumnBatchSize)
+	if errResult != nil {
+		return errResult, nil
+	}
+	maxRows, errResult := intParamInRange(params, "max_rows", defaultEmbeddingColumnMaxRows, 1, maxEmbeddingColumnMaxRows)
+	if errResult != nil {
+		return errResult, nil
+	}
+	if errResult := validateEmbeddingColumnParams(target, action, backfill, sync); errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for manage_embedding_column tool", "DATABASE_ERROR", nil), nil
+	}
+
+	state, err := inspectEmbeddingColumn(ctx, db, target)
+	if err != nil {
+		return Error(fmt.Sprintf("Failed to inspect table %s: %v", table.Sanitize(), err), "QUERY_ERROR", map[string]interface{}{
+			"table": tableName,
+			"error": err.Error(),
+		}), nil
+	}
+	if errResult := checkEmbeddingColumnState(target, state, action); errResult != nil {
+		return errResult, nil
+	}
+
+	model := stringParam(params, "model", "")
+	if model == "" && state.trigger != nil {
+		model = state.trigger.model
+	}
+	if model == "" {
+		model = "default"
+	}
+	// Removing the trigger is the one change that needs no model
+	dimension := state.dimension
+	if action != "sync" || sync != EmbeddingSyncNone {
+		probe := ProbeModel(ctx, t.executor, ModelTarget{Name: model, Kind: ModelKindEmbedding})
+		if !probe.Available {
+			return Error(fmt.Sprintf("Embedding model '%s' is not available: %s", model, probe.Error), "EMBEDDING_ERROR", map[string]interface{}{
+				"model": model,
+				"error": probe.Error,
+			}), nil
+		}
+		dimension = probe.Dimensions
+		if action != "alter" && state.dimension != 0 && state.dimension != dimension {
+			return Error(fmt.Sprintf("column '%s' of %s holds vectors of dimension %d, but model '%s' embeds to %d; use action alter to change it",
+				target.column, table.Sanitize(), state.dimension, model, dimension), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "model",
+				"model":     model,
+			}), nil
+		}
+	}
+
+	statements := embeddingColumnDDL(target, state, action, sync, model, dimension)
+	doBackfill := action == "backfill" || backfill
+
+	if IsDryRun(ctx) {
+		return t.planEmbeddingColumn(ctx, db, target, statements, action, sync, doBackfill, model, batchSize, maxRows), nil
+	}
+
+	started := time.Now()
+	if len(statements) > 0 {
+		if err := execEmbeddingColumnDDL(ctx, db, statements); err != nil {
+			t.logger.Error("Embedding column change failed", err, map[string]interface{}{
+				"table":  tableName,
+				"column": target.column,
+				"action": action,
+			})
+			return Error(fmt.Sprintf("Failed to %s embedding column: table='%s', column='%s', error=%v", action, tableName, target.column, err), "DATABASE_ERROR", map[string]interface{}{
+				"table":  tableName,
+				"column": target.column,
+				"error":  err.Error(),
+			}), nil
+		}
+	}
+
+	result := map[string]interface{}{
+		"table":      table.Sanitize(),
+		"column":     target.column,
+		"action":     action,
+		"model":      model,
+		"dimension":  dimension,
+		"statements": statements,
+	}
+	if target.source != "" {
+		result["source_column"] = target.source
+	}
+	if s := resultingEmbeddingSync(state, sync); s != "" {
+		result["sync"] = s
+	}
+	if sync == EmbeddingSyncJob {
+		result["job"] = embeddingBackfillJob(target, model, batchSize)
+	}
+
+	if doBackfill {
+		progress, err := backfillEmbeddingColumn(ctx, db, t.executor, target, model, batchSize, maxRows)
+		result["backfill"] = progress
+		if err != nil {
+			t.logger.Error("Embedding column backfill failed", err, map[string]interface{}{
+				"table":  tableName,
+				"column": target.column,
+			})
+			return Error(fmt.Sprintf("Embedding column backfill failed: table='%s', column='%s', error=%v. Committed batches are kept; call again to continue.", tableName, target.column, err), "EMBEDDING_ERROR", map[string]interface{}{
+				"table":     tableName,
+				"column":    target.column,
+				"committed": progress,
+				"error":     err.Error(),
+			}), nil
+		}
+	}
+	result["total_ms"] = msSince(started)
+
+	return Success(result, map[string]interface{}{
+		"model":      model,
+		"batch_size": batchSize,
+	}), nil
+}
+
+// validateEmbeddingColumnParams checks the parameters that depend on each
+// other
+func validateEmbeddingColumnParams(target embeddingColumn, action string, backfill bool, sync string) *ToolResult {
+	if target.source == "" && (action == "backfill" || backfill || sync == EmbeddingSyncTrigger || sync == EmbeddingSyncJob) {
+		return Error("source_column is required to backfill or sync an embedding column", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "source_column",
+		})
+	}
+	if target.source != "" && target.source == target.column {
+		return Error(fmt.Sprintf("source_column and column both name column '%s'", target.column), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "source_column",
+		})
+	}
+	if backfill && action != "add" && action != "alter" {
+		return Error("backfill only applies to actions add and alter; use action backfill", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "backfill",
+		})
+	}
+	return nil
+}
+
+// checkEmbeddingColumnState checks that the table is in a state the action
+// can work on
+func checkEmbeddingColumnState(target embeddingColumn, state embeddingColumnState, action string) *ToolResult {
+	table := target.table.Sanitize()
+	if !state.tableExists {
+		return Error(fmt.Sprintf("table %s does not exist", table), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		})
+	}
+	if target.source != "" && !state.sourceExists {
+		return Error(fmt.Sprintf("table %s has no column '%s'", table, target.source), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "source_column",
+		})
+	}
+	if state.columnExists && state.columnType != "vector" {
+		return Error(fmt.Sprintf("column '%s' of %s has type %s, not vector", target.column, table, state.columnType), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "column",
+		})
+	}
+	if action != "add" && !state.columnExists {
+		return Error(fmt.Sprintf("table %s has no column '%s'; add it with action add", table, target.column), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "column",
+		})
+	}
+	return nil
+}
+
+// inspectEmbeddingColumn reads the table's columns and sync trigger from the
+// catalog
+func inspectEmbeddingColumn(ctx context.Context, db *database.Database, target embeddingColumn) (embeddingColumnState, error) {
+	var state embeddingColumnState
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	table := target.table.Sanitize()
+	if err := db.QueryRow(queryCtx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&state.tableExists); err != nil {
+		return state, err
+	}
+	if !state.tableExists {
+		return state, nil
+	}
+
+	rows, err := db.Query(queryCtx, `SELECT a.attname, t.typname, a.atttypmod
+		FROM pg_attribute a JOIN pg_type t ON t.oid = a.atttypid
+		WHERE a.attrelid = to_regclass($1) AND a.attnum > 0 AND NOT a.attisdropped AND a.attname = ANY($2)`,
+		table, []string{target.column, target.source})
+	if err != nil {
+		return state, err
+	}
+	for rows.Next() {
+		var name, typ string
+		var typmod int
+		if err := rows.Scan(&name, &typ, &typmod); err != nil {
+			rows.Close()
+			return state, err
+		}
+		switch name {
+		case target.column:
+			state.columnExists, state.columnType = true, typ
+			if typmod > 0 {
+				state.dimension = typmod
+			}
+		case target.source:
+			state.sourceExists = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return state, err
+	}
+
+	var args string
+	err = db.QueryRow(queryCtx, `SELECT encode(tgargs, 'escape') FROM pg_trigger
+		WHERE tgrelid = to_regclass($1) AND tgname = $2 AND NOT tgisinternal`,
+		table, embeddingSyncTriggerName(target.column)).Scan(&args)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	state.trigger = parseEmbeddingSyncTriggerArgs(args)
+	return state, nil
+}
+
+// parseEmbeddingSyncTriggerArgs reads the source column and model from the
+// escaped tgargs of a sync trigger: NUL-terminated strings, shown as \000
+func parseEmbeddingSyncTriggerArgs(args string) *embeddingSyncTrigger {
+	parts := strings.Split(args, `\000`)
+	if len(parts) < 3 {
+		return &embeddingSyncTrigger{}
+	}
+	return &embeddingSyncTrigger{source: parts[0], model: parts[2]}
+}
+
+// embeddingSyncTriggerName is the name of the sync trigger of column, cut
+// to the 63 bytes of a PostgreSQL identifier
+func embeddingSyncTriggerName(column string) string {
+	name := "neurondb_sync_" + column
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// embeddingColumnDDL returns the statements changing the column and its
+// sync trigger, in order. Altering a column whose trigger is kept moves
+// the trigger to the new model.
+func embeddingColumnDDL(target embeddingColumn, state embeddingColumnState, action, sync, model string, dimension int) []string {
+	table := target.table.Sanitize()
+	column := pgx.Identifier{target.column}.Sanitize()
+	var statements []string
+	switch action {
+	case "add":
+		if !state.columnExists {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s vector(%d)", table, column, dimension))
+		}
+	case "alter":
+		// Embeddings of another model are meaningless in the new space
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE vector(%d) USING NULL", table, column, dimension))
+		if sync == "" && state.trigger != nil && state.trigger.source != "" {
+			kept := target
+			kept.source = state.trigger.source
+			statements = append(statements, embeddingSyncTriggerDDL(kept, model)...)
+		}
+	}
+
+	switch sync {
+	case EmbeddingSyncTrigger:
+		statements = append(statements, embeddingSyncTriggerDDL(target, model)...)
+	case EmbeddingSyncJob, EmbeddingSyncNone:
+		if state.trigger != nil {
+			statements = append(statements, fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s",
+				pgx.Identifier{embeddingSyncTriggerName(target.column)}.Sanitize(), table))
+		}
+	}
+	return statements
+}
+
+// embeddingSyncTriggerDDL installs the sync function and replaces the sync
+// trigger of the column. The trigger runs before inserts and before updates
+// of the source column; rows whose source text is unchanged keep their
+// embedding, and empty texts get none.
+func embeddingSyncTriggerDDL(target embeddingColumn, model string) []string {
+	function := embeddingSyncFunctionName(target.table)
+	trigger := pgx.Identifier{embeddingSyncTriggerName(target.column)}.Sanitize()
+	return []string{
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s() RETURNS trigger LANGUAGE plpgsql AS $sync$
+DECLARE
+	source_text text := to_jsonb(NEW) ->> TG_ARGV[0];
+BEGIN
+	IF TG_OP = 'UPDATE' AND source_text IS NOT DISTINCT FROM to_jsonb(OLD) ->> TG_ARGV[0] THEN
+		RETURN NEW;
+	END IF;
+	IF source_text IS NULL OR btrim(source_text) = '' THEN
+		NEW := jsonb_populate_record(NEW, jsonb_build_object(TG_ARGV[1], NULL));
+	ELSE
+		NEW := jsonb_populate_record(NEW, jsonb_build_object(TG_ARGV[1], embed_text(source_text, TG_ARGV[2])::text));
+	END IF;
+	RETURN NEW;
+END
+$sync$`, function),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", trigger, target.table.Sanitize()),
+		fmt.Sprintf("CREATE TRIGGER %s BEFORE INSERT OR UPDATE OF %s ON %s FOR EACH ROW EXECUTE FUNCTION %s(%s, %s, %s)",
+			trigger, pgx.Identifier{target.source}.Sanitize(), target.table.Sanitize(), function,
+			quoteLiteral(target.source), quoteLiteral(target.column), quoteLiteral(model)),
+	}
+}
+
+// embeddingSyncFunctionName is the sync function in the schema of table
+func embeddingSyncFunctionName(table pgx.Identifier) string {
+	if schema := schemaOf(table); schema != "" {
+		return pgx.Identifier{schema, embeddingSyncFunction}.Sanitize()
+	}
+	return pgx.Identifier{embeddingSyncFunction}.Sanitize()
+}
+
+// resultingEmbeddingSync is how the column is kept in sync after the call
+func resultingEmbeddingSync(state embeddingColumnState, sync string) string {
+	if sync != "" {
+		return sync
+	}
+	if state.trigger != nil {
+		return EmbeddingSyncTrigger
+	}
+	return ""
+}
+
+// embeddingBackfillJob documents a job keeping the column in sync without a
+// trigger: one statement embedding up to batchSize rows, to run periodically
+func embeddingBackfillJob(target embeddingColumn, model string, batchSize int) map[string]interface{} {
+	table := target.table.Sanitize()
+	column := pgx.Identifier{target.column}.Sanitize()
+	source := pgx.Identifier{target.source}.Sanitize()
+	statement := fmt.Sprintf("UPDATE %s SET %s = embed_text(%s::text, %s) WHERE ctid IN (SELECT ctid FROM %s WHERE %s IS NULL AND btrim(%s::text) <> '' LIMIT %d)",
+		table, column, source, quoteLiteral(model), table, column, source, batchSize)
+	return map[string]interface{}{
+		"sql":      statement,
+		"pg_cron":  fmt.Sprintf("SELECT cron.schedule(%s, '* * * * *', $job$%s$job$)", quoteLiteral(embeddingSyncTriggerName(target.column)), statement),
+		"schedule": "Run the statement periodically, for example every minute with pg_cron as shown, or call manage_embedding_column with action backfill. Each run embeds up to batch_size rows without an embedding. Rows whose source text changes keep their old embedding unless it is set to NULL in the same update.",
+	}
+}
+
+// execEmbeddingColumnDDL runs the statements in one transaction
+func execEmbeddingColumnDDL(ctx context.Context, db *database.Database, statements []string) error {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(queryCtx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(queryCtx)
+	for _, stmt := range statements {
+		if _, err := tx.Exec(queryCtx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(queryCtx)
+}
+
+// backfillSQL returns the statements of a backfill: counting the rows
+// without an embedding, selecting a batch of them ($1 rows) and writing the
+// embedding ($2) of one ($1). Rows are addressed by ctid, so tables without
+// a key can be backfilled; a row moved meanwhile is left for the next batch.
+func backfillSQL(target embeddingColumn) (count, selectBatch, update string) {
+	table := target.table.Sanitize()
+	column := pgx.Identifier{target.column}.Sanitize()
+	source := pgx.Identifier{target.source}.Sanitize()
+	pending := fmt.Sprintf("%s IS NULL AND btrim(%s::text) <> ''", column, source)
+	count = fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", table, pending)
+	selectBatch = fmt.Sprintf("SELECT ctid::text, %s::text FROM %s WHERE %s LIMIT $1", source, table, pending)
+	update = fmt.Sprintf("UPDATE %s SET %s = $2::vector WHERE ctid = $1::tid AND %s IS NULL", table, column, column)
+	return count, selectBatch, update
+}
+
+// backfillEmbeddingColumn embeds the rows without an embedding, committing
+// batchSize rows at a time, until none are left or maxRows were embedded.
+// Progress is reported after every batch.
+func backfillEmbeddingColumn(ctx context.Context, db *database.Database, executor *QueryExecutor, target embeddingColumn, model string, batchSize, maxRows int) (embeddingBackfill, error) {
+	var progress embeddingBackfill
+	countSQL, selectSQL, updateSQL := backfillSQL(target)
+
+	var pending int64
+	countCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	err := db.QueryRow(countCtx, countSQL).Scan(&pending)
+	cancel()
+	if err != nil {
+		return progress, fmt.Errorf("failed to count rows to embed: %w", err)
+	}
+	total := pending
+	if total > int64(maxRows) {
+		total = int64(maxRows)
+	}
+	progress.Remaining = pending
+	reportProgress(ctx, 0, float64(total), fmt.Sprintf("embedding %d rows of %s", total, target.table.Sanitize()))
+
+	var embedTime, writeTime time.Duration
+	defer func() {
+		progress.EmbedMs = float64(embedTime.Microseconds()) / 1000
+		progress.WriteMs = float64(writeTime.Microseconds()) / 1000
+	}()
+	for progress.Embedded < maxRows {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		limit := batchSize
+		if left := maxRows - progress.Embedded; left < limit {
+			limit = left
+		}
+
+		stageStart := time.Now()
+		ctids, texts, err := selectBackfillBatch(ctx, db, selectSQL, limit)
+		writeTime += time.Since(stageStart)
+		if err != nil {
+			return progress, fmt.Errorf("failed to read rows to embed: %w", err)
+		}
+		if len(ctids) == 0 {
+			break
+		}
+
+		stageStart = time.Now()
+		vectors, err := embedBatch(ctx, executor, model, texts)
+		embedTime += time.Since(stageStart)
+		if err != nil {
+			return progress, fmt.Errorf("failed to embed batch %d: %w", progress.Batches+1, err)
+		}
+
+		stageStart = time.Now()
+		updated, err := writeBackfillBatch(ctx, db, updateSQL, ctids, vectors)
+		writeTime += time.Since(stageStart)
+		if err != nil {
+			return progress, fmt.Errorf("failed to write batch %d: %w", progress.Batches+1, err)
+		}
+		progress.Embedded += updated
+		progress.Batches++
+		progress.Remaining = max(pending-int64(progress.Embedded), 0)
+		reportProgress(ctx, float64(progress.Embedded), float64(total), fmt.Sprintf("embedded %d of %d rows", progress.Embedded, total))
+		if updated == 0 {
+			// Every row of the batch was changed meanwhile; stop rather
+			// than select the same rows again
+			break
+		}
+	}
+	return progress, nil
+}
+
+// selectBackfillBatch reads up to limit rows without an embedding
+func selectBackfillBatch(ctx context.Context, db *database.Database, selectSQL string, limit int) ([]string, []string, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.Query(queryCtx, selectSQL, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var ctids, texts []string
+	for rows.Next() {
+		var ctid, text string
+		if err := rows.Scan(&ctid, &text); err != nil {
+			return nil, nil, err
+		}
+		ctids = append(ctids, ctid)
+		texts = append(texts, text)
+	}
+	return ctids, texts, rows.Err()
+}
+
+// writeBackfillBatch writes a batch of embeddings in one transaction and
+// returns how many rows were written
+func writeBackfillBatch(ctx context.Context, db *database.Database, updateSQL string, ctids []string, vectors [][]float32) (int, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for i, ctid := range ctids {
+		batch.Queue(updateSQL, ctid, formatFloat32Vector(vectors[i]))
+	}
+	results := tx.SendBatch(ctx, batch)
+	updated := 0
+	for range ctids {
+		tag, err := results.Exec()
+		if err != nil {
+			results.Close()
+			return 0, err
+		}
+		updated += int(tag.RowsAffected())
+	}
+	if err := results.Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return updated, nil
+}
+
+// planEmbeddingColumn returns the dry run plan of a call. The catalog was
+// read and the model probed, so the plan has the column's dimension; no row
+// is embedded.
+func (t *ManageEmbeddingColumnTool) planEmbeddingColumn(ctx context.Context, db *database.Database, target embeddingColumn, ddl []string, action, sync string, backfill bool, model string, batchSize, maxRows int) *ToolResult {
+	table := target.table.Sanitize()
+	var statements []PlannedStatement
+	var permissions []Permission
+	zero := int64(0)
+	for _, stmt := range ddl {
+		statements = append(statements, PlannedStatement{SQL: stmt, EstimatedRows: &zero})
+	}
+	if len(ddl) > 0 {
+		permissions = append(permissions, tablePermission("OWNER", table))
+	}
+	if sync == EmbeddingSyncTrigger {
+		permissions = append(permissions, schemaPermission("CREATE", schemaOf(target.table)), functionPermission("embed_text"))
+	}
+	var notes []string
+	if backfill {
+		countSQL, selectSQL, updateSQL := backfillSQL(target)
+		update := PlannedStatement{
+			SQL:    updateSQL,
+			Params: []interface{}{"(0,1)", nil},
+			Note:   fmt.Sprintf("runs once per row without an embedding, in transactions of up to %d rows, for at most %d rows; the embeddings are computed with model '%s' when the call runs", batchSize, maxRows, model),
+		}
+		var pending int64
+		queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+		if err := db.QueryRow(queryCtx, countSQL).Scan(&pending); err != nil {
+			update.Note = joinNote(update.Note, fmt.Sprintf("rows not counted: %v", err))
+		} else {
+			rows := min(pending, int64(maxRows))
+			update.EstimatedRows = &rows
+			notes = append(notes, fmt.Sprintf("%d rows have no embedding", pending))
+		}
+		cancel()
+		statements = append(statements,
+			PlannedStatement{
+				SQL:           selectSQL,
+				Params:        []interface{}{batchSize},
+				EstimatedRows: &zero,
+				Note:          "reads each batch of rows without an embedding",
+			},
+			update)
+		permissions = append(permissions, tablePermission("UPDATE", table), functionPermission("neurondb.embed_batch"))
+	}
+	notes = append([]string{fmt.Sprintf("action %s on column '%s' of %s with model '%s'", action, target.column, table, model)}, notes...)
+	if len(ddl) == 0 {
+		notes = append(notes, "the column and its sync trigger need no change")
+	}
+	return dryRunResult(ctx, db, t.Name(), statements, permissions, notes...)
+}
+
+// quoteLiteral quotes s as an SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
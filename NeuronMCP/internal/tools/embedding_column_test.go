@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestEmbeddingColumnDDL(t *testing.T) {
+	target := embeddingColumn{table: pgx.Identifier{"docs", "pages"}, column: "embedding", source: "body"}
+
+	added := embeddingColumnDDL(target, embeddingColumnState{tableExists: true}, "add", "", "m", 384)
+	if len(added) != 1 || added[0] != `ALTER TABLE "docs"."pages" ADD COLUMN "embedding" vector(384)` {
+		t.Errorf("add = %q", added)
+	}
+	existing := embeddingColumnState{tableExists: true, columnExists: true, columnType: "vector", dimension: 384}
+	if ddl := embeddingColumnDDL(target, existing, "add", "", "m", 384); len(ddl) != 0 {
+		t.Errorf("add of an existing column = %q, want no statements", ddl)
+	}
+
+	synced := embeddingColumnDDL(target, existing, "add", EmbeddingSyncTrigger, "m", 384)
+	if len(synced) != 3 {
+		t.Fatalf("add with trigger = %d statements, want 3", len(synced))
+	}
+	if !strings.HasPrefix(synced[0], `CREATE OR REPLACE FUNCTION "docs"."neurondb_sync_embedding"()`) {
+		t.Errorf("function = %q", synced[0])
+	}
+	if want := `CREATE TRIGGER "neurondb_sync_embedding" BEFORE INSERT OR UPDATE OF "body" ON "docs"."pages" FOR EACH ROW EXECUTE FUNCTION "docs"."neurondb_sync_embedding"('body', 'embedding', 'm')`; synced[2] != want {
+		t.Errorf("trigger = %q, want %q", synced[2], want)
+	}
+
+	// Altering keeps the installed trigger, moved to the new model
+	existing.trigger = &embeddingSyncTrigger{source: "body", model: "old"}
+	altered := embeddingColumnDDL(embeddingColumn{table: target.table, column: "embedding"}, existing, "alter", "", "new's", 768)
+	if len(altered) != 4 || altered[0] != `ALTER TABLE "docs"."pages" ALTER COLUMN "embedding" TYPE vector(768) USING NULL` {
+		t.Fatalf("alter = %q", altered)
+	}
+	if !strings.HasSuffix(altered[3], `('body', 'embedding', 'new''s')`) {
+		t.Errorf("alter trigger = %q", altered[3])
+	}
+
+	dropped := embeddingColumnDDL(target, existing, "sync", EmbeddingSyncNone, "m", 384)
+	if len(dropped) != 1 || dropped[0] != `DROP TRIGGER IF EXISTS "neurondb_sync_embedding" ON "docs"."pages"` {
+		t.Errorf("sync none = %q", dropped)
+	}
+}
+
+func TestEmbeddingSyncTrigger(t *testing.T) {
+	trigger := parseEmbeddingSyncTriggerArgs(`body\000embedding\000all-MiniLM-L6-v2\000`)
+	if trigger.source != "body" || trigger.model != "all-MiniLM-L6-v2" {
+		t.Errorf("trigger = %+v", trigger)
+	}
+	if name := embeddingSyncTriggerName(strings.Repeat("c", 60)); len(name) != 63 {
+		t.Errorf("trigger name has %d bytes, want 63", len(name))
+	}
+	if got := embeddingSyncFunctionName(pgx.Identifier{"pages"}); got != `"neurondb_sync_embedding"` {
+		t.Errorf("function name = %s", got)
+	}
+}
+
+func TestValidateEmbeddingColumnParams(t *testing.T) {
+	target := embeddingColumn{table: pgx.Identifier{"pages"}, column: "embedding"}
+	if errResult := validateEmbeddingColumnParams(target, "add", false, ""); errResult != nil {
+		t.Errorf("add without source: %v", errResult.Error)
+	}
+	for name, c := range map[string]struct {
+		source, action, sync string
+		backfill             bool
+	}{
+		"backfill without source": {action: "backfill"},
+		"trigger without source":  {action: "add", sync: EmbeddingSyncTrigger},
+		"source is the column":    {source: "embedding", action: "backfill"},
+		"backfill flag on sync":   {source: "body", action: "sync", backfill: true},
+	} {
+		target.source = c.source
+		if validateEmbeddingColumnParams(target, c.action, c.backfill, c.sync) == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReportProgress(t *testing.T) {
+	reportProgress(context.Background(), 1, 2, "no reporter")
+
+	var got []float64
+	ctx := WithProgress(context.Background(), func(progress, total float64, message string) {
+		got = append(got, progress, total)
+	})
+	reportProgress(ctx, 64, 100, "embedded 64 of 100 rows")
+	if len(got) != 2 || got[0] != 64 || got[1] != 100 {
+		t.Errorf("reported %v", got)
+	}
+}
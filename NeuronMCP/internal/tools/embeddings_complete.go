@@ -332,6 +332,13 @@ func (t *ConfigureEmbeddingModelTool) Execute(ctx context.Context, params map[st
 
 	query := "SELECT configure_embedding_model($1::text, $2::text) AS success"
 	queryParams := []interface{}{modelName, configJSON}
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, t.executor.database(ctx), t.Name(), []PlannedStatement{
+			{SQL: query, Params: queryParams, Note: "creates or replaces the stored configuration of the model"},
+		}, []Permission{
+			functionPermission("configure_embedding_model"),
+		}), nil
+	}
 
 	result, err := t.executor.ExecuteQueryOne(ctx, query, queryParams)
 	if err != nil {
@@ -509,6 +516,13 @@ func (t *DeleteEmbeddingModelConfigTool) Execute(ctx context.Context, params map
 
 	query := "SELECT delete_embedding_model_config($1::text) AS success"
 	queryParams := []interface{}{modelName}
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, t.executor.database(ctx), t.Name(), []PlannedStatement{
+			{SQL: query, Params: queryParams, Note: "deletes the stored configuration of the model"},
+		}, []Permission{
+			functionPermission("delete_embedding_model_config"),
+		}), nil
+	}
 
 	result, err := t.executor.ExecuteQueryOne(ctx, query, queryParams)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/neurondb/NeuronMCP/internal/database"
 )
 
@@ -19,26 +20,89 @@ const (
 	VectorSearchTimeout = 30 * time.Second
 )
 
-// QueryExecutor executes database queries for tools
+// Query shapes run as prepared statements, see database.StatementCache
+const (
+	statementVectorSearch = "vector_search"
+	statementEmbedText    = "embed_text"
+	statementEmbedBatch   = "embed_batch"
+)
+
+// QueryExecutor executes database queries for tools. Statements go through
+// the database's circuit breaker, and transient errors are retried with
+// jittered backoff. Calls routed to a MockDatabase are answered by it
+// instead.
 type QueryExecutor struct {
-	db *database.Database
+	db    *database.Database
+	retry database.RetryPolicy
 }
 
 // NewQueryExecutor creates a new query executor
 func NewQueryExecutor(db *database.Database) *QueryExecutor {
-	return &QueryExecutor{db: db}
+	return &QueryExecutor{db: db, retry: database.DefaultRetryPolicy}
 }
 
-// ExecuteVectorSearch executes a vector search query
-func (e *QueryExecutor) ExecuteVectorSearch(ctx context.Context, table, vectorColumn string, queryVector []interface{}, distanceMetric string, limit int, additionalColumns []interface{}) ([]map[string]interface{}, error) {
-	if e.db == nil {
-		return nil, fmt.Errorf("query executor database instance is nil: cannot execute vector search on table '%s', column '%s'", table, vectorColumn)
+type databaseKey struct{}
+
+// WithDatabase returns a context routing the tool call's queries to db
+// instead of the database the tool was created with
+func WithDatabase(ctx context.Context, db *database.Database) context.Context {
+	return context.WithValue(ctx, databaseKey{}, db)
+}
+
+// DatabaseFromContext returns the database a tool call was routed to, or
+// fallback if it was not routed
+func DatabaseFromContext(ctx context.Context, fallback *database.Database) *database.Database {
+	if db, ok := ctx.Value(databaseKey{}).(*database.Database); ok && db != nil {
+		return db
 	}
-	
-	if !e.db.IsConnected() {
-		return nil, fmt.Errorf("database connection not available: cannot execute vector search on table '%s', column '%s' (database connection pool is not initialized)", table, vectorColumn)
+	return fallback
+}
+
+// database returns the database queries made with ctx run on
+func (e *QueryExecutor) database(ctx context.Context) *database.Database {
+	return DatabaseFromContext(ctx, e.db)
+}
+
+// run calls attempt under the circuit breaker until it succeeds, fails with
+// an error that should not be retried, or runs out of attempts. Reads are
+// retried after any transient error. Other statements are retried only when
+// the error shows the statement never reached the server, since it may have
+// taken effect otherwise.
+func (e *QueryExecutor) run(ctx context.Context, query string, attempt func() error) error {
+	breaker := e.database(ctx).Breaker()
+	read := database.IsReadOnlyStatement(query)
+	for n := 1; ; n++ {
+		if err := breaker.Allow(); err != nil {
+			return err
+		}
+		err := attempt()
+		breaker.Record(err)
+		if err == nil {
+			return nil
+		}
+		if n >= e.retry.MaxAttempts || !database.IsTransient(err) || !(read || pgconn.SafeToRetry(err)) {
+			if n > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, n)
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(e.retry.Backoff(n)):
+		}
 	}
-	
+}
+
+// ExecuteVectorSearch executes a vector search query
+func (e *QueryExecutor) ExecuteVectorSearch(ctx context.Context, table, vectorColumn string, queryVector []interface{}, distanceMetric string, limit int, additionalColumns []interface{}) ([]map[string]interface{}, error) {
+	return e.ExecuteTunedVectorSearch(ctx, table, vectorColumn, queryVector, distanceMetric, limit, additionalColumns, nil)
+}
+
+// ExecuteTunedVectorSearch executes a vector search with per-query index
+// settings. A tuned search runs in its own transaction so its SET LOCAL
+// statements do not leak into other queries on the pooled connection.
+func (e *QueryExecutor) ExecuteTunedVectorSearch(ctx context.Context, table, vectorColumn string, queryVector []interface{}, distanceMetric string, limit int, additionalColumns []interface{}, tuning *database.SearchTuning) ([]map[string]interface{}, error) {
 	if table == "" {
 		return nil, fmt.Errorf("table name is required for vector search: table parameter is empty")
 	}
@@ -90,37 +154,68 @@ func (e *QueryExecutor) ExecuteVectorSearch(ctx context.Context, table, vectorCo
 		return nil, fmt.Errorf("limit %d exceeds maximum allowed value of 10000 for vector search on table '%s', column '%s'", limit, table, vectorColumn)
 	}
 
+	if err := tuning.Validate(limit); err != nil {
+		return nil, fmt.Errorf("invalid search tuning for vector search on table '%s', column '%s': %w", table, vectorColumn, err)
+	}
+
+	if mock := MockDatabaseFromContext(ctx); mock != nil {
+		return mock.VectorSearch(table, vectorColumn, vec, distanceMetric, limit, cols), nil
+	}
+
+	db := e.database(ctx)
+	if db == nil {
+		return nil, fmt.Errorf("query executor database instance is nil: cannot execute vector search on table '%s', column '%s'", table, vectorColumn)
+	}
+	if !db.IsConnected() {
+		return nil, fmt.Errorf("database connection not available: cannot execute vector search on table '%s', column '%s' (database connection pool is not initialized)", table, vectorColumn)
+	}
+
 	qb := &database.QueryBuilder{}
-	query, params := qb.VectorSearch(table, vectorColumn, vec, distanceMetric, limit, cols, nil)
+	query, params := qb.TunedVectorSearch(table, vectorColumn, vec, distanceMetric, limit, cols, nil, tuning)
+	settings := qb.SearchSettings(tuning)
 
 	// Create timeout context for vector search
 	queryCtx, cancel := context.WithTimeout(ctx, VectorSearchTimeout)
 	defer cancel()
 
-	rows, err := e.db.Query(queryCtx, query, params...)
+	var results []map[string]interface{}
+	err := e.run(queryCtx, query, func() error {
+		if len(settings) > 0 {
+			var err error
+			results, err = queryWithSettings(queryCtx, db, settings, query, params)
+			return err
+		}
+		rows, err := db.QueryPrepared(queryCtx, statementVectorSearch, query, params...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if results, err = scanRowsToMaps(queryCtx, rows); err != nil {
+			return fmt.Errorf("failed to scan vector search results: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
 		if queryCtx.Err() != nil {
 			return nil, fmt.Errorf("vector search timeout after %v: table='%s', vector_column='%s', distance_metric='%s', limit=%d, error=%w", VectorSearchTimeout, table, vectorColumn, distanceMetric, limit, queryCtx.Err())
 		}
 		return nil, fmt.Errorf("vector search execution failed: table='%s', vector_column='%s', distance_metric='%s', limit=%d, vector_dimension=%d, additional_columns=%v, error=%w", table, vectorColumn, distanceMetric, limit, len(vec), cols, err)
 	}
-	defer rows.Close()
-
-	results, err := scanRowsToMaps(rows)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan vector search results: table='%s', vector_column='%s', distance_metric='%s', limit=%d, error=%w", table, vectorColumn, distanceMetric, limit, err)
-	}
 
 	return results, nil
 }
 
 // ExecuteQuery executes a query and returns all rows
 func (e *QueryExecutor) ExecuteQuery(ctx context.Context, query string, params []interface{}) ([]map[string]interface{}, error) {
-	if e.db == nil {
+	if mock := MockDatabaseFromContext(ctx); mock != nil {
+		return mock.Query(query, params)
+	}
+	db := e.database(ctx)
+	if db == nil {
 		return nil, fmt.Errorf("query executor database instance is nil: cannot execute query '%s' with %d parameters", query, len(params))
 	}
 	
-	if !e.db.IsConnected() {
+	if !db.IsConnected() {
 		return nil, fmt.Errorf("database connection not available: cannot execute query '%s' with %d parameters (database connection pool is not initialized)", query, len(params))
 	}
 	
@@ -132,21 +227,86 @@ func (e *QueryExecutor) ExecuteQuery(ctx context.Context, query string, params [
 	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 	
-	rows, err := e.db.Query(queryCtx, query, params...)
+	var results []map[string]interface{}
+	var scanErr error
+	err := e.run(queryCtx, query, func() error {
+		scanErr = nil
+		rows, err := db.Query(queryCtx, query, params...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		results, scanErr = scanRowsToMaps(queryCtx, rows)
+		return scanErr
+	})
 	if err != nil {
 		if queryCtx.Err() != nil {
 			return nil, fmt.Errorf("query timeout after %v: query='%s', parameter_count=%d, error=%w", DefaultQueryTimeout, query, len(params), queryCtx.Err())
 		}
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan query results: query='%s', parameter_count=%d, error=%w", query, len(params), err)
+		}
 		return nil, fmt.Errorf("query execution failed: query='%s', parameter_count=%d, parameters=%v, error=%w", query, len(params), params, err)
 	}
-	defer rows.Close()
 
-	results, err := scanRowsToMaps(rows)
+	return results, nil
+}
+
+// StreamQuery executes a query and calls fn for each row as it is read, so
+// large results are never held in memory. The query goes through the circuit
+// breaker but is not retried, since fn may already have consumed rows. An
+// error from fn stops the query and is returned.
+func (e *QueryExecutor) StreamQuery(ctx context.Context, query string, params []interface{}, fn func(row map[string]interface{}) error) error {
+	if mock := MockDatabaseFromContext(ctx); mock != nil {
+		rows, err := mock.Query(query, params)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	db := e.database(ctx)
+	if db == nil {
+		return fmt.Errorf("query executor database instance is nil: cannot stream query '%s' with %d parameters", query, len(params))
+	}
+	if !db.IsConnected() {
+		return fmt.Errorf("database connection not available: cannot stream query '%s' with %d parameters (database connection pool is not initialized)", query, len(params))
+	}
+
+	breaker := db.Breaker()
+	if err := breaker.Allow(); err != nil {
+		return err
+	}
+	rows, err := db.Query(ctx, query, params...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan query results: query='%s', parameter_count=%d, error=%w", query, len(params), err)
+		breaker.Record(err)
+		return fmt.Errorf("query execution failed: query='%s', parameter_count=%d, error=%w", query, len(params), err)
 	}
+	defer rows.Close()
 
-	return results, nil
+	rowNum := 0
+	for rows.Next() {
+		rowNum++
+		row, err := scanRowToMap(ctx, rows)
+		if err != nil {
+			breaker.Record(nil)
+			return fmt.Errorf("failed to scan row %d: query='%s', error=%w", rowNum, query, err)
+		}
+		if err := fn(row); err != nil {
+			breaker.Record(nil)
+			return err
+		}
+	}
+	err = rows.Err()
+	breaker.Record(err)
+	if err != nil {
+		return fmt.Errorf("error while streaming rows: query='%s', rows_read=%d, error=%w", query, rowNum, err)
+	}
+	return nil
 }
 
 // ExecuteQueryOne executes a query and returns a single row
@@ -156,11 +316,27 @@ func (e *QueryExecutor) ExecuteQueryOne(ctx context.Context, query string, param
 
 // ExecuteQueryOneWithTimeout executes a query with a specific timeout
 func (e *QueryExecutor) ExecuteQueryOneWithTimeout(ctx context.Context, query string, params []interface{}, timeout time.Duration) (map[string]interface{}, error) {
-	if e.db == nil {
+	return e.queryOne(ctx, "", query, params, timeout)
+}
+
+// ExecutePreparedQueryOne executes a single-row query as the prepared
+// statement of shape, for queries tools run often
+func (e *QueryExecutor) ExecutePreparedQueryOne(ctx context.Context, shape, query string, params []interface{}, timeout time.Duration) (map[string]interface{}, error) {
+	return e.queryOne(ctx, shape, query, params, timeout)
+}
+
+// queryOne executes a single-row query, as a prepared statement when shape
+// is set
+func (e *QueryExecutor) queryOne(ctx context.Context, shape, query string, params []interface{}, timeout time.Duration) (map[string]interface{}, error) {
+	if mock := MockDatabaseFromContext(ctx); mock != nil {
+		return mock.QueryOne(query, params)
+	}
+	db := e.database(ctx)
+	if db == nil {
 		return nil, fmt.Errorf("query executor database instance is nil: cannot execute single-row query '%s' with %d parameters", query, len(params))
 	}
 	
-	if !e.db.IsConnected() {
+	if !db.IsConnected() {
 		return nil, fmt.Errorf("database connection not available: cannot execute single-row query '%s' with %d parameters (database connection pool is not initialized)", query, len(params))
 	}
 	
@@ -172,23 +348,45 @@ func (e *QueryExecutor) ExecuteQueryOneWithTimeout(ctx context.Context, query st
 	queryCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	
-	rows, err := e.db.Query(queryCtx, query, params...)
-	if err != nil {
-		return nil, fmt.Errorf("single-row query execution failed: query='%s', parameter_count=%d, parameters=%v, error=%w", query, len(params), params, err)
-	}
-	defer rows.Close()
+	var result map[string]interface{}
+	var rowErr error // result shape errors, reported as they are
+	err := e.run(queryCtx, query, func() error {
+		var rows pgx.Rows
+		var err error
+		if shape != "" {
+			rows, err = db.QueryPrepared(queryCtx, shape, query, params...)
+		} else {
+			rows, err = db.Query(queryCtx, query, params...)
+		}
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
 
-	if !rows.Next() {
-		return nil, fmt.Errorf("no rows returned from single-row query: query='%s', parameter_count=%d, parameters=%v (expected exactly one row)", query, len(params), params)
-	}
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			rowErr = fmt.Errorf("no rows returned from single-row query: query='%s', parameter_count=%d, parameters=%v (expected exactly one row)", query, len(params), params)
+			return rowErr
+		}
 
-	result, err := scanRowToMap(rows)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan single row result: query='%s', parameter_count=%d, error=%w", query, len(params), err)
-	}
+		if result, err = scanRowToMap(queryCtx, rows); err != nil {
+			rowErr = fmt.Errorf("failed to scan single row result: query='%s', parameter_count=%d, error=%w", query, len(params), err)
+			return rowErr
+		}
 
-	if rows.Next() {
-		return nil, fmt.Errorf("multiple rows returned from single-row query: query='%s', parameter_count=%d, parameters=%v (expected exactly one row, got at least two)", query, len(params), params)
+		if rows.Next() {
+			rowErr = fmt.Errorf("multiple rows returned from single-row query: query='%s', parameter_count=%d, parameters=%v (expected exactly one row, got at least two)", query, len(params), params)
+			return rowErr
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		if rowErr != nil {
+			return nil, rowErr
+		}
+		return nil, fmt.Errorf("single-row query execution failed: query='%s', parameter_count=%d, parameters=%v, error=%w", query, len(params), params, err)
 	}
 
 	// Check if context was cancelled (timeout)
@@ -201,11 +399,16 @@ func (e *QueryExecutor) ExecuteQueryOneWithTimeout(ctx context.Context, query st
 
 // Exec executes a query without returning rows (for DDL statements)
 func (e *QueryExecutor) Exec(ctx context.Context, query string, params []interface{}) error {
-	if e.db == nil {
+	if MockDatabaseFromContext(ctx) != nil {
+		// Statements without a result are discarded in mock mode
+		return nil
+	}
+	db := e.database(ctx)
+	if db == nil {
 		return fmt.Errorf("query executor database instance is nil: cannot execute DDL query '%s' with %d parameters", query, len(params))
 	}
 	
-	if !e.db.IsConnected() {
+	if !db.IsConnected() {
 		return fmt.Errorf("database connection not available: cannot execute DDL query '%s' with %d parameters (database connection pool is not initialized)", query, len(params))
 	}
 	
@@ -213,21 +416,52 @@ func (e *QueryExecutor) Exec(ctx context.Context, query string, params []interfa
 		return fmt.Errorf("query string is empty: cannot execute empty DDL query")
 	}
 	
-	_, err := e.db.Exec(ctx, query, params...)
+	err := e.run(ctx, query, func() error {
+		_, err := db.Exec(ctx, query, params...)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("DDL query execution failed: query='%s', parameter_count=%d, parameters=%v, error=%w", query, len(params), params, err)
 	}
 	return nil
 }
 
-// scanRowsToMaps scans all rows into maps
-func scanRowsToMaps(rows pgx.Rows) ([]map[string]interface{}, error) {
+// queryWithSettings runs the SET LOCAL statements and then the query in one
+// transaction. The transaction only reads, so it is rolled back either way.
+func queryWithSettings(ctx context.Context, db *database.Database, settings []string, query string, params []interface{}) ([]map[string]interface{}, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, setting := range settings {
+		if _, err := tx.Exec(ctx, setting); err != nil {
+			return nil, fmt.Errorf("failed to apply '%s': %w", setting, err)
+		}
+	}
+	rows, err := tx.Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results, err := scanRowsToMaps(ctx, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan vector search results: %w", err)
+	}
+	return results, nil
+}
+
+// scanRowsToMaps scans all rows into maps. When the call asked for a
+// tabular result format, the column order is recorded in ctx.
+func scanRowsToMaps(ctx context.Context, rows pgx.Rows) ([]map[string]interface{}, error) {
+	recordResultColumns(ctx, rows.FieldDescriptions())
 	var results []map[string]interface{}
 	rowNum := 0
 
 	for rows.Next() {
 		rowNum++
-		row, err := scanRowToMap(rows)
+		row, err := scanRowToMap(ctx, rows)
 		if err != nil {
 			fieldDescs := rows.FieldDescriptions()
 			fieldNames := make([]string, len(fieldDescs))
@@ -246,8 +480,9 @@ func scanRowsToMaps(rows pgx.Rows) ([]map[string]interface{}, error) {
 	return results, nil
 }
 
-// scanRowToMap scans a single row into a map
-func scanRowToMap(rows pgx.Rows) (map[string]interface{}, error) {
+// scanRowToMap scans a single row into a map, post-processing its values
+// with the result options of ctx
+func scanRowToMap(ctx context.Context, rows pgx.Rows) (map[string]interface{}, error) {
 	fieldDescriptions := rows.FieldDescriptions()
 	if len(fieldDescriptions) == 0 {
 		return nil, fmt.Errorf("row has no columns: cannot scan empty result set")
@@ -266,9 +501,13 @@ func scanRowToMap(rows pgx.Rows) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to scan row values: columns=%v, error=%w", fieldNames, err)
 	}
 
+	opts, formatted := resultOptionsFromContext(ctx)
 	result := make(map[string]interface{})
 	for i, desc := range fieldDescriptions {
 		val := values[i]
+		if formatted {
+			val = opts.formatValue(desc.DataTypeOID, val)
+		}
 		// Handle byte arrays (JSON, text, etc.)
 		if bytes, ok := val.([]byte); ok {
 			// Try to parse as JSON
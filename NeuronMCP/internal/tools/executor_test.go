@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+func TestQueryExecutorRun(t *testing.T) {
+	e := NewQueryExecutor(database.NewDatabase())
+	e.retry = database.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	serialization := &pgconn.PgError{Code: "40001"}
+
+	// Reads are retried after a transient error
+	calls := 0
+	err := e.run(context.Background(), "SELECT 1", func() error {
+		calls++
+		if calls < 3 {
+			return serialization
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("read: err = %v, calls = %d", err, calls)
+	}
+
+	// Writes that may have reached the server are not
+	calls = 0
+	err = e.run(context.Background(), "UPDATE docs SET x = 1", func() error {
+		calls++
+		return serialization
+	})
+	if !errors.Is(err, serialization) || calls != 1 {
+		t.Errorf("write: err = %v, calls = %d", err, calls)
+	}
+
+	// Attempts are capped and reported
+	calls = 0
+	err = e.run(context.Background(), "SELECT 1", func() error {
+		calls++
+		return serialization
+	})
+	if calls != 3 || err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("exhausted: err = %v, calls = %d", err, calls)
+	}
+}
@@ -0,0 +1,449 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// rowEstimateTolerance is how far estimated and actual rows of the scan may
+// diverge before the tool suggests refreshing statistics
+const rowEstimateTolerance = 10.0
+
+// ExplainVectorSearchTool explains the plan of a vector search query
+type ExplainVectorSearchTool struct {
+	*BaseTool
+	db       *database.Database
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewExplainVectorSearchTool creates a new explain vector search tool
+func NewExplainVectorSearchTool(db *database.Database, logger *logging.Logger) *ExplainVectorSearchTool {
+	return &ExplainVectorSearchTool{
+		BaseTool: NewBaseTool(
+			"explain_vector_search",
+			"Run EXPLAIN (ANALYZE, BUFFERS) on the SQL vector_search would execute and report whether an HNSW/IVF index was used, estimated vs actual rows, buffers and timing",
+			map[string]interface{}{
+				"type": "object",
+				"properties": withSearchTuning(map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name containing vectors",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the vector column",
+					},
+					"query_vector": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "number"},
+						"description": "Query vector for similarity search",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     10,
+						"minimum":     1,
+						"maximum":     1000,
+						"description": "Maximum number of results",
+					},
+					"distance_metric": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"l2", "cosine", "inner_product", "l1", "hamming", "chebyshev", "minkowski"},
+						"default":     "l2",
+						"description": "Distance metric to use",
+					},
+					"additional_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Additional columns to return in results",
+					},
+					"analyze": map[string]interface{}{
+						"type":        "boolean",
+						"default":     true,
+						"description": "Execute the query to collect actual rows, timing and buffers; false only plans it",
+					},
+					"include_plan": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Include the raw JSON plan in the result",
+					},
+				}),
+				"required": []interface{}{"table", "vector_column", "query_vector"},
+			},
+		),
+		db:       db,
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute explains the vector search
+func (t *ExplainVectorSearchTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for explain_vector_search tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	table, _ := params["table"].(string)
+	vectorColumn, _ := params["vector_column"].(string)
+	queryVector, _ := params["query_vector"].([]interface{})
+	limit := 10
+	if l, ok := params["limit"].(float64); ok {
+		limit = int(l)
+	}
+	distanceMetric := "l2"
+	if dm, ok := params["distance_metric"].(string); ok {
+		distanceMetric = dm
+	}
+	analyze := true
+	if a, ok := params["analyze"].(bool); ok {
+		analyze = a
+	}
+	includePlan, _ := params["include_plan"].(bool)
+
+	vec := make([]float32, 0, len(queryVector))
+	for i, v := range queryVector {
+		f, ok := v.(float64)
+		if !ok {
+			return Error(fmt.Sprintf("query_vector element %d must be a number, got %T", i, v), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "query_vector",
+				"index":     i,
+			}), nil
+		}
+		vec = append(vec, float32(f))
+	}
+	var cols []string
+	if ac, ok := params["additional_columns"].([]interface{}); ok {
+		for i, c := range ac {
+			col, ok := c.(string)
+			if !ok || col == "" {
+				return Error(fmt.Sprintf("additional_columns element %d must be a non-empty string", i), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "additional_columns",
+					"index":     i,
+				}), nil
+			}
+			cols = append(cols, col)
+		}
+	}
+	if len(vec) == 0 {
+		return Error(fmt.Sprintf("query_vector parameter is required and cannot be empty for explain_vector_search tool on table '%s', column '%s'", table, vectorColumn), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "query_vector",
+		}), nil
+	}
+
+	tuning, err := searchTuningParam(params, limit)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid search tuning for explain_vector_search tool on table '%s': %v", table, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": table,
+		}), nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for explain_vector_search", "DATABASE_ERROR", map[string]interface{}{
+			"table": table,
+		}), nil
+	}
+
+	qb := &database.QueryBuilder{}
+	searchQuery, queryParams := qb.TunedVectorSearch(table, vectorColumn, vec, distanceMetric, limit, cols, nil, tuning)
+	options := "FORMAT JSON"
+	if analyze {
+		options = "ANALYZE, BUFFERS, FORMAT JSON"
+	}
+	explainQuery := fmt.Sprintf("EXPLAIN (%s) %s", options, searchQuery)
+
+	queryCtx, cancel := context.WithTimeout(ctx, VectorSearchTimeout)
+	defer cancel()
+
+	rawPlan, err := t.explain(queryCtx, db, qb.SearchSettings(tuning), explainQuery, queryParams)
+	if err != nil {
+		t.logger.Error("Explain vector search failed", err, params)
+		return Error(fmt.Sprintf("EXPLAIN failed for vector search: table='%s', vector_column='%s', distance_metric='%s', limit=%d, error=%v", table, vectorColumn, distanceMetric, limit, err), "EXPLAIN_ERROR", map[string]interface{}{
+			"table":           table,
+			"vector_column":   vectorColumn,
+			"distance_metric": distanceMetric,
+			"query":           searchQuery,
+			"error":           err.Error(),
+		}), nil
+	}
+
+	summary, err := summarizeExplainPlan([]byte(rawPlan))
+	if err != nil {
+		return Error(fmt.Sprintf("Failed to parse EXPLAIN output: %v", err), "EXPLAIN_ERROR", map[string]interface{}{
+			"query": searchQuery,
+		}), nil
+	}
+
+	indexes, err := t.tableIndexes(queryCtx, table)
+	if err != nil {
+		// The plan is still useful without index details
+		t.logger.Warn("Failed to list table indexes", map[string]interface{}{
+			"table": table,
+			"error": err.Error(),
+		})
+	}
+	summary.classifyIndexes(indexes)
+
+	result := map[string]interface{}{
+		"query":             searchQuery,
+		"analyzed":          analyze,
+		"index_used":        summary.IndexName != "",
+		"index_name":        summary.IndexName,
+		"index_type":        summary.IndexType,
+		"vector_index_used": summary.VectorIndexUsed,
+		"scan_type":         summary.ScanType,
+		"estimated_rows":    summary.EstimatedRows,
+		"planning_time_ms":  summary.PlanningTime,
+		"total_cost":        summary.TotalCost,
+		"nodes":             summary.Nodes,
+		"vector_indexes":    summary.vectorIndexes(indexes),
+		"suggestions":       summary.suggestions(analyze, indexes, distanceMetric),
+	}
+	if analyze {
+		result["actual_rows"] = summary.ActualRows
+		result["execution_time_ms"] = summary.ExecutionTime
+		result["buffers"] = map[string]interface{}{
+			"shared_hit":  summary.SharedHit,
+			"shared_read": summary.SharedRead,
+		}
+	}
+	if includePlan {
+		var plan interface{}
+		json.Unmarshal([]byte(rawPlan), &plan)
+		result["plan"] = plan
+	}
+
+	return Success(result, searchTuningMetadata(map[string]interface{}{
+		"table":           table,
+		"vector_column":   vectorColumn,
+		"distance_metric": distanceMetric,
+		"limit":           limit,
+	}, tuning)), nil
+}
+
+// explain runs the EXPLAIN statement, after the search settings when there
+// are any. The settings are local to a transaction that is rolled back.
+func (t *ExplainVectorSearchTool) explain(ctx context.Context, db *database.Database, settings []string, explainQuery string, params []interface{}) (string, error) {
+	var rawPlan string
+	if len(settings) == 0 {
+		err := db.QueryRow(ctx, explainQuery, params...).Scan(&rawPlan)
+		return rawPlan, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+	for _, setting := range settings {
+		if _, err := tx.Exec(ctx, setting); err != nil {
+			return "", fmt.Errorf("failed to apply '%s': %w", setting, err)
+		}
+	}
+	err = tx.QueryRow(ctx, explainQuery, params...).Scan(&rawPlan)
+	return rawPlan, err
+}
+
+// indexInfo describes an index on the searched table
+type indexInfo struct {
+	Name       string `json:"name"`
+	Method     string `json:"method"`
+	Definition string `json:"definition"`
+}
+
+func (t *ExplainVectorSearchTool) tableIndexes(ctx context.Context, table string) ([]indexInfo, error) {
+	db := DatabaseFromContext(ctx, t.db)
+	rows, err := db.Query(ctx, `
+		SELECT ic.relname, am.amname, pg_get_indexdef(i.indexrelid)
+		FROM pg_index i
+		JOIN pg_class ic ON ic.oid = i.indexrelid
+		JOIN pg_am am ON am.oid = ic.relam
+		WHERE i.indrelid = to_regclass($1)
+		ORDER BY ic.relname`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []indexInfo
+	for rows.Next() {
+		var idx indexInfo
+		if err := rows.Scan(&idx.Name, &idx.Method, &idx.Definition); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+// planNode is a flattened node of an EXPLAIN plan
+type planNode struct {
+	Depth         int     `json:"depth"`
+	NodeType      string  `json:"node_type"`
+	Relation      string  `json:"relation,omitempty"`
+	IndexName     string  `json:"index_name,omitempty"`
+	EstimatedRows float64 `json:"estimated_rows"`
+	ActualRows    float64 `json:"actual_rows,omitempty"` // per loop
+	Loops         float64 `json:"loops,omitempty"`
+	TotalTimeMs   float64 `json:"total_time_ms,omitempty"`
+	TotalCost     float64 `json:"total_cost"`
+}
+
+// planSummary is the part of an EXPLAIN plan relevant to a vector search
+type planSummary struct {
+	Nodes           []planNode
+	ScanType        string
+	IndexName       string
+	IndexType       string
+	VectorIndexUsed bool
+	EstimatedRows   float64
+	ActualRows      float64
+	PlanningTime    float64
+	ExecutionTime   float64
+	TotalCost       float64
+	SharedHit       float64
+	SharedRead      float64
+}
+
+// summarizeExplainPlan parses EXPLAIN (FORMAT JSON) output and picks the scan
+// node reading the searched table
+func summarizeExplainPlan(raw []byte) (*planSummary, error) {
+	var explained []map[string]interface{}
+	if err := json.Unmarshal(raw, &explained); err != nil {
+		return nil, err
+	}
+	if len(explained) == 0 {
+		return nil, fmt.Errorf("empty plan")
+	}
+	root, ok := explained[0]["Plan"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("plan has no root node")
+	}
+
+	summary := &planSummary{
+		PlanningTime:  planFloat(explained[0], "Planning Time"),
+		ExecutionTime: planFloat(explained[0], "Execution Time"),
+		TotalCost:     planFloat(root, "Total Cost"),
+		SharedHit:     planFloat(root, "Shared Hit Blocks"),
+		SharedRead:    planFloat(root, "Shared Read Blocks"),
+	}
+
+	var walk func(node map[string]interface{}, depth int)
+	walk = func(node map[string]interface{}, depth int) {
+		n := planNode{
+			Depth:         depth,
+			NodeType:      planString(node, "Node Type"),
+			Relation:      planString(node, "Relation Name"),
+			IndexName:     planString(node, "Index Name"),
+			EstimatedRows: planFloat(node, "Plan Rows"),
+			ActualRows:    planFloat(node, "Actual Rows"),
+			Loops:         planFloat(node, "Actual Loops"),
+			TotalTimeMs:   planFloat(node, "Actual Total Time"),
+			TotalCost:     planFloat(node, "Total Cost"),
+		}
+		summary.Nodes = append(summary.Nodes, n)
+
+		// The first scan of a relation is the one reading the searched table
+		if summary.ScanType == "" && n.Relation != "" {
+			summary.ScanType = n.NodeType
+			summary.IndexName = n.IndexName
+			summary.EstimatedRows = n.EstimatedRows
+			loops := n.Loops
+			if loops == 0 {
+				loops = 1
+			}
+			summary.ActualRows = n.ActualRows * loops
+		}
+
+		if children, ok := node["Plans"].([]interface{}); ok {
+			for _, child := range children {
+				if c, ok := child.(map[string]interface{}); ok {
+					walk(c, depth+1)
+				}
+			}
+		}
+	}
+	walk(root, 0)
+
+	return summary, nil
+}
+
+// classifyIndexes fills in the access method of the index used by the scan
+func (s *planSummary) classifyIndexes(indexes []indexInfo) {
+	if s.IndexName == "" {
+		return
+	}
+	for _, idx := range indexes {
+		if idx.Name == s.IndexName {
+			s.IndexType = idx.Method
+			s.VectorIndexUsed = isVectorIndexMethod(idx.Method)
+			return
+		}
+	}
+}
+
+func (s *planSummary) vectorIndexes(indexes []indexInfo) []indexInfo {
+	result := []indexInfo{}
+	for _, idx := range indexes {
+		if isVectorIndexMethod(idx.Method) {
+			result = append(result, idx)
+		}
+	}
+	return result
+}
+
+// suggestions explains likely causes of a slow search
+func (s *planSummary) suggestions(analyzed bool, indexes []indexInfo, distanceMetric string) []string {
+	suggestions := []string{}
+	vectorIndexes := s.vectorIndexes(indexes)
+
+	if !s.VectorIndexUsed {
+		if len(vectorIndexes) == 0 {
+			suggestions = append(suggestions, "No HNSW or IVF index exists on this table; the search scans every row. Create one with create_hnsw_index or create_ivf_index.")
+		} else {
+			switch distanceMetric {
+			case "l2", "cosine", "inner_product":
+				suggestions = append(suggestions, fmt.Sprintf("A vector index exists but was not used. Check that its operator class matches the %s distance, and that the table is large enough for the planner to prefer it (run ANALYZE after bulk loads).", distanceMetric))
+			default:
+				suggestions = append(suggestions, fmt.Sprintf("The %s distance is computed by a function, which vector indexes cannot serve; use l2, cosine or inner_product to benefit from an index.", distanceMetric))
+			}
+		}
+	}
+
+	if analyzed && s.EstimatedRows > 0 && s.ActualRows > 0 {
+		ratio := s.ActualRows / s.EstimatedRows
+		if ratio > rowEstimateTolerance || ratio < 1/rowEstimateTolerance {
+			suggestions = append(suggestions, fmt.Sprintf("The planner estimated %.0f rows for the scan but read %.0f; run ANALYZE on the table to refresh statistics.", s.EstimatedRows, s.ActualRows))
+		}
+	}
+
+	if analyzed && s.SharedRead > 0 && s.SharedRead > s.SharedHit {
+		suggestions = append(suggestions, "Most blocks were read from disk rather than shared buffers; repeated searches will be faster once the index is cached, or increase shared_buffers.")
+	}
+
+	return suggestions
+}
+
+func isVectorIndexMethod(method string) bool {
+	method = strings.ToLower(method)
+	return strings.Contains(method, "hnsw") || strings.Contains(method, "ivf")
+}
+
+func planFloat(node map[string]interface{}, key string) float64 {
+	f, _ := node[key].(float64)
+	return f
+}
+
+func planString(node map[string]interface{}, key string) string {
+	s, _ := node[key].(string)
+	return s
+}
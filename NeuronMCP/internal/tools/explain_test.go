@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+const hnswPlan = `[
+  {
+    "Plan": {
+      "Node Type": "Limit",
+      "Plan Rows": 10,
+      "Actual Rows": 10,
+      "Actual Loops": 1,
+      "Actual Total Time": 1.9,
+      "Total Cost": 52.1,
+      "Shared Hit Blocks": 120,
+      "Shared Read Blocks": 4,
+      "Plans": [
+        {
+          "Node Type": "Index Scan",
+          "Relation Name": "documents",
+          "Index Name": "documents_embedding_hnsw",
+          "Plan Rows": 5000,
+          "Actual Rows": 10,
+          "Actual Loops": 1,
+          "Actual Total Time": 1.8,
+          "Total Cost": 26050.0
+        }
+      ]
+    },
+    "Planning Time": 0.21,
+    "Execution Time": 2.05
+  }
+]`
+
+const seqScanPlan = `[
+  {
+    "Plan": {
+      "Node Type": "Limit",
+      "Plan Rows": 10,
+      "Total Cost": 900.0,
+      "Plans": [
+        {
+          "Node Type": "Sort",
+          "Plan Rows": 20000,
+          "Total Cost": 890.0,
+          "Plans": [
+            {"Node Type": "Seq Scan", "Relation Name": "documents", "Plan Rows": 20000, "Total Cost": 500.0}
+          ]
+        }
+      ]
+    },
+    "Planning Time": 0.1
+  }
+]`
+
+func TestSummarizeExplainPlanIndexScan(t *testing.T) {
+	summary, err := summarizeExplainPlan([]byte(hnswPlan))
+	if err != nil {
+		t.Fatalf("summarizeExplainPlan() error = %v", err)
+	}
+	summary.classifyIndexes([]indexInfo{{Name: "documents_embedding_hnsw", Method: "hnsw"}})
+
+	if summary.ScanType != "Index Scan" || summary.IndexName != "documents_embedding_hnsw" {
+		t.Errorf("scan = %q on %q, want Index Scan on documents_embedding_hnsw", summary.ScanType, summary.IndexName)
+	}
+	if !summary.VectorIndexUsed || summary.IndexType != "hnsw" {
+		t.Errorf("VectorIndexUsed = %v, IndexType = %q", summary.VectorIndexUsed, summary.IndexType)
+	}
+	if summary.EstimatedRows != 5000 || summary.ActualRows != 10 {
+		t.Errorf("rows estimated=%v actual=%v", summary.EstimatedRows, summary.ActualRows)
+	}
+	if summary.ExecutionTime != 2.05 || summary.SharedHit != 120 {
+		t.Errorf("execution=%v shared_hit=%v", summary.ExecutionTime, summary.SharedHit)
+	}
+	if len(summary.Nodes) != 2 || summary.Nodes[1].Depth != 1 {
+		t.Errorf("nodes = %+v", summary.Nodes)
+	}
+
+	suggestions := summary.suggestions(true, []indexInfo{{Name: "documents_embedding_hnsw", Method: "hnsw"}}, "l2")
+	if len(suggestions) != 1 || !strings.Contains(suggestions[0], "ANALYZE") {
+		t.Errorf("suggestions = %v, want only the row estimate hint", suggestions)
+	}
+}
+
+func TestSummarizeExplainPlanSeqScan(t *testing.T) {
+	summary, err := summarizeExplainPlan([]byte(seqScanPlan))
+	if err != nil {
+		t.Fatalf("summarizeExplainPlan() error = %v", err)
+	}
+	summary.classifyIndexes(nil)
+
+	if summary.ScanType != "Seq Scan" || summary.IndexName != "" || summary.VectorIndexUsed {
+		t.Errorf("summary = %+v, want a sequential scan", summary)
+	}
+
+	noIndex := summary.suggestions(false, nil, "l2")
+	if len(noIndex) != 1 || !strings.Contains(noIndex[0], "create_hnsw_index") {
+		t.Errorf("suggestions without index = %v", noIndex)
+	}
+	unused := summary.suggestions(false, []indexInfo{{Name: "documents_ivf", Method: "ivfflat"}}, "l1")
+	if len(unused) != 1 || !strings.Contains(unused[0], "function") {
+		t.Errorf("suggestions with unusable metric = %v", unused)
+	}
+}
+
+func TestSummarizeExplainPlanInvalid(t *testing.T) {
+	if _, err := summarizeExplainPlan([]byte(`[]`)); err == nil {
+		t.Error("expected error for empty plan")
+	}
+	if _, err := summarizeExplainPlan([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
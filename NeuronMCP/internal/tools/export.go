@@ -0,0 +1,528 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+type exportDirKey struct{}
+
+// WithExportDir returns a context allowing export tools to write files in dir
+func WithExportDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, exportDirKey{}, dir)
+}
+
+// ExportDirFromContext returns the directory export tools may write to, if
+// file export is enabled
+func ExportDirFromContext(ctx context.Context) (string, bool) {
+	dir, ok := ctx.Value(exportDirKey{}).(string)
+	return dir, ok && dir != ""
+}
+
+// exportVectorAlias is the result column holding the exported vector as text
+const exportVectorAlias = "_export_vector"
+
+// npyPreambleLen is the size reserved for the .npy magic, version and header.
+// A fixed size lets a file export write the row count once all rows are in.
+const npyPreambleLen = 128
+
+// exportFormats lists the formats of export_vectors with their file extension
+var exportFormats = map[string]string{
+	"csv":   ".csv",
+	"jsonl": ".jsonl",
+	"fvecs": ".fvecs",
+	"npy":   ".npy",
+}
+
+// npyPreamble returns the .npy version 1.0 preamble for a rows x dim float32
+// matrix, padded to npyPreambleLen bytes
+func npyPreamble(rows, dim int) []byte {
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%d, %d), }", rows, dim)
+	headerLen := npyPreambleLen - 10
+	header += strings.Repeat(" ", headerLen-len(header)-1) + "\n"
+
+	preamble := make([]byte, 0, npyPreambleLen)
+	preamble = append(preamble, "\x93NUMPY\x01\x00"...)
+	preamble = binary.LittleEndian.AppendUint16(preamble, uint16(headerLen))
+	return append(preamble, header...)
+}
+
+// vectorEncoder writes exported rows in one of the export formats. csv and
+// jsonl carry the selected columns and the vector; fvecs and npy carry only
+// the vectors, in row order.
+type vectorEncoder struct {
+	format       string
+	columns      []string
+	vectorColumn string
+	w            io.Writer
+	csv          *csv.Writer
+	rows         int
+	dim          int
+}
+
+func newVectorEncoder(w io.Writer, format string, columns []string, vectorColumn string) *vectorEncoder {
+	e := &vectorEncoder{format: format, columns: columns, vectorColumn: vectorColumn, w: w, dim: -1}
+	if format == "csv" {
+		e.csv = csv.NewWriter(w)
+	}
+	return e
+}
+
+// writeHeader writes the CSV header row; other formats have none
+func (e *vectorEncoder) writeHeader() error {
+	if e.csv == nil {
+		return nil
+	}
+	return e.csv.Write(append(append([]string{}, e.columns...), e.vectorColumn))
+}
+
+// write encodes one row. npy requires every vector to have the same
+// dimension.
+func (e *vectorEncoder) write(row map[string]interface{}, vec []float32) error {
+	if e.format == "npy" && e.dim >= 0 && len(vec) != e.dim {
+		return fmt.Errorf("row %d has a %d-dimensional vector, expected %d: npy needs vectors of one dimension", e.rows+1, len(vec), e.dim)
+	}
+	if e.dim < 0 {
+		e.dim = len(vec)
+	}
+	e.rows++
+
+	switch e.format {
+	case "csv":
+		record := make([]string, 0, len(e.columns)+1)
+		for _, c := range e.columns {
+			record = append(record, csvValue(row[c]))
+		}
+		return e.csv.Write(append(record, formatFloat32Vector(vec)))
+	case "jsonl":
+		obj := make(map[string]interface{}, len(e.columns)+1)
+		for _, c := range e.columns {
+			obj[c] = row[c]
+		}
+		obj[e.vectorColumn] = vec
+		line, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to encode row %d: %w", e.rows, err)
+		}
+		_, err = e.w.Write(append(line, '\n'))
+		return err
+	case "fvecs":
+		buf := make([]byte, 0, 4+4*len(vec))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(vec)))
+		return e.writeFloats(buf, vec)
+	case "npy":
+		return e.writeFloats(make([]byte, 0, 4*len(vec)), vec)
+	}
+	return fmt.Errorf("unsupported export format '%s'", e.format)
+}
+
+func (e *vectorEncoder) writeFloats(buf []byte, vec []float32) error {
+	for _, f := range vec {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(f))
+	}
+	_, err := e.w.Write(buf)
+	return err
+}
+
+// flush writes any buffered CSV output
+func (e *vectorEncoder) flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// csvValue formats a column value for CSV: NULL as an empty field, and
+// structured values as JSON
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// buildExportQuery selects columns and the vector, as text, of every row with
+// a vector, ordered by orderBy or else by physical position. limit and offset
+// are bound as $1 and $2 when paged.
+func buildExportQuery(table pgx.Identifier, vectorColumn string, columns []string, orderBy string, paged bool) string {
+	selectList := make([]string, 0, len(columns)+1)
+	for _, c := range columns {
+		selectList = append(selectList, pgx.Identifier{c}.Sanitize())
+	}
+	vec := pgx.Identifier{vectorColumn}.Sanitize()
+	selectList = append(selectList, vec+"::text AS "+exportVectorAlias)
+
+	order := "ctid"
+	if orderBy != "" {
+		order = pgx.Identifier{orderBy}.Sanitize() + ", ctid"
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IS NOT NULL ORDER BY %s",
+		strings.Join(selectList, ", "), table.Sanitize(), vec, order)
+	if paged {
+		query += " LIMIT $1 OFFSET $2"
+	}
+	return query
+}
+
+// resolveExportPath returns the file path for name inside dir. name is
+// relative to dir and cannot leave it.
+func resolveExportPath(dir, name, format string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("path is required for file export")
+	}
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("path must be relative to the export directory, got '%s'", name)
+	}
+	clean := filepath.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path '%s' is outside the export directory", name)
+	}
+	if filepath.Ext(clean) == "" {
+		clean += exportFormats[format]
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// ExportVectorsTool exports a table's vectors and selected columns
+type ExportVectorsTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewExportVectorsTool creates a new vector export tool
+func NewExportVectorsTool(db *database.Database, logger *logging.Logger) *ExportVectorsTool {
+	return &ExportVectorsTool{
+		BaseTool: NewBaseTool(
+			"export_vectors",
+			"Export a table's vectors and selected columns as CSV, JSONL, fvecs or npy, either as base64 pages in the result or to a file in the server's export directory",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Vector column to export; rows where it is NULL are skipped",
+					},
+					"columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Other columns to export with each vector (csv and jsonl only)",
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"csv", "jsonl", "fvecs", "npy"},
+						"default":     "jsonl",
+						"description": "Output format. fvecs and npy hold only the vectors, as little-endian float32",
+					},
+					"order_by": map[string]interface{}{
+						"type":        "string",
+						"description": "Column giving a stable row order, such as the primary key; rows are in physical order when omitted",
+					},
+					"destination": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"inline", "file"},
+						"default":     "inline",
+						"description": "inline returns one page of rows as base64; file writes every row to path in the server's export directory",
+					},
+					"offset": map[string]interface{}{
+						"type":        "number",
+						"default":     0,
+						"minimum":     0,
+						"description": "Rows to skip (inline); pass next_offset from the previous page",
+					},
+					"page_size": map[string]interface{}{
+						"type":        "number",
+						"default":     1000,
+						"minimum":     1,
+						"maximum":     10000,
+						"description": "Rows per page (inline)",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File name relative to the export directory (file); the format's extension is added if missing",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace an existing file (file)",
+					},
+				},
+				"required": []interface{}{"table", "vector_column"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// exportRequest holds the parsed export_vectors parameters
+type exportRequest struct {
+	tableName    string
+	table        pgx.Identifier
+	vectorColumn string
+	columns      []string
+	format       string
+	orderBy      string
+}
+
+// Execute runs the export
+func (t *ExportVectorsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for export_vectors tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	req := exportRequest{
+		vectorColumn: stringParam(params, "vector_column", ""),
+		format:       stringParam(params, "format", "jsonl"),
+		orderBy:      stringParam(params, "order_by", ""),
+	}
+	req.tableName, _ = params["table"].(string)
+	table, err := parseQualifiedIdentifier(req.tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", req.tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	req.table = table
+	if cols, ok := params["columns"].([]interface{}); ok {
+		for i, c := range cols {
+			name, ok := c.(string)
+			if !ok || name == "" {
+				return Error(fmt.Sprintf("Column at index %d must be a non-empty string", i), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "columns",
+				}), nil
+			}
+			req.columns = append(req.columns, name)
+		}
+	}
+	if len(req.columns) > 0 && (req.format == "fvecs" || req.format == "npy") {
+		return Error(fmt.Sprintf("Format '%s' holds only vectors: columns are supported with csv and jsonl", req.format), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "columns",
+		}), nil
+	}
+
+	if stringParam(params, "destination", "inline") == "file" {
+		return t.exportFile(ctx, req, params)
+	}
+	return t.exportPage(ctx, req, params)
+}
+
+// exportPage returns one page of rows encoded as base64. Every page is a
+// complete file in the chosen format; for csv only the first page has a
+// header.
+func (t *ExportVectorsTool) exportPage(ctx context.Context, req exportRequest, params map[string]interface{}) (*ToolResult, error) {
+	offset, pageSize := 0, 1000
+	if v, ok := params["offset"].(float64); ok {
+		offset = int(v)
+	}
+	if v, ok := params["page_size"].(float64); ok {
+		pageSize = int(v)
+	}
+	if offset < 0 || pageSize < 1 || pageSize > 10000 {
+		return Error(fmt.Sprintf("offset must be >= 0 and page_size between 1 and 10000, got offset=%d, page_size=%d", offset, pageSize), "VALIDATION_ERROR", nil), nil
+	}
+
+	// One extra row tells whether another page follows
+	query := buildExportQuery(req.table, req.vectorColumn, req.columns, req.orderBy, true)
+	rows, err := t.executor.ExecuteQuery(ctx, query, []interface{}{pageSize + 1, offset})
+	if err != nil {
+		return t.exportError(req, err), nil
+	}
+	hasMore := len(rows) > pageSize
+	if hasMore {
+		rows = rows[:pageSize]
+	}
+
+	var buf bytes.Buffer
+	enc := newVectorEncoder(&buf, req.format, req.columns, req.vectorColumn)
+	if offset == 0 {
+		if err := enc.writeHeader(); err != nil {
+			return t.exportError(req, err), nil
+		}
+	}
+	for _, row := range rows {
+		if err := t.encodeRow(enc, row); err != nil {
+			return t.exportError(req, err), nil
+		}
+	}
+	if err := enc.flush(); err != nil {
+		return t.exportError(req, err), nil
+	}
+
+	data := buf.Bytes()
+	if req.format == "npy" {
+		data = append(npyPreamble(enc.rows, max(enc.dim, 0)), data...)
+	}
+
+	result := map[string]interface{}{
+		"format":      req.format,
+		"encoding":    "base64",
+		"data":        base64.StdEncoding.EncodeToString(data),
+		"rows":        enc.rows,
+		"offset":      offset,
+		"next_offset": nil,
+	}
+	if enc.dim >= 0 {
+		result["dimension"] = enc.dim
+	}
+	if hasMore {
+		result["next_offset"] = offset + enc.rows
+	}
+	return Success(result, map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"bytes":         len(data),
+	}), nil
+}
+
+// exportFile streams every row to a file in the export directory. A failed
+// export removes the partial file.
+func (t *ExportVectorsTool) exportFile(ctx context.Context, req exportRequest, params map[string]interface{}) (*ToolResult, error) {
+	dir, ok := ExportDirFromContext(ctx)
+	if !ok {
+		return Error("File export is disabled: set server.exportDir (or NEURONDB_MCP_EXPORT_DIR) to allow it, or use destination 'inline'", "EXPORT_DISABLED", nil), nil
+	}
+	path, err := resolveExportPath(dir, stringParam(params, "path", ""), req.format)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid export path: %v", err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "path",
+		}), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return t.exportError(req, fmt.Errorf("failed to create directory for %s: %w", path, err)), nil
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite, _ := params["overwrite"].(bool); overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return Error(fmt.Sprintf("Export file %s already exists: set overwrite to replace it", path), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "path",
+			}), nil
+		}
+		return t.exportError(req, fmt.Errorf("failed to create %s: %w", path, err)), nil
+	}
+
+	enc, size, err := t.writeExportFile(ctx, file, req)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return t.exportError(req, err), nil
+	}
+
+	t.logger.Info("Exported vectors", map[string]interface{}{
+		"table":  req.tableName,
+		"format": req.format,
+		"rows":   enc.rows,
+		"path":   path,
+	})
+	result := map[string]interface{}{
+		"format": req.format,
+		"path":   path,
+		"rows":   enc.rows,
+		"bytes":  size,
+	}
+	if enc.dim >= 0 {
+		result["dimension"] = enc.dim
+	}
+	return Success(result, map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+	}), nil
+}
+
+// writeExportFile writes every row to file and returns the encoder, which
+// holds the row count and dimension, and the file size
+func (t *ExportVectorsTool) writeExportFile(ctx context.Context, file *os.File, req exportRequest) (*vectorEncoder, int64, error) {
+	if req.format == "npy" {
+		// Reserved for the preamble, written once the row count is known
+		if _, err := file.Write(make([]byte, npyPreambleLen)); err != nil {
+			return nil, 0, err
+		}
+	}
+	w := bufio.NewWriter(file)
+	enc := newVectorEncoder(w, req.format, req.columns, req.vectorColumn)
+	if err := enc.writeHeader(); err != nil {
+		return nil, 0, err
+	}
+
+	query := buildExportQuery(req.table, req.vectorColumn, req.columns, req.orderBy, false)
+	if err := t.executor.StreamQuery(ctx, query, nil, func(row map[string]interface{}) error {
+		return t.encodeRow(enc, row)
+	}); err != nil {
+		return nil, 0, err
+	}
+	if err := enc.flush(); err != nil {
+		return nil, 0, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, 0, err
+	}
+	if req.format == "npy" {
+		if _, err := file.WriteAt(npyPreamble(enc.rows, max(enc.dim, 0)), 0); err != nil {
+			return nil, 0, err
+		}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	return enc, info.Size(), nil
+}
+
+// encodeRow parses the vector of a query row and writes the row
+func (t *ExportVectorsTool) encodeRow(enc *vectorEncoder, row map[string]interface{}) error {
+	text, ok := row[exportVectorAlias].(string)
+	if !ok {
+		return fmt.Errorf("row %d: vector column returned %T, expected text", enc.rows+1, row[exportVectorAlias])
+	}
+	vec, err := parseVectorText(text)
+	if err != nil {
+		return fmt.Errorf("row %d: %w", enc.rows+1, err)
+	}
+	return enc.write(row, vec)
+}
+
+func (t *ExportVectorsTool) exportError(req exportRequest, err error) *ToolResult {
+	t.logger.Error("Vector export failed", err, map[string]interface{}{"table": req.tableName, "format": req.format})
+	return Error(fmt.Sprintf("Vector export failed: table='%s', vector_column='%s', format='%s', error=%v", req.tableName, req.vectorColumn, req.format, err), "EXPORT_ERROR", map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"format":        req.format,
+		"error":         err.Error(),
+	})
+}
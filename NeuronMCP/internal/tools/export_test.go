@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestNPYPreamble(t *testing.T) {
+	preamble := npyPreamble(3, 4)
+	if len(preamble) != npyPreambleLen {
+		t.Fatalf("preamble is %d bytes, want %d", len(preamble), npyPreambleLen)
+	}
+	if !bytes.HasPrefix(preamble, []byte("\x93NUMPY\x01\x00")) {
+		t.Errorf("bad magic %q", preamble[:8])
+	}
+	if n := binary.LittleEndian.Uint16(preamble[8:10]); int(n) != npyPreambleLen-10 {
+		t.Errorf("header_len = %d", n)
+	}
+	header := string(preamble[10:])
+	if !bytes.Contains(preamble, []byte("'shape': (3, 4)")) || header[len(header)-1] != '\n' {
+		t.Errorf("header = %q", header)
+	}
+}
+
+func TestVectorEncoder(t *testing.T) {
+	row := map[string]interface{}{"id": int64(7), "title": "a,b"}
+
+	var buf bytes.Buffer
+	enc := newVectorEncoder(&buf, "csv", []string{"id", "title"}, "embedding")
+	if err := enc.writeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.write(row, []float32{1, 2.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,title,embedding\n7,\"a,b\",\"[1,2.5]\"\n"; buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	enc = newVectorEncoder(&buf, "jsonl", []string{"id"}, "embedding")
+	if err := enc.write(row, []float32{1, 2.5}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"embedding\":[1,2.5],\"id\":7}\n"; buf.String() != want {
+		t.Errorf("jsonl = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	enc = newVectorEncoder(&buf, "fvecs", nil, "embedding")
+	if err := enc.write(nil, []float32{1, -2}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if len(data) != 12 || binary.LittleEndian.Uint32(data) != 2 ||
+		math.Float32frombits(binary.LittleEndian.Uint32(data[8:])) != -2 {
+		t.Errorf("fvecs = %v", data)
+	}
+
+	buf.Reset()
+	enc = newVectorEncoder(&buf, "npy", nil, "embedding")
+	if err := enc.write(nil, []float32{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.write(nil, []float32{1, 2, 3}); err == nil {
+		t.Error("npy accepted vectors of different dimensions")
+	}
+	if enc.rows != 1 || enc.dim != 2 || buf.Len() != 8 {
+		t.Errorf("npy rows=%d dim=%d bytes=%d", enc.rows, enc.dim, buf.Len())
+	}
+}
+
+func TestBuildExportQuery(t *testing.T) {
+	table := pgx.Identifier{"public", "docs"}
+	got := buildExportQuery(table, "embedding", []string{"id"}, "id", true)
+	want := `SELECT "id", "embedding"::text AS _export_vector FROM "public"."docs" WHERE "embedding" IS NOT NULL ORDER BY "id", ctid LIMIT $1 OFFSET $2`
+	if got != want {
+		t.Errorf("query = %s", got)
+	}
+	got = buildExportQuery(table, "embedding", nil, "", false)
+	want = `SELECT "embedding"::text AS _export_vector FROM "public"."docs" WHERE "embedding" IS NOT NULL ORDER BY ctid`
+	if got != want {
+		t.Errorf("query = %s", got)
+	}
+}
+
+func TestResolveExportPath(t *testing.T) {
+	dir := filepath.FromSlash("/srv/exports")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"docs", "/srv/exports/docs.npy"},
+		{"runs/a.bin", "/srv/exports/runs/a.bin"},
+		{"runs/../docs.npy", "/srv/exports/docs.npy"},
+		{"", ""},
+		{"../etc/passwd", ""},
+		{"runs/../../x", ""},
+		{"/etc/passwd", ""},
+	}
+	for _, tt := range tests {
+		got, err := resolveExportPath(dir, tt.name, "npy")
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("resolveExportPath(%q) = %q, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != filepath.FromSlash(tt.want) {
+			t.Errorf("resolveExportPath(%q) = (%q, %v), want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+const (
+	// LocalFederationSource labels the results of the connected database
+	LocalFederationSource = "local"
+	// DefaultFederationTimeout bounds the search of each source
+	DefaultFederationTimeout = 10 * time.Second
+)
+
+// FederationRemote is a remote NeuronDB searched through dblink from the
+// connected database. Server names a foreign server of the connected
+// database, such as one created for postgres_fdw; dblink connects through
+// it with the current user's user mapping, so no credentials pass through
+// the tool.
+type FederationRemote struct {
+	// Name labels the remote's results
+	Name   string
+	Server string
+	// Table replaces the table of a call on this remote when set
+	Table string
+}
+
+// Federation configures federated_vector_search
+type Federation struct {
+	Remotes []FederationRemote
+	// Timeout bounds the search of each source; 0 uses
+	// DefaultFederationTimeout
+	Timeout time.Duration
+}
+
+type federationKey struct{}
+
+// WithFederation returns a context carrying the configured remotes of
+// federated_vector_search
+func WithFederation(ctx context.Context, federation Federation) context.Context {
+	return context.WithValue(ctx, federationKey{}, federation)
+}
+
+// FederationFromContext returns the configured remotes
+func FederationFromContext(ctx context.Context) Federation {
+	federation, _ := ctx.Value(federationKey{}).(Federation)
+	return federation
+}
+
+// federatedSimilarities converts the distance of each metric to a score
+// where higher is better, as multi_column_vector_search does
+var federatedSimilarities = map[string]func(distance float64) float64{
+	"l2":            func(d float64) float64 { return 1.0 / (1.0 + d) },
+	"cosine":        func(d float64) float64 { return 1.0 - d },
+	"inner_product": func(d float64) float64 { return -d },
+}
+
+// FederatedVectorSearchTool runs one vector search on the connected
+// database and on remote NeuronDB servers, and merges the results by score
+type FederatedVectorSearchTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewFederatedVectorSearchTool creates a new federated vector search tool
+func NewFederatedVectorSearchTool(db *database.Database, logger *logging.Logger) *FederatedVectorSearchTool {
+	return &FederatedVectorSearchTool{
+		BaseTool: NewBaseTool(
+			"federated_vector_search",
+			"Run a vector search on the connected database and on the remote NeuronDB servers configured in features.federation, reached through dblink, and merge the results by score with the source of each; per-source latency and errors are reported",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table to search, optionally schema-qualified; a remote may configure its own",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Vector column",
+					},
+					"query_vector": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "number"},
+						"description": "Query vector",
+					},
+					"distance_metric": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"l2", "cosine", "inner_product"},
+						"default":     "cosine",
+						"description": "Distance metric; results are merged by a score derived from it, 1 - distance for cosine, the inner product, and 1 / (1 + distance) for l2",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     10,
+						"minimum":     1,
+						"maximum":     1000,
+						"description": "Maximum number of merged results; each source returns up to this many",
+					},
+					"additional_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Columns to return; all columns when omitted",
+					},
+					"remotes": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Names of the configured remotes to search; all of them when omitted",
+					},
+					"include_local": map[string]interface{}{
+						"type":        "boolean",
+						"default":     true,
+						"description": "Search the connected database too, labelled 'local'",
+					},
+				},
+				"required": []interface{}{"table", "vector_column", "query_vector"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// federatedSearch is a validated federated search
+type federatedSearch struct {
+	tableName         string
+	vectorColumn      string
+	vector            string
+	metric            string
+	limit             int
+	additionalColumns []string
+	remotes           []FederationRemote
+	includeLocal      bool
+}
+
+// federatedHit is one result of a source
+type federatedHit struct {
+	Source   string                 `json:"source"`
+	Score    float64                `json:"score"`
+	Distance float64                `json:"distance"`
+	Row      map[string]interface{} `json:"row"`
+}
+
+// federatedSource reports the search of one source
+type federatedSource struct {
+	Source    string  `json:"source"`
+	Server    string  `json:"server,omitempty"`
+	Table     string  `json:"table"`
+	Count     int     `json:"count"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+
+	hits []federatedHit
+}
+
+// Execute executes the federated vector search
+func (t *FederatedVectorSearchTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for federated_vector_search tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+			"params": params,
+		}), nil
+	}
+	federation := FederationFromContext(ctx)
+	search, invalid := parseFederatedSearch(params, federation)
+	if invalid != nil {
+		return invalid, nil
+	}
+	timeout := federation.Timeout
+	if timeout <= 0 {
+		timeout = DefaultFederationTimeout
+	}
+
+	var sources []*federatedSource
+	if search.includeLocal {
+		sources = append(sources, &federatedSource{Source: LocalFederationSource, Table: search.tableName})
+	}
+	for _, remote := range search.remotes {
+		table := search.tableName
+		if remote.Table != "" {
+			table = remote.Table
+		}
+		sources = append(sources, &federatedSource{Source: remote.Name, Server: remote.Server, Table: table})
+	}
+
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source *federatedSource) {
+			defer wg.Done()
+			t.searchSource(ctx, search, source, timeout)
+		}(source)
+	}
+	wg.Wait()
+
+	var hits []federatedHit
+	failed := 0
+	for _, source := range sources {
+		if source.Error != "" {
+			failed++
+			t.logger.Warn("Federated vector search source failed", map[string]interface{}{
+				"source": source.Source,
+				"server": source.Server,
+				"error":  source.Error,
+			})
+			continue
+		}
+		hits = append(hits, source.hits...)
+	}
+	if failed == len(sources) {
+		return Error(fmt.Sprintf("Federated vector search failed on every source: table='%s', sources=%d", search.tableName, len(sources)), "SEARCH_ERROR", map[string]interface{}{
+			"sources": sources,
+		}), nil
+	}
+
+	hits = mergeFederatedHits(hits, search.limit)
+	return Success(map[string]interface{}{
+		"results": hits,
+		"count":   len(hits),
+		"sources": sources,
+	}, map[string]interface{}{
+		"count":           len(hits),
+		"table":           search.tableName,
+		"distance_metric": search.metric,
+		"sources":         len(sources),
+		"failed_sources":  failed,
+	}), nil
+}
+
+// searchSource runs the search on one source, recording its hits, latency
+// and error on source
+func (t *FederatedVectorSearchTool) searchSource(ctx context.Context, search federatedSearch, source *federatedSource, timeout time.Duration) {
+	table, err := parseQualifiedIdentifier(source.Table)
+	if err != nil {
+		source.Error = fmt.Sprintf("invalid table '%s': %v", source.Table, err)
+		return
+	}
+	inner := buildFederatedSearchSQL(search, table)
+	query, queryParams := inner, []interface{}(nil)
+	if source.Server != "" {
+		query, queryParams = buildDblinkSearch(source.Server, inner, timeout)
+	}
+
+	searchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	rows, err := t.executor.ExecuteQuery(searchCtx, query, queryParams)
+	source.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		if searchCtx.Err() == context.DeadlineExceeded {
+			source.Error = fmt.Sprintf("search timed out after %v", timeout)
+			return
+		}
+		source.Error = err.Error()
+		return
+	}
+
+	score := federatedSimilarities[search.metric]
+	for _, row := range rows {
+		distance, ok := row["distance"].(float64)
+		if !ok {
+			continue
+		}
+		values, _ := row["row"].(map[string]interface{})
+		source.hits = append(source.hits, federatedHit{
+			Source:   source.Source,
+			Score:    score(distance),
+			Distance: distance,
+			Row:      values,
+		})
+	}
+	source.Count = len(source.hits)
+}
+
+// parseFederatedSearch validates the search parameters against the
+// configured remotes, returning a validation error result when they are
+// unusable
+func parseFederatedSearch(params map[string]interface{}, federation Federation) (federatedSearch, *ToolResult) {
+	search := federatedSearch{
+		metric:       stringParam(params, "distance_metric", "cosine"),
+		limit:        10,
+		includeLocal: true,
+	}
+
+	search.tableName, _ = params["table"].(string)
+	if _, err := parseQualifiedIdentifier(search.tableName); err != nil {
+		return search, Error(fmt.Sprintf("Invalid table '%s': %v", search.tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		})
+	}
+
+	search.vectorColumn = stringParam(params, "vector_column", "")
+	if search.vectorColumn == "" {
+		return search, Error("vector_column must not be empty", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "vector_column",
+		})
+	}
+	vector, _ := params["query_vector"].([]interface{})
+	var err error
+	if search.vector, err = searchVectorParam(vector); err != nil {
+		return search, Error(fmt.Sprintf("Invalid query_vector: %v", err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "query_vector",
+		})
+	}
+	if _, ok := federatedSimilarities[search.metric]; !ok {
+		return search, Error(fmt.Sprintf("Unsupported distance_metric '%s': use l2, cosine or inner_product", search.metric), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "distance_metric",
+		})
+	}
+	if v, ok := params["limit"].(float64); ok {
+		search.limit = int(v)
+	}
+	if search.limit < 1 || search.limit > 1000 {
+		return search, Error(fmt.Sprintf("limit must be between 1 and 1000, got %d", search.limit), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "limit",
+		})
+	}
+	list, _ := params["additional_columns"].([]interface{})
+	for i, c := range list {
+		name, ok := c.(string)
+		if !ok || name == "" {
+			return search, Error(fmt.Sprintf("additional_columns at index %d must be a non-empty string", i), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "additional_columns",
+			})
+		}
+		search.additionalColumns = append(search.additionalColumns, name)
+	}
+	if v, ok := params["include_local"].(bool); ok {
+		search.includeLocal = v
+	}
+
+	if len(federation.Remotes) == 0 {
+		return search, Error("No remote servers are configured: add them to features.federation.remotes", "VALIDATION_ERROR", nil)
+	}
+	names, ok := params["remotes"].([]interface{})
+	if !ok {
+		search.remotes = federation.Remotes
+		return search, nil
+	}
+	configured := make(map[string]FederationRemote, len(federation.Remotes))
+	for _, remote := range federation.Remotes {
+		configured[remote.Name] = remote
+	}
+	seen := make(map[string]bool, len(names))
+	for i, v := range names {
+		name, _ := v.(string)
+		remote, ok := configured[name]
+		if !ok {
+			return search, Error(fmt.Sprintf("remotes at index %d is not a configured remote: '%v'", i, v), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "remotes",
+				"remotes":   federationRemoteNames(federation.Remotes),
+			})
+		}
+		if !seen[name] {
+			seen[name] = true
+			search.remotes = append(search.remotes, remote)
+		}
+	}
+	if len(search.remotes) == 0 && !search.includeLocal {
+		return search, Error("Nothing to search: name at least one remote or include_local", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "remotes",
+		})
+	}
+	return search, nil
+}
+
+func federationRemoteNames(remotes []FederationRemote) []string {
+	names := make([]string, len(remotes))
+	for i, remote := range remotes {
+		names[i] = remote.Name
+	}
+	return names
+}
+
+// buildFederatedSearchSQL builds the search one source runs. It is sent
+// to remotes as text, so the query vector and limit are inlined; the
+// vector literal holds only numbers. Each row has its distance and the
+// selected columns as a jsonb object, whose type is the same on every
+// source.
+func buildFederatedSearchSQL(search federatedSearch, table pgx.Identifier) string {
+	col := pgx.Identifier{search.vectorColumn}.Sanitize()
+	selected := "*"
+	if len(search.additionalColumns) > 0 {
+		cols := make([]string, len(search.additionalColumns))
+		for i, c := range search.additionalColumns {
+			cols[i] = pgx.Identifier{c}.Sanitize()
+		}
+		selected = strings.Join(cols, ", ")
+	}
+	return fmt.Sprintf(
+		"SELECT s.distance, to_jsonb(s) - 'distance' AS \"row\" FROM "+
+			"(SELECT %s, %s %s %s::vector AS distance FROM %s WHERE %s IS NOT NULL ORDER BY distance LIMIT %d) s",
+		selected, col, similarityJoinOperators[search.metric], quoteLiteral(search.vector),
+		table.Sanitize(), col, search.limit,
+	)
+}
+
+// buildDblinkSearch wraps a search to run on the foreign server through
+// dblink. The remote statement timeout ends the search there too when the
+// local one gives up.
+func buildDblinkSearch(server, search string, timeout time.Duration) (string, []interface{}) {
+	remote := fmt.Sprintf("SET statement_timeout = %d; %s", timeout.Milliseconds(), search)
+	return `SELECT r.distance, r."row" FROM dblink($1, $2) AS r(distance double precision, "row" jsonb)`,
+		[]interface{}{server, remote}
+}
+
+// mergeFederatedHits ranks the hits of every source by score and keeps the
+// limit best. Ties keep the order of the sources.
+func mergeFederatedHits(hits []federatedHit, limit int) []federatedHit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	if hits == nil {
+		hits = []federatedHit{}
+	}
+	return hits
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var testFederation = Federation{Remotes: []FederationRemote{
+	{Name: "eu", Server: "neurondb_eu"},
+	{Name: "us", Server: "neurondb_us", Table: "archive.docs"},
+}}
+
+func TestParseFederatedSearch(t *testing.T) {
+	search, invalid := parseFederatedSearch(map[string]interface{}{
+		"table":              "public.docs",
+		"vector_column":      "embedding",
+		"query_vector":       []interface{}{1.0, 2.5},
+		"distance_metric":    "l2",
+		"limit":              float64(5),
+		"additional_columns": []interface{}{"id", "title"},
+		"remotes":            []interface{}{"us", "us"},
+		"include_local":      false,
+	}, testFederation)
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if search.vector != "[1,2.5]" || search.limit != 5 || search.includeLocal {
+		t.Errorf("search = %+v", search)
+	}
+	if len(search.remotes) != 1 || search.remotes[0].Name != "us" {
+		t.Errorf("remotes = %+v, want us once", search.remotes)
+	}
+
+	search, invalid = parseFederatedSearch(map[string]interface{}{
+		"table":         "docs",
+		"vector_column": "embedding",
+		"query_vector":  []interface{}{1.0},
+	}, testFederation)
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if len(search.remotes) != 2 || !search.includeLocal || search.metric != "cosine" {
+		t.Errorf("defaults = %+v, want every remote, local and cosine", search)
+	}
+
+	for name, tc := range map[string]struct {
+		params     map[string]interface{}
+		federation Federation
+	}{
+		"unknown remote": {map[string]interface{}{"remotes": []interface{}{"apac"}}, testFederation},
+		"no remotes":     {map[string]interface{}{}, Federation{}},
+		"nothing":        {map[string]interface{}{"remotes": []interface{}{}, "include_local": false}, testFederation},
+		"metric":         {map[string]interface{}{"distance_metric": "hamming"}, testFederation},
+		"limit":          {map[string]interface{}{"limit": float64(0)}, testFederation},
+	} {
+		params := map[string]interface{}{
+			"table":         "docs",
+			"vector_column": "embedding",
+			"query_vector":  []interface{}{1.0},
+		}
+		for k, v := range tc.params {
+			params[k] = v
+		}
+		if _, invalid := parseFederatedSearch(params, tc.federation); invalid == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestBuildFederatedSearchSQL(t *testing.T) {
+	search := federatedSearch{
+		vectorColumn:      "embedding",
+		vector:            "[1,2]",
+		metric:            "inner_product",
+		limit:             3,
+		additionalColumns: []string{"id", "title"},
+	}
+	query := buildFederatedSearchSQL(search, pgx.Identifier{"archive", "docs"})
+	want := `SELECT s.distance, to_jsonb(s) - 'distance' AS "row" FROM ` +
+		`(SELECT "id", "title", "embedding" <#> '[1,2]'::vector AS distance FROM "archive"."docs" ` +
+		`WHERE "embedding" IS NOT NULL ORDER BY distance LIMIT 3) s`
+	if query != want {
+		t.Errorf("query =\n%s\nwant\n%s", query, want)
+	}
+
+	remote, params := buildDblinkSearch("neurondb_eu", query, 1500*time.Millisecond)
+	if !strings.Contains(remote, "FROM dblink($1, $2) AS r(distance double precision, \"row\" jsonb)") {
+		t.Errorf("dblink query = %s", remote)
+	}
+	if len(params) != 2 || params[0] != "neurondb_eu" || params[1] != "SET statement_timeout = 1500; "+query {
+		t.Errorf("dblink params = %v", params)
+	}
+}
+
+func TestMergeFederatedHits(t *testing.T) {
+	score := federatedSimilarities["l2"]
+	hits := []federatedHit{
+		{Source: "local", Score: score(2)},
+		{Source: "eu", Score: score(0.5)},
+		{Source: "us", Score: score(2)},
+		{Source: "us", Score: score(1)},
+	}
+	merged := mergeFederatedHits(hits, 3)
+	var sources []string
+	for _, hit := range merged {
+		sources = append(sources, hit.Source)
+	}
+	if got := strings.Join(sources, ","); got != "eu,us,local" {
+		t.Errorf("merged sources = %s, want eu,us,local", got)
+	}
+	if merged := mergeFederatedHits(nil, 3); merged == nil || len(merged) != 0 {
+		t.Errorf("merging no hits = %#v, want an empty list", merged)
+	}
+}
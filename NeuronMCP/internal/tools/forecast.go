@@ -0,0 +1,734 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Forecast limits, those of the NeuronDB ARIMA functions
+const (
+	defaultForecastHorizon = 10
+	maxForecastHorizon     = 1000
+	maxForecastSeasonality = 366
+	maxARIMAOrderP         = 10
+	maxARIMAOrderD         = 2
+	maxARIMAOrderQ         = 10
+	minARIMAObservations   = 10
+	// forecastSpacingWindow is how many recent observations the spacing of
+	// forecast times is measured over
+	forecastSpacingWindow = 100
+)
+
+// forecastInputView is the temporary view train_arima reads a series from.
+// train_arima quotes the table name it is given as a single identifier, so
+// it cannot read a schema-qualified table, a filtered series or a
+// seasonally differenced one directly.
+const forecastInputView = "neurondb_forecast_input"
+
+// forecastSeries is the time series of a table a forecast tool works on
+type forecastSeries struct {
+	table       pgx.Identifier
+	tableName   string
+	timeColumn  string
+	valueColumn string
+}
+
+// arimaOrder is the order of an ARIMA model and the seasonal period its
+// series is differenced at, 1 for none
+type arimaOrder struct {
+	p, d, q     int
+	seasonality int
+}
+
+// forecastSeriesProperties adds the series parameters to the properties of
+// a forecast tool schema
+func forecastSeriesProperties(properties map[string]interface{}) map[string]interface{} {
+	properties["table"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Table holding the time series, optionally schema-qualified",
+	}
+	properties["time_column"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Column ordering the observations, such as a timestamp, date or sequence number",
+	}
+	properties["value_column"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Numeric column holding the observed values",
+	}
+	properties["seasonality"] = map[string]interface{}{
+		"type":        "integer",
+		"default":     1,
+		"minimum":     1,
+		"maximum":     maxForecastSeasonality,
+		"description": "Seasonal period in observations, such as 7 for daily data with a weekly cycle; the series is differenced at this lag before the model is fitted. 1 for no seasonality",
+	}
+	return properties
+}
+
+// arimaOrderProperties adds the ARIMA order parameters to the properties of
+// a forecast tool schema
+func arimaOrderProperties(properties map[string]interface{}) map[string]interface{} {
+	properties["p"] = map[string]interface{}{
+		"type":        "integer",
+		"default":     1,
+		"minimum":     0,
+		"maximum":     maxARIMAOrderP,
+		"description": "Autoregressive order",
+	}
+	properties["d"] = map[string]interface{}{
+		"type":        "integer",
+		"default":     0,
+		"minimum":     0,
+		"maximum":     maxARIMAOrderD,
+		"description": "Differencing order",
+	}
+	properties["q"] = map[string]interface{}{
+		"type":        "integer",
+		"default":     1,
+		"minimum":     0,
+		"maximum":     maxARIMAOrderQ,
+		"description": "Moving average order",
+	}
+	return properties
+}
+
+// parseForecastSeries reads the series parameters. It returns nil, and no
+// error, when required is false and no table is given.
+func parseForecastSeries(params map[string]interface{}, toolName string, required bool) (*forecastSeries, *ToolResult) {
+	tableName := stringParam(params, "table", "")
+	if tableName == "" && !required {
+		return nil, nil
+	}
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return nil, Error(fmt.Sprintf("Invalid table '%s' for %s tool: %v", tableName, toolName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		})
+	}
+	series := &forecastSeries{
+		table:       table,
+		tableName:   tableName,
+		timeColumn:  stringParam(params, "time_column", ""),
+		valueColumn: stringParam(params, "value_column", ""),
+	}
+	for name, value := range map[string]string{"time_column": series.timeColumn, "value_column": series.valueColumn} {
+		if value == "" {
+			return nil, Error(fmt.Sprintf("%s parameter is required with table for %s tool", name, toolName), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": name,
+			})
+		}
+	}
+	return series, nil
+}
+
+// parseARIMAOrder reads the p, d, q and seasonality parameters
+func parseARIMAOrder(params map[string]interface{}) (arimaOrder, *ToolResult) {
+	var order arimaOrder
+	var errResult *ToolResult
+	if order.p, errResult = intParamInRange(params, "p", 1, 0, maxARIMAOrderP); errResult != nil {
+		return order, errResult
+	}
+	if order.d, errResult = intParamInRange(params, "d", 0, 0, maxARIMAOrderD); errResult != nil {
+		return order, errResult
+	}
+	if order.q, errResult = intParamInRange(params, "q", 1, 0, maxARIMAOrderQ); errResult != nil {
+		return order, errResult
+	}
+	if order.seasonality, errResult = intParamInRange(params, "seasonality", 1, 1, maxForecastSeasonality); errResult != nil {
+		return order, errResult
+	}
+	return order, nil
+}
+
+// forecastObservedQuery selects the observations of a series that have both
+// a time and a value, as ts and value, with from_end numbering them from the
+// latest, which is 1
+func forecastObservedQuery(series forecastSeries) string {
+	timeColumn := pgx.Identifier{series.timeColumn}.Sanitize()
+	valueColumn := pgx.Identifier{series.valueColumn}.Sanitize()
+	return fmt.Sprintf(
+		"SELECT %s AS ts, %s::float8 AS value, row_number() OVER (ORDER BY %s DESC) AS from_end FROM %s WHERE %s IS NOT NULL AND %s IS NOT NULL",
+		timeColumn, valueColumn, timeColumn, series.table.Sanitize(), timeColumn, valueColumn)
+}
+
+// forecastTrainingQuery selects the series a model is fitted to: the
+// observations but the latest holdout ones, differenced at the seasonal
+// period when there is one
+func forecastTrainingQuery(series forecastSeries, seasonality, holdout int) string {
+	training := fmt.Sprintf("SELECT ts, value FROM (%s) observed WHERE from_end > %d", forecastObservedQuery(series), holdout)
+	if seasonality <= 1 {
+		return training
+	}
+	return fmt.Sprintf(
+		"SELECT ts, value FROM (SELECT ts, value - lag(value, %d) OVER (ORDER BY ts) AS value FROM (%s) training) differenced WHERE value IS NOT NULL",
+		seasonality, training)
+}
+
+// trainARIMA fits an ARIMA model to the series within tx, leaving out the
+// latest holdout observations. It returns the model ID and the number of
+// values the model was fitted to.
+func trainARIMA(ctx context.Context, tx pgx.Tx, series forecastSeries, order arimaOrder, holdout int) (int, int, error) {
+	if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMP VIEW %s AS %s",
+		forecastInputView, forecastTrainingQuery(series, order.seasonality, holdout))); err != nil {
+		return 0, 0, fmt.Errorf("reading the series failed: %w", err)
+	}
+	var rows int
+	if err := tx.QueryRow(ctx, "SELECT count(*) FROM "+forecastInputView).Scan(&rows); err != nil {
+		return 0, 0, fmt.Errorf("counting the observations failed: %w", err)
+	}
+	if rows < minARIMAObservations {
+		return 0, rows, errTooFewObservations
+	}
+	var modelID int
+	if err := tx.QueryRow(ctx, "SELECT train_arima($1, 'ts', 'value', $2, $3, $4)",
+		forecastInputView, order.p, order.d, order.q).Scan(&modelID); err != nil {
+		return 0, rows, fmt.Errorf("train_arima failed: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DROP VIEW "+forecastInputView); err != nil {
+		return 0, rows, err
+	}
+	return modelID, rows, nil
+}
+
+// errTooFewObservations is returned by trainARIMA when the series is too
+// short to fit a model to
+var errTooFewObservations = fmt.Errorf("at least %d observations are required", minARIMAObservations)
+
+// tooFewObservationsError reports a series too short to fit a model to
+func tooFewObservationsError(series forecastSeries, order arimaOrder, rows int) *ToolResult {
+	message := fmt.Sprintf("Table '%s' has %d usable observations of '%s', at least %d are required", series.tableName, rows, series.valueColumn, minARIMAObservations)
+	if order.seasonality > 1 {
+		message += fmt.Sprintf(" after seasonal differencing at lag %d", order.seasonality)
+	}
+	return Error(message, "VALIDATION_ERROR", map[string]interface{}{
+		"parameter":    "table",
+		"observations": rows,
+	})
+}
+
+// forecastARIMA returns the next horizon values of a model's series
+func forecastARIMA(ctx context.Context, q interface {
+	QueryRow(context.Context, string, ...interface{}) pgx.Row
+}, modelID, horizon int) ([]float64, error) {
+	var raw *string
+	if err := q.QueryRow(ctx, "SELECT forecast_arima($1::int, $2::int)::text", modelID, horizon).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("forecast_arima failed: %w", err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("forecast_arima returned NULL: model_id=%d", modelID)
+	}
+	return parseForecastValues(*raw)
+}
+
+// parseForecastValues reads the text of a forecast, an array of values or
+// a single value
+func parseForecastValues(raw string) ([]float64, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "{") && strings.HasSuffix(raw, "}") {
+		raw = strings.TrimSpace(raw[1 : len(raw)-1])
+		if raw == "" {
+			return nil, fmt.Errorf("forecast is empty")
+		}
+	}
+	parts := strings.Split(raw, ",")
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("forecast value %d is not a number: '%s'", i+1, part)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// lastObservations returns the latest n values of the series before the
+// holdout ones, oldest first
+func lastObservations(ctx context.Context, q interface {
+	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
+}, series forecastSeries, n, holdout int) ([]float64, error) {
+	rows, err := q.Query(ctx, fmt.Sprintf("SELECT value FROM (%s) observed WHERE from_end > $1 ORDER BY from_end LIMIT $2",
+		forecastObservedQuery(series)), holdout, n)
+	if err != nil {
+		return nil, err
+	}
+	values, err := pgx.CollectRows(rows, pgx.RowTo[float64])
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	return values, nil
+}
+
+// reintegrateSeasonal turns a forecast of a series differenced at the
+// seasonal period into a forecast of the series itself. lastSeason is the
+// latest period of observations, oldest first.
+func reintegrateSeasonal(diffs, lastSeason []float64) []float64 {
+	s := len(lastSeason)
+	history := append([]float64(nil), lastSeason...)
+	values := make([]float64, len(diffs))
+	for h, d := range diffs {
+		values[h] = d + history[len(history)-s]
+		history = append(history, values[h])
+	}
+	return values
+}
+
+// forecastTimes returns the times of the next horizon observations of a
+// series, spaced by the mean interval of its latest observations. It fails
+// when the time column has no arithmetic, such as a text column.
+func forecastTimes(ctx context.Context, db *database.Database, series forecastSeries, horizon int) ([]string, error) {
+	query := fmt.Sprintf(`WITH recent AS (
+		SELECT ts FROM (%s) observed ORDER BY from_end LIMIT %d
+	), span AS (
+		SELECT max(ts) AS last, (max(ts) - min(ts)) / NULLIF(count(*) - 1, 0) AS step FROM recent
+	)
+	SELECT (last + step * g)::text FROM span, generate_series(1, $1::int) g ORDER BY g`,
+		forecastObservedQuery(series), forecastSpacingWindow)
+	rows, err := db.Query(ctx, query, horizon)
+	if err != nil {
+		return nil, err
+	}
+	times, err := pgx.CollectRows(rows, pgx.RowTo[*string])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(times))
+	for i, t := range times {
+		if t == nil {
+			return nil, fmt.Errorf("the series has a single observation")
+		}
+		result[i] = *t
+	}
+	return result, nil
+}
+
+// forecastAccuracy measures a forecast against the values observed
+func forecastAccuracy(actual, predicted []float64) map[string]interface{} {
+	var absSum, sqSum, errSum, pctSum, symSum float64
+	pctCount, symCount := 0, 0
+	for i := range actual {
+		e := predicted[i] - actual[i]
+		absSum += math.Abs(e)
+		sqSum += e * e
+		errSum += e
+		if actual[i] != 0 {
+			pctSum += math.Abs(e / actual[i])
+			pctCount++
+		}
+		if denom := math.Abs(actual[i]) + math.Abs(predicted[i]); denom != 0 {
+			symSum += 2 * math.Abs(e) / denom
+			symCount++
+		}
+	}
+	n := float64(len(actual))
+	metrics := map[string]interface{}{
+		"mae":   absSum / n,
+		"rmse":  math.Sqrt(sqSum / n),
+		"bias":  errSum / n,
+		"mape":  nil,
+		"smape": nil,
+	}
+	// Percentage errors are undefined for observations of zero
+	if pctCount > 0 {
+		metrics["mape"] = 100 * pctSum / float64(pctCount)
+	}
+	if symCount > 0 {
+		metrics["smape"] = 100 * symSum / float64(symCount)
+	}
+	return metrics
+}
+
+// naiveForecast repeats the latest season of observations, or the latest
+// observation without seasonality, horizon times
+func naiveForecast(lastSeason []float64, horizon int) []float64 {
+	values := make([]float64, horizon)
+	for h := range values {
+		values[h] = lastSeason[h%len(lastSeason)]
+	}
+	return values
+}
+
+// forecastError logs and reports a failed forecast tool call
+func forecastError(logger *logging.Logger, toolName, stage string, err error, details map[string]interface{}) *ToolResult {
+	logger.Error("Forecast tool failed", err, map[string]interface{}{
+		"tool":  toolName,
+		"stage": stage,
+	})
+	details["stage"] = stage
+	details["error"] = err.Error()
+	return Error(fmt.Sprintf("%s failed during %s: %v", toolName, stage, err), "FORECAST_ERROR", details)
+}
+
+// seriesDetails are the error details naming a series
+func seriesDetails(series forecastSeries) map[string]interface{} {
+	return map[string]interface{}{
+		"table":        series.tableName,
+		"time_column":  series.timeColumn,
+		"value_column": series.valueColumn,
+	}
+}
+
+// TrainForecastModelTool fits an ARIMA model to a time series
+type TrainForecastModelTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewTrainForecastModelTool creates a new forecast model training tool
+func NewTrainForecastModelTool(db *database.Database, logger *logging.Logger) *TrainForecastModelTool {
+	return &TrainForecastModelTool{
+		BaseTool: NewBaseTool(
+			"train_forecast_model",
+			"Fit an ARIMA forecasting model to a time series column of a table with NeuronDB's train_arima, optionally removing a seasonal cycle first; returns the model_id to forecast with",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": arimaOrderProperties(forecastSeriesProperties(map[string]interface{}{})),
+				"required":   []interface{}{"table", "time_column", "value_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute trains the model
+func (t *TrainForecastModelTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for train_forecast_model tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	series, errResult := parseForecastSeries(params, "train_forecast_model", true)
+	if errResult != nil {
+		return errResult, nil
+	}
+	order, errResult := parseARIMAOrder(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for train_forecast_model", "DATABASE_ERROR", seriesDetails(*series)), nil
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return forecastError(t.logger, "train_forecast_model", "transaction", err, seriesDetails(*series)), nil
+	}
+	defer tx.Rollback(ctx)
+
+	modelID, rows, err := trainARIMA(ctx, tx, *series, order, 0)
+	if err == errTooFewObservations {
+		return tooFewObservationsError(*series, order, rows), nil
+	}
+	if err != nil {
+		return forecastError(t.logger, "train_forecast_model", "training", err, seriesDetails(*series)), nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return forecastError(t.logger, "train_forecast_model", "commit", err, seriesDetails(*series)), nil
+	}
+
+	return Success(map[string]interface{}{
+		"model_id":      modelID,
+		"table":         series.tableName,
+		"time_column":   series.timeColumn,
+		"value_column":  series.valueColumn,
+		"p":             order.p,
+		"d":             order.d,
+		"q":             order.q,
+		"seasonality":   order.seasonality,
+		"training_rows": rows,
+	}, map[string]interface{}{
+		"tool": "train_forecast_model",
+	}), nil
+}
+
+// ForecastTool forecasts a time series with a trained ARIMA model
+type ForecastTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewForecastTool creates a new forecasting tool
+func NewForecastTool(db *database.Database, logger *logging.Logger) *ForecastTool {
+	return &ForecastTool{
+		BaseTool: NewBaseTool(
+			"forecast",
+			"Forecast the next values of a time series with a model from train_forecast_model, returned as rows of step, time and value",
+			map[string]interface{}{
+				"type": "object",
+				"properties": forecastSeriesProperties(map[string]interface{}{
+					"model_id": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"description": "Model returned by train_forecast_model",
+					},
+					"horizon": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultForecastHorizon,
+						"minimum":     1,
+						"maximum":     maxForecastHorizon,
+						"description": "Number of future observations to forecast",
+					},
+				}),
+				"required": []interface{}{"model_id"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute runs the forecast
+func (t *ForecastTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for forecast tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	modelID, errResult := intParamInRange(params, "model_id", 0, 1, math.MaxInt32)
+	if errResult != nil {
+		return errResult, nil
+	}
+	horizon, errResult := intParamInRange(params, "horizon", defaultForecastHorizon, 1, maxForecastHorizon)
+	if errResult != nil {
+		return errResult, nil
+	}
+	seasonality, errResult := intParamInRange(params, "seasonality", 1, 1, maxForecastSeasonality)
+	if errResult != nil {
+		return errResult, nil
+	}
+	series, errResult := parseForecastSeries(params, "forecast", false)
+	if errResult != nil {
+		return errResult, nil
+	}
+	if seasonality > 1 && series == nil {
+		return Error("A seasonal forecast needs the table, time_column and value_column the model was trained on", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for forecast", "DATABASE_ERROR", map[string]interface{}{
+			"model_id": modelID,
+		}), nil
+	}
+
+	details := map[string]interface{}{"model_id": modelID}
+	values, err := forecastARIMA(ctx, db, modelID, horizon)
+	if err != nil {
+		return forecastError(t.logger, "forecast", "forecast", err, details), nil
+	}
+	if seasonality > 1 {
+		lastSeason, err := lastObservations(ctx, db, *series, seasonality, 0)
+		if err != nil {
+			return forecastError(t.logger, "forecast", "seasonal reintegration", err, details), nil
+		}
+		if len(lastSeason) < seasonality {
+			return Error(fmt.Sprintf("Table '%s' has %d observations, fewer than the seasonality %d", series.tableName, len(lastSeason), seasonality), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "seasonality",
+			}), nil
+		}
+		values = reintegrateSeasonal(values, lastSeason)
+	}
+
+	var times []string
+	if series != nil {
+		if times, err = forecastTimes(ctx, db, *series, len(values)); err != nil {
+			t.logger.Warn("Forecast times unavailable", map[string]interface{}{
+				"table":       series.tableName,
+				"time_column": series.timeColumn,
+				"error":       err.Error(),
+			})
+			times = nil
+		}
+	}
+
+	rows := make([]map[string]interface{}, len(values))
+	for i, v := range values {
+		rows[i] = map[string]interface{}{"step": i + 1, "value": v}
+		if times != nil {
+			rows[i]["time"] = times[i]
+		}
+	}
+	result := map[string]interface{}{
+		"model_id":    modelID,
+		"horizon":     len(values),
+		"seasonality": seasonality,
+		"forecast":    rows,
+	}
+	if len(values) < horizon {
+		result["note"] = fmt.Sprintf("forecast_arima returned %d of the %d values requested", len(values), horizon)
+	}
+	return Success(result, map[string]interface{}{
+		"tool": "forecast",
+	}), nil
+}
+
+// EvaluateForecastTool backtests an ARIMA model on the latest observations
+// of a time series
+type EvaluateForecastTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewEvaluateForecastTool creates a new forecast evaluation tool
+func NewEvaluateForecastTool(db *database.Database, logger *logging.Logger) *EvaluateForecastTool {
+	return &EvaluateForecastTool{
+		BaseTool: NewBaseTool(
+			"evaluate_forecast",
+			"Backtest an ARIMA model on a time series: fit it without the latest horizon observations, forecast them and report MAE, RMSE, MAPE and the same errors of a naive forecast; nothing is stored",
+			map[string]interface{}{
+				"type": "object",
+				"properties": arimaOrderProperties(forecastSeriesProperties(map[string]interface{}{
+					"horizon": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultForecastHorizon,
+						"minimum":     1,
+						"maximum":     maxForecastHorizon,
+						"description": "Number of latest observations held out and forecast",
+					},
+				})),
+				"required": []interface{}{"table", "time_column", "value_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute runs the backtest
+func (t *EvaluateForecastTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for evaluate_forecast tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	series, errResult := parseForecastSeries(params, "evaluate_forecast", true)
+	if errResult != nil {
+		return errResult, nil
+	}
+	order, errResult := parseARIMAOrder(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+	horizon, errResult := intParamInRange(params, "horizon", defaultForecastHorizon, 1, maxForecastHorizon)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for evaluate_forecast", "DATABASE_ERROR", seriesDetails(*series)), nil
+	}
+
+	// The model is trained in a transaction that is rolled back, so the
+	// backtest leaves nothing behind
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return forecastError(t.logger, "evaluate_forecast", "transaction", err, seriesDetails(*series)), nil
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT ts::text, value FROM (%s) observed WHERE from_end <= $1 ORDER BY from_end DESC",
+		forecastObservedQuery(*series)), horizon)
+	if err != nil {
+		return forecastError(t.logger, "evaluate_forecast", "holdout", err, seriesDetails(*series)), nil
+	}
+	var times []string
+	var actual []float64
+	for rows.Next() {
+		var ts string
+		var value float64
+		if err := rows.Scan(&ts, &value); err != nil {
+			rows.Close()
+			return forecastError(t.logger, "evaluate_forecast", "holdout", err, seriesDetails(*series)), nil
+		}
+		times = append(times, ts)
+		actual = append(actual, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return forecastError(t.logger, "evaluate_forecast", "holdout", err, seriesDetails(*series)), nil
+	}
+
+	modelID, trainingRows, err := trainARIMA(ctx, tx, *series, order, horizon)
+	if err == errTooFewObservations {
+		return tooFewObservationsError(*series, order, trainingRows), nil
+	}
+	if err != nil {
+		return forecastError(t.logger, "evaluate_forecast", "training", err, seriesDetails(*series)), nil
+	}
+	predicted, err := forecastARIMA(ctx, tx, modelID, horizon)
+	if err != nil {
+		return forecastError(t.logger, "evaluate_forecast", "forecast", err, seriesDetails(*series)), nil
+	}
+	lastSeason, err := lastObservations(ctx, tx, *series, order.seasonality, horizon)
+	if err != nil {
+		return forecastError(t.logger, "evaluate_forecast", "naive forecast", err, seriesDetails(*series)), nil
+	}
+	if order.seasonality > 1 {
+		predicted = reintegrateSeasonal(predicted, lastSeason)
+	}
+	if len(predicted) < len(actual) {
+		return forecastError(t.logger, "evaluate_forecast", "forecast",
+			fmt.Errorf("forecast_arima returned %d of the %d values requested", len(predicted), len(actual)), seriesDetails(*series)), nil
+	}
+	predicted = predicted[:len(actual)]
+	naive := naiveForecast(lastSeason, len(actual))
+
+	steps := make([]map[string]interface{}, len(actual))
+	for i := range actual {
+		steps[i] = map[string]interface{}{
+			"step":      i + 1,
+			"time":      times[i],
+			"actual":    actual[i],
+			"predicted": predicted[i],
+			"error":     predicted[i] - actual[i],
+		}
+	}
+	metrics := forecastAccuracy(actual, predicted)
+	baseline := forecastAccuracy(actual, naive)
+	result := map[string]interface{}{
+		"table":         series.tableName,
+		"time_column":   series.timeColumn,
+		"value_column":  series.valueColumn,
+		"p":             order.p,
+		"d":             order.d,
+		"q":             order.q,
+		"seasonality":   order.seasonality,
+		"horizon":       len(actual),
+		"training_rows": trainingRows,
+		"metrics":       metrics,
+		"naive_metrics": baseline,
+		"forecast":      steps,
+	}
+	// Skill above 0 means the model beats repeating the latest observations
+	if naiveMAE := baseline["mae"].(float64); naiveMAE > 0 {
+		result["skill_vs_naive"] = 1 - metrics["mae"].(float64)/naiveMAE
+	}
+	return Success(result, map[string]interface{}{
+		"tool": "evaluate_forecast",
+	}), nil
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestParseForecastValues(t *testing.T) {
+	cases := map[string][]float64{
+		"{1.5,2,-3.25}": {1.5, 2, -3.25},
+		"{ 4 , 5 }":     {4, 5},
+		"42.5":          {42.5},
+	}
+	for raw, want := range cases {
+		got, err := parseForecastValues(raw)
+		if err != nil {
+			t.Errorf("parseForecastValues(%q): unexpected error: %v", raw, err)
+			continue
+		}
+		if len(got) != len(want) {
+			t.Errorf("parseForecastValues(%q) = %v, want %v", raw, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("parseForecastValues(%q) = %v, want %v", raw, got, want)
+				break
+			}
+		}
+	}
+	for _, raw := range []string{"{}", "{1,x}", ""} {
+		if _, err := parseForecastValues(raw); err == nil {
+			t.Errorf("parseForecastValues(%q): expected an error", raw)
+		}
+	}
+}
+
+func TestReintegrateSeasonal(t *testing.T) {
+	// A weekly cycle 10, 20, 30 growing by 1 each season
+	got := reintegrateSeasonal([]float64{1, 1, 1, 1, 1}, []float64{10, 20, 30})
+	want := []float64{11, 21, 31, 12, 22}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("reintegrateSeasonal = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestForecastAccuracy(t *testing.T) {
+	metrics := forecastAccuracy([]float64{10, 20, 0}, []float64{12, 18, 3})
+	if metrics["mae"].(float64) != 7.0/3 {
+		t.Errorf("mae = %v, want %v", metrics["mae"], 7.0/3)
+	}
+	if got, want := metrics["rmse"].(float64), math.Sqrt(17.0/3); math.Abs(got-want) > 1e-12 {
+		t.Errorf("rmse = %v, want %v", got, want)
+	}
+	if metrics["bias"].(float64) != 1 {
+		t.Errorf("bias = %v, want 1", metrics["bias"])
+	}
+	// The observation of zero is left out of MAPE
+	if got := metrics["mape"].(float64); math.Abs(got-15) > 1e-12 {
+		t.Errorf("mape = %v, want 15", got)
+	}
+
+	if metrics := forecastAccuracy([]float64{0}, []float64{0}); metrics["mape"] != nil || metrics["smape"] != nil {
+		t.Errorf("percentage errors of zeros = %v, %v; want nil", metrics["mape"], metrics["smape"])
+	}
+}
+
+func TestNaiveForecast(t *testing.T) {
+	got := naiveForecast([]float64{1, 2, 3}, 5)
+	want := []float64{1, 2, 3, 1, 2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("naiveForecast = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestForecastTrainingQuery(t *testing.T) {
+	series := forecastSeries{table: pgx.Identifier{"sales", "daily"}, timeColumn: "day", valueColumn: "Revenue"}
+
+	plain := forecastTrainingQuery(series, 1, 0)
+	for _, want := range []string{`FROM "sales"."daily"`, `"Revenue"::float8 AS value`, "ORDER BY \"day\" DESC", "from_end > 0"} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("training query missing %q:\n%s", want, plain)
+		}
+	}
+	if strings.Contains(plain, "lag(") {
+		t.Errorf("a non-seasonal series should not be differenced:\n%s", plain)
+	}
+
+	seasonal := forecastTrainingQuery(series, 7, 14)
+	for _, want := range []string{"value - lag(value, 7) OVER (ORDER BY ts)", "from_end > 14", "WHERE value IS NOT NULL"} {
+		if !strings.Contains(seasonal, want) {
+			t.Errorf("seasonal training query missing %q:\n%s", want, seasonal)
+		}
+	}
+}
+
+func TestParseARIMAOrder(t *testing.T) {
+	order, errResult := parseARIMAOrder(map[string]interface{}{"p": float64(2), "seasonality": float64(12)})
+	if errResult != nil {
+		t.Fatalf("unexpected error: %v", errResult)
+	}
+	if order != (arimaOrder{p: 2, d: 0, q: 1, seasonality: 12}) {
+		t.Errorf("order = %+v", order)
+	}
+	for _, params := range []map[string]interface{}{
+		{"p": float64(11)},
+		{"d": float64(3)},
+		{"q": float64(-1)},
+		{"seasonality": float64(0)},
+	} {
+		if _, errResult := parseARIMAOrder(params); errResult == nil {
+			t.Errorf("parseARIMAOrder(%v): expected an error", params)
+		}
+	}
+}
@@ -0,0 +1,680 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// SQL generation limits
+const (
+	defaultGenerateSQLTables  = 10
+	maxGenerateSQLTables      = 50
+	defaultGenerateSQLSamples = 3
+	maxGenerateSQLSamples     = 10
+	defaultGenerateSQLLimit   = 100
+	maxGenerateSQLLimit       = 10000
+	// maxSampleValueLength truncates sample values shown to the model
+	maxSampleValueLength = 40
+	// generateSQLMaxTokens bounds the model's answer
+	generateSQLMaxTokens = 1024
+)
+
+// unsampledTypes are column types whose values are too large or opaque to
+// be useful samples in a prompt
+var unsampledTypes = map[string]bool{
+	"vector": true, "halfvec": true, "sparsevec": true, "bytea": true,
+	"json": true, "jsonb": true, "tsvector": true, "xml": true,
+}
+
+// questionWordRe splits a question into words for schema ranking
+var questionWordRe = regexp.MustCompile(`[a-z0-9]+`)
+
+// sqlFenceRe matches a fenced code block in a model answer
+var sqlFenceRe = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*(.*?)```")
+
+// schemaColumn is a column shown to the model
+type schemaColumn struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Samples []string `json:"samples,omitempty"`
+}
+
+// schemaTable is a table or view shown to the model
+type schemaTable struct {
+	Schema      string         `json:"schema"`
+	Name        string         `json:"name"`
+	Columns     []schemaColumn `json:"columns"`
+	Constraints []string       `json:"constraints,omitempty"`
+}
+
+// qualifiedName returns the table as schema.name
+func (t schemaTable) qualifiedName() string {
+	return t.Schema + "." + t.Name
+}
+
+// generateSQLRequest is a validated generate_sql call
+type generateSQLRequest struct {
+	question      string
+	tables        []pgx.Identifier
+	schemas       []string
+	maxTables     int
+	sampleValues  int
+	model         string
+	execute       bool
+	limit         int
+	includePrompt bool
+}
+
+// sqlValidation is the outcome of checking generated SQL
+type sqlValidation struct {
+	Valid         bool     `json:"valid"`
+	ReadOnly      bool     `json:"read_only"`
+	Error         string   `json:"error,omitempty"`
+	SQLState      string   `json:"sqlstate,omitempty"`
+	EstimatedRows *float64 `json:"estimated_rows,omitempty"`
+	EstimatedCost *float64 `json:"estimated_cost,omitempty"`
+}
+
+// GenerateSQLTool turns a natural language question into SQL
+type GenerateSQLTool struct {
+	*BaseTool
+	db       *database.Database
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewGenerateSQLTool creates a new SQL generation tool
+func NewGenerateSQLTool(db *database.Database, logger *logging.Logger) *GenerateSQLTool {
+	return &GenerateSQLTool{
+		BaseTool: NewBaseTool(
+			"generate_sql",
+			"Generate a read-only SQL query answering a natural language question. The relevant tables, columns, keys and sample values are given to the database LLM, and the generated SQL is checked with EXPLAIN in a read-only transaction. It is run only when execute is true.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"question": map[string]interface{}{
+						"type":        "string",
+						"description": "Question the query should answer",
+					},
+					"tables": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Tables or views the query may use, optionally schema-qualified; by default the tables of schemas that best match the question",
+					},
+					"schemas": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"default":     []interface{}{"public"},
+						"description": "Schemas searched for relevant tables when tables is not given",
+					},
+					"max_tables": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGenerateSQLTables,
+						"minimum":     1,
+						"maximum":     maxGenerateSQLTables,
+						"description": "Maximum number of tables described to the model",
+					},
+					"sample_values": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGenerateSQLSamples,
+						"minimum":     0,
+						"maximum":     maxGenerateSQLSamples,
+						"description": "Sample values shown per column; 0 sends no data to the model",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "LLM model name; defaults to the neurondb.llm_model setting",
+					},
+					"execute": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Run the generated query, read-only, when it validates",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGenerateSQLLimit,
+						"minimum":     1,
+						"maximum":     maxGenerateSQLLimit,
+						"description": "Maximum number of rows returned when executing",
+					},
+					"include_prompt": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Include the prompt sent to the model in the result",
+					},
+				},
+				"required": []interface{}{"question"},
+			},
+		),
+		db:       db,
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute generates, validates and optionally runs the SQL
+func (t *GenerateSQLTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for generate_sql tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+			"params": params,
+		}), nil
+	}
+	req, invalid := parseGenerateSQLRequest(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available for generate_sql tool", "DATABASE_ERROR", nil), nil
+	}
+
+	tables, err := t.loadSchema(ctx, req)
+	if err != nil {
+		t.logger.Error("Schema lookup for SQL generation failed", err, map[string]interface{}{
+			"schemas": req.schemas,
+		})
+		return Error(fmt.Sprintf("Failed to read the schema for generate_sql tool: %v", err), "QUERY_ERROR", map[string]interface{}{
+			"error": err.Error(),
+		}), nil
+	}
+	if len(tables) == 0 {
+		return Error("No tables found to generate SQL from: check the tables and schemas parameters", "VALIDATION_ERROR", map[string]interface{}{
+			"tables":  params["tables"],
+			"schemas": req.schemas,
+		}), nil
+	}
+	if req.sampleValues > 0 {
+		for i := range tables {
+			if err := t.loadSampleValues(ctx, &tables[i], req.sampleValues); err != nil {
+				// Samples only help the model; the table is still described
+				t.logger.Warn("Sample values unavailable for SQL generation", map[string]interface{}{
+					"table": tables[i].qualifiedName(),
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+
+	prompt := buildGenerateSQLPrompt(req.question, tables)
+	answer, err := t.generate(ctx, req.model, prompt)
+	if err != nil {
+		t.logger.Error("SQL generation failed", err, map[string]interface{}{
+			"model": req.model,
+		})
+		return Error(fmt.Sprintf("SQL generation failed: %v", err), "LLM_ERROR", map[string]interface{}{
+			"model": req.model,
+			"error": err.Error(),
+		}), nil
+	}
+	sql, err := extractGeneratedSQL(answer)
+	if err != nil {
+		return Error(fmt.Sprintf("The model did not return a usable query: %v", err), "LLM_ERROR", map[string]interface{}{
+			"response": answer,
+		}), nil
+	}
+
+	tableNames := make([]string, len(tables))
+	for i, table := range tables {
+		tableNames[i] = table.qualifiedName()
+	}
+	result := map[string]interface{}{
+		"sql":      sql,
+		"tables":   tableNames,
+		"executed": false,
+	}
+	if req.includePrompt {
+		result["prompt"] = prompt
+	}
+
+	validation, rows, err := validateGeneratedSQL(ctx, db, sql, req.execute, req.limit)
+	if err != nil {
+		t.logger.Error("Generated SQL check failed", err, map[string]interface{}{
+			"sql":     sql,
+			"execute": req.execute,
+		})
+		return Error(fmt.Sprintf("Failed to check or run the generated SQL: %v", err), "QUERY_ERROR", map[string]interface{}{
+			"sql":   sql,
+			"error": err.Error(),
+		}), nil
+	}
+	result["validation"] = validation
+	if req.execute {
+		if !validation.Valid {
+			return Error(fmt.Sprintf("Generated SQL did not validate and was not executed: %s", validation.Error), "SQL_VALIDATION_ERROR", result), nil
+		}
+		truncated := len(rows) > req.limit
+		if truncated {
+			rows = rows[:req.limit]
+		}
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		result["executed"] = true
+		result["rows"] = rows
+		result["row_count"] = len(rows)
+		result["truncated"] = truncated
+	}
+	return Success(result, map[string]interface{}{
+		"model":       req.model,
+		"table_count": len(tables),
+	}), nil
+}
+
+// parseGenerateSQLRequest validates the parameters of a generate_sql call
+func parseGenerateSQLRequest(params map[string]interface{}) (*generateSQLRequest, *ToolResult) {
+	req := &generateSQLRequest{
+		question:     strings.TrimSpace(stringParam(params, "question", "")),
+		schemas:      []string{"public"},
+		maxTables:    defaultGenerateSQLTables,
+		sampleValues: defaultGenerateSQLSamples,
+		model:        stringParam(params, "model", ""),
+		limit:        defaultGenerateSQLLimit,
+	}
+	req.execute, _ = params["execute"].(bool)
+	req.includePrompt, _ = params["include_prompt"].(bool)
+	if req.question == "" {
+		return nil, Error("question parameter is required and cannot be empty for generate_sql tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "question",
+		})
+	}
+
+	if raw, ok := params["tables"].([]interface{}); ok {
+		for i, v := range raw {
+			name, _ := v.(string)
+			table, err := parseQualifiedIdentifier(name)
+			if err != nil {
+				return nil, Error(fmt.Sprintf("tables element %d is invalid: %v", i, err), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "tables",
+					"index":     i,
+				})
+			}
+			req.tables = append(req.tables, table)
+		}
+	}
+	if raw, ok := params["schemas"].([]interface{}); ok && len(raw) > 0 {
+		req.schemas = req.schemas[:0]
+		for i, v := range raw {
+			schema, _ := v.(string)
+			if schema == "" {
+				return nil, Error(fmt.Sprintf("schemas element %d must be a non-empty string", i), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "schemas",
+					"index":     i,
+				})
+			}
+			req.schemas = append(req.schemas, schema)
+		}
+	}
+
+	if v, ok := params["max_tables"].(float64); ok {
+		req.maxTables = int(v)
+	}
+	if req.maxTables < 1 || req.maxTables > maxGenerateSQLTables {
+		return nil, Error(fmt.Sprintf("max_tables must be between 1 and %d, got %d", maxGenerateSQLTables, req.maxTables), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "max_tables",
+		})
+	}
+	if len(req.tables) > maxGenerateSQLTables {
+		return nil, Error(fmt.Sprintf("at most %d tables can be given, got %d", maxGenerateSQLTables, len(req.tables)), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "tables",
+		})
+	}
+	if v, ok := params["sample_values"].(float64); ok {
+		req.sampleValues = int(v)
+	}
+	if req.sampleValues < 0 || req.sampleValues > maxGenerateSQLSamples {
+		return nil, Error(fmt.Sprintf("sample_values must be between 0 and %d, got %d", maxGenerateSQLSamples, req.sampleValues), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "sample_values",
+		})
+	}
+	if v, ok := params["limit"].(float64); ok {
+		req.limit = int(v)
+	}
+	if req.limit < 1 || req.limit > maxGenerateSQLLimit {
+		return nil, Error(fmt.Sprintf("limit must be between 1 and %d, got %d", maxGenerateSQLLimit, req.limit), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "limit",
+		})
+	}
+	return req, nil
+}
+
+// loadSchema returns the tables described to the model: the requested ones,
+// or the tables of the requested schemas most relevant to the question
+func (t *GenerateSQLTool) loadSchema(ctx context.Context, req *generateSQLRequest) ([]schemaTable, error) {
+	schemas := req.schemas
+	if len(req.tables) > 0 {
+		schemas = nil
+		seen := map[string]bool{}
+		for _, table := range req.tables {
+			schema := schemaOf(table)
+			if schema == "" {
+				schema = "public"
+			}
+			if !seen[schema] {
+				seen[schema] = true
+				schemas = append(schemas, schema)
+			}
+		}
+	}
+
+	rows, err := t.executor.ExecuteQuery(ctx, `
+		SELECT c.table_schema, c.table_name, c.column_name, c.udt_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+		  ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = ANY($1) AND t.table_type IN ('BASE TABLE', 'VIEW')
+		ORDER BY c.table_schema, c.table_name, c.ordinal_position`, []interface{}{schemas})
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*schemaTable{}
+	var tables []*schemaTable
+	for _, row := range rows {
+		schema, _ := row["table_schema"].(string)
+		name, _ := row["table_name"].(string)
+		column, _ := row["column_name"].(string)
+		udt, _ := row["udt_name"].(string)
+		key := schema + "." + name
+		table, ok := byName[key]
+		if !ok {
+			table = &schemaTable{Schema: schema, Name: name}
+			byName[key] = table
+			tables = append(tables, table)
+		}
+		table.Columns = append(table.Columns, schemaColumn{Name: column, Type: udt})
+	}
+
+	var selected []schemaTable
+	if len(req.tables) > 0 {
+		for _, ident := range req.tables {
+			schema := schemaOf(ident)
+			if schema == "" {
+				schema = "public"
+			}
+			table, ok := byName[schema+"."+ident[len(ident)-1]]
+			if !ok {
+				return nil, fmt.Errorf("table '%s' not found", strings.Join(ident, "."))
+			}
+			selected = append(selected, *table)
+		}
+	} else {
+		candidates := make([]schemaTable, len(tables))
+		for i, table := range tables {
+			candidates[i] = *table
+		}
+		selected = rankSchemaTables(req.question, candidates, req.maxTables)
+	}
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, len(selected))
+	for i, table := range selected {
+		names[i] = pgx.Identifier{table.Schema, table.Name}.Sanitize()
+	}
+	constraints, err := t.executor.ExecuteQuery(ctx, `
+		SELECT n.nspname AS table_schema, cl.relname AS table_name, pg_get_constraintdef(con.oid) AS definition
+		FROM pg_constraint con
+		JOIN pg_class cl ON cl.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = cl.relnamespace
+		WHERE con.contype IN ('p', 'f', 'u') AND con.conrelid = ANY($1::regclass[])
+		ORDER BY con.contype, con.conname`, []interface{}{names})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range constraints {
+		schema, _ := row["table_schema"].(string)
+		name, _ := row["table_name"].(string)
+		definition, _ := row["definition"].(string)
+		for i := range selected {
+			if selected[i].Schema == schema && selected[i].Name == name {
+				selected[i].Constraints = append(selected[i].Constraints, definition)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// loadSampleValues adds up to n distinct sample values to each column of
+// table whose type makes them meaningful
+func (t *GenerateSQLTool) loadSampleValues(ctx context.Context, table *schemaTable, n int) error {
+	var exprs []string
+	var sampled []int
+	for i, column := range table.Columns {
+		if unsampledTypes[column.Type] || strings.HasPrefix(column.Type, "_") {
+			continue
+		}
+		ident := pgx.Identifier{column.Name}.Sanitize()
+		exprs = append(exprs, fmt.Sprintf("left(%s::text, %d) AS %s", ident, maxSampleValueLength, pgx.Identifier{fmt.Sprintf("c%d", len(exprs))}.Sanitize()))
+		sampled = append(sampled, i)
+	}
+	if len(exprs) == 0 {
+		return nil
+	}
+
+	// A few times more rows than samples, so low-cardinality columns still
+	// show distinct values
+	query := fmt.Sprintf("SELECT %s FROM %s LIMIT $1", strings.Join(exprs, ", "),
+		pgx.Identifier{table.Schema, table.Name}.Sanitize())
+	rows, err := t.executor.ExecuteQuery(ctx, query, []interface{}{n * 5})
+	if err != nil {
+		return err
+	}
+	for j, i := range sampled {
+		key := fmt.Sprintf("c%d", j)
+		seen := map[string]bool{}
+		for _, row := range rows {
+			v, ok := row[key].(string)
+			if !ok || v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			table.Columns[i].Samples = append(table.Columns[i].Samples, v)
+			if len(table.Columns[i].Samples) == n {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// generate asks the database LLM to complete prompt
+func (t *GenerateSQLTool) generate(ctx context.Context, model, prompt string) (string, error) {
+	var modelParam interface{}
+	if model != "" {
+		modelParam = model
+	}
+	llmParams := fmt.Sprintf(`{"temperature": 0, "do_sample": false, "max_tokens": %d}`, generateSQLMaxTokens)
+	row, err := t.executor.ExecuteQueryOneWithTimeout(ctx,
+		`SELECT neurondb.llm('complete', $1, $2, NULL, $3::jsonb, $4) AS response`,
+		[]interface{}{modelParam, prompt, llmParams, generateSQLMaxTokens}, EmbeddingQueryTimeout)
+	if err != nil {
+		return "", err
+	}
+	return llmResponseText(row["response"])
+}
+
+// llmResponseText extracts the generated text from a neurondb.llm result,
+// which reports failures in its error field
+func llmResponseText(response interface{}) (string, error) {
+	switch v := response.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}:
+		if msg, ok := v["error"].(string); ok && msg != "" {
+			return "", errors.New(msg)
+		}
+		if text, ok := v["text"].(string); ok {
+			return text, nil
+		}
+	}
+	return "", fmt.Errorf("unexpected LLM response of type %T", response)
+}
+
+// rankSchemaTables orders tables by how many of their table and column name
+// words occur in the question, table names weighing more, and keeps the
+// first max. Ties keep schema and name order.
+func rankSchemaTables(question string, tables []schemaTable, max int) []schemaTable {
+	words := map[string]bool{}
+	for _, w := range questionWordRe.FindAllString(strings.ToLower(question), -1) {
+		words[w] = true
+		words[strings.TrimSuffix(w, "s")] = true
+	}
+	matches := func(name string) int {
+		n := 0
+		for _, part := range strings.Split(strings.ToLower(name), "_") {
+			if part != "" && (words[part] || words[strings.TrimSuffix(part, "s")]) {
+				n++
+			}
+		}
+		return n
+	}
+
+	scores := make([]int, len(tables))
+	for i, table := range tables {
+		scores[i] = 3 * matches(table.Name)
+		for _, column := range table.Columns {
+			scores[i] += matches(column.Name)
+		}
+	}
+	order := make([]int, len(tables))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	if len(order) > max {
+		order = order[:max]
+	}
+	ranked := make([]schemaTable, len(order))
+	for i, idx := range order {
+		ranked[i] = tables[idx]
+	}
+	return ranked
+}
+
+// buildGenerateSQLPrompt builds the prompt constraining the model to a single
+// read-only PostgreSQL query over the described tables
+func buildGenerateSQLPrompt(question string, tables []schemaTable) string {
+	var b strings.Builder
+	b.WriteString("You are a PostgreSQL expert. Write one read-only SQL query (SELECT or WITH) that answers the question.\n")
+	b.WriteString("Rules:\n")
+	b.WriteString("- Use only the tables and columns listed below, schema-qualified.\n")
+	b.WriteString("- Never modify data or the schema.\n")
+	b.WriteString("- Return only the SQL, with no explanation and no comments.\n\n")
+	b.WriteString("Schema:\n")
+	for _, table := range tables {
+		fmt.Fprintf(&b, "TABLE %s (\n", table.qualifiedName())
+		for i, column := range table.Columns {
+			fmt.Fprintf(&b, "  %s %s", column.Name, column.Type)
+			if i < len(table.Columns)-1 || len(table.Constraints) > 0 {
+				b.WriteString(",")
+			}
+			if len(column.Samples) > 0 {
+				samples, _ := json.Marshal(column.Samples)
+				fmt.Fprintf(&b, " -- e.g. %s", samples)
+			}
+			b.WriteString("\n")
+		}
+		for i, constraint := range table.Constraints {
+			fmt.Fprintf(&b, "  %s", constraint)
+			if i < len(table.Constraints)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(")\n")
+	}
+	fmt.Fprintf(&b, "\nQuestion: %s\nSQL:", question)
+	return b.String()
+}
+
+// extractGeneratedSQL returns the single statement of a model answer, taken
+// from its first code block when it has one
+func extractGeneratedSQL(answer string) (string, error) {
+	sql := answer
+	if m := sqlFenceRe.FindStringSubmatch(answer); m != nil {
+		sql = m[1]
+	}
+	sql = strings.TrimSpace(sql)
+	sql = strings.TrimSpace(strings.TrimRight(sql, "; \n\t"))
+	if sql == "" {
+		return "", fmt.Errorf("empty response")
+	}
+	if strings.Contains(sql, ";") {
+		return "", fmt.Errorf("response holds more than one statement")
+	}
+	return sql, nil
+}
+
+// validateGeneratedSQL plans sql with EXPLAIN in a read-only transaction and,
+// when execute is set and it is valid, runs it there for up to limit+1 rows.
+// The transaction is always rolled back. An invalid query is reported in the
+// validation; the error is for failures to check it at all.
+func validateGeneratedSQL(ctx context.Context, db *database.Database, sql string, execute bool, limit int) (*sqlValidation, []map[string]interface{}, error) {
+	validation := &sqlValidation{ReadOnly: database.IsReadOnlyStatement(sql)}
+	if !validation.ReadOnly {
+		validation.Error = "query is not a read-only SELECT, WITH, VALUES or TABLE statement"
+		return validation, nil, nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	conn, err := db.Acquire(queryCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Release()
+	tx, err := beginReadOnly(queryCtx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var plan []byte
+	if err := tx.QueryRow(queryCtx, "EXPLAIN (FORMAT JSON) "+sql).Scan(&plan); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			validation.Error = pgErr.Message
+			validation.SQLState = pgErr.Code
+			return validation, nil, nil
+		}
+		return nil, nil, err
+	}
+	validation.Valid = true
+	var plans []struct {
+		Plan struct {
+			PlanRows  float64 `json:"Plan Rows"`
+			TotalCost float64 `json:"Total Cost"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &plans); err == nil && len(plans) > 0 {
+		validation.EstimatedRows = &plans[0].Plan.PlanRows
+		validation.EstimatedCost = &plans[0].Plan.TotalCost
+	}
+	if !execute {
+		return validation, nil, nil
+	}
+
+	rows, err := tx.Query(queryCtx, "SELECT * FROM ("+sql+") AS generated_sql LIMIT $1", limit+1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generated query failed: %w", err)
+	}
+	defer rows.Close()
+	results, err := scanRowsToMaps(queryCtx, rows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generated query failed: %w", err)
+	}
+	return validation, results, nil
+}
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGenerateSQLRequest(t *testing.T) {
+	req, invalid := parseGenerateSQLRequest(map[string]interface{}{
+		"question": "  How many orders per customer?  ",
+		"tables":   []interface{}{"sales.orders", "customers"},
+		"execute":  true,
+		"limit":    float64(20),
+	})
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if req.question != "How many orders per customer?" || !req.execute || req.limit != 20 {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if len(req.tables) != 2 || schemaOf(req.tables[0]) != "sales" || schemaOf(req.tables[1]) != "" {
+		t.Errorf("tables = %v", req.tables)
+	}
+	if req.sampleValues != defaultGenerateSQLSamples || req.maxTables != defaultGenerateSQLTables {
+		t.Errorf("defaults not applied: %+v", req)
+	}
+
+	for name, params := range map[string]map[string]interface{}{
+		"empty question":  {"question": " "},
+		"bad table":       {"question": "q", "tables": []interface{}{"a.b.c"}},
+		"empty schema":    {"question": "q", "schemas": []interface{}{""}},
+		"max_tables":      {"question": "q", "max_tables": float64(0)},
+		"sample_values":   {"question": "q", "sample_values": float64(maxGenerateSQLSamples + 1)},
+		"limit too large": {"question": "q", "limit": float64(maxGenerateSQLLimit + 1)},
+	} {
+		if _, invalid := parseGenerateSQLRequest(params); invalid == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestRankSchemaTables(t *testing.T) {
+	tables := []schemaTable{
+		{Schema: "public", Name: "audit_log", Columns: []schemaColumn{{Name: "id"}, {Name: "message"}}},
+		{Schema: "public", Name: "customers", Columns: []schemaColumn{{Name: "id"}, {Name: "name"}}},
+		{Schema: "public", Name: "orders", Columns: []schemaColumn{{Name: "customer_id"}, {Name: "total"}}},
+	}
+	ranked := rankSchemaTables("Total of orders for each customer name", tables, 2)
+	if len(ranked) != 2 || ranked[0].Name != "orders" || ranked[1].Name != "customers" {
+		names := make([]string, len(ranked))
+		for i, table := range ranked {
+			names[i] = table.Name
+		}
+		t.Errorf("ranked = %v, want [orders customers]", names)
+	}
+}
+
+func TestBuildGenerateSQLPrompt(t *testing.T) {
+	prompt := buildGenerateSQLPrompt("Top customers?", []schemaTable{{
+		Schema: "public",
+		Name:   "customers",
+		Columns: []schemaColumn{
+			{Name: "id", Type: "int4"},
+			{Name: "country", Type: "text", Samples: []string{"FR", "DE"}},
+		},
+		Constraints: []string{"PRIMARY KEY (id)"},
+	}})
+	for _, want := range []string{
+		"read-only SQL query",
+		"TABLE public.customers (\n  id int4,\n  country text, -- e.g. [\"FR\",\"DE\"]\n  PRIMARY KEY (id)\n)",
+		"Question: Top customers?\nSQL:",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestExtractGeneratedSQL(t *testing.T) {
+	for answer, want := range map[string]string{
+		"SELECT 1;": "SELECT 1",
+		"Here you go:\n```sql\nSELECT *\nFROM t;\n```":   "SELECT *\nFROM t",
+		"```\nWITH x AS (SELECT 1) SELECT * FROM x\n```": "WITH x AS (SELECT 1) SELECT * FROM x",
+	} {
+		got, err := extractGeneratedSQL(answer)
+		if err != nil || got != want {
+			t.Errorf("extractGeneratedSQL(%q) = %q, %v; want %q", answer, got, err, want)
+		}
+	}
+	for _, answer := range []string{"", "```sql\n```", "SELECT 1; DROP TABLE t"} {
+		if _, err := extractGeneratedSQL(answer); err == nil {
+			t.Errorf("extractGeneratedSQL(%q): expected an error", answer)
+		}
+	}
+}
+
+func TestLLMResponseText(t *testing.T) {
+	if text, err := llmResponseText(map[string]interface{}{"task": "complete", "text": "SELECT 1"}); err != nil || text != "SELECT 1" {
+		t.Errorf("text = %q, %v", text, err)
+	}
+	if _, err := llmResponseText(map[string]interface{}{"error": "model not found"}); err == nil || err.Error() != "model not found" {
+		t.Errorf("err = %v, want model not found", err)
+	}
+	if _, err := llmResponseText(nil); err == nil {
+		t.Error("expected an error for a nil response")
+	}
+}
@@ -3,14 +3,167 @@ package tools
 import (
 	"context"
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/neurondb/NeuronMCP/internal/database"
 	"github.com/neurondb/NeuronMCP/internal/logging"
 )
 
+// gpuSetting is a GPU run-time parameter configure_gpu can override
+type gpuSetting struct {
+	param string // configure_gpu parameter
+	guc   string
+	kind  string // "enum", "integer", "number" or "kernels"
+	// names are the values of an enum parameter, by the integer the
+	// extension stores
+	names    []string
+	min, max float64
+}
+
+// gpuSettings are the GPU parameters of the extension, all settable by any
+// user for their own session
+var gpuSettings = []gpuSetting{
+	{param: "compute_mode", guc: "neurondb.compute_mode", kind: "enum", names: []string{"cpu", "gpu", "auto"}},
+	{param: "backend", guc: "neurondb.gpu_backend_type", kind: "enum", names: []string{"cuda", "rocm", "metal"}},
+	{param: "device", guc: "neurondb.gpu_device", kind: "integer", min: 0, max: 16},
+	{param: "batch_size", guc: "neurondb.gpu_batch_size", kind: "integer", min: 64, max: 65536},
+	{param: "streams", guc: "neurondb.gpu_streams", kind: "integer", min: 1, max: 8},
+	{param: "memory_pool_mb", guc: "neurondb.gpu_memory_pool_mb", kind: "number", min: 64, max: 32768},
+	{param: "kernels", guc: "neurondb.gpu_kernels", kind: "kernels"},
+	{param: "timeout_ms", guc: "neurondb.gpu_timeout_ms", kind: "integer", min: 1000, max: 300000},
+}
+
+// gpuKernelPattern matches a GPU kernel name, such as l2 or rf_split
+var gpuKernelPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// gpuSettingByGUC returns the GPU setting of a parameter name
+func gpuSettingByGUC(guc string) (gpuSetting, bool) {
+	for _, s := range gpuSettings {
+		if s.guc == guc {
+			return s, true
+		}
+	}
+	return gpuSetting{}, false
+}
+
+// gpuSettingGUCs returns the parameter names of the GPU settings
+func gpuSettingGUCs() []string {
+	names := make([]string, len(gpuSettings))
+	for i, s := range gpuSettings {
+		names[i] = s.guc
+	}
+	return names
+}
+
+// encode converts a configure_gpu argument to the parameter's text value
+func (s gpuSetting) encode(v interface{}) (string, error) {
+	switch s.kind {
+	case "enum":
+		name, _ := v.(string)
+		for i, n := range s.names {
+			if n == name {
+				return strconv.Itoa(i), nil
+			}
+		}
+		return "", fmt.Errorf("%s must be one of %s, got %v", s.param, strings.Join(s.names, ", "), v)
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || n < s.min || n > s.max || (s.kind == "integer" && n != math.Trunc(n)) {
+			return "", fmt.Errorf("%s must be %s between %g and %g, got %v", s.param, map[string]string{"integer": "an integer", "number": "a number"}[s.kind], s.min, s.max, v)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case "kernels":
+		items, ok := v.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("%s must be an array of kernel names", s.param)
+		}
+		kernels := make([]string, 0, len(items))
+		for _, item := range items {
+			kernel, _ := item.(string)
+			if !gpuKernelPattern.MatchString(kernel) {
+				return "", fmt.Errorf("invalid GPU kernel name '%v' in %s: must be lowercase letters, digits or '_'", item, s.param)
+			}
+			kernels = append(kernels, kernel)
+		}
+		return strings.Join(kernels, ","), nil
+	}
+	return "", fmt.Errorf("unsupported setting kind '%s'", s.kind)
+}
+
+// decode converts a parameter's text value to its configure_gpu form
+func (s gpuSetting) decode(value string) interface{} {
+	switch s.kind {
+	case "enum":
+		if i, err := strconv.Atoi(value); err == nil && i >= 0 && i < len(s.names) {
+			return s.names[i]
+		}
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "kernels":
+		kernels := []string{}
+		for _, k := range strings.Split(value, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				kernels = append(kernels, k)
+			}
+		}
+		return kernels
+	}
+	return value
+}
+
+// gpuSessionOverrides returns the GPU settings overridden for the session,
+// by configure_gpu parameter
+func gpuSessionOverrides(settings *database.SessionSettings) map[string]interface{} {
+	overrides := map[string]interface{}{}
+	if settings == nil {
+		return overrides
+	}
+	for guc, value := range settings.Values() {
+		if s, ok := gpuSettingByGUC(guc); ok {
+			overrides[s.param] = s.decode(value)
+		}
+	}
+	return overrides
+}
+
+// gpuInfoSection is a part of the gpu_info report read from an extension
+// function that older or CPU-only builds may lack
+type gpuInfoSection struct {
+	key      string
+	function string // signature checked with to_regprocedure
+	query    string
+	single   bool // the function returns one row
+}
+
+var gpuInfoSections = []gpuInfoSection{
+	{key: "devices", function: "neurondb_gpu_info()", query: "SELECT * FROM neurondb_gpu_info() ORDER BY device_id"},
+	{key: "backends", function: "neurondb_llm_gpu_info()", query: "SELECT * FROM neurondb_llm_gpu_info()"},
+	{key: "utilization", function: "neurondb.llm_gpu_utilization()", query: "SELECT * FROM neurondb.llm_gpu_utilization() ORDER BY device_id"},
+	{key: "stats", function: "neurondb_gpu_stats()", query: "SELECT * FROM neurondb_gpu_stats()", single: true},
+}
+
+// neurondbWorkersQuery lists the running background workers of the
+// extension, whose backend types start with "neurondb"
+const neurondbWorkersQuery = `
+	SELECT pid, backend_type, state, backend_start, wait_event_type, wait_event
+	FROM pg_stat_activity
+	WHERE backend_type LIKE 'neurondb%'
+	ORDER BY backend_type, pid`
+
 // GPUMonitoringTool monitors GPU information
 type GPUMonitoringTool struct {
 	*BaseTool
+	db       *database.Database
 	executor *QueryExecutor
 	logger   *logging.Logger
 }
@@ -20,31 +173,338 @@ func NewGPUMonitoringTool(db *database.Database, logger *logging.Logger) *GPUMon
 	return &GPUMonitoringTool{
 		BaseTool: NewBaseTool(
 			"gpu_info",
-			"Get GPU information and monitoring data",
+			"Report the GPU state of NeuronDB: devices with their memory, backend (CUDA/ROCm/Metal) availability, enabled kernels, utilization, usage statistics, the effective GPU settings of this session and the running NeuronDB background workers. Parts the installation does not provide are listed under unavailable.",
 			map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
 				"required":   []interface{}{},
 			},
 		),
+		db:       db,
 		executor: NewQueryExecutor(db),
 		logger:   logger,
 	}
 }
 
-// Execute executes GPU info query
+// Execute reports the GPU state
 func (t *GPUMonitoringTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
-	query := "SELECT * FROM neurondb.gpu_info()"
-	result, err := t.executor.ExecuteQueryOne(ctx, query, nil)
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for gpu_info", "DATABASE_ERROR", nil), nil
+	}
+	infoCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	result := map[string]interface{}{}
+	unavailable := []string{}
+	failures := map[string]interface{}{}
+	for _, section := range gpuInfoSections {
+		var exists bool
+		if err := db.QueryRow(infoCtx, "SELECT to_regprocedure($1) IS NOT NULL", section.function).Scan(&exists); err != nil {
+			t.logger.Error("GPU info function lookup failed", err, map[string]interface{}{"function": section.function})
+			return Error(fmt.Sprintf("GPU info query failed: error=%v", err), "EXECUTION_ERROR", map[string]interface{}{
+				"error": err.Error(),
+			}), nil
+		}
+		if !exists {
+			unavailable = append(unavailable, section.function)
+			continue
+		}
+		rows, err := t.executor.ExecuteQuery(infoCtx, section.query, nil)
+		if err != nil {
+			// GPU functions fail when the runtime cannot start; that is
+			// worth reporting alongside the rest
+			failures[section.key] = err.Error()
+			continue
+		}
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		if section.single {
+			if len(rows) > 0 {
+				result[section.key] = rows[0]
+			}
+			continue
+		}
+		result[section.key] = rows
+	}
+
+	settings, err := t.executor.ExecuteQuery(infoCtx, "SELECT name, setting, source FROM pg_settings WHERE name = ANY($1) ORDER BY name", []interface{}{gpuSettingGUCs()})
 	if err != nil {
-		t.logger.Error("GPU info query failed", err, nil)
-		return Error(fmt.Sprintf("GPU info query failed: error=%v", err), "EXECUTION_ERROR", map[string]interface{}{
-			"error": err.Error(),
-		}), nil
+		failures["settings"] = err.Error()
+	} else {
+		effective := map[string]interface{}{}
+		for _, row := range settings {
+			name, _ := row["name"].(string)
+			value, _ := row["setting"].(string)
+			if s, ok := gpuSettingByGUC(name); ok {
+				effective[s.param] = map[string]interface{}{
+					"parameter": name,
+					"value":     s.decode(value),
+					"source":    row["source"],
+				}
+			}
+		}
+		result["settings"] = effective
+	}
+	result["session_overrides"] = gpuSessionOverrides(db.Settings())
+
+	workers, err := t.executor.ExecuteQuery(infoCtx, neurondbWorkersQuery, nil)
+	if err != nil {
+		failures["workers"] = err.Error()
+	} else {
+		if workers == nil {
+			workers = []map[string]interface{}{}
+		}
+		result["workers"] = workers
 	}
 
+	result["gpu_available"] = anyDeviceAvailable(result["devices"])
+	result["notes"] = gpuNotes(result)
+	result["unavailable"] = unavailable
+	if len(failures) > 0 {
+		result["errors"] = failures
+	}
 	return Success(result, nil), nil
 }
 
+// anyDeviceAvailable reports whether a device row is marked available
+func anyDeviceAvailable(devices interface{}) bool {
+	rows, _ := devices.([]map[string]interface{})
+	for _, row := range rows {
+		if available, _ := row["is_available"].(bool); available {
+			return true
+		}
+	}
+	return false
+}
 
+// gpuNotes explains common reasons GPU acceleration is not used
+func gpuNotes(report map[string]interface{}) []string {
+	notes := []string{}
+	settings, _ := report["settings"].(map[string]interface{})
+	if mode, ok := settings["compute_mode"].(map[string]interface{}); ok && mode["value"] == "cpu" {
+		notes = append(notes, "compute_mode is 'cpu', so operations never use the GPU; set it to 'gpu' or 'auto' with configure_gpu")
+	}
+	if _, ok := report["devices"]; ok && !anyDeviceAvailable(report["devices"]) {
+		notes = append(notes, "no GPU device is available to the database server")
+	}
+	if stats, ok := report["stats"].(map[string]interface{}); ok {
+		if fallbacks, ok := stats["fallback_count"].(int64); ok && fallbacks > 0 {
+			notes = append(notes, fmt.Sprintf("%d operations fell back to the CPU", fallbacks))
+		}
+	}
+	return notes
+}
+
+// ConfigureGPUTool overrides GPU settings for the MCP session
+type ConfigureGPUTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
 
+// NewConfigureGPUTool creates a new configure GPU tool
+func NewConfigureGPUTool(db *database.Database, logger *logging.Logger) *ConfigureGPUTool {
+	properties := map[string]interface{}{
+		"compute_mode": map[string]interface{}{
+			"type":        "string",
+			"enum":        []interface{}{"cpu", "gpu", "auto"},
+			"description": "cpu never uses the GPU, gpu requires it, auto tries the GPU and falls back to the CPU",
+		},
+		"backend": map[string]interface{}{
+			"type":        "string",
+			"enum":        []interface{}{"cuda", "rocm", "metal"},
+			"description": "GPU backend; ignored when compute_mode is cpu",
+		},
+		"kernels": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "GPU-accelerated kernels to enable, such as l2, cosine, ip, rf_split or rf_predict; an empty array enables all",
+		},
+		"reset": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string", "enum": append(gpuSettingParams(), "all")},
+			"description": "Settings to return to the database default; \"all\" resets every GPU setting",
+		},
+	}
+	descriptions := map[string]string{
+		"device":         "GPU device ID (0-based)",
+		"batch_size":     "Batch size of GPU operations",
+		"streams":        "CUDA/HIP streams for parallel operations",
+		"memory_pool_mb": "GPU memory pool size in MB",
+		"timeout_ms":     "GPU kernel execution timeout in milliseconds",
+	}
+	for _, s := range gpuSettings {
+		if s.kind == "integer" || s.kind == "number" {
+			properties[s.param] = map[string]interface{}{
+				"type":        s.kind,
+				"minimum":     s.min,
+				"maximum":     s.max,
+				"description": descriptions[s.param],
+			}
+		}
+	}
+	return &ConfigureGPUTool{
+		BaseTool: NewBaseTool(
+			"configure_gpu",
+			"Override NeuronDB GPU acceleration settings for this MCP session only: compute mode, backend, device, kernels, batch size, streams, memory pool and timeout. The database defaults and other clients are unaffected; use reset to return settings to the defaults.",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// gpuSettingParams returns the configure_gpu parameters of the settings
+func gpuSettingParams() []interface{} {
+	params := make([]interface{}, len(gpuSettings))
+	for i, s := range gpuSettings {
+		params[i] = s.param
+	}
+	return params
+}
+
+// gpuSettingChanges reads the settings to set and reset from configure_gpu
+// parameters
+func gpuSettingChanges(params map[string]interface{}) (map[string]string, []string, *ToolResult) {
+	set := map[string]string{}
+	for _, s := range gpuSettings {
+		v, ok := params[s.param]
+		if !ok || v == nil {
+			continue
+		}
+		value, err := s.encode(v)
+		if err != nil {
+			return nil, nil, Error(err.Error(), "VALIDATION_ERROR", map[string]interface{}{"parameter": s.param})
+		}
+		set[s.guc] = value
+	}
+
+	var reset []string
+	items, _ := params["reset"].([]interface{})
+	for _, item := range items {
+		name, _ := item.(string)
+		if name == "all" {
+			reset = gpuSettingGUCs()
+			break
+		}
+		found := false
+		for _, s := range gpuSettings {
+			if s.param == name {
+				reset = append(reset, s.guc)
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, Error(fmt.Sprintf("unknown GPU setting '%v' in reset", item), "VALIDATION_ERROR", map[string]interface{}{"parameter": "reset"})
+		}
+	}
+	for _, guc := range reset {
+		if _, ok := set[guc]; ok {
+			s, _ := gpuSettingByGUC(guc)
+			return nil, nil, Error(fmt.Sprintf("%s cannot be both set and reset", s.param), "VALIDATION_ERROR", map[string]interface{}{"parameter": "reset"})
+		}
+	}
+	if len(set) == 0 && len(reset) == 0 {
+		return nil, nil, Error("configure_gpu needs a setting to change or reset", "VALIDATION_ERROR", nil)
+	}
+	return set, reset, nil
+}
+
+// Execute applies the GPU settings to the session
+func (t *ConfigureGPUTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for configure_gpu tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	set, reset, errResult := gpuSettingChanges(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for configure_gpu", "DATABASE_ERROR", nil), nil
+	}
+	settings := db.Settings()
+	if settings == nil {
+		return Error("Session settings are not available on this server", "SESSION_STATE_UNAVAILABLE", nil), nil
+	}
+
+	configCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	if errResult := t.checkSettings(configCtx, db, set); errResult != nil {
+		return errResult, nil
+	}
+
+	names := make([]string, 0, len(set))
+	for guc := range set {
+		names = append(names, guc)
+	}
+	sort.Strings(names)
+	for _, guc := range names {
+		if err := settings.Set(guc, set[guc]); err != nil {
+			return Error(err.Error(), "VALIDATION_ERROR", nil), nil
+		}
+	}
+	resetParams := []string{}
+	for _, guc := range reset {
+		if settings.Reset(guc) {
+			s, _ := gpuSettingByGUC(guc)
+			resetParams = append(resetParams, s.param)
+		}
+	}
+
+	t.logger.Info("GPU session settings changed", map[string]interface{}{
+		"set":   set,
+		"reset": resetParams,
+	})
+	return Success(map[string]interface{}{
+		"session_overrides": gpuSessionOverrides(settings),
+		"reset":             resetParams,
+	}, map[string]interface{}{
+		"scope": "session",
+	}), nil
+}
+
+// checkSettings tries the new values in a transaction that is rolled back,
+// so values the extension rejects are reported instead of breaking every
+// later query of the session
+func (t *ConfigureGPUTool) checkSettings(ctx context.Context, db *database.Database, set map[string]string) *ToolResult {
+	if len(set) == 0 {
+		return nil
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return Error(fmt.Sprintf("configure_gpu failed: error=%v", err), "EXECUTION_ERROR", map[string]interface{}{"error": err.Error()})
+	}
+	defer tx.Rollback(context.Background())
+
+	for guc, value := range set {
+		s, _ := gpuSettingByGUC(guc)
+		var defined bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_settings WHERE name = $1)", guc).Scan(&defined); err != nil {
+			return Error(fmt.Sprintf("configure_gpu failed: error=%v", err), "EXECUTION_ERROR", map[string]interface{}{"error": err.Error()})
+		}
+		if !defined {
+			return Error(fmt.Sprintf("Parameter '%s' is not defined on this server: the NeuronDB library is not loaded (add neurondb to shared_preload_libraries)", guc), "FUNCTION_UNAVAILABLE", map[string]interface{}{
+				"parameter": s.param,
+			})
+		}
+		if _, err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", guc, value); err != nil {
+			return Error(fmt.Sprintf("NeuronDB rejected %s=%s: %v", s.param, value, err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": s.param,
+				"error":     err.Error(),
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+func TestGPUSettingEncode(t *testing.T) {
+	tests := []struct {
+		param string
+		value interface{}
+		want  string
+	}{
+		{"compute_mode", "auto", "2"},
+		{"backend", "rocm", "1"},
+		{"device", float64(3), "3"},
+		{"memory_pool_mb", float64(1024.5), "1024.5"},
+		{"kernels", []interface{}{"l2", "rf_split"}, "l2,rf_split"},
+		{"kernels", []interface{}{}, ""},
+	}
+	for _, tt := range tests {
+		s := gpuSettingByParam(t, tt.param)
+		got, err := s.encode(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("encode(%s=%v) = %q, %v; want %q", tt.param, tt.value, got, err, tt.want)
+		}
+		if s.kind == "enum" && s.decode(got) != tt.value {
+			t.Errorf("decode(%s=%q) = %v, want %v", tt.param, got, s.decode(got), tt.value)
+		}
+	}
+
+	invalid := []struct {
+		param string
+		value interface{}
+	}{
+		{"compute_mode", "fast"},
+		{"device", float64(1.5)},
+		{"streams", float64(9)},
+		{"kernels", []interface{}{"l2; RESET ALL"}},
+		{"kernels", "l2"},
+	}
+	for _, tt := range invalid {
+		if got, err := gpuSettingByParam(t, tt.param).encode(tt.value); err == nil {
+			t.Errorf("encode(%s=%v) = %q, want an error", tt.param, tt.value, got)
+		}
+	}
+
+	if got := gpuSettingByParam(t, "kernels").decode("l2, cosine,,ip"); !reflect.DeepEqual(got, []string{"l2", "cosine", "ip"}) {
+		t.Errorf("decode kernels = %v", got)
+	}
+}
+
+func TestGPUSettingChanges(t *testing.T) {
+	set, reset, errResult := gpuSettingChanges(map[string]interface{}{
+		"compute_mode": "gpu",
+		"device":       float64(1),
+		"reset":        []interface{}{"kernels"},
+	})
+	if errResult != nil {
+		t.Fatalf("unexpected error: %+v", errResult)
+	}
+	if want := map[string]string{"neurondb.compute_mode": "1", "neurondb.gpu_device": "1"}; !reflect.DeepEqual(set, want) {
+		t.Errorf("set = %v, want %v", set, want)
+	}
+	if !reflect.DeepEqual(reset, []string{"neurondb.gpu_kernels"}) {
+		t.Errorf("reset = %v", reset)
+	}
+
+	if _, reset, _ := gpuSettingChanges(map[string]interface{}{"reset": []interface{}{"all"}}); len(reset) != len(gpuSettings) {
+		t.Errorf("reset all = %v", reset)
+	}
+	for _, params := range []map[string]interface{}{
+		{},
+		{"device": float64(1), "reset": []interface{}{"device"}},
+		{"reset": []interface{}{"compute"}},
+	} {
+		if _, _, errResult := gpuSettingChanges(params); errResult == nil {
+			t.Errorf("gpuSettingChanges(%v) succeeded", params)
+		}
+	}
+}
+
+func TestGPUSessionOverrides(t *testing.T) {
+	settings := database.NewSessionSettings()
+	settings.Set("neurondb.compute_mode", "2")
+	settings.Set("neurondb.gpu_kernels", "l2,ip")
+	settings.Set("neurondb.hnsw_ef_search", "80")
+	want := map[string]interface{}{"compute_mode": "auto", "kernels": []string{"l2", "ip"}}
+	if got := gpuSessionOverrides(settings); !reflect.DeepEqual(got, want) {
+		t.Errorf("gpuSessionOverrides = %v, want %v", got, want)
+	}
+}
+
+func gpuSettingByParam(t *testing.T, param string) gpuSetting {
+	t.Helper()
+	for _, s := range gpuSettings {
+		if s.param == param {
+			return s
+		}
+	}
+	t.Fatalf("no GPU setting %s", param)
+	return gpuSetting{}
+}
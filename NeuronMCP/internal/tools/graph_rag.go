@@ -0,0 +1,990 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Graph RAG limits
+const (
+	defaultGraphEntities = 50
+	maxGraphEntities     = 200
+	// maxGraphRelationships bounds the relationships stored per call
+	maxGraphRelationships = 500
+	// maxGraphTextLength bounds the text sent to the model for extraction
+	maxGraphTextLength = 20000
+	// maxGraphDescriptionLength truncates entity and relationship descriptions
+	maxGraphDescriptionLength = 1000
+	// maxGraphLabelLength truncates entity types and relation names
+	maxGraphLabelLength = 64
+	// graphExtractionMaxTokens bounds the model's answer
+	graphExtractionMaxTokens = 4096
+
+	defaultGraphSeeds    = 5
+	maxGraphSeeds        = 100
+	defaultGraphHops     = 2
+	maxGraphHops         = 4
+	defaultGraphMaxNodes = 50
+	maxGraphMaxNodes     = 1000
+	defaultGraphDecay    = 0.5
+	// graphEdgesPerNode bounds the edges returned per returned node
+	graphEdgesPerNode = 5
+)
+
+// graphLabelRe matches the characters dropped from entity types and
+// relation names
+var graphLabelRe = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// graphEntity is an entity to store as a node
+type graphEntity struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// key identifies the entity within the graph
+func (e graphEntity) key() string {
+	return e.Type + "\x00" + graphNameKey(e.Name)
+}
+
+// embeddingText is the text embedded for the entity's node
+func (e graphEntity) embeddingText() string {
+	text := e.Name + " (" + e.Type + ")"
+	if e.Description != "" {
+		text += ": " + e.Description
+	}
+	return text
+}
+
+// graphRelationship is a relationship to store as an edge; Source and
+// Target index the entities it connects
+type graphRelationship struct {
+	Source      int
+	Target      int
+	Relation    string
+	Description string
+	Weight      float64
+}
+
+// graphTables are the node and edge tables of a graph
+type graphTables struct {
+	nodes pgx.Identifier
+	edges pgx.Identifier
+	// edgeTargetIndex is the name of the index on the edges' target_id
+	edgeTargetIndex pgx.Identifier
+}
+
+// parseGraphTables derives the tables of a graph from its optionally
+// schema-qualified name: graph g is stored in g_nodes and g_edges
+func parseGraphTables(graph string) (graphTables, error) {
+	ident, err := parseQualifiedIdentifier(graph)
+	if err != nil {
+		return graphTables{}, err
+	}
+	withSuffix := func(suffix string) pgx.Identifier {
+		id := append(pgx.Identifier{}, ident...)
+		id[len(id)-1] += suffix
+		return id
+	}
+	return graphTables{
+		nodes:           withSuffix("_nodes"),
+		edges:           withSuffix("_edges"),
+		edgeTargetIndex: pgx.Identifier{ident[len(ident)-1] + "_edges_target_id_idx"},
+	}, nil
+}
+
+// createStatements returns the statements creating the graph's tables for
+// node embeddings of the given dimensions
+func (g graphTables) createStatements(dimensions int) []string {
+	nodes := g.nodes.Sanitize()
+	edges := g.edges.Sanitize()
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	name_key TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT,
+	embedding vector(%d),
+	mentions INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (type, name_key)
+)`, nodes, dimensions),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	source_id BIGINT NOT NULL REFERENCES %s (id) ON DELETE CASCADE,
+	target_id BIGINT NOT NULL REFERENCES %s (id) ON DELETE CASCADE,
+	relation TEXT NOT NULL,
+	description TEXT,
+	weight REAL NOT NULL DEFAULT 1,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (source_id, target_id, relation)
+)`, edges, nodes, nodes),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (target_id)", g.edgeTargetIndex.Sanitize(), edges),
+	}
+}
+
+// graphNameKey normalizes an entity name so spellings differing only in
+// case or spacing are the same node
+func graphNameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// graphLabel normalizes an entity type or relation name to lowercase
+// snake_case, e.g. "Works For" becomes "works_for"
+func graphLabel(label string) string {
+	label = strings.ToLower(strings.Join(strings.Fields(label), "_"))
+	label = strings.ReplaceAll(label, "-", "_")
+	label = strings.Trim(graphLabelRe.ReplaceAllString(label, ""), "_")
+	if len(label) > maxGraphLabelLength {
+		label = strings.TrimRight(label[:maxGraphLabelLength], "_")
+	}
+	return label
+}
+
+// truncateGraphText cuts s to max bytes without splitting a UTF-8 sequence
+func truncateGraphText(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// resolveGraphExtraction turns raw entity and relationship objects, from the
+// model or the caller, into entities and the relationships between them.
+// Entities are deduplicated by type and normalized name. Relationship
+// endpoints naming no entity are added with type "entity" while fewer than
+// maxEntities are known. Relationships that cannot be stored are described
+// in skipped.
+func resolveGraphExtraction(rawEntities, rawRelationships []interface{}, maxEntities int) ([]graphEntity, []graphRelationship, []string) {
+	var (
+		entities []graphEntity
+		skipped  []string
+	)
+	byKey := map[string]int{}
+	byName := map[string]int{}
+	add := func(e graphEntity) (int, bool) {
+		if i, ok := byKey[e.key()]; ok {
+			if entities[i].Description == "" {
+				entities[i].Description = e.Description
+			}
+			return i, true
+		}
+		if len(entities) >= maxEntities {
+			return 0, false
+		}
+		entities = append(entities, e)
+		byKey[e.key()] = len(entities) - 1
+		if _, ok := byName[graphNameKey(e.Name)]; !ok {
+			byName[graphNameKey(e.Name)] = len(entities) - 1
+		}
+		return len(entities) - 1, true
+	}
+
+	for i, raw := range rawEntities {
+		obj, _ := raw.(map[string]interface{})
+		name := truncateGraphText(stringParam(obj, "name", ""), maxGraphDescriptionLength)
+		if name == "" {
+			skipped = append(skipped, fmt.Sprintf("entity %d has no name", i))
+			continue
+		}
+		entityType := graphLabel(stringParam(obj, "type", ""))
+		if entityType == "" {
+			entityType = "entity"
+		}
+		if _, ok := add(graphEntity{
+			Name:        name,
+			Type:        entityType,
+			Description: truncateGraphText(stringParam(obj, "description", ""), maxGraphDescriptionLength),
+		}); !ok {
+			skipped = append(skipped, fmt.Sprintf("entity %q exceeds max_entities", name))
+		}
+	}
+
+	endpoint := func(name string) (int, bool) {
+		if i, ok := byName[graphNameKey(name)]; ok {
+			return i, true
+		}
+		return add(graphEntity{Name: name, Type: "entity"})
+	}
+	var relationships []graphRelationship
+	seen := map[[3]string]int{}
+	for i, raw := range rawRelationships {
+		obj, _ := raw.(map[string]interface{})
+		source := truncateGraphText(stringParam(obj, "source", ""), maxGraphDescriptionLength)
+		target := truncateGraphText(stringParam(obj, "target", ""), maxGraphDescriptionLength)
+		if source == "" || target == "" {
+			skipped = append(skipped, fmt.Sprintf("relationship %d needs a source and a target", i))
+			continue
+		}
+		relation := graphLabel(stringParam(obj, "relation", ""))
+		if relation == "" {
+			relation = "related_to"
+		}
+		weight := 1.0
+		if w, ok := obj["weight"].(float64); ok {
+			weight = w
+		}
+		if weight <= 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			skipped = append(skipped, fmt.Sprintf("relationship %d has a weight that is not positive", i))
+			continue
+		}
+		if len(relationships) >= maxGraphRelationships {
+			skipped = append(skipped, fmt.Sprintf("relationship %d exceeds the limit of %d relationships", i, maxGraphRelationships))
+			continue
+		}
+		s, sok := endpoint(source)
+		t, tok := endpoint(target)
+		if !sok || !tok {
+			skipped = append(skipped, fmt.Sprintf("relationship %q -%s-> %q names an entity beyond max_entities", source, relation, target))
+			continue
+		}
+		if s == t {
+			skipped = append(skipped, fmt.Sprintf("relationship %q -%s-> %q connects an entity to itself", source, relation, target))
+			continue
+		}
+		// A relationship repeated in one call counts once per mention
+		k := [3]string{entities[s].key(), entities[t].key(), relation}
+		if j, ok := seen[k]; ok {
+			relationships[j].Weight += weight
+			continue
+		}
+		seen[k] = len(relationships)
+		relationships = append(relationships, graphRelationship{
+			Source:      s,
+			Target:      t,
+			Relation:    relation,
+			Description: truncateGraphText(stringParam(obj, "description", ""), maxGraphDescriptionLength),
+			Weight:      weight,
+		})
+	}
+	return entities, relationships, skipped
+}
+
+// parseGraphExtractionReply extracts the entities and relationships from a
+// model reply, tolerating text and code fences around the JSON object
+func parseGraphExtractionReply(text string) ([]interface{}, []interface{}, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, nil, errors.New("no JSON object in the model reply")
+	}
+	var reply struct {
+		Entities      []interface{} `json:"entities"`
+		Relationships []interface{} `json:"relationships"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &reply); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON in the model reply: %w", err)
+	}
+	return reply.Entities, reply.Relationships, nil
+}
+
+// buildGraphExtractionPrompt returns the instructions for extracting a graph
+// from text
+func buildGraphExtractionPrompt(maxEntities int, entityTypes []string) string {
+	var b strings.Builder
+	b.WriteString("You extract a knowledge graph from text. Reply with only a JSON object of the form ")
+	b.WriteString(`{"entities": [{"name": "...", "type": "...", "description": "..."}], "relationships": [{"source": "...", "target": "...", "relation": "...", "description": "..."}]}. `)
+	if len(entityTypes) > 0 {
+		fmt.Fprintf(&b, "Entity types are one of: %s. ", strings.Join(entityTypes, ", "))
+	} else {
+		b.WriteString("Entity types are short lowercase nouns such as person, organization, location, product, event or concept. ")
+	}
+	b.WriteString("Descriptions are one sentence based only on the text. ")
+	b.WriteString("Relations are short snake_case verb phrases such as works_for or located_in, and the source and target of a relationship are entity names. ")
+	fmt.Fprintf(&b, "List at most %d entities, the most important first.", maxEntities)
+	return b.String()
+}
+
+// ExtractEntitiesTool stores entities and relationships as a graph
+type ExtractEntitiesTool struct {
+	*BaseTool
+	db       *database.Database
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewExtractEntitiesTool creates a new entity extraction tool
+func NewExtractEntitiesTool(db *database.Database, logger *logging.Logger) *ExtractEntitiesTool {
+	return &ExtractEntitiesTool{
+		BaseTool: NewBaseTool(
+			"extract_entities",
+			"Extract entities and relationships from text with an LLM, or take them as given, and store them as an embedded entity-relationship graph in the tables <graph>_nodes and <graph>_edges, created on first use. Entities already in the graph are merged by type and name; repeated relationships add to their weight.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"graph": map[string]interface{}{
+						"type":        "string",
+						"description": "Graph name, optionally schema-qualified; the graph is stored in <graph>_nodes and <graph>_edges",
+					},
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "Text to extract entities and relationships from; the first 20000 characters are used",
+					},
+					"entities": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"name":        map[string]interface{}{"type": "string"},
+								"type":        map[string]interface{}{"type": "string"},
+								"description": map[string]interface{}{"type": "string"},
+							},
+							"required": []interface{}{"name"},
+						},
+						"description": "Entities to store instead of extracting them from text",
+					},
+					"relationships": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"source":      map[string]interface{}{"type": "string"},
+								"target":      map[string]interface{}{"type": "string"},
+								"relation":    map[string]interface{}{"type": "string"},
+								"description": map[string]interface{}{"type": "string"},
+								"weight":      map[string]interface{}{"type": "number"},
+							},
+							"required": []interface{}{"source", "target"},
+						},
+						"description": "Relationships to store, by entity name; endpoints missing from entities are added with type entity",
+					},
+					"entity_types": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Entity types the model may use when extracting from text",
+					},
+					"max_entities": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGraphEntities,
+						"minimum":     1,
+						"maximum":     maxGraphEntities,
+						"description": "Maximum number of entities stored per call",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"default":     "default",
+						"description": "Embedding model for the node embeddings",
+					},
+					"llm_model": map[string]interface{}{
+						"type":        "string",
+						"description": "LLM model used for extraction; defaults to the neurondb.llm_model setting",
+					},
+					"provider": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"auto", "database", "client"},
+						"default":     "auto",
+						"description": "database: neurondb.llm() in NeuronDB; client: the MCP client's model via sampling; auto: database, falling back to the client when the database call fails",
+					},
+				},
+				"required": []interface{}{"graph"},
+			},
+		),
+		db:       db,
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute extracts the graph and stores it
+func (t *ExtractEntitiesTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for extract_entities tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+			"params": params,
+		}), nil
+	}
+	graph := stringParam(params, "graph", "")
+	tables, err := parseGraphTables(graph)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid graph name %q: %v", graph, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "graph",
+		}), nil
+	}
+	text := truncateGraphText(stringParam(params, "text", ""), maxGraphTextLength)
+	rawEntities, _ := params["entities"].([]interface{})
+	rawRelationships, _ := params["relationships"].([]interface{})
+	if text == "" && len(rawEntities) == 0 && len(rawRelationships) == 0 {
+		return Error("extract_entities needs text, entities or relationships", "VALIDATION_ERROR", nil), nil
+	}
+	maxEntities := defaultGraphEntities
+	if v, ok := params["max_entities"].(float64); ok {
+		maxEntities = int(v)
+	}
+	model := stringParam(params, "model", "default")
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available for extract_entities tool", "DATABASE_ERROR", nil), nil
+	}
+
+	start := time.Now()
+	provider := ""
+	if len(rawEntities) == 0 && len(rawRelationships) == 0 {
+		var failed *ToolResult
+		rawEntities, rawRelationships, provider, failed = t.extract(ctx, params, text, maxEntities)
+		if failed != nil {
+			return failed, nil
+		}
+	}
+	extractMs := msSince(start)
+
+	entities, relationships, skipped := resolveGraphExtraction(rawEntities, rawRelationships, maxEntities)
+	if len(entities) == 0 {
+		return Error("No entities to store", "VALIDATION_ERROR", map[string]interface{}{
+			"skipped": skipped,
+		}), nil
+	}
+
+	embedStart := time.Now()
+	texts := make([]string, len(entities))
+	for i, e := range entities {
+		texts[i] = e.embeddingText()
+	}
+	vectors, err := embedBatch(ctx, t.executor, model, texts)
+	if err != nil {
+		t.logger.Error("Embedding graph entities failed", err, map[string]interface{}{
+			"graph": graph,
+			"model": model,
+		})
+		return Error(fmt.Sprintf("Failed to embed entities with model %q: %v", model, err), "EMBEDDING_ERROR", map[string]interface{}{
+			"model": model,
+			"error": err.Error(),
+		}), nil
+	}
+	embedMs := msSince(embedStart)
+
+	storeStart := time.Now()
+	nodeIDs, nodesInserted, edgesInserted, err := storeGraph(ctx, db, tables, entities, relationships, vectors)
+	if err != nil {
+		t.logger.Error("Storing graph failed", err, map[string]interface{}{
+			"graph": graph,
+		})
+		return Error(fmt.Sprintf("Failed to store graph %s: %v", graph, err), "QUERY_ERROR", map[string]interface{}{
+			"graph": graph,
+			"error": err.Error(),
+		}), nil
+	}
+
+	nodes := make([]map[string]interface{}, len(entities))
+	for i, e := range entities {
+		nodes[i] = map[string]interface{}{
+			"id":   nodeIDs[i],
+			"name": e.Name,
+			"type": e.Type,
+		}
+	}
+	edges := make([]map[string]interface{}, len(relationships))
+	for i, r := range relationships {
+		edges[i] = map[string]interface{}{
+			"source":   entities[r.Source].Name,
+			"target":   entities[r.Target].Name,
+			"relation": r.Relation,
+		}
+	}
+	if skipped == nil {
+		skipped = []string{}
+	}
+	result := map[string]interface{}{
+		"graph":          graph,
+		"nodes_table":    tables.nodes.Sanitize(),
+		"edges_table":    tables.edges.Sanitize(),
+		"nodes":          nodes,
+		"edges":          edges,
+		"nodes_inserted": nodesInserted,
+		"nodes_updated":  len(entities) - nodesInserted,
+		"edges_inserted": edgesInserted,
+		"edges_updated":  len(relationships) - edgesInserted,
+		"skipped":        skipped,
+		"timings_ms": map[string]interface{}{
+			"extract": extractMs,
+			"embed":   embedMs,
+			"store":   msSince(storeStart),
+			"total":   msSince(start),
+		},
+	}
+	metadata := map[string]interface{}{
+		"model": model,
+	}
+	if provider != "" {
+		result["provider"] = provider
+		metadata["provider"] = provider
+	}
+	return Success(result, metadata), nil
+}
+
+// extract asks the model for the graph in text and returns the raw entities
+// and relationships with the provider that answered
+func (t *ExtractEntitiesTool) extract(ctx context.Context, params map[string]interface{}, text string, maxEntities int) ([]interface{}, []interface{}, string, *ToolResult) {
+	provider := stringParam(params, "provider", "auto")
+	llmModel := stringParam(params, "llm_model", "")
+	var entityTypes []string
+	if raw, ok := params["entity_types"].([]interface{}); ok {
+		for _, v := range raw {
+			if s := graphLabel(fmt.Sprint(v)); s != "" {
+				entityTypes = append(entityTypes, s)
+			}
+		}
+	}
+	instructions := buildGraphExtractionPrompt(maxEntities, entityTypes)
+
+	sampler, hasSampler := SamplerFromContext(ctx)
+	if provider == "client" && !hasSampler {
+		return nil, nil, "", Error("provider 'client' requires an MCP client that supports sampling", "SAMPLING_UNAVAILABLE", map[string]interface{}{
+			"provider": provider,
+		})
+	}
+
+	var (
+		answer string
+		err    error
+		dbErr  error
+	)
+	if provider != "client" {
+		answer, dbErr = t.extractWithDatabase(ctx, llmModel, instructions+"\n\nText:\n"+text)
+		if dbErr != nil && provider == "auto" && hasSampler {
+			t.logger.Warn("Database LLM entity extraction failed, falling back to client sampling", map[string]interface{}{
+				"error": dbErr.Error(),
+			})
+			provider = "client"
+		} else {
+			provider = "database"
+			err = dbErr
+		}
+	}
+	if provider == "client" {
+		answer, err = extractWithSampler(ctx, sampler, llmModel, instructions, text)
+	}
+	if err == nil {
+		var entities, relationships []interface{}
+		entities, relationships, err = parseGraphExtractionReply(answer)
+		if err == nil {
+			return entities, relationships, provider, nil
+		}
+	}
+
+	t.logger.Error("Entity extraction failed", err, map[string]interface{}{
+		"provider": provider,
+	})
+	details := map[string]interface{}{
+		"provider": provider,
+		"error":    err.Error(),
+	}
+	if dbErr != nil && provider == "client" {
+		details["database_error"] = dbErr.Error()
+	}
+	return nil, nil, "", Error(fmt.Sprintf("Entity extraction failed: %v", err), "LLM_ERROR", details)
+}
+
+// extractWithDatabase runs the extraction prompt with neurondb.llm
+func (t *ExtractEntitiesTool) extractWithDatabase(ctx context.Context, model, prompt string) (string, error) {
+	var modelParam interface{}
+	if model != "" {
+		modelParam = model
+	}
+	llmParams := fmt.Sprintf(`{"temperature": 0, "do_sample": false, "max_tokens": %d}`, graphExtractionMaxTokens)
+	row, err := t.executor.ExecuteQueryOneWithTimeout(ctx,
+		`SELECT neurondb.llm('complete', $1, $2, NULL, $3::jsonb, $4) AS response`,
+		[]interface{}{modelParam, prompt, llmParams, graphExtractionMaxTokens}, EmbeddingQueryTimeout)
+	if err != nil {
+		return "", err
+	}
+	return llmResponseText(row["response"])
+}
+
+// extractWithSampler runs the extraction prompt with the client's model
+func extractWithSampler(ctx context.Context, sampler Sampler, model, instructions, text string) (string, error) {
+	temperature := 0.0
+	sampled, err := sampler.Sample(ctx, &SamplingRequest{
+		SystemPrompt: instructions,
+		Messages:     []SamplingMessage{{Role: "user", Text: text}},
+		MaxTokens:    graphExtractionMaxTokens,
+		Temperature:  &temperature,
+		ModelHint:    model,
+	})
+	if err != nil {
+		return "", err
+	}
+	return sampled.Text, nil
+}
+
+// storeGraph creates the graph's tables if needed and upserts the entities
+// and relationships in one transaction. It returns the node id of each
+// entity and how many nodes and edges were new.
+func storeGraph(ctx context.Context, db *database.Database, tables graphTables, entities []graphEntity, relationships []graphRelationship, vectors [][]float32) ([]int64, int, int, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	tx, err := db.Begin(queryCtx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer tx.Rollback(queryCtx)
+
+	for _, stmt := range tables.createStatements(len(vectors[0])) {
+		if _, err := tx.Exec(queryCtx, stmt); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	// A new description replaces the node's embedding along with it; the
+	// embedding of a node mentioned without one is kept
+	nodeQuery := fmt.Sprintf(`INSERT INTO %s AS n (name, name_key, type, description, embedding)
+VALUES ($1, $2, $3, NULLIF($4, ''), $5::vector)
+ON CONFLICT (type, name_key) DO UPDATE SET
+	description = COALESCE(EXCLUDED.description, n.description),
+	embedding = CASE WHEN EXCLUDED.description IS NOT NULL OR n.description IS NULL THEN EXCLUDED.embedding ELSE n.embedding END,
+	mentions = n.mentions + 1,
+	updated_at = NOW()
+RETURNING id, (xmax = 0) AS inserted`, tables.nodes.Sanitize())
+	ids := make([]int64, len(entities))
+	nodesInserted := 0
+	for i, e := range entities {
+		var inserted bool
+		if err := tx.QueryRow(queryCtx, nodeQuery, e.Name, graphNameKey(e.Name), e.Type, e.Description, formatFloat32Vector(vectors[i])).Scan(&ids[i], &inserted); err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to store entity %q: %w", e.Name, err)
+		}
+		if inserted {
+			nodesInserted++
+		}
+	}
+
+	edgeQuery := fmt.Sprintf(`INSERT INTO %s AS e (source_id, target_id, relation, description, weight)
+VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+ON CONFLICT (source_id, target_id, relation) DO UPDATE SET
+	weight = e.weight + EXCLUDED.weight,
+	description = COALESCE(EXCLUDED.description, e.description),
+	updated_at = NOW()
+RETURNING (xmax = 0) AS inserted`, tables.edges.Sanitize())
+	edgesInserted := 0
+	for _, r := range relationships {
+		var inserted bool
+		if err := tx.QueryRow(queryCtx, edgeQuery, ids[r.Source], ids[r.Target], r.Relation, r.Description, r.Weight).Scan(&inserted); err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to store relationship %q -%s-> %q: %w", entities[r.Source].Name, r.Relation, entities[r.Target].Name, err)
+		}
+		if inserted {
+			edgesInserted++
+		}
+	}
+	if err := tx.Commit(queryCtx); err != nil {
+		return nil, 0, 0, err
+	}
+	return ids, nodesInserted, edgesInserted, nil
+}
+
+// GraphNeighborhoodSearchTool finds the graph nodes closest to a query and
+// their neighborhoods
+type GraphNeighborhoodSearchTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewGraphNeighborhoodSearchTool creates a new graph neighborhood search tool
+func NewGraphNeighborhoodSearchTool(db *database.Database, logger *logging.Logger) *GraphNeighborhoodSearchTool {
+	return &GraphNeighborhoodSearchTool{
+		BaseTool: NewBaseTool(
+			"graph_neighborhood_search",
+			"Search a graph stored by extract_entities: the nodes most similar to the query are the seeds, and nodes up to hops edges away from a seed, in either direction, are returned with the edges between them. A node scores its seed's cosine similarity times decay^hops. The result includes a text context ready for a RAG prompt.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"graph": map[string]interface{}{
+						"type":        "string",
+						"description": "Graph name, optionally schema-qualified, as given to extract_entities",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Query text, embedded with model",
+					},
+					"query_vector": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "number"},
+						"description": "Query embedding, instead of query",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"default":     "default",
+						"description": "Embedding model for the query; use the model the graph was stored with",
+					},
+					"top_k": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGraphSeeds,
+						"minimum":     1,
+						"maximum":     maxGraphSeeds,
+						"description": "Number of seed nodes found by vector similarity",
+					},
+					"hops": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGraphHops,
+						"minimum":     0,
+						"maximum":     maxGraphHops,
+						"description": "Number of edges followed from the seeds",
+					},
+					"max_nodes": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGraphMaxNodes,
+						"minimum":     1,
+						"maximum":     maxGraphMaxNodes,
+						"description": "Maximum number of nodes returned, best scores first",
+					},
+					"relations": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Only follow edges with these relations",
+					},
+					"entity_types": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Only use nodes of these types as seeds",
+					},
+					"min_similarity": map[string]interface{}{
+						"type":        "number",
+						"minimum":     -1,
+						"maximum":     1,
+						"description": "Minimum cosine similarity of a seed to the query",
+					},
+					"decay": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultGraphDecay,
+						"minimum":     0,
+						"maximum":     1,
+						"description": "Score factor per hop from the seed",
+					},
+				},
+				"required": []interface{}{"graph"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// graphNeighborhoodQuery returns the query walking the graph from the seeds.
+// Parameters: $1 query vector, $2 seed count, $3 seed types, $4 hops,
+// $5 relations, $6 decay, $7 minimum seed similarity, $8 node limit.
+func graphNeighborhoodQuery(tables graphTables) string {
+	return fmt.Sprintf(`WITH RECURSIVE seeds AS (
+	SELECT id, 1 - (embedding <=> $1::vector) AS similarity
+	FROM %[1]s
+	WHERE embedding IS NOT NULL AND ($3::text[] IS NULL OR type = ANY($3::text[]))
+	ORDER BY embedding <=> $1::vector
+	LIMIT $2
+), walk(node_id, seed_id, similarity, depth) AS (
+	SELECT id, id, similarity, 0 FROM seeds WHERE $7::float8 IS NULL OR similarity >= $7::float8
+	UNION
+	SELECT CASE WHEN e.source_id = w.node_id THEN e.target_id ELSE e.source_id END, w.seed_id, w.similarity, w.depth + 1
+	FROM walk w
+	JOIN %[2]s e ON w.node_id IN (e.source_id, e.target_id)
+	WHERE w.depth < $4 AND ($5::text[] IS NULL OR e.relation = ANY($5::text[]))
+), ranked AS (
+	SELECT DISTINCT ON (node_id) node_id, seed_id, similarity, depth, similarity * power($6::float8, depth) AS score
+	FROM walk
+	ORDER BY node_id, similarity * power($6::float8, depth) DESC, depth
+)
+SELECT n.id, n.name, n.type, n.description, n.mentions, r.depth, s.name AS seed, r.similarity AS seed_similarity, r.score
+FROM ranked r
+JOIN %[1]s n ON n.id = r.node_id
+JOIN %[1]s s ON s.id = r.seed_id
+ORDER BY r.score DESC, r.depth, n.id
+LIMIT $8`, tables.nodes.Sanitize(), tables.edges.Sanitize())
+}
+
+// graphEdgesQuery returns the query for the edges between nodes.
+// Parameters: $1 node ids, $2 relations, $3 edge limit.
+func graphEdgesQuery(tables graphTables) string {
+	return fmt.Sprintf(`SELECT e.source_id, e.target_id, e.relation, e.description, e.weight
+FROM %s e
+WHERE e.source_id = ANY($1::bigint[]) AND e.target_id = ANY($1::bigint[])
+	AND ($2::text[] IS NULL OR e.relation = ANY($2::text[]))
+ORDER BY e.weight DESC, e.id
+LIMIT $3`, tables.edges.Sanitize())
+}
+
+// graphLabelsParam normalizes a list of entity types or relations, returning
+// nil, which matches everything, when the list is empty
+func graphLabelsParam(params map[string]interface{}, name string) []string {
+	raw, _ := params[name].([]interface{})
+	var labels []string
+	for _, v := range raw {
+		if s := graphLabel(fmt.Sprint(v)); s != "" {
+			labels = append(labels, s)
+		}
+	}
+	return labels
+}
+
+// buildGraphContext renders nodes and edges as text for a RAG prompt
+func buildGraphContext(nodes, edges []map[string]interface{}, names map[int64]string) string {
+	var b strings.Builder
+	b.WriteString("Entities:\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "- %v (%v)", n["name"], n["type"])
+		if d, ok := n["description"].(string); ok && d != "" {
+			b.WriteString(": " + d)
+		}
+		b.WriteString("\n")
+	}
+	if len(edges) > 0 {
+		b.WriteString("Relationships:\n")
+		for _, e := range edges {
+			source, _ := e["source_id"].(int64)
+			target, _ := e["target_id"].(int64)
+			fmt.Fprintf(&b, "- %s %v %s", names[source], e["relation"], names[target])
+			if d, ok := e["description"].(string); ok && d != "" {
+				b.WriteString(": " + d)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Execute runs the neighborhood search
+func (t *GraphNeighborhoodSearchTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for graph_neighborhood_search tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+			"params": params,
+		}), nil
+	}
+	graph := stringParam(params, "graph", "")
+	tables, err := parseGraphTables(graph)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid graph name %q: %v", graph, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "graph",
+		}), nil
+	}
+	query := stringParam(params, "query", "")
+	rawVector, _ := params["query_vector"].([]interface{})
+	if (query == "") == (len(rawVector) == 0) {
+		return Error("Exactly one of query and query_vector is required", "VALIDATION_ERROR", nil), nil
+	}
+	model := stringParam(params, "model", "default")
+	topK, hops, maxNodes, decay := defaultGraphSeeds, defaultGraphHops, defaultGraphMaxNodes, defaultGraphDecay
+	if v, ok := params["top_k"].(float64); ok {
+		topK = int(v)
+	}
+	if v, ok := params["hops"].(float64); ok {
+		hops = int(v)
+	}
+	if v, ok := params["max_nodes"].(float64); ok {
+		maxNodes = int(v)
+	}
+	if v, ok := params["decay"].(float64); ok {
+		decay = v
+	}
+	var minSimilarity interface{}
+	if v, ok := params["min_similarity"].(float64); ok {
+		minSimilarity = v
+	}
+	relations := graphLabelsParam(params, "relations")
+	entityTypes := graphLabelsParam(params, "entity_types")
+
+	start := time.Now()
+	var vector string
+	if query != "" {
+		vectors, err := embedBatch(ctx, t.executor, model, []string{query})
+		if err != nil {
+			t.logger.Error("Embedding graph query failed", err, map[string]interface{}{
+				"model": model,
+			})
+			return Error(fmt.Sprintf("Failed to embed query with model %q: %v", model, err), "EMBEDDING_ERROR", map[string]interface{}{
+				"model": model,
+				"error": err.Error(),
+			}), nil
+		}
+		vector = formatFloat32Vector(vectors[0])
+	} else {
+		vec := make([]float32, len(rawVector))
+		for i, v := range rawVector {
+			f, ok := v.(float64)
+			if !ok {
+				return Error(fmt.Sprintf("query_vector element %d is not a number", i), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "query_vector",
+				}), nil
+			}
+			vec[i] = float32(f)
+		}
+		vector = formatFloat32Vector(vec)
+	}
+	embedMs := msSince(start)
+
+	searchStart := time.Now()
+	nodes, err := t.executor.ExecuteQuery(ctx, graphNeighborhoodQuery(tables),
+		[]interface{}{vector, topK, entityTypes, hops, relations, decay, minSimilarity, maxNodes})
+	if err != nil {
+		t.logger.Error("Graph neighborhood search failed", err, map[string]interface{}{
+			"graph": graph,
+		})
+		return Error(fmt.Sprintf("Graph neighborhood search on %s failed: %v", graph, err), "QUERY_ERROR", map[string]interface{}{
+			"graph": graph,
+			"error": err.Error(),
+		}), nil
+	}
+	ids := make([]int64, 0, len(nodes))
+	names := make(map[int64]string, len(nodes))
+	for _, n := range nodes {
+		if id, ok := n["id"].(int64); ok {
+			ids = append(ids, id)
+			names[id], _ = n["name"].(string)
+		}
+	}
+	edges := []map[string]interface{}{}
+	if len(ids) > 1 {
+		edges, err = t.executor.ExecuteQuery(ctx, graphEdgesQuery(tables), []interface{}{ids, relations, graphEdgesPerNode * maxNodes})
+		if err != nil {
+			t.logger.Error("Graph edge lookup failed", err, map[string]interface{}{
+				"graph": graph,
+			})
+			return Error(fmt.Sprintf("Failed to read the edges of %s: %v", graph, err), "QUERY_ERROR", map[string]interface{}{
+				"graph": graph,
+				"error": err.Error(),
+			}), nil
+		}
+		for _, e := range edges {
+			source, _ := e["source_id"].(int64)
+			target, _ := e["target_id"].(int64)
+			e["source"] = names[source]
+			e["target"] = names[target]
+		}
+	}
+	if nodes == nil {
+		nodes = []map[string]interface{}{}
+	}
+
+	return Success(map[string]interface{}{
+		"graph":      graph,
+		"nodes":      nodes,
+		"edges":      edges,
+		"node_count": len(nodes),
+		"edge_count": len(edges),
+		"context":    buildGraphContext(nodes, edges, names),
+		"timings_ms": map[string]interface{}{
+			"embed":  embedMs,
+			"search": msSince(searchStart),
+			"total":  msSince(start),
+		},
+	}, map[string]interface{}{
+		"graph": graph,
+		"hops":  hops,
+	}), nil
+}
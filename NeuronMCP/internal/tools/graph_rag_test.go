@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGraphTables(t *testing.T) {
+	tables, err := parseGraphTables("kg.people")
+	if err != nil {
+		t.Fatalf("parseGraphTables: %v", err)
+	}
+	if got := tables.nodes.Sanitize(); got != `"kg"."people_nodes"` {
+		t.Errorf("nodes table = %s", got)
+	}
+	if got := tables.edges.Sanitize(); got != `"kg"."people_edges"` {
+		t.Errorf("edges table = %s", got)
+	}
+	stmts := tables.createStatements(384)
+	if len(stmts) != 3 || !strings.Contains(stmts[0], "vector(384)") || !strings.Contains(stmts[1], `REFERENCES "kg"."people_nodes" (id)`) {
+		t.Errorf("create statements = %q", stmts)
+	}
+	if !strings.HasPrefix(stmts[2], `CREATE INDEX IF NOT EXISTS "people_edges_target_id_idx" ON "kg"."people_edges"`) {
+		t.Errorf("index statement = %q", stmts[2])
+	}
+	for _, bad := range []string{"", "a.b.c", "a."} {
+		if _, err := parseGraphTables(bad); err == nil {
+			t.Errorf("parseGraphTables(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGraphLabel(t *testing.T) {
+	for in, want := range map[string]string{
+		"Works For":      "works_for",
+		"located-in":     "located_in",
+		"  Person ":      "person",
+		"CEO of (since)": "ceo_of_since",
+		"!!!":            "",
+	} {
+		if got := graphLabel(in); got != want {
+			t.Errorf("graphLabel(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := graphNameKey("  Ada   LOVELACE "); got != "ada lovelace" {
+		t.Errorf("graphNameKey = %q", got)
+	}
+	if got := truncateGraphText("héllo", 2); got != "h" {
+		t.Errorf("truncateGraphText split a rune: %q", got)
+	}
+}
+
+func TestParseGraphExtractionReply(t *testing.T) {
+	reply := "Here is the graph:\n```json\n" +
+		`{"entities": [{"name": "Ada Lovelace", "type": "person"}], "relationships": [{"source": "Ada Lovelace", "target": "Analytical Engine", "relation": "wrote about"}]}` +
+		"\n```"
+	entities, relationships, err := parseGraphExtractionReply(reply)
+	if err != nil {
+		t.Fatalf("parseGraphExtractionReply: %v", err)
+	}
+	if len(entities) != 1 || len(relationships) != 1 {
+		t.Errorf("got %d entities and %d relationships, want 1 and 1", len(entities), len(relationships))
+	}
+	if _, _, err := parseGraphExtractionReply("no graph here"); err == nil {
+		t.Error("parsed a reply without JSON")
+	}
+}
+
+func TestResolveGraphExtraction(t *testing.T) {
+	entities := []interface{}{
+		map[string]interface{}{"name": "Ada Lovelace", "type": "Person"},
+		map[string]interface{}{"name": "ada  lovelace", "type": "person", "description": "Mathematician"},
+		map[string]interface{}{"name": ""},
+		map[string]interface{}{"name": "London", "type": "location"},
+	}
+	relationships := []interface{}{
+		map[string]interface{}{"source": "Ada Lovelace", "target": "Analytical Engine", "relation": "Wrote About"},
+		map[string]interface{}{"source": "ADA LOVELACE", "target": "analytical engine", "relation": "wrote_about", "weight": 2.0},
+		map[string]interface{}{"source": "London", "target": "london"},
+		map[string]interface{}{"source": "Ada Lovelace", "target": "London", "weight": -1.0},
+		map[string]interface{}{"source": "Ada Lovelace", "target": "Charles Babbage"},
+	}
+	resolved, edges, skipped := resolveGraphExtraction(entities, relationships, 3)
+
+	if len(resolved) != 3 {
+		t.Fatalf("entities = %+v, want Ada Lovelace, London and Analytical Engine", resolved)
+	}
+	if resolved[0].Type != "person" || resolved[0].Description != "Mathematician" {
+		t.Errorf("merged entity = %+v", resolved[0])
+	}
+	if resolved[2].Name != "Analytical Engine" || resolved[2].Type != "entity" {
+		t.Errorf("relationship endpoint entity = %+v", resolved[2])
+	}
+	if len(edges) != 1 || edges[0].Relation != "wrote_about" || edges[0].Weight != 3 || edges[0].Source != 0 || edges[0].Target != 2 {
+		t.Errorf("relationships = %+v, want one wrote_about edge of weight 3", edges)
+	}
+	// The empty name, the self-loop, the negative weight and the endpoint
+	// beyond max_entities are skipped
+	if len(skipped) != 4 {
+		t.Errorf("skipped = %q", skipped)
+	}
+}
+
+func TestBuildGraphContext(t *testing.T) {
+	nodes := []map[string]interface{}{
+		{"id": int64(1), "name": "Ada Lovelace", "type": "person", "description": "Mathematician"},
+		{"id": int64(2), "name": "Analytical Engine", "type": "entity"},
+	}
+	edges := []map[string]interface{}{
+		{"source_id": int64(1), "target_id": int64(2), "relation": "wrote_about"},
+	}
+	got := buildGraphContext(nodes, edges, map[int64]string{1: "Ada Lovelace", 2: "Analytical Engine"})
+	want := "Entities:\n- Ada Lovelace (person): Mathematician\n- Analytical Engine (entity)\nRelationships:\n- Ada Lovelace wrote_about Analytical Engine\n"
+	if got != want {
+		t.Errorf("context = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// healthPingTimeout bounds the connectivity check of database_health
+const healthPingTimeout = 5 * time.Second
+
+// DatabaseHealthTool reports database connectivity, pool usage and the state
+// of the query circuit breaker
+type DatabaseHealthTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewDatabaseHealthTool creates a new database health tool
+func NewDatabaseHealthTool(db *database.Database, logger *logging.Logger) *DatabaseHealthTool {
+	return &DatabaseHealthTool{
+		BaseTool: NewBaseTool(
+			"database_health",
+			"Check database connectivity and report connection pool usage, prepared statement cache counters, the query circuit breaker state and the retry policy for transient errors",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+				"required":   []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute pings the database and reports its health. The ping bypasses the
+// circuit breaker, so it shows whether the database is back while the
+// circuit is still open.
+func (t *DatabaseHealthTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil {
+		return Error("Database health check failed: database instance is nil", "HEALTH_ERROR", nil), nil
+	}
+
+	circuit := db.Breaker().State()
+	result := map[string]interface{}{
+		"connected": db.IsConnected(),
+		"circuit":   circuit,
+		"retry_policy": map[string]interface{}{
+			"max_attempts":  database.DefaultRetryPolicy.MaxAttempts,
+			"base_delay_ms": database.DefaultRetryPolicy.BaseDelay.Milliseconds(),
+			"max_delay_ms":  database.DefaultRetryPolicy.MaxDelay.Milliseconds(),
+		},
+	}
+	if stats := db.GetPoolStats(); stats != nil {
+		result["pool"] = map[string]interface{}{
+			"total_conns":        stats.TotalConns,
+			"acquired_conns":     stats.AcquiredConns,
+			"idle_conns":         stats.IdleConns,
+			"constructing_conns": stats.ConstructingConns,
+			"max_conns":          stats.MaxConns,
+		}
+	}
+
+	if db.IsConnected() {
+		result["prepared_statements"] = db.StatementStats()
+	}
+
+	status := "healthy"
+	pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+	start := time.Now()
+	if err := db.TestConnection(pingCtx); err != nil {
+		status = "unhealthy"
+		result["ping_error"] = err.Error()
+		t.logger.Warn("Database health check failed", map[string]interface{}{
+			"error":         err.Error(),
+			"circuit_state": circuit.State,
+		})
+	} else {
+		result["ping_ms"] = float64(time.Since(start).Microseconds()) / 1000
+		if circuit.State != database.CircuitClosed {
+			status = "degraded"
+		}
+	}
+	result["status"] = status
+
+	return Success(result, map[string]interface{}{
+		"tool": "database_health",
+	}), nil
+}
@@ -136,9 +136,16 @@ func (t *CreateHNSWIndexTool) Execute(ctx context.Context, params map[string]int
 	// neurondb.create_index(table_name, vector_col, index_type, params)
 	paramsJSON := fmt.Sprintf(`{"m": %d, "ef_construction": %d}`, m, efConstruction)
 	query := `SELECT neurondb.create_index($1, $2, $3, $4::jsonb) AS result`
-	result, err := t.executor.ExecuteQueryOne(ctx, query, []interface{}{
-		table, vectorColumn, "hnsw", paramsJSON,
-	})
+	queryParams := []interface{}{table, vectorColumn, "hnsw", paramsJSON}
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, t.executor.database(ctx), t.Name(), []PlannedStatement{
+			{SQL: query, Params: queryParams, rowsOf: table, Note: "builds an index over every row of the table"},
+		}, []Permission{
+			tablePermission("OWNER", table),
+			functionPermission("neurondb.create_index"),
+		}), nil
+	}
+	result, err := t.executor.ExecuteQueryOne(ctx, query, queryParams)
 	if err != nil {
 		t.logger.Error("HNSW index creation failed", err, params)
 		return Error(fmt.Sprintf("HNSW index creation execution failed: table='%s', vector_column='%s', index_name='%s', m=%d, ef_construction=%d, error=%v", table, vectorColumn, indexName, m, efConstruction, err), "INDEX_ERROR", map[string]interface{}{
@@ -259,9 +266,16 @@ func (t *CreateIVFIndexTool) Execute(ctx context.Context, params map[string]inte
 	// neurondb.create_index(table_name, vector_col, index_type, params)
 	paramsJSON := fmt.Sprintf(`{"num_lists": %d}`, numLists)
 	query := `SELECT neurondb.create_index($1, $2, $3, $4::jsonb) AS result`
-	result, err := t.executor.ExecuteQueryOne(ctx, query, []interface{}{
-		table, vectorColumn, "ivf", paramsJSON,
-	})
+	queryParams := []interface{}{table, vectorColumn, "ivf", paramsJSON}
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, t.executor.database(ctx), t.Name(), []PlannedStatement{
+			{SQL: query, Params: queryParams, rowsOf: table, Note: "builds an index over every row of the table"},
+		}, []Permission{
+			tablePermission("OWNER", table),
+			functionPermission("neurondb.create_index"),
+		}), nil
+	}
+	result, err := t.executor.ExecuteQueryOne(ctx, query, queryParams)
 	if err != nil {
 		t.logger.Error("IVF index creation failed", err, params)
 		return Error(fmt.Sprintf("IVF index creation execution failed: table='%s', vector_column='%s', index_name='%s', num_lists=%d, error=%v", table, vectorColumn, indexName, numLists, err), "INDEX_ERROR", map[string]interface{}{
@@ -411,6 +425,13 @@ func (t *DropIndexTool) Execute(ctx context.Context, params map[string]interface
 	escapedName := database.EscapeIdentifier(indexName)
 	query := fmt.Sprintf("DROP INDEX IF EXISTS %s", escapedName)
 
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, t.executor.database(ctx), t.Name(), []PlannedStatement{
+			{SQL: query, rowsOf: escapedName, Note: "removes the index; the table's rows are not changed"},
+		}, []Permission{
+			tablePermission("OWNER", escapedName),
+		}), nil
+	}
 	err := t.executor.Exec(ctx, query, nil)
 	if err != nil {
 		t.logger.Error("Index drop failed", err, params)
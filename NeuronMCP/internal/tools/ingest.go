@@ -0,0 +1,646 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+const (
+	// ingestMaxDocumentBytes caps documents fetched from a URL or read from
+	// a file
+	ingestMaxDocumentBytes = 10 << 20
+	// ingestFetchTimeout bounds the URL download
+	ingestFetchTimeout = 30 * time.Second
+	// ingestMaxRedirects bounds the redirects a URL download follows
+	ingestMaxRedirects = 5
+)
+
+// deniedFetchNetworks are ranges that are neither loopback, link-local nor
+// private by the net package's account, but are not public either
+var deniedFetchNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),     // "this" network
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+	mustParseCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustParseCIDR("198.18.0.0/15"), // benchmarking
+	mustParseCIDR("64:ff9b::/96"),  // NAT64, which can reach IPv4 hosts
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// publicFetchAddress reports whether a URL download may connect to ip:
+// loopback, link-local (such as the 169.254.169.254 cloud metadata
+// service), private, unspecified and multicast addresses are refused
+func publicFetchAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range deniedFetchNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newDocumentClient returns the HTTP client URL downloads use. Every
+// connection, including those of redirects, is checked with allowed after
+// DNS resolution, so a host name cannot lead to a refused address. No proxy
+// is used, as it would connect on the client's behalf.
+func newDocumentClient(allowed func(net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !allowed(ip) {
+				return fmt.Errorf("connecting to %s is not allowed: only public addresses can be fetched", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: ingestFetchTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= ingestMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", ingestMaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to a %s URL is not allowed", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// documentClient downloads documents given by URL
+var documentClient = newDocumentClient(publicFetchAddress)
+
+// IngestDocumentTool chunks a document, embeds the chunks and stores them in
+// a table in a single call
+type IngestDocumentTool struct {
+	*BaseTool
+	db       *database.Database
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewIngestDocumentTool creates a new document ingestion tool
+func NewIngestDocumentTool(db *database.Database, logger *logging.Logger) *IngestDocumentTool {
+	return &IngestDocumentTool{
+		BaseTool: NewBaseTool(
+			"ingest_document",
+			"Ingest a document for RAG: fetch (text, URL or local file), chunk, batch-embed with neurondb.embed_batch and insert the chunks into a table in one transaction",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "Document text (provide one of text, url or path)",
+					},
+					"url": map[string]interface{}{
+						"type":        "string",
+						"description": "http(s) URL to fetch the document from; HTML is reduced to text (provide one of text, url or path)",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute path of a local file to read, inside one of the client's roots, or inside server.localFileDirs for clients without roots; .html files are reduced to text (provide one of text, url or path)",
+					},
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Target table, optionally schema-qualified",
+					},
+					"text_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "content",
+						"description": "Column receiving the chunk text",
+					},
+					"embedding_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "embedding",
+						"description": "Vector column receiving the chunk embedding",
+					},
+					"metadata_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "metadata",
+						"description": "JSONB column receiving chunk metadata",
+					},
+					"metadata": map[string]interface{}{
+						"type":        "object",
+						"description": "Metadata stored with every chunk, merged with chunk_index, start, end and source",
+					},
+					"create_table": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Create the table if it does not exist, sized to the embedding dimension",
+					},
+					"strategy": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{ChunkStrategyFixed, ChunkStrategySentence, ChunkStrategyRecursive, ChunkStrategySemantic},
+						"default":     ChunkStrategyRecursive,
+						"description": "Chunking strategy (see chunk_text)",
+					},
+					"chunk_size": map[string]interface{}{
+						"type":        "number",
+						"default":     1000,
+						"minimum":     1,
+						"description": "Maximum chunk size in characters",
+					},
+					"overlap": map[string]interface{}{
+						"type":        "number",
+						"default":     100,
+						"minimum":     0,
+						"description": "Characters shared between consecutive chunks",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "Embedding model (optional)",
+					},
+					"batch_size": map[string]interface{}{
+						"type":        "number",
+						"default":     64,
+						"minimum":     1,
+						"maximum":     1000,
+						"description": "Chunks embedded per neurondb.embed_batch call",
+					},
+				},
+				"required": []interface{}{"table"},
+			},
+		),
+		db:       db,
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute executes the document ingestion
+func (t *IngestDocumentTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for ingest_document tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	text, _ := params["text"].(string)
+	sourceURL, _ := params["url"].(string)
+	path, _ := params["path"].(string)
+	table, _ := params["table"].(string)
+	sources := 0
+	for _, s := range []string{text, sourceURL, path} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return Error("exactly one of text, url or path is required for ingest_document tool", "VALIDATION_ERROR", map[string]interface{}{
+			"has_text": text != "",
+			"has_url":  sourceURL != "",
+			"has_path": path != "",
+		}), nil
+	}
+
+	tableIdent, err := parseQualifiedIdentifier(table)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table for ingest_document tool: table='%s', error=%v", table, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"table":     table,
+		}), nil
+	}
+	textColumn := stringParam(params, "text_column", "content")
+	embeddingColumn := stringParam(params, "embedding_column", "embedding")
+	metadataColumn := stringParam(params, "metadata_column", "metadata")
+	model := stringParam(params, "model", "default")
+	strategy := stringParam(params, "strategy", ChunkStrategyRecursive)
+	createTable, _ := params["create_table"].(bool)
+	baseMetadata, _ := params["metadata"].(map[string]interface{})
+
+	opts := ChunkOptions{ChunkSize: 1000, Overlap: 100}
+	if c, ok := params["chunk_size"].(float64); ok {
+		opts.ChunkSize = int(c)
+	}
+	if o, ok := params["overlap"].(float64); ok {
+		opts.Overlap = int(o)
+	}
+	batchSize := 64
+	if b, ok := params["batch_size"].(float64); ok {
+		batchSize = int(b)
+	}
+
+	stages := map[string]interface{}{}
+	timings := map[string]interface{}{}
+	started := time.Now()
+
+	// Stage 1: fetch
+	source := "text"
+	if sourceURL != "" {
+		stageStart := time.Now()
+		text, err = fetchDocument(ctx, sourceURL)
+		timings["fetch_ms"] = msSince(stageStart)
+		if err != nil {
+			return Error(fmt.Sprintf("Document fetch failed: url='%s', error=%v", sourceURL, err), "FETCH_ERROR", map[string]interface{}{
+				"url":   sourceURL,
+				"error": err.Error(),
+			}), nil
+		}
+		source = sourceURL
+		stages["fetched_bytes"] = len(text)
+	}
+	if path != "" {
+		stageStart := time.Now()
+		resolved, err := resolveLocalPath(ctx, path)
+		if err != nil {
+			return Error(fmt.Sprintf("Document path refused for ingest_document tool: path='%s', error=%v", path, err), "PATH_NOT_ALLOWED", map[string]interface{}{
+				"parameter": "path",
+				"path":      path,
+				"error":     err.Error(),
+			}), nil
+		}
+		text, err = readDocumentFile(resolved)
+		timings["fetch_ms"] = msSince(stageStart)
+		if err != nil {
+			return Error(fmt.Sprintf("Document read failed: path='%s', error=%v", resolved, err), "FETCH_ERROR", map[string]interface{}{
+				"path":  resolved,
+				"error": err.Error(),
+			}), nil
+		}
+		source = resolved
+		stages["fetched_bytes"] = len(text)
+	}
+	if strings.TrimSpace(text) == "" {
+		return Error("document is empty after fetching for ingest_document tool", "VALIDATION_ERROR", map[string]interface{}{
+			"source": source,
+		}), nil
+	}
+
+	embed := func(ctx context.Context, texts []string) ([][]float32, error) {
+		return t.embedInBatches(ctx, model, texts, batchSize)
+	}
+
+	// Stage 2: chunk
+	stageStart := time.Now()
+	chunks, err := ChunkText(ctx, text, strategy, opts, embed)
+	timings["chunk_ms"] = msSince(stageStart)
+	if err != nil {
+		return Error(fmt.Sprintf("Document chunking failed: strategy='%s', text_length=%d, chunk_size=%d, overlap=%d, error=%v", strategy, len(text), opts.ChunkSize, opts.Overlap, err), "RAG_ERROR", map[string]interface{}{
+			"strategy":   strategy,
+			"chunk_size": opts.ChunkSize,
+			"overlap":    opts.Overlap,
+			"error":      err.Error(),
+		}), nil
+	}
+	stages["chunks"] = len(chunks)
+	if len(chunks) == 0 {
+		return Error("document produced no chunks for ingest_document tool", "RAG_ERROR", map[string]interface{}{
+			"text_length": len(text),
+		}), nil
+	}
+
+	if IsDryRun(ctx) {
+		return t.planIngest(ctx, tableIdent, textColumn, embeddingColumn, metadataColumn, createTable, chunks, baseMetadata, source, model, batchSize), nil
+	}
+
+	// Stage 3: embed
+	stageStart = time.Now()
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	vectors, err := t.embedInBatches(ctx, model, texts, batchSize)
+	timings["embed_ms"] = msSince(stageStart)
+	if err != nil {
+		t.logger.Error("Document embedding failed", err, map[string]interface{}{
+			"chunk_count": len(chunks),
+			"model":       model,
+		})
+		return Error(fmt.Sprintf("Document embedding failed: chunk_count=%d, model='%s', batch_size=%d, error=%v", len(chunks), model, batchSize, err), "EMBEDDING_ERROR", map[string]interface{}{
+			"chunk_count": len(chunks),
+			"model":       model,
+			"error":       err.Error(),
+		}), nil
+	}
+	stages["embedded"] = len(vectors)
+	stages["embedding_batches"] = (len(texts) + batchSize - 1) / batchSize
+	dimension := len(vectors[0])
+
+	// Stage 4: insert
+	stageStart = time.Now()
+	inserted, err := t.insertChunks(ctx, tableIdent, textColumn, embeddingColumn, metadataColumn, createTable, dimension, chunks, vectors, baseMetadata, source)
+	timings["insert_ms"] = msSince(stageStart)
+	if err != nil {
+		t.logger.Error("Document insert failed", err, map[string]interface{}{
+			"table":       table,
+			"chunk_count": len(chunks),
+		})
+		return Error(fmt.Sprintf("Document insert failed, no rows were written: table='%s', chunk_count=%d, error=%v", table, len(chunks), err), "INSERT_ERROR", map[string]interface{}{
+			"table":       table,
+			"chunk_count": len(chunks),
+			"error":       err.Error(),
+		}), nil
+	}
+	stages["inserted"] = inserted
+	timings["total_ms"] = msSince(started)
+
+	return Success(map[string]interface{}{
+		"table":               tableIdent.Sanitize(),
+		"source":              source,
+		"rows_inserted":       inserted,
+		"embedding_dimension": dimension,
+		"stages":              stages,
+		"timings":             timings,
+	}, map[string]interface{}{
+		"strategy": strategy,
+		"model":    model,
+	}), nil
+}
+
+// embedInBatches embeds texts batchSize at a time
+func (t *IngestDocumentTool) embedInBatches(ctx context.Context, model string, texts []string, batchSize int) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		end := start + batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := embedBatch(ctx, t.executor, model, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("batch %d-%d: %w", start, end-1, err)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// insertChunks writes all chunks in one transaction, creating the table first
+// when requested
+func (t *IngestDocumentTool) insertChunks(ctx context.Context, table pgx.Identifier, textColumn, embeddingColumn, metadataColumn string, createTable bool, dimension int, chunks []TextChunk, vectors [][]float32, baseMetadata map[string]interface{}, source string) (int, error) {
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return 0, fmt.Errorf("database connection not available (database connection pool is not initialized)")
+	}
+
+	tableName := table.Sanitize()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if createTable {
+		if _, err := tx.Exec(ctx, ingestTableDDL(table, textColumn, embeddingColumn, metadataColumn, dimension)); err != nil {
+			return 0, fmt.Errorf("failed to create table %s: %w", tableName, err)
+		}
+	}
+
+	insert := ingestInsertSQL(table, textColumn, embeddingColumn, metadataColumn)
+	batch := &pgx.Batch{}
+	for i, chunk := range chunks {
+		metadataJSON, err := chunkMetadata(chunk, baseMetadata, source)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode metadata for chunk %d: %w", i, err)
+		}
+		batch.Queue(insert, chunk.Text, formatFloat32Vector(vectors[i]), metadataJSON)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for i := range chunks {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return 0, fmt.Errorf("insert of chunk %d into %s failed: %w", i, tableName, err)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit ingestion into %s: %w", tableName, err)
+	}
+	return len(chunks), nil
+}
+
+// ingestTableDDL creates the table ingest_document writes to. A dimension
+// of 0 leaves the vector column unsized.
+func ingestTableDDL(table pgx.Identifier, textColumn, embeddingColumn, metadataColumn string, dimension int) string {
+	vectorType := "vector"
+	if dimension > 0 {
+		vectorType = fmt.Sprintf("vector(%d)", dimension)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id BIGSERIAL PRIMARY KEY,
+			%s TEXT NOT NULL,
+			%s %s,
+			%s JSONB DEFAULT '{}',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`, table.Sanitize(), pgx.Identifier{textColumn}.Sanitize(), pgx.Identifier{embeddingColumn}.Sanitize(), vectorType, pgx.Identifier{metadataColumn}.Sanitize())
+}
+
+// ingestInsertSQL inserts one chunk with its text ($1), embedding ($2) and
+// metadata ($3)
+func ingestInsertSQL(table pgx.Identifier, textColumn, embeddingColumn, metadataColumn string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES ($1, $2::vector, $3::jsonb)", table.Sanitize(),
+		pgx.Identifier{textColumn}.Sanitize(), pgx.Identifier{embeddingColumn}.Sanitize(), pgx.Identifier{metadataColumn}.Sanitize())
+}
+
+// chunkMetadata returns the JSON metadata stored with a chunk: the caller's
+// metadata plus the chunk's position and source
+func chunkMetadata(chunk TextChunk, baseMetadata map[string]interface{}, source string) (string, error) {
+	metadata := make(map[string]interface{}, len(baseMetadata)+4)
+	for k, v := range baseMetadata {
+		metadata[k] = v
+	}
+	metadata["chunk_index"] = chunk.Index
+	metadata["start"] = chunk.Start
+	metadata["end"] = chunk.End
+	metadata["source"] = source
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// planIngest returns the dry run plan of an ingestion: the table creation
+// and one insert per chunk. Embedding is skipped, so the inserts show no
+// embedding and a created table an unsized vector column.
+func (t *IngestDocumentTool) planIngest(ctx context.Context, table pgx.Identifier, textColumn, embeddingColumn, metadataColumn string, createTable bool, chunks []TextChunk, baseMetadata map[string]interface{}, source, model string, batchSize int) *ToolResult {
+	var statements []PlannedStatement
+	permissions := []Permission{tablePermission("INSERT", table.Sanitize())}
+	if createTable {
+		statements = append(statements, PlannedStatement{
+			SQL:  ingestTableDDL(table, textColumn, embeddingColumn, metadataColumn, 0),
+			Note: "the vector column is sized to the embedding dimension; nothing is created when the table exists",
+		})
+		permissions = append(permissions, schemaPermission("CREATE", schemaOf(table)))
+	}
+
+	metadataJSON, err := chunkMetadata(chunks[0], baseMetadata, source)
+	if err != nil {
+		return Error(fmt.Sprintf("Failed to encode chunk metadata for ingest_document tool: error=%v", err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "metadata",
+		})
+	}
+	rows := int64(len(chunks))
+	statements = append(statements, PlannedStatement{
+		SQL:           ingestInsertSQL(table, textColumn, embeddingColumn, metadataColumn),
+		Params:        []interface{}{chunks[0].Text, nil, metadataJSON},
+		EstimatedRows: &rows,
+		Note:          fmt.Sprintf("runs once per chunk in one transaction; params are those of the first of %d chunks, whose embedding is computed when the call runs", len(chunks)),
+	})
+
+	return dryRunResult(ctx, t.executor.database(ctx), t.Name(), statements, permissions,
+		fmt.Sprintf("%d chunks would be embedded with model '%s' in %d batches of up to %d", len(chunks), model, (len(chunks)+batchSize-1)/batchSize, batchSize))
+}
+
+// fetchDocument downloads a document over http(s) from a public address,
+// reducing HTML to text
+func fetchDocument(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ingestFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := documentClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ingestMaxDocumentBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > ingestMaxDocumentBytes {
+		return "", fmt.Errorf("document exceeds %d bytes", ingestMaxDocumentBytes)
+	}
+
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html") {
+		return htmlToText(string(body)), nil
+	}
+	return string(body), nil
+}
+
+// readDocumentFile reads a local document, reducing HTML files to text
+func readDocumentFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(file, ingestMaxDocumentBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > ingestMaxDocumentBytes {
+		return "", fmt.Errorf("document exceeds %d bytes", ingestMaxDocumentBytes)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return htmlToText(string(body)), nil
+	}
+	return string(body), nil
+}
+
+var (
+	htmlDropRe  = regexp.MustCompile(`(?is)<(script|style|noscript|head)\b.*?</(script|style|noscript|head)>`)
+	htmlBlockRe = regexp.MustCompile(`(?i)</?(p|div|br|li|tr|h[1-6]|section|article|pre|blockquote)\b[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`(?s)<[^>]+>`)
+	blankRunRe  = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+	spaceRunRe  = regexp.MustCompile(`[ \t]+`)
+)
+
+// htmlToText strips markup, keeping block elements as paragraph breaks so
+// the sentence and recursive chunkers still see the document structure
+func htmlToText(html string) string {
+	text := htmlDropRe.ReplaceAllString(html, "")
+	text = htmlBlockRe.ReplaceAllString(text, "\n\n")
+	text = htmlTagRe.ReplaceAllString(text, "")
+	replacer := strings.NewReplacer("&nbsp;", " ", "&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'")
+	text = replacer.Replace(text)
+	text = spaceRunRe.ReplaceAllString(text, " ")
+	text = blankRunRe.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// parseQualifiedIdentifier splits "schema.table" into a pgx identifier
+func parseQualifiedIdentifier(name string) (pgx.Identifier, error) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("expected table or schema.table")
+	}
+	for _, p := range parts {
+		if strings.TrimSpace(p) == "" {
+			return nil, fmt.Errorf("empty identifier part")
+		}
+	}
+	return pgx.Identifier(parts), nil
+}
+
+func stringParam(params map[string]interface{}, name, def string) string {
+	if s, ok := params[name].(string); ok && s != "" {
+		return s
+	}
+	return def
+}
+
+func formatFloat32Vector(vec []float32) string {
+	parts := make([]string, len(vec))
+	for i, v := range vec {
+		parts[i] = fmt.Sprintf("%g", v)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTMLToText(t *testing.T) {
+	html := `<html><head><title>x</title></head><body><script>var a = 1;</script><h1>Title</h1><p>First &amp; <b>bold</b>.</p><p>Second</p></body></html>`
+	want := "Title\n\nFirst & bold.\n\nSecond"
+	if got := htmlToText(html); got != want {
+		t.Errorf("htmlToText() = %q, want %q", got, want)
+	}
+}
+
+func TestParseQualifiedIdentifier(t *testing.T) {
+	id, err := parseQualifiedIdentifier("docs.chunks")
+	if err != nil {
+		t.Fatalf("parseQualifiedIdentifier() error: %v", err)
+	}
+	if got := id.Sanitize(); got != `"docs"."chunks"` {
+		t.Errorf("Sanitize() = %s", got)
+	}
+	for _, bad := range []string{"", "a.b.c", "docs."} {
+		if _, err := parseQualifiedIdentifier(bad); err == nil {
+			t.Errorf("parseQualifiedIdentifier(%q) accepted", bad)
+		}
+	}
+}
+
+func TestPublicFetchAddress(t *testing.T) {
+	for _, addr := range []string{
+		"127.0.0.1", "::1", "169.254.169.254", "fe80::1", "10.1.2.3", "172.16.0.1", "192.168.1.1",
+		"fd00::1", "0.0.0.0", "::", "100.64.0.1", "224.0.0.1", "::ffff:169.254.169.254", "::ffff:127.0.0.1",
+	} {
+		if publicFetchAddress(net.ParseIP(addr)) {
+			t.Errorf("publicFetchAddress(%s) = true, want refused", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946", "8.8.8.8"} {
+		if !publicFetchAddress(net.ParseIP(addr)) {
+			t.Errorf("publicFetchAddress(%s) = false, want allowed", addr)
+		}
+	}
+}
+
+func TestFetchDocumentRefusesLocalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	// Test servers listen on loopback, as would a local service an attacker
+	// points the tool at
+	_, err := fetchDocument(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("fetching a loopback URL = %v, want refused", err)
+	}
+	u, _ := url.Parse(server.URL)
+	_, err = fetchDocument(context.Background(), "http://localhost:"+u.Port()+"/")
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("fetching localhost = %v, want refused after DNS resolution", err)
+	}
+}
+
+func TestDocumentClientRedirects(t *testing.T) {
+	var target string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, http.StatusFound)
+	}))
+	defer server.Close()
+	client := newDocumentClient(func(net.IP) bool { return true })
+
+	target = "/loop"
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("redirect loop = %v, want stopped", err)
+	}
+	target = "file:///etc/passwd"
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("redirect to a file URL = %v, want refused", err)
+	}
+
+	// A redirect is dialed with the same check as the first request
+	target = "http://169.254.169.254/latest/meta-data/"
+	client = newDocumentClient(func(ip net.IP) bool { return ip.IsLoopback() })
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "169.254.169.254 is not allowed") {
+		t.Errorf("redirect to the metadata service = %v, want refused", err)
+	}
+}
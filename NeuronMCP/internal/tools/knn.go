@@ -0,0 +1,379 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// knnNeighbor is one of the k nearest labelled rows
+type knnNeighbor struct {
+	Label    interface{} // string for classification, float64 for regression
+	Distance float64
+	Columns  map[string]interface{}
+}
+
+// knnVote is the vote of one class in a kNN classification
+type knnVote struct {
+	Label       string  `json:"label"`
+	Count       int     `json:"count"`
+	Weight      float64 `json:"weight"`
+	Probability float64 `json:"probability"`
+}
+
+// knnWeights returns the vote weight of each neighbor. With "uniform" every
+// neighbor counts once. With "distance" a neighbor counts 1/distance, and if
+// any neighbor is at distance 0 only those neighbors count.
+func knnWeights(neighbors []knnNeighbor, weighting string) ([]float64, error) {
+	weights := make([]float64, len(neighbors))
+	if weighting != "distance" {
+		for i := range weights {
+			weights[i] = 1
+		}
+		return weights, nil
+	}
+
+	exact := false
+	for _, n := range neighbors {
+		if n.Distance < 0 {
+			return nil, fmt.Errorf("distance weighting needs non-negative distances, got %g: use l2 or cosine", n.Distance)
+		}
+		if n.Distance == 0 {
+			exact = true
+		}
+	}
+	for i, n := range neighbors {
+		switch {
+		case exact && n.Distance == 0:
+			weights[i] = 1
+		case !exact:
+			weights[i] = 1 / n.Distance
+		}
+	}
+	return weights, nil
+}
+
+// knnClassify predicts the class with the largest total vote weight. Ties go
+// to the class of the nearest tied neighbor. neighbors must be ordered
+// nearest first; weights are from knnWeights.
+func knnClassify(neighbors []knnNeighbor, weights []float64) (prediction string, votes []knnVote, err error) {
+	if len(neighbors) == 0 {
+		return "", nil, fmt.Errorf("no labelled neighbors found")
+	}
+
+	byLabel := make(map[string]int)
+	rank := make(map[string]int) // position of each class's nearest neighbor
+	total := 0.0
+	for i, n := range neighbors {
+		label := fmt.Sprint(n.Label)
+		idx, ok := byLabel[label]
+		if !ok {
+			idx = len(votes)
+			byLabel[label] = idx
+			rank[label] = i
+			votes = append(votes, knnVote{Label: label})
+		}
+		votes[idx].Count++
+		votes[idx].Weight += weights[i]
+		total += weights[i]
+	}
+	for i := range votes {
+		votes[i].Probability = votes[i].Weight / total
+	}
+	sort.SliceStable(votes, func(i, j int) bool {
+		if votes[i].Weight != votes[j].Weight {
+			return votes[i].Weight > votes[j].Weight
+		}
+		return rank[votes[i].Label] < rank[votes[j].Label]
+	})
+	return votes[0].Label, votes, nil
+}
+
+// knnRegress predicts the weighted mean of the neighbors' labels and returns
+// their weighted standard deviation
+func knnRegress(neighbors []knnNeighbor, weights []float64) (prediction, stddev float64, err error) {
+	if len(neighbors) == 0 {
+		return 0, 0, fmt.Errorf("no labelled neighbors found")
+	}
+
+	values := make([]float64, len(neighbors))
+	sum, total := 0.0, 0.0
+	for i, n := range neighbors {
+		v, ok := n.Label.(float64)
+		if !ok {
+			return 0, 0, fmt.Errorf("label of neighbor %d is %T, expected a number", i, n.Label)
+		}
+		values[i] = v
+		sum += weights[i] * v
+		total += weights[i]
+	}
+	prediction = sum / total
+
+	variance := 0.0
+	for i, v := range values {
+		variance += weights[i] * (v - prediction) * (v - prediction)
+	}
+	return prediction, math.Sqrt(variance / total), nil
+}
+
+// buildKNNQuery builds the query for the k nearest rows with a non-null
+// label. queryExpr is the query vector expression ($1, or an embedding of
+// $1); k is bound as $2. The label is returned as _knn_label, cast to text
+// for classification and to double precision for regression, and the
+// distance as _knn_distance.
+func buildKNNQuery(table pgx.Identifier, vectorColumn, labelColumn, metric, task, queryExpr string, columns []interface{}) (string, error) {
+	var distance string
+	col := "t." + pgx.Identifier{vectorColumn}.Sanitize()
+	switch metric {
+	case "l2":
+		distance = col + " <-> q.v"
+	case "cosine":
+		distance = col + " <=> q.v"
+	case "inner_product":
+		distance = col + " <#> q.v"
+	default:
+		return "", fmt.Errorf("distance metric '%s' is not supported: use l2, cosine or inner_product", metric)
+	}
+
+	labelType := "text"
+	if task == "regression" {
+		labelType = "double precision"
+	}
+	label := "t." + pgx.Identifier{labelColumn}.Sanitize()
+
+	selectList := ""
+	if len(columns) > 0 {
+		list, err := selectColumnList("t", columns)
+		if err != nil {
+			return "", err
+		}
+		selectList = ", " + list
+	}
+
+	return fmt.Sprintf(
+		"WITH q AS (SELECT %s AS v) "+
+			"SELECT %s::%s AS _knn_label, (%s)::double precision AS _knn_distance%s "+
+			"FROM %s t, q WHERE %s IS NOT NULL AND %s IS NOT NULL "+
+			"ORDER BY %s LIMIT $2",
+		queryExpr, label, labelType, distance, selectList,
+		table.Sanitize(), label, col, distance), nil
+}
+
+// PredictKNNTool predicts a label for a query vector from its k nearest
+// labelled rows
+type PredictKNNTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewPredictKNNTool creates a new kNN prediction tool
+func NewPredictKNNTool(db *database.Database, logger *logging.Logger) *PredictKNNTool {
+	return &PredictKNNTool{
+		BaseTool: NewBaseTool(
+			"predict_knn",
+			"Classify or regress a query vector or text from its k nearest labelled rows in a table, returning the prediction and the neighbors it is based on",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table of labelled vectors, optionally schema-qualified",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Vector column to search",
+					},
+					"label_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Column holding the label; must be numeric for regression. Rows with a null label are skipped",
+					},
+					"query_vector": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "number"},
+						"description": "Vector to predict for",
+					},
+					"query_text": map[string]interface{}{
+						"type":        "string",
+						"description": "Text to predict for, embedded with model. Used when query_vector is omitted",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"default":     "default",
+						"description": "Embedding model for query_text",
+					},
+					"task": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"classification", "regression"},
+						"default":     "classification",
+						"description": "classification predicts the majority label; regression predicts the mean label",
+					},
+					"k": map[string]interface{}{
+						"type":        "number",
+						"default":     5,
+						"minimum":     1,
+						"maximum":     1000,
+						"description": "Number of neighbors",
+					},
+					"distance_metric": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"l2", "cosine", "inner_product"},
+						"default":     "l2",
+						"description": "Distance metric",
+					},
+					"weighting": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"uniform", "distance"},
+						"default":     "uniform",
+						"description": "uniform counts each neighbor once; distance weights neighbors by 1/distance (l2 and cosine only)",
+					},
+					"additional_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Columns to return with each neighbor, such as its ID",
+					},
+				},
+				"required": []interface{}{"table", "vector_column", "label_column"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute runs the kNN prediction
+func (t *PredictKNNTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for predict_knn tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	tableName, _ := params["table"].(string)
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	vectorColumn, _ := params["vector_column"].(string)
+	labelColumn, _ := params["label_column"].(string)
+	task := stringParam(params, "task", "classification")
+	metric := stringParam(params, "distance_metric", "l2")
+	weighting := stringParam(params, "weighting", "uniform")
+	k := 5
+	if v, ok := params["k"].(float64); ok {
+		k = int(v)
+	}
+	additionalColumns, _ := params["additional_columns"].([]interface{})
+
+	if k < 1 || k > 1000 {
+		return Error(fmt.Sprintf("k must be between 1 and 1000, got %d", k), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "k",
+		}), nil
+	}
+	if weighting == "distance" && metric == "inner_product" {
+		return Error("Distance weighting is not supported with inner_product: use l2 or cosine", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "weighting",
+		}), nil
+	}
+
+	var queryExpr string
+	var queryParams []interface{}
+	if vec, ok := params["query_vector"].([]interface{}); ok && len(vec) > 0 {
+		queryExpr = "$1::vector"
+		queryParams = []interface{}{formatVectorFromInterface(vec), k}
+	} else if text, ok := params["query_text"].(string); ok && text != "" {
+		queryExpr = "embed_text($1::text, $3::text)"
+		queryParams = []interface{}{text, k, stringParam(params, "model", "default")}
+	} else {
+		return Error("predict_knn requires query_vector or query_text", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "query_vector",
+		}), nil
+	}
+
+	query, err := buildKNNQuery(table, vectorColumn, labelColumn, metric, task, queryExpr, additionalColumns)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid parameters for predict_knn tool on table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": tableName,
+		}), nil
+	}
+
+	rows, err := t.executor.ExecuteQuery(ctx, query, queryParams)
+	if err != nil {
+		t.logger.Error("kNN prediction failed", err, map[string]interface{}{"table": tableName, "vector_column": vectorColumn})
+		return Error(fmt.Sprintf("kNN neighbor search failed: table='%s', vector_column='%s', label_column='%s', k=%d, error=%v", tableName, vectorColumn, labelColumn, k, err), "PREDICTION_ERROR", map[string]interface{}{
+			"table":         tableName,
+			"vector_column": vectorColumn,
+			"label_column":  labelColumn,
+			"k":             k,
+			"error":         err.Error(),
+		}), nil
+	}
+
+	neighbors := make([]knnNeighbor, len(rows))
+	for i, row := range rows {
+		distance, _ := row["_knn_distance"].(float64)
+		neighbors[i] = knnNeighbor{Label: row["_knn_label"], Distance: distance, Columns: row}
+		delete(row, "_knn_label")
+		delete(row, "_knn_distance")
+	}
+	weights, err := knnWeights(neighbors, weighting)
+	if err != nil {
+		return Error(fmt.Sprintf("kNN prediction failed on table '%s': %v", tableName, err), "PREDICTION_ERROR", map[string]interface{}{
+			"table": tableName,
+		}), nil
+	}
+
+	result := map[string]interface{}{"task": task}
+	if task == "regression" {
+		prediction, stddev, err := knnRegress(neighbors, weights)
+		if err != nil {
+			return Error(fmt.Sprintf("kNN regression failed on table '%s': %v", tableName, err), "PREDICTION_ERROR", map[string]interface{}{
+				"table": tableName,
+			}), nil
+		}
+		result["prediction"] = prediction
+		result["stddev"] = stddev
+	} else {
+		prediction, votes, err := knnClassify(neighbors, weights)
+		if err != nil {
+			return Error(fmt.Sprintf("kNN classification failed on table '%s': %v", tableName, err), "PREDICTION_ERROR", map[string]interface{}{
+				"table": tableName,
+			}), nil
+		}
+		result["prediction"] = prediction
+		result["confidence"] = votes[0].Probability
+		result["votes"] = votes
+	}
+
+	evidence := make([]map[string]interface{}, len(neighbors))
+	for i, n := range neighbors {
+		evidence[i] = map[string]interface{}{
+			"label":    n.Label,
+			"distance": n.Distance,
+			"weight":   weights[i],
+		}
+		if len(n.Columns) > 0 {
+			evidence[i]["columns"] = n.Columns
+		}
+	}
+	result["neighbors"] = evidence
+
+	return Success(result, map[string]interface{}{
+		"table":           tableName,
+		"vector_column":   vectorColumn,
+		"label_column":    labelColumn,
+		"k":               k,
+		"neighbors_found": len(neighbors),
+		"distance_metric": metric,
+		"weighting":       weighting,
+	}), nil
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestKNNClassify(t *testing.T) {
+	neighbors := []knnNeighbor{
+		{Label: "cat", Distance: 0.5},
+		{Label: "dog", Distance: 1},
+		{Label: "dog", Distance: 2},
+		{Label: "cat", Distance: 4},
+	}
+
+	// A 2-2 tie goes to the class of the nearest neighbor
+	weights, _ := knnWeights(neighbors, "uniform")
+	prediction, votes, err := knnClassify(neighbors, weights)
+	if err != nil || prediction != "cat" || len(votes) != 2 || votes[0].Probability != 0.5 {
+		t.Errorf("uniform = (%q, %+v, %v)", prediction, votes, err)
+	}
+
+	weights, _ = knnWeights(neighbors[1:], "distance")
+	prediction, votes, err = knnClassify(neighbors[1:], weights)
+	if err != nil || prediction != "dog" || votes[0].Count != 2 || math.Abs(votes[0].Probability-1.5/1.75) > 1e-9 {
+		t.Errorf("distance = (%q, %+v, %v)", prediction, votes, err)
+	}
+
+	if _, _, err := knnClassify(nil, nil); err == nil {
+		t.Error("classification without neighbors succeeded")
+	}
+}
+
+func TestKNNWeights(t *testing.T) {
+	weights, err := knnWeights([]knnNeighbor{{Distance: 0}, {Distance: 1}, {Distance: 0}}, "distance")
+	if err != nil || weights[0] != 1 || weights[1] != 0 || weights[2] != 1 {
+		t.Errorf("exact matches = (%v, %v)", weights, err)
+	}
+	if _, err := knnWeights([]knnNeighbor{{Distance: -3}}, "distance"); err == nil {
+		t.Error("distance weighting of a negative distance succeeded")
+	}
+}
+
+func TestKNNRegress(t *testing.T) {
+	neighbors := []knnNeighbor{{Label: 1.0, Distance: 1}, {Label: 3.0, Distance: 1}}
+	weights, _ := knnWeights(neighbors, "uniform")
+	prediction, stddev, err := knnRegress(neighbors, weights)
+	if err != nil || prediction != 2 || stddev != 1 {
+		t.Errorf("knnRegress() = (%g, %g, %v)", prediction, stddev, err)
+	}
+
+	if _, _, err := knnRegress([]knnNeighbor{{Label: "x"}}, []float64{1}); err == nil {
+		t.Error("regression over a text label succeeded")
+	}
+}
+
+func TestBuildKNNQuery(t *testing.T) {
+	table := pgx.Identifier{"public", "docs"}
+	query, err := buildKNNQuery(table, "embedding", "category", "cosine", "classification", "$1::vector", []interface{}{"id"})
+	if err != nil {
+		t.Fatalf("buildKNNQuery() error = %v", err)
+	}
+	for _, want := range []string{
+		"WITH q AS (SELECT $1::vector AS v)",
+		`t."category"::text AS _knn_label`,
+		`(t."embedding" <=> q.v)::double precision AS _knn_distance, t."id"`,
+		`FROM "public"."docs" t, q WHERE t."category" IS NOT NULL`,
+		"LIMIT $2",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+
+	query, _ = buildKNNQuery(table, "embedding", "price", "l2", "regression", "$1::vector", nil)
+	if !strings.Contains(query, `t."price"::double precision AS _knn_label`) || strings.Contains(query, "t.*") {
+		t.Errorf("regression query:\n%s", query)
+	}
+
+	if _, err := buildKNNQuery(table, "embedding", "category", "hamming", "classification", "$1::vector", nil); err == nil {
+		t.Error("query with an unsupported metric succeeded")
+	}
+}
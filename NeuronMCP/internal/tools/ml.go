@@ -127,9 +127,17 @@ func (t *TrainModelTool) Execute(ctx context.Context, params map[string]interfac
 	// NeuronDB train function signature: neurondb.train(project_name, algorithm, table_name, label_col, feature_columns[], params)
 	// Convert featureCol to array format
 	query := `SELECT neurondb.train($1, $2, $3, $4, $5::text[], $6::jsonb) AS model_id`
-	result, err := t.executor.ExecuteQueryOne(ctx, query, []interface{}{
-		project, algorithm, table, labelCol, []string{featureCol}, paramsJSON,
-	})
+	queryParams := []interface{}{project, algorithm, table, labelCol, []string{featureCol}, paramsJSON}
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, t.executor.database(ctx), t.Name(), []PlannedStatement{
+			{SQL: query, Params: queryParams, rowsOf: table, Note: "trains on every row of the table and stores the model in neurondb.ml_models"},
+		}, []Permission{
+			tablePermission("SELECT", table),
+			tablePermission("INSERT", "neurondb.ml_models"),
+			functionPermission("neurondb.train"),
+		}), nil
+	}
+	result, err := t.executor.ExecuteQueryOne(ctx, query, queryParams)
 	if err != nil {
 		t.logger.Error("Model training failed", err, params)
 		return Error(fmt.Sprintf("Model training execution failed: algorithm='%s', project='%s', table='%s', feature_col='%s', label_col='%s', params=%s, error=%v", algorithm, project, table, featureCol, labelCol, paramsJSON, err), "TRAINING_ERROR", map[string]interface{}{
@@ -565,6 +573,13 @@ func (t *DeleteModelTool) Execute(ctx context.Context, params map[string]interfa
 	}
 
 	query := `DELETE FROM neurondb.ml_models WHERE model_id = $1 RETURNING model_id`
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, t.executor.database(ctx), t.Name(), []PlannedStatement{
+			{SQL: query, Params: []interface{}{modelIDInt}},
+		}, []Permission{
+			tablePermission("DELETE", "neurondb.ml_models"),
+		}), nil
+	}
 	result, err := t.executor.ExecuteQueryOne(ctx, query, []interface{}{modelIDInt})
 	if err != nil {
 		t.logger.Error("Delete model failed", err, params)
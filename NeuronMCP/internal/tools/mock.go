@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// MockEmbeddingDimensions is the size of the vectors the mock database
+// returns for embedding calls
+const MockEmbeddingDimensions = 384
+
+// ErrNoMockResponse is returned in mock mode for queries the mock database
+// has no canned response for
+var ErrNoMockResponse = errors.New("mock mode has no canned response for this query")
+
+var (
+	// mockAliasPattern matches the alias of the first output column of
+	// queries such as "SELECT embed_text($1) AS embedding"
+	mockAliasPattern = regexp.MustCompile(`(?i)\bAS\s+([a-z_][a-z0-9_]*)`)
+	mockWordPattern  = regexp.MustCompile(`[\p{L}\p{N}]+`)
+)
+
+// MockDatabase is an in-memory stand-in for NeuronDB, used when the server
+// runs with --mock. Query executors routed to it answer the major tool
+// families with canned data instead of querying a database: embedding calls
+// return deterministic vectors, vector searches return synthetic neighbors,
+// and generation returns a fixed response. Statements without a result are
+// accepted and discarded; other queries fail with ErrNoMockResponse.
+type MockDatabase struct {
+	dimensions int
+}
+
+// NewMockDatabase creates a mock database
+func NewMockDatabase() *MockDatabase {
+	return &MockDatabase{dimensions: MockEmbeddingDimensions}
+}
+
+type mockDatabaseKey struct{}
+
+// WithMockDatabase returns a context routing the tool call's queries to the
+// mock database
+func WithMockDatabase(ctx context.Context, m *MockDatabase) context.Context {
+	return context.WithValue(ctx, mockDatabaseKey{}, m)
+}
+
+// MockDatabaseFromContext returns the mock database a tool call was routed
+// to, or nil
+func MockDatabaseFromContext(ctx context.Context) *MockDatabase {
+	m, _ := ctx.Value(mockDatabaseKey{}).(*MockDatabase)
+	return m
+}
+
+// Embed returns the mock embedding of text. The words of the text are
+// hashed into the vector, so the same text always gets the same vector and
+// texts sharing words are similar.
+func (m *MockDatabase) Embed(text string) []float32 {
+	vec := make([]float32, m.dimensions)
+	words := mockWordPattern.FindAllString(strings.ToLower(text), -1)
+	if len(words) == 0 {
+		words = []string{text}
+	}
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		sign := float32(1)
+		if sum&1 == 1 {
+			sign = -1
+		}
+		vec[(sum>>1)%uint64(m.dimensions)] += sign
+	}
+	return normalizeMockVector(vec)
+}
+
+// VectorSearch returns limit synthetic neighbors of vec in table, ordered
+// by distance. Each has an id, the requested columns (or a content column
+// when none were requested), the vector column and the distance.
+func (m *MockDatabase) VectorSearch(table, vectorColumn string, vec []float32, distanceMetric string, limit int, columns []string) []map[string]interface{} {
+	h := fnv.New64a()
+	h.Write([]byte(table + "." + vectorColumn))
+	h.Write([]byte(formatFloat32Vector(vec)))
+	seed := h.Sum64()
+	base := float64(seed%1000) / 10000
+
+	rows := make([]map[string]interface{}, 0, limit)
+	for i := 0; i < limit; i++ {
+		row := map[string]interface{}{"id": i + 1}
+		if len(columns) == 0 {
+			row["content"] = fmt.Sprintf("mock neighbor %d of %s", i+1, table)
+		}
+		for _, col := range columns {
+			row[col] = fmt.Sprintf("mock %s %d", col, i+1)
+		}
+		neighbor := m.Embed(fmt.Sprintf("%s %d %d", table, seed, i))
+		row[vectorColumn] = formatFloat32Vector(neighbor)
+		distance := base + float64(i)*0.05
+		if distanceMetric == "inner_product" {
+			// <#> is the negated inner product, so it grows the same way
+			distance = -1 + distance
+		}
+		row["distance"] = distance
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// QueryOne answers a single-row query
+func (m *MockDatabase) QueryOne(query string, params []interface{}) (map[string]interface{}, error) {
+	alias := "result"
+	if match := mockAliasPattern.FindStringSubmatch(query); match != nil {
+		alias = match[1]
+	}
+
+	switch {
+	case strings.Contains(query, "vector_dims("):
+		return map[string]interface{}{alias: m.dimensions}, nil
+	case strings.Contains(query, "embed_batch("):
+		texts := mockStringsParam(params)
+		embeddings := make([]interface{}, len(texts))
+		for i, text := range texts {
+			embeddings[i] = formatFloat32Vector(m.Embed(text))
+		}
+		return map[string]interface{}{alias: embeddings}, nil
+	case strings.Contains(query, "neurondb.embed("):
+		// neurondb.embed(model, text, task)
+		return map[string]interface{}{alias: formatFloat32Vector(m.Embed(mockStringParam(params, 1)))}, nil
+	case strings.Contains(query, "embed_text("):
+		// embed_text(text, model)
+		return map[string]interface{}{alias: formatFloat32Vector(m.Embed(mockStringParam(params, 0)))}, nil
+	case strings.Contains(query, "neurondb.llm("):
+		return map[string]interface{}{alias: "This is a mock response from NeuronMCP mock mode; no model was called."}, nil
+	case strings.Contains(query, "neurondb_retrieve_context_c("):
+		// neurondb_retrieve_context_c(query, table, vector_column, limit)
+		table := mockStringParam(params, 1)
+		limit := mockIntParam(params, 3, 5)
+		chunks := make([]interface{}, 0, limit)
+		for i := 0; i < limit; i++ {
+			chunks = append(chunks, map[string]interface{}{
+				"id":         i + 1,
+				"content":    fmt.Sprintf("mock context chunk %d of %s", i+1, table),
+				"similarity": 1 - float64(i)*0.05,
+			})
+		}
+		return map[string]interface{}{alias: chunks}, nil
+	case strings.Contains(query, "hybrid_search("):
+		// hybrid_search(table, query_vector, query_text, filters, vector_weight, limit)
+		table := mockStringParam(params, 0)
+		limit := mockIntParam(params, 5, 10)
+		results := make([]interface{}, 0, limit)
+		for i := 0; i < limit; i++ {
+			results = append(results, map[string]interface{}{
+				"id":      i + 1,
+				"content": fmt.Sprintf("mock hybrid match %d of %s", i+1, table),
+				"score":   1 - float64(i)*0.05,
+			})
+		}
+		return map[string]interface{}{alias: results}, nil
+	}
+	return nil, fmt.Errorf("%w: query='%s'", ErrNoMockResponse, query)
+}
+
+// Query answers a query returning rows. Only the single-row shapes QueryOne
+// knows are answered.
+func (m *MockDatabase) Query(query string, params []interface{}) ([]map[string]interface{}, error) {
+	row, err := m.QueryOne(query, params)
+	if err != nil {
+		return nil, err
+	}
+	return []map[string]interface{}{row}, nil
+}
+
+// mockStringParam returns parameter i as a string, or "" when it is not one
+func mockStringParam(params []interface{}, i int) string {
+	if i < len(params) {
+		if s, ok := params[i].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// mockIntParam returns parameter i as an int, or fallback when it is not one
+func mockIntParam(params []interface{}, i, fallback int) int {
+	if i < len(params) {
+		switch v := params[i].(type) {
+		case int:
+			return v
+		case int64:
+			return int(v)
+		case float64:
+			return int(v)
+		}
+	}
+	return fallback
+}
+
+// mockStringsParam returns the first string array parameter
+func mockStringsParam(params []interface{}) []string {
+	for _, p := range params {
+		if texts, ok := p.([]string); ok {
+			return texts
+		}
+	}
+	return nil
+}
+
+// normalizeMockVector scales vec to unit length
+func normalizeMockVector(vec []float32) []float32 {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		vec[0] = 1
+		return vec
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vec {
+		vec[i] *= scale
+	}
+	return vec
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+func TestMockDatabaseEmbed(t *testing.T) {
+	m := NewMockDatabase()
+	a := m.Embed("The quick brown fox")
+	if len(a) != MockEmbeddingDimensions {
+		t.Fatalf("dimensions = %d, want %d", len(a), MockEmbeddingDimensions)
+	}
+	if formatFloat32Vector(a) != formatFloat32Vector(m.Embed("the quick brown fox")) {
+		t.Error("embedding is not deterministic")
+	}
+
+	dot := func(x, y []float32) (sum float32) {
+		for i := range x {
+			sum += x[i] * y[i]
+		}
+		return sum
+	}
+	if d := dot(a, a); d < 0.999 || d > 1.001 {
+		t.Errorf("norm² = %v, want 1", d)
+	}
+	if near, far := dot(a, m.Embed("quick brown dog")), dot(a, m.Embed("tax returns")); near <= far {
+		t.Errorf("similarity of overlapping text %v <= unrelated %v", near, far)
+	}
+}
+
+func TestQueryExecutorMock(t *testing.T) {
+	// The database is never connected; every call must go to the mock
+	e := NewQueryExecutor(database.NewDatabase())
+	ctx := WithMockDatabase(context.Background(), NewMockDatabase())
+
+	rows, err := e.ExecuteVectorSearch(ctx, "docs", "embedding", []interface{}{0.1, 0.2}, "cosine", 3, []interface{}{"title"})
+	if err != nil {
+		t.Fatalf("vector search: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("rows = %d, want 3", len(rows))
+	}
+	for i, row := range rows {
+		if row["title"] == nil || row["embedding"] == nil {
+			t.Errorf("row %d missing columns: %v", i, row)
+		}
+		if i > 0 && row["distance"].(float64) <= rows[i-1]["distance"].(float64) {
+			t.Errorf("rows not ordered by distance: %v", rows)
+		}
+	}
+
+	row, err := e.ExecuteQueryOne(ctx, "SELECT embed_text($1, $2)::text AS embedding", []interface{}{"hello", "default"})
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if s, _ := row["embedding"].(string); !strings.HasPrefix(s, "[") {
+		t.Errorf("embedding = %v", row)
+	}
+
+	row, err = e.ExecuteQueryOne(ctx, "SELECT json_agg(embedding::text) AS embeddings FROM unnest(neurondb.embed_batch($1, $2::text[])) AS embedding", []interface{}{"default", []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("embed batch: %v", err)
+	}
+	if embeddings, _ := row["embeddings"].([]interface{}); len(embeddings) != 2 {
+		t.Errorf("batch = %v", row)
+	}
+
+	if _, err := e.ExecuteQuery(ctx, "SELECT * FROM docs", nil); !errors.Is(err, ErrNoMockResponse) {
+		t.Errorf("unknown query: err = %v, want ErrNoMockResponse", err)
+	}
+	if err := e.Exec(ctx, "CREATE TABLE docs (id int)", nil); err != nil {
+		t.Errorf("exec: %v", err)
+	}
+}
@@ -0,0 +1,357 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Model kinds probed by warmup_models and model_health
+const (
+	ModelKindEmbedding  = "embedding"
+	ModelKindGeneration = "generation"
+)
+
+const (
+	// modelProbeInput is the text sent to a model by a probe. It is kept tiny
+	// so a probe measures model load and call overhead, not the work.
+	modelProbeInput = "warmup"
+
+	defaultModelHealthSamples = 3
+	maxModelHealthSamples     = 10
+)
+
+// ModelTarget is a model to warm up or probe
+type ModelTarget struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type modelTargetsKey struct{}
+
+// WithModelTargets returns a context carrying the configured models, which
+// warmup_models and model_health use when a call names none
+func WithModelTargets(ctx context.Context, targets []ModelTarget) context.Context {
+	return context.WithValue(ctx, modelTargetsKey{}, targets)
+}
+
+// ModelTargetsFromContext returns the configured models
+func ModelTargetsFromContext(ctx context.Context) []ModelTarget {
+	targets, _ := ctx.Value(modelTargetsKey{}).([]ModelTarget)
+	return targets
+}
+
+// ModelProbe is the outcome of one tiny call to a model
+type ModelProbe struct {
+	Model     string  `json:"model"`
+	Kind      string  `json:"kind"`
+	Available bool    `json:"available"`
+	LatencyMs float64 `json:"latency_ms"`
+	// Dimensions is the embedding size returned by an embedding model
+	Dimensions int    `json:"dimensions,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ProbeModel calls a model once with a tiny input. Embedding models are
+// called like generate_embedding: embed_text, then neurondb.embed if that
+// fails. Generation models are asked for a single token.
+func ProbeModel(ctx context.Context, executor *QueryExecutor, target ModelTarget) ModelProbe {
+	probe := ModelProbe{Model: target.Name, Kind: target.Kind}
+	start := time.Now()
+	var err error
+	switch target.Kind {
+	case ModelKindEmbedding:
+		var row map[string]interface{}
+		row, err = executor.ExecuteQueryOneWithTimeout(ctx, "SELECT vector_dims(embed_text($1, $2)) AS dims", []interface{}{modelProbeInput, target.Name}, EmbeddingQueryTimeout)
+		if err != nil {
+			row, err = executor.ExecuteQueryOneWithTimeout(ctx, "SELECT vector_dims(neurondb.embed($1, $2, 'embedding')) AS dims", []interface{}{target.Name, modelProbeInput}, EmbeddingQueryTimeout)
+		}
+		if err == nil {
+			probe.Dimensions = intValue(row["dims"])
+		}
+	case ModelKindGeneration:
+		_, err = executor.ExecuteQueryOneWithTimeout(ctx, `SELECT neurondb.llm('generation', $1, $2, NULL, '{"max_tokens": 1}'::jsonb, 1) AS response`, []interface{}{target.Name, modelProbeInput}, EmbeddingQueryTimeout)
+	default:
+		err = fmt.Errorf("unknown model kind '%s'", target.Kind)
+	}
+	probe.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		probe.Error = err.Error()
+	} else {
+		probe.Available = true
+	}
+	return probe
+}
+
+// WarmupModels probes each model once, in order, so the extension loads
+// them before the first real call
+func WarmupModels(ctx context.Context, executor *QueryExecutor, targets []ModelTarget) []ModelProbe {
+	probes := make([]ModelProbe, 0, len(targets))
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		probes = append(probes, ProbeModel(ctx, executor, target))
+	}
+	return probes
+}
+
+func intValue(v interface{}) int {
+	switch n := v.(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+// modelTargetsParam reads the embedding_models and generation_models
+// parameters, falling back to the configured models when both are absent
+func modelTargetsParam(ctx context.Context, params map[string]interface{}) ([]ModelTarget, *ToolResult) {
+	var targets []ModelTarget
+	for _, list := range []struct {
+		param string
+		kind  string
+	}{
+		{"embedding_models", ModelKindEmbedding},
+		{"generation_models", ModelKindGeneration},
+	} {
+		names, _ := params[list.param].([]interface{})
+		for i, n := range names {
+			name, ok := n.(string)
+			if !ok || name == "" {
+				return nil, Error(fmt.Sprintf("%s at index %d must be a non-empty string", list.param, i), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": list.param,
+				})
+			}
+			targets = append(targets, ModelTarget{Name: name, Kind: list.kind})
+		}
+	}
+	if len(targets) == 0 {
+		targets = ModelTargetsFromContext(ctx)
+	}
+	if len(targets) == 0 {
+		return nil, Error("No models to probe: pass embedding_models or generation_models, or configure features.models", "VALIDATION_ERROR", nil)
+	}
+	return targets, nil
+}
+
+var modelListProperties = map[string]interface{}{
+	"embedding_models": map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": "Embedding models to call. Defaults to the configured models when no models are given.",
+	},
+	"generation_models": map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": "Generation models to call. Defaults to the configured models when no models are given.",
+	},
+}
+
+// WarmupModelsTool loads models ahead of real traffic
+type WarmupModelsTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewWarmupModelsTool creates a new warmup models tool
+func NewWarmupModelsTool(db *database.Database, logger *logging.Logger) *WarmupModelsTool {
+	return &WarmupModelsTool{
+		BaseTool: NewBaseTool(
+			"warmup_models",
+			"Warm up embedding and generation models with one tiny call each, so later calls skip the model's cold start. Reports each call's latency.",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": modelListProperties,
+				"required":   []interface{}{},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute calls each model once
+func (t *WarmupModelsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	targets, errResult := modelTargetsParam(ctx, params)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	probes := WarmupModels(ctx, t.executor, targets)
+	failed := 0
+	for _, p := range probes {
+		if !p.Available {
+			failed++
+			t.logger.Warn("Model warm-up failed", map[string]interface{}{
+				"model": p.Model,
+				"kind":  p.Kind,
+				"error": p.Error,
+			})
+		}
+	}
+
+	return Success(map[string]interface{}{
+		"models": probes,
+		"warmed": len(probes) - failed,
+		"failed": failed,
+	}, map[string]interface{}{
+		"tool": "warmup_models",
+	}), nil
+}
+
+// ModelHealth summarizes repeated probes of one model
+type ModelHealth struct {
+	Model  string `json:"model"`
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	// Availability is the share of probes that succeeded
+	Availability float64 `json:"availability"`
+	Samples      int     `json:"samples"`
+	// FirstMs is the latency of the first probe, which includes any cold
+	// start. Min, avg and max cover the successful probes.
+	FirstMs   float64 `json:"first_ms"`
+	MinMs     float64 `json:"min_ms,omitempty"`
+	AvgMs     float64 `json:"avg_ms,omitempty"`
+	MaxMs     float64 `json:"max_ms,omitempty"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// summarizeModelProbes folds probes of one model into its health. The model
+// is available when every probe succeeded, degraded when some did and
+// unavailable when none did.
+func summarizeModelProbes(target ModelTarget, probes []ModelProbe) ModelHealth {
+	health := ModelHealth{Model: target.Name, Kind: target.Kind, Samples: len(probes)}
+	if len(probes) > 0 {
+		health.FirstMs = probes[0].LatencyMs
+	}
+	ok := 0
+	total := 0.0
+	health.MinMs = math.Inf(1)
+	for _, p := range probes {
+		if !p.Available {
+			health.LastError = p.Error
+			continue
+		}
+		ok++
+		total += p.LatencyMs
+		health.MinMs = math.Min(health.MinMs, p.LatencyMs)
+		health.MaxMs = math.Max(health.MaxMs, p.LatencyMs)
+	}
+	switch {
+	case ok == 0:
+		health.Status = "unavailable"
+		health.MinMs = 0
+	case ok < len(probes):
+		health.Status = "degraded"
+	default:
+		health.Status = "available"
+	}
+	if ok > 0 {
+		health.AvgMs = total / float64(ok)
+	}
+	if len(probes) > 0 {
+		health.Availability = float64(ok) / float64(len(probes))
+	}
+	return health
+}
+
+// ModelHealthTool probes models and reports their latency and availability
+type ModelHealthTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewModelHealthTool creates a new model health tool
+func NewModelHealthTool(db *database.Database, logger *logging.Logger) *ModelHealthTool {
+	properties := map[string]interface{}{
+		"samples": map[string]interface{}{
+			"type":        "number",
+			"default":     defaultModelHealthSamples,
+			"minimum":     1,
+			"maximum":     maxModelHealthSamples,
+			"description": "Probes per model",
+		},
+	}
+	for name, schema := range modelListProperties {
+		properties[name] = schema
+	}
+	return &ModelHealthTool{
+		BaseTool: NewBaseTool(
+			"model_health",
+			"Probe embedding and generation models with tiny calls and report per-model availability and latency, including the first call's cold-start latency",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   []interface{}{},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute probes each model samples times. The overall status is healthy
+// when every model is available, unhealthy when none is and degraded
+// otherwise.
+func (t *ModelHealthTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	targets, errResult := modelTargetsParam(ctx, params)
+	if errResult != nil {
+		return errResult, nil
+	}
+	samples := defaultModelHealthSamples
+	if v, ok := params["samples"].(float64); ok {
+		samples = int(v)
+	}
+	if samples < 1 || samples > maxModelHealthSamples {
+		return Error(fmt.Sprintf("samples must be between 1 and %d, got %d", maxModelHealthSamples, samples), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "samples",
+		}), nil
+	}
+
+	models := make([]ModelHealth, 0, len(targets))
+	available := 0
+	for _, target := range targets {
+		probes := make([]ModelProbe, 0, samples)
+		for i := 0; i < samples && ctx.Err() == nil; i++ {
+			probes = append(probes, ProbeModel(ctx, t.executor, target))
+		}
+		health := summarizeModelProbes(target, probes)
+		if health.Status == "available" {
+			available++
+		} else {
+			t.logger.Warn("Model health probe failed", map[string]interface{}{
+				"model":  health.Model,
+				"kind":   health.Kind,
+				"status": health.Status,
+				"error":  health.LastError,
+			})
+		}
+		models = append(models, health)
+	}
+
+	status := "degraded"
+	switch available {
+	case len(models):
+		status = "healthy"
+	case 0:
+		status = "unhealthy"
+	}
+
+	return Success(map[string]interface{}{
+		"status": status,
+		"models": models,
+	}, map[string]interface{}{
+		"tool": "model_health",
+	}), nil
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"testing"
+)
+
+func TestModelTargetsParam(t *testing.T) {
+	targets, errResult := modelTargetsParam(context.Background(), map[string]interface{}{
+		"embedding_models":  []interface{}{"all-MiniLM-L6-v2"},
+		"generation_models": []interface{}{"gpt-4o-mini"},
+	})
+	if errResult != nil {
+		t.Fatalf("unexpected error: %+v", errResult)
+	}
+	want := []ModelTarget{{"all-MiniLM-L6-v2", ModelKindEmbedding}, {"gpt-4o-mini", ModelKindGeneration}}
+	if len(targets) != 2 || targets[0] != want[0] || targets[1] != want[1] {
+		t.Errorf("targets = %+v, want %+v", targets, want)
+	}
+
+	configured := []ModelTarget{{"default", ModelKindEmbedding}}
+	ctx := WithModelTargets(context.Background(), configured)
+	if targets, _ := modelTargetsParam(ctx, map[string]interface{}{}); len(targets) != 1 || targets[0] != configured[0] {
+		t.Errorf("configured targets not used: %+v", targets)
+	}
+
+	if _, errResult := modelTargetsParam(context.Background(), map[string]interface{}{}); errResult == nil {
+		t.Error("expected an error without models")
+	}
+	if _, errResult := modelTargetsParam(ctx, map[string]interface{}{"embedding_models": []interface{}{""}}); errResult == nil {
+		t.Error("expected an error for an empty model name")
+	}
+}
+
+func TestSummarizeModelProbes(t *testing.T) {
+	target := ModelTarget{"default", ModelKindEmbedding}
+
+	h := summarizeModelProbes(target, []ModelProbe{
+		{Available: true, LatencyMs: 900},
+		{Available: true, LatencyMs: 10},
+		{Available: true, LatencyMs: 20},
+	})
+	if h.Status != "available" || h.Availability != 1 || h.FirstMs != 900 || h.MinMs != 10 || h.MaxMs != 900 || h.AvgMs != 310 {
+		t.Errorf("all succeeded: %+v", h)
+	}
+
+	h = summarizeModelProbes(target, []ModelProbe{
+		{Available: false, LatencyMs: 5, Error: "model not found"},
+		{Available: true, LatencyMs: 30},
+	})
+	if h.Status != "degraded" || h.Availability != 0.5 || h.MinMs != 30 || h.LastError != "model not found" {
+		t.Errorf("some failed: %+v", h)
+	}
+
+	h = summarizeModelProbes(target, []ModelProbe{{Available: false, LatencyMs: 5, Error: "boom"}})
+	if h.Status != "unavailable" || h.Availability != 0 || h.MinMs != 0 || h.AvgMs != 0 {
+		t.Errorf("all failed: %+v", h)
+	}
+}
@@ -0,0 +1,415 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// maxSearchColumns bounds the vector columns of one multi-column search
+const maxSearchColumns = 8
+
+// multiColumnSimilarities converts a distance expression of each metric to a
+// similarity where higher is better: 1 - cosine distance, the inner product
+// (pgvector's <#> is its negation), and 1 / (1 + L2 distance)
+var multiColumnSimilarities = map[string]string{
+	"l2":            "1.0 / (1.0 + %s)",
+	"cosine":        "1.0 - %s",
+	"inner_product": "-%s",
+}
+
+// MultiColumnVectorSearchTool searches several vector columns of a table at
+// once and ranks rows by an aggregate of the per-column scores
+type MultiColumnVectorSearchTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewMultiColumnVectorSearchTool creates a new multi-column vector search tool
+func NewMultiColumnVectorSearchTool(db *database.Database, logger *logging.Logger) *MultiColumnVectorSearchTool {
+	return &MultiColumnVectorSearchTool{
+		BaseTool: NewBaseTool(
+			"multi_column_vector_search",
+			"Search several vector columns of one table, such as title_embedding and body_embedding, with per-column weights, and rank rows by a score aggregated in the database",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table to search, optionally schema-qualified",
+					},
+					"columns": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"column": map[string]interface{}{
+									"type":        "string",
+									"description": "Vector column",
+								},
+								"weight": map[string]interface{}{
+									"type":        "number",
+									"default":     1.0,
+									"minimum":     0.0,
+									"description": "Weight of the column's score",
+								},
+								"query_vector": map[string]interface{}{
+									"type":        "array",
+									"items":       map[string]interface{}{"type": "number"},
+									"description": "Query vector for this column; defaults to the top-level query_vector",
+								},
+							},
+							"required": []interface{}{"column"},
+						},
+						"description": fmt.Sprintf("Vector columns to search (1 to %d)", maxSearchColumns),
+					},
+					"query_vector": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "number"},
+						"description": "Query vector for the columns that do not have their own",
+					},
+					"id_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Unique key of the table, used to merge the candidates of each column",
+					},
+					"distance_metric": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"l2", "cosine", "inner_product"},
+						"default":     "cosine",
+						"description": "Distance metric of every column",
+					},
+					"aggregation": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"weighted_sum", "max", "rrf"},
+						"default":     "weighted_sum",
+						"description": "How column scores combine: weighted mean of similarities, maximum weighted similarity, or weighted reciprocal rank fusion",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     10,
+						"minimum":     1,
+						"maximum":     1000,
+						"description": "Maximum number of results",
+					},
+					"candidates": map[string]interface{}{
+						"type":        "number",
+						"minimum":     1,
+						"maximum":     10000,
+						"description": "Nearest rows fetched per column before scoring (default 4 x limit, at least 50)",
+					},
+					"additional_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Columns to return; all columns when omitted",
+					},
+				},
+				"required": []interface{}{"table", "columns"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// searchColumn is one vector column of a multi-column search
+type searchColumn struct {
+	column string
+	weight float64
+	vector string
+}
+
+// multiColumnSearchRequest is a validated multi-column search
+type multiColumnSearchRequest struct {
+	table             pgx.Identifier
+	tableName         string
+	idColumn          string
+	columns           []searchColumn
+	metric            string
+	aggregation       string
+	limit             int
+	candidates        int
+	additionalColumns []string
+}
+
+// Execute executes the multi-column vector search
+func (t *MultiColumnVectorSearchTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for multi_column_vector_search tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+			"params": params,
+		}), nil
+	}
+
+	req, invalid := parseMultiColumnSearchRequest(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	query, queryParams := buildMultiColumnSearchQuery(req)
+	queryCtx, cancel := context.WithTimeout(ctx, VectorSearchTimeout)
+	defer cancel()
+	results, err := t.executor.ExecuteQuery(queryCtx, query, queryParams)
+	if err != nil {
+		t.logger.Error("Multi-column vector search failed", err, map[string]interface{}{
+			"table": req.tableName,
+		})
+		return Error(fmt.Sprintf("Multi-column vector search failed: table='%s', columns=%v, error=%v", req.tableName, req.columnNames(), err), "SEARCH_ERROR", map[string]interface{}{
+			"table":   req.tableName,
+			"columns": req.columnNames(),
+			"error":   err.Error(),
+		}), nil
+	}
+
+	weights := make(map[string]interface{}, len(req.columns))
+	for _, c := range req.columns {
+		weights[c.column] = c.weight
+	}
+	return Success(map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	}, map[string]interface{}{
+		"count":           len(results),
+		"table":           req.tableName,
+		"weights":         weights,
+		"distance_metric": req.metric,
+		"aggregation":     req.aggregation,
+		"candidates":      req.candidates,
+	}), nil
+}
+
+func (r multiColumnSearchRequest) columnNames() []string {
+	names := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		names[i] = c.column
+	}
+	return names
+}
+
+// parseMultiColumnSearchRequest validates the search parameters, returning
+// a validation error result when they are unusable
+func parseMultiColumnSearchRequest(params map[string]interface{}) (multiColumnSearchRequest, *ToolResult) {
+	req := multiColumnSearchRequest{
+		idColumn:    stringParam(params, "id_column", "id"),
+		metric:      stringParam(params, "distance_metric", "cosine"),
+		aggregation: stringParam(params, "aggregation", "weighted_sum"),
+		limit:       10,
+	}
+
+	req.tableName, _ = params["table"].(string)
+	table, err := parseQualifiedIdentifier(req.tableName)
+	if err != nil {
+		return req, Error(fmt.Sprintf("Invalid table '%s': %v", req.tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		})
+	}
+	req.table = table
+
+	if _, ok := multiColumnSimilarities[req.metric]; !ok {
+		return req, Error(fmt.Sprintf("Unsupported distance_metric '%s': use l2, cosine or inner_product", req.metric), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "distance_metric",
+		})
+	}
+	switch req.aggregation {
+	case "weighted_sum", "max", "rrf":
+	default:
+		return req, Error(fmt.Sprintf("Unsupported aggregation '%s': use weighted_sum, max or rrf", req.aggregation), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "aggregation",
+		})
+	}
+
+	if v, ok := params["limit"].(float64); ok {
+		req.limit = int(v)
+	}
+	if req.limit < 1 || req.limit > 1000 {
+		return req, Error(fmt.Sprintf("limit must be between 1 and 1000, got %d", req.limit), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "limit",
+		})
+	}
+	req.candidates = req.limit * 4
+	if req.candidates < 50 {
+		req.candidates = 50
+	}
+	if v, ok := params["candidates"].(float64); ok {
+		req.candidates = int(v)
+	}
+	if req.candidates < req.limit || req.candidates > 10000 {
+		return req, Error(fmt.Sprintf("candidates must be between the limit (%d) and 10000, got %d", req.limit, req.candidates), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "candidates",
+		})
+	}
+
+	var defaultVector string
+	if v, ok := params["query_vector"].([]interface{}); ok {
+		vec, err := searchVectorParam(v)
+		if err != nil {
+			return req, Error(fmt.Sprintf("Invalid query_vector: %v", err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "query_vector",
+			})
+		}
+		defaultVector = vec
+	}
+
+	columns, _ := params["columns"].([]interface{})
+	if len(columns) == 0 || len(columns) > maxSearchColumns {
+		return req, Error(fmt.Sprintf("columns must list between 1 and %d vector columns, got %d", maxSearchColumns, len(columns)), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "columns",
+		})
+	}
+	seen := make(map[string]bool, len(columns))
+	var totalWeight float64
+	for i, item := range columns {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			return req, Error(fmt.Sprintf("columns at index %d must be an object with a column name", i), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "columns",
+			})
+		}
+		col := searchColumn{column: stringParam(spec, "column", ""), weight: 1.0, vector: defaultVector}
+		if col.column == "" || seen[col.column] {
+			return req, Error(fmt.Sprintf("columns at index %d must name a vector column not listed before", i), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "columns",
+			})
+		}
+		seen[col.column] = true
+		if w, ok := spec["weight"].(float64); ok {
+			col.weight = w
+		}
+		if col.weight < 0 {
+			return req, Error(fmt.Sprintf("weight of column '%s' must not be negative, got %g", col.column, col.weight), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "columns",
+			})
+		}
+		if v, ok := spec["query_vector"].([]interface{}); ok {
+			vec, err := searchVectorParam(v)
+			if err != nil {
+				return req, Error(fmt.Sprintf("Invalid query_vector of column '%s': %v", col.column, err), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "columns",
+				})
+			}
+			col.vector = vec
+		}
+		if col.vector == "" {
+			return req, Error(fmt.Sprintf("column '%s' has no query_vector and no top-level query_vector was given", col.column), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "query_vector",
+			})
+		}
+		totalWeight += col.weight
+		req.columns = append(req.columns, col)
+	}
+	if totalWeight == 0 {
+		return req, Error("at least one column must have a positive weight", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "columns",
+		})
+	}
+
+	list, _ := params["additional_columns"].([]interface{})
+	for i, c := range list {
+		name, ok := c.(string)
+		if !ok || name == "" {
+			return req, Error(fmt.Sprintf("additional_columns at index %d must be a non-empty string", i), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "additional_columns",
+			})
+		}
+		req.additionalColumns = append(req.additionalColumns, name)
+	}
+	return req, nil
+}
+
+// searchVectorParam formats a query vector parameter as a vector literal
+func searchVectorParam(values []interface{}) (string, error) {
+	if len(values) == 0 {
+		return "", fmt.Errorf("vector is empty")
+	}
+	for i, v := range values {
+		if _, ok := v.(float64); !ok {
+			return "", fmt.Errorf("element %d is %T, not a number", i, v)
+		}
+	}
+	return formatVectorFromInterface(values), nil
+}
+
+// buildMultiColumnSearchQuery builds the search in three steps. Each column's
+// candidates nearest rows are found by an ORDER BY distance LIMIT subquery,
+// which an index on the column serves. The union of their keys is scored by
+// the exact distance on every column, so a row found through one column is
+// still scored on the others; a NULL vector scores nothing. The rows are then
+// ranked by the aggregate score.
+//
+// The query vectors are bound as $1..$n in column order, then the
+// candidates and the limit. Results have the selected columns, a
+// <column>_distance per searched column, and score.
+func buildMultiColumnSearchQuery(req multiColumnSearchRequest) (string, []interface{}) {
+	op := similarityJoinOperators[req.metric]
+	table := req.table.Sanitize()
+	key := pgx.Identifier{req.idColumn}.Sanitize()
+
+	params := make([]interface{}, 0, len(req.columns)+2)
+	for _, c := range req.columns {
+		params = append(params, c.vector)
+	}
+	candidatesParam := len(params) + 1
+	limitParam := len(params) + 2
+	params = append(params, req.candidates, req.limit)
+
+	var nearest, distances, scores []string
+	var totalWeight float64
+	for i, c := range req.columns {
+		col := pgx.Identifier{c.column}.Sanitize()
+		distance := fmt.Sprintf("%s %s $%d::vector", col, op, i+1)
+		nearest = append(nearest, fmt.Sprintf("(SELECT %s FROM %s WHERE %s IS NOT NULL ORDER BY %s LIMIT $%d)",
+			key, table, col, distance, candidatesParam))
+		distanceName := pgx.Identifier{c.column + "_distance"}.Sanitize()
+		distances = append(distances, distance+" AS "+distanceName)
+
+		weight := strconv.FormatFloat(c.weight, 'g', -1, 64)
+		totalWeight += c.weight
+		scored := "s." + distanceName
+		switch req.aggregation {
+		case "rrf":
+			scores = append(scores, fmt.Sprintf("CASE WHEN %s IS NULL THEN 0 ELSE %s / (%d + rank() OVER (ORDER BY %s)) END",
+				scored, weight, rrfK, scored))
+		case "max":
+			scores = append(scores, fmt.Sprintf("%s * ("+multiColumnSimilarities[req.metric]+")", weight, scored))
+		default:
+			scores = append(scores, fmt.Sprintf("%s * COALESCE("+multiColumnSimilarities[req.metric]+", 0)", weight, scored))
+		}
+	}
+
+	var score string
+	switch req.aggregation {
+	case "max":
+		score = "GREATEST(" + strings.Join(scores, ", ") + ")"
+	case "rrf":
+		score = strings.Join(scores, " + ")
+	default:
+		score = "(" + strings.Join(scores, " + ") + ") / " + strconv.FormatFloat(totalWeight, 'g', -1, 64)
+	}
+
+	selected := "t.*"
+	if len(req.additionalColumns) > 0 {
+		cols := make([]string, len(req.additionalColumns))
+		for i, c := range req.additionalColumns {
+			cols[i] = "t." + pgx.Identifier{c}.Sanitize()
+		}
+		selected = strings.Join(cols, ", ")
+	}
+
+	query := fmt.Sprintf(
+		"WITH candidates AS (%s) "+
+			"SELECT s.*, %s AS score FROM (SELECT %s, %s FROM %s t WHERE t.%s IN (SELECT %s FROM candidates)) s "+
+			"ORDER BY score DESC NULLS LAST LIMIT $%d",
+		strings.Join(nearest, " UNION "),
+		score,
+		selected, strings.Join(distances, ", "), table, key, key,
+		limitParam,
+	)
+	return query, params
+}
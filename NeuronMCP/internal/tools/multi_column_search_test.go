@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildMultiColumnSearchQuery(t *testing.T) {
+	req, invalid := parseMultiColumnSearchRequest(map[string]interface{}{
+		"table": "public.articles",
+		"columns": []interface{}{
+			map[string]interface{}{"column": "title_embedding", "weight": 0.3},
+			map[string]interface{}{"column": "body_embedding", "weight": 0.7, "query_vector": []interface{}{0.5, 0.5}},
+		},
+		"query_vector":       []interface{}{1.0, 2.0},
+		"limit":              float64(5),
+		"additional_columns": []interface{}{"title"},
+	})
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if req.candidates != 50 {
+		t.Errorf("candidates = %d, want 50", req.candidates)
+	}
+
+	query, params := buildMultiColumnSearchQuery(req)
+	for _, want := range []string{
+		`(SELECT "id" FROM "public"."articles" WHERE "title_embedding" IS NOT NULL ORDER BY "title_embedding" <=> $1::vector LIMIT $3) UNION (SELECT "id"`,
+		`ORDER BY "body_embedding" <=> $2::vector LIMIT $3)`,
+		`0.3 * COALESCE(1.0 - s."title_embedding_distance", 0)`,
+		`) / 1 AS score`,
+		`SELECT t."title", "title_embedding" <=> $1::vector AS "title_embedding_distance"`,
+		`WHERE t."id" IN (SELECT "id" FROM candidates)`,
+		`ORDER BY score DESC NULLS LAST LIMIT $4`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if want := []interface{}{"[1,2]", "[0.5,0.5]", 50, 5}; !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %v, want %v", params, want)
+	}
+
+	req.aggregation = "rrf"
+	query, _ = buildMultiColumnSearchQuery(req)
+	if !strings.Contains(query, `0.7 / (60 + rank() OVER (ORDER BY s."body_embedding_distance"))`) {
+		t.Errorf("rrf query:\n%s", query)
+	}
+	req.aggregation = "max"
+	query, _ = buildMultiColumnSearchQuery(req)
+	if !strings.Contains(query, `GREATEST(0.3 * (1.0 - s."title_embedding_distance"), 0.7 * (1.0 - s."body_embedding_distance"))`) {
+		t.Errorf("max query:\n%s", query)
+	}
+}
+
+func TestParseMultiColumnSearchRequestErrors(t *testing.T) {
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"table":        "articles",
+			"columns":      []interface{}{map[string]interface{}{"column": "title_embedding"}},
+			"query_vector": []interface{}{1.0, 2.0},
+		}
+	}
+	cases := map[string]func(map[string]interface{}){
+		"no columns": func(p map[string]interface{}) { p["columns"] = []interface{}{} },
+		"duplicate column": func(p map[string]interface{}) {
+			p["columns"] = []interface{}{map[string]interface{}{"column": "a"}, map[string]interface{}{"column": "a"}}
+		},
+		"no query vector": func(p map[string]interface{}) { delete(p, "query_vector") },
+		"negative weight": func(p map[string]interface{}) {
+			p["columns"] = []interface{}{map[string]interface{}{"column": "a", "weight": -1.0}}
+		},
+		"all zero weights": func(p map[string]interface{}) {
+			p["columns"] = []interface{}{map[string]interface{}{"column": "a", "weight": 0.0}}
+		},
+		"bad metric":        func(p map[string]interface{}) { p["distance_metric"] = "hamming" },
+		"bad aggregation":   func(p map[string]interface{}) { p["aggregation"] = "median" },
+		"few candidates":    func(p map[string]interface{}) { p["limit"] = float64(20); p["candidates"] = float64(10) },
+		"non-number vector": func(p map[string]interface{}) { p["query_vector"] = []interface{}{"x"} },
+	}
+	for name, mutate := range cases {
+		params := base()
+		mutate(params)
+		if _, invalid := parseMultiColumnSearchRequest(params); invalid == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+	if _, invalid := parseMultiColumnSearchRequest(base()); invalid != nil {
+		t.Errorf("valid request rejected: %+v", invalid)
+	}
+}
@@ -0,0 +1,543 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Outlier detection limits
+const (
+	// DetectOutliersTimeout bounds a whole detect_outliers call
+	DetectOutliersTimeout = 15 * time.Minute
+	defaultOutlierLimit   = 100
+	maxOutlierLimit       = 10000
+	defaultOutlierK       = 20
+	maxOutlierK           = 1000
+	defaultOutlierTrees   = 100
+	maxOutlierTrees       = 1000
+)
+
+// Temporary relations detect_outliers scores, in the layout of
+// vectorInputQuery
+const (
+	outlierInputTable = "neurondb_outlier_input"
+	outlierInputView  = "neurondb_outlier_vectors"
+)
+
+// outlierMethod is how detect_outliers flags rows with one method
+type outlierMethod struct {
+	// function is the NeuronDB function flagging the rows, with its
+	// argument types; empty when the scores are computed in SQL
+	function string
+	// optional functions are not part of every NeuronDB build
+	optional bool
+	// scored methods return a score per row, so they take a percentile
+	scored bool
+	// thresholded methods take a threshold, defaultThreshold when absent
+	thresholded      bool
+	defaultThreshold float64
+}
+
+// outlierMethods are the methods detect_outliers supports
+var outlierMethods = map[string]outlierMethod{
+	"zscore":            {function: "detect_outliers_zscore(text,text,double precision,text)", scored: true, thresholded: true, defaultThreshold: 3},
+	"modified_zscore":   {function: "detect_outliers_zscore(text,text,double precision,text)", scored: true, thresholded: true, defaultThreshold: 3.5},
+	"iqr":               {function: "detect_outliers_zscore(text,text,double precision,text)", thresholded: true, defaultThreshold: 1.5},
+	"centroid_distance": {scored: true, thresholded: true},
+	"lof":               {function: "neurondb.detect_anomalies_lof(text,text,integer,double precision)", optional: true, thresholded: true, defaultThreshold: 1.5},
+	"isolation_forest":  {function: "neurondb.detect_anomalies_isolation_forest(text,text,integer,double precision)", optional: true},
+}
+
+// outlierRequest is a validated detect_outliers call
+type outlierRequest struct {
+	table        pgx.Identifier
+	tableName    string
+	vectorColumn string
+	idColumn     string
+	method       string
+	threshold    *float64
+	percentile   *float64
+	metric       string
+	k            int
+	nTrees       int
+	limit        int
+	tagColumn    string
+	overwrite    bool
+}
+
+// DetectOutliersTool flags the outlying vectors of a table
+type DetectOutliersTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewDetectOutliersTool creates a new detect outliers tool
+func NewDetectOutliersTool(db *database.Database, logger *logging.Logger) *DetectOutliersTool {
+	return &DetectOutliersTool{
+		BaseTool: NewBaseTool(
+			"detect_outliers",
+			"Detect outlying vectors in a table by Z-score, modified Z-score, IQR, distance to the centroid, local outlier factor or isolation forest; returns the flagged rows with their scores and can tag them in a boolean column",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the vector column; rows where it is NULL are not scored",
+					},
+					"id_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column identifying rows in the result and when tagging",
+					},
+					"method": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"zscore", "modified_zscore", "iqr", "centroid_distance", "lof", "isolation_forest"},
+						"default":     "zscore",
+						"description": "zscore, modified_zscore and iqr score each vector's distance from the mean vector; centroid_distance is that distance itself; lof compares each vector's density with its neighbours'; isolation_forest isolates vectors with random splits. lof and isolation_forest need a NeuronDB build with those functions",
+					},
+					"threshold": map[string]interface{}{
+						"type":        "number",
+						"description": "Flag rows scoring above this: Z-score (default 3), modified Z-score (default 3.5), IQR multiplier (default 1.5), distance (centroid_distance) or LOF score (default 1.5). Not used by isolation_forest",
+					},
+					"percentile": map[string]interface{}{
+						"type":        "number",
+						"minimum":     0,
+						"maximum":     100,
+						"description": "Flag rows scoring above this percentile of the scores instead of a threshold (zscore, modified_zscore, centroid_distance; default 95 for centroid_distance). For isolation_forest, 100 minus the percentile is the expected share of outliers (default 90)",
+					},
+					"distance_metric": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"l2", "cosine"},
+						"default":     "l2",
+						"description": "Distance to the centroid (centroid_distance)",
+					},
+					"k": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultOutlierK,
+						"minimum":     1,
+						"maximum":     maxOutlierK,
+						"description": "Neighbours compared (lof)",
+					},
+					"n_trees": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultOutlierTrees,
+						"minimum":     1,
+						"maximum":     maxOutlierTrees,
+						"description": "Trees in the forest (isolation_forest)",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultOutlierLimit,
+						"minimum":     0,
+						"maximum":     maxOutlierLimit,
+						"description": "Flagged rows returned, highest score first",
+					},
+					"tag_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Boolean column of the table set to true for flagged rows and false for the other scored rows; added if missing",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace the values of an existing tag_column",
+					},
+				},
+				"required": []interface{}{"table", "vector_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute detects the outliers
+func (t *DetectOutliersTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for detect_outliers tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	req, errResult := parseOutlierRequest(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+	method := outlierMethods[req.method]
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for detect_outliers", "DATABASE_ERROR", map[string]interface{}{
+			"table": req.tableName,
+		}), nil
+	}
+
+	detectCtx, cancel := context.WithTimeout(ctx, DetectOutliersTimeout)
+	defer cancel()
+	start := time.Now()
+
+	if method.optional {
+		var available bool
+		if err := db.QueryRow(detectCtx, "SELECT to_regprocedure($1) IS NOT NULL", method.function).Scan(&available); err != nil {
+			return t.outlierError(req, "function lookup", err), nil
+		}
+		if !available {
+			return Error(fmt.Sprintf("Method '%s' needs %s, which this NeuronDB installation does not have; use zscore, modified_zscore, iqr or centroid_distance", req.method, method.function), "FUNCTION_UNAVAILABLE", map[string]interface{}{
+				"parameter": "method",
+				"function":  method.function,
+			}), nil
+		}
+	}
+
+	// Everything runs in one transaction. Without tag_column it is rolled
+	// back, leaving nothing behind.
+	tx, err := db.Begin(detectCtx)
+	if err != nil {
+		return t.outlierError(req, "transaction", err), nil
+	}
+	defer tx.Rollback(detectCtx)
+
+	tag, err := tx.Exec(detectCtx, vectorInputQuery(outlierInputTable, req.table, req.idColumn, req.vectorColumn))
+	if err != nil {
+		return t.outlierError(req, "reading vectors", err), nil
+	}
+	rows := int(tag.RowsAffected())
+	if rows < 2 {
+		return Error(fmt.Sprintf("Table '%s' has %d rows with a '%s' vector, at least 2 are needed", req.tableName, rows, req.vectorColumn), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"rows":      rows,
+		}), nil
+	}
+	if _, err := tx.Exec(detectCtx, vectorInputViewQuery(outlierInputView, outlierInputTable)); err != nil {
+		return t.outlierError(req, "reading vectors", err), nil
+	}
+
+	scores, flags, err := scoreOutliers(detectCtx, tx, req)
+	if err != nil {
+		return t.outlierError(req, "scoring", err), nil
+	}
+	if (scores != nil && len(scores) != rows) || (flags != nil && len(flags) != rows) {
+		return t.outlierError(req, "scoring", fmt.Errorf("method '%s' returned results for %d of %d rows", req.method, max(len(scores), len(flags)), rows)), nil
+	}
+	cutoff := map[string]interface{}{}
+	switch {
+	case req.percentile != nil && req.method != "isolation_forest":
+		scoreCutoff := percentileOf(scores, *req.percentile)
+		flags = flagAbove(scores, scoreCutoff)
+		cutoff["percentile"] = *req.percentile
+		cutoff["score_cutoff"] = scoreCutoff
+	case req.percentile != nil:
+		cutoff["contamination"] = (100 - *req.percentile) / 100
+	case req.method == "centroid_distance":
+		flags = flagAbove(scores, *req.threshold)
+		cutoff["threshold"] = *req.threshold
+	case req.threshold != nil:
+		cutoff["threshold"] = *req.threshold
+	}
+
+	flagged := flaggedRows(scores, flags)
+	returned := flagged
+	if len(returned) > req.limit {
+		returned = returned[:req.limit]
+	}
+	outliers, err := describeOutliers(detectCtx, tx, req, returned, scores)
+	if err != nil {
+		return t.outlierError(req, "reading flagged rows", err), nil
+	}
+
+	result := map[string]interface{}{
+		"table":            req.tableName,
+		"vector_column":    req.vectorColumn,
+		"method":           req.method,
+		"rows":             rows,
+		"flagged":          len(flagged),
+		"flagged_fraction": float64(len(flagged)) / float64(rows),
+		"cutoff":           cutoff,
+		"outliers":         outliers,
+		"truncated":        len(flagged) > len(returned),
+	}
+	if scores != nil {
+		result["scores"] = scoreSummary(scores)
+	}
+	if rows == maxVectorFunctionRows {
+		result["note"] = fmt.Sprintf("scored the first %d rows by %s", rows, req.idColumn)
+	}
+
+	if req.tagColumn != "" {
+		if _, err := tx.Exec(detectCtx, "DROP VIEW "+outlierInputView); err != nil {
+			return t.outlierError(req, "tagging", err), nil
+		}
+		values := fmt.Sprintf("SELECT i.row_id, f.flag AS value FROM %s i JOIN unnest($1::boolean[]) WITH ORDINALITY AS f(flag, ord) USING (ord)", outlierInputTable)
+		written, errResult, err := writeInputColumn(detectCtx, tx, req.table, req.tableName, req.idColumn, req.tagColumn, "boolean", "tag_column", req.overwrite, values, flags)
+		if errResult != nil {
+			return errResult, nil
+		}
+		if err != nil {
+			return t.outlierError(req, "tagging", err), nil
+		}
+		if err := tx.Commit(detectCtx); err != nil {
+			return t.outlierError(req, "commit", err), nil
+		}
+		result["tag_column"] = req.tagColumn
+		result["rows_tagged"] = written
+		t.logger.Info("Outliers tagged", map[string]interface{}{
+			"table":      req.tableName,
+			"method":     req.method,
+			"tag_column": req.tagColumn,
+			"flagged":    len(flagged),
+		})
+	}
+
+	return Success(result, map[string]interface{}{
+		"tool":       "detect_outliers",
+		"elapsed_ms": msSince(start),
+	}), nil
+}
+
+// parseOutlierRequest validates the detect_outliers parameters, returning
+// a validation error result when they are unusable
+func parseOutlierRequest(params map[string]interface{}) (outlierRequest, *ToolResult) {
+	req := outlierRequest{
+		tableName:    stringParam(params, "table", ""),
+		vectorColumn: stringParam(params, "vector_column", ""),
+		idColumn:     stringParam(params, "id_column", "id"),
+		method:       stringParam(params, "method", "zscore"),
+		metric:       stringParam(params, "distance_metric", "l2"),
+		tagColumn:    stringParam(params, "tag_column", ""),
+	}
+	req.overwrite, _ = params["overwrite"].(bool)
+	table, err := parseQualifiedIdentifier(req.tableName)
+	if err != nil {
+		return req, Error(fmt.Sprintf("Invalid table '%s': %v", req.tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		})
+	}
+	req.table = table
+	if req.vectorColumn == "" {
+		return req, Error("vector_column parameter is required and cannot be empty for detect_outliers tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "vector_column",
+		})
+	}
+	method, ok := outlierMethods[req.method]
+	if !ok {
+		return req, Error(fmt.Sprintf("Unsupported outlier detection method '%s' for detect_outliers tool", req.method), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "method",
+		})
+	}
+	if req.metric != "l2" && req.metric != "cosine" {
+		return req, Error(fmt.Sprintf("distance_metric must be l2 or cosine, got '%s'", req.metric), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "distance_metric",
+		})
+	}
+	if req.tagColumn != "" && (req.tagColumn == req.vectorColumn || req.tagColumn == req.idColumn) {
+		return req, Error(fmt.Sprintf("tag_column '%s' cannot be the vector or id column", req.tagColumn), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "tag_column",
+		})
+	}
+
+	var errResult *ToolResult
+	if req.k, errResult = intParamInRange(params, "k", defaultOutlierK, 1, maxOutlierK); errResult != nil {
+		return req, errResult
+	}
+	if req.nTrees, errResult = intParamInRange(params, "n_trees", defaultOutlierTrees, 1, maxOutlierTrees); errResult != nil {
+		return req, errResult
+	}
+	if req.limit, errResult = intParamInRange(params, "limit", defaultOutlierLimit, 0, maxOutlierLimit); errResult != nil {
+		return req, errResult
+	}
+
+	threshold, hasThreshold := params["threshold"].(float64)
+	percentile, hasPercentile := params["percentile"].(float64)
+	switch {
+	case hasThreshold && hasPercentile:
+		return req, Error("Give either threshold or percentile, not both", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "percentile",
+		})
+	case hasThreshold && !method.thresholded:
+		return req, Error(fmt.Sprintf("Method '%s' takes no threshold; use percentile to set the expected share of outliers", req.method), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "threshold",
+		})
+	case hasThreshold && threshold <= 0:
+		return req, Error(fmt.Sprintf("threshold must be greater than 0, got %g", threshold), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "threshold",
+		})
+	case hasPercentile && !method.scored && method.thresholded:
+		return req, Error(fmt.Sprintf("Method '%s' gives no scores to take a percentile of; use threshold", req.method), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "percentile",
+		})
+	case hasPercentile && (percentile <= 0 || percentile >= 100):
+		return req, Error(fmt.Sprintf("percentile must be between 0 and 100, got %g", percentile), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "percentile",
+		})
+	}
+	switch {
+	case hasThreshold:
+		req.threshold = &threshold
+	case hasPercentile:
+		req.percentile = &percentile
+	case req.method == "centroid_distance":
+		p := 95.0
+		req.percentile = &p
+	case req.method == "isolation_forest":
+		p := 90.0
+		req.percentile = &p
+	default:
+		t := method.defaultThreshold
+		req.threshold = &t
+	}
+	return req, nil
+}
+
+// scoreOutliers scores the rows of the input view in order. Methods
+// without scores return nil scores, and methods flagged by a percentile or
+// a threshold on the scores in Go return nil flags.
+func scoreOutliers(ctx context.Context, tx pgx.Tx, req outlierRequest) ([]float64, []bool, error) {
+	var scores []float64
+	var flags []bool
+	switch req.method {
+	case "zscore", "modified_zscore":
+		if err := tx.QueryRow(ctx, "SELECT compute_outlier_scores($1, 'embedding', $2)", outlierInputView, req.method).Scan(&scores); err != nil {
+			return nil, nil, err
+		}
+		if req.threshold == nil {
+			return scores, nil, nil
+		}
+		fallthrough
+	case "iqr":
+		if err := tx.QueryRow(ctx, "SELECT detect_outliers_zscore($1, 'embedding', $2, $3)", outlierInputView, *req.threshold, req.method).Scan(&flags); err != nil {
+			return nil, nil, err
+		}
+	case "centroid_distance":
+		operator := similarityJoinOperators[req.metric]
+		query := fmt.Sprintf(`WITH centroid AS (SELECT vector_avg(embedding) AS c FROM %s)
+			SELECT (i.embedding %s centroid.c)::float8 FROM %s i, centroid ORDER BY i.ord`, outlierInputTable, operator, outlierInputTable)
+		rows, err := tx.Query(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		if scores, err = pgx.CollectRows(rows, pgx.RowTo[float64]); err != nil {
+			return nil, nil, err
+		}
+	case "lof":
+		if err := tx.QueryRow(ctx, "SELECT neurondb.detect_anomalies_lof($1, 'embedding', $2, $3)", outlierInputView, req.k, *req.threshold).Scan(&flags); err != nil {
+			return nil, nil, err
+		}
+	case "isolation_forest":
+		contamination := (100 - *req.percentile) / 100
+		if err := tx.QueryRow(ctx, "SELECT neurondb.detect_anomalies_isolation_forest($1, 'embedding', $2, $3)", outlierInputView, req.nTrees, contamination).Scan(&flags); err != nil {
+			return nil, nil, err
+		}
+	}
+	return scores, flags, nil
+}
+
+// flagAbove flags the scores above cutoff
+func flagAbove(scores []float64, cutoff float64) []bool {
+	flags := make([]bool, len(scores))
+	for i, s := range scores {
+		flags[i] = s > cutoff
+	}
+	return flags
+}
+
+// flaggedRows returns the positions of the flagged rows, highest score
+// first when there are scores and in row order otherwise
+func flaggedRows(scores []float64, flags []bool) []int {
+	var flagged []int
+	for i, f := range flags {
+		if f {
+			flagged = append(flagged, i)
+		}
+	}
+	if scores != nil {
+		sort.SliceStable(flagged, func(a, b int) bool {
+			return scores[flagged[a]] > scores[flagged[b]]
+		})
+	}
+	return flagged
+}
+
+// describeOutliers returns the id and score of the flagged rows at the
+// given positions, in that order
+func describeOutliers(ctx context.Context, tx pgx.Tx, req outlierRequest, positions []int, scores []float64) ([]map[string]interface{}, error) {
+	outliers := make([]map[string]interface{}, 0, len(positions))
+	if len(positions) == 0 {
+		return outliers, nil
+	}
+	ords := make([]int64, len(positions))
+	for i, p := range positions {
+		ords[i] = int64(p + 1)
+	}
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT ord, row_id::text FROM %s WHERE ord = ANY($1::bigint[])", outlierInputTable), ords)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int64]string, len(positions))
+	for rows.Next() {
+		var ord int64
+		var id string
+		if err := rows.Scan(&ord, &id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids[ord] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, p := range positions {
+		outlier := map[string]interface{}{req.idColumn: ids[ords[i]]}
+		if scores != nil {
+			outlier["score"] = scores[p]
+		}
+		outliers = append(outliers, outlier)
+	}
+	return outliers, nil
+}
+
+// scoreSummary describes the distribution of the scores
+func scoreSummary(scores []float64) map[string]interface{} {
+	sum := 0.0
+	minScore, maxScore := math.Inf(1), math.Inf(-1)
+	for _, s := range scores {
+		sum += s
+		minScore = math.Min(minScore, s)
+		maxScore = math.Max(maxScore, s)
+	}
+	return map[string]interface{}{
+		"min":  minScore,
+		"mean": sum / float64(len(scores)),
+		"p50":  percentileOf(scores, 50),
+		"p95":  percentileOf(scores, 95),
+		"p99":  percentileOf(scores, 99),
+		"max":  maxScore,
+	}
+}
+
+func (t *DetectOutliersTool) outlierError(req outlierRequest, stage string, err error) *ToolResult {
+	t.logger.Error("Outlier detection failed", err, map[string]interface{}{
+		"table":  req.tableName,
+		"method": req.method,
+		"stage":  stage,
+	})
+	return Error(fmt.Sprintf("Outlier detection failed during %s: table='%s', vector_column='%s', method='%s', error=%v", stage, req.tableName, req.vectorColumn, req.method, err), "OUTLIER_ERROR", map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"method":        req.method,
+		"stage":         stage,
+		"error":         err.Error(),
+	})
+}
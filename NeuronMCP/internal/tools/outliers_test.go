@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutlierRequest(t *testing.T) {
+	base := func(extra map[string]interface{}) map[string]interface{} {
+		params := map[string]interface{}{"table": "docs", "vector_column": "embedding"}
+		for k, v := range extra {
+			params[k] = v
+		}
+		return params
+	}
+
+	// The defaults of each method
+	for method, want := range map[string]struct {
+		threshold, percentile float64
+	}{
+		"zscore":            {threshold: 3},
+		"modified_zscore":   {threshold: 3.5},
+		"iqr":               {threshold: 1.5},
+		"lof":               {threshold: 1.5},
+		"centroid_distance": {percentile: 95},
+		"isolation_forest":  {percentile: 90},
+	} {
+		req, errResult := parseOutlierRequest(base(map[string]interface{}{"method": method}))
+		if errResult != nil {
+			t.Errorf("%s: unexpected error: %v", method, errResult.Error)
+			continue
+		}
+		if want.threshold != 0 && (req.threshold == nil || *req.threshold != want.threshold || req.percentile != nil) {
+			t.Errorf("%s: threshold = %v, percentile = %v; want threshold %g", method, req.threshold, req.percentile, want.threshold)
+		}
+		if want.percentile != 0 && (req.percentile == nil || *req.percentile != want.percentile || req.threshold != nil) {
+			t.Errorf("%s: threshold = %v, percentile = %v; want percentile %g", method, req.threshold, req.percentile, want.percentile)
+		}
+	}
+
+	req, errResult := parseOutlierRequest(base(map[string]interface{}{"method": "zscore", "percentile": 99.0}))
+	if errResult != nil || req.threshold != nil || *req.percentile != 99 {
+		t.Errorf("zscore with a percentile = %+v, %v", req, errResult)
+	}
+
+	for name, params := range map[string]map[string]interface{}{
+		"unknown method":               base(map[string]interface{}{"method": "ocsvm"}),
+		"threshold and percentile":     base(map[string]interface{}{"threshold": 2.0, "percentile": 90.0}),
+		"threshold for forest":         base(map[string]interface{}{"method": "isolation_forest", "threshold": 0.5}),
+		"percentile without scores":    base(map[string]interface{}{"method": "lof", "percentile": 90.0}),
+		"percentile of 100":            base(map[string]interface{}{"method": "centroid_distance", "percentile": 100.0}),
+		"non-positive threshold":       base(map[string]interface{}{"threshold": 0.0}),
+		"inner product centroid":       base(map[string]interface{}{"method": "centroid_distance", "distance_metric": "inner_product"}),
+		"tag column over vector":       base(map[string]interface{}{"tag_column": "embedding"}),
+		"missing vector column":        {"table": "docs"},
+		"schema-qualified three parts": base(map[string]interface{}{"table": "a.b.c"}),
+	} {
+		if _, errResult := parseOutlierRequest(params); errResult == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestFlaggedRows(t *testing.T) {
+	scores := []float64{0.1, 5, 0.2, 9, 3}
+	flags := flagAbove(scores, 2.5)
+	if want := []bool{false, true, false, true, true}; !reflect.DeepEqual(flags, want) {
+		t.Fatalf("flagAbove = %v, want %v", flags, want)
+	}
+	if got, want := flaggedRows(scores, flags), []int{3, 1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flaggedRows = %v, want highest score first %v", got, want)
+	}
+	if got, want := flaggedRows(nil, []bool{true, false, true}), []int{0, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flaggedRows without scores = %v, want row order %v", got, want)
+	}
+}
+
+func TestScoreSummary(t *testing.T) {
+	summary := scoreSummary([]float64{4, 1, 3, 2})
+	if summary["min"] != 1.0 || summary["max"] != 4.0 || summary["mean"] != 2.5 || summary["p50"] != 2.0 {
+		t.Errorf("scoreSummary = %v", summary)
+	}
+}
@@ -0,0 +1,581 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Profiling limits
+const (
+	defaultProfileSampleSize = 1000
+	maxProfileSampleSize     = 20000
+	profileHistogramBuckets  = 10
+	// normTolerance is how far a norm may be from 1 for the vector to count
+	// as unit length
+	normTolerance = 0.01
+	// duplicateFindingRate is the near-duplicate rate above which the
+	// profile flags duplicates
+	duplicateFindingRate = 0.05
+	// simHashBits and simHashTables size the locality-sensitive hashing used
+	// to find near-duplicate candidates without comparing every pair
+	simHashBits   = 12
+	simHashTables = 4
+	// maxBucketComparisons bounds how many earlier vectors of a hash bucket
+	// each vector is compared with, so clustered data stays linear
+	maxBucketComparisons = 64
+)
+
+// declaredDimensionRe extracts n from a column type such as vector(n)
+var declaredDimensionRe = regexp.MustCompile(`\((\d+)\)`)
+
+// ProfileVectorTableTool reports statistics that explain poor recall
+type ProfileVectorTableTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewProfileVectorTableTool creates a new vector table profiling tool
+func NewProfileVectorTableTool(db *database.Database, logger *logging.Logger) *ProfileVectorTableTool {
+	return &ProfileVectorTableTool{
+		BaseTool: NewBaseTool(
+			"profile_vector_table",
+			"Profile the embeddings of a table: row and null counts, dimension consistency, norm distribution, approximate duplicate rate from a sample, and storage size",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the vector column",
+					},
+					"sample_size": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultProfileSampleSize,
+						"minimum":     10,
+						"maximum":     maxProfileSampleSize,
+						"description": "Rows sampled for the dimension, norm and duplicate statistics",
+					},
+					"duplicate_similarity": map[string]interface{}{
+						"type":        "number",
+						"default":     0.99,
+						"minimum":     0,
+						"maximum":     1,
+						"description": "Cosine similarity at or above which two sampled vectors count as near-duplicates",
+					},
+					"exact_counts": map[string]interface{}{
+						"type":        "boolean",
+						"default":     true,
+						"description": "Count rows and null embeddings with a full scan; false estimates them from statistics and the sample",
+					},
+				},
+				"required": []interface{}{"table", "vector_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute profiles the table
+func (t *ProfileVectorTableTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for profile_vector_table tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	tableName, _ := params["table"].(string)
+	vectorColumn, _ := params["vector_column"].(string)
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	if vectorColumn == "" {
+		return Error("vector_column parameter is required and cannot be empty for profile_vector_table tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "vector_column",
+		}), nil
+	}
+	sampleSize := defaultProfileSampleSize
+	if v, ok := params["sample_size"].(float64); ok {
+		sampleSize = int(v)
+	}
+	if sampleSize < 10 || sampleSize > maxProfileSampleSize {
+		return Error(fmt.Sprintf("sample_size must be between 10 and %d, got %d", maxProfileSampleSize, sampleSize), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "sample_size",
+		}), nil
+	}
+	minSimilarity := 0.99
+	if v, ok := params["duplicate_similarity"].(float64); ok {
+		minSimilarity = v
+	}
+	if minSimilarity < 0 || minSimilarity > 1 {
+		return Error(fmt.Sprintf("duplicate_similarity must be between 0 and 1, got %g", minSimilarity), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "duplicate_similarity",
+		}), nil
+	}
+	exactCounts := true
+	if v, ok := params["exact_counts"].(bool); ok {
+		exactCounts = v
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for profile_vector_table", "DATABASE_ERROR", map[string]interface{}{
+			"table": tableName,
+		}), nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	// Storage and the planner's row estimate
+	regclass := table.Sanitize()
+	var estimatedRows, totalBytes, tableBytes, indexBytes, toastBytes int64
+	err = db.QueryRow(queryCtx, `
+		SELECT c.reltuples::bigint,
+		       pg_total_relation_size(c.oid),
+		       pg_relation_size(c.oid),
+		       pg_indexes_size(c.oid),
+		       COALESCE(pg_total_relation_size(NULLIF(c.reltoastrelid, 0)), 0)
+		FROM pg_class c
+		WHERE c.oid = to_regclass($1)`, regclass).Scan(&estimatedRows, &totalBytes, &tableBytes, &indexBytes, &toastBytes)
+	if err == pgx.ErrNoRows {
+		return Error(fmt.Sprintf("Table '%s' does not exist", tableName), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	if err != nil {
+		return t.profileError(tableName, vectorColumn, "storage", err), nil
+	}
+
+	var declaredType string
+	err = db.QueryRow(queryCtx, `
+		SELECT format_type(a.atttypid, a.atttypmod)
+		FROM pg_attribute a
+		WHERE a.attrelid = to_regclass($1) AND a.attname = $2 AND NOT a.attisdropped`, regclass, vectorColumn).Scan(&declaredType)
+	if err == pgx.ErrNoRows {
+		return Error(fmt.Sprintf("Column '%s' does not exist in table '%s'", vectorColumn, tableName), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "vector_column",
+		}), nil
+	}
+	if err != nil {
+		return t.profileError(tableName, vectorColumn, "column type", err), nil
+	}
+	declaredDimension := 0
+	if m := declaredDimensionRe.FindStringSubmatch(declaredType); m != nil {
+		declaredDimension, _ = strconv.Atoi(m[1])
+	}
+
+	column := pgx.Identifier{vectorColumn}.Sanitize()
+	rowCount, nullCount := estimatedRows, int64(-1)
+	if exactCounts {
+		err = db.QueryRow(queryCtx, fmt.Sprintf(
+			"SELECT count(*), count(*) FILTER (WHERE %s IS NULL) FROM %s", column, regclass)).Scan(&rowCount, &nullCount)
+		if err != nil {
+			return t.profileError(tableName, vectorColumn, "counts", err), nil
+		}
+	}
+
+	// Sample rows, nulls included so the null fraction can be estimated too.
+	// Large tables are sampled with BERNOULLI rather than sorting every row.
+	sampleMethod := "random"
+	sampleQuery := fmt.Sprintf("SELECT %s::text FROM %s ORDER BY random() LIMIT $1", column, regclass)
+	if estimatedRows > int64(sampleSize)*10 {
+		percent := math.Min(100, float64(sampleSize)*3/float64(estimatedRows)*100)
+		sampleMethod = "bernoulli"
+		sampleQuery = fmt.Sprintf("SELECT %s::text FROM %s TABLESAMPLE BERNOULLI (%s) LIMIT $1",
+			column, regclass, strconv.FormatFloat(percent, 'f', 6, 64))
+	}
+	rows, err := db.Query(queryCtx, sampleQuery, sampleSize)
+	if err != nil {
+		return t.profileError(tableName, vectorColumn, "sample", err), nil
+	}
+	var vecs [][]float32
+	sampledRows, sampledNulls := 0, 0
+	for rows.Next() {
+		var text *string
+		if err := rows.Scan(&text); err != nil {
+			rows.Close()
+			return t.profileError(tableName, vectorColumn, "sample", err), nil
+		}
+		sampledRows++
+		if text == nil {
+			sampledNulls++
+			continue
+		}
+		vec, err := parseVectorText(*text)
+		if err != nil {
+			rows.Close()
+			return t.profileError(tableName, vectorColumn, "sample", fmt.Errorf("column '%s' is not a vector column: %w", vectorColumn, err)), nil
+		}
+		vecs = append(vecs, vec)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return t.profileError(tableName, vectorColumn, "sample", err), nil
+	}
+
+	nullFraction := 0.0
+	if exactCounts {
+		if rowCount > 0 {
+			nullFraction = float64(nullCount) / float64(rowCount)
+		}
+	} else if sampledRows > 0 {
+		nullFraction = float64(sampledNulls) / float64(sampledRows)
+		nullCount = int64(math.Round(nullFraction * float64(rowCount)))
+	}
+
+	profile := profileVectors(vecs, minSimilarity)
+	result := map[string]interface{}{
+		"table":           tableName,
+		"vector_column":   vectorColumn,
+		"declared_type":   declaredType,
+		"row_count":       rowCount,
+		"counts_exact":    exactCounts,
+		"null_embeddings": nullCount,
+		"null_fraction":   nullFraction,
+		"storage": map[string]interface{}{
+			"total_bytes": totalBytes,
+			"table_bytes": tableBytes,
+			"index_bytes": indexBytes,
+			"toast_bytes": toastBytes,
+		},
+		"sample": map[string]interface{}{
+			"method":  sampleMethod,
+			"rows":    sampledRows,
+			"vectors": len(vecs),
+		},
+		"dimensions":           profile.Dimensions,
+		"dimension_consistent": len(profile.Dimensions) <= 1,
+		"norms":                profile.Norms,
+		"non_finite_vectors":   profile.NonFinite,
+		"duplicates":           profile.Duplicates,
+		"findings":             profile.findings(nullCount, nullFraction, declaredDimension),
+	}
+	if declaredDimension > 0 {
+		result["declared_dimension"] = declaredDimension
+	}
+
+	return Success(result, map[string]interface{}{
+		"table":         tableName,
+		"vector_column": vectorColumn,
+		"sample_size":   sampleSize,
+	}), nil
+}
+
+func (t *ProfileVectorTableTool) profileError(table, vectorColumn, stage string, err error) *ToolResult {
+	t.logger.Error("Vector table profiling failed", err, map[string]interface{}{
+		"table":         table,
+		"vector_column": vectorColumn,
+		"stage":         stage,
+	})
+	return Error(fmt.Sprintf("Profiling failed while reading %s: table='%s', vector_column='%s', error=%v", stage, table, vectorColumn, err), "PROFILE_ERROR", map[string]interface{}{
+		"table":         table,
+		"vector_column": vectorColumn,
+		"stage":         stage,
+		"error":         err.Error(),
+	})
+}
+
+// dimensionCount is how many sampled vectors have a dimension
+type dimensionCount struct {
+	Dimension int `json:"dimension"`
+	Count     int `json:"count"`
+}
+
+// histogramBucket counts the values in [Min, Max)
+type histogramBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// normStats describes the distribution of vector norms
+type normStats struct {
+	Min        float64           `json:"min"`
+	Max        float64           `json:"max"`
+	Mean       float64           `json:"mean"`
+	StdDev     float64           `json:"stddev"`
+	P05        float64           `json:"p05"`
+	P50        float64           `json:"p50"`
+	P95        float64           `json:"p95"`
+	Zero       int               `json:"zero"`
+	UnitLength float64           `json:"unit_length_fraction"`
+	Histogram  []histogramBucket `json:"histogram"`
+}
+
+// duplicateStats reports duplicates among the sampled vectors
+type duplicateStats struct {
+	MinSimilarity float64 `json:"min_similarity"`
+	Exact         int     `json:"exact"`
+	Near          int     `json:"near"`
+	Rate          float64 `json:"rate"`
+}
+
+// vectorProfile is the part of a profile computed from sampled vectors
+type vectorProfile struct {
+	Dimensions []dimensionCount
+	Norms      *normStats
+	NonFinite  int
+	Duplicates duplicateStats
+}
+
+// profileVectors computes dimension, norm and duplicate statistics.
+// Vectors with NaN or infinite elements are counted and otherwise skipped.
+func profileVectors(vecs [][]float32, minSimilarity float64) *vectorProfile {
+	p := &vectorProfile{Dimensions: []dimensionCount{}}
+
+	dims := make(map[int]int)
+	finite := make([][]float32, 0, len(vecs))
+	for _, vec := range vecs {
+		dims[len(vec)]++
+		if !isFiniteVector(vec) {
+			p.NonFinite++
+			continue
+		}
+		finite = append(finite, vec)
+	}
+	for dim, count := range dims {
+		p.Dimensions = append(p.Dimensions, dimensionCount{dim, count})
+	}
+	sort.Slice(p.Dimensions, func(i, j int) bool {
+		if p.Dimensions[i].Count != p.Dimensions[j].Count {
+			return p.Dimensions[i].Count > p.Dimensions[j].Count
+		}
+		return p.Dimensions[i].Dimension < p.Dimensions[j].Dimension
+	})
+
+	norms := make([]float64, len(finite))
+	for i, vec := range finite {
+		norms[i] = vectorNorm(vec)
+	}
+	p.Norms = summarizeNorms(norms)
+
+	p.Duplicates = countDuplicates(finite, norms, minSimilarity)
+	if len(vecs) > 0 {
+		p.Duplicates.Rate = float64(p.Duplicates.Exact+p.Duplicates.Near) / float64(len(vecs))
+	}
+	return p
+}
+
+func isFiniteVector(vec []float32) bool {
+	for _, v := range vec {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func vectorNorm(vec []float32) float64 {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum)
+}
+
+func summarizeNorms(norms []float64) *normStats {
+	if len(norms) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), norms...)
+	sort.Float64s(sorted)
+
+	s := &normStats{Min: sorted[0], Max: sorted[len(sorted)-1]}
+	unit := 0
+	for _, n := range sorted {
+		s.Mean += n
+		if n == 0 {
+			s.Zero++
+		}
+		if math.Abs(n-1) <= normTolerance {
+			unit++
+		}
+	}
+	s.Mean /= float64(len(sorted))
+	for _, n := range sorted {
+		s.StdDev += (n - s.Mean) * (n - s.Mean)
+	}
+	s.StdDev = math.Sqrt(s.StdDev / float64(len(sorted)))
+	s.UnitLength = float64(unit) / float64(len(sorted))
+	percentile := func(p float64) float64 {
+		return sorted[int(math.Round(p*float64(len(sorted)-1)))]
+	}
+	s.P05, s.P50, s.P95 = percentile(0.05), percentile(0.5), percentile(0.95)
+
+	width := (s.Max - s.Min) / profileHistogramBuckets
+	if width == 0 {
+		s.Histogram = []histogramBucket{{Min: s.Min, Max: s.Max, Count: len(sorted)}}
+		return s
+	}
+	s.Histogram = make([]histogramBucket, profileHistogramBuckets)
+	for i := range s.Histogram {
+		s.Histogram[i].Min = s.Min + float64(i)*width
+		s.Histogram[i].Max = s.Min + float64(i+1)*width
+	}
+	for _, n := range sorted {
+		i := int((n - s.Min) / width)
+		if i >= profileHistogramBuckets {
+			i = profileHistogramBuckets - 1
+		}
+		s.Histogram[i].Count++
+	}
+	return s
+}
+
+// countDuplicates counts vectors identical to another sampled vector (Exact)
+// and vectors within minSimilarity cosine similarity of a different one
+// (Near). Candidates come from random-hyperplane hashing, so Near is a lower
+// bound. Zero vectors have no direction and are not compared.
+func countDuplicates(vecs [][]float32, norms []float64, minSimilarity float64) duplicateStats {
+	stats := duplicateStats{MinSimilarity: minSimilarity}
+
+	// Exact duplicates, keeping one representative of each distinct vector
+	seen := make(map[string]int)
+	copies := make(map[int]int)
+	var reps []int
+	for i, vec := range vecs {
+		key := vectorKey(vec)
+		if first, ok := seen[key]; ok {
+			copies[first]++
+			continue
+		}
+		seen[key] = i
+		copies[i] = 1
+		if norms[i] > 0 {
+			reps = append(reps, i)
+		}
+	}
+	for _, n := range copies {
+		if n > 1 {
+			stats.Exact += n
+		}
+	}
+
+	// Near duplicates among distinct vectors of the same dimension
+	byDim := make(map[int][]int)
+	for _, i := range reps {
+		byDim[len(vecs[i])] = append(byDim[len(vecs[i])], i)
+	}
+	near := make(map[int]bool)
+	for dim, members := range byDim {
+		if len(members) < 2 || dim == 0 {
+			continue
+		}
+		rng := rand.New(rand.NewSource(int64(dim)))
+		planes := make([][]float64, simHashTables*simHashBits)
+		for i := range planes {
+			planes[i] = make([]float64, dim)
+			for j := range planes[i] {
+				planes[i][j] = rng.NormFloat64()
+			}
+		}
+		buckets := make(map[uint64][]int)
+		for _, i := range members {
+			for table := 0; table < simHashTables; table++ {
+				key := uint64(table) << simHashBits
+				for bit := 0; bit < simHashBits; bit++ {
+					if planeDot(planes[table*simHashBits+bit], vecs[i]) >= 0 {
+						key |= 1 << bit
+					}
+				}
+				bucket := buckets[key]
+				start := 0
+				if len(bucket) > maxBucketComparisons {
+					start = len(bucket) - maxBucketComparisons
+				}
+				for _, j := range bucket[start:] {
+					if near[i] && near[j] {
+						continue
+					}
+					if normalizedDot(vecs[i], vecs[j], norms[i], norms[j]) >= minSimilarity {
+						near[i], near[j] = true, true
+					}
+				}
+				buckets[key] = append(bucket, i)
+			}
+		}
+	}
+	// Copies of a vector were already counted as exact duplicates
+	for i := range near {
+		if copies[i] == 1 {
+			stats.Near++
+		}
+	}
+	return stats
+}
+
+func vectorKey(vec []float32) string {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return string(buf)
+}
+
+func planeDot(plane []float64, vec []float32) float64 {
+	var sum float64
+	for i, v := range vec {
+		sum += plane[i] * float64(v)
+	}
+	return sum
+}
+
+// normalizedDot is the cosine similarity of a and b given their norms
+func normalizedDot(a, b []float32, normA, normB float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum / (normA * normB)
+}
+
+// findings explains what in the profile is likely to hurt recall
+func (p *vectorProfile) findings(nullCount int64, nullFraction float64, declaredDimension int) []string {
+	findings := []string{}
+
+	if nullCount > 0 {
+		findings = append(findings, fmt.Sprintf("%d rows (%.1f%%) have no embedding and can never be returned by a vector search; embed them or filter them out of evaluations.", nullCount, nullFraction*100))
+	}
+	if len(p.Dimensions) > 1 {
+		findings = append(findings, fmt.Sprintf("Sampled vectors have %d different dimensions; distances between vectors of different models are meaningless and vector indexes require one dimension. Re-embed with a single model.", len(p.Dimensions)))
+	} else if len(p.Dimensions) == 1 && declaredDimension > 0 && p.Dimensions[0].Dimension != declaredDimension {
+		findings = append(findings, fmt.Sprintf("Sampled vectors have dimension %d but the column is declared with %d.", p.Dimensions[0].Dimension, declaredDimension))
+	}
+	if p.NonFinite > 0 {
+		findings = append(findings, fmt.Sprintf("%d sampled vectors contain NaN or infinite values, which make every distance to them undefined.", p.NonFinite))
+	}
+	if n := p.Norms; n != nil {
+		if n.Zero > 0 {
+			findings = append(findings, fmt.Sprintf("%d sampled vectors are all zeros; cosine distance to them is undefined, which usually means embedding failed for those rows.", n.Zero))
+		}
+		if n.UnitLength >= 0.99 {
+			findings = append(findings, "Vectors are unit length, so l2, cosine and inner_product rank results the same way.")
+		} else if n.Mean > 0 && n.StdDev/n.Mean > 0.1 {
+			findings = append(findings, fmt.Sprintf("Vector norms vary widely (p05 %.3g, p95 %.3g). cosine ignores length, but l2 and inner_product favour long vectors; normalize the embeddings or search with cosine.", n.P05, n.P95))
+		}
+	}
+	if p.Duplicates.Rate > duplicateFindingRate {
+		findings = append(findings, fmt.Sprintf("About %.0f%% of sampled vectors have a duplicate (%d exact, %d near); duplicates crowd distinct results out of the top k. Deduplicate the source data or its chunks.", p.Duplicates.Rate*100, p.Duplicates.Exact, p.Duplicates.Near))
+	}
+	return findings
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestProfileVectorsDimensionsAndNorms(t *testing.T) {
+	vecs := [][]float32{
+		{3, 4, 0},
+		{0, 0, 0},
+		{1, 0, 0},
+		{1, 2},
+		{float32(math.NaN()), 1, 1},
+	}
+	p := profileVectors(vecs, 0.99)
+
+	if len(p.Dimensions) != 2 || p.Dimensions[0] != (dimensionCount{3, 4}) || p.Dimensions[1] != (dimensionCount{2, 1}) {
+		t.Fatalf("dimensions = %+v", p.Dimensions)
+	}
+	if p.NonFinite != 1 {
+		t.Errorf("non-finite = %d, want 1", p.NonFinite)
+	}
+	n := p.Norms
+	if n.Zero != 1 || n.Min != 0 || n.Max != 5 {
+		t.Errorf("norms = %+v", n)
+	}
+	total := 0
+	for _, b := range n.Histogram {
+		total += b.Count
+	}
+	if total != 4 {
+		t.Errorf("histogram counts %d norms, want 4", total)
+	}
+
+	findings := strings.Join(p.findings(3, 0.1, 0), "\n")
+	for _, want := range []string{"have no embedding", "different dimensions", "NaN", "all zeros"} {
+		if !strings.Contains(findings, want) {
+			t.Errorf("findings missing %q:\n%s", want, findings)
+		}
+	}
+}
+
+func TestProfileVectorsDuplicates(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	random := func() []float32 {
+		vec := make([]float32, 32)
+		for i := range vec {
+			vec[i] = float32(rng.NormFloat64())
+		}
+		return vec
+	}
+
+	var vecs [][]float32
+	for i := 0; i < 50; i++ {
+		vecs = append(vecs, random())
+	}
+	// Two identical copies and one slightly perturbed vector
+	base := random()
+	vecs = append(vecs, base, append([]float32(nil), base...))
+	nearby := append([]float32(nil), vecs[0]...)
+	nearby[0] += 0.01
+	vecs = append(vecs, nearby)
+
+	d := profileVectors(vecs, 0.99).Duplicates
+	if d.Exact != 2 {
+		t.Errorf("exact = %d, want 2", d.Exact)
+	}
+	if d.Near != 2 {
+		t.Errorf("near = %d, want 2", d.Near)
+	}
+	if want := 4.0 / float64(len(vecs)); math.Abs(d.Rate-want) > 1e-9 {
+		t.Errorf("rate = %g, want %g", d.Rate, want)
+	}
+}
+
+func TestProfileUnitLengthFinding(t *testing.T) {
+	vecs := [][]float32{{1, 0}, {0, 1}, {0.6, 0.8}}
+	p := profileVectors(vecs, 0.99)
+	if p.Norms.UnitLength != 1 {
+		t.Fatalf("unit length fraction = %g, want 1", p.Norms.UnitLength)
+	}
+	findings := p.findings(0, 0, 2)
+	if len(findings) != 1 || !strings.Contains(findings[0], "unit length") {
+		t.Errorf("findings = %v", findings)
+	}
+}
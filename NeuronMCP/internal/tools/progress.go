@@ -0,0 +1,29 @@
+package tools
+
+import "context"
+
+// ProgressFunc reports how far a long tool call has got: progress out of
+// total units (total is 0 when unknown), with a short message
+type ProgressFunc func(progress, total float64, message string)
+
+type progressKey struct{}
+
+// WithProgress returns a context through which the tool call reports its
+// progress to report
+func WithProgress(ctx context.Context, report ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// reportProgress reports progress when the caller asked for it
+func reportProgress(ctx context.Context, progress, total float64, message string) {
+	if report, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && report != nil {
+		report(progress, total, message)
+	}
+}
+
+// progressRequested reports whether the caller asked for progress, so a
+// tool can skip work done only to report it
+func progressRequested(ctx context.Context) bool {
+	report, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	return ok && report != nil
+}
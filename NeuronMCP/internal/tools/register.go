@@ -12,10 +12,19 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 	registry.Register(NewVectorSearchL2Tool(db, logger))
 	registry.Register(NewVectorSearchCosineTool(db, logger))
 	registry.Register(NewVectorSearchInnerProductTool(db, logger))
+	registry.Register(NewExplainVectorSearchTool(db, logger))
+	registry.Register(NewProfileVectorTableTool(db, logger))
+	registry.Register(NewAnalyzeVectorTablesTool(db, logger))
+	registry.Register(NewBenchmarkSearchTool(db, logger))
+	registry.Register(NewVectorSimilarityJoinTool(db, logger))
+	registry.Register(NewDedupeTableTool(db, logger))
+	registry.Register(NewGenerateTestDataTool(db, logger))
 
 	// Embedding tools
 	registry.Register(NewGenerateEmbeddingTool(db, logger))
 	registry.Register(NewBatchEmbeddingTool(db, logger))
+	registry.Register(NewWarmupModelsTool(db, logger))
+	registry.Register(NewModelHealthTool(db, logger))
 
 	// Additional vector tools
 	registry.Register(NewVectorSimilarityTool(db, logger))
@@ -28,10 +37,13 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 	registry.Register(NewListModelsTool(db, logger))
 	registry.Register(NewGetModelInfoTool(db, logger))
 	registry.Register(NewDeleteModelTool(db, logger))
+	registry.Register(NewPredictKNNTool(db, logger))
 
 	// Analytics tools
 	registry.Register(NewClusterDataTool(db, logger))
+	registry.Register(NewClusterVectorsTool(db, logger))
 	registry.Register(NewDetectOutliersTool(db, logger))
+	registry.Register(NewVisualizeEmbeddingsTool(db, logger))
 	registry.Register(NewReduceDimensionalityTool(db, logger))
 
 	// RAG tools
@@ -39,12 +51,22 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 	registry.Register(NewRetrieveContextTool(db, logger))
 	registry.Register(NewGenerateResponseTool(db, logger))
 	registry.Register(NewChunkDocumentTool(db, logger))
+	registry.Register(NewChunkTextTool(db, logger))
+	registry.Register(NewIngestDocumentTool(db, logger))
+	registry.Register(NewCopyFromTool(db, logger))
+	registry.Register(NewUpsertEmbeddingsTool(db, logger))
+	registry.Register(NewManageEmbeddingColumnTool(db, logger))
 
 	// Indexing tools
 	registry.Register(NewCreateHNSWIndexTool(db, logger))
 	registry.Register(NewCreateIVFIndexTool(db, logger))
 	registry.Register(NewIndexStatusTool(db, logger))
 	registry.Register(NewDropIndexTool(db, logger))
+	registry.Register(NewVectorIndexDriftTool(db, logger))
+	registry.Register(NewRecordVectorIndexBaselineTool(db, logger))
+	registry.Register(NewRebuildVectorIndexTool(db, logger))
+	registry.Register(NewVacuumVectorTableTool(db, logger))
+	registry.Register(NewVectorIndexMaintenanceStatusTool(db, logger))
 	registry.Register(NewTuneHNSWIndexTool(db, logger))
 	registry.Register(NewTuneIVFIndexTool(db, logger))
 
@@ -52,6 +74,9 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 	registry.Register(NewPredictBatchTool(db, logger))
 	registry.Register(NewExportModelTool(db, logger))
 
+	// Export tools
+	registry.Register(NewExportVectorsTool(db, logger))
+
 	// Analytics tools
 	registry.Register(NewAnalyzeDataTool(db, logger))
 
@@ -60,9 +85,11 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 	registry.Register(NewReciprocalRankFusionTool(db, logger))
 	registry.Register(NewSemanticKeywordSearchTool(db, logger))
 	registry.Register(NewMultiVectorSearchTool(db, logger))
+	registry.Register(NewMultiColumnVectorSearchTool(db, logger))
 	registry.Register(NewFacetedVectorSearchTool(db, logger))
 	registry.Register(NewTemporalVectorSearchTool(db, logger))
 	registry.Register(NewDiverseVectorSearchTool(db, logger))
+	registry.Register(NewFederatedVectorSearchTool(db, logger))
 
 	// Reranking tools
 	registry.Register(NewRerankCrossEncoderTool(db, logger))
@@ -71,6 +98,8 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 	registry.Register(NewRerankColBERTTool(db, logger))
 	registry.Register(NewRerankLTRTool(db, logger))
 	registry.Register(NewRerankEnsembleTool(db, logger))
+	registry.Register(NewRecordRerankFeedbackTool(db, logger))
+	registry.Register(NewExportRerankTrainingDataTool(db, logger))
 
 	// Advanced vector operations
 	registry.Register(NewVectorArithmeticTool(db, logger))
@@ -97,21 +126,34 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 
 	// Time series, AutoML, ONNX
 	registry.Register(NewTimeSeriesTool(db, logger))
+	registry.Register(NewTrainForecastModelTool(db, logger))
+	registry.Register(NewForecastTool(db, logger))
+	registry.Register(NewEvaluateForecastTool(db, logger))
 	registry.Register(NewAutoMLTool(db, logger))
 	registry.Register(NewONNXTool(db, logger))
 
 	// Vector graph operations
 	registry.Register(NewVectorGraphTool(db, logger))
 
+	// Graph RAG
+	registry.Register(NewExtractEntitiesTool(db, logger))
+	registry.Register(NewGraphNeighborhoodSearchTool(db, logger))
+
 	// Vecmap operations
 	registry.Register(NewVecmapOperationsTool(db, logger))
 
+	// Sparse vectors
+	registry.Register(NewGenerateSparseEmbeddingTool(db, logger))
+	registry.Register(NewSparseEmbedColumnTool(db, logger))
+	registry.Register(NewSparseSearchTool(db, logger))
+
 	// Dataset loading
 	registry.Register(NewDatasetLoadingTool(db, logger))
 
 	// Workers and GPU
 	registry.Register(NewWorkerManagementTool(db, logger))
 	registry.Register(NewGPUMonitoringTool(db, logger))
+	registry.Register(NewConfigureGPUTool(db, logger))
 
 	// PostgreSQL tools
 	registry.Register(NewPostgreSQLVersionTool(db, logger))
@@ -122,5 +164,33 @@ func RegisterAllTools(registry *ToolRegistry, db *database.Database, logger *log
 	registry.Register(NewPostgreSQLReplicationTool(db, logger))
 	registry.Register(NewPostgreSQLSettingsTool(db, logger))
 	registry.Register(NewPostgreSQLExtensionsTool(db, logger))
+	registry.Register(NewDatabaseHealthTool(db, logger))
+
+	// Health and diagnostics
+	registry.Register(NewHealthCheckTool(db, logger))
+	registry.Register(NewReadinessCheckTool(db, logger))
+	registry.Register(NewDiagnoseTool(db, logger))
+
+	// Text-to-SQL
+	registry.Register(NewGenerateSQLTool(db, logger))
+	registry.Register(NewRunSQLReadOnlyTool(db, logger))
+
+	// Saved queries
+	registry.Register(NewCreateSavedQueryTool(db, logger))
+	registry.Register(NewListSavedQueriesTool(db, logger))
+	registry.Register(NewExecuteSavedQueryTool(db, logger))
+	registry.Register(NewDeleteSavedQueryTool(db, logger))
+
+	// Notification channels
+	registry.Register(NewSubscribeChannelTool(db, logger))
+
+	// Session tables
+	registry.Register(NewCreateSessionTableTool(db, logger))
+	registry.Register(NewListSessionTablesTool(db, logger))
+	registry.Register(NewQuerySessionTableTool(db, logger))
+	registry.Register(NewDropSessionTableTool(db, logger))
+
+	// Schema migrations
+	registry.Register(NewManageSchemaTool(db, logger))
 }
 
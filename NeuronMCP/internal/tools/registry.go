@@ -13,12 +13,15 @@ type ToolDefinition struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	InputSchema map[string]interface{} `json:"inputSchema"`
+	Version     int                    `json:"version"`
+	Versions    []int                  `json:"versions"` // versions callers may request, newest first
 }
 
 // ToolRegistry manages tool registration and execution
 type ToolRegistry struct {
 	tools      map[string]Tool
 	definitions map[string]ToolDefinition
+	adapters   map[string]CompatibilityAdapter
 	mu         sync.RWMutex
 	db         *database.Database
 	logger     *logging.Logger
@@ -29,6 +32,7 @@ func NewToolRegistry(db *database.Database, logger *logging.Logger) *ToolRegistr
 	return &ToolRegistry{
 		tools:       make(map[string]Tool),
 		definitions: make(map[string]ToolDefinition),
+		adapters:    make(map[string]CompatibilityAdapter),
 		db:          db,
 		logger:      logger,
 	}
@@ -39,12 +43,17 @@ func (r *ToolRegistry) Register(tool Tool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	version := ToolVersion(tool)
 	definition := ToolDefinition{
 		Name:        tool.Name(),
 		Description: tool.Description(),
 		InputSchema: tool.InputSchema(),
+		Version:     version,
+		Versions:    []int{version},
 	}
 
+	// A re-registered tool keeps no adapter written for its old version
+	delete(r.adapters, tool.Name())
 	r.tools[tool.Name()] = tool
 	r.definitions[tool.Name()] = definition
 	r.logger.Debug(fmt.Sprintf("Registered tool: %s", tool.Name()), nil)
@@ -113,6 +122,7 @@ func (r *ToolRegistry) Unregister(name string) bool {
 	if _, exists := r.tools[name]; exists {
 		delete(r.tools, name)
 		delete(r.definitions, name)
+		delete(r.adapters, name)
 		removed = true
 		r.logger.Debug(fmt.Sprintf("Unregistered tool: %s", name), nil)
 	}
@@ -125,6 +135,7 @@ func (r *ToolRegistry) Clear() {
 	defer r.mu.Unlock()
 	r.tools = make(map[string]Tool)
 	r.definitions = make(map[string]ToolDefinition)
+	r.adapters = make(map[string]CompatibilityAdapter)
 }
 
 // GetCount returns the number of registered tools
@@ -0,0 +1,617 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// rerankFeedbackTable stores relevance judgments for reranker training. It
+// is created by the first record_rerank_feedback call.
+const rerankFeedbackTable = "neurondb_mcp.rerank_feedback"
+
+const (
+	// maxRerankJudgments bounds the judgments of one record call
+	maxRerankJudgments = 1000
+	// defaultRerankDataset is the dataset judgments go to when none is named
+	defaultRerankDataset = "default"
+)
+
+// Sources of a relevance judgment
+const (
+	feedbackSourceLabel = "label"
+	feedbackSourceClick = "click"
+)
+
+// rerankJudgment is a relevance judgment of a document for a query. Label
+// is in [0, 1].
+type rerankJudgment struct {
+	Document   string  `json:"document"`
+	DocumentID *string `json:"document_id"`
+	Label      float64 `json:"label"`
+	Source     string  `json:"source"`
+	Position   *int    `json:"position"`
+}
+
+// rerankFeedbackItem is one entry of the judgments parameter, before it is
+// turned into a judgment
+type rerankFeedbackItem struct {
+	Document   string   `json:"document"`
+	DocumentID *string  `json:"document_id"`
+	Label      *float64 `json:"label"`
+	Clicked    *bool    `json:"clicked"`
+	Position   *int     `json:"position"`
+}
+
+// rerankJudgments turns labels and click signals into judgments. A label is
+// divided by maxLabel. A clicked document is relevant and an unclicked one
+// ranked above the last click was skipped, so it is not; unclicked documents
+// below the last click were likely never looked at and are left out. A
+// document without a position is ranked by its place in items.
+func rerankJudgments(items []rerankFeedbackItem, maxLabel float64) ([]rerankJudgment, int, error) {
+	lastClick := 0
+	for i, item := range items {
+		if item.Label != nil && item.Clicked != nil {
+			return nil, 0, fmt.Errorf("judgments[%d] has both label and clicked", i)
+		}
+		if item.Label == nil && item.Clicked == nil {
+			return nil, 0, fmt.Errorf("judgments[%d] needs a label or clicked", i)
+		}
+		if item.Document == "" {
+			return nil, 0, fmt.Errorf("judgments[%d] has no document", i)
+		}
+		if item.Label != nil && (*item.Label < 0 || *item.Label > maxLabel) {
+			return nil, 0, fmt.Errorf("judgments[%d] label %v is outside [0, %v]", i, *item.Label, maxLabel)
+		}
+		if item.Position != nil && *item.Position < 1 {
+			return nil, 0, fmt.Errorf("judgments[%d] position must be at least 1, got %d", i, *item.Position)
+		}
+		if item.Clicked != nil && *item.Clicked {
+			lastClick = max(lastClick, feedbackPosition(item, i))
+		}
+	}
+
+	judgments := make([]rerankJudgment, 0, len(items))
+	ignored := 0
+	for i, item := range items {
+		j := rerankJudgment{Document: item.Document, DocumentID: item.DocumentID, Position: item.Position}
+		switch {
+		case item.Label != nil:
+			j.Source = feedbackSourceLabel
+			j.Label = *item.Label / maxLabel
+		case *item.Clicked:
+			j.Source = feedbackSourceClick
+			j.Label = 1
+		case feedbackPosition(item, i) < lastClick:
+			j.Source = feedbackSourceClick
+			j.Label = 0
+		default:
+			ignored++
+			continue
+		}
+		judgments = append(judgments, j)
+	}
+	return judgments, ignored, nil
+}
+
+// feedbackPosition returns the rank of the i-th item, counting from 1
+func feedbackPosition(item rerankFeedbackItem, i int) int {
+	if item.Position != nil {
+		return *item.Position
+	}
+	return i + 1
+}
+
+// ensureRerankFeedbackTable creates the feedback table
+func ensureRerankFeedbackTable(ctx context.Context, db *database.Database) error {
+	_, err := db.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS neurondb_mcp;
+		CREATE TABLE IF NOT EXISTS `+rerankFeedbackTable+` (
+			id bigserial PRIMARY KEY,
+			dataset text NOT NULL,
+			query text NOT NULL,
+			document text NOT NULL,
+			document_id text,
+			label real NOT NULL CHECK (label BETWEEN 0 AND 1),
+			source text NOT NULL,
+			position integer,
+			created_at timestamptz NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS rerank_feedback_dataset_idx ON `+rerankFeedbackTable+` (dataset, query, document)`)
+	return err
+}
+
+// rerankFeedbackError reports a failed feedback operation
+func rerankFeedbackError(operation, dataset string, err error) *ToolResult {
+	return Error(fmt.Sprintf("Rerank feedback %s failed: dataset='%s', error=%v", operation, dataset, err), "DATABASE_ERROR", map[string]interface{}{
+		"dataset": dataset,
+		"error":   err.Error(),
+	})
+}
+
+// rerankDatasetParam returns the dataset parameter, checked like a saved
+// query name
+func rerankDatasetParam(params map[string]interface{}) (string, *ToolResult) {
+	dataset := stringParam(params, "dataset", defaultRerankDataset)
+	if !savedQueryNameRe.MatchString(dataset) {
+		return "", Error(fmt.Sprintf("Invalid dataset '%s': use lowercase letters, digits and underscores, starting with a letter", dataset), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "dataset",
+		})
+	}
+	return dataset, nil
+}
+
+// RecordRerankFeedbackTool records relevance judgments for reranker training
+type RecordRerankFeedbackTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewRecordRerankFeedbackTool creates a new record rerank feedback tool
+func NewRecordRerankFeedbackTool(db *database.Database, logger *logging.Logger) *RecordRerankFeedbackTool {
+	return &RecordRerankFeedbackTool{
+		BaseTool: NewBaseTool(
+			"record_rerank_feedback",
+			"Record (query, document, relevance label) judgments from user feedback or click signals, to train a cross-encoder or learning-to-rank reranker with export_rerank_training_data",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"default":     defaultRerankDataset,
+						"description": "Training dataset the judgments belong to: lowercase letters, digits and underscores",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Query the documents were retrieved for",
+					},
+					"judgments": map[string]interface{}{
+						"type":     "array",
+						"minItems": 1,
+						"maxItems": maxRerankJudgments,
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"document": map[string]interface{}{
+									"type":        "string",
+									"description": "Document text as the reranker saw it",
+								},
+								"document_id": map[string]interface{}{
+									"type":        "string",
+									"description": "ID of the document in its table",
+								},
+								"label": map[string]interface{}{
+									"type":        "number",
+									"minimum":     0,
+									"description": "Relevance given by the user, from 0 to max_label",
+								},
+								"clicked": map[string]interface{}{
+									"type":        "boolean",
+									"description": "Whether the user clicked the document; use instead of label",
+								},
+								"position": map[string]interface{}{
+									"type":        "integer",
+									"minimum":     1,
+									"description": "Rank the document was shown at, from 1; defaults to its place in judgments",
+								},
+							},
+							"required": []interface{}{"document"},
+						},
+						"description": "Documents shown for the query, each with a label or a click signal",
+					},
+					"max_label": map[string]interface{}{
+						"type":        "number",
+						"default":     1,
+						"minimum":     1,
+						"description": "Top of the label scale, such as 3 for graded 0-3 labels; labels are stored divided by it",
+					},
+				},
+				"required": []interface{}{"query", "judgments"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute records the judgments
+func (t *RecordRerankFeedbackTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for record_rerank_feedback tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	dataset, errResult := rerankDatasetParam(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+	query := stringParam(params, "query", "")
+	if query == "" {
+		return Error("query must not be empty", "VALIDATION_ERROR", map[string]interface{}{"parameter": "query"}), nil
+	}
+	maxLabel := 1.0
+	if v, ok := params["max_label"].(float64); ok {
+		maxLabel = v
+	}
+
+	// The judgments were checked against the input schema, so they decode
+	// into items
+	var items []rerankFeedbackItem
+	encoded, err := json.Marshal(params["judgments"])
+	if err == nil {
+		err = json.Unmarshal(encoded, &items)
+	}
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid judgments: %v", err), "VALIDATION_ERROR", map[string]interface{}{"parameter": "judgments"}), nil
+	}
+	judgments, ignored, err := rerankJudgments(items, maxLabel)
+	if err != nil {
+		return Error(err.Error(), "VALIDATION_ERROR", map[string]interface{}{"parameter": "judgments"}), nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+	if len(judgments) > 0 {
+		if err := ensureRerankFeedbackTable(ctx, db); err != nil {
+			return rerankFeedbackError("record", dataset, err), nil
+		}
+		documents := make([]string, len(judgments))
+		documentIDs := make([]*string, len(judgments))
+		labels := make([]float64, len(judgments))
+		sources := make([]string, len(judgments))
+		positions := make([]*int, len(judgments))
+		for i, j := range judgments {
+			documents[i], documentIDs[i], labels[i], sources[i], positions[i] = j.Document, j.DocumentID, j.Label, j.Source, j.Position
+		}
+		_, err := db.Exec(ctx, `INSERT INTO `+rerankFeedbackTable+` (dataset, query, document, document_id, label, source, position)
+			SELECT $1, $2, d, i, l, s, p FROM unnest($3::text[], $4::text[], $5::float8[], $6::text[], $7::int[]) AS j(d, i, l, s, p)`,
+			dataset, query, documents, documentIDs, labels, sources, positions)
+		if err != nil {
+			return rerankFeedbackError("record", dataset, err), nil
+		}
+	}
+
+	t.logger.Info("Rerank feedback recorded", map[string]interface{}{
+		"dataset":  dataset,
+		"recorded": len(judgments),
+		"ignored":  ignored,
+	})
+	return Success(map[string]interface{}{
+		"dataset":   dataset,
+		"recorded":  len(judgments),
+		"ignored":   ignored,
+		"judgments": judgments,
+	}, map[string]interface{}{"max_label": maxLabel}), nil
+}
+
+// rerankTrainingExample is a line of the cross-encoder training file
+type rerankTrainingExample struct {
+	Query      string  `json:"query"`
+	Document   string  `json:"document"`
+	Label      float64 `json:"label"`
+	DocumentID *string `json:"document_id,omitempty"`
+	Judgments  int     `json:"judgments"`
+}
+
+// trainingLabel returns the label exported for the mean relevance score of a
+// pair: the score itself, or 1 or 0 around threshold when binary
+func trainingLabel(score float64, binary bool, threshold float64) float64 {
+	if !binary {
+		return score
+	}
+	if score >= threshold {
+		return 1
+	}
+	return 0
+}
+
+// rerankTrainingQuery aggregates the judgments of each (query, document)
+// pair. Labels given by users outweigh click signals: a pair with any label
+// scores the mean of its labels, and otherwise the mean of its clicks.
+const rerankTrainingQuery = `SELECT query, document, max(document_id) AS document_id,
+		coalesce(avg(label) FILTER (WHERE source = 'label'), avg(label))::float8 AS score,
+		count(*)::int AS judgments
+	FROM ` + rerankFeedbackTable + `
+	WHERE dataset = $1 AND ($2::timestamptz IS NULL OR created_at >= $2)
+	GROUP BY query, document
+	HAVING count(*) >= $3
+	ORDER BY query, document`
+
+// ExportRerankTrainingDataTool exports recorded judgments as cross-encoder
+// training data
+type ExportRerankTrainingDataTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewExportRerankTrainingDataTool creates a new export rerank training data
+// tool
+func NewExportRerankTrainingDataTool(db *database.Database, logger *logging.Logger) *ExportRerankTrainingDataTool {
+	return &ExportRerankTrainingDataTool{
+		BaseTool: NewBaseTool(
+			"export_rerank_training_data",
+			"Export the judgments recorded by record_rerank_feedback as cross-encoder training data: JSONL lines of query, document and label, one per (query, document) pair",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"default":     defaultRerankDataset,
+						"description": "Training dataset to export",
+					},
+					"since": map[string]interface{}{
+						"type":        "string",
+						"description": "Only use judgments recorded at or after this RFC 3339 time",
+					},
+					"min_judgments": map[string]interface{}{
+						"type":        "number",
+						"default":     1,
+						"minimum":     1,
+						"maximum":     1000,
+						"description": "Leave out pairs judged fewer times",
+					},
+					"binary": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Export labels as 1 or 0 around threshold instead of the mean relevance in [0, 1]",
+					},
+					"threshold": map[string]interface{}{
+						"type":        "number",
+						"default":     0.5,
+						"minimum":     0,
+						"maximum":     1,
+						"description": "Mean relevance at or above which a pair is labeled 1 (binary)",
+					},
+					"destination": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"inline", "file"},
+						"default":     "inline",
+						"description": "inline returns one page of examples as base64; file writes every example to path in the server's export directory",
+					},
+					"offset": map[string]interface{}{
+						"type":        "number",
+						"default":     0,
+						"minimum":     0,
+						"description": "Examples to skip (inline); pass next_offset from the previous page",
+					},
+					"page_size": map[string]interface{}{
+						"type":        "number",
+						"default":     1000,
+						"minimum":     1,
+						"maximum":     10000,
+						"description": "Examples per page (inline)",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File name relative to the export directory (file); .jsonl is added if there is no extension",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace an existing file (file)",
+					},
+				},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// rerankExportRequest holds the parsed export_rerank_training_data
+// parameters
+type rerankExportRequest struct {
+	dataset      string
+	since        *time.Time
+	minJudgments int
+	binary       bool
+	threshold    float64
+}
+
+// Execute exports the training data
+func (t *ExportRerankTrainingDataTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for export_rerank_training_data tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	req := rerankExportRequest{threshold: 0.5}
+	var errResult *ToolResult
+	if req.dataset, errResult = rerankDatasetParam(params); errResult != nil {
+		return errResult, nil
+	}
+	if since := stringParam(params, "since", ""); since != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return Error(fmt.Sprintf("since must be an RFC 3339 timestamp, got %q", since), "VALIDATION_ERROR", map[string]interface{}{"parameter": "since"}), nil
+		}
+		req.since = &parsed
+	}
+	if req.minJudgments, errResult = intParamInRange(params, "min_judgments", 1, 1, 1000); errResult != nil {
+		return errResult, nil
+	}
+	req.binary, _ = params["binary"].(bool)
+	if v, ok := params["threshold"].(float64); ok {
+		req.threshold = v
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", rerankFeedbackTable).Scan(&exists); err != nil {
+		return rerankFeedbackError("export", req.dataset, err), nil
+	}
+	if !exists {
+		return Error("No rerank feedback has been recorded: call record_rerank_feedback first", "NOT_FOUND", map[string]interface{}{
+			"dataset": req.dataset,
+		}), nil
+	}
+
+	if stringParam(params, "destination", "inline") == "file" {
+		return t.exportFile(ctx, db, req, params)
+	}
+	return t.exportPage(ctx, db, req, params)
+}
+
+// exportPage returns one page of examples encoded as base64
+func (t *ExportRerankTrainingDataTool) exportPage(ctx context.Context, db *database.Database, req rerankExportRequest, params map[string]interface{}) (*ToolResult, error) {
+	offset, pageSize := 0, 1000
+	if v, ok := params["offset"].(float64); ok {
+		offset = int(v)
+	}
+	if v, ok := params["page_size"].(float64); ok {
+		pageSize = int(v)
+	}
+	if offset < 0 || pageSize < 1 || pageSize > 10000 {
+		return Error(fmt.Sprintf("offset must be >= 0 and page_size between 1 and 10000, got offset=%d, page_size=%d", offset, pageSize), "VALIDATION_ERROR", nil), nil
+	}
+
+	// One extra example tells whether another page follows
+	var buf bytes.Buffer
+	written, positives := 0, 0
+	hasMore := false
+	err := t.streamExamples(ctx, db, req, rerankTrainingQuery+" LIMIT $4 OFFSET $5", []interface{}{pageSize + 1, offset}, func(ex rerankTrainingExample) error {
+		if written == pageSize {
+			hasMore = true
+			return nil
+		}
+		written++
+		if ex.Label >= req.threshold {
+			positives++
+		}
+		return writeTrainingExample(&buf, ex)
+	})
+	if err != nil {
+		return rerankFeedbackError("export", req.dataset, err), nil
+	}
+
+	result := map[string]interface{}{
+		"format":      "jsonl",
+		"encoding":    "base64",
+		"data":        base64.StdEncoding.EncodeToString(buf.Bytes()),
+		"examples":    written,
+		"positives":   positives,
+		"offset":      offset,
+		"next_offset": nil,
+	}
+	if hasMore {
+		result["next_offset"] = offset + written
+	}
+	return Success(result, map[string]interface{}{
+		"dataset": req.dataset,
+		"binary":  req.binary,
+		"bytes":   buf.Len(),
+	}), nil
+}
+
+// exportFile writes every example to a file in the export directory. A
+// failed export removes the partial file.
+func (t *ExportRerankTrainingDataTool) exportFile(ctx context.Context, db *database.Database, req rerankExportRequest, params map[string]interface{}) (*ToolResult, error) {
+	dir, ok := ExportDirFromContext(ctx)
+	if !ok {
+		return Error("File export is disabled: set server.exportDir (or NEURONDB_MCP_EXPORT_DIR) to allow it, or use destination 'inline'", "EXPORT_DISABLED", nil), nil
+	}
+	path, err := resolveExportPath(dir, stringParam(params, "path", ""), "jsonl")
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid export path: %v", err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "path",
+		}), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return rerankFeedbackError("export", req.dataset, fmt.Errorf("failed to create directory for %s: %w", path, err)), nil
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite, _ := params["overwrite"].(bool); overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return Error(fmt.Sprintf("Export file %s already exists: set overwrite to replace it", path), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "path",
+			}), nil
+		}
+		return rerankFeedbackError("export", req.dataset, fmt.Errorf("failed to create %s: %w", path, err)), nil
+	}
+
+	w := bufio.NewWriter(file)
+	written, positives := 0, 0
+	err = t.streamExamples(ctx, db, req, rerankTrainingQuery, nil, func(ex rerankTrainingExample) error {
+		written++
+		if ex.Label >= req.threshold {
+			positives++
+		}
+		return writeTrainingExample(w, ex)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return rerankFeedbackError("export", req.dataset, err), nil
+	}
+
+	t.logger.Info("Exported rerank training data", map[string]interface{}{
+		"dataset":  req.dataset,
+		"examples": written,
+		"path":     path,
+	})
+	return Success(map[string]interface{}{
+		"format":    "jsonl",
+		"path":      path,
+		"examples":  written,
+		"positives": positives,
+	}, map[string]interface{}{
+		"dataset": req.dataset,
+		"binary":  req.binary,
+	}), nil
+}
+
+// streamExamples runs query, the training query with extra arguments, and
+// calls fn with each example
+func (t *ExportRerankTrainingDataTool) streamExamples(ctx context.Context, db *database.Database, req rerankExportRequest, query string, extra []interface{}, fn func(rerankTrainingExample) error) error {
+	args := append([]interface{}{req.dataset, req.since, req.minJudgments}, extra...)
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ex rerankTrainingExample
+		var score float64
+		if err := rows.Scan(&ex.Query, &ex.Document, &ex.DocumentID, &score, &ex.Judgments); err != nil {
+			return err
+		}
+		ex.Label = trainingLabel(score, req.binary, req.threshold)
+		if err := fn(ex); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// writeTrainingExample writes an example as a JSON line
+func writeTrainingExample(w io.Writer, ex rerankTrainingExample) error {
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
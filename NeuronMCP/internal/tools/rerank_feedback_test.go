@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRerankJudgmentsFromClicks(t *testing.T) {
+	yes, no := true, false
+	third := 3
+	items := []rerankFeedbackItem{
+		{Document: "a", Clicked: &no},
+		{Document: "b", Clicked: &yes},
+		{Document: "c", Clicked: &no, Position: &third},
+		{Document: "d", Clicked: &yes, Position: &third},
+		{Document: "e", Clicked: &no},
+	}
+	judgments, ignored, err := rerankJudgments(items, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c shares the last click's position, so it was not skipped over
+	want := map[string]float64{"a": 0, "b": 1, "d": 1}
+	if len(judgments) != len(want) || ignored != 2 {
+		t.Fatalf("judgments = %+v, ignored = %d; want %v and 2 ignored", judgments, ignored, want)
+	}
+	for _, j := range judgments {
+		if label, ok := want[j.Document]; !ok || j.Label != label || j.Source != feedbackSourceClick {
+			t.Errorf("judgment %+v, want label %v from a click", j, label)
+		}
+	}
+}
+
+func TestRerankJudgmentsFromLabels(t *testing.T) {
+	two, five := 2.0, 5.0
+	judgments, _, err := rerankJudgments([]rerankFeedbackItem{{Document: "a", Label: &two}}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if judgments[0].Label != 0.5 || judgments[0].Source != feedbackSourceLabel {
+		t.Errorf("judgment = %+v, want label 0.5", judgments[0])
+	}
+
+	yes := true
+	tests := []struct {
+		name  string
+		items []rerankFeedbackItem
+		want  string
+	}{
+		{"label over max_label", []rerankFeedbackItem{{Document: "a", Label: &five}}, "outside"},
+		{"both signals", []rerankFeedbackItem{{Document: "a", Label: &two, Clicked: &yes}}, "both"},
+		{"no signal", []rerankFeedbackItem{{Document: "a"}}, "needs a label"},
+		{"no document", []rerankFeedbackItem{{Clicked: &yes}}, "no document"},
+	}
+	for _, tt := range tests {
+		if _, _, err := rerankJudgments(tt.items, 4); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestTrainingLabel(t *testing.T) {
+	if got := trainingLabel(0.4, false, 0.5); got != 0.4 {
+		t.Errorf("score label = %v, want 0.4", got)
+	}
+	if got := trainingLabel(0.5, true, 0.5); got != 1 {
+		t.Errorf("binary label at the threshold = %v, want 1", got)
+	}
+	if got := trainingLabel(0.49, true, 0.5); got != 0 {
+		t.Errorf("binary label below the threshold = %v, want 0", got)
+	}
+}
@@ -2,7 +2,9 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/neurondb/NeuronMCP/internal/database"
@@ -120,7 +122,7 @@ func NewRerankLLMTool(db *database.Database, logger *logging.Logger) *RerankLLMT
 	return &RerankLLMTool{
 		BaseTool: NewBaseTool(
 			"rerank_llm",
-			"Rerank documents using LLM",
+			"Rerank documents using an LLM, either the database's configured LLM or the connected client's model via MCP sampling",
 			map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -145,6 +147,12 @@ func NewRerankLLMTool(db *database.Database, logger *logging.Logger) *RerankLLMT
 						"maximum":     1000,
 						"description": "Number of top results to return",
 					},
+					"provider": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"auto", "database", "client"},
+						"default":     "auto",
+						"description": "database: rerank_llm() in NeuronDB; client: the MCP client's model via sampling; auto: database, falling back to the client when the database call fails",
+					},
 				},
 				"required": []interface{}{"query", "documents"},
 			},
@@ -175,16 +183,33 @@ func (t *RerankLLMTool) Execute(ctx context.Context, params map[string]interface
 		topK = int(k)
 	}
 
+	provider := "auto"
+	if p, ok := params["provider"].(string); ok && p != "" {
+		provider = p
+	}
+
 	if query == "" || len(documents) == 0 {
 		return Error("query and documents are required", "VALIDATION_ERROR", nil), nil
 	}
 
 	// Format documents array
 	var docStrs []string
+	var docs []string
 	for _, doc := range documents {
 		if docStr, ok := doc.(string); ok {
 			docStrs = append(docStrs, fmt.Sprintf("'%s'", strings.ReplaceAll(docStr, "'", "''")))
+			docs = append(docs, docStr)
+		}
+	}
+
+	sampler, hasSampler := SamplerFromContext(ctx)
+	if provider == "client" {
+		if !hasSampler {
+			return Error("provider 'client' requires an MCP client that supports sampling", "SAMPLING_UNAVAILABLE", map[string]interface{}{
+				"provider": provider,
+			}), nil
 		}
+		return t.rerankWithClient(ctx, sampler, query, docs, model, topK, nil)
 	}
 	docsStr := "ARRAY[" + strings.Join(docStrs, ",") + "]::text[]"
 
@@ -192,6 +217,12 @@ func (t *RerankLLMTool) Execute(ctx context.Context, params map[string]interface
 	queryParams := []interface{}{query, model, topK}
 
 	results, err := t.executor.ExecuteQuery(ctx, sqlQuery, queryParams)
+	if err != nil && provider == "auto" && hasSampler {
+		t.logger.Warn("Database LLM reranking failed, falling back to client sampling", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return t.rerankWithClient(ctx, sampler, query, docs, model, topK, err)
+	}
 	if err != nil {
 		t.logger.Error("LLM reranking failed", err, params)
 		return Error(fmt.Sprintf("LLM reranking failed: error=%v", err), "EXECUTION_ERROR", map[string]interface{}{
@@ -203,10 +234,114 @@ func (t *RerankLLMTool) Execute(ctx context.Context, params map[string]interface
 		"results": results,
 		"count":   len(results),
 	}, map[string]interface{}{
-		"count": len(results),
+		"count":    len(results),
+		"provider": "database",
 	}), nil
 }
 
+// rerankWithClient scores documents with the client's model. dbErr is the
+// database failure that caused a fallback, if any.
+func (t *RerankLLMTool) rerankWithClient(ctx context.Context, sampler Sampler, query string, docs []string, model string, topK int, dbErr error) (*ToolResult, error) {
+	results, sampled, err := rerankWithSampler(ctx, sampler, query, docs, model, topK)
+	if err != nil {
+		t.logger.Error("Client LLM reranking failed", err, map[string]interface{}{
+			"document_count": len(docs),
+		})
+		details := map[string]interface{}{
+			"provider": "client",
+			"error":    err.Error(),
+		}
+		if dbErr != nil {
+			details["database_error"] = dbErr.Error()
+		}
+		return Error(fmt.Sprintf("LLM reranking via client sampling failed: document_count=%d, error=%v", len(docs), err), "EXECUTION_ERROR", details), nil
+	}
+
+	metadata := map[string]interface{}{
+		"count":    len(results),
+		"provider": "client",
+		"model":    sampled.Model,
+	}
+	if dbErr != nil {
+		metadata["fallback_reason"] = dbErr.Error()
+	}
+	return Success(map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	}, metadata), nil
+}
+
+const rerankSamplingPrompt = "You rank documents by relevance to a search query. " +
+	"Score each document from 0 (irrelevant) to 10 (perfect match). " +
+	"Reply with only a JSON array of numbers, one score per document, in document order."
+
+// rerankWithSampler asks the sampler to score docs against query and returns
+// the topK documents, best first
+func rerankWithSampler(ctx context.Context, sampler Sampler, query string, docs []string, model string, topK int) ([]map[string]interface{}, *SamplingResult, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Query: %s\n\nDocuments:\n", query)
+	for i, doc := range docs {
+		fmt.Fprintf(&prompt, "[%d] %s\n", i, doc)
+	}
+
+	temperature := 0.0
+	sampled, err := sampler.Sample(ctx, &SamplingRequest{
+		SystemPrompt: rerankSamplingPrompt,
+		Messages:     []SamplingMessage{{Role: "user", Text: prompt.String()}},
+		MaxTokens:    16*len(docs) + 64,
+		Temperature:  &temperature,
+		ModelHint:    model,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scores, err := parseRelevanceScores(sampled.Text, len(docs))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := make([]int, len(docs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	if topK > 0 && topK < len(order) {
+		order = order[:topK]
+	}
+
+	results := make([]map[string]interface{}, len(order))
+	for rank, i := range order {
+		results[rank] = map[string]interface{}{
+			"index":    i,
+			"document": docs[i],
+			"score":    scores[i],
+			"rank":     rank + 1,
+		}
+	}
+	return results, sampled, nil
+}
+
+// parseRelevanceScores extracts the JSON array of n scores from a model reply,
+// tolerating text around the array
+func parseRelevanceScores(text string, n int) ([]float64, error) {
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("model reply contains no JSON array: %.80q", text)
+	}
+	var scores []float64
+	if err := json.Unmarshal([]byte(text[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("model reply is not an array of numbers: %w", err)
+	}
+	if len(scores) != n {
+		return nil, fmt.Errorf("model returned %d scores for %d documents", len(scores), n)
+	}
+	return scores, nil
+}
+
 // RerankCohereTool performs Cohere reranking
 type RerankCohereTool struct {
 	*BaseTool
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"testing"
+)
+
+type fakeSampler struct {
+	reply string
+	req   *SamplingRequest
+}
+
+func (f *fakeSampler) Sample(ctx context.Context, req *SamplingRequest) (*SamplingResult, error) {
+	f.req = req
+	return &SamplingResult{Text: f.reply, Model: "fake"}, nil
+}
+
+func TestRerankWithSampler(t *testing.T) {
+	sampler := &fakeSampler{reply: "Scores: [2, 9, 5]"}
+	docs := []string{"cats", "vector indexes", "databases"}
+	results, sampled, err := rerankWithSampler(context.Background(), sampler, "hnsw", docs, "", 2)
+	if err != nil {
+		t.Fatalf("rerankWithSampler() error: %v", err)
+	}
+	if sampled.Model != "fake" || len(sampler.req.Messages) != 1 {
+		t.Errorf("unexpected sampling exchange: %+v", sampler.req)
+	}
+	if len(results) != 2 || results[0]["index"] != 1 || results[1]["index"] != 2 {
+		t.Errorf("unexpected ranking: %v", results)
+	}
+}
+
+func TestParseRelevanceScores(t *testing.T) {
+	if _, err := parseRelevanceScores("[1, 2]", 3); err == nil {
+		t.Error("score count mismatch accepted")
+	}
+	if _, err := parseRelevanceScores("no scores", 1); err == nil {
+		t.Error("reply without array accepted")
+	}
+}
@@ -0,0 +1,362 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Result formats a query-style tool can answer in
+const (
+	ResultFormatJSON  = "json"
+	ResultFormatCSV   = "csv"
+	ResultFormatArrow = "arrow"
+)
+
+// resultFormatTools are the tools whose result is a list of rows, either as
+// the result itself or as its only list field
+var resultFormatTools = map[string]bool{
+	"vector_search":               true,
+	"vector_search_l2":            true,
+	"vector_search_cosine":        true,
+	"vector_search_inner_product": true,
+	"sparse_search":               true,
+	"semantic_keyword_search":     true,
+	"multi_vector_search":         true,
+	"multi_column_vector_search":  true,
+	"faceted_vector_search":       true,
+	"temporal_vector_search":      true,
+	"diverse_vector_search":       true,
+	"list_models":                 true,
+	"postgresql_connections":      true,
+	"postgresql_locks":            true,
+	"postgresql_settings":         true,
+	"postgresql_extensions":       true,
+	"query_session_table":         true,
+	"run_sql_readonly":            true,
+}
+
+// SupportsResultFormat reports whether a tool can answer in CSV or Arrow
+func SupportsResultFormat(toolName string) bool {
+	return resultFormatTools[toolName]
+}
+
+// ValidResultFormat reports whether format is a known result format
+func ValidResultFormat(format string) bool {
+	switch format {
+	case ResultFormatJSON, ResultFormatCSV, ResultFormatArrow:
+		return true
+	}
+	return false
+}
+
+type resultFormatKey struct{}
+
+// resultCapture carries the requested format of a tool call and the column
+// lists of the queries it ran, which keep the order row maps lose
+type resultCapture struct {
+	format  string
+	mu      sync.Mutex
+	columns [][]string
+}
+
+// WithResultFormat returns a context asking for the result in format
+func WithResultFormat(ctx context.Context, format string) context.Context {
+	return context.WithValue(ctx, resultFormatKey{}, &resultCapture{format: format})
+}
+
+// ResultFormatFromContext returns the requested result format, json when
+// none was requested
+func ResultFormatFromContext(ctx context.Context) string {
+	if capture, ok := ctx.Value(resultFormatKey{}).(*resultCapture); ok {
+		return capture.format
+	}
+	return ResultFormatJSON
+}
+
+// recordResultColumns remembers the column order of a query when the call
+// asked for a tabular format
+func recordResultColumns(ctx context.Context, fields []pgconn.FieldDescription) {
+	capture, ok := ctx.Value(resultFormatKey{}).(*resultCapture)
+	if !ok || capture.format == ResultFormatJSON {
+		return
+	}
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+	capture.mu.Lock()
+	capture.columns = append(capture.columns, names)
+	capture.mu.Unlock()
+}
+
+// FormattedResult is a tool result encoded in a tabular format
+type FormattedResult struct {
+	Format      string
+	ContentType string
+	// Text is the CSV, or the base64 of the Arrow IPC stream
+	Text string
+	Rows int
+	// Fields are the fields of an object result other than its rows
+	Fields map[string]interface{}
+}
+
+// FormatResult encodes the rows of a tool result in the format ctx asks for.
+// It returns nil when the format is JSON.
+func FormatResult(ctx context.Context, data interface{}) (*FormattedResult, error) {
+	format := ResultFormatFromContext(ctx)
+	if format == ResultFormatJSON {
+		return nil, nil
+	}
+	rows, fields, err := resultRows(data)
+	if err != nil {
+		return nil, err
+	}
+	var recorded [][]string
+	if capture, ok := ctx.Value(resultFormatKey{}).(*resultCapture); ok {
+		capture.mu.Lock()
+		recorded = capture.columns
+		capture.mu.Unlock()
+	}
+	table := newResultTable(rows, recorded)
+
+	result := &FormattedResult{Format: format, Rows: len(rows), Fields: fields}
+	switch format {
+	case ResultFormatCSV:
+		result.ContentType = "text/csv"
+		result.Text, err = encodeCSV(table)
+	case ResultFormatArrow:
+		result.ContentType = "application/vnd.apache.arrow.stream"
+		result.Text = base64.StdEncoding.EncodeToString(encodeArrowStream(table))
+	default:
+		return nil, fmt.Errorf("unknown result format '%s'", format)
+	}
+	return result, err
+}
+
+// resultRows finds the rows of a result: the result itself, or the one
+// field of an object result that holds rows
+func resultRows(data interface{}) ([]map[string]interface{}, map[string]interface{}, error) {
+	switch v := data.(type) {
+	case []map[string]interface{}:
+		return v, nil, nil
+	case map[string]interface{}:
+		var rows []map[string]interface{}
+		found := 0
+		fields := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if r, ok := value.([]map[string]interface{}); ok {
+				rows = r
+				found++
+				continue
+			}
+			fields[key] = value
+		}
+		if found == 1 {
+			return rows, fields, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("result has no rows to format")
+}
+
+// Kinds of result columns, in order of preference
+const (
+	columnInt64 = iota
+	columnFloat64
+	columnBool
+	columnString
+)
+
+type resultColumn struct {
+	Name string
+	Kind int
+}
+
+// resultTable is a result in row order with normalized cells: int64,
+// float64, bool, string or nil
+type resultTable struct {
+	Columns []resultColumn
+	Rows    [][]interface{}
+}
+
+// newResultTable builds a table from row maps. The columns take the order
+// of a recorded query with exactly the rows' keys, and sorted order when no
+// query matches (rows built by the tool rather than scanned).
+func newResultTable(rows []map[string]interface{}, recorded [][]string) *resultTable {
+	keys := make(map[string]bool)
+	for _, row := range rows {
+		for key := range row {
+			keys[key] = true
+		}
+	}
+	var names []string
+	for i := len(recorded) - 1; i >= 0 && names == nil; i-- {
+		if sameColumns(recorded[i], keys) {
+			names = recorded[i]
+		}
+	}
+	if names == nil {
+		for key := range keys {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+	}
+
+	table := &resultTable{Columns: make([]resultColumn, len(names)), Rows: make([][]interface{}, len(rows))}
+	for r, row := range rows {
+		cells := make([]interface{}, len(names))
+		for c, name := range names {
+			cells[c] = normalizeCell(row[name])
+		}
+		table.Rows[r] = cells
+	}
+	for c, name := range names {
+		table.Columns[c] = resultColumn{Name: name, Kind: columnKind(table.Rows, c)}
+	}
+	return table
+}
+
+func sameColumns(names []string, keys map[string]bool) bool {
+	if len(names) != len(keys) {
+		return false
+	}
+	for _, name := range names {
+		if !keys[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// columnKind picks the narrowest kind holding every value of a column.
+// Mixed integers and floats are floats; anything else mixed is a string.
+func columnKind(rows [][]interface{}, c int) int {
+	kind := -1
+	for _, row := range rows {
+		var k int
+		switch row[c].(type) {
+		case nil:
+			continue
+		case int64:
+			k = columnInt64
+		case float64:
+			k = columnFloat64
+		case bool:
+			k = columnBool
+		default:
+			return columnString
+		}
+		switch {
+		case kind == -1 || kind == k:
+			kind = k
+		case kind <= columnFloat64 && k <= columnFloat64:
+			kind = columnFloat64
+		default:
+			return columnString
+		}
+	}
+	if kind == -1 {
+		return columnString
+	}
+	return kind
+}
+
+// normalizeCell converts a scanned value to int64, float64, bool, string or
+// nil. Times are RFC 3339 strings; other values are their JSON encoding.
+func normalizeCell(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, int64, float64, bool, string:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+		return float64(v)
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	}
+	if encoded, err := json.Marshal(v); err == nil {
+		return string(encoded)
+	}
+	return fmt.Sprint(v)
+}
+
+// cellString formats a normalized cell as text
+func cellString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// cellFloat returns a normalized numeric cell as a float
+func cellFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// encodeCSV writes a header row and then the rows; NULL is an empty field
+func encodeCSV(table *resultTable) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column.Name
+	}
+	if err := w.Write(header); err != nil {
+		return "", err
+	}
+	record := make([]string, len(table.Columns))
+	for _, row := range table.Rows {
+		for i, cell := range row {
+			record[i] = cellString(cell)
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
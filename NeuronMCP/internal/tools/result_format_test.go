@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestNewResultTable(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": int32(1), "score": 0.5, "name": "a", "ok": true},
+		{"id": int64(2), "score": int64(1), "name": nil, "ok": nil},
+	}
+	table := newResultTable(rows, [][]string{{"other"}, {"id", "score", "name", "ok"}})
+
+	want := []resultColumn{{"id", columnInt64}, {"score", columnFloat64}, {"name", columnString}, {"ok", columnBool}}
+	for i, column := range table.Columns {
+		if column != want[i] {
+			t.Errorf("column %d = %+v, want %+v", i, column, want[i])
+		}
+	}
+
+	// Without a recorded query the columns are sorted
+	table = newResultTable(rows, nil)
+	if table.Columns[0].Name != "id" || table.Columns[1].Name != "name" {
+		t.Errorf("unrecorded columns = %+v", table.Columns)
+	}
+
+	if got := normalizeCell(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)); got != "2024-01-02T03:04:05Z" {
+		t.Errorf("time cell = %v", got)
+	}
+	if got := normalizeCell(map[string]interface{}{"k": 1}); got != `{"k":1}` {
+		t.Errorf("map cell = %v", got)
+	}
+}
+
+func TestFormatResultCSV(t *testing.T) {
+	ctx := WithResultFormat(context.Background(), ResultFormatCSV)
+	recordResultColumns(ctx, []pgconn.FieldDescription{{Name: "id"}, {Name: "text"}})
+
+	result, err := FormatResult(ctx, map[string]interface{}{
+		"results": []map[string]interface{}{{"id": int64(1), "text": "a,b"}, {"id": int64(2), "text": nil}},
+		"count":   2,
+	})
+	if err != nil {
+		t.Fatalf("FormatResult() error = %v", err)
+	}
+	if want := "id,text\n1,\"a,b\"\n2,\n"; result.Text != want {
+		t.Errorf("csv = %q, want %q", result.Text, want)
+	}
+	if result.Rows != 2 || result.Fields["count"] != 2 {
+		t.Errorf("result = %+v", result)
+	}
+
+	if _, err := FormatResult(ctx, map[string]interface{}{"model": "x"}); err == nil {
+		t.Error("result without rows was formatted")
+	}
+	if result, _ := FormatResult(context.Background(), []map[string]interface{}{}); result != nil {
+		t.Error("json result was formatted")
+	}
+}
+
+// fbReader reads the FlatBuffers the Arrow encoder writes
+type fbReader []byte
+
+func (r fbReader) u32(pos int) int { return int(binary.LittleEndian.Uint32(r[pos:])) }
+
+func (r fbReader) deref(pos int) int { return pos + r.u32(pos) }
+
+// field returns the position of field id of the table at pos, or 0
+func (r fbReader) field(table, id int) int {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(r[table:])))
+	if 4+2*id >= int(binary.LittleEndian.Uint16(r[vtable:])) {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(r[vtable+4+2*id:]))
+	if off == 0 {
+		return 0
+	}
+	return table + off
+}
+
+func (r fbReader) str(pos int) string {
+	return string(r[pos+4 : pos+4+r.u32(pos)])
+}
+
+func TestEncodeArrowStream(t *testing.T) {
+	ctx := WithResultFormat(context.Background(), ResultFormatArrow)
+	recordResultColumns(ctx, []pgconn.FieldDescription{{Name: "id"}, {Name: "score"}, {Name: "name"}, {Name: "ok"}})
+	result, err := FormatResult(ctx, []map[string]interface{}{
+		{"id": int64(7), "score": 1.5, "name": "ab", "ok": true},
+		{"id": int64(-1), "score": nil, "name": "c", "ok": false},
+	})
+	if err != nil {
+		t.Fatalf("FormatResult() error = %v", err)
+	}
+	stream, err := base64.StdEncoding.DecodeString(result.Text)
+	if err != nil {
+		t.Fatalf("arrow text is not base64: %v", err)
+	}
+
+	// readMessage returns the Message metadata, its header and the body
+	readMessage := func(pos int) (fbReader, int, []byte, int) {
+		if binary.LittleEndian.Uint32(stream[pos:]) != arrowContinuation || pos%8 != 0 {
+			t.Fatalf("no aligned message at %d", pos)
+		}
+		size := int(binary.LittleEndian.Uint32(stream[pos+4:]))
+		meta := fbReader(stream[pos+8 : pos+8+size])
+		root := meta.deref(0)
+		if v := binary.LittleEndian.Uint16(meta[meta.field(root, 0):]); v != arrowMetadataV5 {
+			t.Errorf("version = %d", v)
+		}
+		bodyLen := int(binary.LittleEndian.Uint64(meta[meta.field(root, 3):]))
+		body := stream[pos+8+size : pos+8+size+bodyLen]
+		return meta, root, body, pos + 8 + size + bodyLen
+	}
+
+	meta, root, _, next := readMessage(0)
+	if meta[meta.field(root, 1)] != arrowHeaderSchema {
+		t.Fatal("first message is not a schema")
+	}
+	schema := meta.deref(meta.field(root, 2))
+	fields := meta.deref(meta.field(schema, 1))
+	wantTypes := []byte{arrowTypeInt, arrowTypeFloatingPoint, arrowTypeUtf8, arrowTypeBool}
+	if meta.u32(fields) != len(wantTypes) {
+		t.Fatalf("schema has %d fields", meta.u32(fields))
+	}
+	for i, want := range wantTypes {
+		field := meta.deref(fields + 4 + 4*i)
+		name := meta.str(meta.deref(meta.field(field, 0)))
+		if name != []string{"id", "score", "name", "ok"}[i] || meta[meta.field(field, 2)] != want || meta.field(field, 5) == 0 {
+			t.Errorf("field %d: name=%s type=%d", i, name, meta[meta.field(field, 2)])
+		}
+	}
+
+	meta, root, body, next := readMessage(next)
+	if meta[meta.field(root, 1)] != arrowHeaderRecordBatch {
+		t.Fatal("second message is not a record batch")
+	}
+	batch := meta.deref(meta.field(root, 2))
+	if n := binary.LittleEndian.Uint64(meta[meta.field(batch, 0):]); n != 2 {
+		t.Errorf("batch length = %d", n)
+	}
+	nodes := meta.deref(meta.field(batch, 1))
+	buffers := meta.deref(meta.field(batch, 2))
+	if (nodes+4)%8 != 0 || (buffers+4)%8 != 0 {
+		t.Error("struct vectors are not 8-aligned")
+	}
+	if nulls := binary.LittleEndian.Uint64(meta[nodes+4+16+8:]); nulls != 1 {
+		t.Errorf("score null count = %d", nulls)
+	}
+	buffer := func(i int) []byte {
+		off := binary.LittleEndian.Uint64(meta[buffers+4+16*i:])
+		length := binary.LittleEndian.Uint64(meta[buffers+4+16*i+8:])
+		return body[off : off+length]
+	}
+	if ids := buffer(1); int64(binary.LittleEndian.Uint64(ids[8:])) != -1 {
+		t.Errorf("id values = %v", ids)
+	}
+	if scores := buffer(3); math.Float64frombits(binary.LittleEndian.Uint64(scores)) != 1.5 || buffer(2)[0] != 1 {
+		t.Errorf("score values = %v, validity = %v", scores, buffer(2))
+	}
+	if offsets, data := buffer(5), buffer(6); binary.LittleEndian.Uint32(offsets[8:]) != 3 || string(data) != "abc" {
+		t.Errorf("name offsets = %v, data = %q", offsets, data)
+	}
+	if flags := buffer(8); flags[0] != 1 {
+		t.Errorf("ok values = %v", flags)
+	}
+
+	if next != len(stream)-8 || binary.LittleEndian.Uint32(stream[next+4:]) != 0 {
+		t.Error("stream does not end with the end-of-stream marker")
+	}
+}
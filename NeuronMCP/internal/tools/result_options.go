@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Timestamp styles of query results
+const (
+	// TimestampsRaw returns times as the driver scans them: timestamptz in
+	// the server's zone, and dates and timestamps as UTC instants
+	TimestampsRaw = "raw"
+	// TimestampsISO8601 returns dates, times, timestamps and intervals as
+	// ISO-8601 strings, with an offset only for types that have one
+	TimestampsISO8601 = "iso8601"
+)
+
+// MaxNumericPrecision bounds the decimal places results are rounded to
+const MaxNumericPrecision = 20
+
+// ResultOptions post-process the values the row scanner returns
+type ResultOptions struct {
+	// Location converts timestamptz values; nil keeps them as scanned
+	Location *time.Location
+	// Timestamps is TimestampsRaw or TimestampsISO8601
+	Timestamps string
+	// NumericPrecision rounds floats and numerics to this many decimal
+	// places; negative keeps them as scanned
+	NumericPrecision int
+}
+
+// DefaultResultOptions leave scanned values unchanged
+func DefaultResultOptions() ResultOptions {
+	return ResultOptions{Timestamps: TimestampsRaw, NumericPrecision: -1}
+}
+
+// ParseResultOptions overrides opts with the options of a config or a call.
+// Empty strings and a nil precision keep the options of opts.
+func ParseResultOptions(opts ResultOptions, timezone, timestamps string, precision *int) (ResultOptions, error) {
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return opts, fmt.Errorf("timezone must be an IANA time zone such as 'Europe/Berlin' or 'UTC', got '%s'", timezone)
+		}
+		opts.Location = location
+	}
+	if timestamps != "" {
+		if timestamps != TimestampsRaw && timestamps != TimestampsISO8601 {
+			return opts, fmt.Errorf("timestamps must be %s or %s, got '%s'", TimestampsRaw, TimestampsISO8601, timestamps)
+		}
+		opts.Timestamps = timestamps
+	}
+	if precision != nil {
+		if *precision < 0 || *precision > MaxNumericPrecision {
+			return opts, fmt.Errorf("numeric_precision must be between 0 and %d, got %d", MaxNumericPrecision, *precision)
+		}
+		opts.NumericPrecision = *precision
+	}
+	return opts, nil
+}
+
+func (o ResultOptions) isDefault() bool {
+	return o.Location == nil && o.Timestamps != TimestampsISO8601 && o.NumericPrecision < 0
+}
+
+type resultOptionsKey struct{}
+
+// WithResultOptions returns a context whose query results are post-processed
+// with opts
+func WithResultOptions(ctx context.Context, opts ResultOptions) context.Context {
+	if opts.isDefault() {
+		return ctx
+	}
+	return context.WithValue(ctx, resultOptionsKey{}, opts)
+}
+
+// resultOptionsFromContext returns the result options of ctx, and false when
+// values are returned as scanned
+func resultOptionsFromContext(ctx context.Context) (ResultOptions, bool) {
+	opts, ok := ctx.Value(resultOptionsKey{}).(ResultOptions)
+	return opts, ok
+}
+
+// formatValue applies the options to a value scanned from a column of type
+// oid
+func (o ResultOptions) formatValue(oid uint32, value interface{}) interface{} {
+	iso := o.Timestamps == TimestampsISO8601
+	switch v := value.(type) {
+	case time.Time:
+		switch oid {
+		case pgtype.TimestamptzOID:
+			if o.Location != nil {
+				v = v.In(o.Location)
+			}
+			if iso {
+				return v.Format("2006-01-02T15:04:05.999999Z07:00")
+			}
+			return v
+		case pgtype.TimestampOID:
+			if iso {
+				return v.Format("2006-01-02T15:04:05.999999")
+			}
+		case pgtype.DateOID:
+			if iso {
+				return v.Format("2006-01-02")
+			}
+		}
+		return v
+	case pgtype.InfinityModifier:
+		if iso {
+			return v.String()
+		}
+	case pgtype.Time:
+		if iso && v.Valid {
+			return formatTimeOfDay(v.Microseconds)
+		}
+	case pgtype.Interval:
+		if iso && v.Valid {
+			return formatISODuration(v)
+		}
+	case float64:
+		if o.NumericPrecision >= 0 {
+			return roundFloat(v, o.NumericPrecision, 64)
+		}
+	case float32:
+		if o.NumericPrecision >= 0 {
+			return roundFloat(float64(v), o.NumericPrecision, 32)
+		}
+	case pgtype.Numeric:
+		if o.NumericPrecision >= 0 {
+			return roundNumeric(v, o.NumericPrecision)
+		}
+	}
+	return value
+}
+
+// formatTimeOfDay formats microseconds since midnight as hh:mm:ss with
+// fractional seconds when there are any
+func formatTimeOfDay(us int64) string {
+	if us == 24*60*60*1e6 {
+		// 24:00:00 is a valid PostgreSQL time
+		return "24:00:00"
+	}
+	t := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(us) * time.Microsecond)
+	return t.Format("15:04:05.999999")
+}
+
+// formatISODuration formats an interval as an ISO-8601 duration such as
+// P1Y2M3DT4H5M6.5S. PostgreSQL keeps months, days and microseconds apart,
+// as does the result, so signs may differ between components.
+func formatISODuration(v pgtype.Interval) string {
+	var b strings.Builder
+	b.WriteString("P")
+	years, months := v.Months/12, v.Months%12
+	if years != 0 {
+		fmt.Fprintf(&b, "%dY", years)
+	}
+	if months != 0 {
+		fmt.Fprintf(&b, "%dM", months)
+	}
+	if v.Days != 0 {
+		fmt.Fprintf(&b, "%dD", v.Days)
+	}
+	if us := v.Microseconds; us != 0 {
+		b.WriteString("T")
+		sign := ""
+		if us < 0 {
+			sign, us = "-", -us
+		}
+		hours, us := us/3600e6, us%3600e6
+		minutes, us := us/60e6, us%60e6
+		if hours != 0 {
+			fmt.Fprintf(&b, "%s%dH", sign, hours)
+		}
+		if minutes != 0 {
+			fmt.Fprintf(&b, "%s%dM", sign, minutes)
+		}
+		if us != 0 {
+			seconds := strconv.FormatFloat(float64(us)/1e6, 'f', -1, 64)
+			fmt.Fprintf(&b, "%s%sS", sign, seconds)
+		}
+	}
+	if b.Len() == 1 {
+		return "PT0S"
+	}
+	return b.String()
+}
+
+// roundFloat rounds v to places decimal places, returning the float nearest
+// the rounded decimal
+func roundFloat(v float64, places, bitSize int) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'f', places, bitSize), 64)
+	if err != nil {
+		return v
+	}
+	return rounded
+}
+
+// roundNumeric rounds n to places decimal places, half away from zero like
+// PostgreSQL's round(numeric, int). Numerics with fewer places are kept.
+func roundNumeric(n pgtype.Numeric, places int) pgtype.Numeric {
+	if !n.Valid || n.NaN || n.InfinityModifier != pgtype.Finite || n.Int == nil {
+		return n
+	}
+	shift := -int(n.Exp) - places
+	if shift <= 0 {
+		return n
+	}
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(shift)), nil)
+	quotient, remainder := new(big.Int).QuoRem(n.Int, divisor, new(big.Int))
+	if new(big.Int).Lsh(new(big.Int).Abs(remainder), 1).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(n.Int.Sign())))
+	}
+	return pgtype.Numeric{Int: quotient, Exp: int32(-places), Valid: true}
+}
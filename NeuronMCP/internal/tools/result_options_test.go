@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParseResultOptions(t *testing.T) {
+	precision := 2
+	opts, err := ParseResultOptions(DefaultResultOptions(), "Europe/Berlin", TimestampsISO8601, &precision)
+	if err != nil {
+		t.Fatalf("ParseResultOptions() error = %v", err)
+	}
+	if opts.Location.String() != "Europe/Berlin" || opts.Timestamps != TimestampsISO8601 || opts.NumericPrecision != 2 {
+		t.Errorf("opts = %+v", opts)
+	}
+
+	// Empty options keep the ones they override
+	if kept, err := ParseResultOptions(opts, "", "", nil); err != nil || kept != opts {
+		t.Errorf("kept = %+v, %v", kept, err)
+	}
+
+	bad := MaxNumericPrecision + 1
+	for _, tt := range []struct {
+		timezone, timestamps string
+		precision            *int
+	}{
+		{"Mars/Olympus", "", nil},
+		{"", "rfc2822", nil},
+		{"", "", &bad},
+	} {
+		if _, err := ParseResultOptions(DefaultResultOptions(), tt.timezone, tt.timestamps, tt.precision); err == nil {
+			t.Errorf("ParseResultOptions(%q, %q) accepted", tt.timezone, tt.timestamps)
+		}
+	}
+}
+
+func TestWithResultOptions(t *testing.T) {
+	ctx := WithResultOptions(context.Background(), DefaultResultOptions())
+	if _, ok := resultOptionsFromContext(ctx); ok {
+		t.Error("default options were stored")
+	}
+	ctx = WithResultOptions(context.Background(), ResultOptions{Timestamps: TimestampsISO8601, NumericPrecision: -1})
+	if _, ok := resultOptionsFromContext(ctx); !ok {
+		t.Error("iso8601 options were not stored")
+	}
+}
+
+func TestFormatValueTimestamps(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	opts := ResultOptions{Location: tokyo, Timestamps: TimestampsISO8601, NumericPrecision: -1}
+	instant := time.Date(2024, 3, 1, 22, 30, 0, 500000000, time.UTC)
+
+	tests := []struct {
+		name  string
+		oid   uint32
+		value interface{}
+		want  interface{}
+	}{
+		{"timestamptz", pgtype.TimestamptzOID, instant, "2024-03-02T07:30:00.5+09:00"},
+		{"timestamp", pgtype.TimestampOID, instant, "2024-03-01T22:30:00.5"},
+		{"date", pgtype.DateOID, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "2024-03-01"},
+		{"infinity", pgtype.TimestamptzOID, pgtype.Infinity, "infinity"},
+		{"time", pgtype.TimeOID, pgtype.Time{Microseconds: (13*3600 + 5) * 1e6, Valid: true}, "13:00:05"},
+		{"end of day", pgtype.TimeOID, pgtype.Time{Microseconds: 24 * 3600 * 1e6, Valid: true}, "24:00:00"},
+		{"interval", pgtype.IntervalOID, pgtype.Interval{Months: 14, Days: 3, Microseconds: 3600e6 + 1500000, Valid: true}, "P1Y2M3DT1H1.5S"},
+		{"zero interval", pgtype.IntervalOID, pgtype.Interval{Valid: true}, "PT0S"},
+		{"text", pgtype.TextOID, "2024-03-01", "2024-03-01"},
+	}
+	for _, tt := range tests {
+		if got := opts.formatValue(tt.oid, tt.value); got != tt.want {
+			t.Errorf("%s: formatValue() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Without iso8601, timestamptz values stay times in the requested zone
+	opts.Timestamps = TimestampsRaw
+	got, ok := opts.formatValue(pgtype.TimestamptzOID, instant).(time.Time)
+	if !ok || got.Location() != tokyo || !got.Equal(instant) {
+		t.Errorf("raw timestamptz = %v", got)
+	}
+	if got := opts.formatValue(pgtype.TimestampOID, instant); got != instant {
+		t.Errorf("raw timestamp converted to %v", got)
+	}
+}
+
+func TestFormatValueNumerics(t *testing.T) {
+	opts := ResultOptions{Timestamps: TimestampsRaw, NumericPrecision: 2}
+	if got := opts.formatValue(pgtype.Float8OID, 3.14159); got != 3.14 {
+		t.Errorf("float8 = %v", got)
+	}
+	if got := opts.formatValue(pgtype.Float4OID, float32(2.71828)); got != 2.72 {
+		t.Errorf("float4 = %v", got)
+	}
+	if got := opts.formatValue(pgtype.Int8OID, int64(7)); got != int64(7) {
+		t.Errorf("int8 = %v", got)
+	}
+
+	tests := []struct {
+		n    pgtype.Numeric
+		want string
+	}{
+		{pgtype.Numeric{Int: big.NewInt(123456), Exp: -4, Valid: true}, "12.35"},
+		{pgtype.Numeric{Int: big.NewInt(-123450), Exp: -4, Valid: true}, "-12.35"},
+		{pgtype.Numeric{Int: big.NewInt(15), Exp: -1, Valid: true}, "1.5"},
+		{pgtype.Numeric{Int: big.NewInt(42), Exp: 3, Valid: true}, "42000"},
+		{pgtype.Numeric{NaN: true, Valid: true}, `"NaN"`},
+	}
+	for _, tt := range tests {
+		encoded, err := json.Marshal(opts.formatValue(pgtype.NumericOID, tt.n))
+		if err != nil || string(encoded) != tt.want {
+			t.Errorf("numeric %v = %s, %v, want %s", tt.n, encoded, err, tt.want)
+		}
+	}
+}
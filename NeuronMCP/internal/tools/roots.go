@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RootsProvider lists the directories the connected MCP client shares with
+// the server (MCP roots). Tools reading local files accept only paths inside
+// them.
+type RootsProvider interface {
+	Roots(ctx context.Context) ([]string, error)
+}
+
+type rootsKey struct{}
+
+// WithRoots returns a context carrying the client's roots for tool execution
+func WithRoots(ctx context.Context, roots RootsProvider) context.Context {
+	return context.WithValue(ctx, rootsKey{}, roots)
+}
+
+// RootsFromContext returns the roots provider attached to ctx, if the client
+// supports roots
+func RootsFromContext(ctx context.Context) (RootsProvider, bool) {
+	roots, ok := ctx.Value(rootsKey{}).(RootsProvider)
+	return roots, ok && roots != nil
+}
+
+type localFileDirsKey struct{}
+
+// WithLocalFileDirs returns a context letting tools read local files inside
+// dirs for clients that share no roots
+func WithLocalFileDirs(ctx context.Context, dirs []string) context.Context {
+	return context.WithValue(ctx, localFileDirsKey{}, dirs)
+}
+
+// resolveLocalPath returns the absolute path of a local file a tool was asked
+// to read, with symbolic links resolved. When the client shares roots the
+// path must lie inside one of them. Otherwise it must lie inside one of the
+// directories the server allows with WithLocalFileDirs, and with none of
+// them no path is allowed.
+func resolveLocalPath(ctx context.Context, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path must be absolute, got '%s'", path)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+
+	var roots []string
+	var rootsErr error
+	if provider, ok := RootsFromContext(ctx); ok {
+		roots, rootsErr = provider.Roots(ctx)
+	}
+	if rootsErr == nil && len(roots) > 0 {
+		for _, root := range roots {
+			if pathInRoot(resolved, root) {
+				return resolved, nil
+			}
+		}
+		return "", fmt.Errorf("path '%s' is outside the client's roots [%s]", path, strings.Join(roots, ", "))
+	}
+
+	// Without roots only the server's own allowlist lets a file be read
+	dirs, _ := ctx.Value(localFileDirsKey{}).([]string)
+	for _, dir := range dirs {
+		if pathInRoot(resolved, dir) {
+			return resolved, nil
+		}
+	}
+	reason := "the client shares no roots"
+	if rootsErr != nil {
+		reason = fmt.Sprintf("the client's roots could not be listed: %v", rootsErr)
+	}
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("path '%s' is not allowed: %s and server.localFileDirs is not set", path, reason)
+	}
+	return "", fmt.Errorf("path '%s' is not allowed: %s and the path is outside server.localFileDirs [%s]", path, reason, strings.Join(dirs, ", "))
+}
+
+// pathInRoot reports whether path is root or inside it. Both sides have
+// their symbolic links resolved, so a link in a root cannot lead out of it.
+func pathInRoot(path, root string) bool {
+	if !filepath.IsAbs(root) {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	} else if !os.IsNotExist(err) {
+		return false
+	}
+	root = filepath.Clean(root)
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
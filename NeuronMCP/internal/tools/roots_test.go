@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type staticRoots []string
+
+func (r staticRoots) Roots(ctx context.Context) ([]string, error) {
+	return r, nil
+}
+
+func TestResolveLocalPath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inside := filepath.Join(root, "doc.txt")
+	secret := filepath.Join(outside, "secret.txt")
+	for _, f := range []string{inside, secret} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(root, "link.txt")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithRoots(context.Background(), staticRoots{root})
+	if _, err := resolveLocalPath(ctx, inside); err != nil {
+		t.Errorf("path inside the root refused: %v", err)
+	}
+	for _, path := range []string{
+		secret,
+		filepath.Join(root, "..", "outside", "secret.txt"),
+		link, // a link leading out of the root
+		"doc.txt",
+	} {
+		if _, err := resolveLocalPath(ctx, path); err == nil {
+			t.Errorf("resolveLocalPath(%q) accepted", path)
+		}
+	}
+
+	// A root that only shares a prefix does not contain the path
+	if _, err := resolveLocalPath(WithRoots(context.Background(), staticRoots{filepath.Join(base, "out")}), secret); err == nil {
+		t.Error("path accepted under a root sharing its prefix")
+	}
+	if _, err := resolveLocalPath(WithRoots(context.Background(), staticRoots{}), inside); err == nil {
+		t.Error("path accepted although the client shares no roots")
+	}
+}
+
+type failingRoots struct{}
+
+func (failingRoots) Roots(ctx context.Context) ([]string, error) {
+	return nil, errors.New("roots/list timed out")
+}
+
+func TestResolveLocalPathWithoutRoots(t *testing.T) {
+	base := t.TempDir()
+	allowed := filepath.Join(base, "allowed")
+	if err := os.Mkdir(allowed, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	doc := filepath.Join(allowed, "doc.txt")
+	secret := filepath.Join(base, ".pgpass")
+	for _, f := range []string{doc, secret} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Clients without roots, with no roots shared or whose roots cannot be
+	// listed read no files unless the server allows a directory
+	for name, ctx := range map[string]context.Context{
+		"no roots capability": context.Background(),
+		"empty roots":         WithRoots(context.Background(), staticRoots{}),
+		"failing roots":       WithRoots(context.Background(), failingRoots{}),
+	} {
+		if _, err := resolveLocalPath(ctx, doc); err == nil {
+			t.Errorf("%s: path accepted without an allowed directory", name)
+		}
+		ctx = WithLocalFileDirs(ctx, []string{allowed})
+		if _, err := resolveLocalPath(ctx, doc); err != nil {
+			t.Errorf("%s: path inside server.localFileDirs refused: %v", name, err)
+		}
+		if _, err := resolveLocalPath(ctx, secret); err == nil {
+			t.Errorf("%s: path outside server.localFileDirs accepted", name)
+		}
+	}
+
+	// Roots the client shares take precedence over the server's directories
+	ctx := WithLocalFileDirs(WithRoots(context.Background(), staticRoots{filepath.Join(base, "other")}), []string{allowed})
+	if _, err := resolveLocalPath(ctx, doc); err == nil {
+		t.Error("path outside the client's roots accepted through server.localFileDirs")
+	}
+}
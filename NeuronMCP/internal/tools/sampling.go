@@ -0,0 +1,45 @@
+package tools
+
+import "context"
+
+// SamplingMessage is one turn of a sampling conversation
+type SamplingMessage struct {
+	Role string // "user" or "assistant"
+	Text string
+}
+
+// SamplingRequest asks the client's model for a completion
+type SamplingRequest struct {
+	SystemPrompt string
+	Messages     []SamplingMessage
+	MaxTokens    int
+	Temperature  *float64
+	ModelHint    string // preferred model name, advisory only
+}
+
+// SamplingResult is the client's completion
+type SamplingResult struct {
+	Text       string
+	Model      string
+	StopReason string
+}
+
+// Sampler requests completions from the LLM of the connected MCP client
+// (MCP sampling). Tools use it when the database has no LLM configured.
+type Sampler interface {
+	Sample(ctx context.Context, req *SamplingRequest) (*SamplingResult, error)
+}
+
+type samplerKey struct{}
+
+// WithSampler returns a context carrying sampler for tool execution
+func WithSampler(ctx context.Context, sampler Sampler) context.Context {
+	return context.WithValue(ctx, samplerKey{}, sampler)
+}
+
+// SamplerFromContext returns the sampler attached to ctx, if the client
+// supports sampling
+func SamplerFromContext(ctx context.Context) (Sampler, bool) {
+	sampler, ok := ctx.Value(samplerKey{}).(Sampler)
+	return sampler, ok && sampler != nil
+}
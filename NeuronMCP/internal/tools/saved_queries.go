@@ -0,0 +1,793 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// savedQueriesTable stores the saved queries. It is created by the first
+// create_saved_query call.
+const savedQueriesTable = "neurondb_mcp.saved_queries"
+
+const (
+	// maxSavedQueryParameters bounds the parameters a saved query declares
+	maxSavedQueryParameters = 32
+	// maxSavedQueryTags bounds the permission tags of a saved query
+	maxSavedQueryTags = 16
+)
+
+// savedQueryNameRe matches saved query, parameter and tag names
+var savedQueryNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// savedQueryTypes are the declared types of saved query parameters
+var savedQueryTypes = map[string]bool{
+	"text":      true,
+	"integer":   true,
+	"number":    true,
+	"boolean":   true,
+	"date":      true,
+	"timestamp": true,
+	"uuid":      true,
+	"text[]":    true,
+	"integer[]": true,
+	"number[]":  true,
+}
+
+// savedQueryUUIDRe matches the canonical text form of a UUID
+var savedQueryUUIDRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SavedQueryParameter declares a parameter of a saved query. The i-th
+// parameter is bound to $i.
+type SavedQueryParameter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// SavedQuery is a named read-only query with declared parameters
+type SavedQuery struct {
+	Name           string                `json:"name"`
+	Description    string                `json:"description"`
+	Query          string                `json:"query"`
+	Parameters     []SavedQueryParameter `json:"parameters"`
+	PermissionTags []string              `json:"permission_tags"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// QueryTagAuthorizer returns an error when the caller may not run saved
+// queries carrying tags
+type QueryTagAuthorizer func(tags []string) error
+
+type queryTagAuthorizerKey struct{}
+
+// WithQueryTagAuthorizer returns a context carrying the authorizer of saved
+// query permission tags for tool execution
+func WithQueryTagAuthorizer(ctx context.Context, authorize QueryTagAuthorizer) context.Context {
+	return context.WithValue(ctx, queryTagAuthorizerKey{}, authorize)
+}
+
+// authorizeQueryTags checks tags against the authorizer attached to ctx.
+// Without one every saved query may be run.
+func authorizeQueryTags(ctx context.Context, tags []string) error {
+	authorize, ok := ctx.Value(queryTagAuthorizerKey{}).(QueryTagAuthorizer)
+	if !ok || authorize == nil {
+		return nil
+	}
+	return authorize(tags)
+}
+
+// savedQueryParametersSchema is the input schema of a parameter declaration
+var savedQueryParametersSchema = map[string]interface{}{
+	"type":     "array",
+	"maxItems": maxSavedQueryParameters,
+	"items": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Parameter name: lowercase letters, digits and underscores",
+			},
+			"type": map[string]interface{}{
+				"type":        "string",
+				"enum":        []interface{}{"text", "integer", "number", "boolean", "date", "timestamp", "uuid", "text[]", "integer[]", "number[]"},
+				"description": "Type the argument is checked against; date is YYYY-MM-DD and timestamp RFC 3339",
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "What the parameter means, shown to callers",
+			},
+			"required": map[string]interface{}{
+				"type":        "boolean",
+				"default":     false,
+				"description": "Whether callers must pass the parameter; optional parameters without a default are bound as NULL",
+			},
+			"default": map[string]interface{}{
+				"description": "Value bound when the caller omits the parameter",
+			},
+		},
+		"required": []interface{}{"name", "type"},
+	},
+	"description": "Parameters in placeholder order: the first is bound to $1, the second to $2 and so on",
+}
+
+// CreateSavedQueryTool saves a named query template
+type CreateSavedQueryTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewCreateSavedQueryTool creates a new create saved query tool
+func NewCreateSavedQueryTool(db *database.Database, logger *logging.Logger) *CreateSavedQueryTool {
+	return &CreateSavedQueryTool{
+		BaseTool: NewBaseTool(
+			"create_saved_query",
+			"Save a vetted read-only query under a name, with declared typed parameters and permission tags, so callers can run it with execute_saved_query instead of writing SQL",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Query name: lowercase letters, digits and underscores",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "What the query answers, shown by list_saved_queries",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "A single read-only SELECT, WITH, VALUES or TABLE statement using $1, $2, ... for the parameters",
+					},
+					"parameters": savedQueryParametersSchema,
+					"permission_tags": map[string]interface{}{
+						"type":        "array",
+						"maxItems":    maxSavedQueryTags,
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Tags restricting who may run the query; the policy's queryTagRoles maps each tag to the roles allowed",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace a saved query of the same name",
+					},
+				},
+				"required": []interface{}{"name", "query"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute validates and saves the query
+func (t *CreateSavedQueryTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for create_saved_query tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+
+	saved, invalid := parseSavedQuery(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+	overwrite, _ := params["overwrite"].(bool)
+	// A caller may not store a query it could not run itself
+	if denied := savedQueryDenied(ctx, t.logger, saved.Name, saved.PermissionTags); denied != nil {
+		return denied, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+	if err := ensureSavedQueriesTable(ctx, db); err != nil {
+		return savedQueryError("create", saved.Name, err), nil
+	}
+
+	// Nor replace one it could not run. The update only applies while the
+	// row still has the tags checked here.
+	var existingTags []string
+	if overwrite {
+		found, err := loadSavedQueries(ctx, db, saved.Name)
+		if err != nil {
+			return savedQueryError("create", saved.Name, err), nil
+		}
+		if len(found) > 0 {
+			existingTags = found[0].PermissionTags
+			if denied := savedQueryDenied(ctx, t.logger, saved.Name, existingTags); denied != nil {
+				return denied, nil
+			}
+		}
+	}
+
+	parameters, err := json.Marshal(saved.Parameters)
+	if err != nil {
+		return savedQueryError("create", saved.Name, err), nil
+	}
+	query := `INSERT INTO ` + savedQueriesTable + ` AS q (name, description, query, parameters, permission_tags)
+		VALUES ($1, $2, $3, $4::jsonb, $5)`
+	args := []interface{}{saved.Name, saved.Description, saved.Query, string(parameters), saved.PermissionTags}
+	if overwrite {
+		query += ` ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description, query = EXCLUDED.query,
+			parameters = EXCLUDED.parameters, permission_tags = EXCLUDED.permission_tags, updated_at = now()
+			WHERE q.permission_tags IS NOT DISTINCT FROM $6::text[]`
+		args = append(args, existingTags)
+	} else {
+		query += ` ON CONFLICT (name) DO NOTHING`
+	}
+	query += ` RETURNING updated_at`
+	err = db.QueryRow(ctx, query, args...).Scan(&saved.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) && overwrite {
+		return Error(fmt.Sprintf("Saved query '%s' changed while it was being replaced: try again", saved.Name), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "name",
+		}), nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Error(fmt.Sprintf("Saved query '%s' already exists: set overwrite to replace it", saved.Name), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "name",
+		}), nil
+	}
+	if err != nil {
+		return savedQueryError("create", saved.Name, err), nil
+	}
+
+	t.logger.Info("Saved query created", map[string]interface{}{
+		"name":            saved.Name,
+		"parameters":      len(saved.Parameters),
+		"permission_tags": saved.PermissionTags,
+	})
+	return Success(saved, map[string]interface{}{"overwrite": overwrite}), nil
+}
+
+// parseSavedQuery validates the definition of a saved query
+func parseSavedQuery(params map[string]interface{}) (*SavedQuery, *ToolResult) {
+	saved := &SavedQuery{
+		Name:           stringParam(params, "name", ""),
+		Description:    stringParam(params, "description", ""),
+		Query:          strings.TrimSpace(stringParam(params, "query", "")),
+		Parameters:     []SavedQueryParameter{},
+		PermissionTags: []string{},
+	}
+	if !savedQueryNameRe.MatchString(saved.Name) {
+		return nil, Error(fmt.Sprintf("Invalid name '%s': use lowercase letters, digits and underscores, starting with a letter", saved.Name), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "name",
+		})
+	}
+	if err := database.CheckReadOnlyQuery(saved.Query); err != nil {
+		return nil, Error(fmt.Sprintf("query rejected: %v", err), "VALIDATION_ERROR", map[string]interface{}{"parameter": "query"})
+	}
+	// The query is wrapped in a subquery when it runs, which a trailing
+	// semicolon would end
+	saved.Query = strings.TrimSpace(strings.TrimRight(saved.Query, "; \t\r\n"))
+
+	if raw, ok := params["parameters"]; ok && raw != nil {
+		// The declarations were checked against the input schema, so they
+		// decode into parameters
+		encoded, err := json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(encoded, &saved.Parameters)
+		}
+		if err != nil {
+			return nil, Error(fmt.Sprintf("Invalid parameters: %v", err), "VALIDATION_ERROR", map[string]interface{}{"parameter": "parameters"})
+		}
+	}
+	seen := map[string]bool{}
+	for i, p := range saved.Parameters {
+		invalid := func(format string, args ...interface{}) *ToolResult {
+			return Error(fmt.Sprintf("parameters[%d]: ", i)+fmt.Sprintf(format, args...), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "parameters",
+				"index":     i,
+			})
+		}
+		if !savedQueryNameRe.MatchString(p.Name) {
+			return nil, invalid("invalid name '%s'", p.Name)
+		}
+		if seen[p.Name] {
+			return nil, invalid("duplicate name '%s'", p.Name)
+		}
+		seen[p.Name] = true
+		if !savedQueryTypes[p.Type] {
+			return nil, invalid("unsupported type '%s'", p.Type)
+		}
+		if p.Default != nil {
+			if p.Required {
+				return nil, invalid("a required parameter cannot have a default")
+			}
+			if _, err := savedQueryArgument(p, p.Default); err != nil {
+				return nil, invalid("default: %v", err)
+			}
+		}
+	}
+
+	highest, err := database.MaxQueryParameter(saved.Query)
+	if err != nil {
+		return nil, Error(fmt.Sprintf("query rejected: %v", err), "VALIDATION_ERROR", map[string]interface{}{"parameter": "query"})
+	}
+	if highest > len(saved.Parameters) {
+		return nil, Error(fmt.Sprintf("query uses $%d but declares %d parameters", highest, len(saved.Parameters)), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "parameters",
+		})
+	}
+
+	tags, _ := params["permission_tags"].([]interface{})
+	for i, raw := range tags {
+		tag, _ := raw.(string)
+		if !savedQueryNameRe.MatchString(tag) {
+			return nil, Error(fmt.Sprintf("permission_tags[%d]: invalid tag '%v'", i, raw), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "permission_tags",
+			})
+		}
+		saved.PermissionTags = append(saved.PermissionTags, tag)
+	}
+	sort.Strings(saved.PermissionTags)
+	return saved, nil
+}
+
+// savedQueryArgument converts the JSON value of a parameter to the value
+// bound to its placeholder
+func savedQueryArgument(p SavedQueryParameter, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if element, ok := strings.CutSuffix(p.Type, "[]"); ok {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("must be an array, got %T", value)
+		}
+		switch element {
+		case "text":
+			out := make([]string, len(items))
+			for i, item := range items {
+				s, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("item %d must be a string, got %T", i, item)
+				}
+				out[i] = s
+			}
+			return out, nil
+		case "integer":
+			out := make([]int64, len(items))
+			for i, item := range items {
+				n, ok := item.(float64)
+				if !ok || n != math.Trunc(n) {
+					return nil, fmt.Errorf("item %d must be an integer, got %v", i, item)
+				}
+				out[i] = int64(n)
+			}
+			return out, nil
+		default:
+			out := make([]float64, len(items))
+			for i, item := range items {
+				n, ok := item.(float64)
+				if !ok {
+					return nil, fmt.Errorf("item %d must be a number, got %T", i, item)
+				}
+				out[i] = n
+			}
+			return out, nil
+		}
+	}
+
+	switch p.Type {
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return nil, fmt.Errorf("must be an integer, got %v", value)
+		}
+		return int64(n), nil
+	case "number":
+		n, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("must be a number, got %T", value)
+		}
+		return n, nil
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be a boolean, got %T", value)
+		}
+		return b, nil
+	}
+
+	// The remaining types are passed as text, which PostgreSQL parses as
+	// the type the placeholder has in the query
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("must be a string, got %T", value)
+	}
+	switch p.Type {
+	case "date":
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return nil, fmt.Errorf("must be a date as YYYY-MM-DD, got %q", s)
+		}
+	case "timestamp":
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			return nil, fmt.Errorf("must be an RFC 3339 timestamp, got %q", s)
+		}
+	case "uuid":
+		if !savedQueryUUIDRe.MatchString(s) {
+			return nil, fmt.Errorf("must be a UUID, got %q", s)
+		}
+	}
+	return s, nil
+}
+
+// savedQueryArguments binds the arguments of a call to the placeholders of
+// a saved query
+func savedQueryArguments(saved *SavedQuery, arguments map[string]interface{}) ([]interface{}, *ToolResult) {
+	declared := map[string]bool{}
+	for _, p := range saved.Parameters {
+		declared[p.Name] = true
+	}
+	for name := range arguments {
+		if !declared[name] {
+			return nil, Error(fmt.Sprintf("Saved query '%s' has no parameter '%s'", saved.Name, name), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "params",
+				"name":      name,
+			})
+		}
+	}
+
+	args := make([]interface{}, len(saved.Parameters))
+	for i, p := range saved.Parameters {
+		value, ok := arguments[p.Name]
+		if !ok {
+			if p.Required {
+				return nil, Error(fmt.Sprintf("Saved query '%s' requires parameter '%s'", saved.Name, p.Name), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": "params",
+					"name":      p.Name,
+				})
+			}
+			value = p.Default
+		}
+		arg, err := savedQueryArgument(p, value)
+		if err != nil {
+			return nil, Error(fmt.Sprintf("Parameter '%s' of saved query '%s' %v", p.Name, saved.Name, err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "params",
+				"name":      p.Name,
+			})
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// ensureSavedQueriesTable creates the saved queries table
+func ensureSavedQueriesTable(ctx context.Context, db *database.Database) error {
+	_, err := db.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS neurondb_mcp;
+		CREATE TABLE IF NOT EXISTS `+savedQueriesTable+` (
+			name text PRIMARY KEY,
+			description text NOT NULL DEFAULT '',
+			query text NOT NULL,
+			parameters jsonb NOT NULL DEFAULT '[]',
+			permission_tags text[] NOT NULL DEFAULT '{}',
+			created_at timestamptz NOT NULL DEFAULT now(),
+			updated_at timestamptz NOT NULL DEFAULT now()
+		)`)
+	return err
+}
+
+// savedQueriesExist reports whether the saved queries table has been created
+func savedQueriesExist(ctx context.Context, db *database.Database) (bool, error) {
+	var exists bool
+	err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", savedQueriesTable).Scan(&exists)
+	return exists, err
+}
+
+// loadSavedQueries returns the saved queries named name, or all of them
+// when name is empty, in name order
+func loadSavedQueries(ctx context.Context, db *database.Database, name string) ([]*SavedQuery, error) {
+	exists, err := savedQueriesExist(ctx, db)
+	if err != nil || !exists {
+		return nil, err
+	}
+	rows, err := db.Query(ctx, `SELECT name, description, query, parameters::text, permission_tags, updated_at
+		FROM `+savedQueriesTable+` WHERE $1 = '' OR name = $1 ORDER BY name`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var saved []*SavedQuery
+	for rows.Next() {
+		q := &SavedQuery{}
+		var parameters string
+		if err := rows.Scan(&q.Name, &q.Description, &q.Query, &parameters, &q.PermissionTags, &q.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(parameters), &q.Parameters); err != nil {
+			return nil, fmt.Errorf("saved query %s has invalid parameters: %w", q.Name, err)
+		}
+		saved = append(saved, q)
+	}
+	return saved, rows.Err()
+}
+
+// savedQueryDenied reports a saved query whose permission tags the caller
+// may not use, or returns nil
+func savedQueryDenied(ctx context.Context, logger *logging.Logger, name string, tags []string) *ToolResult {
+	err := authorizeQueryTags(ctx, tags)
+	if err == nil {
+		return nil
+	}
+	logger.Warn("Saved query denied by policy", map[string]interface{}{
+		"name":            name,
+		"permission_tags": tags,
+	})
+	return Error(fmt.Sprintf("Saved query '%s' denied: %v", name, err), "PERMISSION_DENIED", map[string]interface{}{
+		"name":            name,
+		"permission_tags": tags,
+	})
+}
+
+// savedQueryError reports a failed saved query operation
+func savedQueryError(operation, name string, err error) *ToolResult {
+	return Error(fmt.Sprintf("Saved query %s failed: name='%s', error=%v", operation, name, err), "DATABASE_ERROR", map[string]interface{}{
+		"name":  name,
+		"error": err.Error(),
+	})
+}
+
+// ListSavedQueriesTool lists the saved queries the caller may run
+type ListSavedQueriesTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewListSavedQueriesTool creates a new list saved queries tool
+func NewListSavedQueriesTool(db *database.Database, logger *logging.Logger) *ListSavedQueriesTool {
+	return &ListSavedQueriesTool{
+		BaseTool: NewBaseTool(
+			"list_saved_queries",
+			"List the saved queries the caller may run, with their descriptions, SQL, parameters and permission tags",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tag": map[string]interface{}{
+						"type":        "string",
+						"description": "Only list queries carrying this permission tag",
+					},
+				},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute lists the saved queries
+func (t *ListSavedQueriesTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for list_saved_queries tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	tag := stringParam(params, "tag", "")
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+	all, err := loadSavedQueries(ctx, db, "")
+	if err != nil {
+		return savedQueryError("list", "", err), nil
+	}
+
+	queries := make([]*SavedQuery, 0, len(all))
+	hidden := 0
+	for _, q := range all {
+		if tag != "" && !slices.Contains(q.PermissionTags, tag) {
+			continue
+		}
+		if authorizeQueryTags(ctx, q.PermissionTags) != nil {
+			hidden++
+			continue
+		}
+		queries = append(queries, q)
+	}
+	return Success(map[string]interface{}{
+		"queries": queries,
+		"count":   len(queries),
+	}, map[string]interface{}{
+		"hidden": hidden,
+	}), nil
+}
+
+// ExecuteSavedQueryTool runs a saved query
+type ExecuteSavedQueryTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewExecuteSavedQueryTool creates a new execute saved query tool
+func NewExecuteSavedQueryTool(db *database.Database, logger *logging.Logger) *ExecuteSavedQueryTool {
+	return &ExecuteSavedQueryTool{
+		BaseTool: NewBaseTool(
+			"execute_saved_query",
+			"Run a saved query by name with arguments for its declared parameters, in a READ ONLY transaction with a statement timeout and a row limit",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the saved query, as listed by list_saved_queries",
+					},
+					"params": map[string]interface{}{
+						"type":        "object",
+						"description": "Arguments by parameter name",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultReadOnlyLimit,
+						"minimum":     1,
+						"maximum":     maxReadOnlyLimit,
+						"description": "Maximum number of rows returned",
+					},
+					"timeout_ms": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultReadOnlyTimeoutMs,
+						"minimum":     1,
+						"maximum":     maxReadOnlyTimeoutMs,
+						"description": "statement_timeout of the query in milliseconds",
+					},
+				},
+				"required": []interface{}{"name"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute binds the arguments and runs the saved query
+func (t *ExecuteSavedQueryTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for execute_saved_query tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	name := stringParam(params, "name", "")
+	limit, errResult := intParamInRange(params, "limit", defaultReadOnlyLimit, 1, maxReadOnlyLimit)
+	if errResult != nil {
+		return errResult, nil
+	}
+	timeoutMs, errResult := intParamInRange(params, "timeout_ms", defaultReadOnlyTimeoutMs, 1, maxReadOnlyTimeoutMs)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+	found, err := loadSavedQueries(ctx, db, name)
+	if err != nil {
+		return savedQueryError("lookup", name, err), nil
+	}
+	if len(found) == 0 {
+		return Error(fmt.Sprintf("Saved query '%s' not found", name), "NOT_FOUND", map[string]interface{}{"name": name}), nil
+	}
+	saved := found[0]
+	if denied := savedQueryDenied(ctx, t.logger, name, saved.PermissionTags); denied != nil {
+		return denied, nil
+	}
+
+	arguments, _ := params["params"].(map[string]interface{})
+	args, errResult := savedQueryArguments(saved, arguments)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	start := time.Now()
+	rows, err := runReadOnlyQuery(ctx, db, saved.Query, args, limit, timeoutMs)
+	if err != nil {
+		if result := readOnlyQueryFailure(err, timeoutMs); result != nil {
+			return result, nil
+		}
+		t.logger.Error("Saved query failed", err, map[string]interface{}{"name": name})
+		return Error(fmt.Sprintf("Saved query '%s' failed: %v", name, err), "QUERY_ERROR", map[string]interface{}{
+			"name":  name,
+			"error": err.Error(),
+		}), nil
+	}
+
+	truncated := len(rows) > limit
+	if truncated {
+		rows = rows[:limit]
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	return Success(map[string]interface{}{
+		"rows":      rows,
+		"row_count": len(rows),
+		"truncated": truncated,
+	}, map[string]interface{}{
+		"name":       name,
+		"elapsed_ms": msSince(start),
+		"limit":      limit,
+		"timeout_ms": timeoutMs,
+	}), nil
+}
+
+// DeleteSavedQueryTool deletes a saved query
+type DeleteSavedQueryTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewDeleteSavedQueryTool creates a new delete saved query tool
+func NewDeleteSavedQueryTool(db *database.Database, logger *logging.Logger) *DeleteSavedQueryTool {
+	return &DeleteSavedQueryTool{
+		BaseTool: NewBaseTool(
+			"delete_saved_query",
+			"Delete a saved query by name",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the saved query",
+					},
+				},
+				"required": []interface{}{"name"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute deletes the saved query
+func (t *DeleteSavedQueryTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for delete_saved_query tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	name := stringParam(params, "name", "")
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+	found, err := loadSavedQueries(ctx, db, name)
+	if err != nil {
+		return savedQueryError("delete", name, err), nil
+	}
+	if len(found) == 0 {
+		return Error(fmt.Sprintf("Saved query '%s' not found", name), "NOT_FOUND", map[string]interface{}{"name": name}), nil
+	}
+	// A caller may only delete a query it could run, and only while the
+	// query still has the tags checked here
+	tags := found[0].PermissionTags
+	if denied := savedQueryDenied(ctx, t.logger, name, tags); denied != nil {
+		return denied, nil
+	}
+	tag, err := db.Exec(ctx, "DELETE FROM "+savedQueriesTable+" WHERE name = $1 AND permission_tags = $2", name, tags)
+	if err != nil {
+		return savedQueryError("delete", name, err), nil
+	}
+	if tag.RowsAffected() == 0 {
+		return Error(fmt.Sprintf("Saved query '%s' changed while it was being deleted: try again", name), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "name",
+		}), nil
+	}
+
+	t.logger.Info("Saved query deleted", map[string]interface{}{"name": name})
+	return Success(map[string]interface{}{"name": name, "deleted": true}, nil), nil
+}
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+func TestParseSavedQuery(t *testing.T) {
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"name":  "orders_by_customer",
+			"query": "SELECT * FROM orders WHERE customer_id = $1 AND placed_at >= $2;",
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "customer_id", "type": "integer", "required": true},
+				map[string]interface{}{"name": "since", "type": "date", "default": "2024-01-01"},
+			},
+			"permission_tags": []interface{}{"sales", "finance"},
+		}
+	}
+
+	saved, invalid := parseSavedQuery(base())
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if saved.Query != "SELECT * FROM orders WHERE customer_id = $1 AND placed_at >= $2" {
+		t.Errorf("query = %q", saved.Query)
+	}
+	if !reflect.DeepEqual(saved.PermissionTags, []string{"finance", "sales"}) {
+		t.Errorf("permission tags = %v", saved.PermissionTags)
+	}
+	if len(saved.Parameters) != 2 || saved.Parameters[1].Default != "2024-01-01" {
+		t.Errorf("parameters = %+v", saved.Parameters)
+	}
+
+	for name, change := range map[string]func(map[string]interface{}){
+		"name":          func(p map[string]interface{}) { p["name"] = "Orders" },
+		"write query":   func(p map[string]interface{}) { p["query"] = "DELETE FROM orders WHERE id = $1" },
+		"undeclared $3": func(p map[string]interface{}) { p["query"] = "SELECT $1::int, $2::date, $3::text" },
+		"duplicate param": func(p map[string]interface{}) {
+			p["parameters"].([]interface{})[1].(map[string]interface{})["name"] = "customer_id"
+		},
+		"unknown type": func(p map[string]interface{}) {
+			p["parameters"].([]interface{})[0].(map[string]interface{})["type"] = "money"
+		},
+		"bad default": func(p map[string]interface{}) {
+			p["parameters"].([]interface{})[1].(map[string]interface{})["default"] = "yesterday"
+		},
+		"required default": func(p map[string]interface{}) {
+			p["parameters"].([]interface{})[0].(map[string]interface{})["default"] = float64(1)
+		},
+		"tag": func(p map[string]interface{}) { p["permission_tags"] = []interface{}{"has space"} },
+	} {
+		params := base()
+		change(params)
+		if _, invalid := parseSavedQuery(params); invalid == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestSavedQueryArgument(t *testing.T) {
+	valid := []struct {
+		typ   string
+		value interface{}
+		want  interface{}
+	}{
+		{"integer", float64(42), int64(42)},
+		{"number", 1.5, 1.5},
+		{"boolean", true, true},
+		{"text", "abc", "abc"},
+		{"date", "2024-02-29", "2024-02-29"},
+		{"timestamp", "2024-02-29T12:30:00Z", "2024-02-29T12:30:00Z"},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", "123e4567-e89b-12d3-a456-426614174000"},
+		{"text[]", []interface{}{"a", "b"}, []string{"a", "b"}},
+		{"integer[]", []interface{}{float64(1), float64(2)}, []int64{1, 2}},
+		{"number[]", []interface{}{0.5}, []float64{0.5}},
+		{"integer", nil, nil},
+	}
+	for _, tc := range valid {
+		got, err := savedQueryArgument(SavedQueryParameter{Name: "p", Type: tc.typ}, tc.value)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %v: got %#v, %v; want %#v", tc.typ, tc.value, got, err, tc.want)
+		}
+	}
+
+	invalid := []struct {
+		typ   string
+		value interface{}
+	}{
+		{"integer", 1.5},
+		{"integer", "1"},
+		{"boolean", "true"},
+		{"date", "2024-02-30"},
+		{"timestamp", "2024-02-29 12:30"},
+		{"uuid", "not-a-uuid"},
+		{"text[]", "a"},
+		{"integer[]", []interface{}{float64(1), "2"}},
+	}
+	for _, tc := range invalid {
+		if _, err := savedQueryArgument(SavedQueryParameter{Name: "p", Type: tc.typ}, tc.value); err == nil {
+			t.Errorf("%s %#v: expected an error", tc.typ, tc.value)
+		}
+	}
+}
+
+func TestSavedQueryArguments(t *testing.T) {
+	saved := &SavedQuery{
+		Name: "orders_by_customer",
+		Parameters: []SavedQueryParameter{
+			{Name: "customer_id", Type: "integer", Required: true},
+			{Name: "since", Type: "date", Default: "2024-01-01"},
+			{Name: "status", Type: "text"},
+		},
+	}
+
+	args, invalid := savedQueryArguments(saved, map[string]interface{}{"customer_id": float64(7)})
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if want := []interface{}{int64(7), "2024-01-01", nil}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+
+	for name, arguments := range map[string]map[string]interface{}{
+		"missing required": {"since": "2024-06-01"},
+		"unknown":          {"customer_id": float64(7), "region": "eu"},
+		"wrong type":       {"customer_id": "seven"},
+	} {
+		if _, invalid := savedQueryArguments(saved, arguments); invalid == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestAuthorizeQueryTags(t *testing.T) {
+	if err := authorizeQueryTags(context.Background(), []string{"finance"}); err != nil {
+		t.Errorf("without an authorizer every query may run, got %v", err)
+	}
+	ctx := WithQueryTagAuthorizer(context.Background(), func(tags []string) error {
+		for _, tag := range tags {
+			if tag == "finance" {
+				return errors.New("denied")
+			}
+		}
+		return nil
+	})
+	if err := authorizeQueryTags(ctx, []string{"sales"}); err != nil {
+		t.Errorf("sales: unexpected error %v", err)
+	}
+	if err := authorizeQueryTags(ctx, []string{"sales", "finance"}); err == nil {
+		t.Error("finance: expected a denial")
+	}
+}
+
+// denyFinance is a query tag authorizer that denies the finance tag
+func denyFinance(ctx context.Context) context.Context {
+	return WithQueryTagAuthorizer(ctx, func(tags []string) error {
+		for _, tag := range tags {
+			if tag == "finance" {
+				return errors.New("denied")
+			}
+		}
+		return nil
+	})
+}
+
+func TestCreateSavedQueryDeniesTags(t *testing.T) {
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	tool := NewCreateSavedQueryTool(nil, logger)
+	params := map[string]interface{}{
+		"name":            "revenue",
+		"query":           "SELECT 1",
+		"permission_tags": []interface{}{"finance"},
+		"overwrite":       true,
+	}
+
+	result, _ := tool.Execute(denyFinance(context.Background()), params)
+	if result.Success || result.Error.Code != "PERMISSION_DENIED" {
+		t.Fatalf("storing a query the caller may not run: %+v, want PERMISSION_DENIED", result.Error)
+	}
+
+	// Allowed tags get past the check to the missing database
+	params["permission_tags"] = []interface{}{"sales"}
+	result, _ = tool.Execute(denyFinance(context.Background()), params)
+	if result.Success || result.Error.Code != "DATABASE_ERROR" {
+		t.Fatalf("allowed tags: %+v, want DATABASE_ERROR", result.Error)
+	}
+}
+
+func TestSavedQueryDenied(t *testing.T) {
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	ctx := denyFinance(context.Background())
+
+	// Overwrites and deletes check the tags of the stored row with this
+	if denied := savedQueryDenied(ctx, logger, "revenue", []string{"finance", "sales"}); denied == nil || denied.Error.Code != "PERMISSION_DENIED" {
+		t.Errorf("finance row: got %+v, want PERMISSION_DENIED", denied)
+	}
+	if denied := savedQueryDenied(ctx, logger, "orders", []string{"sales"}); denied != nil {
+		t.Errorf("sales row: unexpected denial %+v", denied.Error)
+	}
+	if denied := savedQueryDenied(context.Background(), logger, "revenue", []string{"finance"}); denied != nil {
+		t.Errorf("without an authorizer: unexpected denial %+v", denied.Error)
+	}
+}
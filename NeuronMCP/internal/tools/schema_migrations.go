@@ -0,0 +1,630 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// schemaMigrationsTable records the migrations applied by manage_schema. It
+// is created by the first migration applied.
+const schemaMigrationsTable = "neurondb_mcp.schema_migrations"
+
+// manage_schema limits
+const (
+	maxMigrationStatements       = 100
+	defaultMigrationHistoryLimit = 50
+	maxMigrationHistoryLimit     = 1000
+)
+
+// migrationPreviewLockTimeout bounds the wait for locks while a dry run
+// previews a migration, so a preview does not queue behind long
+// transactions
+const migrationPreviewLockTimeout = "5s"
+
+// migrationIDRe matches migration IDs, such as 2024_06_01_add_docs
+var migrationIDRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// SchemaMigration is a migration applied by manage_schema
+type SchemaMigration struct {
+	ID          string    `json:"migration_id"`
+	Description string    `json:"description"`
+	Checksum    string    `json:"checksum"`
+	Statements  []string  `json:"statements"`
+	Destructive bool      `json:"destructive"`
+	Forced      bool      `json:"forced"`
+	AppliedBy   string    `json:"applied_by"`
+	AppliedAt   time.Time `json:"applied_at"`
+	DurationMs  float64   `json:"duration_ms"`
+}
+
+// migrationStatement is a statement of a migration with what it changes
+type migrationStatement struct {
+	SQL         string `json:"sql"`
+	Command     string `json:"command"`
+	Destructive bool   `json:"destructive"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// SchemaChange is an object a migration adds, removes or changes, as a dry
+// run finds it in the catalog
+type SchemaChange struct {
+	// Change is added, removed or changed
+	Change string `json:"change"`
+	// Kind is schema, table, view, materialized view, sequence, foreign
+	// table, column, index, constraint or function
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// schemaObject identifies an object of a schema snapshot
+type schemaObject struct {
+	kind string
+	name string
+}
+
+// ManageSchemaTool applies schema changes once, recording each migration
+// with a checksum of its statements
+type ManageSchemaTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewManageSchemaTool creates a new manage schema tool
+func NewManageSchemaTool(db *database.Database, logger *logging.Logger) *ManageSchemaTool {
+	return &ManageSchemaTool{
+		BaseTool: NewBaseTool(
+			"manage_schema",
+			"Evolve the database schema safely: apply a named migration of DDL statements once, in one transaction, recording it with a checksum so repeated calls are no-ops; refuse destructive statements unless forced; preview the resulting schema diff with dry_run; and list the migration history",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"apply", "history"},
+						"default":     "apply",
+						"description": "apply runs the migration unless it was applied already; history lists the applied migrations, newest first",
+					},
+					"migration_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique name of the migration, such as 2024_06_01_add_docs: letters, digits, '_', '.' and '-'",
+					},
+					"statements": map[string]interface{}{
+						"type":        "array",
+						"minItems":    1,
+						"maxItems":    maxMigrationStatements,
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "CREATE, ALTER, DROP, COMMENT, GRANT, REVOKE or TRUNCATE statements, one per item, run in order in one transaction",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "What the migration changes, kept in the history",
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Apply destructive statements: drops, truncations and column type changes",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultMigrationHistoryLimit,
+						"minimum":     1,
+						"maximum":     maxMigrationHistoryLimit,
+						"description": "Migrations listed by history",
+					},
+				},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute applies the migration or lists the history
+func (t *ManageSchemaTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for manage_schema tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+		}), nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if stringParam(params, "action", "apply") == "history" {
+		limit, errResult := intParamInRange(params, "limit", defaultMigrationHistoryLimit, 1, maxMigrationHistoryLimit)
+		if errResult != nil {
+			return errResult, nil
+		}
+		if db == nil || !db.IsConnected() {
+			return Error("database connection not available for manage_schema tool", "DATABASE_ERROR", nil), nil
+		}
+		return t.history(ctx, db, limit), nil
+	}
+
+	migration, statements, errResult := parseSchemaMigration(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+	force, _ := params["force"].(bool)
+	migration.Forced = force && migration.Destructive
+
+	if IsDryRun(ctx) {
+		return t.plan(ctx, db, migration, statements, force), nil
+	}
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for manage_schema tool", "DATABASE_ERROR", nil), nil
+	}
+
+	applied, err := loadSchemaMigration(ctx, db, migration.ID)
+	if err != nil {
+		return schemaMigrationError(migration.ID, err), nil
+	}
+	if applied != nil {
+		return appliedMigrationResult(applied, migration), nil
+	}
+	if migration.Destructive && !force {
+		return destructiveMigrationError(migration.ID, statements), nil
+	}
+
+	if err := ensureSchemaMigrationsTable(ctx, db); err != nil {
+		return schemaMigrationError(migration.ID, err), nil
+	}
+	recorded, err := applySchemaMigration(ctx, db, migration)
+	if err != nil {
+		t.logger.Error("Schema migration failed", err, map[string]interface{}{
+			"migration_id": migration.ID,
+			"statements":   len(migration.Statements),
+		})
+		return schemaMigrationError(migration.ID, err), nil
+	}
+	if !recorded {
+		// Another call applied the migration meanwhile
+		applied, err := loadSchemaMigration(ctx, db, migration.ID)
+		if err != nil || applied == nil {
+			return schemaMigrationError(migration.ID, fmt.Errorf("migration was applied concurrently but could not be read: %v", err)), nil
+		}
+		return appliedMigrationResult(applied, migration), nil
+	}
+
+	t.logger.Info("Schema migration applied", map[string]interface{}{
+		"migration_id": migration.ID,
+		"statements":   len(migration.Statements),
+		"destructive":  migration.Destructive,
+		"duration_ms":  migration.DurationMs,
+	})
+	return Success(map[string]interface{}{
+		"migration_id": migration.ID,
+		"status":       "applied",
+		"applied":      true,
+		"checksum":     migration.Checksum,
+		"statements":   statements,
+		"destructive":  migration.Destructive,
+		"forced":       migration.Forced,
+		"applied_by":   migration.AppliedBy,
+		"applied_at":   migration.AppliedAt,
+		"duration_ms":  migration.DurationMs,
+	}, map[string]interface{}{
+		"statements": len(statements),
+	}), nil
+}
+
+// parseSchemaMigration validates the migration of a call and classifies its
+// statements
+func parseSchemaMigration(params map[string]interface{}) (*SchemaMigration, []migrationStatement, *ToolResult) {
+	migration := &SchemaMigration{
+		ID:          stringParam(params, "migration_id", ""),
+		Description: stringParam(params, "description", ""),
+	}
+	if !migrationIDRe.MatchString(migration.ID) {
+		return nil, nil, Error(fmt.Sprintf("Invalid migration_id '%s': use up to 128 letters, digits, '_', '.' and '-'", migration.ID), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "migration_id",
+		})
+	}
+	items, _ := params["statements"].([]interface{})
+	if len(items) == 0 {
+		return nil, nil, Error("statements is required to apply a migration", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "statements",
+		})
+	}
+
+	statements := make([]migrationStatement, 0, len(items))
+	for i, item := range items {
+		sql, _ := item.(string)
+		sql = strings.TrimSpace(sql)
+		ddl, err := database.CheckDDLStatement(sql)
+		if err != nil {
+			return nil, nil, Error(fmt.Sprintf("statements[%d] rejected: %v", i, err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "statements",
+				"index":     i,
+			})
+		}
+		statements = append(statements, migrationStatement{
+			SQL:         sql,
+			Command:     ddl.Command,
+			Destructive: ddl.Destructive,
+			Reason:      ddl.Reason,
+		})
+		migration.Statements = append(migration.Statements, sql)
+		migration.Destructive = migration.Destructive || ddl.Destructive
+	}
+	migration.Checksum = migrationChecksum(migration.Statements)
+	return migration, statements, nil
+}
+
+// migrationChecksum is the SHA-256 of the statements, ignoring surrounding
+// whitespace and trailing semicolons, so a migration sent again with the
+// same statements matches the one applied
+func migrationChecksum(statements []string) string {
+	h := sha256.New()
+	for _, stmt := range statements {
+		h.Write([]byte(strings.TrimRight(strings.TrimSpace(stmt), "; \t\r\n")))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// appliedMigrationResult answers a call for a migration already applied:
+// a no-op when the statements match, an error when the ID was reused for
+// other statements
+func appliedMigrationResult(applied, migration *SchemaMigration) *ToolResult {
+	if applied.Checksum != migration.Checksum {
+		return Error(fmt.Sprintf("Migration '%s' was applied at %s with other statements (checksum %s, got %s): give the new statements a new migration_id",
+			applied.ID, applied.AppliedAt.Format(time.RFC3339), applied.Checksum, migration.Checksum), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter":          "statements",
+			"migration_id":       applied.ID,
+			"applied_checksum":   applied.Checksum,
+			"checksum":           migration.Checksum,
+			"applied_statements": applied.Statements,
+		})
+	}
+	return Success(map[string]interface{}{
+		"migration_id": applied.ID,
+		"status":       "already_applied",
+		"applied":      false,
+		"checksum":     applied.Checksum,
+		"destructive":  applied.Destructive,
+		"forced":       applied.Forced,
+		"applied_by":   applied.AppliedBy,
+		"applied_at":   applied.AppliedAt,
+		"duration_ms":  applied.DurationMs,
+	}, nil)
+}
+
+// destructiveMigrationError refuses a migration with destructive statements
+// applied without force
+func destructiveMigrationError(id string, statements []migrationStatement) *ToolResult {
+	var destructive []map[string]interface{}
+	for i, stmt := range statements {
+		if stmt.Destructive {
+			destructive = append(destructive, map[string]interface{}{
+				"index":  i,
+				"sql":    stmt.SQL,
+				"reason": stmt.Reason,
+			})
+		}
+	}
+	return Error(fmt.Sprintf("Migration '%s' has %d destructive statements: review them, preview the change with dry_run, and set force to apply them", id, len(destructive)), "VALIDATION_ERROR", map[string]interface{}{
+		"parameter":   "force",
+		"destructive": destructive,
+	})
+}
+
+func schemaMigrationError(id string, err error) *ToolResult {
+	return Error(fmt.Sprintf("Schema migration failed: migration_id='%s', error=%v", id, err), "DATABASE_ERROR", map[string]interface{}{
+		"migration_id": id,
+		"error":        err.Error(),
+	})
+}
+
+func ensureSchemaMigrationsTable(ctx context.Context, db *database.Database) error {
+	_, err := db.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS neurondb_mcp;
+		CREATE TABLE IF NOT EXISTS `+schemaMigrationsTable+` (
+			migration_id text PRIMARY KEY,
+			description text NOT NULL DEFAULT '',
+			checksum text NOT NULL,
+			statements text[] NOT NULL,
+			destructive boolean NOT NULL DEFAULT false,
+			forced boolean NOT NULL DEFAULT false,
+			applied_by text NOT NULL DEFAULT current_user,
+			applied_at timestamptz NOT NULL DEFAULT now(),
+			duration_ms double precision NOT NULL DEFAULT 0
+		)`)
+	return err
+}
+
+const schemaMigrationColumns = `migration_id, description, checksum, statements, destructive, forced, applied_by, applied_at, duration_ms`
+
+func scanSchemaMigration(row pgx.Row) (*SchemaMigration, error) {
+	var m SchemaMigration
+	if err := row.Scan(&m.ID, &m.Description, &m.Checksum, &m.Statements, &m.Destructive, &m.Forced, &m.AppliedBy, &m.AppliedAt, &m.DurationMs); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// loadSchemaMigration returns the applied migration id, or nil when it has
+// not been applied
+func loadSchemaMigration(ctx context.Context, db *database.Database, id string) (*SchemaMigration, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", schemaMigrationsTable).Scan(&exists); err != nil || !exists {
+		return nil, err
+	}
+	m, err := scanSchemaMigration(db.QueryRow(ctx, `SELECT `+schemaMigrationColumns+` FROM `+schemaMigrationsTable+` WHERE migration_id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return m, err
+}
+
+// applySchemaMigration runs the statements of a migration in one
+// transaction. The migration is recorded first, so a concurrent call for
+// the same migration waits on the record and finds it applied; it returns
+// false when that happened to this call. A failed statement rolls back the
+// whole migration, record included.
+func applySchemaMigration(ctx context.Context, db *database.Database, m *SchemaMigration) (bool, error) {
+	started := time.Now()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	err = tx.QueryRow(queryCtx, `INSERT INTO `+schemaMigrationsTable+` (migration_id, description, checksum, statements, destructive, forced)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (migration_id) DO NOTHING
+		RETURNING applied_by, applied_at`,
+		m.ID, m.Description, m.Checksum, m.Statements, m.Destructive, m.Forced).Scan(&m.AppliedBy, &m.AppliedAt)
+	cancel()
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	total := float64(len(m.Statements))
+	reportProgress(ctx, 0, total, fmt.Sprintf("applying migration %s", m.ID))
+	for i, stmt := range m.Statements {
+		queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+		_, err := tx.Exec(queryCtx, stmt)
+		cancel()
+		if err != nil {
+			return false, fmt.Errorf("statements[%d] failed, nothing was applied: %w", i, err)
+		}
+		reportProgress(ctx, float64(i+1), total, fmt.Sprintf("applied %d of %d statements", i+1, len(m.Statements)))
+	}
+
+	m.DurationMs = msSince(started)
+	if _, err := tx.Exec(ctx, `UPDATE `+schemaMigrationsTable+` SET duration_ms = $2 WHERE migration_id = $1`, m.ID, m.DurationMs); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// history lists the applied migrations, newest first
+func (t *ManageSchemaTool) history(ctx context.Context, db *database.Database, limit int) *ToolResult {
+	migrations := []*SchemaMigration{}
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", schemaMigrationsTable).Scan(&exists); err != nil {
+		return schemaMigrationError("", err)
+	}
+	if exists {
+		rows, err := db.Query(ctx, `SELECT `+schemaMigrationColumns+` FROM `+schemaMigrationsTable+`
+			ORDER BY applied_at DESC, migration_id LIMIT $1`, limit)
+		if err != nil {
+			return schemaMigrationError("", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			m, err := scanSchemaMigration(rows)
+			if err != nil {
+				return schemaMigrationError("", err)
+			}
+			migrations = append(migrations, m)
+		}
+		if err := rows.Err(); err != nil {
+			return schemaMigrationError("", err)
+		}
+	}
+	return Success(map[string]interface{}{
+		"migrations": migrations,
+	}, map[string]interface{}{
+		"count": len(migrations),
+	})
+}
+
+// plan previews a migration: the statements with the record it would
+// write, whether it would be refused, and the schema diff it makes, found
+// by running it in a transaction that is rolled back
+func (t *ManageSchemaTool) plan(ctx context.Context, db *database.Database, m *SchemaMigration, statements []migrationStatement, force bool) *ToolResult {
+	planned := make([]PlannedStatement, 0, len(statements)+1)
+	zero, one := int64(0), int64(1)
+	for _, stmt := range statements {
+		p := PlannedStatement{SQL: stmt.SQL, EstimatedRows: &zero}
+		if stmt.Destructive {
+			p.Note = stmt.Reason
+		}
+		planned = append(planned, p)
+	}
+	record := PlannedStatement{
+		SQL:           `INSERT INTO ` + schemaMigrationsTable + ` (migration_id, description, checksum, statements, destructive, forced) VALUES ($1, $2, $3, $4, $5, $6)`,
+		Params:        []interface{}{m.ID, m.Description, m.Checksum, m.Statements, m.Destructive, m.Forced},
+		EstimatedRows: &one,
+		Note:          "records the migration in the same transaction",
+	}
+	planned = append([]PlannedStatement{record}, planned...)
+
+	var notes []string
+	status := "pending"
+	var diff []SchemaChange
+	if db != nil && db.IsConnected() {
+		applied, err := loadSchemaMigration(ctx, db, m.ID)
+		switch {
+		case err != nil:
+			notes = append(notes, fmt.Sprintf("migration history not checked: %v", err))
+		case applied != nil && applied.Checksum == m.Checksum:
+			status = "already_applied"
+			notes = append(notes, fmt.Sprintf("migration was applied at %s: the call is a no-op", applied.AppliedAt.Format(time.RFC3339)))
+		case applied != nil:
+			status = "checksum_mismatch"
+			notes = append(notes, fmt.Sprintf("migration was applied at %s with other statements (checksum %s): the call is refused", applied.AppliedAt.Format(time.RFC3339), applied.Checksum))
+		}
+		if status == "pending" {
+			diff, err = previewSchemaDiff(ctx, db, m.Statements)
+			if err != nil {
+				status = "fails"
+				notes = append(notes, fmt.Sprintf("the migration fails and would be rolled back: %v", err))
+			} else {
+				notes = append(notes, "the diff was found by running the statements in a transaction that was rolled back; comments and privileges are not compared")
+			}
+		}
+	}
+	if m.Destructive && !force && status == "pending" {
+		status = "refused"
+		notes = append(notes, "the migration has destructive statements: the call is refused unless force is true")
+	}
+
+	result := dryRunResult(ctx, db, t.Name(), planned, nil, notes...)
+	if data, ok := result.Data.(map[string]interface{}); ok {
+		if diff == nil {
+			diff = []SchemaChange{}
+		}
+		data["migration_id"] = m.ID
+		data["checksum"] = m.Checksum
+		data["status"] = status
+		data["destructive"] = m.Destructive
+		data["diff"] = diff
+	}
+	return result
+}
+
+// previewSchemaDiff runs the statements in a transaction it rolls back and
+// returns what they change in the catalog
+func previewSchemaDiff(ctx context.Context, db *database.Database, statements []string) ([]SchemaChange, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SET LOCAL lock_timeout = '"+migrationPreviewLockTimeout+"'"); err != nil {
+		return nil, err
+	}
+	before, err := snapshotSchema(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("schema snapshot failed: %w", err)
+	}
+	for i, stmt := range statements {
+		queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+		_, err := tx.Exec(queryCtx, stmt)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("statements[%d]: %w", i, err)
+		}
+	}
+	after, err := snapshotSchema(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("schema snapshot failed: %w", err)
+	}
+	return diffSchemaSnapshots(before, after), nil
+}
+
+// schemaSnapshotSQL lists the objects of user schemas with a definition
+// that changes when they do. Objects of extensions and of NeuronMCP's own
+// schema are left out.
+const schemaSnapshotSQL = `
+	WITH user_ns AS (
+		SELECT oid, nspname FROM pg_namespace
+		WHERE nspname NOT IN ('information_schema', 'neurondb_mcp') AND nspname NOT LIKE 'pg\_%'
+		  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_namespace'::regclass AND d.objid = pg_namespace.oid AND d.deptype = 'e')
+	), user_rel AS (
+		SELECT c.oid, c.relname, c.relkind, n.nspname FROM pg_class c JOIN user_ns n ON n.oid = c.relnamespace
+		WHERE NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'e')
+	)
+	SELECT 'schema', format('%I', nspname), '' FROM user_ns
+	UNION ALL
+	SELECT CASE relkind WHEN 'r' THEN 'table' WHEN 'p' THEN 'table' WHEN 'v' THEN 'view' WHEN 'm' THEN 'materialized view'
+			WHEN 'S' THEN 'sequence' ELSE 'foreign table' END,
+		format('%I.%I', nspname, relname),
+		CASE WHEN relkind IN ('v', 'm') THEN pg_get_viewdef(oid) ELSE '' END
+	FROM user_rel WHERE relkind IN ('r', 'p', 'v', 'm', 'S', 'f')
+	UNION ALL
+	SELECT 'column', format('%I.%I.%I', r.nspname, r.relname, a.attname),
+		format_type(a.atttypid, a.atttypmod) || CASE WHEN a.attnotnull THEN ' NOT NULL' ELSE '' END ||
+		COALESCE(' DEFAULT ' || pg_get_expr(ad.adbin, ad.adrelid), '')
+	FROM user_rel r JOIN pg_attribute a ON a.attrelid = r.oid
+	LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+	WHERE r.relkind IN ('r', 'p', 'v', 'm', 'f') AND a.attnum > 0 AND NOT a.attisdropped
+	UNION ALL
+	SELECT 'index', format('%I.%I', nspname, relname), pg_get_indexdef(oid) FROM user_rel WHERE relkind IN ('i', 'I')
+	UNION ALL
+	SELECT 'constraint', format('%I.%I.%I', r.nspname, r.relname, co.conname), pg_get_constraintdef(co.oid)
+	FROM pg_constraint co JOIN user_rel r ON r.oid = co.conrelid
+	UNION ALL
+	SELECT 'function', format('%I.%I(%s)', n.nspname, p.proname, pg_get_function_identity_arguments(p.oid)),
+		pg_get_function_result(p.oid) || ' ' || md5(p.prosrc)
+	FROM pg_proc p JOIN user_ns n ON n.oid = p.pronamespace
+	WHERE p.prokind IN ('f', 'p')
+	  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e')`
+
+// snapshotSchema reads the objects of the user schemas and their
+// definitions
+func snapshotSchema(ctx context.Context, tx pgx.Tx) (map[schemaObject]string, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	rows, err := tx.Query(queryCtx, schemaSnapshotSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	snapshot := make(map[schemaObject]string)
+	for rows.Next() {
+		var kind, name, definition string
+		if err := rows.Scan(&kind, &name, &definition); err != nil {
+			return nil, err
+		}
+		snapshot[schemaObject{kind: kind, name: name}] = definition
+	}
+	return snapshot, rows.Err()
+}
+
+// diffSchemaSnapshots returns the objects added, removed and changed
+// between two snapshots, ordered by kind and name
+func diffSchemaSnapshots(before, after map[schemaObject]string) []SchemaChange {
+	changes := []SchemaChange{}
+	for obj, definition := range after {
+		old, existed := before[obj]
+		switch {
+		case !existed:
+			changes = append(changes, SchemaChange{Change: "added", Kind: obj.kind, Name: obj.name, After: definition})
+		case old != definition:
+			changes = append(changes, SchemaChange{Change: "changed", Kind: obj.kind, Name: obj.name, Before: old, After: definition})
+		}
+	}
+	for obj, definition := range before {
+		if _, exists := after[obj]; !exists {
+			changes = append(changes, SchemaChange{Change: "removed", Kind: obj.kind, Name: obj.name, Before: definition})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
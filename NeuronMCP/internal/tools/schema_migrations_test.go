@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseSchemaMigration(t *testing.T) {
+	m, statements, errResult := parseSchemaMigration(map[string]interface{}{
+		"migration_id": "2024_06_01_docs",
+		"statements": []interface{}{
+			"CREATE TABLE docs (id bigint PRIMARY KEY, body text);",
+			"  ALTER TABLE docs DROP COLUMN body  ",
+		},
+	})
+	if errResult != nil {
+		t.Fatalf("parse: %v", errResult.Error)
+	}
+	if !m.Destructive || len(statements) != 2 || statements[0].Destructive || !statements[1].Destructive {
+		t.Errorf("migration = %+v, statements = %+v", m, statements)
+	}
+	if statements[1].SQL != "ALTER TABLE docs DROP COLUMN body" || statements[1].Reason != "drops the column" {
+		t.Errorf("statement = %+v", statements[1])
+	}
+
+	for name, params := range map[string]map[string]interface{}{
+		"missing id":        {"statements": []interface{}{"CREATE TABLE a (x int)"}},
+		"invalid id":        {"migration_id": "../x", "statements": []interface{}{"CREATE TABLE a (x int)"}},
+		"no statements":     {"migration_id": "m1"},
+		"not ddl":           {"migration_id": "m1", "statements": []interface{}{"DELETE FROM docs"}},
+		"two in one item":   {"migration_id": "m1", "statements": []interface{}{"CREATE TABLE a (x int); DROP TABLE b"}},
+		"non-transactional": {"migration_id": "m1", "statements": []interface{}{"CREATE INDEX CONCURRENTLY i ON docs (id)"}},
+	} {
+		if _, _, errResult := parseSchemaMigration(params); errResult == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMigrationChecksum(t *testing.T) {
+	a := migrationChecksum([]string{"CREATE TABLE a (x int);", "CREATE TABLE b (y int)"})
+	if b := migrationChecksum([]string{"  CREATE TABLE a (x int)\n", "CREATE TABLE b (y int);"}); a != b {
+		t.Error("checksum changed with whitespace and semicolons")
+	}
+	if b := migrationChecksum([]string{"CREATE TABLE b (y int)", "CREATE TABLE a (x int)"}); a == b {
+		t.Error("checksum ignores the order of statements")
+	}
+	if b := migrationChecksum([]string{"CREATE TABLE a (x int);CREATE TABLE b (y int)"}); a == b {
+		t.Error("checksum ignores statement boundaries")
+	}
+}
+
+func TestDiffSchemaSnapshots(t *testing.T) {
+	before := map[schemaObject]string{
+		{"table", "public.docs"}:          "",
+		{"column", "public.docs.body"}:    "text",
+		{"column", "public.docs.id"}:      "integer NOT NULL",
+		{"index", "public.docs_body_idx"}: "CREATE INDEX docs_body_idx ON public.docs USING btree (body)",
+	}
+	after := map[schemaObject]string{
+		{"table", "public.docs"}:       "",
+		{"column", "public.docs.id"}:   "bigint NOT NULL",
+		{"column", "public.docs.tags"}: "text[]",
+	}
+	want := []SchemaChange{
+		{Change: "removed", Kind: "column", Name: "public.docs.body", Before: "text"},
+		{Change: "changed", Kind: "column", Name: "public.docs.id", Before: "integer NOT NULL", After: "bigint NOT NULL"},
+		{Change: "added", Kind: "column", Name: "public.docs.tags", After: "text[]"},
+		{Change: "removed", Kind: "index", Name: "public.docs_body_idx", Before: "CREATE INDEX docs_body_idx ON public.docs USING btree (body)"},
+	}
+	if got := diffSchemaSnapshots(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff = %+v, want %+v", got, want)
+	}
+}
+
+func TestManageSchemaDryRunRefusesDestructive(t *testing.T) {
+	tool := NewManageSchemaTool(nil, nil)
+	result, err := tool.Execute(WithDryRun(context.Background()), map[string]interface{}{
+		"migration_id": "drop_docs",
+		"statements":   []interface{}{"DROP TABLE docs"},
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("dry run = %+v, %v", result, err)
+	}
+	data := result.Data.(map[string]interface{})
+	if data["status"] != "refused" || data["destructive"] != true || data["executed"] != false {
+		t.Errorf("dry run = %+v", data)
+	}
+	if statements := data["statements"].([]PlannedStatement); len(statements) != 2 || statements[1].Note != "drops the table" {
+		t.Errorf("planned statements = %+v", statements)
+	}
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+)
+
+// schemaResourceURL is the URL input schemas are compiled under; it only
+// names the schema in compiler errors
+const schemaResourceURL = "mem://neurondb-mcp/input-schema.json"
+
+// compiledSchemas caches compiled input schemas by their JSON encoding, so
+// each schema is compiled once however many tools or calls share it
+var compiledSchemas sync.Map // string -> *compiledSchema
+
+type compiledSchema struct {
+	schema *jsonschema.Schema
+	err    error
+}
+
+// CompileSchema compiles a tool input schema. Schemas without "$schema" are
+// read as JSON Schema draft 2020-12, the dialect of MCP tool schemas.
+func CompileSchema(schema map[string]interface{}) (*jsonschema.Schema, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("input schema cannot be encoded as JSON: %w", err)
+	}
+	key := string(encoded)
+	if cached, ok := compiledSchemas.Load(key); ok {
+		c := cached.(*compiledSchema)
+		return c.schema, c.err
+	}
+
+	c := &compiledSchema{}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(encoded))
+	if err == nil {
+		compiler := jsonschema.NewCompiler()
+		compiler.DefaultDraft(jsonschema.Draft2020)
+		if err = compiler.AddResource(schemaResourceURL, doc); err == nil {
+			c.schema, err = compiler.Compile(schemaResourceURL)
+		}
+	}
+	if err != nil {
+		c.err = fmt.Errorf("invalid input schema: %w", err)
+	}
+	compiledSchemas.Store(key, c)
+	return c.schema, c.err
+}
+
+// ValidateArguments validates tool arguments against a tool input schema
+// and returns one message per violation, prefixed with the JSON Pointer of
+// the offending value (e.g. "/vectors/2: got string, want number"). A nil or
+// empty schema accepts any arguments; a schema that does not compile
+// rejects them all.
+func ValidateArguments(schema map[string]interface{}, arguments map[string]interface{}) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+	compiled, err := CompileSchema(schema)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	// Arguments are validated in their JSON form, so Go values built by
+	// callers validate the same way as decoded requests
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(arguments)
+	if err != nil {
+		return []string{fmt.Sprintf("arguments cannot be encoded as JSON: %v", err)}
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(encoded))
+	if err != nil {
+		return []string{fmt.Sprintf("arguments cannot be decoded as JSON: %v", err)}
+	}
+
+	err = compiled.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []string{err.Error()}
+	}
+	return validationMessages(validationErr)
+}
+
+// validationMessages flattens a validation error into the messages of its
+// leaf causes, which name the failing keyword and value. Errors that only
+// group their causes, such as the root "doesn't validate" error, are left
+// out. Messages are sorted by location.
+func validationMessages(err *jsonschema.ValidationError) []string {
+	var messages []string
+	seen := map[string]bool{}
+	var walk func(unit jsonschema.OutputUnit)
+	walk = func(unit jsonschema.OutputUnit) {
+		if unit.Error != nil && len(unit.Errors) == 0 {
+			if _, group := unit.Error.Kind.(*kind.Group); !group {
+				location := unit.InstanceLocation
+				if location == "" {
+					location = "/"
+				}
+				message := location + ": " + unit.Error.String()
+				if !seen[message] {
+					seen[message] = true
+					messages = append(messages, message)
+				}
+			}
+		}
+		for _, child := range unit.Errors {
+			walk(child)
+		}
+	}
+	walk(*err.DetailedOutput())
+	if len(messages) == 0 {
+		messages = append(messages, err.Error())
+	}
+	sort.Strings(messages)
+	return messages
+}
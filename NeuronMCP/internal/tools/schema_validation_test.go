@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+func TestValidateArgumentsNested(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"table": map[string]interface{}{"type": "string", "minLength": 1},
+			"vectors": map[string]interface{}{
+				"type":     "array",
+				"minItems": 1,
+				"items":    map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+			},
+			"metric": map[string]interface{}{"type": "string", "enum": []interface{}{"l2", "cosine"}},
+			"options": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"k": map[string]interface{}{"type": "integer", "minimum": 1},
+				},
+				"additionalProperties": false,
+			},
+		},
+		"required": []interface{}{"table", "vectors"},
+	}
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want []string
+	}{
+		{
+			name: "valid",
+			args: map[string]interface{}{"table": "docs", "vectors": []interface{}{0.5, 1}, "metric": "l2", "options": map[string]interface{}{"k": 3}},
+		},
+		{
+			// Go ints and typed slices validate like their JSON form
+			name: "go values",
+			args: map[string]interface{}{"table": "docs", "vectors": []float64{0, 1}, "options": map[string]interface{}{"k": 10}},
+		},
+		{
+			name: "missing required",
+			args: map[string]interface{}{"vectors": []interface{}{0.1}},
+			want: []string{"/: missing property 'table'"},
+		},
+		{
+			name: "array items",
+			args: map[string]interface{}{"table": "docs", "vectors": []interface{}{0.2, "x", 1.5}},
+			want: []string{"/vectors/1: got string, want number", "/vectors/2: maximum: got 1.5, want 1"},
+		},
+		{
+			name: "enum and nested object",
+			args: map[string]interface{}{"table": "docs", "vectors": []interface{}{0.2}, "metric": "dot", "options": map[string]interface{}{"k": 0, "extra": true}},
+			want: []string{
+				"/metric: value must be one of 'l2', 'cosine'",
+				"/options/k: minimum: got 0, want 1",
+				"/options: additional properties 'extra' not allowed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateArguments(schema, tt.args)
+			// Messages are sorted by location
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateArguments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateArgumentsSchemaErrors(t *testing.T) {
+	if errs := ValidateArguments(nil, map[string]interface{}{"x": 1}); errs != nil {
+		t.Errorf("nil schema rejected arguments: %v", errs)
+	}
+	errs := ValidateArguments(map[string]interface{}{"type": "object", "properties": map[string]interface{}{"x": map[string]interface{}{"type": "strng"}}}, nil)
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "invalid input schema") {
+		t.Errorf("invalid schema = %v", errs)
+	}
+}
+
+// TestRegisteredToolSchemasCompile keeps every tool schema valid JSON
+// Schema, since a schema that does not compile rejects every call
+func TestRegisteredToolSchemasCompile(t *testing.T) {
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	db := database.NewDatabase()
+	registry := NewToolRegistry(db, logger)
+	RegisterAllTools(registry, db, logger)
+	for _, def := range registry.GetAllDefinitions() {
+		if _, err := CompileSchema(def.InputSchema); err != nil {
+			t.Errorf("%s: %v", def.Name, err)
+		}
+	}
+}
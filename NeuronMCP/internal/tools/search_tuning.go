@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"fmt"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+// searchTuningProperties are the per-query index settings shared by the
+// vector search tools
+var searchTuningProperties = map[string]interface{}{
+	"ef_search": map[string]interface{}{
+		"type":        "integer",
+		"minimum":     1,
+		"maximum":     database.MaxEfSearch,
+		"description": "HNSW candidate list size for this query; higher improves recall at the cost of latency",
+	},
+	"probes": map[string]interface{}{
+		"type":        "integer",
+		"minimum":     1,
+		"maximum":     database.MaxProbes,
+		"description": "Number of IVF lists scanned for this query; higher improves recall at the cost of latency",
+	},
+	"refine_k": map[string]interface{}{
+		"type":        "integer",
+		"minimum":     1,
+		"maximum":     10000,
+		"description": "Fetch this many candidates through the index, then re-rank them by exact distance and return the limit nearest; must be at least limit",
+	},
+}
+
+// withSearchTuning adds the search tuning properties to a schema's properties
+func withSearchTuning(properties map[string]interface{}) map[string]interface{} {
+	for name, property := range searchTuningProperties {
+		properties[name] = property
+	}
+	return properties
+}
+
+// searchTuningParam reads ef_search, probes and refine_k. It returns nil
+// when none is set, so untuned searches skip the transaction.
+func searchTuningParam(params map[string]interface{}, limit int) (*database.SearchTuning, error) {
+	tuning := &database.SearchTuning{}
+	for name, field := range map[string]*int{
+		"ef_search": &tuning.EfSearch,
+		"probes":    &tuning.Probes,
+		"refine_k":  &tuning.RefineK,
+	} {
+		v, ok := params[name]
+		if !ok || v == nil {
+			continue
+		}
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return nil, fmt.Errorf("%s must be a positive integer, got %v", name, v)
+		}
+		*field = int(n)
+	}
+	if tuning.IsZero() {
+		return nil, nil
+	}
+	if err := tuning.Validate(limit); err != nil {
+		return nil, err
+	}
+	return tuning, nil
+}
+
+// searchTuningMetadata describes the applied tuning in a result's metadata
+func searchTuningMetadata(metadata map[string]interface{}, tuning *database.SearchTuning) map[string]interface{} {
+	if tuning.IsZero() {
+		return metadata
+	}
+	applied := map[string]interface{}{}
+	if tuning.EfSearch > 0 {
+		applied["ef_search"] = tuning.EfSearch
+	}
+	if tuning.Probes > 0 {
+		applied["probes"] = tuning.Probes
+	}
+	if tuning.RefineK > 0 {
+		applied["refine_k"] = tuning.RefineK
+	}
+	metadata["search_tuning"] = applied
+	return metadata
+}
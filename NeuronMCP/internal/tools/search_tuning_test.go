@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/neurondb/NeuronMCP/internal/database"
+)
+
+func TestSearchTuningParam(t *testing.T) {
+	tuning, err := searchTuningParam(map[string]interface{}{}, 10)
+	if tuning != nil || err != nil {
+		t.Errorf("no tuning = (%+v, %v)", tuning, err)
+	}
+
+	tuning, err = searchTuningParam(map[string]interface{}{"ef_search": 128.0, "probes": 16.0, "refine_k": 50.0}, 10)
+	if err != nil || *tuning != (database.SearchTuning{EfSearch: 128, Probes: 16, RefineK: 50}) {
+		t.Errorf("tuning = (%+v, %v)", tuning, err)
+	}
+
+	for _, params := range []map[string]interface{}{
+		{"ef_search": 1.5},
+		{"probes": 0.0},
+		{"refine_k": "many"},
+		{"refine_k": 5.0},
+	} {
+		if _, err := searchTuningParam(params, 10); err == nil {
+			t.Errorf("searchTuningParam(%v) succeeded", params)
+		}
+	}
+
+	metadata := searchTuningMetadata(map[string]interface{}{}, tuning)
+	if applied, _ := metadata["search_tuning"].(map[string]interface{}); applied["ef_search"] != 128 {
+		t.Errorf("metadata = %v", metadata)
+	}
+}
@@ -0,0 +1,326 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Session table query limits
+const (
+	defaultSessionQueryLimit = 100
+	maxSessionQueryLimit     = 10000
+)
+
+// SessionTables holds the temporary result sets tools share across the calls
+// of an MCP session
+type SessionTables interface {
+	Database() *database.Database
+	Tables() []database.SessionTable
+	Create(ctx context.Context, name, query string, replace bool) (*database.SessionTable, error)
+	Query(ctx context.Context, query string, args []interface{}, scan func(pgx.Rows) error) error
+	Drop(ctx context.Context, name string) error
+}
+
+type sessionTablesKey struct{}
+
+// WithSessionTables returns a context carrying the session's tables for tool
+// execution
+func WithSessionTables(ctx context.Context, tables SessionTables) context.Context {
+	return context.WithValue(ctx, sessionTablesKey{}, tables)
+}
+
+// SessionTablesFromContext returns the session tables attached to ctx, if
+// session state is available
+func SessionTablesFromContext(ctx context.Context) (SessionTables, bool) {
+	tables, ok := ctx.Value(sessionTablesKey{}).(SessionTables)
+	return tables, ok && tables != nil
+}
+
+// sessionTables returns the session tables of ctx, or an error result when
+// there are none or the call was routed to a database they do not live on
+func sessionTables(ctx context.Context) (SessionTables, *ToolResult) {
+	tables, ok := SessionTablesFromContext(ctx)
+	if !ok {
+		return nil, Error("Session tables are not available on this server", "SESSION_STATE_UNAVAILABLE", nil)
+	}
+	if db := DatabaseFromContext(ctx, nil); db != nil && db != tables.Database() {
+		return nil, Error("Session tables live on the default database; call this tool without a database target", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "database",
+		})
+	}
+	return tables, nil
+}
+
+// CreateSessionTableTool stores a query result as a session table
+type CreateSessionTableTool struct {
+	*BaseTool
+	logger *logging.Logger
+}
+
+// NewCreateSessionTableTool creates a new create session table tool
+func NewCreateSessionTableTool(db *database.Database, logger *logging.Logger) *CreateSessionTableTool {
+	return &CreateSessionTableTool{
+		BaseTool: NewBaseTool(
+			"create_session_table",
+			"Store the rows of a read-only query as a named temporary table scoped to this MCP session, so later calls can query it with query_session_table. Session tables are dropped when the session ends.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name (an unquoted identifier, matched case-sensitively)",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "SELECT, WITH, VALUES or TABLE statement producing the rows; it may read other session tables",
+					},
+					"replace": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace an existing session table of the same name",
+					},
+				},
+				"required": []interface{}{"name", "query"},
+			},
+		),
+		logger: logger,
+	}
+}
+
+// Execute creates the session table
+func (t *CreateSessionTableTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for create_session_table tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	tables, errResult := sessionTables(ctx)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	name, _ := params["name"].(string)
+	query, _ := params["query"].(string)
+	replace, _ := params["replace"].(bool)
+	if err := database.ValidateSessionTableName(name); err != nil {
+		return Error(err.Error(), "VALIDATION_ERROR", map[string]interface{}{"parameter": "name"}), nil
+	}
+	if !database.IsReadOnlyStatement(query) {
+		return Error("query must be a read-only SELECT, WITH, VALUES or TABLE statement", "VALIDATION_ERROR", map[string]interface{}{"parameter": "query"}), nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	table, err := tables.Create(queryCtx, name, query, replace)
+	if err != nil {
+		if errors.Is(err, database.ErrSessionTableExists) {
+			return Error(err.Error(), "SESSION_TABLE_EXISTS", map[string]interface{}{"name": name}), nil
+		}
+		t.logger.Error("Session table creation failed", err, map[string]interface{}{
+			"name": name,
+		})
+		return Error(fmt.Sprintf("Failed to create session table '%s': %v", name, err), "QUERY_ERROR", map[string]interface{}{
+			"name":  name,
+			"error": err.Error(),
+		}), nil
+	}
+	return Success(table, nil), nil
+}
+
+// ListSessionTablesTool lists the session tables
+type ListSessionTablesTool struct {
+	*BaseTool
+}
+
+// NewListSessionTablesTool creates a new list session tables tool
+func NewListSessionTablesTool(db *database.Database, logger *logging.Logger) *ListSessionTablesTool {
+	return &ListSessionTablesTool{
+		BaseTool: NewBaseTool(
+			"list_session_tables",
+			"List the temporary tables of this MCP session with their columns, row counts and the queries that created them",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+				"required":   []interface{}{},
+			},
+		),
+	}
+}
+
+// Execute lists the session tables
+func (t *ListSessionTablesTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	tables, errResult := sessionTables(ctx)
+	if errResult != nil {
+		return errResult, nil
+	}
+	return Success(map[string]interface{}{
+		"tables":     tables.Tables(),
+		"max_tables": database.MaxSessionTables,
+	}, nil), nil
+}
+
+// QuerySessionTableTool reads session tables
+type QuerySessionTableTool struct {
+	*BaseTool
+	logger *logging.Logger
+}
+
+// NewQuerySessionTableTool creates a new query session table tool
+func NewQuerySessionTableTool(db *database.Database, logger *logging.Logger) *QuerySessionTableTool {
+	return &QuerySessionTableTool{
+		BaseTool: NewBaseTool(
+			"query_session_table",
+			"Read the rows of a session table, or run a read-only query that joins session tables with each other or with regular tables",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Session table to read; ignored when query is given",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Read-only SELECT, WITH, VALUES or TABLE statement that may reference session tables",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultSessionQueryLimit,
+						"minimum":     1,
+						"maximum":     maxSessionQueryLimit,
+						"description": "Maximum number of rows returned",
+					},
+				},
+				"required": []interface{}{},
+			},
+		),
+		logger: logger,
+	}
+}
+
+// Execute runs the query on the session's connection
+func (t *QuerySessionTableTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for query_session_table tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	tables, errResult := sessionTables(ctx)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	limit := defaultSessionQueryLimit
+	if v, ok := params["limit"].(float64); ok {
+		limit = int(v)
+	}
+	if limit < 1 || limit > maxSessionQueryLimit {
+		return Error(fmt.Sprintf("limit must be between 1 and %d, got %d", maxSessionQueryLimit, limit), "VALIDATION_ERROR", map[string]interface{}{"parameter": "limit"}), nil
+	}
+
+	query, _ := params["query"].(string)
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	name, _ := params["name"].(string)
+	switch {
+	case query != "":
+		if !database.IsReadOnlyStatement(query) {
+			return Error("query must be a read-only SELECT, WITH, VALUES or TABLE statement", "VALIDATION_ERROR", map[string]interface{}{"parameter": "query"}), nil
+		}
+	case name != "":
+		if err := database.ValidateSessionTableName(name); err != nil {
+			return Error(err.Error(), "VALIDATION_ERROR", map[string]interface{}{"parameter": "name"}), nil
+		}
+		query = "SELECT * FROM pg_temp." + pgx.Identifier{name}.Sanitize()
+	default:
+		return Error("either name or query is required for query_session_table tool", "VALIDATION_ERROR", map[string]interface{}{"params": params}), nil
+	}
+
+	// Read one row past the limit to report truncation
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	var rows []map[string]interface{}
+	err := tables.Query(queryCtx, "SELECT * FROM ("+query+") AS session_query LIMIT $1", []interface{}{limit + 1}, func(r pgx.Rows) error {
+		var err error
+		rows, err = scanRowsToMaps(queryCtx, r)
+		return err
+	})
+	if err != nil {
+		t.logger.Error("Session table query failed", err, map[string]interface{}{
+			"query": query,
+		})
+		return Error(fmt.Sprintf("Session table query failed: %v", err), "QUERY_ERROR", map[string]interface{}{
+			"query": query,
+			"error": err.Error(),
+		}), nil
+	}
+
+	truncated := len(rows) > limit
+	if truncated {
+		rows = rows[:limit]
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	return Success(map[string]interface{}{
+		"rows":      rows,
+		"row_count": len(rows),
+		"truncated": truncated,
+	}, nil), nil
+}
+
+// DropSessionTableTool removes a session table
+type DropSessionTableTool struct {
+	*BaseTool
+}
+
+// NewDropSessionTableTool creates a new drop session table tool
+func NewDropSessionTableTool(db *database.Database, logger *logging.Logger) *DropSessionTableTool {
+	return &DropSessionTableTool{
+		BaseTool: NewBaseTool(
+			"drop_session_table",
+			"Drop a temporary table of this MCP session",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Session table to drop",
+					},
+				},
+				"required": []interface{}{"name"},
+			},
+		),
+	}
+}
+
+// Execute drops the session table
+func (t *DropSessionTableTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for drop_session_table tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+	tables, errResult := sessionTables(ctx)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	name, _ := params["name"].(string)
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	if err := tables.Drop(queryCtx, name); err != nil {
+		if errors.Is(err, database.ErrSessionTableNotFound) {
+			return Error(err.Error(), "SESSION_TABLE_NOT_FOUND", map[string]interface{}{"name": name}), nil
+		}
+		return Error(fmt.Sprintf("Failed to drop session table '%s': %v", name, err), "QUERY_ERROR", map[string]interface{}{
+			"name":  name,
+			"error": err.Error(),
+		}), nil
+	}
+	return Success(map[string]interface{}{
+		"name":    name,
+		"dropped": true,
+	}, nil), nil
+}
@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/config"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+type fakeSessionTables struct {
+	db     *database.Database
+	tables map[string]database.SessionTable
+}
+
+func (f *fakeSessionTables) Database() *database.Database {
+	return f.db
+}
+
+func (f *fakeSessionTables) Tables() []database.SessionTable {
+	var tables []database.SessionTable
+	for _, t := range f.tables {
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+func (f *fakeSessionTables) Create(ctx context.Context, name, query string, replace bool) (*database.SessionTable, error) {
+	if _, ok := f.tables[name]; ok && !replace {
+		return nil, fmt.Errorf("%w: '%s'", database.ErrSessionTableExists, name)
+	}
+	table := database.SessionTable{Name: name, Query: query}
+	f.tables[name] = table
+	return &table, nil
+}
+
+func (f *fakeSessionTables) Query(ctx context.Context, query string, args []interface{}, scan func(pgx.Rows) error) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeSessionTables) Drop(ctx context.Context, name string) error {
+	if _, ok := f.tables[name]; !ok {
+		return fmt.Errorf("%w: '%s'", database.ErrSessionTableNotFound, name)
+	}
+	delete(f.tables, name)
+	return nil
+}
+
+func TestSessionTableTools(t *testing.T) {
+	logger := logging.NewLogger(config.NewConfigManager().GetLoggingConfig())
+	create := NewCreateSessionTableTool(nil, logger)
+	drop := NewDropSessionTableTool(nil, logger)
+
+	result, _ := create.Execute(context.Background(), map[string]interface{}{"name": "hits", "query": "SELECT 1"})
+	if result.Success || result.Error.Code != "SESSION_STATE_UNAVAILABLE" {
+		t.Fatalf("Execute() without session state = %+v", result.Error)
+	}
+
+	state := &fakeSessionTables{tables: map[string]database.SessionTable{}}
+	ctx := WithSessionTables(context.Background(), state)
+
+	result, _ = create.Execute(ctx, map[string]interface{}{"name": "hits", "query": "SELECT 1"})
+	if !result.Success {
+		t.Fatalf("create failed: %+v", result.Error)
+	}
+	result, _ = create.Execute(ctx, map[string]interface{}{"name": "hits", "query": "SELECT 2"})
+	if result.Success || result.Error.Code != "SESSION_TABLE_EXISTS" {
+		t.Errorf("duplicate create = %+v", result.Error)
+	}
+	result, _ = create.Execute(ctx, map[string]interface{}{"name": "hits", "query": "SELECT 2", "replace": true})
+	if !result.Success || state.tables["hits"].Query != "SELECT 2" {
+		t.Errorf("replace failed: %+v", result.Error)
+	}
+	result, _ = create.Execute(ctx, map[string]interface{}{"name": "bad name", "query": "SELECT 1"})
+	if result.Success {
+		t.Error("invalid table name was accepted")
+	}
+	result, _ = create.Execute(ctx, map[string]interface{}{"name": "gone", "query": "DELETE FROM documents"})
+	if result.Success {
+		t.Error("write query was accepted")
+	}
+
+	result, _ = drop.Execute(ctx, map[string]interface{}{"name": "hits"})
+	if !result.Success || len(state.tables) != 0 {
+		t.Errorf("drop failed: %+v", result.Error)
+	}
+	result, _ = drop.Execute(ctx, map[string]interface{}{"name": "hits"})
+	if result.Success || result.Error.Code != "SESSION_TABLE_NOT_FOUND" {
+		t.Errorf("drop of a missing table = %+v", result.Error)
+	}
+}
+
+func TestQuerySessionTableToolValidation(t *testing.T) {
+	tool := NewQuerySessionTableTool(nil, logging.NewLogger(config.NewConfigManager().GetLoggingConfig()))
+	ctx := WithSessionTables(context.Background(), &fakeSessionTables{tables: map[string]database.SessionTable{}})
+
+	cases := []map[string]interface{}{
+		{},
+		{"name": "hits; DROP TABLE documents"},
+		{"query": "UPDATE documents SET title = ''"},
+		{"name": "hits", "limit": float64(0)},
+	}
+	for _, params := range cases {
+		if result, _ := tool.Execute(ctx, params); result.Success {
+			t.Errorf("Execute(%v) succeeded", params)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+const (
+	// SimilarityJoinTimeout bounds a similarity join written to a table,
+	// which runs one kNN search per left row
+	SimilarityJoinTimeout = 10 * time.Minute
+	// maxSimilarityJoinTopK bounds the matches kept per left row
+	maxSimilarityJoinTopK = 100
+	// maxSimilarityJoinPage bounds the left rows joined per result page
+	maxSimilarityJoinPage = 1000
+)
+
+// similarityJoinOperators maps a distance metric to its pgvector operator
+var similarityJoinOperators = map[string]string{
+	"l2":            "<->",
+	"cosine":        "<=>",
+	"inner_product": "<#>",
+}
+
+// VectorSimilarityJoinTool matches the rows of one table to their nearest
+// rows in another by vector distance
+type VectorSimilarityJoinTool struct {
+	*BaseTool
+	db       *database.Database
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewVectorSimilarityJoinTool creates a new vector similarity join tool
+func NewVectorSimilarityJoinTool(db *database.Database, logger *logging.Logger) *VectorSimilarityJoinTool {
+	return &VectorSimilarityJoinTool{
+		BaseTool: NewBaseTool(
+			"vector_similarity_join",
+			"Join two tables on vector similarity, pairing each left row with its top-k nearest right rows within a distance threshold, for entity resolution and deduplication. Returns pages of pairs or writes every pair to a table",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"left_table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table whose rows are matched, optionally schema-qualified",
+					},
+					"left_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Vector column of the left table; rows where it is NULL are skipped",
+					},
+					"left_key": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column identifying left rows; it also orders them for paging",
+					},
+					"left_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Other left columns to return, as left_<column>",
+					},
+					"right_table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table searched for matches; may be the left table for deduplication",
+					},
+					"right_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Vector column of the right table",
+					},
+					"right_key": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column identifying right rows",
+					},
+					"right_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Other right columns to return, as right_<column>",
+					},
+					"distance_metric": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"l2", "cosine", "inner_product"},
+						"default":     "cosine",
+						"description": "Distance metric; it should match the right column's index so each search can use it",
+					},
+					"threshold": map[string]interface{}{
+						"type":        "number",
+						"description": "Largest distance kept, as returned by the metric's operator (negative inner product for inner_product); all top-k matches are kept when omitted",
+					},
+					"top_k": map[string]interface{}{
+						"type":        "number",
+						"default":     5,
+						"minimum":     1,
+						"maximum":     maxSimilarityJoinTopK,
+						"description": "Nearest right rows considered per left row",
+					},
+					"exclude_self": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Skip right rows whose key equals the left row's key. Defaults to true when both sides are the same table and column",
+					},
+					"destination": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"result", "table"},
+						"default":     "result",
+						"description": "result returns the pairs of one page of left rows; table writes every pair to output_table",
+					},
+					"offset": map[string]interface{}{
+						"type":        "number",
+						"default":     0,
+						"minimum":     0,
+						"description": "Left rows to skip (result); pass next_offset from the previous page",
+					},
+					"page_size": map[string]interface{}{
+						"type":        "number",
+						"default":     100,
+						"minimum":     1,
+						"maximum":     maxSimilarityJoinPage,
+						"description": "Left rows joined per page (result); a page holds up to page_size * top_k pairs",
+					},
+					"output_table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table created with the pairs (table), optionally schema-qualified",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Replace output_table if it exists (table)",
+					},
+				},
+				"required": []interface{}{"left_table", "left_column", "right_table", "right_column"},
+			},
+		),
+		db:       db,
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// similarityJoinRequest holds the parsed vector_similarity_join parameters
+type similarityJoinRequest struct {
+	leftName     string
+	left         pgx.Identifier
+	leftColumn   string
+	leftKey      string
+	leftColumns  []string
+	rightName    string
+	right        pgx.Identifier
+	rightColumn  string
+	rightKey     string
+	rightColumns []string
+	metric       string
+	threshold    *float64
+	topK         int
+	excludeSelf  bool
+}
+
+// Execute runs the similarity join
+func (t *VectorSimilarityJoinTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for vector_similarity_join tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	req, invalid := parseSimilarityJoinRequest(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	if IsDryRun(ctx) {
+		return t.planJoin(ctx, req, params), nil
+	}
+	if stringParam(params, "destination", "result") == "table" {
+		return t.joinToTable(ctx, req, params)
+	}
+	return t.joinPage(ctx, req, params)
+}
+
+// planJoin returns the dry run plan of a join
+func (t *VectorSimilarityJoinTool) planJoin(ctx context.Context, req similarityJoinRequest, params map[string]interface{}) *ToolResult {
+	permissions := []Permission{
+		tablePermission("SELECT", req.left.Sanitize()),
+		tablePermission("SELECT", req.right.Sanitize()),
+	}
+	if stringParam(params, "destination", "result") != "table" {
+		offset, pageSize := 0, 100
+		if v, ok := params["offset"].(float64); ok {
+			offset = int(v)
+		}
+		if v, ok := params["page_size"].(float64); ok {
+			pageSize = int(v)
+		}
+		return dryRunResult(ctx, DatabaseFromContext(ctx, t.db), t.Name(), []PlannedStatement{
+			{SQL: buildSimilarityJoinQuery(req, true), Params: []interface{}{pageSize, offset}, Note: "reads only; estimated_rows is the number of pairs"},
+		}, permissions)
+	}
+
+	outputName := stringParam(params, "output_table", "")
+	output, err := parseQualifiedIdentifier(outputName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid output_table '%s': %v", outputName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "output_table",
+		})
+	}
+	var statements []PlannedStatement
+	if overwrite, _ := params["overwrite"].(bool); overwrite {
+		statements = append(statements, PlannedStatement{
+			SQL:    "DROP TABLE " + output.Sanitize(),
+			rowsOf: output.Sanitize(),
+			Note:   "runs only when the table exists",
+		})
+		permissions = append(permissions, tablePermission("OWNER", output.Sanitize()))
+	}
+	statements = append(statements, PlannedStatement{
+		SQL: fmt.Sprintf("CREATE TABLE %s AS %s", output.Sanitize(), buildSimilarityJoinQuery(req, false)),
+	})
+	permissions = append(permissions, schemaPermission("CREATE", schemaOf(output)))
+	return dryRunResult(ctx, DatabaseFromContext(ctx, t.db), t.Name(), statements, permissions)
+}
+
+// parseSimilarityJoinRequest validates the join parameters, returning a
+// validation error result when they are unusable
+func parseSimilarityJoinRequest(params map[string]interface{}) (similarityJoinRequest, *ToolResult) {
+	req := similarityJoinRequest{
+		leftColumn:  stringParam(params, "left_column", ""),
+		leftKey:     stringParam(params, "left_key", "id"),
+		rightColumn: stringParam(params, "right_column", ""),
+		rightKey:    stringParam(params, "right_key", "id"),
+		metric:      stringParam(params, "distance_metric", "cosine"),
+		topK:        5,
+	}
+
+	for _, side := range []struct {
+		param string
+		name  *string
+		ident *pgx.Identifier
+	}{
+		{"left_table", &req.leftName, &req.left},
+		{"right_table", &req.rightName, &req.right},
+	} {
+		*side.name, _ = params[side.param].(string)
+		ident, err := parseQualifiedIdentifier(*side.name)
+		if err != nil {
+			return req, Error(fmt.Sprintf("Invalid %s '%s': %v", side.param, *side.name, err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": side.param,
+			})
+		}
+		*side.ident = ident
+	}
+
+	for _, cols := range []struct {
+		param string
+		into  *[]string
+	}{
+		{"left_columns", &req.leftColumns},
+		{"right_columns", &req.rightColumns},
+	} {
+		list, _ := params[cols.param].([]interface{})
+		for i, c := range list {
+			name, ok := c.(string)
+			if !ok || name == "" {
+				return req, Error(fmt.Sprintf("%s at index %d must be a non-empty string", cols.param, i), "VALIDATION_ERROR", map[string]interface{}{
+					"parameter": cols.param,
+				})
+			}
+			*cols.into = append(*cols.into, name)
+		}
+	}
+
+	if _, ok := similarityJoinOperators[req.metric]; !ok {
+		return req, Error(fmt.Sprintf("Unsupported distance_metric '%s': use l2, cosine or inner_product", req.metric), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "distance_metric",
+		})
+	}
+	if v, ok := params["top_k"].(float64); ok {
+		req.topK = int(v)
+	}
+	if req.topK < 1 || req.topK > maxSimilarityJoinTopK {
+		return req, Error(fmt.Sprintf("top_k must be between 1 and %d, got %d", maxSimilarityJoinTopK, req.topK), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "top_k",
+		})
+	}
+	if v, ok := params["threshold"].(float64); ok {
+		req.threshold = &v
+	}
+
+	req.excludeSelf = req.left.Sanitize() == req.right.Sanitize() && req.leftColumn == req.rightColumn
+	if v, ok := params["exclude_self"].(bool); ok {
+		req.excludeSelf = v
+	}
+	return req, nil
+}
+
+// buildSimilarityJoinQuery builds the join as a LATERAL kNN search per left
+// row: each left row's top_k nearest right rows are found by an ORDER BY
+// distance LIMIT subquery, which an index on the right column serves, and
+// matches beyond the threshold are dropped afterwards. With paged set, the
+// left rows are bound as $1 (limit) and $2 (offset) in key order. top_k and
+// threshold are validated numbers and inlined, so the query also works in
+// CREATE TABLE AS, which takes no parameters.
+//
+// Pairs have columns left_key, right_key, distance, left_<column> and
+// right_<column>, ordered by left key and distance.
+func buildSimilarityJoinQuery(req similarityJoinRequest, paged bool) string {
+	op := similarityJoinOperators[req.metric]
+	leftKey := "lt." + pgx.Identifier{req.leftKey}.Sanitize()
+	leftVec := "lt." + pgx.Identifier{req.leftColumn}.Sanitize()
+	rightKey := "rt." + pgx.Identifier{req.rightKey}.Sanitize()
+	rightVec := "rt." + pgx.Identifier{req.rightColumn}.Sanitize()
+	distance := fmt.Sprintf("%s %s l.__vector", rightVec, op)
+
+	// The inner columns carry a __ prefix so they cannot collide with the
+	// extra columns passed through
+	leftSelect := []string{leftKey + " AS __key", leftVec + " AS __vector"}
+	rightSelect := []string{rightKey + " AS __key", distance + " AS __distance"}
+	outSelect := []string{"l.__key AS left_key", "m.__key AS right_key", "m.__distance AS distance"}
+	for _, c := range req.leftColumns {
+		col := pgx.Identifier{c}.Sanitize()
+		leftSelect = append(leftSelect, "lt."+col)
+		outSelect = append(outSelect, "l."+col+" AS "+pgx.Identifier{"left_" + c}.Sanitize())
+	}
+	for _, c := range req.rightColumns {
+		col := pgx.Identifier{c}.Sanitize()
+		rightSelect = append(rightSelect, "rt."+col)
+		outSelect = append(outSelect, "m."+col+" AS "+pgx.Identifier{"right_" + c}.Sanitize())
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s FROM (SELECT %s FROM %s lt WHERE %s IS NOT NULL ORDER BY %s",
+		strings.Join(outSelect, ", "), strings.Join(leftSelect, ", "), req.left.Sanitize(), leftVec, leftKey)
+	if paged {
+		b.WriteString(" LIMIT $1 OFFSET $2")
+	}
+	fmt.Fprintf(&b, ") l CROSS JOIN LATERAL (SELECT %s FROM %s rt WHERE %s IS NOT NULL",
+		strings.Join(rightSelect, ", "), req.right.Sanitize(), rightVec)
+	if req.excludeSelf {
+		fmt.Fprintf(&b, " AND %s IS DISTINCT FROM l.__key", rightKey)
+	}
+	fmt.Fprintf(&b, " ORDER BY %s LIMIT %d) m", distance, req.topK)
+	if req.threshold != nil {
+		fmt.Fprintf(&b, " WHERE m.__distance <= %s", strconv.FormatFloat(*req.threshold, 'g', -1, 64))
+	}
+	b.WriteString(" ORDER BY l.__key, m.__distance")
+	return b.String()
+}
+
+// joinPage returns the pairs of one page of left rows
+func (t *VectorSimilarityJoinTool) joinPage(ctx context.Context, req similarityJoinRequest, params map[string]interface{}) (*ToolResult, error) {
+	offset, pageSize := 0, 100
+	if v, ok := params["offset"].(float64); ok {
+		offset = int(v)
+	}
+	if v, ok := params["page_size"].(float64); ok {
+		pageSize = int(v)
+	}
+	if offset < 0 || pageSize < 1 || pageSize > maxSimilarityJoinPage {
+		return Error(fmt.Sprintf("offset must be >= 0 and page_size between 1 and %d, got offset=%d, page_size=%d", maxSimilarityJoinPage, offset, pageSize), "VALIDATION_ERROR", nil), nil
+	}
+
+	start := time.Now()
+	rows, err := t.executor.ExecuteQuery(ctx, buildSimilarityJoinQuery(req, true), []interface{}{pageSize, offset})
+	if err != nil {
+		return t.joinError(req, err), nil
+	}
+
+	// Pairs do not show whether left rows remain, since a row may have no
+	// match within the threshold; look for the first row of the next page
+	more, err := t.executor.ExecuteQuery(ctx, fmt.Sprintf(
+		"SELECT 1 FROM %s lt WHERE lt.%s IS NOT NULL ORDER BY lt.%s LIMIT 1 OFFSET $1",
+		req.left.Sanitize(), pgx.Identifier{req.leftColumn}.Sanitize(), pgx.Identifier{req.leftKey}.Sanitize(),
+	), []interface{}{offset + pageSize})
+	if err != nil {
+		return t.joinError(req, err), nil
+	}
+
+	var nextOffset interface{}
+	if len(more) > 0 {
+		nextOffset = offset + pageSize
+	}
+	return Success(map[string]interface{}{
+		"pairs":       rows,
+		"count":       len(rows),
+		"offset":      offset,
+		"next_offset": nextOffset,
+	}, t.joinMetadata(req, start)), nil
+}
+
+// joinToTable creates output_table from every pair in one transaction, so a
+// failed join leaves an existing table in place
+func (t *VectorSimilarityJoinTool) joinToTable(ctx context.Context, req similarityJoinRequest, params map[string]interface{}) (*ToolResult, error) {
+	outputName := stringParam(params, "output_table", "")
+	output, err := parseQualifiedIdentifier(outputName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid output_table '%s': %v", outputName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "output_table",
+		}), nil
+	}
+	overwrite, _ := params["overwrite"].(bool)
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available (database connection pool is not initialized)", "DATABASE_ERROR", nil), nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, SimilarityJoinTimeout)
+	defer cancel()
+	start := time.Now()
+
+	tx, err := db.Begin(queryCtx)
+	if err != nil {
+		return t.joinError(req, err), nil
+	}
+	defer tx.Rollback(queryCtx)
+
+	var exists bool
+	if err := tx.QueryRow(queryCtx, "SELECT to_regclass($1) IS NOT NULL", output.Sanitize()).Scan(&exists); err != nil {
+		return t.joinError(req, err), nil
+	}
+	if exists {
+		if !overwrite {
+			return Error(fmt.Sprintf("Output table %s already exists: set overwrite to replace it", outputName), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "output_table",
+			}), nil
+		}
+		if _, err := tx.Exec(queryCtx, "DROP TABLE "+output.Sanitize()); err != nil {
+			return t.joinError(req, fmt.Errorf("failed to drop %s: %w", outputName, err)), nil
+		}
+	}
+
+	tag, err := tx.Exec(queryCtx, fmt.Sprintf("CREATE TABLE %s AS %s", output.Sanitize(), buildSimilarityJoinQuery(req, false)))
+	if err != nil {
+		if queryCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("similarity join timeout after %v: %w", SimilarityJoinTimeout, queryCtx.Err())
+		}
+		return t.joinError(req, err), nil
+	}
+	if err := tx.Commit(queryCtx); err != nil {
+		return t.joinError(req, fmt.Errorf("failed to commit %s: %w", outputName, err)), nil
+	}
+
+	t.logger.Info("Similarity join written", map[string]interface{}{
+		"left_table":   req.leftName,
+		"right_table":  req.rightName,
+		"output_table": outputName,
+		"pairs":        tag.RowsAffected(),
+	})
+	return Success(map[string]interface{}{
+		"output_table": outputName,
+		"pairs":        tag.RowsAffected(),
+	}, t.joinMetadata(req, start)), nil
+}
+
+// joinMetadata describes a join in result metadata
+func (t *VectorSimilarityJoinTool) joinMetadata(req similarityJoinRequest, start time.Time) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"left_table":      req.leftName,
+		"right_table":     req.rightName,
+		"distance_metric": req.metric,
+		"top_k":           req.topK,
+		"exclude_self":    req.excludeSelf,
+		"elapsed_ms":      msSince(start),
+	}
+	if req.threshold != nil {
+		metadata["threshold"] = *req.threshold
+	}
+	return metadata
+}
+
+// joinError reports a failed similarity join
+func (t *VectorSimilarityJoinTool) joinError(req similarityJoinRequest, err error) *ToolResult {
+	t.logger.Error("Similarity join failed", err, map[string]interface{}{"left_table": req.leftName, "right_table": req.rightName})
+	return Error(fmt.Sprintf("Similarity join failed: left_table='%s', right_table='%s', distance_metric='%s', error=%v", req.leftName, req.rightName, req.metric, err), "JOIN_ERROR", map[string]interface{}{
+		"left_table":  req.leftName,
+		"right_table": req.rightName,
+		"error":       err.Error(),
+	})
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSimilarityJoinQuery(t *testing.T) {
+	req, invalid := parseSimilarityJoinRequest(map[string]interface{}{
+		"left_table":    "public.customers",
+		"left_column":   "embedding",
+		"left_columns":  []interface{}{"name"},
+		"right_table":   "public.customers",
+		"right_column":  "embedding",
+		"right_columns": []interface{}{"name"},
+		"threshold":     0.15,
+		"top_k":         float64(3),
+	})
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if !req.excludeSelf {
+		t.Error("exclude_self should default to true for a self-join")
+	}
+
+	query := buildSimilarityJoinQuery(req, true)
+	for _, want := range []string{
+		`FROM "public"."customers" lt WHERE lt."embedding" IS NOT NULL ORDER BY lt."id" LIMIT $1 OFFSET $2`,
+		`CROSS JOIN LATERAL`,
+		`rt."id" IS DISTINCT FROM l.__key`,
+		`ORDER BY rt."embedding" <=> l.__vector LIMIT 3) m`,
+		`WHERE m.__distance <= 0.15`,
+		`l."name" AS "left_name"`,
+		`m."name" AS "right_name"`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+
+	if query := buildSimilarityJoinQuery(req, false); strings.Contains(query, "$") {
+		t.Errorf("unpaged query should take no parameters:\n%s", query)
+	}
+}
+
+func TestParseSimilarityJoinRequestErrors(t *testing.T) {
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"left_table":   "a",
+			"left_column":  "v",
+			"right_table":  "b",
+			"right_column": "v",
+		}
+	}
+
+	req, invalid := parseSimilarityJoinRequest(base())
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if req.excludeSelf || req.threshold != nil || req.topK != 5 {
+		t.Errorf("defaults = %+v", req)
+	}
+	if query := buildSimilarityJoinQuery(req, false); strings.Contains(query, "IS DISTINCT FROM") || strings.Contains(query, "__distance <=") {
+		t.Errorf("query should not exclude self or filter by threshold:\n%s", query)
+	}
+
+	for name, change := range map[string]func(map[string]interface{}){
+		"top_k":           func(p map[string]interface{}) { p["top_k"] = float64(0) },
+		"distance_metric": func(p map[string]interface{}) { p["distance_metric"] = "hamming" },
+		"right_columns":   func(p map[string]interface{}) { p["right_columns"] = []interface{}{""} },
+		"left_table":      func(p map[string]interface{}) { p["left_table"] = "a.b.c.d" },
+	} {
+		params := base()
+		change(params)
+		if _, invalid := parseSimilarityJoinRequest(params); invalid == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
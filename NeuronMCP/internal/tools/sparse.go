@@ -0,0 +1,660 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+const (
+	// defaultSparseVocabSize is the BERT WordPiece vocabulary used by SPLADE
+	defaultSparseVocabSize = 30522
+	// rrfK is the rank constant of reciprocal rank fusion
+	rrfK = 60
+)
+
+// sparseEmbedFunctions maps a sparse model to the extension function that
+// embeds text with it
+var sparseEmbedFunctions = map[string]string{
+	"splade":    "splade_embed",
+	"colbertv2": "colbertv2_embed",
+}
+
+// sparseModelNames maps a sparse model to the name stored in sparse_vector
+// values
+var sparseModelNames = map[string]string{
+	"splade":    "SPLADE",
+	"colbertv2": "ColBERTv2",
+	"bm25":      "BM25",
+}
+
+// sparseQueryProperty is the schema of a literal sparse query vector
+var sparseQueryProperty = map[string]interface{}{
+	"description": "Sparse query vector, either in sparse_vector text form or as an object with tokens, weights and optionally vocab_size and model",
+	"oneOf": []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"tokens":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+				"weights":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}},
+				"vocab_size": map[string]interface{}{"type": "integer"},
+				"model":      map[string]interface{}{"type": "string", "enum": []interface{}{"splade", "colbertv2", "bm25"}},
+			},
+			"required": []interface{}{"tokens", "weights"},
+		},
+	},
+}
+
+// sparseModelProperty is the schema of the sparse embedding model parameter
+var sparseModelProperty = map[string]interface{}{
+	"type":        "string",
+	"enum":        []interface{}{"splade", "colbertv2"},
+	"default":     "splade",
+	"description": "Sparse embedding model",
+}
+
+// formatSparseVector renders a sparse query given as an object in the JSON
+// text form accepted by the sparse_vector type
+func formatSparseVector(v map[string]interface{}) (string, error) {
+	tokens, _ := v["tokens"].([]interface{})
+	weights, _ := v["weights"].([]interface{})
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("sparse vector must have at least one token")
+	}
+	if len(tokens) != len(weights) {
+		return "", fmt.Errorf("sparse vector has %d tokens but %d weights", len(tokens), len(weights))
+	}
+
+	vocabSize := defaultSparseVocabSize
+	if n, ok := v["vocab_size"].(float64); ok {
+		vocabSize = int(n)
+	}
+	model := "SPLADE"
+	if m, ok := v["model"].(string); ok && m != "" {
+		name, ok := sparseModelNames[strings.ToLower(m)]
+		if !ok {
+			return "", fmt.Errorf("unknown sparse model '%s': expected splade, colbertv2 or bm25", m)
+		}
+		model = name
+	}
+
+	ids := make([]int, len(tokens))
+	for i, tok := range tokens {
+		n, ok := tok.(float64)
+		if !ok || n != float64(int(n)) || n < 0 || int(n) >= vocabSize {
+			return "", fmt.Errorf("token at index %d must be an integer in [0, %d), got %v", i, vocabSize, tok)
+		}
+		ids[i] = int(n)
+	}
+	ws := make([]float64, len(weights))
+	for i, w := range weights {
+		n, ok := w.(float64)
+		if !ok {
+			return "", fmt.Errorf("weight at index %d must be a number, got %T", i, w)
+		}
+		ws[i] = n
+	}
+
+	out, err := json.Marshal(map[string]interface{}{
+		"vocab_size": vocabSize,
+		"model":      model,
+		"tokens":     ids,
+		"weights":    ws,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// sparseQueryExpr returns the SQL expression for the sparse query of a
+// search and its bind value, placed at parameter index param. The query is
+// either a literal (query_sparse) or text embedded with model (query_text).
+func sparseQueryExpr(params map[string]interface{}, textKey, modelKey string, param int) (string, interface{}, error) {
+	if raw, ok := params["query_sparse"]; ok && raw != nil {
+		switch v := raw.(type) {
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return "", nil, fmt.Errorf("query_sparse cannot be empty")
+			}
+			return fmt.Sprintf("$%d::sparse_vector", param), v, nil
+		case map[string]interface{}:
+			literal, err := formatSparseVector(v)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid query_sparse: %w", err)
+			}
+			return fmt.Sprintf("$%d::sparse_vector", param), literal, nil
+		default:
+			return "", nil, fmt.Errorf("query_sparse must be a string or an object, got %T", raw)
+		}
+	}
+
+	text, _ := params[textKey].(string)
+	if text == "" {
+		return "", nil, fmt.Errorf("either query_sparse or %s is required", textKey)
+	}
+	model := stringParam(params, modelKey, "splade")
+	fn, ok := sparseEmbedFunctions[model]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sparse model '%s': expected splade or colbertv2", model)
+	}
+	return fmt.Sprintf("%s($%d::text)", fn, param), text, nil
+}
+
+// selectColumnList renders the columns returned by a search, qualified by
+// alias; no columns selects the whole row
+func selectColumnList(alias string, columns []interface{}) (string, error) {
+	if len(columns) == 0 {
+		return alias + ".*", nil
+	}
+	parts := make([]string, 0, len(columns))
+	for i, c := range columns {
+		name, ok := c.(string)
+		if !ok || name == "" {
+			return "", fmt.Errorf("additional column at index %d must be a non-empty string", i)
+		}
+		parts = append(parts, alias+"."+pgx.Identifier{name}.Sanitize())
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// buildSparseSearchQuery builds a top-k search ranking rows by the dot
+// product of sparseColumn with the query expression queryExpr ($1). The
+// limit is bound as $2. The query is evaluated once in a CTE, since the
+// embedding functions are volatile and would otherwise run for every row.
+func buildSparseSearchQuery(table pgx.Identifier, sparseColumn, queryExpr string, columns []interface{}) (string, error) {
+	selectList, err := selectColumnList("t", columns)
+	if err != nil {
+		return "", err
+	}
+	col := "t." + pgx.Identifier{sparseColumn}.Sanitize()
+	return fmt.Sprintf(
+		"WITH q AS (SELECT %s AS v) SELECT %s, sparse_vector_dot_product(%s, q.v) AS score FROM %s t, q WHERE %s IS NOT NULL ORDER BY score DESC LIMIT $2",
+		queryExpr, selectList, col, table.Sanitize(), col,
+	), nil
+}
+
+// denseDistanceExpr returns the distance of column to the query vector in
+// parameter param for the metrics supported by dense+sparse search
+func denseDistanceExpr(column, metric string, param int) (string, error) {
+	switch metric {
+	case "l2":
+		return fmt.Sprintf("%s <-> $%d::vector", column, param), nil
+	case "cosine":
+		return fmt.Sprintf("%s <=> $%d::vector", column, param), nil
+	case "inner_product":
+		return fmt.Sprintf("%s <#> $%d::vector", column, param), nil
+	}
+	return "", fmt.Errorf("distance metric '%s' is not supported with a sparse column: use l2, cosine or inner_product", metric)
+}
+
+// buildDenseSparseQuery builds a dense+sparse hybrid search. The dense and
+// sparse searches each take their $3 best rows as candidates; rows found by
+// either are fused and the top $4 returned. Bind parameters are the dense query
+// vector ($1), the sparse query ($2, used by sparseExpr) and the candidate
+// count ($3).
+//
+// With fusion "rrf" the score is the weighted sum of 1/(60 + rank) from each
+// search. Otherwise ("weighted") dense similarity and sparse score are
+// min-max normalised over the candidates and summed with weights
+// 1-sparseWeight and sparseWeight; a row missing from one search scores 0
+// there.
+func buildDenseSparseQuery(table pgx.Identifier, vectorColumn, sparseColumn, metric, sparseExpr, fusion string, sparseWeight float64, columns []interface{}) (string, error) {
+	selectList, err := selectColumnList("t", columns)
+	if err != nil {
+		return "", err
+	}
+	distance, err := denseDistanceExpr(pgx.Identifier{vectorColumn}.Sanitize(), metric, 1)
+	if err != nil {
+		return "", err
+	}
+	sparseCol := pgx.Identifier{sparseColumn}.Sanitize()
+	denseWeight := 1 - sparseWeight
+
+	var score string
+	if fusion == "rrf" {
+		score = fmt.Sprintf(
+			"COALESCE(%g / (%d + dense_rank), 0) + COALESCE(%g / (%d + sparse_rank), 0)",
+			denseWeight, rrfK, sparseWeight, rrfK,
+		)
+	} else {
+		score = fmt.Sprintf(
+			"%g * COALESCE((max_distance - distance) / NULLIF(max_distance - min_distance, 0), CASE WHEN distance IS NULL THEN 0 ELSE 1 END)"+
+				" + %g * COALESCE((sparse_score - min_sparse) / NULLIF(max_sparse - min_sparse, 0), CASE WHEN sparse_score IS NULL THEN 0 ELSE 1 END)",
+			denseWeight, sparseWeight,
+		)
+	}
+
+	return fmt.Sprintf(`WITH q AS (
+	SELECT %[4]s AS v
+), dense AS (
+	SELECT ctid AS rid, %[1]s AS distance FROM %[2]s ORDER BY distance ASC LIMIT $3
+), sparse AS (
+	SELECT ctid AS rid, sparse_vector_dot_product(%[3]s, q.v) AS sparse_score FROM %[2]s, q WHERE %[3]s IS NOT NULL ORDER BY sparse_score DESC LIMIT $3
+), ranked AS (
+	SELECT COALESCE(d.rid, s.rid) AS rid, d.distance, s.sparse_score,
+		rank() OVER (ORDER BY d.distance ASC NULLS LAST)::float8 AS dense_rank,
+		rank() OVER (ORDER BY s.sparse_score DESC NULLS LAST)::float8 AS sparse_rank,
+		min(d.distance) OVER () AS min_distance, max(d.distance) OVER () AS max_distance,
+		min(s.sparse_score) OVER () AS min_sparse, max(s.sparse_score) OVER () AS max_sparse
+	FROM dense d FULL OUTER JOIN sparse s ON d.rid = s.rid
+), fused AS (
+	SELECT rid, distance, sparse_score,
+		CASE WHEN distance IS NULL THEN NULL ELSE dense_rank END AS dense_rank,
+		CASE WHEN sparse_score IS NULL THEN NULL ELSE sparse_rank END AS sparse_rank,
+		min_distance, max_distance, min_sparse, max_sparse
+	FROM ranked
+)
+SELECT %[5]s, f.distance, f.sparse_score, (%[6]s)::float8 AS score
+FROM fused f JOIN %[2]s t ON t.ctid = f.rid
+ORDER BY score DESC LIMIT $4`,
+		distance, table.Sanitize(), sparseCol, sparseExpr, selectList, score,
+	), nil
+}
+
+// executeDenseSparse runs a vector_search that sets sparse_column, fusing
+// the dense search with a sparse dot-product search on that column
+func (t *VectorSearchTool) executeDenseSparse(ctx context.Context, params map[string]interface{}, tableName, vectorColumn, sparseColumn string, queryVector []interface{}, metric string, limit int, additionalColumns []interface{}) (*ToolResult, error) {
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	sparseWeight := 0.3
+	if w, ok := params["sparse_weight"].(float64); ok {
+		sparseWeight = w
+	}
+	if sparseWeight < 0 || sparseWeight > 1 {
+		return Error(fmt.Sprintf("sparse_weight must be between 0.0 and 1.0 for vector_search tool: table='%s', received sparse_weight=%g", tableName, sparseWeight), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter":     "sparse_weight",
+			"sparse_weight": sparseWeight,
+		}), nil
+	}
+	fusion := stringParam(params, "fusion", "weighted")
+
+	sparseExpr, sparseValue, err := sparseQueryExpr(params, "query_text", "sparse_model", 2)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid sparse query for vector_search tool on table '%s', sparse_column '%s': %v", tableName, sparseColumn, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table":         tableName,
+			"sparse_column": sparseColumn,
+		}), nil
+	}
+	query, err := buildDenseSparseQuery(table, vectorColumn, sparseColumn, metric, sparseExpr, fusion, sparseWeight, additionalColumns)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid parameters for vector_search tool on table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table":           tableName,
+			"distance_metric": metric,
+		}), nil
+	}
+
+	// Each search contributes a wider candidate pool than the final limit so
+	// rows ranked moderately by both can surface after fusion
+	candidates := limit * 4
+	if candidates < 40 {
+		candidates = 40
+	}
+
+	results, err := t.executor.ExecuteQuery(ctx, query, []interface{}{formatVectorFromInterface(queryVector), sparseValue, candidates, limit})
+	if err != nil {
+		t.logger.Error("Dense+sparse vector search failed", err, map[string]interface{}{"table": tableName, "vector_column": vectorColumn, "sparse_column": sparseColumn})
+		return Error(fmt.Sprintf("Dense+sparse vector search execution failed: table='%s', vector_column='%s', sparse_column='%s', distance_metric='%s', fusion='%s', limit=%d, error=%v", tableName, vectorColumn, sparseColumn, metric, fusion, limit, err), "SEARCH_ERROR", map[string]interface{}{
+			"table":           tableName,
+			"vector_column":   vectorColumn,
+			"sparse_column":   sparseColumn,
+			"distance_metric": metric,
+			"fusion":          fusion,
+			"limit":           limit,
+			"error":           err.Error(),
+		}), nil
+	}
+
+	return Success(results, map[string]interface{}{
+		"count":           len(results),
+		"distance_metric": metric,
+		"table":           tableName,
+		"vector_column":   vectorColumn,
+		"sparse_column":   sparseColumn,
+		"sparse_weight":   sparseWeight,
+		"fusion":          fusion,
+		"candidates":      candidates,
+		"limit":           limit,
+	}), nil
+}
+
+// GenerateSparseEmbeddingTool generates a learned sparse embedding for text
+type GenerateSparseEmbeddingTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewGenerateSparseEmbeddingTool creates a new sparse embedding tool
+func NewGenerateSparseEmbeddingTool(db *database.Database, logger *logging.Logger) *GenerateSparseEmbeddingTool {
+	return &GenerateSparseEmbeddingTool{
+		BaseTool: NewBaseTool(
+			"generate_sparse_embedding",
+			"Generate a learned sparse embedding (SPLADE or ColBERTv2) for text as a sparse_vector",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "Text to embed",
+					},
+					"model": sparseModelProperty,
+				},
+				"required": []interface{}{"text"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute generates the sparse embedding
+func (t *GenerateSparseEmbeddingTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for generate_sparse_embedding tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	text, _ := params["text"].(string)
+	if text == "" {
+		return Error("text parameter is required and cannot be empty for generate_sparse_embedding tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "text",
+		}), nil
+	}
+	model := stringParam(params, "model", "splade")
+	fn, ok := sparseEmbedFunctions[model]
+	if !ok {
+		return Error(fmt.Sprintf("Unknown sparse model '%s': expected splade or colbertv2", model), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "model",
+			"model":     model,
+		}), nil
+	}
+
+	query := fmt.Sprintf("SELECT %s($1)::text AS sparse_vector", fn)
+	result, err := t.executor.ExecuteQueryOneWithTimeout(ctx, query, []interface{}{text}, EmbeddingQueryTimeout)
+	if err != nil {
+		t.logger.Error("Sparse embedding generation failed", err, map[string]interface{}{"model": model})
+		return Error(fmt.Sprintf("Sparse embedding generation failed: model='%s', text_length=%d, error=%v", model, len(text), err), "EMBEDDING_ERROR", map[string]interface{}{
+			"model":       model,
+			"text_length": len(text),
+			"error":       err.Error(),
+		}), nil
+	}
+
+	return Success(result, map[string]interface{}{
+		"model":       model,
+		"text_length": len(text),
+	}), nil
+}
+
+// SparseEmbedColumnTool stores sparse embeddings of a text column in a
+// sparse_vector column
+type SparseEmbedColumnTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewSparseEmbedColumnTool creates a new sparse column embedding tool
+func NewSparseEmbedColumnTool(db *database.Database, logger *logging.Logger) *SparseEmbedColumnTool {
+	return &SparseEmbedColumnTool{
+		BaseTool: NewBaseTool(
+			"sparse_embed_column",
+			"Embed a text column with a sparse model and store the results in a sparse_vector column, adding the column if it does not exist",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"text_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Column holding the text to embed",
+					},
+					"sparse_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "sparse_embedding",
+						"description": "sparse_vector column to write",
+					},
+					"model": sparseModelProperty,
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"minimum":     1,
+						"description": "Embed at most this many rows per call; call again until rows_updated is 0",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Re-embed rows that already have a sparse embedding",
+					},
+				},
+				"required": []interface{}{"table", "text_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute embeds the column
+func (t *SparseEmbedColumnTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for sparse_embed_column tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	tableName, _ := params["table"].(string)
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	textColumn, _ := params["text_column"].(string)
+	if textColumn == "" {
+		return Error(fmt.Sprintf("text_column parameter is required and cannot be empty for sparse_embed_column tool on table '%s'", tableName), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "text_column",
+		}), nil
+	}
+	sparseColumn := stringParam(params, "sparse_column", "sparse_embedding")
+	model := stringParam(params, "model", "splade")
+	fn, ok := sparseEmbedFunctions[model]
+	if !ok {
+		return Error(fmt.Sprintf("Unknown sparse model '%s': expected splade or colbertv2", model), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "model",
+			"model":     model,
+		}), nil
+	}
+	limit := 0
+	if l, ok := params["limit"].(float64); ok {
+		limit = int(l)
+	}
+	overwrite, _ := params["overwrite"].(bool)
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("Database connection not available for sparse_embed_column tool", "DATABASE_ERROR", nil), nil
+	}
+
+	textCol := pgx.Identifier{textColumn}.Sanitize()
+	sparseCol := pgx.Identifier{sparseColumn}.Sanitize()
+	where := textCol + " IS NOT NULL"
+	if !overwrite {
+		where += " AND " + sparseCol + " IS NULL"
+	}
+	update := fmt.Sprintf("UPDATE %s SET %s = %s(%s) WHERE %s", table.Sanitize(), sparseCol, fn, textCol, where)
+	if limit > 0 {
+		update = fmt.Sprintf("UPDATE %s SET %s = %s(%s) WHERE ctid IN (SELECT ctid FROM %s WHERE %s LIMIT %d)",
+			table.Sanitize(), sparseCol, fn, textCol, table.Sanitize(), where, limit)
+	}
+
+	alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s sparse_vector", table.Sanitize(), sparseCol)
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, db, t.Name(), []PlannedStatement{
+			{SQL: alter, Note: "adds the column when it is missing"},
+			{SQL: update},
+		}, []Permission{
+			tablePermission("OWNER", table.Sanitize()),
+			tablePermission("UPDATE", table.Sanitize()),
+			functionPermission(fn),
+		}), nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, EmbeddingQueryTimeout)
+	defer cancel()
+
+	if _, err := db.Exec(queryCtx, alter); err != nil {
+		t.logger.Error("Adding sparse column failed", err, params)
+		return Error(fmt.Sprintf("Failed to add sparse_vector column: table='%s', sparse_column='%s', error=%v", tableName, sparseColumn, err), "DATABASE_ERROR", map[string]interface{}{
+			"table":         tableName,
+			"sparse_column": sparseColumn,
+			"error":         err.Error(),
+		}), nil
+	}
+
+	tag, err := db.Exec(queryCtx, update)
+	if err != nil {
+		t.logger.Error("Sparse column embedding failed", err, params)
+		return Error(fmt.Sprintf("Sparse embedding of column failed: table='%s', text_column='%s', sparse_column='%s', model='%s', error=%v", tableName, textColumn, sparseColumn, model, err), "EMBEDDING_ERROR", map[string]interface{}{
+			"table":         tableName,
+			"text_column":   textColumn,
+			"sparse_column": sparseColumn,
+			"model":         model,
+			"error":         err.Error(),
+		}), nil
+	}
+
+	return Success(map[string]interface{}{
+		"table":         tableName,
+		"text_column":   textColumn,
+		"sparse_column": sparseColumn,
+		"model":         model,
+		"rows_updated":  tag.RowsAffected(),
+	}, nil), nil
+}
+
+// SparseSearchTool ranks rows by the dot product of a sparse_vector column
+// with a sparse query
+type SparseSearchTool struct {
+	*BaseTool
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewSparseSearchTool creates a new sparse search tool
+func NewSparseSearchTool(db *database.Database, logger *logging.Logger) *SparseSearchTool {
+	return &SparseSearchTool{
+		BaseTool: NewBaseTool(
+			"sparse_search",
+			"Search a sparse_vector column by sparse dot product, with the query given as text (embedded with SPLADE or ColBERTv2) or as a sparse vector",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"sparse_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "sparse_embedding",
+						"description": "sparse_vector column to search",
+					},
+					"query_text": map[string]interface{}{
+						"type":        "string",
+						"description": "Query text, embedded with model",
+					},
+					"query_sparse": sparseQueryProperty,
+					"model":        sparseModelProperty,
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     10,
+						"minimum":     1,
+						"maximum":     1000,
+						"description": "Maximum number of results",
+					},
+					"additional_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Columns to return; all columns when omitted",
+					},
+				},
+				"required": []interface{}{"table"},
+			},
+		),
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// Execute executes the sparse search
+func (t *SparseSearchTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for sparse_search tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+
+	tableName, _ := params["table"].(string)
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	sparseColumn := stringParam(params, "sparse_column", "sparse_embedding")
+	limit := 10
+	if l, ok := params["limit"].(float64); ok {
+		limit = int(l)
+	}
+	additionalColumns, _ := params["additional_columns"].([]interface{})
+
+	queryExpr, queryValue, err := sparseQueryExpr(params, "query_text", "model", 1)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid sparse query for sparse_search tool on table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": tableName,
+		}), nil
+	}
+	query, err := buildSparseSearchQuery(table, sparseColumn, queryExpr, additionalColumns)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid parameters for sparse_search tool on table '%s': %v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": tableName,
+		}), nil
+	}
+
+	results, err := t.executor.ExecuteQuery(ctx, query, []interface{}{queryValue, limit})
+	if err != nil {
+		t.logger.Error("Sparse search failed", err, map[string]interface{}{"table": tableName, "sparse_column": sparseColumn})
+		return Error(fmt.Sprintf("Sparse search execution failed: table='%s', sparse_column='%s', limit=%d, error=%v", tableName, sparseColumn, limit, err), "SEARCH_ERROR", map[string]interface{}{
+			"table":         tableName,
+			"sparse_column": sparseColumn,
+			"limit":         limit,
+			"error":         err.Error(),
+		}), nil
+	}
+
+	return Success(results, map[string]interface{}{
+		"count":         len(results),
+		"table":         tableName,
+		"sparse_column": sparseColumn,
+		"limit":         limit,
+	}), nil
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestFormatSparseVector(t *testing.T) {
+	got, err := formatSparseVector(map[string]interface{}{
+		"tokens":  []interface{}{float64(101), float64(2054)},
+		"weights": []interface{}{0.5, 1.25},
+		"model":   "bm25",
+	})
+	if err != nil {
+		t.Fatalf("formatSparseVector() error = %v", err)
+	}
+	want := `{"model":"BM25","tokens":[101,2054],"vocab_size":30522,"weights":[0.5,1.25]}`
+	if got != want {
+		t.Errorf("formatSparseVector() = %s, want %s", got, want)
+	}
+
+	invalid := []map[string]interface{}{
+		{"tokens": []interface{}{}, "weights": []interface{}{}},
+		{"tokens": []interface{}{float64(1)}, "weights": []interface{}{0.5, 0.5}},
+		{"tokens": []interface{}{1.5}, "weights": []interface{}{0.5}},
+		{"tokens": []interface{}{float64(40000)}, "weights": []interface{}{0.5}},
+		{"tokens": []interface{}{float64(1)}, "weights": []interface{}{"x"}},
+		{"tokens": []interface{}{float64(1)}, "weights": []interface{}{0.5}, "model": "word2vec"},
+	}
+	for i, v := range invalid {
+		if _, err := formatSparseVector(v); err == nil {
+			t.Errorf("case %d: formatSparseVector(%v) succeeded", i, v)
+		}
+	}
+}
+
+func TestSparseQueryExpr(t *testing.T) {
+	expr, value, err := sparseQueryExpr(map[string]interface{}{"query_text": "hello", "model": "colbertv2"}, "query_text", "model", 2)
+	if err != nil || expr != "colbertv2_embed($2::text)" || value != "hello" {
+		t.Errorf("text query = (%q, %v, %v)", expr, value, err)
+	}
+
+	expr, _, err = sparseQueryExpr(map[string]interface{}{"query_sparse": "{}", "query_text": "ignored"}, "query_text", "model", 1)
+	if err != nil || expr != "$1::sparse_vector" {
+		t.Errorf("literal query = (%q, %v)", expr, err)
+	}
+
+	if _, _, err := sparseQueryExpr(map[string]interface{}{}, "query_text", "model", 1); err == nil {
+		t.Error("query without text or literal succeeded")
+	}
+	if _, _, err := sparseQueryExpr(map[string]interface{}{"query_text": "x", "model": "bm25"}, "query_text", "model", 1); err == nil {
+		t.Error("text query with a model that cannot embed succeeded")
+	}
+}
+
+func TestBuildSparseQueries(t *testing.T) {
+	table := pgx.Identifier{"public", "docs"}
+
+	query, err := buildSparseSearchQuery(table, "sparse", "$1::sparse_vector", []interface{}{"id", `ti"tle`})
+	if err != nil {
+		t.Fatalf("buildSparseSearchQuery() error = %v", err)
+	}
+	for _, want := range []string{`t."id", t."ti""tle"`, `FROM "public"."docs" t, q`, `sparse_vector_dot_product(t."sparse", q.v)`} {
+		if !strings.Contains(query, want) {
+			t.Errorf("sparse query missing %q:\n%s", want, query)
+		}
+	}
+
+	query, err = buildDenseSparseQuery(table, "embedding", "sparse", "cosine", "splade_embed($2::text)", "rrf", 0.5, nil)
+	if err != nil {
+		t.Fatalf("buildDenseSparseQuery() error = %v", err)
+	}
+	for _, want := range []string{`"embedding" <=> $1::vector`, "SELECT splade_embed($2::text) AS v", "0.5 / (60 + dense_rank)", "LIMIT $4"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("hybrid query missing %q:\n%s", want, query)
+		}
+	}
+
+	if _, err := buildDenseSparseQuery(table, "embedding", "sparse", "hamming", "$2::sparse_vector", "weighted", 0.5, nil); err == nil {
+		t.Error("hybrid query with an unsupported metric succeeded")
+	}
+}
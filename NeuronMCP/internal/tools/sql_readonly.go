@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// run_sql_readonly limits
+const (
+	defaultReadOnlyLimit     = 1000
+	maxReadOnlyLimit         = 10000
+	defaultReadOnlyTimeoutMs = 30000
+	maxReadOnlyTimeoutMs     = 300000
+)
+
+type readOnlyRoleKey struct{}
+
+// WithReadOnlyRole returns a context whose caller-written queries run as
+// role. Such a role lacks EXECUTE on functions with side effects, which
+// stops calls CheckReadOnlyQuery cannot see, such as those in SQL strings.
+func WithReadOnlyRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, readOnlyRoleKey{}, role)
+}
+
+// beginReadOnly begins a READ ONLY transaction on conn for a query written by
+// a caller, switched to the context's read-only role when one is configured
+func beginReadOnly(ctx context.Context, conn *pgxpool.Conn) (pgx.Tx, error) {
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	if role, _ := ctx.Value(readOnlyRoleKey{}).(string); role != "" {
+		if _, err := tx.Exec(ctx, "SET LOCAL ROLE "+pgx.Identifier{role}.Sanitize()); err != nil {
+			tx.Rollback(context.Background())
+			return nil, fmt.Errorf("failed to switch to read-only role '%s': %w", role, err)
+		}
+	}
+	return tx, nil
+}
+
+// RunSQLReadOnlyTool runs an arbitrary query in a read-only transaction
+type RunSQLReadOnlyTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewRunSQLReadOnlyTool creates a new run SQL read-only tool
+func NewRunSQLReadOnlyTool(db *database.Database, logger *logging.Logger) *RunSQLReadOnlyTool {
+	return &RunSQLReadOnlyTool{
+		BaseTool: NewBaseTool(
+			"run_sql_readonly",
+			"Run a SELECT, WITH, VALUES or TABLE query in a READ ONLY transaction with a statement timeout and a row limit. Queries that write, lock rows or call functions with side effects are rejected before they run.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "A single read-only SELECT, WITH, VALUES or TABLE statement; use $1, $2, ... for params",
+					},
+					"params": map[string]interface{}{
+						"type":        "array",
+						"description": "Values bound to $1, $2, ... in order: strings, numbers, booleans or null",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultReadOnlyLimit,
+						"minimum":     1,
+						"maximum":     maxReadOnlyLimit,
+						"description": "Maximum number of rows returned",
+					},
+					"timeout_ms": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultReadOnlyTimeoutMs,
+						"minimum":     1,
+						"maximum":     maxReadOnlyTimeoutMs,
+						"description": "statement_timeout of the query in milliseconds",
+					},
+				},
+				"required": []interface{}{"query"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute checks and runs the query
+func (t *RunSQLReadOnlyTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for run_sql_readonly tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{"errors": errs, "params": params}), nil
+	}
+
+	query, _ := params["query"].(string)
+	query = strings.TrimSpace(query)
+	if err := database.CheckReadOnlyQuery(query); err != nil {
+		return Error(fmt.Sprintf("query rejected: %v", err), "VALIDATION_ERROR", map[string]interface{}{"parameter": "query"}), nil
+	}
+	// The query is wrapped in a subquery, which a trailing semicolon would end
+	query = strings.TrimSpace(strings.TrimRight(query, "; \t\r\n"))
+
+	args, errResult := readOnlyQueryArgs(params["params"])
+	if errResult != nil {
+		return errResult, nil
+	}
+	limit, errResult := intParamInRange(params, "limit", defaultReadOnlyLimit, 1, maxReadOnlyLimit)
+	if errResult != nil {
+		return errResult, nil
+	}
+	timeoutMs, errResult := intParamInRange(params, "timeout_ms", defaultReadOnlyTimeoutMs, 1, maxReadOnlyTimeoutMs)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	start := time.Now()
+	rows, err := runReadOnlyQuery(ctx, db, query, args, limit, timeoutMs)
+	if err != nil {
+		if result := readOnlyQueryFailure(err, timeoutMs); result != nil {
+			return result, nil
+		}
+		t.logger.Error("Read-only query failed", err, map[string]interface{}{
+			"query_length": len(query),
+		})
+		return Error(fmt.Sprintf("Query failed: %v", err), "QUERY_ERROR", map[string]interface{}{
+			"error": err.Error(),
+		}), nil
+	}
+
+	truncated := len(rows) > limit
+	if truncated {
+		rows = rows[:limit]
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	return Success(map[string]interface{}{
+		"rows":      rows,
+		"row_count": len(rows),
+		"truncated": truncated,
+	}, map[string]interface{}{
+		"elapsed_ms": msSince(start),
+		"limit":      limit,
+		"timeout_ms": timeoutMs,
+	}), nil
+}
+
+// readOnlyQueryFailure reports a query that PostgreSQL rejected, or
+// returns nil for other errors
+func readOnlyQueryFailure(err error, timeoutMs int) *ToolResult {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case "57014": // query_canceled, raised by statement_timeout
+		return Error(fmt.Sprintf("query exceeded the timeout of %d ms", timeoutMs), "QUERY_TIMEOUT", map[string]interface{}{
+			"timeout_ms": timeoutMs,
+		})
+	case "25006": // read_only_sql_transaction
+		return Error(fmt.Sprintf("query tried to write in a read-only transaction: %s", pgErr.Message), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "query",
+			"sqlstate":  pgErr.Code,
+		})
+	}
+	return Error(fmt.Sprintf("Query failed: %s", pgErr.Message), "QUERY_ERROR", map[string]interface{}{
+		"sqlstate": pgErr.Code,
+		"detail":   pgErr.Detail,
+		"hint":     pgErr.Hint,
+		"position": pgErr.Position,
+	})
+}
+
+// readOnlyQueryArgs returns the values bound to the query's placeholders
+func readOnlyQueryArgs(raw interface{}) ([]interface{}, *ToolResult) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.([]interface{})
+	if !ok {
+		return nil, Error("params must be an array", "VALIDATION_ERROR", map[string]interface{}{"parameter": "params"})
+	}
+	for i, v := range values {
+		switch v.(type) {
+		case nil, string, float64, bool:
+		default:
+			return nil, Error(fmt.Sprintf("params[%d] must be a string, number, boolean or null, got %T", i, v), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "params",
+				"index":     i,
+			})
+		}
+	}
+	return values, nil
+}
+
+// runReadOnlyQuery runs query in a READ ONLY transaction with the given
+// statement_timeout, reading at most limit+1 rows so the caller can report
+// truncation. The transaction is always rolled back.
+func runReadOnlyQuery(ctx context.Context, db *database.Database, query string, args []interface{}, limit, timeoutMs int) ([]map[string]interface{}, error) {
+	// The context outlives the statement timeout slightly, so the server
+	// reports the timeout rather than the client cancelling the query
+	queryCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond+5*time.Second)
+	defer cancel()
+	conn, err := db.Acquire(queryCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	tx, err := beginReadOnly(queryCtx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	if _, err := tx.Exec(queryCtx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeoutMs)); err != nil {
+		return nil, fmt.Errorf("failed to set statement_timeout: %w", err)
+	}
+
+	// The newlines keep a trailing line comment from hiding the end of the
+	// wrapper
+	rows, err := tx.Query(queryCtx, fmt.Sprintf("SELECT * FROM (\n%s\n) AS readonly_query LIMIT %d", query, limit+1), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRowsToMaps(queryCtx, rows)
+}
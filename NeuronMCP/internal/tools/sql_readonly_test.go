@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadOnlyQueryArgs(t *testing.T) {
+	args, errResult := readOnlyQueryArgs([]interface{}{"a", 1.0, true, nil})
+	if errResult != nil || len(args) != 4 {
+		t.Fatalf("readOnlyQueryArgs = %v, %v", args, errResult)
+	}
+	if args, errResult := readOnlyQueryArgs(nil); errResult != nil || args != nil {
+		t.Errorf("readOnlyQueryArgs(nil) = %v, %v", args, errResult)
+	}
+	for _, raw := range []interface{}{"a", []interface{}{map[string]interface{}{"k": 1}}, []interface{}{[]interface{}{1.0}}} {
+		if _, errResult := readOnlyQueryArgs(raw); errResult == nil {
+			t.Errorf("readOnlyQueryArgs(%v): expected an error", raw)
+		}
+	}
+}
+
+func TestRunSQLReadOnlyRejectsWrites(t *testing.T) {
+	tool := NewRunSQLReadOnlyTool(nil, nil)
+	for _, query := range []string{
+		"DELETE FROM docs",
+		"WITH d AS (DELETE FROM docs RETURNING *) SELECT * FROM d",
+		"SELECT 1; DROP TABLE docs",
+	} {
+		result, err := tool.Execute(context.Background(), map[string]interface{}{"query": query})
+		if err != nil {
+			t.Fatalf("Execute(%q): unexpected error: %v", query, err)
+		}
+		if result.Success || result.Error == nil || result.Error.Code != "VALIDATION_ERROR" {
+			t.Errorf("Execute(%q) = %+v, want a validation error", query, result)
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// generate_test_data limits
+const (
+	defaultTestDataRows      = 10000
+	maxTestDataRows          = 1000000
+	defaultTestDataDimension = 128
+	maxTestDataDimension     = 4096
+	defaultTestDataClusters  = 10
+	maxTestDataClusters      = 1000
+	defaultTestDataSpread    = 0.1
+	maxTestDataSpread        = 10
+	// testDataBatchRows is the number of rows written by one INSERT
+	testDataBatchRows = 1000
+)
+
+// Synthetic texts are made of words of a vocabulary of pseudo-words: each
+// cluster has its own topic words, and all clusters share filler words
+const (
+	testDataTopicWords  = 12
+	testDataFillerWords = 60
+	// testDataTopicShare is the share of a text's words taken from the
+	// topic words of its cluster
+	testDataTopicShare = 0.6
+	testDataMinWords   = 8
+	testDataMaxWords   = 24
+)
+
+// testDataSyllables build the pseudo-words of synthetic texts
+var testDataSyllables = []string{
+	"ka", "lo", "mi", "ne", "su", "ta", "ri", "po", "ve", "zu", "ba", "de", "fi", "go", "ha", "ju",
+	"ke", "lu", "mo", "na", "pi", "ro", "sa", "ti", "vo", "ze", "bri", "dra", "fen", "gul", "mar", "tor",
+}
+
+// GenerateTestDataTool creates a table of synthetic vectors drawn from a
+// Gaussian mixture, for benchmarking search and index tools without
+// loading a dataset
+type GenerateTestDataTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewGenerateTestDataTool creates a new test data generation tool
+func NewGenerateTestDataTool(db *database.Database, logger *logging.Logger) *GenerateTestDataTool {
+	return &GenerateTestDataTool{
+		BaseTool: NewBaseTool(
+			"generate_test_data",
+			"Create a table of synthetic vectors for benchmarking: rows drawn from a mixture of Gaussian clusters with a configurable row count, dimension and cluster spread, labeled with their cluster, optionally with synthetic text sharing words within a cluster. The same seed generates the same data.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table to create, optionally schema-qualified",
+					},
+					"rows": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultTestDataRows,
+						"minimum":     1,
+						"maximum":     maxTestDataRows,
+						"description": "Number of rows to generate",
+					},
+					"dimension": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultTestDataDimension,
+						"minimum":     1,
+						"maximum":     maxTestDataDimension,
+						"description": "Dimension of the vectors",
+					},
+					"clusters": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultTestDataClusters,
+						"minimum":     1,
+						"maximum":     maxTestDataClusters,
+						"description": "Number of Gaussian clusters; each row belongs to one, chosen uniformly",
+					},
+					"cluster_spread": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultTestDataSpread,
+						"minimum":     0,
+						"maximum":     maxTestDataSpread,
+						"description": "Standard deviation of each coordinate around its cluster center; centers are uniform in [-1, 1] per coordinate, so spreads near 1 blur the clusters together",
+					},
+					"normalize": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Scale vectors to unit length, as for cosine or inner product search",
+					},
+					"text": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Add a content column of synthetic text; texts of a cluster share topic words, for hybrid and keyword search",
+					},
+					"seed": map[string]interface{}{
+						"type":        "number",
+						"description": "Random seed; the same seed and parameters generate the same rows. A random seed is chosen and returned when omitted.",
+					},
+					"replace": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Drop the table first if it exists; otherwise an existing table is an error",
+					},
+				},
+				"required": []interface{}{"table"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// testDataSpec describes the data to generate
+type testDataSpec struct {
+	rows      int
+	dimension int
+	clusters  int
+	spread    float64
+	normalize bool
+	text      bool
+	seed      int64
+}
+
+// Execute creates and fills the table
+func (t *GenerateTestDataTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for generate_test_data tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+		}), nil
+	}
+
+	tableName, _ := params["table"].(string)
+	table, err := parseQualifiedIdentifier(tableName)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table for generate_test_data tool: table='%s', error=%v", tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"table":     tableName,
+		}), nil
+	}
+	spec, errResult := parseTestDataSpec(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+	replace, _ := params["replace"].(bool)
+
+	db := DatabaseFromContext(ctx, t.db)
+	if IsDryRun(ctx) {
+		return t.planTestData(ctx, db, table, spec, replace), nil
+	}
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for generate_test_data tool", "DATABASE_ERROR", nil), nil
+	}
+
+	if !replace {
+		var exists bool
+		queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+		err := db.QueryRow(queryCtx, "SELECT to_regclass($1) IS NOT NULL", table.Sanitize()).Scan(&exists)
+		cancel()
+		if err != nil {
+			return Error(fmt.Sprintf("Failed to look up table %s: %v", table.Sanitize(), err), "QUERY_ERROR", map[string]interface{}{
+				"table": tableName,
+				"error": err.Error(),
+			}), nil
+		}
+		if exists {
+			return Error(fmt.Sprintf("table %s already exists; set replace to drop it first", table.Sanitize()), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "table",
+				"table":     tableName,
+			}), nil
+		}
+	}
+
+	started := time.Now()
+	written, err := writeTestData(ctx, db, table, spec, replace)
+	if err != nil {
+		t.logger.Error("Test data generation failed", err, map[string]interface{}{
+			"table": tableName,
+			"rows":  spec.rows,
+		})
+		return Error(fmt.Sprintf("Failed to generate test data: table='%s', error=%v. The table was not created.", tableName, err), "DATABASE_ERROR", map[string]interface{}{
+			"table": tableName,
+			"error": err.Error(),
+		}), nil
+	}
+
+	columns := map[string]interface{}{
+		"id":         "bigint primary key, 1 to rows",
+		"cluster_id": "integer, the cluster the row was drawn from, 0 to clusters-1",
+		"embedding":  fmt.Sprintf("vector(%d)", spec.dimension),
+	}
+	if spec.text {
+		columns["content"] = "text"
+	}
+	return Success(map[string]interface{}{
+		"table":          table.Sanitize(),
+		"rows":           spec.rows,
+		"dimension":      spec.dimension,
+		"clusters":       spec.clusters,
+		"cluster_spread": spec.spread,
+		"normalized":     spec.normalize,
+		"seed":           spec.seed,
+		"columns":        columns,
+		"cluster_sizes":  written.clusterSizes,
+		"batches":        written.batches,
+		"generate_ms":    written.generateMs,
+		"insert_ms":      written.insertMs,
+		"total_ms":       msSince(started),
+	}, map[string]interface{}{
+		"seed": spec.seed,
+	}), nil
+}
+
+// parseTestDataSpec reads and checks the generation parameters
+func parseTestDataSpec(params map[string]interface{}) (testDataSpec, *ToolResult) {
+	var spec testDataSpec
+	var errResult *ToolResult
+	if spec.rows, errResult = intParamInRange(params, "rows", defaultTestDataRows, 1, maxTestDataRows); errResult != nil {
+		return spec, errResult
+	}
+	if spec.dimension, errResult = intParamInRange(params, "dimension", defaultTestDataDimension, 1, maxTestDataDimension); errResult != nil {
+		return spec, errResult
+	}
+	if spec.clusters, errResult = intParamInRange(params, "clusters", defaultTestDataClusters, 1, maxTestDataClusters); errResult != nil {
+		return spec, errResult
+	}
+	if spec.clusters > spec.rows {
+		return spec, Error(fmt.Sprintf("clusters (%d) must not exceed rows (%d)", spec.clusters, spec.rows), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "clusters",
+		})
+	}
+
+	spec.spread = defaultTestDataSpread
+	if v, ok := params["cluster_spread"].(float64); ok {
+		if v < 0 || v > maxTestDataSpread || math.IsNaN(v) {
+			return spec, Error(fmt.Sprintf("cluster_spread must be between 0 and %d, got %v", maxTestDataSpread, v), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "cluster_spread",
+			})
+		}
+		spec.spread = v
+	}
+	spec.normalize, _ = params["normalize"].(bool)
+	spec.text, _ = params["text"].(bool)
+
+	spec.seed = time.Now().UnixNano()
+	if v, ok := params["seed"].(float64); ok {
+		if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+			return spec, Error(fmt.Sprintf("seed must be an integer, got %v", v), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "seed",
+			})
+		}
+		spec.seed = int64(v)
+	}
+	return spec, nil
+}
+
+// testDataDDL returns the statements creating the table, dropping it first
+// when replace is set
+func testDataDDL(table pgx.Identifier, spec testDataSpec, replace bool) []string {
+	var statements []string
+	if replace {
+		statements = append(statements, fmt.Sprintf("DROP TABLE IF EXISTS %s", table.Sanitize()))
+	}
+	columns := fmt.Sprintf("id bigint PRIMARY KEY, cluster_id integer NOT NULL, embedding vector(%d) NOT NULL", spec.dimension)
+	if spec.text {
+		columns += ", content text NOT NULL"
+	}
+	return append(statements, fmt.Sprintf("CREATE TABLE %s (%s)", table.Sanitize(), columns))
+}
+
+// testDataInsertSQL returns the statement writing a batch of rows, given as
+// arrays of ids ($1), cluster ids ($2), vectors as text ($3) and, with text,
+// contents ($4)
+func testDataInsertSQL(table pgx.Identifier, text bool) string {
+	if text {
+		return fmt.Sprintf(`INSERT INTO %s (id, cluster_id, embedding, content)
+SELECT id, cluster_id, embedding::vector, content FROM unnest($1::bigint[], $2::integer[], $3::text[], $4::text[]) AS r(id, cluster_id, embedding, content)`,
+			table.Sanitize())
+	}
+	return fmt.Sprintf(`INSERT INTO %s (id, cluster_id, embedding)
+SELECT id, cluster_id, embedding::vector FROM unnest($1::bigint[], $2::integer[], $3::text[]) AS r(id, cluster_id, embedding)`,
+		table.Sanitize())
+}
+
+// testDataWritten reports the work of writeTestData
+type testDataWritten struct {
+	clusterSizes []int
+	batches      int
+	generateMs   float64
+	insertMs     float64
+}
+
+// writeTestData creates the table and fills it in batches, in one
+// transaction, so a failed call leaves no partial table. Progress is
+// reported after every batch.
+func writeTestData(ctx context.Context, db *database.Database, table pgx.Identifier, spec testDataSpec, replace bool) (testDataWritten, error) {
+	written := testDataWritten{clusterSizes: make([]int, spec.clusters)}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return written, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range testDataDDL(table, spec, replace) {
+		queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+		_, err := tx.Exec(queryCtx, stmt)
+		cancel()
+		if err != nil {
+			return written, err
+		}
+	}
+
+	insertSQL := testDataInsertSQL(table, spec.text)
+	generator := newTestDataGenerator(spec)
+	reportProgress(ctx, 0, float64(spec.rows), fmt.Sprintf("generating %d rows in %s", spec.rows, table.Sanitize()))
+	var generateTime, insertTime time.Duration
+	for done := 0; done < spec.rows; {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n := min(testDataBatchRows, spec.rows-done)
+
+		stageStart := time.Now()
+		ids := make([]int64, n)
+		clusters := make([]int32, n)
+		vectors := make([]string, n)
+		contents := make([]string, n)
+		for i := range ids {
+			row := generator.next()
+			ids[i], clusters[i], vectors[i], contents[i] = row.id, int32(row.cluster), formatFloat32Vector(row.vector), row.content
+			written.clusterSizes[row.cluster]++
+		}
+		generateTime += time.Since(stageStart)
+
+		stageStart = time.Now()
+		args := []interface{}{ids, clusters, vectors}
+		if spec.text {
+			args = append(args, contents)
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+		_, err := tx.Exec(queryCtx, insertSQL, args...)
+		cancel()
+		insertTime += time.Since(stageStart)
+		if err != nil {
+			return written, fmt.Errorf("failed to insert batch %d: %w", written.batches+1, err)
+		}
+		done += n
+		written.batches++
+		reportProgress(ctx, float64(done), float64(spec.rows), fmt.Sprintf("generated %d of %d rows", done, spec.rows))
+	}
+
+	stageStart := time.Now()
+	if err := tx.Commit(ctx); err != nil {
+		return written, fmt.Errorf("failed to commit: %w", err)
+	}
+	insertTime += time.Since(stageStart)
+	written.generateMs = float64(generateTime.Microseconds()) / 1000
+	written.insertMs = float64(insertTime.Microseconds()) / 1000
+	return written, nil
+}
+
+// testDataRow is one generated row
+type testDataRow struct {
+	id      int64
+	cluster int
+	vector  []float32
+	content string
+}
+
+// testDataGenerator draws the rows of a spec. Cluster centers and
+// vocabularies are drawn first, then rows one after another, all from one
+// generator seeded with spec.seed.
+type testDataGenerator struct {
+	spec    testDataSpec
+	rng     *rand.Rand
+	centers [][]float64
+	// topics holds the topic words of each cluster, filler the words all
+	// clusters share; both are empty without text
+	topics [][]string
+	filler []string
+	nextID int64
+}
+
+func newTestDataGenerator(spec testDataSpec) *testDataGenerator {
+	g := &testDataGenerator{spec: spec, rng: rand.New(rand.NewSource(spec.seed)), nextID: 1}
+	g.centers = make([][]float64, spec.clusters)
+	for c := range g.centers {
+		center := make([]float64, spec.dimension)
+		for i := range center {
+			center[i] = g.rng.Float64()*2 - 1
+		}
+		g.centers[c] = center
+	}
+	if spec.text {
+		used := make(map[string]bool)
+		g.filler = g.words(testDataFillerWords, used)
+		g.topics = make([][]string, spec.clusters)
+		for c := range g.topics {
+			g.topics[c] = g.words(testDataTopicWords, used)
+		}
+	}
+	return g
+}
+
+// next returns the next row
+func (g *testDataGenerator) next() testDataRow {
+	row := testDataRow{id: g.nextID, cluster: g.rng.Intn(g.spec.clusters)}
+	g.nextID++
+
+	center := g.centers[row.cluster]
+	values := make([]float64, len(center))
+	var norm float64
+	for i, c := range center {
+		values[i] = c + g.rng.NormFloat64()*g.spec.spread
+		norm += values[i] * values[i]
+	}
+	norm = math.Sqrt(norm)
+	row.vector = make([]float32, len(values))
+	for i, v := range values {
+		if g.spec.normalize && norm > 0 {
+			v /= norm
+		}
+		row.vector[i] = float32(v)
+	}
+
+	if g.spec.text {
+		words := make([]string, testDataMinWords+g.rng.Intn(testDataMaxWords-testDataMinWords+1))
+		for i := range words {
+			if g.rng.Float64() < testDataTopicShare {
+				words[i] = g.topics[row.cluster][g.rng.Intn(testDataTopicWords)]
+			} else {
+				words[i] = g.filler[g.rng.Intn(testDataFillerWords)]
+			}
+		}
+		row.content = strings.Join(words, " ") + "."
+	}
+	return row
+}
+
+// words draws n pseudo-words not in used, adding them to it
+func (g *testDataGenerator) words(n int, used map[string]bool) []string {
+	words := make([]string, 0, n)
+	for len(words) < n {
+		var b strings.Builder
+		for s := 2 + g.rng.Intn(2); s > 0; s-- {
+			b.WriteString(testDataSyllables[g.rng.Intn(len(testDataSyllables))])
+		}
+		// Words of two syllables run out with many clusters; longer ones
+		// keep the vocabularies apart
+		for used[b.String()] {
+			b.WriteString(testDataSyllables[g.rng.Intn(len(testDataSyllables))])
+		}
+		used[b.String()] = true
+		words = append(words, b.String())
+	}
+	return words
+}
+
+// planTestData returns the dry run plan of a call. Nothing is generated.
+func (t *GenerateTestDataTool) planTestData(ctx context.Context, db *database.Database, table pgx.Identifier, spec testDataSpec, replace bool) *ToolResult {
+	zero := int64(0)
+	var statements []PlannedStatement
+	for _, stmt := range testDataDDL(table, spec, replace) {
+		statements = append(statements, PlannedStatement{SQL: stmt, EstimatedRows: &zero})
+	}
+	rows := int64(spec.rows)
+	batches := (spec.rows + testDataBatchRows - 1) / testDataBatchRows
+	statements = append(statements, PlannedStatement{
+		SQL:           testDataInsertSQL(table, spec.text),
+		EstimatedRows: &rows,
+		Note:          fmt.Sprintf("runs %d times with up to %d generated rows each, in the transaction creating the table", batches, testDataBatchRows),
+	})
+
+	permissions := []Permission{schemaPermission("CREATE", schemaOf(table))}
+	if replace {
+		permissions = append(permissions, tablePermission("OWNER", table.Sanitize()))
+	}
+	return dryRunResult(ctx, db, t.Name(), statements, permissions,
+		fmt.Sprintf("%d rows of dimension %d in %d clusters with spread %g, seed %d", spec.rows, spec.dimension, spec.clusters, spec.spread, spec.seed))
+}
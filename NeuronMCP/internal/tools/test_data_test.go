@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestTestDataGeneratorIsSeeded(t *testing.T) {
+	spec := testDataSpec{rows: 50, dimension: 8, clusters: 3, spread: 0.1, text: true, seed: 42}
+	a, b := newTestDataGenerator(spec), newTestDataGenerator(spec)
+	for i := 0; i < spec.rows; i++ {
+		if ra, rb := a.next(), b.next(); !reflect.DeepEqual(ra, rb) {
+			t.Fatalf("row %d differs with the same seed: %+v, %+v", i, ra, rb)
+		}
+	}
+	spec.seed = 43
+	if reflect.DeepEqual(newTestDataGenerator(spec).next(), newTestDataGenerator(testDataSpec{rows: 50, dimension: 8, clusters: 3, spread: 0.1, text: true, seed: 42}).next()) {
+		t.Error("another seed generated the same row")
+	}
+}
+
+func TestTestDataGeneratorClusters(t *testing.T) {
+	spec := testDataSpec{rows: 300, dimension: 16, clusters: 4, spread: 0.05, text: true, seed: 1}
+	g := newTestDataGenerator(spec)
+	for i := 0; i < spec.rows; i++ {
+		row := g.next()
+		if row.id != int64(i+1) {
+			t.Fatalf("row %d has id %d", i, row.id)
+		}
+		// A tight cluster puts each row nearest its own center
+		nearest, best := -1, math.Inf(1)
+		for c, center := range g.centers {
+			var d float64
+			for j, v := range row.vector {
+				d += (float64(v) - center[j]) * (float64(v) - center[j])
+			}
+			if d < best {
+				nearest, best = c, d
+			}
+		}
+		if nearest != row.cluster {
+			t.Fatalf("row %d of cluster %d is nearest center %d", row.id, row.cluster, nearest)
+		}
+		words := strings.Fields(strings.TrimSuffix(row.content, "."))
+		if len(words) < testDataMinWords || len(words) > testDataMaxWords {
+			t.Fatalf("row %d has %d words", row.id, len(words))
+		}
+	}
+}
+
+func TestTestDataGeneratorNormalize(t *testing.T) {
+	g := newTestDataGenerator(testDataSpec{rows: 10, dimension: 32, clusters: 2, spread: 0.5, normalize: true, seed: 7})
+	for i := 0; i < 10; i++ {
+		var norm float64
+		for _, v := range g.next().vector {
+			norm += float64(v) * float64(v)
+		}
+		if math.Abs(math.Sqrt(norm)-1) > 1e-5 {
+			t.Errorf("norm = %g, want 1", math.Sqrt(norm))
+		}
+	}
+}
+
+func TestTestDataSQL(t *testing.T) {
+	table := pgx.Identifier{"bench", "points"}
+	ddl := testDataDDL(table, testDataSpec{dimension: 64, text: true}, true)
+	want := []string{
+		`DROP TABLE IF EXISTS "bench"."points"`,
+		`CREATE TABLE "bench"."points" (id bigint PRIMARY KEY, cluster_id integer NOT NULL, embedding vector(64) NOT NULL, content text NOT NULL)`,
+	}
+	if !reflect.DeepEqual(ddl, want) {
+		t.Errorf("ddl = %q, want %q", ddl, want)
+	}
+	if insert := testDataInsertSQL(table, false); strings.Contains(insert, "content") || !strings.Contains(insert, "$3::text[]") {
+		t.Errorf("insert without text = %q", insert)
+	}
+}
+
+func TestParseTestDataSpec(t *testing.T) {
+	spec, errResult := parseTestDataSpec(map[string]interface{}{"seed": float64(9)})
+	if errResult != nil {
+		t.Fatalf("defaults: %v", errResult.Error)
+	}
+	if spec.rows != defaultTestDataRows || spec.dimension != defaultTestDataDimension || spec.clusters != defaultTestDataClusters || spec.seed != 9 {
+		t.Errorf("spec = %+v", spec)
+	}
+	for name, params := range map[string]map[string]interface{}{
+		"more clusters than rows": {"rows": float64(5), "clusters": float64(6)},
+		"negative spread":         {"cluster_spread": -0.1},
+		"fractional seed":         {"seed": 1.5},
+		"dimension too large":     {"dimension": float64(maxTestDataDimension + 1)},
+	} {
+		if _, errResult := parseTestDataSpec(params); errResult == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
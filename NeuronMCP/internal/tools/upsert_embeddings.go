@@ -0,0 +1,710 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// upsert_embeddings limits
+const (
+	maxUpsertRows          = 10000
+	defaultUpsertBatchSize = 64
+	maxUpsertBatchSize     = 1000
+)
+
+// UpsertEmbeddingsTool keeps a table of embedded texts in sync with a source:
+// only new and changed texts are embedded, and rows are written with
+// INSERT ... ON CONFLICT DO UPDATE, so the same call can be repeated safely
+type UpsertEmbeddingsTool struct {
+	*BaseTool
+	db       *database.Database
+	executor *QueryExecutor
+	logger   *logging.Logger
+}
+
+// NewUpsertEmbeddingsTool creates a new upsert embeddings tool
+func NewUpsertEmbeddingsTool(db *database.Database, logger *logging.Logger) *UpsertEmbeddingsTool {
+	return &UpsertEmbeddingsTool{
+		BaseTool: NewBaseTool(
+			"upsert_embeddings",
+			"Insert or update rows of (id, text, metadata) with their embeddings. Texts whose content hash matches the stored row are not embedded again, and each batch is committed on its own, so a failed or repeated call can simply be retried.",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Target table, optionally schema-qualified",
+					},
+					"rows": map[string]interface{}{
+						"type":        "array",
+						"description": fmt.Sprintf("Rows to upsert, at most %d: objects with id (string or integer), text and an optional metadata object", maxUpsertRows),
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"id":       map[string]interface{}{"type": []interface{}{"string", "number"}},
+								"text":     map[string]interface{}{"type": "string"},
+								"metadata": map[string]interface{}{"type": "object"},
+							},
+							"required": []interface{}{"id", "text"},
+						},
+					},
+					"id_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column holding the row id; it needs a primary key or unique constraint",
+					},
+					"text_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "content",
+						"description": "Column receiving the text",
+					},
+					"embedding_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "embedding",
+						"description": "Vector column receiving the embedding",
+					},
+					"metadata_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "metadata",
+						"description": "JSONB column receiving the metadata",
+					},
+					"hash_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "content_hash",
+						"description": "Text column holding the content hash of the embedded text",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "Embedding model (optional); part of the content hash, so changing it re-embeds every row",
+					},
+					"batch_size": map[string]interface{}{
+						"type":        "number",
+						"default":     defaultUpsertBatchSize,
+						"minimum":     1,
+						"maximum":     maxUpsertBatchSize,
+						"description": "Rows embedded and committed together",
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Embed and write every row, even when its content hash is unchanged",
+					},
+					"create_table": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Create the table if it does not exist, and add a missing hash column",
+					},
+				},
+				"required": []interface{}{"table", "rows"},
+			},
+		),
+		db:       db,
+		executor: NewQueryExecutor(db),
+		logger:   logger,
+	}
+}
+
+// upsertRow is a row to upsert. metadata is nil when the row has none, which
+// keeps the stored metadata of an existing row.
+type upsertRow struct {
+	id       string
+	numeric  bool
+	text     string
+	metadata *string
+	hash     string
+}
+
+// upsertTarget is the table written and its columns
+type upsertTarget struct {
+	table           pgx.Identifier
+	idColumn        string
+	textColumn      string
+	embeddingColumn string
+	metadataColumn  string
+	hashColumn      string
+	// idType is the SQL type of the id column, which ids are cast to
+	idType string
+}
+
+// upsertCounts reports what an upsert did with the rows
+type upsertCounts struct {
+	RowsReceived    int `json:"rows_received"`
+	Inserted        int `json:"inserted"`
+	Updated         int `json:"updated"`
+	MetadataUpdated int `json:"metadata_updated"`
+	Skipped         int `json:"skipped"`
+	Embedded        int `json:"embedded"`
+	Batches         int `json:"batches"`
+}
+
+// upsertPlan sorts the rows by what has to happen to them
+type upsertPlan struct {
+	embed        []upsertRow // new or changed texts
+	metadataOnly []upsertRow // unchanged texts with metadata to write
+	skipped      int         // unchanged texts without metadata
+}
+
+// Execute upserts the rows
+func (t *UpsertEmbeddingsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errs := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for upsert_embeddings tool: %v", errs), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errs,
+		}), nil
+	}
+
+	table, _ := params["table"].(string)
+	tableIdent, err := parseQualifiedIdentifier(table)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table for upsert_embeddings tool: table='%s', error=%v", table, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"table":     table,
+		}), nil
+	}
+	target := upsertTarget{
+		table:           tableIdent,
+		idColumn:        stringParam(params, "id_column", "id"),
+		textColumn:      stringParam(params, "text_column", "content"),
+		embeddingColumn: stringParam(params, "embedding_column", "embedding"),
+		metadataColumn:  stringParam(params, "metadata_column", "metadata"),
+		hashColumn:      stringParam(params, "hash_column", "content_hash"),
+	}
+	if err := target.validateColumns(); err != nil {
+		return Error(err.Error(), "VALIDATION_ERROR", nil), nil
+	}
+	model := stringParam(params, "model", "default")
+	rows, errResult := parseUpsertRows(params["rows"], model)
+	if errResult != nil {
+		return errResult, nil
+	}
+	batchSize, errResult := intParamInRange(params, "batch_size", defaultUpsertBatchSize, 1, maxUpsertBatchSize)
+	if errResult != nil {
+		return errResult, nil
+	}
+	force, _ := params["force"].(bool)
+	createTable, _ := params["create_table"].(bool)
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for upsert_embeddings tool", "DATABASE_ERROR", nil), nil
+	}
+
+	started := time.Now()
+	exists, columns, err := upsertTableColumns(ctx, db, target)
+	if err != nil {
+		return Error(fmt.Sprintf("Failed to inspect table %s: %v", tableIdent.Sanitize(), err), "QUERY_ERROR", map[string]interface{}{
+			"table": table,
+			"error": err.Error(),
+		}), nil
+	}
+	addHashColumn := false
+	if exists {
+		for _, column := range []string{target.idColumn, target.textColumn, target.embeddingColumn, target.metadataColumn} {
+			if _, ok := columns[column]; !ok {
+				return Error(fmt.Sprintf("table %s has no column '%s'", tableIdent.Sanitize(), column), "VALIDATION_ERROR", map[string]interface{}{
+					"table":  table,
+					"column": column,
+				}), nil
+			}
+		}
+		if _, ok := columns[target.hashColumn]; !ok {
+			if !createTable {
+				return Error(fmt.Sprintf("table %s has no hash column '%s'; add it with ALTER TABLE %s ADD COLUMN %s text, or set create_table to add it",
+					tableIdent.Sanitize(), target.hashColumn, tableIdent.Sanitize(), pgx.Identifier{target.hashColumn}.Sanitize()), "VALIDATION_ERROR", map[string]interface{}{
+					"table":     table,
+					"parameter": "hash_column",
+				}), nil
+			}
+			addHashColumn = true
+		}
+		target.idType = columns[target.idColumn]
+	} else {
+		if !createTable {
+			return Error(fmt.Sprintf("table %s does not exist; set create_table to create it", tableIdent.Sanitize()), "VALIDATION_ERROR", map[string]interface{}{
+				"table": table,
+			}), nil
+		}
+		target.idType = upsertIDType(rows)
+	}
+
+	stored := map[string]string{}
+	if exists && !addHashColumn {
+		if stored, err = storedContentHashes(ctx, db, target, rows); err != nil {
+			return Error(fmt.Sprintf("Failed to read stored content hashes from %s: %v", tableIdent.Sanitize(), err), "QUERY_ERROR", map[string]interface{}{
+				"table": table,
+				"error": err.Error(),
+			}), nil
+		}
+	}
+	plan := planUpsert(rows, stored, force)
+
+	if IsDryRun(ctx) {
+		return t.planUpsertStatements(ctx, target, plan, exists, addHashColumn, force, model, batchSize), nil
+	}
+
+	counts := upsertCounts{RowsReceived: len(rows), Skipped: plan.skipped}
+	var embedTime, writeTime time.Duration
+	dimension := 0
+	fail := func(stage string, err error) (*ToolResult, error) {
+		t.logger.Error("Embedding upsert failed", err, map[string]interface{}{
+			"table": table,
+			"stage": stage,
+		})
+		return Error(fmt.Sprintf("Embedding upsert failed while %s: table='%s', error=%v. Batches already committed are skipped when the call is retried.", stage, table, err), "INSERT_ERROR", map[string]interface{}{
+			"table":     table,
+			"stage":     stage,
+			"committed": counts,
+			"error":     err.Error(),
+		}), nil
+	}
+
+	if addHashColumn {
+		if _, err := db.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s text", tableIdent.Sanitize(), pgx.Identifier{target.hashColumn}.Sanitize())); err != nil {
+			return fail("adding the hash column", err)
+		}
+	}
+
+	for start := 0; start < len(plan.embed); start += batchSize {
+		end := start + batchSize
+		if end > len(plan.embed) {
+			end = len(plan.embed)
+		}
+		batch := plan.embed[start:end]
+		texts := make([]string, len(batch))
+		for i, row := range batch {
+			texts[i] = row.text
+		}
+
+		stageStart := time.Now()
+		vectors, err := embedBatch(ctx, t.executor, model, texts)
+		embedTime += time.Since(stageStart)
+		if err != nil {
+			return fail(fmt.Sprintf("embedding rows %d-%d", start, end-1), err)
+		}
+		counts.Embedded += len(vectors)
+		if dimension == 0 && len(vectors) > 0 {
+			dimension = len(vectors[0])
+		}
+		if !exists {
+			if _, err := db.Exec(ctx, upsertTableDDL(target, dimension)); err != nil {
+				return fail("creating the table", err)
+			}
+			exists = true
+		}
+
+		stageStart = time.Now()
+		inserted, updated, err := writeUpsertBatch(ctx, db, target, force, batch, vectors)
+		writeTime += time.Since(stageStart)
+		if err != nil {
+			return fail(fmt.Sprintf("writing rows %d-%d", start, end-1), err)
+		}
+		counts.Inserted += inserted
+		counts.Updated += updated
+		// Rows another call stored with the same content since the hashes
+		// were read are left alone
+		counts.Skipped += len(batch) - inserted - updated
+		counts.Batches++
+	}
+
+	for start := 0; start < len(plan.metadataOnly); start += batchSize {
+		end := start + batchSize
+		if end > len(plan.metadataOnly) {
+			end = len(plan.metadataOnly)
+		}
+		stageStart := time.Now()
+		updated, err := writeUpsertMetadata(ctx, db, target, plan.metadataOnly[start:end])
+		writeTime += time.Since(stageStart)
+		if err != nil {
+			return fail("updating metadata", err)
+		}
+		counts.MetadataUpdated += updated
+		counts.Skipped += end - start - updated
+		counts.Batches++
+	}
+
+	result := map[string]interface{}{
+		"table":            tableIdent.Sanitize(),
+		"rows_received":    counts.RowsReceived,
+		"inserted":         counts.Inserted,
+		"updated":          counts.Updated,
+		"metadata_updated": counts.MetadataUpdated,
+		"skipped":          counts.Skipped,
+		"embedded":         counts.Embedded,
+		"batches":          counts.Batches,
+		"timings": map[string]interface{}{
+			"embed_ms": float64(embedTime.Microseconds()) / 1000,
+			"write_ms": float64(writeTime.Microseconds()) / 1000,
+			"total_ms": msSince(started),
+		},
+	}
+	if dimension > 0 {
+		result["embedding_dimension"] = dimension
+	}
+	return Success(result, map[string]interface{}{
+		"model":      model,
+		"batch_size": batchSize,
+		"force":      force,
+	}), nil
+}
+
+// validateColumns checks that the target columns are distinct
+func (u upsertTarget) validateColumns() error {
+	seen := map[string]string{}
+	for _, c := range []struct{ param, name string }{
+		{"id_column", u.idColumn},
+		{"text_column", u.textColumn},
+		{"embedding_column", u.embeddingColumn},
+		{"metadata_column", u.metadataColumn},
+		{"hash_column", u.hashColumn},
+	} {
+		if other, ok := seen[c.name]; ok {
+			return fmt.Errorf("%s and %s both name column '%s'", other, c.param, c.name)
+		}
+		seen[c.name] = c.param
+	}
+	return nil
+}
+
+// parseUpsertRows reads the rows parameter and computes each row's content
+// hash. Integer ids are kept in their decimal form.
+func parseUpsertRows(raw interface{}, model string) ([]upsertRow, *ToolResult) {
+	items, ok := raw.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, Error("rows must be a non-empty array", "VALIDATION_ERROR", map[string]interface{}{"parameter": "rows"})
+	}
+	if len(items) > maxUpsertRows {
+		return nil, Error(fmt.Sprintf("rows may hold at most %d rows per call, got %d", maxUpsertRows, len(items)), "VALIDATION_ERROR", map[string]interface{}{"parameter": "rows"})
+	}
+
+	rows := make([]upsertRow, 0, len(items))
+	seen := make(map[string]int, len(items))
+	for i, item := range items {
+		invalid := func(msg string) ([]upsertRow, *ToolResult) {
+			return nil, Error(fmt.Sprintf("rows[%d]: %s", i, msg), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "rows",
+				"index":     i,
+			})
+		}
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return invalid("must be an object")
+		}
+
+		var row upsertRow
+		switch id := obj["id"].(type) {
+		case string:
+			if id == "" {
+				return invalid("id must not be empty")
+			}
+			row.id = id
+		case float64:
+			if id != math.Trunc(id) || math.Abs(id) > 1<<53 {
+				return invalid("a numeric id must be an integer")
+			}
+			row.id = strconv.FormatInt(int64(id), 10)
+			row.numeric = true
+		default:
+			return invalid("id must be a string or an integer")
+		}
+		if first, ok := seen[row.id]; ok {
+			return invalid(fmt.Sprintf("id '%s' is also used by rows[%d]", row.id, first))
+		}
+		seen[row.id] = i
+
+		row.text, _ = obj["text"].(string)
+		if strings.TrimSpace(row.text) == "" {
+			return invalid("text must be a non-empty string")
+		}
+		if m, ok := obj["metadata"]; ok && m != nil {
+			if _, ok := m.(map[string]interface{}); !ok {
+				return invalid("metadata must be an object")
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				return invalid(fmt.Sprintf("metadata cannot be encoded: %v", err))
+			}
+			metadata := string(data)
+			row.metadata = &metadata
+		}
+		row.hash = contentHash(model, row.text)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// contentHash identifies an embedded text: the SHA-256 of the model and the
+// text, so a different model invalidates the stored embedding too. The model
+// is prefixed with its length to keep the two apart.
+func contentHash(model, text string) string {
+	sum := sha256.Sum256([]byte(strconv.Itoa(len(model)) + ":" + model + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// upsertIDType is the id column type of a created table: bigint when every
+// id is an integer, text otherwise
+func upsertIDType(rows []upsertRow) string {
+	for _, row := range rows {
+		if !row.numeric {
+			return "text"
+		}
+	}
+	return "bigint"
+}
+
+// planUpsert compares the rows with the stored content hashes, keyed by id
+func planUpsert(rows []upsertRow, stored map[string]string, force bool) upsertPlan {
+	var plan upsertPlan
+	for _, row := range rows {
+		hash, ok := stored[row.id]
+		switch {
+		case force || !ok || hash != row.hash:
+			plan.embed = append(plan.embed, row)
+		case row.metadata != nil:
+			plan.metadataOnly = append(plan.metadataOnly, row)
+		default:
+			plan.skipped++
+		}
+	}
+	return plan
+}
+
+// upsertTableColumns reports whether the table exists and the types of the
+// target columns it has
+func upsertTableColumns(ctx context.Context, db *database.Database, target upsertTarget) (bool, map[string]string, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", target.table.Sanitize()).Scan(&exists); err != nil {
+		return false, nil, err
+	}
+	if !exists {
+		return false, nil, nil
+	}
+	rows, err := db.Query(ctx, `SELECT attname, format_type(atttypid, atttypmod)
+		FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped AND attname = ANY($2)`,
+		target.table.Sanitize(), []string{target.idColumn, target.textColumn, target.embeddingColumn, target.metadataColumn, target.hashColumn})
+	if err != nil {
+		return false, nil, err
+	}
+	defer rows.Close()
+	columns := map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return false, nil, err
+		}
+		columns[name] = typ
+	}
+	return true, columns, rows.Err()
+}
+
+// storedContentHashes returns the content hash of each row already stored,
+// keyed by id
+func storedContentHashes(ctx context.Context, db *database.Database, target upsertTarget, rows []upsertRow) (map[string]string, error) {
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.id
+	}
+	query := fmt.Sprintf("SELECT %s::text, %s FROM %s WHERE %s = ANY($1::text[]::%s[])",
+		pgx.Identifier{target.idColumn}.Sanitize(), pgx.Identifier{target.hashColumn}.Sanitize(),
+		target.table.Sanitize(), pgx.Identifier{target.idColumn}.Sanitize(), target.idType)
+	result, err := db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	stored := make(map[string]string, len(rows))
+	for result.Next() {
+		var id string
+		var hash *string
+		if err := result.Scan(&id, &hash); err != nil {
+			return nil, err
+		}
+		if hash != nil {
+			stored[id] = *hash
+		}
+	}
+	return stored, result.Err()
+}
+
+// upsertEmbeddingSQL writes one row: id ($1), text ($2), embedding ($3),
+// metadata ($4, NULL keeps the stored metadata) and content hash ($5). It
+// returns whether the row was inserted; unless force is set, a stored row
+// with the same hash is left alone and no row is returned.
+func upsertEmbeddingSQL(target upsertTarget, force bool) string {
+	id := pgx.Identifier{target.idColumn}.Sanitize()
+	text := pgx.Identifier{target.textColumn}.Sanitize()
+	embedding := pgx.Identifier{target.embeddingColumn}.Sanitize()
+	metadata := pgx.Identifier{target.metadataColumn}.Sanitize()
+	hash := pgx.Identifier{target.hashColumn}.Sanitize()
+
+	sql := fmt.Sprintf(`INSERT INTO %s AS target (%s, %s, %s, %s, %s)
+		VALUES ($1::text::%s, $2, $3::vector, COALESCE($4::jsonb, '{}'::jsonb), $5)
+		ON CONFLICT (%s) DO UPDATE SET %s = EXCLUDED.%s, %s = EXCLUDED.%s, %s = COALESCE($4::jsonb, target.%s), %s = EXCLUDED.%s`,
+		target.table.Sanitize(), id, text, embedding, metadata, hash,
+		target.idType,
+		id, text, text, embedding, embedding, metadata, metadata, hash, hash)
+	if !force {
+		sql += fmt.Sprintf("\n\t\tWHERE target.%s IS DISTINCT FROM EXCLUDED.%s", hash, hash)
+	}
+	return sql + "\n\t\tRETURNING (xmax = 0) AS inserted"
+}
+
+// upsertMetadataSQL replaces the metadata ($2) of a row ($1) whose text is
+// unchanged, when it differs
+func upsertMetadataSQL(target upsertTarget) string {
+	metadata := pgx.Identifier{target.metadataColumn}.Sanitize()
+	return fmt.Sprintf("UPDATE %s SET %s = $2::jsonb WHERE %s = $1::text::%s AND %s::jsonb IS DISTINCT FROM $2::jsonb",
+		target.table.Sanitize(), metadata, pgx.Identifier{target.idColumn}.Sanitize(), target.idType, metadata)
+}
+
+// upsertTableDDL creates the table upsert_embeddings writes to
+func upsertTableDDL(target upsertTarget, dimension int) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			%s %s PRIMARY KEY,
+			%s TEXT NOT NULL,
+			%s vector(%d),
+			%s JSONB DEFAULT '{}',
+			%s TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`, target.table.Sanitize(),
+		pgx.Identifier{target.idColumn}.Sanitize(), target.idType,
+		pgx.Identifier{target.textColumn}.Sanitize(),
+		pgx.Identifier{target.embeddingColumn}.Sanitize(), dimension,
+		pgx.Identifier{target.metadataColumn}.Sanitize(),
+		pgx.Identifier{target.hashColumn}.Sanitize())
+}
+
+// writeUpsertBatch upserts a batch of embedded rows in one transaction and
+// returns how many were inserted and updated
+func writeUpsertBatch(ctx context.Context, db *database.Database, target upsertTarget, force bool, rows []upsertRow, vectors [][]float32) (int, int, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := upsertEmbeddingSQL(target, force)
+	batch := &pgx.Batch{}
+	for i, row := range rows {
+		batch.Queue(sql, row.id, row.text, formatFloat32Vector(vectors[i]), row.metadata, row.hash)
+	}
+	results := tx.SendBatch(ctx, batch)
+	inserted, updated := 0, 0
+	for _, row := range rows {
+		var wasInserted bool
+		err := results.QueryRow().Scan(&wasInserted)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			// Stored meanwhile with the same content
+		case err != nil:
+			results.Close()
+			return 0, 0, fmt.Errorf("row id '%s': %w", row.id, err)
+		case wasInserted:
+			inserted++
+		default:
+			updated++
+		}
+	}
+	if err := results.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return inserted, updated, nil
+}
+
+// writeUpsertMetadata writes the metadata of rows whose text is unchanged in
+// one transaction and returns how many rows changed
+func writeUpsertMetadata(ctx context.Context, db *database.Database, target upsertTarget, rows []upsertRow) (int, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := upsertMetadataSQL(target)
+	batch := &pgx.Batch{}
+	for _, row := range rows {
+		batch.Queue(sql, row.id, *row.metadata)
+	}
+	results := tx.SendBatch(ctx, batch)
+	updated := 0
+	for _, row := range rows {
+		tag, err := results.Exec()
+		if err != nil {
+			results.Close()
+			return 0, fmt.Errorf("row id '%s': %w", row.id, err)
+		}
+		updated += int(tag.RowsAffected())
+	}
+	if err := results.Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit metadata updates: %w", err)
+	}
+	return updated, nil
+}
+
+// planUpsertStatements returns the dry run plan of an upsert. The stored
+// hashes were read, so the plan shows which rows would be embedded; the
+// embeddings themselves are not computed.
+func (t *UpsertEmbeddingsTool) planUpsertStatements(ctx context.Context, target upsertTarget, plan upsertPlan, exists, addHashColumn, force bool, model string, batchSize int) *ToolResult {
+	var statements []PlannedStatement
+	permissions := []Permission{
+		tablePermission("INSERT", target.table.Sanitize()),
+		tablePermission("UPDATE", target.table.Sanitize()),
+	}
+	if !exists {
+		statements = append(statements, PlannedStatement{
+			SQL:  upsertTableDDL(target, 0),
+			Note: "the vector column is sized to the embedding dimension",
+		})
+		permissions = append(permissions, schemaPermission("CREATE", schemaOf(target.table)))
+	}
+	if addHashColumn {
+		zero := int64(0)
+		statements = append(statements, PlannedStatement{
+			SQL:           fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s text", target.table.Sanitize(), pgx.Identifier{target.hashColumn}.Sanitize()),
+			EstimatedRows: &zero,
+		})
+		permissions = append(permissions, tablePermission("OWNER", target.table.Sanitize()))
+	}
+	if len(plan.embed) > 0 {
+		rows := int64(len(plan.embed))
+		first := plan.embed[0]
+		statements = append(statements, PlannedStatement{
+			SQL:           upsertEmbeddingSQL(target, force),
+			Params:        []interface{}{first.id, first.text, nil, first.metadata, first.hash},
+			EstimatedRows: &rows,
+			Note:          fmt.Sprintf("runs once per new or changed row, in transactions of up to %d rows; params are those of the first of %d rows, whose embedding is computed when the call runs", batchSize, len(plan.embed)),
+		})
+	}
+	if len(plan.metadataOnly) > 0 {
+		rows := int64(len(plan.metadataOnly))
+		first := plan.metadataOnly[0]
+		statements = append(statements, PlannedStatement{
+			SQL:           upsertMetadataSQL(target),
+			Params:        []interface{}{first.id, *first.metadata},
+			EstimatedRows: &rows,
+			Note:          fmt.Sprintf("runs once per unchanged row with metadata; params are those of the first of %d rows. Rows whose metadata is already equal are not written.", len(plan.metadataOnly)),
+		})
+	}
+
+	return dryRunResult(ctx, t.executor.database(ctx), t.Name(), statements, permissions,
+		fmt.Sprintf("%d rows would be embedded with model '%s', %d have unchanged text with metadata to write and %d would be skipped",
+			len(plan.embed), model, len(plan.metadataOnly), plan.skipped))
+}
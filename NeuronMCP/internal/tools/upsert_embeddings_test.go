@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestParseUpsertRows(t *testing.T) {
+	rows, errResult := parseUpsertRows([]interface{}{
+		map[string]interface{}{"id": float64(42), "text": "first"},
+		map[string]interface{}{"id": "doc-7", "text": "second", "metadata": map[string]interface{}{"lang": "en"}},
+	}, "default")
+	if errResult != nil {
+		t.Fatalf("unexpected error: %v", errResult.Error)
+	}
+	if rows[0].id != "42" || !rows[0].numeric || rows[0].metadata != nil {
+		t.Errorf("rows[0] = %+v", rows[0])
+	}
+	if rows[1].id != "doc-7" || rows[1].numeric || rows[1].metadata == nil || *rows[1].metadata != `{"lang":"en"}` {
+		t.Errorf("rows[1] = %+v", rows[1])
+	}
+	if upsertIDType(rows) != "text" || upsertIDType(rows[:1]) != "bigint" {
+		t.Errorf("upsertIDType = %q, %q", upsertIDType(rows), upsertIDType(rows[:1]))
+	}
+
+	for name, raw := range map[string]interface{}{
+		"empty":           []interface{}{},
+		"not an array":    "rows",
+		"fractional id":   []interface{}{map[string]interface{}{"id": 1.5, "text": "a"}},
+		"missing text":    []interface{}{map[string]interface{}{"id": "a"}},
+		"blank text":      []interface{}{map[string]interface{}{"id": "a", "text": "  "}},
+		"duplicate id":    []interface{}{map[string]interface{}{"id": float64(1), "text": "a"}, map[string]interface{}{"id": "1", "text": "b"}},
+		"scalar metadata": []interface{}{map[string]interface{}{"id": "a", "text": "a", "metadata": "x"}},
+	} {
+		if _, errResult := parseUpsertRows(raw, "default"); errResult == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	if contentHash("m", "text") != contentHash("m", "text") {
+		t.Error("contentHash is not stable")
+	}
+	if contentHash("m", "text") == contentHash("other", "text") {
+		t.Error("contentHash should change with the model")
+	}
+	if contentHash("m", "a\x00b") == contentHash("m\x00a", "b") {
+		t.Error("contentHash should separate model and text")
+	}
+}
+
+func TestPlanUpsert(t *testing.T) {
+	metadata := `{"k":1}`
+	rows := []upsertRow{
+		{id: "new", hash: "h1"},
+		{id: "changed", hash: "h2"},
+		{id: "same", hash: "h3"},
+		{id: "same-with-metadata", hash: "h4", metadata: &metadata},
+	}
+	stored := map[string]string{"changed": "old", "same": "h3", "same-with-metadata": "h4"}
+
+	plan := planUpsert(rows, stored, false)
+	if len(plan.embed) != 2 || plan.embed[0].id != "new" || plan.embed[1].id != "changed" {
+		t.Errorf("embed = %+v", plan.embed)
+	}
+	if len(plan.metadataOnly) != 1 || plan.metadataOnly[0].id != "same-with-metadata" || plan.skipped != 1 {
+		t.Errorf("metadataOnly = %+v, skipped = %d", plan.metadataOnly, plan.skipped)
+	}
+
+	if forced := planUpsert(rows, stored, true); len(forced.embed) != 4 || forced.skipped != 0 {
+		t.Errorf("forced plan = %+v", forced)
+	}
+}
+
+func TestUpsertEmbeddingSQL(t *testing.T) {
+	target := upsertTarget{
+		table:           pgx.Identifier{"kb", "docs"},
+		idColumn:        "id",
+		textColumn:      "body",
+		embeddingColumn: "embedding",
+		metadataColumn:  "metadata",
+		hashColumn:      "content_hash",
+		idType:          "bigint",
+	}
+	sql := upsertEmbeddingSQL(target, false)
+	for _, want := range []string{
+		`INSERT INTO "kb"."docs" AS target`,
+		"$1::text::bigint",
+		`ON CONFLICT ("id") DO UPDATE`,
+		`"metadata" = COALESCE($4::jsonb, target."metadata")`,
+		`WHERE target."content_hash" IS DISTINCT FROM EXCLUDED."content_hash"`,
+		"RETURNING (xmax = 0) AS inserted",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("upsert SQL missing %q:\n%s", want, sql)
+		}
+	}
+	if forced := upsertEmbeddingSQL(target, true); strings.Contains(forced, "IS DISTINCT FROM") {
+		t.Errorf("forced upsert should rewrite unchanged rows:\n%s", forced)
+	}
+
+	target.hashColumn = "id"
+	if err := target.validateColumns(); err == nil {
+		t.Error("validateColumns should reject a column used twice")
+	}
+}
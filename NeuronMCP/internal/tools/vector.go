@@ -23,7 +23,7 @@ func NewVectorSearchTool(db *database.Database, logger *logging.Logger) *VectorS
 			"Perform vector similarity search using L2, cosine, inner product, L1, Hamming, Chebyshev, or Minkowski distance",
 			map[string]interface{}{
 				"type": "object",
-				"properties": map[string]interface{}{
+				"properties": withSearchTuning(map[string]interface{}{
 					"table": map[string]interface{}{
 						"type":        "string",
 						"description": "Table name containing vectors",
@@ -55,7 +55,30 @@ func NewVectorSearchTool(db *database.Database, logger *logging.Logger) *VectorS
 						"items":       map[string]interface{}{"type": "string"},
 						"description": "Additional columns to return in results",
 					},
-				},
+					"sparse_column": map[string]interface{}{
+						"type":        "string",
+						"description": "sparse_vector column; when set, dense results are fused with a sparse dot-product search (l2, cosine and inner_product only)",
+					},
+					"query_text": map[string]interface{}{
+						"type":        "string",
+						"description": "Text for the sparse query, embedded with sparse_model",
+					},
+					"query_sparse": sparseQueryProperty,
+					"sparse_model": sparseModelProperty,
+					"sparse_weight": map[string]interface{}{
+						"type":        "number",
+						"default":     0.3,
+						"minimum":     0.0,
+						"maximum":     1.0,
+						"description": "Weight of the sparse search in the fused score; the dense weight is 1.0 - sparse_weight",
+					},
+					"fusion": map[string]interface{}{
+						"type":        "string",
+						"enum":        []interface{}{"weighted", "rrf"},
+						"default":     "weighted",
+						"description": "How dense and sparse results are fused: weighted sum of min-max normalised scores, or reciprocal rank fusion",
+					},
+				}),
 				"required": []interface{}{"table", "vector_column", "query_vector"},
 			},
 		),
@@ -114,7 +137,23 @@ func (t *VectorSearchTool) Execute(ctx context.Context, params map[string]interf
 		}), nil
 	}
 
-	results, err := t.executor.ExecuteVectorSearch(ctx, table, vectorColumn, queryVector, distanceMetric, limit, additionalColumns)
+	tuning, err := searchTuningParam(params, limit)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid search tuning for vector_search tool on table '%s': %v", table, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": table,
+		}), nil
+	}
+
+	if sparseColumn, _ := params["sparse_column"].(string); sparseColumn != "" {
+		if tuning != nil {
+			return Error("ef_search, probes and refine_k apply to dense searches only and cannot be combined with sparse_column", "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "sparse_column",
+			}), nil
+		}
+		return t.executeDenseSparse(ctx, params, table, vectorColumn, sparseColumn, queryVector, distanceMetric, limit, additionalColumns)
+	}
+
+	results, err := t.executor.ExecuteTunedVectorSearch(ctx, table, vectorColumn, queryVector, distanceMetric, limit, additionalColumns, tuning)
 	if err != nil {
 		t.logger.Error("Vector search failed", err, params)
 		return Error(fmt.Sprintf("Vector search execution failed: table='%s', vector_column='%s', distance_metric='%s', limit=%d, query_vector_dimension=%d, additional_columns_count=%d, error=%v", table, vectorColumn, distanceMetric, limit, len(queryVector), len(additionalColumns), err), "SEARCH_ERROR", map[string]interface{}{
@@ -127,13 +166,13 @@ func (t *VectorSearchTool) Execute(ctx context.Context, params map[string]interf
 		}), nil
 	}
 
-	return Success(results, map[string]interface{}{
+	return Success(results, searchTuningMetadata(map[string]interface{}{
 		"count":          len(results),
 		"distance_metric": distanceMetric,
 		"table":          table,
 		"vector_column":  vectorColumn,
 		"limit":         limit,
-	}), nil
+	}, tuning)), nil
 }
 
 // VectorSearchL2Tool performs L2 distance vector search
@@ -151,12 +190,12 @@ func NewVectorSearchL2Tool(db *database.Database, logger *logging.Logger) *Vecto
 			"Perform vector similarity search using L2 (Euclidean) distance",
 			map[string]interface{}{
 				"type": "object",
-				"properties": map[string]interface{}{
+				"properties": withSearchTuning(map[string]interface{}{
 					"table":         map[string]interface{}{"type": "string"},
 					"vector_column": map[string]interface{}{"type": "string"},
 					"query_vector":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}},
 					"limit":         map[string]interface{}{"type": "number", "default": 10, "minimum": 1, "maximum": 1000},
-				},
+				}),
 				"required": []interface{}{"table", "vector_column", "query_vector"},
 			},
 		),
@@ -180,7 +219,14 @@ func (t *VectorSearchL2Tool) Execute(ctx context.Context, params map[string]inte
 		limit = int(l)
 	}
 
-	results, err := t.executor.ExecuteVectorSearch(ctx, table, vectorColumn, queryVector, "l2", limit, nil)
+	tuning, err := searchTuningParam(params, limit)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid search tuning for vector_search_l2 tool on table '%s': %v", table, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": table,
+		}), nil
+	}
+
+	results, err := t.executor.ExecuteTunedVectorSearch(ctx, table, vectorColumn, queryVector, "l2", limit, nil, tuning)
 	if err != nil {
 		t.logger.Error("L2 vector search failed", err, params)
 		return Error(fmt.Sprintf("L2 vector search execution failed: table='%s', vector_column='%s', limit=%d, query_vector_dimension=%d, error=%v", table, vectorColumn, limit, len(queryVector), err), "SEARCH_ERROR", map[string]interface{}{
@@ -193,10 +239,10 @@ func (t *VectorSearchL2Tool) Execute(ctx context.Context, params map[string]inte
 		}), nil
 	}
 
-	return Success(results, map[string]interface{}{
+	return Success(results, searchTuningMetadata(map[string]interface{}{
 		"count":          len(results),
 		"distance_metric": "l2",
-	}), nil
+	}, tuning)), nil
 }
 
 // VectorSearchCosineTool performs cosine distance vector search
@@ -214,12 +260,12 @@ func NewVectorSearchCosineTool(db *database.Database, logger *logging.Logger) *V
 			"Perform vector similarity search using cosine distance",
 			map[string]interface{}{
 				"type": "object",
-				"properties": map[string]interface{}{
+				"properties": withSearchTuning(map[string]interface{}{
 					"table":         map[string]interface{}{"type": "string"},
 					"vector_column": map[string]interface{}{"type": "string"},
 					"query_vector":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}},
 					"limit":         map[string]interface{}{"type": "number", "default": 10, "minimum": 1, "maximum": 1000},
-				},
+				}),
 				"required": []interface{}{"table", "vector_column", "query_vector"},
 			},
 		),
@@ -243,7 +289,14 @@ func (t *VectorSearchCosineTool) Execute(ctx context.Context, params map[string]
 		limit = int(l)
 	}
 
-	results, err := t.executor.ExecuteVectorSearch(ctx, table, vectorColumn, queryVector, "cosine", limit, nil)
+	tuning, err := searchTuningParam(params, limit)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid search tuning for vector_search_cosine tool on table '%s': %v", table, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": table,
+		}), nil
+	}
+
+	results, err := t.executor.ExecuteTunedVectorSearch(ctx, table, vectorColumn, queryVector, "cosine", limit, nil, tuning)
 	if err != nil {
 		t.logger.Error("Cosine vector search failed", err, params)
 		return Error(fmt.Sprintf("Cosine vector search execution failed: table='%s', vector_column='%s', limit=%d, query_vector_dimension=%d, error=%v", table, vectorColumn, limit, len(queryVector), err), "SEARCH_ERROR", map[string]interface{}{
@@ -256,10 +309,10 @@ func (t *VectorSearchCosineTool) Execute(ctx context.Context, params map[string]
 		}), nil
 	}
 
-	return Success(results, map[string]interface{}{
+	return Success(results, searchTuningMetadata(map[string]interface{}{
 		"count":          len(results),
 		"distance_metric": "cosine",
-	}), nil
+	}, tuning)), nil
 }
 
 // VectorSearchInnerProductTool performs inner product distance vector search
@@ -277,12 +330,12 @@ func NewVectorSearchInnerProductTool(db *database.Database, logger *logging.Logg
 			"Perform vector similarity search using inner product distance",
 			map[string]interface{}{
 				"type": "object",
-				"properties": map[string]interface{}{
+				"properties": withSearchTuning(map[string]interface{}{
 					"table":         map[string]interface{}{"type": "string"},
 					"vector_column": map[string]interface{}{"type": "string"},
 					"query_vector":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}},
 					"limit":         map[string]interface{}{"type": "number", "default": 10, "minimum": 1, "maximum": 1000},
-				},
+				}),
 				"required": []interface{}{"table", "vector_column", "query_vector"},
 			},
 		),
@@ -306,7 +359,14 @@ func (t *VectorSearchInnerProductTool) Execute(ctx context.Context, params map[s
 		limit = int(l)
 	}
 
-	results, err := t.executor.ExecuteVectorSearch(ctx, table, vectorColumn, queryVector, "inner_product", limit, nil)
+	tuning, err := searchTuningParam(params, limit)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid search tuning for vector_search_inner_product tool on table '%s': %v", table, err), "VALIDATION_ERROR", map[string]interface{}{
+			"table": table,
+		}), nil
+	}
+
+	results, err := t.executor.ExecuteTunedVectorSearch(ctx, table, vectorColumn, queryVector, "inner_product", limit, nil, tuning)
 	if err != nil {
 		t.logger.Error("Inner product vector search failed", err, params)
 		return Error(fmt.Sprintf("Inner product vector search execution failed: table='%s', vector_column='%s', limit=%d, query_vector_dimension=%d, error=%v", table, vectorColumn, limit, len(queryVector), err), "SEARCH_ERROR", map[string]interface{}{
@@ -319,10 +379,10 @@ func (t *VectorSearchInnerProductTool) Execute(ctx context.Context, params map[s
 		}), nil
 	}
 
-	return Success(results, map[string]interface{}{
+	return Success(results, searchTuningMetadata(map[string]interface{}{
 		"count":          len(results),
 		"distance_metric": "inner_product",
-	}), nil
+	}, tuning)), nil
 }
 
 // GenerateEmbeddingTool generates text embeddings
@@ -403,7 +463,7 @@ func (t *GenerateEmbeddingTool) Execute(ctx context.Context, params map[string]i
 	})
 	
 	// Use embedding timeout for embedding queries
-	result, err = t.executor.ExecuteQueryOneWithTimeout(ctx, query, queryParams, EmbeddingQueryTimeout)
+	result, err = t.executor.ExecutePreparedQueryOne(ctx, statementEmbedText, query, queryParams, EmbeddingQueryTimeout)
 	if err != nil {
 		// Fallback: neurondb.embed(model, input_text, task) - PL/pgSQL wrapper
 		t.logger.Warn("embed_text failed, trying neurondb.embed fallback", map[string]interface{}{
@@ -416,7 +476,7 @@ func (t *GenerateEmbeddingTool) Execute(ctx context.Context, params map[string]i
 		queryParams = []interface{}{modelName, text}
 		
 		// Use embedding timeout for fallback query too
-		result, err = t.executor.ExecuteQueryOneWithTimeout(ctx, query, queryParams, EmbeddingQueryTimeout)
+		result, err = t.executor.ExecutePreparedQueryOne(ctx, statementEmbedText, query, queryParams, EmbeddingQueryTimeout)
 		if err != nil {
 			t.logger.Error("Embedding generation failed with both methods", err, params)
 			return Error(fmt.Sprintf("Embedding generation failed: text_length=%d, model='%s', error=%v", textLen, modelName, err), "EMBEDDING_ERROR", map[string]interface{}{
@@ -431,7 +491,9 @@ func (t *GenerateEmbeddingTool) Execute(ctx context.Context, params map[string]i
 	return Success(result, map[string]interface{}{"model": modelName}), nil
 }
 
-// BatchEmbeddingTool generates embeddings for multiple texts
+// BatchEmbeddingTool generates embeddings for multiple texts. The texts are
+// embedded in sub-batches so no single SQL call runs long enough to hit the
+// statement timeout.
 type BatchEmbeddingTool struct {
 	*BaseTool
 	executor *QueryExecutor
@@ -443,7 +505,7 @@ func NewBatchEmbeddingTool(db *database.Database, logger *logging.Logger) *Batch
 	return &BatchEmbeddingTool{
 		BaseTool: NewBaseTool(
 			"batch_embedding",
-			"Generate embeddings for multiple texts efficiently",
+			"Generate embeddings for multiple texts efficiently, in parallel sub-batches with per-batch failure reporting and resumable retries",
 			map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -452,12 +514,35 @@ func NewBatchEmbeddingTool(db *database.Database, logger *logging.Logger) *Batch
 						"items":       map[string]interface{}{"type": "string"},
 						"description": "Array of texts to embed",
 						"minItems":    1,
-						"maxItems":    1000,
+						"maxItems":    maxBatchEmbeddingTexts,
 					},
 					"model": map[string]interface{}{
 						"type":        "string",
 						"description": "Model name (optional)",
 					},
+					"batch_size": map[string]interface{}{
+						"type":        "integer",
+						"description": "Texts embedded per SQL call",
+						"default":     defaultEmbeddingSubBatchSize,
+						"minimum":     1,
+						"maximum":     maxBatchEmbeddingTexts,
+					},
+					"parallelism": map[string]interface{}{
+						"type":        "integer",
+						"description": "Sub-batches embedded at the same time",
+						"default":     defaultEmbeddingParallelism,
+						"minimum":     1,
+						"maximum":     maxEmbeddingParallelism,
+					},
+					"stop_on_error": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Start no more sub-batches once one fails; the rest are reported as skipped",
+						"default":     false,
+					},
+					"resume_token": map[string]interface{}{
+						"type":        "string",
+						"description": "Token returned by a call with failed sub-batches. Send it with the same texts to embed only those sub-batches.",
+					},
 				},
 				"required": []interface{}{"texts"},
 			},
@@ -474,82 +559,84 @@ func (t *BatchEmbeddingTool) Execute(ctx context.Context, params map[string]inte
 		return Error("Invalid parameters", "VALIDATION_ERROR", map[string]interface{}{"errors": errors}), nil
 	}
 
-	texts, _ := params["texts"].([]interface{})
-	model, _ := params["model"].(string)
-	
-	textsCount := len(texts)
-	if textsCount == 0 {
-		return Error("texts parameter is required and cannot be empty array for batch_embedding tool", "VALIDATION_ERROR", map[string]interface{}{
-			"parameter":   "texts",
-			"texts_count": 0,
-			"params":      params,
-		}), nil
+	req, invalid := parseBatchEmbeddingRequest(params)
+	if invalid != nil {
+		return invalid, nil
 	}
 
-	if textsCount > 1000 {
-		return Error(fmt.Sprintf("texts array exceeds maximum size of 1000: received %d texts for batch_embedding tool", textsCount), "VALIDATION_ERROR", map[string]interface{}{
-			"parameter":   "texts",
-			"texts_count": textsCount,
-			"max_count":   1000,
-			"params":      params,
-		}), nil
-	}
+	t.logger.Info("Generating batch embeddings", map[string]interface{}{
+		"method":      "neurondb.embed_batch",
+		"model":       req.model,
+		"texts_count": len(req.texts),
+		"batch_size":  req.batchSize,
+		"batches":     req.batchCount(),
+		"parallelism": req.parallelism,
+		"resumed":     req.pending != nil,
+	})
 
-	modelName := model
-	if modelName == "" {
-		modelName = "default"
+	embeddings, batches := runEmbeddingSubBatches(ctx, req, t.embedBatch)
+
+	succeeded, failed, firstError := 0, 0, ""
+	for _, batch := range batches {
+		switch batch.Status {
+		case subBatchSucceeded:
+			succeeded++
+		case subBatchFailed:
+			if failed == 0 {
+				firstError = batch.Error
+			}
+			failed++
+		}
+	}
+	metadata := map[string]interface{}{
+		"count":          len(req.texts),
+		"model":          req.model,
+		"batch_size":     req.batchSize,
+		"batches":        batches,
+		"failed_batches": failed,
+	}
+	pending := unfinishedBatches(req, batches)
+	if len(pending) > 0 {
+		metadata["resume_token"] = encodeEmbeddingResumeToken(req, pending)
 	}
 
-	// Convert []interface{} to []string for PostgreSQL text[] array
-	textStrings := make([]string, 0, textsCount)
-	for i, text := range texts {
-		if textStr, ok := text.(string); ok {
-			if textStr == "" {
-				return Error(fmt.Sprintf("texts array element at index %d is empty string for batch_embedding tool", i), "VALIDATION_ERROR", map[string]interface{}{
-					"parameter":   "texts",
-					"texts_count": textsCount,
-					"empty_index": i,
-					"params":      params,
-				}), nil
+	if len(pending) > 0 && succeeded == 0 {
+		if firstError == "" {
+			// No sub-batch failed, so the call was cancelled before they ran
+			firstError = "sub-batches were not run"
+			if err := ctx.Err(); err != nil {
+				firstError = err.Error()
 			}
-			textStrings = append(textStrings, textStr)
-		} else {
-			return Error(fmt.Sprintf("texts array element at index %d must be a string for batch_embedding tool: got %T", i, text), "VALIDATION_ERROR", map[string]interface{}{
-				"parameter":     "texts",
-				"texts_count":   textsCount,
-				"invalid_index": i,
-				"received_type": fmt.Sprintf("%T", text),
-				"params":        params,
-			}), nil
 		}
+		t.logger.Error("Batch embedding failed", fmt.Errorf("%s", firstError), map[string]interface{}{
+			"texts_count": len(req.texts),
+			"model":       req.model,
+		})
+		metadata["texts_count"] = len(req.texts)
+		metadata["error"] = firstError
+		return Error(fmt.Sprintf("Batch embedding generation failed: texts_count=%d, model='%s', error=%s", len(req.texts), req.model, firstError), "EMBEDDING_ERROR", metadata), nil
+	}
+	if len(pending) > 0 {
+		metadata["partial"] = true
+		t.logger.Warn("Batch embedding partially failed", map[string]interface{}{
+			"texts_count":     len(req.texts),
+			"model":           req.model,
+			"pending_batches": len(pending),
+		})
 	}
 
-	// Use NeuronDB's batch embedding function: neurondb.embed_batch(model, texts[])
+	return Success(map[string]interface{}{"embeddings": embeddings}, metadata), nil
+}
+
+// embedBatch embeds one sub-batch with neurondb.embed_batch
+func (t *BatchEmbeddingTool) embedBatch(ctx context.Context, model string, texts []string) ([]interface{}, error) {
 	// Cast vector[] to text[] array, then to JSON so pgx can scan it
 	query := "SELECT json_agg(embedding::text) AS embeddings FROM unnest(neurondb.embed_batch($1, $2::text[])) AS embedding"
-	queryParams := []interface{}{modelName, textStrings}
-
-	t.logger.Info("Generating batch embeddings", map[string]interface{}{
-		"method": "neurondb.embed_batch",
-		"model":  modelName,
-		"texts_count": textsCount,
-		"query": query,
-	})
-
-	// Use embedding timeout for batch embeddings (can take longer)
-	result, err := t.executor.ExecuteQueryOneWithTimeout(ctx, query, queryParams, EmbeddingQueryTimeout)
+	row, err := t.executor.ExecutePreparedQueryOne(ctx, statementEmbedBatch, query, []interface{}{model, texts}, EmbeddingQueryTimeout)
 	if err != nil {
-		t.logger.Error("Batch embedding failed", err, params)
-		return Error(fmt.Sprintf("Batch embedding generation failed: texts_count=%d, model='%s', error=%v", textsCount, modelName, err), "EMBEDDING_ERROR", map[string]interface{}{
-			"texts_count": textsCount,
-			"model":       modelName,
-			"error":       err.Error(),
-		}), nil
+		return nil, err
 	}
-
-	return Success(result, map[string]interface{}{
-		"count": len(texts),
-		"model": modelName,
-	}), nil
+	embeddings, _ := row["embeddings"].([]interface{})
+	return embeddings, nil
 }
 
@@ -0,0 +1,1453 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Vector index maintenance limits
+const (
+	vectorIndexBaselineTable = "neurondb_mcp.vector_index_baselines"
+
+	defaultDriftThreshold      = 0.2
+	defaultRecallDropThreshold = 0.05
+	defaultDriftRecallQueries  = 50
+	maxDriftRecallQueries      = 500
+
+	defaultRebuildTimeoutMs = 3600000
+	maxRebuildTimeoutMs     = 86400000
+	defaultVacuumTimeoutMs  = 3600000
+
+	// maintenanceProgressInterval is how often a running rebuild or vacuum
+	// reads its progress view to report progress
+	maintenanceProgressInterval = 2 * time.Second
+	maxLockQueryLength          = 200
+)
+
+// vectorIndexMethodCondition matches the access methods isVectorIndexMethod
+// accepts, for queries joining pg_am as am
+const vectorIndexMethodCondition = "(am.amname ILIKE '%hnsw%' OR am.amname ILIKE '%ivf%')"
+
+// vectorIndex is an HNSW or IVF index with the activity statistics of its
+// table
+type vectorIndex struct {
+	Schema      string
+	Name        string
+	TableSchema string
+	TableName   string
+	Method      string
+	Column      string // empty for an index on an expression
+	OpClass     string
+	Valid       bool
+	SizeBytes   int64
+	Scans       int64
+	LiveRows    int64
+	DeadRows    int64
+	Inserted    int64
+	Updated     int64
+	Deleted     int64
+}
+
+// qualifiedName returns the index as schema.name, the key of its baseline
+func (i *vectorIndex) qualifiedName() string {
+	return i.Schema + "." + i.Name
+}
+
+// identifier returns the index as a quoted, schema-qualified identifier
+func (i *vectorIndex) identifier() string {
+	return pgx.Identifier{i.Schema, i.Name}.Sanitize()
+}
+
+// table returns the indexed table
+func (i *vectorIndex) table() pgx.Identifier {
+	return pgx.Identifier{i.TableSchema, i.TableName}
+}
+
+// metric returns the distance metric the index's operator class orders by
+func (i *vectorIndex) metric() string {
+	return opClassMetric(i.OpClass)
+}
+
+// deadFraction returns the share of the table's rows that are dead
+func (i *vectorIndex) deadFraction() float64 {
+	total := i.LiveRows + i.DeadRows
+	if total < 1 {
+		return 0
+	}
+	return float64(i.DeadRows) / float64(total)
+}
+
+// opClassMetric maps an operator class such as vector_cosine_ops to the
+// metric of similarityJoinOperators it orders by, l2 when it names neither
+// cosine nor inner product
+func opClassMetric(opClass string) string {
+	opClass = strings.ToLower(opClass)
+	switch {
+	case strings.Contains(opClass, "cosine"):
+		return "cosine"
+	case strings.Contains(opClass, "_ip_") || strings.Contains(opClass, "inner_product"):
+		return "inner_product"
+	}
+	return "l2"
+}
+
+// indexBaseline is what a vector index's table looked like when the index
+// was last built, or when tracking started: the cumulative write counters
+// of pg_stat_user_tables, so later writes can be told apart, and the recall
+// measured then, if any
+type indexBaseline struct {
+	Index      string    `json:"-"`
+	Source     string    `json:"source"`
+	LiveRows   int64     `json:"live_rows"`
+	Inserted   int64     `json:"-"`
+	Updated    int64     `json:"-"`
+	Deleted    int64     `json:"-"`
+	Recall     *float64  `json:"recall_at_k,omitempty"`
+	RecallK    *int      `json:"k,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// indexDrift is how much of a table changed since its index's baseline
+type indexDrift struct {
+	Inserted         int64   `json:"inserted"`
+	Updated          int64   `json:"updated"`
+	Deleted          int64   `json:"deleted"`
+	DriftFraction    float64 `json:"drift_fraction"`
+	InsertedFraction float64 `json:"inserted_fraction"`
+}
+
+// computeIndexDrift returns the rows inserted, updated and deleted since the
+// baseline as shares of the rows the index was built over. It returns nil
+// when a counter went backwards, which happens when statistics were reset,
+// as the drift is then unknown.
+func computeIndexDrift(idx *vectorIndex, baseline *indexBaseline) *indexDrift {
+	d := &indexDrift{
+		Inserted: idx.Inserted - baseline.Inserted,
+		Updated:  idx.Updated - baseline.Updated,
+		Deleted:  idx.Deleted - baseline.Deleted,
+	}
+	if d.Inserted < 0 || d.Updated < 0 || d.Deleted < 0 {
+		return nil
+	}
+	base := baseline.LiveRows
+	if base < 1 {
+		base = 1
+	}
+	d.DriftFraction = float64(d.Inserted+d.Updated+d.Deleted) / float64(base)
+	d.InsertedFraction = float64(d.Inserted) / float64(base)
+	return d
+}
+
+// maintenanceAdvice is the maintenance an index needs, in the order to run
+// it, with the reasons
+type maintenanceAdvice struct {
+	Actions []string `json:"actions"`
+	Reasons []string `json:"reasons"`
+}
+
+// adviseIndexMaintenance recommends vacuuming the table when dead rows
+// reach highDeadFraction, rebuilding the index when it is invalid or when
+// drift or the recall drop reach their thresholds, and recording a baseline
+// when drift cannot be measured. recallDrop is nil when recall was not
+// compared with the baseline.
+func adviseIndexMaintenance(idx *vectorIndex, baseline *indexBaseline, drift *indexDrift, recallDrop *float64, driftThreshold, recallDropThreshold float64) maintenanceAdvice {
+	advice := maintenanceAdvice{Actions: []string{}, Reasons: []string{}}
+	add := func(action, reason string) {
+		found := false
+		for _, a := range advice.Actions {
+			found = found || a == action
+		}
+		if !found {
+			advice.Actions = append(advice.Actions, action)
+		}
+		advice.Reasons = append(advice.Reasons, reason)
+	}
+
+	if f := idx.deadFraction(); f >= highDeadFraction {
+		add("vacuum", fmt.Sprintf("%.0f%% of the table's rows are dead; vacuum removes them from the index", f*100))
+	}
+	if !idx.Valid {
+		add("rebuild", "the index is invalid, usually left by a failed concurrent build, and queries do not use it")
+	}
+	switch {
+	case baseline == nil:
+		add("record_baseline", "no baseline is recorded, so drift cannot be measured")
+	case drift == nil:
+		add("record_baseline", "table statistics were reset since the baseline was recorded, so drift cannot be measured")
+	case drift.DriftFraction >= driftThreshold:
+		reason := fmt.Sprintf("%.0f%% of rows changed since the baseline (threshold %.0f%%)", drift.DriftFraction*100, driftThreshold*100)
+		if strings.Contains(strings.ToLower(idx.Method), "ivf") && drift.Inserted > 0 {
+			reason += "; IVF lists were trained on the rows present at build time"
+		}
+		add("rebuild", reason)
+	}
+	if recallDrop != nil && *recallDrop >= recallDropThreshold {
+		add("rebuild", fmt.Sprintf("recall dropped by %.3f since the baseline (threshold %.3f)", *recallDrop, recallDropThreshold))
+	}
+	return advice
+}
+
+// readVectorIndexes reads the HNSW and IVF indexes with their table's
+// statistics, only those on the given tables and only the given indexes
+// when there are any
+func readVectorIndexes(ctx context.Context, db *database.Database, tables, indexes []string) ([]*vectorIndex, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	if tables == nil {
+		tables = []string{}
+	}
+	if indexes == nil {
+		indexes = []string{}
+	}
+	rows, err := db.Query(queryCtx, `
+		SELECT n.nspname, ic.relname, tn.nspname, tc.relname, am.amname,
+		       COALESCE(a.attname::text, ''), COALESCE(opc.opcname::text, ''), i.indisvalid,
+		       pg_relation_size(i.indexrelid), COALESCE(si.idx_scan, 0),
+		       COALESCE(st.n_live_tup, 0), COALESCE(st.n_dead_tup, 0),
+		       COALESCE(st.n_tup_ins, 0), COALESCE(st.n_tup_upd, 0), COALESCE(st.n_tup_del, 0)
+		FROM pg_index i
+		JOIN pg_class ic ON ic.oid = i.indexrelid
+		JOIN pg_namespace n ON n.oid = ic.relnamespace
+		JOIN pg_class tc ON tc.oid = i.indrelid
+		JOIN pg_namespace tn ON tn.oid = tc.relnamespace
+		JOIN pg_am am ON am.oid = ic.relam
+		LEFT JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0] AND i.indkey[0] > 0
+		LEFT JOIN pg_opclass opc ON opc.oid = i.indclass[0]
+		LEFT JOIN pg_stat_user_indexes si ON si.indexrelid = i.indexrelid
+		LEFT JOIN pg_stat_user_tables st ON st.relid = i.indrelid
+		WHERE `+vectorIndexMethodCondition+`
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		  AND (cardinality($1::text[]) = 0 OR i.indrelid IN (SELECT to_regclass(t) FROM unnest($1::text[]) AS t))
+		  AND (cardinality($2::text[]) = 0 OR i.indexrelid IN (SELECT to_regclass(x) FROM unnest($2::text[]) AS x))
+		ORDER BY n.nspname, ic.relname`, tables, indexes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*vectorIndex{}
+	for rows.Next() {
+		idx := &vectorIndex{}
+		if err := rows.Scan(&idx.Schema, &idx.Name, &idx.TableSchema, &idx.TableName, &idx.Method,
+			&idx.Column, &idx.OpClass, &idx.Valid, &idx.SizeBytes, &idx.Scans,
+			&idx.LiveRows, &idx.DeadRows, &idx.Inserted, &idx.Updated, &idx.Deleted); err != nil {
+			return nil, err
+		}
+		result = append(result, idx)
+	}
+	return result, rows.Err()
+}
+
+// ensureVectorIndexBaselineTable creates the baseline table on first use
+func ensureVectorIndexBaselineTable(ctx context.Context, db *database.Database) error {
+	_, err := db.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS neurondb_mcp;
+		CREATE TABLE IF NOT EXISTS `+vectorIndexBaselineTable+` (
+			index_name text PRIMARY KEY,
+			table_name text NOT NULL,
+			source text NOT NULL,
+			live_rows bigint NOT NULL,
+			inserted bigint NOT NULL,
+			updated bigint NOT NULL,
+			deleted bigint NOT NULL,
+			recall real,
+			recall_k integer,
+			recorded_at timestamptz NOT NULL DEFAULT now()
+		)`)
+	return err
+}
+
+// readIndexBaselines returns the baselines of the indexes, keyed by
+// schema.name. There are none before the baseline table is created.
+func readIndexBaselines(ctx context.Context, db *database.Database, indexes []*vectorIndex) (map[string]*indexBaseline, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	baselines := map[string]*indexBaseline{}
+	var exists bool
+	if err := db.QueryRow(queryCtx, "SELECT to_regclass($1) IS NOT NULL", vectorIndexBaselineTable).Scan(&exists); err != nil || !exists {
+		return baselines, err
+	}
+	names := make([]string, len(indexes))
+	for i, idx := range indexes {
+		names[i] = idx.qualifiedName()
+	}
+	rows, err := db.Query(queryCtx, `
+		SELECT index_name, source, live_rows, inserted, updated, deleted, recall::float8, recall_k, recorded_at
+		FROM `+vectorIndexBaselineTable+`
+		WHERE index_name = ANY($1)`, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		b := &indexBaseline{}
+		if err := rows.Scan(&b.Index, &b.Source, &b.LiveRows, &b.Inserted, &b.Updated, &b.Deleted, &b.Recall, &b.RecallK, &b.RecordedAt); err != nil {
+			return nil, err
+		}
+		baselines[b.Index] = b
+	}
+	return baselines, rows.Err()
+}
+
+// recordIndexBaselineSQL stores a baseline, replacing the index's previous
+// one
+const recordIndexBaselineSQL = `INSERT INTO ` + vectorIndexBaselineTable + `
+	(index_name, table_name, source, live_rows, inserted, updated, deleted, recall, recall_k)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (index_name) DO UPDATE SET
+		table_name = EXCLUDED.table_name, source = EXCLUDED.source, live_rows = EXCLUDED.live_rows,
+		inserted = EXCLUDED.inserted, updated = EXCLUDED.updated, deleted = EXCLUDED.deleted,
+		recall = EXCLUDED.recall, recall_k = EXCLUDED.recall_k, recorded_at = now()`
+
+// recordIndexBaseline stores the index's current table counters, and the
+// recall when it was measured, as its baseline
+func recordIndexBaseline(ctx context.Context, db *database.Database, idx *vectorIndex, source string, recall *indexRecall) (*indexBaseline, error) {
+	if err := ensureVectorIndexBaselineTable(ctx, db); err != nil {
+		return nil, err
+	}
+	b := &indexBaseline{
+		Index:    idx.qualifiedName(),
+		Source:   source,
+		LiveRows: idx.LiveRows,
+		Inserted: idx.Inserted,
+		Updated:  idx.Updated,
+		Deleted:  idx.Deleted,
+	}
+	if recall != nil {
+		b.Recall = &recall.RecallAtK
+		b.RecallK = &recall.K
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	err := db.QueryRow(queryCtx, recordIndexBaselineSQL+" RETURNING recorded_at",
+		b.Index, idx.TableSchema+"."+idx.TableName, source, b.LiveRows, b.Inserted, b.Updated, b.Deleted, b.Recall, b.RecallK).Scan(&b.RecordedAt)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// indexRecall is the recall@k of searches through an index against exact
+// search, over queries sampled from its table
+type indexRecall struct {
+	RecallAtK float64 `json:"recall_at_k"`
+	MinRecall float64 `json:"min_recall"`
+	K         int     `json:"k"`
+	Queries   int     `json:"queries"`
+	IndexScan bool    `json:"index_scan"`
+}
+
+// measureIndexRecall estimates the index's recall@k. It samples up to
+// samples vectors of the indexed column as queries and compares the rows an
+// index search returns with those of an exact search, by ctid, so rows
+// updated while it runs can count as misses.
+func measureIndexRecall(ctx context.Context, db *database.Database, idx *vectorIndex, k, samples int) (*indexRecall, error) {
+	if idx.Column == "" {
+		return nil, fmt.Errorf("index '%s' is on an expression; recall can only be measured for an index on a column", idx.qualifiedName())
+	}
+	queries, err := sampleBenchmarkQueries(ctx, db, idx.table(), idx.Column, samples)
+	if err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("table '%s.%s' has no vectors to sample queries from", idx.TableSchema, idx.TableName)
+	}
+	query := benchmarkSearchQuery(idx.table(), "ctid", idx.Column, idx.metric(), k, nil)
+
+	recalls := make([]float64, len(queries))
+	stats := runBenchmarkWorkload(ctx, len(queries), defaultBenchmarkConcurrency, func(ctx context.Context, i int) error {
+		truth, err := runBenchmarkSearch(ctx, db, exactSearchSettings, query, queries[i].vector, k)
+		if err != nil {
+			return err
+		}
+		found, err := runBenchmarkSearch(ctx, db, nil, query, queries[i].vector, k)
+		if err != nil {
+			return err
+		}
+		recalls[i] = recallAtK(found, truth, k)
+		return nil
+	})
+	if stats.errors > 0 {
+		return nil, fmt.Errorf("%d of %d recall queries failed, first error: %s", stats.errors, len(queries), stats.firstError)
+	}
+	scan, err := benchmarkIndexScan(ctx, db, nil, query, queries[0].vector, k)
+	if err != nil {
+		return nil, err
+	}
+	return &indexRecall{
+		RecallAtK: mean(recalls),
+		MinRecall: minFloat(recalls),
+		K:         k,
+		Queries:   len(queries),
+		IndexScan: scan,
+	}, nil
+}
+
+// maintenanceProgressView reads the progress of one backend's command from
+// a pg_stat_progress view as phase, blocks done and total, and tuples done
+// and total
+type maintenanceProgressView struct {
+	name  string
+	query string
+}
+
+var (
+	createIndexProgressView = maintenanceProgressView{
+		name: "pg_stat_progress_create_index",
+		query: `SELECT phase, blocks_done, blocks_total, tuples_done, tuples_total
+			FROM pg_stat_progress_create_index WHERE pid = $1`,
+	}
+	vacuumProgressView = maintenanceProgressView{
+		name: "pg_stat_progress_vacuum",
+		query: `SELECT phase, heap_blks_scanned, heap_blks_total, 0::bigint, 0::bigint
+			FROM pg_stat_progress_vacuum WHERE pid = $1`,
+	}
+)
+
+// progressFraction returns the work done and the total from a progress
+// view row: blocks when the phase counts blocks, else tuples. ok is false
+// when the phase counts neither.
+func progressFraction(blocksDone, blocksTotal, tuplesDone, tuplesTotal int64) (done, total float64, ok bool) {
+	switch {
+	case blocksTotal > 0:
+		return float64(blocksDone), float64(blocksTotal), true
+	case tuplesTotal > 0:
+		return float64(tuplesDone), float64(tuplesTotal), true
+	}
+	return 0, 0, false
+}
+
+// runMaintenanceCommand runs a command that cannot run in a transaction
+// block, such as REINDEX CONCURRENTLY or VACUUM, on a dedicated connection
+// with the given statement_timeout. While it runs, it reports the
+// command's progress from view when the caller asked for progress.
+func runMaintenanceCommand(ctx context.Context, db *database.Database, command string, timeoutMs int, view maintenanceProgressView) error {
+	// The context outlives the statement timeout slightly, so the server
+	// reports the timeout rather than the client cancelling the command
+	cmdCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond+5*time.Second)
+	defer cancel()
+	conn, err := db.Acquire(cmdCtx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var pid int32
+	if err := conn.QueryRow(cmdCtx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return err
+	}
+	if _, err := conn.Exec(cmdCtx, fmt.Sprintf("SET statement_timeout = %d", timeoutMs)); err != nil {
+		return fmt.Errorf("failed to set statement_timeout: %w", err)
+	}
+	// The connection goes back to the pool with its default timeout
+	defer func() {
+		resetCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.Exec(resetCtx, "RESET statement_timeout")
+	}()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if progressRequested(ctx) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watchMaintenanceProgress(cmdCtx, db, view, pid, done)
+		}()
+	}
+	_, err = conn.Exec(cmdCtx, command)
+	close(done)
+	wg.Wait()
+	return err
+}
+
+// watchMaintenanceProgress reports the progress of backend pid from view
+// every maintenanceProgressInterval until done is closed
+func watchMaintenanceProgress(ctx context.Context, db *database.Database, view maintenanceProgressView, pid int32, done <-chan struct{}) {
+	ticker := time.NewTicker(maintenanceProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var phase string
+		var blocksDone, blocksTotal, tuplesDone, tuplesTotal int64
+		if err := db.QueryRow(ctx, view.query, pid).Scan(&phase, &blocksDone, &blocksTotal, &tuplesDone, &tuplesTotal); err != nil {
+			// No row yet, or between phases
+			continue
+		}
+		progress, total, _ := progressFraction(blocksDone, blocksTotal, tuplesDone, tuplesTotal)
+		reportProgress(ctx, progress, total, phase)
+	}
+}
+
+// parseIdentifierList parses an array parameter of optionally
+// schema-qualified names into quoted identifiers
+func parseIdentifierList(params map[string]interface{}, name string) ([]string, *ToolResult) {
+	raw, ok := params[name].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	names := make([]string, 0, len(raw))
+	for i, v := range raw {
+		s, _ := v.(string)
+		id, err := parseQualifiedIdentifier(s)
+		if err != nil {
+			return nil, Error(fmt.Sprintf("%s element %d is not a valid name '%s': %v", name, i, s, err), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": name,
+				"index":     i,
+			})
+		}
+		names = append(names, id.Sanitize())
+	}
+	return names, nil
+}
+
+// resolveVectorIndex finds the vector index named by the index parameter
+func resolveVectorIndex(ctx context.Context, db *database.Database, params map[string]interface{}) (*vectorIndex, *ToolResult) {
+	name, _ := params["index"].(string)
+	id, err := parseQualifiedIdentifier(name)
+	if err != nil {
+		return nil, Error(fmt.Sprintf("Invalid index name '%s': %v", name, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "index",
+		})
+	}
+	if missing, err := missingTables(ctx, db, []string{id.Sanitize()}); err != nil {
+		return nil, maintenanceError("index", name, err)
+	} else if len(missing) > 0 {
+		return nil, Error(fmt.Sprintf("Index '%s' does not exist", name), "NOT_FOUND", map[string]interface{}{
+			"index": name,
+		})
+	}
+	indexes, err := readVectorIndexes(ctx, db, nil, []string{id.Sanitize()})
+	if err != nil {
+		return nil, maintenanceError("index", name, err)
+	}
+	if len(indexes) == 0 {
+		return nil, Error(fmt.Sprintf("'%s' is not an HNSW or IVF index", name), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "index",
+			"index":     name,
+		})
+	}
+	return indexes[0], nil
+}
+
+// maintenanceFailure says why a maintenance command did not complete
+func maintenanceFailure(command string, err error, timeoutMs int) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "57014" { // query_canceled, raised by statement_timeout
+		return fmt.Sprintf("%s exceeded timeout_ms (%d)", command, timeoutMs)
+	}
+	return fmt.Sprintf("%s failed: %v", command, err)
+}
+
+// maintenanceError reports a failed maintenance step on a table or index
+func maintenanceError(stage, target string, err error) *ToolResult {
+	return Error(fmt.Sprintf("Vector index maintenance failed: stage='%s', target='%s', error=%v", stage, target, err), "QUERY_ERROR", map[string]interface{}{
+		"stage":  stage,
+		"target": target,
+		"error":  err.Error(),
+	})
+}
+
+// recallParams reads measure_recall, k and sample_queries
+func recallParams(params map[string]interface{}) (measure bool, k, samples int, invalid *ToolResult) {
+	measure, _ = params["measure_recall"].(bool)
+	if k, invalid = intParamInRange(params, "k", defaultBenchmarkK, 1, maxBenchmarkK); invalid != nil {
+		return
+	}
+	samples, invalid = intParamInRange(params, "sample_queries", defaultDriftRecallQueries, 1, maxDriftRecallQueries)
+	return
+}
+
+// recallSchema are the parameters of a recall measurement
+func recallSchema(measureDescription string) map[string]interface{} {
+	return map[string]interface{}{
+		"measure_recall": map[string]interface{}{
+			"type":        "boolean",
+			"default":     false,
+			"description": measureDescription,
+		},
+		"k": map[string]interface{}{
+			"type":        "integer",
+			"default":     defaultBenchmarkK,
+			"minimum":     1,
+			"maximum":     maxBenchmarkK,
+			"description": "k of recall@k",
+		},
+		"sample_queries": map[string]interface{}{
+			"type":        "integer",
+			"default":     defaultDriftRecallQueries,
+			"minimum":     1,
+			"maximum":     maxDriftRecallQueries,
+			"description": "Vectors sampled from the table as recall queries",
+		},
+	}
+}
+
+// VectorIndexDriftTool measures how far vector indexes drifted from the
+// data they were built over
+type VectorIndexDriftTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewVectorIndexDriftTool creates a new vector index drift tool
+func NewVectorIndexDriftTool(db *database.Database, logger *logging.Logger) *VectorIndexDriftTool {
+	properties := map[string]interface{}{
+		"tables": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Tables whose vector indexes to check, as table or schema.table; all when absent",
+		},
+		"indexes": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Indexes to check, as index or schema.index; all when absent",
+		},
+		"drift_threshold": map[string]interface{}{
+			"type":        "number",
+			"default":     defaultDriftThreshold,
+			"minimum":     0,
+			"description": "Share of rows changed since the baseline at which a rebuild is recommended",
+		},
+		"recall_drop_threshold": map[string]interface{}{
+			"type":        "number",
+			"default":     defaultRecallDropThreshold,
+			"minimum":     0,
+			"maximum":     1,
+			"description": "Drop in recall@k since the baseline at which a rebuild is recommended",
+		},
+	}
+	for name, schema := range recallSchema("Estimate each index's recall@k against exact search; this runs 2 searches per sampled query") {
+		properties[name] = schema
+	}
+	return &VectorIndexDriftTool{
+		BaseTool: NewBaseTool(
+			"vector_index_drift",
+			"Measure drift of HNSW and IVF indexes: rows inserted, updated and deleted since each index's baseline as a share of the rows it was built over, dead rows, and optionally recall@k against exact search and its drop since the baseline; recommends vacuum or rebuild",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute reports the drift of the vector indexes
+func (t *VectorIndexDriftTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for vector_index_drift tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	tables, invalid := parseIdentifierList(params, "tables")
+	if invalid != nil {
+		return invalid, nil
+	}
+	indexNames, invalid := parseIdentifierList(params, "indexes")
+	if invalid != nil {
+		return invalid, nil
+	}
+	driftThreshold := defaultDriftThreshold
+	if v, ok := params["drift_threshold"].(float64); ok {
+		driftThreshold = v
+	}
+	recallDropThreshold := defaultRecallDropThreshold
+	if v, ok := params["recall_drop_threshold"].(float64); ok {
+		recallDropThreshold = v
+	}
+	measure, k, samples, invalid := recallParams(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for vector_index_drift", "DATABASE_ERROR", nil), nil
+	}
+	relations := append(append([]string{}, tables...), indexNames...)
+	if missing, err := missingTables(ctx, db, relations); err != nil {
+		return maintenanceError("relations", strings.Join(relations, ", "), err), nil
+	} else if len(missing) > 0 {
+		return Error(fmt.Sprintf("Relations do not exist: %s", strings.Join(missing, ", ")), "NOT_FOUND", map[string]interface{}{
+			"missing": missing,
+		}), nil
+	}
+	indexes, err := readVectorIndexes(ctx, db, tables, indexNames)
+	if err != nil {
+		return maintenanceError("indexes", "", err), nil
+	}
+	baselines, err := readIndexBaselines(ctx, db, indexes)
+	if err != nil {
+		return maintenanceError("baselines", vectorIndexBaselineTable, err), nil
+	}
+
+	recallCtx, cancel := context.WithTimeout(ctx, BenchmarkTimeout)
+	defer cancel()
+	results := make([]map[string]interface{}, len(indexes))
+	needsRebuild := []string{}
+	for i, idx := range indexes {
+		if measure {
+			reportProgress(ctx, float64(i), float64(len(indexes)), "measuring recall of "+idx.qualifiedName())
+		}
+		entry := map[string]interface{}{
+			"index":         idx.qualifiedName(),
+			"table":         idx.TableSchema + "." + idx.TableName,
+			"method":        idx.Method,
+			"column":        idx.Column,
+			"metric":        idx.metric(),
+			"valid":         idx.Valid,
+			"size_bytes":    idx.SizeBytes,
+			"index_scans":   idx.Scans,
+			"live_rows":     idx.LiveRows,
+			"dead_rows":     idx.DeadRows,
+			"dead_fraction": idx.deadFraction(),
+		}
+		baseline := baselines[idx.qualifiedName()]
+		var drift *indexDrift
+		if baseline != nil {
+			entry["baseline"] = baseline
+			if drift = computeIndexDrift(idx, baseline); drift != nil {
+				entry["drift"] = drift
+			}
+		}
+
+		var recallDrop *float64
+		if measure {
+			recall, err := measureIndexRecall(recallCtx, db, idx, k, samples)
+			if err != nil {
+				entry["recall_error"] = err.Error()
+				t.logger.Warn("Recall measurement of vector index failed", map[string]interface{}{
+					"index": idx.qualifiedName(),
+					"error": err.Error(),
+				})
+			} else {
+				entry["recall"] = recall
+				// Recall at different k is not comparable
+				if baseline != nil && baseline.Recall != nil && baseline.RecallK != nil && *baseline.RecallK == k {
+					drop := *baseline.Recall - recall.RecallAtK
+					recallDrop = &drop
+					entry["recall_drop"] = drop
+				}
+			}
+		}
+
+		advice := adviseIndexMaintenance(idx, baseline, drift, recallDrop, driftThreshold, recallDropThreshold)
+		entry["recommendation"] = advice
+		for _, action := range advice.Actions {
+			if action == "rebuild" {
+				needsRebuild = append(needsRebuild, idx.qualifiedName())
+			}
+		}
+		results[i] = entry
+	}
+
+	return Success(map[string]interface{}{
+		"indexes_checked": len(indexes),
+		"needs_rebuild":   needsRebuild,
+		"indexes":         results,
+	}, map[string]interface{}{
+		"drift_threshold":       driftThreshold,
+		"recall_drop_threshold": recallDropThreshold,
+		"measure_recall":        measure,
+		"k":                     k,
+	}), nil
+}
+
+// RecordVectorIndexBaselineTool records the baseline vector_index_drift
+// measures drift from
+type RecordVectorIndexBaselineTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewRecordVectorIndexBaselineTool creates a new record vector index
+// baseline tool
+func NewRecordVectorIndexBaselineTool(db *database.Database, logger *logging.Logger) *RecordVectorIndexBaselineTool {
+	properties := map[string]interface{}{
+		"indexes": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"minItems":    1,
+			"description": "Indexes to record, as index or schema.index",
+		},
+	}
+	for name, schema := range recallSchema("Also measure and record recall@k, so vector_index_drift can report its drop") {
+		properties[name] = schema
+	}
+	return &RecordVectorIndexBaselineTool{
+		BaseTool: NewBaseTool(
+			"record_vector_index_baseline",
+			"Record the current state of HNSW or IVF indexes' tables, and optionally their recall@k, as the baseline vector_index_drift measures drift from; rebuild_vector_index records one after each rebuild",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   []interface{}{"indexes"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute records the baselines
+func (t *RecordVectorIndexBaselineTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for record_vector_index_baseline tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	names, invalid := parseIdentifierList(params, "indexes")
+	if invalid != nil {
+		return invalid, nil
+	}
+	measure, k, samples, invalid := recallParams(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for record_vector_index_baseline", "DATABASE_ERROR", nil), nil
+	}
+	if missing, err := missingTables(ctx, db, names); err != nil {
+		return maintenanceError("indexes", strings.Join(names, ", "), err), nil
+	} else if len(missing) > 0 {
+		return Error(fmt.Sprintf("Indexes do not exist: %s", strings.Join(missing, ", ")), "NOT_FOUND", map[string]interface{}{
+			"missing": missing,
+		}), nil
+	}
+	indexes, err := readVectorIndexes(ctx, db, nil, names)
+	if err != nil {
+		return maintenanceError("indexes", strings.Join(names, ", "), err), nil
+	}
+	if len(indexes) < len(names) {
+		return Error("Every index must be an HNSW or IVF index", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "indexes",
+			"found":     len(indexes),
+			"requested": len(names),
+		}), nil
+	}
+
+	recallCtx, cancel := context.WithTimeout(ctx, BenchmarkTimeout)
+	defer cancel()
+	recorded := make([]map[string]interface{}, len(indexes))
+	for i, idx := range indexes {
+		var recall *indexRecall
+		if measure {
+			reportProgress(ctx, float64(i), float64(len(indexes)), "measuring recall of "+idx.qualifiedName())
+			if recall, err = measureIndexRecall(recallCtx, db, idx, k, samples); err != nil {
+				return maintenanceError("recall", idx.qualifiedName(), err), nil
+			}
+		}
+		baseline, err := recordIndexBaseline(ctx, db, idx, "recorded", recall)
+		if err != nil {
+			return maintenanceError("record", idx.qualifiedName(), err), nil
+		}
+		recorded[i] = map[string]interface{}{
+			"index":    idx.qualifiedName(),
+			"table":    idx.TableSchema + "." + idx.TableName,
+			"baseline": baseline,
+		}
+	}
+	return Success(map[string]interface{}{
+		"recorded": recorded,
+	}, map[string]interface{}{
+		"measure_recall": measure,
+		"k":              k,
+	}), nil
+}
+
+// RebuildVectorIndexTool rebuilds a vector index, concurrently by default,
+// and records a new baseline
+type RebuildVectorIndexTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewRebuildVectorIndexTool creates a new rebuild vector index tool
+func NewRebuildVectorIndexTool(db *database.Database, logger *logging.Logger) *RebuildVectorIndexTool {
+	properties := map[string]interface{}{
+		"index": map[string]interface{}{
+			"type":        "string",
+			"description": "Index to rebuild, as index or schema.index",
+		},
+		"concurrently": map[string]interface{}{
+			"type":        "boolean",
+			"default":     true,
+			"description": "Rebuild with REINDEX CONCURRENTLY, so reads and writes continue; false blocks writes to the table while it runs",
+		},
+		"timeout_ms": map[string]interface{}{
+			"type":        "integer",
+			"default":     defaultRebuildTimeoutMs,
+			"minimum":     1000,
+			"maximum":     maxRebuildTimeoutMs,
+			"description": "statement_timeout of the REINDEX in milliseconds",
+		},
+	}
+	for name, schema := range recallSchema("Measure recall@k after the rebuild and record it in the new baseline") {
+		properties[name] = schema
+	}
+	return &RebuildVectorIndexTool{
+		BaseTool: NewBaseTool(
+			"rebuild_vector_index",
+			"Rebuild an HNSW or IVF index with REINDEX CONCURRENTLY, reporting its phase and block or tuple progress while it runs, then record a new drift baseline, optionally with recall@k",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   []interface{}{"index"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute rebuilds the index
+func (t *RebuildVectorIndexTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for rebuild_vector_index tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	concurrently := true
+	if v, ok := params["concurrently"].(bool); ok {
+		concurrently = v
+	}
+	timeoutMs, invalid := intParamInRange(params, "timeout_ms", defaultRebuildTimeoutMs, 1000, maxRebuildTimeoutMs)
+	if invalid != nil {
+		return invalid, nil
+	}
+	measure, k, samples, invalid := recallParams(params)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for rebuild_vector_index", "DATABASE_ERROR", nil), nil
+	}
+	idx, failure := resolveVectorIndex(ctx, db, params)
+	if failure != nil {
+		return failure, nil
+	}
+
+	command := "REINDEX INDEX " + idx.identifier()
+	note := fmt.Sprintf("rebuilds the %s index while blocking writes to the table, with statement_timeout %d ms", idx.Method, timeoutMs)
+	if concurrently {
+		command = "REINDEX INDEX CONCURRENTLY " + idx.identifier()
+		note = fmt.Sprintf("builds a new %s index beside the old one and swaps them, without blocking reads or writes, with statement_timeout %d ms", idx.Method, timeoutMs)
+	}
+	if IsDryRun(ctx) {
+		tableName := idx.table().Sanitize()
+		one := int64(1)
+		return dryRunResult(ctx, db, t.Name(), []PlannedStatement{
+			{SQL: command, rowsOf: tableName, Note: note},
+			{
+				SQL:           recordIndexBaselineSQL,
+				Params:        []interface{}{idx.qualifiedName(), idx.TableSchema + "." + idx.TableName, "rebuild"},
+				EstimatedRows: &one,
+				Note:          "records the rebuilt index's drift baseline from the table's statistics after the rebuild, creating " + vectorIndexBaselineTable + " on first use",
+			},
+		}, []Permission{
+			tablePermission("OWNER", tableName),
+			schemaPermission("CREATE", "neurondb_mcp"),
+		}), nil
+	}
+
+	start := time.Now()
+	reportProgress(ctx, 0, 0, "rebuilding "+idx.qualifiedName())
+	if err := runMaintenanceCommand(ctx, db, command, timeoutMs, createIndexProgressView); err != nil {
+		t.logger.Error("Rebuild of vector index failed", err, map[string]interface{}{
+			"index": idx.qualifiedName(),
+		})
+		details := map[string]interface{}{
+			"index":   idx.qualifiedName(),
+			"command": command,
+			"error":   err.Error(),
+		}
+		// A failed REINDEX CONCURRENTLY leaves its new index behind, invalid
+		if leftovers, lerr := leftoverRebuildIndexes(ctx, db, idx); lerr == nil && len(leftovers) > 0 {
+			details["invalid_indexes"] = leftovers
+			details["hint"] = "drop the invalid indexes with drop_index before rebuilding again"
+		}
+		return Error(fmt.Sprintf("Rebuild of index '%s' failed: %s", idx.qualifiedName(), maintenanceFailure("REINDEX", err, timeoutMs)), "INDEX_ERROR", details), nil
+	}
+	duration := msSince(start)
+
+	rebuilt, err := readVectorIndexes(ctx, db, nil, []string{idx.identifier()})
+	if err != nil || len(rebuilt) == 0 {
+		if err == nil {
+			err = fmt.Errorf("index not found after rebuild")
+		}
+		return maintenanceError("statistics", idx.qualifiedName(), err), nil
+	}
+	after := rebuilt[0]
+	result := map[string]interface{}{
+		"index":             after.qualifiedName(),
+		"table":             after.TableSchema + "." + after.TableName,
+		"method":            after.Method,
+		"concurrently":      concurrently,
+		"duration_ms":       duration,
+		"size_bytes_before": idx.SizeBytes,
+		"size_bytes_after":  after.SizeBytes,
+		"valid":             after.Valid,
+	}
+	var recall *indexRecall
+	if measure {
+		reportProgress(ctx, 0, 0, "measuring recall of "+after.qualifiedName())
+		recallCtx, cancel := context.WithTimeout(ctx, BenchmarkTimeout)
+		recall, err = measureIndexRecall(recallCtx, db, after, k, samples)
+		cancel()
+		if err != nil {
+			result["recall_error"] = err.Error()
+		} else {
+			result["recall"] = recall
+		}
+	}
+	baseline, err := recordIndexBaseline(ctx, db, after, "rebuild", recall)
+	if err != nil {
+		result["baseline_error"] = err.Error()
+	} else {
+		result["baseline"] = baseline
+	}
+	return Success(result, map[string]interface{}{
+		"timeout_ms": timeoutMs,
+	}), nil
+}
+
+// leftoverRebuildIndexes returns the invalid indexes a failed concurrent
+// rebuild of idx left on its table, named after it with a _ccnew suffix
+func leftoverRebuildIndexes(ctx context.Context, db *database.Database, idx *vectorIndex) ([]string, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	rows, err := db.Query(queryCtx, `
+		SELECT n.nspname || '.' || c.relname
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE i.indrelid = to_regclass($1) AND NOT i.indisvalid
+		  AND starts_with(c.relname, left($2, 57) || '_ccnew')
+		ORDER BY c.relname`, idx.table().Sanitize(), idx.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// VacuumVectorTableTool vacuums a table with vector indexes
+type VacuumVectorTableTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewVacuumVectorTableTool creates a new vacuum vector table tool
+func NewVacuumVectorTableTool(db *database.Database, logger *logging.Logger) *VacuumVectorTableTool {
+	return &VacuumVectorTableTool{
+		BaseTool: NewBaseTool(
+			"vacuum_vector_table",
+			"Run VACUUM on a table with vector indexes, removing dead rows from the table and its HNSW and IVF indexes without blocking reads or writes, reporting its phase and progress while it runs and dead rows before and after",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"analyze": map[string]interface{}{
+						"type":        "boolean",
+						"default":     true,
+						"description": "Also refresh planner statistics (VACUUM (ANALYZE))",
+					},
+					"timeout_ms": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultVacuumTimeoutMs,
+						"minimum":     1000,
+						"maximum":     maxRebuildTimeoutMs,
+						"description": "statement_timeout of the VACUUM in milliseconds",
+					},
+				},
+				"required": []interface{}{"table"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute vacuums the table
+func (t *VacuumVectorTableTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for vacuum_vector_table tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	name, _ := params["table"].(string)
+	table, err := parseQualifiedIdentifier(name)
+	if err != nil {
+		return Error(fmt.Sprintf("Invalid table name '%s': %v", name, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	runAnalyze := true
+	if v, ok := params["analyze"].(bool); ok {
+		runAnalyze = v
+	}
+	timeoutMs, invalid := intParamInRange(params, "timeout_ms", defaultVacuumTimeoutMs, 1000, maxRebuildTimeoutMs)
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for vacuum_vector_table", "DATABASE_ERROR", nil), nil
+	}
+	if missing, err := missingTables(ctx, db, []string{table.Sanitize()}); err != nil {
+		return maintenanceError("table", name, err), nil
+	} else if len(missing) > 0 {
+		return Error(fmt.Sprintf("Table '%s' does not exist", name), "NOT_FOUND", map[string]interface{}{
+			"table": name,
+		}), nil
+	}
+	before, err := readVectorIndexes(ctx, db, []string{table.Sanitize()}, nil)
+	if err != nil {
+		return maintenanceError("indexes", name, err), nil
+	}
+	if len(before) == 0 {
+		return Error(fmt.Sprintf("Table '%s' has no HNSW or IVF index", name), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"table":     name,
+		}), nil
+	}
+
+	command := "VACUUM " + table.Sanitize()
+	if runAnalyze {
+		command = "VACUUM (ANALYZE) " + table.Sanitize()
+	}
+	if IsDryRun(ctx) {
+		return dryRunResult(ctx, db, t.Name(), []PlannedStatement{{
+			SQL:    command,
+			rowsOf: table.Sanitize(),
+			Note:   fmt.Sprintf("removes dead rows from the table and its %d vector indexes without blocking reads or writes, with statement_timeout %d ms; live rows are not changed", len(before), timeoutMs),
+		}}, []Permission{tablePermission("OWNER", table.Sanitize())}), nil
+	}
+
+	start := time.Now()
+	reportProgress(ctx, 0, 0, "vacuuming "+table.Sanitize())
+	if err := runMaintenanceCommand(ctx, db, command, timeoutMs, vacuumProgressView); err != nil {
+		t.logger.Error("Vacuum of vector table failed", err, map[string]interface{}{
+			"table": table.Sanitize(),
+		})
+		return Error(fmt.Sprintf("Vacuum of table '%s' failed: %s", name, maintenanceFailure("VACUUM", err, timeoutMs)), "QUERY_ERROR", map[string]interface{}{
+			"table":   name,
+			"command": command,
+			"error":   err.Error(),
+		}), nil
+	}
+	duration := msSince(start)
+
+	after, err := readVectorIndexes(ctx, db, []string{table.Sanitize()}, nil)
+	if err != nil {
+		return maintenanceError("statistics", name, err), nil
+	}
+	sizesBefore := map[string]int64{}
+	for _, idx := range before {
+		sizesBefore[idx.qualifiedName()] = idx.SizeBytes
+	}
+	indexes := make([]map[string]interface{}, len(after))
+	for i, idx := range after {
+		indexes[i] = map[string]interface{}{
+			"index":             idx.qualifiedName(),
+			"size_bytes_before": sizesBefore[idx.qualifiedName()],
+			"size_bytes_after":  idx.SizeBytes,
+		}
+	}
+	result := map[string]interface{}{
+		"table":            before[0].TableSchema + "." + before[0].TableName,
+		"analyzed":         runAnalyze,
+		"duration_ms":      duration,
+		"dead_rows_before": before[0].DeadRows,
+		"indexes":          indexes,
+	}
+	if len(after) > 0 {
+		// The statistics collector can take a moment to show the vacuum
+		result["dead_rows_after"] = after[0].DeadRows
+	}
+	return Success(result, map[string]interface{}{
+		"timeout_ms": timeoutMs,
+	}), nil
+}
+
+// VectorIndexMaintenanceStatusTool reports running vector index builds and
+// vacuums and the locks they hold or wait for
+type VectorIndexMaintenanceStatusTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewVectorIndexMaintenanceStatusTool creates a new vector index
+// maintenance status tool
+func NewVectorIndexMaintenanceStatusTool(db *database.Database, logger *logging.Logger) *VectorIndexMaintenanceStatusTool {
+	return &VectorIndexMaintenanceStatusTool{
+		BaseTool: NewBaseTool(
+			"vector_index_maintenance_status",
+			"Report index builds, rebuilds and vacuums running on tables with HNSW or IVF indexes with their phase and progress, locks that block writes or maintenance on those tables with the sessions waiting for them, and invalid vector indexes left by failed concurrent builds",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tables": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Tables to report, as table or schema.table; all tables with vector indexes when absent",
+					},
+				},
+				"required": []interface{}{},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// vectorRelationsSQL selects the tables with a vector index and their
+// vector indexes, narrowed to the tables in $1 when it is not empty
+const vectorRelationsSQL = `
+	SELECT r FROM (
+		SELECT i.indrelid AS t, unnest(ARRAY[i.indrelid, i.indexrelid]) AS r
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_am am ON am.oid = c.relam
+		WHERE ` + vectorIndexMethodCondition + `
+	) v
+	WHERE cardinality($1::text[]) = 0 OR v.t IN (SELECT to_regclass(x) FROM unnest($1::text[]) AS x)`
+
+// Execute reports maintenance status
+func (t *VectorIndexMaintenanceStatusTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for vector_index_maintenance_status tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	tables, invalid := parseIdentifierList(params, "tables")
+	if invalid != nil {
+		return invalid, nil
+	}
+	if tables == nil {
+		tables = []string{}
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for vector_index_maintenance_status", "DATABASE_ERROR", nil), nil
+	}
+	if missing, err := missingTables(ctx, db, tables); err != nil {
+		return maintenanceError("tables", strings.Join(tables, ", "), err), nil
+	} else if len(missing) > 0 {
+		return Error(fmt.Sprintf("Tables do not exist: %s", strings.Join(missing, ", ")), "NOT_FOUND", map[string]interface{}{
+			"missing": missing,
+		}), nil
+	}
+
+	operations, err := readMaintenanceOperations(ctx, db, tables)
+	if err != nil {
+		return maintenanceError("operations", strings.Join(tables, ", "), err), nil
+	}
+	locks, err := readMaintenanceLocks(ctx, db, tables)
+	if err != nil {
+		return maintenanceError("locks", strings.Join(tables, ", "), err), nil
+	}
+	indexes, err := readVectorIndexes(ctx, db, tables, nil)
+	if err != nil {
+		return maintenanceError("indexes", strings.Join(tables, ", "), err), nil
+	}
+	invalidIndexes := []map[string]interface{}{}
+	for _, idx := range indexes {
+		if !idx.Valid {
+			invalidIndexes = append(invalidIndexes, map[string]interface{}{
+				"index":      idx.qualifiedName(),
+				"table":      idx.TableSchema + "." + idx.TableName,
+				"method":     idx.Method,
+				"size_bytes": idx.SizeBytes,
+			})
+		}
+	}
+	waiting := 0
+	for _, l := range locks {
+		if !l["granted"].(bool) {
+			waiting++
+		}
+	}
+
+	return Success(map[string]interface{}{
+		"operations":      operations,
+		"locks":           locks,
+		"waiting":         waiting,
+		"invalid_indexes": invalidIndexes,
+	}, nil), nil
+}
+
+// readMaintenanceOperations reads the index builds and vacuums running on
+// vector tables from pg_stat_progress_create_index and
+// pg_stat_progress_vacuum
+func readMaintenanceOperations(ctx context.Context, db *database.Database, tables []string) ([]map[string]interface{}, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	rows, err := db.Query(queryCtx, `
+		WITH vector_relations AS (`+vectorRelationsSQL+`)
+		SELECT p.pid, p.command, p.phase, p.relid::regclass::text, COALESCE(p.index_relid::regclass::text, ''),
+		       p.blocks_done, p.blocks_total, p.tuples_done, p.tuples_total,
+		       a.query_start, COALESCE(a.wait_event_type, ''), COALESCE(a.wait_event, ''), pg_blocking_pids(p.pid)
+		FROM pg_stat_progress_create_index p
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database()
+		  AND (p.relid IN (SELECT r FROM vector_relations) OR p.index_relid IN (
+		       SELECT c.oid FROM pg_class c JOIN pg_am am ON am.oid = c.relam WHERE `+vectorIndexMethodCondition+`))
+		  AND (cardinality($1::text[]) = 0 OR p.relid IN (SELECT to_regclass(x) FROM unnest($1::text[]) AS x))
+		UNION ALL
+		SELECT p.pid, CASE WHEN a.backend_type = 'autovacuum worker' THEN 'AUTOVACUUM' ELSE 'VACUUM' END, p.phase,
+		       p.relid::regclass::text, '',
+		       p.heap_blks_scanned, p.heap_blks_total, 0::bigint, 0::bigint,
+		       a.query_start, COALESCE(a.wait_event_type, ''), COALESCE(a.wait_event, ''), pg_blocking_pids(p.pid)
+		FROM pg_stat_progress_vacuum p
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database()
+		  AND p.relid IN (SELECT r FROM vector_relations)
+		ORDER BY 1`, tables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	operations := []map[string]interface{}{}
+	for rows.Next() {
+		var pid int32
+		var command, phase, table, index, waitType, waitEvent string
+		var blocksDone, blocksTotal, tuplesDone, tuplesTotal int64
+		var started *time.Time
+		var blockedBy []int32
+		if err := rows.Scan(&pid, &command, &phase, &table, &index, &blocksDone, &blocksTotal, &tuplesDone, &tuplesTotal,
+			&started, &waitType, &waitEvent, &blockedBy); err != nil {
+			return nil, err
+		}
+		op := map[string]interface{}{
+			"pid":          pid,
+			"command":      command,
+			"phase":        phase,
+			"table":        table,
+			"blocks_done":  blocksDone,
+			"blocks_total": blocksTotal,
+			"tuples_done":  tuplesDone,
+			"tuples_total": tuplesTotal,
+			"blocked_by":   blockedBy,
+		}
+		if index != "" && index != "-" {
+			op["index"] = index
+		}
+		if done, total, ok := progressFraction(blocksDone, blocksTotal, tuplesDone, tuplesTotal); ok {
+			op["progress"] = done / total
+		}
+		if started != nil {
+			op["started_at"] = started
+			op["duration_ms"] = msSince(*started)
+		}
+		if waitType != "" {
+			op["wait_event"] = waitType + ":" + waitEvent
+		}
+		operations = append(operations, op)
+	}
+	return operations, rows.Err()
+}
+
+// readMaintenanceLocks reads the relation locks on vector tables and their
+// vector indexes that block writes or maintenance, granted or awaited,
+// with the sessions blocking each awaited one
+func readMaintenanceLocks(ctx context.Context, db *database.Database, tables []string) ([]map[string]interface{}, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	rows, err := db.Query(queryCtx, `
+		WITH vector_relations AS (`+vectorRelationsSQL+`)
+		SELECT l.pid, l.relation::regclass::text, l.mode, l.granted, COALESCE(a.state, ''),
+		       a.query_start, left(COALESCE(a.query, ''), $2), pg_blocking_pids(l.pid)
+		FROM pg_locks l
+		LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'relation'
+		  AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		  AND l.relation IN (SELECT r FROM vector_relations)
+		  AND (NOT l.granted OR l.mode IN ('ShareUpdateExclusiveLock', 'ShareLock', 'ShareRowExclusiveLock', 'ExclusiveLock', 'AccessExclusiveLock'))
+		  AND l.pid <> pg_backend_pid()
+		ORDER BY l.granted DESC, l.pid`, tables, maxLockQueryLength)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := []map[string]interface{}{}
+	for rows.Next() {
+		var pid int32
+		var relation, mode, state, query string
+		var granted bool
+		var started *time.Time
+		var blockedBy []int32
+		if err := rows.Scan(&pid, &relation, &mode, &granted, &state, &started, &query, &blockedBy); err != nil {
+			return nil, err
+		}
+		lock := map[string]interface{}{
+			"pid":      pid,
+			"relation": relation,
+			"mode":     mode,
+			"granted":  granted,
+			"state":    state,
+			"query":    query,
+		}
+		if started != nil {
+			lock["query_start"] = started
+		}
+		if !granted {
+			sort.Slice(blockedBy, func(i, j int) bool { return blockedBy[i] < blockedBy[j] })
+			lock["blocked_by"] = blockedBy
+		}
+		locks = append(locks, lock)
+	}
+	return locks, rows.Err()
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestOpClassMetric(t *testing.T) {
+	tests := map[string]string{
+		"vector_l2_ops":     "l2",
+		"vector_cosine_ops": "cosine",
+		"vector_ip_ops":     "inner_product",
+		"halfvec_ip_ops":    "inner_product",
+		"":                  "l2",
+	}
+	for opClass, want := range tests {
+		if got := opClassMetric(opClass); got != want {
+			t.Errorf("opClassMetric(%q) = %q, want %q", opClass, got, want)
+		}
+	}
+}
+
+func TestComputeIndexDrift(t *testing.T) {
+	baseline := &indexBaseline{LiveRows: 1000, Inserted: 1000, Updated: 50, Deleted: 10}
+	idx := &vectorIndex{Inserted: 1200, Updated: 150, Deleted: 60}
+	drift := computeIndexDrift(idx, baseline)
+	if drift == nil {
+		t.Fatal("drift is nil")
+	}
+	if drift.Inserted != 200 || drift.Updated != 100 || drift.Deleted != 50 {
+		t.Errorf("drift counts = %+v", drift)
+	}
+	if drift.DriftFraction != 0.35 || drift.InsertedFraction != 0.2 {
+		t.Errorf("fractions = %v, %v, want 0.35 and 0.2", drift.DriftFraction, drift.InsertedFraction)
+	}
+
+	// Counters below the baseline mean statistics were reset
+	if drift := computeIndexDrift(&vectorIndex{Inserted: 5}, baseline); drift != nil {
+		t.Errorf("drift after reset = %+v, want nil", drift)
+	}
+
+	// A baseline over an empty table counts every row as drift
+	drift = computeIndexDrift(&vectorIndex{Inserted: 30}, &indexBaseline{})
+	if drift == nil || drift.DriftFraction != 30 {
+		t.Errorf("drift from empty baseline = %+v", drift)
+	}
+}
+
+func TestAdviseIndexMaintenance(t *testing.T) {
+	baseline := &indexBaseline{LiveRows: 1000}
+	small := &indexDrift{DriftFraction: 0.05}
+	large := &indexDrift{Inserted: 300, DriftFraction: 0.3}
+	drop := 0.1
+	tests := []struct {
+		name       string
+		idx        *vectorIndex
+		baseline   *indexBaseline
+		drift      *indexDrift
+		recallDrop *float64
+		want       []string
+	}{
+		{"healthy", &vectorIndex{Method: "hnsw", Valid: true, LiveRows: 1000}, baseline, small, nil, []string{}},
+		{"no baseline", &vectorIndex{Method: "hnsw", Valid: true}, nil, nil, nil, []string{"record_baseline"}},
+		{"statistics reset", &vectorIndex{Method: "hnsw", Valid: true}, baseline, nil, nil, []string{"record_baseline"}},
+		{"drifted", &vectorIndex{Method: "ivfflat", Valid: true}, baseline, large, nil, []string{"rebuild"}},
+		{"recall dropped", &vectorIndex{Method: "hnsw", Valid: true}, baseline, small, &drop, []string{"rebuild"}},
+		{"invalid and drifted", &vectorIndex{Method: "hnsw"}, baseline, large, nil, []string{"rebuild"}},
+		{"dead rows", &vectorIndex{Method: "hnsw", Valid: true, LiveRows: 700, DeadRows: 300}, baseline, large, nil, []string{"vacuum", "rebuild"}},
+	}
+	for _, tt := range tests {
+		advice := adviseIndexMaintenance(tt.idx, tt.baseline, tt.drift, tt.recallDrop, 0.2, 0.05)
+		if !reflect.DeepEqual(advice.Actions, tt.want) {
+			t.Errorf("%s: actions = %v, want %v", tt.name, advice.Actions, tt.want)
+		}
+		if len(advice.Reasons) < len(advice.Actions) {
+			t.Errorf("%s: %d reasons for %d actions", tt.name, len(advice.Reasons), len(advice.Actions))
+		}
+	}
+
+	advice := adviseIndexMaintenance(&vectorIndex{Method: "ivfflat", Valid: true}, baseline, large, nil, 0.2, 0.05)
+	if !strings.Contains(advice.Reasons[0], "IVF lists") {
+		t.Errorf("IVF reason = %q", advice.Reasons[0])
+	}
+}
+
+func TestProgressFraction(t *testing.T) {
+	if done, total, ok := progressFraction(5, 10, 100, 200); !ok || done != 5 || total != 10 {
+		t.Errorf("blocks: %v/%v ok=%v", done, total, ok)
+	}
+	if done, total, ok := progressFraction(0, 0, 100, 200); !ok || done != 100 || total != 200 {
+		t.Errorf("tuples: %v/%v ok=%v", done, total, ok)
+	}
+	if _, _, ok := progressFraction(0, 0, 0, 0); ok {
+		t.Error("no totals reported progress")
+	}
+}
+
+func TestMaintenanceFailure(t *testing.T) {
+	timeout := &pgconn.PgError{Code: "57014"}
+	if got := maintenanceFailure("REINDEX", timeout, 1000); got != "REINDEX exceeded timeout_ms (1000)" {
+		t.Errorf("timeout = %q", got)
+	}
+	if got := maintenanceFailure("VACUUM", errors.New("boom"), 1000); got != "VACUUM failed: boom" {
+		t.Errorf("failure = %q", got)
+	}
+}
+
+func TestProgressRequested(t *testing.T) {
+	if progressRequested(context.Background()) {
+		t.Error("progress requested without a reporter")
+	}
+	ctx := WithProgress(context.Background(), func(progress, total float64, message string) {})
+	if !progressRequested(ctx) {
+		t.Error("progress not requested with a reporter")
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import "fmt"
+
+// DefaultToolVersion is the schema version of a tool that does not set one
+const DefaultToolVersion = 1
+
+// VersionedTool is implemented by tools that report the version of their
+// input schema and result. Tools embedding BaseTool implement it.
+type VersionedTool interface {
+	Version() int
+}
+
+// ToolVersion returns the schema version of a tool
+func ToolVersion(tool Tool) int {
+	if versioned, ok := tool.(VersionedTool); ok && versioned.Version() > 0 {
+		return versioned.Version()
+	}
+	return DefaultToolVersion
+}
+
+// CompatibilityAdapter lets clients built against the previous version of
+// a tool's schema (N-1) keep calling it. Calls requesting that version are
+// validated against InputSchema, their arguments converted by Arguments and
+// the tool's result converted back by Result.
+type CompatibilityAdapter struct {
+	InputSchema map[string]interface{}
+	Arguments   func(arguments map[string]interface{}) (map[string]interface{}, error)
+	Result      func(result *ToolResult) *ToolResult // optional
+}
+
+// UnsupportedVersionError is returned for a call requesting a version of a
+// tool that the registry can no longer serve
+type UnsupportedVersionError struct {
+	Tool      string
+	Requested int
+	Versions  []int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("tool '%s' does not support version %d: supported versions are %v", e.Tool, e.Requested, e.Versions)
+}
+
+// RegisterAdapter registers the adapter serving calls for the version
+// before the current one of a registered tool, replacing any earlier one
+func (r *ToolRegistry) RegisterAdapter(name string, adapter CompatibilityAdapter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tool, exists := r.tools[name]
+	if !exists {
+		return fmt.Errorf("cannot register an adapter for unknown tool '%s'", name)
+	}
+	version := ToolVersion(tool)
+	if version <= DefaultToolVersion {
+		return fmt.Errorf("tool '%s' is at version %d and has no previous version to adapt", name, version)
+	}
+	if adapter.InputSchema == nil || adapter.Arguments == nil {
+		return fmt.Errorf("adapter for tool '%s' needs an input schema and an arguments converter", name)
+	}
+	r.adapters[name] = adapter
+	def := r.definitions[name]
+	def.Versions = []int{version, version - 1}
+	r.definitions[name] = def
+	return nil
+}
+
+// ResolveVersion returns the schema version serving a call to a tool and,
+// for calls requesting the previous version, its adapter. A requested
+// version of 0 means the current one.
+func (r *ToolRegistry) ResolveVersion(name string, requested int) (int, *CompatibilityAdapter, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tool, exists := r.tools[name]
+	if !exists {
+		return 0, nil, fmt.Errorf("tool not found: %s", name)
+	}
+	version := ToolVersion(tool)
+	if requested == 0 || requested == version {
+		return version, nil, nil
+	}
+	if adapter, ok := r.adapters[name]; ok && requested == version-1 {
+		return requested, &adapter, nil
+	}
+	versions := append([]int(nil), r.definitions[name].Versions...)
+	return 0, nil, &UnsupportedVersionError{Tool: name, Requested: requested, Versions: versions}
+}
@@ -0,0 +1,610 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/neurondb/NeuronMCP/internal/database"
+	"github.com/neurondb/NeuronMCP/internal/logging"
+)
+
+// Visualization limits
+const (
+	defaultVisualizeSampleSize = 1000
+	maxVisualizeSampleSize     = 10000
+	maxVisualizeMetadata       = 8
+	// pcaIterations bounds the power iterations finding each component
+	pcaIterations = 200
+	// pcaTolerance ends the iterations once the component stops turning
+	pcaTolerance = 1e-9
+	// visualizeDecimals is the precision of the returned coordinates
+	visualizeDecimals = 4
+)
+
+// defaultClusterColumn is the column cluster_vectors writes, used for
+// coloring when cluster_column is not given
+const defaultClusterColumn = "cluster_id"
+
+// clusterPalette colors clusters in order of size; larger cluster counts
+// reuse it
+var clusterPalette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// noiseColor colors rows without a cluster, including cluster -1 (noise)
+const noiseColor = "#c7c7c7"
+
+// visualizeRequest is a validated visualize_embeddings call
+type visualizeRequest struct {
+	table           pgx.Identifier
+	tableName       string
+	vectorColumn    string
+	idColumn        string
+	labelColumn     string
+	clusterColumn   string
+	metadataColumns []string
+	sampleSize      int
+	normalize       bool
+}
+
+// VisualizeEmbeddingsTool projects a sample of embeddings to 2D for plotting
+type VisualizeEmbeddingsTool struct {
+	*BaseTool
+	db     *database.Database
+	logger *logging.Logger
+}
+
+// NewVisualizeEmbeddingsTool creates a new embedding visualization tool
+func NewVisualizeEmbeddingsTool(db *database.Database, logger *logging.Logger) *VisualizeEmbeddingsTool {
+	return &VisualizeEmbeddingsTool{
+		BaseTool: NewBaseTool(
+			"visualize_embeddings",
+			"Sample rows of a vector table and project their embeddings to 2D with PCA, returning compact columnar JSON of coordinates, ids, labels, metadata and cluster colors for plotting",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table": map[string]interface{}{
+						"type":        "string",
+						"description": "Table name, optionally schema-qualified",
+					},
+					"vector_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the vector column",
+					},
+					"id_column": map[string]interface{}{
+						"type":        "string",
+						"default":     "id",
+						"description": "Column identifying each point",
+					},
+					"label_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Column shown as each point's label, such as a title",
+					},
+					"cluster_column": map[string]interface{}{
+						"type":        "string",
+						"description": "Column coloring the points by cluster; defaults to cluster_id when the table has it, as written by cluster_vectors",
+					},
+					"metadata_columns": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"maxItems":    maxVisualizeMetadata,
+						"description": "Further columns returned for each point, as text",
+					},
+					"sample_size": map[string]interface{}{
+						"type":        "integer",
+						"default":     defaultVisualizeSampleSize,
+						"minimum":     3,
+						"maximum":     maxVisualizeSampleSize,
+						"description": "Rows sampled and projected",
+					},
+					"normalize": map[string]interface{}{
+						"type":        "boolean",
+						"default":     false,
+						"description": "Scale vectors to unit length before the projection, so it reflects cosine rather than L2 distances",
+					},
+				},
+				"required": []interface{}{"table", "vector_column"},
+			},
+		),
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Execute samples and projects the embeddings
+func (t *VisualizeEmbeddingsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	valid, errors := t.ValidateParams(params, t.InputSchema())
+	if !valid {
+		return Error(fmt.Sprintf("Invalid parameters for visualize_embeddings tool: %v", errors), "VALIDATION_ERROR", map[string]interface{}{
+			"errors": errors,
+			"params": params,
+		}), nil
+	}
+	req, errResult := parseVisualizeRequest(params)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	db := DatabaseFromContext(ctx, t.db)
+	if db == nil || !db.IsConnected() {
+		return Error("database connection not available for visualize_embeddings", "DATABASE_ERROR", map[string]interface{}{
+			"table": req.tableName,
+		}), nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+	start := time.Now()
+
+	var estimatedRows int64
+	var columns []string
+	err := db.QueryRow(queryCtx, `
+		SELECT c.reltuples::bigint,
+		       ARRAY(SELECT a.attname::text FROM pg_attribute a
+		             WHERE a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped)
+		FROM pg_class c
+		WHERE c.oid = to_regclass($1)`, req.table.Sanitize()).Scan(&estimatedRows, &columns)
+	if err == pgx.ErrNoRows {
+		return Error(fmt.Sprintf("Table '%s' does not exist", req.tableName), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		}), nil
+	}
+	if err != nil {
+		return t.visualizeError(req, "table lookup", err), nil
+	}
+	if errResult := req.resolveColumns(columns); errResult != nil {
+		return errResult, nil
+	}
+
+	query, method := visualizeSampleQuery(req, estimatedRows)
+	sample, err := readVisualizeSample(queryCtx, db, req, query)
+	if err != nil {
+		return t.visualizeError(req, "sample", err), nil
+	}
+	if len(sample.vectors) < 3 {
+		return Error(fmt.Sprintf("Table '%s' has %d sampled rows with a '%s' vector, at least 3 are needed", req.tableName, len(sample.vectors), req.vectorColumn), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+			"rows":      len(sample.vectors),
+		}), nil
+	}
+	if req.normalize {
+		for _, vec := range sample.vectors {
+			if norm := vectorNorm(vec); norm > 0 {
+				for i := range vec {
+					vec[i] = float32(float64(vec[i]) / norm)
+				}
+			}
+		}
+	}
+
+	projection := projectPCA(sample.vectors, 2)
+	x, y := projection.coordinates[0], projection.coordinates[1]
+	result := map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"method":        "pca",
+		"dimension":     len(sample.vectors[0]),
+		"points":        len(x),
+		"ids":           sample.ids,
+		"x":             roundAll(x, visualizeDecimals),
+		"y":             roundAll(y, visualizeDecimals),
+		"bounds": map[string]interface{}{
+			"x": []float64{minOf(x), maxOf(x)},
+			"y": []float64{minOf(y), maxOf(y)},
+		},
+		"explained_variance_ratio": roundAll(projection.explained, visualizeDecimals),
+		"sample": map[string]interface{}{
+			"method":         method,
+			"estimated_rows": estimatedRows,
+		},
+	}
+	if req.labelColumn != "" {
+		result["labels"] = sample.labels
+	}
+	if len(req.metadataColumns) > 0 {
+		metadata := make(map[string]interface{}, len(req.metadataColumns))
+		for i, column := range req.metadataColumns {
+			metadata[column] = sample.metadata[i]
+		}
+		result["metadata"] = metadata
+	}
+	if req.clusterColumn != "" {
+		result["cluster_column"] = req.clusterColumn
+		result["clusters"] = sample.clusters
+		result["cluster_colors"] = clusterColors(sample.clusters)
+	}
+
+	return Success(result, map[string]interface{}{
+		"tool":        "visualize_embeddings",
+		"sample_size": req.sampleSize,
+		"elapsed_ms":  msSince(start),
+	}), nil
+}
+
+// parseVisualizeRequest validates the visualize_embeddings parameters,
+// returning a validation error result when they are unusable
+func parseVisualizeRequest(params map[string]interface{}) (visualizeRequest, *ToolResult) {
+	req := visualizeRequest{
+		tableName:     stringParam(params, "table", ""),
+		vectorColumn:  stringParam(params, "vector_column", ""),
+		idColumn:      stringParam(params, "id_column", "id"),
+		labelColumn:   stringParam(params, "label_column", ""),
+		clusterColumn: stringParam(params, "cluster_column", ""),
+	}
+	req.normalize, _ = params["normalize"].(bool)
+	table, err := parseQualifiedIdentifier(req.tableName)
+	if err != nil {
+		return req, Error(fmt.Sprintf("Invalid table '%s': %v", req.tableName, err), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "table",
+		})
+	}
+	req.table = table
+	if req.vectorColumn == "" {
+		return req, Error("vector_column parameter is required and cannot be empty for visualize_embeddings tool", "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "vector_column",
+		})
+	}
+	var errResult *ToolResult
+	if req.sampleSize, errResult = intParamInRange(params, "sample_size", defaultVisualizeSampleSize, 3, maxVisualizeSampleSize); errResult != nil {
+		return req, errResult
+	}
+
+	list, _ := params["metadata_columns"].([]interface{})
+	if len(list) > maxVisualizeMetadata {
+		return req, Error(fmt.Sprintf("At most %d metadata_columns can be returned, got %d", maxVisualizeMetadata, len(list)), "VALIDATION_ERROR", map[string]interface{}{
+			"parameter": "metadata_columns",
+		})
+	}
+	seen := map[string]bool{}
+	for _, item := range list {
+		column, _ := item.(string)
+		if column == "" || seen[column] {
+			return req, Error(fmt.Sprintf("metadata_columns must be distinct column names, got %v", item), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": "metadata_columns",
+			})
+		}
+		seen[column] = true
+		req.metadataColumns = append(req.metadataColumns, column)
+	}
+	return req, nil
+}
+
+// resolveColumns checks the requested columns against those of the table
+// and picks the default cluster column when the table has it
+func (req *visualizeRequest) resolveColumns(columns []string) *ToolResult {
+	exists := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		exists[column] = true
+	}
+	if req.clusterColumn == "" && exists[defaultClusterColumn] && defaultClusterColumn != req.vectorColumn {
+		req.clusterColumn = defaultClusterColumn
+	}
+	check := []struct{ parameter, column string }{
+		{"vector_column", req.vectorColumn},
+		{"id_column", req.idColumn},
+		{"label_column", req.labelColumn},
+		{"cluster_column", req.clusterColumn},
+	}
+	for _, column := range req.metadataColumns {
+		check = append(check, struct{ parameter, column string }{"metadata_columns", column})
+	}
+	for _, c := range check {
+		if c.column != "" && !exists[c.column] {
+			return Error(fmt.Sprintf("Column '%s' does not exist in table '%s'", c.column, req.tableName), "VALIDATION_ERROR", map[string]interface{}{
+				"parameter": c.parameter,
+			})
+		}
+	}
+	return nil
+}
+
+// visualizeSampleQuery reads the sampled rows: id, vector, then the label,
+// cluster and metadata columns requested, all as text. Large tables are
+// sampled with BERNOULLI rather than sorting every row.
+func visualizeSampleQuery(req visualizeRequest, estimatedRows int64) (string, string) {
+	vector := pgx.Identifier{req.vectorColumn}.Sanitize()
+	selected := []string{
+		pgx.Identifier{req.idColumn}.Sanitize() + "::text",
+		vector + "::text",
+	}
+	for _, column := range append([]string{req.labelColumn, req.clusterColumn}, req.metadataColumns...) {
+		if column != "" {
+			selected = append(selected, pgx.Identifier{column}.Sanitize()+"::text")
+		}
+	}
+	from := req.table.Sanitize()
+	order := " ORDER BY random()"
+	method := "random"
+	if estimatedRows > int64(req.sampleSize)*10 {
+		percent := math.Min(100, float64(req.sampleSize)*3/float64(estimatedRows)*100)
+		from += " TABLESAMPLE BERNOULLI (" + strconv.FormatFloat(percent, 'f', 6, 64) + ")"
+		order = ""
+		method = "bernoulli"
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s IS NOT NULL%s LIMIT $1",
+		strings.Join(selected, ", "), from, vector, order), method
+}
+
+// visualizeSample is the sampled rows in columns
+type visualizeSample struct {
+	ids      []*string
+	vectors  [][]float32
+	labels   []*string
+	clusters []*string
+	metadata [][]*string
+}
+
+// readVisualizeSample runs the sample query, rejecting vectors whose
+// dimension differs from the first
+func readVisualizeSample(ctx context.Context, db *database.Database, req visualizeRequest, query string) (*visualizeSample, error) {
+	rows, err := db.Query(ctx, query, req.sampleSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sample := &visualizeSample{metadata: make([][]*string, len(req.metadataColumns))}
+	for rows.Next() {
+		var id, text, label, cluster *string
+		dest := []interface{}{&id, &text}
+		if req.labelColumn != "" {
+			dest = append(dest, &label)
+		}
+		if req.clusterColumn != "" {
+			dest = append(dest, &cluster)
+		}
+		metadata := make([]*string, len(req.metadataColumns))
+		for i := range metadata {
+			dest = append(dest, &metadata[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if text == nil {
+			continue
+		}
+		vec, err := parseVectorText(*text)
+		if err != nil {
+			return nil, fmt.Errorf("column '%s' is not a vector column: %w", req.vectorColumn, err)
+		}
+		if !isFiniteVector(vec) {
+			continue
+		}
+		if len(sample.vectors) > 0 && len(vec) != len(sample.vectors[0]) {
+			return nil, fmt.Errorf("column '%s' mixes dimensions %d and %d; profile_vector_table reports them", req.vectorColumn, len(sample.vectors[0]), len(vec))
+		}
+		sample.ids = append(sample.ids, id)
+		sample.vectors = append(sample.vectors, vec)
+		if req.labelColumn != "" {
+			sample.labels = append(sample.labels, label)
+		}
+		if req.clusterColumn != "" {
+			sample.clusters = append(sample.clusters, cluster)
+		}
+		for i, value := range metadata {
+			sample.metadata[i] = append(sample.metadata[i], value)
+		}
+	}
+	return sample, rows.Err()
+}
+
+// pcaProjection is the data projected on its principal components
+type pcaProjection struct {
+	// coordinates[c][i] is row i on component c
+	coordinates [][]float64
+	// explained is the share of the total variance of each component
+	explained []float64
+}
+
+// projectPCA projects vecs on their first n principal components, found by
+// power iteration on the covariance with deflation. The covariance is never
+// formed: each iteration multiplies by the centered data twice, which keeps
+// it linear in the number of values.
+func projectPCA(vecs [][]float32, n int) pcaProjection {
+	rows, dim := len(vecs), len(vecs[0])
+	mean := make([]float64, dim)
+	for _, vec := range vecs {
+		for j, v := range vec {
+			mean[j] += float64(v)
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(rows)
+	}
+	centered := make([][]float64, rows)
+	totalVariance := 0.0
+	for i, vec := range vecs {
+		centered[i] = make([]float64, dim)
+		for j, v := range vec {
+			centered[i][j] = float64(v) - mean[j]
+			totalVariance += centered[i][j] * centered[i][j]
+		}
+	}
+
+	projection := pcaProjection{
+		coordinates: make([][]float64, n),
+		explained:   make([]float64, n),
+	}
+	var components [][]float64
+	for c := 0; c < n; c++ {
+		component := principalComponent(centered, components, c)
+		components = append(components, component)
+
+		scores := make([]float64, rows)
+		variance := 0.0
+		for i, row := range centered {
+			scores[i] = dot(row, component)
+			variance += scores[i] * scores[i]
+		}
+		projection.coordinates[c] = scores
+		if totalVariance > 0 {
+			projection.explained[c] = variance / totalVariance
+		}
+	}
+	return projection
+}
+
+// principalComponent finds the direction of greatest variance of centered
+// orthogonal to the earlier components. The start vector is fixed per
+// component so projections are reproducible for the same sample.
+func principalComponent(centered [][]float64, earlier [][]float64, index int) []float64 {
+	dim := len(centered[0])
+	v := make([]float64, dim)
+	for j := range v {
+		// A deterministic start that is unlikely to be orthogonal to the
+		// component
+		v[j] = 1 + float64((j*7+index*13)%11)/11
+	}
+	orthogonalize(v, earlier)
+	if !normalize(v) {
+		return v
+	}
+
+	scores := make([]float64, len(centered))
+	for iter := 0; iter < pcaIterations; iter++ {
+		for i, row := range centered {
+			scores[i] = dot(row, v)
+		}
+		next := make([]float64, dim)
+		for i, row := range centered {
+			for j, x := range row {
+				next[j] += scores[i] * x
+			}
+		}
+		orthogonalize(next, earlier)
+		if !normalize(next) {
+			// No variance is left outside the earlier components
+			return next
+		}
+		change := 0.0
+		for j := range next {
+			d := next[j] - v[j]
+			change += d * d
+		}
+		v = next
+		if change < pcaTolerance {
+			break
+		}
+	}
+	// Fix the sign so the largest coordinate is positive
+	largest := 0
+	for j := range v {
+		if math.Abs(v[j]) > math.Abs(v[largest]) {
+			largest = j
+		}
+	}
+	if v[largest] < 0 {
+		for j := range v {
+			v[j] = -v[j]
+		}
+	}
+	return v
+}
+
+// orthogonalize removes from v its projection on each of the unit vectors
+func orthogonalize(v []float64, units [][]float64) {
+	for _, u := range units {
+		d := dot(v, u)
+		for j := range v {
+			v[j] -= d * u[j]
+		}
+	}
+}
+
+// normalize scales v to unit length, reporting false when it is zero
+func normalize(v []float64) bool {
+	norm := math.Sqrt(dot(v, v))
+	if norm < 1e-12 {
+		for j := range v {
+			v[j] = 0
+		}
+		return false
+	}
+	for j := range v {
+		v[j] /= norm
+	}
+	return true
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// clusterColors maps each cluster of the sample to a color, assigned in
+// order of cluster size. Rows without a cluster and cluster -1 (noise)
+// are grey.
+func clusterColors(clusters []*string) map[string]string {
+	counts := map[string]int{}
+	for _, cluster := range clusters {
+		if cluster != nil && *cluster != "-1" {
+			counts[*cluster]++
+		}
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	colors := make(map[string]string, len(names)+1)
+	for i, name := range names {
+		colors[name] = clusterPalette[i%len(clusterPalette)]
+	}
+	colors["-1"] = noiseColor
+	return colors
+}
+
+// roundAll rounds the values to the given decimals, keeping the JSON small
+func roundAll(values []float64, decimals int) []float64 {
+	scale := math.Pow(10, float64(decimals))
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = math.Round(v*scale) / scale
+	}
+	return out
+}
+
+func minOf(values []float64) float64 {
+	m := math.Inf(1)
+	for _, v := range values {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+func maxOf(values []float64) float64 {
+	m := math.Inf(-1)
+	for _, v := range values {
+		m = math.Max(m, v)
+	}
+	return m
+}
+
+func (t *VisualizeEmbeddingsTool) visualizeError(req visualizeRequest, stage string, err error) *ToolResult {
+	t.logger.Error("Embedding visualization failed", err, map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"stage":         stage,
+	})
+	return Error(fmt.Sprintf("Embedding visualization failed while reading %s: table='%s', vector_column='%s', error=%v", stage, req.tableName, req.vectorColumn, err), "VISUALIZE_ERROR", map[string]interface{}{
+		"table":         req.tableName,
+		"vector_column": req.vectorColumn,
+		"stage":         stage,
+		"error":         err.Error(),
+	})
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestProjectPCA(t *testing.T) {
+	// Points spread widely along (1, 1, 0), narrowly along (0, 0, 1)
+	var vecs [][]float32
+	for i := -5; i <= 5; i++ {
+		for _, z := range []float32{-0.5, 0.5} {
+			vecs = append(vecs, []float32{float32(i), float32(i), z})
+		}
+	}
+	projection := projectPCA(vecs, 2)
+	if len(projection.coordinates) != 2 || len(projection.coordinates[0]) != len(vecs) {
+		t.Fatalf("coordinates shape = %d x %d", len(projection.coordinates), len(projection.coordinates[0]))
+	}
+	// The first component is (1, 1, 0)/sqrt(2), so x is i*sqrt(2)
+	if x := projection.coordinates[0][len(vecs)-1]; math.Abs(x-5*math.Sqrt2) > 1e-6 {
+		t.Errorf("x of (5, 5, 0.5) = %g, want %g", x, 5*math.Sqrt2)
+	}
+	if y := projection.coordinates[1][1]; math.Abs(math.Abs(y)-0.5) > 1e-6 {
+		t.Errorf("|y| of (-5, -5, 0.5) = %g, want 0.5", math.Abs(y))
+	}
+	total := projection.explained[0] + projection.explained[1]
+	if projection.explained[0] < 0.98 || math.Abs(total-1) > 1e-9 {
+		t.Errorf("explained variance = %v", projection.explained)
+	}
+
+	// Identical vectors have no variance to project
+	flat := projectPCA([][]float32{{1, 2}, {1, 2}, {1, 2}}, 2)
+	for c, coords := range flat.coordinates {
+		for i, v := range coords {
+			if v != 0 || math.IsNaN(v) {
+				t.Errorf("coordinate %d of row %d = %g, want 0", c, i, v)
+			}
+		}
+	}
+}
+
+func TestClusterColors(t *testing.T) {
+	s := func(v string) *string { return &v }
+	clusters := []*string{s("2"), s("0"), s("2"), nil, s("-1"), s("0"), s("2"), s("1")}
+	colors := clusterColors(clusters)
+	want := map[string]string{
+		"2":  clusterPalette[0],
+		"0":  clusterPalette[1],
+		"1":  clusterPalette[2],
+		"-1": noiseColor,
+	}
+	if len(colors) != len(want) {
+		t.Errorf("clusterColors = %v, want %v", colors, want)
+	}
+	for cluster, color := range want {
+		if colors[cluster] != color {
+			t.Errorf("cluster %s colored %s, want %s", cluster, colors[cluster], color)
+		}
+	}
+}
+
+func TestParseVisualizeRequest(t *testing.T) {
+	params := map[string]interface{}{
+		"table":            "public.docs",
+		"vector_column":    "embedding",
+		"label_column":     "title",
+		"metadata_columns": []interface{}{"source", "lang"},
+		"sample_size":      float64(500),
+	}
+	req, invalid := parseVisualizeRequest(params)
+	if invalid != nil {
+		t.Fatalf("unexpected validation error: %+v", invalid)
+	}
+	if invalid := req.resolveColumns([]string{"id", "title", "embedding", "source", "lang", "cluster_id"}); invalid != nil {
+		t.Fatalf("unexpected column error: %+v", invalid)
+	}
+	if req.clusterColumn != defaultClusterColumn {
+		t.Errorf("cluster column = %q, want the default %q", req.clusterColumn, defaultClusterColumn)
+	}
+
+	query, method := visualizeSampleQuery(req, 1000)
+	if method != "random" || !strings.Contains(query, `SELECT "id"::text, "embedding"::text, "title"::text, "cluster_id"::text, "source"::text, "lang"::text FROM "public"."docs" WHERE "embedding" IS NOT NULL ORDER BY random() LIMIT $1`) {
+		t.Errorf("query = %s (%s)", query, method)
+	}
+	if query, method := visualizeSampleQuery(req, 1000000); method != "bernoulli" || !strings.Contains(query, "TABLESAMPLE BERNOULLI (0.150000)") {
+		t.Errorf("large table query = %s (%s)", query, method)
+	}
+
+	if invalid := req.resolveColumns([]string{"id", "title", "embedding", "source"}); invalid == nil {
+		t.Error("expected an error for the missing metadata column")
+	}
+	params["metadata_columns"] = []interface{}{"source", "source"}
+	if _, invalid := parseVisualizeRequest(params); invalid == nil {
+		t.Error("expected an error for duplicate metadata columns")
+	}
+}
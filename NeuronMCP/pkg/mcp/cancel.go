@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Notifications a client sends to cancel one of its in-flight requests: the
+// MCP notifications/cancelled and the LSP-style $/cancelRequest
+const (
+	MethodCancelled     = "notifications/cancelled"
+	MethodCancelRequest = "$/cancelRequest"
+)
+
+// CancelledNotification holds the parameters of a cancellation. MCP names
+// the request in requestId, $/cancelRequest in id.
+type CancelledNotification struct {
+	RequestID json.RawMessage `json:"requestId,omitempty"`
+	ID        json.RawMessage `json:"id,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// IsCancellation reports whether msg is a notification cancelling a request
+func IsCancellation(msg *JSONRPCRequest) bool {
+	return IsNotification(msg) && (msg.Method == MethodCancelled || msg.Method == MethodCancelRequest)
+}
+
+// inflightRequest is a request read from the client that has not been
+// answered yet
+type inflightRequest struct {
+	req    *JSONRPCRequest
+	ctx    context.Context
+	cancel context.CancelFunc
+	// cancelled is set when the client cancelled the request; it then gets
+	// no response
+	cancelled bool
+}
+
+// requestKey identifies a request by its JSON-RPC id
+func requestKey(id json.RawMessage) string {
+	return strings.TrimSpace(string(id))
+}
+
+// track registers a request so the client can cancel it, and returns it
+// with a context that ends when it is cancelled
+func (s *Server) track(ctx context.Context, req *JSONRPCRequest) *inflightRequest {
+	reqCtx, cancel := context.WithCancel(ctx)
+	r := &inflightRequest{req: req, ctx: reqCtx, cancel: cancel}
+	if IsNotification(req) || req.Method == "initialize" {
+		// Neither can be cancelled: notifications have no id, and the
+		// spec forbids cancelling initialize
+		return r
+	}
+	s.inflightMu.Lock()
+	s.inflight[requestKey(req.ID)] = r
+	s.inflightMu.Unlock()
+	return r
+}
+
+// untrack removes a finished request and reports whether the client
+// cancelled it
+func (s *Server) untrack(r *inflightRequest) bool {
+	defer r.cancel()
+	if IsNotification(r.req) {
+		return false
+	}
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+	key := requestKey(r.req.ID)
+	if s.inflight[key] == r {
+		delete(s.inflight, key)
+	}
+	return r.cancelled
+}
+
+// cancelRequest cancels the context of the in-flight request named by a
+// cancellation notification. Unknown or finished requests are ignored, as
+// the cancellation may cross the response.
+func (s *Server) cancelRequest(msg *JSONRPCRequest) {
+	var params CancelledNotification
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		s.transport.WriteError(fmt.Errorf("ignoring malformed %s notification: %w", msg.Method, err))
+		return
+	}
+	id := params.RequestID
+	if len(id) == 0 {
+		id = params.ID
+	}
+
+	s.inflightMu.Lock()
+	r, ok := s.inflight[requestKey(id)]
+	if ok {
+		r.cancelled = true
+	}
+	s.inflightMu.Unlock()
+
+	if !ok {
+		s.transport.WriteError(fmt.Errorf("ignoring cancellation of unknown request %s", string(id)))
+		return
+	}
+	s.transport.WriteError(fmt.Errorf("cancelling request %s (%s): %s", string(id), r.req.Method, params.Reason))
+	r.cancel()
+}
@@ -0,0 +1,120 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pipeServer runs s over pipes and returns functions to send a message and
+// read the next one
+func pipeServer(t *testing.T, s *Server) (send func(string), read func() map[string]interface{}, stop func()) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s.transport = &StdioTransport{
+		stdin:  bufio.NewReader(inR),
+		stdout: bufio.NewWriter(outW),
+		stderr: io.Discard,
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(context.Background()) }()
+
+	out := bufio.NewReader(outR)
+	send = func(msg string) {
+		t.Helper()
+		if _, err := fmt.Fprintln(inW, msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	read = func() map[string]interface{} {
+		t.Helper()
+		line, err := out.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		return msg
+	}
+	stop = func() {
+		inW.Close()
+		select {
+		case <-runErr:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run() did not return after EOF")
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test"}}}`)
+	read() // initialize response
+	read() // notifications/initialized
+	return send, read, stop
+}
+
+func TestServer_ConcurrentRequestsAndCancellation(t *testing.T) {
+	s := NewServer("test", "1.0")
+	blocked := make(chan struct{})
+	s.SetHandler("test/block", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		close(blocked)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	s.SetHandler("test/echo", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	})
+	send, read, stop := pipeServer(t, s)
+	defer stop()
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"test/block"}`)
+	<-blocked
+	send(`{"jsonrpc":"2.0","id":2,"method":"test/echo"}`)
+	if resp := read(); resp["id"] != float64(2) {
+		t.Fatalf("expected the response to request 2 while 1 is running, got %v", resp)
+	}
+
+	send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1,"reason":"test"}}`)
+	send(`{"jsonrpc":"2.0","id":3,"method":"test/echo"}`)
+	if resp := read(); resp["id"] != float64(3) {
+		t.Fatalf("cancelled request 1 should get no response, got %v", resp)
+	}
+}
+
+func TestServer_CancelQueuedRequest(t *testing.T) {
+	s := NewServer("test", "1.0")
+	s.SetMaxConcurrentRequests(1)
+	blocked := make(chan struct{})
+	s.SetHandler("test/block", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		close(blocked)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	var echoed int32
+	s.SetHandler("test/echo", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		atomic.AddInt32(&echoed, 1)
+		return "ok", nil
+	})
+	send, read, stop := pipeServer(t, s)
+	defer stop()
+
+	send(`{"jsonrpc":"2.0","id":"a","method":"test/block"}`)
+	<-blocked
+	send(`{"jsonrpc":"2.0","id":"b","method":"test/echo"}`)
+	send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"b"}}`)
+	send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"a"}}`)
+	send(`{"jsonrpc":"2.0","id":"c","method":"test/echo"}`)
+
+	if resp := read(); resp["id"] != "c" {
+		t.Fatalf("expected only the response to request c, got %v", resp)
+	}
+	if n := atomic.LoadInt32(&echoed); n != 1 {
+		t.Errorf("echo handler ran %d times, want 1: the queued request should not run", n)
+	}
+}
@@ -58,8 +58,23 @@ const (
 	ErrCodeToolNotFound    = -32001
 	ErrCodeResourceNotFound = -32002
 	ErrCodeExecutionError  = -32003
+	ErrCodeToolDenied      = -32004
+	ErrCodeSamplingUnavailable = -32005
+	ErrCodeRootsUnavailable    = -32006
 )
 
+// Error is a handler error carrying a specific JSON-RPC error code. Handlers
+// return it when a failure should not be reported as an internal error.
+type Error struct {
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
 // SerializeResponse serializes a JSON-RPC response to JSON
 func SerializeResponse(resp *JSONRPCResponse) ([]byte, error) {
 	return json.Marshal(resp)
@@ -83,3 +98,8 @@ func IsNotification(req *JSONRPCRequest) bool {
 	return len(req.ID) == 0 || bytes.Equal(req.ID, []byte("null"))
 }
 
+
+// IsResponse checks if a message is a response to a server-initiated request
+func IsResponse(msg *JSONRPCRequest) bool {
+	return msg.Method == "" && !IsNotification(msg) && (msg.Result != nil || msg.Error != nil)
+}
@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MethodRootsListChanged is the notification a client sends when its roots
+// change
+const MethodRootsListChanged = "notifications/roots/list_changed"
+
+// DefaultRootsTimeout bounds a roots/list request when the caller's context
+// has no deadline
+const DefaultRootsTimeout = 10 * time.Second
+
+// ErrRootsNotSupported is returned when the client did not declare the roots
+// capability in initialize
+var ErrRootsNotSupported = &Error{
+	Code:    ErrCodeRootsUnavailable,
+	Message: "client does not support roots",
+}
+
+// ClientSupportsRoots reports whether the client declared the roots
+// capability in initialize
+func (s *Server) ClientSupportsRoots() bool {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	_, ok := s.clientCaps["roots"]
+	return ok
+}
+
+// ListRoots returns the filesystem roots the client shares (roots/list).
+// The list is cached until the client sends
+// notifications/roots/list_changed. Like CreateMessage it must be called
+// while Run is active.
+func (s *Server) ListRoots(ctx context.Context) ([]Root, error) {
+	if !s.ClientSupportsRoots() {
+		return nil, ErrRootsNotSupported
+	}
+	s.rootsMu.Lock()
+	if s.rootsCached {
+		roots := s.roots
+		s.rootsMu.Unlock()
+		return roots, nil
+	}
+	gen := s.rootsGen
+	s.rootsMu.Unlock()
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRootsTimeout)
+		defer cancel()
+	}
+	raw, err := s.request(ctx, "roots/list", struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	var result ListRootsResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse roots/list result: %w", err)
+	}
+	if result.Roots == nil {
+		result.Roots = []Root{}
+	}
+
+	// A change reported while the request was out makes the answer stale
+	// for later calls, so it is not cached
+	s.rootsMu.Lock()
+	if s.rootsGen == gen {
+		s.roots, s.rootsCached = result.Roots, true
+	}
+	s.rootsMu.Unlock()
+	return result.Roots, nil
+}
+
+// handleRootsListChanged drops the cached roots, so the next ListRoots asks
+// the client again
+func (s *Server) handleRootsListChanged(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	s.invalidateRoots()
+	return nil, nil
+}
+
+func (s *Server) invalidateRoots() {
+	s.rootsMu.Lock()
+	s.roots, s.rootsCached = nil, false
+	s.rootsGen++
+	s.rootsMu.Unlock()
+}
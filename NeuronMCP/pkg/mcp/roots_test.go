@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestListRoots_CachedUntilChanged(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := NewServer("test", "1.0")
+	s.transport = &StdioTransport{
+		stdin:  bufio.NewReader(inR),
+		stdout: bufio.NewWriter(outW),
+		stderr: io.Discard,
+	}
+	s.SetHandler("test/roots", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		roots, err := s.ListRoots(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"roots": roots}, nil
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(context.Background()) }()
+
+	out := bufio.NewReader(outR)
+	readMsg := func() map[string]interface{} {
+		t.Helper()
+		line, err := out.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		return msg
+	}
+	send := func(msg string) {
+		t.Helper()
+		if _, err := fmt.Fprintln(inW, msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	answerRoots := func(uri string) {
+		t.Helper()
+		req := readMsg()
+		if req["method"] != "roots/list" {
+			t.Fatalf("expected roots/list request, got %v", req)
+		}
+		id, _ := json.Marshal(req["id"])
+		send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"roots":[{"uri":%q,"name":"work"}]}}`, id, uri))
+	}
+	rootURI := func(resp map[string]interface{}) string {
+		t.Helper()
+		result, _ := resp["result"].(map[string]interface{})
+		roots, _ := result["roots"].([]interface{})
+		if len(roots) != 1 {
+			t.Fatalf("unexpected response: %v", resp)
+		}
+		root, _ := roots[0].(map[string]interface{})
+		uri, _ := root["uri"].(string)
+		return uri
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"roots":{"listChanged":true}},"clientInfo":{"name":"test"}}}`)
+	readMsg() // initialize response
+	readMsg() // notifications/initialized
+
+	send(`{"jsonrpc":"2.0","id":2,"method":"test/roots"}`)
+	answerRoots("file:///home/a")
+	if uri := rootURI(readMsg()); uri != "file:///home/a" {
+		t.Fatalf("first roots = %s", uri)
+	}
+
+	// Served from the cache without asking the client
+	send(`{"jsonrpc":"2.0","id":3,"method":"test/roots"}`)
+	if uri := rootURI(readMsg()); uri != "file:///home/a" {
+		t.Fatalf("cached roots = %s", uri)
+	}
+
+	send(`{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`)
+	send(`{"jsonrpc":"2.0","id":4,"method":"test/roots"}`)
+	answerRoots("file:///home/b")
+	if uri := rootURI(readMsg()); uri != "file:///home/b" {
+		t.Fatalf("roots after change = %s", uri)
+	}
+
+	inW.Close()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after EOF")
+	}
+}
+
+func TestListRoots_NotSupported(t *testing.T) {
+	s := NewServer("test", "1.0")
+	_, err := s.ListRoots(context.Background())
+	var mcpErr *Error
+	if !errors.As(err, &mcpErr) || mcpErr.Code != ErrCodeRootsUnavailable {
+		t.Errorf("ListRoots() error = %v, want roots unavailable", err)
+	}
+}
@@ -0,0 +1,120 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSamplingTimeout bounds a sampling request when the caller's context
+// has no deadline. It is generous because clients may ask the user to
+// approve the request first.
+const DefaultSamplingTimeout = 2 * time.Minute
+
+// ErrSamplingNotSupported is returned when the client did not declare the
+// sampling capability in initialize
+var ErrSamplingNotSupported = &Error{
+	Code:    ErrCodeSamplingUnavailable,
+	Message: "client does not support sampling",
+}
+
+// ClientSupportsSampling reports whether the client declared the sampling
+// capability in initialize
+func (s *Server) ClientSupportsSampling() bool {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	_, ok := s.clientCaps["sampling"]
+	return ok
+}
+
+// CreateMessage asks the client's model for a completion
+// (sampling/createMessage). It must be called from a request handler or
+// another goroutine while Run is active, since the response arrives through
+// the read loop.
+func (s *Server) CreateMessage(ctx context.Context, req *CreateMessageRequest) (*CreateMessageResult, error) {
+	if !s.ClientSupportsSampling() {
+		return nil, ErrSamplingNotSupported
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultSamplingTimeout)
+		defer cancel()
+	}
+
+	raw, err := s.request(ctx, "sampling/createMessage", req)
+	if err != nil {
+		return nil, err
+	}
+	var result CreateMessageResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse sampling/createMessage result: %w", err)
+	}
+	return &result, nil
+}
+
+// request sends a request to the client and waits for its response
+func (s *Server) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id := fmt.Sprintf("server-%d", atomic.AddInt64(&s.nextRequestID, 1))
+	ch := make(chan *JSONRPCRequest, 1)
+
+	s.pendingMu.Lock()
+	s.pending[id] = ch
+	s.pendingMu.Unlock()
+	defer func() {
+		s.pendingMu.Lock()
+		delete(s.pending, id)
+		s.pendingMu.Unlock()
+	}()
+
+	if err := s.transport.WriteRequest(id, method, params); err != nil {
+		return nil, fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s request %s: %w", method, id, ctx.Err())
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("%s request %s: connection closed", method, id)
+		}
+		if resp.Error != nil {
+			return nil, &Error{Code: resp.Error.Code, Message: resp.Error.Message, Data: resp.Error.Data}
+		}
+		return resp.Result, nil
+	}
+}
+
+// deliverResponse routes a client response to the waiting request. Responses
+// nobody is waiting for (e.g. after a timeout) are dropped.
+func (s *Server) deliverResponse(resp *JSONRPCRequest) {
+	var id string
+	if err := json.Unmarshal(resp.ID, &id); err != nil {
+		s.transport.WriteError(fmt.Errorf("dropping response with unexpected id %s", string(resp.ID)))
+		return
+	}
+
+	s.pendingMu.Lock()
+	ch, ok := s.pending[id]
+	if ok {
+		delete(s.pending, id)
+	}
+	s.pendingMu.Unlock()
+
+	if !ok {
+		s.transport.WriteError(fmt.Errorf("dropping response to unknown request %s", id))
+		return
+	}
+	ch <- resp
+}
+
+// failPending aborts every request still waiting for the client
+func (s *Server) failPending() {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	for id, ch := range s.pending {
+		close(ch)
+		delete(s.pending, id)
+	}
+}
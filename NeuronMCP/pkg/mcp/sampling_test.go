@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCreateMessage_RoundTrip(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := NewServer("test", "1.0")
+	s.transport = &StdioTransport{
+		stdin:  bufio.NewReader(inR),
+		stdout: bufio.NewWriter(outW),
+		stderr: io.Discard,
+	}
+	s.SetHandler("test/sample", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		result, err := s.CreateMessage(ctx, &CreateMessageRequest{
+			Messages:  []SamplingMessage{{Role: "user", Content: ContentBlock{Type: "text", Text: "hi"}}},
+			MaxTokens: 10,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"text": result.Content.Text}, nil
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(context.Background()) }()
+
+	out := bufio.NewReader(outR)
+	readMsg := func() map[string]interface{} {
+		t.Helper()
+		line, err := out.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		return msg
+	}
+	send := func(msg string) {
+		t.Helper()
+		if _, err := fmt.Fprintln(inW, msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"sampling":{}},"clientInfo":{"name":"test"}}}`)
+	readMsg() // initialize response
+	readMsg() // notifications/initialized
+
+	send(`{"jsonrpc":"2.0","id":2,"method":"test/sample"}`)
+	req := readMsg()
+	if req["method"] != "sampling/createMessage" {
+		t.Fatalf("expected sampling/createMessage request, got %v", req)
+	}
+	id, _ := json.Marshal(req["id"])
+	send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"role":"assistant","content":{"type":"text","text":"hello"},"model":"m"}}`, id))
+
+	resp := readMsg()
+	result, _ := resp["result"].(map[string]interface{})
+	if resp["id"] != float64(2) || result["text"] != "hello" {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+
+	inW.Close()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after EOF")
+	}
+}
+
+func TestCreateMessage_NotSupported(t *testing.T) {
+	s := NewServer("test", "1.0")
+	_, err := s.CreateMessage(context.Background(), &CreateMessageRequest{MaxTokens: 1})
+	var mcpErr *Error
+	if !errors.As(err, &mcpErr) || mcpErr.Code != ErrCodeSamplingUnavailable {
+		t.Errorf("CreateMessage() error = %v, want sampling unavailable", err)
+	}
+}
@@ -3,9 +3,11 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 )
 
 // HandlerFunc is a function that handles an MCP request
@@ -17,13 +19,49 @@ type Server struct {
 	handlers  map[string]HandlerFunc
 	info      ServerInfo
 	caps      ServerCapabilities
+
+	clientMu   sync.RWMutex
+	clientInfo  map[string]interface{}
+	clientCaps  map[string]interface{}
+	initialized bool
+
+	// Roots listed by the client, cached until it reports a change;
+	// rootsGen counts the changes
+	rootsMu     sync.Mutex
+	roots       []Root
+	rootsCached bool
+	rootsGen    int64
+
+	// Server-initiated requests awaiting a client response, keyed by ID
+	pendingMu     sync.Mutex
+	pending       map[string]chan *JSONRPCRequest
+	nextRequestID int64
+
+	// Client requests not answered yet, keyed by ID, so they can be
+	// cancelled
+	inflightMu    sync.Mutex
+	inflight      map[string]*inflightRequest
+	maxConcurrent int
 }
 
+// maxQueuedRequests bounds the requests read ahead while handlers run. The
+// read loop must keep reading during a handler so responses to
+// server-initiated requests (such as sampling) and cancellations can reach
+// it.
+const maxQueuedRequests = 1024
+
+// DefaultMaxConcurrentRequests bounds the requests handled at once
+const DefaultMaxConcurrentRequests = 8
+
 // NewServer creates a new MCP server
 func NewServer(name, version string) *Server {
 	return &Server{
 		transport: NewStdioTransport(),
 		handlers:  make(map[string]HandlerFunc),
+		pending:   make(map[string]chan *JSONRPCRequest),
+		inflight:  make(map[string]*inflightRequest),
+
+		maxConcurrent: DefaultMaxConcurrentRequests,
 		info: ServerInfo{
 			Name:    name,
 			Version: version,
@@ -40,11 +78,44 @@ func (s *Server) SetHandler(method string, handler HandlerFunc) {
 	s.handlers[method] = handler
 }
 
+// SetMaxConcurrentRequests sets how many requests are handled at once. It
+// must be called before Run; values below 1 are ignored.
+func (s *Server) SetMaxConcurrentRequests(n int) {
+	if n >= 1 {
+		s.maxConcurrent = n
+	}
+}
+
 // SetCapabilities sets server capabilities
 func (s *Server) SetCapabilities(caps ServerCapabilities) {
 	s.caps = caps
 }
 
+// ClientName returns the clientInfo.name sent by the client in initialize,
+// or "" before initialization
+func (s *Server) ClientName() string {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	name, _ := s.clientInfo["name"].(string)
+	return name
+}
+
+// Initialized reports whether the client has sent initialize
+func (s *Server) Initialized() bool {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	return s.initialized
+}
+
+// Notify sends a notification to the client. Notifications before
+// initialize are dropped, as the client cannot handle them yet.
+func (s *Server) Notify(method string, params interface{}) error {
+	if !s.Initialized() {
+		return nil
+	}
+	return s.transport.WriteNotification(method, params)
+}
+
 // HandleInitialize handles the initialize request
 func (s *Server) HandleInitialize(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var req InitializeRequest
@@ -52,6 +123,13 @@ func (s *Server) HandleInitialize(ctx context.Context, params json.RawMessage) (
 		return nil, fmt.Errorf("failed to parse initialize request: %w", err)
 	}
 
+	s.clientMu.Lock()
+	s.clientInfo = req.ClientInfo
+	s.clientCaps = req.Capabilities
+	s.initialized = true
+	s.clientMu.Unlock()
+	s.invalidateRoots()
+
 	return InitializeResponse{
 		ProtocolVersion: ProtocolVersion,
 		Capabilities:    s.caps,
@@ -59,14 +137,33 @@ func (s *Server) HandleInitialize(ctx context.Context, params json.RawMessage) (
 	}, nil
 }
 
-// Run starts the server and processes requests
+// Run starts the server and processes requests. Messages are read on the
+// calling goroutine. Requests are dispatched in arrival order and handled
+// concurrently, up to the maximum set by SetMaxConcurrentRequests, so their
+// responses may be written in a different order; clients match them by ID,
+// as JSON-RPC requires. Initialize and notifications are handled one at a
+// time, in order. Cancellations and responses to server-initiated requests
+// are handled by the read loop as they arrive.
 func (s *Server) Run(ctx context.Context) error {
 	// Register initialize handler
 	s.SetHandler("initialize", s.HandleInitialize)
+	s.SetHandler(MethodRootsListChanged, s.handleRootsListChanged)
 	
 	s.transport.WriteError(fmt.Errorf("DEBUG: Server Run() started, entering main loop"))
-	
-	var initializedSent bool
+
+	requests := make(chan *inflightRequest, maxQueuedRequests)
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		s.processRequests(ctx, requests)
+	}()
+	defer func() {
+		// Unblock a handler still waiting on the client, then let the
+		// dispatcher finish the requests already read
+		s.failPending()
+		close(requests)
+		<-workerDone
+	}()
 
 	for {
 		s.transport.WriteError(fmt.Errorf("DEBUG: Loop iteration started"))
@@ -105,54 +202,117 @@ func (s *Server) Run(ctx context.Context) error {
 				continue
 			}
 
-			// Handle initialize specially - send initialized notification
-			if req.Method == "initialize" && !initializedSent {
-				s.transport.WriteError(fmt.Errorf("DEBUG: Received initialize request"))
-				
-				resp := s.handleRequest(ctx, req)
-				
-				s.transport.WriteError(fmt.Errorf("DEBUG: Generated initialize response, hasError=%v", resp.Error != nil))
-				
-				// CRITICAL: ALWAYS send response for initialize request immediately
-				if !IsNotification(req) {
-					// Send the initialize response FIRST - must happen synchronously
-					s.transport.WriteError(fmt.Errorf("DEBUG: About to write initialize response"))
-					if err := s.transport.WriteMessage(resp); err != nil {
-						s.transport.WriteError(fmt.Errorf("CRITICAL: failed to write initialize response: %w", err))
-					} else {
-						s.transport.WriteError(fmt.Errorf("DEBUG: Initialize response written successfully"))
-					}
-					
-					// If response was successful, send initialized notification
-					if resp.Error == nil {
-						// Send initialized notification AFTER response
-						s.transport.WriteError(fmt.Errorf("DEBUG: About to write initialized notification"))
-						if err := s.transport.WriteNotification("notifications/initialized", nil); err != nil {
-							s.transport.WriteError(fmt.Errorf("failed to write initialized notification: %w", err))
-						} else {
-							s.transport.WriteError(fmt.Errorf("DEBUG: Initialized notification written successfully"))
-						}
-						initializedSent = true
-					} else {
-						// Even if there was an error, mark as initialized to prevent retry loops
-						initializedSent = true
-					}
+			if IsResponse(req) {
+				s.deliverResponse(req)
+				continue
+			}
+			if IsCancellation(req) {
+				s.cancelRequest(req)
+				continue
+			}
+
+			// Tracked from now on, so a request still queued can be
+			// cancelled too
+			select {
+			case requests <- s.track(ctx, req):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// processRequests dispatches requests in arrival order until requests is
+// closed, then waits for those still being handled
+func (s *Server) processRequests(ctx context.Context, requests <-chan *inflightRequest) {
+	var initializedSent bool
+	slots := make(chan struct{}, s.maxConcurrent)
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
+
+	for r := range requests {
+		req := r.req
+		// Handle initialize specially - send initialized notification
+		if req.Method == "initialize" && !initializedSent {
+			s.transport.WriteError(fmt.Errorf("DEBUG: Received initialize request"))
+			
+			resp := s.handleRequest(ctx, req)
+			
+			s.transport.WriteError(fmt.Errorf("DEBUG: Generated initialize response, hasError=%v", resp.Error != nil))
+			
+			// CRITICAL: ALWAYS send response for initialize request immediately
+			if !IsNotification(req) {
+				// Send the initialize response FIRST - must happen synchronously
+				s.transport.WriteError(fmt.Errorf("DEBUG: About to write initialize response"))
+				if err := s.transport.WriteMessage(resp); err != nil {
+					s.transport.WriteError(fmt.Errorf("CRITICAL: failed to write initialize response: %w", err))
+				} else {
+					s.transport.WriteError(fmt.Errorf("DEBUG: Initialize response written successfully"))
 				}
-				s.transport.WriteError(fmt.Errorf("DEBUG: Finished processing initialize, continuing loop"))
-				// Continue loop to wait for next message - server stays alive
-			} else {
-				// Handle other requests
-				resp := s.handleRequest(ctx, req)
 				
-				// Only send response if it's a request (has ID), not a notification
-				if !IsNotification(req) {
-					if err := s.transport.WriteMessage(resp); err != nil {
-						s.transport.WriteError(err)
-						continue
+				// If response was successful, send initialized notification
+				if resp.Error == nil {
+					// Send initialized notification AFTER response
+					s.transport.WriteError(fmt.Errorf("DEBUG: About to write initialized notification"))
+					if err := s.transport.WriteNotification("notifications/initialized", nil); err != nil {
+						s.transport.WriteError(fmt.Errorf("failed to write initialized notification: %w", err))
+					} else {
+						s.transport.WriteError(fmt.Errorf("DEBUG: Initialized notification written successfully"))
 					}
+					initializedSent = true
+				} else {
+					// Even if there was an error, mark as initialized to prevent retry loops
+					initializedSent = true
 				}
 			}
+			s.transport.WriteError(fmt.Errorf("DEBUG: Finished processing initialize, continuing loop"))
+			s.untrack(r)
+			continue
+		}
+
+		// Notifications get no response and may depend on each other's
+		// order
+		if IsNotification(req) {
+			s.handleRequest(r.ctx, req)
+			s.untrack(r)
+			continue
 		}
+
+		// Waiting for a free slot holds back the requests behind this one,
+		// which keeps them in the queue where they can still be cancelled.
+		// A request cancelled while queued is dropped without running.
+		acquired := false
+		select {
+		case slots <- struct{}{}:
+			acquired = true
+		case <-r.ctx.Done():
+		}
+		if r.ctx.Err() != nil {
+			if acquired {
+				<-slots
+			}
+			s.untrack(r)
+			continue
+		}
+		handlers.Add(1)
+		go func(r *inflightRequest) {
+			defer handlers.Done()
+			defer func() { <-slots }()
+			s.serveRequest(r)
+		}(r)
+	}
+}
+
+// serveRequest handles a request and writes its response, unless the
+// client cancelled it
+func (s *Server) serveRequest(r *inflightRequest) {
+	resp := s.handleRequest(r.ctx, r.req)
+	if s.untrack(r) {
+		s.transport.WriteError(fmt.Errorf("dropping response to cancelled request %s", string(r.req.ID)))
+		return
+	}
+	if err := s.transport.WriteMessage(resp); err != nil {
+		s.transport.WriteError(err)
 	}
 }
 
@@ -172,6 +332,10 @@ func (s *Server) handleRequest(ctx context.Context, req *JSONRPCRequest) *JSONRP
 	// Execute handler
 	result, err := handler(ctx, req.Params)
 	if err != nil {
+		var mcpErr *Error
+		if errors.As(err, &mcpErr) {
+			return CreateErrorResponse(req.ID, mcpErr.Code, mcpErr.Message, mcpErr.Data)
+		}
 		return CreateErrorResponse(req.ID, ErrCodeInternalError, err.Error(), nil)
 	}
 
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 )
 
 // StdioTransport handles MCP communication over stdio
@@ -14,6 +15,10 @@ type StdioTransport struct {
 	stdin  *bufio.Reader
 	stdout *bufio.Writer
 	stderr io.Writer
+
+	// writeMu serializes writes from the request loop and server-initiated
+	// requests
+	writeMu sync.Mutex
 }
 
 // NewStdioTransport creates a new stdio transport
@@ -104,6 +109,9 @@ func (t *StdioTransport) ReadMessage() (*JSONRPCRequest, error) {
 
 // WriteMessage writes a JSON-RPC message to stdout
 func (t *StdioTransport) WriteMessage(resp *JSONRPCResponse) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
 	data, err := SerializeResponse(resp)
 	if err != nil {
 		return fmt.Errorf("failed to serialize response: %w", err)
@@ -134,6 +142,9 @@ func (t *StdioTransport) WriteMessage(resp *JSONRPCResponse) error {
 
 // WriteNotification writes a JSON-RPC notification (no response expected)
 func (t *StdioTransport) WriteNotification(method string, params interface{}) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
 	notification := map[string]interface{}{
 		"jsonrpc": "2.0",
 		"method":  method,
@@ -171,6 +182,41 @@ func (t *StdioTransport) WriteNotification(method string, params interface{}) er
 	return nil
 }
 
+// WriteRequest writes a server-initiated JSON-RPC request; the client's
+// response arrives through ReadMessage
+func (t *StdioTransport) WriteRequest(id, method string, params interface{}) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+	}
+	if params != nil {
+		request["params"] = params
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	t.WriteError(fmt.Errorf("DEBUG: Writing request: %s", string(data)))
+
+	if _, err := t.stdout.Write(data); err != nil {
+		return fmt.Errorf("failed to write body: %w", err)
+	}
+	if _, err := t.stdout.Write([]byte("\n")); err != nil {
+		return fmt.Errorf("failed to write newline: %w", err)
+	}
+	if err := t.stdout.Flush(); err != nil {
+		return fmt.Errorf("failed to flush stdout: %w", err)
+	}
+
+	return nil
+}
+
 // WriteError writes an error to stderr (only in debug mode)
 func (t *StdioTransport) WriteError(err error) {
 	// Only write debug errors if DEBUG environment variable is set
@@ -8,6 +8,10 @@ type JSONRPCRequest struct {
 	ID      json.RawMessage `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
+	// Result and Error are set instead of Method when the message is the
+	// client's response to a request sent by the server
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *JSONRPCError   `json:"error,omitempty"`
 }
 
 type JSONRPCResponse struct {
@@ -31,6 +35,28 @@ type ListToolsRequest struct {
 type CallToolRequest struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
+}
+
+// RequestMeta is the _meta of a request. A client that sets ProgressToken
+// accepts notifications/progress notifications about the request. A
+// tools/call that sets ToolVersion is served with that version of the
+// tool's schema; 0 means the current version.
+type RequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+	ToolVersion   int         `json:"toolVersion,omitempty"`
+}
+
+// MethodProgress is the notification reporting the progress of a request
+const MethodProgress = "notifications/progress"
+
+// ProgressNotification holds the parameters of a progress notification.
+// Progress grows with every notification; Total is 0 when unknown.
+type ProgressNotification struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
 }
 
 type ListResourcesRequest struct {
@@ -46,6 +72,10 @@ type ToolDefinition struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	InputSchema map[string]interface{} `json:"inputSchema"`
+	// Version is the tool's schema version and Versions the versions a
+	// call may request in _meta.toolVersion, newest first
+	Version  int   `json:"version,omitempty"`
+	Versions []int `json:"versions,omitempty"`
 }
 
 type ListToolsResponse struct {
@@ -79,9 +109,65 @@ type ReadResourceResponse struct {
 }
 
 type ResourceContent struct {
-	URI      string `json:"uri"`
-	MimeType string `json:"mimeType"`
-	Text     string `json:"text"`
+	URI      string                 `json:"uri"`
+	MimeType string                 `json:"mimeType"`
+	Text     string                 `json:"text"`
+	Meta     map[string]interface{} `json:"_meta,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+type ListResourceTemplatesResponse struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+// Completion (argument autocompletion)
+
+// Completion reference types. RefTool is an extension of the protocol, which
+// only defines references to prompts and resources.
+const (
+	RefPrompt   = "ref/prompt"
+	RefResource = "ref/resource"
+	RefTool     = "ref/tool"
+)
+
+// MaxCompletionValues is the most values a completion may return
+const MaxCompletionValues = 100
+
+type CompleteReference struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+type CompleteArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CompleteContext struct {
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type CompleteRequest struct {
+	Ref      CompleteReference `json:"ref"`
+	Argument CompleteArgument  `json:"argument"`
+	Context  *CompleteContext  `json:"context,omitempty"`
+}
+
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+type CompleteResponse struct {
+	Completion Completion `json:"completion"`
 }
 
 // Server info
@@ -91,8 +177,9 @@ type ServerInfo struct {
 }
 
 type ServerCapabilities struct {
-	Tools     map[string]interface{} `json:"tools,omitempty"`
-	Resources map[string]interface{} `json:"resources,omitempty"`
+	Tools       map[string]interface{} `json:"tools,omitempty"`
+	Resources   map[string]interface{} `json:"resources,omitempty"`
+	Completions map[string]interface{} `json:"completions,omitempty"`
 }
 
 type InitializeRequest struct {
@@ -107,3 +194,50 @@ type InitializeResponse struct {
 	ServerInfo      ServerInfo         `json:"serverInfo"`
 }
 
+
+// Sampling (server-initiated LLM requests)
+
+type SamplingMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
+type ModelHint struct {
+	Name string `json:"name,omitempty"`
+}
+
+type ModelPreferences struct {
+	Hints                []ModelHint `json:"hints,omitempty"`
+	CostPriority         *float64    `json:"costPriority,omitempty"`
+	SpeedPriority        *float64    `json:"speedPriority,omitempty"`
+	IntelligencePriority *float64    `json:"intelligencePriority,omitempty"`
+}
+
+type CreateMessageRequest struct {
+	Messages         []SamplingMessage `json:"messages"`
+	ModelPreferences *ModelPreferences `json:"modelPreferences,omitempty"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`
+	IncludeContext   string            `json:"includeContext,omitempty"`
+	Temperature      *float64          `json:"temperature,omitempty"`
+	MaxTokens        int               `json:"maxTokens"`
+	StopSequences    []string          `json:"stopSequences,omitempty"`
+}
+
+type CreateMessageResult struct {
+	Role       string       `json:"role"`
+	Content    ContentBlock `json:"content"`
+	Model      string       `json:"model"`
+	StopReason string       `json:"stopReason,omitempty"`
+}
+
+// Roots (filesystem locations the client shares with the server)
+
+// Root is a directory the client lets the server work in
+type Root struct {
+	URI  string `json:"uri"` // a file:// URI
+	Name string `json:"name,omitempty"`
+}
+
+type ListRootsResult struct {
+	Roots []Root `json:"roots"`
+}
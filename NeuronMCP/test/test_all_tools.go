@@ -41,9 +41,12 @@ func TestAllToolsRegistered(t *testing.T) {
 		// ML
 		"train_model", "predict", "predict_batch", "evaluate_model", "list_models", "get_model_info", "delete_model", "export_model",
 		// Analytics
-		"analyze_data", "cluster_data", "reduce_dimensionality", "detect_outliers", "quality_metrics", "detect_drift", "topic_discovery",
+		"analyze_data", "cluster_data", "cluster_vectors", "reduce_dimensionality", "detect_outliers", "quality_metrics", "detect_drift", "topic_discovery",
 		// Time series
 		"timeseries_analysis",
+		"train_forecast_model",
+		"forecast",
+		"evaluate_forecast",
 		// AutoML
 		"automl",
 		// ONNX
@@ -51,12 +54,14 @@ func TestAllToolsRegistered(t *testing.T) {
 		// Indexing
 		"create_hnsw_index", "create_ivf_index", "index_status", "drop_index", "tune_hnsw_index", "tune_ivf_index",
 		// RAG
-		"process_document", "retrieve_context", "generate_response", "chunk_document",
+		"process_document", "retrieve_context", "generate_response", "chunk_document", "upsert_embeddings", "manage_embedding_column",
 		// Workers & GPU
-		"worker_management", "gpu_info",
+		"worker_management", "gpu_info", "configure_gpu",
 		// PostgreSQL
 		"postgresql_version", "postgresql_stats", "postgresql_databases", "postgresql_connections",
 		"postgresql_locks", "postgresql_replication", "postgresql_settings", "postgresql_extensions",
+		// SQL
+		"run_sql_readonly",
 	}
 
 	for _, toolName := range expectedTools {